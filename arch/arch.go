@@ -22,6 +22,7 @@ package arch
 import (
 	"log"
 	"runtime"
+	"syscall"
 )
 
 // ArchitectureType is the type for a supported snappy architecture
@@ -33,11 +34,22 @@ type ArchitectureType string
 // machine
 var arch = ArchitectureType(ubuntuArchFromGoArch(runtime.GOARCH))
 
+// kernelArch is the architecture of the running kernel, which can
+// differ from the one of the userland, e.g. an arm64 kernel running an
+// armhf userland
+var kernelArch = ArchitectureType(ubuntuArchFromKernelMachine(kernelMachine(), string(arch)))
+
 // SetArchitecture allows overriding the auto detected Architecture
 func SetArchitecture(newArch ArchitectureType) {
 	arch = newArch
 }
 
+// SetKernelArchitecture allows overriding the auto detected
+// architecture of the kernel
+func SetKernelArchitecture(newArch ArchitectureType) {
+	kernelArch = newArch
+}
+
 // UbuntuArchitecture returns the debian equivalent architecture for the
 // currently running architecture.
 //
@@ -72,6 +84,95 @@ func ubuntuArchFromGoArch(goarch string) string {
 	return ubuntuArch
 }
 
+// KernelArchitecture returns the debian equivalent architecture of the
+// running kernel.
+func KernelArchitecture() string {
+	return string(kernelArch)
+}
+
+func kernelMachine() string {
+	var uts syscall.Utsname
+	if err := syscall.Uname(&uts); err != nil {
+		return ""
+	}
+	machine := make([]byte, 0, len(uts.Machine))
+	for _, c := range uts.Machine {
+		if c == 0 {
+			break
+		}
+		machine = append(machine, byte(c))
+	}
+	return string(machine)
+}
+
+// ubuntuArchFromKernelMachine maps the machine reported by the kernel
+// to the coresponding Ubuntu architecture string, or fallback if it
+// does not map any.
+func ubuntuArchFromKernelMachine(machine, fallback string) string {
+	machineMapping := map[string]string{
+		// kernel  ubuntu
+		"i386":    "i386",
+		"i586":    "i386",
+		"i686":    "i386",
+		"x86_64":  "amd64",
+		"armv6l":  "armhf",
+		"armv7l":  "armhf",
+		"aarch64": "arm64",
+		"ppc":     "powerpc",
+		"ppc64le": "ppc64el",
+		"s390x":   "s390x",
+	}
+
+	if ubuntuArch := machineMapping[machine]; ubuntuArch != "" {
+		return ubuntuArch
+	}
+	return fallback
+}
+
+// foreignArchitectures are the architectures whose binaries a kernel
+// of a given architecture can run besides its own
+var foreignArchitectures = map[ArchitectureType][]ArchitectureType{
+	"amd64": {"i386"},
+	"arm64": {"armhf"},
+}
+
+// CompatibleArchitectures returns the architectures whose snaps can run
+// on the system, starting with the one of the userland.
+func CompatibleArchitectures() []string {
+	compatible := []string{string(arch)}
+	add := func(a ArchitectureType) {
+		for _, known := range compatible {
+			if known == string(a) {
+				return
+			}
+		}
+		compatible = append(compatible, string(a))
+	}
+	add(kernelArch)
+	for _, a := range foreignArchitectures[kernelArch] {
+		add(a)
+	}
+	return compatible
+}
+
+// IsCompatibleArchitecture returns true if snaps built for any of the
+// architectures can run on the system, natively or not.
+func IsCompatibleArchitecture(architectures []string) bool {
+	compatible := CompatibleArchitectures()
+	for _, a := range architectures {
+		if a == "all" {
+			return true
+		}
+		for _, c := range compatible {
+			if a == c {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
 // IsSupportedArchitecture returns true if the system architecture is in the
 // list of architectures.
 func IsSupportedArchitecture(architectures []string) bool {
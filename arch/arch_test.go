@@ -57,3 +57,38 @@ func (ts *ArchTestSuite) TestSupportedArchitectures(c *C) {
 	c.Check(IsSupportedArchitecture([]string{"amd64", "armhf", "powerpc"}), Equals, true)
 	c.Check(IsSupportedArchitecture([]string{"powerpc"}), Equals, false)
 }
+
+func (ts *ArchTestSuite) TestUbuntuArchFromKernelMachine(c *C) {
+	c.Check(ubuntuArchFromKernelMachine("x86_64", "amd64"), Equals, "amd64")
+	c.Check(ubuntuArchFromKernelMachine("aarch64", "armhf"), Equals, "arm64")
+	c.Check(ubuntuArchFromKernelMachine("armv7l", "armhf"), Equals, "armhf")
+	c.Check(ubuntuArchFromKernelMachine("i686", "i386"), Equals, "i386")
+	c.Check(ubuntuArchFromKernelMachine("mystery", "armhf"), Equals, "armhf")
+}
+
+func (ts *ArchTestSuite) TestSetKernelArchitecture(c *C) {
+	SetKernelArchitecture("arm64")
+	c.Assert(KernelArchitecture(), Equals, "arm64")
+}
+
+func (ts *ArchTestSuite) TestCompatibleArchitectures(c *C) {
+	arch, kernelArch = "armhf", "arm64"
+	c.Check(CompatibleArchitectures(), DeepEquals, []string{"armhf", "arm64"})
+
+	arch, kernelArch = "amd64", "amd64"
+	c.Check(CompatibleArchitectures(), DeepEquals, []string{"amd64", "i386"})
+
+	arch, kernelArch = "armhf", "armhf"
+	c.Check(CompatibleArchitectures(), DeepEquals, []string{"armhf"})
+}
+
+func (ts *ArchTestSuite) TestIsCompatibleArchitecture(c *C) {
+	arch, kernelArch = "armhf", "arm64"
+	c.Check(IsCompatibleArchitecture([]string{"all"}), Equals, true)
+	c.Check(IsCompatibleArchitecture([]string{"armhf"}), Equals, true)
+	c.Check(IsCompatibleArchitecture([]string{"arm64"}), Equals, true)
+	c.Check(IsCompatibleArchitecture([]string{"amd64", "i386"}), Equals, false)
+
+	arch, kernelArch = "armhf", "armhf"
+	c.Check(IsCompatibleArchitecture([]string{"arm64"}), Equals, false)
+}
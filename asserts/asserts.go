@@ -51,6 +51,7 @@ var (
 	SnapDeclarationType = &AssertionType{"snap-declaration", []string{"series", "snap-id"}, assembleSnapDeclaration}
 	SnapBuildType       = &AssertionType{"snap-build", []string{"series", "snap-id", "snap-digest"}, assembleSnapBuild}
 	SnapRevisionType    = &AssertionType{"snap-revision", []string{"series", "snap-id", "snap-digest"}, assembleSnapRevision}
+	SnapAdvisoryType    = &AssertionType{"snap-advisory", []string{"series", "snap-id"}, assembleSnapAdvisory}
 
 // ...
 )
@@ -63,6 +64,7 @@ var typeRegistry = map[string]*AssertionType{
 	SnapDeclarationType.Name: SnapDeclarationType,
 	SnapBuildType.Name:       SnapBuildType,
 	SnapRevisionType.Name:    SnapRevisionType,
+	SnapAdvisoryType.Name:    SnapAdvisoryType,
 }
 
 // Type returns the AssertionType with name or nil
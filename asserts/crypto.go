@@ -33,6 +33,8 @@ import (
 	"time"
 
 	"golang.org/x/crypto/openpgp/packet"
+
+	"github.com/snapcore/snapd/fips"
 )
 
 const (
@@ -158,9 +160,12 @@ func verifyContentSignature(content []byte, sig Signature, pubKey *packet.Public
 		panic(fmt.Errorf("not an internally supported Signature: %T", sig))
 	}
 
-	h := opgSig.sig.Hash.New()
+	h, err := fips.NewHash(opgSig.sig.Hash)
+	if err != nil {
+		return err
+	}
 	h.Write(content)
-	return pubKey.VerifySignature(h, opgSig.sig)
+	return fips.Current().VerifySignature(pubKey, h, opgSig.sig)
 }
 
 func decodeSignature(signature []byte) (Signature, error) {
@@ -275,10 +280,13 @@ func (opgPrivK openpgpPrivateKey) sign(content []byte) (*packet.Signature, error
 	sig.CreationTime = time.Now()
 	sig.IssuerKeyId = &privk.KeyId
 
-	h := openpgpConfig.Hash().New()
+	h, err := fips.NewHash(openpgpConfig.Hash())
+	if err != nil {
+		return nil, err
+	}
 	h.Write(content)
 
-	err := sig.Sign(h, privk, openpgpConfig)
+	err = fips.Current().Sign(privk, h, sig)
 	if err != nil {
 		return nil, err
 	}
@@ -25,6 +25,7 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"sync"
 	"time"
 )
 
@@ -132,6 +133,12 @@ type Database struct {
 	trusted    Backstore
 	backstores []Backstore
 	checkers   []Checker
+
+	// earliestTime is a lower bound for the current time, the
+	// system clock cannot be trusted if it says otherwise
+	// (e.g. on devices with a dead RTC)
+	mu           sync.Mutex
+	earliestTime time.Time
 }
 
 // OpenDatabase opens the assertion database based on the configuration.
@@ -187,6 +194,40 @@ func OpenDatabase(cfg *DatabaseConfig) (*Database, error) {
 	}, nil
 }
 
+var timeNow = time.Now
+
+// SetEarliestTime sets a lower bound for the current time as known to
+// the database, usually from a previously persisted one. The bound is
+// only ever raised.
+func (db *Database) SetEarliestTime(earliest time.Time) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if earliest.After(db.earliestTime) {
+		db.earliestTime = earliest
+	}
+}
+
+// EarliestTime returns the lower bound for the current time as known
+// to the database, based on what was set with SetEarliestTime and the
+// timestamps of the assertions added since.
+func (db *Database) EarliestTime() time.Time {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.earliestTime
+}
+
+// CheckTime returns the time used to check the validity of
+// assertions: the system clock, unless it is behind the earliest
+// time known to the database.
+func (db *Database) CheckTime() time.Time {
+	now := timeNow()
+	earliest := db.EarliestTime()
+	if now.Before(earliest) {
+		return earliest
+	}
+	return now
+}
+
 // ImportKey stores the given private/public key pair for identity.
 func (db *Database) ImportKey(authorityID string, privKey PrivateKey) error {
 	return db.keypairMgr.Put(authorityID, privKey)
@@ -262,9 +303,9 @@ func (db *Database) Check(assert Assertion) error {
 		return fmt.Errorf("error finding matching public key for signature: %v", err)
 	}
 
-	now := time.Now()
+	checkTime := db.CheckTime()
 	for _, checker := range db.checkers {
-		err := checker(assert, sig, accKey, db, now)
+		err := checker(assert, sig, accKey, db, checkTime)
 		if err != nil {
 			return err
 		}
@@ -299,7 +340,16 @@ func (db *Database) Add(assert Assertion) error {
 		return fmt.Errorf("cannot add %q assertion with primary key clashing with a trusted assertion: %v", assertType.Name, keyValues)
 	}
 
-	return db.bs.Put(assertType, assert)
+	err = db.bs.Put(assertType, assert)
+	if err != nil {
+		return err
+	}
+
+	// a properly signed assertion cannot have been issued in the future
+	if tstamped, ok := assert.(timestamped); ok {
+		db.SetEarliestTime(tstamped.Timestamp())
+	}
+	return nil
 }
 
 func searchMatch(assert Assertion, expectedHeaders map[string]string) bool {
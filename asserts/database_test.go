@@ -35,6 +35,7 @@ import (
 	. "gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/fips"
 )
 
 func Test(t *testing.T) { TestingT(t) }
@@ -237,6 +238,77 @@ func (chks *checkSuite) TestCheckExpiredPubKey(c *C) {
 	c.Assert(err, ErrorMatches, `assertion is signed with expired public key "[a-f0-9]+" from "canonical"`)
 }
 
+func (chks *checkSuite) TestCheckClockBehindEarliestTime(c *C) {
+	since := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	until := since.AddDate(5, 0, 0)
+	cfg := &asserts.DatabaseConfig{
+		Backstore:      chks.bs,
+		KeypairManager: asserts.NewMemoryKeypairManager(),
+		Trusted:        []asserts.Assertion{asserts.AccountKeyValidBetweenForTest("canonical", testPrivKey0.PublicKey(), since, until)},
+	}
+	db, err := asserts.OpenDatabase(cfg)
+	c.Assert(err, IsNil)
+
+	// dead RTC
+	epoch := time.Unix(0, 0)
+	restore := asserts.MockTimeNow(func() time.Time { return epoch })
+	defer restore()
+
+	c.Check(db.CheckTime(), Equals, epoch)
+	err = db.Check(chks.a)
+	c.Assert(err, ErrorMatches, `assertion is signed with expired public key "[a-f0-9]+" from "canonical"`)
+
+	earliest := since.AddDate(0, 6, 0)
+	db.SetEarliestTime(earliest)
+	c.Check(db.EarliestTime(), Equals, earliest)
+	c.Check(db.CheckTime(), Equals, earliest)
+	err = db.Check(chks.a)
+	c.Check(err, IsNil)
+
+	// the bound is never lowered
+	db.SetEarliestTime(since)
+	c.Check(db.EarliestTime(), Equals, earliest)
+}
+
+func (chks *checkSuite) TestCheckExpiredPubKeyClockBehind(c *C) {
+	since := time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC)
+	until := since.AddDate(5, 0, 0)
+	cfg := &asserts.DatabaseConfig{
+		Backstore:      chks.bs,
+		KeypairManager: asserts.NewMemoryKeypairManager(),
+		Trusted:        []asserts.Assertion{asserts.AccountKeyValidBetweenForTest("canonical", testPrivKey0.PublicKey(), since, until)},
+	}
+	db, err := asserts.OpenDatabase(cfg)
+	c.Assert(err, IsNil)
+
+	restore := asserts.MockTimeNow(func() time.Time { return since.AddDate(2, 0, 0) })
+	defer restore()
+
+	err = db.Check(chks.a)
+	c.Assert(err, IsNil)
+
+	db.SetEarliestTime(until.AddDate(1, 0, 0))
+	err = db.Check(chks.a)
+	c.Check(err, ErrorMatches, `assertion is signed with expired public key "[a-f0-9]+" from "canonical"`)
+}
+
+func (chks *checkSuite) TestCheckFIPSMode(c *C) {
+	cfg := &asserts.DatabaseConfig{
+		Backstore:      chks.bs,
+		KeypairManager: asserts.NewMemoryKeypairManager(),
+		Trusted:        []asserts.Assertion{asserts.BootstrapAccountKeyForTest("canonical", testPrivKey0.PublicKey())},
+	}
+	db, err := asserts.OpenDatabase(cfg)
+	c.Assert(err, IsNil)
+
+	restore := fips.MockEnabled(true)
+	defer restore()
+
+	// the test keys are too small for approved mode
+	err = db.Check(chks.a)
+	c.Check(err, ErrorMatches, "failed signature verification: 752 bits RSA key is not approved in FIPS mode, need at least 2048")
+}
+
 func (chks *checkSuite) TestCheckForgery(c *C) {
 	trustedKey := testPrivKey0
 
@@ -445,6 +517,36 @@ func (safs *signAddFindSuite) TestAddSuperseding(c *C) {
 	c.Check(err, ErrorMatches, "revision 0 is older than current revision 1")
 }
 
+func (safs *signAddFindSuite) TestAddRaisesEarliestTime(c *C) {
+	c.Check(safs.db.EarliestTime().IsZero(), Equals, true)
+
+	ts := time.Now().Add(24 * time.Hour).Truncate(time.Second).UTC()
+	headers := map[string]string{
+		"authority-id": "canonical",
+		"account-id":   "abc-123",
+		"display-name": "Nice User",
+		"validation":   "certified",
+		"timestamp":    ts.Format(time.RFC3339),
+	}
+	acct, err := safs.signingDB.Sign(asserts.AccountType, headers, nil, safs.signingKeyID)
+	c.Assert(err, IsNil)
+
+	err = safs.db.Add(acct)
+	c.Assert(err, IsNil)
+	c.Check(safs.db.EarliestTime().Equal(ts), Equals, true)
+	c.Check(safs.db.CheckTime().Equal(ts), Equals, true)
+
+	// assertions without a timestamp do not affect it
+	a, err := safs.signingDB.Sign(asserts.TestOnlyType, map[string]string{
+		"authority-id": "canonical",
+		"primary-key":  "a",
+	}, nil, safs.signingKeyID)
+	c.Assert(err, IsNil)
+	err = safs.db.Add(a)
+	c.Assert(err, IsNil)
+	c.Check(safs.db.EarliestTime().Equal(ts), Equals, true)
+}
+
 func (safs *signAddFindSuite) TestFindNotFound(c *C) {
 	headers := map[string]string{
 		"authority-id": "canonical",
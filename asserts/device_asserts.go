@@ -21,6 +21,7 @@ package asserts
 
 import (
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -28,9 +29,12 @@ import (
 // about the properties of a device model.
 type Model struct {
 	assertionBase
-	allowedModes  []string
-	requiredSnaps []string
-	timestamp     time.Time
+	allowedModes      []string
+	requiredSnaps     []string
+	disallowedSnaps   []string
+	preferredChannels map[string]string
+	storeOnly         bool
+	timestamp         time.Time
 }
 
 // BrandID returns the brand identifier. Same as the authority id.
@@ -83,6 +87,23 @@ func (mod *Model) RequiredSnaps() []string {
 	return mod.requiredSnaps
 }
 
+// DisallowedSnaps returns the snaps that cannot be installed for this model.
+func (mod *Model) DisallowedSnaps() []string {
+	return mod.disallowedSnaps
+}
+
+// PreferredChannels returns the channels snaps are installed from for
+// this model when no channel is asked for, by snap name.
+func (mod *Model) PreferredChannels() map[string]string {
+	return mod.preferredChannels
+}
+
+// StoreOnly returns whether only snaps from the store of the model can
+// be installed, refusing the ones from local files.
+func (mod *Model) StoreOnly() bool {
+	return mod.storeOnly
+}
+
 // Class returns which class the model belongs to defining policies for
 // additional software installation.
 func (mod *Model) Class() string {
@@ -128,6 +149,39 @@ func assembleModel(assert assertionBase) (Assertion, error) {
 		return nil, err
 	}
 
+	disallowedSnaps, err := checkOptionalCommaSepList(assert.headers, "disallowed-snaps")
+	if err != nil {
+		return nil, err
+	}
+	for _, disallowed := range disallowedSnaps {
+		for _, required := range requiredSnaps {
+			if disallowed == required {
+				return nil, fmt.Errorf("snap %q cannot be both required and disallowed", disallowed)
+			}
+		}
+	}
+
+	preferred, err := checkOptionalCommaSepList(assert.headers, "preferred-channels")
+	if err != nil {
+		return nil, err
+	}
+	var preferredChannels map[string]string
+	for _, entry := range preferred {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid entry in \"preferred-channels\" header, expected <snap>=<channel>: %q", entry)
+		}
+		if preferredChannels == nil {
+			preferredChannels = make(map[string]string, len(preferred))
+		}
+		preferredChannels[parts[0]] = parts[1]
+	}
+
+	storeOnly, err := checkOptionalBool(assert.headers, "store-only")
+	if err != nil {
+		return nil, err
+	}
+
 	timestamp, err := checkRFC3339Date(assert.headers, "timestamp")
 	if err != nil {
 		return nil, err
@@ -135,10 +189,13 @@ func assembleModel(assert assertionBase) (Assertion, error) {
 
 	// ignore extra headers and non-empty body for future compatibility
 	return &Model{
-		assertionBase: assert,
-		allowedModes:  allowedModes,
-		requiredSnaps: requiredSnaps,
-		timestamp:     timestamp,
+		assertionBase:     assert,
+		allowedModes:      allowedModes,
+		requiredSnaps:     requiredSnaps,
+		disallowedSnaps:   disallowedSnaps,
+		preferredChannels: preferredChannels,
+		storeOnly:         storeOnly,
+		timestamp:         timestamp,
 	}, nil
 }
 
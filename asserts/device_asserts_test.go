@@ -80,6 +80,20 @@ func (mods *modelSuite) TestDecodeOK(c *C) {
 	c.Check(model.Store(), Equals, "brand-store")
 	c.Check(model.AllowedModes(), HasLen, 0)
 	c.Check(model.RequiredSnaps(), DeepEquals, []string{"foo", "bar"})
+	c.Check(model.DisallowedSnaps(), HasLen, 0)
+	c.Check(model.PreferredChannels(), HasLen, 0)
+	c.Check(model.StoreOnly(), Equals, false)
+}
+
+func (mods *modelSuite) TestDecodeGating(c *C) {
+	encoded := strings.Replace(modelExample, "TSLINE", mods.tsLine, 1)
+	encoded = strings.Replace(encoded, "class: fixed\n", "class: fixed\ndisallowed-snaps: baz, quux\npreferred-channels: foo=beta, bar=edge\nstore-only: true\n", 1)
+	a, err := asserts.Decode([]byte(encoded))
+	c.Assert(err, IsNil)
+	model := a.(*asserts.Model)
+	c.Check(model.DisallowedSnaps(), DeepEquals, []string{"baz", "quux"})
+	c.Check(model.PreferredChannels(), DeepEquals, map[string]string{"foo": "beta", "bar": "edge"})
+	c.Check(model.StoreOnly(), Equals, true)
 }
 
 const (
@@ -112,6 +126,11 @@ func (mods *modelSuite) TestDecodeInvalid(c *C) {
 		{"allowed-modes: \n", "allowed-modes: ,\n", `empty entry in comma separated "allowed-modes" header: ","`},
 		{"required-snaps: foo, bar\n", "", `"required-snaps" header is mandatory`},
 		{"required-snaps: foo, bar\n", "required-snaps: foo,\n", `empty entry in comma separated "required-snaps" header: "foo,"`},
+		{"class: fixed\n", "class: fixed\ndisallowed-snaps: foo,\n", `empty entry in comma separated "disallowed-snaps" header: "foo,"`},
+		{"class: fixed\n", "class: fixed\ndisallowed-snaps: bar\n", `snap "bar" cannot be both required and disallowed`},
+		{"class: fixed\n", "class: fixed\npreferred-channels: foo\n", `invalid entry in "preferred-channels" header, expected <snap>=<channel>: "foo"`},
+		{"class: fixed\n", "class: fixed\npreferred-channels: foo=\n", `invalid entry in "preferred-channels" header, expected <snap>=<channel>: "foo="`},
+		{"class: fixed\n", "class: fixed\nstore-only: yes\n", `"store-only" header must be 'true' or 'false'`},
 		{"class: fixed\n", "", `"class" header is mandatory`},
 		{"class: fixed\n", "class: \n", `"class" header should not be empty`},
 		{mods.tsLine, "", `"timestamp" header is mandatory`},
@@ -82,6 +82,18 @@ func ExpiredAccountKeyForTest(authorityID string, pubKey PublicKey) *AccountKey
 	return makeAccountKeyForTest(authorityID, pubKey, 1)
 }
 
+func AccountKeyValidBetweenForTest(authorityID string, pubKey PublicKey, since, until time.Time) *AccountKey {
+	accKey := makeAccountKeyForTest(authorityID, pubKey, 0)
+	accKey.since = since
+	accKey.until = until
+	return accKey
+}
+
+func MockTimeNow(now func() time.Time) (restore func()) {
+	timeNow = now
+	return func() { timeNow = time.Now }
+}
+
 // define dummy assertion types to use in the tests
 
 type TestOnly struct {
@@ -22,42 +22,21 @@ package asserts
 import (
 	"bytes"
 	"fmt"
-	"os/exec"
 	"strings"
-)
-
-func runGPGImpl(homedir string, input []byte, args ...string) ([]byte, error) {
-	general := []string{"-q"}
-	if homedir != "" {
-		general = append([]string{"--homedir", homedir}, general...)
-	}
-	allArgs := append(general, args...)
-
-	cmd := exec.Command("gpg", allArgs...)
-	var outBuf bytes.Buffer
-	var errBuf bytes.Buffer
-
-	if len(input) != 0 {
-		cmd.Stdin = bytes.NewBuffer(input)
-	}
-
-	cmd.Stdout = &outBuf
-	cmd.Stderr = &errBuf
+	"time"
 
-	if err := cmd.Run(); err != nil {
-		return nil, fmt.Errorf("gpg %s failed: %v (%q)", strings.Join(args, " "), err, errBuf.Bytes())
-	}
-
-	return outBuf.Bytes(), nil
-}
+	"github.com/snapcore/snapd/osutil"
+)
 
-var runGPG = runGPGImpl
+var runGPG = osutil.RunGPG
 
-type gpgKeypairManager struct {
+// GPGKeypairManager is a key pair manager backed by a local GnuPG
+// setup.
+type GPGKeypairManager struct {
 	homedir string
 }
 
-func (gkm *gpgKeypairManager) gpg(input []byte, args ...string) ([]byte, error) {
+func (gkm *GPGKeypairManager) gpg(input []byte, args ...string) ([]byte, error) {
 	return runGPG(gkm.homedir, input, args...)
 }
 
@@ -67,18 +46,18 @@ func (gkm *gpgKeypairManager) gpg(input []byte, args ...string) ([]byte, error)
 // Importing keys through the keypair manager interface is not
 // suppored.
 // Main purpose is allowing signing using keys from a GPG setup.
-func NewGPGKeypairManager(homedir string) KeypairManager {
-	return &gpgKeypairManager{
+func NewGPGKeypairManager(homedir string) *GPGKeypairManager {
+	return &GPGKeypairManager{
 		homedir: homedir,
 	}
 }
 
-func (gkm *gpgKeypairManager) Put(authorityID string, privKey PrivateKey) error {
+func (gkm *GPGKeypairManager) Put(authorityID string, privKey PrivateKey) error {
 	// NOTE: we don't need this initially at least and this keypair mgr is not for general arbitrary usage
 	return fmt.Errorf("cannot import private key into GPG keyring")
 }
 
-func (gkm *gpgKeypairManager) Get(authorityID, keyID string) (PrivateKey, error) {
+func (gkm *GPGKeypairManager) Get(authorityID, keyID string) (PrivateKey, error) {
 	out, err := gkm.gpg(nil, "--batch", "--export", "--export-options", "export-minimal,export-clean,no-export-attributes", "0x"+keyID)
 	if err != nil {
 		return nil, err
@@ -99,10 +78,63 @@ func (gkm *gpgKeypairManager) Get(authorityID, keyID string) (PrivateKey, error)
 	return privKey, nil
 }
 
-func (gkm *gpgKeypairManager) sign(fingerprint string, content []byte) ([]byte, error) {
+func (gkm *GPGKeypairManager) sign(fingerprint string, content []byte) ([]byte, error) {
 	out, err := gkm.gpg(content, "--personal-digest-preferences", "SHA512", "--default-key", "0x"+fingerprint, "--detach-sign")
 	if err != nil {
 		return nil, fmt.Errorf("cannot sign using GPG: %v", err)
 	}
 	return out, nil
 }
+
+// GetByName returns the key pair with the given name, as the user id
+// of the key in the GPG keyring.
+func (gkm *GPGKeypairManager) GetByName(name string) (PrivateKey, error) {
+	out, err := gkm.gpg(nil, "--batch", "--list-secret-keys", "--fixed-list-mode", "--with-colons")
+	if err != nil {
+		return nil, err
+	}
+
+	keyID := ""
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Split(line, ":")
+		switch {
+		case fields[0] == "sec" && len(fields) > 4:
+			keyID = strings.ToLower(fields[4])
+		case fields[0] == "uid" && len(fields) > 9 && keyID != "":
+			if fields[9] == name {
+				return gkm.Get("", keyID)
+			}
+		}
+	}
+	return nil, fmt.Errorf("cannot find key named %q in GPG keyring", name)
+}
+
+// Generate creates a new key pair with the given name in the GPG
+// keyring, protected by the given passphrase if not empty.
+func (gkm *GPGKeypairManager) Generate(passphrase string, name string) error {
+	if name == "" || strings.ContainsAny(name, ":\n") {
+		return fmt.Errorf("invalid key name %q", name)
+	}
+	if strings.ContainsRune(passphrase, '\n') {
+		return fmt.Errorf("passphrase cannot contain newlines")
+	}
+	_, err := gkm.GetByName(name)
+	if err == nil {
+		return fmt.Errorf("key named %q already exists in GPG keyring", name)
+	}
+
+	var params bytes.Buffer
+	fmt.Fprintf(&params, "Key-Type: RSA\nKey-Length: 4096\nName-Real: %s\nCreation-Date: seconds=%d\nPreferences: SHA512\n", name, time.Now().Unix())
+	if passphrase != "" {
+		fmt.Fprintf(&params, "Passphrase: %s\n", passphrase)
+	} else {
+		params.WriteString("%no-protection\n")
+	}
+	params.WriteString("%commit\n")
+
+	_, err = gkm.gpg(params.Bytes(), "--batch", "--gen-key")
+	if err != nil {
+		return fmt.Errorf("cannot generate key named %q: %v", name, err)
+	}
+	return nil
+}
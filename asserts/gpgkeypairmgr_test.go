@@ -40,7 +40,7 @@ import (
 
 type gpgKeypairMgrSuite struct {
 	homedir    string
-	keypairMgr asserts.KeypairManager
+	keypairMgr *asserts.GPGKeypairManager
 }
 
 var _ = Suite(&gpgKeypairMgrSuite{})
@@ -135,6 +135,54 @@ func (gkms *gpgKeypairMgrSuite) TestGetPublicKeyLooksGood(c *C) {
 	c.Check(fp, Equals, testKeyFingerprint)
 }
 
+func (gkms *gpgKeypairMgrSuite) TestGetByName(c *C) {
+	got, err := gkms.keypairMgr.GetByName(" (test)")
+	c.Assert(err, IsNil)
+	c.Check(got.PublicKey().ID(), Equals, testKeyID)
+
+	_, err = gkms.keypairMgr.GetByName("missing")
+	c.Check(err, ErrorMatches, `cannot find key named "missing" in GPG keyring`)
+}
+
+func (gkms *gpgKeypairMgrSuite) TestGenerate(c *C) {
+	err := gkms.keypairMgr.Generate("", "default")
+	c.Assert(err, IsNil)
+
+	got, err := gkms.keypairMgr.GetByName("default")
+	c.Assert(err, IsNil)
+	c.Check(got.PublicKey().ID(), Not(Equals), testKeyID)
+
+	err = gkms.keypairMgr.Generate("", "default")
+	c.Check(err, ErrorMatches, `key named "default" already exists in GPG keyring`)
+}
+
+func (gkms *gpgKeypairMgrSuite) TestGenerateParams(c *C) {
+	var input []byte
+	mockGPG := func(prev asserts.GPGRunner, homedir string, in []byte, args ...string) ([]byte, error) {
+		if args[1] == "--list-secret-keys" {
+			return nil, nil
+		}
+		c.Check(args, DeepEquals, []string{"--batch", "--gen-key"})
+		input = in
+		return nil, nil
+	}
+	restore := asserts.MockRunGPG(mockGPG)
+	defer restore()
+
+	err := gkms.keypairMgr.Generate("secret", "mykey")
+	c.Assert(err, IsNil)
+	c.Check(string(input), Matches, `(?s)Key-Type: RSA\nKey-Length: 4096\nName-Real: mykey\nCreation-Date: seconds=[0-9]+\nPreferences: SHA512\nPassphrase: secret\n%commit\n`)
+}
+
+func (gkms *gpgKeypairMgrSuite) TestGenerateInvalid(c *C) {
+	err := gkms.keypairMgr.Generate("", "")
+	c.Check(err, ErrorMatches, `invalid key name ""`)
+	err = gkms.keypairMgr.Generate("", "my\nkey")
+	c.Check(err, ErrorMatches, `invalid key name "my\\nkey"`)
+	err = gkms.keypairMgr.Generate("pass\nphrase", "mykey")
+	c.Check(err, ErrorMatches, "passphrase cannot contain newlines")
+}
+
 func (gkms *gpgKeypairMgrSuite) TestGetNotFound(c *C) {
 	got, err := gkms.keypairMgr.Get("auth-id1", "ffffffffffffffff")
 	c.Check(err, ErrorMatches, `cannot find key "ffffffffffffffff" in GPG keyring`)
@@ -139,3 +139,25 @@ func checkCommaSepList(headers map[string]string, name string) ([]string, error)
 
 	return entries, nil
 }
+
+func checkOptionalCommaSepList(headers map[string]string, name string) ([]string, error) {
+	if _, ok := headers[name]; !ok {
+		return nil, nil
+	}
+	return checkCommaSepList(headers, name)
+}
+
+func checkOptionalBool(headers map[string]string, name string) (bool, error) {
+	value, ok := headers[name]
+	if !ok {
+		return false, nil
+	}
+	switch value {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	default:
+		return false, fmt.Errorf("%q header must be 'true' or 'false'", name)
+	}
+}
@@ -0,0 +1,98 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package signtool offers tooling to sign assertions.
+package signtool
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/snapcore/snapd/asserts"
+)
+
+// Options specifies the complete input for signing an assertion.
+type Options struct {
+	// KeyID specifies the key id of the key to use.
+	KeyID string
+
+	// Statement is used as input to construct the assertion: it is
+	// a JSON map of the headers of the assertion, as strings or
+	// integers, with the body, if any, under "body".
+	Statement []byte
+}
+
+// Sign produces the text of a signed assertion as specified by opts,
+// using the key pairs of keypairMgr.
+func Sign(opts *Options, keypairMgr asserts.KeypairManager) ([]byte, error) {
+	var statement map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(opts.Statement))
+	dec.UseNumber()
+	if err := dec.Decode(&statement); err != nil {
+		return nil, fmt.Errorf("cannot parse the assertion input as JSON: %v", err)
+	}
+
+	headers := make(map[string]string, len(statement))
+	var body []byte
+	for name, v := range statement {
+		if name == "body" {
+			bodyStr, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("body if specified must be a string")
+			}
+			body = []byte(bodyStr)
+			continue
+		}
+		switch value := v.(type) {
+		case string:
+			headers[name] = value
+		case json.Number:
+			if _, err := value.Int64(); err != nil {
+				return nil, fmt.Errorf("header %q must be a string or an integer, got %s", name, value)
+			}
+			headers[name] = value.String()
+		default:
+			return nil, fmt.Errorf("header %q must be a string or an integer", name)
+		}
+	}
+
+	typeName := headers["type"]
+	if typeName == "" {
+		return nil, fmt.Errorf("cannot sign assertion with unspecified type")
+	}
+	assertType := asserts.Type(typeName)
+	if assertType == nil {
+		return nil, fmt.Errorf("invalid assertion type: %q", typeName)
+	}
+	delete(headers, "type")
+
+	db, err := asserts.OpenDatabase(&asserts.DatabaseConfig{
+		KeypairManager: keypairMgr,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := db.Sign(assertType, headers, body, opts.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	return asserts.Encode(a), nil
+}
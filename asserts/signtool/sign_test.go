@@ -0,0 +1,112 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package signtool_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/openpgp/packet"
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/signtool"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type signSuite struct {
+	keypairMgr asserts.KeypairManager
+	testKeyID  string
+}
+
+var _ = Suite(&signSuite{})
+
+func (s *signSuite) SetUpSuite(c *C) {
+	rsaPrivKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	c.Assert(err, IsNil)
+	privKey := asserts.OpenPGPPrivateKey(packet.NewRSAPrivateKey(time.Now(), rsaPrivKey))
+
+	s.keypairMgr = asserts.NewMemoryKeypairManager()
+	c.Assert(s.keypairMgr.Put("user-id1", privKey), IsNil)
+	s.testKeyID = privKey.PublicKey().ID()
+}
+
+func (s *signSuite) TestSign(c *C) {
+	statement := `{
+  "type": "account",
+  "authority-id": "user-id1",
+  "account-id": "user-id1",
+  "display-name": "User One",
+  "validation": "unproven",
+  "timestamp": "2016-10-01T12:00:00Z",
+  "revision": 2,
+  "body": "BODY"
+}`
+	assertText, err := signtool.Sign(&signtool.Options{
+		KeyID:     s.testKeyID,
+		Statement: []byte(statement),
+	}, s.keypairMgr)
+	c.Assert(err, IsNil)
+
+	a, err := asserts.Decode(assertText)
+	c.Assert(err, IsNil)
+	c.Check(a.Type(), Equals, asserts.AccountType)
+	c.Check(a.AuthorityID(), Equals, "user-id1")
+	c.Check(a.Revision(), Equals, 2)
+	c.Check(a.Header("display-name"), Equals, "User One")
+	c.Check(string(a.Body()), Equals, "BODY")
+
+	acct := a.(*asserts.Account)
+	c.Check(acct.Timestamp().Equal(time.Date(2016, 10, 1, 12, 0, 0, 0, time.UTC)), Equals, true)
+}
+
+func (s *signSuite) TestSignErrors(c *C) {
+	tests := []struct {
+		statement string
+		err       string
+	}{
+		{`{`, "cannot parse the assertion input as JSON: .*"},
+		{`{"authority-id": "user-id1"}`, "cannot sign assertion with unspecified type"},
+		{`{"type": "foo", "authority-id": "user-id1"}`, `invalid assertion type: "foo"`},
+		{`{"type": "account", "authority-id": "user-id1", "body": 1}`, "body if specified must be a string"},
+		{`{"type": "account", "authority-id": "user-id1", "revision": 1.5}`, `header "revision" must be a string or an integer, got 1.5`},
+		{`{"type": "account", "authority-id": "user-id1", "display-name": ["a"]}`, `header "display-name" must be a string or an integer`},
+		{`{"type": "account", "authority-id": "user-id1"}`, `"account-id" header is mandatory`},
+	}
+
+	for _, test := range tests {
+		_, err := signtool.Sign(&signtool.Options{
+			KeyID:     s.testKeyID,
+			Statement: []byte(test.statement),
+		}, s.keypairMgr)
+		c.Check(err, ErrorMatches, test.err, Commentf(test.statement))
+	}
+}
+
+func (s *signSuite) TestSignUnknownKey(c *C) {
+	_, err := signtool.Sign(&signtool.Options{
+		KeyID:     "ffffffffffffffff",
+		Statement: []byte(`{"type": "account", "authority-id": "user-id1"}`),
+	}, s.keypairMgr)
+	c.Check(err, ErrorMatches, "cannot find key pair")
+}
@@ -20,7 +20,10 @@
 package asserts
 
 import (
+	"fmt"
 	"time"
+
+	"gopkg.in/yaml.v2"
 )
 
 // SnapDeclaration holds a snap-declaration assertion, declaring a
@@ -242,3 +245,87 @@ func assembleSnapRevision(assert assertionBase) (Assertion, error) {
 		timestamp:     timestamp,
 	}, nil
 }
+
+// Advisory describes a security issue affecting the revisions of a snap
+// published before the one fixing it.
+type Advisory struct {
+	ID       string   `yaml:"id" json:"id"`
+	CVEs     []string `yaml:"cves,omitempty" json:"cves,omitempty"`
+	Severity string   `yaml:"severity" json:"severity"`
+	Summary  string   `yaml:"summary,omitempty" json:"summary,omitempty"`
+	// FixedRevision is the first revision of the snap without the
+	// issue, or zero if no revision fixes it yet.
+	FixedRevision int `yaml:"fixed-revision,omitempty" json:"fixed-revision,omitempty"`
+}
+
+// Affects returns whether the issue affects the given store revision.
+func (adv *Advisory) Affects(revision int) bool {
+	if revision <= 0 {
+		// local revisions did not come from the store
+		return false
+	}
+	return adv.FixedRevision == 0 || revision < adv.FixedRevision
+}
+
+var validSeverities = []string{"low", "medium", "high", "critical"}
+
+// SnapAdvisory holds a snap-advisory assertion, listing in its body the
+// security advisories concerning the revisions of a snap.
+type SnapAdvisory struct {
+	assertionBase
+	advisories []*Advisory
+	timestamp  time.Time
+}
+
+// Series returns the series of the snap the advisories are about.
+func (snapadv *SnapAdvisory) Series() string {
+	return snapadv.Header("series")
+}
+
+// SnapID returns the snap id of the snap the advisories are about.
+func (snapadv *SnapAdvisory) SnapID() string {
+	return snapadv.Header("snap-id")
+}
+
+// Advisories returns the security advisories concerning the snap.
+func (snapadv *SnapAdvisory) Advisories() []*Advisory {
+	return snapadv.advisories
+}
+
+// Timestamp returns the time when the snap-advisory was issued.
+func (snapadv *SnapAdvisory) Timestamp() time.Time {
+	return snapadv.timestamp
+}
+
+func assembleSnapAdvisory(assert assertionBase) (Assertion, error) {
+	timestamp, err := checkRFC3339Date(assert.headers, "timestamp")
+	if err != nil {
+		return nil, err
+	}
+
+	var advisories []*Advisory
+	if err := yaml.Unmarshal(assert.body, &advisories); err != nil {
+		return nil, fmt.Errorf("cannot parse the advisories in the body: %v", err)
+	}
+	for i, adv := range advisories {
+		if adv == nil || adv.ID == "" {
+			return nil, fmt.Errorf("advisory #%d has no id", i+1)
+		}
+		valid := false
+		for _, severity := range validSeverities {
+			valid = valid || adv.Severity == severity
+		}
+		if !valid {
+			return nil, fmt.Errorf("advisory %q has invalid severity %q", adv.ID, adv.Severity)
+		}
+		if adv.FixedRevision < 0 {
+			return nil, fmt.Errorf("advisory %q has invalid fixed revision %d", adv.ID, adv.FixedRevision)
+		}
+	}
+
+	return &SnapAdvisory{
+		assertionBase: assert,
+		advisories:    advisories,
+		timestamp:     timestamp,
+	}, nil
+}
@@ -21,6 +21,8 @@ package asserts_test
 
 import (
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -33,6 +35,7 @@ var (
 	_ = Suite(&snapDeclSuite{})
 	_ = Suite(&snapBuildSuite{})
 	_ = Suite(&snapRevSuite{})
+	_ = Suite(&snapAdvSuite{})
 )
 
 type snapDeclSuite struct {
@@ -427,3 +430,109 @@ func (srs *snapRevSuite) TestPrimaryKey(c *C) {
 	})
 	c.Assert(err, IsNil)
 }
+
+type snapAdvSuite struct {
+	ts     time.Time
+	tsLine string
+}
+
+func (sas *snapAdvSuite) SetUpSuite(c *C) {
+	sas.ts = time.Now().Truncate(time.Second).UTC()
+	sas.tsLine = "timestamp: " + sas.ts.Format(time.RFC3339) + "\n"
+}
+
+const snapAdvBody = `- id: USN-3000-1
+  cves: [CVE-2016-1000, CVE-2016-1001]
+  severity: high
+  summary: remote code execution in libfoo
+  fixed-revision: 12
+- id: USN-3001-1
+  severity: low
+`
+
+func (sas *snapAdvSuite) makeValidEncoded() string {
+	return "type: snap-advisory\n" +
+		"authority-id: store-id1\n" +
+		"series: 16\n" +
+		"snap-id: snap-id-1\n" +
+		sas.tsLine +
+		"body-length: " + strconv.Itoa(len(snapAdvBody)) +
+		"\n\n" +
+		snapAdvBody +
+		"\n\n" +
+		"openpgp c2ln"
+}
+
+func (sas *snapAdvSuite) TestDecodeOK(c *C) {
+	a, err := asserts.Decode([]byte(sas.makeValidEncoded()))
+	c.Assert(err, IsNil)
+	c.Check(a.Type(), Equals, asserts.SnapAdvisoryType)
+	snapAdv := a.(*asserts.SnapAdvisory)
+	c.Check(snapAdv.AuthorityID(), Equals, "store-id1")
+	c.Check(snapAdv.Timestamp(), Equals, sas.ts)
+	c.Check(snapAdv.Series(), Equals, "16")
+	c.Check(snapAdv.SnapID(), Equals, "snap-id-1")
+	c.Check(snapAdv.Advisories(), DeepEquals, []*asserts.Advisory{{
+		ID:            "USN-3000-1",
+		CVEs:          []string{"CVE-2016-1000", "CVE-2016-1001"},
+		Severity:      "high",
+		Summary:       "remote code execution in libfoo",
+		FixedRevision: 12,
+	}, {
+		ID:       "USN-3001-1",
+		Severity: "low",
+	}})
+}
+
+const (
+	snapAdvErrPrefix = "assertion snap-advisory: "
+)
+
+func (sas *snapAdvSuite) TestDecodeInvalid(c *C) {
+	encoded := sas.makeValidEncoded()
+	invalidTests := []struct{ original, invalid, expectedErr string }{
+		{"series: 16\n", "", `"series" header is mandatory`},
+		{"snap-id: snap-id-1\n", "", `"snap-id" header is mandatory`},
+		{sas.tsLine, "", `"timestamp" header is mandatory`},
+		{sas.tsLine, "timestamp: 12:30\n", `"timestamp" header is not a RFC3339 date: .*`},
+		{"- id: USN-3001-1\n", "- id: \n", `advisory #2 has no id`},
+		{"severity: low\n", "severity: bad\n", `advisory "USN-3001-1" has invalid severity "bad"`},
+		{"fixed-revision: 12\n", "fixed-revision: -1\n", `advisory "USN-3000-1" has invalid fixed revision -1`},
+		{"  severity: high\n", "  severity: [\n", `cannot parse the advisories in the body: .*`},
+	}
+
+	for _, test := range invalidTests {
+		invalid := strings.Replace(encoded, test.original, test.invalid, 1)
+		invalid = regexp.MustCompile(`body-length: \d+`).ReplaceAllString(invalid, "body-length: "+strconv.Itoa(len(strings.Replace(snapAdvBody, test.original, test.invalid, 1))))
+		_, err := asserts.Decode([]byte(invalid))
+		c.Check(err, ErrorMatches, snapAdvErrPrefix+test.expectedErr)
+	}
+}
+
+func (sas *snapAdvSuite) TestAffects(c *C) {
+	fixed := &asserts.Advisory{ID: "a", Severity: "low", FixedRevision: 12}
+	c.Check(fixed.Affects(11), Equals, true)
+	c.Check(fixed.Affects(12), Equals, false)
+	c.Check(fixed.Affects(13), Equals, false)
+	c.Check(fixed.Affects(-1), Equals, false)
+	unfixed := &asserts.Advisory{ID: "b", Severity: "low"}
+	c.Check(unfixed.Affects(100), Equals, true)
+}
+
+func (sas *snapAdvSuite) TestSnapAdvisoryCheck(c *C) {
+	signingKeyID, accSignDB, db := makeSignAndCheckDbWithAccountKey(c, "store-id1")
+
+	headers := map[string]string{
+		"authority-id": "store-id1",
+		"series":       "16",
+		"snap-id":      "snap-id-1",
+		"timestamp":    sas.ts.Format(time.RFC3339),
+	}
+	snapAdv, err := accSignDB.Sign(asserts.SnapAdvisoryType, headers, []byte(snapAdvBody), signingKeyID)
+	c.Assert(err, IsNil)
+
+	c.Assert(db.Add(snapAdv), IsNil)
+	found, err := db.Find(asserts.SnapAdvisoryType, map[string]string{"series": "16", "snap-id": "snap-id-1"})
+	c.Assert(err, IsNil)
+	c.Check(found.(*asserts.SnapAdvisory).Advisories(), HasLen, 2)
+}
@@ -130,23 +130,32 @@ func SetNextBoot(s *snap.Info) error {
 		return fmt.Errorf("cannot set next boot: %s", err)
 	}
 
-	var bootvar string
+	var bootvar, tryvar string
 	switch s.Type {
 	case snap.TypeOS:
 		bootvar = "snappy_os"
+		tryvar = "snappy_try_os"
 	case snap.TypeKernel:
 		bootvar = "snappy_kernel"
+		tryvar = "snappy_try_kernel"
 	}
 	blobName := filepath.Base(s.MountFile())
 	if err := bootloader.SetBootVar(bootvar, blobName); err != nil {
 		return err
 	}
+	// the bootloader sets bootvar back to the good snap when it gives
+	// up on this one, which is then still known from tryvar
+	if err := bootloader.SetBootVar(tryvar, blobName); err != nil {
+		return err
+	}
 
 	if err := bootloader.SetBootVar("snappy_mode", "try"); err != nil {
 		return err
 	}
 
-	return nil
+	// snapd marks the boot successful once the system is healthy,
+	// and reverts if the bootloader gives up on it
+	return partition.CountBootAttempts(bootloader, partition.DefaultBootLimit)
 }
 
 // KernelOrOsRebootRequired returns whether a reboot is required to swith to the given OS or kernel snap.
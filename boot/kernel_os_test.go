@@ -165,8 +165,11 @@ func (s *kernelOSSuite) TestSetNextBootForCore(c *C) {
 	c.Assert(err, IsNil)
 
 	c.Assert(s.bootloader.BootVars, DeepEquals, map[string]string{
-		"snappy_os":   "core_100.snap",
-		"snappy_mode": "try",
+		"snappy_os":            "core_100.snap",
+		"snappy_try_os":        "core_100.snap",
+		"snappy_mode":          "try",
+		"snappy_boot_attempts": "0",
+		"snappy_boot_limit":    "3",
 	})
 
 	c.Check(boot.KernelOrOsRebootRequired(info), Equals, true)
@@ -185,8 +188,11 @@ func (s *kernelOSSuite) TestSetNextBootForKernel(c *C) {
 	c.Assert(err, IsNil)
 
 	c.Assert(s.bootloader.BootVars, DeepEquals, map[string]string{
-		"snappy_kernel": "krnl_42.snap",
-		"snappy_mode":   "try",
+		"snappy_kernel":        "krnl_42.snap",
+		"snappy_try_kernel":    "krnl_42.snap",
+		"snappy_mode":          "try",
+		"snappy_boot_attempts": "0",
+		"snappy_boot_limit":    "3",
 	})
 
 	s.bootloader.BootVars["snappy_good_kernel"] = "krnl_40.snap"
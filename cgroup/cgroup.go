@@ -0,0 +1,165 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package cgroup maps processes to the snap applications they belong to.
+package cgroup
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/snap"
+)
+
+// ErrNotSnap is returned when a process is not part of any snap.
+var ErrNotSnap = errors.New("process is not part of any snap")
+
+// ProcessInfo describes the snap application or hook a process belongs to.
+type ProcessInfo struct {
+	Snap string
+	App  string
+	Hook string
+	// Revision is unset when it cannot be determined.
+	Revision snap.Revision
+}
+
+func procPath(pid int, name string) string {
+	return filepath.Join(dirs.GlobalRootDir, "proc", strconv.Itoa(pid), name)
+}
+
+// ProcessInfoFromPid returns the snap application or hook the given process
+// belongs to.
+//
+// The process is first looked up by its security label, which snap-confine
+// sets to snap.<snap>.<app> or snap.<snap>.hook.<hook>, falling back to its
+// cgroups, which follow the same naming. The revision comes from the
+// environment of the process, if it can be read.
+func ProcessInfoFromPid(pid int) (*ProcessInfo, error) {
+	info, err := infoFromSecurityLabel(pid)
+	if err == ErrNotSnap {
+		info, err = infoFromCgroups(pid)
+	}
+	if err != nil {
+		return nil, err
+	}
+	info.Revision = revisionFromEnviron(pid)
+	return info, nil
+}
+
+// parseSecurityTag parses tags of the form snap.<snap>.<app> and
+// snap.<snap>.hook.<hook>, optionally followed by more dot-separated
+// components as used for the names of transient scopes and services.
+func parseSecurityTag(tag string) *ProcessInfo {
+	parts := strings.Split(tag, ".")
+	if len(parts) < 3 || parts[0] != "snap" {
+		return nil
+	}
+	if err := snap.ValidateName(parts[1]); err != nil {
+		return nil
+	}
+	if parts[2] == "hook" {
+		if len(parts) < 4 {
+			return nil
+		}
+		return &ProcessInfo{Snap: parts[1], Hook: parts[3]}
+	}
+	return &ProcessInfo{Snap: parts[1], App: parts[2]}
+}
+
+// infoFromSecurityLabel looks at the AppArmor label of the process, which
+// reads e.g. "snap.foo.bar (enforce)".
+func infoFromSecurityLabel(pid int) (*ProcessInfo, error) {
+	label, err := ioutil.ReadFile(procPath(pid, "attr/current"))
+	if os.IsNotExist(err) {
+		// no such process, or no LSM to ask
+		if _, err := os.Stat(procPath(pid, "")); err != nil {
+			return nil, err
+		}
+		return nil, ErrNotSnap
+	}
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(string(bytes.TrimRight(label, "\x00")))
+	if len(fields) == 0 {
+		return nil, ErrNotSnap
+	}
+	if info := parseSecurityTag(fields[0]); info != nil {
+		return info, nil
+	}
+	return nil, ErrNotSnap
+}
+
+// infoFromCgroups looks at the cgroups of the process, e.g.
+// "1:name=systemd:/system.slice/snap.foo.bar.service".
+func infoFromCgroups(pid int) (*ProcessInfo, error) {
+	f, err := os.Open(procPath(pid, "cgroup"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// each line is hierarchy-id:controllers:path
+		fields := strings.SplitN(scanner.Text(), ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		for _, component := range strings.Split(fields[2], "/") {
+			component = strings.TrimSuffix(component, ".service")
+			component = strings.TrimSuffix(component, ".scope")
+			if info := parseSecurityTag(component); info != nil {
+				return info, nil
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, ErrNotSnap
+}
+
+// revisionFromEnviron returns the SNAP_REVISION the process was started
+// with, or an unset revision if that is not available.
+func revisionFromEnviron(pid int) snap.Revision {
+	environ, err := ioutil.ReadFile(procPath(pid, "environ"))
+	if err != nil {
+		return snap.Revision{}
+	}
+	for _, entry := range bytes.Split(environ, []byte{0}) {
+		if !bytes.HasPrefix(entry, []byte("SNAP_REVISION=")) {
+			continue
+		}
+		rev, err := snap.ParseRevision(string(entry[len("SNAP_REVISION="):]))
+		if err != nil {
+			return snap.Revision{}
+		}
+		return rev
+	}
+	return snap.Revision{}
+}
@@ -0,0 +1,107 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cgroup_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/cgroup"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/snap"
+)
+
+// Hook up check.v1 into the "go test" runner
+func Test(t *testing.T) { TestingT(t) }
+
+type cgroupSuite struct{}
+
+var _ = Suite(&cgroupSuite{})
+
+func (s *cgroupSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	c.Assert(os.MkdirAll(filepath.Join(dirs.GlobalRootDir, "proc", "42", "attr"), 0755), IsNil)
+}
+
+func (s *cgroupSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("/")
+}
+
+func mockProcFile(c *C, name, content string) {
+	err := ioutil.WriteFile(filepath.Join(dirs.GlobalRootDir, "proc", "42", name), []byte(content), 0644)
+	c.Assert(err, IsNil)
+}
+
+func (s *cgroupSuite) TestFromSecurityLabel(c *C) {
+	mockProcFile(c, "attr/current", "snap.foo.bar (enforce)\n")
+	mockProcFile(c, "environ", "HOME=/home/user\x00SNAP_REVISION=x2\x00")
+
+	info, err := cgroup.ProcessInfoFromPid(42)
+	c.Assert(err, IsNil)
+	c.Check(info, DeepEquals, &cgroup.ProcessInfo{Snap: "foo", App: "bar", Revision: snap.R("x2")})
+}
+
+func (s *cgroupSuite) TestFromSecurityLabelHook(c *C) {
+	mockProcFile(c, "attr/current", "snap.foo.hook.configure (complain)\n")
+
+	info, err := cgroup.ProcessInfoFromPid(42)
+	c.Assert(err, IsNil)
+	c.Check(info, DeepEquals, &cgroup.ProcessInfo{Snap: "foo", Hook: "configure"})
+}
+
+func (s *cgroupSuite) TestFromCgroups(c *C) {
+	mockProcFile(c, "attr/current", "unconfined\n")
+	mockProcFile(c, "cgroup", `11:devices:/user.slice
+1:name=systemd:/system.slice/snap.foo.svc.service
+`)
+	mockProcFile(c, "environ", "SNAP_REVISION=7\x00")
+
+	info, err := cgroup.ProcessInfoFromPid(42)
+	c.Assert(err, IsNil)
+	c.Check(info, DeepEquals, &cgroup.ProcessInfo{Snap: "foo", App: "svc", Revision: snap.R(7)})
+}
+
+func (s *cgroupSuite) TestFromCgroupsWithoutSecurityLabel(c *C) {
+	mockProcFile(c, "cgroup", "11:devices:/snap.foo.bar\n")
+
+	info, err := cgroup.ProcessInfoFromPid(42)
+	c.Assert(err, IsNil)
+	c.Check(info, DeepEquals, &cgroup.ProcessInfo{Snap: "foo", App: "bar"})
+}
+
+func (s *cgroupSuite) TestNotSnap(c *C) {
+	mockProcFile(c, "attr/current", "unconfined\n")
+	mockProcFile(c, "cgroup", `11:devices:/user.slice
+1:name=systemd:/user.slice/user-1000.slice/session-c2.scope
+2:cpu:/snap.Not-A-Snap.app
+`)
+
+	_, err := cgroup.ProcessInfoFromPid(42)
+	c.Check(err, Equals, cgroup.ErrNotSnap)
+}
+
+func (s *cgroupSuite) TestNoSuchProcess(c *C) {
+	_, err := cgroup.ProcessInfoFromPid(1234)
+	c.Check(os.IsNotExist(err), Equals, true)
+}
@@ -0,0 +1,48 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"net/url"
+	"strconv"
+
+	"github.com/snapcore/snapd/snap"
+)
+
+// CgroupInfo describes the snap application or hook a process belongs to.
+type CgroupInfo struct {
+	Snap     string        `json:"snap"`
+	App      string        `json:"app,omitempty"`
+	Hook     string        `json:"hook,omitempty"`
+	Revision snap.Revision `json:"revision"`
+}
+
+// CgroupInfo returns the snap application or hook the given process
+// belongs to.
+func (client *Client) CgroupInfo(pid int) (*CgroupInfo, error) {
+	query := url.Values{}
+	query.Set("pid", strconv.Itoa(pid))
+
+	var info CgroupInfo
+	if _, err := client.doSync("GET", "/v2/cgroup-info", query, nil, nil, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
@@ -36,6 +36,13 @@ type Change struct {
 	Tasks   []*Task `json:"tasks,omitempty"`
 	Ready   bool    `json:"ready"`
 	Err     string  `json:"err,omitempty"`
+	// ErrorCode classifies the cause of a failed change, one of
+	// network, space, assertion, policy-compile, hook-failed,
+	// service-start, scan-rejected or unknown.
+	ErrorCode string `json:"error-code,omitempty"`
+	// RebootRequired is whether the change needs a reboot to be
+	// fully applied.
+	RebootRequired bool `json:"reboot-required,omitempty"`
 
 	SpawnTime time.Time `json:"spawn-time,omitempty"`
 	ReadyTime time.Time `json:"ready-time,omitempty"`
@@ -43,6 +50,17 @@ type Change struct {
 	data map[string]*json.RawMessage
 }
 
+// SnapResult is the outcome for one snap of a change refreshing several
+// snaps, as found in the "snap-results" data of auto-refresh changes.
+type SnapResult struct {
+	// Status is one of succeeded, failed, undone (as the refresh of
+	// another snap failed) or skipped (e.g. as another change was in
+	// progress for the snap).
+	Status    string `json:"status"`
+	ErrorCode string `json:"error-code,omitempty"`
+	Message   string `json:"message,omitempty"`
+}
+
 var ErrNoData = fmt.Errorf("data entry not found")
 
 // Get unmarshals into value the kind-specific data with the provided key.
@@ -62,6 +80,11 @@ type Task struct {
 	Status   string       `json:"status"`
 	Log      []string     `json:"log,omitempty"`
 	Progress TaskProgress `json:"progress"`
+	// Output is the end of the output of a hook or failed service
+	// run by the task, if any.
+	Output string `json:"output,omitempty"`
+	// WaitTasks are the IDs of the tasks the task waits for.
+	WaitTasks []string `json:"wait-tasks,omitempty"`
 
 	SpawnTime time.Time `json:"spawn-time,omitempty"`
 	ReadyTime time.Time `json:"ready-time,omitempty"`
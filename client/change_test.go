@@ -27,6 +27,64 @@ import (
 	"time"
 )
 
+func (cs *clientSuite) TestClientChangeErrorCode(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": {
+  "id":   "uno",
+  "kind": "foo",
+  "summary": "...",
+  "status": "Error",
+  "ready": true,
+  "err": "cannot perform the following tasks: ...",
+  "error-code": "network"
+}}`
+
+	chg, err := cs.cli.Change("uno")
+	c.Assert(err, check.IsNil)
+	c.Check(chg.Err, check.Equals, "cannot perform the following tasks: ...")
+	c.Check(chg.ErrorCode, check.Equals, "network")
+}
+
+func (cs *clientSuite) TestClientChangeTaskOutput(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": {
+  "id":   "uno",
+  "kind": "foo",
+  "summary": "...",
+  "status": "Error",
+  "ready": true,
+  "tasks": [{"kind": "run-hook", "summary": "...", "status": "Error", "output": "error: cannot frobnicate\n"}]
+}}`
+
+	chg, err := cs.cli.Change("uno")
+	c.Assert(err, check.IsNil)
+	c.Assert(chg.Tasks, check.HasLen, 1)
+	c.Check(chg.Tasks[0].Output, check.Equals, "error: cannot frobnicate\n")
+}
+
+func (cs *clientSuite) TestClientChangeSnapResults(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": {
+  "id":   "uno",
+  "kind": "auto-refresh",
+  "summary": "...",
+  "status": "Error",
+  "ready": true,
+  "data": {"snap-names": ["bar", "foo"], "snap-results": {
+    "foo": {"status": "failed", "error-code": "network", "message": "dial tcp: i/o timeout"},
+    "bar": {"status": "undone"},
+    "baz": {"status": "skipped", "message": "snap \"baz\" has changes in progress"}
+  }}
+}}`
+
+	chg, err := cs.cli.Change("uno")
+	c.Assert(err, check.IsNil)
+	var results map[string]*client.SnapResult
+	c.Assert(chg.Get("snap-results", &results), check.IsNil)
+	c.Check(results, check.DeepEquals, map[string]*client.SnapResult{
+		"foo": {Status: "failed", ErrorCode: "network", Message: "dial tcp: i/o timeout"},
+		"bar": {Status: "undone"},
+		"baz": {Status: "skipped", Message: `snap "baz" has changes in progress`},
+	})
+}
+
 func (cs *clientSuite) TestClientChange(c *check.C) {
 	cs.rsp = `{"type": "sync", "result": {
   "id":   "uno",
@@ -29,6 +29,8 @@ import (
 	"net/url"
 	"os"
 	"path"
+	"strings"
+	"time"
 
 	"github.com/snapcore/snapd/dirs"
 )
@@ -96,6 +98,30 @@ func (client *Client) setAuthorization(req *http.Request) error {
 	return nil
 }
 
+// acceptLanguage returns the language of the messages of the user as
+// an Accept-Language value, e.g. de-DE for de_DE.UTF-8, following the
+// precedence of gettext for the environment. It is empty for the
+// untranslated C and POSIX locales.
+func acceptLanguage() string {
+	var locale string
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if locale = os.Getenv(name); locale != "" {
+			break
+		}
+	}
+	if i := strings.IndexAny(locale, ".@"); i >= 0 {
+		locale = locale[:i]
+	}
+	if locale == "" || locale == "C" || locale == "POSIX" {
+		return ""
+	}
+	// LANGUAGE lists the preferred languages, in order
+	if languages := os.Getenv("LANGUAGE"); languages != "" {
+		locale = strings.Split(languages, ":")[0]
+	}
+	return strings.Replace(locale, "_", "-", -1)
+}
+
 // raw performs a request and returns the resulting http.Response and
 // error you usually only need to call this directly if you expect the
 // response to not be JSON, otherwise you'd call Do(...) instead.
@@ -109,6 +135,11 @@ func (client *Client) raw(method, urlpath string, query url.Values, headers map[
 		return nil, err
 	}
 
+	// let snapd answer in the language of the user
+	if lang := acceptLanguage(); lang != "" {
+		req.Header.Set("Accept-Language", lang)
+	}
+
 	for key, value := range headers {
 		req.Header.Set(key, value)
 	}
@@ -231,6 +262,7 @@ const (
 	ErrorKindTwoFactorRequired = "two-factor-required"
 	ErrorKindTwoFactorFailed   = "two-factor-failed"
 	ErrorKindLoginRequired     = "login-required"
+	ErrorKindNothingToRefresh  = "nothing-to-refresh"
 )
 
 // IsTwoFactorError returns whether the given error is due to problems
@@ -248,6 +280,33 @@ func IsTwoFactorError(err error) bool {
 type SysInfo struct {
 	Series  string `json:"series,omitempty"`
 	Version string `json:"version,omitempty"`
+
+	Refresh *RefreshInfo `json:"refresh,omitempty"`
+	// RebootRequired lists the snaps the device needs to reboot for
+	// to fully use.
+	RebootRequired []string `json:"reboot-required,omitempty"`
+	// RebootAt is when the device is due to reboot for them, within
+	// the windows of reboot.schedule if set.
+	RebootAt time.Time `json:"reboot-at,omitempty"`
+	// Crypto describes how snapd does its digest and signature
+	// operations.
+	Crypto *CryptoInfo `json:"crypto,omitempty"`
+}
+
+// CryptoInfo holds the provider of the digest and signature operations
+// and whether they are restricted to the FIPS approved algorithms.
+type CryptoInfo struct {
+	Provider string `json:"provider"`
+	FIPSMode bool   `json:"fips-mode"`
+}
+
+// RefreshInfo holds the status of the automatic refreshes of the snaps.
+type RefreshInfo struct {
+	Last time.Time `json:"last,omitempty"`
+	Next time.Time `json:"next,omitempty"`
+	// Failures is the number of consecutive failed attempts
+	Failures  int    `json:"failures,omitempty"`
+	LastError string `json:"last-error,omitempty"`
 }
 
 func (rsp *response) err() error {
@@ -292,3 +351,32 @@ func (client *Client) SysInfo() (*SysInfo, error) {
 
 	return &sysInfo, nil
 }
+
+// RebootNow has the device reboot right away for the snaps waiting for
+// it, instead of in the next window of reboot.schedule.
+func (client *Client) RebootNow() error {
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(map[string]string{"action": "now"}); err != nil {
+		return err
+	}
+	_, err := client.doSync("POST", "/v2/reboot", nil, nil, &body, nil)
+	return err
+}
+
+// RestartServices has snapd restart the running services of the snaps,
+// for them to pick up changes to the system like to its locale. It
+// returns the id of the change restarting them, or "" if there are none.
+func (client *Client) RestartServices() (changeID string, err error) {
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(map[string]string{"action": "restart"}); err != nil {
+		return "", err
+	}
+	var rsp response
+	if err := client.do("POST", "/v2/services", nil, nil, &body, &rsp); err != nil {
+		return "", fmt.Errorf("cannot communicate with server: %v", err)
+	}
+	if err := rsp.err(); err != nil {
+		return "", err
+	}
+	return rsp.Change, nil
+}
@@ -98,6 +98,39 @@ func (cs *clientSuite) TestClientWorks(c *check.C) {
 	c.Check(cs.req.URL.Path, check.Equals, "/this")
 }
 
+func (cs *clientSuite) TestClientSendsLanguage(c *check.C) {
+	vars := []string{"LANGUAGE", "LC_ALL", "LC_MESSAGES", "LANG"}
+	saved := make(map[string]string, len(vars))
+	for _, name := range vars {
+		saved[name] = os.Getenv(name)
+	}
+	defer func() {
+		for name, value := range saved {
+			os.Setenv(name, value)
+		}
+	}()
+
+	for _, t := range []struct {
+		env  map[string]string
+		lang string
+	}{
+		{map[string]string{"LANG": "de_DE.UTF-8"}, "de-DE"},
+		{map[string]string{"LANG": "de_DE.UTF-8", "LC_MESSAGES": "gl_ES.UTF-8"}, "gl-ES"},
+		{map[string]string{"LANG": "de_DE.UTF-8", "LC_ALL": "es"}, "es"},
+		{map[string]string{"LANG": "de_DE.UTF-8", "LANGUAGE": "ug:de"}, "ug"},
+		{map[string]string{"LANG": "C.UTF-8", "LANGUAGE": "ug:de"}, ""},
+		{map[string]string{"LC_ALL": "POSIX"}, ""},
+		{map[string]string{}, ""},
+	} {
+		for _, name := range vars {
+			os.Setenv(name, t.env[name])
+		}
+		err := cs.cli.Do("GET", "/", nil, nil, nil)
+		c.Assert(err, check.IsNil)
+		c.Check(cs.req.Header.Get("Accept-Language"), check.Equals, t.lang, check.Commentf("%v", t.env))
+	}
+}
+
 func (cs *clientSuite) TestClientDefaultsToNoAuthorization(c *check.C) {
 	home := os.Getenv("HOME")
 	tmpdir := c.MkDir()
@@ -141,6 +174,63 @@ func (cs *clientSuite) TestClientSysInfo(c *check.C) {
 	})
 }
 
+func (cs *clientSuite) TestClientSysInfoRebootRequired(c *check.C) {
+	cs.rsp = `{"type": "sync", "result":
+                     {"series": "16",
+                      "version": "2",
+                      "reboot-required": ["core", "pc-kernel"]}}`
+	sysInfo, err := cs.cli.SysInfo()
+	c.Check(err, check.IsNil)
+	c.Check(sysInfo, check.DeepEquals, &client.SysInfo{
+		Version:        "2",
+		Series:         "16",
+		RebootRequired: []string{"core", "pc-kernel"},
+	})
+}
+
+func (cs *clientSuite) TestClientSysInfoCrypto(c *check.C) {
+	cs.rsp = `{"type": "sync", "result":
+                     {"series": "16",
+                      "version": "2",
+                      "crypto": {"provider": "go", "fips-mode": true}}}`
+	sysInfo, err := cs.cli.SysInfo()
+	c.Check(err, check.IsNil)
+	c.Check(sysInfo, check.DeepEquals, &client.SysInfo{
+		Version: "2",
+		Series:  "16",
+		Crypto:  &client.CryptoInfo{Provider: "go", FIPSMode: true},
+	})
+}
+
+func (cs *clientSuite) TestClientRebootNow(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": null}`
+	c.Assert(cs.cli.RebootNow(), check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/reboot")
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	c.Check(string(body), check.Equals, `{"action":"now"}`+"\n")
+}
+
+func (cs *clientSuite) TestClientRestartServices(c *check.C) {
+	cs.rsp = `{"type": "async", "status-code": 202, "change": "42"}`
+	id, err := cs.cli.RestartServices()
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, "42")
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/services")
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	c.Check(string(body), check.Equals, `{"action":"restart"}`+"\n")
+}
+
+func (cs *clientSuite) TestClientRestartServicesNone(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": null}`
+	id, err := cs.cli.RestartServices()
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, "")
+}
+
 func (cs *clientSuite) TestClientIntegration(c *check.C) {
 	c.Assert(os.MkdirAll(filepath.Dir(dirs.SnapdSocket), 0755), check.IsNil)
 	l, err := net.Listen("unix", dirs.SnapdSocket)
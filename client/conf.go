@@ -0,0 +1,74 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// SetConf requests a snap to apply the provided patch to the
+// configuration, all of it or none. When the snap has a configure hook
+// the patch is applied by a change running it, whose id is returned;
+// otherwise it is applied right away and the id is empty.
+func (client *Client) SetConf(snapName string, patch map[string]interface{}) (changeID string, err error) {
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(patch); err != nil {
+		return "", err
+	}
+
+	path := fmt.Sprintf("/v2/snaps/%s/conf", snapName)
+	var rsp response
+	if err := client.do("PUT", path, nil, nil, &body, &rsp); err != nil {
+		return "", fmt.Errorf("cannot communicate with server: %v", err)
+	}
+	if err := rsp.err(); err != nil {
+		return "", err
+	}
+	switch rsp.Type {
+	case "sync":
+		return "", nil
+	case "async":
+		if rsp.Change == "" {
+			return "", fmt.Errorf("async response without change reference")
+		}
+		return rsp.Change, nil
+	}
+	return "", fmt.Errorf("unexpected response for %q on %q: %q", "PUT", path, rsp.Type)
+}
+
+// Conf asks for a snap's current configuration, the options with the
+// given keys or all of it if there are none.
+func (client *Client) Conf(snapName string, keys []string) (configuration map[string]interface{}, err error) {
+	query := url.Values{}
+	if len(keys) > 0 {
+		query.Set("keys", strings.Join(keys, ","))
+	}
+
+	path := fmt.Sprintf("/v2/snaps/%s/conf", snapName)
+	_, err = client.doSync("GET", path, query, nil, nil, &configuration)
+	if err != nil {
+		return nil, err
+	}
+	return configuration, nil
+}
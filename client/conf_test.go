@@ -0,0 +1,66 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"encoding/json"
+
+	"gopkg.in/check.v1"
+)
+
+func (cs *clientSuite) TestClientSetConf(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": null}`
+	id, err := cs.cli.SetConf("core", map[string]interface{}{"store-certs.corp": "PEM"})
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, "")
+	c.Check(cs.req.Method, check.Equals, "PUT")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snaps/core/conf")
+
+	var body map[string]interface{}
+	c.Assert(json.NewDecoder(cs.req.Body).Decode(&body), check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{"store-certs.corp": "PEM"})
+}
+
+func (cs *clientSuite) TestClientSetConfAsync(c *check.C) {
+	cs.rsp = `{"type": "async", "status-code": 202, "change": "42"}`
+	id, err := cs.cli.SetConf("foo", map[string]interface{}{"port": 8080})
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, "42")
+}
+
+func (cs *clientSuite) TestClientConf(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": {"store-certs": {"corp": "PEM"}}}`
+	value, err := cs.cli.Conf("core", []string{"store-certs", "other"})
+	c.Assert(err, check.IsNil)
+	c.Check(value, check.DeepEquals, map[string]interface{}{
+		"store-certs": map[string]interface{}{"corp": "PEM"},
+	})
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snaps/core/conf")
+	c.Check(cs.req.URL.Query().Get("keys"), check.Equals, "store-certs,other")
+}
+
+func (cs *clientSuite) TestClientConfAll(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": {"port": 8080}}`
+	value, err := cs.cli.Conf("foo", nil)
+	c.Assert(err, check.IsNil)
+	c.Check(value, check.DeepEquals, map[string]interface{}{"port": 8080.0})
+	c.Check(cs.req.URL.RawQuery, check.Equals, "")
+}
@@ -0,0 +1,59 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"time"
+)
+
+// ConnectivityTLS describes the TLS connection to an endpoint.
+type ConnectivityTLS struct {
+	Version     string    `json:"version"`
+	CipherSuite string    `json:"cipher-suite"`
+	ServerName  string    `json:"server-name,omitempty"`
+	Subject     string    `json:"subject,omitempty"`
+	Issuer      string    `json:"issuer,omitempty"`
+	NotAfter    time.Time `json:"not-after,omitempty"`
+}
+
+// ConnectivityResult is the outcome of reaching one of the endpoints
+// snapd uses. Times are in milliseconds.
+type ConnectivityResult struct {
+	Endpoint    string           `json:"endpoint"`
+	URL         string           `json:"url"`
+	Proxy       string           `json:"proxy,omitempty"`
+	Status      int              `json:"status,omitempty"`
+	Error       string           `json:"error,omitempty"`
+	DNSTime     int64            `json:"dns-time"`
+	ConnectTime int64            `json:"connect-time"`
+	TotalTime   int64            `json:"total-time"`
+	TLS         *ConnectivityTLS `json:"tls,omitempty"`
+}
+
+// Connectivity checks the reachability of the endpoints snapd uses, the
+// store, the authentication service and the CDN, through the proxies in
+// effect for snapd.
+func (client *Client) Connectivity() ([]*ConnectivityResult, error) {
+	var results []*ConnectivityResult
+	if _, err := client.doSync("GET", "/v2/debug/connectivity", nil, nil, nil, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
@@ -0,0 +1,54 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+)
+
+func (cs *clientSuite) TestClientConnectivity(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": [
+		{"endpoint": "search", "url": "https://search.apps.ubuntu.com/api/v1/search", "status": 200, "dns-time": 3, "connect-time": 40, "total-time": 210,
+		 "tls": {"version": "TLS 1.2", "cipher-suite": "0xc02f", "subject": "*.apps.ubuntu.com"}},
+		{"endpoint": "cdn", "url": "", "proxy": "http://proxy:3128", "error": "cannot connect to proxy:3128: connection refused", "dns-time": 1, "connect-time": 0, "total-time": 0}
+	]}`
+	results, err := cs.cli.Connectivity()
+	c.Assert(err, check.IsNil)
+	c.Check(results, check.DeepEquals, []*client.ConnectivityResult{
+		{
+			Endpoint:    "search",
+			URL:         "https://search.apps.ubuntu.com/api/v1/search",
+			Status:      200,
+			DNSTime:     3,
+			ConnectTime: 40,
+			TotalTime:   210,
+			TLS:         &client.ConnectivityTLS{Version: "TLS 1.2", CipherSuite: "0xc02f", Subject: "*.apps.ubuntu.com"},
+		}, {
+			Endpoint: "cdn",
+			Proxy:    "http://proxy:3128",
+			Error:    "cannot connect to proxy:3128: connection refused",
+			DNSTime:  1,
+		},
+	})
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/debug/connectivity")
+}
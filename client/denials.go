@@ -0,0 +1,69 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"net/url"
+	"time"
+)
+
+// Denial aggregates the apparmor denials of the same access by an app
+// or a hook of a snap, with the interfaces whose connection would have
+// allowed it.
+type Denial struct {
+	Snap string `json:"snap"`
+	App  string `json:"app,omitempty"`
+	Hook string `json:"hook,omitempty"`
+
+	Operation  string `json:"operation"`
+	Path       string `json:"path,omitempty"`
+	Capability string `json:"capability,omitempty"`
+	// Denied is the mask of the denied file permissions, e.g. "rw".
+	Denied string `json:"denied,omitempty"`
+
+	Count     int       `json:"count"`
+	FirstSeen time.Time `json:"first-seen"`
+	LastSeen  time.Time `json:"last-seen"`
+
+	Suggestions []InterfaceSuggestion `json:"suggestions,omitempty"`
+}
+
+// InterfaceSuggestion is an interface whose connection would allow an
+// access, with the plug of the snap for it if it has one.
+type InterfaceSuggestion struct {
+	Interface string `json:"interface"`
+	Plug      string `json:"plug,omitempty"`
+	Connected bool   `json:"connected"`
+}
+
+// Denials returns the apparmor denials collected for the given snap, or
+// for all the snaps if snapName is empty, the most recently seen first.
+func (client *Client) Denials(snapName string) ([]*Denial, error) {
+	query := url.Values{}
+	if snapName != "" {
+		query.Set("snap", snapName)
+	}
+
+	var denials []*Denial
+	if _, err := client.doSync("GET", "/v2/debug/denials", query, nil, nil, &denials); err != nil {
+		return nil, err
+	}
+	return denials, nil
+}
@@ -0,0 +1,58 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+)
+
+func (cs *clientSuite) TestClientDenials(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": [{
+		"snap": "foo",
+		"app": "app",
+		"operation": "open",
+		"path": "/media/usb0/file",
+		"denied": "r",
+		"count": 2,
+		"first-seen": "2016-10-14T15:00:00Z",
+		"last-seen": "2016-10-14T15:05:00Z",
+		"suggestions": [{"interface": "removable-media", "plug": "media", "connected": false}]
+	}]}`
+	denials, err := cs.cli.Denials("foo")
+	c.Assert(err, check.IsNil)
+	c.Check(denials, check.DeepEquals, []*client.Denial{{
+		Snap:        "foo",
+		App:         "app",
+		Operation:   "open",
+		Path:        "/media/usb0/file",
+		Denied:      "r",
+		Count:       2,
+		FirstSeen:   time.Date(2016, 10, 14, 15, 0, 0, 0, time.UTC),
+		LastSeen:    time.Date(2016, 10, 14, 15, 5, 0, 0, time.UTC),
+		Suggestions: []client.InterfaceSuggestion{{Interface: "removable-media", Plug: "media"}},
+	}})
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/debug/denials")
+	c.Check(cs.req.URL.Query().Get("snap"), check.Equals, "foo")
+}
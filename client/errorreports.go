@@ -0,0 +1,50 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"time"
+)
+
+// ErrorReport describes a failure of snapd, as reported to the error
+// tracking service once the user opted in.
+type ErrorReport struct {
+	ID            string    `json:"id"`
+	Time          time.Time `json:"time"`
+	Code          string    `json:"error-code"`
+	Snap          string    `json:"snap,omitempty"`
+	Revision      string    `json:"revision,omitempty"`
+	Distro        string    `json:"distro"`
+	DistroRelease string    `json:"distro-release,omitempty"`
+	Architecture  string    `json:"architecture"`
+	Log           []string  `json:"log,omitempty"`
+	// Sent is when the report was submitted, zero while it is queued.
+	Sent time.Time `json:"sent,omitempty"`
+}
+
+// ErrorReports returns the error reports kept by snapd, the ones
+// submitted and the ones queued for submission, oldest first.
+func (client *Client) ErrorReports() ([]*ErrorReport, error) {
+	var reports []*ErrorReport
+	if _, err := client.doSync("GET", "/v2/debug/error-reports", nil, nil, nil, &reports); err != nil {
+		return nil, err
+	}
+	return reports, nil
+}
@@ -0,0 +1,52 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+)
+
+func (cs *clientSuite) TestClientErrorReports(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": [
+		{"id": "1475323200000000000", "time": "2016-10-01T12:00:00Z", "error-code": "network", "snap": "foo", "revision": "7",
+		 "distro": "ubuntu", "distro-release": "16.04", "architecture": "amd64", "log": ["ERROR dial tcp <ip>:443: i/o timeout"],
+		 "sent": "2016-10-01T12:00:05Z"}
+	]}`
+	reports, err := cs.cli.ErrorReports()
+	c.Assert(err, check.IsNil)
+	c.Check(reports, check.DeepEquals, []*client.ErrorReport{{
+		ID:            "1475323200000000000",
+		Time:          time.Date(2016, 10, 1, 12, 0, 0, 0, time.UTC),
+		Code:          "network",
+		Snap:          "foo",
+		Revision:      "7",
+		Distro:        "ubuntu",
+		DistroRelease: "16.04",
+		Architecture:  "amd64",
+		Log:           []string{"ERROR dial tcp <ip>:443: i/o timeout"},
+		Sent:          time.Date(2016, 10, 1, 12, 0, 5, 0, time.UTC),
+	}})
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/debug/error-reports")
+}
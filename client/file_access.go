@@ -0,0 +1,68 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"net/url"
+)
+
+// FileAccess is what an application of a snap can do with a path under
+// its current confinement, and the interfaces that would let it do more.
+type FileAccess struct {
+	Snap    string `json:"snap"`
+	App     string `json:"app"`
+	Path    string `json:"path"`
+	DevMode bool   `json:"devmode,omitempty"`
+	Read    bool   `json:"read"`
+	Write   bool   `json:"write"`
+	Execute bool   `json:"execute"`
+
+	Interfaces []FileAccessInterface `json:"interfaces,omitempty"`
+}
+
+// FileAccessInterface is what connecting a plug of an interface lets an
+// application do with a path.
+type FileAccessInterface struct {
+	Interface string `json:"interface"`
+	// Plug is the plug of the snap for the interface, if it has one.
+	Plug      string `json:"plug,omitempty"`
+	Connected bool   `json:"connected"`
+	Read      bool   `json:"read"`
+	Write     bool   `json:"write"`
+	Execute   bool   `json:"execute"`
+}
+
+// FileAccess returns whether the given application of the snap can read,
+// write or execute the given path, and which interfaces would allow it.
+// The only application of the snap is used if appName is empty.
+func (client *Client) FileAccess(snapName, appName, path string) (*FileAccess, error) {
+	query := url.Values{}
+	query.Set("snap", snapName)
+	if appName != "" {
+		query.Set("app", appName)
+	}
+	query.Set("path", path)
+
+	var access FileAccess
+	if _, err := client.doSync("GET", "/v2/file-access", query, nil, nil, &access); err != nil {
+		return nil, err
+	}
+	return &access, nil
+}
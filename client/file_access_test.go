@@ -0,0 +1,62 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+)
+
+func (cs *clientSuite) TestClientFileAccess(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": {
+		"snap": "foo",
+		"app": "app",
+		"path": "/media/usb0/file",
+		"read": true,
+		"write": false,
+		"execute": false,
+		"interfaces": [{"interface": "removable-media", "plug": "media", "connected": false, "read": true, "write": true, "execute": false}]
+	}}`
+	access, err := cs.cli.FileAccess("foo", "app", "/media/usb0/file")
+	c.Assert(err, check.IsNil)
+	c.Check(access, check.DeepEquals, &client.FileAccess{
+		Snap: "foo",
+		App:  "app",
+		Path: "/media/usb0/file",
+		Read: true,
+		Interfaces: []client.FileAccessInterface{
+			{Interface: "removable-media", Plug: "media", Read: true, Write: true},
+		},
+	})
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/file-access")
+	c.Check(cs.req.URL.Query().Get("snap"), check.Equals, "foo")
+	c.Check(cs.req.URL.Query().Get("app"), check.Equals, "app")
+	c.Check(cs.req.URL.Query().Get("path"), check.Equals, "/media/usb0/file")
+}
+
+func (cs *clientSuite) TestClientFileAccessNoApp(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": {"snap": "foo", "app": "foo", "path": "/srv"}}`
+	_, err := cs.cli.FileAccess("foo", "", "/srv")
+	c.Assert(err, check.IsNil)
+	_, ok := cs.req.URL.Query()["app"]
+	c.Check(ok, check.Equals, false)
+}
@@ -0,0 +1,58 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// License holds the license text of a snap whose license must be
+// accepted before its services are started, and whether it was.
+type License struct {
+	Intro   string `json:"intro,omitempty"`
+	License string `json:"license,omitempty"`
+	Agreed  bool   `json:"agreed"`
+}
+
+// License returns the license of the installed snap with the given name.
+func (client *Client) License(name string) (*License, error) {
+	var license License
+	path := fmt.Sprintf("/v2/snaps/%s/license", name)
+	if _, err := client.doSync("GET", path, nil, nil, nil, &license); err != nil {
+		return nil, err
+	}
+	return &license, nil
+}
+
+// AcceptLicense accepts the license of the installed snap with the
+// given name, starting its services.
+func (client *Client) AcceptLicense(name string) (changeID string, err error) {
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(&License{Agreed: true}); err != nil {
+		return "", err
+	}
+	path := fmt.Sprintf("/v2/snaps/%s/license", name)
+	headers := map[string]string{
+		"Content-Type": "application/json",
+	}
+	return client.doAsync("POST", path, nil, headers, &body)
+}
@@ -0,0 +1,53 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"io/ioutil"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+)
+
+func (cs *clientSuite) TestClientLicense(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": {"intro": "foo requires...", "license": "You must agree.\n", "agreed": true}}`
+	license, err := cs.cli.License("foo")
+	c.Assert(err, check.IsNil)
+	c.Check(license, check.DeepEquals, &client.License{
+		Intro:   "foo requires...",
+		License: "You must agree.\n",
+		Agreed:  true,
+	})
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snaps/foo/license")
+}
+
+func (cs *clientSuite) TestClientAcceptLicense(c *check.C) {
+	cs.rsp = `{"type": "async", "status-code": 202, "result": {}, "change": "42"}`
+	id, err := cs.cli.AcceptLicense("foo")
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, "42")
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snaps/foo/license")
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	c.Check(string(body), check.Equals, `{"agreed":true}`+"\n")
+}
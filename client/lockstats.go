@@ -0,0 +1,56 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"time"
+)
+
+// LockOpStats are the statistics of the state lock of snapd for an
+// operation, the function that took the lock.
+type LockOpStats struct {
+	Op        string        `json:"operation"`
+	Count     int           `json:"count"`
+	TotalWait time.Duration `json:"total-wait"`
+	MaxWait   time.Duration `json:"max-wait"`
+	TotalHold time.Duration `json:"total-hold"`
+	MaxHold   time.Duration `json:"max-hold"`
+}
+
+// LockStats are the statistics of the state lock of snapd.
+type LockStats struct {
+	// Holder is the operation holding the lock, if any, since
+	// HeldSince.
+	Holder    string     `json:"holder,omitempty"`
+	HeldSince *time.Time `json:"held-since,omitempty"`
+	// Ops are the operations that took the lock, the longest holds
+	// first.
+	Ops []*LockOpStats `json:"operations"`
+}
+
+// LockStats returns who holds the state lock of snapd and for how long
+// the operations waited for it and held it.
+func (client *Client) LockStats() (*LockStats, error) {
+	var stats LockStats
+	if _, err := client.doSync("GET", "/v2/debug/lock-stats", nil, nil, nil, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
@@ -0,0 +1,53 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+)
+
+func (cs *clientSuite) TestClientLockStats(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": {
+		"holder": "snapstate.(*SnapManager).doLinkSnap",
+		"held-since": "2016-10-01T12:00:00Z",
+		"operations": [{"operation": "snapstate.(*SnapManager).doLinkSnap", "count": 3, "total-wait": 1000, "max-wait": 600, "total-hold": 5000000000, "max-hold": 4000000000}]
+	}}`
+	stats, err := cs.cli.LockStats()
+	c.Assert(err, check.IsNil)
+	heldSince := time.Date(2016, 10, 1, 12, 0, 0, 0, time.UTC)
+	c.Check(stats, check.DeepEquals, &client.LockStats{
+		Holder:    "snapstate.(*SnapManager).doLinkSnap",
+		HeldSince: &heldSince,
+		Ops: []*client.LockOpStats{{
+			Op:        "snapstate.(*SnapManager).doLinkSnap",
+			Count:     3,
+			TotalWait: 1000,
+			MaxWait:   600,
+			TotalHold: 5 * time.Second,
+			MaxHold:   4 * time.Second,
+		}},
+	})
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/debug/lock-stats")
+}
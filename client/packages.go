@@ -49,7 +49,25 @@ type Snap struct {
 	TryMode       bool          `json:"trymode"`
 	Apps          []AppInfo     `json:"apps"`
 
+	// these are only set for installed snaps whose license must be
+	// accepted before their services are started
+	LicenseAgreement string `json:"license-agreement,omitempty"`
+	LicenseAccepted  bool   `json:"license-accepted,omitempty"`
+
 	Prices map[string]float64 `json:"prices"`
+
+	// these are only set for the snaps looked up by name in the store
+	Publisher   string                           `json:"publisher,omitempty"`
+	License     string                           `json:"license,omitempty"`
+	Screenshots []string                         `json:"screenshots,omitempty"`
+	Channels    map[string]*snap.ChannelSnapInfo `json:"channels,omitempty"`
+
+	// these are only set for the snaps listed as available updates
+	CurrentVersion  string        `json:"current-version,omitempty"`
+	CurrentRevision snap.Revision `json:"current-revision,omitempty"`
+	DeltaSize       int64         `json:"delta-size,omitempty"`
+	ReleaseNotesURL string        `json:"release-notes-url,omitempty"`
+	RebootRequired  bool          `json:"reboot-required,omitempty"`
 }
 
 type AppInfo struct {
@@ -82,6 +100,14 @@ type ResultInfo struct {
 type FindOptions struct {
 	Refresh bool
 	Query   string
+
+	// Name looks up the snap with exactly this name instead, with
+	// its full details.
+	Name string
+
+	// UnholdRollout includes in the refreshes the revisions being
+	// released progressively that do not include the device yet.
+	UnholdRollout bool
 }
 
 // List returns the list of all snaps installed on the system
@@ -119,9 +145,16 @@ func (client *Client) Find(opts *FindOptions) ([]*Snap, *ResultInfo, error) {
 	}
 
 	q := url.Values{}
+	if opts.Name != "" {
+		q.Set("name", opts.Name)
+		return client.snapsFromPath("/v2/find", q)
+	}
 	q.Set("q", opts.Query)
 	if opts.Refresh {
 		q.Set("select", "refresh")
+		if opts.UnholdRollout {
+			q.Set("unhold-rollout", "true")
+		}
 	}
 
 	return client.snapsFromPath("/v2/find", q)
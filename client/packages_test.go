@@ -27,6 +27,7 @@ import (
 	"gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/snap"
 )
 
 func (cs *clientSuite) TestClientSnapsCallsEndpoint(c *check.C) {
@@ -47,6 +48,44 @@ func (cs *clientSuite) TestClientFindRefreshSetsQuery(c *check.C) {
 	})
 }
 
+func (cs *clientSuite) TestClientFindRefreshUnholdRolloutSetsQuery(c *check.C) {
+	_, _, _ = cs.cli.Find(&client.FindOptions{
+		Refresh:       true,
+		UnholdRollout: true,
+	})
+	c.Check(cs.req.URL.Query(), check.DeepEquals, url.Values{
+		"q": []string{""}, "select": []string{"refresh"}, "unhold-rollout": []string{"true"},
+	})
+}
+
+func (cs *clientSuite) TestClientFindName(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"result": [{
+			"name": "hello-world",
+			"publisher": "Canonical",
+			"license": "GPL-3.0",
+			"screenshots": ["https://example.com/shot.png"],
+			"channels": {
+				"stable": {"revision": "25", "version": "6.0", "confinement": "strict"}
+			}
+		}]
+	}`
+	snaps, _, err := cs.cli.Find(&client.FindOptions{Name: "hello-world"})
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/find")
+	c.Check(cs.req.URL.Query(), check.DeepEquals, url.Values{
+		"name": []string{"hello-world"},
+	})
+	c.Assert(snaps, check.HasLen, 1)
+	c.Check(snaps[0].Publisher, check.Equals, "Canonical")
+	c.Check(snaps[0].License, check.Equals, "GPL-3.0")
+	c.Check(snaps[0].Screenshots, check.DeepEquals, []string{"https://example.com/shot.png"})
+	c.Check(snaps[0].Channels, check.DeepEquals, map[string]*snap.ChannelSnapInfo{
+		"stable": {Revision: snap.R(25), Version: "6.0", Confinement: snap.StrictConfinement},
+	})
+}
+
 func (cs *clientSuite) TestClientSnapsInvalidSnapsJSON(c *check.C) {
 	cs.rsp = `{
 		"type": "sync",
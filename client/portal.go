@@ -0,0 +1,46 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"net/url"
+	"strconv"
+)
+
+// PortalInfo holds what xdg-desktop-portal needs to know about the snap a
+// process belongs to.
+type PortalInfo struct {
+	Snap        string `json:"snap"`
+	App         string `json:"app,omitempty"`
+	DesktopFile string `json:"desktop-file,omitempty"`
+	HasNetwork  bool   `json:"has-network"`
+}
+
+// PortalInfo returns information about the snap the given process belongs to.
+func (client *Client) PortalInfo(pid int) (*PortalInfo, error) {
+	query := url.Values{}
+	query.Set("pid", strconv.Itoa(pid))
+
+	var info PortalInfo
+	if _, err := client.doSync("GET", "/v2/portal-info", query, nil, nil, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
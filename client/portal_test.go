@@ -0,0 +1,52 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+)
+
+func (cs *clientSuite) TestClientPortalInfo(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": {
+		"snap": "foo",
+		"app": "app",
+		"desktop-file": "/var/lib/snapd/desktop/applications/foo_app.desktop",
+		"has-network": true
+	}}`
+	info, err := cs.cli.PortalInfo(42)
+	c.Assert(err, check.IsNil)
+	c.Check(info, check.DeepEquals, &client.PortalInfo{
+		Snap:        "foo",
+		App:         "app",
+		DesktopFile: "/var/lib/snapd/desktop/applications/foo_app.desktop",
+		HasNetwork:  true,
+	})
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/portal-info")
+	c.Check(cs.req.URL.Query().Get("pid"), check.Equals, "42")
+}
+
+func (cs *clientSuite) TestClientPortalInfoError(c *check.C) {
+	cs.rsp = `{"type": "error", "status-code": 404, "result": {"message": "process 42 is not part of any snap"}}`
+	_, err := cs.cli.PortalInfo(42)
+	c.Check(err, check.ErrorMatches, "process 42 is not part of any snap")
+}
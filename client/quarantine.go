@@ -0,0 +1,68 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+)
+
+// QuarantinedSnap describes a snap file kept in quarantine by snapd as
+// its installation was refused, for it to be inspected.
+type QuarantinedSnap struct {
+	ID       string    `json:"id"`
+	Time     time.Time `json:"time"`
+	Snap     string    `json:"snap"`
+	SnapID   string    `json:"snap-id,omitempty"`
+	Revision string    `json:"revision"`
+	// Reason classifies why the installation was refused, one of
+	// scan-rejected, scan-failed or check-failed.
+	Reason string `json:"reason"`
+	Error  string `json:"error"`
+	Origin string `json:"origin"`
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+}
+
+// Quarantine returns the snap files kept in quarantine, oldest first.
+func (client *Client) Quarantine() ([]*QuarantinedSnap, error) {
+	var quarantined []*QuarantinedSnap
+	if _, err := client.doSync("GET", "/v2/debug/quarantine", nil, nil, nil, &quarantined); err != nil {
+		return nil, err
+	}
+	return quarantined, nil
+}
+
+// PurgeQuarantine removes the snap files with the given ids from the
+// quarantine, or all of them if no id is given, returning the ones
+// removed.
+func (client *Client) PurgeQuarantine(ids []string) ([]*QuarantinedSnap, error) {
+	var body bytes.Buffer
+	data := map[string]interface{}{"action": "purge", "ids": ids}
+	if err := json.NewEncoder(&body).Encode(data); err != nil {
+		return nil, err
+	}
+	var purged []*QuarantinedSnap
+	if _, err := client.doSync("POST", "/v2/debug/quarantine", nil, nil, &body, &purged); err != nil {
+		return nil, err
+	}
+	return purged, nil
+}
@@ -0,0 +1,65 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"io/ioutil"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+)
+
+func (cs *clientSuite) TestClientQuarantine(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": [
+		{"id": "1475323200000000000", "time": "2016-10-01T12:00:00Z", "snap": "foo", "snap-id": "foo-id", "revision": "7",
+		 "reason": "scan-rejected", "error": "snap \"foo\" was rejected by the scanner: Eicar-Test-Signature FOUND",
+		 "origin": "/tmp/foo123", "path": "/var/lib/snapd/quarantine/1475323200000000000.snap", "size": 4096}
+	]}`
+	quarantined, err := cs.cli.Quarantine()
+	c.Assert(err, check.IsNil)
+	c.Check(quarantined, check.DeepEquals, []*client.QuarantinedSnap{{
+		ID:       "1475323200000000000",
+		Time:     time.Date(2016, 10, 1, 12, 0, 0, 0, time.UTC),
+		Snap:     "foo",
+		SnapID:   "foo-id",
+		Revision: "7",
+		Reason:   "scan-rejected",
+		Error:    `snap "foo" was rejected by the scanner: Eicar-Test-Signature FOUND`,
+		Origin:   "/tmp/foo123",
+		Path:     "/var/lib/snapd/quarantine/1475323200000000000.snap",
+		Size:     4096,
+	}})
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/debug/quarantine")
+}
+
+func (cs *clientSuite) TestClientPurgeQuarantine(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": [{"id": "1475323200000000000", "snap": "foo"}]}`
+	purged, err := cs.cli.PurgeQuarantine([]string{"1475323200000000000"})
+	c.Assert(err, check.IsNil)
+	c.Check(purged, check.DeepEquals, []*client.QuarantinedSnap{{ID: "1475323200000000000", Snap: "foo"}})
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/debug/quarantine")
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	c.Check(string(body), check.Equals, `{"action":"purge","ids":["1475323200000000000"]}`+"\n")
+}
@@ -0,0 +1,45 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+)
+
+// SBOM returns a software bill of materials for the given installed
+// snaps, or for all of them if none are given, in the given format
+// ("spdx" or "cyclonedx"; the daemon defaults to "spdx").
+func (client *Client) SBOM(format string, snaps []string) (json.RawMessage, error) {
+	query := url.Values{}
+	if format != "" {
+		query.Set("format", format)
+	}
+	if len(snaps) > 0 {
+		query.Set("snaps", strings.Join(snaps, ","))
+	}
+
+	var doc json.RawMessage
+	if _, err := client.doSync("GET", "/v2/sbom", query, nil, nil, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
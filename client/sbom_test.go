@@ -0,0 +1,42 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"gopkg.in/check.v1"
+)
+
+func (cs *clientSuite) TestClientSBOM(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": {"bomFormat": "CycloneDX"}}`
+	doc, err := cs.cli.SBOM("cyclonedx", []string{"foo", "bar"})
+	c.Assert(err, check.IsNil)
+	c.Check(string(doc), check.Equals, `{"bomFormat": "CycloneDX"}`)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/sbom")
+	c.Check(cs.req.URL.Query().Get("format"), check.Equals, "cyclonedx")
+	c.Check(cs.req.URL.Query().Get("snaps"), check.Equals, "foo,bar")
+}
+
+func (cs *clientSuite) TestClientSBOMDefaults(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": {}}`
+	_, err := cs.cli.SBOM("", nil)
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.URL.RawQuery, check.Equals, "")
+}
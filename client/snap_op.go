@@ -31,14 +31,37 @@ import (
 )
 
 type SnapOptions struct {
-	Channel string `json:"channel,omitempty"`
-	DevMode bool   `json:"devmode,omitempty"`
+	Channel       string `json:"channel,omitempty"`
+	DevMode       bool   `json:"devmode,omitempty"`
+	Architecture  string `json:"architecture,omitempty"`
+	UnholdRollout bool   `json:"unhold-rollout,omitempty"`
+	// Offline refreshes to the cached refresh candidates using the
+	// snap files already downloaded, without network.
+	Offline bool `json:"offline,omitempty"`
+	// PurgeDependents also removes the snaps connected to slots of the
+	// removed snaps.
+	PurgeDependents bool `json:"purge-dependents,omitempty"`
+	// Transactional undoes the operation on all of the snaps if the one
+	// on any of them fails, instead of only the failed one.
+	Transactional bool `json:"transactional,omitempty"`
+	// AllowUnsigned asks snapd to accept a refresh spec without a
+	// signature, which it only does on devices without a keyring of
+	// the device owner.
+	AllowUnsigned bool `json:"allow-unsigned,omitempty"`
+	// License, if agreed to, accepts the license of the snap while
+	// installing or refreshing it.
+	License *License `json:"license,omitempty"`
 }
 
 type actionData struct {
-	Action   string `json:"action"`
-	Name     string `json:"name,omitempty"`
-	SnapPath string `json:"snap-path,omitempty"`
+	Action   string   `json:"action"`
+	Name     string   `json:"name,omitempty"`
+	Snaps    []string `json:"snaps,omitempty"`
+	SnapPath string   `json:"snap-path,omitempty"`
+	// Spec and SpecSignature are a refresh spec and its detached
+	// signature.
+	Spec          []byte `json:"spec,omitempty"`
+	SpecSignature []byte `json:"spec-signature,omitempty"`
 	*SnapOptions
 }
 
@@ -53,12 +76,69 @@ func (client *Client) Remove(name string, options *SnapOptions) (changeID string
 	return client.doSnapAction("remove", name, options)
 }
 
+// RemoveMany removes the snaps with the given names, each before the
+// snaps providing the slots its plugs are connected to.
+func (client *Client) RemoveMany(names []string, options *SnapOptions) (changeID string, err error) {
+	action := actionData{
+		Action:      "remove",
+		Snaps:       names,
+		SnapOptions: options,
+	}
+	data, err := json.Marshal(&action)
+	if err != nil {
+		return "", fmt.Errorf("cannot marshal snap options: %s", err)
+	}
+	headers := map[string]string{
+		"Content-Type": "application/json",
+	}
+	return client.doAsync("POST", "/v2/snaps", nil, headers, bytes.NewBuffer(data))
+}
+
 // Refresh refreshes the snap with the given name (switching it to track
 // the given channel if given).
 func (client *Client) Refresh(name string, options *SnapOptions) (changeID string, err error) {
 	return client.doSnapAction("refresh", name, options)
 }
 
+// RefreshOffline refreshes the snaps with the given names, or all the
+// snaps that can be if none are given, to the refreshes that were
+// cached along with their snap files, without network.
+func (client *Client) RefreshOffline(names []string) (changeID string, err error) {
+	action := actionData{
+		Action:      "refresh",
+		Snaps:       names,
+		SnapOptions: &SnapOptions{Offline: true},
+	}
+	data, err := json.Marshal(&action)
+	if err != nil {
+		return "", fmt.Errorf("cannot marshal snap options: %s", err)
+	}
+	headers := map[string]string{
+		"Content-Type": "application/json",
+	}
+	return client.doAsync("POST", "/v2/snaps", nil, headers, bytes.NewBuffer(data))
+}
+
+// RefreshToSpec brings the snaps to the exact revisions of the given
+// refresh spec, installing them if needed. snapd checks the spec against
+// its detached signature before acting on it.
+func (client *Client) RefreshToSpec(spec, signature []byte, options *SnapOptions) (changeID string, err error) {
+	action := actionData{
+		Action:        "refresh",
+		Spec:          spec,
+		SpecSignature: signature,
+		SnapOptions:   options,
+	}
+	data, err := json.Marshal(&action)
+	if err != nil {
+		return "", fmt.Errorf("cannot marshal snap options: %s", err)
+	}
+	headers := map[string]string{
+		"Content-Type": "application/json",
+	}
+	return client.doAsync("POST", "/v2/snaps", nil, headers, bytes.NewBuffer(data))
+}
+
 func (client *Client) doSnapAction(actionName string, snapName string, options *SnapOptions) (changeID string, err error) {
 	action := actionData{
 		Action:      actionName,
@@ -131,6 +211,9 @@ func sendSnapFile(snapPath string, snapFile *os.File, pw *io.PipeWriter, mw *mul
 		mw.WriteField("channel", action.Channel),
 		mw.WriteField("devmode", strconv.FormatBool(action.DevMode)),
 	}
+	if action.License != nil && action.License.Agreed {
+		errs = append(errs, mw.WriteField("accept-license", "true"))
+	}
 	for _, err := range errs {
 		if err != nil {
 			pw.CloseWithError(err)
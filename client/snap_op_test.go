@@ -20,6 +20,7 @@
 package client_test
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -118,6 +119,78 @@ func (cs *clientSuite) TestClientOpSnap(c *check.C) {
 	}
 }
 
+func (cs *clientSuite) TestClientOpRemoveMany(c *check.C) {
+	cs.rsp = `{
+		"change": "d728",
+		"status-code": 202,
+		"type": "async"
+	}`
+	id, err := cs.cli.RemoveMany([]string{"foo", "bar"}, &client.SnapOptions{PurgeDependents: true})
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, "d728")
+
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snaps")
+	c.Check(cs.req.Header.Get("Content-Type"), check.Equals, "application/json")
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	var jsonBody map[string]interface{}
+	c.Assert(json.Unmarshal(body, &jsonBody), check.IsNil)
+	c.Check(jsonBody, check.DeepEquals, map[string]interface{}{
+		"action":           "remove",
+		"snaps":            []interface{}{"foo", "bar"},
+		"purge-dependents": true,
+	})
+}
+
+func (cs *clientSuite) TestClientOpRefreshOffline(c *check.C) {
+	cs.rsp = `{
+		"change": "d729",
+		"status-code": 202,
+		"type": "async"
+	}`
+	id, err := cs.cli.RefreshOffline(nil)
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, "d729")
+
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snaps")
+	c.Check(cs.req.Header.Get("Content-Type"), check.Equals, "application/json")
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	var jsonBody map[string]interface{}
+	c.Assert(json.Unmarshal(body, &jsonBody), check.IsNil)
+	c.Check(jsonBody, check.DeepEquals, map[string]interface{}{
+		"action":  "refresh",
+		"offline": true,
+	})
+}
+
+func (cs *clientSuite) TestClientOpRefreshToSpec(c *check.C) {
+	cs.rsp = `{
+		"change": "d730",
+		"status-code": 202,
+		"type": "async"
+	}`
+	id, err := cs.cli.RefreshToSpec([]byte("snaps: {foo: 12}"), []byte("sig"), &client.SnapOptions{AllowUnsigned: true})
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, "d730")
+
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snaps")
+	c.Check(cs.req.Header.Get("Content-Type"), check.Equals, "application/json")
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	var jsonBody map[string]interface{}
+	c.Assert(json.Unmarshal(body, &jsonBody), check.IsNil)
+	c.Check(jsonBody, check.DeepEquals, map[string]interface{}{
+		"action":         "refresh",
+		"spec":           base64.StdEncoding.EncodeToString([]byte("snaps: {foo: 12}")),
+		"spec-signature": base64.StdEncoding.EncodeToString([]byte("sig")),
+		"allow-unsigned": true,
+	})
+}
+
 func (cs *clientSuite) TestClientOpInstallPath(c *check.C) {
 	cs.rsp = `{
 		"change": "66b3",
@@ -145,6 +218,24 @@ func (cs *clientSuite) TestClientOpInstallPath(c *check.C) {
 	c.Check(id, check.Equals, "66b3")
 }
 
+func (cs *clientSuite) TestClientOpInstallPathAcceptLicense(c *check.C) {
+	cs.rsp = `{
+		"change": "66b3",
+		"status-code": 202,
+		"type": "async"
+	}`
+	snap := filepath.Join(c.MkDir(), "foo.snap")
+	err := ioutil.WriteFile(snap, []byte("snap-data"), 0644)
+	c.Assert(err, check.IsNil)
+
+	_, err = cs.cli.InstallPath(snap, &client.SnapOptions{License: &client.License{Agreed: true}})
+	c.Assert(err, check.IsNil)
+
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	c.Check(string(body), check.Matches, "(?s).*Content-Disposition: form-data; name=\"accept-license\"\r\n\r\ntrue\r\n.*")
+}
+
 func formToMap(c *check.C, mr *multipart.Reader) map[string]string {
 	formData := map[string]string{}
 	for {
@@ -0,0 +1,37 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+// StrayPolicyFile is a framework policy file of a snap that is not
+// installed.
+type StrayPolicyFile struct {
+	Snap string `json:"snap"`
+	Path string `json:"path"`
+}
+
+// StrayPolicy returns the framework policy files of the snaps that are
+// not installed, as left behind by frameworks whose removal went wrong.
+func (client *Client) StrayPolicy() ([]*StrayPolicyFile, error) {
+	var files []*StrayPolicyFile
+	if _, err := client.doSync("GET", "/v2/debug/stray-policy", nil, nil, nil, &files); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
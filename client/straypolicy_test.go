@@ -0,0 +1,38 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+)
+
+func (cs *clientSuite) TestClientStrayPolicy(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": [{"snap": "bar", "path": "/var/lib/snappy/seccomp/templates/bar_default"}]}`
+	files, err := cs.cli.StrayPolicy()
+	c.Assert(err, check.IsNil)
+	c.Check(files, check.DeepEquals, []*client.StrayPolicyFile{{
+		Snap: "bar",
+		Path: "/var/lib/snappy/seccomp/templates/bar_default",
+	}})
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/debug/stray-policy")
+}
@@ -0,0 +1,52 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"time"
+)
+
+// TimeWarp holds the time bounds snapd checks assertions against.
+type TimeWarp struct {
+	// Clock is the time according to the system clock.
+	Clock time.Time `json:"clock"`
+	// MaxSeenTime is the latest time snapd has seen in the timestamps of
+	// assertions and persisted.
+	MaxSeenTime time.Time `json:"max-seen-time,omitempty"`
+	// EarliestTime is the lower bound for the current time known
+	// from the persisted time and the timestamps of the assertions.
+	EarliestTime time.Time `json:"earliest-time,omitempty"`
+	// CheckTime is the time assertions are checked against.
+	CheckTime time.Time `json:"check-time"`
+	// ClockBehind is set when the system clock is behind the
+	// earliest time and cannot be trusted.
+	ClockBehind bool `json:"clock-behind,omitempty"`
+}
+
+// TimeWarp returns the time bounds snapd checks the validity of
+// assertions against, which do not depend on the system clock being
+// right.
+func (client *Client) TimeWarp() (*TimeWarp, error) {
+	var tw TimeWarp
+	if _, err := client.doSync("GET", "/v2/debug/timewarp", nil, nil, nil, &tw); err != nil {
+		return nil, err
+	}
+	return &tw, nil
+}
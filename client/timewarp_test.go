@@ -0,0 +1,49 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+)
+
+func (cs *clientSuite) TestClientTimeWarp(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": {
+		"clock": "1970-01-01T00:02:03Z",
+		"max-seen-time": "2016-09-01T10:00:00Z",
+		"earliest-time": "2016-09-02T08:00:00Z",
+		"check-time": "2016-09-02T08:00:00Z",
+		"clock-behind": true
+	}}`
+	tw, err := cs.cli.TimeWarp()
+	c.Assert(err, check.IsNil)
+	c.Check(tw, check.DeepEquals, &client.TimeWarp{
+		Clock:        time.Date(1970, 1, 1, 0, 2, 3, 0, time.UTC),
+		MaxSeenTime:  time.Date(2016, 9, 1, 10, 0, 0, 0, time.UTC),
+		EarliestTime: time.Date(2016, 9, 2, 8, 0, 0, 0, time.UTC),
+		CheckTime:    time.Date(2016, 9, 2, 8, 0, 0, 0, time.UTC),
+		ClockBehind:  true,
+	})
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/debug/timewarp")
+}
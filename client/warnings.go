@@ -0,0 +1,63 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"net/url"
+)
+
+// Warning is about an installed snap needing attention; for now the
+// only kind is "security", about a snap revision affected by a security
+// advisory.
+type Warning struct {
+	Kind     string `json:"kind"`
+	Snap     string `json:"snap"`
+	Revision string `json:"revision"`
+
+	ID       string   `json:"id"`
+	CVEs     []string `json:"cves,omitempty"`
+	Severity string   `json:"severity"`
+	Summary  string   `json:"summary,omitempty"`
+	// FixedRevision is the first revision of the snap not affected,
+	// zero if there is none yet.
+	FixedRevision int `json:"fixed-revision,omitempty"`
+	// Held is whether the snap is held at its revision, so it won't
+	// be refreshed to a fixed one.
+	Held bool `json:"held,omitempty"`
+}
+
+// WarningsOptions selects the warnings to list.
+type WarningsOptions struct {
+	Security bool
+}
+
+// Warnings returns the warnings about the installed snaps.
+func (client *Client) Warnings(opts *WarningsOptions) ([]*Warning, error) {
+	query := url.Values{}
+	if opts != nil && opts.Security {
+		query.Set("select", "security")
+	}
+
+	var warnings []*Warning
+	if _, err := client.doSync("GET", "/v2/warnings", query, nil, nil, &warnings); err != nil {
+		return nil, err
+	}
+	return warnings, nil
+}
@@ -0,0 +1,57 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+)
+
+func (cs *clientSuite) TestClientWarnings(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": [
+		{"kind": "security", "snap": "foo", "revision": "7", "id": "USN-1", "cves": ["CVE-2016-1"],
+		 "severity": "high", "summary": "remote code execution", "fixed-revision": 8, "held": true}
+	]}`
+	warnings, err := cs.cli.Warnings(&client.WarningsOptions{Security: true})
+	c.Assert(err, check.IsNil)
+	c.Check(warnings, check.DeepEquals, []*client.Warning{{
+		Kind:          "security",
+		Snap:          "foo",
+		Revision:      "7",
+		ID:            "USN-1",
+		CVEs:          []string{"CVE-2016-1"},
+		Severity:      "high",
+		Summary:       "remote code execution",
+		FixedRevision: 8,
+		Held:          true,
+	}})
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/warnings")
+	c.Check(cs.req.URL.RawQuery, check.Equals, "select=security")
+}
+
+func (cs *clientSuite) TestClientWarningsAll(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": []}`
+	warnings, err := cs.cli.Warnings(nil)
+	c.Assert(err, check.IsNil)
+	c.Check(warnings, check.HasLen, 0)
+	c.Check(cs.req.URL.RawQuery, check.Equals, "")
+}
@@ -22,7 +22,6 @@ package main
 import (
 	"fmt"
 	"os"
-	"path/filepath"
 	"syscall"
 
 	"github.com/jessevdk/go-flags"
@@ -110,7 +109,7 @@ func snapExec(snapApp, revision, command string, args []string) error {
 	// build the evnironment from the yamle
 	env := append(os.Environ(), app.Env()...)
 
-	// run the command
-	fullCmd := filepath.Join(app.Snap.MountDir(), cmd)
-	return syscallExec(fullCmd, args, env)
+	// run the command, through the helpers of the command chain
+	fullCmd := app.CommandWithChain(cmd)
+	return syscallExec(fullCmd[0], append(fullCmd[1:], args...), env)
 }
@@ -116,3 +116,33 @@ func (s *snapExecSuite) TestSnapLaunchIntegration(c *C) {
 	c.Check(execArgs, DeepEquals, []string{"arg1", "arg2"})
 	c.Check(execEnv, testutil.Contains, "LD_LIBRARY_PATH=/some/path\n")
 }
+
+func (s *snapExecSuite) TestSnapLaunchWithCommandChain(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	snaptest.MockSnap(c, `name: snapname
+version: 1.0
+apps:
+ app:
+  command: run-app
+  command-chain: [bin/setup-env, bin/select-runtime]
+`, &snap.SideInfo{
+		Revision: snap.R("42"),
+	})
+
+	execArgv0 := ""
+	execArgs := []string{}
+	syscallExec = func(argv0 string, argv []string, env []string) error {
+		execArgv0 = argv0
+		execArgs = argv
+		return nil
+	}
+
+	err := snapExec("snapname.app", "42", "", []string{"arg1"})
+	c.Assert(err, IsNil)
+	c.Check(execArgv0, Equals, fmt.Sprintf("%s/snapname/42/bin/setup-env", dirs.SnapSnapsDir))
+	c.Check(execArgs, DeepEquals, []string{
+		fmt.Sprintf("%s/snapname/42/bin/select-runtime", dirs.SnapSnapsDir),
+		fmt.Sprintf("%s/snapname/42/run-app", dirs.SnapSnapsDir),
+		"arg1",
+	})
+}
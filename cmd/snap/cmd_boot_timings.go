@@ -0,0 +1,135 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/systemd"
+)
+
+var shortBootTimingsHelp = i18n.G("Show where the time of the first boot went")
+var longBootTimingsHelp = i18n.G(`
+The boot-timings command shows when the tasks of the change seeding the
+system, the first one it made, were ready relative to its start, and
+when the snaps got mounted and when their services and the snapd units
+were started, relative to boot.
+`)
+
+type cmdBootTimings struct{}
+
+func init() {
+	addDebugCommand("boot-timings", shortBootTimingsHelp, longBootTimingsHelp, func() flags.Commander {
+		return &cmdBootTimings{}
+	})
+}
+
+// bootUnits are the snapd units that take part in the first boot.
+var bootUnits = []string{"snapd.firstboot.service", "snapd.service"}
+
+func formatDuration(d time.Duration) string {
+	return fmt.Sprintf("%.3fs", d.Seconds())
+}
+
+// readyAt formats when something got ready relative to start, or "-"
+// if it is not ready yet. Tasks do not record when they started to
+// run, so how long each took on its own cannot be told.
+func readyAt(start, ready time.Time) string {
+	if ready.IsZero() {
+		return "-"
+	}
+	return formatDuration(ready.Sub(start))
+}
+
+func (x *cmdBootTimings) Execute(args []string) error {
+	cli := Client()
+	changes, err := cli.Changes(&client.ChangesOptions{Selector: client.ChangesAll})
+	if err != nil {
+		return err
+	}
+	snaps, err := cli.List(nil)
+	if err != nil {
+		return err
+	}
+
+	sort.Sort(changesByTime(changes))
+
+	w := tabWriter()
+
+	// the first change of the system is the one seeding it; the later
+	// ones have nothing to do with the first boot
+	if len(changes) > 0 {
+		seed := changes[0]
+		fmt.Fprintf(w, i18n.G("Change\tTask\tReady at\tSummary\n"))
+		fmt.Fprintf(w, "%s\t-\t%s\t%s\n", seed.ID, readyAt(seed.SpawnTime, seed.ReadyTime), seed.Summary)
+		for _, t := range seed.Tasks {
+			fmt.Fprintf(w, "\t%s\t%s\t%s\n", t.ID, readyAt(seed.SpawnTime, t.ReadyTime), t.Summary)
+		}
+		w.Flush()
+		fmt.Fprintln(Stdout)
+	}
+
+	units := append([]string(nil), bootUnits...)
+	for _, sn := range snaps {
+		units = append(units, filepath.Base(systemd.MountUnitPath(dirs.StripRootDir(snap.MountDir(sn.Name, sn.Revision)), "mount")))
+
+		info, err := snap.ReadInfo(sn.Name, &snap.SideInfo{Revision: sn.Revision})
+		if err != nil {
+			// not mounted, nothing more we can say about it
+			continue
+		}
+		var services []string
+		for _, app := range info.Apps {
+			if app.Daemon == "" {
+				continue
+			}
+			services = append(services, filepath.Base(app.ServiceFile()))
+		}
+		sort.Strings(services)
+		units = append(units, services...)
+	}
+
+	sysd := systemd.New(dirs.GlobalRootDir, nil)
+
+	fmt.Fprintf(w, i18n.G("Unit\tStarted\tTook\n"))
+	for _, unit := range units {
+		timings, err := sysd.UnitTimings(unit)
+		if err != nil {
+			return err
+		}
+		if timings.Activating == 0 {
+			fmt.Fprintf(w, "%s\t-\t-\n", unit)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", unit, formatDuration(timings.Activating), formatDuration(timings.Duration()))
+	}
+	w.Flush()
+
+	return nil
+}
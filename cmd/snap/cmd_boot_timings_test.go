@@ -0,0 +1,98 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+	"github.com/snapcore/snapd/dirs"
+	snapinfo "github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/systemd"
+)
+
+func (s *SnapSuite) TestBootTimings(c *check.C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("/")
+
+	snaptest.MockSnap(c, `name: foo
+version: 1.0
+apps:
+ svc:
+  command: svc
+  daemon: simple
+ cli:
+  command: cli
+`, &snapinfo.SideInfo{Revision: snapinfo.R(7)})
+
+	var shown []string
+	origSystemctl := systemd.SystemctlCmd
+	defer func() { systemd.SystemctlCmd = origSystemctl }()
+	systemd.SystemctlCmd = func(args ...string) ([]byte, error) {
+		unit := args[len(args)-1]
+		shown = append(shown, unit)
+		if unit == "snapd.service" {
+			return []byte("InactiveExitTimestampMonotonic=0\nActiveEnterTimestampMonotonic=0\n"), nil
+		}
+		return []byte("InactiveExitTimestampMonotonic=2000000\nActiveEnterTimestampMonotonic=3500000\n"), nil
+	}
+
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		switch n {
+		case 0:
+			c.Check(r.URL.Path, check.Equals, "/v2/changes")
+			fmt.Fprintln(w, `{"type": "sync", "result": [{"id": "2", "summary": "Refresh foo", "spawn-time": "2016-04-21T02:00:00Z", "ready-time": "2016-04-21T02:00:10Z", "tasks": [{"id": "21", "summary": "Mount foo", "spawn-time": "2016-04-21T02:00:00Z", "ready-time": "2016-04-21T02:00:05Z"}]}, {"id": "1", "summary": "Install foo", "spawn-time": "2016-04-21T01:02:03Z", "ready-time": "2016-04-21T01:02:13Z", "tasks": [{"id": "11", "summary": "Mount foo", "spawn-time": "2016-04-21T01:02:03Z", "ready-time": "2016-04-21T01:02:05.5Z"}, {"id": "12", "summary": "Setup foo", "spawn-time": "2016-04-21T01:02:03Z", "ready-time": "2016-04-21T01:02:09Z"}, {"id": "13", "summary": "Start foo", "spawn-time": "2016-04-21T01:02:03Z"}]}]}`)
+		case 1:
+			c.Check(r.URL.Path, check.Equals, "/v2/snaps")
+			fmt.Fprintln(w, `{"type": "sync", "result": [{"name": "foo", "status": "active", "version": "1.0", "revision": 7}]}`)
+		default:
+			c.Fatalf("expected to get 2 requests, now on %d", n+1)
+		}
+		n++
+	})
+
+	rest, err := snap.Parser().ParseArgs([]string{"debug", "boot-timings"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(n, check.Equals, 2)
+	c.Check(shown, check.DeepEquals, []string{"snapd.firstboot.service", "snapd.service", "snap-foo-7.mount", "snap.foo.svc.service"})
+	c.Check(s.Stdout(), check.Equals, strings.Join([]string{
+		"Change  Task  Ready at  Summary",
+		"1       -     10.000s   Install foo",
+		"        11    2.500s    Mount foo",
+		"        12    6.000s    Setup foo",
+		"        13    -         Start foo",
+		"",
+		"Unit                     Started  Took",
+		"snapd.firstboot.service  2.000s   1.500s",
+		"snapd.service            -        -",
+		"snap-foo-7.mount         2.000s   1.500s",
+		"snap.foo.svc.service     2.000s   1.500s",
+		"",
+	}, "\n"))
+	c.Check(s.Stderr(), check.Equals, "")
+}
@@ -46,6 +46,16 @@ func (x *cmdBooted) Execute(args []string) error {
 		return fmt.Errorf("can not mark boot successful: %s", err)
 	}
 
+	// snapd counts the attempts at booting what it installed, and
+	// marks the boot successful itself once the system is healthy
+	_, limit, err := partition.BootAttempts(bootloader)
+	if err != nil {
+		return err
+	}
+	if limit > 0 {
+		return nil
+	}
+
 	if err := partition.MarkBootSuccessful(bootloader); err != nil {
 		return err
 	}
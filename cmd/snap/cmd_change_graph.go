@@ -0,0 +1,172 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/i18n"
+)
+
+var shortChangeGraphHelp = i18n.G("Export the task graph of a change")
+var longChangeGraphHelp = i18n.G(`
+The change-graph command writes the tasks of a change, with their status
+and timings, and the dependencies between them, either as a Graphviz dot
+graph or as JSON, to see why a change is stuck or slow or to feed it into
+tracing tools.
+`)
+
+type cmdChangeGraph struct {
+	Format     string `long:"format" description:"format of the graph" choice:"dot" choice:"json" default:"dot"`
+	Positional struct {
+		Id string `positional-arg-name:"<id>" required:"yes"`
+	} `positional-args:"yes"`
+}
+
+func init() {
+	addDebugCommand("change-graph", shortChangeGraphHelp, longChangeGraphHelp, func() flags.Commander {
+		return &cmdChangeGraph{}
+	})
+}
+
+// graphNode is a task of the JSON graph of a change.
+type graphNode struct {
+	ID        string        `json:"id"`
+	Kind      string        `json:"kind"`
+	Summary   string        `json:"summary"`
+	Status    string        `json:"status"`
+	SpawnTime time.Time     `json:"spawn-time"`
+	ReadyTime *time.Time    `json:"ready-time,omitempty"`
+	Duration  time.Duration `json:"duration,omitempty"`
+}
+
+// graphEdge goes from a task to a task waiting for it.
+type graphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type changeGraph struct {
+	ID        string       `json:"id"`
+	Kind      string       `json:"kind"`
+	Summary   string       `json:"summary"`
+	Status    string       `json:"status"`
+	SpawnTime time.Time    `json:"spawn-time"`
+	ReadyTime *time.Time   `json:"ready-time,omitempty"`
+	Nodes     []*graphNode `json:"nodes"`
+	Edges     []*graphEdge `json:"edges"`
+}
+
+func (x *cmdChangeGraph) Execute(args []string) error {
+	chg, err := Client().Change(x.Positional.Id)
+	if err != nil {
+		return err
+	}
+
+	if x.Format == "json" {
+		return writeChangeGraphJSON(chg)
+	}
+	writeChangeGraphDot(chg)
+	return nil
+}
+
+func writeChangeGraphJSON(chg *client.Change) error {
+	graph := &changeGraph{
+		ID:        chg.ID,
+		Kind:      chg.Kind,
+		Summary:   chg.Summary,
+		Status:    chg.Status,
+		SpawnTime: chg.SpawnTime,
+		Nodes:     []*graphNode{},
+		Edges:     []*graphEdge{},
+	}
+	if !chg.ReadyTime.IsZero() {
+		graph.ReadyTime = &chg.ReadyTime
+	}
+	for _, t := range chg.Tasks {
+		node := &graphNode{
+			ID:        t.ID,
+			Kind:      t.Kind,
+			Summary:   t.Summary,
+			Status:    t.Status,
+			SpawnTime: t.SpawnTime,
+		}
+		if !t.ReadyTime.IsZero() {
+			node.ReadyTime = &t.ReadyTime
+			node.Duration = t.ReadyTime.Sub(t.SpawnTime)
+		}
+		graph.Nodes = append(graph.Nodes, node)
+		for _, id := range t.WaitTasks {
+			graph.Edges = append(graph.Edges, &graphEdge{From: id, To: t.ID})
+		}
+	}
+
+	enc := json.NewEncoder(Stdout)
+	return enc.Encode(graph)
+}
+
+var dotQuoter = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func dotQuote(s string) string {
+	return `"` + dotQuoter.Replace(s) + `"`
+}
+
+// statusColors are the colors of the tasks in the dot graph, by status.
+var statusColors = map[string]string{
+	"Doing":   "gold",
+	"Undoing": "gold",
+	"Done":    "palegreen",
+	"Undone":  "lightgrey",
+	"Hold":    "lightgrey",
+	"Error":   "lightcoral",
+}
+
+func writeChangeGraphDot(chg *client.Change) {
+	fmt.Fprintf(Stdout, "digraph %s {\n", dotQuote("change "+chg.ID))
+	fmt.Fprintf(Stdout, "\tlabel=%s;\n", dotQuote(fmt.Sprintf("%s (%s)", chg.Summary, chg.Status)))
+	fmt.Fprintln(Stdout, "\tnode [shape=box, style=filled, fillcolor=white];")
+	for _, t := range chg.Tasks {
+		status := t.Status
+		if !t.ReadyTime.IsZero() {
+			// tasks do not record when they started to run, only
+			// when the change spawned them, so say when they got
+			// ready within the change rather than how long they took
+			status = fmt.Sprintf("%s at %.3fs", status, t.ReadyTime.Sub(chg.SpawnTime).Seconds())
+		}
+		label := strings.Join([]string{t.Kind, t.Summary, status}, "\n")
+		fmt.Fprintf(Stdout, "\t%s [label=%s", dotQuote(t.ID), dotQuote(label))
+		if color, ok := statusColors[t.Status]; ok {
+			fmt.Fprintf(Stdout, ", fillcolor=%s", color)
+		}
+		fmt.Fprintln(Stdout, "];")
+	}
+	for _, t := range chg.Tasks {
+		for _, id := range t.WaitTasks {
+			fmt.Fprintf(Stdout, "\t%s -> %s;\n", dotQuote(id), dotQuote(t.ID))
+		}
+	}
+	fmt.Fprintln(Stdout, "}")
+}
@@ -0,0 +1,104 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+const changeGraphJSON = `{"type": "sync", "status-code": 200, "result": {
+	"id": "42", "kind": "install-snap", "summary": "Install \"foo\" snap", "status": "Doing",
+	"spawn-time": "2016-10-01T12:00:00Z",
+	"tasks": [
+		{"id": "1", "kind": "download-snap", "summary": "Download snap \"foo\"", "status": "Done",
+		 "spawn-time": "2016-10-01T12:00:00Z", "ready-time": "2016-10-01T12:00:02.5Z", "progress": {"done": 1, "total": 1}},
+		{"id": "2", "kind": "mount-snap", "summary": "Mount snap \"foo\"", "status": "Doing",
+		 "spawn-time": "2016-10-01T12:00:00Z", "progress": {"done": 0, "total": 1}, "wait-tasks": ["1"]}
+	]}}`
+
+func (s *SnapSuite) TestChangeGraphDot(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		c.Check(r.URL.Path, check.Equals, "/v2/changes/42")
+		fmt.Fprintln(w, changeGraphJSON)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"debug", "change-graph", "42"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, `digraph "change 42" {
+	label="Install \"foo\" snap (Doing)";
+	node [shape=box, style=filled, fillcolor=white];
+	"1" [label="download-snap\nDownload snap \"foo\"\nDone at 2.500s", fillcolor=palegreen];
+	"2" [label="mount-snap\nMount snap \"foo\"\nDoing", fillcolor=gold];
+	"1" -> "2";
+}
+`)
+}
+
+func (s *SnapSuite) TestChangeGraphJSON(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, changeGraphJSON)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"debug", "change-graph", "--format=json", "42"})
+	c.Assert(err, check.IsNil)
+
+	var graph map[string]interface{}
+	c.Assert(json.Unmarshal([]byte(s.Stdout()), &graph), check.IsNil)
+	c.Check(graph, check.DeepEquals, map[string]interface{}{
+		"id":         "42",
+		"kind":       "install-snap",
+		"summary":    `Install "foo" snap`,
+		"status":     "Doing",
+		"spawn-time": "2016-10-01T12:00:00Z",
+		"nodes": []interface{}{
+			map[string]interface{}{
+				"id":         "1",
+				"kind":       "download-snap",
+				"summary":    `Download snap "foo"`,
+				"status":     "Done",
+				"spawn-time": "2016-10-01T12:00:00Z",
+				"ready-time": "2016-10-01T12:00:02.5Z",
+				"duration":   2.5e9,
+			},
+			map[string]interface{}{
+				"id":         "2",
+				"kind":       "mount-snap",
+				"summary":    `Mount snap "foo"`,
+				"status":     "Doing",
+				"spawn-time": "2016-10-01T12:00:00Z",
+			},
+		},
+		"edges": []interface{}{
+			map[string]interface{}{"from": "1", "to": "2"},
+		},
+	})
+}
+
+func (s *SnapSuite) TestChangeGraphBadFormat(c *check.C) {
+	_, err := snap.Parser().ParseArgs([]string{"debug", "change-graph", "--format=svg", "42"})
+	c.Assert(err, check.ErrorMatches, `Invalid value .svg. for option .--format.*`)
+}
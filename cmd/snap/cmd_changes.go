@@ -24,6 +24,7 @@ import (
 	"os"
 	"regexp"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/snapcore/snapd/client"
@@ -37,7 +38,10 @@ var shortChangeHelp = i18n.G("List a change's tasks")
 var longChangesHelp = i18n.G(`
 The changes command displays a summary of the recent system changes performed.`)
 var longChangeHelp = i18n.G(`
-The change command displays a summary of tasks associated to an individual change.`)
+The change command displays a summary of tasks associated to an individual change.
+
+With --log, the output of the hooks run by the change and of the services
+that failed to start is shown as well.`)
 
 type cmdChanges struct {
 	Positional struct {
@@ -46,6 +50,7 @@ type cmdChanges struct {
 }
 
 type cmdChange struct {
+	Log        bool `long:"log" description:"show the output of hooks and of services that failed to start"`
 	Positional struct {
 		Id string `positional-arg-name:"<id>" required:"yes"`
 	} `positional-args:"yes"`
@@ -54,6 +59,7 @@ type cmdChange struct {
 func init() {
 	addCommand("changes", shortChangesHelp, longChangesHelp, func() flags.Commander { return &cmdChanges{} })
 	addCommand("change", shortChangeHelp, longChangeHelp, func() flags.Commander { return &cmdChange{} })
+	addCommand("tasks", shortChangeHelp, longChangeHelp, func() flags.Commander { return &cmdChange{} })
 }
 
 type changesByTime []*client.Change
@@ -94,14 +100,18 @@ func (c *cmdChanges) Execute([]string) error {
 
 	w := tabWriter()
 
-	fmt.Fprintf(w, i18n.G("ID\tStatus\tSpawn\tReady\tSummary\n"))
+	fmt.Fprintf(w, i18n.G("ID\tStatus\tSpawn\tReady\tNotes\tSummary\n"))
 	for _, chg := range changes {
 		spawnTime := chg.SpawnTime.UTC().Format(time.RFC3339)
 		readyTime := chg.ReadyTime.UTC().Format(time.RFC3339)
 		if chg.ReadyTime.IsZero() {
 			readyTime = "-"
 		}
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", chg.ID, chg.Status, spawnTime, readyTime, chg.Summary)
+		notes := "-"
+		if chg.RebootRequired {
+			notes = "reboot-required"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", chg.ID, chg.Status, spawnTime, readyTime, notes, chg.Summary)
 	}
 
 	w.Flush()
@@ -131,8 +141,13 @@ func (c *cmdChange) Execute([]string) error {
 
 	w.Flush()
 
+	hasOutput := false
 	for _, t := range chg.Tasks {
-		if len(t.Log) == 0 {
+		if t.Output != "" {
+			hasOutput = true
+		}
+		showOutput := c.Log && t.Output != ""
+		if len(t.Log) == 0 && !showOutput {
 			continue
 		}
 		fmt.Fprintln(Stdout)
@@ -142,11 +157,60 @@ func (c *cmdChange) Execute([]string) error {
 		for _, line := range t.Log {
 			fmt.Fprintln(Stdout, line)
 		}
+		if showOutput {
+			if len(t.Log) > 0 {
+				fmt.Fprintln(Stdout)
+			}
+			fmt.Fprintln(Stdout, i18n.G("Output:"))
+			fmt.Fprint(Stdout, t.Output)
+			if !strings.HasSuffix(t.Output, "\n") {
+				fmt.Fprintln(Stdout)
+			}
+		}
+	}
+
+	if chg.ErrorCode != "" {
+		fmt.Fprintln(Stdout)
+		fmt.Fprintf(Stdout, i18n.G("Error code: %s\n"), chg.ErrorCode)
+	}
+
+	var results map[string]*client.SnapResult
+	if chg.Get("snap-results", &results) == nil && len(results) > 0 {
+		showSnapResults(results)
 	}
 
 	fmt.Fprintln(Stdout)
 
+	if hasOutput && !c.Log {
+		fmt.Fprintf(Stderr, i18n.G("Use --log to see the output of hooks and services.\n"))
+	}
+
 	return nil
 }
 
+func showSnapResults(results map[string]*client.SnapResult) {
+	names := make([]string, 0, len(results))
+	for name := range results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(Stdout)
+	w := tabWriter()
+	fmt.Fprintf(w, i18n.G("Snap\tResult\tError code\tMessage\n"))
+	for _, name := range names {
+		r := results[name]
+		code := r.ErrorCode
+		if code == "" {
+			code = "-"
+		}
+		msg := r.Message
+		if msg == "" {
+			msg = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", name, r.Status, code, msg)
+	}
+	w.Flush()
+}
+
 const line = "......................................................................"
@@ -0,0 +1,140 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) TestChangeSnapResults(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		c.Check(r.URL.Path, check.Equals, "/v2/changes/42")
+		fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": {
+  "id": "42", "kind": "auto-refresh", "summary": "Auto-refresh snaps bar, foo", "status": "Error", "ready": true,
+  "spawn-time": "2016-04-21T01:02:03Z", "ready-time": "2016-04-21T01:02:04Z",
+  "tasks": [{"kind": "download-snap", "summary": "Download snap \"foo\"", "status": "Error",
+    "spawn-time": "2016-04-21T01:02:03Z", "ready-time": "2016-04-21T01:02:04Z"}],
+  "error-code": "network",
+  "data": {"snap-names": ["bar", "foo"], "snap-results": {
+    "foo": {"status": "failed", "error-code": "network", "message": "dial tcp: i/o timeout"},
+    "bar": {"status": "undone"}
+  }}
+}}`)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"change", "42"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, `Status  Spawn                 Ready                 Summary
+Error   2016-04-21T01:02:03Z  2016-04-21T01:02:04Z  Download snap "foo"
+
+Error code: network
+
+Snap  Result  Error code  Message
+bar   undone  -           -
+foo   failed  network     dial tcp: i/o timeout
+
+`)
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+const changeWithOutputJSON = `{"type": "sync", "status-code": 200, "result": {
+  "id": "42", "kind": "install-snap", "summary": "Install \"foo\" snap", "status": "Error", "ready": true,
+  "spawn-time": "2016-04-21T01:02:03Z", "ready-time": "2016-04-21T01:02:04Z",
+  "tasks": [{"kind": "run-hook", "summary": "Run install hook of \"foo\" snap", "status": "Error",
+    "log": ["2016-04-21T01:02:04Z ERROR cannot run hook \"install\": exit status 1: error: cannot frobnicate"],
+    "output": "starting\nerror: cannot frobnicate\n",
+    "spawn-time": "2016-04-21T01:02:03Z", "ready-time": "2016-04-21T01:02:04Z"}],
+  "error-code": "hook-failed"
+}}`
+
+func (s *SnapSuite) TestChangeHintsAtOutput(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, changeWithOutputJSON)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"change", "42"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, `Status  Spawn                 Ready                 Summary
+Error   2016-04-21T01:02:03Z  2016-04-21T01:02:04Z  Run install hook of "foo" snap
+
+......................................................................
+Run install hook of "foo" snap
+
+2016-04-21T01:02:04Z ERROR cannot run hook "install": exit status 1: error: cannot frobnicate
+
+Error code: hook-failed
+
+`)
+	c.Check(s.Stderr(), check.Equals, "Use --log to see the output of hooks and services.\n")
+}
+
+func (s *SnapSuite) TestTasksLog(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, check.Equals, "/v2/changes/42")
+		fmt.Fprintln(w, changeWithOutputJSON)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"tasks", "--log", "42"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, `Status  Spawn                 Ready                 Summary
+Error   2016-04-21T01:02:03Z  2016-04-21T01:02:04Z  Run install hook of "foo" snap
+
+......................................................................
+Run install hook of "foo" snap
+
+2016-04-21T01:02:04Z ERROR cannot run hook "install": exit status 1: error: cannot frobnicate
+
+Output:
+starting
+error: cannot frobnicate
+
+Error code: hook-failed
+
+`)
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *SnapSuite) TestChangesRebootRequired(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		c.Check(r.URL.Path, check.Equals, "/v2/changes")
+		fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": [
+  {"id": "1", "kind": "install-snap", "summary": "Install \"foo\" snap", "status": "Done", "ready": true,
+   "spawn-time": "2016-04-21T01:02:03Z", "ready-time": "2016-04-21T01:02:04Z"},
+  {"id": "2", "kind": "refresh-snap", "summary": "Refresh \"pc-kernel\" snap", "status": "Done", "ready": true,
+   "spawn-time": "2016-04-21T01:02:05Z", "ready-time": "2016-04-21T01:02:06Z", "reboot-required": true}
+]}`)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"changes"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, `ID   Status  Spawn                 Ready                 Notes            Summary
+1    Done    2016-04-21T01:02:03Z  2016-04-21T01:02:04Z  -                Install "foo" snap
+2    Done    2016-04-21T01:02:05Z  2016-04-21T01:02:06Z  reboot-required  Refresh "pc-kernel" snap
+
+`)
+	c.Check(s.Stderr(), check.Equals, "")
+}
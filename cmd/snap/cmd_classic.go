@@ -26,7 +26,6 @@ import (
 
 	"github.com/snapcore/snapd/classic"
 	"github.com/snapcore/snapd/i18n"
-	"github.com/snapcore/snapd/progress"
 )
 
 // FIXME: Implement feature via "snap install classic"
@@ -62,7 +61,7 @@ func (x *cmdEnableClassic) doEnable() (err error) {
 		return fmt.Errorf(i18n.G("Classic dimension is already enabled."))
 	}
 
-	pbar := progress.NewTextProgress()
+	pbar := newProgress()
 	if err := classic.Create(pbar); err != nil {
 		return err
 	}
@@ -0,0 +1,172 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) TestSetConf(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "PUT")
+		c.Check(r.URL.Path, check.Equals, "/v2/snaps/core/conf")
+		c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+			"store-certs.corp": "-----BEGIN CERTIFICATE-----",
+			"store-certs.old":  nil,
+			"answer":           float64(42),
+		})
+		fmt.Fprintln(w, `{"type":"sync", "status-code": 200, "result": null}`)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"set", "core", "store-certs.corp=-----BEGIN CERTIFICATE-----", "store-certs.old=null", "answer=42"})
+	c.Assert(err, check.IsNil)
+}
+
+func (s *SnapSuite) TestSetConfInvalid(c *check.C) {
+	_, err := snap.Parser().ParseArgs([]string{"set", "core", "store-certs"})
+	c.Assert(err, check.ErrorMatches, `invalid configuration: "store-certs" \(want key=value\)`)
+}
+
+func (s *SnapSuite) TestGetConf(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		c.Check(r.URL.Path, check.Equals, "/v2/snaps/core/conf")
+		c.Check(r.URL.Query().Get("keys"), check.Equals, "store-certs")
+		fmt.Fprintln(w, `{"type":"sync", "status-code": 200, "result": {"store-certs": {"corp": "PEM"}}}`)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"get", "core", "store-certs"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "{\n\t\"corp\": \"PEM\"\n}\n")
+}
+
+func (s *SnapSuite) TestGetConfString(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"type":"sync", "status-code": 200, "result": {"store-certs.corp": "PEM"}}`)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"get", "core", "store-certs.corp"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "PEM\n")
+}
+
+func (s *SnapSuite) TestSetConfFromFile(c *check.C) {
+	path := filepath.Join(c.MkDir(), "config.yaml")
+	c.Assert(ioutil.WriteFile(path, []byte("server:\n  port: 8080\n  hosts: [a, b]\nname: frank\n"), 0644), check.IsNil)
+
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+			"server.port":  float64(8080),
+			"server.hosts": []interface{}{"a", "b"},
+			"name":         "joe",
+		})
+		fmt.Fprintln(w, `{"type":"sync", "status-code": 200, "result": null}`)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"set", "foo", "--from-file", path, "name=joe"})
+	c.Assert(err, check.IsNil)
+}
+
+func (s *SnapSuite) TestSetConfFromFileInvalid(c *check.C) {
+	path := filepath.Join(c.MkDir(), "config.yaml")
+	c.Assert(ioutil.WriteFile(path, []byte("Server: 1\n"), 0644), check.IsNil)
+
+	_, err := snap.Parser().ParseArgs([]string{"set", "foo", "--from-file", path})
+	c.Assert(err, check.ErrorMatches, `cannot read configuration file ".*": invalid option name: "Server"`)
+}
+
+func (s *SnapSuite) TestSetConfNothing(c *check.C) {
+	_, err := snap.Parser().ParseArgs([]string{"set", "foo"})
+	c.Assert(err, check.ErrorMatches, `set needs at least one key=value, or --from-file`)
+}
+
+func (s *SnapSuite) TestSetConfTyped(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+			"port":  float64(8080),
+			"hosts": []interface{}{"a", "b"},
+			"name":  "frank",
+		})
+		fmt.Fprintln(w, `{"type":"sync", "status-code": 200, "result": null}`)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"set", "-t", "foo", "port=8080", `hosts=["a", "b"]`, `name="frank"`})
+	c.Assert(err, check.IsNil)
+}
+
+func (s *SnapSuite) TestSetConfTypedInvalid(c *check.C) {
+	_, err := snap.Parser().ParseArgs([]string{"set", "-t", "foo", "port=80a"})
+	c.Assert(err, check.ErrorMatches, `invalid configuration: "port=80a" \(want key=<JSON value>\)`)
+}
+
+func (s *SnapSuite) TestSetConfConfigureHook(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/snaps/foo/conf":
+			c.Check(r.Method, check.Equals, "PUT")
+			fmt.Fprintln(w, `{"type": "async", "status-code": 202, "change": "zzz"}`)
+		case "/v2/changes/zzz":
+			c.Check(r.Method, check.Equals, "GET")
+			fmt.Fprintln(w, `{"type": "sync", "result": {"ready": true, "status": "Error", "err": "cannot run hook \"configure\": exit status 1: bad port"}}`)
+		default:
+			c.Fatalf("unexpected path %q", r.URL.Path)
+		}
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"set", "foo", "port=70000"})
+	c.Assert(err, check.ErrorMatches, `cannot run hook "configure": exit status 1: bad port`)
+}
+
+func (s *SnapSuite) TestGetConfDocument(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Query().Get("keys"), check.Equals, "name,server")
+		fmt.Fprintln(w, `{"type":"sync", "status-code": 200, "result": {"name": "frank", "server": {"port": 8080}}}`)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"get", "-d", "foo", "name", "server"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "{\n\t\"name\": \"frank\",\n\t\"server\": {\n\t\t\"port\": 8080\n\t}\n}\n")
+}
+
+func (s *SnapSuite) TestGetConfDocumentAll(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.RawQuery, check.Equals, "")
+		fmt.Fprintln(w, `{"type":"sync", "status-code": 200, "result": {"name": "frank"}}`)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"get", "-d", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "{\n\t\"name\": \"frank\"\n}\n")
+}
+
+func (s *SnapSuite) TestGetConfNeedsKey(c *check.C) {
+	_, err := snap.Parser().ParseArgs([]string{"get", "foo"})
+	c.Assert(err, check.ErrorMatches, `get needs exactly one key, or -d`)
+
+	_, err = snap.Parser().ParseArgs([]string{"get", "foo", "a", "b"})
+	c.Assert(err, check.ErrorMatches, `get needs exactly one key, or -d`)
+}
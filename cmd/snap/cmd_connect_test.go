@@ -55,6 +55,7 @@ proceeds as above.
 
 Application Options:
       --version            print the version and exit
+      --screen-reader      report progress as plain lines of text
 
 Help Options:
   -h, --help               Show this help message
@@ -0,0 +1,104 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/i18n"
+)
+
+var shortConnectivityHelp = i18n.G("Check the reachability of the store")
+var longConnectivityHelp = i18n.G(`
+The connectivity command has snapd check that it can reach the endpoints
+it needs: the store services, the authentication service and the CDN
+snaps are downloaded from. For each of them it shows the proxy in effect,
+how long name resolution, connecting and the request took, and the TLS
+details of the connection.
+`)
+
+type cmdConnectivity struct {
+	JSON bool `long:"json" description:"print the results as JSON"`
+}
+
+func init() {
+	addDebugCommand("connectivity", shortConnectivityHelp, longConnectivityHelp, func() flags.Commander {
+		return &cmdConnectivity{}
+	})
+}
+
+func formatMilliseconds(ms int64) string {
+	return fmt.Sprintf("%dms", ms)
+}
+
+func (x *cmdConnectivity) Execute(args []string) error {
+	results, err := Client().Connectivity()
+	if err != nil {
+		return err
+	}
+
+	var unreachable []*client.ConnectivityResult
+	for _, r := range results {
+		if r.Error != "" {
+			unreachable = append(unreachable, r)
+		}
+	}
+
+	if x.JSON {
+		if results == nil {
+			results = []*client.ConnectivityResult{}
+		}
+		enc := json.NewEncoder(Stdout)
+		if err := enc.Encode(map[string]interface{}{"results": results}); err != nil {
+			return err
+		}
+	} else {
+		w := tabWriter()
+		fmt.Fprintf(w, i18n.G("Endpoint\tStatus\tDNS\tConnect\tTotal\tProxy\tTLS\n"))
+		for _, r := range results {
+			status := fmt.Sprintf("%d", r.Status)
+			if r.Error != "" {
+				status = i18n.G("error")
+			}
+			proxy := r.Proxy
+			if proxy == "" {
+				proxy = "-"
+			}
+			tls := "-"
+			if r.TLS != nil {
+				tls = fmt.Sprintf("%s %s (%s)", r.TLS.Version, r.TLS.CipherSuite, r.TLS.Subject)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", r.Endpoint, status, formatMilliseconds(r.DNSTime), formatMilliseconds(r.ConnectTime), formatMilliseconds(r.TotalTime), proxy, tls)
+		}
+		w.Flush()
+		for _, r := range unreachable {
+			fmt.Fprintf(Stdout, "%s: %s\n", r.Endpoint, r.Error)
+		}
+	}
+
+	if len(unreachable) > 0 {
+		return fmt.Errorf(i18n.G("cannot reach %d of %d endpoints"), len(unreachable), len(results))
+	}
+	return nil
+}
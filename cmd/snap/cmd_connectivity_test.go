@@ -0,0 +1,61 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+const connectivityJSON = `{"type": "sync", "status-code": 200, "result": [
+	{"endpoint": "search", "url": "https://search.apps.ubuntu.com/api/v1/search", "status": 200, "dns-time": 3, "connect-time": 40, "total-time": 210,
+	 "tls": {"version": "TLS 1.2", "cipher-suite": "0xc02f", "subject": "*.apps.ubuntu.com"}},
+	{"endpoint": "cdn", "url": "", "proxy": "http://proxy:3128", "error": "cannot connect to proxy:3128: connection refused", "dns-time": 1, "connect-time": 0, "total-time": 0}
+]}`
+
+func (s *SnapSuite) TestConnectivity(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		c.Check(r.URL.Path, check.Equals, "/v2/debug/connectivity")
+		fmt.Fprintln(w, connectivityJSON)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"debug", "connectivity"})
+	c.Assert(err, check.ErrorMatches, "cannot reach 1 of 2 endpoints")
+	c.Check(s.Stdout(), check.Equals, `Endpoint  Status  DNS  Connect  Total  Proxy              TLS
+search    200     3ms  40ms     210ms  -                  TLS 1.2 0xc02f (*.apps.ubuntu.com)
+cdn       error   1ms  0ms      0ms    http://proxy:3128  -
+cdn: cannot connect to proxy:3128: connection refused
+`)
+}
+
+func (s *SnapSuite) TestConnectivityJSON(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": [{"endpoint": "search", "url": "https://search.apps.ubuntu.com/api/v1/search", "status": 200, "dns-time": 3, "connect-time": 40, "total-time": 210}]}`)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"debug", "connectivity", "--json"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, `{"results":[{"endpoint":"search","url":"https://search.apps.ubuntu.com/api/v1/search","status":200,"dns-time":3,"connect-time":40,"total-time":210}]}`+"\n")
+}
@@ -0,0 +1,77 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/i18n"
+)
+
+type cmdCreateKey struct {
+	Positional struct {
+		KeyName string `positional-arg-name:"<key-name>" description:"name of the key to create; defaults to 'default'"`
+	} `positional-args:"true"`
+}
+
+var shortCreateKeyHelp = i18n.G("Creates a key pair to sign assertions")
+var longCreateKeyHelp = i18n.G(`
+The create-key command creates a 4096 bits RSA key pair under the given
+name, to sign assertions with snap sign. The keys are kept in their own
+GPG keyring in ~/.snap/gnupg, protected by the passphrase asked for.
+`)
+
+func init() {
+	addCommand("create-key", shortCreateKeyHelp, longCreateKeyHelp, func() flags.Commander {
+		return &cmdCreateKey{}
+	})
+}
+
+func (x *cmdCreateKey) Execute(args []string) error {
+	keyName := x.Positional.KeyName
+	if keyName == "" {
+		keyName = defaultKeyName
+	}
+
+	fmt.Fprint(Stdout, i18n.G("Passphrase: "))
+	passphrase, err := readPassword(0)
+	fmt.Fprint(Stdout, "\n")
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(Stdout, i18n.G("Confirm passphrase: "))
+	confirmPassphrase, err := readPassword(0)
+	fmt.Fprint(Stdout, "\n")
+	if err != nil {
+		return err
+	}
+	if string(passphrase) != string(confirmPassphrase) {
+		return errors.New(i18n.G("passphrases do not match"))
+	}
+
+	manager, err := getKeypairManager()
+	if err != nil {
+		return err
+	}
+	return manager.Generate(string(passphrase), keyName)
+}
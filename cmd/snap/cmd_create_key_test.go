@@ -0,0 +1,72 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"os"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	snap "github.com/snapcore/snapd/cmd/snap"
+	"github.com/snapcore/snapd/osutil"
+)
+
+func (s *SnapSuite) mockGPGHome(c *check.C) string {
+	if !osutil.FileExists("/usr/bin/gpg") {
+		c.Skip("gpg not installed")
+	}
+	homedir := c.MkDir()
+	os.Setenv("SNAP_GNUPG_HOME", homedir)
+	s.AddCleanup(func() { os.Unsetenv("SNAP_GNUPG_HOME") })
+	return homedir
+}
+
+func (s *SnapSuite) TestCreateKey(c *check.C) {
+	homedir := s.mockGPGHome(c)
+	restore := snap.MockReadPassword(func(fd int) ([]byte, error) {
+		return nil, nil
+	})
+	defer restore()
+
+	_, err := snap.Parser().ParseArgs([]string{"create-key", "mykey"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "Passphrase: \nConfirm passphrase: \n")
+
+	_, err = asserts.NewGPGKeypairManager(homedir).GetByName("mykey")
+	c.Check(err, check.IsNil)
+
+	_, err = snap.Parser().ParseArgs([]string{"create-key", "mykey"})
+	c.Check(err, check.ErrorMatches, `key named "mykey" already exists in GPG keyring`)
+}
+
+func (s *SnapSuite) TestCreateKeyPassphraseMismatch(c *check.C) {
+	s.mockGPGHome(c)
+	passphrases := []string{"one", "two"}
+	restore := snap.MockReadPassword(func(fd int) ([]byte, error) {
+		p := passphrases[0]
+		passphrases = passphrases[1:]
+		return []byte(p), nil
+	})
+	defer restore()
+
+	_, err := snap.Parser().ParseArgs([]string{"create-key"})
+	c.Check(err, check.ErrorMatches, "passphrases do not match")
+}
@@ -0,0 +1,108 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/i18n"
+)
+
+var shortDenialsHelp = i18n.G("List the apparmor denials of snaps")
+var longDenialsHelp = i18n.G(`
+The denials command lists the accesses apparmor denied to the apps and
+hooks of the given snap, or of all the snaps, as found in the audit or
+kernel log, the most recently seen first. For denied accesses to files
+and capabilities, the interfaces whose connection would allow them are
+suggested.
+`)
+
+type cmdDenials struct {
+	Positional struct {
+		Snap string `positional-arg-name:"<snap>" description:"the snap whose denials to list"`
+	} `positional-args:"yes"`
+}
+
+func init() {
+	addDebugCommand("denials", shortDenialsHelp, longDenialsHelp, func() flags.Commander {
+		return &cmdDenials{}
+	})
+}
+
+// deniedAccess describes what was denied.
+func deniedAccess(d *client.Denial) string {
+	switch {
+	case d.Capability != "":
+		return fmt.Sprintf("capability %s", d.Capability)
+	case d.Path != "" && d.Denied != "":
+		return fmt.Sprintf("%s %s (%s)", d.Operation, d.Path, d.Denied)
+	case d.Path != "":
+		return fmt.Sprintf("%s %s", d.Operation, d.Path)
+	}
+	return d.Operation
+}
+
+// suggestedInterfaces lists the interfaces suggested for a denial, with
+// the plug to connect if the snap has one.
+func suggestedInterfaces(d *client.Denial) string {
+	if len(d.Suggestions) == 0 {
+		return "-"
+	}
+	suggestions := make([]string, len(d.Suggestions))
+	for i, s := range d.Suggestions {
+		switch {
+		case s.Plug == "":
+			suggestions[i] = s.Interface
+		case s.Connected:
+			suggestions[i] = fmt.Sprintf(i18n.G("%s (%s:%s, connected)"), s.Interface, d.Snap, s.Plug)
+		default:
+			suggestions[i] = fmt.Sprintf("%s (%s:%s)", s.Interface, d.Snap, s.Plug)
+		}
+	}
+	return strings.Join(suggestions, ", ")
+}
+
+func (x *cmdDenials) Execute(args []string) error {
+	denials, err := Client().Denials(x.Positional.Snap)
+	if err != nil {
+		return err
+	}
+	if len(denials) == 0 {
+		fmt.Fprintln(Stderr, i18n.G("No denials."))
+		return nil
+	}
+
+	w := tabWriter()
+	fmt.Fprintln(w, i18n.G("Last seen\tSnap\tApp\tCount\tDenied\tSuggested"))
+	for _, d := range denials {
+		app := d.App
+		if d.Hook != "" {
+			app = fmt.Sprintf(i18n.G("%s hook"), d.Hook)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n", d.LastSeen.UTC().Format(time.RFC3339), d.Snap, app, d.Count, deniedAccess(d), suggestedInterfaces(d))
+	}
+	w.Flush()
+	return nil
+}
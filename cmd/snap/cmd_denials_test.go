@@ -0,0 +1,65 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) TestDenials(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		c.Check(r.URL.Path, check.Equals, "/v2/debug/denials")
+		c.Check(r.URL.Query().Get("snap"), check.Equals, "foo")
+		fmt.Fprintln(w, `{"type":"sync", "status-code": 200, "result": [
+			{"snap": "foo", "app": "app", "operation": "open", "path": "/media/usb0/a", "denied": "rw", "count": 3,
+			 "last-seen": "2016-10-14T15:05:00Z", "suggestions": [{"interface": "media", "plug": "usb"}, {"interface": "home"}]},
+			{"snap": "foo", "hook": "configure", "operation": "capable", "capability": "net_admin", "count": 1,
+			 "last-seen": "2016-10-14T15:04:00Z", "suggestions": [{"interface": "network-control", "plug": "net", "connected": true}]},
+			{"snap": "foo", "app": "app", "operation": "signal", "count": 1, "last-seen": "2016-10-14T15:03:00Z"}
+		]}`)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"debug", "denials", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, ""+
+		"Last seen             Snap  App             Count  Denied                   Suggested\n"+
+		"2016-10-14T15:05:00Z  foo   app             3      open /media/usb0/a (rw)  media (foo:usb), home\n"+
+		"2016-10-14T15:04:00Z  foo   configure hook  1      capability net_admin     network-control (foo:net, connected)\n"+
+		"2016-10-14T15:03:00Z  foo   app             1      signal                   -\n")
+}
+
+func (s *SnapSuite) TestDenialsNone(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		_, ok := r.URL.Query()["snap"]
+		c.Check(ok, check.Equals, false)
+		fmt.Fprintln(w, `{"type":"sync", "status-code": 200, "result": []}`)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"debug", "denials"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "")
+	c.Check(s.Stderr(), check.Equals, "No denials.\n")
+}
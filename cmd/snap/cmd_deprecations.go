@@ -0,0 +1,124 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/builtin"
+)
+
+var shortDeprecationsHelp = i18n.G("Report the snaps using deprecated interfaces")
+var longDeprecationsHelp = i18n.G(`
+The deprecations command reports, for each installed snap, the plugs and
+slots using interfaces or interface attributes that are deprecated,
+together with their replacement and when support for them is expected
+to be removed.
+`)
+
+type cmdDeprecations struct{}
+
+func init() {
+	addDebugCommand("deprecations", shortDeprecationsHelp, longDeprecationsHelp, func() flags.Commander {
+		return &cmdDeprecations{}
+	})
+}
+
+// deprecations returns the deprecated interfaces and attributes.
+var deprecations = builtin.Deprecations
+
+// deprecationUse aggregates the plugs and slots of a snap affected by a
+// deprecation.
+type deprecationUse struct {
+	snap        string
+	deprecation *interfaces.Deprecation
+	names       []string
+}
+
+func (x *cmdDeprecations) Execute(args []string) error {
+	ifaces, err := Client().Interfaces()
+	if err != nil {
+		return err
+	}
+
+	var uses []*deprecationUse
+	add := func(snapName, name, iface string, attrs map[string]interface{}) {
+		for _, d := range interfaces.FindDeprecations(deprecations(), iface, attrs) {
+			var use *deprecationUse
+			for _, u := range uses {
+				if u.snap == snapName && u.deprecation == d {
+					use = u
+					break
+				}
+			}
+			if use == nil {
+				use = &deprecationUse{snap: snapName, deprecation: d}
+				uses = append(uses, use)
+			}
+			use.names = append(use.names, name)
+		}
+	}
+	for _, plug := range ifaces.Plugs {
+		add(plug.Snap, plug.Name, plug.Interface, plug.Attrs)
+	}
+	for _, slot := range ifaces.Slots {
+		add(slot.Snap, slot.Name, slot.Interface, slot.Attrs)
+	}
+
+	if len(uses) == 0 {
+		fmt.Fprintln(Stdout, i18n.G("No snaps use deprecated interfaces or attributes."))
+		return nil
+	}
+
+	sort.Sort(usesBySnap(uses))
+	w := tabWriter()
+	fmt.Fprintln(w, i18n.G("Snap\tPlugs and slots\tDeprecated\tReplacement\tRemoval"))
+	for _, use := range uses {
+		sort.Strings(use.names)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", use.snap, strings.Join(use.names, ","), use.deprecation.Subject(), dashIfEmpty(use.deprecation.Replacement), dashIfEmpty(use.deprecation.Removal))
+	}
+	w.Flush()
+
+	return nil
+}
+
+func dashIfEmpty(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+type usesBySnap []*deprecationUse
+
+func (us usesBySnap) Len() int { return len(us) }
+func (us usesBySnap) Less(i, j int) bool {
+	if us[i].snap != us[j].snap {
+		return us[i].snap < us[j].snap
+	}
+	return us[i].deprecation.Subject() < us[j].deprecation.Subject()
+}
+func (us usesBySnap) Swap(i, j int) { us[i], us[j] = us[j], us[i] }
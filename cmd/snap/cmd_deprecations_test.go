@@ -0,0 +1,77 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+	"github.com/snapcore/snapd/interfaces"
+)
+
+func (s *SnapSuite) TestDeprecations(c *check.C) {
+	restore := snap.MockDeprecations([]*interfaces.Deprecation{
+		{Interface: "old", Replacement: `"new"`, Removal: "2.20"},
+		{Interface: "network", Attribute: "legacy"},
+	})
+	defer restore()
+
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		c.Check(r.URL.Path, check.Equals, "/v2/interfaces")
+		fmt.Fprintln(w, `{"type": "sync", "result": {
+"plugs": [
+ {"snap": "foo", "plug": "b", "interface": "old"},
+ {"snap": "foo", "plug": "a", "interface": "old"},
+ {"snap": "bar", "plug": "net", "interface": "network", "attrs": {"legacy": true}},
+ {"snap": "bar", "plug": "net2", "interface": "network"}
+],
+"slots": [
+ {"snap": "baz", "slot": "old", "interface": "old"}
+]}}`)
+		n++
+	})
+	rest, err := snap.Parser().ParseArgs([]string{"debug", "deprecations"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(n, check.Equals, 1)
+	c.Check(s.Stdout(), check.Equals, ""+
+		"Snap  Plugs and slots  Deprecated                                 Replacement  Removal\n"+
+		"bar   net              attribute \"legacy\" of interface \"network\"  -            -\n"+
+		"baz   old              interface \"old\"                            \"new\"        2.20\n"+
+		"foo   a,b              interface \"old\"                            \"new\"        2.20\n")
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *SnapSuite) TestDeprecationsNone(c *check.C) {
+	restore := snap.MockDeprecations(nil)
+	defer restore()
+
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"type": "sync", "result": {"plugs": [{"snap": "foo", "plug": "a", "interface": "old"}]}}`)
+	})
+	_, err := snap.Parser().ParseArgs([]string{"debug", "deprecations"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "No snaps use deprecated interfaces or attributes.\n")
+}
@@ -50,6 +50,7 @@ Disconnects all plugs from the provided snap.
 
 Application Options:
       --version            print the version and exit
+      --screen-reader      report progress as plain lines of text
 
 Help Options:
   -h, --help               Show this help message
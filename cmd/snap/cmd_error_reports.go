@@ -0,0 +1,113 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/i18n"
+)
+
+var shortErrorReportsHelp = i18n.G("List the error reports")
+var longErrorReportsHelp = i18n.G(`
+The error-reports command lists the reports of the failures of snapd kept
+on this system, the ones submitted to the error tracking service and the
+ones queued until it can be reached. Reports are only made once enabled
+with
+
+    snap set core error-reports.enable=true error-reports.url=<url>
+
+With --verbose the content of each report is shown as it is submitted,
+after what could identify the user or the device was removed from it.
+`)
+
+type cmdErrorReports struct {
+	Verbose bool `long:"verbose" description:"show the content of the reports"`
+}
+
+func init() {
+	addDebugCommand("error-reports", shortErrorReportsHelp, longErrorReportsHelp, func() flags.Commander {
+		return &cmdErrorReports{}
+	})
+}
+
+func (x *cmdErrorReports) Execute(args []string) error {
+	reports, err := Client().ErrorReports()
+	if err != nil {
+		return err
+	}
+	if len(reports) == 0 {
+		fmt.Fprintln(Stderr, i18n.G("No error reports."))
+		return nil
+	}
+
+	if x.Verbose {
+		for i, r := range reports {
+			if i > 0 {
+				fmt.Fprintln(Stdout)
+			}
+			status := i18n.G("queued")
+			if !r.Sent.IsZero() {
+				status = fmt.Sprintf(i18n.G("sent %s"), r.Sent.UTC().Format(time.RFC3339))
+			}
+			fmt.Fprintf(Stdout, "id: %s\n", r.ID)
+			fmt.Fprintf(Stdout, "time: %s\n", r.Time.UTC().Format(time.RFC3339))
+			fmt.Fprintf(Stdout, "status: %s\n", status)
+			fmt.Fprintf(Stdout, "error-code: %s\n", r.Code)
+			if r.Snap != "" {
+				fmt.Fprintf(Stdout, "snap: %s\n", r.Snap)
+			}
+			if r.Revision != "" {
+				fmt.Fprintf(Stdout, "revision: %s\n", r.Revision)
+			}
+			fmt.Fprintf(Stdout, "distro: %s %s\n", r.Distro, r.DistroRelease)
+			fmt.Fprintf(Stdout, "architecture: %s\n", r.Architecture)
+			if len(r.Log) > 0 {
+				fmt.Fprintln(Stdout, "log:")
+				for _, line := range r.Log {
+					fmt.Fprintf(Stdout, "  %s\n", line)
+				}
+			}
+		}
+		return nil
+	}
+
+	w := tabWriter()
+	fmt.Fprintln(w, i18n.G("ID\tTime\tError\tSnap\tRev\tStatus"))
+	for _, r := range reports {
+		snap, rev := r.Snap, r.Revision
+		if snap == "" {
+			snap = "-"
+		}
+		if rev == "" {
+			rev = "-"
+		}
+		status := i18n.G("queued")
+		if !r.Sent.IsZero() {
+			status = i18n.G("sent")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", r.ID, r.Time.UTC().Format(time.RFC3339), r.Code, snap, rev, status)
+	}
+	w.Flush()
+	return nil
+}
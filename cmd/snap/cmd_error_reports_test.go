@@ -0,0 +1,91 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+const errorReportsJSON = `{"type": "sync", "status-code": 200, "result": [
+	{"id": "1475323200000000000", "time": "2016-10-01T12:00:00Z", "error-code": "network", "snap": "foo", "revision": "7",
+	 "distro": "ubuntu", "distro-release": "16.04", "architecture": "amd64",
+	 "log": ["2016-10-01T12:00:00Z ERROR dial tcp <ip>:443: i/o timeout"], "sent": "2016-10-01T12:00:05Z"},
+	{"id": "1475326800000000000", "time": "2016-10-01T13:00:00Z", "error-code": "unknown",
+	 "distro": "ubuntu", "distro-release": "16.04", "architecture": "amd64"}
+]}`
+
+func (s *SnapSuite) TestErrorReports(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		c.Check(r.URL.Path, check.Equals, "/v2/debug/error-reports")
+		fmt.Fprintln(w, errorReportsJSON)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"debug", "error-reports"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, `ID                   Time                  Error    Snap  Rev  Status
+1475323200000000000  2016-10-01T12:00:00Z  network  foo   7    sent
+1475326800000000000  2016-10-01T13:00:00Z  unknown  -     -    queued
+`)
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *SnapSuite) TestErrorReportsVerbose(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, errorReportsJSON)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"debug", "error-reports", "--verbose"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, `id: 1475323200000000000
+time: 2016-10-01T12:00:00Z
+status: sent 2016-10-01T12:00:05Z
+error-code: network
+snap: foo
+revision: 7
+distro: ubuntu 16.04
+architecture: amd64
+log:
+  2016-10-01T12:00:00Z ERROR dial tcp <ip>:443: i/o timeout
+
+id: 1475326800000000000
+time: 2016-10-01T13:00:00Z
+status: queued
+error-code: unknown
+distro: ubuntu 16.04
+architecture: amd64
+`)
+}
+
+func (s *SnapSuite) TestErrorReportsNone(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": []}`)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"debug", "error-reports"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "")
+	c.Check(s.Stderr(), check.Equals, "No error reports.\n")
+}
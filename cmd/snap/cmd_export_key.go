@@ -0,0 +1,70 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/i18n"
+)
+
+type cmdExportKey struct {
+	Positional struct {
+		KeyName string `positional-arg-name:"<key-name>" description:"name of the key to export; defaults to 'default'"`
+	} `positional-args:"true"`
+}
+
+var shortExportKeyHelp = i18n.G("Exports a public key")
+var longExportKeyHelp = i18n.G(`
+The export-key command exports the public part of a key created with
+snap create-key, encoded as the body of the account-key assertion that
+lets others check the assertions signed with it.
+`)
+
+func init() {
+	addCommand("export-key", shortExportKeyHelp, longExportKeyHelp, func() flags.Commander {
+		return &cmdExportKey{}
+	})
+}
+
+func (x *cmdExportKey) Execute(args []string) error {
+	keyName := x.Positional.KeyName
+	if keyName == "" {
+		keyName = defaultKeyName
+	}
+
+	manager, err := getKeypairManager()
+	if err != nil {
+		return err
+	}
+	privKey, err := manager.GetByName(keyName)
+	if err != nil {
+		return err
+	}
+	encoded, err := asserts.EncodePublicKey(privKey.PublicKey())
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(Stdout, "%s\n", encoded)
+	return nil
+}
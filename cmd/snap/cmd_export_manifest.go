@@ -0,0 +1,71 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/installmanifest"
+	"github.com/snapcore/snapd/osutil"
+)
+
+var shortExportManifestHelp = i18n.G("Export the install manifest of the system")
+var longExportManifestHelp = i18n.G(`
+The export-manifest command writes the manifest of the snaps installed,
+refreshed and removed on the system, once checked it was not tampered with,
+into the given file. With --key the manifest is also signed, the detached
+signature being written next to it with the .sig extension.
+`)
+
+type cmdExportManifest struct {
+	KeyID      string `long:"key" description:"GnuPG key to sign the manifest with"`
+	Positional struct {
+		Filename string `positional-arg-name:"<filename>" required:"yes"`
+	} `positional-args:"yes"`
+}
+
+func init() {
+	addDebugCommand("export-manifest", shortExportManifestHelp, longExportManifestHelp, func() flags.Commander {
+		return &cmdExportManifest{}
+	})
+}
+
+func (x *cmdExportManifest) Execute(args []string) error {
+	var buf bytes.Buffer
+	entries, sig, err := installmanifest.Export(&buf, &installmanifest.ExportOptions{KeyID: x.KeyID})
+	if err != nil {
+		return err
+	}
+	if err := osutil.AtomicWriteFile(x.Positional.Filename, buf.Bytes(), 0600, 0); err != nil {
+		return err
+	}
+	if sig != nil {
+		if err := osutil.AtomicWriteFile(x.Positional.Filename+".sig", sig, 0600, 0); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(Stdout, i18n.G("Exported %d install manifest entries to %s\n"), len(entries), x.Positional.Filename)
+	return nil
+}
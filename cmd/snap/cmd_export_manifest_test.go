@@ -0,0 +1,67 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/installmanifest"
+)
+
+func (s *SnapSuite) TestExportManifest(c *check.C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("/")
+
+	c.Assert(installmanifest.Append(
+		&installmanifest.Entry{Action: "install", Snap: "foo", Revision: "1"},
+		&installmanifest.Entry{Action: "remove", Snap: "foo"},
+	), check.IsNil)
+
+	filename := filepath.Join(c.MkDir(), "manifest.jsonl")
+	rest, err := snap.Parser().ParseArgs([]string{"debug", "export-manifest", filename})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Equals, "Exported 2 install manifest entries to "+filename+"\n")
+
+	exported, err := ioutil.ReadFile(filename)
+	c.Assert(err, check.IsNil)
+	data, err := ioutil.ReadFile(dirs.SnapInstallManifestFile)
+	c.Assert(err, check.IsNil)
+	c.Check(string(exported), check.Equals, string(data))
+}
+
+func (s *SnapSuite) TestExportManifestTampered(c *check.C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("/")
+
+	c.Assert(installmanifest.Append(&installmanifest.Entry{Action: "install", Snap: "foo", Revision: "1"}), check.IsNil)
+	data, err := ioutil.ReadFile(dirs.SnapInstallManifestFile)
+	c.Assert(err, check.IsNil)
+	data = []byte(string(data[:len(data)-1]) + "\n" + `{"seq":2,"action":"install","snap":"bar"}` + "\n")
+	c.Assert(ioutil.WriteFile(dirs.SnapInstallManifestFile, data, 0600), check.IsNil)
+
+	_, err = snap.Parser().ParseArgs([]string{"debug", "export-manifest", filepath.Join(c.MkDir(), "manifest.jsonl")})
+	c.Assert(err, check.ErrorMatches, "install manifest entry 2 does not follow the previous one")
+}
@@ -0,0 +1,119 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/overlord/statearchive"
+)
+
+var shortExportStateHelp = i18n.G("Export the system state into an archive")
+var longExportStateHelp = i18n.G(`
+The export-state command writes the system state, including interface
+connections, configuration and assertions, and the system data of the
+current revision of the snaps into an archive that can be imported with
+import-state on replacement hardware.
+`)
+
+var shortImportStateHelp = i18n.G("Import the system state from an archive")
+var longImportStateHelp = i18n.G(`
+The import-state command installs the system state from an archive written
+by export-state. The snaps the state refers to need to be present on the
+system already, and snapd must not be running. The archive must be signed
+by a key in the keyring of the device owner, /etc/snapd/state-archive.gpg,
+and exported by the same or an older version of snapd.
+`)
+
+type cmdExportState struct {
+	KeyID      string `long:"key" description:"GnuPG key to sign the archive with"`
+	Positional struct {
+		Filename string `positional-arg-name:"<filename>" required:"yes"`
+	} `positional-args:"yes"`
+}
+
+type cmdImportState struct {
+	AllowUnsigned bool `long:"allow-unsigned" description:"import archives that are not signed"`
+	Force         bool `long:"force" description:"replace an existing system state"`
+	Positional    struct {
+		Filename string `positional-arg-name:"<filename>" required:"yes"`
+	} `positional-args:"yes"`
+}
+
+func init() {
+	addDebugCommand("export-state", shortExportStateHelp, longExportStateHelp, func() flags.Commander {
+		return &cmdExportState{}
+	})
+	addDebugCommand("import-state", shortImportStateHelp, longImportStateHelp, func() flags.Commander {
+		return &cmdImportState{}
+	})
+}
+
+func (x *cmdExportState) Execute(args []string) error {
+	fn := x.Positional.Filename
+	// the archive is written next to its target and renamed into place
+	// once complete, so no half written archive is ever left there
+	f, err := ioutil.TempFile(filepath.Dir(fn), "."+filepath.Base(fn)+"~")
+	if err != nil {
+		return err
+	}
+	manifest, err := statearchive.Export(f, &statearchive.ExportOptions{KeyID: x.KeyID})
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), fn)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	fmt.Fprintf(Stdout, i18n.G("Exported state of %d snaps to %s\n"), len(manifest.Snaps), fn)
+	return nil
+}
+
+func (x *cmdImportState) Execute(args []string) error {
+	f, err := os.Open(x.Positional.Filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	manifest, err := statearchive.Import(f, &statearchive.ImportOptions{
+		AllowUnsigned: x.AllowUnsigned,
+		Force:         x.Force,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(Stdout, i18n.G("Imported state of %d snaps from %s\n"), len(manifest.Snaps), x.Positional.Filename)
+	return nil
+}
@@ -0,0 +1,78 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+	"github.com/snapcore/snapd/dirs"
+)
+
+func (s *SnapSuite) TestExportImportState(c *check.C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("/")
+
+	c.Assert(os.MkdirAll(filepath.Dir(dirs.SnapStateFile), 0755), check.IsNil)
+	c.Assert(ioutil.WriteFile(dirs.SnapStateFile, []byte(`{"data":{}}`), 0600), check.IsNil)
+
+	archive := filepath.Join(c.MkDir(), "state.tar.gz")
+	rest, err := snap.Parser().ParseArgs([]string{"debug", "export-state", archive})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Equals, "Exported state of 0 snaps to "+archive+"\n")
+	fis, err := ioutil.ReadDir(filepath.Dir(archive))
+	c.Assert(err, check.IsNil)
+	c.Assert(fis, check.HasLen, 1)
+	c.Check(fis[0].Name(), check.Equals, "state.tar.gz")
+	c.Check(fis[0].Mode().Perm(), check.Equals, os.FileMode(0600))
+
+	c.Assert(os.Remove(dirs.SnapStateFile), check.IsNil)
+	s.stdout.Reset()
+
+	_, err = snap.Parser().ParseArgs([]string{"debug", "import-state", archive})
+	c.Assert(err, check.ErrorMatches, "cannot import state archive: manifest is not signed")
+
+	_, err = snap.Parser().ParseArgs([]string{"debug", "import-state", "--allow-unsigned", archive})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "Imported state of 0 snaps from "+archive+"\n")
+
+	data, err := ioutil.ReadFile(dirs.SnapStateFile)
+	c.Assert(err, check.IsNil)
+	c.Check(string(data), check.Equals, `{"data":{}}`)
+}
+
+func (s *SnapSuite) TestExportStateFails(c *check.C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("/")
+
+	dir := c.MkDir()
+	_, err := snap.Parser().ParseArgs([]string{"debug", "export-state", filepath.Join(dir, "state.tar.gz")})
+	c.Assert(err, check.ErrorMatches, "cannot read system state: .*")
+
+	// nothing is left behind
+	fis, err := ioutil.ReadDir(dir)
+	c.Assert(err, check.IsNil)
+	c.Check(fis, check.HasLen, 0)
+}
@@ -0,0 +1,104 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/i18n"
+)
+
+var shortGetHelp = i18n.G("Prints configuration options")
+var longGetHelp = i18n.G(`
+The get command prints the configuration option of the given snap.
+
+    $ snap get snap-name username
+    frank
+
+Nested values are printed as JSON, so for instance all the extra CA
+certificates trusted by the store client are listed with:
+
+    $ snap get core store-certs
+
+With -d the options with the given keys, or the whole configuration of
+the snap if no keys are given, are printed as a single JSON document:
+
+    $ snap get -d snap-name username server
+    {
+    	"server": {
+    		"port": 8080
+    	},
+    	"username": "frank"
+    }
+`)
+
+type cmdGet struct {
+	Document bool `short:"d" description:"print the options, or the whole configuration, as a JSON document"`
+
+	Positional struct {
+		Snap string   `positional-arg-name:"<snap name>" description:"the snap whose conf is being requested"`
+		Keys []string `positional-arg-name:"<key>" description:"key of interest within the configuration"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func init() {
+	addCommand("get", shortGetHelp, longGetHelp, func() flags.Commander {
+		return &cmdGet{}
+	})
+}
+
+func (x *cmdGet) Execute(args []string) error {
+	keys := x.Positional.Keys
+	if !x.Document && len(keys) != 1 {
+		return fmt.Errorf(i18n.G("get needs exactly one key, or -d"))
+	}
+
+	conf, err := Client().Conf(x.Positional.Snap, keys)
+	if err != nil {
+		return err
+	}
+
+	if x.Document {
+		return printJSON(conf)
+	}
+
+	switch value := conf[keys[0]].(type) {
+	case string:
+		fmt.Fprintln(Stdout, value)
+	case map[string]interface{}, []interface{}:
+		return printJSON(value)
+	default:
+		fmt.Fprintln(Stdout, value)
+	}
+
+	return nil
+}
+
+func printJSON(value interface{}) error {
+	bytes, err := json.MarshalIndent(value, "", "\t")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(Stdout, string(bytes))
+	return nil
+}
@@ -0,0 +1,78 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/i18n"
+)
+
+var shortLicenseHelp = i18n.G("Show or accept the license of a snap")
+var longLicenseHelp = i18n.G(`
+The license command shows the license shipped with an installed snap that
+requires accepting it before its services are started, and whether it was
+accepted. With --accept the license is accepted and the services of the
+snap are started.
+`)
+
+type cmdLicense struct {
+	Accept     bool `long:"accept" description:"Accept the license and start the services of the snap"`
+	Positional struct {
+		Snap string `positional-arg-name:"<snap>"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func init() {
+	addCommand("license", shortLicenseHelp, longLicenseHelp, func() flags.Commander {
+		return &cmdLicense{}
+	})
+}
+
+func (x *cmdLicense) Execute(args []string) error {
+	cli := Client()
+	name := x.Positional.Snap
+
+	if x.Accept {
+		id, err := cli.AcceptLicense(name)
+		if err != nil {
+			return err
+		}
+		if _, err := wait(cli, id); err != nil {
+			return err
+		}
+		fmt.Fprintf(Stdout, i18n.G("License of snap %q accepted\n"), name)
+		return nil
+	}
+
+	license, err := cli.License(name)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(Stdout, "%s\n\n%s", license.Intro, license.License)
+	if license.Agreed {
+		fmt.Fprintln(Stdout, i18n.G("\nThe license was accepted."))
+	} else {
+		fmt.Fprintf(Stdout, i18n.G("\nThe license was not accepted yet, accept it with 'snap license --accept %s'.\n"), name)
+	}
+	return nil
+}
@@ -72,9 +72,10 @@ func listSnaps(names []string) error {
 
 	for _, snap := range snaps {
 		notes := &Notes{
-			Private: snap.Private,
-			DevMode: snap.DevMode,
-			TryMode: snap.TryMode,
+			Private:        snap.Private,
+			DevMode:        snap.DevMode,
+			TryMode:        snap.TryMode,
+			LicensePending: snap.LicenseAgreement != "" && !snap.LicenseAccepted,
 		}
 		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", snap.Name, snap.Version, snap.Revision, snap.Developer, notes)
 	}
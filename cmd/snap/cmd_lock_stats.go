@@ -0,0 +1,74 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/i18n"
+)
+
+var shortLockStatsHelp = i18n.G("Show who holds the state lock of snapd and for how long")
+var longLockStatsHelp = i18n.G(`
+The lock-stats command shows which operation of snapd holds its state
+lock, if any, and for each operation that took the lock how many times
+it did, and the longest and average times it waited for the lock and
+held it, the longest holds first. It works even when snapd is stuck
+holding the lock.
+`)
+
+type cmdLockStats struct{}
+
+func init() {
+	addDebugCommand("lock-stats", shortLockStatsHelp, longLockStatsHelp, func() flags.Commander {
+		return &cmdLockStats{}
+	})
+}
+
+func (x *cmdLockStats) Execute(args []string) error {
+	stats, err := Client().LockStats()
+	if err != nil {
+		return err
+	}
+
+	if stats.Holder != "" {
+		fmt.Fprintf(Stdout, i18n.G("Held by %s since %s.\n"), stats.Holder, stats.HeldSince.UTC().Format(time.RFC3339))
+	} else {
+		fmt.Fprintln(Stdout, i18n.G("Not held."))
+	}
+	if len(stats.Ops) == 0 {
+		return nil
+	}
+
+	w := tabWriter()
+	fmt.Fprintln(w, i18n.G("Operation\tCount\tMax hold\tAvg hold\tMax wait\tAvg wait"))
+	for _, op := range stats.Ops {
+		n := time.Duration(op.Count)
+		if n == 0 {
+			n = 1
+		}
+		fmt.Fprintf(w, "%s\t%d\t%v\t%v\t%v\t%v\n", op.Op, op.Count, op.MaxHold, op.TotalHold/n, op.MaxWait, op.TotalWait/n)
+	}
+	w.Flush()
+	return nil
+}
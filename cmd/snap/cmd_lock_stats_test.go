@@ -0,0 +1,61 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) TestLockStats(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		c.Check(r.URL.Path, check.Equals, "/v2/debug/lock-stats")
+		fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": {
+			"holder": "snapstate.(*SnapManager).doLinkSnap",
+			"held-since": "2016-10-01T12:00:00Z",
+			"operations": [
+				{"operation": "snapstate.(*SnapManager).doLinkSnap", "count": 2, "total-wait": 4000000, "max-wait": 3000000, "total-hold": 6000000000, "max-hold": 5000000000},
+				{"operation": "overlord.(*Overlord).Loop.func1", "count": 4, "total-wait": 0, "max-wait": 0, "total-hold": 4000000, "max-hold": 2000000}
+			]}}`)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"debug", "lock-stats"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, `Held by snapstate.(*SnapManager).doLinkSnap since 2016-10-01T12:00:00Z.
+Operation                            Count  Max hold  Avg hold  Max wait  Avg wait
+snapstate.(*SnapManager).doLinkSnap  2      5s        3s        3ms       2ms
+overlord.(*Overlord).Loop.func1      4      2ms       1ms       0s        0s
+`)
+}
+
+func (s *SnapSuite) TestLockStatsNotHeld(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": {"operations": []}}`)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"debug", "lock-stats"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "Not held.\n")
+}
@@ -0,0 +1,93 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/i18n"
+)
+
+var shortQuarantineHelp = i18n.G("List or purge the quarantined snaps")
+var longQuarantineHelp = i18n.G(`
+The quarantine command lists the snap files kept in quarantine as their
+installation was refused, because the scanner set with scanner.command or
+scanner.socket rejected them or could not scan them, or because they
+failed the checks of snapd. They are kept as
+/var/lib/snapd/quarantine/<id>.snap for them to be inspected, until purged.
+
+With --purge the quarantined snaps with the given ids, or all of them,
+are removed.
+`)
+
+type cmdQuarantine struct {
+	Purge      bool `long:"purge" description:"remove the given quarantined snaps, or all of them"`
+	Positional struct {
+		IDs []string `positional-arg-name:"<id>" description:"the id of a quarantined snap to purge"`
+	} `positional-args:"yes"`
+}
+
+func init() {
+	addDebugCommand("quarantine", shortQuarantineHelp, longQuarantineHelp, func() flags.Commander {
+		return &cmdQuarantine{}
+	})
+}
+
+func (x *cmdQuarantine) Execute(args []string) error {
+	if len(x.Positional.IDs) > 0 && !x.Purge {
+		return fmt.Errorf(i18n.G("quarantined snaps can only be given to purge them, with --purge"))
+	}
+
+	cli := Client()
+	if x.Purge {
+		purged, err := cli.PurgeQuarantine(x.Positional.IDs)
+		if err != nil {
+			return err
+		}
+		for _, q := range purged {
+			fmt.Fprintf(Stdout, i18n.G("Purged %s (snap %q)\n"), q.ID, q.Snap)
+		}
+		return nil
+	}
+
+	quarantined, err := cli.Quarantine()
+	if err != nil {
+		return err
+	}
+	if len(quarantined) == 0 {
+		fmt.Fprintln(Stderr, i18n.G("No quarantined snaps."))
+		return nil
+	}
+
+	w := tabWriter()
+	fmt.Fprintln(w, i18n.G("ID\tTime\tSnap\tRev\tReason\tError"))
+	for _, q := range quarantined {
+		rev := q.Revision
+		if rev == "" || rev == "unset" {
+			rev = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", q.ID, q.Time.UTC().Format(time.RFC3339), q.Snap, rev, q.Reason, q.Error)
+	}
+	w.Flush()
+	return nil
+}
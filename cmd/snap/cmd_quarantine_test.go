@@ -0,0 +1,82 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) TestQuarantine(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		c.Check(r.URL.Path, check.Equals, "/v2/debug/quarantine")
+		fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": [
+			{"id": "1475323200000000000", "time": "2016-10-01T12:00:00Z", "snap": "foo", "revision": "7",
+			 "reason": "scan-rejected", "error": "snap \"foo\" was rejected by the scanner: Eicar-Test-Signature FOUND"},
+			{"id": "1475326800000000000", "time": "2016-10-01T13:00:00Z", "snap": "bar", "revision": "unset",
+			 "reason": "check-failed", "error": "cannot install a gadget snap on classic"}
+		]}`)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"debug", "quarantine"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, `ID                   Time                  Snap  Rev  Reason         Error
+1475323200000000000  2016-10-01T12:00:00Z  foo   7    scan-rejected  snap "foo" was rejected by the scanner: Eicar-Test-Signature FOUND
+1475326800000000000  2016-10-01T13:00:00Z  bar   -    check-failed   cannot install a gadget snap on classic
+`)
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *SnapSuite) TestQuarantineNone(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": []}`)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"debug", "quarantine"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "")
+	c.Check(s.Stderr(), check.Equals, "No quarantined snaps.\n")
+}
+
+func (s *SnapSuite) TestQuarantinePurge(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "POST")
+		c.Check(r.URL.Path, check.Equals, "/v2/debug/quarantine")
+		body, err := ioutil.ReadAll(r.Body)
+		c.Check(err, check.IsNil)
+		c.Check(string(body), check.Equals, `{"action":"purge","ids":["1475323200000000000"]}`+"\n")
+		fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": [{"id": "1475323200000000000", "snap": "foo"}]}`)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"debug", "quarantine", "--purge", "1475323200000000000"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "Purged 1475323200000000000 (snap \"foo\")\n")
+}
+
+func (s *SnapSuite) TestQuarantineIDsWithoutPurge(c *check.C) {
+	_, err := snap.Parser().ParseArgs([]string{"debug", "quarantine", "1475323200000000000"})
+	c.Assert(err, check.ErrorMatches, `quarantined snaps can only be given to purge them, with --purge`)
+}
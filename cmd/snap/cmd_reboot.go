@@ -0,0 +1,78 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/i18n"
+)
+
+var shortRebootHelp = i18n.G("Show or hasten the pending reboot")
+var longRebootHelp = i18n.G(`
+The reboot command tells when the device is due to reboot to finish
+applying the refreshes of kernel, gadget or core snaps. These reboots are
+deferred to the windows set with
+
+    snap set core reboot.schedule=sun,03:00-05:00
+
+if any. With --now the device reboots right away instead.
+`)
+
+type cmdReboot struct {
+	Now bool `long:"now" description:"Reboot right away"`
+}
+
+func init() {
+	addCommand("reboot", shortRebootHelp, longRebootHelp, func() flags.Commander {
+		return &cmdReboot{}
+	})
+}
+
+func (x *cmdReboot) Execute(args []string) error {
+	cli := Client()
+	if x.Now {
+		if err := cli.RebootNow(); err != nil {
+			return err
+		}
+		fmt.Fprintln(Stdout, i18n.G("Rebooting."))
+		return nil
+	}
+
+	sysInfo, err := cli.SysInfo()
+	if err != nil {
+		return err
+	}
+	if len(sysInfo.RebootRequired) == 0 {
+		fmt.Fprintln(Stdout, i18n.G("No reboot is pending."))
+		return nil
+	}
+	snaps := strings.Join(sysInfo.RebootRequired, ", ")
+	if sysInfo.RebootAt.IsZero() {
+		fmt.Fprintf(Stdout, i18n.G("Reboot pending for %s.\n"), snaps)
+		return nil
+	}
+	fmt.Fprintf(Stdout, i18n.G("Reboot pending for %s, due %s (use --now to reboot right away).\n"), snaps, sysInfo.RebootAt.UTC().Format(time.RFC3339))
+	return nil
+}
@@ -0,0 +1,68 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) TestRebootPending(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		c.Check(r.URL.Path, check.Equals, "/v2/system-info")
+		fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": {"series": "16", "reboot-required": ["core", "pc-kernel"], "reboot-at": "2016-08-07T03:00:00Z"}}`)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"reboot"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "Reboot pending for core, pc-kernel, due 2016-08-07T03:00:00Z (use --now to reboot right away).\n")
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *SnapSuite) TestRebootNothingPending(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": {"series": "16"}}`)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"reboot"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "No reboot is pending.\n")
+}
+
+func (s *SnapSuite) TestRebootNow(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "POST")
+		c.Check(r.URL.Path, check.Equals, "/v2/reboot")
+		body, err := ioutil.ReadAll(r.Body)
+		c.Assert(err, check.IsNil)
+		c.Check(string(body), check.Equals, `{"action":"now"}`+"\n")
+		fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": null}`)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"reboot", "--now"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "Rebooting.\n")
+}
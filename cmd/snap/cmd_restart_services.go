@@ -0,0 +1,50 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"github.com/jessevdk/go-flags"
+)
+
+// cmdRestartServices is run by snapd.locale-changed.service to have
+// snapd restart the running services of snaps, in their order, when the
+// locale or the timezone of the system changes.
+type cmdRestartServices struct{}
+
+func init() {
+	cmd := addCommand("restart-services",
+		"internal",
+		"internal",
+		func() flags.Commander {
+			return &cmdRestartServices{}
+		})
+	cmd.hidden = true
+}
+
+func (x *cmdRestartServices) Execute(args []string) error {
+	cli := Client()
+	id, err := cli.RestartServices()
+	if err != nil || id == "" {
+		return err
+	}
+
+	_, err = wait(cli, id)
+	return err
+}
@@ -0,0 +1,87 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/dnsstub"
+	"github.com/snapcore/snapd/i18n"
+)
+
+var shortDNSStubHelp = i18n.G("Runs the stub resolver of a snap")
+var longDNSStubHelp = i18n.G(`
+The dns-stub command runs the stub resolver dedicated to a snap, logging
+the names it resolves and forwarding the queries to the resolvers of the
+host, or to those of the given network link.
+
+This command is run by the snap.<snap>.dns-stub service of snaps whose
+network plug sets the dns attribute.
+`)
+
+type cmdRoutineDNSStub struct {
+	Link       string `long:"link" description:"forward the queries to the resolvers of this network link"`
+	Positional struct {
+		Snap string `positional-arg-name:"<snap>" description:"the snap name"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func init() {
+	addRoutineCommand("dns-stub", shortDNSStubHelp, longDNSStubHelp, func() flags.Commander {
+		return &cmdRoutineDNSStub{}
+	})
+}
+
+func (x *cmdRoutineDNSStub) Execute(args []string) error {
+	var upstream []string
+	var err error
+	if x.Link != "" {
+		upstream, err = dnsstub.LinkResolvers(x.Link)
+	} else {
+		upstream, err = dnsstub.HostResolvers(filepath.Join(dirs.GlobalRootDir, "/etc/resolv.conf"))
+	}
+	if err != nil {
+		return fmt.Errorf("cannot find upstream resolvers: %v", err)
+	}
+
+	addr := net.JoinHostPort(dnsstub.ListenAddress(x.Positional.Snap), "53")
+	pc, l, err := dnsstub.Listen(addr)
+	if err != nil {
+		return fmt.Errorf("cannot listen on %s: %v", addr, err)
+	}
+	defer pc.Close()
+	defer l.Close()
+
+	stub := &dnsstub.Stub{
+		Snap:     x.Positional.Snap,
+		Upstream: upstream,
+		Timeout:  5 * time.Second,
+		Logf: func(format string, args ...interface{}) {
+			fmt.Fprintf(Stdout, format+"\n", args...)
+		},
+	}
+	return stub.Serve(pc, l)
+}
@@ -0,0 +1,110 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/i18n"
+)
+
+var shortFileAccessHelp = i18n.G("Tells whether a snap can access a path")
+var longFileAccessHelp = i18n.G(`
+The file-access command tells whether the given application of a snap
+can read, write or execute the given path under its current
+confinement, and which interfaces would allow it to. The application
+can be left out for snaps with a single one.
+
+Directories are told apart by a trailing slash, as in apparmor rules.
+`)
+
+type cmdRoutineFileAccess struct {
+	Positional struct {
+		SnapApp string `positional-arg-name:"<snap>[.<app>]" description:"the snap and its application"`
+		Path    string `positional-arg-name:"<path>" description:"the absolute path"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func init() {
+	addRoutineCommand("file-access", shortFileAccessHelp, longFileAccessHelp, func() flags.Commander {
+		return &cmdRoutineFileAccess{}
+	})
+}
+
+// accessString lists what is allowed, or says none.
+func accessString(read, write, execute bool) string {
+	var allowed []string
+	if read {
+		allowed = append(allowed, "read")
+	}
+	if write {
+		allowed = append(allowed, "write")
+	}
+	if execute {
+		allowed = append(allowed, "execute")
+	}
+	if len(allowed) == 0 {
+		return "none"
+	}
+	return strings.Join(allowed, ", ")
+}
+
+func (x *cmdRoutineFileAccess) Execute(args []string) error {
+	parts := strings.SplitN(x.Positional.SnapApp, ".", 2)
+	snapName, appName := parts[0], ""
+	if len(parts) == 2 {
+		appName = parts[1]
+	}
+
+	access, err := Client().FileAccess(snapName, appName, x.Positional.Path)
+	if err != nil {
+		return err
+	}
+
+	w := tabWriter()
+	defer w.Flush()
+
+	fmt.Fprintf(w, "app:\t%s.%s\n", access.Snap, access.App)
+	fmt.Fprintf(w, "path:\t%s\n", access.Path)
+	fmt.Fprintf(w, "access:\t%s\n", accessString(access.Read, access.Write, access.Execute))
+	if access.DevMode {
+		fmt.Fprintf(w, "devmode:\ttrue\n")
+	}
+	if len(access.Interfaces) == 0 {
+		return nil
+	}
+	fmt.Fprintf(w, "interfaces:\n")
+	for _, iface := range access.Interfaces {
+		var status string
+		switch {
+		case iface.Plug == "":
+			status = i18n.G("no plug")
+		case iface.Connected:
+			status = fmt.Sprintf(i18n.G("plug %s, connected"), iface.Plug)
+		default:
+			status = fmt.Sprintf(i18n.G("plug %s, disconnected"), iface.Plug)
+		}
+		fmt.Fprintf(w, "  %s (%s):\t%s\n", iface.Interface, status, accessString(iface.Read, iface.Write, iface.Execute))
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) TestFileAccess(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		c.Check(r.URL.Path, check.Equals, "/v2/file-access")
+		c.Check(r.URL.Query().Get("snap"), check.Equals, "foo")
+		c.Check(r.URL.Query().Get("app"), check.Equals, "app")
+		c.Check(r.URL.Query().Get("path"), check.Equals, "/media/usb0/file")
+		fmt.Fprintln(w, `{"type":"sync", "status-code": 200, "result": {
+			"snap": "foo",
+			"app": "app",
+			"path": "/media/usb0/file",
+			"read": true,
+			"interfaces": [
+				{"interface": "home", "read": true},
+				{"interface": "media", "plug": "usb", "read": true, "write": true},
+				{"interface": "tools", "plug": "bins", "connected": true, "execute": true}
+			]
+		}}`)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"routine", "file-access", "foo.app", "/media/usb0/file"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, `app:     foo.app
+path:    /media/usb0/file
+access:  read
+interfaces:
+  home (no plug):                  read
+  media (plug usb, disconnected):  read, write
+  tools (plug bins, connected):    execute
+`)
+}
+
+func (s *SnapSuite) TestFileAccessNoApp(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		_, ok := r.URL.Query()["app"]
+		c.Check(ok, check.Equals, false)
+		fmt.Fprintln(w, `{"type":"sync", "status-code": 200, "result": {"snap": "foo", "app": "foo", "path": "/srv", "devmode": true, "read": true, "write": true, "execute": true}}`)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"routine", "file-access", "foo", "/srv"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "app:      foo.foo\npath:     /srv\naccess:   read, write, execute\ndevmode:  true\n")
+}
+
+func (s *SnapSuite) TestFileAccessError(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"type":"error", "status-code": 404, "result": {"message": "cannot find snap \"foo\""}}`)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"routine", "file-access", "foo", "/srv"})
+	c.Assert(err, check.ErrorMatches, `cannot find snap "foo"`)
+}
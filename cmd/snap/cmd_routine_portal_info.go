@@ -0,0 +1,68 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/i18n"
+)
+
+var shortPortalInfoHelp = i18n.G("Returns information about a process")
+var longPortalInfoHelp = i18n.G(`
+The portal-info command returns information about a process in keyfile
+format.
+
+This command is used by the xdg-desktop-portal service to retrieve
+information about snap confined processes.
+`)
+
+type cmdRoutinePortalInfo struct {
+	Positional struct {
+		Pid int `positional-arg-name:"<pid>" description:"the process id"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func init() {
+	addRoutineCommand("portal-info", shortPortalInfoHelp, longPortalInfoHelp, func() flags.Commander {
+		return &cmdRoutinePortalInfo{}
+	})
+}
+
+func (x *cmdRoutinePortalInfo) Execute(args []string) error {
+	info, err := Client().PortalInfo(x.Positional.Pid)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(Stdout, "[Snap Info]\n")
+	fmt.Fprintf(Stdout, "InstanceName=%s\n", info.Snap)
+	if info.App != "" {
+		fmt.Fprintf(Stdout, "AppName=%s\n", info.App)
+	}
+	if info.DesktopFile != "" {
+		fmt.Fprintf(Stdout, "DesktopFile=%s\n", filepath.Base(info.DesktopFile))
+	}
+	fmt.Fprintf(Stdout, "HasNetwork=%t\n", info.HasNetwork)
+	return nil
+}
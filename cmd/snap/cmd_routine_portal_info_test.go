@@ -0,0 +1,71 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) TestPortalInfo(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		c.Check(r.URL.Path, check.Equals, "/v2/portal-info")
+		c.Check(r.URL.Query().Get("pid"), check.Equals, "42")
+		fmt.Fprintln(w, `{"type":"sync", "status-code": 200, "result": {
+			"snap": "foo",
+			"app": "app",
+			"desktop-file": "/var/lib/snapd/desktop/applications/foo_app.desktop",
+			"has-network": true
+		}}`)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"routine", "portal-info", "42"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, `[Snap Info]
+InstanceName=foo
+AppName=app
+DesktopFile=foo_app.desktop
+HasNetwork=true
+`)
+}
+
+func (s *SnapSuite) TestPortalInfoNoApp(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"type":"sync", "status-code": 200, "result": {"snap": "foo", "has-network": false}}`)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"routine", "portal-info", "42"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "[Snap Info]\nInstanceName=foo\nHasNetwork=false\n")
+}
+
+func (s *SnapSuite) TestPortalInfoNotASnap(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"type":"error", "status-code": 404, "result": {"message": "process 42 is not part of any snap"}}`)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"routine", "portal-info", "42"})
+	c.Assert(err, check.ErrorMatches, "process 42 is not part of any snap")
+}
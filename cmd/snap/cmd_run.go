@@ -20,6 +20,7 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/user"
@@ -29,6 +30,7 @@ import (
 
 	"github.com/jessevdk/go-flags"
 
+	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/snap"
@@ -48,6 +50,7 @@ type cmdRun struct {
 	Command  string `long:"command" description:"alternative command to run" hidden:"yes"`
 	Hook     string `long:"hook" description:"hook to run" hidden:"yes"`
 	Revision string `short:"r" description:"use a specific snap revision when running hook" hidden:"yes"`
+	PrintEnv bool   `long:"print-env" description:"print the environment the app or hook would run with, instead of running it"`
 }
 
 func init() {
@@ -70,8 +73,17 @@ func (x *cmdRun) Execute(args []string) error {
 	if x.Hook != "" && len(args) > 0 {
 		return fmt.Errorf("too many arguments for hook %q: %s", x.Hook, strings.Join(args, " "))
 	}
+	if x.PrintEnv && len(args) > 0 {
+		return fmt.Errorf("too many arguments for --print-env: %s", strings.Join(args, " "))
+	}
 
 	// Now actually handle the dispatching
+	if x.PrintEnv && x.Hook != "" {
+		return snapPrintHookEnv(x.Positional.SnapApp, x.Hook, x.Revision)
+	}
+	if x.PrintEnv {
+		return snapPrintAppEnv(x.Positional.SnapApp, x.Command)
+	}
 	if x.Hook != "" {
 		return snapRunHook(x.Positional.SnapApp, x.Hook, x.Revision)
 	}
@@ -139,42 +151,56 @@ func createUserDataDirs(info *snap.Info) error {
 	return nil
 }
 
-func snapRunApp(snapApp, command string, args []string) error {
+func findApp(snapApp string) (*snap.AppInfo, error) {
 	snapName, appName := snap.SplitSnapApp(snapApp)
 	info, err := getSnapInfo(snapName, "")
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	app := info.Apps[appName]
 	if app == nil {
-		return fmt.Errorf("cannot find app %q in %q", appName, snapName)
+		return nil, fmt.Errorf("cannot find app %q in %q", appName, snapName)
 	}
-
-	return runSnapConfine(info, app.SecurityTag(), snapApp, command, args)
+	return app, nil
 }
 
-func snapRunHook(snapName, hookName, revision string) error {
+func findHook(snapName, hookName, revision string) (*snap.HookInfo, error) {
 	info, err := getSnapInfo(snapName, revision)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	hook := info.Hooks[hookName]
 	if hook == nil {
-		return fmt.Errorf("cannot find hook %q in %q", hookName, snapName)
+		return nil, fmt.Errorf("cannot find hook %q in %q", hookName, snapName)
 	}
+	return hook, nil
+}
 
-	hookBinary := filepath.Join(info.HooksDir(), hook.Name)
+func snapRunApp(snapApp, command string, args []string) error {
+	app, err := findApp(snapApp)
+	if err != nil {
+		return err
+	}
 
-	return runSnapConfine(info, hook.SecurityTag(), hookBinary, "", nil)
+	return runSnapConfine(app.Snap, app.SecurityTag(), snapApp, command, args)
 }
 
-func runSnapConfine(info *snap.Info, securityTag, binary, command string, args []string) error {
-	if err := createUserDataDirs(info); err != nil {
-		logger.Noticef("WARNING: cannot create user data directory: %s", err)
+func snapRunHook(snapName, hookName, revision string) error {
+	hook, err := findHook(snapName, hookName, revision)
+	if err != nil {
+		return err
 	}
 
+	hookBinary := filepath.Join(hook.Snap.HooksDir(), hook.Name)
+
+	return runSnapConfine(hook.Snap, hook.SecurityTag(), hookBinary, "", nil)
+}
+
+// snapConfineCommand returns the command line running binary, or the
+// given command of it, confined under the given security tag.
+func snapConfineCommand(securityTag, binary, command string, args []string) []string {
 	cmd := []string{
 		"/usr/bin/ubuntu-core-launcher",
 		securityTag,
@@ -187,9 +213,97 @@ func runSnapConfine(info *snap.Info, securityTag, binary, command string, args [
 		cmd = append(cmd, "--command="+command)
 	}
 
-	cmd = append(cmd, args...)
+	return append(cmd, args...)
+}
+
+func runSnapConfine(info *snap.Info, securityTag, binary, command string, args []string) error {
+	if err := createUserDataDirs(info); err != nil {
+		logger.Noticef("WARNING: cannot create user data directory: %s", err)
+	}
 
+	cmd := snapConfineCommand(securityTag, binary, command, args)
 	env := append(os.Environ(), snapExecEnv(info)...)
 
 	return syscallExec(cmd[0], cmd, env)
 }
+
+// runEnv describes the environment an app or hook of a snap runs with,
+// as printed by snap run --print-env.
+type runEnv struct {
+	Snap     string        `json:"snap"`
+	App      string        `json:"app,omitempty"`
+	Hook     string        `json:"hook,omitempty"`
+	Revision snap.Revision `json:"revision"`
+	// SecurityTag names the AppArmor and seccomp profiles of the app
+	// or hook, and the systemd unit of a daemon.
+	SecurityTag     string `json:"security-tag"`
+	AppArmorProfile string `json:"apparmor-profile"`
+	SeccompProfile  string `json:"seccomp-profile"`
+	// Cgroup is only set for daemons, which run in the cgroup of
+	// their systemd service.
+	Cgroup      string            `json:"cgroup,omitempty"`
+	Command     []string          `json:"command"`
+	Environment map[string]string `json:"environment"`
+}
+
+func newRunEnv(info *snap.Info, securityTag string, cmd []string) *runEnv {
+	renv := &runEnv{
+		Snap:            info.Name(),
+		Revision:        info.Revision,
+		SecurityTag:     securityTag,
+		AppArmorProfile: filepath.Join(dirs.SnapAppArmorDir, securityTag),
+		SeccompProfile:  filepath.Join(dirs.SnapSeccompDir, securityTag),
+		Command:         cmd,
+		Environment:     make(map[string]string),
+	}
+	for _, kv := range snapExecEnv(info) {
+		if i := strings.IndexRune(kv, '='); i > 0 {
+			renv.Environment[kv[:i]] = kv[i+1:]
+		}
+	}
+	return renv
+}
+
+func printRunEnv(renv *runEnv) error {
+	bytes, err := json.MarshalIndent(renv, "", "\t")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(Stdout, string(bytes))
+	return nil
+}
+
+func snapPrintAppEnv(snapApp, command string) error {
+	app, err := findApp(snapApp)
+	if err != nil {
+		return err
+	}
+
+	renv := newRunEnv(app.Snap, app.SecurityTag(), snapConfineCommand(app.SecurityTag(), snapApp, command, nil))
+	renv.App = app.Name
+	if app.Daemon != "" {
+		renv.Cgroup = "/system.slice/" + filepath.Base(app.ServiceFile())
+	}
+	// as set by snap-exec, see AppInfo.Env
+	for k, v := range app.Snap.Environment {
+		renv.Environment[k] = v
+	}
+	for k, v := range app.Environment {
+		renv.Environment[k] = v
+	}
+
+	return printRunEnv(renv)
+}
+
+func snapPrintHookEnv(snapName, hookName, revision string) error {
+	hook, err := findHook(snapName, hookName, revision)
+	if err != nil {
+		return err
+	}
+
+	hookBinary := filepath.Join(hook.Snap.HooksDir(), hook.Name)
+	renv := newRunEnv(hook.Snap, hook.SecurityTag(), snapConfineCommand(hook.SecurityTag(), hookBinary, "", nil))
+	renv.Hook = hook.Name
+
+	return printRunEnv(renv)
+}
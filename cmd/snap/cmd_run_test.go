@@ -21,6 +21,7 @@
 package main_test
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"os/user"
@@ -63,6 +64,10 @@ func (s *SnapSuite) TestInvalidParameters(c *check.C) {
 	invalidParameters = []string{"run", "snap-name", "--hook=hook-name", "foo", "bar"}
 	_, err = snaprun.Parser().ParseArgs(invalidParameters)
 	c.Check(err, check.ErrorMatches, ".*too many arguments for hook \"hook-name\": foo bar.*")
+
+	invalidParameters = []string{"run", "--print-env", "snap-name.app", "foo", "bar"}
+	_, err = snaprun.Parser().ParseArgs(invalidParameters)
+	c.Check(err, check.ErrorMatches, ".*too many arguments for --print-env: foo bar.*")
 }
 
 func (s *SnapSuite) TestSnapRunSnapExecEnv(c *check.C) {
@@ -79,7 +84,7 @@ func (s *SnapSuite) TestSnapRunSnapExecEnv(c *check.C) {
 		"SNAP=/snap/snapname/42",
 		fmt.Sprintf("SNAP_ARCH=%s", arch.UbuntuArchitecture()),
 		"SNAP_DATA=/var/snap/snapname/42",
-		"SNAP_LIBRARY_PATH=/var/lib/snapd/lib/gl:",
+		"SNAP_LIBRARY_PATH=/var/lib/snapd/lib/gl:/var/lib/snapd/lib/gl32:",
 		"SNAP_NAME=snapname",
 		"SNAP_REVISION=42",
 		fmt.Sprintf("SNAP_USER_DATA=%s/snap/snapname/42", usr.HomeDir),
@@ -257,6 +262,95 @@ func (s *SnapSuite) TestSnapRunHookInvalidRevisionIntegration(c *check.C) {
 	c.Check(err, check.ErrorMatches, "invalid snap revision: \"invalid\"")
 }
 
+func (s *SnapSuite) TestSnapRunPrintEnvApp(c *check.C) {
+	// mock installed snap
+	dirs.SetRootDir(c.MkDir())
+	defer func() { dirs.SetRootDir("/") }()
+
+	snaptest.MockSnap(c, `name: snapname
+version: 1.0
+environment:
+ LANG: C
+ PORT: "80"
+apps:
+ app:
+  command: run-app
+  daemon: simple
+  environment:
+   PORT: "8080"
+`, &snap.SideInfo{
+		Revision: snap.R(42),
+	})
+
+	// and mock the server
+	s.mockServer(c)
+
+	restorer := snaprun.MockSyscallExec(func(arg0 string, args []string, envv []string) error {
+		c.Fatalf("nothing should be run")
+		return nil
+	})
+	defer restorer()
+
+	rest, err := snaprun.Parser().ParseArgs([]string{"run", "--print-env", "snapname.app"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+
+	var renv map[string]interface{}
+	c.Assert(json.Unmarshal([]byte(s.Stdout()), &renv), check.IsNil)
+	c.Check(renv["snap"], check.Equals, "snapname")
+	c.Check(renv["app"], check.Equals, "app")
+	c.Check(renv["revision"], check.Equals, "42")
+	c.Check(renv["security-tag"], check.Equals, "snap.snapname.app")
+	c.Check(renv["apparmor-profile"], check.Equals, filepath.Join(dirs.SnapAppArmorDir, "snap.snapname.app"))
+	c.Check(renv["seccomp-profile"], check.Equals, filepath.Join(dirs.SnapSeccompDir, "snap.snapname.app"))
+	c.Check(renv["cgroup"], check.Equals, "/system.slice/snap.snapname.app.service")
+	c.Check(renv["command"], check.DeepEquals, []interface{}{
+		"/usr/bin/ubuntu-core-launcher",
+		"snap.snapname.app",
+		"snap.snapname.app",
+		"/usr/lib/snapd/snap-exec",
+		"snapname.app"})
+	env := renv["environment"].(map[string]interface{})
+	c.Check(env["SNAP"], check.Equals, filepath.Join(dirs.GlobalRootDir, "/snap/snapname/42"))
+	c.Check(env["SNAP_REVISION"], check.Equals, "42")
+	c.Check(env["LANG"], check.Equals, "C")
+	c.Check(env["PORT"], check.Equals, "8080")
+}
+
+func (s *SnapSuite) TestSnapRunPrintEnvHook(c *check.C) {
+	// mock installed snap
+	dirs.SetRootDir(c.MkDir())
+	defer func() { dirs.SetRootDir("/") }()
+
+	snaptest.MockSnap(c, string(mockYaml), &snap.SideInfo{
+		Revision: snap.R(41),
+	})
+
+	restorer := snaprun.MockSyscallExec(func(arg0 string, args []string, envv []string) error {
+		c.Fatalf("nothing should be run")
+		return nil
+	})
+	defer restorer()
+
+	// the revision is given, so no need to ask snapd
+	_, err := snaprun.Parser().ParseArgs([]string{"run", "--print-env", "--hook=hook-name", "-r=41", "snapname"})
+	c.Assert(err, check.IsNil)
+
+	var renv map[string]interface{}
+	c.Assert(json.Unmarshal([]byte(s.Stdout()), &renv), check.IsNil)
+	c.Check(renv["hook"], check.Equals, "hook-name")
+	c.Check(renv["revision"], check.Equals, "41")
+	c.Check(renv["security-tag"], check.Equals, "snap.snapname.hook.hook-name")
+	c.Check(renv["cgroup"], check.IsNil)
+	c.Check(renv["command"], check.DeepEquals, []interface{}{
+		"/usr/bin/ubuntu-core-launcher",
+		"snap.snapname.hook.hook-name",
+		"snap.snapname.hook.hook-name",
+		"/usr/lib/snapd/snap-exec",
+		filepath.Join(dirs.GlobalRootDir, "/snap/snapname/41/meta/hooks/hook-name")})
+	c.Check(renv["environment"].(map[string]interface{})["SNAP_REVISION"], check.Equals, "41")
+}
+
 func (s *SnapSuite) mockServer(c *check.C) {
 	n := 0
 	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
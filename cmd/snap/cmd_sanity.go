@@ -0,0 +1,270 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/snap"
+)
+
+var shortSanityHelp = i18n.G("Check the consistency of installed snaps")
+var longSanityHelp = i18n.G(`
+The sanity command runs a battery of consistency checks on the installed
+snaps: that the current symlinks point at installed revisions, that the
+generated wrappers and service units exist and reference existing
+commands, and that installed framework policy belongs to installed snaps.
+`)
+
+type cmdSanity struct {
+	JSON bool `long:"json" description:"print the problems found as JSON"`
+}
+
+func init() {
+	addDebugCommand("sanity", shortSanityHelp, longSanityHelp, func() flags.Commander {
+		return &cmdSanity{}
+	})
+}
+
+// sanityProblem describes an inconsistency found by a sanity check.
+type sanityProblem struct {
+	Check   string `json:"check"`
+	Snap    string `json:"snap,omitempty"`
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+type sanityCheck struct {
+	name  string
+	check func(snaps []*client.Snap, infos map[string]*snap.Info) ([]sanityProblem, error)
+}
+
+var sanityChecks = []sanityCheck{
+	{"current-symlinks", checkCurrentSymlinks},
+	{"wrappers", checkWrappers},
+	{"service-units", checkServiceUnits},
+	{"framework-policy", checkFrameworkPolicy},
+}
+
+func checkCurrentSymlink(name, link string, rev snap.Revision) []sanityProblem {
+	target, err := os.Readlink(link)
+	if err != nil {
+		return []sanityProblem{{Snap: name, Path: link, Message: fmt.Sprintf("cannot read symlink: %v", err)}}
+	}
+	if target != rev.String() {
+		return []sanityProblem{{Snap: name, Path: link, Message: fmt.Sprintf("points at %q instead of the current revision %s", target, rev)}}
+	}
+	if _, err := os.Stat(link); err != nil {
+		return []sanityProblem{{Snap: name, Path: link, Message: fmt.Sprintf("points at missing revision %s", rev)}}
+	}
+	return nil
+}
+
+func checkCurrentSymlinks(snaps []*client.Snap, infos map[string]*snap.Info) ([]sanityProblem, error) {
+	var problems []sanityProblem
+	for _, sn := range snaps {
+		if sn.Status != client.StatusActive {
+			continue
+		}
+		mountDir := snap.MountDir(sn.Name, sn.Revision)
+		problems = append(problems, checkCurrentSymlink(sn.Name, filepath.Join(mountDir, "..", "current"), sn.Revision)...)
+		dataDir := filepath.Join(dirs.SnapDataDir, sn.Name)
+		problems = append(problems, checkCurrentSymlink(sn.Name, filepath.Join(dataDir, "current"), sn.Revision)...)
+	}
+	return problems, nil
+}
+
+func checkWrappers(snaps []*client.Snap, infos map[string]*snap.Info) ([]sanityProblem, error) {
+	var problems []sanityProblem
+	known := make(map[string]bool)
+	for _, info := range infos {
+		for _, app := range info.Apps {
+			if app.Daemon != "" {
+				continue
+			}
+			known[app.WrapperPath()] = true
+			if _, err := os.Stat(app.WrapperPath()); err != nil {
+				problems = append(problems, sanityProblem{Snap: info.Name(), Path: app.WrapperPath(), Message: "missing wrapper"})
+			}
+		}
+	}
+
+	wrappers, _ := filepath.Glob(filepath.Join(dirs.SnapBinariesDir, "*"))
+	for _, wrapper := range wrappers {
+		if !known[wrapper] {
+			problems = append(problems, sanityProblem{Path: wrapper, Message: "wrapper does not belong to any installed app"})
+		}
+	}
+	return problems, nil
+}
+
+// unitCommand returns the command the given service unit runs, if any.
+func unitCommand(unit string) (string, error) {
+	f, err := os.Open(unit)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "ExecStart=") {
+			continue
+		}
+		// ExecStart=/usr/bin/ubuntu-core-launcher <tag> <tag> <command> [args...]
+		fields := strings.Fields(strings.TrimPrefix(line, "ExecStart="))
+		if len(fields) < 4 {
+			return "", nil
+		}
+		return fields[3], nil
+	}
+	return "", scanner.Err()
+}
+
+func checkServiceUnits(snaps []*client.Snap, infos map[string]*snap.Info) ([]sanityProblem, error) {
+	var problems []sanityProblem
+	known := make(map[string]bool)
+	for _, info := range infos {
+		for _, app := range info.Apps {
+			if app.Daemon == "" {
+				continue
+			}
+			known[app.ServiceFile()] = true
+			if _, err := os.Stat(app.ServiceFile()); err != nil {
+				problems = append(problems, sanityProblem{Snap: info.Name(), Path: app.ServiceFile(), Message: "missing service unit"})
+			}
+		}
+	}
+
+	units, _ := filepath.Glob(filepath.Join(dirs.SnapServicesDir, "snap.*.service"))
+	for _, unit := range units {
+		if !known[unit] {
+			problems = append(problems, sanityProblem{Path: unit, Message: "service unit does not belong to any installed app"})
+		}
+		command, err := unitCommand(unit)
+		if err != nil {
+			problems = append(problems, sanityProblem{Path: unit, Message: fmt.Sprintf("cannot read service unit: %v", err)})
+			continue
+		}
+		if command == "" {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dirs.GlobalRootDir, command)); err != nil {
+			problems = append(problems, sanityProblem{Path: unit, Message: fmt.Sprintf("references missing command %q", command)})
+		}
+	}
+	return problems, nil
+}
+
+// checkFrameworkPolicy asks snapd for the framework policy files of the
+// snaps that are not installed.
+func checkFrameworkPolicy(snaps []*client.Snap, infos map[string]*snap.Info) ([]sanityProblem, error) {
+	files, err := Client().StrayPolicy()
+	if err != nil {
+		return nil, err
+	}
+	var problems []sanityProblem
+	for _, file := range files {
+		problems = append(problems, sanityProblem{Snap: file.Snap, Path: file.Path, Message: "policy file belongs to a snap that is not installed"})
+	}
+	return problems, nil
+}
+
+func (x *cmdSanity) Execute(args []string) error {
+	snaps, err := Client().List(nil)
+	if err != nil {
+		return err
+	}
+
+	// only the active revisions have wrappers and units
+	infos := make(map[string]*snap.Info)
+	var problems []sanityProblem
+	for _, sn := range snaps {
+		if sn.Status != client.StatusActive {
+			continue
+		}
+		info, err := snap.ReadInfo(sn.Name, &snap.SideInfo{Revision: sn.Revision})
+		if err != nil {
+			problems = append(problems, sanityProblem{Check: "snap-info", Snap: sn.Name, Path: snap.MountDir(sn.Name, sn.Revision), Message: err.Error()})
+			continue
+		}
+		infos[sn.Name] = info
+	}
+
+	for _, check := range sanityChecks {
+		found, err := check.check(snaps, infos)
+		if err != nil {
+			return err
+		}
+		sort.Sort(problemsByPath(found))
+		for i := range found {
+			found[i].Check = check.name
+		}
+		problems = append(problems, found...)
+	}
+
+	if x.JSON {
+		if problems == nil {
+			problems = []sanityProblem{}
+		}
+		enc := json.NewEncoder(Stdout)
+		if err := enc.Encode(map[string]interface{}{"problems": problems}); err != nil {
+			return err
+		}
+	} else if len(problems) > 0 {
+		w := tabWriter()
+		fmt.Fprintf(w, i18n.G("Check\tSnap\tPath\tProblem\n"))
+		for _, p := range problems {
+			snapName := p.Snap
+			if snapName == "" {
+				snapName = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", p.Check, snapName, p.Path, p.Message)
+		}
+		w.Flush()
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf(i18n.G("found %d problems"), len(problems))
+	}
+	if !x.JSON {
+		fmt.Fprintln(Stdout, i18n.G("No problems found."))
+	}
+
+	return nil
+}
+
+type problemsByPath []sanityProblem
+
+func (ps problemsByPath) Len() int           { return len(ps) }
+func (ps problemsByPath) Less(i, j int) bool { return ps[i].Path < ps[j].Path }
+func (ps problemsByPath) Swap(i, j int)      { ps[i], ps[j] = ps[j], ps[i] }
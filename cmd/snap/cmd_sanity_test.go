@@ -0,0 +1,143 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+	"github.com/snapcore/snapd/dirs"
+	snapinfo "github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+)
+
+const sanityYaml = `name: foo
+version: 1.0
+apps:
+ svc:
+  command: svc
+  daemon: simple
+ cli:
+  command: cli
+`
+
+// mockSaneSnap mocks an installed snap without problems, with snapd
+// answering with the given stray framework policy files.
+func (s *SnapSuite) mockSaneSnap(c *check.C, strayPolicy string) {
+	info := snaptest.MockSnap(c, sanityYaml, &snapinfo.SideInfo{Revision: snapinfo.R(7)})
+	c.Assert(os.Symlink("7", filepath.Join(info.MountDir(), "..", "current")), check.IsNil)
+	c.Assert(os.MkdirAll(info.DataDir(), 0755), check.IsNil)
+	c.Assert(os.Symlink("7", filepath.Join(info.DataDir(), "..", "current")), check.IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(info.MountDir(), "svc"), nil, 0755), check.IsNil)
+
+	c.Assert(os.MkdirAll(dirs.SnapBinariesDir, 0755), check.IsNil)
+	c.Assert(ioutil.WriteFile(info.Apps["cli"].WrapperPath(), nil, 0755), check.IsNil)
+	c.Assert(os.MkdirAll(dirs.SnapServicesDir, 0755), check.IsNil)
+	unit := "[Service]\nExecStart=/usr/bin/ubuntu-core-launcher snap.foo.svc snap.foo.svc /snap/foo/7/svc\n"
+	c.Assert(ioutil.WriteFile(info.Apps["svc"].ServiceFile(), []byte(unit), 0644), check.IsNil)
+
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		switch r.URL.Path {
+		case "/v2/snaps":
+			fmt.Fprintln(w, `{"type": "sync", "result": [{"name": "foo", "status": "active", "version": "1.0", "revision": 7}]}`)
+		case "/v2/debug/stray-policy":
+			fmt.Fprintf(w, `{"type": "sync", "result": %s}`+"\n", strayPolicy)
+		default:
+			c.Fatalf("unexpected path %q", r.URL.Path)
+		}
+	})
+}
+
+func (s *SnapSuite) TestSanityNoProblems(c *check.C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("/")
+	s.mockSaneSnap(c, "[]")
+
+	rest, err := snap.Parser().ParseArgs([]string{"debug", "sanity"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Equals, "No problems found.\n")
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *SnapSuite) TestSanityNoProblemsJSON(c *check.C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("/")
+	s.mockSaneSnap(c, "[]")
+
+	_, err := snap.Parser().ParseArgs([]string{"debug", "sanity", "--json"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, `{"problems":[]}`+"\n")
+}
+
+func (s *SnapSuite) TestSanityProblems(c *check.C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("/")
+	// policy of a snap that is gone
+	s.mockSaneSnap(c, `[{"snap": "bar", "path": "/var/lib/snappy/seccomp/templates/bar_tmpl"}]`)
+
+	// dangling data symlink
+	dataCurrent := filepath.Join(dirs.SnapDataDir, "foo", "current")
+	c.Assert(os.Remove(dataCurrent), check.IsNil)
+	c.Assert(os.Symlink("6", dataCurrent), check.IsNil)
+	// missing wrapper and a stale one
+	c.Assert(os.Remove(filepath.Join(dirs.SnapBinariesDir, "foo.cli")), check.IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dirs.SnapBinariesDir, "bar"), nil, 0755), check.IsNil)
+	// unit referencing a missing command
+	c.Assert(os.Remove(filepath.Join(dirs.SnapSnapsDir, "foo", "7", "svc")), check.IsNil)
+
+	_, err := snap.Parser().ParseArgs([]string{"debug", "sanity"})
+	c.Assert(err, check.ErrorMatches, "found 5 problems")
+
+	// the column widths depend on the temporary root, so compare fields
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(s.Stdout()), "\n") {
+		lines = append(lines, strings.Join(strings.Fields(line), " "))
+	}
+	root := dirs.GlobalRootDir
+	c.Check(lines, check.DeepEquals, []string{
+		`Check Snap Path Problem`,
+		`current-symlinks foo ` + root + `/var/snap/foo/current points at "6" instead of the current revision 7`,
+		`wrappers - ` + root + `/snap/bin/bar wrapper does not belong to any installed app`,
+		`wrappers foo ` + root + `/snap/bin/foo.cli missing wrapper`,
+		`service-units - ` + root + `/etc/systemd/system/snap.foo.svc.service references missing command "/snap/foo/7/svc"`,
+		`framework-policy bar /var/lib/snappy/seccomp/templates/bar_tmpl policy file belongs to a snap that is not installed`,
+	})
+}
+
+func (s *SnapSuite) TestSanityProblemsJSON(c *check.C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("/")
+	s.mockSaneSnap(c, "[]")
+
+	c.Assert(os.Remove(filepath.Join(dirs.SnapBinariesDir, "foo.cli")), check.IsNil)
+
+	_, err := snap.Parser().ParseArgs([]string{"debug", "sanity", "--json"})
+	c.Assert(err, check.ErrorMatches, "found 1 problems")
+	c.Check(s.Stdout(), check.Equals, fmt.Sprintf(`{"problems":[{"check":"wrappers","snap":"foo","path":"%s","message":"missing wrapper"}]}`+"\n", filepath.Join(dirs.SnapBinariesDir, "foo.cli")))
+}
@@ -0,0 +1,65 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/snapcore/snapd/i18n"
+
+	"github.com/jessevdk/go-flags"
+)
+
+var shortSBOMHelp = i18n.G("Print a software bill of materials for installed snaps")
+var longSBOMHelp = i18n.G(`
+The sbom command prints a software bill of materials describing the
+installed snaps, or the given ones, as JSON in the SPDX or CycloneDX
+format. Each snap is listed with its version, revision, publisher, the
+digests of its file, the snap it runs on and the assertions vouching for
+it.
+`)
+
+type cmdSBOM struct {
+	Format     string `long:"format" description:"format of the bill of materials" choice:"spdx" choice:"cyclonedx" default:"spdx"`
+	Positional struct {
+		Snaps []string `positional-arg-name:"<snap>"`
+	} `positional-args:"yes"`
+}
+
+func init() {
+	addCommand("sbom", shortSBOMHelp, longSBOMHelp, func() flags.Commander { return &cmdSBOM{} })
+}
+
+func (x *cmdSBOM) Execute(args []string) error {
+	doc, err := Client().SBOM(x.Format, x.Positional.Snaps)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, doc, "", "  "); err != nil {
+		return fmt.Errorf("cannot format bill of materials: %v", err)
+	}
+	buf.WriteByte('\n')
+	_, err = buf.WriteTo(Stdout)
+	return err
+}
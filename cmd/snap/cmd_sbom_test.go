@@ -0,0 +1,63 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) TestSBOM(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		c.Check(r.URL.Path, check.Equals, "/v2/sbom")
+		c.Check(r.URL.Query().Get("format"), check.Equals, "cyclonedx")
+		c.Check(r.URL.Query().Get("snaps"), check.Equals, "foo,bar")
+		fmt.Fprintln(w, `{"type": "sync", "result": {"bomFormat": "CycloneDX", "components": []}}`)
+		n++
+	})
+	rest, err := snap.Parser().ParseArgs([]string{"sbom", "--format=cyclonedx", "foo", "bar"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(n, check.Equals, 1)
+	c.Check(s.Stdout(), check.Equals, "{\n  \"bomFormat\": \"CycloneDX\",\n  \"components\": []\n}\n")
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *SnapSuite) TestSBOMDefaultFormat(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Query().Get("format"), check.Equals, "spdx")
+		c.Check(r.URL.Query().Get("snaps"), check.Equals, "")
+		fmt.Fprintln(w, `{"type": "sync", "result": {"spdxVersion": "SPDX-2.2"}}`)
+	})
+	_, err := snap.Parser().ParseArgs([]string{"sbom"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "{\n  \"spdxVersion\": \"SPDX-2.2\"\n}\n")
+}
+
+func (s *SnapSuite) TestSBOMBadFormat(c *check.C) {
+	_, err := snap.Parser().ParseArgs([]string{"sbom", "--format=xml"})
+	c.Assert(err, check.ErrorMatches, `Invalid value .xml. for option .--format.*`)
+}
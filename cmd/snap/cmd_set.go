@@ -0,0 +1,131 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/snap"
+)
+
+var shortSetHelp = i18n.G("Changes configuration options")
+var longSetHelp = i18n.G(`
+The set command changes the provided configuration options as requested.
+
+    $ snap set snap-name username=frank password=$PASSWORD
+
+Values that are valid JSON are used as such, so nested values may be
+set with key.subkey={...}, and a value of null unsets the option. With
+-t values must be valid JSON, so that for instance a mistyped number
+is an error rather than a string:
+
+    $ snap set -t snap-name server.port=8080 server.hosts='["a", "b"]'
+
+Options can also be read from a YAML or JSON file, each value within
+it being set on its own, before the ones given on the command line:
+
+    $ snap set snap-name --from-file config.yaml
+
+All the options are changed together or none of them is. If the snap
+has a configure hook it is run with the changes made, and they are
+rolled back if it fails.
+
+The extra CA certificates trusted by the store client, for instance
+behind a TLS intercepting proxy or with an on-premises store, are set
+as options of the core snap:
+
+    $ snap set core store-certs.corp="$(cat corp-ca.pem)"
+`)
+
+type cmdSet struct {
+	Typed    bool   `short:"t" description:"parse the values strictly as JSON"`
+	FromFile string `long:"from-file" description:"read the options from a YAML or JSON file"`
+
+	Positional struct {
+		Snap       string   `positional-arg-name:"<snap name>" description:"the snap to configure (e.g. hello-world)"`
+		ConfValues []string `positional-arg-name:"<conf value>" description:"configuration value (key=value)"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func init() {
+	addCommand("set", shortSetHelp, longSetHelp, func() flags.Commander {
+		return &cmdSet{}
+	})
+}
+
+// parseConfValues parses key=value pairs, using the value as JSON where
+// possible and as a plain string otherwise, or only as JSON if typed.
+func parseConfValues(confValues []string, typed bool) (map[string]interface{}, error) {
+	patchValues := make(map[string]interface{})
+	for _, patchValue := range confValues {
+		parts := strings.SplitN(patchValue, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf(i18n.G("invalid configuration: %q (want key=value)"), patchValue)
+		}
+		var value interface{}
+		if err := json.Unmarshal([]byte(parts[1]), &value); err != nil {
+			if typed {
+				return nil, fmt.Errorf(i18n.G("invalid configuration: %q (want key=<JSON value>)"), patchValue)
+			}
+			// not valid json, use it as a string
+			value = parts[1]
+		}
+		patchValues[parts[0]] = value
+	}
+	return patchValues, nil
+}
+
+func (x *cmdSet) Execute(args []string) error {
+	if len(x.Positional.ConfValues) == 0 && x.FromFile == "" {
+		return fmt.Errorf(i18n.G("set needs at least one key=value, or --from-file"))
+	}
+
+	patchValues, err := parseConfValues(x.Positional.ConfValues, x.Typed)
+	if err != nil {
+		return err
+	}
+	if x.FromFile != "" {
+		fileValues, err := snap.ReadConfigFile(x.FromFile)
+		if err != nil {
+			return err
+		}
+		for key, value := range fileValues {
+			if _, ok := patchValues[key]; !ok {
+				patchValues[key] = value
+			}
+		}
+	}
+
+	cli := Client()
+	id, err := cli.SetConf(x.Positional.Snap, patchValues)
+	if err != nil {
+		return err
+	}
+	if id == "" {
+		return nil
+	}
+	_, err = wait(cli, id)
+	return err
+}
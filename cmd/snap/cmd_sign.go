@@ -0,0 +1,80 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/asserts/signtool"
+	"github.com/snapcore/snapd/i18n"
+)
+
+var shortSignHelp = i18n.G("Signs an assertion")
+var longSignHelp = i18n.G(`
+The sign command signs an assertion using the specified key, using the
+input for headers from a JSON mapping provided through stdin, the body
+of the assertion can be specified through a "body" pseudo-header. The
+key is one created with snap create-key.
+`)
+
+type cmdSign struct {
+	KeyName string `short:"k" default:"default" description:"name of the key to use, otherwise use the default key"`
+}
+
+func init() {
+	addCommand("sign", shortSignHelp, longSignHelp, func() flags.Commander {
+		return &cmdSign{}
+	})
+}
+
+func (x *cmdSign) Execute(args []string) error {
+	statement, err := ioutil.ReadAll(Stdin)
+	if err != nil {
+		return fmt.Errorf(i18n.G("cannot read assertion input: %v"), err)
+	}
+
+	keypairMgr, err := getKeypairManager()
+	if err != nil {
+		return err
+	}
+	privKey, err := keypairMgr.GetByName(x.KeyName)
+	if err != nil {
+		return err
+	}
+
+	signOpts := signtool.Options{
+		KeyID:     privKey.PublicKey().ID(),
+		Statement: statement,
+	}
+
+	encodedAssert, err := signtool.Sign(&signOpts, keypairMgr)
+	if err != nil {
+		return err
+	}
+
+	_, err = Stdout.Write(encodedAssert)
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"strings"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+const accountStatement = `{
+  "type": "account",
+  "authority-id": "user-id1",
+  "account-id": "user-id1",
+  "display-name": "User One",
+  "validation": "unproven",
+  "timestamp": "2016-10-01T12:00:00Z"
+}`
+
+func (s *SnapSuite) TestSignAndExportKey(c *check.C) {
+	homedir := s.mockGPGHome(c)
+	keypairMgr := asserts.NewGPGKeypairManager(homedir)
+	c.Assert(keypairMgr.Generate("", "default"), check.IsNil)
+	privKey, err := keypairMgr.GetByName("default")
+	c.Assert(err, check.IsNil)
+
+	s.stdin.WriteString(accountStatement)
+	_, err = snap.Parser().ParseArgs([]string{"sign"})
+	c.Assert(err, check.IsNil)
+
+	a, err := asserts.Decode(s.stdout.Bytes())
+	c.Assert(err, check.IsNil)
+	c.Check(a.Type(), check.Equals, asserts.AccountType)
+	c.Check(a.Header("display-name"), check.Equals, "User One")
+	_, sig := a.Signature()
+	c.Check(len(sig) > 0, check.Equals, true)
+
+	s.stdout.Reset()
+	_, err = snap.Parser().ParseArgs([]string{"export-key"})
+	c.Assert(err, check.IsNil)
+	encoded, err := asserts.EncodePublicKey(privKey.PublicKey())
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, string(encoded)+"\n")
+	c.Check(strings.HasPrefix(s.Stdout(), "openpgp "), check.Equals, true)
+}
+
+func (s *SnapSuite) TestSignMissingKey(c *check.C) {
+	s.mockGPGHome(c)
+
+	s.stdin.WriteString(accountStatement)
+	_, err := snap.Parser().ParseArgs([]string{"sign", "-k", "missing"})
+	c.Check(err, check.ErrorMatches, `cannot find key named "missing" in GPG keyring`)
+
+	_, err = snap.Parser().ParseArgs([]string{"export-key", "missing"})
+	c.Check(err, check.ErrorMatches, `cannot find key named "missing" in GPG keyring`)
+}
@@ -23,12 +23,12 @@ import (
 	"errors"
 	"fmt"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/i18n"
-	"github.com/snapcore/snapd/progress"
 
 	"github.com/jessevdk/go-flags"
 )
@@ -41,10 +41,12 @@ func lastLogStr(logs []string) string {
 }
 
 func wait(client *client.Client, id string) (*client.Change, error) {
-	pb := progress.NewTextProgress()
+	pb := newProgress()
 	defer func() {
 		pb.Finished()
-		fmt.Fprint(Stdout, "\n")
+		if !plainOutput() {
+			fmt.Fprint(Stdout, "\n")
+		}
 	}()
 
 	var lastID string
@@ -106,7 +108,14 @@ The install command installs the named snap in the system.
 `)
 
 var longRemoveHelp = i18n.G(`
-The remove command removes the named snap from the system.
+The remove command removes the named snaps from the system.
+
+Snaps are removed before the snaps providing the slots their plugs are
+connected to. Snaps connected to slots of the removed snaps lose their
+provider, and are reported; with --purge-dependents they are removed as well.
+
+If removing a snap fails, only that snap and the snaps it was to be removed
+after are kept; with --transactional, all of the snaps are kept.
 
 The snap's data is currently not removed; use purge for that. This behaviour
 will change before 16.04 is final.
@@ -114,6 +123,18 @@ will change before 16.04 is final.
 
 var longRefreshHelp = i18n.G(`
 The refresh command refreshes (updates) the named snap.
+
+With --to-spec, the snaps are instead brought to the exact revisions listed
+in the given refresh spec file, installing them if needed, and are then held
+there until refreshed again. The spec must be accompanied by a detached
+signature (the file name with .sig appended) from a key in the keyring of
+the device owner, /etc/snapd/refresh-spec.gpg, which snapd checks before
+acting on the spec. With --allow-unsigned, snapd accepts a spec without a
+signature, but only on devices without that keyring.
+
+With --offline, the snaps are refreshed without network to the revisions the
+store last offered whose files were already downloaded, all of those that
+can be if no snap is named.
 `)
 
 var longTryHelp = i18n.G(`
@@ -124,30 +145,49 @@ performed in snap.yaml will require reinstallation to go live.
 `)
 
 type cmdRemove struct {
-	Positional struct {
-		Snap string `positional-arg-name:"<snap>"`
+	PurgeDependents bool `long:"purge-dependents" description:"Remove as well the snaps connected to slots of the removed snaps"`
+	Transactional   bool `long:"transactional" description:"Keep all of the snaps if removing any of them fails"`
+	Positional      struct {
+		Snaps []string `positional-arg-name:"<snap>" required:"1"`
 	} `positional-args:"yes" required:"yes"`
 }
 
 func (x *cmdRemove) Execute([]string) error {
 	cli := Client()
-	name := x.Positional.Snap
-	changeID, err := cli.Remove(name, nil)
+	names := x.Positional.Snaps
+	opts := &client.SnapOptions{
+		PurgeDependents: x.PurgeDependents,
+		Transactional:   x.Transactional,
+	}
+	var changeID string
+	var err error
+	if len(names) == 1 {
+		changeID, err = cli.Remove(names[0], opts)
+	} else {
+		changeID, err = cli.RemoveMany(names, opts)
+	}
 	if err != nil {
 		return err
 	}
 
-	if _, err := wait(cli, changeID); err != nil {
+	chg, err := wait(cli, changeID)
+	if err != nil {
 		return err
 	}
+	var dependents []string
+	if chg.Get("dependents", &dependents) == nil && len(dependents) > 0 {
+		fmt.Fprintf(Stderr, i18n.G("WARNING: %s lost the provider of connected interfaces (use --purge-dependents to remove them too)\n"), strings.Join(dependents, ", "))
+	}
 	fmt.Fprintln(Stdout, "Done")
 	return nil
 }
 
 type cmdInstall struct {
-	Channel    string `long:"channel" description:"Install from this channel instead of the device's default"`
-	DevMode    bool   `long:"devmode" description:"Install the snap with non-enforcing security"`
-	Positional struct {
+	Channel       string `long:"channel" description:"Install from this channel instead of the device's default"`
+	DevMode       bool   `long:"devmode" description:"Install the snap with non-enforcing security"`
+	Arch          string `long:"arch" description:"Install the snap built for this architecture, e.g. arm64 on an arm64 kernel running an armhf userland"`
+	AcceptLicense bool   `long:"accept-license" description:"Accept the license of the snap, if it requires accepting it"`
+	Positional    struct {
 		Snap string `positional-arg-name:"<snap>"`
 	} `positional-args:"yes" required:"yes"`
 }
@@ -159,8 +199,14 @@ func (x *cmdInstall) Execute([]string) error {
 
 	cli := Client()
 	name := x.Positional.Snap
-	opts := &client.SnapOptions{Channel: x.Channel, DevMode: x.DevMode}
+	opts := &client.SnapOptions{Channel: x.Channel, DevMode: x.DevMode, Architecture: x.Arch}
+	if x.AcceptLicense {
+		opts.License = &client.License{Agreed: true}
+	}
 	if strings.Contains(name, "/") || strings.HasSuffix(name, ".snap") || strings.Contains(name, ".snap.") {
+		if x.Arch != "" {
+			return fmt.Errorf(i18n.G("cannot use --arch when installing from a file"))
+		}
 		installFromFile = true
 		changeID, err = cli.InstallPath(name, opts)
 	} else {
@@ -189,23 +235,27 @@ func (x *cmdInstall) Execute([]string) error {
 }
 
 type cmdRefresh struct {
-	List       bool   `long:"list" description:"show available snaps for refresh"`
-	Channel    string `long:"channel" description:"Refresh to the latest on this channel, and track this channel henceforth"`
-	Positional struct {
+	List          bool   `long:"list" description:"show available snaps for refresh"`
+	Channel       string `long:"channel" description:"Refresh to the latest on this channel, and track this channel henceforth"`
+	ToSpec        string `long:"to-spec" description:"Refresh to the exact revisions in this refresh spec file"`
+	AllowUnsigned bool   `long:"allow-unsigned" description:"Ask snapd to accept a refresh spec without a signature"`
+	UnholdRollout bool   `long:"unhold-rollout" description:"Refresh to revisions being released progressively even if the release does not include this device yet"`
+	Offline       bool   `long:"offline" description:"Refresh without network to the revisions already downloaded"`
+	Positional    struct {
 		Snap string `positional-arg-name:"<snap>"`
 	} `positional-args:"yes"`
 }
 
-func refreshAll() error {
+func refreshAll(unholdRollout bool) error {
 	// FIXME: move this to snapd instead and have a new refresh-all endpoint
 	cli := Client()
-	updates, _, err := cli.Find(&client.FindOptions{Refresh: true})
+	updates, _, err := cli.Find(&client.FindOptions{Refresh: true, UnholdRollout: unholdRollout})
 	if err != nil {
 		return fmt.Errorf("cannot list updates: %s", err)
 	}
 
 	for _, update := range updates {
-		changeID, err := cli.Refresh(update.Name, &client.SnapOptions{Channel: update.Channel})
+		changeID, err := cli.Refresh(update.Name, &client.SnapOptions{Channel: update.Channel, UnholdRollout: unholdRollout})
 		if err != nil {
 			return err
 		}
@@ -217,9 +267,9 @@ func refreshAll() error {
 	return listSnaps(nil)
 }
 
-func refreshOne(name, channel string) error {
+func refreshOne(name, channel string, unholdRollout bool) error {
 	cli := Client()
-	changeID, err := cli.Refresh(name, &client.SnapOptions{Channel: channel})
+	changeID, err := cli.Refresh(name, &client.SnapOptions{Channel: channel, UnholdRollout: unholdRollout})
 	if err != nil {
 		return err
 	}
@@ -231,16 +281,100 @@ func refreshOne(name, channel string) error {
 	return listSnaps([]string{name})
 }
 
+func refreshOffline(name string) error {
+	var names []string
+	if name != "" {
+		names = []string{name}
+	}
+
+	cli := Client()
+	changeID, err := cli.RefreshOffline(names)
+	if err != nil {
+		return err
+	}
+
+	if _, err := wait(cli, changeID); err != nil {
+		return err
+	}
+
+	return listSnaps(names)
+}
+
+// listRefresh shows the available updates without applying them, with
+// what is needed to review them first.
+func listRefresh() error {
+	cli := Client()
+	updates, _, err := cli.Find(&client.FindOptions{Refresh: true})
+	if err != nil {
+		return fmt.Errorf("cannot list updates: %s", err)
+	}
+
+	if len(updates) == 0 {
+		fmt.Fprintln(Stderr, i18n.G("All snaps up to date."))
+		return nil
+	}
+
+	sort.Sort(snapsByName(updates))
+
+	w := tabWriter()
+	defer w.Flush()
+
+	fmt.Fprintln(w, i18n.G("Name\tCurrent\tAvailable\tDownload\tReboot\tRelease notes"))
+
+	for _, update := range updates {
+		download := sizeString(update.DownloadSize)
+		if update.DeltaSize > 0 {
+			download = fmt.Sprintf(i18n.G("%s (delta %s)"), download, sizeString(update.DeltaSize))
+		}
+		reboot := i18n.G("no")
+		if update.RebootRequired {
+			reboot = i18n.G("yes")
+		}
+		notes := update.ReleaseNotesURL
+		if notes == "" {
+			notes = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s (%s)\t%s (%s)\t%s\t%s\t%s\n", update.Name, update.CurrentVersion, update.CurrentRevision, update.Version, update.Revision, download, reboot, notes)
+	}
+
+	return nil
+}
+
+// sizeString formats a size in bytes for humans.
+func sizeString(size int64) string {
+	const units = "kMGT"
+	if size < 1000 {
+		return fmt.Sprintf("%dB", size)
+	}
+	n := float64(size)
+	i := -1
+	for n >= 1000 && i < len(units)-1 {
+		n /= 1000
+		i++
+	}
+	return fmt.Sprintf("%.1f%cB", n, units[i])
+}
+
 func (x *cmdRefresh) Execute([]string) error {
 	if x.List {
-		return findSnaps(&client.FindOptions{
-			Refresh: true,
-		})
+		return listRefresh()
+	}
+	if x.ToSpec != "" {
+		if x.Positional.Snap != "" || x.Channel != "" {
+			return fmt.Errorf(i18n.G("cannot use --to-spec with a snap name or --channel"))
+		}
+		return refreshToSpec(x.ToSpec, x.AllowUnsigned)
+	}
+	if x.Offline {
+		if x.Channel != "" || x.UnholdRollout {
+			return fmt.Errorf(i18n.G("cannot use --offline with --channel or --unhold-rollout"))
+		}
+		return refreshOffline(x.Positional.Snap)
 	}
 	if x.Positional.Snap == "" {
-		return refreshAll()
+		return refreshAll(x.UnholdRollout)
 	}
-	return refreshOne(x.Positional.Snap, x.Channel)
+	return refreshOne(x.Positional.Snap, x.Channel, x.UnholdRollout)
 }
 
 type cmdTry struct {
@@ -125,6 +125,175 @@ func (s *SnapOpSuite) TestInstallDevMode(c *check.C) {
 	c.Check(s.srv.n, check.Equals, s.srv.total)
 }
 
+func (s *SnapOpSuite) TestInstallAcceptLicense(c *check.C) {
+	s.srv.checker = func(r *http.Request) {
+		c.Check(r.URL.Path, check.Equals, "/v2/snaps/foo")
+		c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+			"action":  "install",
+			"name":    "foo",
+			"license": map[string]interface{}{"agreed": true},
+		})
+	}
+
+	s.RedirectClientToTestServer(s.srv.handle)
+	rest, err := snap.Parser().ParseArgs([]string{"install", "--accept-license", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Matches, `(?sm).*foo\s+1.0\s+42\s+bar.*`)
+	// ensure that the fake server api was actually hit
+	c.Check(s.srv.n, check.Equals, s.srv.total)
+}
+
+func (s *SnapOpSuite) TestInstallArch(c *check.C) {
+	s.srv.checker = func(r *http.Request) {
+		c.Check(r.URL.Path, check.Equals, "/v2/snaps/foo")
+		c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+			"action":       "install",
+			"name":         "foo",
+			"architecture": "arm64",
+		})
+	}
+
+	s.RedirectClientToTestServer(s.srv.handle)
+	rest, err := snap.Parser().ParseArgs([]string{"install", "--arch", "arm64", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Matches, `(?sm).*foo\s+1.0\s+42\s+bar.*`)
+	c.Check(s.Stderr(), check.Equals, "")
+	// ensure that the fake server api was actually hit
+	c.Check(s.srv.n, check.Equals, s.srv.total)
+}
+
+func (s *SnapOpSuite) TestInstallScreenReader(c *check.C) {
+	s.srv.checker = func(r *http.Request) {}
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		if s.srv.n != 1 {
+			s.srv.handle(w, r)
+			return
+		}
+		s.srv.n++
+		fmt.Fprintln(w, `{"type": "sync", "result": {"status": "Doing", "tasks": [{"id": "1", "summary": "Mount snap \"foo\"", "status": "Doing", "progress": {"done": 0, "total": 1}}]}}`)
+	})
+	rest, err := snap.Parser().ParseArgs([]string{"--screen-reader", "install", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Matches, `(?s)Mount snap "foo"\.\.\.\n.*foo\s+1.0\s+42\s+bar.*`)
+	c.Check(s.Stdout(), check.Not(check.Matches), "(?s).*[\\r\\x1b].*")
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(s.srv.n, check.Equals, s.srv.total)
+}
+
+func (s *SnapOpSuite) TestInstallArchFromFile(c *check.C) {
+	_, err := snap.Parser().ParseArgs([]string{"install", "--arch", "arm64", "./foo.snap"})
+	c.Assert(err, check.ErrorMatches, "cannot use --arch when installing from a file")
+}
+
+func (s *SnapOpSuite) TestRefreshUnholdRollout(c *check.C) {
+	s.srv.checker = func(r *http.Request) {
+		c.Check(r.URL.Path, check.Equals, "/v2/snaps/foo")
+		c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+			"action":         "refresh",
+			"name":           "foo",
+			"unhold-rollout": true,
+		})
+	}
+
+	s.RedirectClientToTestServer(s.srv.handle)
+	rest, err := snap.Parser().ParseArgs([]string{"refresh", "--unhold-rollout", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Matches, `(?sm).*foo\s+1.0\s+42\s+bar.*`)
+	c.Check(s.Stderr(), check.Equals, "")
+	// ensure that the fake server api was actually hit
+	c.Check(s.srv.n, check.Equals, s.srv.total)
+}
+
+func (s *SnapOpSuite) TestRefreshOffline(c *check.C) {
+	s.srv.checker = func(r *http.Request) {
+		c.Check(r.URL.Path, check.Equals, "/v2/snaps")
+		c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+			"action":  "refresh",
+			"snaps":   []interface{}{"foo"},
+			"offline": true,
+		})
+	}
+
+	s.RedirectClientToTestServer(s.srv.handle)
+	rest, err := snap.Parser().ParseArgs([]string{"refresh", "--offline", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Matches, `(?sm).*foo\s+1.0\s+42\s+bar.*`)
+	c.Check(s.Stderr(), check.Equals, "")
+	// ensure that the fake server api was actually hit
+	c.Check(s.srv.n, check.Equals, s.srv.total)
+}
+
+func (s *SnapOpSuite) TestRefreshOfflineWithChannel(c *check.C) {
+	_, err := snap.Parser().ParseArgs([]string{"refresh", "--offline", "--channel", "beta", "foo"})
+	c.Assert(err, check.ErrorMatches, "cannot use --offline with --channel or --unhold-rollout")
+}
+
+func (s *SnapOpSuite) testRemove(c *check.C, args []string, checker func(r *http.Request), data string) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "POST")
+			checker(r)
+			w.WriteHeader(http.StatusAccepted)
+			fmt.Fprintln(w, `{"type":"async", "change": "42", "status-code": 202}`)
+		case 1:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/changes/42")
+			fmt.Fprintf(w, `{"type": "sync", "result": {"ready": true, "status": "Done", "data": %s}}`+"\n", data)
+		default:
+			c.Fatalf("expected to get 2 requests, now on %d", n+1)
+		}
+		n++
+	})
+
+	rest, err := snap.Parser().ParseArgs(args)
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Matches, `(?sm).*Done$`)
+	c.Check(n, check.Equals, 2)
+}
+
+func (s *SnapOpSuite) TestRemove(c *check.C) {
+	s.testRemove(c, []string{"remove", "foo"}, func(r *http.Request) {
+		c.Check(r.URL.Path, check.Equals, "/v2/snaps/foo")
+		c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+			"action": "remove",
+			"name":   "foo",
+		})
+	}, `{"dependents": ["bar", "baz"]}`)
+	c.Check(s.Stderr(), check.Equals, "WARNING: bar, baz lost the provider of connected interfaces (use --purge-dependents to remove them too)\n")
+}
+
+func (s *SnapOpSuite) TestRemoveManyPurgeDependents(c *check.C) {
+	s.testRemove(c, []string{"remove", "--purge-dependents", "foo", "bar"}, func(r *http.Request) {
+		c.Check(r.URL.Path, check.Equals, "/v2/snaps")
+		c.Check(r.Header.Get("Content-Type"), check.Equals, "application/json")
+		c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+			"action":           "remove",
+			"snaps":            []interface{}{"foo", "bar"},
+			"purge-dependents": true,
+		})
+	}, `{}`)
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *SnapOpSuite) TestRemoveManyTransactional(c *check.C) {
+	s.testRemove(c, []string{"remove", "--transactional", "foo", "bar"}, func(r *http.Request) {
+		c.Check(r.URL.Path, check.Equals, "/v2/snaps")
+		c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+			"action":        "remove",
+			"snaps":         []interface{}{"foo", "bar"},
+			"transactional": true,
+		})
+	}, `{}`)
+}
+
 func (s *SnapOpSuite) TestInstallPath(c *check.C) {
 	s.srv.checker = func(r *http.Request) {
 		c.Check(r.URL.Path, check.Equals, "/v2/snaps")
@@ -183,7 +352,10 @@ func (s *SnapSuite) TestRefreshList(c *check.C) {
 			c.Check(r.Method, check.Equals, "GET")
 			c.Check(r.URL.Path, check.Equals, "/v2/find")
 			c.Check(r.URL.Query().Get("select"), check.Equals, "refresh")
-			fmt.Fprintln(w, `{"type": "sync", "result": [{"name": "foo", "status": "active", "version": "4.2update1", "developer": "bar", "revision":17,"summary":"some summary"}]}`)
+			fmt.Fprintln(w, `{"type": "sync", "result": [
+{"name": "foo", "status": "active", "version": "4.2update1", "developer": "bar", "revision": 17, "summary": "some summary", "current-version": "4.2", "current-revision": 16, "download-size": 2500000, "delta-size": 512000, "release-notes-url": "https://example.com/foo/17"},
+{"name": "core", "status": "active", "version": "16-2.1", "developer": "canonical", "revision": 90, "current-version": "16-2", "current-revision": 88, "download-size": 800, "reboot-required": true}
+]}`)
 		default:
 			c.Fatalf("expected to get 1 requests, now on %d", n+1)
 		}
@@ -193,14 +365,26 @@ func (s *SnapSuite) TestRefreshList(c *check.C) {
 	rest, err := snap.Parser().ParseArgs([]string{"refresh", "--list"})
 	c.Assert(err, check.IsNil)
 	c.Assert(rest, check.DeepEquals, []string{})
-	c.Check(s.Stdout(), check.Matches, `Name +Version +Developer +Notes +Summary
-foo +4.2update1 +bar +- +some summary
+	c.Check(s.Stdout(), check.Matches, `Name +Current +Available +Download +Reboot +Release notes
+core +16-2 \(88\) +16-2.1 \(90\) +800B +yes +-
+foo +4.2 \(16\) +4.2update1 \(17\) +2.5MB \(delta 512.0kB\) +no +https://example.com/foo/17
 `)
 	c.Check(s.Stderr(), check.Equals, "")
 	// ensure that the fake server api was actually hit
 	c.Check(n, check.Equals, 1)
 }
 
+func (s *SnapSuite) TestRefreshListNothing(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Query().Get("select"), check.Equals, "refresh")
+		fmt.Fprintln(w, `{"type": "sync", "result": []}`)
+	})
+	_, err := snap.Parser().ParseArgs([]string{"refresh", "--list"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "")
+	c.Check(s.Stderr(), check.Equals, "All snaps up to date.\n")
+}
+
 func (s *SnapOpSuite) runTryTest(c *check.C, devmode bool) {
 	// pass relative path to cmd
 	tryDir := "some-dir"
@@ -0,0 +1,74 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/i18n"
+)
+
+var shortTimeWarpHelp = i18n.G("Show the time bounds assertions are checked against")
+var longTimeWarpHelp = i18n.G(`
+The timewarp command shows the times snapd considers when checking the
+validity of assertions: the system clock, the latest time snapd has seen
+in the timestamps of assertions and persisted, the earliest time the current time can be as known from
+that and the timestamps of the assertions, and the resulting time
+assertions are checked against. Devices without a working real time
+clock can boot with a clock far in the past, in which case the earliest
+time is used instead.
+`)
+
+type cmdTimeWarp struct{}
+
+func init() {
+	addDebugCommand("timewarp", shortTimeWarpHelp, longTimeWarpHelp, func() flags.Commander {
+		return &cmdTimeWarp{}
+	})
+}
+
+func formatBound(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func (x *cmdTimeWarp) Execute(args []string) error {
+	tw, err := Client().TimeWarp()
+	if err != nil {
+		return err
+	}
+
+	w := tabWriter()
+	fmt.Fprintf(w, "clock:\t%s\n", formatBound(tw.Clock))
+	fmt.Fprintf(w, "max-seen-time:\t%s\n", formatBound(tw.MaxSeenTime))
+	fmt.Fprintf(w, "earliest-time:\t%s\n", formatBound(tw.EarliestTime))
+	fmt.Fprintf(w, "check-time:\t%s\n", formatBound(tw.CheckTime))
+	w.Flush()
+
+	if tw.ClockBehind {
+		fmt.Fprintf(Stdout, i18n.G("The system clock is %s behind the earliest time, assertions are checked against the latter.\n"), tw.EarliestTime.Sub(tw.Clock))
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) TestTimeWarp(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		c.Check(r.URL.Path, check.Equals, "/v2/debug/timewarp")
+		fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": {"clock": "2016-09-02T10:00:00Z", "max-seen-time": "2016-09-02T09:55:00Z", "earliest-time": "2016-09-02T09:55:00Z", "check-time": "2016-09-02T10:00:00Z"}}`)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"debug", "timewarp"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, `clock:          2016-09-02T10:00:00Z
+max-seen-time:  2016-09-02T09:55:00Z
+earliest-time:  2016-09-02T09:55:00Z
+check-time:     2016-09-02T10:00:00Z
+`)
+}
+
+func (s *SnapSuite) TestTimeWarpClockBehind(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": {"clock": "2016-09-02T08:00:00Z", "earliest-time": "2016-09-02T10:30:00Z", "check-time": "2016-09-02T10:30:00Z", "clock-behind": true}}`)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"debug", "timewarp"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, `clock:          2016-09-02T08:00:00Z
+max-seen-time:  -
+earliest-time:  2016-09-02T10:30:00Z
+check-time:     2016-09-02T10:30:00Z
+The system clock is 2h30m0s behind the earliest time, assertions are checked against the latter.
+`)
+}
@@ -0,0 +1,73 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/overlord"
+	"github.com/snapcore/snapd/snap"
+)
+
+var shortValidateGadgetHelp = i18n.G("Check the gadget of an image")
+var longValidateGadgetHelp = i18n.G(`
+The validate-gadget command checks the meta/gadget.yaml of the unpacked
+gadget snap in the given directory, so that image builders find mistakes
+in the configuration defaults for the seeded snaps before the image boots.
+When the seeded snaps are given with --seeded, defaults for other snaps
+are reported as well.
+`)
+
+type cmdValidateGadget struct {
+	Seeded     []string `long:"seeded" description:"name of a snap seeded in the image (can be repeated)"`
+	Positional struct {
+		GadgetDir string `positional-arg-name:"<gadget-dir>" required:"yes"`
+	} `positional-args:"yes"`
+}
+
+func init() {
+	addDebugCommand("validate-gadget", shortValidateGadgetHelp, longValidateGadgetHelp, func() flags.Commander {
+		return &cmdValidateGadget{}
+	})
+}
+
+func (x *cmdValidateGadget) Execute(args []string) error {
+	gadget, err := snap.ReadGadgetInfo(x.Positional.GadgetDir)
+	if err != nil {
+		return err
+	}
+
+	var seeded map[string]bool
+	if len(x.Seeded) > 0 {
+		seeded = make(map[string]bool, len(x.Seeded))
+		for _, name := range x.Seeded {
+			seeded[name] = true
+		}
+	}
+	if err := overlord.CheckGadgetDefaults(gadget, seeded); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(Stdout, i18n.G("Gadget has valid defaults for %d snaps\n"), len(gadget.Defaults))
+	return nil
+}
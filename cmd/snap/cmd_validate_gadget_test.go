@@ -0,0 +1,60 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) mockGadgetDir(c *check.C, gadgetYaml string) string {
+	dir := c.MkDir()
+	c.Assert(os.MkdirAll(filepath.Join(dir, "meta"), 0755), check.IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, "meta", "gadget.yaml"), []byte(gadgetYaml), 0644), check.IsNil)
+	return dir
+}
+
+func (s *SnapSuite) TestValidateGadget(c *check.C) {
+	dir := s.mockGadgetDir(c, "defaults:\n  foo:\n    port: 8080\n  core:\n    some-option: x\n")
+
+	rest, err := snap.Parser().ParseArgs([]string{"debug", "validate-gadget", "--seeded", "foo", dir})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Equals, "Gadget has valid defaults for 2 snaps\n")
+}
+
+func (s *SnapSuite) TestValidateGadgetTypo(c *check.C) {
+	dir := s.mockGadgetDir(c, "defaults:\n  foo:\n    server_port: 8080\n")
+
+	_, err := snap.Parser().ParseArgs([]string{"debug", "validate-gadget", dir})
+	c.Assert(err, check.ErrorMatches, `invalid default for snap "foo": invalid option name: "server_port"`)
+}
+
+func (s *SnapSuite) TestValidateGadgetNotSeeded(c *check.C) {
+	dir := s.mockGadgetDir(c, "defaults:\n  fooo:\n    port: 8080\n")
+
+	_, err := snap.Parser().ParseArgs([]string{"debug", "validate-gadget", "--seeded", "foo", dir})
+	c.Assert(err, check.ErrorMatches, `gadget has defaults for snap "fooo" which is not seeded`)
+}
@@ -0,0 +1,79 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/i18n"
+)
+
+var shortWarningsHelp = i18n.G("List the warnings about installed snaps")
+var longWarningsHelp = i18n.G(`
+The warnings command lists the warnings about the installed snaps.
+
+With --security, only the installed revisions affected by the security
+advisories fetched from the store are listed, noting the snaps held at
+their revision, which won't be refreshed to a fixed one. The advisories
+are only fetched once enabled with
+
+    snap set core security-advisories.enable=true
+`)
+
+type cmdWarnings struct {
+	Security bool `long:"security" description:"only list the installed revisions affected by security advisories"`
+}
+
+func init() {
+	addCommand("warnings", shortWarningsHelp, longWarningsHelp, func() flags.Commander { return &cmdWarnings{} })
+}
+
+func (x *cmdWarnings) Execute(args []string) error {
+	warnings, err := Client().Warnings(&client.WarningsOptions{Security: x.Security})
+	if err != nil {
+		return err
+	}
+	if len(warnings) == 0 {
+		fmt.Fprintln(Stdout, i18n.G("No warnings."))
+		return nil
+	}
+
+	w := tabWriter()
+	fmt.Fprintln(w, i18n.G("Snap\tRev\tAdvisory\tSeverity\tCVEs\tFixed in\tNotes"))
+	for _, warning := range warnings {
+		fixed := "-"
+		if warning.FixedRevision > 0 {
+			fixed = strconv.Itoa(warning.FixedRevision)
+		}
+		notes := "-"
+		if warning.Held {
+			notes = i18n.G("held")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", warning.Snap, warning.Revision, warning.ID, warning.Severity, dashIfEmpty(strings.Join(warning.CVEs, ",")), fixed, notes)
+	}
+	w.Flush()
+
+	return nil
+}
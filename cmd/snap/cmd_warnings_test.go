@@ -0,0 +1,62 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) TestWarningsSecurity(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		c.Check(r.URL.Path, check.Equals, "/v2/warnings")
+		c.Check(r.URL.Query().Get("select"), check.Equals, "security")
+		fmt.Fprintln(w, `{"type": "sync", "result": [
+			{"kind": "security", "snap": "foo", "revision": "7", "id": "USN-1", "cves": ["CVE-2016-1", "CVE-2016-2"], "severity": "high", "fixed-revision": 8, "held": true},
+			{"kind": "security", "snap": "foo", "revision": "7", "id": "USN-3", "severity": "medium"}
+		]}`)
+		n++
+	})
+	rest, err := snap.Parser().ParseArgs([]string{"warnings", "--security"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(n, check.Equals, 1)
+	c.Check(s.Stdout(), check.Equals, ""+
+		"Snap  Rev  Advisory  Severity  CVEs                   Fixed in  Notes\n"+
+		"foo   7    USN-1     high      CVE-2016-1,CVE-2016-2  8         held\n"+
+		"foo   7    USN-3     medium    -                      -         -\n")
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *SnapSuite) TestWarningsNone(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Query().Get("select"), check.Equals, "")
+		fmt.Fprintln(w, `{"type": "sync", "result": []}`)
+	})
+	_, err := snap.Parser().ParseArgs([]string{"warnings"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "No warnings.\n")
+}
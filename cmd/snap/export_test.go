@@ -22,6 +22,8 @@ package main
 
 import (
 	"os/user"
+
+	"github.com/snapcore/snapd/interfaces"
 )
 
 var RunMain = run
@@ -48,3 +50,17 @@ func MockUserCurrent(f func() (*user.User, error)) (restore func()) {
 		userCurrent = userCurrentOrig
 	}
 }
+
+func MockReadPassword(f func(fd int) ([]byte, error)) (restore func()) {
+	readPasswordOrig := readPassword
+	readPassword = f
+	return func() {
+		readPassword = readPasswordOrig
+	}
+}
+
+func MockDeprecations(deps []*interfaces.Deprecation) (restore func()) {
+	old := deprecations
+	deprecations = func() []*interfaces.Deprecation { return deps }
+	return func() { deprecations = old }
+}
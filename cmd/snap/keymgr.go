@@ -0,0 +1,54 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/ssh/terminal"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/osutil"
+)
+
+// the name of the signing key used when none is given
+const defaultKeyName = "default"
+
+var readPassword = terminal.ReadPassword
+
+// getKeypairManager returns the manager of the local signing keys, kept
+// in their own GPG keyring under ~/.snap/gnupg unless SNAP_GNUPG_HOME
+// says otherwise.
+func getKeypairManager() (*asserts.GPGKeypairManager, error) {
+	homedir := os.Getenv("SNAP_GNUPG_HOME")
+	if homedir == "" {
+		home, err := osutil.CurrentHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		homedir = filepath.Join(home, ".snap", "gnupg")
+	}
+	if err := os.MkdirAll(homedir, 0700); err != nil {
+		return nil, fmt.Errorf("cannot create GPG home %q: %v", homedir, err)
+	}
+	return asserts.NewGPGKeypairManager(homedir), nil
+}
@@ -29,6 +29,7 @@ import (
 	"github.com/snapcore/snapd/cmd"
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/progress"
 
 	"github.com/jessevdk/go-flags"
 )
@@ -41,7 +42,8 @@ var (
 )
 
 type options struct {
-	Version func() `long:"version" description:"print the version and exit"`
+	Version      func() `long:"version" description:"print the version and exit"`
+	ScreenReader bool   `long:"screen-reader" description:"report progress as plain lines of text"`
 }
 
 var optionsData options
@@ -59,6 +61,12 @@ var commands []*cmdInfo
 // experimentalCommands holds information about all experimental commands.
 var experimentalCommands []*cmdInfo
 
+// debugCommands holds information about all debug commands.
+var debugCommands []*cmdInfo
+
+// routineCommands holds information about all routine commands.
+var routineCommands []*cmdInfo
+
 // addCommand replaces parser.addCommand() in a way that is compatible with
 // re-constructing a pristine parser.
 func addCommand(name, shortHelp, longHelp string, builder func() flags.Commander) *cmdInfo {
@@ -86,6 +94,34 @@ func addExperimentalCommand(name, shortHelp, longHelp string, builder func() fla
 	return info
 }
 
+// addDebugCommand replaces parser.addCommand() in a way that is
+// compatible with re-constructing a pristine parser. It is meant for
+// adding debug commands.
+func addDebugCommand(name, shortHelp, longHelp string, builder func() flags.Commander) *cmdInfo {
+	info := &cmdInfo{
+		name:      name,
+		shortHelp: shortHelp,
+		longHelp:  longHelp,
+		builder:   builder,
+	}
+	debugCommands = append(debugCommands, info)
+	return info
+}
+
+// addRoutineCommand replaces parser.addCommand() in a way that is
+// compatible with re-constructing a pristine parser. It is meant for
+// adding routine commands.
+func addRoutineCommand(name, shortHelp, longHelp string, builder func() flags.Commander) *cmdInfo {
+	info := &cmdInfo{
+		name:      name,
+		shortHelp: shortHelp,
+		longHelp:  longHelp,
+		builder:   builder,
+	}
+	routineCommands = append(routineCommands, info)
+	return info
+}
+
 type parserSetter interface {
 	setParser(*flags.Parser)
 }
@@ -94,6 +130,7 @@ type parserSetter interface {
 // Since commands have local state a fresh parser is required to isolate tests
 // from each other.
 func Parser() *flags.Parser {
+	optionsData.ScreenReader = false
 	optionsData.Version = func() {
 		cv, err := Client().ServerVersion()
 		if err != nil {
@@ -138,9 +175,52 @@ The snap tool interacts with the snapd daemon to control the snappy software pla
 		}
 		cmd.Hidden = c.hidden
 	}
+	// Add the debug command
+	debugCommand, err := parser.AddCommand("debug", shortDebugHelp, longDebugHelp, &cmdDebug{})
+	if err != nil {
+		logger.Panicf("cannot add command %q: %v", "debug", err)
+	}
+	debugCommand.Hidden = true
+	// Add all the sub-commands of the debug command
+	for _, c := range debugCommands {
+		cmd, err := debugCommand.AddCommand(c.name, c.shortHelp, strings.TrimSpace(c.longHelp), c.builder())
+		if err != nil {
+			logger.Panicf("cannot add debug command %q: %v", c.name, err)
+		}
+		cmd.Hidden = c.hidden
+	}
+	// Add the routine command
+	routineCommand, err := parser.AddCommand("routine", shortRoutineHelp, longRoutineHelp, &cmdRoutine{})
+	if err != nil {
+		logger.Panicf("cannot add command %q: %v", "routine", err)
+	}
+	routineCommand.Hidden = true
+	// Add all the sub-commands of the routine command
+	for _, c := range routineCommands {
+		cmd, err := routineCommand.AddCommand(c.name, c.shortHelp, strings.TrimSpace(c.longHelp), c.builder())
+		if err != nil {
+			logger.Panicf("cannot add routine command %q: %v", c.name, err)
+		}
+		cmd.Hidden = c.hidden
+	}
 	return parser
 }
 
+// plainOutput returns whether progress should be reported as plain
+// lines of text, for screen readers and dumb terminals.
+func plainOutput() bool {
+	return optionsData.ScreenReader || progress.PlainRequested()
+}
+
+// newProgress returns the progress meter to use for long running
+// operations.
+func newProgress() progress.Meter {
+	if plainOutput() {
+		return progress.NewPlainProgress(Stdout)
+	}
+	return progress.NewTextProgress()
+}
+
 // ClientConfig is the configuration of the Client used by all commands.
 var ClientConfig client.Config
 
@@ -159,7 +239,7 @@ func init() {
 func main() {
 	cmd.ExecInCoreSnap()
 	if err := run(); err != nil {
-		fmt.Fprintf(Stderr, "error: %v\n", err)
+		fmt.Fprintf(Stderr, i18n.G("error: %v\n"), err)
 		os.Exit(1)
 	}
 }
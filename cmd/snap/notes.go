@@ -31,6 +31,9 @@ type Notes struct {
 	Private     bool
 	DevMode     bool
 	TryMode     bool
+	// LicensePending is set for snaps whose services wait for their
+	// license to be accepted
+	LicensePending bool
 }
 
 func (n *Notes) String() string {
@@ -56,6 +59,10 @@ func (n *Notes) String() string {
 		ns = append(ns, "try")
 	}
 
+	if n.LicensePending {
+		ns = append(ns, "license-pending")
+	}
+
 	if len(ns) == 0 {
 		return "-"
 	}
@@ -59,3 +59,9 @@ func (notesSuite) TestNotesOtherDevmode(c *check.C) {
 		TryMode: true,
 	}).String(), check.Equals, "devmode,try")
 }
+
+func (notesSuite) TestNotesLicensePending(c *check.C) {
+	c.Check((&snap.Notes{
+		LicensePending: true,
+	}).String(), check.Equals, "license-pending")
+}
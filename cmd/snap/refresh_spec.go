@@ -0,0 +1,68 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/i18n"
+)
+
+// refreshToSpec sends the refresh spec at the given path, along with
+// its detached signature if there is one, to snapd, which checks it and
+// brings the snaps to its revisions.
+func refreshToSpec(path string, allowUnsigned bool) error {
+	spec, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf(i18n.G("cannot read refresh spec: %v"), err)
+	}
+	sigPath := path + ".sig"
+	sig, err := ioutil.ReadFile(sigPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf(i18n.G("cannot read refresh spec signature: %v"), err)
+	}
+	if sig == nil && !allowUnsigned {
+		return fmt.Errorf(i18n.G("cannot use refresh spec %q: missing signature %q"), path, sigPath)
+	}
+
+	cli := Client()
+	changeID, err := cli.RefreshToSpec(spec, sig, &client.SnapOptions{AllowUnsigned: allowUnsigned})
+	if e, ok := err.(*client.Error); ok && e.Kind == client.ErrorKindNothingToRefresh {
+		fmt.Fprintln(Stderr, i18n.G("All snaps are at the revisions of the refresh spec."))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	chg, err := wait(cli, changeID)
+	if err != nil {
+		return err
+	}
+	var names []string
+	if err := chg.Get("snap-names", &names); err != nil {
+		return err
+	}
+
+	return listSnaps(names)
+}
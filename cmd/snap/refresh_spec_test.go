@@ -0,0 +1,133 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+const refreshSpecYaml = `snaps:
+  foo: 12
+  bar: 3
+  ubuntu-core: 100
+`
+
+func writeRefreshSpec(c *check.C, content string, signed bool) string {
+	specPath := filepath.Join(c.MkDir(), "spec.yaml")
+	c.Assert(ioutil.WriteFile(specPath, []byte(content), 0644), check.IsNil)
+	if signed {
+		c.Assert(ioutil.WriteFile(specPath+".sig", []byte("sig"), 0644), check.IsNil)
+	}
+	return specPath
+}
+
+func (s *SnapSuite) TestRefreshToSpec(c *check.C) {
+	specPath := writeRefreshSpec(c, refreshSpecYaml, true)
+
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "POST")
+			c.Check(r.URL.Path, check.Equals, "/v2/snaps")
+			// snapd is the one checking the signature
+			c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+				"action":         "refresh",
+				"spec":           base64.StdEncoding.EncodeToString([]byte(refreshSpecYaml)),
+				"spec-signature": base64.StdEncoding.EncodeToString([]byte("sig")),
+			})
+			w.WriteHeader(http.StatusAccepted)
+			fmt.Fprintln(w, `{"type":"async", "change": "1", "status-code": 202}`)
+		case 1:
+			c.Check(r.URL.Path, check.Equals, "/v2/changes/1")
+			fmt.Fprintln(w, `{"type": "sync", "result": {"ready": true, "status": "Done", "data": {"snap-names": ["ubuntu-core", "bar", "foo"]}}}`)
+		case 2:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/snaps")
+			fmt.Fprintln(w, `{"type": "sync", "result": [{"name": "foo", "status": "active", "version": "1.1", "developer": "dev", "revision": 12}, {"name": "bar", "status": "active", "version": "2.0", "developer": "dev", "revision": 3}, {"name": "ubuntu-core", "status": "active", "version": "16.04", "developer": "canonical", "revision": 100}]}`)
+		default:
+			c.Fatalf("expected to get 3 requests, now on %d", n+1)
+		}
+		n++
+	})
+
+	rest, err := snap.Parser().ParseArgs([]string{"refresh", "--to-spec", specPath})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(n, check.Equals, 3)
+	c.Check(s.Stdout(), check.Matches, `(?sm).*foo\s+1.1\s+12\s+dev.*`)
+	c.Check(s.Stdout(), check.Matches, `(?sm).*bar\s+2.0\s+3\s+dev.*`)
+}
+
+func (s *SnapSuite) TestRefreshToSpecNothingToRefresh(c *check.C) {
+	specPath := writeRefreshSpec(c, refreshSpecYaml, true)
+
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, check.Equals, "/v2/snaps")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintln(w, `{"type": "error", "result": {"message": "snaps are at the revisions of the refresh spec already", "kind": "nothing-to-refresh"}, "status-code": 400}`)
+		n++
+	})
+
+	rest, err := snap.Parser().ParseArgs([]string{"refresh", "--to-spec", specPath})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(n, check.Equals, 1)
+	c.Check(s.Stderr(), check.Equals, "All snaps are at the revisions of the refresh spec.\n")
+}
+
+func (s *SnapSuite) TestRefreshToSpecUnsigned(c *check.C) {
+	specPath := writeRefreshSpec(c, refreshSpecYaml, false)
+
+	_, err := snap.Parser().ParseArgs([]string{"refresh", "--to-spec", specPath})
+	c.Assert(err, check.ErrorMatches, `cannot use refresh spec ".*/spec.yaml": missing signature ".*/spec.yaml.sig"`)
+}
+
+func (s *SnapSuite) TestRefreshToSpecAllowUnsigned(c *check.C) {
+	specPath := writeRefreshSpec(c, refreshSpecYaml, false)
+
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		// whether to accept it is up to snapd
+		c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+			"action":         "refresh",
+			"spec":           base64.StdEncoding.EncodeToString([]byte(refreshSpecYaml)),
+			"allow-unsigned": true,
+		})
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintln(w, `{"type": "error", "result": {"message": "cannot refresh: cannot use refresh spec: missing signature, required by the keyring of the device owner"}, "status-code": 500}`)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"refresh", "--allow-unsigned", "--to-spec", specPath})
+	c.Assert(err, check.ErrorMatches, `cannot refresh: cannot use refresh spec: missing signature, required by the keyring of the device owner`)
+}
+
+func (s *SnapSuite) TestRefreshToSpecConflicts(c *check.C) {
+	_, err := snap.Parser().ParseArgs([]string{"refresh", "--to-spec", "spec.yaml", "foo"})
+	c.Assert(err, check.ErrorMatches, `cannot use --to-spec with a snap name or --channel`)
+}
@@ -23,11 +23,16 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
+	"time"
 
 	"github.com/snapcore/snapd/cmd"
 	"github.com/snapcore/snapd/daemon"
+	"github.com/snapcore/snapd/gpu"
 	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord"
+	"github.com/snapcore/snapd/release"
 )
 
 func init() {
@@ -46,6 +51,20 @@ func main() {
 }
 
 func run() error {
+	if len(os.Args) > 1 && os.Args[1] == "preseed" {
+		if len(os.Args) != 3 {
+			return fmt.Errorf("usage: snapd preseed <image-root>")
+		}
+		return overlord.Preseed(os.Args[2])
+	}
+
+	if release.OnClassic {
+		// make the GPU libraries of the host available to snaps
+		if err := gpu.Setup(); err != nil {
+			logger.Noticef("cannot set up GPU libraries for snaps: %v", err)
+		}
+	}
+
 	d, err := daemon.New()
 	if err != nil {
 		return err
@@ -57,6 +76,17 @@ func run() error {
 
 	d.Start()
 
+	// free the memory of constrained devices when there is nothing to
+	// do, snapd being started again by its socket or its timers
+	if v := os.Getenv("SNAPD_IDLE_EXIT"); v != "" {
+		minutes, err := strconv.Atoi(v)
+		if err != nil || minutes <= 0 {
+			logger.Noticef("invalid SNAPD_IDLE_EXIT %q, expected a number of minutes", v)
+		} else {
+			d.ExitWhenIdle(time.Duration(minutes) * time.Minute)
+		}
+	}
+
 	ch := make(chan os.Signal)
 	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
 	select {
@@ -30,23 +30,31 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
 
 	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/cgroup"
 	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/errtracker"
+	"github.com/snapcore/snapd/fips"
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/configstate"
 	"github.com/snapcore/snapd/overlord/ifacestate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/policy"
 	"github.com/snapcore/snapd/progress"
 	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/sbom"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/store"
 )
@@ -57,9 +65,23 @@ var api = []*Command{
 	loginCmd,
 	logoutCmd,
 	appIconCmd,
+	portalInfoCmd,
+	fileAccessCmd,
+	cgroupInfoCmd,
+	sbomCmd,
+	warningsCmd,
+	connectivityCmd,
+	errorReportsCmd,
+	denialsCmd,
+	quarantineCmd,
+	timeWarpCmd,
+	lockStatsCmd,
+	strayPolicyCmd,
 	findCmd,
 	snapsCmd,
 	snapCmd,
+	snapConfCmd,
+	snapLicenseCmd,
 	//FIXME: renenable config for GA
 	//snapConfigCmd,
 	interfacesCmd,
@@ -68,6 +90,8 @@ var api = []*Command{
 	eventsCmd,
 	stateChangeCmd,
 	stateChangesCmd,
+	rebootCmd,
+	servicesCmd,
 }
 
 var (
@@ -101,6 +125,77 @@ var (
 		GET:    appIconGet,
 	}
 
+	portalInfoCmd = &Command{
+		Path:   "/v2/portal-info",
+		UserOK: true,
+		GET:    getPortalInfo,
+	}
+
+	fileAccessCmd = &Command{
+		Path:   "/v2/file-access",
+		UserOK: true,
+		GET:    getFileAccess,
+	}
+
+	cgroupInfoCmd = &Command{
+		Path:   "/v2/cgroup-info",
+		UserOK: true,
+		GET:    getCgroupInfo,
+	}
+
+	sbomCmd = &Command{
+		Path:   "/v2/sbom",
+		UserOK: true,
+		GET:    getSBOM,
+	}
+
+	warningsCmd = &Command{
+		Path:   "/v2/warnings",
+		UserOK: true,
+		GET:    getWarnings,
+	}
+
+	connectivityCmd = &Command{
+		Path:   "/v2/debug/connectivity",
+		UserOK: true,
+		GET:    getConnectivity,
+	}
+
+	errorReportsCmd = &Command{
+		Path: "/v2/debug/error-reports",
+		GET:  getErrorReports,
+	}
+
+	denialsCmd = &Command{
+		Path:   "/v2/debug/denials",
+		UserOK: true,
+		GET:    getDenials,
+	}
+
+	quarantineCmd = &Command{
+		Path: "/v2/debug/quarantine",
+		GET:  getQuarantine,
+		POST: postQuarantine,
+	}
+
+	timeWarpCmd = &Command{
+		Path:   "/v2/debug/timewarp",
+		UserOK: true,
+		GET:    getTimeWarp,
+	}
+
+	lockStatsCmd = &Command{
+		Path:   "/v2/debug/lock-stats",
+		UserOK: true,
+		GET:    getLockStats,
+	}
+
+	strayPolicyCmd = &Command{
+		Path:   "/v2/debug/stray-policy",
+		UserOK: true,
+		GET:    getStrayPolicy,
+	}
+
 	findCmd = &Command{
 		Path:   "/v2/find",
 		UserOK: true,
@@ -111,7 +206,7 @@ var (
 		Path:   "/v2/snaps",
 		UserOK: true,
 		GET:    getSnapsInfo,
-		POST:   sideloadSnap,
+		POST:   postSnaps,
 	}
 
 	snapCmd = &Command{
@@ -120,6 +215,18 @@ var (
 		GET:    getSnapInfo,
 		POST:   postSnap,
 	}
+	snapConfCmd = &Command{
+		Path: "/v2/snaps/{name}/conf",
+		GET:  getSnapConf,
+		PUT:  setSnapConf,
+	}
+	snapLicenseCmd = &Command{
+		Path:   "/v2/snaps/{name}/license",
+		UserOK: true,
+		GET:    getSnapLicense,
+		POST:   postSnapLicense,
+	}
+
 	//FIXME: renenable config for GA
 	/*
 		snapConfigCmd = &Command{
@@ -153,6 +260,16 @@ var (
 		GET:  getEvents,
 	}
 
+	rebootCmd = &Command{
+		Path: "/v2/reboot",
+		POST: postReboot,
+	}
+
+	servicesCmd = &Command{
+		Path: "/v2/services",
+		POST: postServices,
+	}
+
 	stateChangeCmd = &Command{
 		Path:   "/v2/changes/{id}",
 		UserOK: true,
@@ -171,10 +288,68 @@ func tbd(c *Command, r *http.Request, user *auth.UserState) Response {
 	return SyncResponse([]string{"TBD"}, nil)
 }
 
+type refreshInfo struct {
+	Last      *time.Time `json:"last,omitempty"`
+	Next      *time.Time `json:"next,omitempty"`
+	Failures  int        `json:"failures,omitempty"`
+	LastError string     `json:"last-error,omitempty"`
+}
+
+type cryptoInfo struct {
+	Provider string `json:"provider"`
+	FIPSMode bool   `json:"fips-mode"`
+}
+
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
 func sysInfo(c *Command, r *http.Request, user *auth.UserState) Response {
-	m := map[string]string{
+	m := map[string]interface{}{
 		"series":  release.Series,
 		"version": c.d.Version,
+		"crypto": &cryptoInfo{
+			Provider: fips.ProviderName(),
+			FIPSMode: fips.Enabled(),
+		},
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	status, err := snapstate.GetRefreshStatus(st)
+	st.Unlock()
+	if err != nil {
+		return InternalError("cannot get refresh status: %v", err)
+	}
+	if !status.NextAttempt.IsZero() {
+		m["refresh"] = &refreshInfo{
+			Last:      timeOrNil(status.LastAttempt),
+			Next:      timeOrNil(status.NextAttempt),
+			Failures:  status.Failures,
+			LastError: status.LastError,
+		}
+	}
+
+	st.Lock()
+	rebootRequired, err := snapstate.RebootRequired(st)
+	st.Unlock()
+	if err != nil {
+		return InternalError("cannot get the snaps waiting for a reboot: %v", err)
+	}
+	if len(rebootRequired) > 0 {
+		m["reboot-required"] = rebootRequired
+	}
+	st.Lock()
+	rebootAt, err := snapstate.RebootTime(st)
+	st.Unlock()
+	if err != nil {
+		return InternalError("cannot get when to reboot: %v", err)
+	}
+	if !rebootAt.IsZero() {
+		m["reboot-at"] = rebootAt
 	}
 
 	return SyncResponse(m, nil)
@@ -303,7 +478,7 @@ func getSnapInfo(c *Command, r *http.Request, user *auth.UserState) Response {
 	localSnap, active, err := localSnapInfo(c.d.overlord.State(), name)
 	if err != nil {
 		if err == errNoSnap {
-			return NotFound("cannot find snap %q", name)
+			return NotFound(i18n.G("cannot find snap %q"), name)
 		}
 
 		return InternalError("%v", err)
@@ -324,6 +499,206 @@ func getSnapInfo(c *Command, r *http.Request, user *auth.UserState) Response {
 	return SyncResponse(result, nil)
 }
 
+// checkConfSnap checks that the configuration of the named snap can be
+// used, that is, that the snap is installed or is the system itself.
+// Note that the state must be locked by the caller.
+func checkConfSnap(st *state.State, name string) Response {
+	if name == configstate.CoreSnapName {
+		return nil
+	}
+	var snapst snapstate.SnapState
+	err := snapstate.Get(st, name, &snapst)
+	if err == state.ErrNoState || (err == nil && snapst.Current() == nil) {
+		return NotFound(i18n.G("cannot find snap %q"), name)
+	}
+	if err != nil {
+		return InternalError("cannot consult state: %v", err)
+	}
+	return nil
+}
+
+// confSnapInfo returns the details of the installed snap whose
+// configuration is being set, and its configuration schema if it has
+// one; the system itself has neither.
+// Note that the state must be locked by the caller.
+func confSnapInfo(st *state.State, name string) (*snap.Info, *snap.ConfigSchema, Response) {
+	if name == configstate.CoreSnapName {
+		return nil, nil, nil
+	}
+	var snapst snapstate.SnapState
+	if err := snapstate.Get(st, name, &snapst); err != nil {
+		return nil, nil, InternalError("cannot consult state: %v", err)
+	}
+	info, err := snap.ReadInfo(name, snapst.Current())
+	if err != nil {
+		return nil, nil, InternalError("cannot read snap details: %v", err)
+	}
+	schema, err := snap.ReadConfigSchema(info.MountDir())
+	if err != nil {
+		return nil, nil, InternalError("cannot set configuration of snap %q: %v", name, err)
+	}
+	return info, schema, nil
+}
+
+// getSnapConf returns the values of the configuration options of the
+// snap with the given keys, or the whole of its configuration when no
+// keys are given.
+func getSnapConf(c *Command, r *http.Request, user *auth.UserState) Response {
+	name := muxVars(r)["name"]
+	var keys []string
+	if q := r.URL.Query().Get("keys"); q != "" {
+		keys = strings.Split(q, ",")
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	if rsp := checkConfSnap(st, name); rsp != nil {
+		return rsp
+	}
+
+	if len(keys) == 0 {
+		config, err := configstate.Config(st, name)
+		if err != nil {
+			return InternalError("cannot get configuration of snap %q: %v", name, err)
+		}
+		return SyncResponse(config, nil)
+	}
+
+	result := make(map[string]interface{}, len(keys))
+	for _, key := range keys {
+		var value interface{}
+		if err := configstate.Get(st, name, key, &value); err != nil {
+			if _, ok := err.(*configstate.NoOptionError); ok {
+				return NotFound("%v", err)
+			}
+			return BadRequest("%v", err)
+		}
+		result[key] = value
+	}
+
+	return SyncResponse(result, nil)
+}
+
+func setSnapConf(c *Command, r *http.Request, user *auth.UserState) Response {
+	name := muxVars(r)["name"]
+
+	var patch map[string]interface{}
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&patch); err != nil {
+		return BadRequest("cannot decode request body into patch values: %v", err)
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	if rsp := checkConfSnap(st, name); rsp != nil {
+		return rsp
+	}
+	info, schema, rsp := confSnapInfo(st, name)
+	if rsp != nil {
+		return rsp
+	}
+
+	keys := make([]string, 0, len(patch))
+	for key := range patch {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	// nothing is set unless all of the patch is valid
+	for _, key := range keys {
+		if err := configstate.ValidateSchema(schema, key, patch[key]); err != nil {
+			return BadRequest("cannot set configuration of snap %q: %v", name, err)
+		}
+	}
+
+	// the configure hook of the snap is told about the change, which
+	// is rolled back should the hook fail
+	if info != nil && info.Hooks[configstate.ConfigureHook] != nil {
+		task := configstate.Configure(st, name, info.Revision, patch)
+		chg := newChange(st, "configure-snap", fmt.Sprintf(i18n.G("Change configuration of %q snap"), name), []*state.TaskSet{state.NewTaskSet(task)})
+		st.EnsureBefore(0)
+		return AsyncResponse(nil, &Meta{Change: chg.ID()})
+	}
+
+	tr := configstate.NewTransaction(st, name)
+	for _, key := range keys {
+		if err := tr.Set(key, patch[key]); err != nil {
+			return BadRequest("cannot set configuration of snap %q: %v", name, err)
+		}
+	}
+	if err := tr.Commit(); err != nil {
+		return BadRequest("cannot set configuration of snap %q: %v", name, err)
+	}
+
+	return SyncResponse(nil, nil)
+}
+
+// getSnapLicense returns the license text shipped with the installed
+// snap, and whether it was accepted.
+func getSnapLicense(c *Command, r *http.Request, user *auth.UserState) Response {
+	name := muxVars(r)["name"]
+
+	info, snapst, err := localSnapInfo(c.d.overlord.State(), name)
+	if err != nil {
+		if err == errNoSnap {
+			return NotFound(i18n.G("cannot find snap %q"), name)
+		}
+
+		return InternalError("%v", err)
+	}
+	if !info.NeedsLicenseAgreement() {
+		return NotFound("snap %q does not require accepting its license", name)
+	}
+
+	text, err := ioutil.ReadFile(info.LicenseFile())
+	if err != nil {
+		return InternalError("cannot read license of snap %q: %v", name, err)
+	}
+
+	// not a *licenseData, which is an error
+	return SyncResponse(licenseData{
+		Intro:   fmt.Sprintf(i18n.G("%s requires that you accept the following license before its services are started"), name),
+		License: string(text),
+		Agreed:  snapst.LicenseAccepted(info),
+	}, nil)
+}
+
+// postSnapLicense accepts the license of the installed snap, starting
+// the services it held back.
+func postSnapLicense(c *Command, r *http.Request, user *auth.UserState) Response {
+	name := muxVars(r)["name"]
+
+	var data licenseData
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&data); err != nil {
+		return BadRequest("cannot decode data from request body: %v", err)
+	}
+	if !data.Agreed {
+		return BadRequest("cannot accept the license of snap %q: not agreed to", name)
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	ts, err := snapstate.AcceptSnapLicense(st, name)
+	if err != nil {
+		return BadRequest("cannot accept the license of snap %q: %v", name, err)
+	}
+
+	chg := st.NewChange("accept-license", fmt.Sprintf(i18n.G("Accept the license of snap %q"), name))
+	chg.Set("snap-names", []string{name})
+	chg.AddAll(ts)
+
+	st.EnsureBefore(0)
+
+	return AsyncResponse(nil, &Meta{Change: chg.ID()})
+}
+
 func webify(result map[string]interface{}, resource string) map[string]interface{} {
 	result["resource"] = resource
 
@@ -356,10 +731,18 @@ func searchStore(c *Command, r *http.Request, user *auth.UserState) Response {
 	}
 	query := r.URL.Query()
 
+	name := query.Get("name")
+	if name != "" && query.Get("q") != "" {
+		return BadRequest("cannot use 'q' and 'name' together")
+	}
+
 	if query.Get("select") == "refresh" {
 		if query.Get("q") != "" {
 			return BadRequest("cannot use 'q' with 'select=refresh'")
 		}
+		if name != "" {
+			return BadRequest("cannot use 'name' with 'select=refresh'")
+		}
 		return storeUpdates(c, r, user)
 	}
 
@@ -368,14 +751,27 @@ func searchStore(c *Command, r *http.Request, user *auth.UserState) Response {
 		return InternalError("%v", err)
 	}
 
-	store := getStore(c)
-	found, err := store.Find(query.Get("q"), query.Get("channel"), auther)
-	if err != nil {
-		return InternalError("%v", err)
+	theStore := getStore(c)
+	var found []*snap.Info
+	if name != "" {
+		// exact lookup, with the full details of the snap
+		info, err := theStore.Snap(name, query.Get("channel"), auther)
+		if err == store.ErrSnapNotFound {
+			return NotFound(i18n.G("cannot find snap %q in the store"), name)
+		}
+		if err != nil {
+			return InternalError("%v", err)
+		}
+		found = []*snap.Info{info}
+	} else {
+		found, err = theStore.Find(query.Get("q"), query.Get("channel"), auther)
+		if err != nil {
+			return InternalError("%v", err)
+		}
 	}
 
 	meta := &Meta{
-		SuggestedCurrency: store.SuggestedCurrency(),
+		SuggestedCurrency: theStore.SuggestedCurrency(),
 		Sources:           []string{"store"},
 	}
 
@@ -415,10 +811,11 @@ func storeUpdates(c *Command, r *http.Request, user *auth.UserState) Response {
 		return InternalError("cannot list local snaps: %v", err)
 	}
 
+	current := make(map[string]*snap.Info, len(found))
 	candidatesInfo := make([]*store.RefreshCandidate, 0, len(found))
 	for _, sn := range found {
-		// snaps in try mode are not considered here
-		if sn.snapst.TryMode() {
+		// snaps in try mode or pinned to a revision are not considered here
+		if sn.snapst.TryMode() || sn.snapst.Pinned() {
 			continue
 		}
 
@@ -432,6 +829,7 @@ func storeUpdates(c *Command, r *http.Request, user *auth.UserState) Response {
 			Revision: sn.info.Revision,
 			Epoch:    sn.info.Epoch,
 		})
+		current[sn.info.SnapID] = sn.info
 	}
 
 	var auther store.Authenticator
@@ -444,10 +842,38 @@ func storeUpdates(c *Command, r *http.Request, user *auth.UserState) Response {
 		return InternalError("cannot list updates: %v", err)
 	}
 
-	return sendStorePackages(route, nil, updates)
+	// progressive releases not including the device yet are left
+	// out unless asked for
+	if r.URL.Query().Get("unhold-rollout") != "true" {
+		snapMgr := c.d.overlord.SnapManager()
+		available := updates[:0]
+		for _, update := range updates {
+			if !snapMgr.HeldByRollout(update) {
+				available = append(available, update)
+			}
+		}
+		updates = available
+	}
+
+	// keep what is offered, to be able to refresh offline later
+	st := c.d.overlord.State()
+	st.Lock()
+	snapstate.CacheRefreshCandidates(st, updates)
+	st.Unlock()
+
+	return sendStoreResults(route, nil, updates, func(update *snap.Info) map[string]interface{} {
+		if cur := current[update.SnapID]; cur != nil {
+			return mapRefresh(update, cur)
+		}
+		return mapRemote(update)
+	})
 }
 
 func sendStorePackages(route *mux.Route, meta *Meta, found []*snap.Info) Response {
+	return sendStoreResults(route, meta, found, mapRemote)
+}
+
+func sendStoreResults(route *mux.Route, meta *Meta, found []*snap.Info, mapf func(*snap.Info) map[string]interface{}) Response {
 	results := make([]*json.RawMessage, 0, len(found))
 	for _, x := range found {
 		url, err := route.URL("name", x.Name())
@@ -456,7 +882,7 @@ func sendStorePackages(route *mux.Route, meta *Meta, found []*snap.Info) Respons
 			continue
 		}
 
-		data, err := json.Marshal(webify(mapRemote(x), url.String()))
+		data, err := json.Marshal(webify(mapf(x), url.String()))
 		if err != nil {
 			return InternalError("%v", err)
 		}
@@ -536,21 +962,53 @@ type snapInstruction struct {
 	Action  string `json:"action"`
 	Channel string `json:"channel"`
 	DevMode bool   `json:"devmode"`
+	// Architecture is the architecture to install the snap for,
+	// instead of the one of the system, for it and its refreshes
+	Architecture string `json:"architecture"`
+	// UnholdRollout refreshes to a revision being released
+	// progressively even if the release does not include the device yet
+	UnholdRollout bool `json:"unhold-rollout"`
+	// Offline refreshes to the cached refresh candidates using the
+	// snap files already downloaded, without network
+	Offline bool `json:"offline"`
 	// dropping support temporarely until flag confusion is sorted,
 	// this isn't supported by client atm anyway
 	LeaveOld bool         `json:"temp-dropped-leave-old"`
 	License  *licenseData `json:"license"`
+	// PurgeDependents removes as well the snaps connected to slots of
+	// the removed snaps
+	PurgeDependents bool `json:"purge-dependents"`
+	// Transactional undoes the operation on all of the snaps if the
+	// one on any of them fails, instead of only the failed one
+	Transactional bool `json:"transactional"`
+	// Snaps are the snaps to operate on, for the operations on many
+	Snaps []string `json:"snaps"`
+	// Spec is a refresh spec to bring the snaps to the exact revisions
+	// of, checked against its detached SpecSignature
+	Spec          []byte `json:"spec"`
+	SpecSignature []byte `json:"spec-signature"`
+	// AllowUnsigned accepts a refresh spec without a signature, on
+	// devices without a keyring of the device owner
+	AllowUnsigned bool `json:"allow-unsigned"`
 
 	// The fields below should not be unmarshalled into. Do not export them.
 	snap   string
 	userID int
+
+	// set by the action, when it acts on other snaps than the given ones
+	snapNames  []string
+	dependents []string
 }
 
 var snapstateInstall = snapstate.Install
+var snapstateInstallRevision = snapstate.InstallRevision
 var snapstateUpdate = snapstate.Update
+var snapstateUpdateToRevision = snapstate.UpdateToRevision
+var snapstateUpdateOffline = snapstate.UpdateOffline
 var snapstateInstallPath = snapstate.InstallPath
 var snapstateTryPath = snapstate.TryPath
 var snapstateGet = snapstate.Get
+var ifacestateRemoveMany = ifacestate.RemoveMany
 
 var errNothingToInstall = errors.New("nothing to install")
 
@@ -596,6 +1054,15 @@ func snapInstall(inst *snapInstruction, st *state.State) (string, []*state.TaskS
 	if inst.DevMode || release.ReleaseInfo.ForceDevMode() {
 		flags |= snapstate.DevMode
 	}
+	if inst.License != nil && inst.License.Agreed {
+		flags |= snapstate.AcceptLicense
+	}
+
+	if inst.Architecture != "" {
+		if err := snapstate.SetArchitecture(st, inst.snap, inst.Architecture); err != nil {
+			return "", nil, err
+		}
+	}
 
 	tsets, err := withEnsureUbuntuCore(st, inst.snap, inst.userID,
 		func() (*state.TaskSet, error) {
@@ -614,7 +1081,20 @@ func snapInstall(inst *snapInstruction, st *state.State) (string, []*state.TaskS
 }
 
 func snapUpdate(inst *snapInstruction, st *state.State) (string, []*state.TaskSet, error) {
+	if inst.Offline {
+		return snapUpdateOffline(inst, st)
+	}
+	if inst.Spec != nil {
+		return snapUpdateToSpec(inst, st)
+	}
+
 	flags := snapstate.Flags(0)
+	if inst.UnholdRollout {
+		flags |= snapstate.UnholdRollout
+	}
+	if inst.License != nil && inst.License.Agreed {
+		flags |= snapstate.AcceptLicense
+	}
 
 	ts, err := snapstateUpdate(st, inst.snap, inst.Channel, inst.userID, flags)
 	if err != nil {
@@ -629,14 +1109,116 @@ func snapUpdate(inst *snapInstruction, st *state.State) (string, []*state.TaskSe
 	return msg, []*state.TaskSet{ts}, nil
 }
 
+// snapUpdateOffline refreshes the given snaps, or all the snaps with
+// a refresh available offline, from the download cache.
+func snapUpdateOffline(inst *snapInstruction, st *state.State) (string, []*state.TaskSet, error) {
+	if inst.Channel != "" || inst.Spec != nil {
+		return "", nil, fmt.Errorf("cannot refresh offline to a channel or refresh spec")
+	}
+	names := inst.Snaps
+	if inst.snap != "" {
+		names = []string{inst.snap}
+	}
+	updated, tsets, err := snapstateUpdateOffline(st, names, inst.userID)
+	if err != nil {
+		return "", nil, err
+	}
+	inst.snapNames = updated
+
+	msg := fmt.Sprintf(i18n.G("Refresh %q snap from the download cache"), updated[0])
+	if len(updated) > 1 {
+		msg = fmt.Sprintf(i18n.G("Refresh snaps %s from the download cache"), strings.Join(updated, ", "))
+	}
+	return msg, tsets, nil
+}
+
+var errNothingToRefresh = errors.New("snaps are at the revisions of the refresh spec already")
+
+// snapUpdateToSpec brings the snaps to the exact revisions of the
+// refresh spec, installing them if needed, once its signature checks
+// out. The OS snap goes first as the other snaps need it, and the
+// snaps providing slots before the snaps connected to them.
+func snapUpdateToSpec(inst *snapInstruction, st *state.State) (string, []*state.TaskSet, error) {
+	if inst.snap != "" || len(inst.Snaps) > 0 || inst.Channel != "" {
+		return "", nil, fmt.Errorf("cannot refresh to a refresh spec and to a channel or given snaps")
+	}
+	revisions, err := snapstate.ReadRefreshSpec(inst.Spec, inst.SpecSignature, inst.AllowUnsigned)
+	if err != nil {
+		return "", nil, err
+	}
+
+	names := make([]string, 0, len(revisions))
+	for name := range revisions {
+		names = append(names, name)
+	}
+	sort.Sort(osFirst(names))
+
+	var tsets []*state.TaskSet
+	var changed []string
+	for _, name := range names {
+		revision := revisions[name]
+		var snapst snapstate.SnapState
+		err := snapstateGet(st, name, &snapst)
+		if err != nil && err != state.ErrNoState {
+			return "", nil, err
+		}
+
+		var ts *state.TaskSet
+		switch {
+		case err == state.ErrNoState:
+			ts, err = snapstateInstallRevision(st, name, revision, inst.userID, 0)
+		case snapst.Current() != nil && snapst.Current().Revision == revision:
+			continue
+		default:
+			ts, err = snapstateUpdateToRevision(st, name, revision, inst.userID, 0)
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		tsets = append(tsets, ts)
+		changed = append(changed, name)
+	}
+	inst.snapNames = names
+	if len(tsets) == 0 {
+		return "", nil, errNothingToRefresh
+	}
+	if err := snapstate.WaitForDependencies(st, changed, tsets); err != nil {
+		return "", nil, err
+	}
+
+	msg := fmt.Sprintf(i18n.G("Refresh snaps %s to the revisions of a refresh spec"), strings.Join(names, ", "))
+	return msg, tsets, nil
+}
+
+// osFirst sorts snap names putting the OS snap first.
+type osFirst []string
+
+func (o osFirst) Len() int      { return len(o) }
+func (o osFirst) Swap(i, j int) { o[i], o[j] = o[j], o[i] }
+func (o osFirst) Less(i, j int) bool {
+	if (o[i] == "ubuntu-core") != (o[j] == "ubuntu-core") {
+		return o[i] == "ubuntu-core"
+	}
+	return o[i] < o[j]
+}
+
 func snapRemove(inst *snapInstruction, st *state.State) (string, []*state.TaskSet, error) {
-	ts, err := snapstate.Remove(st, inst.snap)
+	names := inst.Snaps
+	if len(names) == 0 {
+		names = []string{inst.snap}
+	}
+	removed, dependents, tsets, err := ifacestateRemoveMany(st, names, inst.PurgeDependents)
 	if err != nil {
 		return "", nil, err
 	}
+	inst.snapNames = removed
+	inst.dependents = dependents
 
-	msg := fmt.Sprintf(i18n.G("Remove %q snap"), inst.snap)
-	return msg, []*state.TaskSet{ts}, nil
+	msg := fmt.Sprintf(i18n.G("Remove %q snap"), removed[0])
+	if len(removed) > 1 {
+		msg = fmt.Sprintf(i18n.G("Remove snaps %s"), strings.Join(removed, ", "))
+	}
+	return msg, tsets, nil
 }
 
 func snapRollback(inst *snapInstruction, st *state.State) (string, []*state.TaskSet, error) {
@@ -686,6 +1268,7 @@ func postSnap(c *Command, r *http.Request, user *auth.UserState) Response {
 
 	vars := muxVars(r)
 	inst.snap = vars["name"]
+	inst.Snaps = nil
 
 	impl := inst.dispatch()
 	if impl == nil {
@@ -697,9 +1280,98 @@ func postSnap(c *Command, r *http.Request, user *auth.UserState) Response {
 		return InternalError("cannot %s %q: %v", inst.Action, inst.snap, err)
 	}
 
-	chg := newChange(state, inst.Action+"-snap", msg, tsets)
-	chg.Set("snap-names", []string{inst.snap})
-	state.EnsureBefore(0)
+	return snapInstructionChange(state, &inst, msg, tsets)
+}
+
+// postSnaps operates on many snaps with a JSON request, or sideloads
+// a snap with a multipart/form-data one.
+func postSnaps(c *Command, r *http.Request, user *auth.UserState) Response {
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		return sideloadSnap(c, r, user)
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	var inst snapInstruction
+	if err := decoder.Decode(&inst); err != nil {
+		return BadRequest("cannot decode request body into snap instruction: %v", err)
+	}
+	// only removing many snaps, and refreshing them offline or to a
+	// refresh spec, is supported so far
+	switch {
+	case inst.Action == "refresh" && inst.Offline:
+		// no snaps given refreshes all that can be
+	case inst.Action == "refresh" && inst.Spec != nil:
+		// the spec names the snaps
+	case inst.Action != "remove":
+		return BadRequest("unsupported multi-snap operation %q", inst.Action)
+	case len(inst.Snaps) == 0:
+		return BadRequest("cannot %s: no snaps given", inst.Action)
+	}
+
+	state := c.d.overlord.State()
+	state.Lock()
+	defer state.Unlock()
+
+	if user != nil {
+		inst.userID = user.ID
+	}
+
+	msg, tsets, err := inst.dispatch()(&inst, state)
+	if err == errNothingToRefresh {
+		return SyncResponse(&resp{
+			Type: ResponseTypeError,
+			Result: &errorResult{
+				Kind:    errorKindNothingToRefresh,
+				Message: err.Error(),
+			},
+			Status: http.StatusBadRequest,
+		}, nil)
+	}
+	if err != nil && len(inst.Snaps) == 0 {
+		return InternalError("cannot %s: %v", inst.Action, err)
+	}
+	if err != nil {
+		return InternalError("cannot %s %s: %v", inst.Action, strings.Join(inst.Snaps, ", "), err)
+	}
+
+	return snapInstructionChange(state, &inst, msg, tsets)
+}
+
+// snapInstructionChange starts the change carrying out the instruction.
+func snapInstructionChange(st *state.State, inst *snapInstruction, msg string, tsets []*state.TaskSet) Response {
+	snapNames := inst.snapNames
+	if snapNames == nil {
+		snapNames = []string{inst.snap}
+	}
+
+	// a failure only undoes the operation on the snap of the failed
+	// task set, and what depends on it, unless asked otherwise
+	if inst.Transactional {
+		lane := st.NewLane()
+		for _, ts := range tsets {
+			ts.JoinLane(lane)
+		}
+	} else {
+		for _, ts := range tsets {
+			ts.JoinLane(st.NewLane())
+		}
+	}
+
+	chg := newChange(st, inst.Action+"-snap", msg, tsets)
+	chg.Set("snap-names", snapNames)
+	apiData := make(map[string][]string)
+	if len(inst.dependents) > 0 {
+		// the snaps losing the provider of their connected plugs
+		apiData["dependents"] = inst.dependents
+	}
+	if inst.Spec != nil {
+		// the snaps of the spec, for the client to show
+		apiData["snap-names"] = snapNames
+	}
+	if len(apiData) > 0 {
+		chg.Set("api-data", apiData)
+	}
+	st.EnsureBefore(0)
 
 	return AsyncResponse(nil, &Meta{Change: chg.ID()})
 }
@@ -773,6 +1445,9 @@ func sideloadSnap(c *Command, r *http.Request, user *auth.UserState) Response {
 	if len(form.Value["devmode"]) > 0 && form.Value["devmode"][0] == "true" {
 		flags |= snapstate.DevMode
 	}
+	if len(form.Value["accept-license"]) > 0 && form.Value["accept-license"][0] == "true" {
+		flags |= snapstate.AcceptLicense
+	}
 	if release.ReleaseInfo.ForceDevMode() {
 		flags |= snapstate.DevMode
 	}
@@ -885,7 +1560,7 @@ func iconGet(st *state.State, name string) Response {
 	info, _, err := localSnapInfo(st, name)
 	if err != nil {
 		if err == errNoSnap {
-			return NotFound("cannot find snap %q", name)
+			return NotFound(i18n.G("cannot find snap %q"), name)
 		}
 		return InternalError("%v", err)
 	}
@@ -906,6 +1581,277 @@ func appIconGet(c *Command, r *http.Request, user *auth.UserState) Response {
 	return iconGet(c.d.overlord.State(), name)
 }
 
+// snapProcess resolves the process given by the pid parameter of the
+// request to the installed snap it belongs to.
+func snapProcess(c *Command, r *http.Request) (*cgroup.ProcessInfo, *snap.Info, Response) {
+	pid, err := strconv.Atoi(r.URL.Query().Get("pid"))
+	if err != nil || pid <= 0 {
+		return nil, nil, BadRequest("invalid pid %q", r.URL.Query().Get("pid"))
+	}
+
+	proc, err := cgroup.ProcessInfoFromPid(pid)
+	if err == cgroup.ErrNotSnap {
+		return nil, nil, NotFound("process %d is not part of any snap", pid)
+	}
+	if err != nil {
+		return nil, nil, InternalError("cannot determine the snap of process %d: %v", pid, err)
+	}
+
+	info, _, err := localSnapInfo(c.d.overlord.State(), proc.Snap)
+	if err == errNoSnap {
+		return nil, nil, NotFound("cannot find snap %q of process %d", proc.Snap, pid)
+	}
+	if err != nil {
+		return nil, nil, InternalError("%v", err)
+	}
+
+	return proc, info, nil
+}
+
+// getCgroupInfo returns the snap application or hook a process belongs to.
+func getCgroupInfo(c *Command, r *http.Request, user *auth.UserState) Response {
+	proc, info, rsp := snapProcess(c, r)
+	if rsp != nil {
+		return rsp
+	}
+
+	result := cgroupInfo{
+		Snap:     proc.Snap,
+		App:      proc.App,
+		Hook:     proc.Hook,
+		Revision: proc.Revision,
+	}
+	if result.Revision.Unset() {
+		result.Revision = info.Revision
+	}
+
+	return SyncResponse(result, nil)
+}
+
+// getPortalInfo returns information about the snap the given process
+// belongs to, for xdg-desktop-portal.
+func getPortalInfo(c *Command, r *http.Request, user *auth.UserState) Response {
+	proc, info, rsp := snapProcess(c, r)
+	if rsp != nil {
+		return rsp
+	}
+
+	result := portalInfo{Snap: proc.Snap}
+	if _, ok := info.Apps[proc.App]; ok {
+		result.App = proc.App
+		result.DesktopFile = snapDesktopFile(info, proc.App)
+		repo := c.d.overlord.InterfaceManager().Repository()
+		result.HasNetwork = appHasNetwork(repo, proc.Snap, proc.App)
+	}
+
+	return SyncResponse(result, nil)
+}
+
+// getSBOM returns a software bill of materials for the installed snaps,
+// or for the ones given with snaps=, in the format given with format=.
+func getSBOM(c *Command, r *http.Request, user *auth.UserState) Response {
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = sbom.SPDX
+	}
+	if format != sbom.SPDX && format != sbom.CycloneDX {
+		return BadRequest("unknown bill of materials format %q", format)
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	infos, err := snapstate.ActiveInfos(st)
+	st.Unlock()
+	if err != nil {
+		return InternalError("cannot list installed snaps: %v", err)
+	}
+
+	if names := query.Get("snaps"); names != "" {
+		installed := make(map[string]*snap.Info, len(infos))
+		for _, info := range infos {
+			installed[info.Name()] = info
+		}
+		infos = nil
+		for _, name := range strings.Split(names, ",") {
+			info := installed[name]
+			if info == nil {
+				return NotFound("cannot find snap %q", name)
+			}
+			infos = append(infos, info)
+		}
+	}
+
+	db, err := c.d.overlord.AssertManager().DB()
+	if err != nil {
+		return InternalError("%v", err)
+	}
+	components, err := sbom.Components(infos, db)
+	if err != nil {
+		return InternalError("%v", err)
+	}
+	doc, err := sbom.Document(format, components, time.Now())
+	if err != nil {
+		return InternalError("%v", err)
+	}
+
+	return SyncResponse(doc, nil)
+}
+
+// securityWarningJSON is a warning about an installed snap revision
+// affected by a security advisory.
+type securityWarningJSON struct {
+	Kind string `json:"kind"`
+	*snapstate.SecurityWarning
+}
+
+// getWarnings lists the warnings about the installed snaps; for now
+// only the security ones, which select=security restricts them to.
+func getWarnings(c *Command, r *http.Request, user *auth.UserState) Response {
+	switch sel := r.URL.Query().Get("select"); sel {
+	case "", "all", "security":
+	default:
+		return BadRequest("invalid select parameter: %q", sel)
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	warnings, err := snapstate.SecurityWarnings(st)
+	st.Unlock()
+	if err != nil {
+		return InternalError("cannot list security warnings: %v", err)
+	}
+
+	results := make([]*securityWarningJSON, len(warnings))
+	for i, w := range warnings {
+		results[i] = &securityWarningJSON{Kind: "security", SecurityWarning: w}
+	}
+	return SyncResponse(results, nil)
+}
+
+// connectivityChecker is implemented by stores that can check the
+// reachability of the endpoints they use.
+type connectivityChecker interface {
+	CheckConnectivity() []*store.ConnectivityResult
+}
+
+// getConnectivity checks the reachability of the endpoints of the store,
+// through the proxies in effect for snapd.
+func getConnectivity(c *Command, r *http.Request, user *auth.UserState) Response {
+	checker, ok := getStore(c).(connectivityChecker)
+	if !ok {
+		return InternalError("store does not support connectivity checks")
+	}
+	return SyncResponse(checker.CheckConnectivity(), nil)
+}
+
+// getErrorReports returns the error reports kept, the ones submitted
+// and the ones queued for submission.
+func getErrorReports(c *Command, r *http.Request, user *auth.UserState) Response {
+	reports, err := errtracker.Reports()
+	if err != nil {
+		return InternalError("cannot list error reports: %v", err)
+	}
+	return SyncResponse(reports, nil)
+}
+
+// getQuarantine returns the snap files kept in quarantine as their
+// installation was refused.
+func getQuarantine(c *Command, r *http.Request, user *auth.UserState) Response {
+	quarantined, err := snapstate.Quarantined()
+	if err != nil {
+		return InternalError("cannot list quarantined snaps: %v", err)
+	}
+	return SyncResponse(quarantined, nil)
+}
+
+// postQuarantine purges the snap files with the given ids from the
+// quarantine, or all of them, returning the ones purged.
+func postQuarantine(c *Command, r *http.Request, user *auth.UserState) Response {
+	var reqData struct {
+		Action string   `json:"action"`
+		IDs    []string `json:"ids"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&reqData); err != nil {
+		return BadRequest("cannot decode data from request body: %v", err)
+	}
+
+	if reqData.Action != "purge" {
+		return BadRequest("quarantine action %q is unsupported", reqData.Action)
+	}
+
+	purged, err := snapstate.PurgeQuarantine(reqData.IDs)
+	if err != nil {
+		return BadRequest("cannot purge quarantine: %v", err)
+	}
+	return SyncResponse(purged, nil)
+}
+
+// getTimeWarp reports the time bounds assertions are checked against,
+// to debug devices whose clock cannot be trusted.
+func getTimeWarp(c *Command, r *http.Request, user *auth.UserState) Response {
+	bounds, err := c.d.overlord.AssertManager().TimeBounds()
+	if err != nil {
+		return InternalError("cannot get time bounds: %v", err)
+	}
+
+	m := map[string]interface{}{
+		"clock":        bounds.Clock,
+		"check-time":   bounds.Check,
+		"clock-behind": bounds.Clock.Before(bounds.Check),
+	}
+	if !bounds.MaxSeen.IsZero() {
+		m["max-seen-time"] = bounds.MaxSeen
+	}
+	if !bounds.Earliest.IsZero() {
+		m["earliest-time"] = bounds.Earliest
+	}
+	return SyncResponse(m, nil)
+}
+
+// getLockStats reports who holds the state lock and for how long the
+// operations waited for it and held it, without taking it itself.
+func getLockStats(c *Command, r *http.Request, user *auth.UserState) Response {
+	return SyncResponse(c.d.overlord.State().LockStats(), nil)
+}
+
+// strayPolicyJSON is a framework policy file of a snap that is not
+// installed.
+type strayPolicyJSON struct {
+	Snap string `json:"snap"`
+	Path string `json:"path"`
+}
+
+// getStrayPolicy reports the framework policy files of the snaps that
+// are not installed, as left behind by frameworks whose removal went
+// wrong.
+func getStrayPolicy(c *Command, r *http.Request, user *auth.UserState) Response {
+	st := c.d.overlord.State()
+	st.Lock()
+	all, err := snapstate.All(st)
+	st.Unlock()
+	if err != nil {
+		return InternalError("cannot get the installed snaps: %v", err)
+	}
+	installed := make([]string, 0, len(all))
+	for name := range all {
+		installed = append(installed, name)
+	}
+
+	plan, err := policy.New(policy.WithRootDir(dirs.GlobalRootDir)).PlanGC(installed)
+	if err != nil {
+		return InternalError("cannot find stray framework policy: %v", err)
+	}
+	stray := make([]strayPolicyJSON, len(plan))
+	for i, op := range plan {
+		base := filepath.Base(op.Path)
+		stray[i] = strayPolicyJSON{Snap: base[:strings.Index(base, "_")], Path: op.Path}
+	}
+	return SyncResponse(stray, nil)
+}
+
 // getInterfaces returns all plugs and slots.
 func getInterfaces(c *Command, r *http.Request, user *auth.UserState) Response {
 	repo := c.d.overlord.InterfaceManager().Repository()
@@ -1006,8 +1952,11 @@ func doAssert(c *Command, r *http.Request, user *auth.UserState) Response {
 		return BadRequest("cannot decode request body into an assertion: %v", err)
 	}
 	// TODO/XXX: turn this into a Change/Task combination
-	amgr := c.d.overlord.AssertManager()
-	if err := amgr.DB().Add(a); err != nil {
+	db, err := c.d.overlord.AssertManager().DB()
+	if err != nil {
+		return InternalError("%v", err)
+	}
+	if err := db.Add(a); err != nil {
 		// TODO: have a specific error to be able to return  409 for not newer revision?
 		return BadRequest("assert failed: %v", err)
 	}
@@ -1029,8 +1978,11 @@ func assertsFindMany(c *Command, r *http.Request, user *auth.UserState) Response
 	for k := range q {
 		headers[k] = q.Get(k)
 	}
-	amgr := c.d.overlord.AssertManager()
-	assertions, err := amgr.DB().FindMany(assertType, headers)
+	db, err := c.d.overlord.AssertManager().DB()
+	if err != nil {
+		return InternalError("%v", err)
+	}
+	assertions, err := db.FindMany(assertType, headers)
 	if err == asserts.ErrNotFound {
 		return AssertResponse(nil, true)
 	} else if err != nil {
@@ -1051,6 +2003,11 @@ type changeInfo struct {
 	Tasks   []*taskInfo `json:"tasks,omitempty"`
 	Ready   bool        `json:"ready"`
 	Err     string      `json:"err,omitempty"`
+	// ErrorCode classifies the cause of a failed change
+	ErrorCode string `json:"error-code,omitempty"`
+	// RebootRequired is whether the change needs a reboot to be
+	// fully applied
+	RebootRequired bool `json:"reboot-required,omitempty"`
 
 	SpawnTime time.Time  `json:"spawn-time,omitempty"`
 	ReadyTime *time.Time `json:"ready-time,omitempty"`
@@ -1065,6 +2022,11 @@ type taskInfo struct {
 	Status   string           `json:"status"`
 	Log      []string         `json:"log,omitempty"`
 	Progress taskInfoProgress `json:"progress"`
+	// Output is the end of the output of a hook or failed service
+	// run by the task
+	Output string `json:"output,omitempty"`
+	// WaitTasks are the IDs of the tasks the task waits for
+	WaitTasks []string `json:"wait-tasks,omitempty"`
 
 	SpawnTime time.Time  `json:"spawn-time,omitempty"`
 	ReadyTime *time.Time `json:"ready-time,omitempty"`
@@ -1092,6 +2054,18 @@ func change2changeInfo(chg *state.Change) *changeInfo {
 	}
 	if err := chg.Err(); err != nil {
 		chgInfo.Err = err.Error()
+		// the snap manager records it on its next ensure
+		code := snapstate.ChangeErrorCode(chg)
+		if code == "" {
+			code = snapstate.ClassifyChange(chg)
+		}
+		chgInfo.ErrorCode = string(code)
+	}
+	if status == state.DoneStatus {
+		var rebootRequired bool
+		if err := chg.Get("reboot-required", &rebootRequired); err == nil {
+			chgInfo.RebootRequired = rebootRequired
+		}
 	}
 
 	tasks := chg.Tasks()
@@ -1104,6 +2078,7 @@ func change2changeInfo(chg *state.Change) *changeInfo {
 			Summary: t.Summary(),
 			Status:  t.Status().String(),
 			Log:     t.Log(),
+			Output:  t.Output(),
 			Progress: taskInfoProgress{
 				Done:  done,
 				Total: total,
@@ -1114,6 +2089,9 @@ func change2changeInfo(chg *state.Change) *changeInfo {
 		if !readyTime.IsZero() {
 			taskInfo.ReadyTime = &readyTime
 		}
+		for _, wt := range t.WaitTasks() {
+			taskInfo.WaitTasks = append(taskInfo.WaitTasks, wt.ID())
+		}
 		taskInfos[j] = taskInfo
 	}
 	chgInfo.Tasks = taskInfos
@@ -1122,6 +2100,18 @@ func change2changeInfo(chg *state.Change) *changeInfo {
 	if chg.Get("api-data", &data) == nil {
 		chgInfo.Data = data
 	}
+	if _, ok := chgInfo.Data["snap-results"]; !ok {
+		// the outcome for each snap of a change on several snaps
+		if results := snapstate.SnapResults(chg); results != nil {
+			if raw, err := json.Marshal(results); err == nil {
+				if chgInfo.Data == nil {
+					chgInfo.Data = make(map[string]*json.RawMessage)
+				}
+				rawResults := json.RawMessage(raw)
+				chgInfo.Data["snap-results"] = &rawResults
+			}
+		}
+	}
 
 	return chgInfo
 }
@@ -1133,7 +2123,7 @@ func getChange(c *Command, r *http.Request, user *auth.UserState) Response {
 	defer state.Unlock()
 	chg := state.Change(chID)
 	if chg == nil {
-		return NotFound("cannot find change with id %q", chID)
+		return NotFound(i18n.G("cannot find change with id %q"), chID)
 	}
 
 	return SyncResponse(change2changeInfo(chg), nil)
@@ -1201,7 +2191,7 @@ func abortChange(c *Command, r *http.Request, user *auth.UserState) Response {
 	defer state.Unlock()
 	chg := state.Change(chID)
 	if chg == nil {
-		return NotFound("cannot find change with id %q", chID)
+		return NotFound(i18n.G("cannot find change with id %q"), chID)
 	}
 
 	var reqData struct {
@@ -1225,3 +2215,63 @@ func abortChange(c *Command, r *http.Request, user *auth.UserState) Response {
 
 	return SyncResponse(change2changeInfo(chg), nil)
 }
+
+func postReboot(c *Command, r *http.Request, user *auth.UserState) Response {
+	var reqData struct {
+		Action string `json:"action"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&reqData); err != nil {
+		return BadRequest("cannot decode data from request body: %v", err)
+	}
+
+	if reqData.Action != "now" {
+		return BadRequest("reboot action %q is unsupported", reqData.Action)
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	if err := snapstate.RebootNow(st); err != nil {
+		return BadRequest("cannot reboot: %v", err)
+	}
+
+	return SyncResponse(nil, nil)
+}
+
+// postServices restarts the running services of the snaps, for them to
+// pick up changes to the system, like to its locale.
+func postServices(c *Command, r *http.Request, user *auth.UserState) Response {
+	var reqData struct {
+		Action string `json:"action"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&reqData); err != nil {
+		return BadRequest("cannot decode data from request body: %v", err)
+	}
+
+	if reqData.Action != "restart" {
+		return BadRequest("services action %q is unsupported", reqData.Action)
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	names, ts, err := snapstate.RestartServices(st)
+	if err != nil {
+		return InternalError("cannot restart services: %v", err)
+	}
+	if len(names) == 0 {
+		// nothing to restart
+		return SyncResponse(nil, nil)
+	}
+
+	chg := newChange(st, "restart-services", i18n.G("Restart the running services of snaps"), []*state.TaskSet{ts})
+	chg.Set("snap-names", names)
+
+	st.EnsureBefore(0)
+
+	return AsyncResponse(nil, &Meta{Change: chg.ID()})
+}
@@ -21,6 +21,7 @@ package daemon
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -33,22 +34,30 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strconv"
 	"time"
 
 	"gopkg.in/check.v1"
 
+	"github.com/snapcore/snapd/arch"
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/errtracker"
+	"github.com/snapcore/snapd/fips"
 	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
 	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/configstate"
 	"github.com/snapcore/snapd/overlord/ifacestate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/policy"
 	"github.com/snapcore/snapd/progress"
 	"github.com/snapcore/snapd/release"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/snaptest"
 	"github.com/snapcore/snapd/store"
+	"github.com/snapcore/snapd/systemd"
 	"github.com/snapcore/snapd/testutil"
 )
 
@@ -57,17 +66,33 @@ type apiSuite struct {
 	err               error
 	vars              map[string]string
 	searchTerm        string
+	snapName          string
 	channel           string
 	suggestedCurrency string
 	d                 *Daemon
 	auther            store.Authenticator
 	restoreBackends   func()
 	refreshCandidates []*store.RefreshCandidate
+	connectivity      []*store.ConnectivityResult
 }
 
 var _ = check.Suite(&apiSuite{})
 
+func (s *apiSuite) SnapForArchitecture(name, channel, architecture string, auther store.Authenticator) (*snap.Info, error) {
+	return s.Snap(name, channel, auther)
+}
+
 func (s *apiSuite) Snap(name, channel string, auther store.Authenticator) (*snap.Info, error) {
+	s.auther = auther
+	s.snapName = name
+	s.channel = channel
+	if len(s.rsnaps) > 0 {
+		return s.rsnaps[0], s.err
+	}
+	return nil, s.err
+}
+
+func (s *apiSuite) SnapRevision(name string, revision snap.Revision, auther store.Authenticator) (*snap.Info, error) {
 	s.auther = auther
 	if len(s.rsnaps) > 0 {
 		return s.rsnaps[0], s.err
@@ -93,6 +118,14 @@ func (s *apiSuite) SuggestedCurrency() string {
 	return s.suggestedCurrency
 }
 
+func (s *apiSuite) Assertion(assertType *asserts.AssertionType, primaryKey []string, auther store.Authenticator) (asserts.Assertion, error) {
+	return nil, store.ErrAssertionNotFound
+}
+
+func (s *apiSuite) CheckConnectivity() []*store.ConnectivityResult {
+	return s.connectivity
+}
+
 func (s *apiSuite) Download(*snap.Info, progress.Meter, store.Authenticator) (string, error) {
 	panic("Download not expected to be called")
 }
@@ -124,6 +157,7 @@ func (s *apiSuite) SetUpTest(c *check.C) {
 	s.auther = nil
 	s.d = nil
 	s.refreshCandidates = nil
+	s.connectivity = nil
 	// Disable real security backends for all API tests
 	s.restoreBackends = ifacestate.MockSecurityBackends(nil)
 }
@@ -132,8 +166,13 @@ func (s *apiSuite) TearDownTest(c *check.C) {
 	s.d = nil
 	s.restoreBackends()
 	snapstateInstall = snapstate.Install
+	snapstateInstallRevision = snapstate.InstallRevision
+	snapstateUpdate = snapstate.Update
+	snapstateUpdateToRevision = snapstate.UpdateToRevision
+	snapstateUpdateOffline = snapstate.UpdateOffline
 	snapstateGet = snapstate.Get
 	snapstateInstallPath = snapstate.InstallPath
+	ifacestateRemoveMany = ifacestate.RemoveMany
 	readSnapInfo = readSnapInfoImpl
 }
 
@@ -260,6 +299,105 @@ func (s *apiSuite) TestSnapInfoOneIntegration(c *check.C) {
 	c.Check(rsp.Result, check.DeepEquals, expected.Result)
 }
 
+func (s *apiSuite) TestSnapInfoLicense(c *check.C) {
+	d := s.daemon(c)
+	s.vars = map[string]string{"name": "licensed"}
+
+	s.mkInstalledInState(c, d, "licensed", "bar", "v1", snap.R(10), true, "license-agreement: explicit")
+
+	req, err := http.NewRequest("GET", "/v2/snaps/licensed", nil)
+	c.Assert(err, check.IsNil)
+	rsp := getSnapInfo(snapCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	m := rsp.Result.(map[string]interface{})
+	c.Check(m["license-agreement"], check.Equals, "explicit")
+	c.Check(m["license-accepted"], check.Equals, false)
+}
+
+func (s *apiSuite) TestGetSnapLicense(c *check.C) {
+	d := s.daemon(c)
+	s.vars = map[string]string{"name": "licensed"}
+
+	info := s.mkInstalledInState(c, d, "licensed", "bar", "v1", snap.R(10), true, "license-agreement: explicit")
+	c.Assert(ioutil.WriteFile(info.LicenseFile(), []byte("You must agree.\n"), 0644), check.IsNil)
+
+	req, err := http.NewRequest("GET", "/v2/snaps/licensed/license", nil)
+	c.Assert(err, check.IsNil)
+	rsp := getSnapLicense(snapLicenseCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.DeepEquals, licenseData{
+		Intro:   "licensed requires that you accept the following license before its services are started",
+		License: "You must agree.\n",
+	})
+
+	// snaps without a license agreement have no license to show
+	s.vars = map[string]string{"name": "bar"}
+	s.mkInstalledInState(c, d, "bar", "bar", "v1", snap.R(1), true, "")
+	rsp = getSnapLicense(snapLicenseCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, http.StatusNotFound)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `snap "bar" does not require accepting its license`)
+
+	s.vars = map[string]string{"name": "baz"}
+	rsp = getSnapLicense(snapLicenseCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, http.StatusNotFound)
+}
+
+func (s *apiSuite) TestPostSnapLicense(c *check.C) {
+	d := s.daemon(c)
+	d.overlord.Loop()
+	defer d.overlord.Stop()
+	s.vars = map[string]string{"name": "licensed"}
+
+	s.mkInstalledInState(c, d, "licensed", "bar", "v1", snap.R(10), true, "license-agreement: explicit")
+
+	req, err := http.NewRequest("POST", "/v2/snaps/licensed/license", bytes.NewBufferString(`{"agreed": false}`))
+	c.Assert(err, check.IsNil)
+	rsp := postSnapLicense(snapLicenseCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, http.StatusBadRequest)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `cannot accept the license of snap "licensed": not agreed to`)
+
+	req, err = http.NewRequest("POST", "/v2/snaps/licensed/license", bytes.NewBufferString(`{"agreed": true}`))
+	c.Assert(err, check.IsNil)
+	rsp = postSnapLicense(snapLicenseCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeAsync)
+
+	st := d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Kind(), check.Equals, "accept-license")
+	c.Check(chg.Summary(), check.Equals, `Accept the license of snap "licensed"`)
+	tasks := chg.Tasks()
+	c.Assert(tasks, check.HasLen, 1)
+	c.Check(tasks[0].Kind(), check.Equals, "accept-license")
+}
+
+func (s *apiSuite) TestInstallAcceptLicense(c *check.C) {
+	var calledFlags snapstate.Flags
+
+	snapstateInstall = func(s *state.State, name, channel string, userID int, flags snapstate.Flags) (*state.TaskSet, error) {
+		calledFlags = flags
+
+		t := s.NewTask("fake-install-snap", "Doing a fake install")
+		return state.NewTaskSet(t), nil
+	}
+
+	d := s.daemon(c)
+	inst := &snapInstruction{
+		Action:  "install",
+		License: &licenseData{Agreed: true},
+	}
+
+	st := d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	_, _, err := inst.dispatch()(inst, st)
+	c.Check(err, check.IsNil)
+
+	c.Check(calledFlags&snapstate.AcceptLicense, check.Equals, snapstate.Flags(snapstate.AcceptLicense))
+}
+
 func (s *apiSuite) TestSnapInfoWithAuth(c *check.C) {
 	state := snapCmd.d.overlord.State()
 	state.Lock()
@@ -340,13 +478,18 @@ func (s *apiSuite) TestListIncludesAll(c *check.C) {
 		"maxReadBuflen",
 		"muxVars",
 		"errNothingToInstall",
+		"errNothingToRefresh",
 		// snapInstruction vars:
 		"snapInstructionDispTable",
 		"snapstateInstall",
+		"snapstateInstallRevision",
 		"snapstateUpdate",
+		"snapstateUpdateToRevision",
+		"snapstateUpdateOffline",
 		"snapstateInstallPath",
 		"snapstateTryPath",
 		"snapstateGet",
+		"ifacestateRemoveMany",
 		"readSnapInfo",
 	}
 	c.Check(found, check.Equals, len(api)+len(exceptions),
@@ -393,6 +536,7 @@ func (s *apiSuite) TestSysInfo(c *check.C) {
 	expected := map[string]interface{}{
 		"series":  "16",
 		"version": "42b1",
+		"crypto":  map[string]interface{}{"provider": "go", "fips-mode": false},
 	}
 	var rsp resp
 	c.Assert(json.Unmarshal(rec.Body.Bytes(), &rsp), check.IsNil)
@@ -401,6 +545,21 @@ func (s *apiSuite) TestSysInfo(c *check.C) {
 	c.Check(rsp.Result, check.DeepEquals, expected)
 }
 
+func (s *apiSuite) TestSysInfoFIPSMode(c *check.C) {
+	restore := fips.MockEnabled(true)
+	defer restore()
+	s.daemon(c)
+
+	rec := httptest.NewRecorder()
+	sysInfoCmd.GET(sysInfoCmd, nil, nil).ServeHTTP(rec, nil)
+	c.Check(rec.Code, check.Equals, 200)
+
+	var rsp resp
+	c.Assert(json.Unmarshal(rec.Body.Bytes(), &rsp), check.IsNil)
+	m := rsp.Result.(map[string]interface{})
+	c.Check(m["crypto"], check.DeepEquals, map[string]interface{}{"provider": "go", "fips-mode": true})
+}
+
 func (s *apiSuite) makeMyAppsServer(statusCode int, data string) *httptest.Server {
 	mockMyAppsServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(statusCode)
@@ -419,6 +578,196 @@ func (s *apiSuite) makeSSOServer(statusCode int, data string) *httptest.Server {
 	return mockSSOServer
 }
 
+func (s *apiSuite) TestSysInfoRefreshStatus(c *check.C) {
+	d := s.daemon(c)
+	d.Version = "42b1"
+
+	st := d.overlord.State()
+	st.Lock()
+	st.Set("refresh-status", &snapstate.RefreshStatus{
+		LastAttempt: time.Date(2016, 4, 21, 1, 2, 3, 0, time.UTC),
+		NextAttempt: time.Date(2016, 4, 21, 1, 22, 3, 0, time.UTC),
+		Failures:    2,
+		LastError:   "store is down",
+	})
+	st.Unlock()
+
+	rec := httptest.NewRecorder()
+	sysInfoCmd.GET(sysInfoCmd, nil, nil).ServeHTTP(rec, nil)
+	c.Check(rec.Code, check.Equals, 200)
+
+	var rsp resp
+	c.Assert(json.Unmarshal(rec.Body.Bytes(), &rsp), check.IsNil)
+	c.Check(rsp.Result, check.DeepEquals, map[string]interface{}{
+		"series":  "16",
+		"version": "42b1",
+		"crypto":  map[string]interface{}{"provider": "go", "fips-mode": false},
+		"refresh": map[string]interface{}{
+			"last":       "2016-04-21T01:02:03Z",
+			"next":       "2016-04-21T01:22:03Z",
+			"failures":   2.,
+			"last-error": "store is down",
+		},
+	})
+}
+
+func (s *apiSuite) TestSysInfoRebootRequired(c *check.C) {
+	d := s.daemon(c)
+	d.Version = "42b1"
+
+	st := d.overlord.State()
+	st.Lock()
+	st.Set("reboot-required", map[string]interface{}{
+		"snaps":   []string{"core", "pc-kernel"},
+		"boot-id": "",
+	})
+	st.Unlock()
+
+	rec := httptest.NewRecorder()
+	sysInfoCmd.GET(sysInfoCmd, nil, nil).ServeHTTP(rec, nil)
+	c.Check(rec.Code, check.Equals, 200)
+
+	var rsp resp
+	c.Assert(json.Unmarshal(rec.Body.Bytes(), &rsp), check.IsNil)
+	c.Check(rsp.Result, check.DeepEquals, map[string]interface{}{
+		"series":          "16",
+		"version":         "42b1",
+		"crypto":          map[string]interface{}{"provider": "go", "fips-mode": false},
+		"reboot-required": []interface{}{"core", "pc-kernel"},
+	})
+}
+
+func (s *apiSuite) TestSysInfoRebootAt(c *check.C) {
+	d := s.daemon(c)
+	d.Version = "42b1"
+
+	st := d.overlord.State()
+	st.Lock()
+	st.Set("reboot-required", map[string]interface{}{
+		"snaps":     []string{"pc-kernel"},
+		"boot-id":   "",
+		"reboot-at": time.Date(2016, 8, 7, 3, 0, 0, 0, time.UTC),
+	})
+	st.Unlock()
+
+	rec := httptest.NewRecorder()
+	sysInfoCmd.GET(sysInfoCmd, nil, nil).ServeHTTP(rec, nil)
+	c.Check(rec.Code, check.Equals, 200)
+
+	var rsp resp
+	c.Assert(json.Unmarshal(rec.Body.Bytes(), &rsp), check.IsNil)
+	c.Check(rsp.Result, check.DeepEquals, map[string]interface{}{
+		"series":          "16",
+		"version":         "42b1",
+		"crypto":          map[string]interface{}{"provider": "go", "fips-mode": false},
+		"reboot-required": []interface{}{"pc-kernel"},
+		"reboot-at":       "2016-08-07T03:00:00Z",
+	})
+}
+
+func (s *apiSuite) TestPostRebootNow(c *check.C) {
+	rebooted := 0
+	restore := reboot
+	reboot = func() error {
+		rebooted++
+		return nil
+	}
+	defer func() { reboot = restore }()
+
+	d := s.daemon(c)
+	st := d.overlord.State()
+	st.Lock()
+	st.Set("reboot-required", map[string]interface{}{
+		"snaps":   []string{"pc-kernel"},
+		"boot-id": "",
+	})
+	st.Unlock()
+
+	req, err := http.NewRequest("POST", "/v2/reboot", bytes.NewBufferString(`{"action": "now"}`))
+	c.Assert(err, check.IsNil)
+	rsp := postReboot(rebootCmd, req, nil).(*resp)
+	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rebooted, check.Equals, 1)
+}
+
+func (s *apiSuite) TestPostRebootErrors(c *check.C) {
+	s.daemon(c)
+
+	for _, t := range []struct {
+		body string
+		err  string
+	}{
+		{`{"action": "later"}`, `reboot action "later" is unsupported`},
+		{`{"action": "now"}`, `cannot reboot: no reboot is pending`},
+		{`}`, `cannot decode data from request body: .*`},
+	} {
+		req, err := http.NewRequest("POST", "/v2/reboot", bytes.NewBufferString(t.body))
+		c.Assert(err, check.IsNil)
+		rsp := postReboot(rebootCmd, req, nil).(*resp)
+		c.Check(rsp.Type, check.Equals, ResponseTypeError)
+		c.Check(rsp.Status, check.Equals, http.StatusBadRequest)
+		c.Check(rsp.Result.(*errorResult).Message, check.Matches, t.err)
+	}
+}
+
+func (s *apiSuite) TestPostServicesRestart(c *check.C) {
+	oldSystemctlCmd := systemd.SystemctlCmd
+	systemd.SystemctlCmd = func(cmd ...string) ([]byte, error) {
+		return []byte("ActiveState=inactive\n"), nil
+	}
+	defer func() { systemd.SystemctlCmd = oldSystemctlCmd }()
+
+	d := s.daemon(c)
+	d.overlord.Loop()
+	defer d.overlord.Stop()
+	s.mkInstalledInState(c, d, "foo", "bar", "v1", snap.R(10), true, "apps: {svc: {command: foo, daemon: simple}}")
+	s.mkInstalledInState(c, d, "baz", "bar", "v1", snap.R(10), true, "apps: {app: {command: baz}}")
+
+	req, err := http.NewRequest("POST", "/v2/services", bytes.NewBufferString(`{"action": "restart"}`))
+	c.Assert(err, check.IsNil)
+	rsp := postServices(servicesCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeAsync)
+
+	st := d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Kind(), check.Equals, "restart-services")
+	var names []string
+	c.Assert(chg.Get("snap-names", &names), check.IsNil)
+	c.Check(names, check.DeepEquals, []string{"foo"})
+}
+
+func (s *apiSuite) TestPostServicesNothingToRestart(c *check.C) {
+	d := s.daemon(c)
+	s.mkInstalledInState(c, d, "baz", "bar", "v1", snap.R(10), true, "apps: {app: {command: baz}}")
+
+	req, err := http.NewRequest("POST", "/v2/services", bytes.NewBufferString(`{"action": "restart"}`))
+	c.Assert(err, check.IsNil)
+	rsp := postServices(servicesCmd, req, nil).(*resp)
+	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
+}
+
+func (s *apiSuite) TestPostServicesErrors(c *check.C) {
+	s.daemon(c)
+
+	for _, t := range []struct {
+		body string
+		err  string
+	}{
+		{`{"action": "stop"}`, `services action "stop" is unsupported`},
+		{`}`, `cannot decode data from request body: .*`},
+	} {
+		req, err := http.NewRequest("POST", "/v2/services", bytes.NewBufferString(t.body))
+		c.Assert(err, check.IsNil)
+		rsp := postServices(servicesCmd, req, nil).(*resp)
+		c.Check(rsp.Type, check.Equals, ResponseTypeError)
+		c.Check(rsp.Status, check.Equals, http.StatusBadRequest)
+		c.Check(rsp.Result.(*errorResult).Message, check.Matches, t.err)
+	}
+}
+
 func (s *apiSuite) TestLoginUser(c *check.C) {
 	macaroon := `{"macaroon": "the-macaroon-serialized-data"}`
 	mockMyAppsServer := s.makeMyAppsServer(200, macaroon)
@@ -755,88 +1104,236 @@ func (s *apiSuite) TestFind(c *check.C) {
 	c.Check(s.refreshCandidates, check.HasLen, 0)
 }
 
-func (s *apiSuite) TestFindRefreshes(c *check.C) {
-	d := s.daemon(c)
-	d.overlord.Loop()
-	defer d.overlord.Stop()
-
+func (s *apiSuite) TestFindName(c *check.C) {
 	s.rsnaps = []*snap.Info{{
 		SideInfo: snap.SideInfo{
 			OfficialName: "store",
 			Developer:    "foo",
 		},
+		Publisher:   "Foo Inc.",
+		License:     "MIT",
+		Screenshots: []string{"https://example.com/shot.png"},
+		Channels: map[string]*snap.ChannelSnapInfo{
+			"stable": {Revision: snap.R(3), Version: "1.0", Confinement: snap.StrictConfinement},
+		},
 	}}
-	s.mockSnap(c, "name: foo\nversion: 1.0")
 
-	req, err := http.NewRequest("GET", "/v2/find?select=refresh", nil)
+	req, err := http.NewRequest("GET", "/v2/find?name=store&channel=beta", nil)
 	c.Assert(err, check.IsNil)
 
 	rsp := searchStore(findCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
 
 	snaps := snapList(rsp.Result)
 	c.Assert(snaps, check.HasLen, 1)
-	c.Assert(snaps[0]["name"], check.Equals, "store")
-	c.Check(s.refreshCandidates, check.HasLen, 1)
+	c.Check(snaps[0]["name"], check.Equals, "store")
+	c.Check(snaps[0]["publisher"], check.Equals, "Foo Inc.")
+	c.Check(snaps[0]["license"], check.Equals, "MIT")
+	c.Check(snaps[0]["screenshots"], check.DeepEquals, []interface{}{"https://example.com/shot.png"})
+	c.Check(snaps[0]["channels"], check.DeepEquals, map[string]interface{}{
+		"stable": map[string]interface{}{
+			"revision":    "3",
+			"version":     "1.0",
+			"confinement": "strict",
+		},
+	})
+
+	c.Check(s.snapName, check.Equals, "store")
+	c.Check(s.channel, check.Equals, "beta")
+	c.Check(s.searchTerm, check.Equals, "")
 }
 
-func (s *apiSuite) TestFindRefreshNotQ(c *check.C) {
-	req, err := http.NewRequest("GET", "/v2/find?select=refresh&q=foo", nil)
+func (s *apiSuite) TestFindNameNotFound(c *check.C) {
+	s.err = store.ErrSnapNotFound
+
+	req, err := http.NewRequest("GET", "/v2/find?name=missing", nil)
 	c.Assert(err, check.IsNil)
 
 	rsp := searchStore(findCmd, req, nil).(*resp)
-	c.Check(rsp.Type, check.Equals, ResponseTypeError)
-	c.Check(rsp.Status, check.Equals, http.StatusBadRequest)
-	c.Check(rsp.Result.(*errorResult).Message, check.Matches, "cannot use 'q' with 'select=refresh'")
+	c.Check(rsp.Status, check.Equals, http.StatusNotFound)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `cannot find snap "missing" in the store`)
 }
 
-func (s *apiSuite) TestFindPriced(c *check.C) {
-	s.suggestedCurrency = "GBP"
+func (s *apiSuite) TestFindNameConflicts(c *check.C) {
+	for _, q := range []string{"name=foo&q=foo", "name=foo&select=refresh"} {
+		req, err := http.NewRequest("GET", "/v2/find?"+q, nil)
+		c.Assert(err, check.IsNil)
+
+		rsp := searchStore(findCmd, req, nil).(*resp)
+		c.Check(rsp.Status, check.Equals, http.StatusBadRequest, check.Commentf(q))
+	}
+}
+
+func (s *apiSuite) TestFindRefreshes(c *check.C) {
+	d := s.daemon(c)
+	d.overlord.Loop()
+	defer d.overlord.Stop()
 
 	s.rsnaps = []*snap.Info{{
-		Type:    snap.TypeApp,
-		Version: "v2",
-		Prices: map[string]float64{
-			"GBP": 1.23,
-			"EUR": 2.34,
-		},
-		MustBuy: true,
 		SideInfo: snap.SideInfo{
-			OfficialName: "banana",
+			OfficialName: "store",
 			Developer:    "foo",
 		},
 	}}
+	s.mockSnap(c, "name: foo\nversion: 1.0")
 
-	req, err := http.NewRequest("GET", "/v2/find?q=banana&channel=stable", nil)
+	req, err := http.NewRequest("GET", "/v2/find?select=refresh", nil)
 	c.Assert(err, check.IsNil)
-	rsp, ok := searchStore(findCmd, req, nil).(*resp)
-	c.Assert(ok, check.Equals, true)
+
+	rsp := searchStore(findCmd, req, nil).(*resp)
 
 	snaps := snapList(rsp.Result)
 	c.Assert(snaps, check.HasLen, 1)
+	c.Assert(snaps[0]["name"], check.Equals, "store")
+	c.Check(s.refreshCandidates, check.HasLen, 1)
+}
 
-	snap := snaps[0]
-	c.Check(snap["name"], check.Equals, "banana")
-	c.Check(snap["prices"], check.DeepEquals, map[string]interface{}{
-		"EUR": 2.34,
-		"GBP": 1.23,
-	})
-	c.Check(snap["status"], check.Equals, "priced")
+func (s *apiSuite) TestFindRefreshesSkipsPinned(c *check.C) {
+	d := s.daemon(c)
 
-	c.Check(rsp.SuggestedCurrency, check.Equals, "GBP")
+	s.mkInstalledInState(c, d, "foo", "bar", "v1", snap.R(10), true, "")
+	st := d.overlord.State()
+	st.Lock()
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(st, "foo", &snapst), check.IsNil)
+	snapst.SetPinned(true)
+	snapstate.Set(st, "foo", &snapst)
+	st.Unlock()
+
+	req, err := http.NewRequest("GET", "/v2/find?select=refresh", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := searchStore(findCmd, req, nil).(*resp)
+	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(s.refreshCandidates, check.HasLen, 0)
 }
 
-func (s *apiSuite) TestSnapsInfoOnlyStore(c *check.C) {
+func (s *apiSuite) TestFindRefreshesHeldByRollout(c *check.C) {
 	d := s.daemon(c)
-
-	s.suggestedCurrency = "EUR"
+	d.overlord.Loop()
+	defer d.overlord.Stop()
 
 	s.rsnaps = []*snap.Info{{
 		SideInfo: snap.SideInfo{
 			OfficialName: "store",
 			Developer:    "foo",
 		},
+		// the device has no identity so it is never part of the release
+		RolloutPercentage: 50,
 	}}
-	s.mkInstalledInState(c, d, "local", "foo", "v1", snap.R(10), true, "")
+	s.mockSnap(c, "name: foo\nversion: 1.0")
+
+	req, err := http.NewRequest("GET", "/v2/find?select=refresh", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := searchStore(findCmd, req, nil).(*resp)
+	c.Check(snapList(rsp.Result), check.HasLen, 0)
+
+	req, err = http.NewRequest("GET", "/v2/find?select=refresh&unhold-rollout=true", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp = searchStore(findCmd, req, nil).(*resp)
+	snaps := snapList(rsp.Result)
+	c.Assert(snaps, check.HasLen, 1)
+	c.Check(snaps[0]["name"], check.Equals, "store")
+}
+
+func (s *apiSuite) TestFindRefreshesDetails(c *check.C) {
+	d := s.daemon(c)
+
+	restore := release.MockOnClassic(false)
+	defer restore()
+
+	s.mkInstalledInState(c, d, "foo", "bar", "v1", snap.R(10), true, "type: kernel")
+
+	s.rsnaps = []*snap.Info{{
+		SideInfo: snap.SideInfo{
+			SnapID:       "funky-snap-id",
+			OfficialName: "foo",
+			Developer:    "bar",
+			Revision:     snap.R(12),
+			Size:         4096,
+		},
+		Type:            snap.TypeKernel,
+		Version:         "v2",
+		DeltaSize:       512,
+		ReleaseNotesURL: "https://example.com/foo/12",
+	}}
+
+	req, err := http.NewRequest("GET", "/v2/find?select=refresh", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := searchStore(findCmd, req, nil).(*resp)
+	snaps := snapList(rsp.Result)
+	c.Assert(snaps, check.HasLen, 1)
+	c.Check(snaps[0]["version"], check.Equals, "v2")
+	c.Check(snaps[0]["revision"], check.Equals, "12")
+	c.Check(snaps[0]["current-version"], check.Equals, "v1")
+	c.Check(snaps[0]["current-revision"], check.Equals, "10")
+	c.Check(snaps[0]["download-size"], check.Equals, 4096.)
+	c.Check(snaps[0]["delta-size"], check.Equals, 512.)
+	c.Check(snaps[0]["release-notes-url"], check.Equals, "https://example.com/foo/12")
+	c.Check(snaps[0]["reboot-required"], check.Equals, true)
+}
+
+func (s *apiSuite) TestFindRefreshNotQ(c *check.C) {
+	req, err := http.NewRequest("GET", "/v2/find?select=refresh&q=foo", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := searchStore(findCmd, req, nil).(*resp)
+	c.Check(rsp.Type, check.Equals, ResponseTypeError)
+	c.Check(rsp.Status, check.Equals, http.StatusBadRequest)
+	c.Check(rsp.Result.(*errorResult).Message, check.Matches, "cannot use 'q' with 'select=refresh'")
+}
+
+func (s *apiSuite) TestFindPriced(c *check.C) {
+	s.suggestedCurrency = "GBP"
+
+	s.rsnaps = []*snap.Info{{
+		Type:    snap.TypeApp,
+		Version: "v2",
+		Prices: map[string]float64{
+			"GBP": 1.23,
+			"EUR": 2.34,
+		},
+		MustBuy: true,
+		SideInfo: snap.SideInfo{
+			OfficialName: "banana",
+			Developer:    "foo",
+		},
+	}}
+
+	req, err := http.NewRequest("GET", "/v2/find?q=banana&channel=stable", nil)
+	c.Assert(err, check.IsNil)
+	rsp, ok := searchStore(findCmd, req, nil).(*resp)
+	c.Assert(ok, check.Equals, true)
+
+	snaps := snapList(rsp.Result)
+	c.Assert(snaps, check.HasLen, 1)
+
+	snap := snaps[0]
+	c.Check(snap["name"], check.Equals, "banana")
+	c.Check(snap["prices"], check.DeepEquals, map[string]interface{}{
+		"EUR": 2.34,
+		"GBP": 1.23,
+	})
+	c.Check(snap["status"], check.Equals, "priced")
+
+	c.Check(rsp.SuggestedCurrency, check.Equals, "GBP")
+}
+
+func (s *apiSuite) TestSnapsInfoOnlyStore(c *check.C) {
+	d := s.daemon(c)
+
+	s.suggestedCurrency = "EUR"
+
+	s.rsnaps = []*snap.Info{{
+		SideInfo: snap.SideInfo{
+			OfficialName: "store",
+			Developer:    "foo",
+		},
+	}}
+	s.mkInstalledInState(c, d, "local", "foo", "v1", snap.R(10), true, "")
 
 	req, err := http.NewRequest("GET", "/v2/snaps?sources=store", nil)
 	c.Assert(err, check.IsNil)
@@ -1167,6 +1664,23 @@ func (s *apiSuite) TestSideloadSnapDevMode(c *check.C) {
 	c.Check(chgSummary, check.Equals, `Install "local" snap from file "x"`)
 }
 
+func (s *apiSuite) TestSideloadSnapAcceptLicense(c *check.C) {
+	body := "" +
+		"----hello--\r\n" +
+		"Content-Disposition: form-data; name=\"snap\"; filename=\"x\"\r\n" +
+		"\r\n" +
+		"xyzzy\r\n" +
+		"----hello--\r\n" +
+		"Content-Disposition: form-data; name=\"accept-license\"\r\n" +
+		"\r\n" +
+		"true\r\n" +
+		"----hello--\r\n"
+	head := map[string]string{"Content-Type": "multipart/thing; boundary=--hello--"}
+	restore := release.MockReleaseInfo(&release.OS{ID: "ubuntu"})
+	defer restore()
+	s.sideloadCheck(c, body, head, snapstate.AcceptLicense, true)
+}
+
 func (s *apiSuite) TestSideloadSnapNotValidFormFile(c *check.C) {
 	d := newTestDaemon(c)
 	d.overlord.Loop()
@@ -1367,141 +1881,1090 @@ func (s *apiSuite) TestAppIconGetInactive(c *check.C) {
 	req, err := http.NewRequest("GET", "/v2/icons/foo/icon", nil)
 	c.Assert(err, check.IsNil)
 
-	rec := httptest.NewRecorder()
+	rec := httptest.NewRecorder()
+
+	appIconCmd.GET(appIconCmd, req, nil).ServeHTTP(rec, req)
+	c.Check(rec.Code, check.Equals, 200)
+	c.Check(rec.Body.String(), check.Equals, "ick")
+}
+
+func (s *apiSuite) TestAppIconGetNoIcon(c *check.C) {
+	d := s.daemon(c)
+
+	// have an *in*active foo in the system
+	info := s.mkInstalledInState(c, d, "foo", "bar", "v1", snap.R(10), true, "")
+
+	// NO ICON!
+	err := os.RemoveAll(filepath.Join(info.MountDir(), "meta", "gui", "icon.svg"))
+	c.Assert(err, check.IsNil)
+
+	s.vars = map[string]string{"name": "foo"}
+	req, err := http.NewRequest("GET", "/v2/icons/foo/icon", nil)
+	c.Assert(err, check.IsNil)
+
+	rec := httptest.NewRecorder()
+
+	appIconCmd.GET(appIconCmd, req, nil).ServeHTTP(rec, req)
+	c.Check(rec.Code/100, check.Equals, 4)
+}
+
+func (s *apiSuite) TestAppIconGetNoApp(c *check.C) {
+	s.daemon(c)
+
+	s.vars = map[string]string{"name": "foo"}
+	req, err := http.NewRequest("GET", "/v2/icons/foo/icon", nil)
+	c.Assert(err, check.IsNil)
+
+	rec := httptest.NewRecorder()
+
+	appIconCmd.GET(appIconCmd, req, nil).ServeHTTP(rec, req)
+	c.Check(rec.Code, check.Equals, 404)
+}
+
+func (s *apiSuite) mockProcCgroup(c *check.C, pid int, content string) {
+	cgroupFile := filepath.Join(dirs.GlobalRootDir, "proc", strconv.Itoa(pid), "cgroup")
+	c.Assert(os.MkdirAll(filepath.Dir(cgroupFile), 0755), check.IsNil)
+	c.Assert(ioutil.WriteFile(cgroupFile, []byte(content), 0644), check.IsNil)
+}
+
+func (s *apiSuite) getPortalInfo(c *check.C, pid string) *resp {
+	req, err := http.NewRequest("GET", "/v2/portal-info?pid="+pid, nil)
+	c.Assert(err, check.IsNil)
+	return portalInfoCmd.GET(portalInfoCmd, req, nil).(*resp)
+}
+
+func (s *apiSuite) TestPortalInfo(c *check.C) {
+	d := s.daemon(c)
+	s.mkInstalledInState(c, d, "foo", "bar", "v1", snap.R(10), true, "apps: {app: {command: foo}}")
+	desktopFile := filepath.Join(dirs.SnapDesktopFilesDir, "foo_app.desktop")
+	c.Assert(os.MkdirAll(dirs.SnapDesktopFilesDir, 0755), check.IsNil)
+	c.Assert(ioutil.WriteFile(desktopFile, nil, 0644), check.IsNil)
+
+	s.mockProcCgroup(c, 42, `11:devices:/snap.foo.app
+10:memory:/user.slice
+1:name=systemd:/user.slice/user-1000.slice/session-c2.scope
+`)
+
+	rsp := s.getPortalInfo(c, "42")
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.DeepEquals, portalInfo{
+		Snap:        "foo",
+		App:         "app",
+		DesktopFile: desktopFile,
+	})
+}
+
+func (s *apiSuite) TestPortalInfoService(c *check.C) {
+	d := s.daemon(c)
+	s.mkInstalledInState(c, d, "foo", "bar", "v1", snap.R(10), true, "apps: {svc: {command: foo, daemon: simple}}")
+
+	s.mockProcCgroup(c, 42, "1:name=systemd:/system.slice/snap.foo.svc.service\n")
+
+	rsp := s.getPortalInfo(c, "42")
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.DeepEquals, portalInfo{Snap: "foo", App: "svc"})
+}
+
+func (s *apiSuite) TestPortalInfoNotASnap(c *check.C) {
+	s.daemon(c)
+	s.mockProcCgroup(c, 42, "1:name=systemd:/user.slice/user-1000.slice/session-c2.scope\n")
+
+	rsp := s.getPortalInfo(c, "42")
+	c.Check(rsp.Status, check.Equals, http.StatusNotFound)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, "process 42 is not part of any snap")
+}
+
+func (s *apiSuite) TestPortalInfoSnapNotInstalled(c *check.C) {
+	s.daemon(c)
+	s.mockProcCgroup(c, 42, "11:devices:/snap.foo.app\n")
+
+	rsp := s.getPortalInfo(c, "42")
+	c.Check(rsp.Status, check.Equals, http.StatusNotFound)
+}
+
+func (s *apiSuite) TestPortalInfoBadPid(c *check.C) {
+	s.daemon(c)
+
+	for _, pid := range []string{"", "foo", "-1"} {
+		rsp := s.getPortalInfo(c, pid)
+		c.Check(rsp.Status, check.Equals, http.StatusBadRequest, check.Commentf("pid %q", pid))
+	}
+}
+
+func (s *apiSuite) getFileAccess(c *check.C, query string) *resp {
+	req, err := http.NewRequest("GET", "/v2/file-access?"+query, nil)
+	c.Assert(err, check.IsNil)
+	return fileAccessCmd.GET(fileAccessCmd, req, nil).(*resp)
+}
+
+func (s *apiSuite) mockFileAccess(c *check.C) {
+	s.daemon(c)
+	snippets := map[string]string{
+		"media": "/media/*/ r,\n/media/*/** rw,\n",
+		"bins":  "/usr/local/bin/* ix,\n",
+		"other": "/srv/** rw,\n",
+		"admin": "capability net_admin,\n",
+	}
+	for name, snippet := range snippets {
+		snippet := snippet
+		s.mockIface(c, &interfaces.TestInterface{
+			InterfaceName: name,
+			PlugSnippetCallback: func(plug *interfaces.Plug, slot *interfaces.Slot, securitySystem interfaces.SecuritySystem) ([]byte, error) {
+				if securitySystem != interfaces.SecurityAppArmor {
+					return nil, nil
+				}
+				return []byte(snippet), nil
+			},
+		})
+	}
+	s.mockSnap(c, `
+name: consumer
+version: 1
+apps:
+ app:
+plugs:
+ usb:
+  interface: media
+ tools:
+  interface: bins
+`)
+	s.mockSnap(c, `
+name: producer
+version: 1
+slots:
+ media:
+ bins:
+ other:
+ admin:
+`)
+	repo := s.d.overlord.InterfaceManager().Repository()
+	c.Assert(repo.Connect("consumer", "tools", "producer", "bins"), check.IsNil)
+
+	c.Assert(os.MkdirAll(dirs.SnapAppArmorDir, 0755), check.IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dirs.SnapAppArmorDir, "snap.consumer.app"), []byte(`
+@{SNAP_NAME}="consumer"
+profile "snap.consumer.app" (attach_disconnected) {
+  /var/snap/@{SNAP_NAME}/** rw,
+  /usr/local/bin/* ix,
+}
+`), 0644), check.IsNil)
+}
+
+func (s *apiSuite) TestFileAccess(c *check.C) {
+	s.mockFileAccess(c)
+
+	rsp := s.getFileAccess(c, "snap=consumer&path=/media/usb0/file")
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.DeepEquals, fileAccess{
+		Snap: "consumer",
+		App:  "app",
+		Path: "/media/usb0/file",
+		Interfaces: []fileAccessInterface{
+			{Interface: "media", Plug: "usb", Access: apparmor.Access{Read: true, Write: true}},
+		},
+	})
+
+	rsp = s.getFileAccess(c, "snap=consumer&app=app&path=/usr/local/bin/tool")
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.DeepEquals, fileAccess{
+		Snap:   "consumer",
+		App:    "app",
+		Path:   "/usr/local/bin/tool",
+		Access: apparmor.Access{Execute: true},
+		Interfaces: []fileAccessInterface{
+			{Interface: "bins", Plug: "tools", Connected: true, Access: apparmor.Access{Execute: true}},
+		},
+	})
+
+	// interfaces the snap has no plug for are suggested too
+	rsp = s.getFileAccess(c, "snap=consumer&path=/srv/www/../data")
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.DeepEquals, fileAccess{
+		Snap: "consumer",
+		App:  "app",
+		Path: "/srv/data",
+		Interfaces: []fileAccessInterface{
+			{Interface: "other", Access: apparmor.Access{Read: true, Write: true}},
+		},
+	})
+
+	rsp = s.getFileAccess(c, "snap=consumer&path=/var/snap/consumer/common/")
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result.(fileAccess).Access, check.Equals, apparmor.Access{Read: true, Write: true})
+	c.Check(rsp.Result.(fileAccess).Interfaces, check.HasLen, 0)
+}
+
+func (s *apiSuite) TestFileAccessErrors(c *check.C) {
+	s.mockFileAccess(c)
+
+	for query, status := range map[string]int{
+		"snap=consumer":                         http.StatusBadRequest,
+		"snap=consumer&path=media":              http.StatusBadRequest,
+		"snap=missing&path=/media":              http.StatusNotFound,
+		"snap=consumer&app=missing&path=/media": http.StatusNotFound,
+		"snap=producer&path=/media":             http.StatusBadRequest,
+	} {
+		rsp := s.getFileAccess(c, query)
+		c.Check(rsp.Status, check.Equals, status, check.Commentf(query))
+	}
+}
+
+func (s *apiSuite) TestDenials(c *check.C) {
+	s.mockFileAccess(c)
+	seen := time.Date(2016, 10, 14, 15, 0, 0, 0, time.UTC)
+	denials := map[string][]*ifacestate.Denial{
+		"consumer": {
+			{Snap: "consumer", App: "app", Operation: "open", Path: "/media/usb0/a", Denied: "rw", Count: 3, FirstSeen: seen, LastSeen: seen.Add(3 * time.Minute)},
+			{Snap: "consumer", App: "app", Operation: "exec", Path: "/srv/bin", Denied: "x", Count: 1, FirstSeen: seen, LastSeen: seen.Add(2 * time.Minute)},
+			{Snap: "consumer", App: "app", Operation: "capable", Capability: "net_admin", Count: 1, FirstSeen: seen, LastSeen: seen.Add(time.Minute)},
+		},
+		"gone": {
+			{Snap: "gone", App: "app", Operation: "open", Path: "/media/usb0/b", Denied: "r", Count: 1, FirstSeen: seen, LastSeen: seen},
+		},
+	}
+	st := s.d.overlord.State()
+	st.Lock()
+	st.Set("denials", denials)
+	st.Unlock()
+
+	req, err := http.NewRequest("GET", "/v2/debug/denials?snap=consumer", nil)
+	c.Assert(err, check.IsNil)
+	rsp := denialsCmd.GET(denialsCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.DeepEquals, []denialInfo{
+		{Denial: denials["consumer"][0], Suggestions: []interfaceSuggestion{{Interface: "media", Plug: "usb"}}},
+		// /srv is only readable and writable with other
+		{Denial: denials["consumer"][1]},
+		{Denial: denials["consumer"][2], Suggestions: []interfaceSuggestion{{Interface: "admin"}}},
+	})
+
+	req, err = http.NewRequest("GET", "/v2/debug/denials", nil)
+	c.Assert(err, check.IsNil)
+	rsp = denialsCmd.GET(denialsCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	result := rsp.Result.([]denialInfo)
+	c.Assert(result, check.HasLen, 4)
+	// no suggestions for the snaps no longer installed
+	c.Check(result[3], check.DeepEquals, denialInfo{Denial: denials["gone"][0]})
+}
+
+func (s *apiSuite) getCgroupInfo(c *check.C, pid string) *resp {
+	req, err := http.NewRequest("GET", "/v2/cgroup-info?pid="+pid, nil)
+	c.Assert(err, check.IsNil)
+	return cgroupInfoCmd.GET(cgroupInfoCmd, req, nil).(*resp)
+}
+
+func (s *apiSuite) TestCgroupInfo(c *check.C) {
+	d := s.daemon(c)
+	s.mkInstalledInState(c, d, "foo", "bar", "v1", snap.R(10), true, "apps: {app: {command: foo}}")
+	s.mockProcCgroup(c, 42, "11:devices:/snap.foo.app\n")
+
+	rsp := s.getCgroupInfo(c, "42")
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	// the revision of the process is unknown, so the current one is used
+	c.Check(rsp.Result, check.DeepEquals, cgroupInfo{Snap: "foo", App: "app", Revision: snap.R(10)})
+}
+
+func (s *apiSuite) TestCgroupInfoHookRevisionFromEnviron(c *check.C) {
+	d := s.daemon(c)
+	s.mkInstalledInState(c, d, "foo", "bar", "v1", snap.R(10), true, "")
+	s.mockProcCgroup(c, 42, "11:devices:/snap.foo.hook.configure\n")
+	environ := filepath.Join(dirs.GlobalRootDir, "proc", "42", "environ")
+	c.Assert(ioutil.WriteFile(environ, []byte("SNAP_REVISION=9\x00"), 0644), check.IsNil)
+
+	rsp := s.getCgroupInfo(c, "42")
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.DeepEquals, cgroupInfo{Snap: "foo", Hook: "configure", Revision: snap.R(9)})
+}
+
+func (s *apiSuite) TestCgroupInfoNotASnap(c *check.C) {
+	s.daemon(c)
+	s.mockProcCgroup(c, 42, "1:name=systemd:/user.slice\n")
+
+	rsp := s.getCgroupInfo(c, "42")
+	c.Check(rsp.Status, check.Equals, http.StatusNotFound)
+}
+
+func (s *apiSuite) getSBOM(c *check.C, query string) (*resp, map[string]interface{}) {
+	req, err := http.NewRequest("GET", "/v2/sbom"+query, nil)
+	c.Assert(err, check.IsNil)
+	rsp := sbomCmd.GET(sbomCmd, req, nil).(*resp)
+	if rsp.Type != ResponseTypeSync {
+		return rsp, nil
+	}
+	data, err := json.Marshal(rsp.Result)
+	c.Assert(err, check.IsNil)
+	var doc map[string]interface{}
+	c.Assert(json.Unmarshal(data, &doc), check.IsNil)
+	return rsp, doc
+}
+
+func (s *apiSuite) TestSBOM(c *check.C) {
+	d := s.daemon(c)
+	s.mkInstalledInState(c, d, "foo", "bar", "v1", snap.R(10), true, "")
+	s.mkInstalledInState(c, d, "baz", "bar", "v2", snap.R(3), true, "")
+
+	_, doc := s.getSBOM(c, "")
+	c.Check(doc["spdxVersion"], check.Equals, "SPDX-2.2")
+	pkgs := doc["packages"].([]interface{})
+	c.Assert(pkgs, check.HasLen, 2)
+	c.Check(pkgs[0].(map[string]interface{})["name"], check.Equals, "baz")
+	c.Check(pkgs[1].(map[string]interface{})["name"], check.Equals, "foo")
+	c.Check(pkgs[1].(map[string]interface{})["supplier"], check.Equals, "Organization: bar")
+
+	_, doc = s.getSBOM(c, "?format=cyclonedx&snaps=foo")
+	c.Check(doc["bomFormat"], check.Equals, "CycloneDX")
+	comps := doc["components"].([]interface{})
+	c.Assert(comps, check.HasLen, 1)
+	c.Check(comps[0].(map[string]interface{})["purl"], check.Equals, "pkg:snap/foo@v1?revision=10")
+}
+
+func (s *apiSuite) TestSBOMErrors(c *check.C) {
+	d := s.daemon(c)
+	s.mkInstalledInState(c, d, "foo", "bar", "v1", snap.R(10), true, "")
+
+	rsp, _ := s.getSBOM(c, "?format=xml")
+	c.Check(rsp.Status, check.Equals, http.StatusBadRequest)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `unknown bill of materials format "xml"`)
+
+	rsp, _ = s.getSBOM(c, "?snaps=foo,missing")
+	c.Check(rsp.Status, check.Equals, http.StatusNotFound)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `cannot find snap "missing"`)
+}
+
+type fakeAdvisoryDatabase map[string]asserts.Assertion
+
+func (db fakeAdvisoryDatabase) Add(a asserts.Assertion) error {
+	db[a.Header("snap-id")] = a
+	return nil
+}
+
+func (db fakeAdvisoryDatabase) Find(assertType *asserts.AssertionType, headers map[string]string) (asserts.Assertion, error) {
+	a, ok := db[headers["snap-id"]]
+	if !ok {
+		return nil, asserts.ErrNotFound
+	}
+	return a, nil
+}
+
+func (s *apiSuite) getWarnings(c *check.C, query string) *resp {
+	req, err := http.NewRequest("GET", "/v2/warnings"+query, nil)
+	c.Assert(err, check.IsNil)
+	return warningsCmd.GET(warningsCmd, req, nil).(*resp)
+}
+
+func (s *apiSuite) TestWarnings(c *check.C) {
+	d := s.daemon(c)
+	s.mkInstalledInState(c, d, "foo", "bar", "v1", snap.R(10), true, "")
+
+	rsp := s.getWarnings(c, "")
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.HasLen, 0)
+
+	body := "- id: USN-1\n  cves: [CVE-2016-1]\n  severity: high\n  fixed-revision: 12\n"
+	a, err := asserts.Decode([]byte("type: snap-advisory\n" +
+		"authority-id: canonical\n" +
+		"series: 16\n" +
+		"snap-id: funky-snap-id\n" +
+		"timestamp: 2016-09-01T12:00:00Z\n" +
+		"body-length: " + strconv.Itoa(len(body)) + "\n\n" +
+		body + "\n\n" +
+		"openpgp c2ln"))
+	c.Assert(err, check.IsNil)
+	db := fakeAdvisoryDatabase{"funky-snap-id": a}
+	restore := snapstate.OpenAdvisoryDatabase
+	defer func() { snapstate.OpenAdvisoryDatabase = restore }()
+	snapstate.OpenAdvisoryDatabase = func() (snapstate.AdvisoryDatabase, error) {
+		return db, nil
+	}
+
+	rsp = s.getWarnings(c, "?select=security")
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	warnings := rsp.Result.([]*securityWarningJSON)
+	c.Assert(warnings, check.HasLen, 1)
+	c.Check(warnings[0].Kind, check.Equals, "security")
+	c.Check(warnings[0].Snap, check.Equals, "foo")
+	c.Check(warnings[0].Revision, check.Equals, "10")
+	c.Check(warnings[0].ID, check.Equals, "USN-1")
+	c.Check(warnings[0].FixedRevision, check.Equals, 12)
+
+	rsp = s.getWarnings(c, "?select=other")
+	c.Check(rsp.Status, check.Equals, http.StatusBadRequest)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `invalid select parameter: "other"`)
+}
+
+func (s *apiSuite) TestConnectivity(c *check.C) {
+	s.daemon(c)
+	s.connectivity = []*store.ConnectivityResult{
+		{Endpoint: "search", URL: "https://search.apps.ubuntu.com/api/v1/search", Status: 200, TotalTime: 120},
+		{Endpoint: "cdn", Error: "cannot connect to proxy:3128: connection refused"},
+	}
+
+	req, err := http.NewRequest("GET", "/v2/debug/connectivity", nil)
+	c.Assert(err, check.IsNil)
+	rsp := connectivityCmd.GET(connectivityCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.DeepEquals, s.connectivity)
+}
+
+func (s *apiSuite) TestErrorReports(c *check.C) {
+	s.daemon(c)
+	c.Assert(errtracker.Add(&errtracker.Report{Code: "network", Snap: "foo", Revision: "7"}), check.IsNil)
+
+	req, err := http.NewRequest("GET", "/v2/debug/error-reports", nil)
+	c.Assert(err, check.IsNil)
+	rsp := errorReportsCmd.GET(errorReportsCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	reports := rsp.Result.([]*errtracker.Report)
+	c.Assert(reports, check.HasLen, 1)
+	c.Check(reports[0].Code, check.Equals, "network")
+	c.Check(reports[0].Snap, check.Equals, "foo")
+}
+
+func (s *apiSuite) TestQuarantine(c *check.C) {
+	s.daemon(c)
+	c.Assert(os.MkdirAll(dirs.SnapQuarantineDir, 0700), check.IsNil)
+	for _, id := range []string{"1475323200000000000", "1475326800000000000"} {
+		data := fmt.Sprintf(`{"id": %q, "snap": "foo", "revision": "7", "reason": "scan-rejected"}`, id)
+		c.Assert(ioutil.WriteFile(filepath.Join(dirs.SnapQuarantineDir, id+".json"), []byte(data), 0600), check.IsNil)
+		c.Assert(ioutil.WriteFile(filepath.Join(dirs.SnapQuarantineDir, id+".snap"), nil, 0600), check.IsNil)
+	}
+
+	req, err := http.NewRequest("GET", "/v2/debug/quarantine", nil)
+	c.Assert(err, check.IsNil)
+	rsp := quarantineCmd.GET(quarantineCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.HasLen, 2)
+
+	req, err = http.NewRequest("POST", "/v2/debug/quarantine", bytes.NewBufferString(`{"action": "purge", "ids": ["1475323200000000000"]}`))
+	c.Assert(err, check.IsNil)
+	rsp = quarantineCmd.POST(quarantineCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	purged := rsp.Result.([]*snapstate.QuarantinedSnap)
+	c.Assert(purged, check.HasLen, 1)
+	c.Check(purged[0].ID, check.Equals, "1475323200000000000")
+	c.Check(purged[0].Reason, check.Equals, "scan-rejected")
+
+	quarantined, err := snapstate.Quarantined()
+	c.Assert(err, check.IsNil)
+	c.Assert(quarantined, check.HasLen, 1)
+	c.Check(quarantined[0].ID, check.Equals, "1475326800000000000")
+}
+
+func (s *apiSuite) TestQuarantineErrors(c *check.C) {
+	s.daemon(c)
+
+	for _, t := range []struct {
+		body string
+		err  string
+	}{
+		{`{"action": "restore"}`, `quarantine action "restore" is unsupported`},
+		{`{"action": "purge", "ids": ["42"]}`, `cannot purge quarantine: cannot find quarantined snap "42"`},
+		{`}`, `cannot decode data from request body: .*`},
+	} {
+		req, err := http.NewRequest("POST", "/v2/debug/quarantine", bytes.NewBufferString(t.body))
+		c.Assert(err, check.IsNil)
+		rsp := quarantineCmd.POST(quarantineCmd, req, nil).(*resp)
+		c.Check(rsp.Type, check.Equals, ResponseTypeError)
+		c.Check(rsp.Status, check.Equals, http.StatusBadRequest)
+		c.Check(rsp.Result.(*errorResult).Message, check.Matches, t.err)
+	}
+}
+
+func (s *apiSuite) TestTimeWarp(c *check.C) {
+	d := s.daemon(c)
+	assertMgr := d.overlord.AssertManager()
+	db, err := assertMgr.DB()
+	c.Assert(err, check.IsNil)
+	db.SetEarliestTime(time.Now().Add(-time.Hour))
+	c.Assert(assertMgr.Ensure(), check.IsNil)
+
+	req, err := http.NewRequest("GET", "/v2/debug/timewarp", nil)
+	c.Assert(err, check.IsNil)
+	rsp := timeWarpCmd.GET(timeWarpCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	m := rsp.Result.(map[string]interface{})
+	c.Check(m["clock-behind"], check.Equals, false)
+	clock := m["clock"].(time.Time)
+	maxSeen := m["max-seen-time"].(time.Time)
+	c.Check(maxSeen.After(clock), check.Equals, false)
+	c.Check(m["earliest-time"], check.Equals, maxSeen)
+	c.Check(m["check-time"], check.Equals, clock)
+}
+
+func (s *apiSuite) TestLockStats(c *check.C) {
+	d := s.daemon(c)
+	st := d.overlord.State()
+	st.Lock()
+	st.Unlock()
+
+	req, err := http.NewRequest("GET", "/v2/debug/lock-stats", nil)
+	c.Assert(err, check.IsNil)
+	st.Lock()
+	// answered even with the state locked
+	rsp := lockStatsCmd.GET(lockStatsCmd, req, nil).(*resp)
+	st.Unlock()
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	stats := rsp.Result.(*state.LockStats)
+	c.Check(stats.Holder, check.Equals, "daemon.(*apiSuite).TestLockStats")
+	c.Assert(stats.Ops, check.Not(check.HasLen), 0)
+	found := false
+	for _, op := range stats.Ops {
+		if op.Op == "daemon.(*apiSuite).TestLockStats" {
+			found = true
+			c.Check(op.Count, check.Equals, 2)
+		}
+	}
+	c.Check(found, check.Equals, true)
+}
+
+func (s *apiSuite) TestStrayPolicy(c *check.C) {
+	d := s.daemon(c)
+	s.mkInstalledInState(c, d, "foo", "bar", "v1", snap.R(10), true, "")
+
+	policyDir := filepath.Join(dirs.GlobalRootDir, policy.SecBase, "seccomp", "templates")
+	c.Assert(os.MkdirAll(policyDir, 0755), check.IsNil)
+	for _, name := range []string{"foo_default", "bar_default"} {
+		c.Assert(ioutil.WriteFile(filepath.Join(policyDir, name), nil, 0644), check.IsNil)
+	}
+	// the files staged and backed up by operations in flight are theirs
+	for _, name := range []string{".baz_default~new", ".baz_default~old", ".foo_default~new"} {
+		c.Assert(ioutil.WriteFile(filepath.Join(policyDir, name), nil, 0644), check.IsNil)
+	}
+
+	req, err := http.NewRequest("GET", "/v2/debug/stray-policy", nil)
+	c.Assert(err, check.IsNil)
+	rsp := strayPolicyCmd.GET(strayPolicyCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.DeepEquals, []strayPolicyJSON{
+		{Snap: "bar", Path: filepath.Join(policyDir, "bar_default")},
+	})
+}
+
+func (s *apiSuite) TestPortalInfoHasNetwork(c *check.C) {
+	repo := interfaces.NewRepository()
+	c.Assert(repo.AddInterface(&interfaces.TestInterface{InterfaceName: "network"}), check.IsNil)
+	for _, yaml := range []string{`
+name: foo
+apps:
+  app:
+    plugs: [network]
+  other:
+`, `
+name: core
+type: os
+slots:
+  network:
+`} {
+		info, err := snap.InfoFromSnapYaml([]byte(yaml))
+		c.Assert(err, check.IsNil)
+		c.Assert(repo.AddSnap(info), check.IsNil)
+	}
+
+	c.Check(appHasNetwork(repo, "foo", "app"), check.Equals, false)
+	c.Assert(repo.Connect("foo", "network", "core", "network"), check.IsNil)
+	c.Check(appHasNetwork(repo, "foo", "app"), check.Equals, true)
+	c.Check(appHasNetwork(repo, "foo", "other"), check.Equals, false)
+}
+
+func (s *apiSuite) TestInstalOnNonDevModeDistro(c *check.C) {
+	s.testInstall(c, &release.OS{ID: "ubuntu"}, snapstate.Flags(0))
+}
+func (s *apiSuite) TestInstalOnDevModeDistro(c *check.C) {
+	s.testInstall(c, &release.OS{ID: "x-devmode-distro"}, snapstate.DevMode)
+}
+
+func (s *apiSuite) testInstall(c *check.C, releaseInfo *release.OS, flags snapstate.Flags) {
+	calledFlags := snapstate.Flags(42)
+	installQueue := []string{}
+	restore := release.MockReleaseInfo(releaseInfo)
+	defer restore()
+
+	snapstateGet = func(s *state.State, name string, snapst *snapstate.SnapState) error {
+		// we have ubuntu-core
+		return nil
+	}
+	snapstateInstall = func(s *state.State, name, channel string, userID int, flags snapstate.Flags) (*state.TaskSet, error) {
+		calledFlags = flags
+		installQueue = append(installQueue, name)
+
+		t := s.NewTask("fake-install-snap", "Doing a fake install")
+		return state.NewTaskSet(t), nil
+	}
+
+	d := s.daemon(c)
+
+	d.overlord.Loop()
+	defer d.overlord.Stop()
+
+	buf := bytes.NewBufferString(`{"action": "install"}`)
+	req, err := http.NewRequest("POST", "/v2/snaps/some-snap", buf)
+	c.Assert(err, check.IsNil)
+
+	s.vars = map[string]string{"name": "some-snap"}
+	rsp := postSnap(snapCmd, req, nil).(*resp)
+
+	c.Assert(rsp.Type, check.Equals, ResponseTypeAsync)
+
+	st := d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+
+	c.Check(chg.Tasks(), check.HasLen, 1)
+
+	st.Unlock()
+	<-chg.Ready()
+	st.Lock()
+
+	c.Check(chg.Status(), check.Equals, state.DoneStatus)
+	c.Check(calledFlags, check.Equals, flags)
+	c.Check(err, check.IsNil)
+	c.Check(installQueue, check.DeepEquals, []string{"some-snap"})
+	c.Check(chg.Kind(), check.Equals, "install-snap")
+	c.Check(chg.Summary(), check.Equals, `Install "some-snap" snap`)
+}
+
+func (s *apiSuite) TestRefresh(c *check.C) {
+	calledFlags := snapstate.Flags(42)
+	calledUserID := 0
+	installQueue := []string{}
+
+	snapstateGet = func(s *state.State, name string, snapst *snapstate.SnapState) error {
+		// we have ubuntu-core
+		return nil
+	}
+	snapstateUpdate = func(s *state.State, name, channel string, userID int, flags snapstate.Flags) (*state.TaskSet, error) {
+		calledFlags = flags
+		calledUserID = userID
+		installQueue = append(installQueue, name)
+
+		t := s.NewTask("fake-refresh-snap", "Doing a fake install")
+		return state.NewTaskSet(t), nil
+	}
+
+	d := s.daemon(c)
+	inst := &snapInstruction{
+		Action: "refresh",
+		snap:   "some-snap",
+		userID: 17,
+	}
+
+	st := d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	summary, _, err := inst.dispatch()(inst, st)
+	c.Check(err, check.IsNil)
+
+	c.Check(calledFlags, check.Equals, snapstate.Flags(0))
+	c.Check(calledUserID, check.Equals, 17)
+	c.Check(err, check.IsNil)
+	c.Check(installQueue, check.DeepEquals, []string{"some-snap"})
+	c.Check(summary, check.Equals, `Refresh "some-snap" snap`)
+}
+
+func (s *apiSuite) TestRemove(c *check.C) {
+	var calledNames []string
+	var calledPurge bool
+	ifacestateRemoveMany = func(st *state.State, names []string, purgeDependents bool) ([]string, []string, []*state.TaskSet, error) {
+		calledNames = names
+		calledPurge = purgeDependents
+		t := st.NewTask("fake-remove-snap", "Doing a fake remove")
+		return []string{"consumer", "some-snap"}, nil, []*state.TaskSet{state.NewTaskSet(t)}, nil
+	}
+
+	d := s.daemon(c)
+	inst := &snapInstruction{
+		Action:          "remove",
+		PurgeDependents: true,
+		snap:            "some-snap",
+	}
+
+	st := d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	summary, tsets, err := inst.dispatch()(inst, st)
+	c.Assert(err, check.IsNil)
+	c.Check(tsets, check.HasLen, 1)
+	c.Check(calledNames, check.DeepEquals, []string{"some-snap"})
+	c.Check(calledPurge, check.Equals, true)
+	c.Check(summary, check.Equals, `Remove snaps consumer, some-snap`)
+	c.Check(inst.snapNames, check.DeepEquals, []string{"consumer", "some-snap"})
+}
+
+func (s *apiSuite) TestPostSnapsRemoveMany(c *check.C) {
+	var calledNames []string
+	ifacestateRemoveMany = func(st *state.State, names []string, purgeDependents bool) ([]string, []string, []*state.TaskSet, error) {
+		calledNames = names
+		c.Check(purgeDependents, check.Equals, false)
+		t := st.NewTask("fake-remove-snap", "Doing a fake remove")
+		return []string{"bar", "foo"}, []string{"consumer"}, []*state.TaskSet{state.NewTaskSet(t)}, nil
+	}
+
+	d := s.daemon(c)
+	d.overlord.Loop()
+	defer d.overlord.Stop()
+
+	buf := bytes.NewBufferString(`{"action": "remove", "snaps": ["foo", "bar"]}`)
+	req, err := http.NewRequest("POST", "/v2/snaps", buf)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/json")
+
+	rsp := postSnaps(snapsCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeAsync)
+	c.Check(calledNames, check.DeepEquals, []string{"foo", "bar"})
+
+	st := d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Kind(), check.Equals, "remove-snap")
+	c.Check(chg.Summary(), check.Equals, "Remove snaps bar, foo")
+	var names []string
+	c.Assert(chg.Get("snap-names", &names), check.IsNil)
+	c.Check(names, check.DeepEquals, []string{"bar", "foo"})
+	var data map[string][]string
+	c.Assert(chg.Get("api-data", &data), check.IsNil)
+	c.Check(data, check.DeepEquals, map[string][]string{"dependents": {"consumer"}})
+}
+
+func (s *apiSuite) TestPostSnapsRemoveManyLanes(c *check.C) {
+	ifacestateRemoveMany = func(st *state.State, names []string, purgeDependents bool) ([]string, []string, []*state.TaskSet, error) {
+		var tsets []*state.TaskSet
+		for _, name := range names {
+			tsets = append(tsets, state.NewTaskSet(st.NewTask("fake-remove-snap", "Doing a fake remove of "+name)))
+		}
+		return names, nil, tsets, nil
+	}
+
+	d := s.daemon(c)
+	d.overlord.Loop()
+	defer d.overlord.Stop()
+
+	for _, t := range []struct {
+		body  string
+		lanes int
+	}{
+		{`{"action": "remove", "snaps": ["foo", "bar"]}`, 2},
+		{`{"action": "remove", "snaps": ["foo", "bar"], "transactional": true}`, 1},
+	} {
+		buf := bytes.NewBufferString(t.body)
+		req, err := http.NewRequest("POST", "/v2/snaps", buf)
+		c.Assert(err, check.IsNil)
+		req.Header.Set("Content-Type", "application/json")
+
+		rsp := postSnaps(snapsCmd, req, nil).(*resp)
+		c.Assert(rsp.Type, check.Equals, ResponseTypeAsync)
+
+		st := d.overlord.State()
+		st.Lock()
+		chg := st.Change(rsp.Change)
+		c.Assert(chg, check.NotNil)
+		lanes := make(map[int]bool)
+		for _, task := range chg.Tasks() {
+			c.Assert(task.Lanes(), check.HasLen, 1)
+			lanes[task.Lanes()[0]] = true
+		}
+		c.Check(lanes, check.HasLen, t.lanes, check.Commentf(t.body))
+		st.Unlock()
+	}
+}
+
+func (s *apiSuite) TestPostSnapsRefreshOffline(c *check.C) {
+	calledNames := []string{"unset"}
+	snapstateUpdateOffline = func(st *state.State, names []string, userID int) ([]string, []*state.TaskSet, error) {
+		calledNames = names
+		t := st.NewTask("fake-refresh-snap", "Doing a fake refresh")
+		return []string{"bar", "foo"}, []*state.TaskSet{state.NewTaskSet(t)}, nil
+	}
+
+	d := s.daemon(c)
+	d.overlord.Loop()
+	defer d.overlord.Stop()
+
+	buf := bytes.NewBufferString(`{"action": "refresh", "offline": true}`)
+	req, err := http.NewRequest("POST", "/v2/snaps", buf)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/json")
+
+	rsp := postSnaps(snapsCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeAsync)
+	c.Check(calledNames, check.HasLen, 0)
+
+	st := d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Kind(), check.Equals, "refresh-snap")
+	c.Check(chg.Summary(), check.Equals, "Refresh snaps bar, foo from the download cache")
+	var names []string
+	c.Assert(chg.Get("snap-names", &names), check.IsNil)
+	c.Check(names, check.DeepEquals, []string{"bar", "foo"})
+}
+
+func (s *apiSuite) TestRefreshOffline(c *check.C) {
+	var calledNames []string
+	snapstateUpdateOffline = func(st *state.State, names []string, userID int) ([]string, []*state.TaskSet, error) {
+		calledNames = names
+		t := st.NewTask("fake-refresh-snap", "Doing a fake refresh")
+		return names, []*state.TaskSet{state.NewTaskSet(t)}, nil
+	}
+
+	d := s.daemon(c)
+	inst := &snapInstruction{
+		Action:  "refresh",
+		Offline: true,
+		snap:    "some-snap",
+	}
+
+	st := d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	summary, _, err := inst.dispatch()(inst, st)
+	c.Assert(err, check.IsNil)
+	c.Check(calledNames, check.DeepEquals, []string{"some-snap"})
+	c.Check(summary, check.Equals, `Refresh "some-snap" snap from the download cache`)
+
+	inst.Channel = "beta"
+	_, _, err = inst.dispatch()(inst, st)
+	c.Check(err, check.ErrorMatches, "cannot refresh offline to a channel or refresh spec")
+}
+
+func (s *apiSuite) TestPostSnapsBadRequests(c *check.C) {
+	s.daemon(c)
+
+	for _, t := range []struct {
+		body string
+		err  string
+	}{
+		{`{"action": "install", "snaps": ["foo"]}`, `unsupported multi-snap operation "install"`},
+		{`{"action": "remove"}`, `cannot remove: no snaps given`},
+		{`{"action": "refresh", "snaps": ["foo"]}`, `unsupported multi-snap operation "refresh"`},
+		{`{"action": `, `cannot decode request body into snap instruction: .*`},
+	} {
+		req, err := http.NewRequest("POST", "/v2/snaps", bytes.NewBufferString(t.body))
+		c.Assert(err, check.IsNil)
+		req.Header.Set("Content-Type", "application/json")
+
+		rsp := postSnaps(snapsCmd, req, nil).(*resp)
+		c.Check(rsp.Type, check.Equals, ResponseTypeError)
+		c.Check(rsp.Result.(*errorResult).Message, check.Matches, t.err)
+	}
+}
+
+func (s *apiSuite) TestRefreshUnholdRollout(c *check.C) {
+	var calledFlags snapstate.Flags
+
+	snapstateUpdate = func(s *state.State, name, channel string, userID int, flags snapstate.Flags) (*state.TaskSet, error) {
+		calledFlags = flags
+
+		t := s.NewTask("fake-refresh-snap", "Doing a fake install")
+		return state.NewTaskSet(t), nil
+	}
+
+	d := s.daemon(c)
+	inst := &snapInstruction{
+		Action:        "refresh",
+		UnholdRollout: true,
+		snap:          "some-snap",
+	}
+
+	st := d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	_, _, err := inst.dispatch()(inst, st)
+	c.Check(err, check.IsNil)
+
+	c.Check(calledFlags, check.Equals, snapstate.Flags(snapstate.UnholdRollout))
+}
+
+func (s *apiSuite) mockRefreshSpecOps(c *check.C, installed map[string]snap.Revision) *[]string {
+	var ops []string
+	snapstateGet = func(s *state.State, name string, snapst *snapstate.SnapState) error {
+		rev, ok := installed[name]
+		if !ok {
+			return state.ErrNoState
+		}
+		snapst.Sequence = []*snap.SideInfo{{OfficialName: name, Revision: rev}}
+		return nil
+	}
+	snapstateInstallRevision = func(s *state.State, name string, revision snap.Revision, userID int, flags snapstate.Flags) (*state.TaskSet, error) {
+		ops = append(ops, fmt.Sprintf("install %s %s", name, revision))
+		return state.NewTaskSet(s.NewTask("fake-install-snap", "Doing a fake install of "+name)), nil
+	}
+	snapstateUpdateToRevision = func(s *state.State, name string, revision snap.Revision, userID int, flags snapstate.Flags) (*state.TaskSet, error) {
+		ops = append(ops, fmt.Sprintf("refresh %s %s", name, revision))
+		return state.NewTaskSet(s.NewTask("fake-refresh-snap", "Doing a fake refresh of "+name)), nil
+	}
+	return &ops
+}
+
+func (s *apiSuite) TestRefreshToSpec(c *check.C) {
+	ops := s.mockRefreshSpecOps(c, map[string]snap.Revision{"foo": snap.R(10), "ubuntu-core": snap.R(99), "baz": snap.R(5)})
+
+	c.Assert(os.MkdirAll(filepath.Dir(dirs.SnapRefreshSpecKeyring), 0755), check.IsNil)
+	c.Assert(ioutil.WriteFile(dirs.SnapRefreshSpecKeyring, nil, 0644), check.IsNil)
+	gpg := testutil.MockCommand(c, "gpg", "")
+	defer gpg.Restore()
+
+	d := s.daemon(c)
+	inst := &snapInstruction{
+		Action:        "refresh",
+		Spec:          []byte("snaps: {foo: 12, bar: 3, ubuntu-core: 100, baz: 5}"),
+		SpecSignature: []byte("sig"),
+	}
+
+	st := d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	summary, tsets, err := inst.dispatch()(inst, st)
+	c.Assert(err, check.IsNil)
+	c.Check(summary, check.Equals, "Refresh snaps ubuntu-core, bar, baz, foo to the revisions of a refresh spec")
+	c.Check(*ops, check.DeepEquals, []string{"refresh ubuntu-core 100", "install bar 3", "refresh foo 12"})
+	c.Check(inst.snapNames, check.DeepEquals, []string{"ubuntu-core", "bar", "baz", "foo"})
+	c.Assert(tsets, check.HasLen, 3)
+	// the other snaps need the OS snap
+	c.Check(tsets[1].Tasks()[0].WaitTasks(), check.DeepEquals, tsets[0].Tasks())
+	c.Check(tsets[2].Tasks()[0].WaitTasks(), check.DeepEquals, tsets[0].Tasks())
+
+	// the signature is checked by snapd, against the keyring of the owner
+	c.Assert(gpg.Calls(), check.HasLen, 1)
+	call := gpg.Calls()[0]
+	c.Check(call[:6], check.DeepEquals, []string{"gpg", "-q", "--batch", "--no-default-keyring", "--keyring", dirs.SnapRefreshSpecKeyring})
+	c.Check(call[6], check.Equals, "--verify")
+}
+
+func (s *apiSuite) TestRefreshToSpecBadSignature(c *check.C) {
+	ops := s.mockRefreshSpecOps(c, nil)
 
-	appIconCmd.GET(appIconCmd, req, nil).ServeHTTP(rec, req)
-	c.Check(rec.Code, check.Equals, 200)
-	c.Check(rec.Body.String(), check.Equals, "ick")
-}
+	c.Assert(os.MkdirAll(filepath.Dir(dirs.SnapRefreshSpecKeyring), 0755), check.IsNil)
+	c.Assert(ioutil.WriteFile(dirs.SnapRefreshSpecKeyring, nil, 0644), check.IsNil)
+	gpg := testutil.MockCommand(c, "gpg", "echo BAD signature >&2; exit 1")
+	defer gpg.Restore()
 
-func (s *apiSuite) TestAppIconGetNoIcon(c *check.C) {
 	d := s.daemon(c)
+	buf := bytes.NewBufferString(`{"action": "refresh", "spec": "` + base64.StdEncoding.EncodeToString([]byte("snaps: {foo: 12}")) + `", "spec-signature": "c2ln"}`)
+	req, err := http.NewRequest("POST", "/v2/snaps", buf)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/json")
 
-	// have an *in*active foo in the system
-	info := s.mkInstalledInState(c, d, "foo", "bar", "v1", snap.R(10), true, "")
+	rsp := postSnaps(snapsCmd, req, nil).(*resp)
+	c.Check(rsp.Type, check.Equals, ResponseTypeError)
+	c.Check(rsp.Result.(*errorResult).Message, check.Matches, `cannot refresh: cannot verify refresh spec signature: .*BAD signature.*`)
+	c.Check(*ops, check.HasLen, 0)
 
-	// NO ICON!
-	err := os.RemoveAll(filepath.Join(info.MountDir(), "meta", "gui", "icon.svg"))
-	c.Assert(err, check.IsNil)
+	st := d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	c.Check(st.Changes(), check.HasLen, 0)
+}
 
-	s.vars = map[string]string{"name": "foo"}
-	req, err := http.NewRequest("GET", "/v2/icons/foo/icon", nil)
-	c.Assert(err, check.IsNil)
+func (s *apiSuite) TestRefreshToSpecUnsigned(c *check.C) {
+	ops := s.mockRefreshSpecOps(c, nil)
 
-	rec := httptest.NewRecorder()
+	d := s.daemon(c)
+	st := d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
 
-	appIconCmd.GET(appIconCmd, req, nil).ServeHTTP(rec, req)
-	c.Check(rec.Code/100, check.Equals, 4)
+	inst := &snapInstruction{Action: "refresh", Spec: []byte("snaps: {foo: 12}")}
+	_, _, err := inst.dispatch()(inst, st)
+	c.Check(err, check.ErrorMatches, "cannot use refresh spec: missing signature")
+
+	// unsigned specs are accepted if asked for on devices without an owner
+	inst.AllowUnsigned = true
+	_, _, err = inst.dispatch()(inst, st)
+	c.Check(err, check.IsNil)
+	c.Check(*ops, check.DeepEquals, []string{"install foo 12"})
+
+	// and refused on devices with one
+	c.Assert(os.MkdirAll(filepath.Dir(dirs.SnapRefreshSpecKeyring), 0755), check.IsNil)
+	c.Assert(ioutil.WriteFile(dirs.SnapRefreshSpecKeyring, nil, 0644), check.IsNil)
+	_, _, err = inst.dispatch()(inst, st)
+	c.Check(err, check.ErrorMatches, "cannot use refresh spec: missing signature, required by the keyring of the device owner")
+	c.Check(*ops, check.HasLen, 1)
 }
 
-func (s *apiSuite) TestAppIconGetNoApp(c *check.C) {
-	s.daemon(c)
+func (s *apiSuite) TestRefreshToSpecNothingToRefresh(c *check.C) {
+	s.mockRefreshSpecOps(c, map[string]snap.Revision{"foo": snap.R(12)})
 
-	s.vars = map[string]string{"name": "foo"}
-	req, err := http.NewRequest("GET", "/v2/icons/foo/icon", nil)
+	s.daemon(c)
+	buf := bytes.NewBufferString(`{"action": "refresh", "allow-unsigned": true, "spec": "` + base64.StdEncoding.EncodeToString([]byte("snaps: {foo: 12}")) + `"}`)
+	req, err := http.NewRequest("POST", "/v2/snaps", buf)
 	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/json")
 
-	rec := httptest.NewRecorder()
-
-	appIconCmd.GET(appIconCmd, req, nil).ServeHTTP(rec, req)
-	c.Check(rec.Code, check.Equals, 404)
+	rsp := postSnaps(snapsCmd, req, nil).(*resp)
+	c.Check(rsp.Type, check.Equals, ResponseTypeError)
+	c.Check(rsp.Status, check.Equals, http.StatusBadRequest)
+	c.Check(rsp.Result.(*errorResult).Kind, check.Equals, errorKindNothingToRefresh)
 }
 
-func (s *apiSuite) TestInstalOnNonDevModeDistro(c *check.C) {
-	s.testInstall(c, &release.OS{ID: "ubuntu"}, snapstate.Flags(0))
-}
-func (s *apiSuite) TestInstalOnDevModeDistro(c *check.C) {
-	s.testInstall(c, &release.OS{ID: "x-devmode-distro"}, snapstate.DevMode)
+func (s *apiSuite) TestRefreshToSpecWithSnap(c *check.C) {
+	s.mockRefreshSpecOps(c, nil)
+
+	d := s.daemon(c)
+	st := d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	inst := &snapInstruction{Action: "refresh", Spec: []byte("snaps: {foo: 12}"), AllowUnsigned: true, snap: "foo"}
+	_, _, err := inst.dispatch()(inst, st)
+	c.Check(err, check.ErrorMatches, "cannot refresh to a refresh spec and to a channel or given snaps")
 }
 
-func (s *apiSuite) testInstall(c *check.C, releaseInfo *release.OS, flags snapstate.Flags) {
-	calledFlags := snapstate.Flags(42)
-	installQueue := []string{}
-	restore := release.MockReleaseInfo(releaseInfo)
-	defer restore()
+func (s *apiSuite) TestInstallArchitecture(c *check.C) {
+	var calledArchitecture string
 
 	snapstateGet = func(s *state.State, name string, snapst *snapstate.SnapState) error {
 		// we have ubuntu-core
 		return nil
 	}
 	snapstateInstall = func(s *state.State, name, channel string, userID int, flags snapstate.Flags) (*state.TaskSet, error) {
-		calledFlags = flags
-		installQueue = append(installQueue, name)
+		architecture, err := snapstate.Architecture(s, name)
+		c.Assert(err, check.IsNil)
+		calledArchitecture = architecture
 
 		t := s.NewTask("fake-install-snap", "Doing a fake install")
 		return state.NewTaskSet(t), nil
 	}
 
 	d := s.daemon(c)
-
-	d.overlord.Loop()
-	defer d.overlord.Stop()
-
-	buf := bytes.NewBufferString(`{"action": "install"}`)
-	req, err := http.NewRequest("POST", "/v2/snaps/some-snap", buf)
-	c.Assert(err, check.IsNil)
-
-	s.vars = map[string]string{"name": "some-snap"}
-	rsp := postSnap(snapCmd, req, nil).(*resp)
-
-	c.Assert(rsp.Type, check.Equals, ResponseTypeAsync)
+	inst := &snapInstruction{
+		Action:       "install",
+		Architecture: arch.UbuntuArchitecture(),
+		snap:         "some-snap",
+	}
 
 	st := d.overlord.State()
 	st.Lock()
 	defer st.Unlock()
-	chg := st.Change(rsp.Change)
-	c.Assert(chg, check.NotNil)
-
-	c.Check(chg.Tasks(), check.HasLen, 1)
-
-	st.Unlock()
-	<-chg.Ready()
-	st.Lock()
-
-	c.Check(chg.Status(), check.Equals, state.DoneStatus)
-	c.Check(calledFlags, check.Equals, flags)
+	_, _, err := inst.dispatch()(inst, st)
 	c.Check(err, check.IsNil)
-	c.Check(installQueue, check.DeepEquals, []string{"some-snap"})
-	c.Check(chg.Kind(), check.Equals, "install-snap")
-	c.Check(chg.Summary(), check.Equals, `Install "some-snap" snap`)
+	c.Check(calledArchitecture, check.Equals, arch.UbuntuArchitecture())
 }
 
-func (s *apiSuite) TestRefresh(c *check.C) {
-	calledFlags := snapstate.Flags(42)
-	calledUserID := 0
-	installQueue := []string{}
-
-	snapstateGet = func(s *state.State, name string, snapst *snapstate.SnapState) error {
-		// we have ubuntu-core
-		return nil
-	}
-	snapstateUpdate = func(s *state.State, name, channel string, userID int, flags snapstate.Flags) (*state.TaskSet, error) {
-		calledFlags = flags
-		calledUserID = userID
-		installQueue = append(installQueue, name)
-
-		t := s.NewTask("fake-refresh-snap", "Doing a fake install")
-		return state.NewTaskSet(t), nil
+func (s *apiSuite) TestInstallIncompatibleArchitecture(c *check.C) {
+	snapstateInstall = func(s *state.State, name, channel string, userID int, flags snapstate.Flags) (*state.TaskSet, error) {
+		c.Fatalf("should not be reached")
+		return nil, nil
 	}
 
 	d := s.daemon(c)
 	inst := &snapInstruction{
-		Action: "refresh",
-		snap:   "some-snap",
-		userID: 17,
+		Action:       "install",
+		Architecture: "mystery",
+		snap:         "some-snap",
 	}
 
 	st := d.overlord.State()
 	st.Lock()
 	defer st.Unlock()
-	summary, _, err := inst.dispatch()(inst, st)
-	c.Check(err, check.IsNil)
-
-	c.Check(calledFlags, check.Equals, snapstate.Flags(0))
-	c.Check(calledUserID, check.Equals, 17)
-	c.Check(err, check.IsNil)
-	c.Check(installQueue, check.DeepEquals, []string{"some-snap"})
-	c.Check(summary, check.Equals, `Refresh "some-snap" snap`)
+	_, _, err := inst.dispatch()(inst, st)
+	c.Check(err, check.ErrorMatches, `cannot use architecture "mystery" for snap "some-snap": it is incompatible with this system .*`)
 }
 
 func (s *apiSuite) TestInstallMissingUbuntuCore(c *check.C) {
@@ -2267,7 +3730,9 @@ func (s *apiSuite) TestAssertOK(c *check.C) {
 	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
 	c.Check(rsp.Status, check.Equals, http.StatusOK)
 	// Verify (internal)
-	_, err = d.overlord.AssertManager().DB().Find(asserts.AccountKeyType, map[string]string{
+	db, err := d.overlord.AssertManager().DB()
+	c.Assert(err, check.IsNil)
+	_, err = db.Find(asserts.AccountKeyType, map[string]string{
 		"account-id":    "developer1",
 		"public-key-id": "adea89b00094c337",
 	})
@@ -2310,7 +3775,9 @@ func (s *apiSuite) TestAssertsFindManyAll(c *check.C) {
 	d := s.daemon(c)
 	a, err := asserts.Decode([]byte(testAccKey))
 	c.Assert(err, check.IsNil)
-	err = d.overlord.AssertManager().DB().Add(a)
+	db, err := d.overlord.AssertManager().DB()
+	c.Assert(err, check.IsNil)
+	err = db.Add(a)
 	c.Assert(err, check.IsNil)
 	// Execute
 	req, err := http.NewRequest("POST", "/v2/assertions/account-key", nil)
@@ -2345,7 +3812,9 @@ func (s *apiSuite) TestAssertsFindManyFilter(c *check.C) {
 	d := s.daemon(c)
 	a, err := asserts.Decode([]byte(testAccKey))
 	c.Assert(err, check.IsNil)
-	err = d.overlord.AssertManager().DB().Add(a)
+	db, err := d.overlord.AssertManager().DB()
+	c.Assert(err, check.IsNil)
+	err = db.Add(a)
 	c.Assert(err, check.IsNil)
 	// Execute
 	req, err := http.NewRequest("POST", "/v2/assertions/account-key?account-id=developer1", nil)
@@ -2373,7 +3842,9 @@ func (s *apiSuite) TestAssertsFindManyNoResults(c *check.C) {
 	d := s.daemon(c)
 	a, err := asserts.Decode([]byte(testAccKey))
 	c.Assert(err, check.IsNil)
-	err = d.overlord.AssertManager().DB().Add(a)
+	db, err := d.overlord.AssertManager().DB()
+	c.Assert(err, check.IsNil)
+	err = db.Add(a)
 	c.Assert(err, check.IsNil)
 	// Execute
 	req, err := http.NewRequest("POST", "/v2/assertions/account-key?account-id=xyzzyx", nil)
@@ -2590,6 +4061,7 @@ func (s *apiSuite) TestStateChange(c *check.C) {
 	ids := setupChanges(st)
 	chg := st.Change(ids[0])
 	chg.Set("api-data", map[string]int{"n": 42})
+	st.Task(ids[3]).WaitFor(st.Task(ids[2]))
 	st.Unlock()
 	s.vars = map[string]string{"id": ids[0]}
 
@@ -2632,6 +4104,7 @@ func (s *apiSuite) TestStateChange(c *check.C) {
 				"summary":    "2...",
 				"status":     "Do",
 				"progress":   map[string]interface{}{"done": 0., "total": 1.},
+				"wait-tasks": []interface{}{ids[2]},
 				"spawn-time": "2016-04-21T01:02:03Z",
 			},
 		},
@@ -2641,6 +4114,108 @@ func (s *apiSuite) TestStateChange(c *check.C) {
 	})
 }
 
+func (s *apiSuite) TestStateChangeSnapResults(c *check.C) {
+	d := newTestDaemon(c)
+	st := d.overlord.State()
+	st.Lock()
+	chg := st.NewChange("remove-snap", "Remove snaps foo, bar")
+	for _, name := range []string{"foo", "bar"} {
+		t := st.NewTask("unlink-snap", "Unlink "+name)
+		t.Set("snap-setup", &snapstate.SnapSetup{Name: name})
+		chg.AddTask(t)
+		if name == "foo" {
+			t.Errorf("boom")
+			t.SetStatus(state.ErrorStatus)
+		} else {
+			t.SetStatus(state.DoneStatus)
+		}
+	}
+	st.Unlock()
+	s.vars = map[string]string{"id": chg.ID()}
+
+	req, err := http.NewRequest("GET", "/v2/changes/"+chg.ID(), nil)
+	c.Assert(err, check.IsNil)
+	rsp := getChange(stateChangeCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+
+	info := rsp.Result.(*changeInfo)
+	c.Assert(info.Data["snap-results"], check.NotNil)
+	var results map[string]*snapstate.SnapResult
+	c.Assert(json.Unmarshal(*info.Data["snap-results"], &results), check.IsNil)
+	c.Check(results["foo"].Status, check.Equals, snapstate.SnapResultFailed)
+	c.Check(results["foo"].Message, check.Equals, "boom")
+	c.Check(results["bar"].Status, check.Equals, snapstate.SnapResultSucceeded)
+}
+
+func (s *apiSuite) TestStateChangeErrorCode(c *check.C) {
+	d := newTestDaemon(c)
+	st := d.overlord.State()
+	st.Lock()
+	ids := setupChanges(st)
+	st.Unlock()
+	s.vars = map[string]string{"id": ids[1]}
+
+	req, err := http.NewRequest("GET", "/v2/change/"+ids[1], nil)
+	c.Assert(err, check.IsNil)
+	rsp := getChange(stateChangeCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result.(*changeInfo).ErrorCode, check.Equals, "unknown")
+
+	st.Lock()
+	st.Change(ids[1]).Set("error-code", "space")
+	st.Unlock()
+
+	rsp = getChange(stateChangeCmd, req, nil).(*resp)
+	c.Check(rsp.Result.(*changeInfo).ErrorCode, check.Equals, "space")
+}
+
+func (s *apiSuite) TestStateChangeTaskOutput(c *check.C) {
+	d := newTestDaemon(c)
+	st := d.overlord.State()
+	st.Lock()
+	ids := setupChanges(st)
+	st.Task(ids[2]).SetOutput("error: cannot frobnicate\n")
+	st.Unlock()
+	s.vars = map[string]string{"id": ids[0]}
+
+	req, err := http.NewRequest("GET", "/v2/change/"+ids[0], nil)
+	c.Assert(err, check.IsNil)
+	rsp := getChange(stateChangeCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	tasks := rsp.Result.(*changeInfo).Tasks
+	c.Assert(tasks, check.HasLen, 2)
+	c.Check(tasks[0].Output, check.Equals, "error: cannot frobnicate\n")
+	c.Check(tasks[1].Output, check.Equals, "")
+}
+
+func (s *apiSuite) TestStateChangeRebootRequired(c *check.C) {
+	d := newTestDaemon(c)
+	st := d.overlord.State()
+	st.Lock()
+	chg := st.NewChange("refresh-snap", "Refresh \"pc-kernel\" snap")
+	t := st.NewTask("link-snap", "...")
+	chg.AddTask(t)
+	chg.Set("reboot-required", true)
+	st.Unlock()
+	s.vars = map[string]string{"id": chg.ID()}
+
+	req, err := http.NewRequest("GET", "/v2/change/"+chg.ID(), nil)
+	c.Assert(err, check.IsNil)
+
+	// only reported once the change is done
+	rsp := getChange(stateChangeCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result.(*changeInfo).RebootRequired, check.Equals, false)
+
+	st.Lock()
+	t.SetStatus(state.DoneStatus)
+	st.Unlock()
+
+	rsp = getChange(stateChangeCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result.(*changeInfo).RebootRequired, check.Equals, true)
+}
+
 func (s *apiSuite) TestStateChangeAbort(c *check.C) {
 	restore := state.MockTime(time.Date(2016, 04, 21, 1, 2, 3, 0, time.UTC))
 	defer restore()
@@ -2738,3 +4313,166 @@ func (s *apiSuite) TestStateChangeAbortIsReady(c *check.C) {
 		"message": fmt.Sprintf("cannot abort change %s with nothing pending", ids[0]),
 	})
 }
+
+func (s *apiSuite) TestSetConfGetConf(c *check.C) {
+	d := s.daemon(c)
+	s.mkInstalledInState(c, d, "foo", "bar", "v1", snap.R(1), true, "")
+	s.vars = map[string]string{"name": "foo"}
+
+	buf := bytes.NewBufferString(`{"key": "value", "nested.key": 42}`)
+	req, err := http.NewRequest("PUT", "/v2/snaps/foo/conf", buf)
+	c.Assert(err, check.IsNil)
+	rsp := setSnapConf(snapConfCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+
+	req, err = http.NewRequest("GET", "/v2/snaps/foo/conf?keys=key,nested", nil)
+	c.Assert(err, check.IsNil)
+	rsp = getSnapConf(snapConfCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.DeepEquals, map[string]interface{}{
+		"key":    "value",
+		"nested": map[string]interface{}{"key": json.Number("42")},
+	})
+}
+
+func (s *apiSuite) TestGetConfAll(c *check.C) {
+	d := s.daemon(c)
+	s.mkInstalledInState(c, d, "foo", "bar", "v1", snap.R(1), true, "")
+	s.vars = map[string]string{"name": "foo"}
+
+	req, err := http.NewRequest("GET", "/v2/snaps/foo/conf", nil)
+	c.Assert(err, check.IsNil)
+	rsp := getSnapConf(snapConfCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.DeepEquals, map[string]interface{}{})
+
+	buf := bytes.NewBufferString(`{"key": "value", "nested.key": 42}`)
+	req, err = http.NewRequest("PUT", "/v2/snaps/foo/conf", buf)
+	c.Assert(err, check.IsNil)
+	rsp = setSnapConf(snapConfCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+
+	req, err = http.NewRequest("GET", "/v2/snaps/foo/conf", nil)
+	c.Assert(err, check.IsNil)
+	rsp = getSnapConf(snapConfCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.DeepEquals, map[string]interface{}{
+		"key":    "value",
+		"nested": map[string]interface{}{"key": json.Number("42")},
+	})
+}
+
+func (s *apiSuite) TestSetConfAllOrNothing(c *check.C) {
+	d := s.daemon(c)
+	s.vars = map[string]string{"name": "core"}
+
+	buf := bytes.NewBufferString(`{"error-reports.enable": true, "error-reports.url": "x"}`)
+	req, err := http.NewRequest("PUT", "/v2/snaps/core/conf", buf)
+	c.Assert(err, check.IsNil)
+	rsp := setSnapConf(snapConfCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, http.StatusBadRequest)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `cannot set configuration of snap "core": cannot set "error-reports.url": invalid URL "x"`)
+
+	st := d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	config, err := configstate.Config(st, "core")
+	c.Assert(err, check.IsNil)
+	c.Check(config, check.HasLen, 0)
+}
+
+func (s *apiSuite) TestSetConfConfigureHook(c *check.C) {
+	d := s.daemon(c)
+	cmd := testutil.MockCommand(c, "snap", "exit 1")
+	defer cmd.Restore()
+	d.overlord.Loop()
+	defer d.overlord.Stop()
+	s.mkInstalledInState(c, d, "foo", "bar", "v1", snap.R(1), true, "hooks:\n  configure:\n")
+	s.vars = map[string]string{"name": "foo"}
+
+	buf := bytes.NewBufferString(`{"key": "value"}`)
+	req, err := http.NewRequest("PUT", "/v2/snaps/foo/conf", buf)
+	c.Assert(err, check.IsNil)
+	rsp := setSnapConf(snapConfCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeAsync)
+
+	st := d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Kind(), check.Equals, "configure-snap")
+	c.Check(chg.Summary(), check.Equals, `Change configuration of "foo" snap`)
+	tasks := chg.Tasks()
+	c.Assert(tasks, check.HasLen, 1)
+	c.Check(tasks[0].Kind(), check.Equals, "run-hook")
+}
+
+func (s *apiSuite) TestSetConfSchema(c *check.C) {
+	d := s.daemon(c)
+	s.mkInstalledInState(c, d, "foo", "bar", "v1", snap.R(1), true, "")
+	schema := "options:\n  port:\n    type: integer\n    maximum: 65535\n  name:\n    type: string\n"
+	schemaFile := filepath.Join(dirs.SnapSnapsDir, "foo", "1", "meta", "config-schema.yaml")
+	c.Assert(ioutil.WriteFile(schemaFile, []byte(schema), 0644), check.IsNil)
+	s.vars = map[string]string{"name": "foo"}
+
+	for _, t := range []struct {
+		patch string
+		err   string
+	}{
+		{`{"name": "x", "prot": 80}`, `cannot set configuration of snap "foo": unknown option "prot"`},
+		{`{"name": "x", "port": 70000}`, `cannot set configuration of snap "foo": option "port" must be at most 65535`},
+		{`{"name": 1}`, `cannot set configuration of snap "foo": option "name" must be a string`},
+	} {
+		req, err := http.NewRequest("PUT", "/v2/snaps/foo/conf", bytes.NewBufferString(t.patch))
+		c.Assert(err, check.IsNil)
+		rsp := setSnapConf(snapConfCmd, req, nil).(*resp)
+		c.Check(rsp.Status, check.Equals, http.StatusBadRequest)
+		c.Check(rsp.Result.(*errorResult).Message, check.Equals, t.err)
+	}
+
+	// nothing was set
+	req, err := http.NewRequest("GET", "/v2/snaps/foo/conf?keys=name", nil)
+	c.Assert(err, check.IsNil)
+	rsp := getSnapConf(snapConfCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, http.StatusNotFound)
+
+	req, err = http.NewRequest("PUT", "/v2/snaps/foo/conf", bytes.NewBufferString(`{"name": "x", "port": 8080}`))
+	c.Assert(err, check.IsNil)
+	rsp = setSnapConf(snapConfCmd, req, nil).(*resp)
+	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
+}
+
+func (s *apiSuite) TestGetConfMissingOption(c *check.C) {
+	s.daemon(c)
+	s.vars = map[string]string{"name": "core"}
+
+	req, err := http.NewRequest("GET", "/v2/snaps/core/conf?keys=store-certs", nil)
+	c.Assert(err, check.IsNil)
+	rsp := getSnapConf(snapConfCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, http.StatusNotFound)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `snap "core" has no "store-certs" configuration option`)
+}
+
+func (s *apiSuite) TestSetConfUnknownSnap(c *check.C) {
+	s.daemon(c)
+	s.vars = map[string]string{"name": "foo"}
+
+	buf := bytes.NewBufferString(`{"key": "value"}`)
+	req, err := http.NewRequest("PUT", "/v2/snaps/foo/conf", buf)
+	c.Assert(err, check.IsNil)
+	rsp := setSnapConf(snapConfCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, http.StatusNotFound)
+}
+
+func (s *apiSuite) TestSetConfStoreCertsInvalid(c *check.C) {
+	s.daemon(c)
+	s.vars = map[string]string{"name": "core"}
+
+	buf := bytes.NewBufferString(`{"store-certs.corp": "garbage"}`)
+	req, err := http.NewRequest("PUT", "/v2/snaps/core/conf", buf)
+	c.Assert(err, check.IsNil)
+	rsp := setSnapConf(snapConfCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, http.StatusBadRequest)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `cannot set configuration of snap "core": cannot use store certificate "corp": not a PEM encoded certificate`)
+}
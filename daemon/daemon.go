@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"os/exec"
 	"strings"
 	"time"
 
@@ -35,6 +36,7 @@ import (
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord"
 	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/store"
 )
 
@@ -46,6 +48,7 @@ type Daemon struct {
 	tomb     tomb.Tomb
 	router   *mux.Router
 	hub      *notifications.Hub
+	activity activity
 	// enableInternalInterfaceActions controls if adding and removing slots and plugs is allowed.
 	enableInternalInterfaceActions bool
 }
@@ -223,8 +226,12 @@ func (d *Daemon) addRoutes() {
 func (d *Daemon) Start() {
 	// the loop runs in its own goroutine
 	d.overlord.Loop()
+	// ensure right away, as snapd may have been started again for
+	// files to check, e.g. the configuration files of snaps
+	d.overlord.State().EnsureBefore(0)
+	d.activity.last = timeNow()
 	d.tomb.Go(func() error {
-		if err := http.Serve(d.listener, logit(d.router)); err != nil && d.tomb.Err() == tomb.ErrStillAlive {
+		if err := http.Serve(d.listener, d.activity.track(logit(d.router))); err != nil && d.tomb.Err() == tomb.ErrStillAlive {
 			return err
 		}
 
@@ -257,12 +264,32 @@ func (d *Daemon) auther(r *http.Request) (store.Authenticator, error) {
 	return user.Authenticator(), nil
 }
 
+// reboot has the system reboot.
+var reboot = func() error {
+	if output, err := exec.Command("shutdown", "-r", "now", "Rebooting to finish applying snap refreshes").CombinedOutput(); err != nil {
+		return fmt.Errorf("cannot reboot: %s (%s)", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func handleRestart(t state.RestartType) {
+	switch t {
+	case state.RestartSystem:
+		if err := reboot(); err != nil {
+			logger.Noticef("%v", err)
+		}
+	default:
+		logger.Noticef("restart requested but not supported")
+	}
+}
+
 // New Daemon
 func New() (*Daemon, error) {
 	ovld, err := overlord.New()
 	if err != nil {
 		return nil, err
 	}
+	ovld.SetRestartHandler(handleRestart)
 	return &Daemon{
 		overlord: ovld,
 		hub:      notifications.NewHub(),
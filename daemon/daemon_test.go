@@ -22,11 +22,14 @@ package daemon
 import (
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/gorilla/mux"
 	"gopkg.in/check.v1"
 
+	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/overlord/auth"
 )
 
@@ -37,6 +40,16 @@ type daemonSuite struct{}
 
 var _ = check.Suite(&daemonSuite{})
 
+func (s *daemonSuite) SetUpTest(c *check.C) {
+	dirs.SetRootDir(c.MkDir())
+	err := os.MkdirAll(filepath.Dir(dirs.SnapStateFile), 0755)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *daemonSuite) TearDownTest(c *check.C) {
+	dirs.SetRootDir("")
+}
+
 // build a new daemon, with only a little of Init(), suitable for the tests
 func newTestDaemon(c *check.C) *Daemon {
 	d, err := New()
@@ -103,19 +116,22 @@ func (s *daemonSuite) TestGuestAccess(c *check.C) {
 	pst := &http.Request{Method: "POST"}
 	del := &http.Request{Method: "DELETE"}
 
-	cmd := &Command{d: newTestDaemon(c)}
+	// a daemon holds the state lock, so they all share one
+	d := newTestDaemon(c)
+
+	cmd := &Command{d: d}
 	c.Check(cmd.canAccess(get, nil), check.Equals, false)
 	c.Check(cmd.canAccess(put, nil), check.Equals, false)
 	c.Check(cmd.canAccess(pst, nil), check.Equals, false)
 	c.Check(cmd.canAccess(del, nil), check.Equals, false)
 
-	cmd = &Command{d: newTestDaemon(c), UserOK: true}
+	cmd = &Command{d: d, UserOK: true}
 	c.Check(cmd.canAccess(get, nil), check.Equals, false)
 	c.Check(cmd.canAccess(put, nil), check.Equals, false)
 	c.Check(cmd.canAccess(pst, nil), check.Equals, false)
 	c.Check(cmd.canAccess(del, nil), check.Equals, false)
 
-	cmd = &Command{d: newTestDaemon(c), GuestOK: true}
+	cmd = &Command{d: d, GuestOK: true}
 	c.Check(cmd.canAccess(get, nil), check.Equals, true)
 	c.Check(cmd.canAccess(put, nil), check.Equals, false)
 	c.Check(cmd.canAccess(pst, nil), check.Equals, false)
@@ -126,15 +142,17 @@ func (s *daemonSuite) TestUserAccess(c *check.C) {
 	get := &http.Request{Method: "GET", RemoteAddr: "uid=42;"}
 	put := &http.Request{Method: "PUT", RemoteAddr: "uid=42;"}
 
-	cmd := &Command{d: newTestDaemon(c)}
+	d := newTestDaemon(c)
+
+	cmd := &Command{d: d}
 	c.Check(cmd.canAccess(get, nil), check.Equals, false)
 	c.Check(cmd.canAccess(put, nil), check.Equals, false)
 
-	cmd = &Command{d: newTestDaemon(c), UserOK: true}
+	cmd = &Command{d: d, UserOK: true}
 	c.Check(cmd.canAccess(get, nil), check.Equals, true)
 	c.Check(cmd.canAccess(put, nil), check.Equals, false)
 
-	cmd = &Command{d: newTestDaemon(c), GuestOK: true}
+	cmd = &Command{d: d, GuestOK: true}
 	c.Check(cmd.canAccess(get, nil), check.Equals, true)
 	c.Check(cmd.canAccess(put, nil), check.Equals, false)
 }
@@ -152,17 +170,19 @@ func (s *daemonSuite) TestGroupAccess(c *check.C) {
 	get := &http.Request{Method: "GET", RemoteAddr: "uid=42;"}
 	put := &http.Request{Method: "PUT", RemoteAddr: "uid=42;"}
 
-	cmd := &Command{d: newTestDaemon(c)}
+	d := newTestDaemon(c)
+
+	cmd := &Command{d: d}
 	c.Check(cmd.canAccess(get, nil), check.Equals, false)
 	c.Check(cmd.canAccess(put, nil), check.Equals, false)
 
 	isSudo = false
-	cmd = &Command{d: newTestDaemon(c), SudoerOK: true}
+	cmd = &Command{d: d, SudoerOK: true}
 	c.Check(cmd.canAccess(get, nil), check.Equals, false)
 	c.Check(cmd.canAccess(put, nil), check.Equals, false)
 
 	isSudo = true
-	cmd = &Command{d: newTestDaemon(c), SudoerOK: true}
+	cmd = &Command{d: d, SudoerOK: true}
 	c.Check(cmd.canAccess(get, nil), check.Equals, true)
 	c.Check(cmd.canAccess(put, nil), check.Equals, true)
 }
@@ -171,15 +191,17 @@ func (s *daemonSuite) TestSuperAccess(c *check.C) {
 	get := &http.Request{Method: "GET", RemoteAddr: "uid=0;"}
 	put := &http.Request{Method: "PUT", RemoteAddr: "uid=0;"}
 
-	cmd := &Command{d: newTestDaemon(c)}
+	d := newTestDaemon(c)
+
+	cmd := &Command{d: d}
 	c.Check(cmd.canAccess(get, nil), check.Equals, true)
 	c.Check(cmd.canAccess(put, nil), check.Equals, true)
 
-	cmd = &Command{d: newTestDaemon(c), UserOK: true}
+	cmd = &Command{d: d, UserOK: true}
 	c.Check(cmd.canAccess(get, nil), check.Equals, true)
 	c.Check(cmd.canAccess(put, nil), check.Equals, true)
 
-	cmd = &Command{d: newTestDaemon(c), GuestOK: true}
+	cmd = &Command{d: d, GuestOK: true}
 	c.Check(cmd.canAccess(get, nil), check.Equals, true)
 	c.Check(cmd.canAccess(put, nil), check.Equals, true)
 }
@@ -0,0 +1,123 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/ifacestate"
+	"github.com/snapcore/snapd/snap"
+)
+
+// denialInfo is an aggregate of apparmor denials, with the interfaces
+// whose connection would have allowed the access.
+type denialInfo struct {
+	*ifacestate.Denial
+	Suggestions []interfaceSuggestion `json:"suggestions,omitempty"`
+}
+
+// interfaceSuggestion is an interface whose connection would allow an
+// access, with the plug of the snap for it if it has one.
+type interfaceSuggestion struct {
+	Interface string `json:"interface"`
+	Plug      string `json:"plug,omitempty"`
+	Connected bool   `json:"connected"`
+}
+
+// denialSuggestions returns the interfaces whose connection would have
+// allowed the denied access, if it is to a file or a capability.
+func denialSuggestions(repo *interfaces.Repository, info *snap.Info, d *ifacestate.Denial) []interfaceSuggestion {
+	if d.Path == "" && d.Capability == "" {
+		return nil
+	}
+	var securityTag string
+	if app, ok := info.Apps[d.App]; ok {
+		securityTag = app.SecurityTag()
+	} else if hook, ok := info.Hooks[d.Hook]; ok {
+		securityTag = hook.SecurityTag()
+	} else {
+		return nil
+	}
+	profile, err := ioutil.ReadFile(filepath.Join(dirs.SnapAppArmorDir, securityTag))
+	if err != nil {
+		return nil
+	}
+	base := apparmor.ParseRules(profile, nil)
+
+	var suggestions []interfaceSuggestion
+	forEachInterfaceRules(repo, info, d.App, d.Hook, base, func(iface string, own *interfaces.Plug, rules *apparmor.Rules) {
+		if d.Capability != "" && !rules.Capability(d.Capability) {
+			return
+		}
+		if d.Path != "" {
+			access := rules.Access(d.Path)
+			if !access.Any() || !access.Covers(apparmor.MaskAccess(d.Denied)) {
+				return
+			}
+		}
+		suggestion := interfaceSuggestion{Interface: iface}
+		if own != nil {
+			suggestion.Plug = own.Name
+			suggestion.Connected = len(own.Connections) > 0
+		}
+		suggestions = append(suggestions, suggestion)
+	})
+	return suggestions
+}
+
+// getDenials returns the apparmor denials collected for the snap given
+// with snap=, or for all the snaps, with the interfaces that would
+// allow the denied accesses of the installed ones.
+func getDenials(c *Command, r *http.Request, user *auth.UserState) Response {
+	snapName := r.URL.Query().Get("snap")
+
+	st := c.d.overlord.State()
+	st.Lock()
+	denials, err := ifacestate.Denials(st, snapName)
+	st.Unlock()
+	if err != nil {
+		return InternalError("cannot list apparmor denials: %v", err)
+	}
+
+	repo := c.d.overlord.InterfaceManager().Repository()
+	infos := make(map[string]*snap.Info)
+	result := make([]denialInfo, 0, len(denials))
+	for _, d := range denials {
+		info, ok := infos[d.Snap]
+		if !ok {
+			// the suggestions are only for installed snaps
+			info, _, _ = localSnapInfo(st, d.Snap)
+			infos[d.Snap] = info
+		}
+		denial := denialInfo{Denial: d}
+		if info != nil {
+			denial.Suggestions = denialSuggestions(repo, info, d)
+		}
+		result = append(result, denial)
+	}
+
+	return SyncResponse(result, nil)
+}
@@ -0,0 +1,209 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/snap"
+)
+
+// fileAccess is what an application of a snap can do with a path under
+// its current confinement, and the interfaces that would let it do
+// more.
+type fileAccess struct {
+	Snap    string `json:"snap"`
+	App     string `json:"app"`
+	Path    string `json:"path"`
+	DevMode bool   `json:"devmode,omitempty"`
+	apparmor.Access
+	Interfaces []fileAccessInterface `json:"interfaces,omitempty"`
+}
+
+// fileAccessInterface is what connecting a plug of an interface lets an
+// application do with a path.
+type fileAccessInterface struct {
+	Interface string `json:"interface"`
+	// Plug is the plug of the snap for the interface, if it has one.
+	Plug      string `json:"plug,omitempty"`
+	Connected bool   `json:"connected"`
+	apparmor.Access
+}
+
+// snapApp returns the application of the snap with the given name, or
+// its only one if the name is empty.
+func snapApp(info *snap.Info, appName string) (*snap.AppInfo, Response) {
+	if appName != "" {
+		app, ok := info.Apps[appName]
+		if !ok {
+			return nil, NotFound("cannot find app %q in snap %q", appName, info.Name())
+		}
+		return app, nil
+	}
+	if app, ok := info.Apps[info.Name()]; ok {
+		return app, nil
+	}
+	if len(info.Apps) != 1 {
+		return nil, BadRequest("snap %q has %d apps, one has to be given", info.Name(), len(info.Apps))
+	}
+	for _, app := range info.Apps {
+		return app, nil
+	}
+	return nil, nil
+}
+
+// getFileAccess returns whether an application of a snap can read,
+// write or execute a path, according to its apparmor profile, and which
+// interfaces would allow it.
+func getFileAccess(c *Command, r *http.Request, user *auth.UserState) Response {
+	query := r.URL.Query()
+	path := query.Get("path")
+	if !strings.HasPrefix(path, "/") {
+		return BadRequest("path %q is not absolute", path)
+	}
+	// a trailing slash tells directories apart for apparmor
+	if cleaned := filepath.Clean(path); strings.HasSuffix(path, "/") && cleaned != "/" {
+		path = cleaned + "/"
+	} else {
+		path = cleaned
+	}
+
+	snapName := query.Get("snap")
+	info, snapst, err := localSnapInfo(c.d.overlord.State(), snapName)
+	if err == errNoSnap {
+		return NotFound("cannot find snap %q", snapName)
+	}
+	if err != nil {
+		return InternalError("%v", err)
+	}
+	app, rsp := snapApp(info, query.Get("app"))
+	if rsp != nil {
+		return rsp
+	}
+
+	profile, err := ioutil.ReadFile(filepath.Join(dirs.SnapAppArmorDir, app.SecurityTag()))
+	if err != nil {
+		return InternalError("cannot read apparmor profile of %q: %v", app.SecurityTag(), err)
+	}
+	rules := apparmor.ParseRules(profile, nil)
+
+	result := fileAccess{
+		Snap:    info.Name(),
+		App:     app.Name,
+		Path:    path,
+		DevMode: snapst.DevMode(),
+		Access:  rules.Access(path),
+	}
+	if result.DevMode {
+		// denials are only logged
+		result.Access = apparmor.Access{Read: true, Write: true, Execute: true}
+	}
+	repo := c.d.overlord.InterfaceManager().Repository()
+	result.Interfaces = grantingInterfaces(repo, info, app.Name, path, rules)
+
+	return SyncResponse(result, nil)
+}
+
+// forEachInterfaceRules calls f with each interface having a slot, the
+// plug of the snap for it bound to the given app or hook if there is
+// one, and the apparmor rules the connection of the interface would add
+// for the app or hook, using the variables of the base rules.
+func forEachInterfaceRules(repo *interfaces.Repository, info *snap.Info, appName, hookName string, base *apparmor.Rules, f func(iface string, own *interfaces.Plug, rules *apparmor.Rules)) {
+	plugs := make(map[string]*interfaces.Plug)
+	for _, plug := range repo.Plugs(info.Name()) {
+		_, forApp := plug.Apps[appName]
+		_, forHook := plug.Hooks[hookName]
+		if forApp || forHook {
+			plugs[plug.Interface] = plug
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, slot := range repo.Interfaces().Slots {
+		if seen[slot.Interface] {
+			continue
+		}
+		seen[slot.Interface] = true
+		iface := repo.Interface(slot.Interface)
+		if iface == nil {
+			continue
+		}
+
+		own := plugs[slot.Interface]
+		plug := own
+		if plug == nil {
+			plugInfo := &snap.PlugInfo{
+				Snap:      info,
+				Name:      slot.Interface,
+				Interface: slot.Interface,
+			}
+			if hookName != "" {
+				plugInfo.Hooks = map[string]*snap.HookInfo{hookName: info.Hooks[hookName]}
+			} else {
+				plugInfo.Apps = map[string]*snap.AppInfo{appName: info.Apps[appName]}
+			}
+			plug = &interfaces.Plug{PlugInfo: plugInfo}
+		}
+		snippet, err := iface.ConnectedPlugSnippet(plug, slot, interfaces.SecurityAppArmor)
+		if err != nil || snippet == nil {
+			continue
+		}
+		f(slot.Interface, own, apparmor.ParseRules(snippet, base))
+	}
+}
+
+// grantingInterfaces returns the interfaces whose connection lets the
+// given application access the path, using the plug of the snap for
+// them if it has one, and a slot of any snap.
+func grantingInterfaces(repo *interfaces.Repository, info *snap.Info, appName, path string, base *apparmor.Rules) []fileAccessInterface {
+	var granting []fileAccessInterface
+	forEachInterfaceRules(repo, info, appName, "", base, func(iface string, own *interfaces.Plug, rules *apparmor.Rules) {
+		access := rules.Access(path)
+		if !access.Any() {
+			return
+		}
+		granted := fileAccessInterface{
+			Interface: iface,
+			Access:    access,
+		}
+		if own != nil {
+			granted.Plug = own.Name
+			granted.Connected = len(own.Connections) > 0
+		}
+		granting = append(granting, granted)
+	})
+	sort.Sort(byInterfaceName(granting))
+
+	return granting
+}
+
+type byInterfaceName []fileAccessInterface
+
+func (s byInterfaceName) Len() int           { return len(s) }
+func (s byInterfaceName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byInterfaceName) Less(i, j int) bool { return s[i].Interface < s[j].Interface }
@@ -0,0 +1,159 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/systemd"
+)
+
+var (
+	// idleCheckInterval is how often the daemon checks whether it is
+	// idle when it exits when idle.
+	idleCheckInterval = time.Minute
+
+	timeNow = time.Now
+)
+
+// wakeupTimer is the systemd timer starting snapd again when the work
+// it scheduled is due after it exited.
+const wakeupTimer = "snapd-wakeup.timer"
+
+// activity tracks the requests served by the daemon.
+type activity struct {
+	mu     sync.Mutex
+	active int
+	last   time.Time
+}
+
+func (a *activity) track(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.mu.Lock()
+		a.active++
+		a.mu.Unlock()
+		defer func() {
+			a.mu.Lock()
+			a.active--
+			a.last = timeNow()
+			a.mu.Unlock()
+		}()
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// idleFor returns how long no request was served for.
+func (a *activity) idleFor() time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.active > 0 {
+		return 0
+	}
+	return timeNow().Sub(a.last)
+}
+
+func wakeupTimerContent(wakeup time.Time) string {
+	return fmt.Sprintf(`[Unit]
+Description=Start snapd for its scheduled work
+
+[Timer]
+OnCalendar=%s
+Persistent=true
+AccuracySec=1min
+Unit=snapd.service
+`, wakeup.Local().Format("2006-01-02 15:04:05"))
+}
+
+// scheduleWakeup hands to systemd the starting of snapd at the given
+// time, or does away with it if the time is zero.
+func scheduleWakeup(wakeup time.Time) error {
+	path := filepath.Join(dirs.SnapServicesDir, wakeupTimer)
+	sysd := systemd.New(dirs.GlobalRootDir, nil)
+	if wakeup.IsZero() {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return sysd.DaemonReload()
+	}
+
+	if err := os.MkdirAll(dirs.SnapServicesDir, 0755); err != nil {
+		return err
+	}
+	if err := osutil.AtomicWriteFile(path, []byte(wakeupTimerContent(wakeup)), 0644, 0); err != nil {
+		return err
+	}
+	if err := sysd.DaemonReload(); err != nil {
+		return err
+	}
+	return sysd.Start(wakeupTimer)
+}
+
+// idle returns whether the daemon can exit: no request was served for
+// the given time, no change is in progress and the next work scheduled
+// is not due within that time either. It then hands the scheduled work
+// to systemd, so that snapd is started again for it.
+func (d *Daemon) idle(timeout time.Duration) bool {
+	if d.activity.idleFor() < timeout {
+		return false
+	}
+	idle, wakeup := d.overlord.Idle()
+	if !idle {
+		return false
+	}
+	if !wakeup.IsZero() && wakeup.Sub(timeNow()) < timeout {
+		return false
+	}
+	if err := scheduleWakeup(wakeup); err != nil {
+		logger.Noticef("cannot schedule the next start of snapd: %v", err)
+		return false
+	}
+	return true
+}
+
+// ExitWhenIdle has the daemon stop once it has been idle for the given
+// time, to free its memory. snapd is started again by the activation of
+// its socket when a request comes, or by systemd when the work it
+// scheduled is due. The state is saved as the daemon stops.
+func (d *Daemon) ExitWhenIdle(timeout time.Duration) {
+	d.tomb.Go(func() error {
+		ticker := time.NewTicker(idleCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-d.tomb.Dying():
+				return nil
+			case <-ticker.C:
+			}
+			if d.idle(timeout) {
+				logger.Noticef("Exiting after being idle for %s.", timeout)
+				d.tomb.Kill(nil)
+				return nil
+			}
+		}
+	})
+}
@@ -0,0 +1,166 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2014-2015 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/systemd"
+)
+
+type idleSuite struct {
+	d        *Daemon
+	sysdArgs [][]string
+
+	restoreSystemctl func()
+}
+
+var _ = check.Suite(&idleSuite{})
+
+func (s *idleSuite) SetUpTest(c *check.C) {
+	dirs.SetRootDir(c.MkDir())
+	c.Assert(os.MkdirAll(filepath.Dir(dirs.SnapStateFile), 0755), check.IsNil)
+
+	s.sysdArgs = nil
+	oldSystemctl := systemd.SystemctlCmd
+	systemd.SystemctlCmd = func(args ...string) ([]byte, error) {
+		s.sysdArgs = append(s.sysdArgs, args)
+		return nil, nil
+	}
+	s.restoreSystemctl = func() { systemd.SystemctlCmd = oldSystemctl }
+
+	s.d = newTestDaemon(c)
+	s.d.activity.last = time.Now().Add(-time.Hour)
+}
+
+func (s *idleSuite) TearDownTest(c *check.C) {
+	s.restoreSystemctl()
+	dirs.SetRootDir("")
+}
+
+func mockTimeNow(now time.Time) (restore func()) {
+	old := timeNow
+	timeNow = func() time.Time { return now }
+	return func() { timeNow = old }
+}
+
+func (s *idleSuite) setNextRefresh(next time.Time) {
+	st := s.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	st.Set("refresh-status", map[string]interface{}{"next-attempt": next})
+}
+
+func (s *idleSuite) TestActivityTracking(c *check.C) {
+	var a activity
+	a.last = time.Now().Add(-time.Hour)
+	c.Check(a.idleFor() >= time.Hour, check.Equals, true)
+
+	handler := a.track(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// busy while serving
+		c.Check(a.idleFor(), check.Equals, time.Duration(0))
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), &http.Request{})
+
+	c.Check(a.idleFor() < time.Minute, check.Equals, true)
+}
+
+func (s *idleSuite) TestIdleSchedulesWakeup(c *check.C) {
+	next := time.Date(2016, 10, 1, 12, 0, 0, 0, time.Local)
+	s.setNextRefresh(next)
+	restore := mockTimeNow(next.Add(-time.Hour))
+	defer restore()
+	s.d.activity.last = next.Add(-2 * time.Hour)
+
+	c.Check(s.d.idle(10*time.Minute), check.Equals, true)
+
+	content, err := ioutil.ReadFile(filepath.Join(dirs.SnapServicesDir, "snapd-wakeup.timer"))
+	c.Assert(err, check.IsNil)
+	c.Check(string(content), check.Equals, `[Unit]
+Description=Start snapd for its scheduled work
+
+[Timer]
+OnCalendar=2016-10-01 12:00:00
+Persistent=true
+AccuracySec=1min
+Unit=snapd.service
+`)
+	c.Check(s.sysdArgs, check.DeepEquals, [][]string{
+		{"daemon-reload"},
+		{"start", "snapd-wakeup.timer"},
+	})
+}
+
+func (s *idleSuite) TestIdleNothingScheduled(c *check.C) {
+	path := filepath.Join(dirs.SnapServicesDir, "snapd-wakeup.timer")
+	c.Assert(os.MkdirAll(dirs.SnapServicesDir, 0755), check.IsNil)
+	c.Assert(ioutil.WriteFile(path, nil, 0644), check.IsNil)
+
+	c.Check(s.d.idle(10*time.Minute), check.Equals, true)
+
+	_, err := os.Stat(path)
+	c.Check(os.IsNotExist(err), check.Equals, true)
+	c.Check(s.sysdArgs, check.DeepEquals, [][]string{{"daemon-reload"}})
+}
+
+func (s *idleSuite) TestNotIdleWhenServing(c *check.C) {
+	s.d.activity.last = time.Now().Add(-5 * time.Minute)
+	c.Check(s.d.idle(10*time.Minute), check.Equals, false)
+	c.Check(s.sysdArgs, check.HasLen, 0)
+}
+
+func (s *idleSuite) TestNotIdleWithChangeInProgress(c *check.C) {
+	st := s.d.overlord.State()
+	st.Lock()
+	chg := st.NewChange("install-snap", "...")
+	chg.AddTask(st.NewTask("nop", "..."))
+	st.Unlock()
+
+	c.Check(s.d.idle(10*time.Minute), check.Equals, false)
+	c.Check(s.sysdArgs, check.HasLen, 0)
+}
+
+func (s *idleSuite) TestNotIdleWhenWorkIsDue(c *check.C) {
+	s.setNextRefresh(time.Now().Add(5 * time.Minute))
+	c.Check(s.d.idle(10*time.Minute), check.Equals, false)
+	c.Check(s.sysdArgs, check.HasLen, 0)
+}
+
+func (s *idleSuite) TestExitWhenIdle(c *check.C) {
+	oldInterval := idleCheckInterval
+	idleCheckInterval = time.Millisecond
+	defer func() { idleCheckInterval = oldInterval }()
+
+	s.d.ExitWhenIdle(10 * time.Minute)
+	select {
+	case <-s.d.Dying():
+	case <-time.After(5 * time.Second):
+		c.Fatal("daemon did not exit when idle")
+	}
+	c.Check(s.d.tomb.Wait(), check.IsNil)
+}
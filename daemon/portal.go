@@ -0,0 +1,77 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/snap"
+)
+
+// cgroupInfo describes the snap application or hook a process belongs to.
+type cgroupInfo struct {
+	Snap     string        `json:"snap"`
+	App      string        `json:"app,omitempty"`
+	Hook     string        `json:"hook,omitempty"`
+	Revision snap.Revision `json:"revision"`
+}
+
+// portalInfo is what xdg-desktop-portal needs to know about the snap a
+// process belongs to.
+type portalInfo struct {
+	Snap        string `json:"snap"`
+	App         string `json:"app,omitempty"`
+	DesktopFile string `json:"desktop-file,omitempty"`
+	HasNetwork  bool   `json:"has-network"`
+}
+
+// snapDesktopFile returns the path of the installed desktop file of the
+// given application, or an empty string if it has none.
+func snapDesktopFile(info *snap.Info, appName string) string {
+	desktopFile := filepath.Join(dirs.SnapDesktopFilesDir, fmt.Sprintf("%s_%s.desktop", info.Name(), appName))
+	if _, err := os.Stat(desktopFile); err == nil {
+		return desktopFile
+	}
+	// a snap with a single desktop file uses it for all its apps
+	found, _ := filepath.Glob(filepath.Join(dirs.SnapDesktopFilesDir, info.Name()+"_*.desktop"))
+	if len(found) == 1 {
+		return found[0]
+	}
+
+	return ""
+}
+
+// appHasNetwork returns true if the application can access the network.
+func appHasNetwork(repo *interfaces.Repository, snapName, appName string) bool {
+	for _, plug := range repo.Plugs(snapName) {
+		if plug.Interface != "network" || len(plug.Connections) == 0 {
+			continue
+		}
+		if _, ok := plug.Apps[appName]; ok {
+			return true
+		}
+	}
+
+	return false
+}
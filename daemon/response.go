@@ -26,10 +26,12 @@ import (
 	"net/http"
 	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/gorilla/websocket"
 
 	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/notifications"
 )
@@ -92,7 +94,15 @@ func (r *resp) MarshalJSON() ([]byte, error) {
 	})
 }
 
-func (r *resp) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+func (r *resp) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if errResult, ok := r.Result.(*errorResult); ok {
+		if translated := errResult.translated(req); translated != errResult {
+			tr := *r
+			tr.Result = translated
+			r = &tr
+		}
+	}
+
 	status := r.Status
 	bs, err := r.MarshalJSON()
 	if err != nil {
@@ -124,6 +134,7 @@ const (
 	errorKindTwoFactorRequired = errorKind("two-factor-required")
 	errorKindTwoFactorFailed   = errorKind("two-factor-failed")
 	errorKindLoginRequired     = errorKind("login-required")
+	errorKindNothingToRefresh  = errorKind("nothing-to-refresh")
 )
 
 type errorValue interface{}
@@ -132,6 +143,47 @@ type errorResult struct {
 	Message string     `json:"message"` // note no omitempty
 	Kind    errorKind  `json:"kind,omitempty"`
 	Value   errorValue `json:"value,omitempty"`
+
+	// the untranslated format of the message and its arguments,
+	// to translate it to the language of the request
+	format string
+	args   []interface{}
+}
+
+// requestLocale returns the locale of the preferred language of the
+// request, e.g. de_DE for an Accept-Language of "de-DE,de;q=0.8".
+func requestLocale(r *http.Request) string {
+	if r == nil {
+		return ""
+	}
+	lang := strings.Split(r.Header.Get("Accept-Language"), ",")[0]
+	if i := strings.IndexByte(lang, ';'); i >= 0 {
+		lang = lang[:i]
+	}
+	lang = strings.TrimSpace(lang)
+	if lang == "*" {
+		return ""
+	}
+	return strings.Replace(lang, "-", "_", -1)
+}
+
+// translated returns the error result with its message translated for
+// the language of the request, or itself if there is no translation.
+func (e *errorResult) translated(r *http.Request) *errorResult {
+	if e.format == "" {
+		return e
+	}
+	catalog := i18n.CatalogFor(requestLocale(r))
+	if catalog == nil {
+		return e
+	}
+	format := catalog.G(e.format)
+	if format == e.format {
+		return e
+	}
+	translated := *e
+	translated.Message = fmt.Sprintf(format, e.args...)
+	return &translated
 }
 
 // SyncResponse builds a "sync" response from the given result.
@@ -169,6 +221,8 @@ func makeErrorResponder(status int) errorResponder {
 			Type: ResponseTypeError,
 			Result: &errorResult{
 				Message: fmt.Sprintf(format, v...),
+				format:  format,
+				args:    v,
 			},
 			Status: status,
 		}
@@ -20,6 +20,7 @@
 package daemon
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -28,6 +29,9 @@ import (
 	"path/filepath"
 
 	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/i18n/i18ntest"
 )
 
 type responseSuite struct{}
@@ -97,3 +101,56 @@ func (s *responseSuite) TestFileResponseSetsContentDisposition(c *check.C) {
 	c.Check(hdr.Get("Content-Disposition"), check.Equals,
 		fmt.Sprintf("attachment; filename=%s", filename))
 }
+
+func (s *responseSuite) TestErrorTranslatedToRequestLanguage(c *check.C) {
+	oldLocaleDir := i18n.LocaleDir
+	i18n.LocaleDir = c.MkDir()
+	defer func() { i18n.LocaleDir = oldLocaleDir }()
+	i18ntest.MockCatalog(c, i18n.LocaleDir, "de", i18n.TEXTDOMAIN, map[string]string{
+		"cannot find snap %q": "Snap %q nicht gefunden",
+	})
+
+	rsp := NotFound("cannot find snap %q", "foo").(*resp)
+
+	for _, t := range []struct {
+		lang    string
+		message string
+	}{
+		{"de-DE,de;q=0.8,en;q=0.5", `Snap "foo" nicht gefunden`},
+		{"de", `Snap "foo" nicht gefunden`},
+		{"fr-FR", `cannot find snap "foo"`},
+		{"", `cannot find snap "foo"`},
+	} {
+		req, err := http.NewRequest("GET", "/v2/snaps/foo", nil)
+		c.Assert(err, check.IsNil)
+		if t.lang != "" {
+			req.Header.Set("Accept-Language", t.lang)
+		}
+		rec := httptest.NewRecorder()
+		rsp.ServeHTTP(rec, req)
+		c.Check(rec.Code, check.Equals, http.StatusNotFound)
+		var body struct {
+			Result errorResult `json:"result"`
+		}
+		c.Assert(json.Unmarshal(rec.Body.Bytes(), &body), check.IsNil)
+		c.Check(body.Result.Message, check.Equals, t.message, check.Commentf(t.lang))
+	}
+
+	// the response itself is left untranslated
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `cannot find snap "foo"`)
+}
+
+func (s *responseSuite) TestRequestLocale(c *check.C) {
+	for lang, locale := range map[string]string{
+		"de-DE,de;q=0.8": "de_DE",
+		"gl;q=0.9":       "gl",
+		"*":              "",
+		"":               "",
+	} {
+		req, err := http.NewRequest("GET", "/", nil)
+		c.Assert(err, check.IsNil)
+		req.Header.Set("Accept-Language", lang)
+		c.Check(requestLocale(req), check.Equals, locale)
+	}
+	c.Check(requestLocale(nil), check.Equals, "")
+}
@@ -136,7 +136,7 @@ func mapLocal(localSnap *snap.Info, snapst *snapstate.SnapState) map[string]inte
 		})
 	}
 
-	return map[string]interface{}{
+	result := map[string]interface{}{
 		"description":    localSnap.Description(),
 		"developer":      localSnap.Developer,
 		"icon":           snapIcon(localSnap),
@@ -156,6 +156,12 @@ func mapLocal(localSnap *snap.Info, snapst *snapstate.SnapState) map[string]inte
 		"private":        localSnap.Private,
 		"apps":           apps,
 	}
+	if localSnap.NeedsLicenseAgreement() {
+		result["license-agreement"] = localSnap.LicenseAgreement
+		result["license-accepted"] = snapst.LicenseAccepted(localSnap)
+	}
+
+	return result
 }
 
 func mapRemote(remoteSnap *snap.Info) map[string]interface{} {
@@ -189,5 +195,33 @@ func mapRemote(remoteSnap *snap.Info) map[string]interface{} {
 	if len(remoteSnap.Prices) > 0 {
 		result["prices"] = remoteSnap.Prices
 	}
+	if remoteSnap.DeltaSize > 0 {
+		result["delta-size"] = remoteSnap.DeltaSize
+	}
+	if remoteSnap.ReleaseNotesURL != "" {
+		result["release-notes-url"] = remoteSnap.ReleaseNotesURL
+	}
+	if remoteSnap.Publisher != "" {
+		result["publisher"] = remoteSnap.Publisher
+	}
+	if remoteSnap.License != "" {
+		result["license"] = remoteSnap.License
+	}
+	if len(remoteSnap.Screenshots) > 0 {
+		result["screenshots"] = remoteSnap.Screenshots
+	}
+	if len(remoteSnap.Channels) > 0 {
+		result["channels"] = remoteSnap.Channels
+	}
+	return result
+}
+
+// mapRefresh maps an available update like mapRemote, adding what is
+// needed to review it against the installed revision.
+func mapRefresh(update, current *snap.Info) map[string]interface{} {
+	result := mapRemote(update)
+	result["current-version"] = current.Version
+	result["current-revision"] = current.Revision
+	result["reboot-required"] = snapstate.NeedsReboot(update)
 	return result
 }
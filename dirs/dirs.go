@@ -30,7 +30,20 @@ var (
 
 	SnapSnapsDir              string
 	SnapBlobDir               string
+	SnapPeerCacheDir          string
+	SnapStoreCacheDir         string
+	SnapPartialDownloadsDir   string
+	SnapDownloadCacheDir      string
+	SnapLibGLDir              string
+	SnapLibGL32Dir            string
+	SnapStoreCertsDir         string
+	SnapResolvDir             string
+	SnapErrorReportsDir       string
+	SnapQuarantineDir         string
+	SnapInstallManifestFile   string
+	SnapMountPolicyDir        string
 	SnapDataDir               string
+	SnapPublisherDataDir      string
 	SnapDataHomeGlob          string
 	SnapAppArmorDir           string
 	AppArmorCacheDir          string
@@ -40,16 +53,27 @@ var (
 	LocaleDir                 string
 	SnapMetaDir               string
 	SnapdSocket               string
+	SnapRebootRequiredFile    string
 
 	SnapAssertsDBDir      string
 	SnapTrustedAccountKey string
 
-	SnapStateFile string
+	SnapStateFile     string
+	SnapStateLogFile  string
+	SnapStateLockFile string
+
+	SnapConnectionPolicyFile    string
+	SnapConnectionPolicyKeyring string
+	SnapRefreshSpecKeyring      string
+	SnapStateArchiveKeyring     string
+
+	SnapConfigSourcesDir string
 
 	SnapBinariesDir     string
 	SnapServicesDir     string
 	SnapDesktopFilesDir string
 	SnapBusPolicyDir    string
+	SnapJournaldConfDir string
 
 	CloudMetaDataFile string
 
@@ -78,6 +102,7 @@ func SetRootDir(rootdir string) {
 
 	SnapSnapsDir = filepath.Join(rootdir, "/snap")
 	SnapDataDir = filepath.Join(rootdir, "/var/snap")
+	SnapPublisherDataDir = filepath.Join(rootdir, "/var/snap/.publisher")
 	SnapDataHomeGlob = filepath.Join(rootdir, "/home/*/snap/")
 	SnapAppArmorDir = filepath.Join(rootdir, snappyDir, "apparmor", "profiles")
 	AppArmorCacheDir = filepath.Join(rootdir, "/var/cache/apparmor")
@@ -85,18 +110,42 @@ func SetRootDir(rootdir string) {
 	SnapSeccompDir = filepath.Join(rootdir, snappyDir, "seccomp", "profiles")
 	SnapMetaDir = filepath.Join(rootdir, snappyDir, "meta")
 	SnapBlobDir = filepath.Join(rootdir, snappyDir, "snaps")
+	SnapPeerCacheDir = filepath.Join(rootdir, snappyDir, "peer-cache")
+	SnapStoreCacheDir = filepath.Join(rootdir, snappyDir, "store-cache")
+	SnapPartialDownloadsDir = filepath.Join(rootdir, snappyDir, "partial-downloads")
+	SnapDownloadCacheDir = filepath.Join(rootdir, snappyDir, "download-cache")
+	SnapLibGLDir = filepath.Join(rootdir, snappyDir, "lib", "gl")
+	SnapLibGL32Dir = filepath.Join(rootdir, snappyDir, "lib", "gl32")
+	SnapStoreCertsDir = filepath.Join(rootdir, snappyDir, "store-certs")
+	SnapResolvDir = filepath.Join(rootdir, snappyDir, "resolv")
+	SnapErrorReportsDir = filepath.Join(rootdir, snappyDir, "error-reports")
+	SnapQuarantineDir = filepath.Join(rootdir, snappyDir, "quarantine")
+	SnapInstallManifestFile = filepath.Join(rootdir, snappyDir, "install-manifest.jsonl")
+	SnapMountPolicyDir = filepath.Join(rootdir, snappyDir, "mount")
 	SnapDesktopFilesDir = filepath.Join(rootdir, snappyDir, "desktop", "applications")
 	// keep in sync with the debian/ubuntu-snappy.snapd.socket file:
 	SnapdSocket = filepath.Join(rootdir, "/run/snapd.socket")
+	// the flag distro tooling (e.g. update-notifier) looks at
+	SnapRebootRequiredFile = filepath.Join(rootdir, "/run/reboot-required")
 
 	SnapAssertsDBDir = filepath.Join(rootdir, snappyDir, "assertions")
 	SnapTrustedAccountKey = filepath.Join(rootdir, "/usr/share/snapd/trusted.acckey")
 
 	SnapStateFile = filepath.Join(rootdir, snappyDir, "state.json")
+	SnapStateLogFile = filepath.Join(rootdir, snappyDir, "state.log")
+	SnapStateLockFile = filepath.Join(rootdir, snappyDir, "state.lock")
+
+	SnapConnectionPolicyFile = filepath.Join(rootdir, "/etc/snapd/connection-policy.yaml")
+	SnapConnectionPolicyKeyring = filepath.Join(rootdir, "/etc/snapd/connection-policy.gpg")
+	SnapRefreshSpecKeyring = filepath.Join(rootdir, "/etc/snapd/refresh-spec.gpg")
+	SnapStateArchiveKeyring = filepath.Join(rootdir, "/etc/snapd/state-archive.gpg")
+
+	SnapConfigSourcesDir = filepath.Join(rootdir, "/etc/snapd/config")
 
 	SnapBinariesDir = filepath.Join(SnapSnapsDir, "bin")
 	SnapServicesDir = filepath.Join(rootdir, "/etc/systemd/system")
 	SnapBusPolicyDir = filepath.Join(rootdir, "/etc/dbus-1/system.d")
+	SnapJournaldConfDir = filepath.Join(rootdir, "/etc/systemd")
 
 	CloudMetaDataFile = filepath.Join(rootdir, "/var/lib/cloud/seed/nocloud-net/meta-data")
 
@@ -105,3 +154,12 @@ func SetRootDir(rootdir string) {
 	LocaleDir = filepath.Join(rootdir, "/usr/share/locale")
 	ClassicDir = filepath.Join(rootdir, "/writable/classic")
 }
+
+// StripRootDir strips the custom global root directory from the specified argument.
+func StripRootDir(dir string) string {
+	if GlobalRootDir == "/" {
+		return dir
+	}
+
+	return dir[len(GlobalRootDir):]
+}
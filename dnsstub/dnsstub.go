@@ -0,0 +1,296 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2014-2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package dnsstub implements the stub resolvers dedicated to snaps, which
+// log the names a snap resolves and forward the queries to chosen upstream
+// resolvers.
+package dnsstub
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// ListenAddress returns the loopback address the stub resolver of the
+// given snap listens on, in 127.53.0.0/16.
+func ListenAddress(snapName string) string {
+	h := fnv.New32a()
+	h.Write([]byte(snapName))
+	sum := h.Sum32()
+	return fmt.Sprintf("127.53.%d.%d", (sum>>8)&0xff, 1+sum%254)
+}
+
+var qtypes = map[uint16]string{
+	1:   "A",
+	2:   "NS",
+	5:   "CNAME",
+	6:   "SOA",
+	12:  "PTR",
+	15:  "MX",
+	16:  "TXT",
+	28:  "AAAA",
+	33:  "SRV",
+	255: "ANY",
+}
+
+var errMalformed = errors.New("malformed DNS message")
+
+// Question returns the name and the type of the first question of the
+// given DNS message.
+func Question(msg []byte) (name, qtype string, err error) {
+	if len(msg) < 12 || binary.BigEndian.Uint16(msg[4:6]) == 0 {
+		return "", "", errMalformed
+	}
+	var labels []string
+	i := 12
+	for {
+		if i >= len(msg) {
+			return "", "", errMalformed
+		}
+		n := int(msg[i])
+		i++
+		if n == 0 {
+			break
+		}
+		// questions of queries are never compressed
+		if n > 63 || i+n > len(msg) {
+			return "", "", errMalformed
+		}
+		labels = append(labels, string(msg[i:i+n]))
+		i += n
+	}
+	if i+2 > len(msg) {
+		return "", "", errMalformed
+	}
+	t := binary.BigEndian.Uint16(msg[i : i+2])
+	qtype, ok := qtypes[t]
+	if !ok {
+		qtype = fmt.Sprintf("TYPE%d", t)
+	}
+	return strings.Join(labels, ".") + ".", qtype, nil
+}
+
+// serverFailure returns the reply to the given query telling the
+// resolution failed.
+func serverFailure(query []byte) []byte {
+	reply := make([]byte, len(query))
+	copy(reply, query)
+	if len(reply) >= 4 {
+		reply[2] |= 0x80 // response
+		reply[3] = (reply[3] & 0xf0) | 2
+	}
+	return reply
+}
+
+// Stub is a stub resolver dedicated to a snap.
+type Stub struct {
+	// Snap is the name of the snap, for the log.
+	Snap string
+	// Upstream are the addresses of the resolvers queries are forwarded
+	// to, tried in order.
+	Upstream []string
+	// Timeout is how long to wait for an upstream resolver.
+	Timeout time.Duration
+	// Logf logs the queries.
+	Logf func(format string, args ...interface{})
+}
+
+// Listen opens the sockets, UDP and TCP, a stub resolver serves at the
+// given address.
+func Listen(addr string) (net.PacketConn, net.Listener, error) {
+	pc, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	l, err := net.Listen("tcp", pc.LocalAddr().String())
+	if err != nil {
+		pc.Close()
+		return nil, nil, err
+	}
+	return pc, l, nil
+}
+
+// Serve answers the queries received on the given sockets until one of
+// them fails.
+func (s *Stub) Serve(pc net.PacketConn, l net.Listener) error {
+	errs := make(chan error, 2)
+	go func() { errs <- s.serveUDP(pc) }()
+	go func() { errs <- s.serveTCP(l) }()
+	return <-errs
+}
+
+func (s *Stub) serveUDP(pc net.PacketConn) error {
+	buf := make([]byte, 65535)
+	for {
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		query := make([]byte, n)
+		copy(query, buf[:n])
+		go func() {
+			pc.WriteTo(s.resolve(query, "udp"), addr)
+		}()
+	}
+}
+
+func (s *Stub) serveTCP(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			for {
+				conn.SetDeadline(time.Now().Add(10 * time.Second))
+				query, err := readTCPMessage(conn)
+				if err != nil {
+					return
+				}
+				if err := writeTCPMessage(conn, s.resolve(query, "tcp")); err != nil {
+					return
+				}
+			}
+		}()
+	}
+}
+
+func readTCPMessage(r io.Reader) ([]byte, error) {
+	var size uint16
+	if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+		return nil, err
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func writeTCPMessage(w io.Writer, msg []byte) error {
+	buf := make([]byte, 2+len(msg))
+	binary.BigEndian.PutUint16(buf, uint16(len(msg)))
+	copy(buf[2:], msg)
+	_, err := w.Write(buf)
+	return err
+}
+
+// resolve logs the query and returns the reply of the first upstream
+// resolver that answers it.
+func (s *Stub) resolve(query []byte, network string) []byte {
+	name, qtype, err := Question(query)
+	if err != nil {
+		s.Logf("snap %q: cannot forward query: %v", s.Snap, err)
+		return serverFailure(query)
+	}
+	s.Logf("snap %q: query %s %s", s.Snap, qtype, name)
+	for _, upstream := range s.Upstream {
+		reply, err := s.forward(query, network, upstream)
+		if err == nil {
+			return reply
+		}
+		s.Logf("snap %q: cannot forward query to %s: %v", s.Snap, upstream, err)
+	}
+	return serverFailure(query)
+}
+
+func (s *Stub) forward(query []byte, network, upstream string) ([]byte, error) {
+	conn, err := net.DialTimeout(network, upstream, s.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(s.Timeout))
+
+	if network == "tcp" {
+		if err := writeTCPMessage(conn, query); err != nil {
+			return nil, err
+		}
+		return readTCPMessage(conn)
+	}
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 65535)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// HostResolvers returns the addresses of the resolvers listed in the
+// given resolv.conf file.
+func HostResolvers(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var resolvers []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			resolvers = append(resolvers, net.JoinHostPort(fields[1], "53"))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(resolvers) == 0 {
+		return nil, fmt.Errorf("no nameserver in %s", path)
+	}
+	return resolvers, nil
+}
+
+var resolvectl = func(args ...string) ([]byte, error) {
+	return exec.Command("resolvectl", args...).Output()
+}
+
+// LinkResolvers returns the addresses of the resolvers systemd-resolved
+// uses for the given network link.
+func LinkResolvers(link string) ([]string, error) {
+	output, err := resolvectl("dns", link)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get resolvers of link %q: %v", link, err)
+	}
+	// Link 3 (eth1): 10.0.0.1 fe80::1
+	var resolvers []string
+	if i := strings.Index(string(output), "):"); i >= 0 {
+		for _, addr := range strings.Fields(string(output[i+2:])) {
+			resolvers = append(resolvers, net.JoinHostPort(addr, "53"))
+		}
+	}
+	if len(resolvers) == 0 {
+		return nil, fmt.Errorf("link %q has no resolvers", link)
+	}
+	return resolvers, nil
+}
@@ -0,0 +1,199 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2014-2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package dnsstub_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dnsstub"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type dnsstubSuite struct{}
+
+var _ = Suite(&dnsstubSuite{})
+
+// query for "www.example.com." of type AAAA
+var query = []byte{
+	0x12, 0x34, 0x01, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	3, 'w', 'w', 'w', 7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 3, 'c', 'o', 'm', 0,
+	0x00, 0x1c, 0x00, 0x01,
+}
+
+func (s *dnsstubSuite) TestQuestion(c *C) {
+	name, qtype, err := dnsstub.Question(query)
+	c.Assert(err, IsNil)
+	c.Check(name, Equals, "www.example.com.")
+	c.Check(qtype, Equals, "AAAA")
+
+	_, _, err = dnsstub.Question(query[:20])
+	c.Check(err, ErrorMatches, "malformed DNS message")
+	_, _, err = dnsstub.Question(query[:8])
+	c.Check(err, ErrorMatches, "malformed DNS message")
+}
+
+func (s *dnsstubSuite) TestListenAddress(c *C) {
+	addr := dnsstub.ListenAddress("foo")
+	c.Check(strings.HasPrefix(addr, "127.53."), Equals, true)
+	c.Check(net.ParseIP(addr), NotNil)
+	c.Check(dnsstub.ListenAddress("foo"), Equals, addr)
+	c.Check(dnsstub.ListenAddress("bar"), Not(Equals), addr)
+}
+
+type logBuffer struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *logBuffer) Logf(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+// upstream answers queries with their own copy, flagged as a response
+func upstream(c *C) string {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	go func() {
+		defer pc.Close()
+		buf := make([]byte, 512)
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		buf[2] |= 0x80
+		pc.WriteTo(buf[:n], addr)
+	}()
+	return pc.LocalAddr().String()
+}
+
+func (s *dnsstubSuite) exchange(c *C, stub *dnsstub.Stub) []byte {
+	pc, l, err := dnsstub.Listen("127.0.0.1:0")
+	c.Assert(err, IsNil)
+	defer l.Close()
+	defer pc.Close()
+	go stub.Serve(pc, l)
+
+	conn, err := net.Dial("udp", pc.LocalAddr().String())
+	c.Assert(err, IsNil)
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	_, err = conn.Write(query)
+	c.Assert(err, IsNil)
+	buf := make([]byte, 512)
+	n, err := conn.Read(buf)
+	c.Assert(err, IsNil)
+	return buf[:n]
+}
+
+func (s *dnsstubSuite) TestServeForwardsAndLogs(c *C) {
+	log := &logBuffer{}
+	stub := &dnsstub.Stub{
+		Snap:     "foo",
+		Upstream: []string{upstream(c)},
+		Timeout:  time.Second,
+		Logf:     log.Logf,
+	}
+	reply := s.exchange(c, stub)
+	c.Check(reply[2]&0x80, Equals, byte(0x80))
+	c.Check(reply[3]&0x0f, Equals, byte(0))
+	c.Check(reply[12:], DeepEquals, query[12:])
+	c.Check(log.lines, DeepEquals, []string{`snap "foo": query AAAA www.example.com.`})
+}
+
+func (s *dnsstubSuite) TestServeFailsOver(c *C) {
+	// nothing answers on the first upstream
+	dead, err := net.ListenPacket("udp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	defer dead.Close()
+
+	log := &logBuffer{}
+	stub := &dnsstub.Stub{
+		Snap:     "foo",
+		Upstream: []string{dead.LocalAddr().String(), upstream(c)},
+		Timeout:  100 * time.Millisecond,
+		Logf:     log.Logf,
+	}
+	reply := s.exchange(c, stub)
+	c.Check(reply[3]&0x0f, Equals, byte(0))
+	c.Check(log.lines, HasLen, 2)
+	c.Check(log.lines[1], Matches, `snap "foo": cannot forward query to .*`)
+}
+
+func (s *dnsstubSuite) TestServeServerFailure(c *C) {
+	stub := &dnsstub.Stub{
+		Snap:    "foo",
+		Timeout: time.Second,
+		Logf:    (&logBuffer{}).Logf,
+	}
+	reply := s.exchange(c, stub)
+	c.Check(reply[2]&0x80, Equals, byte(0x80))
+	c.Check(reply[3]&0x0f, Equals, byte(2))
+}
+
+func (s *dnsstubSuite) TestHostResolvers(c *C) {
+	path := filepath.Join(c.MkDir(), "resolv.conf")
+	err := ioutil.WriteFile(path, []byte("# comment\nnameserver 127.0.0.53\nnameserver ::1\nsearch lan\n"), 0644)
+	c.Assert(err, IsNil)
+
+	resolvers, err := dnsstub.HostResolvers(path)
+	c.Assert(err, IsNil)
+	c.Check(resolvers, DeepEquals, []string{"127.0.0.53:53", "[::1]:53"})
+
+	err = ioutil.WriteFile(path, []byte("search lan\n"), 0644)
+	c.Assert(err, IsNil)
+	_, err = dnsstub.HostResolvers(path)
+	c.Check(err, ErrorMatches, "no nameserver in .*")
+}
+
+func (s *dnsstubSuite) TestLinkResolvers(c *C) {
+	var calls [][]string
+	restore := dnsstub.MockResolvectl(func(args ...string) ([]byte, error) {
+		calls = append(calls, args)
+		return []byte("Link 3 (eth1): 10.0.0.1 fe80::1\n"), nil
+	})
+	defer restore()
+
+	resolvers, err := dnsstub.LinkResolvers("eth1")
+	c.Assert(err, IsNil)
+	c.Check(resolvers, DeepEquals, []string{"10.0.0.1:53", "[fe80::1]:53"})
+	c.Check(calls, DeepEquals, [][]string{{"dns", "eth1"}})
+}
+
+func (s *dnsstubSuite) TestLinkResolversNone(c *C) {
+	restore := dnsstub.MockResolvectl(func(args ...string) ([]byte, error) {
+		return []byte("Link 3 (eth1):\n"), nil
+	})
+	defer restore()
+
+	_, err := dnsstub.LinkResolvers("eth1")
+	c.Check(err, ErrorMatches, `link "eth1" has no resolvers`)
+}
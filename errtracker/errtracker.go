@@ -0,0 +1,236 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2014-2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package errtracker keeps the reports of the failures of snapd and
+// submits them, once the user opted in, to an error tracking service.
+// Reports are scrubbed of what could identify the user or the device
+// before they are kept, and are queued until they can be submitted.
+package errtracker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/snapcore/snapd/arch"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/release"
+)
+
+// maxReports is how many reports are kept, sent or not; the oldest
+// ones are dropped first.
+const maxReports = 50
+
+var timeNow = time.Now
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// Report describes a failure of snapd.
+type Report struct {
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+	// Code is the classification of the failure.
+	Code     string `json:"error-code"`
+	Snap     string `json:"snap,omitempty"`
+	Revision string `json:"revision,omitempty"`
+
+	Distro        string `json:"distro"`
+	DistroRelease string `json:"distro-release,omitempty"`
+	Architecture  string `json:"architecture"`
+	// Log is the log of the failed task, scrubbed.
+	Log []string `json:"log,omitempty"`
+
+	// Sent is when the report was submitted, zero while it is queued.
+	Sent time.Time `json:"sent,omitempty"`
+}
+
+var scrubbers = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	// credentials given in URLs, then their query strings
+	{regexp.MustCompile(`([a-z]+://)[^/\s@"]+@`), "${1}<user>@"},
+	{regexp.MustCompile(`([a-z]+://[^\s?"]*)\?[^\s"]*?(:?(?:\s|"|$))`), "${1}?<query>${2}"},
+	{regexp.MustCompile(`(?i)\b(authorization|macaroon|discharge|password|token)(["']?\s*[:=]\s*["']?)[^\s"',]+`), "${1}${2}<secret>"},
+	{regexp.MustCompile(`(?i)\b(serial(?:[-_ ]?number)?)(["']?\s*[:=]\s*["']?)[^\s"',]+`), "${1}${2}<serial>"},
+	{regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`), "<email>"},
+	{regexp.MustCompile(`/home/[^/\s"']+`), "/home/<user>"},
+	{regexp.MustCompile(`\b[0-9]{1,3}(\.[0-9]{1,3}){3}\b`), "<ip>"},
+	{regexp.MustCompile(`(?i)\b([0-9a-f]{2}:){5}[0-9a-f]{2}\b`), "<mac>"},
+	// IPv6 addresses, in full or with "::" for the zeros
+	{regexp.MustCompile(`(?i)(^|[^0-9a-z:])((?:[0-9a-f]{1,4}:){7}[0-9a-f]{1,4}|(?:[0-9a-f]{1,4}:){1,7}:(?:[0-9a-f]{1,4}(?::[0-9a-f]{1,4}){0,6})?|::[0-9a-f]{1,4}(?::[0-9a-f]{1,4}){0,6})([^0-9a-z:]|$)`), "${1}<ip>${3}"},
+}
+
+var osHostname = os.Hostname
+
+// Scrub removes from the given line what could identify the user or the
+// device: credentials, serial numbers, email addresses, home
+// directories, IP and MAC addresses, and the hostname of the device.
+func Scrub(line string) string {
+	for _, s := range scrubbers {
+		line = s.re.ReplaceAllString(line, s.repl)
+	}
+	if host, err := osHostname(); err == nil && host != "" && host != "localhost" {
+		hostRe := regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(host) + `\b`)
+		line = hostRe.ReplaceAllString(line, "<hostname>")
+	}
+	return line
+}
+
+func reportPath(id string) string {
+	return filepath.Join(dirs.SnapErrorReportsDir, id+".json")
+}
+
+func saveReport(r *Report) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return osutil.AtomicWriteFile(reportPath(r.ID), data, 0600, 0)
+}
+
+// Add scrubs the given report, completes it with the details of the
+// system and queues it for submission, dropping the oldest reports past
+// the ones kept.
+func Add(r *Report) error {
+	if r.Time.IsZero() {
+		r.Time = timeNow()
+	}
+	r.Distro = release.ReleaseInfo.ID
+	r.DistroRelease = release.ReleaseInfo.Release
+	r.Architecture = arch.UbuntuArchitecture()
+	r.ID = fmt.Sprintf("%d", r.Time.UnixNano())
+	log := make([]string, len(r.Log))
+	for i, line := range r.Log {
+		log[i] = Scrub(line)
+	}
+	r.Log = log
+
+	if err := os.MkdirAll(dirs.SnapErrorReportsDir, 0700); err != nil {
+		return fmt.Errorf("cannot queue error report: %v", err)
+	}
+	if err := saveReport(r); err != nil {
+		return fmt.Errorf("cannot queue error report: %v", err)
+	}
+
+	reports, err := Reports()
+	if err != nil {
+		return err
+	}
+	for len(reports) > maxReports {
+		if err := os.Remove(reportPath(reports[0].ID)); err != nil {
+			return fmt.Errorf("cannot drop error report: %v", err)
+		}
+		reports = reports[1:]
+	}
+	return nil
+}
+
+type byTime []*Report
+
+func (rs byTime) Len() int           { return len(rs) }
+func (rs byTime) Less(i, j int) bool { return rs[i].Time.Before(rs[j].Time) }
+func (rs byTime) Swap(i, j int)      { rs[i], rs[j] = rs[j], rs[i] }
+
+// Reports returns the kept reports, sent or not, oldest first.
+func Reports() ([]*Report, error) {
+	matches, err := filepath.Glob(filepath.Join(dirs.SnapErrorReportsDir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	reports := make([]*Report, 0, len(matches))
+	for _, path := range matches {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read error report: %v", err)
+		}
+		var r Report
+		if err := json.Unmarshal(data, &r); err != nil {
+			return nil, fmt.Errorf("cannot read error report %q: %v", filepath.Base(path), err)
+		}
+		reports = append(reports, &r)
+	}
+	sort.Sort(byTime(reports))
+	return reports, nil
+}
+
+// Queued returns whether there are reports waiting to be submitted.
+func Queued() (bool, error) {
+	reports, err := Reports()
+	if err != nil {
+		return false, err
+	}
+	for _, r := range reports {
+		if r.Sent.IsZero() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func submit(url string, r *Report) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Flush submits the queued reports to the error tracking service at the
+// given URL, oldest first. It stops at the first report that cannot be
+// submitted, e.g. while offline, leaving it and the later ones queued.
+func Flush(url string) error {
+	if !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "http://") {
+		return fmt.Errorf("cannot submit error reports: invalid URL %q", url)
+	}
+	reports, err := Reports()
+	if err != nil {
+		return err
+	}
+	for _, r := range reports {
+		if !r.Sent.IsZero() {
+			continue
+		}
+		if err := submit(url, r); err != nil {
+			return fmt.Errorf("cannot submit error report: %v", err)
+		}
+		r.Sent = timeNow()
+		if err := saveReport(r); err != nil {
+			return fmt.Errorf("cannot mark error report as sent: %v", err)
+		}
+	}
+	return nil
+}
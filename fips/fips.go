@@ -0,0 +1,221 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package fips routes the digest and signature operations of snapd
+// through a crypto provider, restricting them to the FIPS approved
+// algorithms when running in approved mode.
+package fips
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	_ "crypto/sha256" // register SHA224 and SHA256
+	_ "crypto/sha512" // register SHA384 and SHA512
+	"fmt"
+	"hash"
+	"io/ioutil"
+
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+// Provider implements the digest and signature operations.
+type Provider interface {
+	// Name identifies the provider, e.g. in system-info.
+	Name() string
+	// NewHash returns a new hash computing the given digest.
+	NewHash(h crypto.Hash) (hash.Hash, error)
+	// Sign signs the content written to signed, filling in sig.
+	Sign(privKey *packet.PrivateKey, signed hash.Hash, sig *packet.Signature) error
+	// VerifySignature checks sig over the content written to signed.
+	VerifySignature(pubKey *packet.PublicKey, signed hash.Hash, sig *packet.Signature) error
+}
+
+// goProvider is the provider backed by the Go crypto packages.
+type goProvider struct{}
+
+func (goProvider) Name() string {
+	return "go"
+}
+
+func (goProvider) NewHash(h crypto.Hash) (hash.Hash, error) {
+	if !h.Available() {
+		return nil, fmt.Errorf("unsupported hash %d", h)
+	}
+	return h.New(), nil
+}
+
+func (goProvider) Sign(privKey *packet.PrivateKey, signed hash.Hash, sig *packet.Signature) error {
+	return sig.Sign(signed, privKey, &packet.Config{DefaultHash: sig.Hash})
+}
+
+func (goProvider) VerifySignature(pubKey *packet.PublicKey, signed hash.Hash, sig *packet.Signature) error {
+	return pubKey.VerifySignature(signed, sig)
+}
+
+// the FIPS 180-4 digests, as available from the Go crypto packages
+var approvedHashes = map[crypto.Hash]string{
+	crypto.SHA224: "SHA224",
+	crypto.SHA256: "SHA256",
+	crypto.SHA384: "SHA384",
+	crypto.SHA512: "SHA512",
+}
+
+// minRSABits is the smallest RSA modulus allowed by FIPS 186-4 for
+// signatures.
+const minRSABits = 2048
+
+// approvedProvider restricts a provider to the approved algorithms.
+type approvedProvider struct {
+	Provider
+}
+
+func (p approvedProvider) NewHash(h crypto.Hash) (hash.Hash, error) {
+	if _, ok := approvedHashes[h]; !ok {
+		return nil, fmt.Errorf("hash %d is not approved in FIPS mode", h)
+	}
+	return p.Provider.NewHash(h)
+}
+
+func checkApprovedKey(algo packet.PublicKeyAlgorithm, pubKey interface{}) error {
+	if algo != packet.PubKeyAlgoRSA && algo != packet.PubKeyAlgoRSASignOnly {
+		return fmt.Errorf("public key algorithm %d is not approved in FIPS mode", algo)
+	}
+	rsaPubKey, ok := pubKey.(*rsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("public key algorithm %d is not approved in FIPS mode", algo)
+	}
+	if bits := rsaPubKey.N.BitLen(); bits < minRSABits {
+		return fmt.Errorf("%d bits RSA key is not approved in FIPS mode, need at least %d", bits, minRSABits)
+	}
+	return nil
+}
+
+func (p approvedProvider) Sign(privKey *packet.PrivateKey, signed hash.Hash, sig *packet.Signature) error {
+	if _, ok := approvedHashes[sig.Hash]; !ok {
+		return fmt.Errorf("hash %d is not approved in FIPS mode", sig.Hash)
+	}
+	if err := checkApprovedKey(privKey.PubKeyAlgo, privKey.PublicKey.PublicKey); err != nil {
+		return err
+	}
+	return p.Provider.Sign(privKey, signed, sig)
+}
+
+func (p approvedProvider) VerifySignature(pubKey *packet.PublicKey, signed hash.Hash, sig *packet.Signature) error {
+	if _, ok := approvedHashes[sig.Hash]; !ok {
+		return fmt.Errorf("hash %d is not approved in FIPS mode", sig.Hash)
+	}
+	if err := checkApprovedKey(pubKey.PubKeyAlgo, pubKey.PublicKey); err != nil {
+		return err
+	}
+	return p.Provider.VerifySignature(pubKey, signed, sig)
+}
+
+var (
+	provider Provider = goProvider{}
+	enabled           = builtWithFIPS || kernelFIPSEnabled("/proc/sys/crypto/fips_enabled")
+)
+
+// kernelFIPSEnabled returns whether the kernel runs in FIPS mode, as
+// it does on systems certified for it.
+func kernelFIPSEnabled(path string) bool {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(bytes.TrimSpace(data), []byte("1"))
+}
+
+// Enabled returns whether snapd runs in FIPS approved mode, either
+// because it was built with the fips tag or because the kernel runs in
+// FIPS mode.
+func Enabled() bool {
+	return enabled
+}
+
+// SetProvider sets the provider of the digest and signature
+// operations, e.g. a FIPS validated module. A nil provider goes back to
+// the Go crypto packages.
+func SetProvider(p Provider) {
+	if p == nil {
+		p = goProvider{}
+	}
+	provider = p
+}
+
+// ProviderName returns the name of the provider in use.
+func ProviderName() string {
+	return provider.Name()
+}
+
+// Current returns the provider to use for digest and signature
+// operations, restricted to the approved algorithms in FIPS mode.
+func Current() Provider {
+	if enabled {
+		return approvedProvider{provider}
+	}
+	return provider
+}
+
+// NewHash returns a new hash computing the given digest through the
+// current provider.
+func NewHash(h crypto.Hash) (hash.Hash, error) {
+	return Current().NewHash(h)
+}
+
+func mustHash(h crypto.Hash) hash.Hash {
+	hsh, err := NewHash(h)
+	if err != nil {
+		// approved digests are always available
+		panic(fmt.Sprintf("internal error: %v", err))
+	}
+	return hsh
+}
+
+// SHA256 returns a new hash computing the SHA256 digest.
+func SHA256() hash.Hash {
+	return mustHash(crypto.SHA256)
+}
+
+// SHA512 returns a new hash computing the SHA512 digest.
+func SHA512() hash.Hash {
+	return mustHash(crypto.SHA512)
+}
+
+// Sum256 returns the SHA256 digest of data.
+func Sum256(data []byte) []byte {
+	h := SHA256()
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// Sum512 returns the SHA512 digest of data.
+func Sum512(data []byte) []byte {
+	h := SHA512()
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// MockEnabled forces FIPS approved mode on or off for testing
+// purposes.
+func MockEnabled(fipsEnabled bool) (restore func()) {
+	old := enabled
+	enabled = fipsEnabled
+	return func() { enabled = old }
+}
@@ -0,0 +1,185 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package fips_test
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/openpgp/packet"
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/fips"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type fipsSuite struct{}
+
+var _ = Suite(&fipsSuite{})
+
+func (s *fipsSuite) TestSums(c *C) {
+	sum256 := sha256.Sum256([]byte("snap"))
+	c.Check(fips.Sum256([]byte("snap")), DeepEquals, sum256[:])
+	sum512 := sha512.Sum512([]byte("snap"))
+	c.Check(fips.Sum512([]byte("snap")), DeepEquals, sum512[:])
+}
+
+func (s *fipsSuite) TestNewHash(c *C) {
+	restore := fips.MockEnabled(false)
+	defer restore()
+
+	h, err := fips.NewHash(crypto.SHA1)
+	c.Assert(err, IsNil)
+	c.Check(h.Size(), Equals, 20)
+
+	fips.MockEnabled(true)
+	_, err = fips.NewHash(crypto.SHA1)
+	c.Check(err, ErrorMatches, "hash 3 is not approved in FIPS mode")
+	h, err = fips.NewHash(crypto.SHA384)
+	c.Assert(err, IsNil)
+	c.Check(h.Size(), Equals, 48)
+}
+
+func signAndVerify(privKey *packet.PrivateKey, content []byte) error {
+	sig := &packet.Signature{
+		SigType:      packet.SigTypeBinary,
+		PubKeyAlgo:   privKey.PubKeyAlgo,
+		Hash:         crypto.SHA512,
+		CreationTime: time.Now(),
+		IssuerKeyId:  &privKey.KeyId,
+	}
+	h, err := fips.NewHash(sig.Hash)
+	if err != nil {
+		return err
+	}
+	h.Write(content)
+	if err := fips.Current().Sign(privKey, h, sig); err != nil {
+		return err
+	}
+
+	h, err = fips.NewHash(sig.Hash)
+	if err != nil {
+		return err
+	}
+	h.Write(content)
+	return fips.Current().VerifySignature(&privKey.PublicKey, h, sig)
+}
+
+func genKey(c *C, bits int) *packet.PrivateKey {
+	rsaPrivKey, err := rsa.GenerateKey(rand.Reader, bits)
+	c.Assert(err, IsNil)
+	return packet.NewRSAPrivateKey(time.Now(), rsaPrivKey)
+}
+
+func (s *fipsSuite) TestSignAndVerify(c *C) {
+	restore := fips.MockEnabled(false)
+	defer restore()
+
+	privKey := genKey(c, 752)
+	c.Check(signAndVerify(privKey, []byte("content")), IsNil)
+
+	// small keys are not approved
+	fips.MockEnabled(true)
+	c.Check(signAndVerify(privKey, []byte("content")), ErrorMatches, "752 bits RSA key is not approved in FIPS mode, need at least 2048")
+
+	privKey = genKey(c, 2048)
+	c.Check(signAndVerify(privKey, []byte("content")), IsNil)
+}
+
+func (s *fipsSuite) TestVerifyTampered(c *C) {
+	restore := fips.MockEnabled(true)
+	defer restore()
+
+	privKey := genKey(c, 2048)
+	sig := &packet.Signature{
+		SigType:      packet.SigTypeBinary,
+		PubKeyAlgo:   privKey.PubKeyAlgo,
+		Hash:         crypto.SHA256,
+		CreationTime: time.Now(),
+		IssuerKeyId:  &privKey.KeyId,
+	}
+	h := fips.SHA256()
+	h.Write([]byte("content"))
+	c.Assert(fips.Current().Sign(privKey, h, sig), IsNil)
+
+	h = fips.SHA256()
+	h.Write([]byte("tampered"))
+	c.Check(fips.Current().VerifySignature(&privKey.PublicKey, h, sig), NotNil)
+}
+
+type fakeProvider struct {
+	hashes []crypto.Hash
+}
+
+func (p *fakeProvider) Name() string {
+	return "fake"
+}
+
+func (p *fakeProvider) NewHash(h crypto.Hash) (hash.Hash, error) {
+	p.hashes = append(p.hashes, h)
+	return h.New(), nil
+}
+
+func (p *fakeProvider) Sign(privKey *packet.PrivateKey, signed hash.Hash, sig *packet.Signature) error {
+	return nil
+}
+
+func (p *fakeProvider) VerifySignature(pubKey *packet.PublicKey, signed hash.Hash, sig *packet.Signature) error {
+	return nil
+}
+
+func (s *fipsSuite) TestSetProvider(c *C) {
+	restore := fips.MockEnabled(true)
+	defer restore()
+	c.Check(fips.ProviderName(), Equals, "go")
+
+	p := &fakeProvider{}
+	fips.SetProvider(p)
+	defer fips.SetProvider(nil)
+	c.Check(fips.ProviderName(), Equals, "fake")
+
+	fips.Sum512([]byte("snap"))
+	c.Check(p.hashes, DeepEquals, []crypto.Hash{crypto.SHA512})
+
+	// still restricted to the approved algorithms
+	_, err := fips.NewHash(crypto.SHA1)
+	c.Check(err, NotNil)
+	c.Check(p.hashes, HasLen, 1)
+}
+
+func (s *fipsSuite) TestKernelFIPSEnabled(c *C) {
+	path := filepath.Join(c.MkDir(), "fips_enabled")
+	c.Check(fips.KernelFIPSEnabled(path), Equals, false)
+
+	c.Assert(ioutil.WriteFile(path, []byte("0\n"), 0644), IsNil)
+	c.Check(fips.KernelFIPSEnabled(path), Equals, false)
+
+	c.Assert(ioutil.WriteFile(path, []byte("1\n"), 0644), IsNil)
+	c.Check(fips.KernelFIPSEnabled(path), Equals, true)
+}
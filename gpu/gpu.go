@@ -0,0 +1,191 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package gpu makes the GPU userspace libraries of the host available to
+// snaps.
+//
+// The libraries are linked from the host into dirs.SnapLibGLDir (and
+// dirs.SnapLibGL32Dir for the 32-bit ones), which the launcher makes
+// visible in the snap namespace and snaps find through
+// SNAP_LIBRARY_PATH. The NVIDIA proprietary libraries are only linked
+// if they match the version of the loaded kernel module.
+package gpu
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/snapcore/snapd/arch"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+)
+
+// multiarch maps the architecture to its native and, if any, 32-bit
+// multiarch library directories.
+var multiarch = map[string][2]string{
+	"amd64":   {"x86_64-linux-gnu", "i386-linux-gnu"},
+	"arm64":   {"aarch64-linux-gnu", "arm-linux-gnueabihf"},
+	"armhf":   {"arm-linux-gnueabihf", ""},
+	"i386":    {"i386-linux-gnu", ""},
+	"ppc64el": {"powerpc64le-linux-gnu", ""},
+}
+
+// the NVIDIA libraries found in the multiarch directories
+var nvidiaLibs = regexp.MustCompile(`^(libnvidia-.*|lib(GLX|EGL|GLESv1_CM|GLESv2)_nvidia|libcuda|libnvcuvid)\.so`)
+
+// a library with a full version suffix, like libnvidia-glcore.so.367.57
+var versionedLib = regexp.MustCompile(`\.so\.([0-9]+\.[0-9]+(\.[0-9]+)?)$`)
+
+// NvidiaVersion returns the version of the loaded NVIDIA kernel module,
+// or "" if there is none.
+func NvidiaVersion() (string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dirs.GlobalRootDir, "/sys/module/nvidia/version"))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// hostLibs returns the paths of the GPU libraries of the host in the
+// given multiarch directory, and the NVIDIA ones in the given
+// driver directories, that go with the kernel module version.
+func hostLibs(triplet string, nvidiaDirs []string, version string) ([]string, error) {
+	var libs []string
+	if version == "" {
+		return nil, nil
+	}
+
+	candidates, err := filepath.Glob(filepath.Join(dirs.GlobalRootDir, "/usr/lib", triplet, "*.so*"))
+	if err != nil {
+		return nil, err
+	}
+	for _, lib := range candidates {
+		if nvidiaLibs.MatchString(filepath.Base(lib)) {
+			libs = append(libs, lib)
+		}
+	}
+	for _, dir := range nvidiaDirs {
+		candidates, err := filepath.Glob(filepath.Join(dir, "*.so*"))
+		if err != nil {
+			return nil, err
+		}
+		libs = append(libs, candidates...)
+	}
+
+	matching := libs[:0]
+	for _, lib := range libs {
+		// a library for another version of the driver would not work
+		// with the loaded module
+		if m := versionedLib.FindStringSubmatch(lib); m != nil && m[1] != version {
+			continue
+		}
+		matching = append(matching, lib)
+	}
+	return matching, nil
+}
+
+// nvidiaDirs returns the directories where the NVIDIA driver of the
+// given version keeps its libraries, as packaged by Ubuntu.
+func nvidiaDirs(lib, version string) []string {
+	major := strings.SplitN(version, ".", 2)[0]
+	dir := filepath.Join(dirs.GlobalRootDir, lib, "nvidia-"+major)
+	if _, err := os.Stat(dir); err != nil {
+		return nil
+	}
+	return []string{dir}
+}
+
+// Setup links the GPU libraries of the host into the directories snaps
+// get them from, replacing what was linked there before.
+func Setup() error {
+	version, err := NvidiaVersion()
+	if err != nil {
+		return fmt.Errorf("cannot determine NVIDIA driver version: %v", err)
+	}
+
+	triplets := multiarch[arch.UbuntuArchitecture()]
+
+	libs, err := hostLibs(triplets[0], nvidiaDirs("/usr/lib", version), version)
+	if err != nil {
+		return err
+	}
+	if err := linkLibs(dirs.SnapLibGLDir, libs, filepath.Join(dirs.GlobalRootDir, "/usr/lib", triplets[0], "dri")); err != nil {
+		return err
+	}
+
+	if triplets[1] == "" {
+		return nil
+	}
+	libs, err = hostLibs(triplets[1], nvidiaDirs("/usr/lib32", version), version)
+	if err != nil {
+		return err
+	}
+	return linkLibs(dirs.SnapLibGL32Dir, libs, filepath.Join(dirs.GlobalRootDir, "/usr/lib", triplets[1], "dri"))
+}
+
+// linkLibs makes dir hold symlinks to the given libraries, and to the
+// Mesa DRI drivers directory if there is one.
+func linkLibs(dir string, libs []string, driDir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	want := make(map[string]string, len(libs)+1)
+	for _, lib := range libs {
+		want[filepath.Base(lib)] = lib
+	}
+	if fi, err := os.Stat(driDir); err == nil && fi.IsDir() {
+		want["dri"] = driDir
+	}
+
+	// drop what is stale
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, fi := range fis {
+		name := fi.Name()
+		path := filepath.Join(dir, name)
+		if fi.Mode()&os.ModeSymlink == 0 {
+			logger.Noticef("ignoring unexpected %q in %s", name, dir)
+			continue
+		}
+		if target, err := os.Readlink(path); err == nil && target == want[name] {
+			delete(want, name)
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+
+	for name, target := range want {
+		if err := os.Symlink(target, filepath.Join(dir, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package gpu_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/arch"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/gpu"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type gpuSuite struct {
+	root    string
+	oldArch string
+}
+
+var _ = Suite(&gpuSuite{})
+
+func (s *gpuSuite) SetUpTest(c *C) {
+	s.root = c.MkDir()
+	dirs.SetRootDir(s.root)
+	s.oldArch = arch.UbuntuArchitecture()
+	arch.SetArchitecture("amd64")
+}
+
+func (s *gpuSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("")
+	arch.SetArchitecture(arch.ArchitectureType(s.oldArch))
+}
+
+func (s *gpuSuite) mockFiles(c *C, paths ...string) {
+	for _, p := range paths {
+		p = filepath.Join(s.root, p)
+		c.Assert(os.MkdirAll(filepath.Dir(p), 0755), IsNil)
+		c.Assert(ioutil.WriteFile(p, nil, 0644), IsNil)
+	}
+}
+
+func (s *gpuSuite) mockNvidia(c *C, version string) {
+	s.mockFiles(c, "/sys/module/nvidia/version")
+	c.Assert(ioutil.WriteFile(filepath.Join(s.root, "/sys/module/nvidia/version"), []byte(version+"\n"), 0644), IsNil)
+}
+
+func (s *gpuSuite) links(c *C, dir string) map[string]string {
+	fis, err := ioutil.ReadDir(dir)
+	c.Assert(err, IsNil)
+	links := make(map[string]string)
+	for _, fi := range fis {
+		target, err := os.Readlink(filepath.Join(dir, fi.Name()))
+		c.Assert(err, IsNil)
+		links[fi.Name()] = target
+	}
+	return links
+}
+
+func (s *gpuSuite) TestNvidiaVersion(c *C) {
+	version, err := gpu.NvidiaVersion()
+	c.Assert(err, IsNil)
+	c.Check(version, Equals, "")
+
+	s.mockNvidia(c, "367.57")
+	version, err = gpu.NvidiaVersion()
+	c.Assert(err, IsNil)
+	c.Check(version, Equals, "367.57")
+}
+
+func (s *gpuSuite) TestSetupNvidia(c *C) {
+	s.mockNvidia(c, "367.57")
+	s.mockFiles(c,
+		"/usr/lib/x86_64-linux-gnu/libnvidia-glcore.so.367.57",
+		"/usr/lib/x86_64-linux-gnu/libnvidia-glcore.so.361.42",
+		"/usr/lib/x86_64-linux-gnu/libGLX_nvidia.so.0",
+		"/usr/lib/x86_64-linux-gnu/libz.so.1",
+		"/usr/lib/x86_64-linux-gnu/dri/i965_dri.so",
+		"/usr/lib/nvidia-367/libGL.so.1",
+		"/usr/lib/nvidia-367/libGL.so.367.57",
+		"/usr/lib/nvidia-361/libGL.so.361.42",
+		"/usr/lib32/nvidia-367/libGL.so.1",
+		"/usr/lib/i386-linux-gnu/libnvidia-glcore.so.367.57",
+	)
+
+	c.Assert(gpu.Setup(), IsNil)
+
+	lib := filepath.Join(s.root, "/usr/lib/x86_64-linux-gnu")
+	nv := filepath.Join(s.root, "/usr/lib/nvidia-367")
+	c.Check(s.links(c, dirs.SnapLibGLDir), DeepEquals, map[string]string{
+		"libnvidia-glcore.so.367.57": filepath.Join(lib, "libnvidia-glcore.so.367.57"),
+		"libGLX_nvidia.so.0":         filepath.Join(lib, "libGLX_nvidia.so.0"),
+		"libGL.so.1":                 filepath.Join(nv, "libGL.so.1"),
+		"libGL.so.367.57":            filepath.Join(nv, "libGL.so.367.57"),
+		"dri":                        filepath.Join(lib, "dri"),
+	})
+	c.Check(s.links(c, dirs.SnapLibGL32Dir), DeepEquals, map[string]string{
+		"libnvidia-glcore.so.367.57": filepath.Join(s.root, "/usr/lib/i386-linux-gnu/libnvidia-glcore.so.367.57"),
+		"libGL.so.1":                 filepath.Join(s.root, "/usr/lib32/nvidia-367/libGL.so.1"),
+	})
+}
+
+func (s *gpuSuite) TestSetupMesaOnly(c *C) {
+	s.mockFiles(c,
+		"/usr/lib/x86_64-linux-gnu/libnvidia-glcore.so.367.57",
+		"/usr/lib/x86_64-linux-gnu/dri/i965_dri.so",
+	)
+
+	c.Assert(gpu.Setup(), IsNil)
+
+	// without the kernel module the NVIDIA libraries are of no use
+	c.Check(s.links(c, dirs.SnapLibGLDir), DeepEquals, map[string]string{
+		"dri": filepath.Join(s.root, "/usr/lib/x86_64-linux-gnu/dri"),
+	})
+	c.Check(s.links(c, dirs.SnapLibGL32Dir), HasLen, 0)
+}
+
+func (s *gpuSuite) TestSetupReplacesStaleLinks(c *C) {
+	s.mockNvidia(c, "361.42")
+	s.mockFiles(c,
+		"/usr/lib/x86_64-linux-gnu/libnvidia-glcore.so.361.42",
+		"/usr/lib/x86_64-linux-gnu/libnvidia-glcore.so.367.57",
+	)
+	c.Assert(gpu.Setup(), IsNil)
+
+	// the driver got upgraded
+	s.mockNvidia(c, "367.57")
+	c.Assert(gpu.Setup(), IsNil)
+
+	var names []string
+	for name := range s.links(c, dirs.SnapLibGLDir) {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	c.Check(names, DeepEquals, []string{"libnvidia-glcore.so.367.57"})
+}
@@ -0,0 +1,197 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package i18n
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// LocaleDir is where the catalogs of the locales are looked up, as
+// <locale>/LC_MESSAGES/<TEXTDOMAIN>.mo.
+var LocaleDir = "/usr/share/locale"
+
+// Catalog holds the translations of the messages to one language, as
+// read from a gettext .mo file. Unlike G and NG it does not depend on
+// the locale of the process, so it can translate to the locale of the
+// user a request comes from.
+type Catalog struct {
+	messages map[string]string
+}
+
+const (
+	moMagicLittleEndian = 0x950412de
+	moMagicBigEndian    = 0xde120495
+)
+
+// ReadCatalog reads the catalog in the gettext .mo file at path.
+func ReadCatalog(path string) (*Catalog, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseCatalog(data)
+}
+
+func parseCatalog(data []byte) (*Catalog, error) {
+	if len(data) < 20 {
+		return nil, fmt.Errorf("cannot read message catalog: too short")
+	}
+	var order binary.ByteOrder
+	switch binary.LittleEndian.Uint32(data) {
+	case moMagicLittleEndian:
+		order = binary.LittleEndian
+	case moMagicBigEndian:
+		order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("cannot read message catalog: bad magic number")
+	}
+
+	n := order.Uint32(data[8:])
+	origTable := order.Uint32(data[12:])
+	transTable := order.Uint32(data[16:])
+
+	str := func(table, i uint32) (string, error) {
+		entry := uint64(table) + uint64(i)*8
+		if entry+8 > uint64(len(data)) {
+			return "", fmt.Errorf("cannot read message catalog: string table out of bounds")
+		}
+		length := uint64(order.Uint32(data[entry:]))
+		offset := uint64(order.Uint32(data[entry+4:]))
+		if offset+length > uint64(len(data)) {
+			return "", fmt.Errorf("cannot read message catalog: string out of bounds")
+		}
+		return string(data[offset : offset+length]), nil
+	}
+
+	messages := make(map[string]string, n)
+	for i := uint32(0); i < n; i++ {
+		msgid, err := str(origTable, i)
+		if err != nil {
+			return nil, err
+		}
+		msgstr, err := str(transTable, i)
+		if err != nil {
+			return nil, err
+		}
+		if msgid == "" {
+			// the header
+			continue
+		}
+		// plural messages are keyed by their singular form
+		if nul := strings.IndexByte(msgid, 0); nul >= 0 {
+			msgid = msgid[:nul]
+		}
+		messages[msgid] = msgstr
+	}
+
+	return &Catalog{messages: messages}, nil
+}
+
+// G returns the translation of msgid, or msgid itself if the catalog
+// has none. A nil catalog translates nothing.
+func (c *Catalog) G(msgid string) string {
+	if c == nil {
+		return msgid
+	}
+	msgstr, ok := c.messages[msgid]
+	if !ok || msgstr == "" {
+		return msgid
+	}
+	if nul := strings.IndexByte(msgstr, 0); nul >= 0 {
+		return msgstr[:nul]
+	}
+	return msgstr
+}
+
+// NG returns the translation of msgid or msgidPlural depending on n,
+// or the untranslated one if the catalog has none. It only knows the
+// plural rule of the languages with one singular and one plural form.
+func (c *Catalog) NG(msgid, msgidPlural string, n uint64) string {
+	form := 1
+	if n == 1 {
+		form = 0
+	}
+	if c != nil {
+		if msgstr, ok := c.messages[msgid]; ok {
+			forms := strings.Split(msgstr, "\x00")
+			if form < len(forms) && forms[form] != "" {
+				return forms[form]
+			}
+		}
+	}
+	if form == 0 {
+		return msgid
+	}
+	return msgidPlural
+}
+
+// localeCandidates returns the names the catalogs for locale can be
+// found under, most specific first, e.g. de_DE and de for
+// de_DE.UTF-8. It returns nothing for the untranslated C and POSIX
+// locales.
+func localeCandidates(locale string) []string {
+	if i := strings.IndexAny(locale, ".@"); i >= 0 {
+		locale = locale[:i]
+	}
+	if locale == "" || locale == "C" || locale == "POSIX" {
+		return nil
+	}
+	candidates := []string{locale}
+	if i := strings.IndexByte(locale, '_'); i > 0 {
+		candidates = append(candidates, locale[:i])
+	}
+	return candidates
+}
+
+var (
+	catalogsMu sync.Mutex
+	catalogs   = make(map[string]*Catalog)
+)
+
+// CatalogFor returns the catalog of the messages of TEXTDOMAIN for the
+// given locale, e.g. de_DE.UTF-8, or nil if there is none. Catalogs are
+// read once and kept.
+func CatalogFor(locale string) *Catalog {
+	catalogsMu.Lock()
+	defer catalogsMu.Unlock()
+
+	for _, name := range localeCandidates(locale) {
+		path := filepath.Join(LocaleDir, name, "LC_MESSAGES", TEXTDOMAIN+".mo")
+		catalog, ok := catalogs[path]
+		if !ok {
+			var err error
+			catalog, err = ReadCatalog(path)
+			if err != nil {
+				// remember there is none
+				catalog = nil
+			}
+			catalogs[path] = catalog
+		}
+		if catalog != nil {
+			return catalog
+		}
+	}
+	return nil
+}
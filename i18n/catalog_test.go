@@ -0,0 +1,114 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package i18n
+
+import (
+	"encoding/binary"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/i18n/i18ntest"
+)
+
+type catalogTestSuite struct {
+	oldLocaleDir string
+}
+
+var _ = Suite(&catalogTestSuite{})
+
+func (s *catalogTestSuite) SetUpTest(c *C) {
+	s.oldLocaleDir = LocaleDir
+	LocaleDir = c.MkDir()
+}
+
+func (s *catalogTestSuite) TearDownTest(c *C) {
+	LocaleDir = s.oldLocaleDir
+}
+
+var testMessages = map[string]string{
+	"":                        "Content-Type: text/plain; charset=UTF-8\n",
+	"cannot find snap %q":     "Snap %q nicht gefunden",
+	"one snap\x00%d snaps":    "ein Snap\x00%d Snaps",
+	"untranslated":            "",
+	"cannot find change %q":   "Änderung %q nicht gefunden",
+	"plural only\x00plurals":  "\x00Plurale",
+	"access denied":           "Zugriff verweigert",
+	"something else entirely": "etwas ganz anderes",
+}
+
+func (s *catalogTestSuite) TestParseCatalog(c *C) {
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		catalog, err := parseCatalog(i18ntest.MakeMO(order, testMessages))
+		c.Assert(err, IsNil)
+
+		c.Check(catalog.G("cannot find snap %q"), Equals, "Snap %q nicht gefunden")
+		c.Check(catalog.G("cannot find change %q"), Equals, "Änderung %q nicht gefunden")
+		c.Check(catalog.G("untranslated"), Equals, "untranslated")
+		c.Check(catalog.G("unknown"), Equals, "unknown")
+		c.Check(catalog.G("one snap"), Equals, "ein Snap")
+
+		c.Check(catalog.NG("one snap", "%d snaps", 1), Equals, "ein Snap")
+		c.Check(catalog.NG("one snap", "%d snaps", 3), Equals, "%d Snaps")
+		c.Check(catalog.NG("plural only", "plurals", 1), Equals, "plural only")
+		c.Check(catalog.NG("plural only", "plurals", 2), Equals, "Plurale")
+		c.Check(catalog.NG("unknown", "unknowns", 2), Equals, "unknowns")
+	}
+}
+
+func (s *catalogTestSuite) TestParseCatalogErrors(c *C) {
+	_, err := parseCatalog([]byte("short"))
+	c.Check(err, ErrorMatches, "cannot read message catalog: too short")
+
+	_, err = parseCatalog(make([]byte, 28))
+	c.Check(err, ErrorMatches, "cannot read message catalog: bad magic number")
+
+	mo := i18ntest.MakeMO(binary.LittleEndian, testMessages)
+	_, err = parseCatalog(mo[:40])
+	c.Check(err, ErrorMatches, "cannot read message catalog: .* out of bounds")
+}
+
+func (s *catalogTestSuite) TestNilCatalog(c *C) {
+	var catalog *Catalog
+	c.Check(catalog.G("access denied"), Equals, "access denied")
+	c.Check(catalog.NG("one snap", "%d snaps", 2), Equals, "%d snaps")
+}
+
+func (s *catalogTestSuite) TestLocaleCandidates(c *C) {
+	c.Check(localeCandidates("de_DE.UTF-8"), DeepEquals, []string{"de_DE", "de"})
+	c.Check(localeCandidates("ca_ES@valencia"), DeepEquals, []string{"ca_ES", "ca"})
+	c.Check(localeCandidates("gl"), DeepEquals, []string{"gl"})
+	c.Check(localeCandidates("C.UTF-8"), HasLen, 0)
+	c.Check(localeCandidates("POSIX"), HasLen, 0)
+	c.Check(localeCandidates(""), HasLen, 0)
+}
+
+func (s *catalogTestSuite) TestCatalogFor(c *C) {
+	i18ntest.MockCatalog(c, LocaleDir, "de", TEXTDOMAIN, testMessages)
+
+	catalog := CatalogFor("de_AT.UTF-8")
+	c.Assert(catalog, NotNil)
+	c.Check(catalog.G("access denied"), Equals, "Zugriff verweigert")
+
+	// kept once read
+	c.Check(CatalogFor("de"), Equals, catalog)
+
+	c.Check(CatalogFor("fr_FR.UTF-8"), IsNil)
+	c.Check(CatalogFor("C"), IsNil)
+}
@@ -0,0 +1,80 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package i18ntest contains helper functions for mocking message
+// catalogs.
+package i18ntest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/check.v1"
+)
+
+// MakeMO builds a gettext .mo file in the given byte order with the
+// given translations, keyed by msgid. Plural messages are keyed by
+// "<singular>\x00<plural>" and their forms are separated by "\x00".
+func MakeMO(order binary.ByteOrder, messages map[string]string) []byte {
+	msgids := make([]string, 0, len(messages))
+	for msgid := range messages {
+		msgids = append(msgids, msgid)
+	}
+	sort.Strings(msgids)
+	msgstrs := make([]string, len(msgids))
+	for i, msgid := range msgids {
+		msgstrs[i] = messages[msgid]
+	}
+
+	n := uint32(len(msgids))
+	origTable := uint32(28)
+	transTable := origTable + n*8
+	offset := transTable + n*8
+
+	var strs bytes.Buffer
+	entries := func(strings []string) []uint32 {
+		var entries []uint32
+		for _, str := range strings {
+			entries = append(entries, uint32(len(str)), offset+uint32(strs.Len()))
+			strs.WriteString(str)
+			strs.WriteByte(0)
+		}
+		return entries
+	}
+
+	var mo bytes.Buffer
+	binary.Write(&mo, order, []uint32{0x950412de, 0, n, origTable, transTable, 0, 0})
+	binary.Write(&mo, order, entries(msgids))
+	binary.Write(&mo, order, entries(msgstrs))
+	mo.Write(strs.Bytes())
+	return mo.Bytes()
+}
+
+// MockCatalog puts the catalog of the translations of the domain to
+// the locale under localeDir, as gettext expects it.
+func MockCatalog(c *check.C, localeDir, locale, domain string, messages map[string]string) {
+	dir := filepath.Join(localeDir, locale, "LC_MESSAGES")
+	c.Assert(os.MkdirAll(dir, 0755), check.IsNil)
+	err := ioutil.WriteFile(filepath.Join(dir, domain+".mo"), MakeMO(binary.LittleEndian, messages), 0644)
+	c.Assert(err, check.IsNil)
+}
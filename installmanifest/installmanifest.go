@@ -0,0 +1,227 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2014-2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package installmanifest keeps the manifest of the snaps installed,
+// refreshed and removed on the device: an append-only log whose entries
+// are chained by their hashes, so that changing or dropping any entry but
+// the last ones is evident, which can be exported, signed, for audits.
+package installmanifest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/fips"
+	"github.com/snapcore/snapd/osutil"
+)
+
+// The actions recorded in the manifest.
+const (
+	ActionInstall = "install"
+	ActionRefresh = "refresh"
+	ActionRemove  = "remove"
+)
+
+// Entry records an action on a snap.
+type Entry struct {
+	// Seq is the position of the entry in the manifest, from 1.
+	Seq      int       `json:"seq"`
+	Time     time.Time `json:"time"`
+	Action   string    `json:"action"`
+	Snap     string    `json:"snap"`
+	Revision string    `json:"revision,omitempty"`
+	// SHA512 is the hex encoded sha512 digest of the snap file
+	// installed, if it could be read.
+	SHA512 string `json:"sha512,omitempty"`
+	// Change is the id of the change that made the action.
+	Change string `json:"change,omitempty"`
+	// Prev is the hash of the previous entry, empty for the first one.
+	Prev string `json:"prev"`
+	// Hash is the hex encoded sha256 digest of the entry without it.
+	Hash string `json:"hash"`
+}
+
+// hash returns the hash of the entry, computed over its JSON encoding
+// without the hash itself.
+func (e *Entry) hash() (string, error) {
+	unhashed := *e
+	unhashed.Hash = ""
+	data, err := json.Marshal(&unhashed)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(fips.Sum256(data)), nil
+}
+
+// mu serializes the appends to the manifest.
+var mu sync.Mutex
+
+// Append chains the given entries to the last one of the manifest,
+// filling in their Seq, Prev and Hash, and appends them to the manifest.
+func Append(entries ...*Entry) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	existing, err := Entries()
+	if err != nil {
+		return err
+	}
+	seq, prev := 0, ""
+	if len(existing) > 0 {
+		last := existing[len(existing)-1]
+		seq, prev = last.Seq, last.Hash
+	}
+
+	var buf bytes.Buffer
+	for _, e := range entries {
+		seq++
+		e.Seq = seq
+		e.Prev = prev
+		e.Hash = ""
+		if e.Hash, err = e.hash(); err != nil {
+			return err
+		}
+		prev = e.Hash
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dirs.SnapInstallManifestFile), 0755); err != nil {
+		return fmt.Errorf("cannot create install manifest directory: %v", err)
+	}
+	f, err := os.OpenFile(dirs.SnapInstallManifestFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("cannot open install manifest: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("cannot append to install manifest: %v", err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("cannot sync install manifest: %v", err)
+	}
+	return nil
+}
+
+// Entries returns the entries of the manifest, oldest first, without
+// checking them.
+func Entries() ([]*Entry, error) {
+	f, err := os.Open(dirs.SnapInstallManifestFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read install manifest: %v", err)
+	}
+	defer f.Close()
+
+	var entries []*Entry
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("cannot read line %d of install manifest: %v", line, err)
+		}
+		entries = append(entries, &e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read install manifest: %v", err)
+	}
+	return entries, nil
+}
+
+// Verify checks that each of the given entries follows the previous one
+// and is as it was appended.
+func Verify(entries []*Entry) error {
+	prev := ""
+	for i, e := range entries {
+		if e.Seq != i+1 {
+			return fmt.Errorf("install manifest entry %d is out of sequence (found %d)", i+1, e.Seq)
+		}
+		if e.Prev != prev {
+			return fmt.Errorf("install manifest entry %d does not follow the previous one", e.Seq)
+		}
+		hash, err := e.hash()
+		if err != nil {
+			return err
+		}
+		if e.Hash != hash {
+			return fmt.Errorf("install manifest entry %d was modified", e.Seq)
+		}
+		prev = e.Hash
+	}
+	return nil
+}
+
+// ExportOptions hold options for Export.
+type ExportOptions struct {
+	// KeyID is the GnuPG key used to sign the manifest, if any.
+	KeyID string
+}
+
+// runGPG runs gpg from the default home directory in batch mode, so
+// that it never asks anything.
+var runGPG = func(input []byte, args ...string) ([]byte, error) {
+	return osutil.RunGPG("", input, append([]string{"--batch"}, args...)...)
+}
+
+// Export writes the manifest to w once verified, returning its entries
+// and, if a key is given, the detached signature of what was written.
+func Export(w io.Writer, opts *ExportOptions) (entries []*Entry, sig []byte, err error) {
+	entries, err = Entries()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := Verify(entries); err != nil {
+		return nil, nil, err
+	}
+
+	var buf bytes.Buffer
+	for _, e := range entries {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return nil, nil, err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	if opts != nil && opts.KeyID != "" {
+		sig, err = runGPG(buf.Bytes(), "--default-key", "0x"+opts.KeyID, "--detach-sign")
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot sign install manifest: %v", err)
+		}
+	}
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return nil, nil, err
+	}
+	return entries, sig, nil
+}
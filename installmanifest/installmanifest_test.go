@@ -0,0 +1,195 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2014-2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package installmanifest_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/installmanifest"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type manifestSuite struct{}
+
+var _ = Suite(&manifestSuite{})
+
+func (s *manifestSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+}
+
+func (s *manifestSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("/")
+}
+
+var t0 = time.Date(2016, 11, 1, 10, 0, 0, 0, time.UTC)
+
+func (s *manifestSuite) appendSome(c *C) {
+	err := installmanifest.Append(
+		&installmanifest.Entry{Time: t0, Action: installmanifest.ActionInstall, Snap: "foo", Revision: "1", SHA512: "abc", Change: "1"},
+		&installmanifest.Entry{Time: t0, Action: installmanifest.ActionInstall, Snap: "bar", Revision: "3", Change: "1"},
+	)
+	c.Assert(err, IsNil)
+	err = installmanifest.Append(&installmanifest.Entry{Time: t0.Add(time.Hour), Action: installmanifest.ActionRemove, Snap: "foo", Change: "2"})
+	c.Assert(err, IsNil)
+}
+
+func (s *manifestSuite) TestEntriesEmpty(c *C) {
+	entries, err := installmanifest.Entries()
+	c.Assert(err, IsNil)
+	c.Check(entries, HasLen, 0)
+	c.Check(installmanifest.Verify(entries), IsNil)
+}
+
+func (s *manifestSuite) TestAppendChains(c *C) {
+	s.appendSome(c)
+
+	entries, err := installmanifest.Entries()
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 3)
+	c.Check(installmanifest.Verify(entries), IsNil)
+
+	c.Check(entries[0].Seq, Equals, 1)
+	c.Check(entries[0].Prev, Equals, "")
+	c.Check(entries[0].Snap, Equals, "foo")
+	c.Check(entries[0].SHA512, Equals, "abc")
+	c.Check(entries[1].Seq, Equals, 2)
+	c.Check(entries[1].Prev, Equals, entries[0].Hash)
+	c.Check(entries[2].Seq, Equals, 3)
+	c.Check(entries[2].Prev, Equals, entries[1].Hash)
+	c.Check(entries[2].Action, Equals, "remove")
+	c.Check(entries[2].Change, Equals, "2")
+
+	st, err := os.Stat(dirs.SnapInstallManifestFile)
+	c.Assert(err, IsNil)
+	c.Check(st.Mode().Perm(), Equals, os.FileMode(0600))
+}
+
+func (s *manifestSuite) TestVerifyDetectsTampering(c *C) {
+	s.appendSome(c)
+
+	entries, err := installmanifest.Entries()
+	c.Assert(err, IsNil)
+	entries[1].Revision = "4"
+	c.Check(installmanifest.Verify(entries), ErrorMatches, "install manifest entry 2 was modified")
+
+	entries, err = installmanifest.Entries()
+	c.Assert(err, IsNil)
+	c.Check(installmanifest.Verify(append(entries[:1], entries[2:]...)), ErrorMatches, "install manifest entry 2 is out of sequence \\(found 3\\)")
+
+	entries, err = installmanifest.Entries()
+	c.Assert(err, IsNil)
+	// rehashing a modified entry breaks the chain
+	entries[0].Revision = "2"
+	entries[0].Hash = "0000"
+	c.Check(installmanifest.Verify(entries), ErrorMatches, "install manifest entry 1 was modified")
+}
+
+func (s *manifestSuite) TestEntriesBadLine(c *C) {
+	s.appendSome(c)
+	f, err := os.OpenFile(dirs.SnapInstallManifestFile, os.O_WRONLY|os.O_APPEND, 0)
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte("garbage\n"))
+	c.Assert(err, IsNil)
+	f.Close()
+
+	_, err = installmanifest.Entries()
+	c.Check(err, ErrorMatches, "cannot read line 4 of install manifest: .*")
+}
+
+func (s *manifestSuite) TestExport(c *C) {
+	s.appendSome(c)
+	restore := installmanifest.MockRunGPG(func(input []byte, args ...string) ([]byte, error) {
+		c.Fatalf("unexpected gpg call")
+		return nil, nil
+	})
+	defer restore()
+
+	var buf bytes.Buffer
+	entries, sig, err := installmanifest.Export(&buf, nil)
+	c.Assert(err, IsNil)
+	c.Check(entries, HasLen, 3)
+	c.Check(sig, IsNil)
+
+	data, err := ioutil.ReadFile(dirs.SnapInstallManifestFile)
+	c.Assert(err, IsNil)
+	c.Check(buf.String(), Equals, string(data))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	c.Assert(lines, HasLen, 3)
+	var e installmanifest.Entry
+	c.Assert(json.Unmarshal([]byte(lines[2]), &e), IsNil)
+	c.Check(e.Hash, Equals, entries[2].Hash)
+}
+
+func (s *manifestSuite) TestExportSigned(c *C) {
+	s.appendSome(c)
+	var gpgInput []byte
+	var gpgArgs []string
+	restore := installmanifest.MockRunGPG(func(input []byte, args ...string) ([]byte, error) {
+		gpgInput = input
+		gpgArgs = args
+		return []byte("signature"), nil
+	})
+	defer restore()
+
+	var buf bytes.Buffer
+	_, sig, err := installmanifest.Export(&buf, &installmanifest.ExportOptions{KeyID: "ABCD"})
+	c.Assert(err, IsNil)
+	c.Check(string(sig), Equals, "signature")
+	c.Check(string(gpgInput), Equals, buf.String())
+	c.Check(gpgArgs, DeepEquals, []string{"--default-key", "0xABCD", "--detach-sign"})
+}
+
+func (s *manifestSuite) TestExportSignError(c *C) {
+	s.appendSome(c)
+	restore := installmanifest.MockRunGPG(func(input []byte, args ...string) ([]byte, error) {
+		return nil, errors.New("no secret key")
+	})
+	defer restore()
+
+	var buf bytes.Buffer
+	_, _, err := installmanifest.Export(&buf, &installmanifest.ExportOptions{KeyID: "ABCD"})
+	c.Check(err, ErrorMatches, "cannot sign install manifest: no secret key")
+	c.Check(buf.Len(), Equals, 0)
+}
+
+func (s *manifestSuite) TestExportRefusesTampered(c *C) {
+	s.appendSome(c)
+	data, err := ioutil.ReadFile(dirs.SnapInstallManifestFile)
+	c.Assert(err, IsNil)
+	data = bytes.Replace(data, []byte(`"revision":"3"`), []byte(`"revision":"5"`), 1)
+	c.Assert(ioutil.WriteFile(dirs.SnapInstallManifestFile, data, 0600), IsNil)
+
+	var buf bytes.Buffer
+	_, _, err = installmanifest.Export(&buf, nil)
+	c.Check(err, ErrorMatches, "install manifest entry 2 was modified")
+	c.Check(buf.Len(), Equals, 0)
+}
@@ -102,6 +102,37 @@ func (b *Backend) Setup(snapInfo *snap.Info, devMode bool, repo *interfaces.Repo
 	return errUnload
 }
 
+// Regenerate writes the apparmor profiles of a given snap again, as
+// Setup does, but leaves loading them to the caller. It returns the
+// paths of the profiles.
+//
+// This method should be called after changing the policy the profiles
+// are made from.
+func (b *Backend) Regenerate(snapInfo *snap.Info, devMode bool, repo *interfaces.Repository) ([]string, error) {
+	snapName := snapInfo.Name()
+	snippets, err := repo.SecuritySnippetsForSnap(snapName, interfaces.SecurityAppArmor)
+	if err != nil {
+		return nil, fmt.Errorf("cannot obtain security snippets for snap %q: %s", snapName, err)
+	}
+	content, err := b.combineSnippets(snapInfo, devMode, snippets)
+	if err != nil {
+		return nil, fmt.Errorf("cannot obtain expected security files for snap %q: %s", snapName, err)
+	}
+	dir := dirs.SnapAppArmorDir
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("cannot create directory for apparmor profiles %q: %s", dir, err)
+	}
+	if _, _, err := osutil.EnsureDirState(dir, interfaces.SecurityTagGlob(snapName), content); err != nil {
+		return nil, fmt.Errorf("cannot synchronize security files for snap %q: %s", snapName, err)
+	}
+	profiles := make([]string, 0, len(content))
+	for name := range content {
+		profiles = append(profiles, filepath.Join(dir, name))
+	}
+	sort.Strings(profiles)
+	return profiles, nil
+}
+
 // Remove removes and unloads apparmor profiles of a given snap.
 func (b *Backend) Remove(snapName string) error {
 	glob := interfaces.SecurityTagGlob(snapName)
@@ -122,26 +153,23 @@ var (
 	attachComplain           = []byte("(attach_disconnected,complain)")
 )
 
+// readOnlyDataSnippet denies writing the system data of the revision,
+// for the snaps whose data was sealed read-only.
+var readOnlyDataSnippet = []byte(`
+# The data of this revision is read-only
+deny /var/snap/@{SNAP_NAME}/@{SNAP_REVISION}/** wl,
+`)
+
 // combineSnippets combines security snippets collected from all the interfaces
 // affecting a given snap into a content map applicable to EnsureDirState. The
 // backend delegates writing those files to higher layers.
 func (b *Backend) combineSnippets(snapInfo *snap.Info, devMode bool, snippets map[string][][]byte) (content map[string]*osutil.FileState, err error) {
 	for _, appInfo := range snapInfo.Apps {
-		policy := defaultTemplate
-		if devMode {
-			policy = attachPattern.ReplaceAll(policy, attachComplain)
+		joined := bytes.Join(snippets[appInfo.Name], []byte("\n"))
+		if snapInfo.ReadOnlyData {
+			joined = append(joined, readOnlyDataSnippet...)
 		}
-		policy = templatePattern.ReplaceAllFunc(policy, func(placeholder []byte) []byte {
-			switch {
-			case bytes.Equal(placeholder, placeholderVar):
-				return templateVariables(appInfo)
-			case bytes.Equal(placeholder, placeholderProfileAttach):
-				return []byte(fmt.Sprintf("profile \"%s\"", appInfo.SecurityTag()))
-			case bytes.Equal(placeholder, placeholderSnippets):
-				return bytes.Join(snippets[appInfo.Name], []byte("\n"))
-			}
-			return nil
-		})
+		policy := ExpandTemplate(defaultTemplate, appInfo, devMode, joined)
 		if content == nil {
 			content = make(map[string]*osutil.FileState)
 		}
@@ -154,6 +182,26 @@ func (b *Backend) combineSnippets(snapInfo *snap.Info, devMode bool, snippets ma
 	return content, nil
 }
 
+// ExpandTemplate returns the profile of the given application made from
+// the template, with its variables, the attachment of its profile and
+// the given snippets in place of the placeholders.
+func ExpandTemplate(template []byte, appInfo *snap.AppInfo, devMode bool, snippets []byte) []byte {
+	if devMode {
+		template = attachPattern.ReplaceAll(template, attachComplain)
+	}
+	return templatePattern.ReplaceAllFunc(template, func(placeholder []byte) []byte {
+		switch {
+		case bytes.Equal(placeholder, placeholderVar):
+			return templateVariables(appInfo)
+		case bytes.Equal(placeholder, placeholderProfileAttach):
+			return []byte(fmt.Sprintf("profile \"%s\"", appInfo.SecurityTag()))
+		case bytes.Equal(placeholder, placeholderSnippets):
+			return snippets
+		}
+		return nil
+	})
+}
+
 func reloadProfiles(profiles []string) error {
 	for _, profile := range profiles {
 		fname := filepath.Join(dirs.SnapAppArmorDir, profile)
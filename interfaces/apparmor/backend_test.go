@@ -150,6 +150,22 @@ func (s *backendSuite) TestProfilesAreAlwaysLoaded(c *C) {
 	}
 }
 
+func (s *backendSuite) TestRegenerateWritesProfilesWithoutLoading(c *C) {
+	snapInfo := s.installSnap(c, false, sambaYamlWithNmbd, 1)
+	nmbd := filepath.Join(dirs.SnapAppArmorDir, "snap.samba.nmbd")
+	smbd := filepath.Join(dirs.SnapAppArmorDir, "snap.samba.smbd")
+	c.Assert(os.Remove(smbd), IsNil)
+	s.parserCmd.ForgetCalls()
+
+	profiles, err := s.backend.Regenerate(snapInfo, false, s.repo)
+	c.Assert(err, IsNil)
+	c.Check(profiles, DeepEquals, []string{nmbd, smbd})
+	data, err := ioutil.ReadFile(smbd)
+	c.Assert(err, IsNil)
+	c.Check(string(data), testutil.Contains, `profile "snap.samba.smbd"`)
+	c.Check(s.parserCmd.Calls(), HasLen, 0)
+}
+
 func (s *backendSuite) TestRemovingSnapRemovesAndUnloadsProfiles(c *C) {
 	for _, devMode := range []bool{true, false} {
 		snapInfo := s.installSnap(c, devMode, sambaYaml, 1)
@@ -245,6 +261,25 @@ func (s *backendSuite) TestRealDefaultTemplateIsNormallyUsed(c *C) {
 	}
 }
 
+func (s *backendSuite) TestRealDefaultTemplateAllowsLocaleAndTimezone(c *C) {
+	snapInfo, err := snap.InfoFromSnapYaml([]byte(sambaYaml))
+	c.Assert(err, IsNil)
+	err = s.backend.Setup(snapInfo, false, s.repo)
+	c.Assert(err, IsNil)
+	data, err := ioutil.ReadFile(filepath.Join(dirs.SnapAppArmorDir, "snap.samba.smbd"))
+	c.Assert(err, IsNil)
+	for _, line := range []string{
+		"  /etc/{,writable/}localtime r,\n",
+		"  /etc/{,writable/}timezone r,\n",
+		"  /etc/default/locale r,\n",
+		"  /usr/share/zoneinfo/{,**} r,\n",
+		"  /usr/lib/locale/{,**} r,\n",
+		"  /usr/share/i18n/{,**} r,\n",
+	} {
+		c.Check(string(data), testutil.Contains, line)
+	}
+}
+
 type combineSnippetsScenario struct {
 	devMode bool
 	snippet string
@@ -312,6 +347,33 @@ func (s *backendSuite) TestCombineSnippets(c *C) {
 	}
 }
 
+func (s *backendSuite) TestReadOnlyData(c *C) {
+	restore := apparmor.MockTemplate([]byte("\n" +
+		"###VAR###\n" +
+		"###PROFILEATTACH### (attach_disconnected) {\n" +
+		"###SNIPPETS###\n" +
+		"}\n"))
+	defer restore()
+	snapInfo, err := snap.InfoFromSnapYaml([]byte(sambaYaml))
+	c.Assert(err, IsNil)
+	snapInfo.Revision = snap.R(1)
+	snapInfo.ReadOnlyData = true
+	c.Assert(s.repo.AddSnap(snapInfo), IsNil)
+	c.Assert(s.backend.Setup(snapInfo, false, s.repo), IsNil)
+
+	data, err := ioutil.ReadFile(filepath.Join(dirs.SnapAppArmorDir, "snap.samba.smbd"))
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, commonPrefix+`
+profile "snap.samba.smbd" (attach_disconnected) {
+
+# The data of this revision is read-only
+deny /var/snap/@{SNAP_NAME}/@{SNAP_REVISION}/** wl,
+
+}
+`)
+	s.removeSnap(c, snapInfo)
+}
+
 // Support code for tests
 
 // installSnap "installs" a snap from YAML.
@@ -0,0 +1,120 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package apparmor
+
+import (
+	"encoding/hex"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Denial is an access denied by apparmor, as logged by the kernel.
+type Denial struct {
+	Time      time.Time
+	Profile   string
+	Operation string
+	// Name is the path of the denied file operations.
+	Name string
+	// Capability is the name of the denied capability.
+	Capability string
+	// Requested and Denied are the masks of the file permissions
+	// requested and denied, e.g. "rw" and "w".
+	Requested string
+	Denied    string
+}
+
+var (
+	auditTimePattern  = regexp.MustCompile(`audit\((\d+)\.(\d+):\d+\)`)
+	auditFieldPattern = regexp.MustCompile(`(\w+)=("[^"]*"|\S+)`)
+)
+
+// auditValue returns the value of a field of an audit record, decoding
+// it from hex if it is a path apparmor encoded because of special
+// characters.
+func auditValue(value string, path bool) string {
+	if strings.HasPrefix(value, `"`) {
+		return strings.Trim(value, `"`)
+	}
+	if path {
+		if decoded, err := hex.DecodeString(value); err == nil {
+			return string(decoded)
+		}
+	}
+	return value
+}
+
+// ParseDenial returns the apparmor denial logged in the given line of
+// the audit or kernel log, or false if it is not one.
+func ParseDenial(line string) (*Denial, bool) {
+	if !strings.Contains(line, `apparmor="DENIED"`) {
+		return nil, false
+	}
+
+	d := &Denial{}
+	if m := auditTimePattern.FindStringSubmatch(line); m != nil {
+		sec, _ := strconv.ParseInt(m[1], 10, 64)
+		msec, _ := strconv.ParseInt(m[2], 10, 64)
+		d.Time = time.Unix(sec, msec*int64(time.Millisecond))
+	}
+	for _, m := range auditFieldPattern.FindAllStringSubmatch(line, -1) {
+		switch m[1] {
+		case "profile":
+			d.Profile = auditValue(m[2], false)
+		case "operation":
+			d.Operation = auditValue(m[2], false)
+		case "name":
+			d.Name = auditValue(m[2], true)
+		case "capname":
+			d.Capability = auditValue(m[2], false)
+		case "requested_mask":
+			d.Requested = auditValue(m[2], false)
+		case "denied_mask":
+			d.Denied = auditValue(m[2], false)
+		}
+	}
+	if d.Profile == "" {
+		return nil, false
+	}
+	// denials in children profiles are the ones of the parent
+	if i := strings.Index(d.Profile, "//"); i >= 0 {
+		d.Profile = d.Profile[:i]
+	}
+
+	return d, true
+}
+
+// MaskAccess returns the access a mask of file permissions of a denial
+// stands for.
+func MaskAccess(mask string) Access {
+	var access Access
+	for _, perm := range mask {
+		switch perm {
+		case 'r':
+			access.Read = true
+		case 'w', 'a', 'c', 'd':
+			access.Write = true
+		case 'x':
+			access.Execute = true
+		}
+	}
+	return access
+}
@@ -0,0 +1,72 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package apparmor_test
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces/apparmor"
+)
+
+type denialSuite struct{}
+
+var _ = Suite(&denialSuite{})
+
+func (s *denialSuite) TestParseDenialFile(c *C) {
+	d, ok := apparmor.ParseDenial(`Oct 14 15:12:04 host kernel: [ 42.1] audit: type=1400 audit(1476453124.513:1090): apparmor="DENIED" operation="open" profile="snap.foo.app" name="/media/usb0/file" pid=1234 comm="foo" requested_mask="r" denied_mask="r" fsuid=1000 ouid=1000`)
+	c.Assert(ok, Equals, true)
+	c.Check(d, DeepEquals, &apparmor.Denial{
+		Time:      time.Unix(1476453124, 513*int64(time.Millisecond)),
+		Profile:   "snap.foo.app",
+		Operation: "open",
+		Name:      "/media/usb0/file",
+		Requested: "r",
+		Denied:    "r",
+	})
+}
+
+func (s *denialSuite) TestParseDenialCapability(c *C) {
+	d, ok := apparmor.ParseDenial(`type=AVC msg=audit(1476453124.513:1091): apparmor="DENIED" operation="capable" profile="snap.foo.hook.configure//null-/usr/bin/foo" pid=1234 comm="foo" capability=12 capname="net_admin"`)
+	c.Assert(ok, Equals, true)
+	c.Check(d.Profile, Equals, "snap.foo.hook.configure")
+	c.Check(d.Operation, Equals, "capable")
+	c.Check(d.Capability, Equals, "net_admin")
+}
+
+func (s *denialSuite) TestParseDenialHexName(c *C) {
+	d, ok := apparmor.ParseDenial(`audit(1476453124.513:1092): apparmor="DENIED" operation="mknod" profile="snap.foo.app" name=2F6D656469612F6D7920646F63 pid=1 requested_mask="c" denied_mask="c"`)
+	c.Assert(ok, Equals, true)
+	c.Check(d.Name, Equals, "/media/my doc")
+	c.Check(apparmor.MaskAccess(d.Denied), Equals, apparmor.Access{Write: true})
+}
+
+func (s *denialSuite) TestParseDenialNotOne(c *C) {
+	for _, line := range []string{
+		"",
+		`audit(1476453124.513:1093): apparmor="ALLOWED" operation="open" profile="snap.foo.app" name="/etc/foo"`,
+		`audit(1476453124.513:1093): apparmor="STATUS" operation="profile_load" name="snap.foo.app"`,
+		`kernel: usb 1-1: new high-speed USB device`,
+	} {
+		_, ok := apparmor.ParseDenial(line)
+		c.Check(ok, Equals, false, Commentf(line))
+	}
+}
@@ -0,0 +1,272 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package apparmor
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+// Access is what apparmor rules allow doing with a path.
+type Access struct {
+	Read    bool `json:"read"`
+	Write   bool `json:"write"`
+	Execute bool `json:"execute"`
+}
+
+// Any returns whether anything is allowed at all.
+func (a Access) Any() bool {
+	return a.Read || a.Write || a.Execute
+}
+
+// Covers returns whether everything the other access needs is allowed.
+func (a Access) Covers(other Access) bool {
+	return (a.Read || !other.Read) && (a.Write || !other.Write) && (a.Execute || !other.Execute)
+}
+
+// tunables are the variables of the system wide tunables the profiles
+// and snippets use, with their usual values.
+var tunables = map[string][]string{
+	"HOME":     {"/home/*/", "/root/"},
+	"HOMEDIRS": {"/home/"},
+	"PROC":     {"/proc/"},
+	"pid":      {"[1-9]*"},
+	"pids":     {"[1-9]*"},
+	"sys":      {"/sys/"},
+	"run":      {"/run/", "/var/run/"},
+}
+
+// fileRule is a file rule of a profile, with its path turned into a
+// regular expression.
+type fileRule struct {
+	path   *regexp.Regexp
+	access Access
+	deny   bool
+}
+
+// Rules are the file rules of an apparmor profile, or of snippets of one.
+type Rules struct {
+	vars  map[string][]string
+	rules []fileRule
+	// capabilities tells the capabilities allowed, or denied with
+	// false whatever the other rules say.
+	capabilities map[string]bool
+}
+
+// qualifiers are the words that can come before the path of a rule.
+var qualifiers = map[string]bool{"deny": true, "owner": true, "audit": true, "allow": true}
+
+var (
+	varPattern   = regexp.MustCompile(`^@\{(\w+)\}\s*\+?=\s*(.*)$`)
+	usePattern   = regexp.MustCompile(`@\{(\w+)\}`)
+	slashPattern = regexp.MustCompile(`//+`)
+)
+
+// ParseRules returns the file rules found in the given profile or
+// snippet, which can use the variables of base as well as the ones of
+// the system wide tunables. Includes are not followed, and the rules
+// using unknown variables are left out.
+func ParseRules(text []byte, base *Rules) *Rules {
+	r := &Rules{
+		vars:         make(map[string][]string),
+		capabilities: make(map[string]bool),
+	}
+	for name, values := range tunables {
+		r.vars[name] = values
+	}
+	if base != nil {
+		for name, values := range base.vars {
+			r.vars[name] = values
+		}
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(text))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if m := varPattern.FindStringSubmatch(line); m != nil {
+			var values []string
+			for _, value := range strings.Fields(m[2]) {
+				values = append(values, strings.Trim(value, `"`))
+			}
+			r.vars[m[1]] = values
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	for _, line := range lines {
+		r.addRule(line)
+	}
+	return r
+}
+
+// addRule adds the given line of a profile if it is a file rule.
+func (r *Rules) addRule(line string) {
+	if i := strings.Index(line, "#"); i >= 0 {
+		line = line[:i]
+	}
+	fields := strings.Fields(strings.TrimSuffix(strings.TrimSpace(line), ","))
+	var rule fileRule
+	for len(fields) > 0 && qualifiers[fields[0]] {
+		rule.deny = rule.deny || fields[0] == "deny"
+		fields = fields[1:]
+	}
+	if len(fields) > 1 && fields[0] == "capability" {
+		for _, name := range fields[1:] {
+			if allowed, ok := r.capabilities[name]; !ok || allowed {
+				r.capabilities[name] = !rule.deny
+			}
+		}
+		return
+	}
+	if len(fields) < 2 || !(strings.HasPrefix(fields[0], "/") || strings.HasPrefix(fields[0], "@{")) {
+		return
+	}
+	for _, perm := range fields[1] {
+		switch perm {
+		case 'r':
+			rule.access.Read = true
+		case 'w', 'a':
+			rule.access.Write = true
+		case 'x':
+			rule.access.Execute = true
+		}
+	}
+	if !rule.access.Any() {
+		return
+	}
+
+	paths, ok := r.expand(fields[0])
+	if !ok {
+		return
+	}
+	for i, path := range paths {
+		paths[i] = globToRegexp(slashPattern.ReplaceAllString(path, "/"))
+	}
+	pattern, err := regexp.Compile("^(?:" + strings.Join(paths, "|") + ")$")
+	if err != nil {
+		return
+	}
+	rule.path = pattern
+	r.rules = append(r.rules, rule)
+}
+
+// expand returns the paths the given path with variables stands for,
+// or false if it uses an unknown variable.
+func (r *Rules) expand(path string) ([]string, bool) {
+	m := usePattern.FindStringSubmatchIndex(path)
+	if m == nil {
+		return []string{path}, true
+	}
+	values, ok := r.vars[path[m[2]:m[3]]]
+	if !ok {
+		return nil, false
+	}
+	var paths []string
+	for _, value := range values {
+		expanded, ok := r.expand(path[:m[0]] + value + path[m[1]:])
+		if !ok {
+			return nil, false
+		}
+		paths = append(paths, expanded...)
+	}
+	return paths, true
+}
+
+// globToRegexp turns an apparmor glob into a regular expression.
+func globToRegexp(glob string) string {
+	var buf bytes.Buffer
+	depth := 0
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; {
+		case c == '*' && i+1 < len(glob) && glob[i+1] == '*':
+			// like apparmor_parser, a wildcard right after a
+			// slash does not match an empty name
+			if i > 0 && glob[i-1] == '/' {
+				buf.WriteString("[^/].*")
+			} else {
+				buf.WriteString(".*")
+			}
+			i++
+		case c == '*':
+			if i > 0 && glob[i-1] == '/' {
+				buf.WriteString("[^/]+")
+			} else {
+				buf.WriteString("[^/]*")
+			}
+		case c == '?':
+			buf.WriteString("[^/]")
+		case c == '{':
+			buf.WriteString("(?:")
+			depth++
+		case c == '}' && depth > 0:
+			buf.WriteString(")")
+			depth--
+		case c == ',' && depth > 0:
+			buf.WriteString("|")
+		case c == '[':
+			end := strings.IndexByte(glob[i:], ']')
+			if end < 0 {
+				buf.WriteString(regexp.QuoteMeta(glob[i:]))
+				return buf.String()
+			}
+			buf.WriteString(glob[i : i+end+1])
+			i += end
+		default:
+			buf.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return buf.String()
+}
+
+// Access returns what the rules allow doing with the given path, the
+// deny rules taking precedence. A directory is only matched by rules
+// for paths ending with a slash.
+func (r *Rules) Access(path string) Access {
+	var allowed, denied Access
+	for _, rule := range r.rules {
+		if !rule.path.MatchString(path) {
+			continue
+		}
+		acc := &allowed
+		if rule.deny {
+			acc = &denied
+		}
+		acc.Read = acc.Read || rule.access.Read
+		acc.Write = acc.Write || rule.access.Write
+		acc.Execute = acc.Execute || rule.access.Execute
+	}
+	return Access{
+		Read:    allowed.Read && !denied.Read,
+		Write:   allowed.Write && !denied.Write,
+		Execute: allowed.Execute && !denied.Execute,
+	}
+}
+
+// Capability returns whether the rules allow using the given capability.
+func (r *Rules) Capability(name string) bool {
+	return r.capabilities[name]
+}
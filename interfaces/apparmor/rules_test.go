@@ -0,0 +1,101 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package apparmor_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces/apparmor"
+)
+
+type rulesSuite struct{}
+
+var _ = Suite(&rulesSuite{})
+
+const rulesProfile = `
+@{SNAP_NAME}="foo"
+@{SNAP_REVISION}="7"
+@{INSTALL_DIR}="/snap"
+
+profile "snap.foo.app" (attach_disconnected) {
+  #include <abstractions/base>
+  /usr/bin/python{,2,3} ixr,
+  @{INSTALL_DIR}/@{SNAP_NAME}/@{SNAP_REVISION}/**  mrklix,
+  owner @{HOME}/snap/@{SNAP_NAME}/** mrkix,
+  owner @{HOME}/snap/@{SNAP_NAME}/@{SNAP_REVISION}/** wl,
+  /var/snap/@{SNAP_NAME}/** rwk, # the data
+  deny /var/snap/@{SNAP_NAME}/7/** wl,
+  @{PROC}/@{pid}/task/[0-9]*/stat r,
+  @{UNKNOWN}/** rw,
+  network inet,
+  capability net_bind_service,
+}
+`
+
+func (s *rulesSuite) TestAccess(c *C) {
+	rules := apparmor.ParseRules([]byte(rulesProfile), nil)
+	for path, access := range map[string]apparmor.Access{
+		"/usr/bin/python3":                   {Read: true, Execute: true},
+		"/usr/bin/python4":                   {},
+		"/snap/foo/7/bin/app":                {Read: true, Execute: true},
+		"/snap/foo/8/bin/app":                {},
+		"/home/user/snap/foo/7/file":         {Read: true, Write: true, Execute: true},
+		"/root/snap/foo/common/file":         {Read: true, Execute: true},
+		"/home/user/Documents":               {},
+		"/var/snap/foo/common/file":          {Read: true, Write: true},
+		"/var/snap/foo/7/file":               {Read: true},
+		"/proc/42/task/43/stat":              {Read: true},
+		"/proc/self/task/43/stat":            {},
+		"/media/usb0/":                       {},
+		"/home/user/snap/foo/7/sub/dir/file": {Read: true, Write: true, Execute: true},
+	} {
+		c.Check(rules.Access(path), Equals, access, Commentf(path))
+	}
+}
+
+func (s *rulesSuite) TestAccessSnippetWithBaseVariables(c *C) {
+	base := apparmor.ParseRules([]byte(rulesProfile), nil)
+	rules := apparmor.ParseRules([]byte(`
+# Description: media access
+/media/*/ r,
+/media/*/** rw,
+/run/@{SNAP_NAME}/** rw,
+`), base)
+	c.Check(rules.Access("/media/usb0/"), Equals, apparmor.Access{Read: true})
+	c.Check(rules.Access("/media/usb0/file"), Equals, apparmor.Access{Read: true, Write: true})
+	c.Check(rules.Access("/run/foo/sock"), Equals, apparmor.Access{Read: true, Write: true})
+	// the rules of the base are not part of the snippet
+	c.Check(rules.Access("/var/snap/foo/common/file").Any(), Equals, false)
+}
+
+func (s *rulesSuite) TestCapability(c *C) {
+	rules := apparmor.ParseRules([]byte(`
+capability net_bind_service,
+capability sys_admin setuid,
+deny capability setuid,
+capability setuid,
+audit capability chown,
+`), nil)
+	c.Check(rules.Capability("net_bind_service"), Equals, true)
+	c.Check(rules.Capability("sys_admin"), Equals, true)
+	c.Check(rules.Capability("chown"), Equals, true)
+	c.Check(rules.Capability("setuid"), Equals, false)
+	c.Check(rules.Capability("net_admin"), Equals, false)
+}
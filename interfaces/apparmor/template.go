@@ -211,6 +211,12 @@ var defaultTemplate = []byte(`
   /etc/{,writable/}hostname r,
   /etc/{,writable/}localtime r,
   /etc/{,writable/}timezone r,
+  # the system locale and timezone data, so apps don't fall back to "C"
+  # and UTC
+  /etc/default/locale r,
+  /usr/share/zoneinfo/{,**} r,
+  /usr/lib/locale/{,**} r,
+  /usr/share/i18n/{,**} r,
   @{PROC}/@{pid}/io r,
   @{PROC}/@{pid}/stat r,
   @{PROC}/@{pid}/statm r,
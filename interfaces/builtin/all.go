@@ -29,12 +29,15 @@ var allInterfaces = []interfaces.Interface{
 	&LocationControlInterface{},
 	&LocationObserveInterface{},
 	&NetworkManagerInterface{},
+	&PublisherDataInterface{},
+	&PasswordManagerServiceInterface{},
 	NewFirewallControlInterface(),
 	NewGsettingsInterface(),
 	NewHomeInterface(),
 	NewLocaleControlInterface(),
 	NewLogObserveInterface(),
 	NewMountObserveInterface(),
+	NewHostTmpInterface(),
 	NewNetworkInterface(),
 	NewNetworkBindInterface(),
 	NewNetworkControlInterface(),
@@ -47,6 +50,7 @@ var allInterfaces = []interfaces.Interface{
 	NewX11Interface(),
 	NewOpenglInterface(),
 	NewPulseAudioInterface(),
+	NewCupsInterface(),
 	NewCupsControlInterface(),
 }
 
@@ -54,3 +58,14 @@ var allInterfaces = []interfaces.Interface{
 func Interfaces() []interfaces.Interface {
 	return allInterfaces
 }
+
+// allDeprecations declares the deprecated built-in interfaces and
+// attributes. Snaps using them are warned when installed or connected,
+// and entries should stay for at least two releases before support is
+// removed, so publishers have time to migrate.
+var allDeprecations = []*interfaces.Deprecation{}
+
+// Deprecations returns the deprecated built-in interfaces and attributes.
+func Deprecations() []*interfaces.Deprecation {
+	return allDeprecations
+}
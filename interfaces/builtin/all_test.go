@@ -36,12 +36,15 @@ func (s *AllSuite) TestInterfaces(c *C) {
 	c.Check(all, Contains, &builtin.BluezInterface{})
 	c.Check(all, Contains, &builtin.LocationControlInterface{})
 	c.Check(all, Contains, &builtin.LocationObserveInterface{})
+	c.Check(all, Contains, &builtin.PublisherDataInterface{})
+	c.Check(all, Contains, &builtin.PasswordManagerServiceInterface{})
 	c.Check(all, DeepContains, builtin.NewFirewallControlInterface())
 	c.Check(all, DeepContains, builtin.NewGsettingsInterface())
 	c.Check(all, DeepContains, builtin.NewHomeInterface())
 	c.Check(all, DeepContains, builtin.NewLocaleControlInterface())
 	c.Check(all, DeepContains, builtin.NewLogObserveInterface())
 	c.Check(all, DeepContains, builtin.NewMountObserveInterface())
+	c.Check(all, DeepContains, builtin.NewHostTmpInterface())
 	c.Check(all, DeepContains, builtin.NewNetworkInterface())
 	c.Check(all, DeepContains, builtin.NewNetworkBindInterface())
 	c.Check(all, DeepContains, builtin.NewNetworkControlInterface())
@@ -54,5 +57,16 @@ func (s *AllSuite) TestInterfaces(c *C) {
 	c.Check(all, DeepContains, builtin.NewX11Interface())
 	c.Check(all, DeepContains, builtin.NewOpenglInterface())
 	c.Check(all, DeepContains, builtin.NewPulseAudioInterface())
+	c.Check(all, DeepContains, builtin.NewCupsInterface())
 	c.Check(all, DeepContains, builtin.NewCupsControlInterface())
 }
+
+func (s *AllSuite) TestDeprecationsAreOfKnownInterfaces(c *C) {
+	known := make(map[string]bool)
+	for _, iface := range builtin.Interfaces() {
+		known[iface.Name()] = true
+	}
+	for _, d := range builtin.Deprecations() {
+		c.Check(known[d.Interface], Equals, true, Commentf("%s", d.Subject()))
+	}
+}
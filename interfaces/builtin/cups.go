@@ -0,0 +1,58 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin
+
+import "github.com/snapcore/snapd/interfaces"
+
+// cupsConnectedPlugAppArmor allows printing through the cups socket without
+// granting access to the local authentication certificates in
+// /run/cups/certs. Without them cupsd cannot authenticate the client as a
+// local administrator and refuses the operations its policy reserves for
+// the @SYSTEM group (adding, modifying or deleting printers and classes,
+// changing server settings), while print jobs are accepted as usual. The
+// cups-pk-helper D-Bus service, which performs the same operations on
+// behalf of desktop clients, is not reachable either. Apps that need to
+// administer printers should use the cups-control interface instead.
+const cupsConnectedPlugAppArmor = `
+# Description: Can print documents and query printers and jobs through the
+# cups socket. Administrative operations are refused by cupsd since the local
+# authentication certificates are not accessible.
+
+/etc/cups/client.conf r,
+@{HOME}/.cups/client.conf r,
+@{HOME}/.cups/lpoptions r,
+/etc/cups/lpoptions r,
+/{,var/}run/cups/cups.sock rw,
+`
+
+const cupsConnectedPlugSecComp = `
+setsockopt
+`
+
+// NewCupsInterface returns a new "cups" interface.
+func NewCupsInterface() interfaces.Interface {
+	return &commonInterface{
+		name:                  "cups",
+		connectedPlugAppArmor: cupsConnectedPlugAppArmor,
+		connectedPlugSecComp:  cupsConnectedPlugSecComp,
+		reservedForOS:         true,
+		autoConnect:           true,
+	}
+}
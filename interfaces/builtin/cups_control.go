@@ -32,7 +32,7 @@ const cupsControlConnectedPlugSecComp = `
 setsockopt
 `
 
-// NewCupsControlInterface returns a new "cups" interface.
+// NewCupsControlInterface returns a new "cups-control" interface.
 func NewCupsControlInterface() interfaces.Interface {
 	return &commonInterface{
 		name: "cups-control",
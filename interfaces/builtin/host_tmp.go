@@ -0,0 +1,46 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin
+
+import (
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/snap"
+)
+
+// The default template already allows all of /tmp, which is private to
+// each snap unless it has a host-tmp plug: the mount profile of the snap
+// then binds the /tmp of the host over it, see wrappers.AddSnapMounts.
+const hostTmpConnectedPlugAppArmor = `
+# Description: Can share /tmp with the host and the other snaps using it,
+# instead of getting a private one. This is restricted because temporary
+# files of the host and of those snaps become readable and writable.
+# Usage: reserved
+/tmp/   r,
+/tmp/** mrwlk,
+`
+
+// NewHostTmpInterface returns a new "host-tmp" interface.
+func NewHostTmpInterface() interfaces.Interface {
+	return &commonInterface{
+		name:                  snap.HostTmpInterface,
+		connectedPlugAppArmor: hostTmpConnectedPlugAppArmor,
+		reservedForOS:         true,
+	}
+}
@@ -19,7 +19,10 @@
 
 package builtin
 
-import "github.com/snapcore/snapd/interfaces"
+import (
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/snap"
+)
 
 // http://bazaar.launchpad.net/~ubuntu-security/ubuntu-core-security/trunk/view/head:/data/apparmor/policygroups/ubuntu-core/16.04/network
 const networkConnectedPlugAppArmor = `
@@ -61,13 +64,28 @@ socket
 socketcall
 `
 
+// networkInterface is the network interface, whose plugs can choose how
+// the names are resolved for the snap with the dns and dns-link attributes.
+type networkInterface struct {
+	commonInterface
+}
+
+// SanitizePlug checks the name resolution attributes of the plug.
+func (iface *networkInterface) SanitizePlug(plug *interfaces.Plug) error {
+	if err := iface.commonInterface.SanitizePlug(plug); err != nil {
+		return err
+	}
+	_, err := snap.PlugDNS(plug.PlugInfo)
+	return err
+}
+
 // NewNetworkInterface returns a new "network" interface.
 func NewNetworkInterface() interfaces.Interface {
-	return &commonInterface{
+	return &networkInterface{commonInterface{
 		name: "network",
 		connectedPlugAppArmor: networkConnectedPlugAppArmor,
 		connectedPlugSecComp:  networkConnectedPlugSecComp,
 		reservedForOS:         true,
 		autoConnect:           true,
-	}
+	}}
 }
@@ -71,6 +71,20 @@ func (s *NetworkInterfaceSuite) TestSanitizePlug(c *C) {
 	c.Assert(err, IsNil)
 }
 
+func (s *NetworkInterfaceSuite) TestSanitizePlugDNS(c *C) {
+	plug := &interfaces.Plug{
+		PlugInfo: &snap.PlugInfo{
+			Snap:      &snap.Info{SuggestedName: "other"},
+			Name:      "network",
+			Interface: "network",
+			Attrs:     map[string]interface{}{"dns": "link", "dns-link": "eth1"},
+		},
+	}
+	c.Assert(s.iface.SanitizePlug(plug), IsNil)
+	plug.Attrs = map[string]interface{}{"dns": "link"}
+	c.Assert(s.iface.SanitizePlug(plug), ErrorMatches, `dns-link attribute is required when dns is "link"`)
+}
+
 func (s *NetworkInterfaceSuite) TestSanitizeIncorrectInterface(c *C) {
 	c.Assert(func() { s.iface.SanitizeSlot(&interfaces.Slot{SlotInfo: &snap.SlotInfo{Interface: "other"}}) },
 		PanicMatches, `slot is not of interface "network"`)
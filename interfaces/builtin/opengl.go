@@ -27,8 +27,13 @@ const openglConnectedPlugAppArmor = `
 # Description: Can access opengl.
 # Usage: reserved
 
-  # specific gl libs
-  /var/lib/snapd/lib/gl/** rm,
+  # specific gl libs, linked from the host by snapd
+  /var/lib/snapd/lib/gl{,32}/** rm,
+  /usr/lib/@{multiarch}/libnvidia-*.so* rm,
+  /usr/lib/@{multiarch}/lib{GLX,EGL,GLESv1_CM,GLESv2}_nvidia.so* rm,
+  /usr/lib/@{multiarch}/lib{cuda,nvcuvid}.so* rm,
+  /usr/lib{,32}/nvidia-[0-9]*/** rm,
+  /usr/lib/@{multiarch}/dri/** rm,
 
   # nvidia
   @{PROC}/driver/nvidia/params r,
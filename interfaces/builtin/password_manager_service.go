@@ -0,0 +1,190 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/snap"
+)
+
+// PasswordManagerServiceInterface allows snaps to keep their credentials in
+// the secrets service of the session (org.freedesktop.secrets, as provided
+// by gnome-keyring and others).
+//
+// Each snap is confined to a collection of its own, created with the label
+// "snap-<name>". The methods of the service that work across collections,
+// such as searching items or reading secrets in bulk, are not reachable.
+type PasswordManagerServiceInterface struct{}
+
+// String returns the same value as Name().
+func (iface *PasswordManagerServiceInterface) String() string {
+	return iface.Name()
+}
+
+// Name returns the name of the password-manager-service interface.
+func (iface *PasswordManagerServiceInterface) Name() string {
+	return "password-manager-service"
+}
+
+// SanitizeSlot checks and possibly modifies a slot.
+// Only the operating system snap may provide the secrets service.
+func (iface *PasswordManagerServiceInterface) SanitizeSlot(slot *interfaces.Slot) error {
+	if iface.Name() != slot.Interface {
+		panic(fmt.Sprintf("slot is not of interface %q", iface))
+	}
+	if slot.Snap.Type != snap.TypeOS {
+		return fmt.Errorf("%s slots are reserved for the operating system snap", iface.Name())
+	}
+	return nil
+}
+
+// SanitizePlug checks and possibly modifies a plug.
+func (iface *PasswordManagerServiceInterface) SanitizePlug(plug *interfaces.Plug) error {
+	if iface.Name() != plug.Interface {
+		panic(fmt.Sprintf("plug is not of interface %q", iface))
+	}
+	// NOTE: currently we don't check anything on the plug side.
+	return nil
+}
+
+// secretsCollectionPath returns the D-Bus object path element of the
+// collection labelled "snap-<name>". Secret services derive it from the
+// label, escaping anything but ASCII letters and digits as _XX.
+func secretsCollectionPath(name string) string {
+	var buf bytes.Buffer
+	for _, c := range []byte("snap-" + name) {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+			buf.WriteByte(c)
+		default:
+			fmt.Fprintf(&buf, "_%02x", c)
+		}
+	}
+	return buf.String()
+}
+
+const passwordManagerServiceConnectedPlugAppArmor = `
+# Description: Can store and retrieve credentials in a collection of the
+# session secrets service reserved to this snap.
+
+#include <abstractions/dbus-session-strict>
+
+# Sessions for transferring secrets, and creating or unlocking collections.
+# Unlock() and Lock() go through a prompt of the service.
+dbus (send)
+    bus=session
+    path=/org/freedesktop/secrets
+    interface=org.freedesktop.Secret.Service
+    member={OpenSession,CreateCollection,Unlock,Lock}
+    peer=(label=unconfined),
+
+dbus (send)
+    bus=session
+    path=/org/freedesktop/secrets/session/*
+    interface=org.freedesktop.Secret.Session
+    member=Close
+    peer=(label=unconfined),
+
+dbus (send)
+    bus=session
+    path=/org/freedesktop/secrets/prompt/*
+    interface=org.freedesktop.Secret.Prompt
+    member={Prompt,Dismiss}
+    peer=(label=unconfined),
+
+dbus (receive)
+    bus=session
+    path=/org/freedesktop/secrets/prompt/*
+    interface=org.freedesktop.Secret.Prompt
+    member=Completed
+    peer=(label=unconfined),
+
+# The collection of the snap and its items
+dbus (receive, send)
+    bus=session
+    path=/org/freedesktop/secrets/collection/###COLLECTION###{,/**}
+    interface=org.freedesktop.Secret.{Collection,Item}
+    peer=(label=unconfined),
+
+dbus (receive, send)
+    bus=session
+    path=/org/freedesktop/secrets/collection/###COLLECTION###{,/**}
+    interface=org.freedesktop.DBus.Properties
+    peer=(label=unconfined),
+`
+
+// ConnectedPlugSnippet returns security snippet specific to a given connection between the password-manager-service plug and some slot.
+// Applications associated with the plug gain access to the collection of their snap.
+func (iface *PasswordManagerServiceInterface) ConnectedPlugSnippet(plug *interfaces.Plug, slot *interfaces.Slot, securitySystem interfaces.SecuritySystem) ([]byte, error) {
+	switch securitySystem {
+	case interfaces.SecurityAppArmor:
+		collection := []byte(secretsCollectionPath(plug.Snap.Name()))
+		return bytes.Replace([]byte(passwordManagerServiceConnectedPlugAppArmor), []byte("###COLLECTION###"), collection, -1), nil
+	case interfaces.SecuritySecComp, interfaces.SecurityDBus, interfaces.SecurityUDev:
+		return nil, nil
+	default:
+		return nil, interfaces.ErrUnknownSecurity
+	}
+}
+
+// PermanentPlugSnippet returns the configuration snippet required to use a password-manager-service interface.
+// Plugs don't get any permanent security snippets.
+func (iface *PasswordManagerServiceInterface) PermanentPlugSnippet(plug *interfaces.Plug, securitySystem interfaces.SecuritySystem) ([]byte, error) {
+	switch securitySystem {
+	case interfaces.SecurityAppArmor, interfaces.SecuritySecComp, interfaces.SecurityDBus, interfaces.SecurityUDev:
+		return nil, nil
+	default:
+		return nil, interfaces.ErrUnknownSecurity
+	}
+}
+
+// ConnectedSlotSnippet returns security snippet specific to a given connection between the password-manager-service slot and some plug.
+// The secrets service runs unconfined, so the slot doesn't need any extra permissions.
+func (iface *PasswordManagerServiceInterface) ConnectedSlotSnippet(plug *interfaces.Plug, slot *interfaces.Slot, securitySystem interfaces.SecuritySystem) ([]byte, error) {
+	switch securitySystem {
+	case interfaces.SecurityAppArmor, interfaces.SecuritySecComp, interfaces.SecurityDBus, interfaces.SecurityUDev:
+		return nil, nil
+	default:
+		return nil, interfaces.ErrUnknownSecurity
+	}
+}
+
+// PermanentSlotSnippet returns security snippet permanently granted to password-manager-service slots.
+// The secrets service runs unconfined, so the slot doesn't need any extra permissions.
+func (iface *PasswordManagerServiceInterface) PermanentSlotSnippet(slot *interfaces.Slot, securitySystem interfaces.SecuritySystem) ([]byte, error) {
+	switch securitySystem {
+	case interfaces.SecurityAppArmor, interfaces.SecuritySecComp, interfaces.SecurityDBus, interfaces.SecurityUDev:
+		return nil, nil
+	default:
+		return nil, interfaces.ErrUnknownSecurity
+	}
+}
+
+// AutoConnect returns true if plugs and slots should be implicitly
+// auto-connected when an unambiguous connection candidate is available.
+//
+// This interface auto-connects, as each snap only reaches its own
+// collection.
+func (iface *PasswordManagerServiceInterface) AutoConnect() bool {
+	return true
+}
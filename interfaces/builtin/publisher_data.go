@@ -0,0 +1,172 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/snap"
+)
+
+// PublisherDataInterface allows snaps of the same publisher to share data.
+//
+// The slot side shares a selection of its own read-only directories with
+// the plug side. Both sides get read-write access to a data directory
+// common to all the snaps of the publisher that use the interface.
+type PublisherDataInterface struct{}
+
+// String returns the same value as Name().
+func (iface *PublisherDataInterface) String() string {
+	return iface.Name()
+}
+
+// Name returns the name of the publisher-data interface.
+func (iface *PublisherDataInterface) Name() string {
+	return snap.PublisherDataInterface
+}
+
+var publisherDataPathPattern = regexp.MustCompile("^[a-zA-Z0-9_+-]+(\\.[a-zA-Z0-9_+-]+)*(/[a-zA-Z0-9_+-]+(\\.[a-zA-Z0-9_+-]+)*)*$")
+
+// publisher names are store account names, anything else is not trusted
+// to go into security policy
+var publisherNamePattern = regexp.MustCompile("^[a-z0-9][a-z0-9-]*$")
+
+// SanitizeSlot checks and possibly modifies a slot.
+// The optional "read" attribute lists the directories, relative to the
+// root of the snap, shared with the other snaps of the publisher.
+func (iface *PublisherDataInterface) SanitizeSlot(slot *interfaces.Slot) error {
+	if iface.Name() != slot.Interface {
+		panic(fmt.Sprintf("slot is not of interface %q", iface))
+	}
+	read, ok := slot.Attrs["read"]
+	if !ok {
+		return nil
+	}
+	paths, ok := read.([]interface{})
+	if !ok {
+		return fmt.Errorf("publisher-data read attribute must be a list of paths")
+	}
+	for _, p := range paths {
+		path, ok := p.(string)
+		if !ok || !publisherDataPathPattern.MatchString(path) || filepath.Clean(path) != path {
+			return fmt.Errorf("publisher-data read paths must be relative to the snap, got %v", p)
+		}
+	}
+	return nil
+}
+
+// SanitizePlug checks and possibly modifies a plug.
+func (iface *PublisherDataInterface) SanitizePlug(plug *interfaces.Plug) error {
+	if iface.Name() != plug.Interface {
+		panic(fmt.Sprintf("plug is not of interface %q", iface))
+	}
+	// NOTE: currently we don't check anything on the plug side.
+	return nil
+}
+
+// publisherDataSnippet grants read-write access to the data directory of
+// the publisher of the given snap.
+func publisherDataSnippet(info *snap.Info) []byte {
+	if !publisherNamePattern.MatchString(info.Developer) {
+		return nil
+	}
+	return []byte(fmt.Sprintf(`
+# Description: data common to the snaps of publisher %[1]s
+/var/snap/.publisher/%[1]s/ rw,
+/var/snap/.publisher/%[1]s/** rwk,
+`, info.Developer))
+}
+
+// ConnectedPlugSnippet returns security snippet specific to a given connection between the publisher-data plug and some slot.
+// Applications associated with the plug gain read-only access to the directories shared by the slot,
+// as long as both snaps come from the same publisher.
+func (iface *PublisherDataInterface) ConnectedPlugSnippet(plug *interfaces.Plug, slot *interfaces.Slot, securitySystem interfaces.SecuritySystem) ([]byte, error) {
+	switch securitySystem {
+	case interfaces.SecurityAppArmor:
+		if plug.Snap.Developer != slot.Snap.Developer || !publisherNamePattern.MatchString(slot.Snap.Developer) {
+			return nil, nil
+		}
+		paths, _ := slot.Attrs["read"].([]interface{})
+		if len(paths) == 0 {
+			return nil, nil
+		}
+		var buf bytes.Buffer
+		fmt.Fprintf(&buf, "\n# Description: data shared by snap %s\n", slot.Snap.Name())
+		for _, path := range paths {
+			fmt.Fprintf(&buf, "/snap/%s/*/%s/ r,\n", slot.Snap.Name(), path)
+			fmt.Fprintf(&buf, "/snap/%s/*/%s/** mr,\n", slot.Snap.Name(), path)
+		}
+		return buf.Bytes(), nil
+	case interfaces.SecuritySecComp, interfaces.SecurityDBus, interfaces.SecurityUDev:
+		return nil, nil
+	default:
+		return nil, interfaces.ErrUnknownSecurity
+	}
+}
+
+// PermanentPlugSnippet returns the configuration snippet required to use a publisher-data interface.
+// Applications associated with the plug gain read-write access to the data directory of their publisher.
+func (iface *PublisherDataInterface) PermanentPlugSnippet(plug *interfaces.Plug, securitySystem interfaces.SecuritySystem) ([]byte, error) {
+	switch securitySystem {
+	case interfaces.SecurityAppArmor:
+		return publisherDataSnippet(plug.Snap), nil
+	case interfaces.SecuritySecComp, interfaces.SecurityDBus, interfaces.SecurityUDev:
+		return nil, nil
+	default:
+		return nil, interfaces.ErrUnknownSecurity
+	}
+}
+
+// ConnectedSlotSnippet returns security snippet specific to a given connection between the publisher-data slot and some plug.
+// Applications associated with the slot don't gain any extra permissions.
+func (iface *PublisherDataInterface) ConnectedSlotSnippet(plug *interfaces.Plug, slot *interfaces.Slot, securitySystem interfaces.SecuritySystem) ([]byte, error) {
+	switch securitySystem {
+	case interfaces.SecurityAppArmor, interfaces.SecuritySecComp, interfaces.SecurityDBus, interfaces.SecurityUDev:
+		return nil, nil
+	default:
+		return nil, interfaces.ErrUnknownSecurity
+	}
+}
+
+// PermanentSlotSnippet returns security snippet permanently granted to publisher-data slots.
+// Applications associated with the slot gain read-write access to the data directory of their publisher.
+func (iface *PublisherDataInterface) PermanentSlotSnippet(slot *interfaces.Slot, securitySystem interfaces.SecuritySystem) ([]byte, error) {
+	switch securitySystem {
+	case interfaces.SecurityAppArmor:
+		return publisherDataSnippet(slot.Snap), nil
+	case interfaces.SecuritySecComp, interfaces.SecurityDBus, interfaces.SecurityUDev:
+		return nil, nil
+	default:
+		return nil, interfaces.ErrUnknownSecurity
+	}
+}
+
+// AutoConnect returns true if plugs and slots should be implicitly
+// auto-connected when an unambiguous connection candidate is available.
+//
+// This interface auto-connects, the connections being limited to snaps of
+// the same publisher.
+func (iface *PublisherDataInterface) AutoConnect() bool {
+	return true
+}
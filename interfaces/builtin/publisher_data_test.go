@@ -0,0 +1,169 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/snap"
+)
+
+type PublisherDataInterfaceSuite struct {
+	iface       interfaces.Interface
+	slot        *interfaces.Slot
+	badPathSlot *interfaces.Slot
+	plug        *interfaces.Plug
+	otherPlug   *interfaces.Plug
+}
+
+var _ = Suite(&PublisherDataInterfaceSuite{
+	iface: &builtin.PublisherDataInterface{},
+})
+
+func (s *PublisherDataInterfaceSuite) SetUpTest(c *C) {
+	provider, err := snap.InfoFromSnapYaml([]byte(`
+name: provider
+slots:
+    shared:
+        interface: publisher-data
+        read: [assets, share/fonts]
+    bad-path:
+        interface: publisher-data
+        read: [../etc]
+`))
+	c.Assert(err, IsNil)
+	provider.Developer = "acme"
+	consumer, err := snap.InfoFromSnapYaml([]byte(`
+name: consumer
+plugs:
+    publisher-data: null
+`))
+	c.Assert(err, IsNil)
+	consumer.Developer = "acme"
+	other, err := snap.InfoFromSnapYaml([]byte(`
+name: other
+plugs:
+    publisher-data: null
+`))
+	c.Assert(err, IsNil)
+	other.Developer = "someone-else"
+
+	s.slot = &interfaces.Slot{SlotInfo: provider.Slots["shared"]}
+	s.badPathSlot = &interfaces.Slot{SlotInfo: provider.Slots["bad-path"]}
+	s.plug = &interfaces.Plug{PlugInfo: consumer.Plugs["publisher-data"]}
+	s.otherPlug = &interfaces.Plug{PlugInfo: other.Plugs["publisher-data"]}
+}
+
+func (s *PublisherDataInterfaceSuite) TestName(c *C) {
+	c.Assert(s.iface.Name(), Equals, "publisher-data")
+}
+
+func (s *PublisherDataInterfaceSuite) TestSanitizeSlot(c *C) {
+	c.Assert(s.iface.SanitizeSlot(s.slot), IsNil)
+	c.Assert(s.iface.SanitizeSlot(s.badPathSlot), ErrorMatches,
+		`publisher-data read paths must be relative to the snap, got ../etc`)
+	for _, path := range []interface{}{"/etc", "a/../b", "a//b", "a b", "", 42} {
+		slot := &interfaces.Slot{SlotInfo: &snap.SlotInfo{
+			Snap:      s.slot.Snap,
+			Name:      "shared",
+			Interface: "publisher-data",
+			Attrs:     map[string]interface{}{"read": []interface{}{path}},
+		}}
+		c.Check(s.iface.SanitizeSlot(slot), NotNil, Commentf("%v", path))
+	}
+	slot := &interfaces.Slot{SlotInfo: &snap.SlotInfo{
+		Snap:      s.slot.Snap,
+		Name:      "shared",
+		Interface: "publisher-data",
+		Attrs:     map[string]interface{}{"read": "assets"},
+	}}
+	c.Assert(s.iface.SanitizeSlot(slot), ErrorMatches, "publisher-data read attribute must be a list of paths")
+}
+
+func (s *PublisherDataInterfaceSuite) TestSanitizePlug(c *C) {
+	c.Assert(s.iface.SanitizePlug(s.plug), IsNil)
+}
+
+func (s *PublisherDataInterfaceSuite) TestConnectedPlugSnippet(c *C) {
+	snippet, err := s.iface.ConnectedPlugSnippet(s.plug, s.slot, interfaces.SecurityAppArmor)
+	c.Assert(err, IsNil)
+	c.Check(string(snippet), Equals, `
+# Description: data shared by snap provider
+/snap/provider/*/assets/ r,
+/snap/provider/*/assets/** mr,
+/snap/provider/*/share/fonts/ r,
+/snap/provider/*/share/fonts/** mr,
+`)
+
+	// nothing is shared with snaps of other publishers
+	snippet, err = s.iface.ConnectedPlugSnippet(s.otherPlug, s.slot, interfaces.SecurityAppArmor)
+	c.Assert(err, IsNil)
+	c.Check(snippet, IsNil)
+}
+
+func (s *PublisherDataInterfaceSuite) TestPermanentSnippets(c *C) {
+	expected := `
+# Description: data common to the snaps of publisher acme
+/var/snap/.publisher/acme/ rw,
+/var/snap/.publisher/acme/** rwk,
+`
+	snippet, err := s.iface.PermanentPlugSnippet(s.plug, interfaces.SecurityAppArmor)
+	c.Assert(err, IsNil)
+	c.Check(string(snippet), Equals, expected)
+	snippet, err = s.iface.PermanentSlotSnippet(s.slot, interfaces.SecurityAppArmor)
+	c.Assert(err, IsNil)
+	c.Check(string(snippet), Equals, expected)
+
+	// sideloaded snaps have no publisher
+	s.plug.Snap.Developer = ""
+	snippet, err = s.iface.PermanentPlugSnippet(s.plug, interfaces.SecurityAppArmor)
+	c.Assert(err, IsNil)
+	c.Check(snippet, IsNil)
+}
+
+func (s *PublisherDataInterfaceSuite) TestUnexpectedSecuritySystems(c *C) {
+	for _, system := range []interfaces.SecuritySystem{interfaces.SecuritySecComp, interfaces.SecurityDBus, interfaces.SecurityUDev} {
+		snippet, err := s.iface.ConnectedPlugSnippet(s.plug, s.slot, system)
+		c.Assert(err, IsNil)
+		c.Assert(snippet, IsNil)
+		snippet, err = s.iface.PermanentPlugSnippet(s.plug, system)
+		c.Assert(err, IsNil)
+		c.Assert(snippet, IsNil)
+		snippet, err = s.iface.ConnectedSlotSnippet(s.plug, s.slot, system)
+		c.Assert(err, IsNil)
+		c.Assert(snippet, IsNil)
+		snippet, err = s.iface.PermanentSlotSnippet(s.slot, system)
+		c.Assert(err, IsNil)
+		c.Assert(snippet, IsNil)
+	}
+}
+
+func (s *PublisherDataInterfaceSuite) TestUnknownSecuritySystem(c *C) {
+	_, err := s.iface.ConnectedPlugSnippet(s.plug, s.slot, "foo")
+	c.Assert(err, Equals, interfaces.ErrUnknownSecurity)
+	_, err = s.iface.PermanentSlotSnippet(s.slot, "foo")
+	c.Assert(err, Equals, interfaces.ErrUnknownSecurity)
+}
+
+func (s *PublisherDataInterfaceSuite) TestAutoConnect(c *C) {
+	c.Check(s.iface.AutoConnect(), Equals, true)
+}
@@ -0,0 +1,88 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package interfaces
+
+import (
+	"fmt"
+)
+
+// Deprecation marks an interface, or an attribute of its plugs and
+// slots, as deprecated, so that the snaps still using it can be warned
+// ahead of its removal.
+type Deprecation struct {
+	// Interface is the name of the deprecated interface.
+	Interface string
+	// Attribute, when set, limits the deprecation to the plugs and
+	// slots of the interface that set this attribute.
+	Attribute string
+	// Replacement is what should be used instead, if anything.
+	Replacement string
+	// Removal is the release after which support is expected to go
+	// away, if known.
+	Removal string
+}
+
+// Applies returns whether the deprecation applies to a plug or slot of
+// the given interface with the given attributes.
+func (d *Deprecation) Applies(iface string, attrs map[string]interface{}) bool {
+	if d.Interface != iface {
+		return false
+	}
+	if d.Attribute == "" {
+		return true
+	}
+	_, ok := attrs[d.Attribute]
+	return ok
+}
+
+// Subject returns what is deprecated, as in `interface "foo"` or
+// `attribute "bar" of interface "foo"`.
+func (d *Deprecation) Subject() string {
+	if d.Attribute == "" {
+		return fmt.Sprintf("interface %q", d.Interface)
+	}
+	return fmt.Sprintf("attribute %q of interface %q", d.Attribute, d.Interface)
+}
+
+// Message returns a description of the deprecation suitable for
+// warning users, including the replacement and the expected removal.
+func (d *Deprecation) Message() string {
+	msg := d.Subject() + " is deprecated"
+	if d.Removal != "" {
+		msg += fmt.Sprintf(" and will be removed after %s", d.Removal)
+	}
+	if d.Replacement != "" {
+		msg += fmt.Sprintf(", use %s instead", d.Replacement)
+	}
+	return msg
+}
+
+// FindDeprecations returns the deprecations among deprecations that
+// apply to a plug or slot of the given interface with the given
+// attributes.
+func FindDeprecations(deprecations []*Deprecation, iface string, attrs map[string]interface{}) []*Deprecation {
+	var found []*Deprecation
+	for _, d := range deprecations {
+		if d.Applies(iface, attrs) {
+			found = append(found, d)
+		}
+	}
+	return found
+}
@@ -0,0 +1,57 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package interfaces_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	. "github.com/snapcore/snapd/interfaces"
+)
+
+type DeprecationSuite struct{}
+
+var _ = Suite(&DeprecationSuite{})
+
+var (
+	ifaceDeprecation = &Deprecation{Interface: "old", Replacement: `the "new" interface`, Removal: "2.20"}
+	attrDeprecation  = &Deprecation{Interface: "other", Attribute: "legacy"}
+)
+
+func (s *DeprecationSuite) TestApplies(c *C) {
+	c.Check(ifaceDeprecation.Applies("old", nil), Equals, true)
+	c.Check(ifaceDeprecation.Applies("new", nil), Equals, false)
+	c.Check(attrDeprecation.Applies("other", nil), Equals, false)
+	c.Check(attrDeprecation.Applies("other", map[string]interface{}{"legacy": true}), Equals, true)
+	c.Check(attrDeprecation.Applies("old", map[string]interface{}{"legacy": true}), Equals, false)
+}
+
+func (s *DeprecationSuite) TestMessage(c *C) {
+	c.Check(ifaceDeprecation.Subject(), Equals, `interface "old"`)
+	c.Check(ifaceDeprecation.Message(), Equals, `interface "old" is deprecated and will be removed after 2.20, use the "new" interface instead`)
+	c.Check(attrDeprecation.Subject(), Equals, `attribute "legacy" of interface "other"`)
+	c.Check(attrDeprecation.Message(), Equals, `attribute "legacy" of interface "other" is deprecated`)
+}
+
+func (s *DeprecationSuite) TestFindDeprecations(c *C) {
+	deprecations := []*Deprecation{ifaceDeprecation, attrDeprecation}
+	c.Check(FindDeprecations(deprecations, "old", nil), DeepEquals, []*Deprecation{ifaceDeprecation})
+	c.Check(FindDeprecations(deprecations, "other", map[string]interface{}{"legacy": "x"}), DeepEquals, []*Deprecation{attrDeprecation})
+	c.Check(FindDeprecations(deprecations, "other", nil), HasLen, 0)
+}
@@ -22,6 +22,7 @@ package interfaces
 import (
 	"bytes"
 	"fmt"
+	"reflect"
 	"sort"
 	"strings"
 	"sync"
@@ -292,6 +293,12 @@ func (r *Repository) Connect(plugSnapName, plugName, slotSnapName, slotName stri
 		return nil
 	}
 	// Connect the plug
+	r.connect(plug, slot)
+	return nil
+}
+
+// connect connects a plug to a slot.
+func (r *Repository) connect(plug *Plug, slot *Slot) {
 	if r.slotPlugs[slot] == nil {
 		r.slotPlugs[slot] = make(map[*Plug]bool)
 	}
@@ -302,7 +309,6 @@ func (r *Repository) Connect(plugSnapName, plugName, slotSnapName, slotName stri
 	r.plugSlots[plug][slot] = true
 	slot.Connections = append(slot.Connections, PlugRef{plug.Snap.Name(), plug.Name})
 	plug.Connections = append(plug.Connections, SlotRef{slot.Snap.Name(), slot.Name})
-	return nil
 }
 
 // Disconnect disconnects the named plug from the slot of the given snap.
@@ -575,8 +581,28 @@ func (r *Repository) AddSnap(snapInfo *snap.Info) error {
 		return fmt.Errorf("cannot register interfaces for snap %q more than once", snapName)
 	}
 
-	bad := BadInterfacesError{
-		snap:   snapName,
+	plugs, slots, bad := r.sanitizeSnap(snapInfo)
+	if len(plugs) > 0 {
+		r.plugs[snapName] = plugs
+	}
+	if len(slots) > 0 {
+		r.slots[snapName] = slots
+	}
+
+	if len(bad.issues) > 0 {
+		return bad
+	}
+	return nil
+}
+
+// sanitizeSnap returns the plugs and slots declared by the given snap that
+// are valid according to the corresponding interfaces, along with
+// information about those that are not.
+func (r *Repository) sanitizeSnap(snapInfo *snap.Info) (map[string]*Plug, map[string]*Slot, *BadInterfacesError) {
+	plugs := make(map[string]*Plug)
+	slots := make(map[string]*Slot)
+	bad := &BadInterfacesError{
+		snap:   snapInfo.Name(),
 		issues: make(map[string]string),
 	}
 
@@ -591,10 +617,7 @@ func (r *Repository) AddSnap(snapInfo *snap.Info) error {
 			bad.issues[plugName] = err.Error()
 			continue
 		}
-		if r.plugs[snapName] == nil {
-			r.plugs[snapName] = make(map[string]*Plug)
-		}
-		r.plugs[snapName][plugName] = plug
+		plugs[plugName] = plug
 	}
 
 	for slotName, slotInfo := range snapInfo.Slots {
@@ -608,16 +631,146 @@ func (r *Repository) AddSnap(snapInfo *snap.Info) error {
 			bad.issues[slotName] = err.Error()
 			continue
 		}
-		if r.slots[snapName] == nil {
-			r.slots[snapName] = make(map[string]*Slot)
+		slots[slotName] = slot
+	}
+
+	return plugs, slots, bad
+}
+
+// UpdateSnap replaces the plugs and slots of a snap with those declared by
+// the given revision of it, keeping existing connections intact.
+//
+// This function can be used to implement snap refresh without breaking the
+// connections of the snap. A connection is kept as long as the new revision
+// still declares the plug or slot with the same interface, otherwise it is
+// dropped. If the snap is not present in the repository UpdateSnap behaves
+// like AddSnap.
+//
+// The return value is a sorted list of the names of other snaps whose
+// connections to the given snap were dropped or whose connected plugs or
+// slots changed attributes or apps. Only those need their security
+// regenerated; the given snap itself is never part of the list.
+//
+// As with AddSnap, plugs and slots that don't validate are not added and
+// information about those failures is returned to the caller.
+func (r *Repository) UpdateSnap(snapInfo *snap.Info) ([]string, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	snapName := snapInfo.Name()
+	plugs, slots, bad := r.sanitizeSnap(snapInfo)
+
+	// Map each old plug and slot of the snap to its replacement, if any.
+	newPlug := func(plug *Plug) *Plug {
+		if plug.Snap.Name() != snapName {
+			return plug
+		}
+		if p := plugs[plug.Name]; p != nil && p.Interface == plug.Interface {
+			return p
+		}
+		return nil
+	}
+	newSlot := func(slot *Slot) *Slot {
+		if slot.Snap.Name() != snapName {
+			return slot
+		}
+		if s := slots[slot.Name]; s != nil && s.Interface == slot.Interface {
+			return s
+		}
+		return nil
+	}
+
+	type connection struct {
+		plug *Plug
+		slot *Slot
+	}
+	var conns []connection
+	for _, plug := range r.plugs[snapName] {
+		for slot := range r.plugSlots[plug] {
+			conns = append(conns, connection{plug, slot})
+		}
+	}
+	for _, slot := range r.slots[snapName] {
+		for plug := range r.slotPlugs[slot] {
+			// connections within the snap were already collected above
+			if plug.Snap.Name() != snapName {
+				conns = append(conns, connection{plug, slot})
+			}
 		}
-		r.slots[snapName][slotName] = slot
 	}
 
+	affected := make(map[string]bool)
+	var kept []connection
+	for _, conn := range conns {
+		r.disconnect(conn.plug, conn.slot)
+		plug, slot := newPlug(conn.plug), newSlot(conn.slot)
+		if plug != nil && slot != nil {
+			kept = append(kept, connection{plug, slot})
+		}
+		switch {
+		case conn.plug.Snap.Name() != snapName:
+			if slot == nil || !sameSlot(conn.slot, slot) {
+				affected[conn.plug.Snap.Name()] = true
+			}
+		case conn.slot.Snap.Name() != snapName:
+			if plug == nil || !samePlug(conn.plug, plug) {
+				affected[conn.slot.Snap.Name()] = true
+			}
+		}
+	}
+
+	for _, plug := range r.plugs[snapName] {
+		delete(r.plugSlots, plug)
+	}
+	delete(r.plugs, snapName)
+	for _, slot := range r.slots[snapName] {
+		delete(r.slotPlugs, slot)
+	}
+	delete(r.slots, snapName)
+	if len(plugs) > 0 {
+		r.plugs[snapName] = plugs
+	}
+	if len(slots) > 0 {
+		r.slots[snapName] = slots
+	}
+	for _, conn := range kept {
+		r.connect(conn.plug, conn.slot)
+	}
+
+	result := make([]string, 0, len(affected))
+	for name := range affected {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+
 	if len(bad.issues) > 0 {
-		return &bad
+		return result, bad
 	}
-	return nil
+	return result, nil
+}
+
+// samePlug returns true if the two plugs look the same to the other side of
+// a connection.
+func samePlug(a, b *Plug) bool {
+	return reflect.DeepEqual(a.Attrs, b.Attrs) && sameApps(a.Apps, b.Apps)
+}
+
+// sameSlot returns true if the two slots look the same to the other side of
+// a connection.
+func sameSlot(a, b *Slot) bool {
+	return reflect.DeepEqual(a.Attrs, b.Attrs) && sameApps(a.Apps, b.Apps)
+}
+
+func sameApps(a, b map[string]*snap.AppInfo) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name := range a {
+		if b[name] == nil {
+			return false
+		}
+	}
+	return true
 }
 
 // RemoveSnap removes all the plugs and slots associated with a given snap.
@@ -1107,3 +1107,134 @@ func (s *DisconnectSnapSuite) TestCrossConnection(c *C) {
 		c.Check(affected, testutil.Contains, "s2")
 	}
 }
+
+// Tests for UpdateSnap
+
+type UpdateSnapSuite struct {
+	repo *Repository
+}
+
+var _ = Suite(&UpdateSnapSuite{})
+
+func (s *UpdateSnapSuite) SetUpTest(c *C) {
+	s.repo = NewRepository()
+	err := s.repo.AddInterface(&TestInterface{InterfaceName: "iface"})
+	c.Assert(err, IsNil)
+	err = s.repo.AddInterface(&TestInterface{InterfaceName: "other-iface"})
+	c.Assert(err, IsNil)
+
+	for _, yaml := range []string{testConsumerYaml, testProducerYaml} {
+		snapInfo, err := snap.InfoFromSnapYaml([]byte(yaml))
+		c.Assert(err, IsNil)
+		err = s.repo.AddSnap(snapInfo)
+		c.Assert(err, IsNil)
+	}
+	err = s.repo.Connect("consumer", "iface", "producer", "iface")
+	c.Assert(err, IsNil)
+}
+
+func (s *UpdateSnapSuite) updateSnap(c *C, yaml string) ([]string, error) {
+	snapInfo, err := snap.InfoFromSnapYaml([]byte(yaml))
+	c.Assert(err, IsNil)
+	return s.repo.UpdateSnap(snapInfo)
+}
+
+func (s *UpdateSnapSuite) TestUpdateSnapAddsNewSnap(c *C) {
+	affected, err := s.updateSnap(c, `
+name: new
+plugs:
+    iface:
+`)
+	c.Assert(err, IsNil)
+	c.Check(affected, HasLen, 0)
+	c.Check(s.repo.Plug("new", "iface"), NotNil)
+}
+
+func (s *UpdateSnapSuite) TestUpdateSnapKeepsUnchangedConnection(c *C) {
+	affected, err := s.updateSnap(c, testProducerYaml)
+	c.Assert(err, IsNil)
+	c.Check(affected, HasLen, 0)
+
+	plug := s.repo.Plug("consumer", "iface")
+	slot := s.repo.Slot("producer", "iface")
+	c.Check(plug.Connections, DeepEquals, []SlotRef{{Snap: "producer", Name: "iface"}})
+	c.Check(slot.Connections, DeepEquals, []PlugRef{{Snap: "consumer", Name: "iface"}})
+	// The connection now refers to the new slot.
+	err = s.repo.Disconnect("consumer", "iface", "producer", "iface")
+	c.Check(err, IsNil)
+}
+
+func (s *UpdateSnapSuite) TestUpdateSnapReportsChangedAttributes(c *C) {
+	affected, err := s.updateSnap(c, `
+name: producer
+slots:
+    iface:
+        path: /new/path
+apps:
+    app:
+        slots: [iface]
+`)
+	c.Assert(err, IsNil)
+	c.Check(affected, DeepEquals, []string{"consumer"})
+
+	slot := s.repo.Slot("producer", "iface")
+	c.Check(slot.Attrs, DeepEquals, map[string]interface{}{"path": "/new/path"})
+	c.Check(slot.Connections, DeepEquals, []PlugRef{{Snap: "consumer", Name: "iface"}})
+}
+
+func (s *UpdateSnapSuite) TestUpdateSnapReportsChangedApps(c *C) {
+	affected, err := s.updateSnap(c, `
+name: consumer
+apps:
+    app:
+        plugs: [iface]
+    app2:
+        plugs: [iface]
+`)
+	c.Assert(err, IsNil)
+	c.Check(affected, DeepEquals, []string{"producer"})
+	c.Check(s.repo.Plug("consumer", "iface").Connections, HasLen, 1)
+}
+
+func (s *UpdateSnapSuite) TestUpdateSnapDisconnectsRemovedSlot(c *C) {
+	affected, err := s.updateSnap(c, `
+name: producer
+slots:
+    other:
+        interface: other-iface
+`)
+	c.Assert(err, IsNil)
+	c.Check(affected, DeepEquals, []string{"consumer"})
+	c.Check(s.repo.Slot("producer", "iface"), IsNil)
+	c.Check(s.repo.Slot("producer", "other"), NotNil)
+	c.Check(s.repo.Plug("consumer", "iface").Connections, HasLen, 0)
+}
+
+func (s *UpdateSnapSuite) TestUpdateSnapDisconnectsSlotWithChangedInterface(c *C) {
+	affected, err := s.updateSnap(c, `
+name: producer
+slots:
+    iface:
+        interface: other-iface
+`)
+	c.Assert(err, IsNil)
+	c.Check(affected, DeepEquals, []string{"consumer"})
+	c.Check(s.repo.Slot("producer", "iface").Interface, Equals, "other-iface")
+	c.Check(s.repo.Plug("consumer", "iface").Connections, HasLen, 0)
+}
+
+func (s *UpdateSnapSuite) TestUpdateSnapReportsBadInterfaces(c *C) {
+	affected, err := s.updateSnap(c, `
+name: producer
+slots:
+    unknown:
+        interface: unknown-iface
+apps:
+    app:
+        slots: [iface]
+`)
+	c.Check(err, ErrorMatches, `snap "producer" has bad plugs or slots: unknown \(unknown interface\)`)
+	c.Check(affected, HasLen, 0)
+	c.Check(s.repo.Slot("producer", "unknown"), IsNil)
+	c.Check(s.repo.Slot("producer", "iface").Connections, HasLen, 1)
+}
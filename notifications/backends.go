@@ -0,0 +1,164 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package notifications
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// deliveryTimeout is how long delivering a notification to a backend
+// can take before it is given up on.
+var deliveryTimeout = 10 * time.Second
+
+// A Backend delivers notifications out of snapd, to the users of the
+// device or to the systems looking after it, e.g. for headless devices.
+type Backend interface {
+	Deliver(n *Notification) error
+}
+
+// NewBackend returns the backend of the given kind delivering to the
+// given target: desktop notifications, which take no target, a webhook
+// the notifications are posted to as JSON, given its http or https URL,
+// an MQTT topic they are published to as JSON, given as
+// mqtt[s]://[user:password@]host[:port]/topic, or a script they are
+// passed to, given its absolute path.
+func NewBackend(kind, target string) (Backend, error) {
+	switch kind {
+	case "desktop":
+		return desktopBackend{}, nil
+	case "webhook":
+		return NewWebhookBackend(target, "")
+	case "mqtt":
+		return newMQTTBackend(target)
+	case "script":
+		if !filepath.IsAbs(target) {
+			return nil, fmt.Errorf("invalid script path %q: not absolute", target)
+		}
+		return &scriptBackend{path: target}, nil
+	}
+	return nil, fmt.Errorf("unknown notification backend %q", kind)
+}
+
+// NewWebhookBackend returns a backend posting the notifications as JSON
+// to the given http or https URL, authenticating with the user and
+// password of the URL, if any, or else with the given bearer token, if
+// not empty.
+func NewWebhookBackend(target, token string) (Backend, error) {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, fmt.Errorf("invalid webhook URL %q", target)
+	}
+	return &webhookBackend{url: target, token: token}, nil
+}
+
+// Message returns the human readable message of the notification.
+func (n *Notification) Message() string {
+	msg, _ := n.Metadata["message"].(string)
+	if msg == "" {
+		return n.Type
+	}
+	return msg
+}
+
+// notifySend shows a desktop notification, mocked in the tests.
+var notifySend = func(summary, body string) error {
+	if output, err := exec.Command("notify-send", "--app-name=snapd", summary, body).CombinedOutput(); err != nil {
+		return fmt.Errorf("notify-send failed: %v (%s)", err, output)
+	}
+	return nil
+}
+
+type desktopBackend struct{}
+
+func (desktopBackend) Deliver(n *Notification) error {
+	if err := notifySend("snapd", n.Message()); err != nil {
+		return fmt.Errorf("cannot show desktop notification: %v", err)
+	}
+	return nil
+}
+
+type webhookBackend struct {
+	url   string
+	token string
+}
+
+func (b *webhookBackend) Deliver(n *Notification) error {
+	data, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", b.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if req.URL.User == nil && b.token != "" {
+		req.Header.Set("Authorization", "Bearer "+b.token)
+	}
+	client := &http.Client{Timeout: deliveryTimeout}
+	rsp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot post notification: %v", err)
+	}
+	rsp.Body.Close()
+	if rsp.StatusCode/100 != 2 {
+		return fmt.Errorf("cannot post notification: got unexpected HTTP status code %d from %s", rsp.StatusCode, b.url)
+	}
+	return nil
+}
+
+// scriptBackend runs a script for each notification, with the type and
+// the message of the notification as arguments and the notification
+// as JSON on its standard input, e.g. to drive the LEDs of an appliance.
+type scriptBackend struct {
+	path string
+}
+
+func (b *scriptBackend) Deliver(n *Notification) error {
+	data, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	var output bytes.Buffer
+	cmd := exec.Command(b.path, n.Type, n.Message())
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("cannot run notification script: %v", err)
+	}
+	timer := time.AfterFunc(deliveryTimeout, func() {
+		cmd.Process.Kill()
+	})
+	err = cmd.Wait()
+	timer.Stop()
+	if err != nil {
+		return fmt.Errorf("notification script %s failed: %v (%s)", b.path, err, strings.TrimSpace(output.String()))
+	}
+	return nil
+}
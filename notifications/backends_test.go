@@ -0,0 +1,233 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package notifications
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+type BackendsSuite struct {
+	n *Notification
+}
+
+var _ = Suite(&BackendsSuite{})
+
+func (s *BackendsSuite) SetUpTest(c *C) {
+	s.n = &Notification{
+		Timestamp: 1475323200,
+		Type:      "reboot-required",
+		Metadata:  map[string]interface{}{"message": "Reboot required for pc-kernel."},
+	}
+}
+
+func (s *BackendsSuite) TestNewBackendErrors(c *C) {
+	for _, t := range []struct{ kind, target, err string }{
+		{"pager", "", `unknown notification backend "pager"`},
+		{"webhook", "ftp://example.com", `invalid webhook URL "ftp://example.com"`},
+		{"mqtt", "http://example.com/topic", `invalid MQTT URL .*: scheme is not mqtt or mqtts`},
+		{"mqtt", "mqtt://example.com", `invalid MQTT URL .*: missing or invalid topic`},
+		{"mqtt", "mqtt://example.com/devices/+", `invalid MQTT URL .*: missing or invalid topic`},
+		{"script", "led.sh", `invalid script path "led.sh": not absolute`},
+	} {
+		_, err := NewBackend(t.kind, t.target)
+		c.Check(err, ErrorMatches, t.err, Commentf("%s %s", t.kind, t.target))
+	}
+}
+
+func (s *BackendsSuite) TestMessage(c *C) {
+	c.Check(s.n.Message(), Equals, "Reboot required for pc-kernel.")
+	c.Check((&Notification{Type: "warning"}).Message(), Equals, "warning")
+}
+
+func (s *BackendsSuite) TestDesktop(c *C) {
+	var calls []string
+	old := notifySend
+	notifySend = func(summary, body string) error {
+		calls = append(calls, summary+": "+body)
+		return nil
+	}
+	defer func() { notifySend = old }()
+
+	b, err := NewBackend("desktop", "")
+	c.Assert(err, IsNil)
+	c.Assert(b.Deliver(s.n), IsNil)
+	c.Check(calls, DeepEquals, []string{"snapd: Reboot required for pc-kernel."})
+}
+
+func (s *BackendsSuite) TestWebhook(c *C) {
+	var posted Notification
+	status := 200
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "POST")
+		c.Check(r.Header.Get("Content-Type"), Equals, "application/json")
+		c.Check(json.NewDecoder(r.Body).Decode(&posted), IsNil)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	b, err := NewBackend("webhook", server.URL)
+	c.Assert(err, IsNil)
+	c.Assert(b.Deliver(s.n), IsNil)
+	c.Check(&posted, DeepEquals, s.n)
+
+	status = 500
+	c.Check(b.Deliver(s.n), ErrorMatches, "cannot post notification: got unexpected HTTP status code 500 from .*")
+}
+
+func (s *BackendsSuite) TestWebhookAuthentication(c *C) {
+	var auth []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = append(auth, r.Header.Get("Authorization"))
+	}))
+	defer server.Close()
+
+	b, err := NewWebhookBackend(server.URL, "s3kr1t")
+	c.Assert(err, IsNil)
+	c.Assert(b.Deliver(s.n), IsNil)
+
+	b, err = NewWebhookBackend(strings.Replace(server.URL, "http://", "http://fleet:pass@", 1), "s3kr1t")
+	c.Assert(err, IsNil)
+	c.Assert(b.Deliver(s.n), IsNil)
+
+	c.Check(auth, DeepEquals, []string{"Bearer s3kr1t", "Basic ZmxlZXQ6cGFzcw=="})
+}
+
+func (s *BackendsSuite) TestScript(c *C) {
+	dir := c.MkDir()
+	script := filepath.Join(dir, "led.sh")
+	out := filepath.Join(dir, "out")
+	c.Assert(ioutil.WriteFile(script, []byte(fmt.Sprintf("#!/bin/sh\necho \"$1|$2\" > %s\ncat >> %s\n", out, out)), 0755), IsNil)
+
+	b, err := NewBackend("script", script)
+	c.Assert(err, IsNil)
+	c.Assert(b.Deliver(s.n), IsNil)
+
+	data, err := ioutil.ReadFile(out)
+	c.Assert(err, IsNil)
+	lines := bytes.SplitN(data, []byte("\n"), 2)
+	c.Check(string(lines[0]), Equals, "reboot-required|Reboot required for pc-kernel.")
+	var passed Notification
+	c.Assert(json.Unmarshal(lines[1], &passed), IsNil)
+	c.Check(&passed, DeepEquals, s.n)
+}
+
+func (s *BackendsSuite) TestScriptFails(c *C) {
+	script := filepath.Join(c.MkDir(), "led.sh")
+	c.Assert(ioutil.WriteFile(script, []byte("#!/bin/sh\necho no led\nexit 1\n"), 0755), IsNil)
+
+	b, err := NewBackend("script", script)
+	c.Assert(err, IsNil)
+	c.Check(b.Deliver(s.n), ErrorMatches, `notification script .*/led.sh failed: exit status 1 \(no led\)`)
+}
+
+// mqttBroker accepts a connection, acknowledges it with the given return
+// code and sends back the packets it got until the client disconnects.
+func mqttBroker(c *C, code byte) (addr string, packets chan []byte) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	packets = make(chan []byte, 3)
+	go func() {
+		defer l.Close()
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		defer close(packets)
+		for {
+			header := make([]byte, 2)
+			if _, err := io.ReadFull(conn, header); err != nil {
+				return
+			}
+			// the test packets are short enough for a one byte length
+			body := make([]byte, header[1])
+			if _, err := io.ReadFull(conn, body); err != nil {
+				return
+			}
+			packets <- append(header, body...)
+			switch header[0] {
+			case mqttConnect:
+				conn.Write([]byte{mqttConnack, 2, 0, code})
+			case mqttDisconnect:
+				return
+			}
+		}
+	}()
+	return l.Addr().String(), packets
+}
+
+func (s *BackendsSuite) TestMQTT(c *C) {
+	addr, packets := mqttBroker(c, 0)
+	s.n.Metadata = nil
+
+	b, err := NewBackend("mqtt", "mqtt://user:secret@"+addr+"/devices/1")
+	c.Assert(err, IsNil)
+	b.(*mqttBackend).clientID = "snapd-test"
+	c.Assert(b.Deliver(s.n), IsNil)
+
+	var connect bytes.Buffer
+	connect.Write([]byte{0, 4, 'M', 'Q', 'T', 'T', 4, 0xc2, 0, 60})
+	mqttString(&connect, "snapd-test")
+	mqttString(&connect, "user")
+	mqttString(&connect, "secret")
+	c.Check(<-packets, DeepEquals, append([]byte{mqttConnect, byte(connect.Len())}, connect.Bytes()...))
+
+	payload, err := json.Marshal(s.n)
+	c.Assert(err, IsNil)
+	var publish bytes.Buffer
+	mqttString(&publish, "devices/1")
+	publish.Write(payload)
+	c.Check(<-packets, DeepEquals, append([]byte{mqttPublish, byte(publish.Len())}, publish.Bytes()...))
+
+	c.Check(<-packets, DeepEquals, []byte{mqttDisconnect, 0})
+}
+
+func (s *BackendsSuite) TestMQTTRefused(c *C) {
+	addr, _ := mqttBroker(c, 5)
+
+	b, err := NewBackend("mqtt", "mqtt://"+addr+"/devices/1")
+	c.Assert(err, IsNil)
+	c.Check(b.Deliver(s.n), ErrorMatches, "cannot publish notification to .*: connection refused with return code 5")
+}
+
+func (s *BackendsSuite) TestMQTTDefaultPort(c *C) {
+	b, err := newMQTTBackend("mqtts://broker.example.com/devices")
+	c.Assert(err, IsNil)
+	c.Check(b.addr, Equals, "broker.example.com:8883")
+	c.Check(b.tls, Equals, true)
+	c.Check(b.topic, Equals, "devices")
+}
+
+func (s *BackendsSuite) TestMQTTPacketLength(c *C) {
+	p := mqttPacket(mqttPublish, make([]byte, 321))
+	c.Check(p[:3], DeepEquals, []byte{mqttPublish, 0xc1, 0x02})
+	c.Check(p, HasLen, 324)
+}
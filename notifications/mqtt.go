@@ -0,0 +1,188 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package notifications
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/snapcore/snapd/strutil"
+)
+
+// mqttBackend publishes the notifications to a topic of an MQTT broker,
+// connecting to it for each of them with MQTT 3.1.1, at most once.
+type mqttBackend struct {
+	addr     string
+	tls      bool
+	topic    string
+	user     *url.Userinfo
+	clientID string
+}
+
+func newMQTTBackend(target string) (*mqttBackend, error) {
+	u, err := url.Parse(target)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid MQTT URL %q", target)
+	}
+	b := &mqttBackend{
+		addr:     u.Host,
+		topic:    strings.TrimPrefix(u.Path, "/"),
+		user:     u.User,
+		clientID: "snapd-" + strutil.MakeRandomString(8),
+	}
+	port := "1883"
+	switch u.Scheme {
+	case "mqtt":
+	case "mqtts":
+		b.tls = true
+		port = "8883"
+	default:
+		return nil, fmt.Errorf("invalid MQTT URL %q: scheme is not mqtt or mqtts", target)
+	}
+	if b.topic == "" || strings.ContainsAny(b.topic, "+#") {
+		return nil, fmt.Errorf("invalid MQTT URL %q: missing or invalid topic", target)
+	}
+	if _, _, err := net.SplitHostPort(b.addr); err != nil {
+		b.addr = net.JoinHostPort(b.addr, port)
+	}
+	return b, nil
+}
+
+// MQTT control packet types, in the upper bits of the first byte
+const (
+	mqttConnect    = 0x10
+	mqttConnack    = 0x20
+	mqttPublish    = 0x30
+	mqttDisconnect = 0xe0
+)
+
+func mqttString(buf *bytes.Buffer, s string) {
+	buf.WriteByte(byte(len(s) >> 8))
+	buf.WriteByte(byte(len(s)))
+	buf.WriteString(s)
+}
+
+// mqttPacket returns the packet of the given type with the given body,
+// preceded by its length as a variable length integer.
+func mqttPacket(typ byte, body []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte(typ)
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		buf.WriteByte(b)
+		if n == 0 {
+			break
+		}
+	}
+	buf.Write(body)
+	return buf.Bytes()
+}
+
+func (b *mqttBackend) connectPacket() []byte {
+	var buf bytes.Buffer
+	mqttString(&buf, "MQTT")
+	// protocol level 3.1.1
+	buf.WriteByte(4)
+	// clean session
+	flags := byte(0x02)
+	if b.user != nil {
+		flags |= 0x80
+		if _, ok := b.user.Password(); ok {
+			flags |= 0x40
+		}
+	}
+	buf.WriteByte(flags)
+	// keep alive, in seconds
+	buf.Write([]byte{0, 60})
+	mqttString(&buf, b.clientID)
+	if b.user != nil {
+		mqttString(&buf, b.user.Username())
+		if password, ok := b.user.Password(); ok {
+			mqttString(&buf, password)
+		}
+	}
+	return mqttPacket(mqttConnect, buf.Bytes())
+}
+
+func (b *mqttBackend) publishPacket(payload []byte) []byte {
+	var buf bytes.Buffer
+	mqttString(&buf, b.topic)
+	buf.Write(payload)
+	return mqttPacket(mqttPublish, buf.Bytes())
+}
+
+func (b *mqttBackend) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: deliveryTimeout}
+	if b.tls {
+		return tls.DialWithDialer(dialer, "tcp", b.addr, nil)
+	}
+	return dialer.Dial("tcp", b.addr)
+}
+
+func (b *mqttBackend) publish(payload []byte) error {
+	conn, err := b.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(deliveryTimeout))
+
+	if _, err := conn.Write(b.connectPacket()); err != nil {
+		return err
+	}
+	ack := make([]byte, 4)
+	if _, err := io.ReadFull(conn, ack); err != nil {
+		return fmt.Errorf("cannot read connection acknowledgement: %v", err)
+	}
+	if ack[0] != mqttConnack || ack[1] != 2 {
+		return fmt.Errorf("unexpected reply to connection: %x", ack)
+	}
+	if ack[3] != 0 {
+		return fmt.Errorf("connection refused with return code %d", ack[3])
+	}
+	if _, err := conn.Write(b.publishPacket(payload)); err != nil {
+		return err
+	}
+	_, err = conn.Write(mqttPacket(mqttDisconnect, nil))
+	return err
+}
+
+func (b *mqttBackend) Deliver(n *Notification) error {
+	data, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	if err := b.publish(data); err != nil {
+		return fmt.Errorf("cannot publish notification to %s: %v", b.addr, err)
+	}
+	return nil
+}
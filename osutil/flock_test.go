@@ -0,0 +1,51 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2014-2015 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package osutil
+
+import (
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+)
+
+type flockSuite struct{}
+
+var _ = Suite(&flockSuite{})
+
+func (s *flockSuite) TestTryLockFile(c *C) {
+	path := filepath.Join(c.MkDir(), "lock")
+
+	f, err := TryLockFile(path)
+	c.Assert(err, IsNil)
+	c.Check(FileExists(path), Equals, true)
+
+	_, err = TryLockFile(path)
+	c.Check(err, Equals, ErrAlreadyLocked)
+
+	c.Assert(f.Close(), IsNil)
+	f, err = TryLockFile(path)
+	c.Assert(err, IsNil)
+	f.Close()
+}
+
+func (s *flockSuite) TestTryLockFileNoDir(c *C) {
+	_, err := TryLockFile(filepath.Join(c.MkDir(), "missing", "lock"))
+	c.Check(err, ErrorMatches, "open .*: no such file or directory")
+}
@@ -0,0 +1,61 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2014-2015 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package osutil
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/testutil"
+)
+
+type gpgSuite struct{}
+
+var _ = Suite(&gpgSuite{})
+
+func (s *gpgSuite) TestRunGPG(c *C) {
+	mockGPG := testutil.MockCommand(c, "gpg", "cat; echo signed")
+	defer mockGPG.Restore()
+
+	out, err := RunGPG("/home/dir", []byte("data\n"), "--detach-sign")
+	c.Assert(err, IsNil)
+	c.Check(string(out), Equals, "data\nsigned\n")
+	c.Check(mockGPG.Calls(), DeepEquals, [][]string{
+		{"gpg", "--homedir", "/home/dir", "-q", "--detach-sign"},
+	})
+}
+
+func (s *gpgSuite) TestRunGPGDefaultHomedir(c *C) {
+	mockGPG := testutil.MockCommand(c, "gpg", "")
+	defer mockGPG.Restore()
+
+	_, err := RunGPG("", nil, "--verify", "sig")
+	c.Assert(err, IsNil)
+	c.Check(mockGPG.Calls(), DeepEquals, [][]string{
+		{"gpg", "-q", "--verify", "sig"},
+	})
+}
+
+func (s *gpgSuite) TestRunGPGFails(c *C) {
+	mockGPG := testutil.MockCommand(c, "gpg", "echo bad key >&2; exit 2")
+	defer mockGPG.Restore()
+
+	_, err := RunGPG("", nil, "--detach-sign")
+	c.Check(err, ErrorMatches, `gpg --detach-sign failed: exit status 2 \("bad key\\n"\)`)
+}
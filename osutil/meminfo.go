@@ -0,0 +1,72 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2014-2015 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package osutil
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var procMeminfo = "/proc/meminfo"
+
+// MemInfo returns the total memory of the system and how much of it is
+// available for new allocations without swapping, in bytes.
+func MemInfo() (total, available uint64, err error) {
+	f, err := os.Open(procMeminfo)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	fields := make(map[string]uint64)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// e.g. "MemAvailable:    1234567 kB"
+		parts := strings.Fields(scanner.Text())
+		if len(parts) < 2 {
+			continue
+		}
+		v, err := strconv.ParseUint(parts[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		if len(parts) == 3 && parts[2] == "kB" {
+			v *= 1024
+		}
+		fields[strings.TrimSuffix(parts[0], ":")] = v
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	total, ok := fields["MemTotal"]
+	if !ok {
+		return 0, 0, fmt.Errorf("cannot find MemTotal in %s", procMeminfo)
+	}
+	available, ok = fields["MemAvailable"]
+	if !ok {
+		// kernels before 3.14 don't tell, estimate it
+		available = fields["MemFree"] + fields["Buffers"] + fields["Cached"]
+	}
+	return total, available, nil
+}
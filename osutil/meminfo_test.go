@@ -0,0 +1,84 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2014-2015 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package osutil
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+)
+
+type meminfoSuite struct{}
+
+var _ = Suite(&meminfoSuite{})
+
+func (s *meminfoSuite) mockMeminfo(c *C, content string) (restore func()) {
+	path := filepath.Join(c.MkDir(), "meminfo")
+	c.Assert(ioutil.WriteFile(path, []byte(content), 0644), IsNil)
+	old := procMeminfo
+	procMeminfo = path
+	return func() { procMeminfo = old }
+}
+
+func (s *meminfoSuite) TestMemInfo(c *C) {
+	restore := s.mockMeminfo(c, `MemTotal:         508480 kB
+MemFree:           20324 kB
+MemAvailable:     301204 kB
+Buffers:           12764 kB
+Cached:           250124 kB
+HugePages_Total:       0
+`)
+	defer restore()
+
+	total, available, err := MemInfo()
+	c.Assert(err, IsNil)
+	c.Check(total, Equals, uint64(508480*1024))
+	c.Check(available, Equals, uint64(301204*1024))
+}
+
+func (s *meminfoSuite) TestMemInfoNoMemAvailable(c *C) {
+	restore := s.mockMeminfo(c, `MemTotal:         508480 kB
+MemFree:           20000 kB
+Buffers:           10000 kB
+Cached:           200000 kB
+`)
+	defer restore()
+
+	total, available, err := MemInfo()
+	c.Assert(err, IsNil)
+	c.Check(total, Equals, uint64(508480*1024))
+	c.Check(available, Equals, uint64(230000*1024))
+}
+
+func (s *meminfoSuite) TestMemInfoNoTotal(c *C) {
+	restore := s.mockMeminfo(c, "MemFree: 20000 kB\n")
+	defer restore()
+
+	_, _, err := MemInfo()
+	c.Check(err, ErrorMatches, "cannot find MemTotal in .*")
+}
+
+func (s *meminfoSuite) TestMemInfoReal(c *C) {
+	total, available, err := MemInfo()
+	c.Assert(err, IsNil)
+	c.Check(total > 0, Equals, true)
+	c.Check(available <= total, Equals, true)
+}
@@ -22,13 +22,17 @@
 package assertstate
 
 import (
+	"fmt"
 	"os"
+	"sync"
+	"time"
 
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/osutil"
 
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/release"
 )
 
 // AssertManager is responsible for the enforcement of assertions in
@@ -36,9 +40,24 @@ import (
 // nothing in it violates existing assertions, or misses required
 // ones.
 type AssertManager struct {
+	state *state.State
+
+	mu sync.Mutex
 	db *asserts.Database
+	// maxSeenTime is the latest time the manager has persisted from
+	// the timestamps of the assertions in the database, loaded from
+	// the state by the first Ensure
+	maxSeenTime time.Time
+	loaded      bool
 }
 
+var timeNow = time.Now
+
+// maxSeenTimeStep is how far the lower bound for the current time must
+// move before it is persisted again, so that the state is not written
+// for every assertion added.
+const maxSeenTimeStep = time.Hour
+
 func getTrustedAccountKey() string {
 	if !osutil.FileExists(dirs.SnapTrustedAccountKey) {
 		// XXX: allow this fallback here for integration tests,
@@ -51,15 +70,42 @@ func getTrustedAccountKey() string {
 
 // Manager returns a new assertion manager.
 func Manager(s *state.State) (*AssertManager, error) {
-	db, err := asserts.OpenSysDatabase(getTrustedAccountKey())
-	if err != nil {
-		return nil, err
-	}
-	return &AssertManager{db: db}, nil
+	// the database is only opened once needed
+	return &AssertManager{state: s}, nil
 }
 
-// Ensure implements StateManager.Ensure.
+// Ensure implements StateManager.Ensure. It persists the lower bound
+// for the current time the assertion database got from the timestamps
+// of its assertions, which is used when checking assertions on devices
+// whose clock cannot be trusted. The system clock is not taken into
+// account, so that a clock set wrongly in the future cannot raise the
+// bound for good. The first Ensure loads the persisted bound into the
+// database.
 func (m *AssertManager) Ensure() error {
+	m.state.Lock()
+	defer m.state.Unlock()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.loaded {
+		err := m.state.Get("max-seen-time", &m.maxSeenTime)
+		if err != nil && err != state.ErrNoState {
+			return err
+		}
+		m.loaded = true
+		if m.db != nil {
+			m.db.SetEarliestTime(m.maxSeenTime)
+		}
+	}
+	if m.db == nil {
+		return nil
+	}
+	seen := m.db.EarliestTime()
+	if seen.Sub(m.maxSeenTime) < maxSeenTimeStep {
+		return nil
+	}
+	m.maxSeenTime = seen
+	m.state.Set("max-seen-time", seen)
 	return nil
 }
 
@@ -71,7 +117,125 @@ func (m *AssertManager) Stop() {
 func (m *AssertManager) Wait() {
 }
 
-// DB returns the assertion database under the manager.
-func (m *AssertManager) DB() *asserts.Database {
-	return m.db
+// DB returns the assertion database under the manager, opening it on
+// first use.
+func (m *AssertManager) DB() (*asserts.Database, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.db == nil {
+		db, err := asserts.OpenSysDatabase(getTrustedAccountKey())
+		if err != nil {
+			return nil, fmt.Errorf("cannot open assertion database: %v", err)
+		}
+		db.SetEarliestTime(m.maxSeenTime)
+		m.db = db
+	}
+	return m.db, nil
+}
+
+// ReleaseMemory drops the assertion database, to be opened again when
+// next needed.
+func (m *AssertManager) ReleaseMemory() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.db = nil
+}
+
+// TimeBounds reports the times considered when checking assertions.
+type TimeBounds struct {
+	// Clock is the time according to the system clock.
+	Clock time.Time
+	// MaxSeen is the latest time seen and persisted by the manager.
+	MaxSeen time.Time
+	// Earliest is the lower bound for the current time known to the
+	// assertion database.
+	Earliest time.Time
+	// Check is the time used to check the validity of assertions.
+	Check time.Time
+}
+
+// TimeBounds returns the times considered when checking assertions,
+// useful to debug devices with an unreliable clock.
+func (m *AssertManager) TimeBounds() (*TimeBounds, error) {
+	db, err := m.DB()
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	maxSeenTime := m.maxSeenTime
+	m.mu.Unlock()
+	bounds := &TimeBounds{
+		Clock:    timeNow(),
+		MaxSeen:  maxSeenTime,
+		Earliest: db.EarliestTime(),
+	}
+	bounds.Check = bounds.Clock
+	if bounds.Clock.Before(bounds.Earliest) {
+		bounds.Check = bounds.Earliest
+	}
+	return bounds, nil
+}
+
+// DeviceSerial returns the identity of the device from its serial
+// assertion, as <brand-id>/<model>/<serial>. It returns
+// asserts.ErrNotFound if the device has no serial assertion.
+func (m *AssertManager) DeviceSerial() (string, error) {
+	db, err := m.DB()
+	if err != nil {
+		return "", err
+	}
+	found, err := db.FindMany(asserts.SerialType, nil)
+	if err != nil {
+		return "", err
+	}
+	// the device might have been registered more than once, use the
+	// latest serial
+	var serial *asserts.Serial
+	for _, a := range found {
+		cand := a.(*asserts.Serial)
+		if serial == nil || cand.Timestamp().After(serial.Timestamp()) {
+			serial = cand
+		}
+	}
+	return fmt.Sprintf("%s/%s/%s", serial.BrandID(), serial.Model(), serial.Serial()), nil
+}
+
+// DeviceModel returns the model assertion of the device: the one named
+// by its latest serial assertion, or else the only model assertion
+// known. It returns asserts.ErrNotFound if the model cannot be told.
+func (m *AssertManager) DeviceModel() (*asserts.Model, error) {
+	db, err := m.DB()
+	if err != nil {
+		return nil, err
+	}
+	serials, err := db.FindMany(asserts.SerialType, nil)
+	if err != nil && err != asserts.ErrNotFound {
+		return nil, err
+	}
+	var serial *asserts.Serial
+	for _, a := range serials {
+		cand := a.(*asserts.Serial)
+		if serial == nil || cand.Timestamp().After(serial.Timestamp()) {
+			serial = cand
+		}
+	}
+	if serial != nil {
+		a, err := db.Find(asserts.ModelType, map[string]string{
+			"series":   release.Series,
+			"brand-id": serial.BrandID(),
+			"model":    serial.Model(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return a.(*asserts.Model), nil
+	}
+	models, err := db.FindMany(asserts.ModelType, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(models) != 1 {
+		return nil, asserts.ErrNotFound
+	}
+	return models[0].(*asserts.Model), nil
 }
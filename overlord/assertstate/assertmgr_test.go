@@ -20,7 +20,10 @@
 package assertstate_test
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	. "gopkg.in/check.v1"
 
@@ -46,6 +49,188 @@ func (ams *assertMgrSuite) TestManagerAndDB(c *C) {
 	mgr, err := assertstate.Manager(s)
 	c.Assert(err, IsNil)
 
-	db := mgr.DB()
+	db, err := mgr.DB()
+	c.Assert(err, IsNil)
 	c.Check(db, FitsTypeOf, (*asserts.Database)(nil))
+
+	// the database is kept until released
+	again, err := mgr.DB()
+	c.Assert(err, IsNil)
+	c.Check(again, Equals, db)
+
+	mgr.ReleaseMemory()
+	again, err = mgr.DB()
+	c.Assert(err, IsNil)
+	c.Check(again, FitsTypeOf, (*asserts.Database)(nil))
+	c.Check(again, Not(Equals), db)
+}
+
+func (ams *assertMgrSuite) TestDBOpenError(c *C) {
+	s := state.New(nil)
+	mgr, err := assertstate.Manager(s)
+	c.Assert(err, IsNil)
+
+	os.Setenv("SNAPPY_TRUSTED_ACCOUNT_KEY", filepath.Join(dirs.GlobalRootDir, "missing"))
+	defer os.Unsetenv("SNAPPY_TRUSTED_ACCOUNT_KEY")
+
+	_, err = mgr.DB()
+	c.Check(err, ErrorMatches, "cannot open assertion database: failed to read trusted account key: .*")
+}
+
+func (ams *assertMgrSuite) TestDBUsesMaxSeenTime(c *C) {
+	maxSeen := time.Date(2016, 6, 1, 12, 0, 0, 0, time.UTC)
+	s := state.New(nil)
+	s.Lock()
+	s.Set("max-seen-time", maxSeen)
+	s.Unlock()
+
+	mgr, err := assertstate.Manager(s)
+	c.Assert(err, IsNil)
+	err = mgr.Ensure()
+	c.Assert(err, IsNil)
+
+	db, err := mgr.DB()
+	c.Assert(err, IsNil)
+	c.Check(db.EarliestTime().Equal(maxSeen), Equals, true)
+}
+
+func (ams *assertMgrSuite) TestEnsureLoadsMaxSeenTimeIntoOpenDB(c *C) {
+	maxSeen := time.Date(2016, 6, 1, 12, 0, 0, 0, time.UTC)
+	s := state.New(nil)
+	s.Lock()
+	s.Set("max-seen-time", maxSeen)
+	s.Unlock()
+
+	mgr, err := assertstate.Manager(s)
+	c.Assert(err, IsNil)
+	db, err := mgr.DB()
+	c.Assert(err, IsNil)
+	c.Check(db.EarliestTime().IsZero(), Equals, true)
+
+	err = mgr.Ensure()
+	c.Assert(err, IsNil)
+	c.Check(db.EarliestTime().Equal(maxSeen), Equals, true)
+}
+
+func (ams *assertMgrSuite) TestEnsurePersistsMaxSeenTime(c *C) {
+	s := state.New(nil)
+	mgr, err := assertstate.Manager(s)
+	c.Assert(err, IsNil)
+
+	getMaxSeen := func() time.Time {
+		var maxSeen time.Time
+		s.Lock()
+		defer s.Unlock()
+		err := s.Get("max-seen-time", &maxSeen)
+		c.Assert(err, IsNil)
+		return maxSeen
+	}
+
+	// nothing to persist until the database is opened
+	err = mgr.Ensure()
+	c.Assert(err, IsNil)
+	s.Lock()
+	err = s.Get("max-seen-time", new(time.Time))
+	s.Unlock()
+	c.Check(err, Equals, state.ErrNoState)
+
+	db, err := mgr.DB()
+	c.Assert(err, IsNil)
+
+	// as if an assertion with that timestamp was added
+	tstamp := time.Date(2016, 6, 1, 12, 0, 0, 0, time.UTC)
+	db.SetEarliestTime(tstamp)
+	err = mgr.Ensure()
+	c.Assert(err, IsNil)
+	c.Check(getMaxSeen().Equal(tstamp), Equals, true)
+
+	// small moves are not persisted
+	db.SetEarliestTime(tstamp.Add(30 * time.Minute))
+	err = mgr.Ensure()
+	c.Assert(err, IsNil)
+	c.Check(getMaxSeen().Equal(tstamp), Equals, true)
+
+	db.SetEarliestTime(tstamp.Add(2 * time.Hour))
+	err = mgr.Ensure()
+	c.Assert(err, IsNil)
+	c.Check(getMaxSeen().Equal(tstamp.Add(2*time.Hour)), Equals, true)
+}
+
+func (ams *assertMgrSuite) TestEnsureIgnoresClock(c *C) {
+	maxSeen := time.Date(2016, 6, 1, 12, 0, 0, 0, time.UTC)
+	s := state.New(nil)
+	s.Lock()
+	s.Set("max-seen-time", maxSeen)
+	s.Unlock()
+
+	mgr, err := assertstate.Manager(s)
+	c.Assert(err, IsNil)
+	_, err = mgr.DB()
+	c.Assert(err, IsNil)
+
+	// a clock set wrongly in the future does not raise the bound
+	future := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	restore := assertstate.MockTimeNow(func() time.Time { return future })
+	defer restore()
+
+	err = mgr.Ensure()
+	c.Assert(err, IsNil)
+
+	var persisted time.Time
+	s.Lock()
+	err = s.Get("max-seen-time", &persisted)
+	s.Unlock()
+	c.Assert(err, IsNil)
+	c.Check(persisted.Equal(maxSeen), Equals, true)
+}
+
+func (ams *assertMgrSuite) TestTimeBounds(c *C) {
+	maxSeen := time.Date(2016, 6, 1, 12, 0, 0, 0, time.UTC)
+	s := state.New(nil)
+	s.Lock()
+	s.Set("max-seen-time", maxSeen)
+	s.Unlock()
+
+	mgr, err := assertstate.Manager(s)
+	c.Assert(err, IsNil)
+	err = mgr.Ensure()
+	c.Assert(err, IsNil)
+
+	// dead RTC
+	epoch := time.Unix(0, 0)
+	restore := assertstate.MockTimeNow(func() time.Time { return epoch })
+	defer restore()
+
+	bounds, err := mgr.TimeBounds()
+	c.Assert(err, IsNil)
+	c.Check(bounds.Clock, Equals, epoch)
+	c.Check(bounds.MaxSeen.Equal(maxSeen), Equals, true)
+	c.Check(bounds.Earliest.Equal(maxSeen), Equals, true)
+	c.Check(bounds.Check.Equal(maxSeen), Equals, true)
+
+	later := maxSeen.Add(time.Hour)
+	restore = assertstate.MockTimeNow(func() time.Time { return later })
+	defer restore()
+
+	bounds, err = mgr.TimeBounds()
+	c.Assert(err, IsNil)
+	c.Check(bounds.Check, Equals, later)
+}
+
+func (ams *assertMgrSuite) TestDeviceSerialNoSerial(c *C) {
+	s := state.New(nil)
+	mgr, err := assertstate.Manager(s)
+	c.Assert(err, IsNil)
+
+	_, err = mgr.DeviceSerial()
+	c.Check(err, Equals, asserts.ErrNotFound)
+}
+
+func (ams *assertMgrSuite) TestDeviceModelNoModel(c *C) {
+	s := state.New(nil)
+	mgr, err := assertstate.Manager(s)
+	c.Assert(err, IsNil)
+
+	_, err = mgr.DeviceModel()
+	c.Check(err, Equals, asserts.ErrNotFound)
 }
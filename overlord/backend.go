@@ -23,15 +23,22 @@ import (
 	"time"
 
 	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/overlord/statelog"
 )
 
 type overlordStateBackend struct {
-	path           string
+	path string
+	// log, if set, keeps the state instead of the file at path.
+	log            *statelog.Log
 	ensureBefore   func(d time.Duration)
-	requestRestart func()
+	requestRestart func(t state.RestartType)
 }
 
 func (osb *overlordStateBackend) Checkpoint(data []byte) error {
+	if osb.log != nil {
+		return osb.log.Checkpoint(data)
+	}
 	return osutil.AtomicWriteFile(osb.path, data, 0600, 0)
 }
 
@@ -39,6 +46,6 @@ func (osb *overlordStateBackend) EnsureBefore(d time.Duration) {
 	osb.ensureBefore(d)
 }
 
-func (osb *overlordStateBackend) RequestRestart() {
-	osb.requestRestart()
+func (osb *overlordStateBackend) RequestRestart(t state.RestartType) {
+	osb.requestRestart(t)
 }
@@ -0,0 +1,407 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package configstate implements the state aspects responsible for the configuration of snaps.
+package configstate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+// CoreSnapName is the name under which the configuration of the system
+// itself is kept.
+const CoreSnapName = "core"
+
+var validKey = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*(\.[a-z0-9]+(-[a-z0-9]+)*)*$`)
+
+// NoOptionError is returned by Get when the requested option is not set.
+type NoOptionError struct {
+	SnapName string
+	Key      string
+}
+
+func (e *NoOptionError) Error() string {
+	return fmt.Sprintf("snap %q has no %q configuration option", e.SnapName, e.Key)
+}
+
+// ParseKey splits a dotted configuration key into its parts.
+func ParseKey(key string) ([]string, error) {
+	if !validKey.MatchString(key) {
+		return nil, fmt.Errorf("invalid option name: %q", key)
+	}
+	return strings.Split(key, "."), nil
+}
+
+// coreHandler validates and applies a change to a top level option of
+// the system configuration; subkeys are the rest of the dotted key and
+// value is nil when the option is being unset.
+type coreHandler func(subkeys []string, value interface{}) error
+
+// optionCheck checks that the option of the given dotted key can be set
+// to value.
+type optionCheck func(key string, value interface{}) error
+
+// anyOption is the name of the check of mapOptionHandler for the options
+// not otherwise listed, such as the snaps of readonly-data.
+const anyOption = "*"
+
+// subOptions returns the options, by name, of the top level option set
+// to value with the given subkeys.
+func subOptions(top string, subkeys []string, value interface{}) (map[string]interface{}, error) {
+	if len(subkeys) > 1 {
+		return nil, fmt.Errorf("invalid option name: %q", top+"."+strings.Join(subkeys, "."))
+	}
+	if len(subkeys) == 1 {
+		return map[string]interface{}{subkeys[0]: value}, nil
+	}
+	if value == nil {
+		return nil, nil
+	}
+	options, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("cannot set %q: not a map", top)
+	}
+	return options, nil
+}
+
+// mapOptionHandler returns the handler of the top level option made of
+// the options checked by the given checks, by name. Unsetting them is
+// always fine.
+func mapOptionHandler(top string, checks map[string]optionCheck) coreHandler {
+	return func(subkeys []string, value interface{}) error {
+		options, err := subOptions(top, subkeys, value)
+		if err != nil || value == nil {
+			return err
+		}
+		names := make([]string, 0, len(options))
+		for name := range options {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			check, ok := checks[name]
+			if !ok {
+				check, ok = checks[anyOption]
+			}
+			if !ok {
+				return fmt.Errorf("invalid option name: %q", top+"."+name)
+			}
+			if err := check(top+"."+name, options[name]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// checkBool checks that the option is set to a boolean.
+func checkBool(key string, value interface{}) error {
+	if _, ok := value.(bool); !ok {
+		return fmt.Errorf("cannot set %q: not a boolean", key)
+	}
+	return nil
+}
+
+// checkString returns the check of an option set to a string, which
+// valid, if not nil, checks further.
+func checkString(valid func(s string) error) optionCheck {
+	return func(key string, value interface{}) error {
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("cannot set %q: not a string", key)
+		}
+		if valid == nil {
+			return nil
+		}
+		if err := valid(s); err != nil {
+			return fmt.Errorf("cannot set %q: %v", key, err)
+		}
+		return nil
+	}
+}
+
+var coreHandlers = map[string]coreHandler{
+	"store-certs":   handleStoreCerts,
+	"error-reports": handleErrorReports,
+	"reboot":        handleReboot,
+	"readonly-data": handleReadOnlyData,
+	"notifications": handleNotifications,
+	"events":        handleEvents,
+	"scanner":       handleScanner,
+	"tasks":         handleTasks,
+
+	"security-advisories": handleSecurityAdvisories,
+}
+
+// snapHandler validates and applies a change to a top level option
+// that snapd itself uses for any snap, as coreHandler does for the
+// system configuration.
+type snapHandler func(snapName string, subkeys []string, value interface{}) error
+
+var snapHandlers = map[string]snapHandler{
+	"system": handleSystem,
+}
+
+func unmarshal(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	// keep numbers as they were given
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+func snapConfig(st *state.State, snapName string) (map[string]interface{}, error) {
+	var config map[string]*json.RawMessage
+	if err := st.Get("config", &config); err != nil && err != state.ErrNoState {
+		return nil, err
+	}
+	cfg := make(map[string]interface{})
+	raw, ok := config[snapName]
+	if !ok {
+		return cfg, nil
+	}
+	if err := unmarshal(*raw, &cfg); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal configuration of snap %q: %v", snapName, err)
+	}
+	return cfg, nil
+}
+
+func setSnapConfig(st *state.State, snapName string, cfg map[string]interface{}) {
+	var config map[string]*json.RawMessage
+	if err := st.Get("config", &config); err != nil && err != state.ErrNoState {
+		panic("internal error: cannot unmarshal config state: " + err.Error())
+	}
+	if config == nil {
+		config = make(map[string]*json.RawMessage)
+	}
+	if len(cfg) == 0 {
+		delete(config, snapName)
+	} else {
+		data, err := json.Marshal(cfg)
+		if err != nil {
+			panic("internal error: cannot marshal snap config: " + err.Error())
+		}
+		raw := json.RawMessage(data)
+		config[snapName] = &raw
+	}
+	st.Set("config", config)
+}
+
+// Get unmarshals the value of the configuration option of the snap
+// identified by the dotted key into result.
+// Note that the state must be locked by the caller.
+func Get(st *state.State, snapName, key string, result interface{}) error {
+	parts, err := ParseKey(key)
+	if err != nil {
+		return err
+	}
+	cfg, err := snapConfig(st, snapName)
+	if err != nil {
+		return err
+	}
+
+	value := lookup(cfg, parts)
+	if value == nil {
+		return &NoOptionError{SnapName: snapName, Key: key}
+	}
+	return remarshal(value, result)
+}
+
+// remarshal unmarshals into result the value as it reads back from the
+// state.
+func remarshal(value interface{}, result interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return unmarshal(data, result)
+}
+
+// Set sets the configuration option of the snap identified by the
+// dotted key to value, or unsets it if value is nil. Options of the
+// system are validated and applied before being stored.
+// Note that the state must be locked by the caller.
+func Set(st *state.State, snapName, key string, value interface{}) error {
+	parts, err := ParseKey(key)
+	if err != nil {
+		return err
+	}
+
+	// normalize the value to what it reads back as
+	if value != nil {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("cannot set %q: %v", key, err)
+		}
+		if err := unmarshal(data, &value); err != nil {
+			return fmt.Errorf("cannot set %q: %v", key, err)
+		}
+	}
+
+	cfg, err := snapConfig(st, snapName)
+	if err != nil {
+		return err
+	}
+
+	m := cfg
+	for i, part := range parts[:len(parts)-1] {
+		sub, ok := m[part]
+		if !ok {
+			if value == nil {
+				// nothing to unset
+				return nil
+			}
+			sub = make(map[string]interface{})
+			m[part] = sub
+		}
+		if m, ok = sub.(map[string]interface{}); !ok {
+			return fmt.Errorf("cannot set %q: %q is not a map", key, strings.Join(parts[:i+1], "."))
+		}
+	}
+
+	if snapName == CoreSnapName {
+		if handler, ok := coreHandlers[parts[0]]; ok {
+			if err := handler(parts[1:], value); err != nil {
+				return err
+			}
+		}
+	}
+	if handler, ok := snapHandlers[parts[0]]; ok {
+		if err := handler(snapName, parts[1:], value); err != nil {
+			return err
+		}
+	}
+
+	last := parts[len(parts)-1]
+	if value == nil {
+		delete(m, last)
+	} else {
+		m[last] = value
+	}
+	setSnapConfig(st, snapName, cfg)
+	return nil
+}
+
+// ValidateSchema checks that the option of the snap identified by the
+// dotted key can be set to value, or unset if value is nil, as the
+// configuration schema the snap ships says, if it has one. The options
+// snapd itself handles for any snap are left to their handlers.
+func ValidateSchema(schema *snap.ConfigSchema, key string, value interface{}) error {
+	parts, err := ParseKey(key)
+	if err != nil {
+		return err
+	}
+	if _, ok := snapHandlers[parts[0]]; ok || schema == nil {
+		return nil
+	}
+	return schema.Validate(key, value)
+}
+
+func flattenOptions(prefix string, options map[string]interface{}, flat map[string]interface{}) {
+	for key, value := range options {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if sub, ok := value.(map[string]interface{}); ok && len(sub) > 0 {
+			flattenOptions(key, sub, flat)
+			continue
+		}
+		flat[key] = value
+	}
+}
+
+// flatOptions returns the leaf values of the given options keyed by
+// their dotted keys, and the sorted keys.
+func flatOptions(options map[string]interface{}) (map[string]interface{}, []string) {
+	flat := make(map[string]interface{})
+	flattenOptions("", options, flat)
+	keys := make([]string, 0, len(flat))
+	for key := range flat {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return flat, keys
+}
+
+// ValidateDefaults checks that the default configuration options a
+// gadget gives to the snap are usable, so that mistakes are found when
+// building an image rather than when seeding a device.
+func ValidateDefaults(snapName string, defaults map[string]interface{}) error {
+	flat, keys := flatOptions(defaults)
+
+	for _, key := range keys {
+		parts, err := ParseKey(key)
+		if err != nil {
+			return fmt.Errorf("invalid default for snap %q: %v", snapName, err)
+		}
+		if flat[key] == nil {
+			return fmt.Errorf("invalid default for snap %q: option %q has no value", snapName, key)
+		}
+		if snapName == CoreSnapName && parts[0] == "store-certs" {
+			if len(parts) != 2 {
+				return fmt.Errorf("invalid default for snap %q: invalid option name: %q", snapName, key)
+			}
+			if err := checkStoreCert(parts[1], flat[key]); err != nil {
+				return fmt.Errorf("invalid default for snap %q: %v", snapName, err)
+			}
+		}
+		if snapName == CoreSnapName && parts[0] == "error-reports" {
+			if len(parts) != 2 {
+				return fmt.Errorf("invalid default for snap %q: invalid option name: %q", snapName, key)
+			}
+			if err := handleErrorReports(parts[1:], flat[key]); err != nil {
+				return fmt.Errorf("invalid default for snap %q: %v", snapName, err)
+			}
+		}
+	}
+	return nil
+}
+
+// SetDefaults sets the default configuration options a gadget gives to
+// the snap, leaving alone the options that are already set.
+// Note that the state must be locked by the caller.
+func SetDefaults(st *state.State, snapName string, defaults map[string]interface{}) error {
+	if err := ValidateDefaults(snapName, defaults); err != nil {
+		return err
+	}
+
+	flat, keys := flatOptions(defaults)
+
+	for _, key := range keys {
+		var current interface{}
+		err := Get(st, snapName, key, &current)
+		if err == nil {
+			continue
+		}
+		if _, ok := err.(*NoOptionError); !ok {
+			return err
+		}
+		if err := Set(st, snapName, key, flat[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configstate
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+// ConfigureHook is the hook of a snap told about changes made to its
+// configuration.
+const ConfigureHook = "configure"
+
+// Configure returns a task changing the configuration of the snap as
+// the patch says and running its configure hook; should the hook fail,
+// the changes are rolled back.
+func Configure(st *state.State, snapName string, revision snap.Revision, patch map[string]interface{}) *state.Task {
+	summary := fmt.Sprintf(i18n.G("Run configure hook of %q snap"), snapName)
+	return hookstate.HookTaskWithContext(st, summary, snapName, revision, ConfigureHook, map[string]interface{}{
+		"patch": patch,
+	})
+}
+
+// configureHandler handles the configure hook, making the changes to
+// the configuration before the hook runs and undoing them if it fails.
+type configureHandler struct {
+	context *hookstate.Context
+}
+
+// NewConfigureHandler returns the handler of the configure hook of the
+// given context.
+func NewConfigureHandler(context *hookstate.Context) hookstate.Handler {
+	return &configureHandler{context: context}
+}
+
+// get unmarshals the value of the context associated with key into
+// result, keeping numbers as they were given.
+func (h *configureHandler) get(key string, result interface{}) error {
+	var raw json.RawMessage
+	if err := h.context.Get(key, &raw); err != nil {
+		return err
+	}
+	return unmarshal(raw, result)
+}
+
+// patchKeys returns the patch of the hook and its sorted keys.
+func (h *configureHandler) patchKeys() (map[string]interface{}, []string, error) {
+	var patch map[string]interface{}
+	if err := h.get("patch", &patch); err != nil && err != state.ErrNoState {
+		return nil, nil, err
+	}
+	keys := make([]string, 0, len(patch))
+	for key := range patch {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return patch, keys, nil
+}
+
+// Before makes the changes, keeping the configuration as it was before
+// them in the context to roll them back.
+func (h *configureHandler) Before() error {
+	h.context.Lock()
+	defer h.context.Unlock()
+
+	var snapshot map[string]interface{}
+	if err := h.get("snapshot", &snapshot); err == nil {
+		// made already, before a restart
+		return nil
+	}
+
+	patch, keys, err := h.patchKeys()
+	if err != nil {
+		return err
+	}
+	tr := NewTransaction(h.context.State(), h.context.SnapName())
+	for _, key := range keys {
+		if err := tr.Set(key, patch[key]); err != nil {
+			return err
+		}
+	}
+	if err := tr.Commit(); err != nil {
+		return err
+	}
+	h.context.Set("snapshot", tr.snapshot)
+	return nil
+}
+
+// Done implements hookstate.Handler.Done.
+func (h *configureHandler) Done() error {
+	return nil
+}
+
+// Error rolls the changes back.
+func (h *configureHandler) Error(hookErr error) error {
+	h.context.Lock()
+	defer h.context.Unlock()
+
+	var snapshot map[string]interface{}
+	if err := h.get("snapshot", &snapshot); err != nil {
+		return nil
+	}
+	if snapshot == nil {
+		snapshot = make(map[string]interface{})
+	}
+	_, keys, err := h.patchKeys()
+	if err == nil {
+		err = restoreConfig(h.context.State(), h.context.SnapName(), keys, snapshot)
+	}
+	if err != nil {
+		return fmt.Errorf("%v; cannot roll back configuration of snap %q: %v", hookErr, h.context.SnapName(), err)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configstate_test
+
+import (
+	"encoding/json"
+	"regexp"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/configstate"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type configureSuite struct {
+	state   *state.State
+	manager *hookstate.HookManager
+}
+
+var _ = Suite(&configureSuite{})
+
+const configureSnapYaml = `name: foo
+version: 1.0
+hooks:
+  configure:
+`
+
+func (s *configureSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	s.state = state.New(nil)
+	manager, err := hookstate.Manager(s.state)
+	c.Assert(err, IsNil)
+	manager.Register(regexp.MustCompile("^configure$"), configstate.NewConfigureHandler)
+	s.manager = manager
+
+	snaptest.MockSnap(c, configureSnapYaml, &snap.SideInfo{Revision: snap.R(1)})
+}
+
+func (s *configureSuite) TearDownTest(c *C) {
+	s.manager.Stop()
+	dirs.SetRootDir("")
+}
+
+func (s *configureSuite) configure(c *C, patch map[string]interface{}) *state.Task {
+	s.state.Lock()
+	c.Assert(configstate.Set(s.state, "foo", "port", 80), IsNil)
+	chg := s.state.NewChange("configure-snap", "...")
+	task := configstate.Configure(s.state, "foo", snap.R(1), patch)
+	chg.AddTask(task)
+	s.state.Unlock()
+
+	s.manager.Ensure()
+	s.manager.Wait()
+
+	return task
+}
+
+func (s *configureSuite) TestConfigure(c *C) {
+	cmd := testutil.MockCommand(c, "snap", "")
+	defer cmd.Restore()
+
+	task := s.configure(c, map[string]interface{}{
+		"port":      8080,
+		"user.name": "admin",
+	})
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(task.Status(), Equals, state.DoneStatus)
+	c.Check(cmd.Calls(), DeepEquals, [][]string{{"snap", "run", "--hook", "configure", "-r", "1", "foo"}})
+
+	config, err := configstate.Config(s.state, "foo")
+	c.Assert(err, IsNil)
+	c.Check(config, DeepEquals, map[string]interface{}{
+		"port": json.Number("8080"),
+		"user": map[string]interface{}{"name": "admin"},
+	})
+}
+
+func (s *configureSuite) TestConfigureHookFailsRollsBack(c *C) {
+	cmd := testutil.MockCommand(c, "snap", "echo bad port; exit 1")
+	defer cmd.Restore()
+
+	task := s.configure(c, map[string]interface{}{
+		"port":      8080,
+		"user.name": "admin",
+	})
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(task.Status(), Equals, state.ErrorStatus)
+	c.Check(task.Log(), HasLen, 1)
+	c.Check(task.Log()[0], Matches, `.* cannot run hook "configure": exit status 1: bad port`)
+
+	config, err := configstate.Config(s.state, "foo")
+	c.Assert(err, IsNil)
+	c.Check(config, DeepEquals, map[string]interface{}{
+		"port": json.Number("80"),
+	})
+}
+
+func (s *configureSuite) TestConfigureInvalidPatch(c *C) {
+	cmd := testutil.MockCommand(c, "snap", "")
+	defer cmd.Restore()
+
+	task := s.configure(c, map[string]interface{}{
+		"port":         8080,
+		"system.bogus": 1,
+	})
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(task.Status(), Equals, state.ErrorStatus)
+	c.Check(cmd.Calls(), HasLen, 0)
+
+	var port json.Number
+	c.Assert(configstate.Get(s.state, "foo", "port", &port), IsNil)
+	c.Check(port, Equals, json.Number("80"))
+}
@@ -0,0 +1,40 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configstate
+
+import (
+	"fmt"
+)
+
+func checkToken(s string) error {
+	if s == "" {
+		return fmt.Errorf("empty token")
+	}
+	return nil
+}
+
+// handleEvents validates the options of the publisher of the events
+// about the changes and the snaps, which are only published once a
+// webhook or an MQTT broker is set.
+var handleEvents = mapOptionHandler("events", map[string]optionCheck{
+	"webhook": checkBackend("webhook"),
+	"mqtt":    checkBackend("mqtt"),
+	"token":   checkString(checkToken),
+})
@@ -0,0 +1,43 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configstate
+
+import (
+	"github.com/snapcore/snapd/notifications"
+)
+
+// checkBackend returns the check of the option setting up the
+// notification backend of the given name.
+func checkBackend(name string) optionCheck {
+	return checkString(func(s string) error {
+		_, err := notifications.NewBackend(name, s)
+		return err
+	})
+}
+
+// handleNotifications validates the options of the backends the
+// notifications about the device, such as pending refreshes, required
+// reboots and security warnings, are delivered with.
+var handleNotifications = mapOptionHandler("notifications", map[string]optionCheck{
+	"desktop": checkBool,
+	"webhook": checkBackend("webhook"),
+	"mqtt":    checkBackend("mqtt"),
+	"script":  checkBackend("script"),
+})
@@ -0,0 +1,42 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configstate
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/snapcore/snapd/snap"
+)
+
+// checkReadOnlyData checks that readonly-data.<snap> can be set to value.
+func checkReadOnlyData(key string, value interface{}) error {
+	if err := snap.ValidateName(strings.TrimPrefix(key, "readonly-data.")); err != nil {
+		return fmt.Errorf("invalid option name: %q", key)
+	}
+	return checkBool(key, value)
+}
+
+// handleReadOnlyData validates the snaps whose system data is made
+// read-only once installed or refreshed, set as
+// readonly-data.<snap>=true.
+var handleReadOnlyData = mapOptionHandler("readonly-data", map[string]optionCheck{
+	anyOption: checkReadOnlyData,
+})
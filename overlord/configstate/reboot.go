@@ -0,0 +1,110 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configstate
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RebootWindow is a time of the week in which the device can be
+// rebooted to finish applying refreshes.
+type RebootWindow struct {
+	// Days are the days the window starts on; every day if empty.
+	Days []time.Weekday
+	// Start and End are the times of the day the window starts and
+	// ends at; the window ends the next day if End is before Start.
+	Start time.Duration
+	End   time.Duration
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func parseRebootWindow(s string) (*RebootWindow, error) {
+	fields := strings.Split(s, ",")
+	var w RebootWindow
+	for _, day := range fields[:len(fields)-1] {
+		wd, ok := weekdays[day]
+		if !ok {
+			return nil, fmt.Errorf("invalid week day %q", day)
+		}
+		w.Days = append(w.Days, wd)
+	}
+
+	times := strings.Split(fields[len(fields)-1], "-")
+	if len(times) != 2 {
+		return nil, fmt.Errorf("invalid time range %q", fields[len(fields)-1])
+	}
+	var err error
+	if w.Start, err = parseTimeOfDay(times[0]); err != nil {
+		return nil, err
+	}
+	if w.End, err = parseTimeOfDay(times[1]); err != nil {
+		return nil, err
+	}
+	if w.Start == w.End {
+		return nil, fmt.Errorf("empty time range %q", fields[len(fields)-1])
+	}
+	return &w, nil
+}
+
+// ParseRebootSchedule parses the reboot.schedule option: windows
+// separated by "/", each made of the week days it starts on, if not
+// every day, followed by a time range, such as "sun,03:00-05:00" or
+// "sat,sun,23:00-01:00/wed,12:00-13:00".
+func ParseRebootSchedule(schedule string) ([]*RebootWindow, error) {
+	var windows []*RebootWindow
+	for _, s := range strings.Split(schedule, "/") {
+		w, err := parseRebootWindow(s)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse reboot schedule %q: %v", schedule, err)
+		}
+		windows = append(windows, w)
+	}
+	return windows, nil
+}
+
+func checkRebootSchedule(s string) error {
+	_, err := ParseRebootSchedule(s)
+	return err
+}
+
+// handleReboot validates the options of the reboots of the device:
+// reboot.schedule restricts them to the windows it lists.
+var handleReboot = mapOptionHandler("reboot", map[string]optionCheck{
+	"schedule": checkString(checkRebootSchedule),
+})
@@ -0,0 +1,40 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configstate
+
+import (
+	"github.com/snapcore/snapd/scanner"
+)
+
+// checkScanner returns the check of the option setting the scanner up
+// with the given kind of address.
+func checkScanner(kind string) optionCheck {
+	return checkString(func(s string) error {
+		_, err := scanner.New(kind, s)
+		return err
+	})
+}
+
+// handleScanner validates the options of the scanner that snaps are
+// given to before they are installed.
+var handleScanner = mapOptionHandler("scanner", map[string]optionCheck{
+	"command": checkScanner("command"),
+	"socket":  checkScanner("socket"),
+})
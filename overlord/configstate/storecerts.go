@@ -0,0 +1,140 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configstate
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+)
+
+var validCertName = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// validateStoreCert checks that data holds one or more PEM encoded
+// certificates and nothing else.
+func validateStoreCert(data string) error {
+	rest := []byte(strings.TrimSpace(data))
+	n := 0
+	for len(rest) > 0 {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return fmt.Errorf("not a PEM encoded certificate")
+		}
+		if block.Type != "CERTIFICATE" {
+			return fmt.Errorf("unexpected PEM block of type %q", block.Type)
+		}
+		if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+			return err
+		}
+		n++
+		rest = []byte(strings.TrimSpace(string(rest)))
+	}
+	if n == 0 {
+		return fmt.Errorf("no certificate found")
+	}
+	return nil
+}
+
+func storeCertPath(name string) string {
+	return filepath.Join(dirs.SnapStoreCertsDir, name+".pem")
+}
+
+// checkStoreCert checks that the store certificate can be set to value.
+func checkStoreCert(name string, value interface{}) error {
+	if !validCertName.MatchString(name) {
+		return fmt.Errorf("invalid store certificate name: %q", name)
+	}
+	data, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("cannot use store certificate %q: not a string", name)
+	}
+	if err := validateStoreCert(data); err != nil {
+		return fmt.Errorf("cannot use store certificate %q: %v", name, err)
+	}
+	return nil
+}
+
+func setStoreCert(name string, value interface{}) error {
+	if value == nil {
+		if !validCertName.MatchString(name) {
+			return fmt.Errorf("invalid store certificate name: %q", name)
+		}
+		if err := os.Remove(storeCertPath(name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	if err := checkStoreCert(name, value); err != nil {
+		return err
+	}
+	data := value.(string)
+	if err := os.MkdirAll(dirs.SnapStoreCertsDir, 0755); err != nil {
+		return err
+	}
+	return osutil.AtomicWriteFile(storeCertPath(name), []byte(data), 0644, 0)
+}
+
+// handleStoreCerts installs the extra CA certificates used by the store
+// client, set as store-certs.<name>=<PEM data>.
+func handleStoreCerts(subkeys []string, value interface{}) error {
+	certs, err := subOptions("store-certs", subkeys, value)
+	if err != nil {
+		return err
+	}
+	if len(subkeys) == 1 {
+		return setStoreCert(subkeys[0], value)
+	}
+
+	// replacing all of them
+	for name, cert := range certs {
+		if err := checkStoreCert(name, cert); err != nil {
+			return err
+		}
+	}
+
+	existing, err := ioutil.ReadDir(dirs.SnapStoreCertsDir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, fi := range existing {
+		name := strings.TrimSuffix(fi.Name(), ".pem")
+		if _, ok := certs[name]; !ok {
+			if err := setStoreCert(name, nil); err != nil {
+				return err
+			}
+		}
+	}
+	for name, cert := range certs {
+		if err := setStoreCert(name, cert); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configstate
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/wrappers"
+)
+
+// systemOption checks and applies an option under system for a snap,
+// value being nil when the option is unset.
+type systemOption struct {
+	check func(value interface{}) error
+	apply func(snapName string, value interface{}) error
+}
+
+var systemOptions = map[string]systemOption{
+	"system.journal.forward": {checkJournalForward, applyJournalForward},
+	"system.tmp.size":        {checkTmpSize, applyTmpSize},
+}
+
+// handleSystem applies the options snapd itself uses for the snap:
+// system.journal.forward tells whether the journal output of its
+// services is forwarded to syslog, "syslog" being the default, or kept
+// out of it with "none"; system.tmp.size is the size of its private
+// /tmp. The options under the key that are not given are unset, back
+// to their defaults.
+func handleSystem(snapName string, subkeys []string, value interface{}) error {
+	key := strings.Join(append([]string{"system"}, subkeys...), ".")
+	options := make(map[string]interface{})
+	if sub, ok := value.(map[string]interface{}); ok {
+		flattenOptions(key, sub, options)
+	} else if value != nil {
+		options[key] = value
+	}
+	for name := range options {
+		if _, ok := systemOptions[name]; !ok {
+			return fmt.Errorf("invalid option name: %q", name)
+		}
+	}
+
+	var names []string
+	for name := range systemOptions {
+		if name == key || strings.HasPrefix(name, key+".") {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("invalid option name: %q", key)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := systemOptions[name].check(options[name]); err != nil {
+			return fmt.Errorf("cannot set %q: %v", name, err)
+		}
+	}
+	for _, name := range names {
+		if err := systemOptions[name].apply(snapName, options[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkJournalForward(value interface{}) error {
+	switch value {
+	case nil, "syslog", "none":
+		return nil
+	}
+	return fmt.Errorf(`not one of "syslog" or "none"`)
+}
+
+func applyJournalForward(snapName string, value interface{}) error {
+	return wrappers.SetSnapJournalForward(snapName, value != "none", &progress.NullProgress{})
+}
+
+// tmpSize returns the size of /tmp in value, given as a string or as a
+// plain number of bytes.
+func tmpSize(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	}
+	return ""
+}
+
+func checkTmpSize(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	size := tmpSize(value)
+	if size == "" {
+		return fmt.Errorf("not a size")
+	}
+	return wrappers.ValidateTmpSize(size)
+}
+
+func applyTmpSize(snapName string, value interface{}) error {
+	return wrappers.SetSnapTmpSize(snapName, tmpSize(value))
+}
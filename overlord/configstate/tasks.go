@@ -0,0 +1,45 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configstate
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// checkMaxWorkers checks that tasks.max-workers is set to a number of
+// workers.
+func checkMaxWorkers(key string, value interface{}) error {
+	n, ok := value.(json.Number)
+	if !ok {
+		return fmt.Errorf("cannot set %q: not a number", key)
+	}
+	if i, err := n.Int64(); err != nil || i < 0 {
+		return fmt.Errorf("cannot set %q: not a number of workers: %s", key, n)
+	}
+	return nil
+}
+
+// handleTasks validates the options of the tasks of the changes:
+// tasks.max-workers is how many tasks run at the same time, with 0 for
+// no limit.
+var handleTasks = mapOptionHandler("tasks", map[string]optionCheck{
+	"max-workers": checkMaxWorkers,
+})
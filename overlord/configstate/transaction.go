@@ -0,0 +1,142 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configstate
+
+import (
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// Config returns the whole configuration of the snap, nested as its
+// dotted keys are.
+// Note that the state must be locked by the caller.
+func Config(st *state.State, snapName string) (map[string]interface{}, error) {
+	return snapConfig(st, snapName)
+}
+
+// Transaction holds changes to the configuration of a snap that are
+// made together: if one of them fails, those made already are undone.
+type Transaction struct {
+	st       *state.State
+	snapName string
+	keys     []string
+	values   map[string]interface{}
+	snapshot map[string]interface{}
+}
+
+// NewTransaction returns a new transaction changing the configuration
+// of the snap.
+func NewTransaction(st *state.State, snapName string) *Transaction {
+	return &Transaction{
+		st:       st,
+		snapName: snapName,
+		values:   make(map[string]interface{}),
+	}
+}
+
+// Set records that the option identified by the dotted key is to be
+// set to value, or unset if value is nil. Options are changed in the
+// order they were first set.
+func (t *Transaction) Set(key string, value interface{}) error {
+	if _, err := ParseKey(key); err != nil {
+		return err
+	}
+	if _, ok := t.values[key]; !ok {
+		t.keys = append(t.keys, key)
+	}
+	t.values[key] = value
+	return nil
+}
+
+// Get unmarshals into result the value the option identified by the
+// dotted key is set to in the transaction, or has otherwise.
+// Note that the state must be locked by the caller.
+func (t *Transaction) Get(key string, result interface{}) error {
+	value, ok := t.values[key]
+	if !ok {
+		return Get(t.st, t.snapName, key, result)
+	}
+	if value == nil {
+		return &NoOptionError{SnapName: t.snapName, Key: key}
+	}
+	return remarshal(value, result)
+}
+
+// Commit makes the changes of the transaction. Should one fail, those
+// made already are undone before its error is returned.
+// Note that the state must be locked by the caller.
+func (t *Transaction) Commit() error {
+	snapshot, err := snapConfig(t.st, t.snapName)
+	if err != nil {
+		return err
+	}
+	t.snapshot = snapshot
+	for i, key := range t.keys {
+		if err := Set(t.st, t.snapName, key, t.values[key]); err != nil {
+			restoreConfig(t.st, t.snapName, t.keys[:i], snapshot)
+			return err
+		}
+	}
+	return nil
+}
+
+// Rollback undoes the changes made by Commit, putting the configuration
+// back as it was before.
+// Note that the state must be locked by the caller.
+func (t *Transaction) Rollback() error {
+	if t.snapshot == nil {
+		return nil
+	}
+	return restoreConfig(t.st, t.snapName, t.keys, t.snapshot)
+}
+
+// restoreConfig puts the configuration of the snap back to snapshot,
+// as it was before the options with the given keys were changed. The
+// options are set back to their previous values in reverse order first,
+// for the effects their handlers had to be undone too.
+func restoreConfig(st *state.State, snapName string, keys []string, snapshot map[string]interface{}) error {
+	var firstErr error
+	for i := len(keys) - 1; i >= 0; i-- {
+		parts, err := ParseKey(keys[i])
+		if err != nil {
+			return err
+		}
+		if err := Set(st, snapName, keys[i], lookup(snapshot, parts)); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	setSnapConfig(st, snapName, snapshot)
+	return firstErr
+}
+
+// lookup returns the value of the option with the given key parts in
+// the configuration, or nil if it is not set.
+func lookup(cfg map[string]interface{}, parts []string) interface{} {
+	var value interface{} = cfg
+	for _, part := range parts {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		if value, ok = m[part]; !ok {
+			return nil
+		}
+	}
+	return value
+}
@@ -45,6 +45,14 @@ func MockPruneInterval(prunei, prunew, abortw time.Duration) (restore func()) {
 	}
 }
 
+// MockMemoryCheckInterval sets how often at most the ensure loop checks
+// the memory for tests.
+func MockMemoryCheckInterval(d time.Duration) (restore func()) {
+	old := memoryCheckInterval
+	memoryCheckInterval = d
+	return func() { memoryCheckInterval = old }
+}
+
 func MockEnsureNext(o *Overlord, t time.Time) {
 	o.ensureNext = t
 }
@@ -53,3 +61,29 @@ func MockEnsureNext(o *Overlord, t time.Time) {
 func (o *Overlord) Engine() *StateEngine {
 	return o.stateEng
 }
+
+// MockChroot replaces the chroot used when preseeding.
+func MockChroot(f func(string) error) (restore func()) {
+	old := chroot
+	chroot = f
+	return func() { chroot = old }
+}
+
+// PopulateStateFromInstalled exposes populateStateFromInstalled for tests.
+var PopulateStateFromInstalled = populateStateFromInstalled
+
+// MockMemInfo replaces how the overlord learns about the memory of the
+// system.
+func MockMemInfo(f func() (total, available uint64, err error)) (restore func()) {
+	old := memInfo
+	memInfo = f
+	return func() { memInfo = old }
+}
+
+// MockFreeOSMemory replaces how the memory freed is returned to the
+// system.
+func MockFreeOSMemory(f func()) (restore func()) {
+	old := freeOSMemory
+	freeOSMemory = f
+	return func() { freeOSMemory = old }
+}
@@ -21,11 +21,18 @@ package overlord
 
 import (
 	"fmt"
+	"os"
+	"sort"
+	"syscall"
 
 	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/configstate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/overlord/statelog"
+	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snappy"
 )
 
@@ -35,13 +42,29 @@ func populateStateFromInstalled() error {
 		return err
 	}
 
-	if osutil.FileExists(dirs.SnapStateFile) {
-		return fmt.Errorf("cannot create state: state %q already exists", dirs.SnapStateFile)
+	for _, fn := range []string{dirs.SnapStateFile, dirs.SnapStateLogFile} {
+		if osutil.FileExists(fn) {
+			return fmt.Errorf("cannot create state: state %q already exists", fn)
+		}
 	}
 
-	st := state.New(&overlordStateBackend{
+	// the gadget picks how the state is kept; mistakes in it should
+	// have been caught when building the image
+	gadget, gadgetErr := installedGadgetInfo(all)
+
+	backend := &overlordStateBackend{
 		path: dirs.SnapStateFile,
-	})
+	}
+	if gadgetErr == nil && gadget.StateBackend == "log" {
+		log, err := statelog.Open(dirs.SnapStateLogFile)
+		if err != nil {
+			return err
+		}
+		defer log.Close()
+		backend.log = log
+	}
+
+	st := state.New(backend)
 	st.Lock()
 	defer st.Unlock()
 
@@ -56,6 +79,69 @@ func populateStateFromInstalled() error {
 		snapstate.Set(st, sn.Name(), &snapst)
 	}
 
+	// configure the seeded snaps as the gadget asks
+	if gadgetErr != nil {
+		logger.Noticef("cannot use gadget defaults: %v", gadgetErr)
+		return nil
+	}
+	for _, name := range configuredSnaps(all, gadget) {
+		if err := configstate.SetDefaults(st, name, gadget.Defaults[name]); err != nil {
+			logger.Noticef("cannot use gadget defaults: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// installedGadgetInfo returns the gadget information of the installed
+// gadget snap, if there is one.
+func installedGadgetInfo(all []*snappy.Snap) (*snap.GadgetInfo, error) {
+	for _, sn := range all {
+		if sn.Type() == snap.TypeGadget {
+			return snap.ReadGadgetInfo(sn.Info().MountDir())
+		}
+	}
+	return &snap.GadgetInfo{}, nil
+}
+
+// configuredSnaps returns the sorted names of the installed snaps the
+// gadget has defaults for, including the system itself.
+func configuredSnaps(all []*snappy.Snap, gadget *snap.GadgetInfo) []string {
+	var names []string
+	for name := range gadget.Defaults {
+		if name == configstate.CoreSnapName {
+			names = append(names, name)
+			continue
+		}
+		for _, sn := range all {
+			if sn.Name() == name {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CheckGadgetDefaults checks the configuration defaults the gadget
+// gives to snaps. If seeded is not nil, the defaults must be for snaps
+// in it or for the system itself.
+func CheckGadgetDefaults(gadget *snap.GadgetInfo, seeded map[string]bool) error {
+	names := make([]string, 0, len(gadget.Defaults))
+	for name := range gadget.Defaults {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if seeded != nil && !seeded[name] && name != configstate.CoreSnapName {
+			return fmt.Errorf("gadget has defaults for snap %q which is not seeded", name)
+		}
+		if err := configstate.ValidateDefaults(name, gadget.Defaults[name]); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -64,9 +150,53 @@ func populateStateFromInstalled() error {
 // an intermediate step to have working images again. We need to
 // figure out how we want first-boot to look like.
 func FirstBoot() error {
+	// the state of a preseeded image was created when it was built
+	preseeded := snappy.Preseeded()
 	if err := snappy.FirstBoot(); err != nil {
 		return err
 	}
+	if preseeded {
+		return nil
+	}
+
+	return populateStateFromInstalled()
+}
+
+var chroot = syscall.Chroot
+
+// Preseed runs the first boot steps that do not need the device
+// inside the given image root at image build time: it activates the
+// installed snaps and creates the state from them, so that first boot
+// only has to bring up the network and flag that it ran. The gadget
+// defaults are checked first, so that mistakes in them fail the image
+// build.
+func Preseed(root string) error {
+	if err := chroot(root); err != nil {
+		return fmt.Errorf("cannot chroot into %s: %v", root, err)
+	}
+	if err := os.Chdir("/"); err != nil {
+		return err
+	}
+
+	all, err := (&snappy.Overlord{}).Installed()
+	if err != nil {
+		return err
+	}
+	gadget, err := installedGadgetInfo(all)
+	if err != nil {
+		return err
+	}
+	seeded := make(map[string]bool, len(all))
+	for _, sn := range all {
+		seeded[sn.Name()] = true
+	}
+	if err := CheckGadgetDefaults(gadget, seeded); err != nil {
+		return err
+	}
+
+	if err := snappy.Preseed(); err != nil {
+		return err
+	}
 
 	return populateStateFromInstalled()
 }
@@ -0,0 +1,135 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package overlord_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord"
+	"github.com/snapcore/snapd/overlord/configstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/overlord/statelog"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/snappy"
+)
+
+type firstBootSuite struct{}
+
+var _ = Suite(&firstBootSuite{})
+
+func (s *firstBootSuite) TestPreseedChrootFails(c *C) {
+	var root string
+	restore := overlord.MockChroot(func(path string) error {
+		root = path
+		return errors.New("boom")
+	})
+	defer restore()
+
+	err := overlord.Preseed("/some/image")
+	c.Check(err, ErrorMatches, "cannot chroot into /some/image: boom")
+	c.Check(root, Equals, "/some/image")
+}
+
+func (s *firstBootSuite) TestCheckGadgetDefaults(c *C) {
+	gadget := &snap.GadgetInfo{Defaults: map[string]map[string]interface{}{
+		"foo":  {"port": 8080},
+		"core": {"some-option": "x"},
+	}}
+	c.Check(overlord.CheckGadgetDefaults(gadget, nil), IsNil)
+	c.Check(overlord.CheckGadgetDefaults(gadget, map[string]bool{"foo": true}), IsNil)
+
+	err := overlord.CheckGadgetDefaults(gadget, map[string]bool{"bar": true})
+	c.Check(err, ErrorMatches, `gadget has defaults for snap "foo" which is not seeded`)
+
+	gadget.Defaults["foo"]["Port"] = 1
+	err = overlord.CheckGadgetDefaults(gadget, nil)
+	c.Check(err, ErrorMatches, `invalid default for snap "foo": invalid option name: "Port"`)
+}
+
+func (s *firstBootSuite) TestPopulateStateAppliesGadgetDefaults(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("/")
+
+	snaptest.MockSnap(c, "name: foo\nversion: 1.0\n", &snap.SideInfo{Revision: snap.R(1)})
+	gadget := snaptest.MockSnap(c, "name: pc\nversion: 1.0\ntype: gadget\n", &snap.SideInfo{Revision: snap.R(2)})
+	c.Assert(snappy.SaveManifest(gadget), IsNil)
+	gadgetYaml := "defaults:\n  foo:\n    port: 8080\n  bar:\n    port: 1\n"
+	err := ioutil.WriteFile(filepath.Join(gadget.MountDir(), "meta", "gadget.yaml"), []byte(gadgetYaml), 0644)
+	c.Assert(err, IsNil)
+
+	c.Assert(os.MkdirAll(filepath.Dir(dirs.SnapStateFile), 0755), IsNil)
+	c.Assert(overlord.PopulateStateFromInstalled(), IsNil)
+
+	data, err := ioutil.ReadFile(dirs.SnapStateFile)
+	c.Assert(err, IsNil)
+	st, err := state.ReadState(nil, bytes.NewReader(data))
+	c.Assert(err, IsNil)
+	st.Lock()
+	defer st.Unlock()
+
+	var port json.Number
+	c.Assert(configstate.Get(st, "foo", "port", &port), IsNil)
+	c.Check(port, Equals, json.Number("8080"))
+
+	// not seeded, so not configured
+	err = configstate.Get(st, "bar", "port", &port)
+	c.Check(err, FitsTypeOf, &configstate.NoOptionError{})
+}
+
+func (s *firstBootSuite) TestPopulateStateUsesGadgetStateBackend(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("/")
+
+	snaptest.MockSnap(c, "name: foo\nversion: 1.0\n", &snap.SideInfo{Revision: snap.R(1)})
+	gadget := snaptest.MockSnap(c, "name: pc\nversion: 1.0\ntype: gadget\n", &snap.SideInfo{Revision: snap.R(2)})
+	c.Assert(snappy.SaveManifest(gadget), IsNil)
+	gadgetYaml := "state-backend: log\ndefaults:\n  foo:\n    port: 8080\n"
+	err := ioutil.WriteFile(filepath.Join(gadget.MountDir(), "meta", "gadget.yaml"), []byte(gadgetYaml), 0644)
+	c.Assert(err, IsNil)
+
+	c.Assert(os.MkdirAll(filepath.Dir(dirs.SnapStateFile), 0755), IsNil)
+	c.Assert(overlord.PopulateStateFromInstalled(), IsNil)
+
+	c.Check(osutil.FileExists(dirs.SnapStateFile), Equals, false)
+	data, err := statelog.ReadData(dirs.SnapStateLogFile)
+	c.Assert(err, IsNil)
+	st, err := state.ReadState(nil, bytes.NewReader(data))
+	c.Assert(err, IsNil)
+	st.Lock()
+	defer st.Unlock()
+
+	var port json.Number
+	c.Assert(configstate.Get(st, "foo", "port", &port), IsNil)
+	c.Check(port, Equals, json.Number("8080"))
+
+	// the state is only created once
+	err = overlord.PopulateStateFromInstalled()
+	c.Check(err, ErrorMatches, `cannot create state: state ".*/state.log" already exists`)
+}
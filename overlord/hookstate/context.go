@@ -58,6 +58,12 @@ func (c *Context) Unlock() {
 	c.task.State().Unlock()
 }
 
+// State returns the state the hook is running against, for handlers
+// to make changes to it while holding the lock.
+func (c *Context) State() *state.State {
+	return c.task.State()
+}
+
 // Set associates value with key.
 // The provided value must properly marshal and unmarshal with encoding/json.
 func (c *Context) Set(key string, value interface{}) {
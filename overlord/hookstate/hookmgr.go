@@ -23,7 +23,11 @@ package hookstate
 
 import (
 	"fmt"
+	"os"
+	"os/exec"
 	"regexp"
+	"sort"
+	"strings"
 
 	"gopkg.in/tomb.v2"
 
@@ -60,6 +64,9 @@ type hookSetup struct {
 	Snap     string        `json:"snap"`
 	Revision snap.Revision `json:"revision"`
 	Hook     string        `json:"hook"`
+
+	// Env holds the variables added to the environment of the hook.
+	Env map[string]string `json:"env,omitempty"`
 }
 
 // Manager returns a new HookManager.
@@ -83,6 +90,25 @@ func HookTask(s *state.State, taskSummary, snapName string, revision snap.Revisi
 	return task
 }
 
+// HookTaskWithEnv returns a task that will run the specified hook with
+// the given variables added to its environment.
+func HookTaskWithEnv(s *state.State, taskSummary, snapName string, revision snap.Revision, hookName string, env map[string]string) *state.Task {
+	task := s.NewTask("run-hook", taskSummary)
+	task.Set("hook-setup", hookSetup{Snap: snapName, Revision: revision, Hook: hookName, Env: env})
+	return task
+}
+
+// HookTaskWithContext returns a task that will run the specified hook
+// with the given values already in its context, for its handler to get.
+func HookTaskWithContext(s *state.State, taskSummary, snapName string, revision snap.Revision, hookName string, values map[string]interface{}) *state.Task {
+	task := HookTask(s, taskSummary, snapName, revision, hookName)
+	context := &Context{task: task}
+	for key, value := range values {
+		context.Set(key, value)
+	}
+	return task
+}
+
 // Register requests that a given handler generator be called when a matching
 // hook is run, and the handler be used for the hook.
 //
@@ -143,13 +169,102 @@ func (m *HookManager) doRunHook(task *state.Task, tomb *tomb.Tomb) error {
 		return err
 	}
 
-	// TODO: Actually dispatch the hook.
+	info, err := snap.ReadInfo(setup.Snap, &snap.SideInfo{Revision: setup.Revision})
+	if err != nil {
+		return fmt.Errorf("cannot read snap %q: %v", setup.Snap, err)
+	}
+
+	// Hooks the snap does not have are skipped.
+	if info.Hooks[setup.Hook] != nil {
+		output, err := runHook(setup.Snap, setup.Revision, setup.Hook, hookEnv(setup.Env), tomb)
+		if len(output) > 0 {
+			task.State().Lock()
+			task.SetOutput(string(output))
+			task.State().Unlock()
+		}
+		if err != nil {
+			err = hookError(setup.Hook, output, err)
+			if handlerErr := handler.Error(err); handlerErr != nil {
+				return handlerErr
+			}
+			return err
+		}
+	}
 
-	// Done with the hook. TODO: Check the result, if success call Done(), if
-	// error, call Error(). Since we have no hooks, for now we just call Done().
+	// Done with the hook
 	if err := handler.Done(); err != nil {
 		return err
 	}
 
 	return nil
 }
+
+// hookError returns the error for the failed hook, with the last line of
+// its output for context; the whole of it is kept in the task.
+func hookError(hookName string, output []byte, err error) error {
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	if last := strings.TrimSpace(lines[len(lines)-1]); last != "" {
+		return fmt.Errorf("cannot run hook %q: %v: %s", hookName, err, last)
+	}
+	return fmt.Errorf("cannot run hook %q: %v", hookName, err)
+}
+
+// maxHookOutput bounds the memory used capturing the output of a hook;
+// only the end of it is kept in the task anyway.
+const maxHookOutput = 1024 * 1024
+
+// tailBuffer is a writer keeping (at least) the last max bytes written.
+type tailBuffer struct {
+	max int
+	buf []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > 2*b.max {
+		b.buf = append(b.buf[:0], b.buf[len(b.buf)-b.max:]...)
+	}
+	return len(p), nil
+}
+
+// hookEnv returns the given variables in the KEY=VALUE form, sorted.
+func hookEnv(vars map[string]string) []string {
+	env := make([]string, 0, len(vars))
+	for k, v := range vars {
+		env = append(env, k+"="+v)
+	}
+	sort.Strings(env)
+	return env
+}
+
+// runHookAndWait runs the hook of the snap through "snap run", with the
+// given variables added to the environment, returning its combined
+// standard output and error. The hook is killed if the task is aborted.
+func runHookAndWait(snapName string, revision snap.Revision, hookName string, env []string, tomb *tomb.Tomb) ([]byte, error) {
+	cmd := exec.Command("snap", "run", "--hook", hookName, "-r", revision.String(), snapName)
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	output := &tailBuffer{max: maxHookOutput}
+	cmd.Stdout = output
+	cmd.Stderr = output
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		return output.buf, err
+	case <-tomb.Dying():
+		cmd.Process.Kill()
+		<-done
+		return output.buf, fmt.Errorf("aborted")
+	}
+}
+
+var runHook = runHookAndWait
@@ -29,6 +29,7 @@ import (
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/testutil"
 )
 
 type hookManagerSuite struct {
@@ -96,3 +97,42 @@ func (s *hookManagerSuite) TestHookSetupJsonUnmarshal(c *C) {
 	c.Check(setup.Revision, Equals, snap.R(1))
 	c.Check(setup.Hook, Equals, "hook-name")
 }
+
+func (s *hookManagerSuite) TestRunHookAndWait(c *C) {
+	cmd := testutil.MockCommand(c, "snap", "echo out; echo err >&2; exit 1")
+	defer cmd.Restore()
+
+	output, err := runHookAndWait("foo", snap.R(3), "configure", nil, &tomb.Tomb{})
+	c.Check(err, ErrorMatches, "exit status 1")
+	c.Check(string(output), Equals, "out\nerr\n")
+	c.Check(cmd.Calls(), DeepEquals, [][]string{{"snap", "run", "--hook", "configure", "-r", "3", "foo"}})
+}
+
+func (s *hookManagerSuite) TestRunHookAndWaitEnv(c *C) {
+	cmd := testutil.MockCommand(c, "snap", "echo $SNAP_FOO")
+	defer cmd.Restore()
+
+	output, err := runHookAndWait("foo", snap.R(3), "configure", []string{"SNAP_FOO=bar"}, &tomb.Tomb{})
+	c.Check(err, IsNil)
+	c.Check(string(output), Equals, "bar\n")
+}
+
+func (s *hookManagerSuite) TestRunHookAndWaitAborted(c *C) {
+	cmd := testutil.MockCommand(c, "snap", "sleep 60")
+	defer cmd.Restore()
+
+	var tb tomb.Tomb
+	tb.Kill(nil)
+	_, err := runHookAndWait("foo", snap.R(3), "configure", nil, &tb)
+	c.Check(err, ErrorMatches, "aborted")
+}
+
+func (s *hookManagerSuite) TestTailBuffer(c *C) {
+	b := &tailBuffer{max: 4}
+	for _, p := range []string{"abc", "def", "ghi"} {
+		n, err := b.Write([]byte(p))
+		c.Assert(err, IsNil)
+		c.Assert(n, Equals, 3)
+	}
+	c.Check(string(b.buf), Equals, "fghi")
+}
@@ -20,16 +20,19 @@
 package hookstate_test
 
 import (
+	"errors"
 	"fmt"
 	"regexp"
 	"testing"
 
 	. "gopkg.in/check.v1"
+	"gopkg.in/tomb.v2"
 
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
 )
 
 func TestHookManager(t *testing.T) { TestingT(t) }
@@ -40,8 +43,20 @@ type hookManagerSuite struct {
 	mockHandler *mockHandler
 	task        *state.Task
 	change      *state.Change
+
+	hookRuns   []string
+	hookEnv    []string
+	hookOutput string
+	hookErr    error
+	restore    func()
 }
 
+const testSnapYaml = `name: test-snap
+version: 1.0
+hooks:
+  test-hook:
+`
+
 var _ = Suite(&hookManagerSuite{})
 
 func (s *hookManagerSuite) SetUpTest(c *C) {
@@ -51,6 +66,17 @@ func (s *hookManagerSuite) SetUpTest(c *C) {
 	c.Assert(err, IsNil)
 	s.manager = manager
 
+	snaptest.MockSnap(c, testSnapYaml, &snap.SideInfo{Revision: snap.R(1)})
+	s.hookRuns = nil
+	s.hookEnv = nil
+	s.hookOutput = ""
+	s.hookErr = nil
+	s.restore = hookstate.MockRunHook(func(snapName string, revision snap.Revision, hookName string, env []string, tomb *tomb.Tomb) ([]byte, error) {
+		s.hookRuns = append(s.hookRuns, fmt.Sprintf("%s:%s:%s", snapName, revision, hookName))
+		s.hookEnv = env
+		return []byte(s.hookOutput), s.hookErr
+	})
+
 	s.state.Lock()
 	s.task = hookstate.HookTask(s.state, "test summary", "test-snap", snap.R(1), "test-hook")
 	c.Assert(s.task, NotNil, Commentf("Expected HookTask to return a task"))
@@ -61,6 +87,7 @@ func (s *hookManagerSuite) SetUpTest(c *C) {
 }
 
 func (s *hookManagerSuite) TearDownTest(c *C) {
+	s.restore()
 	s.manager.Stop()
 	dirs.SetRootDir("")
 }
@@ -99,6 +126,144 @@ func (s *hookManagerSuite) TestHookTask(c *C) {
 	c.Check(s.task.Kind(), Equals, "run-hook")
 	c.Check(s.task.Status(), Equals, state.DoneStatus)
 	c.Check(s.change.Status(), Equals, state.DoneStatus)
+
+	c.Check(s.hookRuns, DeepEquals, []string{"test-snap:1:test-hook"})
+}
+
+func (s *hookManagerSuite) TestHookTaskWithEnv(c *C) {
+	s.manager.Register(regexp.MustCompile("test-hook"), func(*hookstate.Context) hookstate.Handler {
+		return newMockHandler()
+	})
+
+	s.state.Lock()
+	task := hookstate.HookTaskWithEnv(s.state, "test summary", "test-snap", snap.R(1), "test-hook", map[string]string{
+		"SNAP_B": "2",
+		"SNAP_A": "1",
+	})
+	s.change.AddTask(task)
+	task.WaitFor(s.task)
+	s.state.Unlock()
+
+	s.manager.Ensure()
+	s.manager.Wait()
+	s.manager.Ensure()
+	s.manager.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(task.Status(), Equals, state.DoneStatus)
+	c.Check(s.hookRuns, DeepEquals, []string{"test-snap:1:test-hook", "test-snap:1:test-hook"})
+	c.Check(s.hookEnv, DeepEquals, []string{"SNAP_A=1", "SNAP_B=2"})
+}
+
+func (s *hookManagerSuite) TestHookTaskWithContext(c *C) {
+	var value string
+	var getErr error
+	s.manager.Register(regexp.MustCompile("other-hook"), func(context *hookstate.Context) hookstate.Handler {
+		context.Lock()
+		getErr = context.Get("greeting", &value)
+		context.Unlock()
+		return newMockHandler()
+	})
+	s.manager.Register(regexp.MustCompile("test-hook"), func(*hookstate.Context) hookstate.Handler {
+		return newMockHandler()
+	})
+
+	s.state.Lock()
+	task := hookstate.HookTaskWithContext(s.state, "test summary", "test-snap", snap.R(1), "other-hook", map[string]interface{}{
+		"greeting": "hello",
+	})
+	s.change.AddTask(task)
+	task.WaitFor(s.task)
+	s.state.Unlock()
+
+	s.manager.Ensure()
+	s.manager.Wait()
+	s.manager.Ensure()
+	s.manager.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(task.Status(), Equals, state.DoneStatus)
+	c.Assert(getErr, IsNil)
+	c.Check(value, Equals, "hello")
+}
+
+func (s *hookManagerSuite) TestHookTaskKeepsOutput(c *C) {
+	s.manager.Register(regexp.MustCompile("test-hook"), func(*hookstate.Context) hookstate.Handler {
+		return newMockHandler()
+	})
+	s.hookOutput = "all good\n"
+
+	s.manager.Ensure()
+	s.manager.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(s.task.Status(), Equals, state.DoneStatus)
+	c.Check(s.task.Output(), Equals, "all good\n")
+}
+
+func (s *hookManagerSuite) TestHookTaskMissingHookIsSkipped(c *C) {
+	snaptest.MockSnap(c, "name: test-snap\nversion: 1.0\n", &snap.SideInfo{Revision: snap.R(1)})
+	mockHandler := newMockHandler()
+	s.manager.Register(regexp.MustCompile("test-hook"), func(*hookstate.Context) hookstate.Handler {
+		return mockHandler
+	})
+
+	s.manager.Ensure()
+	s.manager.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(s.hookRuns, HasLen, 0)
+	c.Check(mockHandler.doneCalled, Equals, true)
+	c.Check(s.task.Status(), Equals, state.DoneStatus)
+}
+
+func (s *hookManagerSuite) TestHookTaskHookFails(c *C) {
+	mockHandler := newMockHandler()
+	s.manager.Register(regexp.MustCompile("test-hook"), func(*hookstate.Context) hookstate.Handler {
+		return mockHandler
+	})
+	s.hookOutput = "starting\nerror: cannot frobnicate\n"
+	s.hookErr = errors.New("exit status 1")
+
+	s.manager.Ensure()
+	s.manager.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(mockHandler.doneCalled, Equals, false)
+	c.Check(mockHandler.errorCalled, Equals, true)
+	c.Check(mockHandler.err, ErrorMatches, `cannot run hook "test-hook": exit status 1: error: cannot frobnicate`)
+
+	c.Check(s.task.Status(), Equals, state.ErrorStatus)
+	c.Check(s.change.Status(), Equals, state.ErrorStatus)
+	c.Check(s.task.Output(), Equals, "starting\nerror: cannot frobnicate\n")
+	checkTaskLogContains(c, s.task, regexp.MustCompile(`.*cannot run hook "test-hook": exit status 1: error: cannot frobnicate`))
+}
+
+func (s *hookManagerSuite) TestHookTaskHookFailsWithoutOutput(c *C) {
+	s.manager.Register(regexp.MustCompile("test-hook"), func(*hookstate.Context) hookstate.Handler {
+		return newMockHandler()
+	})
+	s.hookErr = errors.New("exit status 1")
+
+	s.manager.Ensure()
+	s.manager.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(s.task.Status(), Equals, state.ErrorStatus)
+	c.Check(s.task.Output(), Equals, "")
+	checkTaskLogContains(c, s.task, regexp.MustCompile(`.*cannot run hook "test-hook": exit status 1$`))
 }
 
 func (s *hookManagerSuite) TestHookTaskHandlerBeforeError(c *C) {
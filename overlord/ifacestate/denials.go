@@ -0,0 +1,277 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ifacestate
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// denialLogs are the logs apparmor denials can be found in, the audit
+// log being used if auditd runs.
+var denialLogs = []string{"/var/log/audit/audit.log", "/var/log/kern.log", "/var/log/syslog"}
+
+var (
+	// denialsInterval is how often the logs are looked at for new
+	// denials.
+	denialsInterval = time.Minute
+	// denialsMaxRead is how much of a log is read when looking at it
+	// for the first time.
+	denialsMaxRead int64 = 4 << 20
+	// maxDenials is how many kinds of denials are kept for each snap,
+	// the ones seen the least recently being dropped.
+	maxDenials = 100
+)
+
+// Denial aggregates the apparmor denials of the same access by an app
+// or a hook of a snap.
+type Denial struct {
+	Snap string `json:"snap"`
+	App  string `json:"app,omitempty"`
+	Hook string `json:"hook,omitempty"`
+
+	Operation  string `json:"operation"`
+	Path       string `json:"path,omitempty"`
+	Capability string `json:"capability,omitempty"`
+	// Denied is the mask of the denied file permissions, e.g. "rw".
+	Denied string `json:"denied,omitempty"`
+
+	Count     int       `json:"count"`
+	FirstSeen time.Time `json:"first-seen"`
+	LastSeen  time.Time `json:"last-seen"`
+}
+
+func (d *Denial) same(other *Denial) bool {
+	return d.App == other.App && d.Hook == other.Hook && d.Operation == other.Operation &&
+		d.Path == other.Path && d.Capability == other.Capability && d.Denied == other.Denied
+}
+
+// denialLogPosition is how far the denials of a log were collected.
+type denialLogPosition struct {
+	Path   string `json:"path"`
+	Offset int64  `json:"offset"`
+}
+
+// denialLog returns the first of the logs apparmor denials can be found
+// in that exists, or an empty string.
+func denialLog() string {
+	for _, log := range denialLogs {
+		path := filepath.Join(dirs.GlobalRootDir, log)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// readDenials returns the apparmor denials logged in complete lines of
+// the given log from the given offset on, and the offset of what is
+// left to read. A log found shorter than the offset was rotated, and is
+// read from the start.
+func readDenials(path string, offset int64) ([]*apparmor.Denial, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, offset, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, offset, err
+	}
+
+	partial := false
+	if fi.Size() < offset {
+		offset = 0
+	}
+	if offset == 0 && fi.Size() > denialsMaxRead {
+		offset = fi.Size() - denialsMaxRead
+		partial = true
+	}
+	if _, err := f.Seek(offset, 0); err != nil {
+		return nil, offset, err
+	}
+
+	var denials []*apparmor.Denial
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadString('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, offset, err
+		}
+		offset += int64(len(line))
+		if partial {
+			partial = false
+			continue
+		}
+		if d, ok := apparmor.ParseDenial(line); ok {
+			denials = append(denials, d)
+		}
+	}
+	return denials, offset, nil
+}
+
+// addDenial adds the given denial to the ones of its snap, unless it is
+// not by a snap.
+func addDenial(all map[string][]*Denial, d *apparmor.Denial) {
+	parts := strings.SplitN(d.Profile, ".", 3)
+	if len(parts) != 3 || parts[0] != "snap" {
+		return
+	}
+	denial := &Denial{
+		Snap:       parts[1],
+		App:        parts[2],
+		Operation:  d.Operation,
+		Path:       d.Name,
+		Capability: d.Capability,
+		Denied:     d.Denied,
+		Count:      1,
+		FirstSeen:  d.Time,
+		LastSeen:   d.Time,
+	}
+	if strings.HasPrefix(denial.App, "hook.") {
+		denial.Hook = strings.TrimPrefix(denial.App, "hook.")
+		denial.App = ""
+	}
+	if denial.Capability != "" {
+		denial.Path = ""
+	}
+
+	denials := all[denial.Snap]
+	for _, known := range denials {
+		if known.same(denial) {
+			known.Count++
+			if known.LastSeen.Before(denial.LastSeen) {
+				known.LastSeen = denial.LastSeen
+			}
+			return
+		}
+	}
+	denials = append(denials, denial)
+	if len(denials) > maxDenials {
+		oldest := 0
+		for i, known := range denials {
+			if known.LastSeen.Before(denials[oldest].LastSeen) {
+				oldest = i
+			}
+		}
+		denials = append(denials[:oldest], denials[oldest+1:]...)
+	}
+	all[denial.Snap] = denials
+}
+
+// ensureDenials collects the apparmor denials logged since last time,
+// at most every denialsInterval.
+func (m *InterfaceManager) ensureDenials() {
+	now := time.Now()
+	if now.Before(m.nextDenialsCollect) {
+		return
+	}
+	m.nextDenialsCollect = now.Add(denialsInterval)
+	if err := m.collectDenials(); err != nil {
+		logger.Noticef("cannot collect apparmor denials: %v", err)
+	}
+}
+
+// collectDenials reads the apparmor denials logged since last time, and
+// aggregates them per snap in the state. How far the log was read is
+// only written to the state along with new denials; lines read since
+// without any are read again after a restart, to no effect.
+func (m *InterfaceManager) collectDenials() error {
+	path := denialLog()
+	if path == "" {
+		return nil
+	}
+
+	st := m.state
+	if m.denialsLog == nil {
+		var pos denialLogPosition
+		st.Lock()
+		err := st.Get("denials-log", &pos)
+		st.Unlock()
+		if err != nil && err != state.ErrNoState {
+			return err
+		}
+		m.denialsLog = &pos
+	}
+	pos := m.denialsLog
+	if pos.Path != path {
+		*pos = denialLogPosition{Path: path}
+	}
+
+	denials, offset, err := readDenials(path, pos.Offset)
+	if err != nil {
+		return err
+	}
+	pos.Offset = offset
+	if len(denials) == 0 {
+		return nil
+	}
+
+	st.Lock()
+	defer st.Unlock()
+	all := make(map[string][]*Denial)
+	if err := st.Get("denials", &all); err != nil && err != state.ErrNoState {
+		return err
+	}
+	for _, d := range denials {
+		addDenial(all, d)
+	}
+	st.Set("denials", all)
+	st.Set("denials-log", pos)
+	return nil
+}
+
+type byLastSeen []*Denial
+
+func (s byLastSeen) Len() int           { return len(s) }
+func (s byLastSeen) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byLastSeen) Less(i, j int) bool { return s[i].LastSeen.After(s[j].LastSeen) }
+
+// Denials returns the apparmor denials collected for the given snap, or
+// for all the snaps if snapName is empty, the most recently seen first.
+func Denials(st *state.State, snapName string) ([]*Denial, error) {
+	var all map[string][]*Denial
+	if err := st.Get("denials", &all); err != nil && err != state.ErrNoState {
+		return nil, err
+	}
+	var denials []*Denial
+	if snapName != "" {
+		denials = all[snapName]
+	} else {
+		for _, snapDenials := range all {
+			denials = append(denials, snapDenials...)
+		}
+	}
+	sort.Stable(byLastSeen(denials))
+	return denials, nil
+}
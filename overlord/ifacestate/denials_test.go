@@ -0,0 +1,194 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ifacestate_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/ifacestate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+func denialLine(sec int, profile, name, mask string) string {
+	return fmt.Sprintf(`kernel: audit: type=1400 audit(%d.000:1): apparmor="DENIED" operation="open" profile=%q name=%q pid=1 comm="foo" requested_mask=%q denied_mask=%q`+"\n", sec, profile, name, mask, mask)
+}
+
+func (s *interfaceManagerSuite) writeLog(c *C, name string, lines ...string) string {
+	path := filepath.Join(dirs.GlobalRootDir, "var", "log", name)
+	c.Assert(os.MkdirAll(filepath.Dir(path), 0755), IsNil)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	c.Assert(err, IsNil)
+	defer f.Close()
+	for _, line := range lines {
+		_, err := f.WriteString(line)
+		c.Assert(err, IsNil)
+	}
+	return path
+}
+
+func (s *interfaceManagerSuite) denials(c *C, snapName string) []*ifacestate.Denial {
+	s.state.Lock()
+	defer s.state.Unlock()
+	denials, err := ifacestate.Denials(s.state, snapName)
+	c.Assert(err, IsNil)
+	return denials
+}
+
+func (s *interfaceManagerSuite) TestCollectDenials(c *C) {
+	mgr := s.manager(c)
+	s.writeLog(c, "kern.log",
+		denialLine(10, "snap.foo.app", "/media/usb0/a", "r"),
+		"kernel: usb 1-1: new high-speed USB device\n",
+		denialLine(20, "snap.foo.app", "/media/usb0/a", "r"),
+		denialLine(15, "snap.foo.hook.configure", "/etc/foo", "w"),
+		`audit(30.000:2): apparmor="DENIED" operation="capable" profile="snap.bar.svc" capability=12 capname="net_admin"`+"\n",
+		denialLine(40, "/usr/sbin/cupsd", "/etc/cups", "w"),
+		// not complete yet
+		`audit(50.000:3): apparmor="DENIED" operation="open" profile="snap.foo.app"`,
+	)
+
+	c.Assert(mgr.CollectDenials(), IsNil)
+	c.Check(s.denials(c, "foo"), DeepEquals, []*ifacestate.Denial{{
+		Snap:      "foo",
+		App:       "app",
+		Operation: "open",
+		Path:      "/media/usb0/a",
+		Denied:    "r",
+		Count:     2,
+		FirstSeen: time.Unix(10, 0).UTC(),
+		LastSeen:  time.Unix(20, 0).UTC(),
+	}, {
+		Snap:      "foo",
+		Hook:      "configure",
+		Operation: "open",
+		Path:      "/etc/foo",
+		Denied:    "w",
+		Count:     1,
+		FirstSeen: time.Unix(15, 0).UTC(),
+		LastSeen:  time.Unix(15, 0).UTC(),
+	}})
+	c.Check(s.denials(c, "bar"), DeepEquals, []*ifacestate.Denial{{
+		Snap:       "bar",
+		App:        "svc",
+		Operation:  "capable",
+		Capability: "net_admin",
+		Count:      1,
+		FirstSeen:  time.Unix(30, 0).UTC(),
+		LastSeen:   time.Unix(30, 0).UTC(),
+	}})
+	c.Check(s.denials(c, ""), HasLen, 3)
+
+	// only what was logged since is collected next time, starting with
+	// the line that was not complete
+	s.writeLog(c, "kern.log",
+		` name="/media/usb0/b" denied_mask="r"`+"\n",
+		denialLine(60, "snap.foo.app", "/media/usb0/a", "r"),
+	)
+	c.Assert(mgr.CollectDenials(), IsNil)
+	denials := s.denials(c, "foo")
+	c.Assert(denials, HasLen, 3)
+	c.Check(denials[0].Path, Equals, "/media/usb0/a")
+	c.Check(denials[0].Count, Equals, 3)
+	c.Check(denials[1].Path, Equals, "/media/usb0/b")
+}
+
+func (s *interfaceManagerSuite) TestCollectDenialsRotatedAndAudit(c *C) {
+	mgr := s.manager(c)
+	kernLog := s.writeLog(c, "kern.log",
+		denialLine(10, "snap.foo.app", "/srv/a", "r"),
+		denialLine(11, "snap.foo.app", "/srv/b", "r"),
+	)
+	c.Assert(mgr.CollectDenials(), IsNil)
+	c.Check(s.denials(c, "foo"), HasLen, 2)
+
+	// rotated
+	c.Assert(ioutil.WriteFile(kernLog, []byte(denialLine(12, "snap.foo.app", "/srv/c", "r")), 0644), IsNil)
+	c.Assert(mgr.CollectDenials(), IsNil)
+	c.Check(s.denials(c, "foo"), HasLen, 3)
+
+	// the audit log is preferred once there is one
+	s.writeLog(c, "audit/audit.log", denialLine(13, "snap.foo.app", "/srv/d", "r"))
+	c.Assert(mgr.CollectDenials(), IsNil)
+	denials := s.denials(c, "foo")
+	c.Assert(denials, HasLen, 4)
+	c.Check(denials[0].Path, Equals, "/srv/d")
+}
+
+func (s *interfaceManagerSuite) TestCollectDenialsPersistsOffsetOnlyWithDenials(c *C) {
+	mgr := s.manager(c)
+	s.writeLog(c, "kern.log", "kernel: usb 1-1: new high-speed USB device\n")
+	c.Assert(mgr.CollectDenials(), IsNil)
+
+	var pos map[string]interface{}
+	s.state.Lock()
+	err := s.state.Get("denials-log", &pos)
+	s.state.Unlock()
+	c.Check(err, Equals, state.ErrNoState)
+
+	s.writeLog(c, "kern.log", denialLine(10, "snap.foo.app", "/srv/a", "r"))
+	c.Assert(mgr.CollectDenials(), IsNil)
+	c.Check(s.denials(c, "foo"), HasLen, 1)
+
+	// a new manager resumes from what was stored along with the denials
+	s.writeLog(c, "kern.log", denialLine(11, "snap.foo.app", "/srv/a", "r"))
+	mgr, err = ifacestate.Manager(s.state, nil)
+	c.Assert(err, IsNil)
+	c.Assert(mgr.CollectDenials(), IsNil)
+	denials := s.denials(c, "foo")
+	c.Assert(denials, HasLen, 1)
+	c.Check(denials[0].Count, Equals, 2)
+}
+
+func (s *interfaceManagerSuite) TestCollectDenialsLimits(c *C) {
+	line := denialLine(10, "snap.foo.app", "/srv/a", "r")
+	restore := ifacestate.MockDenialsLimits(int64(len(line)+10), 2)
+	defer restore()
+
+	mgr := s.manager(c)
+	// only the end of a long log is read the first time, skipping the
+	// partial line
+	s.writeLog(c, "kern.log",
+		denialLine(9, "snap.foo.app", "/srv/old", "r"),
+		line,
+	)
+	c.Assert(mgr.CollectDenials(), IsNil)
+	denials := s.denials(c, "foo")
+	c.Assert(denials, HasLen, 1)
+	c.Check(denials[0].Path, Equals, "/srv/a")
+
+	s.writeLog(c, "kern.log",
+		denialLine(11, "snap.foo.app", "/srv/b", "r"),
+		denialLine(12, "snap.foo.app", "/srv/c", "r"),
+		denialLine(13, "snap.foo.app", "/srv/b", "r"),
+		denialLine(14, "snap.foo.app", "/srv/d", "r"),
+	)
+	c.Assert(mgr.CollectDenials(), IsNil)
+	denials = s.denials(c, "foo")
+	c.Assert(denials, HasLen, 2)
+	c.Check(denials[0].Path, Equals, "/srv/d")
+	c.Check(denials[1].Path, Equals, "/srv/b")
+}
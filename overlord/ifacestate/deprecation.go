@@ -0,0 +1,71 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ifacestate
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/snap"
+)
+
+// deprecations returns the deprecated interfaces and attributes.
+var deprecations = builtin.Deprecations
+
+func plugDeprecationWarnings(plug *snap.PlugInfo) []string {
+	var warnings []string
+	for _, d := range interfaces.FindDeprecations(deprecations(), plug.Interface, plug.Attrs) {
+		warnings = append(warnings, fmt.Sprintf("plug %s:%s: %s", plug.Snap.Name(), plug.Name, d.Message()))
+	}
+	return warnings
+}
+
+func slotDeprecationWarnings(slot *snap.SlotInfo) []string {
+	var warnings []string
+	for _, d := range interfaces.FindDeprecations(deprecations(), slot.Interface, slot.Attrs) {
+		warnings = append(warnings, fmt.Sprintf("slot %s:%s: %s", slot.Snap.Name(), slot.Name, d.Message()))
+	}
+	return warnings
+}
+
+// deprecationWarnings returns warnings about the plugs and slots of the
+// snap that use deprecated interfaces or attributes.
+func deprecationWarnings(snapInfo *snap.Info) []string {
+	var warnings []string
+	plugNames := make([]string, 0, len(snapInfo.Plugs))
+	for name := range snapInfo.Plugs {
+		plugNames = append(plugNames, name)
+	}
+	sort.Strings(plugNames)
+	for _, name := range plugNames {
+		warnings = append(warnings, plugDeprecationWarnings(snapInfo.Plugs[name])...)
+	}
+	slotNames := make([]string, 0, len(snapInfo.Slots))
+	for name := range snapInfo.Slots {
+		slotNames = append(slotNames, name)
+	}
+	sort.Strings(slotNames)
+	for _, name := range slotNames {
+		warnings = append(warnings, slotDeprecationWarnings(snapInfo.Slots[name])...)
+	}
+	return warnings
+}
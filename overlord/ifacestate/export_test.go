@@ -18,3 +18,29 @@
  */
 
 package ifacestate
+
+import (
+	"github.com/snapcore/snapd/interfaces"
+)
+
+func MockVerifyPolicySignature(mock func(policyPath, sigPath, keyring string) error) (restore func()) {
+	old := verifyPolicySignature
+	verifyPolicySignature = mock
+	return func() { verifyPolicySignature = old }
+}
+
+func MockDeprecations(deps []*interfaces.Deprecation) (restore func()) {
+	old := deprecations
+	deprecations = func() []*interfaces.Deprecation { return deps }
+	return func() { deprecations = old }
+}
+
+func (m *InterfaceManager) CollectDenials() error {
+	return m.collectDenials()
+}
+
+func MockDenialsLimits(maxRead int64, max int) (restore func()) {
+	oldMaxRead, oldMax := denialsMaxRead, maxDenials
+	denialsMaxRead, maxDenials = maxRead, max
+	return func() { denialsMaxRead, maxDenials = oldMaxRead, oldMax }
+}
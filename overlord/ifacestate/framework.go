@@ -0,0 +1,98 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ifacestate
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+// profileRegenerator is a security backend able to write the profiles
+// of a snap again, leaving loading them to the caller.
+type profileRegenerator interface {
+	Regenerate(snapInfo *snap.Info, devMode bool, repo *interfaces.Repository) ([]string, error)
+}
+
+// frameworkUsers returns the given framework snap and the snaps with
+// plugs connected to its slots, which use its policy.
+func (m *InterfaceManager) frameworkUsers(framework string) []string {
+	users := map[string]bool{framework: true}
+	for _, slot := range m.repo.Slots(framework) {
+		for _, plugRef := range slot.Connections {
+			users[plugRef.Snap] = true
+		}
+	}
+	names := make([]string, 0, len(users))
+	for name := range users {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RegenerateProfiles writes again, with the named security backend, the
+// profiles of the given framework snap and of the snaps connected to
+// its slots, and returns their paths; snaps not installed are skipped.
+// It is meant to be given to the policy manager installing the policy
+// of the framework, with policy.WithRegenerator, as the profiles are
+// made from that policy.
+func (m *InterfaceManager) RegenerateProfiles(backendName, framework string) ([]string, error) {
+	var backend profileRegenerator
+	for _, b := range securityBackends {
+		if r, ok := b.(profileRegenerator); ok && b.Name() == backendName {
+			backend = r
+		}
+	}
+	if backend == nil {
+		return nil, fmt.Errorf("cannot regenerate %s profiles: backend unknown or without regeneration", backendName)
+	}
+
+	var profiles []string
+	for _, snapName := range m.frameworkUsers(framework) {
+		m.state.Lock()
+		var snapst snapstate.SnapState
+		err := snapstate.Get(m.state, snapName, &snapst)
+		var snapInfo *snap.Info
+		if err == nil && snapst.Current() != nil {
+			snapInfo, err = snapstate.Info(m.state, snapName, snapst.Current().Revision)
+		}
+		m.state.Unlock()
+		if err == state.ErrNoState || (err == nil && snapInfo == nil) {
+			// not installed (yet), nothing to regenerate
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot regenerate %s profiles of snap %q: %v", backendName, snapName, err)
+		}
+		snap.AddImplicitSlots(snapInfo)
+		snapInfo.ReadOnlyData = !snapst.ReadOnlyData.Unset() && snapst.ReadOnlyData == snapInfo.Revision
+		written, err := backend.Regenerate(snapInfo, snapst.DevMode(), m.repo)
+		if err != nil {
+			return nil, fmt.Errorf("cannot regenerate %s profiles of snap %q: %v", backendName, snapName, err)
+		}
+		profiles = append(profiles, written...)
+	}
+	return profiles, nil
+}
@@ -61,30 +61,30 @@ func (m *InterfaceManager) doSetupProfiles(task *state.Task, _ *tomb.Tomb) error
 	// The snap may have been updated so perform the following operation to
 	// ensure that we are always working on the correct state:
 	//
-	// - disconnect all connections to/from the given snap
-	//   - remembering the snaps that were affected by this operation
-	// - remove the (old) snap from the interfaces repository
-	// - add the (new) snap to the interfaces repository
+	// - replace the (old) snap with the (new) snap in the interfaces
+	//   repository, keeping the connections that are still valid
+	//   - remembering the other snaps whose connections were dropped or
+	//     changed by this operation
 	// - restore connections based on what is kept in the state
 	//   - if a connection cannot be restored then remove it from the state
-	// - setup the security of all the affected snaps
+	// - setup the security of the snap and of all the affected snaps
+	//
+	// Connected snaps that are not affected by the update keep their
+	// security setup as-is and are not disturbed.
 	blacklist := m.repo.AutoConnectBlacklist(snapName)
-	affectedSnaps, err := m.repo.DisconnectSnap(snapName)
-	if err != nil {
-		return err
-	}
 	// XXX: what about snap renames? We should remove the old name (or switch
 	// to IDs in the interfaces repository)
-	if err := m.repo.RemoveSnap(snapName); err != nil {
-		return err
-	}
-	if err := m.repo.AddSnap(snapInfo); err != nil {
+	affectedSnaps, err := m.repo.UpdateSnap(snapInfo)
+	if err != nil {
 		if _, ok := err.(*interfaces.BadInterfacesError); ok {
 			logger.Noticef("%s", err)
 		} else {
 			return err
 		}
 	}
+	for _, warning := range deprecationWarnings(snapInfo) {
+		task.Warningf("%s", warning)
+	}
 	if err := m.reloadConnections(snapName); err != nil {
 		return err
 	}
@@ -95,10 +95,6 @@ func (m *InterfaceManager) doSetupProfiles(task *state.Task, _ *tomb.Tomb) error
 		return state.Retry
 	}
 	for _, snapName := range affectedSnaps {
-		// The affected snap is setup explicitly so skip it here.
-		if snapName == snapInfo.Name() {
-			continue
-		}
 		snapInfo, err := snapstate.Current(task.State(), snapName)
 		if err != nil {
 			return err
@@ -261,13 +257,25 @@ func (m *InterfaceManager) doConnect(task *state.Task, _ *tomb.Tomb) error {
 		return err
 	}
 
+	plug := m.repo.Plug(plugRef.Snap, plugRef.Name)
+	slot := m.repo.Slot(slotRef.Snap, slotRef.Name)
+	if plug != nil && slot != nil {
+		if err := checkConnection(connectionPolicies(st), plug, slot, false); err != nil {
+			return err
+		}
+		for _, warning := range plugDeprecationWarnings(plug.PlugInfo) {
+			task.Warningf("%s", warning)
+		}
+		for _, warning := range slotDeprecationWarnings(slot.SlotInfo) {
+			task.Warningf("%s", warning)
+		}
+	}
+
 	err = m.repo.Connect(plugRef.Snap, plugRef.Name, slotRef.Snap, slotRef.Name)
 	if err != nil {
 		return err
 	}
 
-	plug := m.repo.Plug(plugRef.Snap, plugRef.Name)
-	slot := m.repo.Slot(slotRef.Snap, slotRef.Name)
 	if err := setupSnapSecurity(task, plug.Snap, m.repo); err != nil {
 		return state.Retry
 	}
@@ -112,6 +112,7 @@ func setupSnapSecurity(task *state.Task, snapInfo *snap.Info, repo *interfaces.R
 		task.Errorf("cannot get state of snap %q: %s", snapName, err)
 		return err
 	}
+	snapInfo.ReadOnlyData = !snapState.ReadOnlyData.Unset() && snapState.ReadOnlyData == snapInfo.Revision
 	for _, backend := range securityBackends {
 		st.Unlock()
 		err := backend.Setup(snapInfo, snapState.DevMode(), repo)
@@ -171,6 +172,7 @@ func (m *InterfaceManager) autoConnect(task *state.Task, snapName string, blackl
 	if conns == nil {
 		conns = make(map[string]connState)
 	}
+	policies := connectionPolicies(task.State())
 	// XXX: quick hack, auto-connect everything
 	for _, plug := range m.repo.Plugs(snapName) {
 		if blacklist[plug.Name] {
@@ -181,6 +183,10 @@ func (m *InterfaceManager) autoConnect(task *state.Task, snapName string, blackl
 			continue
 		}
 		slot := candidates[0]
+		if err := checkConnection(policies, plug, slot, true); err != nil {
+			task.Logf("not auto-connecting: %s", err)
+			continue
+		}
 		if err := m.repo.Connect(snapName, plug.Name, slot.Snap.Name(), slot.Name); err != nil {
 			task.Logf("cannot auto connect %s:%s to %s:%s: %s",
 				snapName, plug.Name, slot.Snap.Name(), slot.Name, err)
@@ -23,6 +23,7 @@ package ifacestate
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/interfaces"
@@ -36,6 +37,11 @@ type InterfaceManager struct {
 	state  *state.State
 	runner *state.TaskRunner
 	repo   *interfaces.Repository
+
+	nextDenialsCollect time.Time
+	// denialsLog is how far the denials were collected, kept in the
+	// state only along with new denials not to write it every time
+	denialsLog *denialLogPosition
 }
 
 // Manager returns a new InterfaceManager.
@@ -87,6 +93,7 @@ func Disconnect(s *state.State, plugSnap, plugName, slotSnap, slotName string) (
 // Ensure implements StateManager.Ensure.
 func (m *InterfaceManager) Ensure() error {
 	m.runner.Ensure()
+	m.ensureDenials()
 	return nil
 }
 
@@ -20,17 +20,21 @@
 package ifacestate_test
 
 import (
+	"io/ioutil"
+	"path/filepath"
 	"testing"
 
 	. "gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
 	"github.com/snapcore/snapd/overlord/ifacestate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
 )
 
 func TestInterfaceManager(t *testing.T) { TestingT(t) }
@@ -132,6 +136,37 @@ func (s *interfaceManagerSuite) TestEnsureProcessesConnectTask(c *C) {
 	c.Check(slot.Connections[0], DeepEquals, interfaces.PlugRef{Snap: "consumer", Name: "plug"})
 }
 
+func (s *interfaceManagerSuite) TestConnectWarnsAboutDeprecations(c *C) {
+	restore := ifacestate.MockDeprecations([]*interfaces.Deprecation{
+		{Interface: "test", Replacement: `the "new-test" interface`},
+	})
+	defer restore()
+	s.mockIface(c, &interfaces.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+
+	s.state.Lock()
+	change := s.state.NewChange("kind", "summary")
+	ts, err := ifacestate.Connect(s.state, "consumer", "plug", "producer", "slot")
+	c.Assert(err, IsNil)
+	change.AddAll(ts)
+	s.state.Unlock()
+
+	mgr := s.manager(c)
+	mgr.Ensure()
+	mgr.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	task := change.Tasks()[0]
+	c.Check(task.Status(), Equals, state.DoneStatus)
+	log := task.Log()
+	c.Assert(log, HasLen, 2)
+	c.Check(log[0], Matches, `.* WARNING plug consumer:plug: interface "test" is deprecated, use the "new-test" interface instead`)
+	c.Check(log[1], Matches, `.* WARNING slot producer:slot: interface "test" is deprecated, use the "new-test" interface instead`)
+}
+
 func (s *interfaceManagerSuite) TestDisconnectTask(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -335,6 +370,41 @@ func (s *interfaceManagerSuite) TestDoSetupSnapSecurityHonorsDisconnect(c *C) {
 	c.Check(plug.Connections, HasLen, 0)
 }
 
+// The setup-profiles task warns about plugs using deprecated attributes.
+func (s *interfaceManagerSuite) TestDoSetupSnapSecurityWarnsAboutDeprecations(c *C) {
+	restore := ifacestate.MockDeprecations([]*interfaces.Deprecation{
+		{Interface: "network", Attribute: "legacy", Removal: "2.20"},
+	})
+	defer restore()
+	s.mockSnap(c, osSnapYaml)
+	snapInfo := s.mockSnap(c, `
+name: snap
+version: 1
+plugs:
+ network:
+  interface: network
+ old-network:
+  interface: network
+  legacy: true
+`)
+
+	mgr := s.manager(c)
+
+	change := s.addSetupSnapSecurityChange(c, &snapstate.SnapSetup{
+		Name: snapInfo.Name(), Revision: snapInfo.Revision})
+	mgr.Ensure()
+	mgr.Wait()
+	mgr.Stop()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Assert(change.Status(), Equals, state.DoneStatus)
+	log := change.Tasks()[0].Log()
+	c.Assert(log, HasLen, 1)
+	c.Check(log[0], Matches, `.* WARNING plug snap:old-network: attribute "legacy" of interface "network" is deprecated and will be removed after 2.20`)
+}
+
 // The setup-profiles task will auto-connect plugs with viable candidates.
 func (s *interfaceManagerSuite) TestDoSetupSnapSecuirtyAutoConnects(c *C) {
 	// Add an OS snap.
@@ -546,17 +616,31 @@ func (s *interfaceManagerSuite) TestSetupProfilesHonorsDevMode(c *C) {
 	c.Check(oldDevMode, Equals, false)
 }
 
+var sampleSnapYamlWithMoreApps = `
+name: snap
+version: 1
+apps:
+ app:
+   command: foo
+ app2:
+   command: bar
+plugs:
+ network:
+  interface: network
+`
+
 // setup-profiles uses the new snap.Info when setting up security for the new
-// snap when it had prior connections and DisconnectSnap() returns it as a part
-// of the affected set.
+// snap when it had prior connections and UpdateSnap() returns the other side
+// of the connection as a part of the affected set.
 func (s *interfaceManagerSuite) TestSetupProfilesUsesFreshSnapInfo(c *C) {
 	// Put the OS and the sample snaps in place.
 	coreSnapInfo := s.mockSnap(c, osSnapYaml)
 	oldSnapInfo := s.mockSnap(c, sampleSnapYaml)
 
 	// Put connection information between the OS snap and the sample snap.
-	// This is done so that DisconnectSnap returns both snaps as "affected"
-	// and so that the previously broken code path is exercised.
+	// The new revision binds the plug to more apps so that UpdateSnap
+	// returns the OS snap as "affected" and so that the previously broken
+	// code path is exercised.
 	s.state.Lock()
 	s.state.Set("conns", map[string]interface{}{
 		"snap:network ubuntu-core:network": map[string]interface{}{"interface": "network"},
@@ -568,7 +652,7 @@ func (s *interfaceManagerSuite) TestSetupProfilesUsesFreshSnapInfo(c *C) {
 	mgr := s.manager(c)
 
 	// Put a new revision of the sample snap in place.
-	newSnapInfo := s.mockUpdatedSnap(c, sampleSnapYaml, 42)
+	newSnapInfo := s.mockUpdatedSnap(c, sampleSnapYamlWithMoreApps, 42)
 
 	// Sanity check, the revisions are different.
 	c.Assert(oldSnapInfo.Revision, Not(Equals), 42)
@@ -598,6 +682,46 @@ func (s *interfaceManagerSuite) TestSetupProfilesUsesFreshSnapInfo(c *C) {
 	c.Check(s.secBackend.SetupCalls[1].SnapInfo.Revision, Equals, coreSnapInfo.Revision)
 }
 
+// setup-profiles keeps the connections of the refreshed snap and leaves the
+// security of the other side alone when the connection didn't change.
+func (s *interfaceManagerSuite) TestSetupProfilesKeepsUnchangedConnections(c *C) {
+	s.mockSnap(c, osSnapYaml)
+	s.mockSnap(c, sampleSnapYaml)
+
+	s.state.Lock()
+	s.state.Set("conns", map[string]interface{}{
+		"snap:network ubuntu-core:network": map[string]interface{}{"interface": "network"},
+	})
+	s.state.Unlock()
+
+	mgr := s.manager(c)
+
+	newSnapInfo := s.mockUpdatedSnap(c, sampleSnapYaml, 42)
+
+	change := s.addSetupSnapSecurityChange(c, &snapstate.SnapSetup{
+		Name: newSnapInfo.Name(), Revision: newSnapInfo.Revision})
+	mgr.Ensure()
+	mgr.Wait()
+	mgr.Stop()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(change.Status(), Equals, state.DoneStatus)
+
+	// Only the refreshed snap was setup.
+	c.Assert(s.secBackend.SetupCalls, HasLen, 1)
+	c.Check(s.secBackend.SetupCalls[0].SnapInfo.Name(), Equals, "snap")
+	c.Check(s.secBackend.SetupCalls[0].SnapInfo.Revision, Equals, snap.R(42))
+
+	// The connection is still in place.
+	repo := mgr.Repository()
+	plug := repo.Plug("snap", "network")
+	c.Assert(plug, NotNil)
+	c.Check(plug.Connections, DeepEquals, []interfaces.SlotRef{{Snap: "ubuntu-core", Name: "network"}})
+	c.Check(plug.Snap.Revision, Equals, snap.R(42))
+}
+
 // The undo handler of the setup-profiles task will honor `old-devmode` that
 // is optionally stored in the task state and use it to set the DevMode flag in
 // the SnapState.
@@ -951,3 +1075,32 @@ func (s *interfaceManagerSuite) TestManagerReloadsConnections(c *C) {
 	c.Check(plug.Connections[0], DeepEquals, interfaces.SlotRef{Snap: "producer", Name: "slot"})
 	c.Check(slot.Connections[0], DeepEquals, interfaces.PlugRef{Snap: "consumer", Name: "plug"})
 }
+
+func (s *interfaceManagerSuite) TestRegenerateProfiles(c *C) {
+	restore := ifacestate.MockSecurityBackends([]interfaces.SecurityBackend{&apparmor.Backend{}})
+	defer restore()
+	s.mockIface(c, &interfaces.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, consumerYaml+"apps:\n app:\n  command: foo\n")
+	s.mockSnap(c, producerYaml)
+	s.mockSnap(c, sampleSnapYaml)
+	mgr := s.manager(c)
+	c.Assert(mgr.Repository().Connect("consumer", "plug", "producer", "slot"), IsNil)
+
+	profiles, err := mgr.RegenerateProfiles("apparmor", "producer")
+	c.Assert(err, IsNil)
+	// the sample snap does not use the policy of the producer
+	c.Check(profiles, DeepEquals, []string{
+		filepath.Join(dirs.SnapAppArmorDir, "snap.consumer.app"),
+	})
+	data, err := ioutil.ReadFile(profiles[0])
+	c.Assert(err, IsNil)
+	c.Check(string(data), testutil.Contains, `profile "snap.consumer.app"`)
+
+	// snaps not installed are skipped
+	profiles, err = mgr.RegenerateProfiles("apparmor", "unknown")
+	c.Assert(err, IsNil)
+	c.Check(profiles, HasLen, 0)
+
+	_, err = mgr.RegenerateProfiles("udev", "producer")
+	c.Check(err, ErrorMatches, "cannot regenerate udev profiles: backend unknown or without regeneration")
+}
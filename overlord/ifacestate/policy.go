@@ -0,0 +1,213 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ifacestate
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+// connectionRule constrains the connections of the plugs of one interface.
+type connectionRule struct {
+	// AllowSnaps, when set, are the only snaps whose plugs of the
+	// interface can be connected.
+	AllowSnaps []string `yaml:"allow-snaps"`
+	// DenySnaps are snaps whose plugs of the interface cannot be
+	// connected, with "*" standing for all of them.
+	DenySnaps []string `yaml:"deny-snaps"`
+	// ManualApproval requires the connections to be made explicitly,
+	// so they are never auto-connected.
+	ManualApproval bool `yaml:"manual-approval"`
+	// PlugAttributes are attributes the plugs of the interface must
+	// have, with the given values, to be connected.
+	PlugAttributes map[string]string `yaml:"plug-attributes"`
+}
+
+// connectionPolicy holds the connection rules set by the device owner,
+// keyed by interface name.
+type connectionPolicy struct {
+	Interfaces map[string]*connectionRule `yaml:"interfaces"`
+
+	// origin describes where the policy comes from, for messages
+	origin string
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name || n == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// ConnectionDeniedError is returned when the device policy does not
+// allow a connection.
+type ConnectionDeniedError struct {
+	Plug      interfaces.PlugRef
+	Slot      interfaces.SlotRef
+	Interface string
+	Reason    string
+}
+
+func (e *ConnectionDeniedError) Error() string {
+	return fmt.Sprintf("cannot connect %s:%s to %s:%s: %s", e.Plug.Snap, e.Plug.Name, e.Slot.Snap, e.Slot.Name, e.Reason)
+}
+
+func (p *connectionPolicy) check(plug *interfaces.Plug, auto bool) string {
+	rule := p.Interfaces[plug.Interface]
+	if rule == nil {
+		return ""
+	}
+	snapName := plug.Snap.Name()
+	if contains(rule.DenySnaps, snapName) || (len(rule.AllowSnaps) > 0 && !contains(rule.AllowSnaps, snapName)) {
+		return fmt.Sprintf("%s does not allow snap %q to use the %q interface", p.origin, snapName, plug.Interface)
+	}
+	attrs := make([]string, 0, len(rule.PlugAttributes))
+	for attr := range rule.PlugAttributes {
+		attrs = append(attrs, attr)
+	}
+	sort.Strings(attrs)
+	for _, attr := range attrs {
+		if v, ok := plug.Attrs[attr].(string); !ok || v != rule.PlugAttributes[attr] {
+			return fmt.Sprintf("%s requires the %q interface plugs to have attribute %s set to %q", p.origin, plug.Interface, attr, rule.PlugAttributes[attr])
+		}
+	}
+	if auto && rule.ManualApproval {
+		return fmt.Sprintf("%s requires manual approval of %q interface connections", p.origin, plug.Interface)
+	}
+	return ""
+}
+
+// checkConnection checks the connection of the plug to the slot against
+// the device policies, returning a *ConnectionDeniedError if it is not
+// allowed. Connections being made automatically need to also not
+// require manual approval.
+func checkConnection(policies []*connectionPolicy, plug *interfaces.Plug, slot *interfaces.Slot, auto bool) error {
+	denied := func(reason string) error {
+		return &ConnectionDeniedError{
+			Plug:      interfaces.PlugRef{Snap: plug.Snap.Name(), Name: plug.Name},
+			Slot:      interfaces.SlotRef{Snap: slot.Snap.Name(), Name: slot.Name},
+			Interface: plug.Interface,
+			Reason:    reason,
+		}
+	}
+	// publisher data is only ever shared between snaps of the same publisher
+	if plug.Interface == snap.PublisherDataInterface && (plug.Snap.Developer == "" || plug.Snap.Developer != slot.Snap.Developer) {
+		return denied("snaps must come from the same publisher")
+	}
+	for _, p := range policies {
+		if reason := p.check(plug, auto); reason != "" {
+			return denied(reason)
+		}
+	}
+	return nil
+}
+
+func parseConnectionPolicy(data []byte, origin string) (*connectionPolicy, error) {
+	var p connectionPolicy
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("cannot parse %s: %v", origin, err)
+	}
+	p.origin = origin
+	return &p, nil
+}
+
+// verifyPolicySignature checks the detached signature of the local
+// connection policy against the keyring of the device owner.
+var verifyPolicySignature = func(policyPath, sigPath, keyring string) error {
+	var errBuf bytes.Buffer
+	gpg := exec.Command("gpg", "-q", "--batch", "--no-default-keyring", "--keyring", keyring, "--verify", sigPath, policyPath)
+	gpg.Stderr = &errBuf
+	if err := gpg.Run(); err != nil {
+		return fmt.Errorf("%v (%q)", err, errBuf.Bytes())
+	}
+	return nil
+}
+
+// localConnectionPolicy returns the signed connection policy of the
+// device owner, if there is one.
+func localConnectionPolicy() (*connectionPolicy, error) {
+	path := dirs.SnapConnectionPolicyFile
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyPolicySignature(path, path+".sig", dirs.SnapConnectionPolicyKeyring); err != nil {
+		return nil, fmt.Errorf("cannot verify signature of %s: %v", path, err)
+	}
+	return parseConnectionPolicy(data, "local device policy")
+}
+
+// gadgetConnectionPolicy returns the connection policy shipped by the
+// gadget snap, if there is one.
+func gadgetConnectionPolicy(st *state.State) (*connectionPolicy, error) {
+	gadget, err := snapstate.GadgetInfo(st)
+	if err == state.ErrNoState {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(filepath.Join(gadget.MountDir(), "meta", "connection-policy.yaml"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseConnectionPolicy(data, fmt.Sprintf("policy of gadget %q", gadget.Name()))
+}
+
+// connectionPolicies returns the connection policies of the device, from
+// the gadget and from the device owner. Policies that cannot be used are
+// logged and skipped.
+// Note that the state must be locked by the caller.
+func connectionPolicies(st *state.State) []*connectionPolicy {
+	var policies []*connectionPolicy
+	if p, err := gadgetConnectionPolicy(st); err != nil {
+		logger.Noticef("cannot use gadget connection policy: %v", err)
+	} else if p != nil {
+		policies = append(policies, p)
+	}
+	if p, err := localConnectionPolicy(); err != nil {
+		logger.Noticef("cannot use local connection policy: %v", err)
+	} else if p != nil {
+		policies = append(policies, p)
+	}
+	return policies
+}
@@ -0,0 +1,232 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ifacestate_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/overlord/ifacestate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+)
+
+var gadgetYaml = `
+name: gadget
+version: 1
+type: gadget
+`
+
+func (s *interfaceManagerSuite) mockLocalPolicy(c *C, policy string) {
+	c.Assert(os.MkdirAll(filepath.Dir(dirs.SnapConnectionPolicyFile), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(dirs.SnapConnectionPolicyFile, []byte(policy), 0644), IsNil)
+}
+
+func (s *interfaceManagerSuite) connect(c *C) *state.Change {
+	s.state.Lock()
+	change := s.state.NewChange("kind", "summary")
+	ts, err := ifacestate.Connect(s.state, "consumer", "plug", "producer", "slot")
+	c.Assert(err, IsNil)
+	change.AddAll(ts)
+	s.state.Unlock()
+
+	mgr := s.manager(c)
+	mgr.Ensure()
+	mgr.Wait()
+	return change
+}
+
+func (s *interfaceManagerSuite) TestConnectDeniedByLocalPolicy(c *C) {
+	restore := ifacestate.MockVerifyPolicySignature(func(policyPath, sigPath, keyring string) error {
+		c.Check(policyPath, Equals, dirs.SnapConnectionPolicyFile)
+		c.Check(sigPath, Equals, dirs.SnapConnectionPolicyFile+".sig")
+		c.Check(keyring, Equals, dirs.SnapConnectionPolicyKeyring)
+		return nil
+	})
+	defer restore()
+	s.mockLocalPolicy(c, `
+interfaces:
+  test:
+    allow-snaps: [other-snap]
+`)
+	s.mockIface(c, &interfaces.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+
+	change := s.connect(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(change.Status(), Equals, state.ErrorStatus)
+	c.Check(change.Err(), ErrorMatches, `(?s).*cannot connect consumer:plug to producer:slot: local device policy does not allow snap "consumer" to use the "test" interface.*`)
+	c.Check(s.manager(c).Repository().Plug("consumer", "plug").Connections, HasLen, 0)
+}
+
+func (s *interfaceManagerSuite) TestConnectIgnoresUnsignedLocalPolicy(c *C) {
+	restore := ifacestate.MockVerifyPolicySignature(func(policyPath, sigPath, keyring string) error {
+		return errors.New("no signature")
+	})
+	defer restore()
+	s.mockLocalPolicy(c, `
+interfaces:
+  test:
+    deny-snaps: ["*"]
+`)
+	s.mockIface(c, &interfaces.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+
+	change := s.connect(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(change.Status(), Equals, state.DoneStatus)
+}
+
+func (s *interfaceManagerSuite) TestConnectManualApprovalAllowsExplicit(c *C) {
+	gadget := s.mockSnap(c, gadgetYaml)
+	c.Assert(ioutil.WriteFile(filepath.Join(gadget.MountDir(), "meta", "connection-policy.yaml"), []byte(`
+interfaces:
+  test:
+    manual-approval: true
+`), 0644), IsNil)
+	s.mockIface(c, &interfaces.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+
+	change := s.connect(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(change.Status(), Equals, state.DoneStatus)
+}
+
+func (s *interfaceManagerSuite) TestConnectDeniedByPlugAttributes(c *C) {
+	gadget := s.mockSnap(c, gadgetYaml)
+	c.Assert(ioutil.WriteFile(filepath.Join(gadget.MountDir(), "meta", "connection-policy.yaml"), []byte(`
+interfaces:
+  test:
+    plug-attributes:
+      dns: stub
+`), 0644), IsNil)
+	s.mockIface(c, &interfaces.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+
+	change := s.connect(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(change.Status(), Equals, state.ErrorStatus)
+	c.Check(change.Err(), ErrorMatches, `(?s).*policy of gadget "gadget" requires the "test" interface plugs to have attribute dns set to "stub".*`)
+}
+
+func (s *interfaceManagerSuite) TestAutoConnectHonorsGadgetPolicy(c *C) {
+	s.mockSnap(c, osSnapYaml)
+	gadget := s.mockSnap(c, gadgetYaml)
+	c.Assert(ioutil.WriteFile(filepath.Join(gadget.MountDir(), "meta", "connection-policy.yaml"), []byte(`
+interfaces:
+  network:
+    manual-approval: true
+`), 0644), IsNil)
+	mgr := s.manager(c)
+
+	snapInfo := s.mockSnap(c, sampleSnapYaml)
+	change := s.addSetupSnapSecurityChange(c, &snapstate.SnapSetup{
+		Name: snapInfo.Name(), Revision: snapInfo.Revision})
+	mgr.Ensure()
+	mgr.Wait()
+	mgr.Stop()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Assert(change.Status(), Equals, state.DoneStatus)
+	var conns map[string]interface{}
+	err := s.state.Get("conns", &conns)
+	c.Assert(err, IsNil)
+	c.Check(conns, HasLen, 0)
+	c.Check(mgr.Repository().Plug("snap", "network").Connections, HasLen, 0)
+
+	log := change.Tasks()[0].Log()
+	c.Assert(log, HasLen, 1)
+	c.Check(log[0], Matches, `.* not auto-connecting: cannot connect snap:network to ubuntu-core:network: policy of gadget "gadget" requires manual approval of "network" interface connections`)
+}
+
+func (s *interfaceManagerSuite) mockSnapFromPublisher(c *C, yamlText, publisher string) {
+	sideInfo := &snap.SideInfo{Developer: publisher}
+	snaptest.MockSnap(c, yamlText, sideInfo)
+	info, err := snap.InfoFromSnapYaml([]byte(yamlText))
+	c.Assert(err, IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	snapstate.Set(s.state, info.Name(), &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{sideInfo},
+	})
+}
+
+var publisherDataConsumerYaml = `
+name: consumer
+version: 1
+plugs:
+ plug:
+  interface: publisher-data
+`
+
+var publisherDataProducerYaml = `
+name: producer
+version: 1
+slots:
+ slot:
+  interface: publisher-data
+`
+
+func (s *interfaceManagerSuite) TestConnectPublisherDataSamePublisher(c *C) {
+	s.mockSnapFromPublisher(c, publisherDataConsumerYaml, "acme")
+	s.mockSnapFromPublisher(c, publisherDataProducerYaml, "acme")
+
+	change := s.connect(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(change.Status(), Equals, state.DoneStatus)
+}
+
+func (s *interfaceManagerSuite) TestConnectPublisherDataDifferentPublishers(c *C) {
+	s.mockSnapFromPublisher(c, publisherDataConsumerYaml, "acme")
+	s.mockSnapFromPublisher(c, publisherDataProducerYaml, "someone-else")
+
+	change := s.connect(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(change.Status(), Equals, state.ErrorStatus)
+	c.Check(change.Err(), ErrorMatches, `(?s).*cannot connect consumer:plug to producer:slot: snaps must come from the same publisher.*`)
+}
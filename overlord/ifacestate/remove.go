@@ -0,0 +1,168 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ifacestate
+
+import (
+	"sort"
+
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// Providers returns, for each snap with connected plugs, the snaps
+// providing the slots they are connected to.
+// Note that the state must be locked by the caller.
+func Providers(st *state.State) (map[string]map[string]bool, error) {
+	conns, err := getConns(st)
+	if err != nil {
+		return nil, err
+	}
+	provs := make(map[string]map[string]bool)
+	for id := range conns {
+		plugRef, slotRef, err := parseConnID(id)
+		if err != nil {
+			return nil, err
+		}
+		if plugRef.Snap == slotRef.Snap {
+			continue
+		}
+		if provs[plugRef.Snap] == nil {
+			provs[plugRef.Snap] = make(map[string]bool)
+		}
+		provs[plugRef.Snap][slotRef.Snap] = true
+	}
+	return provs, nil
+}
+
+// dependsOnAny returns whether any of the providers of the snap is in
+// the given set.
+func dependsOnAny(provs map[string]bool, snaps map[string]bool) bool {
+	for prov := range provs {
+		if snaps[prov] {
+			return true
+		}
+	}
+	return false
+}
+
+// removalOrder orders the snaps so that a snap comes before the snaps
+// providing the slots its plugs are connected to. Snaps connected to each
+// other both ways are ordered by name.
+func removalOrder(names []string, provs map[string]map[string]bool) []string {
+	left := make(map[string]bool, len(names))
+	for _, name := range names {
+		left[name] = true
+	}
+
+	order := make([]string, 0, len(names))
+	for len(left) > 0 {
+		var next []string
+		for name := range left {
+			needed := false
+			for other := range left {
+				if other != name && provs[other][name] {
+					needed = true
+					break
+				}
+			}
+			if !needed {
+				next = append(next, name)
+			}
+		}
+		sort.Strings(next)
+		if len(next) == 0 {
+			// a cycle, break it
+			for name := range left {
+				if len(next) == 0 || name < next[0] {
+					next = []string{name}
+				}
+			}
+		}
+		for _, name := range next {
+			order = append(order, name)
+			delete(left, name)
+		}
+	}
+	return order
+}
+
+// RemoveMany returns the task sets removing the given snaps. Unrelated
+// snaps are removed in parallel, while a snap is removed before the
+// snaps providing the slots its plugs are connected to, so consumers
+// are not left without their provider mid-change.
+//
+// The snaps connected to slots of the removed snaps lose their provider:
+// they are returned as dependents, or with purgeDependents they are
+// removed as well (before their providers).
+// Note that the state must be locked by the caller.
+func RemoveMany(st *state.State, names []string, purgeDependents bool) (removed, dependents []string, tss []*state.TaskSet, err error) {
+	provs, err := Providers(st)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	removing := make(map[string]bool, len(names))
+	for _, name := range names {
+		removing[name] = true
+	}
+	for {
+		var more []string
+		for consumer, consumerProvs := range provs {
+			if !removing[consumer] && dependsOnAny(consumerProvs, removing) {
+				more = append(more, consumer)
+			}
+		}
+		if len(more) == 0 {
+			break
+		}
+		sort.Strings(more)
+		if !purgeDependents {
+			dependents = more
+			break
+		}
+		for _, name := range more {
+			removing[name] = true
+		}
+	}
+
+	all := make([]string, 0, len(removing))
+	for name := range removing {
+		all = append(all, name)
+	}
+	removed = removalOrder(all, provs)
+
+	byName := make(map[string]*state.TaskSet, len(removed))
+	for _, name := range removed {
+		ts, err := snapstate.Remove(st, name)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		// only wait for the consumers ordered before, which breaks
+		// the cycles the same way removalOrder does
+		for _, consumer := range removed {
+			if byName[consumer] != nil && provs[consumer][name] {
+				ts.WaitAll(byName[consumer])
+			}
+		}
+		byName[name] = ts
+		tss = append(tss, ts)
+	}
+	return removed, dependents, tss, nil
+}
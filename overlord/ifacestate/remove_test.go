@@ -0,0 +1,127 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ifacestate_test
+
+import (
+	"fmt"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/ifacestate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+func (s *interfaceManagerSuite) mockConnectedSnaps(c *C, names []string, conns ...string) {
+	for _, name := range names {
+		s.mockSnap(c, fmt.Sprintf("name: %s\nversion: 1\n", name))
+	}
+	connStates := make(map[string]interface{})
+	for _, conn := range conns {
+		connStates[conn] = map[string]interface{}{"interface": "test"}
+	}
+	s.state.Lock()
+	s.state.Set("conns", connStates)
+	s.state.Unlock()
+}
+
+func removedSnap(c *C, ts *state.TaskSet) string {
+	ss, err := snapstate.TaskSnapSetup(ts.Tasks()[0])
+	c.Assert(err, IsNil)
+	return ss.Name
+}
+
+func (s *interfaceManagerSuite) TestRemoveManyOrdersConsumersFirst(c *C) {
+	s.mockConnectedSnaps(c, []string{"producer", "consumer", "other"},
+		"consumer:plug producer:slot",
+		"producer:plug producer:slot")
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	removed, dependents, tss, err := ifacestate.RemoveMany(s.state, []string{"producer", "consumer", "other"}, false)
+	c.Assert(err, IsNil)
+	c.Check(removed, DeepEquals, []string{"consumer", "other", "producer"})
+	c.Check(dependents, HasLen, 0)
+	c.Assert(tss, HasLen, 3)
+	for i, ts := range tss {
+		c.Check(removedSnap(c, ts), Equals, removed[i])
+	}
+	// only the producer waits, for its consumer
+	c.Check(tss[0].Tasks()[0].WaitTasks(), HasLen, 0)
+	c.Check(tss[1].Tasks()[0].WaitTasks(), HasLen, 0)
+	c.Check(tss[2].Tasks()[0].WaitTasks(), DeepEquals, tss[0].Tasks())
+}
+
+func (s *interfaceManagerSuite) TestRemoveManyDependents(c *C) {
+	s.mockConnectedSnaps(c, []string{"producer", "consumer", "consumer2"},
+		"consumer:plug producer:slot",
+		"consumer2:plug consumer:slot")
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	removed, dependents, tss, err := ifacestate.RemoveMany(s.state, []string{"producer"}, false)
+	c.Assert(err, IsNil)
+	c.Check(removed, DeepEquals, []string{"producer"})
+	c.Check(dependents, DeepEquals, []string{"consumer"})
+	c.Check(tss, HasLen, 1)
+}
+
+func (s *interfaceManagerSuite) TestRemoveManyPurgeDependents(c *C) {
+	s.mockConnectedSnaps(c, []string{"producer", "consumer", "consumer2", "other"},
+		"consumer:plug producer:slot",
+		"consumer2:plug consumer:slot",
+		"other:plug core:slot")
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	removed, dependents, tss, err := ifacestate.RemoveMany(s.state, []string{"producer"}, true)
+	c.Assert(err, IsNil)
+	c.Check(removed, DeepEquals, []string{"consumer2", "consumer", "producer"})
+	c.Check(dependents, HasLen, 0)
+	c.Assert(tss, HasLen, 3)
+	c.Check(removedSnap(c, tss[0]), Equals, "consumer2")
+}
+
+func (s *interfaceManagerSuite) TestRemoveManyCycle(c *C) {
+	s.mockConnectedSnaps(c, []string{"a", "b"},
+		"a:plug b:slot",
+		"b:plug a:slot")
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	removed, _, tss, err := ifacestate.RemoveMany(s.state, []string{"b", "a"}, false)
+	c.Assert(err, IsNil)
+	c.Check(removed, DeepEquals, []string{"a", "b"})
+	c.Assert(tss, HasLen, 2)
+	c.Check(tss[0].Tasks()[0].WaitTasks(), HasLen, 0)
+	c.Check(tss[1].Tasks()[0].WaitTasks(), DeepEquals, tss[0].Tasks())
+}
+
+func (s *interfaceManagerSuite) TestRemoveManyError(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	_, _, _, err := ifacestate.RemoveMany(s.state, []string{"missing"}, false)
+	c.Check(err, ErrorMatches, `cannot find snap "missing"`)
+}
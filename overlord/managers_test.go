@@ -63,6 +63,8 @@ var _ = Suite(&mgrsSuite{})
 func (ms *mgrsSuite) SetUpTest(c *C) {
 	ms.tempdir = c.MkDir()
 	dirs.SetRootDir(ms.tempdir)
+	err := os.MkdirAll(filepath.Dir(dirs.SnapStateFile), 0755)
+	c.Assert(err, IsNil)
 
 	os.Setenv("SNAPPY_SQUASHFS_UNPACK_FOR_TESTS", "1")
 
@@ -380,8 +382,11 @@ type: os
 	c.Assert(chg.Status(), Equals, state.DoneStatus, Commentf("install-snap change failed with: %v", chg.Err()))
 
 	c.Assert(bootloader.BootVars, DeepEquals, map[string]string{
-		"snappy_os":   "core_x1.snap",
-		"snappy_mode": "try",
+		"snappy_os":            "core_x1.snap",
+		"snappy_try_os":        "core_x1.snap",
+		"snappy_mode":          "try",
+		"snappy_boot_attempts": "0",
+		"snappy_boot_limit":    "3",
 	})
 }
 
@@ -422,7 +427,10 @@ type: kernel`
 	c.Assert(chg.Status(), Equals, state.DoneStatus, Commentf("install-snap change failed with: %v", chg.Err()))
 
 	c.Assert(bootloader.BootVars, DeepEquals, map[string]string{
-		"snappy_kernel": "krnl_x1.snap",
-		"snappy_mode":   "try",
+		"snappy_kernel":        "krnl_x1.snap",
+		"snappy_try_kernel":    "krnl_x1.snap",
+		"snappy_mode":          "try",
+		"snappy_boot_attempts": "0",
+		"snappy_boot_limit":    "3",
 	})
 }
@@ -21,8 +21,11 @@
 package overlord
 
 import (
+	"bytes"
 	"fmt"
 	"os"
+	"path/filepath"
+	"regexp"
 	"sync"
 	"time"
 
@@ -33,9 +36,12 @@ import (
 	"github.com/snapcore/snapd/osutil"
 
 	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/configstate"
+	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/ifacestate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/overlord/statelog"
 )
 
 var (
@@ -43,32 +49,68 @@ var (
 	pruneInterval  = 10 * time.Minute
 	pruneWait      = 24 * time.Hour * 1
 	abortWait      = 24 * time.Hour * 7
+
+	// lowMemoryRatio is the share of the memory of the system under
+	// which the available memory is low enough for the managers to
+	// release what they can.
+	lowMemoryRatio = 0.1
+	memInfo        = osutil.MemInfo
+	// memoryCheckInterval is how often at most the ensure loop checks
+	// whether the memory is low.
+	memoryCheckInterval = time.Minute
+
+	// lockWatchdogThreshold is how long the state can stay locked
+	// before the stack traces of snapd are logged, to debug it being
+	// stuck.
+	lockWatchdogThreshold = 30 * time.Second
 )
 
 // Overlord is the central manager of a snappy system, keeping
 // track of all available state managers and related helpers.
 type Overlord struct {
 	stateEng *StateEngine
+	// held for as long as the overlord runs, so that the state is not
+	// changed behind its back
+	stateLock *os.File
 	// ensure loop
 	loopTomb    *tomb.Tomb
 	ensureLock  sync.Mutex
 	ensureTimer *time.Timer
 	ensureNext  time.Time
 	pruneTimer  *time.Timer
+	memoryCheck time.Time
 	// restarts
-	restartHandler func()
+	restartHandler func(t state.RestartType)
 	// managers
 	snapMgr   *snapstate.SnapManager
 	assertMgr *assertstate.AssertManager
 	ifaceMgr  *ifacestate.InterfaceManager
+	hookMgr   *hookstate.HookManager
 }
 
 // New creates a new Overlord with all its state managers.
-func New() (*Overlord, error) {
-	o := &Overlord{
+func New() (o *Overlord, err error) {
+	o = &Overlord{
 		loopTomb: new(tomb.Tomb),
 	}
 
+	if err := os.MkdirAll(filepath.Dir(dirs.SnapStateLockFile), 0755); err != nil {
+		return nil, err
+	}
+	stateLock, err := osutil.TryLockFile(dirs.SnapStateLockFile)
+	if err == osutil.ErrAlreadyLocked {
+		return nil, fmt.Errorf("cannot lock the system state: snapd is running already")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot lock the system state: %v", err)
+	}
+	o.stateLock = stateLock
+	defer func() {
+		if err != nil {
+			stateLock.Close()
+		}
+	}()
+
 	backend := &overlordStateBackend{
 		path:           dirs.SnapStateFile,
 		ensureBefore:   o.ensureBefore,
@@ -95,6 +137,17 @@ func New() (*Overlord, error) {
 	o.assertMgr = assertMgr
 	o.stateEng.AddManager(o.assertMgr)
 
+	// progressive releases are decided on the device serial
+	snapMgr.SetDeviceSerial(assertMgr.DeviceSerial)
+	snapstate.DeviceModel = assertMgr.DeviceModel
+	snapstate.OpenAdvisoryDatabase = func() (snapstate.AdvisoryDatabase, error) {
+		db, err := assertMgr.DB()
+		if err != nil {
+			return nil, err
+		}
+		return db, nil
+	}
+
 	ifaceMgr, err := ifacestate.Manager(s, nil)
 	if err != nil {
 		return nil, err
@@ -102,10 +155,47 @@ func New() (*Overlord, error) {
 	o.ifaceMgr = ifaceMgr
 	o.stateEng.AddManager(o.ifaceMgr)
 
+	// snaps installed or refreshed together go after their providers
+	snapstate.SnapProviders = ifacestate.Providers
+
+	hookMgr, err := hookstate.Manager(s)
+	if err != nil {
+		return nil, err
+	}
+	o.hookMgr = hookMgr
+	o.stateEng.AddManager(o.hookMgr)
+
+	// the boot is only marked successful once the snaps are healthy
+	hookMgr.Register(regexp.MustCompile("^check-health$"), snapstate.NewHealthHookHandler)
+	// a failing migration of the data of a snap fails its refresh
+	hookMgr.Register(regexp.MustCompile("^migrate-data$"), snapstate.NewMigrateDataHookHandler)
+	// a failing configure hook rolls back the configuration it was given
+	hookMgr.Register(regexp.MustCompile("^configure$"), configstate.NewConfigureHandler)
+
 	return o, nil
 }
 
-func loadState(backend state.Backend) (*state.State, error) {
+// loadState loads the state from the state log if there is one, as
+// chosen on first boot, or from the state file otherwise, setting up
+// the backend to checkpoint it there.
+func loadState(backend *overlordStateBackend) (*state.State, error) {
+	if osutil.FileExists(dirs.SnapStateLogFile) {
+		log, err := statelog.Open(dirs.SnapStateLogFile)
+		if err != nil {
+			return nil, err
+		}
+		data, err := log.Data()
+		if err != nil {
+			log.Close()
+			return nil, err
+		}
+		backend.log = log
+		if data == nil {
+			return state.New(backend), nil
+		}
+		return state.ReadState(backend, bytes.NewReader(data))
+	}
+
 	if !osutil.FileExists(dirs.SnapStateFile) {
 		return state.New(backend), nil
 	}
@@ -150,16 +240,16 @@ func (o *Overlord) ensureBefore(d time.Duration) {
 	}
 }
 
-func (o *Overlord) requestRestart() {
+func (o *Overlord) requestRestart(t state.RestartType) {
 	if o.restartHandler == nil {
 		logger.Noticef("restart requested but no handler set")
 	} else {
-		o.restartHandler()
+		o.restartHandler(t)
 	}
 }
 
 // SetRestartHandler sets a handler to fulfill restart requests asynchronously.
-func (o *Overlord) SetRestartHandler(handleRestart func()) {
+func (o *Overlord) SetRestartHandler(handleRestart func(t state.RestartType)) {
 	o.restartHandler = handleRestart
 }
 
@@ -182,8 +272,32 @@ func (o *Overlord) Loop() {
 			// in case of errors engine logs them,
 			// continue to the next Ensure() try for now
 			o.stateEng.Ensure()
+			o.releaseMemoryIfLow()
 		}
 	})
+	o.loopTomb.Go(func() error {
+		o.State().WatchLock(lockWatchdogThreshold, o.loopTomb.Dying())
+		return nil
+	})
+}
+
+// releaseMemoryIfLow has the managers release what they can when the
+// system is short of memory, checking it at most every
+// memoryCheckInterval.
+func (o *Overlord) releaseMemoryIfLow() {
+	now := time.Now()
+	if now.Sub(o.memoryCheck) < memoryCheckInterval {
+		return
+	}
+	o.memoryCheck = now
+	total, available, err := memInfo()
+	if err != nil {
+		return
+	}
+	if float64(available) < float64(total)*lowMemoryRatio {
+		logger.Debugf("releasing memory, %d of %d bytes available", available, total)
+		o.stateEng.ReleaseMemory()
+	}
 }
 
 // Stop stops the ensure loop and the managers under the StateEngine.
@@ -191,6 +305,7 @@ func (o *Overlord) Stop() error {
 	o.loopTomb.Kill(nil)
 	err1 := o.loopTomb.Wait()
 	o.stateEng.Stop()
+	o.stateLock.Close()
 	return err1
 }
 
@@ -238,6 +353,34 @@ func (o *Overlord) Settle() error {
 	return nil
 }
 
+// Idle returns whether no change is in progress, and when the managers
+// next need to run on their own, or zero if they don't.
+func (o *Overlord) Idle() (idle bool, wakeup time.Time) {
+	st := o.State()
+	st.Lock()
+	defer st.Unlock()
+	for _, chg := range st.Changes() {
+		if !chg.Status().Ready() {
+			return false, time.Time{}
+		}
+	}
+	status, err := snapstate.GetRefreshStatus(st)
+	if err != nil {
+		logger.Noticef("cannot get the auto-refresh status: %v", err)
+		return false, time.Time{}
+	}
+	wakeup = status.NextAttempt
+	rebootAt, err := snapstate.RebootTime(st)
+	if err != nil {
+		logger.Noticef("cannot get when to reboot: %v", err)
+		return false, time.Time{}
+	}
+	if !rebootAt.IsZero() && (wakeup.IsZero() || rebootAt.Before(wakeup)) {
+		wakeup = rebootAt
+	}
+	return true, wakeup
+}
+
 // State returns the system state managed by the overlord.
 func (o *Overlord) State() *state.State {
 	return o.stateEng.State()
@@ -260,3 +403,9 @@ func (o *Overlord) AssertManager() *assertstate.AssertManager {
 func (o *Overlord) InterfaceManager() *ifacestate.InterfaceManager {
 	return o.ifaceMgr
 }
+
+// HookManager returns the hook manager responsible for running hooks
+// under the overlord.
+func (o *Overlord) HookManager() *hookstate.HookManager {
+	return o.hookMgr
+}
@@ -23,6 +23,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sync/atomic"
 	"syscall"
 	"testing"
 	"time"
@@ -31,10 +32,12 @@ import (
 	"gopkg.in/tomb.v2"
 
 	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/testutil"
 
 	"github.com/snapcore/snapd/overlord"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/overlord/statelog"
 )
 
 func TestOverlord(t *testing.T) { TestingT(t) }
@@ -61,6 +64,7 @@ func (ovs *overlordSuite) TestNew(c *C) {
 	c.Check(o.SnapManager(), NotNil)
 	c.Check(o.AssertManager(), NotNil)
 	c.Check(o.InterfaceManager(), NotNil)
+	c.Check(o.HookManager(), NotNil)
 
 	s := o.State()
 	c.Check(s, NotNil)
@@ -85,6 +89,32 @@ func (ovs *overlordSuite) TestNewWithGoodState(c *C) {
 	c.Assert(string(d), DeepEquals, string(fakeState))
 }
 
+func (ovs *overlordSuite) TestNewWithStateLog(c *C) {
+	fakeState := []byte(`{"data":{"some":"data"},"changes":null,"tasks":null,"last-change-id":0,"last-task-id":0}`)
+	c.Assert(os.MkdirAll(filepath.Dir(dirs.SnapStateLogFile), 0755), IsNil)
+	log, err := statelog.Open(dirs.SnapStateLogFile)
+	c.Assert(err, IsNil)
+	c.Assert(log.Checkpoint(fakeState), IsNil)
+	c.Assert(log.Close(), IsNil)
+
+	o, err := overlord.New()
+	c.Assert(err, IsNil)
+
+	st := o.State()
+	st.Lock()
+	d, err := st.MarshalJSON()
+	c.Assert(err, IsNil)
+	c.Check(string(d), Equals, string(fakeState))
+	st.Set("some", "other")
+	st.Unlock()
+
+	// the changes went to the log, not to a state file
+	c.Check(osutil.FileExists(dirs.SnapStateFile), Equals, false)
+	data, err := statelog.ReadData(dirs.SnapStateLogFile)
+	c.Assert(err, IsNil)
+	c.Check(string(data), Matches, `.*"some":"other".*`)
+}
+
 func (ovs *overlordSuite) TestNewWithInvalidState(c *C) {
 	fakeState := []byte(``)
 	err := ioutil.WriteFile(dirs.SnapStateFile, fakeState, 0600)
@@ -94,6 +124,20 @@ func (ovs *overlordSuite) TestNewWithInvalidState(c *C) {
 	c.Assert(err, ErrorMatches, "EOF")
 }
 
+func (ovs *overlordSuite) TestNewLocksState(c *C) {
+	o, err := overlord.New()
+	c.Assert(err, IsNil)
+
+	_, err = overlord.New()
+	c.Check(err, ErrorMatches, "cannot lock the system state: snapd is running already")
+
+	// stopping the overlord lets go of the state
+	o.Loop()
+	c.Assert(o.Stop(), IsNil)
+	_, err = overlord.New()
+	c.Check(err, IsNil)
+}
+
 type witnessManager struct {
 	state          *state.State
 	expectedEnsure int
@@ -457,20 +501,125 @@ func (ovs *overlordSuite) TestRequestRestartNoHandler(c *C) {
 	o, err := overlord.New()
 	c.Assert(err, IsNil)
 
-	o.State().RequestRestart()
+	o.State().RequestRestart(state.RestartDaemon)
 }
 
 func (ovs *overlordSuite) TestRequestRestartHandler(c *C) {
 	o, err := overlord.New()
 	c.Assert(err, IsNil)
 
-	restartRequested := false
+	var restartRequested []state.RestartType
 
-	o.SetRestartHandler(func() {
-		restartRequested = true
+	o.SetRestartHandler(func(t state.RestartType) {
+		restartRequested = append(restartRequested, t)
 	})
 
-	o.State().RequestRestart()
+	o.State().RequestRestart(state.RestartSystem)
+
+	c.Check(restartRequested, DeepEquals, []state.RestartType{state.RestartSystem})
+}
+
+func (ovs *overlordSuite) TestIdle(c *C) {
+	o, err := overlord.New()
+	c.Assert(err, IsNil)
+
+	next := time.Now().Add(3 * time.Hour)
+	st := o.State()
+	st.Lock()
+	st.Set("refresh-status", map[string]interface{}{"next-attempt": next})
+	chg := st.NewChange("install-snap", "...")
+	t := st.NewTask("nop", "...")
+	chg.AddTask(t)
+	st.Unlock()
+
+	idle, _ := o.Idle()
+	c.Check(idle, Equals, false)
+
+	st.Lock()
+	t.SetStatus(state.DoneStatus)
+	st.Unlock()
+
+	idle, wakeup := o.Idle()
+	c.Check(idle, Equals, true)
+	c.Check(wakeup.Equal(next), Equals, true)
+}
+
+func (ovs *overlordSuite) TestIdleWakesUpForReboot(c *C) {
+	o, err := overlord.New()
+	c.Assert(err, IsNil)
+
+	next := time.Now().Add(3 * time.Hour)
+	rebootAt := time.Now().Add(time.Hour)
+	st := o.State()
+	st.Lock()
+	st.Set("refresh-status", map[string]interface{}{"next-attempt": next})
+	st.Set("reboot-required", map[string]interface{}{
+		"snaps":     []string{"pc-kernel"},
+		"boot-id":   "",
+		"reboot-at": rebootAt,
+	})
+	st.Unlock()
+
+	idle, wakeup := o.Idle()
+	c.Check(idle, Equals, true)
+	c.Check(wakeup.Equal(rebootAt), Equals, true)
+}
+
+func (ovs *overlordSuite) TestEnsureLoopReleasesMemoryWhenLow(c *C) {
+	restoreIntv := overlord.MockEnsureInterval(10 * time.Millisecond)
+	defer restoreIntv()
+	restoreCheckIntv := overlord.MockMemoryCheckInterval(0)
+	defer restoreCheckIntv()
+	available := uint64(500)
+	restoreMemInfo := overlord.MockMemInfo(func() (uint64, uint64, error) {
+		return 1000, atomic.LoadUint64(&available), nil
+	})
+	defer restoreMemInfo()
+	freed := make(chan bool, 10)
+	restoreFree := overlord.MockFreeOSMemory(func() {
+		freed <- true
+	})
+	defer restoreFree()
+
+	o, err := overlord.New()
+	c.Assert(err, IsNil)
+
+	o.Loop()
+	defer o.Stop()
+
+	select {
+	case <-freed:
+		c.Fatal("memory released while plenty is available")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	atomic.StoreUint64(&available, 50)
+
+	select {
+	case <-freed:
+	case <-time.After(2 * time.Second):
+		c.Fatal("memory not released while short of it")
+	}
+}
+
+func (ovs *overlordSuite) TestEnsureLoopChecksMemoryAtMostEveryInterval(c *C) {
+	restoreIntv := overlord.MockEnsureInterval(10 * time.Millisecond)
+	defer restoreIntv()
+	restoreCheckIntv := overlord.MockMemoryCheckInterval(time.Hour)
+	defer restoreCheckIntv()
+	var checks int32
+	restoreMemInfo := overlord.MockMemInfo(func() (uint64, uint64, error) {
+		atomic.AddInt32(&checks, 1)
+		return 1000, 500, nil
+	})
+	defer restoreMemInfo()
+
+	o, err := overlord.New()
+	c.Assert(err, IsNil)
+
+	o.Loop()
+	time.Sleep(100 * time.Millisecond)
+	c.Assert(o.Stop(), IsNil)
 
-	c.Check(restartRequested, Equals, true)
+	c.Check(atomic.LoadInt32(&checks), Equals, int32(1))
 }
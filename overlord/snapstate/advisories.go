@@ -0,0 +1,198 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"sort"
+	"time"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/store"
+)
+
+// advisoriesInterval is how often the security advisories of the
+// installed snaps are fetched from the store.
+var advisoriesInterval = 24 * time.Hour
+
+// AdvisoryDatabase is where the snap-advisory assertions fetched from
+// the store are checked and kept.
+type AdvisoryDatabase interface {
+	Add(assert asserts.Assertion) error
+	Find(assertionType *asserts.AssertionType, headers map[string]string) (asserts.Assertion, error)
+}
+
+// OpenAdvisoryDatabase finds the assertion database keeping the
+// security advisories. It is set by the overlord when wiring the
+// managers.
+var OpenAdvisoryDatabase func() (AdvisoryDatabase, error)
+
+func advisoryDatabase() (AdvisoryDatabase, error) {
+	if OpenAdvisoryDatabase == nil {
+		return nil, nil
+	}
+	return OpenAdvisoryDatabase()
+}
+
+// SecurityWarning is about an installed snap revision affected by a
+// security advisory.
+type SecurityWarning struct {
+	Snap     string `json:"snap"`
+	Revision string `json:"revision"`
+	*asserts.Advisory
+	// Held is whether the snap is held at its revision, so it won't
+	// be refreshed to a fixed one.
+	Held bool `json:"held,omitempty"`
+}
+
+type securityWarningsByName []*SecurityWarning
+
+func (s securityWarningsByName) Len() int { return len(s) }
+func (s securityWarningsByName) Less(i, j int) bool {
+	if s[i].Snap != s[j].Snap {
+		return s[i].Snap < s[j].Snap
+	}
+	return s[i].ID < s[j].ID
+}
+func (s securityWarningsByName) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+
+// SecurityWarnings returns the warnings about the installed snap
+// revisions affected by the known security advisories, ordered by snap.
+// Note that the state must be locked by the caller.
+func SecurityWarnings(st *state.State) ([]*SecurityWarning, error) {
+	db, err := advisoryDatabase()
+	if err != nil || db == nil {
+		return nil, err
+	}
+	snapStates, err := All(st)
+	if err != nil {
+		return nil, err
+	}
+
+	var warnings []*SecurityWarning
+	for name, snapst := range snapStates {
+		current := snapst.Current()
+		if current == nil || current.SnapID == "" {
+			continue
+		}
+		a, err := db.Find(asserts.SnapAdvisoryType, map[string]string{
+			"series":  release.Series,
+			"snap-id": current.SnapID,
+		})
+		if err == asserts.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, adv := range a.(*asserts.SnapAdvisory).Advisories() {
+			if !adv.Affects(current.Revision.N) {
+				continue
+			}
+			warnings = append(warnings, &SecurityWarning{
+				Snap:     name,
+				Revision: current.Revision.String(),
+				Advisory: adv,
+				Held:     snapst.Pinned(),
+			})
+		}
+	}
+	sort.Sort(securityWarningsByName(warnings))
+	return warnings, nil
+}
+
+// fetchAdvisories fetches from the store the security advisories of
+// the given snaps and adds them to the database.
+func (m *SnapManager) fetchAdvisories(db AdvisoryDatabase, snapIDs []string) {
+	sto := m.Store()
+	for _, snapID := range snapIDs {
+		a, err := sto.Assertion(asserts.SnapAdvisoryType, []string{release.Series, snapID}, nil)
+		if err == store.ErrAssertionNotFound {
+			continue
+		}
+		if err != nil {
+			logger.Noticef("cannot fetch security advisories of snap-id %q: %v", snapID, err)
+			continue
+		}
+		err = db.Add(a)
+		if _, ok := err.(*asserts.RevisionError); ok {
+			// nothing new
+			continue
+		}
+		if err != nil {
+			logger.Noticef("cannot add security advisories of snap-id %q: %v", snapID, err)
+		}
+	}
+}
+
+// ensureAdvisories fetches the security advisories of the installed
+// snaps from the store once a day, if the user opted in.
+func (m *SnapManager) ensureAdvisories() {
+	st := m.state
+	st.Lock()
+	var enabled bool
+	configstate.Get(st, configstate.CoreSnapName, "security-advisories.enable", &enabled)
+	if !enabled {
+		st.Unlock()
+		return
+	}
+	now := timeNow()
+	var last time.Time
+	if err := st.Get("last-advisories-fetch", &last); err == nil && now.Before(last.Add(advisoriesInterval)) {
+		st.Unlock()
+		return
+	}
+	st.Set("last-advisories-fetch", now)
+
+	db, err := advisoryDatabase()
+	if err != nil || db == nil {
+		st.Unlock()
+		if err != nil {
+			logger.Noticef("cannot fetch security advisories: %v", err)
+		}
+		return
+	}
+	snapStates, err := All(st)
+	if err != nil {
+		st.Unlock()
+		logger.Noticef("cannot fetch security advisories: %v", err)
+		return
+	}
+	var snapIDs []string
+	for _, snapst := range snapStates {
+		if current := snapst.Current(); current != nil && current.SnapID != "" {
+			snapIDs = append(snapIDs, current.SnapID)
+		}
+	}
+	sort.Strings(snapIDs)
+	// don't hold the state while talking to the store
+	st.Unlock()
+
+	m.fetchAdvisories(db, snapIDs)
+
+	st.Lock()
+	defer st.Unlock()
+	if err := queueSecurityWarnings(st); err != nil {
+		logger.Noticef("cannot notify security warnings: %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"strconv"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/overlord/configstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/snap"
+)
+
+// fakeAdvisoryDatabase keeps the snap-advisory assertions by snap-id.
+type fakeAdvisoryDatabase map[string]asserts.Assertion
+
+func (db fakeAdvisoryDatabase) Add(a asserts.Assertion) error {
+	snapID := a.Header("snap-id")
+	if old, ok := db[snapID]; ok && old.Revision() >= a.Revision() {
+		return &asserts.RevisionError{Used: a.Revision(), Current: old.Revision()}
+	}
+	db[snapID] = a
+	return nil
+}
+
+func (db fakeAdvisoryDatabase) Find(assertType *asserts.AssertionType, headers map[string]string) (asserts.Assertion, error) {
+	a, ok := db[headers["snap-id"]]
+	if !ok {
+		return nil, asserts.ErrNotFound
+	}
+	return a, nil
+}
+
+func makeSnapAdvisory(c *C, snapID, body string) asserts.Assertion {
+	a, err := asserts.Decode([]byte("type: snap-advisory\n" +
+		"authority-id: canonical\n" +
+		"series: 16\n" +
+		"snap-id: " + snapID + "\n" +
+		"timestamp: 2016-09-01T12:00:00Z\n" +
+		"body-length: " + strconv.Itoa(len(body)) + "\n\n" +
+		body + "\n\n" +
+		"openpgp c2ln"))
+	c.Assert(err, IsNil)
+	return a
+}
+
+const fooAdvisories = `- id: USN-1
+  cves: [CVE-2016-1]
+  severity: high
+  summary: remote code execution
+  fixed-revision: 8
+- id: USN-2
+  severity: low
+  fixed-revision: 5
+- id: USN-3
+  severity: medium
+`
+
+func (s *snapmgrTestSuite) setUpAdvisories(c *C) fakeAdvisoryDatabase {
+	db := fakeAdvisoryDatabase{}
+	snapstate.OpenAdvisoryDatabase = func() (snapstate.AdvisoryDatabase, error) {
+		return db, nil
+	}
+	s.fakeStore.assertions = map[string]asserts.Assertion{
+		"snap-advisory/16/foo-id": makeSnapAdvisory(c, "foo-id", fooAdvisories),
+	}
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	snapstate.Set(s.state, "foo", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{OfficialName: "foo", SnapID: "foo-id", Revision: snap.R(7)}},
+		Flags:    snapstate.SnapStateFlags(snapstate.Pinned),
+	})
+	snapstate.Set(s.state, "bar", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{OfficialName: "bar", SnapID: "bar-id", Revision: snap.R(3)}},
+	})
+	snapstate.Set(s.state, "local", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{OfficialName: "local", Revision: snap.R(-1)}},
+	})
+	return db
+}
+
+func (s *snapmgrTestSuite) TestAdvisoriesNotEnabled(c *C) {
+	db := s.setUpAdvisories(c)
+
+	s.snapmgr.EnsureAdvisories()
+	c.Check(s.fakeStore.assertionFetches, HasLen, 0)
+	c.Check(db, HasLen, 0)
+}
+
+func (s *snapmgrTestSuite) TestAdvisories(c *C) {
+	now := time.Now()
+	restore := snapstate.MockTimeNow(func() time.Time { return now })
+	defer restore()
+
+	s.setUpAdvisories(c)
+	s.state.Lock()
+	c.Assert(configstate.Set(s.state, "core", "security-advisories.enable", true), IsNil)
+	s.state.Unlock()
+
+	s.snapmgr.EnsureAdvisories()
+	c.Check(s.fakeStore.assertionFetches, DeepEquals, [][]string{{"16", "bar-id"}, {"16", "foo-id"}})
+
+	s.state.Lock()
+	warnings, err := snapstate.SecurityWarnings(s.state)
+	s.state.Unlock()
+	c.Assert(err, IsNil)
+	c.Assert(warnings, HasLen, 2)
+	c.Check(warnings[0].Snap, Equals, "foo")
+	c.Check(warnings[0].Revision, Equals, "7")
+	c.Check(warnings[0].ID, Equals, "USN-1")
+	c.Check(warnings[0].CVEs, DeepEquals, []string{"CVE-2016-1"})
+	c.Check(warnings[0].FixedRevision, Equals, 8)
+	c.Check(warnings[0].Held, Equals, true)
+	c.Check(warnings[1].ID, Equals, "USN-3")
+
+	// fetched again only once a day
+	s.snapmgr.EnsureAdvisories()
+	c.Check(s.fakeStore.assertionFetches, HasLen, 2)
+
+	now = now.Add(25 * time.Hour)
+	s.snapmgr.EnsureAdvisories()
+	c.Check(s.fakeStore.assertionFetches, HasLen, 4)
+}
+
+func (s *snapmgrTestSuite) TestSecurityWarningsNoDatabase(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	warnings, err := snapstate.SecurityWarnings(s.state)
+	c.Assert(err, IsNil)
+	c.Check(warnings, HasLen, 0)
+}
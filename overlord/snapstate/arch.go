@@ -0,0 +1,74 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/snapcore/snapd/arch"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// SetArchitecture sets the architecture the snap is installed and
+// refreshed for instead of the one of the system userland, e.g. an
+// arm64 snap on a device running an armhf userland on an arm64 kernel.
+// An empty architecture drops the override.
+// Note that the state must be locked by the caller.
+func SetArchitecture(s *state.State, name, architecture string) error {
+	if architecture != "" && !arch.IsCompatibleArchitecture([]string{architecture}) {
+		return fmt.Errorf("cannot use architecture %q for snap %q: it is incompatible with this system (%s)", architecture, name, strings.Join(arch.CompatibleArchitectures(), ", "))
+	}
+
+	architectures, err := snapArchitectures(s)
+	if err != nil {
+		return err
+	}
+	if architecture == "" {
+		delete(architectures, name)
+	} else {
+		architectures[name] = architecture
+	}
+	s.Set("snap-architectures", architectures)
+	return nil
+}
+
+// Architecture returns the architecture the snap is installed and
+// refreshed for if it was set, or an empty string otherwise.
+// Note that the state must be locked by the caller.
+func Architecture(s *state.State, name string) (string, error) {
+	architectures, err := snapArchitectures(s)
+	if err != nil {
+		return "", err
+	}
+	return architectures[name], nil
+}
+
+func snapArchitectures(s *state.State) (map[string]string, error) {
+	var architectures map[string]string
+	err := s.Get("snap-architectures", &architectures)
+	if err != nil && err != state.ErrNoState {
+		return nil, err
+	}
+	if architectures == nil {
+		architectures = make(map[string]string)
+	}
+	return architectures, nil
+}
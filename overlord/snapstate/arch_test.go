@@ -0,0 +1,151 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/arch"
+	"github.com/snapcore/snapd/overlord/snapstate"
+)
+
+func mockArchitectures(userland, kernel string) (restore func()) {
+	oldUserland := arch.UbuntuArchitecture()
+	oldKernel := arch.KernelArchitecture()
+	arch.SetArchitecture(arch.ArchitectureType(userland))
+	arch.SetKernelArchitecture(arch.ArchitectureType(kernel))
+	return func() {
+		arch.SetArchitecture(arch.ArchitectureType(oldUserland))
+		arch.SetKernelArchitecture(arch.ArchitectureType(oldKernel))
+	}
+}
+
+func (s *snapmgrTestSuite) TestSetArchitecture(c *C) {
+	restore := mockArchitectures("armhf", "arm64")
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	err := snapstate.SetArchitecture(s.state, "some-snap", "arm64")
+	c.Assert(err, IsNil)
+	architecture, err := snapstate.Architecture(s.state, "some-snap")
+	c.Assert(err, IsNil)
+	c.Check(architecture, Equals, "arm64")
+
+	architecture, err = snapstate.Architecture(s.state, "other-snap")
+	c.Assert(err, IsNil)
+	c.Check(architecture, Equals, "")
+
+	err = snapstate.SetArchitecture(s.state, "some-snap", "")
+	c.Assert(err, IsNil)
+	architecture, err = snapstate.Architecture(s.state, "some-snap")
+	c.Assert(err, IsNil)
+	c.Check(architecture, Equals, "")
+}
+
+func (s *snapmgrTestSuite) TestSetArchitectureIncompatible(c *C) {
+	restore := mockArchitectures("armhf", "arm64")
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	err := snapstate.SetArchitecture(s.state, "some-snap", "amd64")
+	c.Check(err, ErrorMatches, `cannot use architecture "amd64" for snap "some-snap": it is incompatible with this system \(armhf, arm64\)`)
+}
+
+func (s *snapmgrTestSuite) TestInstallForArchitecture(c *C) {
+	restore := mockArchitectures("armhf", "arm64")
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	err := snapstate.SetArchitecture(s.state, "some-snap", "arm64")
+	c.Assert(err, IsNil)
+
+	chg := s.state.NewChange("install", "install a snap")
+	ts, err := snapstate.Install(s.state, "some-snap", "some-channel", s.user.ID, 0)
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	var ss snapstate.SnapSetup
+	err = ts.Tasks()[0].Get("snap-setup", &ss)
+	c.Assert(err, IsNil)
+	c.Check(ss.Architecture, Equals, "arm64")
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle()
+	s.state.Lock()
+
+	c.Assert(chg.Err(), IsNil)
+	c.Assert(len(s.fakeBackend.ops) >= 2, Equals, true)
+	c.Check(s.fakeBackend.ops[0].op, Equals, "storesvc-snap")
+	c.Check(s.fakeBackend.ops[0].architecture, Equals, "arm64")
+	c.Check(s.fakeBackend.ops[1].op, Equals, "storesvc-download")
+}
+
+func (s *snapmgrTestSuite) TestInstallIncompatibleArchitectureBeforeDownload(c *C) {
+	restore := mockArchitectures("armhf", "arm64")
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	err := snapstate.SetArchitecture(s.state, "some-snap", "arm64")
+	c.Assert(err, IsNil)
+
+	chg := s.state.NewChange("install", "install a snap")
+	ts, err := snapstate.Install(s.state, "some-snap", "some-channel", s.user.ID, 0)
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	// the kernel cannot run arm64 binaries after all
+	arch.SetKernelArchitecture("armhf")
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle()
+	s.state.Lock()
+
+	c.Assert(chg.Err(), ErrorMatches, `(?s).*snap "some-snap" supported architectures \(arm64\) are incompatible with this system \(armhf\).*`)
+	c.Check(s.fakeStore.downloads, HasLen, 0)
+	for _, op := range s.fakeBackend.ops {
+		c.Check(op.op, Not(Equals), "storesvc-download")
+	}
+}
+
+func (s *snapmgrTestSuite) TestInstallPathIgnoresArchitecture(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	err := snapstate.SetArchitecture(s.state, "some-snap", arch.UbuntuArchitecture())
+	c.Assert(err, IsNil)
+
+	ts, err := snapstate.InstallPath(s.state, "some-snap", "/path/to/some-snap_1.0_all.snap", "", 0)
+	c.Assert(err, IsNil)
+
+	var ss snapstate.SnapSetup
+	err = ts.Tasks()[0].Get("snap-setup", &ss)
+	c.Assert(err, IsNil)
+	c.Check(ss.Architecture, Equals, "")
+}
@@ -0,0 +1,368 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/store"
+)
+
+var (
+	// autoRefreshInterval is how often the snaps are refreshed when
+	// all is well.
+	autoRefreshInterval = 8 * time.Hour
+	// autoRefreshRetryDelay is how long to wait after a first failed
+	// attempt; it doubles with each further failure up to
+	// autoRefreshRetryMax.
+	autoRefreshRetryDelay = 10 * time.Minute
+	autoRefreshRetryMax   = 8 * time.Hour
+)
+
+var timeNow = time.Now
+
+var jitterRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+
+// jitter returns a random duration between d/2 and d, so devices that
+// failed or refreshed together don't keep hitting the store together.
+var jitter = func(d time.Duration) time.Duration {
+	half := int64(d / 2)
+	if half <= 0 {
+		return d
+	}
+	return time.Duration(half + jitterRand.Int63n(half))
+}
+
+// RefreshStatus is the state of the automatic refreshes of the snaps.
+type RefreshStatus struct {
+	LastAttempt time.Time `json:"last-attempt,omitempty"`
+	LastSuccess time.Time `json:"last-success,omitempty"`
+	NextAttempt time.Time `json:"next-attempt,omitempty"`
+	// Failures is the number of consecutive failed attempts.
+	Failures  int    `json:"failures,omitempty"`
+	LastError string `json:"last-error,omitempty"`
+
+	// ChangeID is the auto-refresh change in progress, if any.
+	ChangeID string `json:"change-id,omitempty"`
+}
+
+// The outcomes for a snap of a change on several snaps, such as an
+// auto-refresh change.
+const (
+	SnapResultSucceeded = "succeeded"
+	SnapResultFailed    = "failed"
+	// SnapResultUndone is for snaps whose operation was undone because
+	// the one of another snap of the change failed, that they depended
+	// on or that shared their lane.
+	SnapResultUndone = "undone"
+	// SnapResultSkipped is for snaps not refreshed at all, e.g. as
+	// another change was in progress for them.
+	SnapResultSkipped = "skipped"
+)
+
+// SnapResult is the outcome for one snap of a change on several snaps.
+type SnapResult struct {
+	Status    string    `json:"status"`
+	ErrorCode ErrorCode `json:"error-code,omitempty"`
+	Message   string    `json:"message,omitempty"`
+}
+
+// autoRefreshData is the data of an auto-refresh change exposed
+// through the API.
+type autoRefreshData struct {
+	SnapNames   []string               `json:"snap-names"`
+	SnapResults map[string]*SnapResult `json:"snap-results,omitempty"`
+}
+
+// SnapResults returns the outcome for each snap of the change: as
+// recorded for the auto-refresh changes, or worked out from the status
+// of its tasks once ready for the other changes on more than one snap,
+// whose snaps are undone independently. It returns nil otherwise.
+func SnapResults(chg *state.Change) map[string]*SnapResult {
+	if chg.Kind() == "auto-refresh" {
+		var data autoRefreshData
+		if err := chg.Get("api-data", &data); err != nil {
+			return nil
+		}
+		return data.SnapResults
+	}
+	if !chg.Status().Ready() {
+		return nil
+	}
+	results := make(map[string]*SnapResult)
+	taskSnapResults(chg, results)
+	if len(results) < 2 {
+		return nil
+	}
+	return results
+}
+
+// recordSnapResults works out the outcome of the refresh of each snap
+// by the ready auto-refresh change from the status of its tasks,
+// persisting them in the change besides the snaps that were skipped.
+func recordSnapResults(chg *state.Change) {
+	var data autoRefreshData
+	if err := chg.Get("api-data", &data); err != nil {
+		logger.Noticef("cannot get data of change %s: %v", chg.ID(), err)
+		return
+	}
+	if data.SnapResults == nil {
+		data.SnapResults = make(map[string]*SnapResult)
+	}
+	taskSnapResults(chg, data.SnapResults)
+	chg.Set("api-data", &data)
+}
+
+// taskSnapResults adds to results the outcome for each snap of the
+// change, from the status of its tasks.
+func taskSnapResults(chg *state.Change, results map[string]*SnapResult) {
+	for _, t := range chg.Tasks() {
+		ss, err := TaskSnapSetup(t)
+		if err != nil {
+			continue
+		}
+		result := results[ss.Name]
+		if result == nil {
+			result = &SnapResult{Status: SnapResultSucceeded}
+			results[ss.Name] = result
+		}
+		switch {
+		case result.Status == SnapResultFailed:
+			// the failure is what matters
+		case t.Status() == state.ErrorStatus:
+			msg := taskFailure(t)
+			result.Status = SnapResultFailed
+			result.ErrorCode = classifyTask(t)
+			result.Message = msg
+		case t.Status() != state.DoneStatus:
+			result.Status = SnapResultUndone
+		}
+	}
+}
+
+// retryDelay returns the delay before the next attempt after the given
+// number of consecutive failures.
+func retryDelay(failures int) time.Duration {
+	delay := autoRefreshRetryDelay
+	for i := 1; i < failures && delay < autoRefreshRetryMax; i++ {
+		delay *= 2
+	}
+	if delay > autoRefreshRetryMax {
+		delay = autoRefreshRetryMax
+	}
+	return jitter(delay)
+}
+
+// GetRefreshStatus returns the status of the automatic refreshes.
+// Note that the state must be locked by the caller.
+func GetRefreshStatus(st *state.State) (*RefreshStatus, error) {
+	var status RefreshStatus
+	if err := st.Get("refresh-status", &status); err != nil && err != state.ErrNoState {
+		return nil, err
+	}
+	return &status, nil
+}
+
+func (status *RefreshStatus) succeeded(now time.Time) {
+	status.LastSuccess = now
+	status.Failures = 0
+	status.LastError = ""
+	status.NextAttempt = now.Add(jitter(autoRefreshInterval))
+}
+
+func (status *RefreshStatus) failed(now time.Time, err error) {
+	status.Failures++
+	status.LastError = err.Error()
+	status.NextAttempt = now.Add(retryDelay(status.Failures))
+	if after := now.Add(store.RetryAfter(err)); after.After(status.NextAttempt) {
+		// the store asked to wait longer
+		status.NextAttempt = after
+	}
+	logger.Noticef("cannot auto-refresh snaps (attempt %d), next attempt at %s: %v", status.Failures, status.NextAttempt.Format(time.RFC3339), err)
+}
+
+// refreshCandidates returns what to ask the store about for refreshing
+// the installed snaps.
+func refreshCandidates(st *state.State) ([]*store.RefreshCandidate, error) {
+	snapStates, err := All(st)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make([]*store.RefreshCandidate, 0, len(snapStates))
+	for name, snapst := range snapStates {
+		// snaps in try mode or pinned to a revision are not refreshed
+		if snapst.TryMode() || snapst.Pinned() {
+			continue
+		}
+		info, err := readInfo(name, snapst.Current())
+		if err != nil {
+			logger.Noticef("cannot read info for snap %q, not refreshing it: %v", name, err)
+			continue
+		}
+		if info.SnapID == "" {
+			// not from the store
+			continue
+		}
+		candidates = append(candidates, &store.RefreshCandidate{
+			Channel:  snapst.Channel,
+			DevMode:  snapst.DevMode(),
+			SnapID:   info.SnapID,
+			Revision: info.Revision,
+			Epoch:    info.Epoch,
+		})
+	}
+	return candidates, nil
+}
+
+type infosByName []*snap.Info
+
+func (s infosByName) Len() int           { return len(s) }
+func (s infosByName) Less(i, j int) bool { return s[i].Name() < s[j].Name() }
+func (s infosByName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// autoRefresh starts a change refreshing the snaps with updates, if
+// any, returning nil otherwise.
+func (m *SnapManager) autoRefresh() (*state.Change, error) {
+	st := m.state
+
+	candidates, err := refreshCandidates(st)
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	// don't hold the state while talking to the store
+	st.Unlock()
+	updates, err := m.Store().ListRefresh(candidates, nil)
+	st.Lock()
+	if err != nil {
+		return nil, err
+	}
+
+	available := make([]*snap.Info, 0, len(updates))
+	for _, update := range updates {
+		if !m.HeldByRollout(update) {
+			available = append(available, update)
+		}
+	}
+	// keep them to be able to refresh offline later
+	CacheRefreshCandidates(st, available)
+	pruneDownloadCache(st)
+
+	var names []string
+	var tss []*state.TaskSet
+	skipped := make(map[string]*SnapResult)
+	sort.Sort(infosByName(available))
+	for _, update := range available {
+		ts, err := Update(st, update.Name(), "", 0, 0)
+		if err != nil {
+			// e.g. a change in progress for the snap
+			logger.Noticef("cannot auto-refresh snap %q: %v", update.Name(), err)
+			skipped[update.Name()] = &SnapResult{Status: SnapResultSkipped, Message: err.Error()}
+			continue
+		}
+		// each snap is refreshed in its own lane, so that a failure
+		// only undoes the refresh of that snap
+		ts.JoinLane(st.NewLane())
+		names = append(names, update.Name())
+		tss = append(tss, ts)
+	}
+	if len(tss) == 0 {
+		return nil, nil
+	}
+	if err := WaitForDependencies(st, names, tss); err != nil {
+		return nil, err
+	}
+
+	chg := st.NewChange("auto-refresh", fmt.Sprintf(i18n.G("Auto-refresh snaps %s"), strings.Join(names, ", ")))
+	for _, ts := range tss {
+		chg.AddAll(ts)
+	}
+	chg.Set("api-data", &autoRefreshData{SnapNames: names, SnapResults: skipped})
+	queueNotification(st, "refresh-pending", "/v2/changes/"+chg.ID(), fmt.Sprintf("Refresh of snaps %s pending.", strings.Join(names, ", ")), map[string]interface{}{
+		"snaps": names,
+	})
+	return chg, nil
+}
+
+// ensureAutoRefresh refreshes the snaps when it is time to, backing off
+// on failures.
+// Note that the state must be locked by the caller.
+func (m *SnapManager) ensureAutoRefresh() error {
+	st := m.state
+	status, err := GetRefreshStatus(st)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		st.Set("refresh-status", status)
+	}()
+	now := timeNow()
+
+	if status.ChangeID != "" {
+		chg := st.Change(status.ChangeID)
+		if chg != nil && !chg.Status().Ready() {
+			return nil
+		}
+		status.ChangeID = ""
+		if chg != nil {
+			recordSnapResults(chg)
+		}
+		if chg != nil && chg.Err() != nil {
+			status.failed(now, chg.Err())
+		} else {
+			status.succeeded(now)
+		}
+		return nil
+	}
+
+	if status.NextAttempt.IsZero() {
+		// spread the first attempts of devices booted together
+		status.NextAttempt = now.Add(jitter(autoRefreshInterval))
+		return nil
+	}
+	if now.Before(status.NextAttempt) {
+		return nil
+	}
+
+	status.LastAttempt = now
+	chg, err := m.autoRefresh()
+	switch {
+	case err != nil:
+		status.failed(now, err)
+	case chg == nil:
+		status.succeeded(now)
+	default:
+		status.ChangeID = chg.ID()
+		st.EnsureBefore(0)
+	}
+	return nil
+}
@@ -0,0 +1,466 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/store"
+)
+
+type autoRefreshSuite struct {
+	// not embedded, not to run its tests again
+	mgr snapmgrTestSuite
+
+	now     time.Time
+	restore []func()
+}
+
+var _ = Suite(&autoRefreshSuite{})
+
+func (s *autoRefreshSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	s.mgr.SetUpTest(c)
+
+	s.now = time.Date(2016, 8, 1, 12, 0, 0, 0, time.UTC)
+	s.restore = []func(){
+		snapstate.MockTimeNow(func() time.Time { return s.now }),
+		// no randomness
+		snapstate.MockJitter(func(d time.Duration) time.Duration { return d }),
+	}
+
+	s.mgr.state.Lock()
+	defer s.mgr.state.Unlock()
+	snapstate.Set(s.mgr.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Channel:  "stable",
+		Sequence: []*snap.SideInfo{{OfficialName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(7)}},
+	})
+}
+
+func (s *autoRefreshSuite) TearDownTest(c *C) {
+	for _, restore := range s.restore {
+		restore()
+	}
+	s.mgr.TearDownTest(c)
+	dirs.SetRootDir("")
+}
+
+func (s *autoRefreshSuite) refreshStatus(c *C) *snapstate.RefreshStatus {
+	s.mgr.state.Lock()
+	defer s.mgr.state.Unlock()
+	status, err := snapstate.GetRefreshStatus(s.mgr.state)
+	c.Assert(err, IsNil)
+	return status
+}
+
+func (s *autoRefreshSuite) ensure(c *C) {
+	c.Assert(s.mgr.snapmgr.Ensure(), IsNil)
+	s.mgr.snapmgr.Wait()
+}
+
+func (s *autoRefreshSuite) listRefreshCalls() int {
+	n := 0
+	for _, op := range s.mgr.fakeBackend.ops {
+		if op.op == "storesvc-list-refresh" {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *autoRefreshSuite) TestFirstEnsureSchedules(c *C) {
+	s.ensure(c)
+
+	c.Check(s.listRefreshCalls(), Equals, 0)
+	c.Check(s.refreshStatus(c).NextAttempt.Equal(s.now.Add(8*time.Hour)), Equals, true)
+}
+
+func (s *autoRefreshSuite) TestNoUpdates(c *C) {
+	s.ensure(c)
+	s.now = s.now.Add(9 * time.Hour)
+	s.ensure(c)
+
+	c.Check(s.mgr.fakeBackend.ops, DeepEquals, []fakeOp{
+		{op: "storesvc-list-refresh", name: "some-snap-id", revno: snap.R(7)},
+	})
+	status := s.refreshStatus(c)
+	c.Check(status.LastAttempt.Equal(s.now), Equals, true)
+	c.Check(status.LastSuccess.Equal(s.now), Equals, true)
+	c.Check(status.NextAttempt.Equal(s.now.Add(8*time.Hour)), Equals, true)
+	c.Check(status.Failures, Equals, 0)
+
+	// nothing happens until the next attempt is due
+	s.now = s.now.Add(time.Hour)
+	s.ensure(c)
+	c.Check(s.listRefreshCalls(), Equals, 1)
+}
+
+func (s *autoRefreshSuite) TestStoreFailureBacksOff(c *C) {
+	s.mgr.fakeStore.refreshErr = errors.New("store is down")
+	s.ensure(c)
+
+	for i, delay := range []time.Duration{10 * time.Minute, 20 * time.Minute, 40 * time.Minute} {
+		s.now = s.refreshStatus(c).NextAttempt
+		s.ensure(c)
+
+		status := s.refreshStatus(c)
+		c.Check(status.Failures, Equals, i+1)
+		c.Check(status.LastError, Equals, "store is down")
+		c.Check(status.NextAttempt.Equal(s.now.Add(delay)), Equals, true, Commentf("attempt %d", i+1))
+	}
+	c.Check(s.listRefreshCalls(), Equals, 3)
+
+	// recovering resets the backoff
+	s.mgr.fakeStore.refreshErr = nil
+	s.now = s.refreshStatus(c).NextAttempt
+	s.ensure(c)
+	status := s.refreshStatus(c)
+	c.Check(status.Failures, Equals, 0)
+	c.Check(status.LastError, Equals, "")
+	c.Check(status.NextAttempt.Equal(s.now.Add(8*time.Hour)), Equals, true)
+}
+
+func (s *autoRefreshSuite) TestStoreRateLimitedWaits(c *C) {
+	s.mgr.fakeStore.refreshErr = &store.ErrDownload{Code: 429, RetryAfter: 2 * time.Hour}
+	s.ensure(c)
+	s.now = s.refreshStatus(c).NextAttempt
+	s.ensure(c)
+
+	status := s.refreshStatus(c)
+	c.Check(status.Failures, Equals, 1)
+	c.Check(status.NextAttempt.Equal(s.now.Add(2*time.Hour)), Equals, true)
+
+	// the backoff wins when it is longer
+	s.mgr.fakeStore.refreshErr = &store.ErrDownload{Code: 429, RetryAfter: time.Minute}
+	s.now = status.NextAttempt
+	s.ensure(c)
+	status = s.refreshStatus(c)
+	c.Check(status.Failures, Equals, 2)
+	c.Check(status.NextAttempt.Equal(s.now.Add(20*time.Minute)), Equals, true)
+}
+
+func (s *autoRefreshSuite) TestRetryDelayCapped(c *C) {
+	c.Check(snapstate.RetryDelay(1), Equals, 10*time.Minute)
+	c.Check(snapstate.RetryDelay(2), Equals, 20*time.Minute)
+	c.Check(snapstate.RetryDelay(6), Equals, 320*time.Minute)
+	c.Check(snapstate.RetryDelay(7), Equals, 8*time.Hour)
+	c.Check(snapstate.RetryDelay(1000), Equals, 8*time.Hour)
+}
+
+func (s *autoRefreshSuite) TestSkipsPinnedAndLocal(c *C) {
+	s.mgr.state.Lock()
+	snapstate.Set(s.mgr.state, "pinned-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{OfficialName: "pinned-snap", SnapID: "pinned-snap-id", Revision: snap.R(1)}},
+		Flags:    snapstate.SnapStateFlags(snapstate.Pinned),
+	})
+	snapstate.Set(s.mgr.state, "local-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{OfficialName: "local-snap", Revision: snap.R(-1)}},
+	})
+	s.mgr.state.Unlock()
+
+	s.ensure(c)
+	s.now = s.now.Add(9 * time.Hour)
+	s.ensure(c)
+
+	c.Check(s.mgr.fakeBackend.ops, DeepEquals, []fakeOp{
+		{op: "storesvc-list-refresh", name: "some-snap-id", revno: snap.R(7)},
+	})
+}
+
+func (s *autoRefreshSuite) TestRefreshChange(c *C) {
+	s.mgr.fakeStore.refreshes = []*snap.Info{{
+		SideInfo: snap.SideInfo{OfficialName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(8)},
+	}}
+	s.ensure(c)
+	s.now = s.now.Add(9 * time.Hour)
+	c.Assert(s.mgr.snapmgr.Ensure(), IsNil)
+
+	status := s.refreshStatus(c)
+	c.Assert(status.ChangeID, Not(Equals), "")
+
+	s.mgr.state.Lock()
+	chg := s.mgr.state.Change(status.ChangeID)
+	c.Assert(chg, NotNil)
+	c.Check(chg.Kind(), Equals, "auto-refresh")
+	c.Check(chg.Summary(), Equals, "Auto-refresh snaps some-snap")
+	queued := queuedNotifications(c, s.mgr.state)
+	c.Assert(queued, HasLen, 1)
+	c.Check(queued[0].Type, Equals, "refresh-pending")
+	c.Check(queued[0].Resource, Equals, "/v2/changes/"+chg.ID())
+	c.Check(queued[0].Message(), Equals, "Refresh of snaps some-snap pending.")
+	// make it fail before it runs
+	for _, t := range chg.Tasks() {
+		t.SetStatus(state.HoldStatus)
+	}
+	t := chg.Tasks()[0]
+	t.Errorf("dial tcp: connection refused")
+	t.SetStatus(state.ErrorStatus)
+	s.mgr.state.Unlock()
+
+	s.now = s.now.Add(time.Minute)
+	s.ensure(c)
+
+	status = s.refreshStatus(c)
+	c.Check(status.ChangeID, Equals, "")
+	c.Check(status.Failures, Equals, 1)
+	c.Check(status.LastError, Matches, "(?s).*connection refused.*")
+	c.Check(status.NextAttempt.Equal(s.now.Add(10*time.Minute)), Equals, true)
+}
+
+func (s *autoRefreshSuite) TestRefreshCachesCandidates(c *C) {
+	h := sha512.Sum512([]byte("snap content"))
+	digest := hex.EncodeToString(h[:])
+	c.Assert(os.MkdirAll(dirs.SnapDownloadCacheDir, 0755), IsNil)
+	for _, name := range []string{digest, "stale"} {
+		c.Assert(ioutil.WriteFile(filepath.Join(dirs.SnapDownloadCacheDir, name), []byte("snap content"), 0644), IsNil)
+	}
+
+	s.mgr.fakeStore.refreshes = []*snap.Info{{
+		SideInfo: snap.SideInfo{OfficialName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(8), Sha512: digest},
+	}}
+	s.ensure(c)
+	s.now = s.now.Add(9 * time.Hour)
+	c.Assert(s.mgr.snapmgr.Ensure(), IsNil)
+
+	s.mgr.state.Lock()
+	defer s.mgr.state.Unlock()
+	names, err := snapstate.OfflineRefreshes(s.mgr.state)
+	c.Assert(err, IsNil)
+	c.Check(names, DeepEquals, []string{"some-snap"})
+	c.Check(osutil.FileExists(filepath.Join(dirs.SnapDownloadCacheDir, digest)), Equals, true)
+	c.Check(osutil.FileExists(filepath.Join(dirs.SnapDownloadCacheDir, "stale")), Equals, false)
+}
+
+func (s *autoRefreshSuite) TestRefreshLanes(c *C) {
+	s.mgr.state.Lock()
+	snapstate.Set(s.mgr.state, "core", &snapstate.SnapState{
+		Active:   true,
+		Channel:  "stable",
+		Sequence: []*snap.SideInfo{{OfficialName: "core", SnapID: "core-id", Revision: snap.R(1)}},
+	})
+	s.mgr.state.Unlock()
+
+	s.mgr.fakeStore.refreshes = []*snap.Info{{
+		SideInfo: snap.SideInfo{OfficialName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(8)},
+	}, {
+		Type:     snap.TypeOS,
+		SideInfo: snap.SideInfo{OfficialName: "core", SnapID: "core-id", Revision: snap.R(2)},
+	}}
+	s.ensure(c)
+	s.now = s.now.Add(9 * time.Hour)
+	c.Assert(s.mgr.snapmgr.Ensure(), IsNil)
+
+	status := s.refreshStatus(c)
+	c.Assert(status.ChangeID, Not(Equals), "")
+
+	s.mgr.state.Lock()
+	defer s.mgr.state.Unlock()
+	chg := s.mgr.state.Change(status.ChangeID)
+	c.Assert(chg, NotNil)
+
+	lanes := make(map[string]int)
+	var coreTasks []*state.Task
+	var first *state.Task
+	for _, t := range chg.Tasks() {
+		ss, err := snapstate.TaskSnapSetup(t)
+		c.Assert(err, IsNil)
+		c.Assert(t.Lanes(), HasLen, 1)
+		if lane, ok := lanes[ss.Name]; ok {
+			c.Check(t.Lanes()[0], Equals, lane)
+		}
+		lanes[ss.Name] = t.Lanes()[0]
+		if ss.Name == "core" {
+			coreTasks = append(coreTasks, t)
+		} else if first == nil {
+			first = t
+		}
+	}
+	c.Check(lanes["core"], Not(Equals), lanes["some-snap"])
+	// some-snap waits for all of the core refresh
+	c.Assert(first, NotNil)
+	c.Check(first.WaitTasks(), HasLen, len(coreTasks))
+}
+
+func (s *autoRefreshSuite) TestRefreshProvidersFirst(c *C) {
+	s.mgr.state.Lock()
+	snapstate.Set(s.mgr.state, "other-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{OfficialName: "other-snap", SnapID: "other-snap-id", Revision: snap.R(1)}},
+	})
+	s.mgr.state.Unlock()
+	// some-snap has a plug connected to a slot of other-snap
+	snapstate.SnapProviders = func(st *state.State) (map[string]map[string]bool, error) {
+		return map[string]map[string]bool{"some-snap": {"other-snap": true}}, nil
+	}
+	defer func() { snapstate.SnapProviders = nil }()
+
+	s.mgr.fakeStore.refreshes = []*snap.Info{{
+		SideInfo: snap.SideInfo{OfficialName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(8)},
+	}, {
+		SideInfo: snap.SideInfo{OfficialName: "other-snap", SnapID: "other-snap-id", Revision: snap.R(2)},
+	}}
+	s.ensure(c)
+	s.now = s.now.Add(9 * time.Hour)
+	c.Assert(s.mgr.snapmgr.Ensure(), IsNil)
+
+	status := s.refreshStatus(c)
+	c.Assert(status.ChangeID, Not(Equals), "")
+
+	s.mgr.state.Lock()
+	defer s.mgr.state.Unlock()
+	chg := s.mgr.state.Change(status.ChangeID)
+	c.Assert(chg, NotNil)
+
+	first := make(map[string]*state.Task)
+	count := make(map[string]int)
+	for _, t := range chg.Tasks() {
+		ss, err := snapstate.TaskSnapSetup(t)
+		c.Assert(err, IsNil)
+		if first[ss.Name] == nil {
+			first[ss.Name] = t
+		}
+		count[ss.Name]++
+	}
+	c.Check(first["other-snap"].WaitTasks(), HasLen, 0)
+	c.Check(first["some-snap"].WaitTasks(), HasLen, count["other-snap"])
+}
+
+func (s *autoRefreshSuite) TestSnapResults(c *C) {
+	s.mgr.state.Lock()
+	for _, name := range []string{"other-snap", "busy-snap"} {
+		snapstate.Set(s.mgr.state, name, &snapstate.SnapState{
+			Active:   true,
+			Channel:  "stable",
+			Sequence: []*snap.SideInfo{{OfficialName: name, SnapID: name + "-id", Revision: snap.R(1)}},
+		})
+	}
+	// a change in progress for busy-snap
+	busy := s.mgr.state.NewChange("refresh-snap", "...")
+	prereq := s.mgr.state.NewTask("prerequisite", "...")
+	t := s.mgr.state.NewTask("link-snap", "...")
+	t.Set("snap-setup", &snapstate.SnapSetup{Name: "busy-snap"})
+	t.WaitFor(prereq)
+	busy.AddTask(prereq)
+	busy.AddTask(t)
+	s.mgr.state.Unlock()
+
+	s.mgr.fakeStore.refreshes = []*snap.Info{
+		{SideInfo: snap.SideInfo{OfficialName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(8)}},
+		{SideInfo: snap.SideInfo{OfficialName: "other-snap", SnapID: "other-snap-id", Revision: snap.R(2)}},
+		{SideInfo: snap.SideInfo{OfficialName: "busy-snap", SnapID: "busy-snap-id", Revision: snap.R(2)}},
+	}
+	s.ensure(c)
+	s.now = s.now.Add(9 * time.Hour)
+	c.Assert(s.mgr.snapmgr.Ensure(), IsNil)
+
+	status := s.refreshStatus(c)
+	c.Assert(status.ChangeID, Not(Equals), "")
+
+	s.mgr.state.Lock()
+	chg := s.mgr.state.Change(status.ChangeID)
+	c.Assert(chg, NotNil)
+	c.Check(chg.Summary(), Equals, "Auto-refresh snaps other-snap, some-snap")
+	c.Check(snapstate.SnapResults(chg), DeepEquals, map[string]*snapstate.SnapResult{
+		"busy-snap": {Status: "skipped", Message: `snap "busy-snap" has changes in progress`},
+	})
+	// other-snap fails to download, undoing the refresh of some-snap
+	for _, t := range chg.Tasks() {
+		ss, err := snapstate.TaskSnapSetup(t)
+		c.Assert(err, IsNil)
+		switch {
+		case ss.Name == "other-snap" && t.Kind() == "download-snap":
+			t.Errorf("dial tcp: connection refused")
+			t.SetStatus(state.ErrorStatus)
+		case ss.Name == "other-snap":
+			t.SetStatus(state.HoldStatus)
+		default:
+			t.SetStatus(state.UndoneStatus)
+		}
+	}
+	s.mgr.state.Unlock()
+
+	s.now = s.now.Add(time.Minute)
+	s.ensure(c)
+
+	s.mgr.state.Lock()
+	defer s.mgr.state.Unlock()
+	c.Check(snapstate.SnapResults(chg), DeepEquals, map[string]*snapstate.SnapResult{
+		"busy-snap":  {Status: "skipped", Message: `snap "busy-snap" has changes in progress`},
+		"other-snap": {Status: "failed", ErrorCode: "network", Message: "dial tcp: connection refused"},
+		"some-snap":  {Status: "undone"},
+	})
+	var data map[string]interface{}
+	c.Assert(chg.Get("api-data", &data), IsNil)
+	c.Check(data["snap-names"], DeepEquals, []interface{}{"other-snap", "some-snap"})
+}
+
+func (s *autoRefreshSuite) TestSnapResultsSucceeded(c *C) {
+	s.mgr.fakeStore.refreshes = []*snap.Info{{
+		SideInfo: snap.SideInfo{OfficialName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(8)},
+	}}
+	s.ensure(c)
+	s.now = s.now.Add(9 * time.Hour)
+	c.Assert(s.mgr.snapmgr.Ensure(), IsNil)
+
+	status := s.refreshStatus(c)
+	s.mgr.state.Lock()
+	chg := s.mgr.state.Change(status.ChangeID)
+	c.Assert(chg, NotNil)
+	c.Check(snapstate.SnapResults(chg), HasLen, 0)
+	for _, t := range chg.Tasks() {
+		t.SetStatus(state.DoneStatus)
+	}
+	s.mgr.state.Unlock()
+
+	s.ensure(c)
+
+	s.mgr.state.Lock()
+	defer s.mgr.state.Unlock()
+	c.Check(snapstate.SnapResults(chg), DeepEquals, map[string]*snapstate.SnapResult{
+		"some-snap": {Status: "succeeded"},
+	})
+}
+
+type jitterSuite struct{}
+
+var _ = Suite(&jitterSuite{})
+
+func (s *jitterSuite) TestRetryDelayJitter(c *C) {
+	for i := 0; i < 100; i++ {
+		delay := snapstate.RetryDelay(2)
+		c.Assert(delay >= 10*time.Minute && delay < 20*time.Minute, Equals, true, Commentf("%v", delay))
+	}
+}
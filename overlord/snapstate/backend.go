@@ -20,18 +20,23 @@
 package snapstate
 
 import (
+	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/overlord/snapstate/backend"
 	"github.com/snapcore/snapd/progress"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/store"
 )
 
-// A StoreService can find, list available updates and download snaps.
+// A StoreService can find, list available updates and download snaps,
+// and fetch assertions.
 type StoreService interface {
 	Snap(name, channel string, auther store.Authenticator) (*snap.Info, error)
+	SnapForArchitecture(name, channel, architecture string, auther store.Authenticator) (*snap.Info, error)
+	SnapRevision(name string, revision snap.Revision, auther store.Authenticator) (*snap.Info, error)
 	Find(query, channel string, auther store.Authenticator) ([]*snap.Info, error)
 	ListRefresh([]*store.RefreshCandidate, store.Authenticator) ([]*snap.Info, error)
 	SuggestedCurrency() string
+	Assertion(assertType *asserts.AssertionType, primaryKey []string, auther store.Authenticator) (asserts.Assertion, error)
 
 	Download(*snap.Info, progress.Meter, store.Authenticator) (string, error)
 }
@@ -41,15 +46,29 @@ type managerBackend interface {
 	SetupSnap(snapFilePath string, si *snap.SideInfo, meter progress.Meter) error
 	CopySnapData(newSnap, oldSnap *snap.Info, meter progress.Meter) error
 	LinkSnap(info *snap.Info) error
+	LinkSnapWithoutServices(info *snap.Info) error
+	StartSnapServices(info *snap.Info, meter progress.Meter) error
 	// the undoers for install
 	UndoSetupSnap(s snap.PlaceInfo, meter progress.Meter) error
 	UndoCopySnapData(newSnap, oldSnap *snap.Info, meter progress.Meter) error
 
 	// remove releated
 	UnlinkSnap(info *snap.Info, meter progress.Meter) error
+	UnlinkSnapForRefresh(info, next *snap.Info, meter progress.Meter) error
 	RemoveSnapFiles(s snap.PlaceInfo, meter progress.Meter) error
 	RemoveSnapData(info *snap.Info) error
 	RemoveSnapCommonData(info *snap.Info) error
+	RemoveSnapPublisherData(info *snap.Info) error
+
+	// read-only data related
+	SealSnapData(info *snap.Info, meter progress.Meter) error
+	UnsealSnapData(info *snap.Info, meter progress.Meter) error
+
+	// boot related
+	InactiveServices(info *snap.Info) ([]string, error)
+
+	// services related
+	RestartSnapServices(info *snap.Info, meter progress.Meter) error
 
 	// testing helpers
 	Current(cur *snap.Info)
@@ -58,7 +58,29 @@ func updateCurrentSymlinks(info *snap.Info) error {
 
 // LinkSnap makes the snap available by generating wrappers and setting the current symlinks.
 func (b Backend) LinkSnap(info *snap.Info) error {
-	if err := generateWrappers(info); err != nil {
+	return linkSnap(info, true)
+}
+
+// LinkSnapWithoutServices makes the snap available like LinkSnap, but
+// only writes the service units of the snap, leaving them disabled and
+// stopped until StartSnapServices is called.
+func (b Backend) LinkSnapWithoutServices(info *snap.Info) error {
+	return linkSnap(info, false)
+}
+
+// StartSnapServices enables and starts the services of a snap linked
+// with LinkSnapWithoutServices.
+func (b Backend) StartSnapServices(info *snap.Info, meter progress.Meter) error {
+	return wrappers.AddSnapServices(info, meter)
+}
+
+// RestartSnapServices restarts the running services of a snap.
+func (b Backend) RestartSnapServices(info *snap.Info, meter progress.Meter) error {
+	return wrappers.RestartSnapServices(info, meter)
+}
+
+func linkSnap(info *snap.Info, startServices bool) error {
+	if err := generateWrappers(info, startServices); err != nil {
 		return err
 	}
 
@@ -67,16 +89,39 @@ func (b Backend) LinkSnap(info *snap.Info) error {
 		return err
 	}
 
+	if info.UsesPublisherData() {
+		if err := os.MkdirAll(info.PublisherDataDir(), 0755); err != nil {
+			return err
+		}
+	}
+
 	return updateCurrentSymlinks(info)
 }
 
-func generateWrappers(s *snap.Info) error {
+// InactiveServices returns the services of the snap that are not up.
+func (b Backend) InactiveServices(info *snap.Info) ([]string, error) {
+	return wrappers.InactiveSnapServices(info, &progress.NullProgress{})
+}
+
+func generateWrappers(s *snap.Info, startServices bool) error {
 	// add the CLI apps from the snap.yaml
 	if err := wrappers.AddSnapBinaries(s); err != nil {
 		return err
 	}
+	// set up the name resolution before the daemons need it
+	if err := wrappers.AddSnapDNS(s, &progress.NullProgress{}); err != nil {
+		return err
+	}
+	// and the mounts of its namespace, /tmp and /etc/resolv.conf
+	if err := wrappers.AddSnapMounts(s); err != nil {
+		return err
+	}
 	// add the daemons from the snap.yaml
-	if err := wrappers.AddSnapServices(s, &progress.NullProgress{}); err != nil {
+	addServices := wrappers.AddSnapServices
+	if !startServices {
+		addServices = wrappers.WriteSnapServices
+	}
+	if err := addServices(s, &progress.NullProgress{}); err != nil {
 		return err
 	}
 	// add the desktop files
@@ -87,13 +132,18 @@ func generateWrappers(s *snap.Info) error {
 	return nil
 }
 
-func removeGeneratedWrappers(s *snap.Info, meter progress.Meter) error {
+func removeGeneratedWrappers(s, next *snap.Info, meter progress.Meter) error {
 	err1 := wrappers.RemoveSnapBinaries(s)
 	if err1 != nil {
 		logger.Noticef("Cannot remove binaries for %q: %v", s.Name(), err1)
 	}
 
-	err2 := wrappers.RemoveSnapServices(s, meter)
+	var err2 error
+	if next != nil {
+		err2 = wrappers.RemoveSnapServicesForRefresh(s, next, meter)
+	} else {
+		err2 = wrappers.RemoveSnapServices(s, meter)
+	}
 	if err2 != nil {
 		logger.Noticef("Cannot remove services for %q: %v", s.Name(), err2)
 	}
@@ -103,13 +153,34 @@ func removeGeneratedWrappers(s *snap.Info, meter progress.Meter) error {
 		logger.Noticef("Cannot remove desktop files for %q: %v", s.Name(), err3)
 	}
 
-	return firstErr(err1, err2, err3)
+	err4 := wrappers.RemoveSnapDNS(s, meter)
+	if err4 != nil {
+		logger.Noticef("Cannot remove name resolution setup for %q: %v", s.Name(), err4)
+	}
+
+	err5 := wrappers.RemoveSnapMounts(s)
+	if err5 != nil {
+		logger.Noticef("Cannot remove mount profile for %q: %v", s.Name(), err5)
+	}
+
+	return firstErr(err1, err2, err3, err4, err5)
 }
 
 // UnlinkSnap makes the snap unavailable to the system removing wrappers and symlinks.
 func (b Backend) UnlinkSnap(info *snap.Info, meter progress.Meter) error {
+	return unlinkSnap(info, nil, meter)
+}
+
+// UnlinkSnapForRefresh makes the snap unavailable to the system like
+// UnlinkSnap, but leaves running the services that the next revision
+// of the snap lets endure the refresh.
+func (b Backend) UnlinkSnapForRefresh(info, next *snap.Info, meter progress.Meter) error {
+	return unlinkSnap(info, next, meter)
+}
+
+func unlinkSnap(info, next *snap.Info, meter progress.Meter) error {
 	// remove generated services, binaries etc
-	err1 := removeGeneratedWrappers(info, meter)
+	err1 := removeGeneratedWrappers(info, next, meter)
 
 	// and finally remove current symlinks
 	err2 := removeCurrentSymlinks(info)
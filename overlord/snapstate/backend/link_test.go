@@ -94,6 +94,61 @@ apps:
 	c.Assert(l, HasLen, 0)
 }
 
+func (s *linkSuite) TestLinkWithoutServicesThenStart(c *C) {
+	const yaml = `name: hello
+version: 1.0
+license-agreement: explicit
+
+apps:
+ svc:
+   command: svc
+   daemon: simple
+`
+	var sysdLog [][]string
+	systemd.SystemctlCmd = func(cmd ...string) ([]byte, error) {
+		sysdLog = append(sysdLog, cmd)
+		return []byte("ActiveState=inactive\n"), nil
+	}
+
+	info := snaptest.MockSnap(c, yaml, &snap.SideInfo{Revision: snap.R(11)})
+
+	err := s.be.LinkSnapWithoutServices(info)
+	c.Assert(err, IsNil)
+
+	l, err := filepath.Glob(filepath.Join(dirs.SnapServicesDir, "*.service"))
+	c.Assert(err, IsNil)
+	c.Assert(l, HasLen, 1)
+	// the unit is written but neither enabled nor started
+	c.Check(sysdLog, DeepEquals, [][]string{{"daemon-reload"}})
+
+	sysdLog = nil
+	err = s.be.StartSnapServices(info, &s.nullProgress)
+	c.Assert(err, IsNil)
+	c.Check(sysdLog, DeepEquals, [][]string{
+		{"daemon-reload"},
+		{"--root", dirs.GlobalRootDir, "enable", "snap.hello.svc.service"},
+		{"start", "snap.hello.svc.service"},
+	})
+}
+
+func (s *linkSuite) TestLinkCreatesPublisherDataDir(c *C) {
+	const yaml = `name: hello
+version: 1.0
+plugs:
+ publisher-data:
+`
+
+	info := snaptest.MockSnap(c, yaml, &snap.SideInfo{Revision: snap.R(11), Developer: "acme"})
+
+	err := s.be.LinkSnap(info)
+	c.Assert(err, IsNil)
+	c.Check(osutil.IsDirectory(filepath.Join(dirs.SnapPublisherDataDir, "acme")), Equals, true)
+
+	err = s.be.RemoveSnapPublisherData(info)
+	c.Assert(err, IsNil)
+	c.Check(osutil.FileExists(filepath.Join(dirs.SnapPublisherDataDir, "acme")), Equals, false)
+}
+
 func (s *linkSuite) TestLinkDoUndoCurrentSymlink(c *C) {
 	const yaml = `name: hello
 version: 1.0
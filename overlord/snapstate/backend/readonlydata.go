@@ -0,0 +1,50 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package backend
+
+import (
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/systemd"
+)
+
+// SealSnapData makes the system data of the given revision of the snap
+// read-only, bind mounting it read-only onto itself through a mount
+// unit so that it stays so across reboots.
+func (b Backend) SealSnapData(info *snap.Info, meter progress.Meter) error {
+	dataDir := dirs.StripRootDir(info.DataDir())
+
+	sysd := systemd.New(dirs.GlobalRootDir, meter)
+	mountUnitName, err := sysd.WriteReadOnlyBindMountUnitFile(info.Name(), dataDir)
+	if err != nil {
+		return err
+	}
+	if err := sysd.Enable(mountUnitName); err != nil {
+		return err
+	}
+	return sysd.Start(mountUnitName)
+}
+
+// UnsealSnapData makes the system data of the given revision of the
+// snap writable again, if it was sealed.
+func (b Backend) UnsealSnapData(info *snap.Info, meter progress.Meter) error {
+	return removeMountUnit(info.DataDir(), meter)
+}
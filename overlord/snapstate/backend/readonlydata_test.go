@@ -0,0 +1,94 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package backend_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/snapstate/backend"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/systemd"
+)
+
+type readOnlyDataSuite struct {
+	be           backend.Backend
+	nullProgress progress.NullProgress
+	prevctlCmd   func(...string) ([]byte, error)
+	systemctl    [][]string
+}
+
+var _ = Suite(&readOnlyDataSuite{})
+
+func (s *readOnlyDataSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+
+	err := os.MkdirAll(filepath.Join(dirs.GlobalRootDir, "etc", "systemd", "system", "multi-user.target.wants"), 0755)
+	c.Assert(err, IsNil)
+
+	s.systemctl = nil
+	s.prevctlCmd = systemd.SystemctlCmd
+	systemd.SystemctlCmd = func(cmd ...string) ([]byte, error) {
+		s.systemctl = append(s.systemctl, cmd)
+		return []byte("ActiveState=inactive\n"), nil
+	}
+}
+
+func (s *readOnlyDataSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("")
+	systemd.SystemctlCmd = s.prevctlCmd
+}
+
+func (s *readOnlyDataSuite) TestSealUnsealSnapData(c *C) {
+	info := &snap.Info{SideInfo: snap.SideInfo{OfficialName: "foo", Revision: snap.R(13)}}
+
+	err := s.be.SealSnapData(info, &s.nullProgress)
+	c.Assert(err, IsNil)
+
+	p := filepath.Join(dirs.SnapServicesDir, "var-snap-foo-13.mount")
+	mount, err := ioutil.ReadFile(p)
+	c.Assert(err, IsNil)
+	c.Check(string(mount), Matches, `(?s).*What=/var/snap/foo/13\nWhere=/var/snap/foo/13\nOptions=bind,ro\n.*`)
+	c.Check(s.systemctl, DeepEquals, [][]string{
+		{"--root", dirs.GlobalRootDir, "enable", "var-snap-foo-13.mount"},
+		{"start", "var-snap-foo-13.mount"},
+	})
+
+	err = s.be.UnsealSnapData(info, &s.nullProgress)
+	c.Assert(err, IsNil)
+	c.Check(osutil.FileExists(p), Equals, false)
+}
+
+func (s *readOnlyDataSuite) TestRemoveSnapDataUnseals(c *C) {
+	info := &snap.Info{SideInfo: snap.SideInfo{OfficialName: "foo", Revision: snap.R(13)}}
+	c.Assert(os.MkdirAll(info.DataDir(), 0755), IsNil)
+	c.Assert(s.be.SealSnapData(info, &s.nullProgress), IsNil)
+
+	err := s.be.RemoveSnapData(info)
+	c.Assert(err, IsNil)
+	c.Check(osutil.FileExists(filepath.Join(dirs.SnapServicesDir, "var-snap-foo-13.mount")), Equals, false)
+	c.Check(osutil.FileExists(info.DataDir()), Equals, false)
+}
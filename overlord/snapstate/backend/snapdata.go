@@ -26,11 +26,17 @@ import (
 	"os/exec"
 	"path/filepath"
 
+	"github.com/snapcore/snapd/progress"
 	"github.com/snapcore/snapd/snap"
 )
 
 // RemoveSnapData removes the data for the given version of the given snap.
 func (b Backend) RemoveSnapData(snap *snap.Info) error {
+	// the data can't be removed while it is sealed
+	if err := removeMountUnit(snap.DataDir(), &progress.NullProgress{}); err != nil {
+		return err
+	}
+
 	dirs, err := snapDataDirs(snap)
 	if err != nil {
 		return err
@@ -49,6 +55,12 @@ func (b Backend) RemoveSnapCommonData(snap *snap.Info) error {
 	return removeDirs(dirs)
 }
 
+// RemoveSnapPublisherData removes the data common to the snaps of the
+// publisher of the given snap.
+func (b Backend) RemoveSnapPublisherData(snap *snap.Info) error {
+	return removeDirs([]string{snap.PublisherDataDir()})
+}
+
 func removeDirs(dirs []string) error {
 	for _, dir := range dirs {
 		if err := os.RemoveAll(dir); err != nil {
@@ -23,6 +23,7 @@ import (
 	"errors"
 	"strings"
 
+	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
@@ -39,6 +40,8 @@ type fakeOp struct {
 	sinfo snap.SideInfo
 
 	old string
+
+	architecture string
 }
 
 type fakeDownload struct {
@@ -49,9 +52,28 @@ type fakeDownload struct {
 
 type fakeStore struct {
 	downloads           []fakeDownload
+	downloadErr         error
 	fakeBackend         *fakeSnappyBackend
 	fakeCurrentProgress int
 	fakeTotalProgress   int
+
+	refreshes  []*snap.Info
+	refreshErr error
+
+	rolloutPercentage float64
+
+	assertions       map[string]asserts.Assertion
+	assertionFetches [][]string
+}
+
+func (f *fakeStore) SnapForArchitecture(name, channel, architecture string, auther store.Authenticator) (*snap.Info, error) {
+	info, err := f.Snap(name, channel, auther)
+	if err != nil {
+		return nil, err
+	}
+	info.Architectures = []string{architecture}
+	f.fakeBackend.ops[len(f.fakeBackend.ops)-1].architecture = architecture
+	return info, nil
 }
 
 func (f *fakeStore) Snap(name, channel string, auther store.Authenticator) (*snap.Info, error) {
@@ -67,25 +89,52 @@ func (f *fakeStore) Snap(name, channel string, auther store.Authenticator) (*sna
 			SnapID:       "snapIDsnapidsnapidsnapidsnapidsn",
 			Revision:     revno,
 		},
-		Version: name,
+		Version:           name,
+		RolloutPercentage: f.rolloutPercentage,
 	}
 	f.fakeBackend.ops = append(f.fakeBackend.ops, fakeOp{op: "storesvc-snap", name: name, revno: revno})
 
 	return info, nil
 }
 
+func (f *fakeStore) SnapRevision(name string, revision snap.Revision, auther store.Authenticator) (*snap.Info, error) {
+	info := &snap.Info{
+		SideInfo: snap.SideInfo{
+			OfficialName: name,
+			SnapID:       "snapIDsnapidsnapidsnapidsnapidsn",
+			Revision:     revision,
+		},
+		Version: name,
+	}
+	f.fakeBackend.ops = append(f.fakeBackend.ops, fakeOp{op: "storesvc-snap-revision", name: name, revno: revision})
+
+	return info, nil
+}
+
 func (f *fakeStore) Find(query, channel string, auther store.Authenticator) ([]*snap.Info, error) {
 	panic("Find called")
 }
 
-func (f *fakeStore) ListRefresh([]*store.RefreshCandidate, store.Authenticator) ([]*snap.Info, error) {
-	panic("ListRefresh called")
+func (f *fakeStore) ListRefresh(cands []*store.RefreshCandidate, _ store.Authenticator) ([]*snap.Info, error) {
+	for _, cand := range cands {
+		f.fakeBackend.ops = append(f.fakeBackend.ops, fakeOp{op: "storesvc-list-refresh", name: cand.SnapID, revno: cand.Revision})
+	}
+	return f.refreshes, f.refreshErr
 }
 
 func (f *fakeStore) SuggestedCurrency() string {
 	return "XTS"
 }
 
+func (f *fakeStore) Assertion(assertType *asserts.AssertionType, primaryKey []string, auther store.Authenticator) (asserts.Assertion, error) {
+	f.assertionFetches = append(f.assertionFetches, primaryKey)
+	a, ok := f.assertions[assertType.Name+"/"+strings.Join(primaryKey, "/")]
+	if !ok {
+		return nil, store.ErrAssertionNotFound
+	}
+	return a, nil
+}
+
 func (f *fakeStore) Download(snapInfo *snap.Info, pb progress.Meter, auther store.Authenticator) (string, error) {
 	var macaroon string
 	if auther != nil {
@@ -97,6 +146,9 @@ func (f *fakeStore) Download(snapInfo *snap.Info, pb progress.Meter, auther stor
 		channel:  snapInfo.Channel,
 	})
 	f.fakeBackend.ops = append(f.fakeBackend.ops, fakeOp{op: "storesvc-download", name: snapInfo.Name()})
+	if f.downloadErr != nil {
+		return "", f.downloadErr
+	}
 
 	pb.SetTotal(float64(f.fakeTotalProgress))
 	pb.Set(float64(f.fakeCurrentProgress))
@@ -108,6 +160,9 @@ type fakeSnappyBackend struct {
 	ops []fakeOp
 
 	linkSnapFailTrigger string
+	linkSnapFailErr     error
+
+	inactiveServices map[string][]string
 }
 
 func (f *fakeSnappyBackend) OpenSnapFile(snapFilePath string, si *snap.SideInfo) (*snap.Info, snap.Container, error) {
@@ -147,6 +202,20 @@ func (f *fakeSnappyBackend) ReadInfo(name string, si *snap.SideInfo) (*snap.Info
 	if name == "core" {
 		info.Type = snap.TypeOS
 	}
+	if strings.HasPrefix(name, "shared-") {
+		info.Plugs = map[string]*snap.PlugInfo{
+			"publisher-data": {Snap: info, Name: "publisher-data", Interface: snap.PublisherDataInterface},
+		}
+	}
+	if strings.HasPrefix(name, "licensed-") {
+		info.LicenseAgreement = snap.LicenseAgreementExplicit
+		info.LicenseVersion = "1"
+	}
+	if strings.HasPrefix(name, "services-") {
+		info.Apps = map[string]*snap.AppInfo{
+			"svc": {Snap: info, Name: "svc", Daemon: "simple"},
+		}
+	}
 	return info, nil
 }
 
@@ -170,6 +239,9 @@ func (f *fakeSnappyBackend) LinkSnap(info *snap.Info) error {
 			op:   "link-snap.failed",
 			name: info.MountDir(),
 		})
+		if f.linkSnapFailErr != nil {
+			return f.linkSnapFailErr
+		}
 		return errors.New("fail")
 	}
 
@@ -180,6 +252,22 @@ func (f *fakeSnappyBackend) LinkSnap(info *snap.Info) error {
 	return nil
 }
 
+func (f *fakeSnappyBackend) LinkSnapWithoutServices(info *snap.Info) error {
+	f.ops = append(f.ops, fakeOp{
+		op:   "link-snap-without-services",
+		name: info.MountDir(),
+	})
+	return nil
+}
+
+func (f *fakeSnappyBackend) StartSnapServices(info *snap.Info, meter progress.Meter) error {
+	f.ops = append(f.ops, fakeOp{
+		op:   "start-snap-services",
+		name: info.MountDir(),
+	})
+	return nil
+}
+
 func (f *fakeSnappyBackend) UndoSetupSnap(s snap.PlaceInfo, p progress.Meter) error {
 	p.Notify("setup-snap")
 	f.ops = append(f.ops, fakeOp{
@@ -212,6 +300,15 @@ func (f *fakeSnappyBackend) UnlinkSnap(info *snap.Info, meter progress.Meter) er
 	return nil
 }
 
+func (f *fakeSnappyBackend) UnlinkSnapForRefresh(info, next *snap.Info, meter progress.Meter) error {
+	meter.Notify("unlink")
+	f.ops = append(f.ops, fakeOp{
+		op:   "unlink-snap",
+		name: info.MountDir(),
+	})
+	return nil
+}
+
 func (f *fakeSnappyBackend) RemoveSnapFiles(s snap.PlaceInfo, meter progress.Meter) error {
 	meter.Notify("remove-snap-files")
 	f.ops = append(f.ops, fakeOp{
@@ -229,6 +326,38 @@ func (f *fakeSnappyBackend) RemoveSnapData(info *snap.Info) error {
 	return nil
 }
 
+func (f *fakeSnappyBackend) SealSnapData(info *snap.Info, meter progress.Meter) error {
+	f.ops = append(f.ops, fakeOp{
+		op:   "seal-snap-data",
+		name: info.DataDir(),
+	})
+	return nil
+}
+
+func (f *fakeSnappyBackend) UnsealSnapData(info *snap.Info, meter progress.Meter) error {
+	f.ops = append(f.ops, fakeOp{
+		op:   "unseal-snap-data",
+		name: info.DataDir(),
+	})
+	return nil
+}
+
+func (f *fakeSnappyBackend) InactiveServices(info *snap.Info) ([]string, error) {
+	f.ops = append(f.ops, fakeOp{
+		op:   "inactive-services",
+		name: info.MountDir(),
+	})
+	return f.inactiveServices[info.Name()], nil
+}
+
+func (f *fakeSnappyBackend) RestartSnapServices(info *snap.Info, meter progress.Meter) error {
+	f.ops = append(f.ops, fakeOp{
+		op:   "restart-snap-services",
+		name: info.MountDir(),
+	})
+	return nil
+}
+
 func (f *fakeSnappyBackend) RemoveSnapCommonData(info *snap.Info) error {
 	f.ops = append(f.ops, fakeOp{
 		op:   "remove-snap-common-data",
@@ -237,6 +366,14 @@ func (f *fakeSnappyBackend) RemoveSnapCommonData(info *snap.Info) error {
 	return nil
 }
 
+func (f *fakeSnappyBackend) RemoveSnapPublisherData(info *snap.Info) error {
+	f.ops = append(f.ops, fakeOp{
+		op:   "remove-snap-publisher-data",
+		name: info.PublisherDataDir(),
+	})
+	return nil
+}
+
 func (f *fakeSnappyBackend) Candidate(sideInfo *snap.SideInfo) {
 	var sinfo snap.SideInfo
 	if sideInfo != nil {
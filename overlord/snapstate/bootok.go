@@ -0,0 +1,326 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/partition"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/snap"
+)
+
+var (
+	// bootOkTimeout is how long the system is given to become healthy
+	// after booting a new kernel or OS, before it is rebooted for the
+	// bootloader to attempt it again, or fall back.
+	bootOkTimeout = 10 * time.Minute
+	// bootOkRetryDelay is how often the services are checked on until
+	// they are all up.
+	bootOkRetryDelay = 30 * time.Second
+)
+
+// bootOkState is what is kept in the state about the handshake with
+// the bootloader for the current boot.
+type bootOkState struct {
+	BootID    string    `json:"boot-id"`
+	StartedAt time.Time `json:"started-at"`
+
+	RebootRequested bool `json:"reboot-requested,omitempty"`
+}
+
+// healthHookHandler handles the check-health hook, which fails when the
+// snap is not healthy; snapd has nothing to do around it.
+type healthHookHandler struct{}
+
+func (healthHookHandler) Before() error         { return nil }
+func (healthHookHandler) Done() error           { return nil }
+func (healthHookHandler) Error(err error) error { return nil }
+
+// NewHealthHookHandler returns the handler of the check-health hook of
+// the given context.
+func NewHealthHookHandler(*hookstate.Context) hookstate.Handler {
+	return healthHookHandler{}
+}
+
+// bootOk returns the tasks marking the boot successful once the system
+// is healthy: the check-health hooks of the snaps that have one pass,
+// and the services of the snaps are all up.
+func bootOk(st *state.State) (*state.TaskSet, error) {
+	infos, err := ActiveInfos(st)
+	if err != nil {
+		return nil, err
+	}
+
+	markOk := st.NewTask("mark-boot-ok", i18n.G("Mark boot successful once the services are up"))
+	markOk.Set("boot-id", bootID())
+	ts := state.NewTaskSet()
+	for _, info := range infos {
+		if info.Hooks["check-health"] == nil {
+			continue
+		}
+		hook := hookstate.HookTask(st, fmt.Sprintf(i18n.G("Check health of snap %q"), info.Name()), info.Name(), info.Revision, "check-health")
+		ts.AddTask(hook)
+		markOk.WaitFor(hook)
+	}
+	ts.AddTask(markOk)
+	return ts, nil
+}
+
+// ensureBootOk completes the handshake with the bootloader after
+// booting a new kernel or OS: the boot is marked successful once the
+// system is healthy, and the device is rebooted for the bootloader to
+// attempt it again otherwise. Once the bootloader gives up, falling
+// back to the previous kernel or OS, the refresh of the snap is
+// reverted.
+// Note that the state must be locked by the caller.
+func (m *SnapManager) ensureBootOk() error {
+	if release.OnClassic {
+		return nil
+	}
+	bootloader, err := partition.FindBootloader()
+	if err != nil {
+		// nothing to hand shake with
+		return nil
+	}
+	st := m.state
+
+	failed, err := partition.FailedTryBoot(bootloader)
+	if err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		return m.revertFailedBoot(failed)
+	}
+
+	tried, err := partition.TryBooted(bootloader)
+	if err != nil {
+		return err
+	}
+	if !tried {
+		return nil
+	}
+
+	var bs bootOkState
+	if err := st.Get("boot-ok", &bs); err != nil && err != state.ErrNoState {
+		return err
+	}
+	now := timeNow()
+	if bs.StartedAt.IsZero() || bs.BootID != bootID() {
+		ts, err := bootOk(st)
+		if err != nil {
+			return err
+		}
+		chg := st.NewChange("boot-ok", i18n.G("Mark boot successful"))
+		chg.AddAll(ts)
+		st.Set("boot-ok", bootOkState{BootID: bootID(), StartedAt: now})
+		st.EnsureBefore(0)
+		return nil
+	}
+
+	if bs.RebootRequested || now.Before(bs.StartedAt.Add(bootOkTimeout)) {
+		return nil
+	}
+	attempts, limit, err := partition.BootAttempts(bootloader)
+	if err != nil {
+		return err
+	}
+	logger.Noticef("System not healthy %v after boot attempt %d of %d, rebooting.", bootOkTimeout, attempts, limit)
+	bs.RebootRequested = true
+	st.Set("boot-ok", bs)
+	st.RequestRestart(state.RestartSystem)
+	return nil
+}
+
+func (m *SnapManager) doMarkBootOk(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var id string
+	if err := t.Get("boot-id", &id); err != nil {
+		return err
+	}
+	if id != bootID() {
+		return fmt.Errorf("cannot mark boot successful: the system rebooted since")
+	}
+
+	infos, err := ActiveInfos(st)
+	if err != nil {
+		return err
+	}
+	var inactive []string
+	for _, info := range infos {
+		units, err := m.backend.InactiveServices(info)
+		if err != nil {
+			return err
+		}
+		inactive = append(inactive, units...)
+	}
+	if len(inactive) > 0 {
+		t.Logf("Waiting for services to be up: %s", strings.Join(inactive, ", "))
+		st.EnsureBefore(bootOkRetryDelay)
+		return state.Retry
+	}
+
+	bootloader, err := partition.FindBootloader()
+	if err != nil {
+		return fmt.Errorf("cannot mark boot successful: %v", err)
+	}
+	if err := partition.MarkBootSuccessful(bootloader); err != nil {
+		return fmt.Errorf("cannot mark boot successful: %v", err)
+	}
+	logger.Noticef("Boot marked successful.")
+	return nil
+}
+
+// bootFileNameAndRevision splits the name of a snap file the bootloader
+// boots from, such as "core_42.snap", into the snap name and revision.
+func bootFileNameAndRevision(fn string) (string, snap.Revision, error) {
+	base := strings.TrimSuffix(fn, ".snap")
+	i := strings.LastIndex(base, "_")
+	if i <= 0 || base == fn {
+		return "", snap.Revision{}, fmt.Errorf("invalid snap file name %q", fn)
+	}
+	rev, err := snap.ParseRevision(base[i+1:])
+	if err != nil {
+		return "", snap.Revision{}, fmt.Errorf("invalid snap file name %q: %v", fn, err)
+	}
+	return base[:i], rev, nil
+}
+
+// revertFailedBoot reverts the kernel and OS snaps whose refresh the
+// bootloader gave up booting to the revisions they had before.
+// Note that the state must be locked by the caller.
+func (m *SnapManager) revertFailedBoot(failed []string) error {
+	st := m.state
+	for _, fn := range failed {
+		name, revision, err := bootFileNameAndRevision(fn)
+		if err != nil {
+			logger.Noticef("cannot revert failed boot: %v", err)
+			continue
+		}
+		var snapst SnapState
+		err = Get(st, name, &snapst)
+		if err == state.ErrNoState {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		cur := snapst.Current()
+		if cur == nil || cur.Revision != revision || len(snapst.Sequence) < 2 {
+			// reverted already, or nothing to revert to
+			continue
+		}
+		if err := checkChangeConflict(st, name); err != nil {
+			// done once the snap is no longer busy
+			continue
+		}
+
+		prev := snapst.Sequence[len(snapst.Sequence)-2]
+		ss := SnapSetup{
+			Name:     name,
+			Revision: prev.Revision,
+		}
+		if snapst.DevMode() {
+			ss.Flags |= SnapSetupFlags(DevMode)
+		}
+		summary := fmt.Sprintf(i18n.G("Revert snap %q to revision %s after failing to boot revision %s"), name, prev.Revision, revision)
+		revert := st.NewTask("revert-boot", summary)
+		revert.Set("snap-setup", ss)
+		revert.Set("failed-revision", revision)
+
+		setupSecurity := st.NewTask("setup-profiles", fmt.Sprintf(i18n.G("Setup snap %q security profiles"), name))
+		setupSecurity.Set("snap-setup-task", revert.ID())
+		setupSecurity.WaitFor(revert)
+
+		chg := st.NewChange("revert-boot", summary)
+		chg.AddAll(state.NewTaskSet(revert, setupSecurity))
+		logger.Noticef("%s", summary)
+		st.EnsureBefore(0)
+	}
+	return nil
+}
+
+func (m *SnapManager) doRevertBoot(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	ss, snapst, err := snapSetupAndState(t)
+	if err != nil {
+		return err
+	}
+	var failed snap.Revision
+	if err := t.Get("failed-revision", &failed); err != nil {
+		return err
+	}
+
+	n := len(snapst.Sequence)
+	cur := snapst.Current()
+	if cur == nil || cur.Revision != failed || n < 2 || snapst.Sequence[n-2].Revision != ss.Revision {
+		return fmt.Errorf("cannot revert snap %q to revision %s: revision %s is no longer current", ss.Name, ss.Revision, failed)
+	}
+	prev := snapst.Sequence[n-2]
+
+	failedInfo, err := readInfo(ss.Name, cur)
+	if err != nil {
+		return err
+	}
+	prevInfo, err := readInfo(ss.Name, prev)
+	if err != nil {
+		return err
+	}
+
+	licenseAccepted := snapst.LicenseAccepted(prevInfo)
+	pb := &TaskProgressAdapter{task: t}
+	st.Unlock() // pb itself will ask for locking
+	err = m.backend.UnlinkSnap(failedInfo, pb)
+	if err == nil {
+		err = m.linkSnap(prevInfo, licenseAccepted)
+	}
+	// the failed revision is of no use anymore
+	if err == nil {
+		err = m.backend.RemoveSnapData(failedInfo)
+	}
+	if err == nil {
+		err = m.backend.RemoveSnapFiles(failedInfo, pb)
+	}
+	st.Lock()
+	if err != nil {
+		return err
+	}
+
+	snapst.Sequence = snapst.Sequence[:n-1]
+	snapst.Active = true
+	Set(st, ss.Name, snapst)
+	// Make sure if state commits and snapst is mutated we won't be rerun
+	t.SetStatus(state.DoneStatus)
+	return nil
+}
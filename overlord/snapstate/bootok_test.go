@@ -0,0 +1,267 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/boot/boottest"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/partition"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/snap"
+)
+
+type bootOkSuite struct {
+	state        *state.State
+	stateBackend *witnessRestartReqStateBackend
+	snapmgr      *snapstate.SnapManager
+	fakeBackend  *fakeSnappyBackend
+	bootloader   *boottest.MockBootloader
+
+	now     time.Time
+	restore []func()
+}
+
+var _ = Suite(&bootOkSuite{})
+
+func (s *bootOkSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	s.stateBackend = &witnessRestartReqStateBackend{}
+	s.fakeBackend = &fakeSnappyBackend{}
+	s.state = state.New(s.stateBackend)
+
+	var err error
+	s.snapmgr, err = snapstate.Manager(s.state)
+	c.Assert(err, IsNil)
+	s.snapmgr.AddForeignTaskHandlers(s.fakeBackend)
+	snapstate.SetSnapManagerBackend(s.snapmgr, s.fakeBackend)
+
+	s.bootloader = boottest.NewMockBootloader("mock", c.MkDir())
+	partition.ForceBootloader(s.bootloader)
+
+	s.now = time.Date(2016, 8, 1, 12, 0, 0, 0, time.UTC)
+	s.restore = []func(){
+		snapstate.MockReadInfo(s.fakeBackend.ReadInfo),
+		snapstate.MockTimeNow(func() time.Time { return s.now }),
+		release.MockOnClassic(false),
+		func() { partition.ForceBootloader(nil) },
+	}
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	snapstate.Set(s.state, "core", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{OfficialName: "core", Revision: snap.R(1)},
+			{OfficialName: "core", Revision: snap.R(2)},
+		},
+	})
+}
+
+func (s *bootOkSuite) TearDownTest(c *C) {
+	for _, restore := range s.restore {
+		restore()
+	}
+	dirs.SetRootDir("")
+}
+
+func (s *bootOkSuite) settle() {
+	for i := 0; i < 50; i++ {
+		s.snapmgr.Ensure()
+		s.snapmgr.Wait()
+	}
+}
+
+// tryBoot sets the boot variables as the bootloader leaves them when
+// booting core revision 2 in try mode.
+func (s *bootOkSuite) tryBoot() {
+	s.bootloader.BootVars = map[string]string{
+		"snappy_mode":          "try",
+		"snappy_os":            "core_2.snap",
+		"snappy_try_os":        "core_2.snap",
+		"snappy_good_os":       "core_1.snap",
+		"snappy_kernel":        "krnl_1.snap",
+		"snappy_good_kernel":   "krnl_1.snap",
+		"snappy_boot_attempts": "1",
+		"snappy_boot_limit":    "3",
+	}
+}
+
+func (s *bootOkSuite) TestBootOkMarksBootSuccessful(c *C) {
+	s.tryBoot()
+
+	s.settle()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Assert(s.state.Changes(), HasLen, 1)
+	chg := s.state.Changes()[0]
+	c.Check(chg.Kind(), Equals, "boot-ok")
+	c.Check(chg.Status(), Equals, state.DoneStatus)
+	c.Check(s.bootloader.BootVars["snappy_mode"], Equals, "regular")
+	c.Check(s.bootloader.BootVars["snappy_good_os"], Equals, "core_2.snap")
+	c.Check(s.bootloader.BootVars["snappy_boot_attempts"], Equals, "0")
+	c.Check(s.fakeBackend.ops, DeepEquals, []fakeOp{
+		{op: "inactive-services", name: filepath.Join(dirs.SnapSnapsDir, "core/2")},
+	})
+	c.Check(s.stateBackend.restartRequested, HasLen, 0)
+}
+
+func (s *bootOkSuite) TestBootOkNothingToDo(c *C) {
+	s.bootloader.BootVars = map[string]string{
+		"snappy_mode":    "regular",
+		"snappy_os":      "core_2.snap",
+		"snappy_good_os": "core_2.snap",
+	}
+	s.settle()
+
+	// nor before rebooting into what is to be tried
+	s.tryBoot()
+	s.bootloader.BootVars["snappy_boot_attempts"] = "0"
+	s.settle()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(s.state.Changes(), HasLen, 0)
+}
+
+func (s *bootOkSuite) TestBootOkWaitsForServices(c *C) {
+	s.tryBoot()
+	s.fakeBackend.inactiveServices = map[string][]string{
+		"core": {"snap.core.svc.service"},
+	}
+
+	s.settle()
+
+	s.state.Lock()
+	c.Assert(s.state.Changes(), HasLen, 1)
+	chg := s.state.Changes()[0]
+	c.Check(chg.Status(), Equals, state.DoingStatus)
+	c.Check(chg.Tasks()[0].Log(), Not(HasLen), 0)
+	c.Check(s.bootloader.BootVars["snappy_mode"], Equals, "try")
+	s.state.Unlock()
+
+	s.fakeBackend.inactiveServices = nil
+	s.settle()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(chg.Status(), Equals, state.DoneStatus)
+	c.Check(s.bootloader.BootVars["snappy_mode"], Equals, "regular")
+	c.Check(s.state.Changes(), HasLen, 1)
+}
+
+func (s *bootOkSuite) TestBootOkRebootsWhenNotHealthy(c *C) {
+	s.tryBoot()
+	s.fakeBackend.inactiveServices = map[string][]string{
+		"core": {"snap.core.svc.service"},
+	}
+
+	s.settle()
+	c.Check(s.stateBackend.restartRequested, HasLen, 0)
+
+	s.now = s.now.Add(11 * time.Minute)
+	s.settle()
+	// only once
+	c.Check(s.stateBackend.restartRequested, DeepEquals, []state.RestartType{state.RestartSystem})
+	c.Check(s.bootloader.BootVars["snappy_mode"], Equals, "try")
+}
+
+func (s *bootOkSuite) TestBootOkRunsHealthHooks(c *C) {
+	s.restore = append(s.restore, snapstate.MockReadInfo(func(name string, si *snap.SideInfo) (*snap.Info, error) {
+		info, err := s.fakeBackend.ReadInfo(name, si)
+		info.Hooks = map[string]*snap.HookInfo{"check-health": {Snap: info, Name: "check-health"}}
+		return info, err
+	}))
+	s.tryBoot()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Assert(s.snapmgr.EnsureBootOk(), IsNil)
+	c.Assert(s.state.Changes(), HasLen, 1)
+	tasks := s.state.Changes()[0].Tasks()
+	c.Assert(tasks, HasLen, 2)
+	c.Check(tasks[0].Kind(), Equals, "run-hook")
+	c.Check(tasks[0].Summary(), Equals, `Check health of snap "core"`)
+	c.Check(tasks[1].Kind(), Equals, "mark-boot-ok")
+	c.Check(tasks[1].WaitTasks(), DeepEquals, []*state.Task{tasks[0]})
+}
+
+func (s *bootOkSuite) TestBootOkRevertsFailedBoot(c *C) {
+	// the bootloader gave up on core revision 2, as the documented
+	// scripts do
+	s.tryBoot()
+	s.bootloader.BootVars["snappy_boot_attempts"] = "3"
+	s.bootloader.BootVars["snappy_mode"] = "regular"
+	s.bootloader.BootVars["snappy_os"] = s.bootloader.BootVars["snappy_good_os"]
+	s.bootloader.BootVars["snappy_kernel"] = s.bootloader.BootVars["snappy_good_kernel"]
+
+	s.settle()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Assert(s.state.Changes(), HasLen, 1)
+	chg := s.state.Changes()[0]
+	c.Check(chg.Kind(), Equals, "revert-boot")
+	c.Check(chg.Summary(), Equals, `Revert snap "core" to revision 1 after failing to boot revision 2`)
+	c.Check(chg.Status(), Equals, state.DoneStatus)
+	c.Check(s.fakeBackend.ops, DeepEquals, []fakeOp{
+		{op: "unlink-snap", name: filepath.Join(dirs.SnapSnapsDir, "core/2")},
+		{op: "link-snap", name: filepath.Join(dirs.SnapSnapsDir, "core/1")},
+		{op: "remove-snap-data", name: filepath.Join(dirs.SnapSnapsDir, "core/2")},
+		{op: "remove-snap-files", name: filepath.Join(dirs.SnapSnapsDir, "core/2")},
+		{op: "setup-profiles:Doing", name: "core", revno: snap.R(1)},
+	})
+
+	// the failed revision is gone rather than kept as the previous one
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "core", &snapst), IsNil)
+	c.Check(snapst.Current().Revision, Equals, snap.R(1))
+	c.Check(snapst.Sequence, DeepEquals, []*snap.SideInfo{{OfficialName: "core", Revision: snap.R(1)}})
+
+	// and it is not reverted again
+	s.state.Unlock()
+	s.settle()
+	s.state.Lock()
+	c.Check(s.state.Changes(), HasLen, 1)
+}
+
+func (s *bootOkSuite) TestBootFileNameAndRevision(c *C) {
+	name, rev, err := snapstate.BootFileNameAndRevision("core_42.snap")
+	c.Assert(err, IsNil)
+	c.Check(name, Equals, "core")
+	c.Check(rev, Equals, snap.R(42))
+
+	name, rev, err = snapstate.BootFileNameAndRevision("pc-kernel_x1.snap")
+	c.Assert(err, IsNil)
+	c.Check(name, Equals, "pc-kernel")
+	c.Check(rev, Equals, snap.R(-1))
+
+	for _, fn := range []string{"core", "core_42", "_42.snap", "core_x.snap"} {
+		_, _, err := snapstate.BootFileNameAndRevision(fn)
+		c.Check(err, ErrorMatches, `invalid snap file name .*`, Commentf(fn))
+	}
+}
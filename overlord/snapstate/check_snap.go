@@ -52,6 +52,15 @@ func checkAssumes(s *snap.Info) error {
 
 var openSnapFile = backend.OpenSnapFile
 
+// checkArchitectures ensures that a snap supporting the given
+// architectures can run on the system, natively or not.
+func checkArchitectures(name string, architectures []string) error {
+	if !arch.IsCompatibleArchitecture(architectures) {
+		return fmt.Errorf("snap %q supported architectures (%s) are incompatible with this system (%s)", name, strings.Join(architectures, ", "), strings.Join(arch.CompatibleArchitectures(), ", "))
+	}
+	return nil
+}
+
 // checkSnap ensures that the snap can be installed.
 func checkSnap(state *state.State, snapFilePath string, curInfo *snap.Info, flags Flags) error {
 	// XXX: actually verify snap before using content from it unless dev-mode
@@ -62,8 +71,8 @@ func checkSnap(state *state.State, snapFilePath string, curInfo *snap.Info, flag
 	}
 
 	// verify we have a valid architecture
-	if !arch.IsSupportedArchitecture(s.Architectures) {
-		return fmt.Errorf("snap %q supported architectures (%s) are incompatible with this system (%s)", s.Name(), strings.Join(s.Architectures, ", "), arch.UbuntuArchitecture())
+	if err := checkArchitectures(s.Name(), s.Architectures); err != nil {
+		return err
 	}
 
 	// check assumes
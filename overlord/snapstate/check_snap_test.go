@@ -21,6 +21,7 @@ package snapstate_test
 
 import (
 	"fmt"
+	"strings"
 
 	. "gopkg.in/check.v1"
 
@@ -66,7 +67,7 @@ architectures:
 
 	err = snapstate.CheckSnap(nil, "snap-path", nil, 0)
 
-	errorMsg := fmt.Sprintf(`snap "hello" supported architectures (yadayada, blahblah) are incompatible with this system (%s)`, arch.UbuntuArchitecture())
+	errorMsg := fmt.Sprintf(`snap "hello" supported architectures (yadayada, blahblah) are incompatible with this system (%s)`, strings.Join(arch.CompatibleArchitectures(), ", "))
 	c.Assert(err.Error(), Equals, errorMsg)
 }
 
@@ -0,0 +1,257 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+// configSourcesKey is where the options last applied from the
+// configuration files of each snap are kept in the state, marshalled,
+// by snap name and dotted key.
+const configSourcesKey = "config-sources"
+
+// configSourceDirs returns the directories holding the configuration
+// files of snaps, named <snap>.yaml: the meta/config of the gadget, if
+// there is one, and then the one of the device, whose options win.
+// Note that the state must be locked by the caller.
+func configSourceDirs(st *state.State) ([]string, error) {
+	snapStates, err := All(st)
+	if err != nil {
+		return nil, err
+	}
+	var sourceDirs []string
+	for name, snapst := range snapStates {
+		if !snapst.Active || snapst.Current() == nil {
+			continue
+		}
+		info, err := readInfo(name, snapst.Current())
+		if err != nil || info.Type != snap.TypeGadget {
+			continue
+		}
+		sourceDirs = append(sourceDirs, filepath.Join(info.MountDir(), "meta", "config"))
+		break
+	}
+	return append(sourceDirs, dirs.SnapConfigSourcesDir), nil
+}
+
+// configSourceNames returns the names of the snaps with configuration
+// files in the given directories.
+func configSourceNames(sourceDirs []string) map[string]bool {
+	names := make(map[string]bool)
+	for _, dir := range sourceDirs {
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				logger.Noticef("cannot read configuration files: %v", err)
+			}
+			continue
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if !entry.Mode().IsRegular() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".yaml") {
+				continue
+			}
+			names[strings.TrimSuffix(name, ".yaml")] = true
+		}
+	}
+	return names
+}
+
+// readConfigSources returns the options the configuration files of the
+// snap give, marshalled, by dotted key.
+func readConfigSources(sourceDirs []string, snapName string) (map[string]*json.RawMessage, error) {
+	options := make(map[string]*json.RawMessage)
+	for _, dir := range sourceDirs {
+		path := filepath.Join(dir, snapName+".yaml")
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			continue
+		}
+		fileOptions, err := snap.ReadConfigFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range fileOptions {
+			data, err := json.Marshal(value)
+			if err != nil {
+				return nil, fmt.Errorf("cannot use option %q of configuration file %q: %v", key, path, err)
+			}
+			raw := json.RawMessage(data)
+			options[key] = &raw
+		}
+	}
+	return options, nil
+}
+
+// configSourcesPatch returns the changes to make for the options the
+// configuration files give now, after those applied before: options
+// no longer given are unset.
+func configSourcesPatch(applied, options map[string]*json.RawMessage) (map[string]interface{}, error) {
+	patch := make(map[string]interface{})
+	for key, raw := range options {
+		if old, ok := applied[key]; ok && string(*old) == string(*raw) {
+			continue
+		}
+		var value interface{}
+		if err := json.Unmarshal(*raw, &value); err != nil {
+			return nil, err
+		}
+		patch[key] = value
+	}
+	for key := range applied {
+		if _, ok := options[key]; !ok {
+			patch[key] = nil
+		}
+	}
+	return patch, nil
+}
+
+// ensureConfigSources applies the configuration files of the snaps,
+// from the gadget and from the device, whenever they change, so that
+// the configuration of a device can be kept in files. Snaps with a
+// configure hook are configured by a change running it. Failures are
+// logged, and the files are tried again once they change. snapd is
+// started again by systemd for the files of the device to be checked
+// when they change after it exited idle.
+// Note that the state must be locked by the caller.
+func (m *SnapManager) ensureConfigSources() error {
+	st := m.state
+	var applied map[string]map[string]*json.RawMessage
+	if err := st.Get(configSourcesKey, &applied); err != nil && err != state.ErrNoState {
+		return err
+	}
+	if applied == nil {
+		applied = make(map[string]map[string]*json.RawMessage)
+	}
+
+	sourceDirs, err := configSourceDirs(st)
+	if err != nil {
+		return err
+	}
+	found := configSourceNames(sourceDirs)
+	names := make([]string, 0, len(found)+len(applied))
+	for name := range found {
+		names = append(names, name)
+	}
+	for name := range applied {
+		if !found[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	changed := false
+	for _, name := range names {
+		options, err := readConfigSources(sourceDirs, name)
+		if err != nil {
+			logger.Noticef("cannot use configuration files of snap %q: %v", name, err)
+			continue
+		}
+		patch, err := configSourcesPatch(applied[name], options)
+		if err != nil {
+			return err
+		}
+		if len(patch) == 0 {
+			continue
+		}
+
+		var info *snap.Info
+		if name != configstate.CoreSnapName {
+			var snapst SnapState
+			if err := Get(st, name, &snapst); err != nil && err != state.ErrNoState {
+				return err
+			}
+			if !snapst.Active || snapst.Current() == nil {
+				// applied once the snap is installed
+				continue
+			}
+			if info, err = readInfo(name, snapst.Current()); err != nil {
+				logger.Noticef("cannot use configuration files of snap %q: %v", name, err)
+				continue
+			}
+		}
+
+		if err := applyConfigSources(st, name, info, patch); err != nil {
+			logger.Noticef("cannot use configuration files of snap %q: %v", name, err)
+		}
+		if len(options) == 0 {
+			delete(applied, name)
+		} else {
+			applied[name] = options
+		}
+		changed = true
+	}
+
+	if changed {
+		st.Set(configSourcesKey, applied)
+	}
+	return nil
+}
+
+// applyConfigSources makes the changes to the configuration of the
+// snap, checking them against its configuration schema, if it has one.
+func applyConfigSources(st *state.State, snapName string, info *snap.Info, patch map[string]interface{}) error {
+	keys := make([]string, 0, len(patch))
+	for key := range patch {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	if info != nil {
+		schema, err := snap.ReadConfigSchema(info.MountDir())
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if err := configstate.ValidateSchema(schema, key, patch[key]); err != nil {
+				return err
+			}
+		}
+		if info.Hooks[configstate.ConfigureHook] != nil {
+			task := configstate.Configure(st, snapName, info.Revision, patch)
+			chg := st.NewChange("configure-snap", fmt.Sprintf(i18n.G("Change configuration of %q snap from its configuration files"), snapName))
+			chg.AddTask(task)
+			logger.Noticef("%s", chg.Summary())
+			st.EnsureBefore(0)
+			return nil
+		}
+	}
+
+	tr := configstate.NewTransaction(st, snapName)
+	for _, key := range keys {
+		if err := tr.Set(key, patch[key]); err != nil {
+			return err
+		}
+	}
+	return tr.Commit()
+}
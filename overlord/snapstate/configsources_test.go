@@ -0,0 +1,193 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/configstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+func writeConfigSource(c *C, dir, snapName, content string) {
+	c.Assert(os.MkdirAll(dir, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, snapName+".yaml"), []byte(content), 0644), IsNil)
+}
+
+func (s *snapmgrTestSuite) installedForConfig(snapName string) {
+	snapstate.Set(s.state, snapName, &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{OfficialName: snapName, Revision: snap.R(7)}},
+	})
+}
+
+func (s *snapmgrTestSuite) TestConfigSources(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("")
+
+	writeConfigSource(c, dirs.SnapConfigSourcesDir, "foo", "server:\n  port: 8080\nname: frank\n")
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.installedForConfig("foo")
+
+	s.state.Unlock()
+	s.settle()
+	s.state.Lock()
+
+	config, err := configstate.Config(s.state, "foo")
+	c.Assert(err, IsNil)
+	c.Check(config, DeepEquals, map[string]interface{}{
+		"server": map[string]interface{}{"port": json.Number("8080")},
+		"name":   "frank",
+	})
+
+	// options changed by hand are left alone while the file is not
+	c.Assert(configstate.Set(s.state, "foo", "server.port", 1), IsNil)
+	s.state.Unlock()
+	s.settle()
+	s.state.Lock()
+	var port int
+	c.Assert(configstate.Get(s.state, "foo", "server.port", &port), IsNil)
+	c.Check(port, Equals, 1)
+
+	// options dropped from the file are unset
+	writeConfigSource(c, dirs.SnapConfigSourcesDir, "foo", "server:\n  port: 9090\n")
+	s.state.Unlock()
+	s.settle()
+	s.state.Lock()
+	config, err = configstate.Config(s.state, "foo")
+	c.Assert(err, IsNil)
+	c.Check(config, DeepEquals, map[string]interface{}{
+		"server": map[string]interface{}{"port": json.Number("9090")},
+	})
+
+	// and all of them once the file is gone
+	c.Assert(os.Remove(filepath.Join(dirs.SnapConfigSourcesDir, "foo.yaml")), IsNil)
+	s.state.Unlock()
+	s.settle()
+	s.state.Lock()
+	config, err = configstate.Config(s.state, "foo")
+	c.Assert(err, IsNil)
+	c.Check(config, DeepEquals, map[string]interface{}{
+		"server": map[string]interface{}{},
+	})
+	var applied map[string]interface{}
+	c.Assert(s.state.Get("config-sources", &applied), IsNil)
+	c.Check(applied, HasLen, 0)
+}
+
+func (s *snapmgrTestSuite) TestConfigSourcesGadget(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("")
+
+	gadgetConfigDir := filepath.Join(dirs.SnapSnapsDir, "gadget", "7", "meta", "config")
+	writeConfigSource(c, gadgetConfigDir, "foo", "name: frank\nport: 8080\n")
+	writeConfigSource(c, dirs.SnapConfigSourcesDir, "foo", "port: 9090\n")
+	// the snap is not installed yet
+	writeConfigSource(c, gadgetConfigDir, "bar", "name: joe\n")
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.installedForConfig("gadget")
+	s.installedForConfig("foo")
+
+	s.state.Unlock()
+	s.settle()
+	s.state.Lock()
+
+	config, err := configstate.Config(s.state, "foo")
+	c.Assert(err, IsNil)
+	c.Check(config, DeepEquals, map[string]interface{}{
+		"name": "frank",
+		"port": json.Number("9090"),
+	})
+	config, err = configstate.Config(s.state, "bar")
+	c.Assert(err, IsNil)
+	c.Check(config, HasLen, 0)
+
+	s.installedForConfig("bar")
+	s.state.Unlock()
+	s.settle()
+	s.state.Lock()
+	config, err = configstate.Config(s.state, "bar")
+	c.Assert(err, IsNil)
+	c.Check(config, DeepEquals, map[string]interface{}{"name": "joe"})
+}
+
+func (s *snapmgrTestSuite) TestConfigSourcesInvalid(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("")
+
+	// all of the file applies or none of it
+	writeConfigSource(c, dirs.SnapConfigSourcesDir, "foo", "name: frank\nsystem:\n  bogus: 1\n")
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.installedForConfig("foo")
+
+	s.state.Unlock()
+	s.settle()
+	s.state.Lock()
+
+	config, err := configstate.Config(s.state, "foo")
+	c.Assert(err, IsNil)
+	c.Check(config, HasLen, 0)
+}
+
+func (s *snapmgrTestSuite) TestConfigSourcesConfigureHook(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("")
+	restore := snapstate.MockReadInfo(func(name string, si *snap.SideInfo) (*snap.Info, error) {
+		info, err := s.fakeBackend.ReadInfo(name, si)
+		if err == nil && name == "foo" {
+			info.Hooks = map[string]*snap.HookInfo{"configure": {Snap: info, Name: "configure"}}
+		}
+		return info, err
+	})
+	defer restore()
+
+	writeConfigSource(c, dirs.SnapConfigSourcesDir, "foo", "name: frank\n")
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.installedForConfig("foo")
+
+	s.state.Unlock()
+	s.settle()
+	s.state.Lock()
+
+	c.Assert(s.state.Changes(), HasLen, 1)
+	chg := s.state.Changes()[0]
+	c.Check(chg.Kind(), Equals, "configure-snap")
+	c.Check(chg.Summary(), Equals, `Change configuration of "foo" snap from its configuration files`)
+	tasks := chg.Tasks()
+	c.Assert(tasks, HasLen, 1)
+	c.Check(tasks[0].Kind(), Equals, "run-hook")
+	c.Check(tasks[0].Status(), Equals, state.DoStatus)
+}
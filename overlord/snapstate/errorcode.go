@@ -0,0 +1,178 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"strings"
+
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/store"
+)
+
+// ErrorCode is a stable classification of the cause of a failed change,
+// meant for aggregating failures across many devices.
+type ErrorCode string
+
+// The error codes a failed change can be classified with.
+const (
+	ErrorCodeNetwork       ErrorCode = "network"
+	ErrorCodeSpace         ErrorCode = "space"
+	ErrorCodeAssertion     ErrorCode = "assertion"
+	ErrorCodePolicyCompile ErrorCode = "policy-compile"
+	ErrorCodeHookFailed    ErrorCode = "hook-failed"
+	ErrorCodeServiceStart  ErrorCode = "service-start"
+	ErrorCodeScanRejected  ErrorCode = "scan-rejected"
+	ErrorCodeStoreAuth     ErrorCode = "store-auth"
+	ErrorCodeUnknown       ErrorCode = "unknown"
+)
+
+// recordStoreError records in the task the kind of the error the store
+// returned, for the failure to be classified by it, and returns the
+// error. The state must not be locked by the caller.
+func recordStoreError(t *state.Task, err error) error {
+	st := t.State()
+	st.Lock()
+	t.Set("store-error", store.Kind(err))
+	st.Unlock()
+	return err
+}
+
+// networkErrors are how network errors are told apart in the failures
+// of tasks from before the kind of store errors was recorded.
+var networkErrors = []string{
+	"dial tcp",
+	"no such host",
+	"connection refused",
+	"connection reset",
+	"network is unreachable",
+	"i/o timeout",
+	"TLS handshake",
+	"unexpected EOF",
+}
+
+func containsAny(s string, subs []string) bool {
+	for _, sub := range subs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// classifyTaskError returns the error code for the given error logged
+// by a task of the given kind.
+func classifyTaskError(kind, msg string) ErrorCode {
+	switch {
+	case strings.Contains(msg, "was rejected by the scanner"):
+		// whatever the reason given by the scanner
+		return ErrorCodeScanRejected
+	case strings.Contains(msg, "no space left on device"):
+		return ErrorCodeSpace
+	case strings.Contains(msg, "assertion"):
+		return ErrorCodeAssertion
+	}
+
+	switch kind {
+	case "download-snap":
+		return ErrorCodeNetwork
+	case "setup-profiles", "remove-profiles":
+		return ErrorCodePolicyCompile
+	case "run-hook":
+		return ErrorCodeHookFailed
+	case "link-snap":
+		// systemctl and service timeout errors
+		if strings.Contains(msg, "failed with exit status") || strings.HasSuffix(msg, ": timeout") {
+			return ErrorCodeServiceStart
+		}
+	}
+
+	if containsAny(msg, networkErrors) {
+		return ErrorCodeNetwork
+	}
+	return ErrorCodeUnknown
+}
+
+// classifyTask returns the error code for the failure of the task,
+// going by the kind of store error it recorded if any.
+func classifyTask(t *state.Task) ErrorCode {
+	var kind store.ErrorKind
+	t.Get("store-error", &kind)
+	switch kind {
+	case store.ErrorRetryable, store.ErrorRateLimited:
+		return ErrorCodeNetwork
+	case store.ErrorAuthExpired:
+		return ErrorCodeStoreAuth
+	}
+	return classifyTaskError(t.Kind(), taskFailure(t))
+}
+
+// taskFailure returns the message of the error that made the task fail,
+// which is the last one logged.
+func taskFailure(t *state.Task) string {
+	prefix := " " + state.LogError + " "
+	log := t.Log()
+	for i := len(log) - 1; i >= 0; i-- {
+		j := strings.Index(log[i], " ")
+		if j >= 0 && strings.HasPrefix(log[i][j:], prefix) {
+			return log[i][j+len(prefix):]
+		}
+	}
+	return ""
+}
+
+// ClassifyChange returns the error code of the failed change, going by
+// its first failed task, or "" if the change has not failed.
+func ClassifyChange(chg *state.Change) ErrorCode {
+	if chg.Status() != state.ErrorStatus {
+		return ""
+	}
+	for _, t := range chg.Tasks() {
+		if t.Status() == state.ErrorStatus {
+			return classifyTask(t)
+		}
+	}
+	return ErrorCodeUnknown
+}
+
+// ChangeErrorCode returns the error code recorded for the change, or ""
+// if there is none.
+func ChangeErrorCode(chg *state.Change) ErrorCode {
+	var code ErrorCode
+	if err := chg.Get("error-code", &code); err != nil {
+		return ""
+	}
+	return code
+}
+
+// recordErrorCodes classifies the failed changes that were not
+// classified yet, persisting the result in each change.
+func recordErrorCodes(st *state.State) {
+	st.Lock()
+	defer st.Unlock()
+
+	for _, chg := range st.Changes() {
+		if !chg.Status().Ready() || ChangeErrorCode(chg) != "" {
+			continue
+		}
+		if code := ClassifyChange(chg); code != "" {
+			chg.Set("error-code", code)
+		}
+	}
+}
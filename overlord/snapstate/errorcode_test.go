@@ -0,0 +1,159 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"net/url"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/store"
+)
+
+type errorCodeSuite struct {
+	state *state.State
+}
+
+var _ = Suite(&errorCodeSuite{})
+
+func (s *errorCodeSuite) SetUpTest(c *C) {
+	s.state = state.New(nil)
+}
+
+func (s *errorCodeSuite) failedChange(kind, msg string) *state.Change {
+	chg := s.state.NewChange("install-snap", "...")
+	ok := s.state.NewTask("prepare-snap", "...")
+	ok.SetStatus(state.UndoneStatus)
+	chg.AddTask(ok)
+	t := s.state.NewTask(kind, "...")
+	t.Errorf("some earlier problem")
+	t.Errorf("%s", msg)
+	t.SetStatus(state.ErrorStatus)
+	chg.AddTask(t)
+	return chg
+}
+
+func (s *errorCodeSuite) TestClassifyChange(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	for _, t := range []struct {
+		kind string
+		msg  string
+		code snapstate.ErrorCode
+	}{
+		{"download-snap", `Get https://example.com/foo.snap: dial tcp: lookup example.com: no such host`, snapstate.ErrorCodeNetwork},
+		{"download-snap", `write /var/lib/snapd/snaps/foo.snap: no space left on device`, snapstate.ErrorCodeSpace},
+		{"mount-snap", `cannot copy: no space left on device`, snapstate.ErrorCodeSpace},
+		{"mount-snap", `cannot find assertion for snap "foo"`, snapstate.ErrorCodeAssertion},
+		{"setup-profiles", `cannot load apparmor profile "snap.foo.foo": exit status 1`, snapstate.ErrorCodePolicyCompile},
+		{"mount-snap", `snap "foo" was rejected by the scanner: unsigned assertion`, snapstate.ErrorCodeScanRejected},
+		{"run-hook", `hook "configure" failed`, snapstate.ErrorCodeHookFailed},
+		{"link-snap", `[start snap.foo.svc.service] failed with exit status 1: Job failed`, snapstate.ErrorCodeServiceStart},
+		{"link-snap", `snap.foo.svc.service failed to start: timeout`, snapstate.ErrorCodeServiceStart},
+		{"link-snap", `cannot read info for "foo"`, snapstate.ErrorCodeUnknown},
+		{"prepare-snap", `Post https://example.com: read tcp: i/o timeout`, snapstate.ErrorCodeNetwork},
+	} {
+		chg := s.failedChange(t.kind, t.msg)
+		c.Check(snapstate.ClassifyChange(chg), Equals, t.code, Commentf("%s: %s", t.kind, t.msg))
+	}
+}
+
+func (s *errorCodeSuite) TestClassifyChangeStoreErrorKind(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	for _, t := range []struct {
+		kind store.ErrorKind
+		code snapstate.ErrorCode
+	}{
+		{store.ErrorRetryable, snapstate.ErrorCodeNetwork},
+		{store.ErrorRateLimited, snapstate.ErrorCodeNetwork},
+		{store.ErrorAuthExpired, snapstate.ErrorCodeStoreAuth},
+		// going by the message then
+		{store.ErrorPermanent, snapstate.ErrorCodeUnknown},
+	} {
+		chg := s.failedChange("mount-snap", "store said no")
+		chg.Tasks()[1].Set("store-error", t.kind)
+		c.Check(snapstate.ClassifyChange(chg), Equals, t.code, Commentf("%s", t.kind))
+	}
+}
+
+func (s *errorCodeSuite) TestClassifyChangeNotFailed(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	chg := s.state.NewChange("install-snap", "...")
+	t := s.state.NewTask("download-snap", "...")
+	t.SetStatus(state.DoneStatus)
+	chg.AddTask(t)
+	c.Check(snapstate.ClassifyChange(chg), Equals, snapstate.ErrorCode(""))
+	c.Check(snapstate.ChangeErrorCode(chg), Equals, snapstate.ErrorCode(""))
+}
+
+func (s *snapmgrTestSuite) TestEnsureRecordsErrorCodes(c *C) {
+	s.state.Lock()
+	chg := s.state.NewChange("install-snap", "...")
+	t := s.state.NewTask("download-snap", "...")
+	t.Errorf("dial tcp: connection refused")
+	t.SetStatus(state.ErrorStatus)
+	chg.AddTask(t)
+	s.state.Unlock()
+
+	s.snapmgr.Ensure()
+	s.snapmgr.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(snapstate.ChangeErrorCode(chg), Equals, snapstate.ErrorCodeNetwork)
+
+	// once recorded, it sticks
+	t.Errorf("no space left on device")
+	s.state.Unlock()
+	s.snapmgr.Ensure()
+	s.state.Lock()
+	c.Check(snapstate.ChangeErrorCode(chg), Equals, snapstate.ErrorCodeNetwork)
+}
+
+func (s *snapmgrTestSuite) TestDownloadRecordsStoreErrorKind(c *C) {
+	u, _ := url.Parse("https://example.com/some-snap.snap")
+	s.fakeStore.downloadErr = &store.ErrDownload{Code: 401, URL: u}
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	chg := s.state.NewChange("install", "install a snap")
+	ts, err := snapstate.Install(s.state, "some-snap", "some-channel", s.user.ID, 0)
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle()
+	s.state.Lock()
+
+	c.Assert(chg.Status(), Equals, state.ErrorStatus)
+	var kind store.ErrorKind
+	c.Assert(ts.Tasks()[0].Get("store-error", &kind), IsNil)
+	c.Check(kind, Equals, store.ErrorAuthExpired)
+	c.Check(snapstate.ClassifyChange(chg), Equals, snapstate.ErrorCodeStoreAuth)
+}
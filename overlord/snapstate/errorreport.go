@@ -0,0 +1,110 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"time"
+
+	"github.com/snapcore/snapd/errtracker"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// errorReportsRetry is how long to wait before trying again to submit
+// the queued error reports when no new failure calls for it.
+var errorReportsRetry = time.Hour
+
+// errorReport returns the report of the failed change, about its first
+// failed task.
+func errorReport(chg *state.Change) *errtracker.Report {
+	r := &errtracker.Report{Code: string(ChangeErrorCode(chg))}
+	for _, t := range chg.Tasks() {
+		if t.Status() != state.ErrorStatus {
+			continue
+		}
+		if ss, err := TaskSnapSetup(t); err == nil {
+			r.Snap = ss.Name
+			if !ss.Revision.Unset() {
+				r.Revision = ss.Revision.String()
+			}
+		}
+		r.Log = t.Log()
+		break
+	}
+	return r
+}
+
+// errorReportsConfig returns whether the user opted in to the error
+// reports, and where they are submitted to.
+func errorReportsConfig(st *state.State) (enabled bool, url string) {
+	if err := configstate.Get(st, configstate.CoreSnapName, "error-reports.enable", &enabled); err != nil {
+		return false, ""
+	}
+	configstate.Get(st, configstate.CoreSnapName, "error-reports.url", &url)
+	return enabled, url
+}
+
+// ensureErrorReports queues the reports of the changes that failed since
+// the last time, if the user opted in, and submits the queued reports.
+// Changes failed while the reports were not enabled are never reported.
+func (m *SnapManager) ensureErrorReports() {
+	st := m.state
+	st.Lock()
+	enabled, url := errorReportsConfig(st)
+	var reports []*errtracker.Report
+	for _, chg := range st.Changes() {
+		var reported bool
+		if ChangeErrorCode(chg) == "" || chg.Get("error-reported", &reported) == nil {
+			continue
+		}
+		chg.Set("error-reported", true)
+		if enabled {
+			reports = append(reports, errorReport(chg))
+		}
+	}
+	st.Unlock()
+
+	if !enabled {
+		return
+	}
+	for _, r := range reports {
+		if err := errtracker.Add(r); err != nil {
+			logger.Noticef("%v", err)
+		}
+	}
+
+	now := timeNow()
+	if url == "" || (len(reports) == 0 && now.Before(m.nextErrorReportsFlush)) {
+		return
+	}
+	m.nextErrorReportsFlush = now.Add(errorReportsRetry)
+	queued, err := errtracker.Queued()
+	if err != nil {
+		logger.Noticef("%v", err)
+		return
+	}
+	if !queued {
+		return
+	}
+	if err := errtracker.Flush(url); err != nil {
+		logger.Noticef("%v", err)
+	}
+}
@@ -0,0 +1,241 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"sort"
+	"time"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/notifications"
+	"github.com/snapcore/snapd/overlord/configstate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// eventsRetry is how long to wait before trying again to publish the
+// queued events when no new event calls for it.
+var eventsRetry = time.Minute
+
+// maxQueuedEvents is how many events are kept until they are published;
+// the oldest ones are dropped first.
+const maxQueuedEvents = 100
+
+// queuedEvent is an event waiting to be published.
+type queuedEvent struct {
+	Event *notifications.Notification `json:"event"`
+	// Pending are the kinds of the backends the event is still to be
+	// published with.
+	Pending []string `json:"pending"`
+}
+
+// eventBackends returns the backends the user configured to publish
+// the events with, by kind.
+func eventBackends(st *state.State) map[string]notifications.Backend {
+	backends := make(map[string]notifications.Backend)
+	var token, target string
+	configstate.Get(st, configstate.CoreSnapName, "events.token", &token)
+	if configstate.Get(st, configstate.CoreSnapName, "events.webhook", &target) == nil {
+		if b, err := notifications.NewWebhookBackend(target, token); err != nil {
+			logger.Noticef("cannot publish events to webhook: %v", err)
+		} else {
+			backends["webhook"] = b
+		}
+	}
+	if configstate.Get(st, configstate.CoreSnapName, "events.mqtt", &target) == nil {
+		if b, err := notifications.NewBackend("mqtt", target); err != nil {
+			logger.Noticef("cannot publish events to MQTT: %v", err)
+		} else {
+			backends["mqtt"] = b
+		}
+	}
+	return backends
+}
+
+func changeEvent(typ string, chg *state.Change) *notifications.Notification {
+	metadata := map[string]interface{}{
+		"id":      chg.ID(),
+		"kind":    chg.Kind(),
+		"summary": chg.Summary(),
+		"status":  chg.Status().String(),
+	}
+	if err := chg.Err(); err != nil {
+		metadata["error"] = err.Error()
+		metadata["error-code"] = string(ChangeErrorCode(chg))
+	}
+	return &notifications.Notification{
+		Timestamp: timeNow().Unix(),
+		Type:      typ,
+		Resource:  "/v2/changes/" + chg.ID(),
+		Metadata:  metadata,
+	}
+}
+
+// snapEvents returns the events about the snaps the change installed,
+// refreshed or removed.
+func snapEvents(chg *state.Change) []*notifications.Notification {
+	var events []*notifications.Notification
+	for _, t := range chg.Tasks() {
+		if t.Status() != state.DoneStatus {
+			continue
+		}
+		var typ string
+		switch t.Kind() {
+		case "link-snap":
+			typ = "snap-installed"
+		case "discard-conns":
+			// only done once all the revisions are removed
+			typ = "snap-removed"
+		default:
+			continue
+		}
+		ss, err := TaskSnapSetup(t)
+		if err != nil {
+			continue
+		}
+		metadata := map[string]interface{}{
+			"snap":   ss.Name,
+			"change": chg.ID(),
+		}
+		if typ == "snap-installed" {
+			metadata["revision"] = ss.Revision.String()
+		}
+		events = append(events, &notifications.Notification{
+			Timestamp: timeNow().Unix(),
+			Type:      typ,
+			Resource:  "/v2/snaps/" + ss.Name,
+			Metadata:  metadata,
+		})
+	}
+	return events
+}
+
+// changeStarted returns whether a task of the change started running.
+func changeStarted(chg *state.Change) bool {
+	for _, t := range chg.Tasks() {
+		if t.Status() != state.DoStatus {
+			return true
+		}
+	}
+	return false
+}
+
+// changeEvents returns the events about the changes that started or
+// became ready since the last time, and about their snaps.
+// Note that the state must be locked by the caller.
+func changeEvents(st *state.State) []*notifications.Notification {
+	var events []*notifications.Notification
+	for _, chg := range st.Changes() {
+		var stage string
+		chg.Get("event-stage", &stage)
+		if stage == "ready" {
+			continue
+		}
+		status := chg.Status()
+		if stage == "" && (status.Ready() || changeStarted(chg)) {
+			events = append(events, changeEvent("change-started", chg))
+			stage = "started"
+		}
+		if status.Ready() {
+			typ := "change-finished"
+			if status == state.ErrorStatus {
+				typ = "change-failed"
+			}
+			events = append(events, changeEvent(typ, chg))
+			events = append(events, snapEvents(chg)...)
+			stage = "ready"
+		}
+		if stage != "" {
+			chg.Set("event-stage", stage)
+		}
+	}
+	return events
+}
+
+// ensureEvents publishes the events about the changes, started,
+// finished or failed, and about the snaps they installed or removed,
+// with the webhook and the MQTT broker the user configured, if any.
+// Events that cannot be published are queued and retried. Changes
+// from before a backend was configured are never published.
+func (m *SnapManager) ensureEvents() {
+	st := m.state
+	st.Lock()
+	backends := eventBackends(st)
+	events := changeEvents(st)
+	var queue []*queuedEvent
+	if err := st.Get("event-queue", &queue); err != nil && err != state.ErrNoState {
+		logger.Noticef("cannot read queued events: %v", err)
+	}
+	if len(backends) > 0 {
+		kinds := make([]string, 0, len(backends))
+		for kind := range backends {
+			kinds = append(kinds, kind)
+		}
+		sort.Strings(kinds)
+		for _, ev := range events {
+			queue = append(queue, &queuedEvent{Event: ev, Pending: kinds})
+		}
+	}
+	if len(queue) > maxQueuedEvents {
+		queue = queue[len(queue)-maxQueuedEvents:]
+	}
+	st.Set("event-queue", queue)
+
+	now := timeNow()
+	if len(queue) == 0 || (len(events) == 0 && now.Before(m.nextEventsFlush)) {
+		st.Unlock()
+		return
+	}
+	// don't hold the state while publishing
+	st.Unlock()
+
+	var remaining []*queuedEvent
+	failed := make(map[string]bool)
+	for _, qe := range queue {
+		var pending []string
+		for _, kind := range qe.Pending {
+			b, ok := backends[kind]
+			if !ok {
+				// not configured anymore
+				continue
+			}
+			if failed[kind] {
+				// keep the events in order
+				pending = append(pending, kind)
+				continue
+			}
+			if err := b.Deliver(qe.Event); err != nil {
+				logger.Noticef("cannot publish events with %s: %v", kind, err)
+				failed[kind] = true
+				pending = append(pending, kind)
+			}
+		}
+		if len(pending) > 0 {
+			qe.Pending = pending
+			remaining = append(remaining, qe)
+		}
+	}
+	if len(failed) > 0 {
+		m.nextEventsFlush = now.Add(eventsRetry)
+	}
+
+	st.Lock()
+	st.Set("event-queue", remaining)
+	st.Unlock()
+}
@@ -0,0 +1,197 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/notifications"
+	"github.com/snapcore/snapd/overlord/configstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+type eventsServer struct {
+	*httptest.Server
+	status   int
+	received []*notifications.Notification
+}
+
+// newEventsServer starts a webhook keeping the events it got, unless
+// its status is set to an error.
+func newEventsServer(c *C) *eventsServer {
+	s := &eventsServer{status: 200}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Header.Get("Authorization"), Equals, "Bearer s3kr1t")
+		if s.status != 200 {
+			w.WriteHeader(s.status)
+			return
+		}
+		var n notifications.Notification
+		c.Assert(json.NewDecoder(r.Body).Decode(&n), IsNil)
+		s.received = append(s.received, &n)
+	}))
+	return s
+}
+
+func (s *eventsServer) types() []string {
+	var types []string
+	for _, n := range s.received {
+		types = append(types, n.Type)
+	}
+	return types
+}
+
+func (s *snapmgrTestSuite) configureEvents(c *C, url string) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Assert(configstate.Set(s.state, "core", "events.webhook", url), IsNil)
+	c.Assert(configstate.Set(s.state, "core", "events.token", "s3kr1t"), IsNil)
+}
+
+func (s *snapmgrTestSuite) TestEventsPublished(c *C) {
+	server := newEventsServer(c)
+	defer server.Close()
+	s.configureEvents(c, server.URL)
+
+	now := time.Date(2016, 10, 1, 12, 0, 0, 0, time.UTC)
+	restore := snapstate.MockTimeNow(func() time.Time { return now })
+	defer restore()
+
+	s.state.Lock()
+	chg := s.state.NewChange("install-snap", "Install snap foo")
+	t1 := s.state.NewTask("download-snap", "...")
+	t1.Set("snap-setup", &snapstate.SnapSetup{Name: "foo", Revision: snap.R(7)})
+	t2 := s.state.NewTask("link-snap", "...")
+	t2.Set("snap-setup-task", t1.ID())
+	t2.WaitFor(t1)
+	chg.AddTask(t1)
+	chg.AddTask(t2)
+	s.state.Unlock()
+
+	// not started yet
+	s.snapmgr.EnsureEvents()
+	c.Check(server.received, HasLen, 0)
+
+	s.state.Lock()
+	t1.SetStatus(state.DoingStatus)
+	s.state.Unlock()
+	s.snapmgr.EnsureEvents()
+	c.Check(server.types(), DeepEquals, []string{"change-started"})
+	c.Check(server.received[0], DeepEquals, &notifications.Notification{
+		Timestamp: now.Unix(),
+		Type:      "change-started",
+		Resource:  "/v2/changes/" + chg.ID(),
+		Metadata: map[string]interface{}{
+			"id":      chg.ID(),
+			"kind":    "install-snap",
+			"summary": "Install snap foo",
+			"status":  "Doing",
+		},
+	})
+
+	s.state.Lock()
+	t1.SetStatus(state.DoneStatus)
+	t2.SetStatus(state.DoneStatus)
+	s.state.Unlock()
+	s.snapmgr.EnsureEvents()
+	c.Check(server.types(), DeepEquals, []string{"change-started", "change-finished", "snap-installed"})
+	c.Check(server.received[2].Resource, Equals, "/v2/snaps/foo")
+	c.Check(server.received[2].Metadata, DeepEquals, map[string]interface{}{
+		"snap":     "foo",
+		"revision": "7",
+		"change":   chg.ID(),
+	})
+
+	// each event is published once
+	s.snapmgr.EnsureEvents()
+	c.Check(server.received, HasLen, 3)
+}
+
+func (s *snapmgrTestSuite) TestEventsFailedChange(c *C) {
+	server := newEventsServer(c)
+	defer server.Close()
+	s.configureEvents(c, server.URL)
+
+	s.state.Lock()
+	chg := s.failedInstall(c)
+	s.state.Unlock()
+
+	s.snapmgr.EnsureEvents()
+	c.Check(server.types(), DeepEquals, []string{"change-started", "change-failed"})
+	c.Check(server.received[1].Metadata["error-code"], Equals, "network")
+	c.Check(server.received[1].Metadata["error"], Matches, `(?s)cannot perform the following tasks:.*`)
+	c.Check(server.received[1].Metadata["id"], Equals, chg.ID())
+}
+
+func (s *snapmgrTestSuite) TestEventsRetried(c *C) {
+	server := newEventsServer(c)
+	defer server.Close()
+	server.status = 503
+	s.configureEvents(c, server.URL)
+
+	now := time.Now()
+	restore := snapstate.MockTimeNow(func() time.Time { return now })
+	defer restore()
+
+	s.state.Lock()
+	s.failedInstall(c)
+	s.state.Unlock()
+
+	s.snapmgr.EnsureEvents()
+	c.Check(server.received, HasLen, 0)
+
+	// not retried right away
+	server.status = 200
+	s.snapmgr.EnsureEvents()
+	c.Check(server.received, HasLen, 0)
+
+	now = now.Add(2 * time.Minute)
+	s.snapmgr.EnsureEvents()
+	c.Check(server.types(), DeepEquals, []string{"change-started", "change-failed"})
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	var queue []interface{}
+	c.Assert(s.state.Get("event-queue", &queue), IsNil)
+	c.Check(queue, HasLen, 0)
+}
+
+func (s *snapmgrTestSuite) TestEventsNotConfigured(c *C) {
+	server := newEventsServer(c)
+	defer server.Close()
+
+	s.state.Lock()
+	s.failedInstall(c)
+	s.state.Unlock()
+
+	s.snapmgr.EnsureEvents()
+
+	// changes from before are not published later
+	s.configureEvents(c, server.URL)
+	s.snapmgr.EnsureEvents()
+	c.Check(server.received, HasLen, 0)
+}
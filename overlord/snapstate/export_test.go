@@ -21,11 +21,13 @@ package snapstate
 
 import (
 	"errors"
+	"time"
 
 	"gopkg.in/tomb.v2"
 
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/store"
 )
 
 type ManagerBackend managerBackend
@@ -38,6 +40,10 @@ type ForeignTaskTracker interface {
 	ForeignTask(kind string, status state.Status, ss *SnapSetup)
 }
 
+func SetSnapManagerPeerCache(s *SnapManager, c *store.PeerCache) {
+	s.peerCache = c
+}
+
 // AddForeignTaskHandlers registers handlers for tasks handled outside of the snap manager.
 func (m *SnapManager) AddForeignTaskHandlers(tracker ForeignTaskTracker) {
 	// Add fake handlers for tasks handled by interfaces manager
@@ -77,9 +83,30 @@ func MockOpenSnapFile(mock func(path string, si *snap.SideInfo) (*snap.Info, sna
 	return func() { openSnapFile = prevOpenSnapFile }
 }
 
+func MockTimeNow(now func() time.Time) (restore func()) {
+	timeNow = now
+	return func() { timeNow = time.Now }
+}
+
+func MockVerifyRefreshSpecSignature(mock func(specPath, sigPath, keyring string) error) (restore func()) {
+	prevVerify := verifyRefreshSpecSignature
+	verifyRefreshSpecSignature = mock
+	return func() { verifyRefreshSpecSignature = prevVerify }
+}
+
+func MockJitter(mock func(time.Duration) time.Duration) (restore func()) {
+	prevJitter := jitter
+	jitter = mock
+	return func() { jitter = prevJitter }
+}
+
 var (
-	CheckSnap = checkSnap
-	CanRemove = canRemove
+	CheckSnap  = checkSnap
+	RetryDelay = retryDelay
+	CanRemove  = canRemove
+	InRollout  = inRollout
+
+	QuarantineSnap = quarantineSnap
 )
 
 // flagscompat
@@ -87,3 +114,40 @@ const (
 	InterimUnusableFlagValueMin  = interimUnusableLegacyFlagValueMin
 	InterimUnusableFlagValueLast = interimUnusableLegacyFlagValueLast
 )
+
+func (m *SnapManager) EnsureErrorReports() {
+	m.ensureErrorReports()
+}
+
+func (m *SnapManager) EnsureAdvisories() {
+	m.ensureAdvisories()
+}
+
+func (m *SnapManager) EnsureNotifications() {
+	m.ensureNotifications()
+}
+
+func (m *SnapManager) EnsureEvents() {
+	m.ensureEvents()
+}
+
+func (m *SnapManager) EnsureManifest() {
+	m.ensureManifest()
+}
+
+var (
+	QueueNotification = queueNotification
+	SetRebootRequired = setRebootRequired
+)
+
+func (m *SnapManager) EnsureReadOnlyData() error {
+	return m.ensureReadOnlyData()
+}
+
+func (m *SnapManager) EnsureBootOk() error {
+	return m.ensureBootOk()
+}
+
+var BootFileNameAndRevision = bootFileNameAndRevision
+
+var NextReboot = nextReboot
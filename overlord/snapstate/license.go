@@ -0,0 +1,146 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/wrappers"
+)
+
+// LicenseAcceptance records the acceptance of the license of a snap.
+type LicenseAcceptance struct {
+	// Revision is the revision of the snap whose license was accepted.
+	Revision snap.Revision `json:"revision"`
+	// Version is the license-version of the accepted license, if any.
+	Version string    `json:"version,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// LicenseAccepted returns true if the license of the given revision of
+// the snap does not need to be accepted, or was accepted. An acceptance
+// carries over to the revisions declaring the same license-version.
+func (snapst *SnapState) LicenseAccepted(info *snap.Info) bool {
+	if !info.NeedsLicenseAgreement() {
+		return true
+	}
+	acc := snapst.License
+	if acc == nil {
+		return false
+	}
+	if acc.Revision == info.Revision {
+		return true
+	}
+	return acc.Version != "" && acc.Version == info.LicenseVersion
+}
+
+// acceptLicense records that the license of the given revision of the
+// snap was accepted.
+func (snapst *SnapState) acceptLicense(info *snap.Info) {
+	snapst.License = &LicenseAcceptance{
+		Revision: info.Revision,
+		Version:  info.LicenseVersion,
+		Time:     time.Now(),
+	}
+}
+
+// linkSnap makes the snap available to the system, leaving its services
+// stopped unless its license was accepted.
+// Note that the state must not be locked by the caller.
+func (m *SnapManager) linkSnap(info *snap.Info, licenseAccepted bool) error {
+	if licenseAccepted {
+		return m.backend.LinkSnap(info)
+	}
+	return m.backend.LinkSnapWithoutServices(info)
+}
+
+// AcceptSnapLicense returns the tasks recording the acceptance of the
+// license of the current revision of the snap and starting its services.
+func AcceptSnapLicense(s *state.State, name string) (*state.TaskSet, error) {
+	var snapst SnapState
+	err := Get(s, name, &snapst)
+	if err == state.ErrNoState {
+		return nil, fmt.Errorf("cannot find snap %q", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	info, err := readInfo(name, snapst.Current())
+	if err != nil {
+		return nil, err
+	}
+	if !info.NeedsLicenseAgreement() {
+		return nil, fmt.Errorf("snap %q does not require accepting its license", name)
+	}
+	if !snapst.Active {
+		return nil, fmt.Errorf("snap %q is not active", name)
+	}
+	if err := checkChangeConflict(s, name); err != nil {
+		return nil, err
+	}
+
+	ss := SnapSetup{
+		Name:     name,
+		Revision: info.Revision,
+	}
+	accept := s.NewTask("accept-license", fmt.Sprintf(i18n.G("Accept the license of snap %q and start its services"), name))
+	accept.Set("snap-setup", ss)
+
+	return state.NewTaskSet(accept), nil
+}
+
+func (m *SnapManager) doAcceptLicense(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	ss, snapst, err := snapSetupAndState(t)
+	st.Unlock()
+	if err != nil {
+		return err
+	}
+
+	info, err := readInfo(ss.Name, snapst.Current())
+	if err != nil {
+		return err
+	}
+	pb := &TaskProgressAdapter{task: t}
+	err = m.backend.StartSnapServices(info, pb)
+
+	st.Lock()
+	defer st.Unlock()
+	if err != nil {
+		if e, ok := err.(*wrappers.ServiceStartError); ok && len(e.Log) > 0 {
+			t.SetOutput(strings.Join(e.Log, "\n") + "\n")
+		}
+		return err
+	}
+	if err := Get(st, ss.Name, snapst); err != nil {
+		return err
+	}
+	snapst.acceptLicense(info)
+	Set(st, ss.Name, snapst)
+	return nil
+}
@@ -0,0 +1,173 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"strings"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+func (s *snapmgrTestSuite) linkOps() []fakeOp {
+	var ops []fakeOp
+	for _, op := range s.fakeBackend.ops {
+		switch op.op {
+		case "link-snap", "link-snap-without-services", "start-snap-services":
+			ops = append(ops, op)
+		}
+	}
+	s.fakeBackend.ops = nil
+	return ops
+}
+
+func (s *snapmgrTestSuite) TestInstallLicenseNotAccepted(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	chg := s.state.NewChange("install", "install a snap")
+	ts, err := snapstate.Install(s.state, "licensed-snap", "some-channel", s.user.ID, 0)
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle()
+	s.state.Lock()
+
+	c.Assert(chg.Status(), Equals, state.DoneStatus)
+	c.Check(s.linkOps(), DeepEquals, []fakeOp{
+		{op: "link-snap-without-services", name: "/snap/licensed-snap/11"},
+	})
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "licensed-snap", &snapst), IsNil)
+	c.Check(snapst.Active, Equals, true)
+	c.Check(snapst.License, IsNil)
+
+	link := ts.Tasks()[len(ts.Tasks())-1]
+	c.Assert(link.Kind(), Equals, "link-snap")
+	c.Assert(link.Log(), HasLen, 1)
+	c.Check(strings.HasSuffix(link.Log()[0], `Services of snap "licensed-snap" not started: its license must be accepted first`), Equals, true)
+}
+
+func (s *snapmgrTestSuite) TestInstallAcceptLicense(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	chg := s.state.NewChange("install", "install a snap")
+	ts, err := snapstate.Install(s.state, "licensed-snap", "some-channel", s.user.ID, snapstate.AcceptLicense)
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle()
+	s.state.Lock()
+
+	c.Assert(chg.Status(), Equals, state.DoneStatus)
+	c.Check(s.linkOps(), DeepEquals, []fakeOp{
+		{op: "link-snap", name: "/snap/licensed-snap/11"},
+	})
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "licensed-snap", &snapst), IsNil)
+	c.Assert(snapst.License, NotNil)
+	c.Check(snapst.License.Revision, Equals, snap.R(11))
+	c.Check(snapst.License.Version, Equals, "1")
+	c.Check(snapst.License.Time.IsZero(), Equals, false)
+}
+
+func (s *snapmgrTestSuite) TestAcceptSnapLicense(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "licensed-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{OfficialName: "licensed-snap", Revision: snap.R(7)}},
+	})
+
+	chg := s.state.NewChange("accept-license", "...")
+	ts, err := snapstate.AcceptSnapLicense(s.state, "licensed-snap")
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	// no other operation on the snap while accepting
+	_, err = snapstate.AcceptSnapLicense(s.state, "licensed-snap")
+	c.Check(err, ErrorMatches, `snap "licensed-snap" has changes in progress`)
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle()
+	s.state.Lock()
+
+	c.Assert(chg.Status(), Equals, state.DoneStatus)
+	c.Check(s.linkOps(), DeepEquals, []fakeOp{
+		{op: "start-snap-services", name: "/snap/licensed-snap/7"},
+	})
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "licensed-snap", &snapst), IsNil)
+	c.Assert(snapst.License, NotNil)
+	c.Check(snapst.License.Revision, Equals, snap.R(7))
+}
+
+func (s *snapmgrTestSuite) TestAcceptSnapLicenseErrors(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{OfficialName: "some-snap", Revision: snap.R(7)}},
+	})
+	snapstate.Set(s.state, "licensed-snap", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{{OfficialName: "licensed-snap", Revision: snap.R(7)}},
+	})
+
+	_, err := snapstate.AcceptSnapLicense(s.state, "missing-snap")
+	c.Check(err, ErrorMatches, `cannot find snap "missing-snap"`)
+	_, err = snapstate.AcceptSnapLicense(s.state, "some-snap")
+	c.Check(err, ErrorMatches, `snap "some-snap" does not require accepting its license`)
+	_, err = snapstate.AcceptSnapLicense(s.state, "licensed-snap")
+	c.Check(err, ErrorMatches, `snap "licensed-snap" is not active`)
+}
+
+func (s *snapmgrTestSuite) TestLicenseAccepted(c *C) {
+	info := &snap.Info{SideInfo: snap.SideInfo{Revision: snap.R(8)}}
+	snapst := &snapstate.SnapState{}
+	// snaps without an explicit license agreement need no acceptance
+	c.Check(snapst.LicenseAccepted(info), Equals, true)
+
+	info.LicenseAgreement = snap.LicenseAgreementExplicit
+	c.Check(snapst.LicenseAccepted(info), Equals, false)
+
+	snapst.License = &snapstate.LicenseAcceptance{Revision: snap.R(8)}
+	c.Check(snapst.LicenseAccepted(info), Equals, true)
+
+	// the acceptance of another revision only carries over with
+	// the same license version
+	snapst.License = &snapstate.LicenseAcceptance{Revision: snap.R(7)}
+	c.Check(snapst.LicenseAccepted(info), Equals, false)
+	snapst.License.Version = "2"
+	info.LicenseVersion = "2"
+	c.Check(snapst.LicenseAccepted(info), Equals, true)
+	info.LicenseVersion = "3"
+	c.Check(snapst.LicenseAccepted(info), Equals, false)
+}
@@ -20,14 +20,22 @@
 package snapstate_test
 
 import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	. "gopkg.in/check.v1"
 
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/release"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/wrappers"
 )
 
 type linkSnapSuite struct {
@@ -44,15 +52,15 @@ type linkSnapSuite struct {
 var _ = Suite(&linkSnapSuite{})
 
 type witnessRestartReqStateBackend struct {
-	restartRequested bool
+	restartRequested []state.RestartType
 }
 
 func (b *witnessRestartReqStateBackend) Checkpoint([]byte) error {
 	return nil
 }
 
-func (b *witnessRestartReqStateBackend) RequestRestart() {
-	b.restartRequested = true
+func (b *witnessRestartReqStateBackend) RequestRestart(t state.RestartType) {
+	b.restartRequested = append(b.restartRequested, t)
 }
 
 func (b *witnessRestartReqStateBackend) EnsureBefore(time.Duration) {}
@@ -106,7 +114,7 @@ func (s *linkSnapSuite) TestDoLinkSnapSuccess(c *C) {
 	c.Check(snapst.Candidate, IsNil)
 	c.Check(snapst.Channel, Equals, "beta")
 	c.Check(t.Status(), Equals, state.DoneStatus)
-	c.Check(s.stateBackend.restartRequested, Equals, false)
+	c.Check(s.stateBackend.restartRequested, HasLen, 0)
 }
 
 func (s *linkSnapSuite) TestDoUndoLinkSnap(c *C) {
@@ -201,6 +209,33 @@ func (s *linkSnapSuite) TestDoLinkSnapTryToCleanupOnError(c *C) {
 	})
 }
 
+func (s *linkSnapSuite) TestDoLinkSnapServiceStartFailureKeepsLog(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	snapstate.Set(s.state, "foo", &snapstate.SnapState{
+		Candidate: &snap.SideInfo{OfficialName: "foo", Revision: snap.R(35)},
+	})
+	t := s.state.NewTask("link-snap", "test")
+	t.Set("snap-setup", &snapstate.SnapSetup{Name: "foo"})
+
+	s.fakeBackend.linkSnapFailTrigger = "/snap/foo/35"
+	s.fakeBackend.linkSnapFailErr = &wrappers.ServiceStartError{
+		Service: "snap.foo.svc.service",
+		Err:     errors.New("systemctl command [start snap.foo.svc.service] failed with exit status 1"),
+		Log:     []string{"2016-10-01T12:00:00.000000Z foo.svc starting", "2016-10-01T12:00:01.000000Z foo.svc cannot bind port"},
+	}
+	s.state.NewChange("dummy", "...").AddTask(t)
+	s.state.Unlock()
+
+	s.snapmgr.Ensure()
+	s.snapmgr.Wait()
+
+	s.state.Lock()
+	c.Check(t.Status(), Equals, state.ErrorStatus)
+	c.Check(t.Output(), Equals, "2016-10-01T12:00:00.000000Z foo.svc starting\n2016-10-01T12:00:01.000000Z foo.svc cannot bind port\n")
+	c.Check(strings.Join(t.Log(), "\n"), Matches, "(?s).*failed with exit status 1.*")
+}
+
 func (s *linkSnapSuite) TestDoLinkSnapSuccessCoreRestarts(c *C) {
 	restore := release.MockOnClassic(true)
 	defer restore()
@@ -226,5 +261,93 @@ func (s *linkSnapSuite) TestDoLinkSnapSuccessCoreRestarts(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
 	c.Check(t.Status(), Equals, state.DoneStatus)
-	c.Check(s.stateBackend.restartRequested, Equals, true)
+	c.Check(s.stateBackend.restartRequested, DeepEquals, []state.RestartType{state.RestartDaemon})
+}
+
+func (s *linkSnapSuite) TestDoLinkSnapSuccessGadgetRequiresReboot(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("")
+
+	bootID := filepath.Join(dirs.GlobalRootDir, "/proc/sys/kernel/random/boot_id")
+	c.Assert(os.MkdirAll(filepath.Dir(bootID), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(bootID, []byte("boot-1\n"), 0644), IsNil)
+
+	s.state.Lock()
+	snapstate.Set(s.state, "gadget", &snapstate.SnapState{
+		Candidate: &snap.SideInfo{
+			OfficialName: "gadget",
+			Revision:     snap.R(33),
+		},
+	})
+	t := s.state.NewTask("link-snap", "test")
+	t.Set("snap-setup", &snapstate.SnapSetup{
+		Name: "gadget",
+	})
+	chg := s.state.NewChange("dummy", "...")
+	chg.AddTask(t)
+
+	s.state.Unlock()
+
+	s.snapmgr.Ensure()
+	s.snapmgr.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(t.Status(), Equals, state.DoneStatus)
+
+	var rebootRequired bool
+	c.Assert(chg.Get("reboot-required", &rebootRequired), IsNil)
+	c.Check(rebootRequired, Equals, true)
+
+	snaps, err := snapstate.RebootRequired(s.state)
+	c.Assert(err, IsNil)
+	c.Check(snaps, DeepEquals, []string{"gadget"})
+
+	data, err := ioutil.ReadFile(dirs.SnapRebootRequiredFile)
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, "*** System restart required ***\n")
+	data, err = ioutil.ReadFile(dirs.SnapRebootRequiredFile + ".pkgs")
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, "gadget\n")
+
+	// once rebooted, nothing is waiting anymore
+	c.Assert(ioutil.WriteFile(bootID, []byte("boot-2\n"), 0644), IsNil)
+	snaps, err = snapstate.RebootRequired(s.state)
+	c.Assert(err, IsNil)
+	c.Check(snaps, HasLen, 0)
+}
+
+func (s *linkSnapSuite) TestDoLinkSnapSuccessAppNoReboot(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("")
+
+	s.state.Lock()
+	snapstate.Set(s.state, "foo", &snapstate.SnapState{
+		Candidate: &snap.SideInfo{
+			OfficialName: "foo",
+			Revision:     snap.R(33),
+		},
+	})
+	t := s.state.NewTask("link-snap", "test")
+	t.Set("snap-setup", &snapstate.SnapSetup{
+		Name: "foo",
+	})
+	chg := s.state.NewChange("dummy", "...")
+	chg.AddTask(t)
+
+	s.state.Unlock()
+
+	s.snapmgr.Ensure()
+	s.snapmgr.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(t.Status(), Equals, state.DoneStatus)
+
+	var rebootRequired bool
+	c.Check(chg.Get("reboot-required", &rebootRequired), Equals, state.ErrNoState)
+	snaps, err := snapstate.RebootRequired(s.state)
+	c.Assert(err, IsNil)
+	c.Check(snaps, HasLen, 0)
+	c.Check(osutil.FileExists(dirs.SnapRebootRequiredFile), Equals, false)
 }
@@ -0,0 +1,151 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"encoding/hex"
+	"io"
+	"os"
+
+	"github.com/snapcore/snapd/fips"
+	"github.com/snapcore/snapd/installmanifest"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// manifestEntry is an install manifest entry waiting for the digest of
+// the snap file, if any, to be computed.
+type manifestEntry struct {
+	*installmanifest.Entry
+	snapFile string
+}
+
+// manifestEntries returns the install manifest entries for the snaps the
+// change installed, refreshed or removed.
+func manifestEntries(chg *state.Change) []manifestEntry {
+	refreshed := make(map[string]bool)
+	for _, t := range chg.Tasks() {
+		if t.Kind() != "unlink-current-snap" || t.Status() != state.DoneStatus {
+			continue
+		}
+		if ss, err := TaskSnapSetup(t); err == nil {
+			refreshed[ss.Name] = true
+		}
+	}
+
+	var entries []manifestEntry
+	for _, t := range chg.Tasks() {
+		if t.Status() != state.DoneStatus {
+			continue
+		}
+		var action string
+		switch t.Kind() {
+		case "link-snap":
+			action = installmanifest.ActionInstall
+		case "discard-conns":
+			// only done once all the revisions are removed
+			action = installmanifest.ActionRemove
+		default:
+			continue
+		}
+		ss, err := TaskSnapSetup(t)
+		if err != nil {
+			continue
+		}
+		e := manifestEntry{Entry: &installmanifest.Entry{
+			Time:   t.ReadyTime(),
+			Action: action,
+			Snap:   ss.Name,
+			Change: chg.ID(),
+		}}
+		if action == installmanifest.ActionInstall {
+			if refreshed[ss.Name] {
+				e.Action = installmanifest.ActionRefresh
+			}
+			e.Revision = ss.Revision.String()
+			e.snapFile = ss.placeInfo().MountFile()
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// fileSHA512 returns the hex encoded sha512 digest of the file at path.
+func fileSHA512(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := fips.SHA512()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ensureManifest appends to the install manifest the snaps installed,
+// refreshed or removed by the changes that became ready since the last
+// time. Changes whose entries cannot be recorded are tried again.
+func (m *SnapManager) ensureManifest() {
+	st := m.state
+	st.Lock()
+	var changes []*state.Change
+	var entries []manifestEntry
+	for _, chg := range st.Changes() {
+		var recorded bool
+		chg.Get("manifest-recorded", &recorded)
+		if recorded || !chg.Status().Ready() {
+			continue
+		}
+		changes = append(changes, chg)
+		entries = append(entries, manifestEntries(chg)...)
+	}
+	// don't hold the state while reading the snap files
+	st.Unlock()
+
+	if len(changes) == 0 {
+		return
+	}
+	toAppend := make([]*installmanifest.Entry, 0, len(entries))
+	for _, e := range entries {
+		if e.snapFile != "" {
+			digest, err := fileSHA512(e.snapFile)
+			if err != nil {
+				// the revision might be gone already
+				logger.Debugf("cannot compute digest of %s: %v", e.snapFile, err)
+			}
+			e.SHA512 = digest
+		}
+		toAppend = append(toAppend, e.Entry)
+	}
+	if len(toAppend) > 0 {
+		if err := installmanifest.Append(toAppend...); err != nil {
+			logger.Noticef("cannot record changes in install manifest: %v", err)
+			return
+		}
+	}
+
+	st.Lock()
+	defer st.Unlock()
+	for _, chg := range changes {
+		chg.Set("manifest-recorded", true)
+	}
+}
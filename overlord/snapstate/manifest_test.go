@@ -0,0 +1,146 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/fips"
+	"github.com/snapcore/snapd/installmanifest"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+// doneChange adds a change whose tasks of the given kinds are all done,
+// for the given snap.
+func (s *snapmgrTestSuite) doneChange(c *C, kind, name string, rev snap.Revision, taskKinds ...string) *state.Change {
+	chg := s.state.NewChange(kind, "...")
+	var first *state.Task
+	for _, k := range taskKinds {
+		t := s.state.NewTask(k, "...")
+		if first == nil {
+			t.Set("snap-setup", &snapstate.SnapSetup{Name: name, Revision: rev})
+			first = t
+		} else {
+			t.Set("snap-setup-task", first.ID())
+		}
+		t.SetStatus(state.DoneStatus)
+		chg.AddTask(t)
+	}
+	return chg
+}
+
+func (s *snapmgrTestSuite) TestManifestRecorded(c *C) {
+	s.state.Lock()
+	install := s.doneChange(c, "install-snap", "foo", snap.R(7), "download-snap", "link-snap")
+	refresh := s.doneChange(c, "refresh-snap", "bar", snap.R(3), "download-snap", "unlink-current-snap", "link-snap")
+	remove := s.doneChange(c, "remove-snap", "baz", snap.R(2), "unlink-snap", "discard-snap", "discard-conns")
+	failed := s.failedInstall(c)
+	pending := s.state.NewChange("install-snap", "...")
+	pending.AddTask(s.state.NewTask("download-snap", "..."))
+	s.state.Unlock()
+
+	s.snapmgr.EnsureManifest()
+
+	entries, err := installmanifest.Entries()
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 3)
+	c.Check(installmanifest.Verify(entries), IsNil)
+
+	byChange := make(map[string]*installmanifest.Entry)
+	for _, e := range entries {
+		byChange[e.Change] = e
+	}
+	c.Check(byChange[install.ID()].Action, Equals, "install")
+	c.Check(byChange[install.ID()].Snap, Equals, "foo")
+	c.Check(byChange[install.ID()].Revision, Equals, "7")
+	c.Check(byChange[install.ID()].Time.IsZero(), Equals, false)
+	c.Check(byChange[refresh.ID()].Action, Equals, "refresh")
+	c.Check(byChange[refresh.ID()].Snap, Equals, "bar")
+	c.Check(byChange[refresh.ID()].Revision, Equals, "3")
+	c.Check(byChange[remove.ID()].Action, Equals, "remove")
+	c.Check(byChange[remove.ID()].Snap, Equals, "baz")
+	c.Check(byChange[remove.ID()].Revision, Equals, "")
+
+	s.state.Lock()
+	for _, chg := range []*state.Change{install, refresh, remove, failed} {
+		var recorded bool
+		c.Check(chg.Get("manifest-recorded", &recorded), IsNil)
+		c.Check(recorded, Equals, true)
+	}
+	c.Check(pending.Get("manifest-recorded", new(bool)), Equals, state.ErrNoState)
+	s.state.Unlock()
+
+	// each change is recorded once
+	s.snapmgr.EnsureManifest()
+	entries, err = installmanifest.Entries()
+	c.Assert(err, IsNil)
+	c.Check(entries, HasLen, 3)
+}
+
+func (s *snapmgrTestSuite) TestManifestDigest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("")
+
+	blob := snap.MinimalPlaceInfo("foo", snap.R(7)).MountFile()
+	c.Assert(os.MkdirAll(filepath.Dir(blob), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(blob, []byte("snap data"), 0644), IsNil)
+
+	s.state.Lock()
+	s.doneChange(c, "install-snap", "foo", snap.R(7), "download-snap", "link-snap")
+	s.state.Unlock()
+
+	s.snapmgr.EnsureManifest()
+
+	entries, err := installmanifest.Entries()
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 1)
+	c.Check(entries[0].SHA512, Equals, hex.EncodeToString(fips.Sum512([]byte("snap data"))))
+}
+
+func (s *snapmgrTestSuite) TestManifestRetried(c *C) {
+	// the manifest cannot be created
+	dirs.SnapInstallManifestFile = filepath.Join(c.MkDir(), "not-a-dir", "install-manifest.jsonl")
+	c.Assert(ioutil.WriteFile(filepath.Dir(dirs.SnapInstallManifestFile), nil, 0644), IsNil)
+
+	s.state.Lock()
+	chg := s.doneChange(c, "install-snap", "foo", snap.R(7), "download-snap", "link-snap")
+	s.state.Unlock()
+
+	s.snapmgr.EnsureManifest()
+
+	s.state.Lock()
+	c.Check(chg.Get("manifest-recorded", new(bool)), Equals, state.ErrNoState)
+	s.state.Unlock()
+
+	dirs.SnapInstallManifestFile = filepath.Join(c.MkDir(), "install-manifest.jsonl")
+	s.snapmgr.EnsureManifest()
+
+	entries, err := installmanifest.Entries()
+	c.Assert(err, IsNil)
+	c.Check(entries, HasLen, 1)
+}
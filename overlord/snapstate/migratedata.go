@@ -0,0 +1,101 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"fmt"
+
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// migrateDataHook is the hook of the new revision of a snap migrating
+// the data copied from the revision it refreshes from.
+const migrateDataHook = "migrate-data"
+
+// migrateDataHookHandler handles the migrate-data hook; a failing hook
+// fails the refresh, whose undoing discards the copy of the data so the
+// data of the previous revision is left as it was.
+type migrateDataHookHandler struct{}
+
+func (migrateDataHookHandler) Before() error         { return nil }
+func (migrateDataHookHandler) Done() error           { return nil }
+func (migrateDataHookHandler) Error(err error) error { return nil }
+
+// NewMigrateDataHookHandler returns the handler of the migrate-data
+// hook of the given context.
+func NewMigrateDataHookHandler(*hookstate.Context) hookstate.Handler {
+	return migrateDataHookHandler{}
+}
+
+// doMigrateSnapData adds to the change the running of the migrate-data
+// hook of the new revision, if it has one, before anything waiting on
+// the task. The hook is told where its data comes from through
+// SNAP_MIGRATE_FROM_REVISION and SNAP_MIGRATE_FROM_VERSION.
+func (m *SnapManager) doMigrateSnapData(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var hookID string
+	if err := t.Get("migrate-data-hook", &hookID); err == nil {
+		// added already, before a restart
+		return nil
+	}
+
+	ss, snapst, err := snapSetupAndState(t)
+	if err != nil {
+		return err
+	}
+	cur := snapst.Current()
+	if cur == nil {
+		return nil
+	}
+	newInfo, err := readInfo(ss.Name, snapst.Candidate)
+	if err != nil {
+		return err
+	}
+	if newInfo.Hooks[migrateDataHook] == nil {
+		return nil
+	}
+	oldInfo, err := readInfo(ss.Name, cur)
+	if err != nil {
+		return err
+	}
+
+	summary := fmt.Sprintf(i18n.G("Migrate data of snap %q from revision %s"), ss.Name, oldInfo.Revision)
+	hook := hookstate.HookTaskWithEnv(st, summary, ss.Name, newInfo.Revision, migrateDataHook, map[string]string{
+		"SNAP_MIGRATE_FROM_REVISION": oldInfo.Revision.String(),
+		"SNAP_MIGRATE_FROM_VERSION":  oldInfo.Version,
+	})
+	for _, halted := range t.HaltTasks() {
+		halted.WaitFor(hook)
+	}
+	hook.WaitFor(t)
+	for _, lane := range t.Lanes() {
+		hook.JoinLane(lane)
+	}
+	t.Change().AddTask(hook)
+	t.Set("migrate-data-hook", hook.ID())
+	return nil
+}
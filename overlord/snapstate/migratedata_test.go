@@ -0,0 +1,147 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+func (s *snapmgrTestSuite) mockMigrateDataHook() (restore func()) {
+	return snapstate.MockReadInfo(func(name string, si *snap.SideInfo) (*snap.Info, error) {
+		info, err := s.fakeBackend.ReadInfo(name, si)
+		if err == nil && si.Revision == snap.R(11) {
+			info.Hooks = map[string]*snap.HookInfo{"migrate-data": {Snap: info, Name: "migrate-data"}}
+		}
+		return info, err
+	})
+}
+
+func (s *snapmgrTestSuite) updateWithMigrateDataHook(c *C) (*state.Change, *state.Task) {
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{OfficialName: "some-snap", Revision: snap.R(7)}},
+	})
+
+	chg := s.state.NewChange("refresh", "refresh a snap")
+	ts, err := snapstate.Update(s.state, "some-snap", "some-channel", s.user.ID, 0)
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.state.Unlock()
+	s.settle()
+	s.state.Lock()
+
+	var hook *state.Task
+	for _, t := range chg.Tasks() {
+		if t.Kind() == "run-hook" {
+			c.Assert(hook, IsNil)
+			hook = t
+		}
+	}
+	c.Assert(hook, NotNil)
+	return chg, hook
+}
+
+func (s *snapmgrTestSuite) TestUpdateRunsMigrateDataHook(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	defer s.snapmgr.Stop()
+	defer s.mockMigrateDataHook()()
+
+	chg, hook := s.updateWithMigrateDataHook(c)
+
+	c.Check(hook.Summary(), Equals, `Migrate data of snap "some-snap" from revision 7`)
+	var setup map[string]interface{}
+	c.Assert(hook.Get("hook-setup", &setup), IsNil)
+	c.Check(setup, DeepEquals, map[string]interface{}{
+		"snap":     "some-snap",
+		"revision": "11",
+		"hook":     "migrate-data",
+		"env": map[string]interface{}{
+			"SNAP_MIGRATE_FROM_REVISION": "7",
+			"SNAP_MIGRATE_FROM_VERSION":  "",
+		},
+	})
+	c.Check(hook.Status(), Equals, state.DoStatus)
+	c.Assert(hook.WaitTasks(), HasLen, 1)
+	c.Check(hook.WaitTasks()[0].Kind(), Equals, "migrate-snap-data")
+
+	// nothing past the migration runs until the hook is done
+	for _, t := range hook.HaltTasks() {
+		c.Check(t.Kind(), Equals, "setup-profiles")
+		c.Check(t.Status(), Equals, state.DoStatus)
+	}
+	c.Check(chg.Status(), Equals, state.DoStatus)
+}
+
+func (s *snapmgrTestSuite) TestUpdateMigrateDataHookFailsUndoesCopy(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	defer s.snapmgr.Stop()
+	defer s.mockMigrateDataHook()()
+
+	chg, hook := s.updateWithMigrateDataHook(c)
+	s.fakeBackend.ops = nil
+	hook.SetStatus(state.ErrorStatus)
+	chg.AbortLanes(hook.Lanes())
+
+	s.state.Unlock()
+	s.settle()
+	s.state.Lock()
+
+	c.Check(chg.Status(), Equals, state.ErrorStatus)
+	var undone []string
+	for _, op := range s.fakeBackend.ops {
+		undone = append(undone, op.op)
+	}
+	c.Check(undone, DeepEquals, []string{"undo-copy-snap-data", "link-snap", "undo-setup-snap"})
+
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "some-snap", &snapst), IsNil)
+	c.Check(snapst.Active, Equals, true)
+	c.Check(snapst.Current().Revision, Equals, snap.R(7))
+}
+
+func (s *snapmgrTestSuite) TestUpdateWithoutMigrateDataHook(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	defer s.snapmgr.Stop()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{OfficialName: "some-snap", Revision: snap.R(7)}},
+	})
+
+	chg := s.state.NewChange("refresh", "refresh a snap")
+	ts, err := snapstate.Update(s.state, "some-snap", "some-channel", s.user.ID, 0)
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.state.Unlock()
+	s.settle()
+	s.state.Lock()
+
+	c.Check(chg.Status(), Equals, state.DoneStatus)
+	c.Check(chg.Tasks(), HasLen, len(ts.Tasks()))
+}
@@ -0,0 +1,80 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"fmt"
+
+	"github.com/snapcore/snapd/asserts"
+)
+
+// DeviceModel finds the model assertion of the device, which gates
+// which snaps can be installed and removed. It is set by the overlord
+// when wiring the managers.
+var DeviceModel func() (*asserts.Model, error)
+
+// deviceModel returns the model assertion of the device, or nil if
+// it has none.
+func deviceModel() (*asserts.Model, error) {
+	if DeviceModel == nil {
+		return nil, nil
+	}
+	mod, err := DeviceModel()
+	if err == asserts.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot find the model of the device: %v", err)
+	}
+	return mod, nil
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// checkInstallAllowed verifies that the model of the device lets the
+// snap be installed, from a local file if fromFile is set.
+func checkInstallAllowed(mod *asserts.Model, name string, fromFile bool) error {
+	if mod == nil {
+		return nil
+	}
+	if contains(mod.DisallowedSnaps(), name) {
+		return fmt.Errorf("snap %q is not allowed by the model of the device", name)
+	}
+	if fromFile && mod.StoreOnly() {
+		return fmt.Errorf("cannot install snap %q from a file: the model of the device only allows snaps from its store", name)
+	}
+	return nil
+}
+
+// checkRemoveAllowed verifies that the model of the device lets the
+// snap be removed.
+func checkRemoveAllowed(mod *asserts.Model, name string) error {
+	if mod != nil && contains(mod.RequiredSnaps(), name) {
+		return fmt.Errorf("snap %q is required by the model of the device and cannot be removed", name)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/snap"
+)
+
+const modelTemplate = "type: model\n" +
+	"authority-id: brand\n" +
+	"series: 16\n" +
+	"brand-id: brand\n" +
+	"model: baz-3000\n" +
+	"core: core\n" +
+	"architecture: amd64\n" +
+	"gadget: brand-gadget\n" +
+	"kernel: baz-linux\n" +
+	"store: brand-store\n" +
+	"allowed-modes: \n" +
+	"required-snaps: foo\n" +
+	"EXTRA" +
+	"class: fixed\n" +
+	"timestamp: TS\n" +
+	"body-length: 0" +
+	"\n\n" +
+	"openpgp c2ln"
+
+func makeModel(c *C, extra string) *asserts.Model {
+	encoded := strings.Replace(modelTemplate, "EXTRA", extra, 1)
+	encoded = strings.Replace(encoded, "TS", time.Now().UTC().Format(time.RFC3339), 1)
+	a, err := asserts.Decode([]byte(encoded))
+	c.Assert(err, IsNil)
+	return a.(*asserts.Model)
+}
+
+func (s *snapmgrTestSuite) mockModel(c *C, extra string) {
+	mod := makeModel(c, extra)
+	snapstate.DeviceModel = func() (*asserts.Model, error) {
+		return mod, nil
+	}
+}
+
+func (s *snapmgrTestSuite) TestInstallDisallowedByModel(c *C) {
+	s.mockModel(c, "disallowed-snaps: some-snap\n")
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	_, err := snapstate.Install(s.state, "some-snap", "", 0, 0)
+	c.Check(err, ErrorMatches, `snap "some-snap" is not allowed by the model of the device`)
+	_, err = snapstate.InstallRevision(s.state, "some-snap", snap.R(7), 0, 0)
+	c.Check(err, ErrorMatches, `snap "some-snap" is not allowed by the model of the device`)
+
+	mockSnap := "/path/to/some-snap_1.0_all.snap"
+	_, err = snapstate.InstallPath(s.state, "some-snap", mockSnap, "", 0)
+	c.Check(err, ErrorMatches, `snap "some-snap" is not allowed by the model of the device`)
+
+	// other snaps are fine
+	_, err = snapstate.Install(s.state, "other-snap", "", 0, 0)
+	c.Check(err, IsNil)
+}
+
+func (s *snapmgrTestSuite) TestInstallPathStoreOnlyModel(c *C) {
+	s.mockModel(c, "store-only: true\n")
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	mockSnap := "/path/to/some-snap_1.0_all.snap"
+	_, err := snapstate.InstallPath(s.state, "some-snap", mockSnap, "", 0)
+	c.Check(err, ErrorMatches, `cannot install snap "some-snap" from a file: the model of the device only allows snaps from its store`)
+	_, err = snapstate.TryPath(s.state, "some-snap", mockSnap, 0)
+	c.Check(err, ErrorMatches, `cannot install snap "some-snap" from a file: .*`)
+
+	_, err = snapstate.Install(s.state, "some-snap", "", 0, 0)
+	c.Check(err, IsNil)
+}
+
+func (s *snapmgrTestSuite) TestInstallPreferredChannelFromModel(c *C) {
+	s.mockModel(c, "preferred-channels: some-snap=beta\n")
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	channelOf := func(name, channel string) string {
+		ts, err := snapstate.Install(s.state, name, channel, 0, 0)
+		c.Assert(err, IsNil)
+		var ss snapstate.SnapSetup
+		err = ts.Tasks()[0].Get("snap-setup", &ss)
+		c.Assert(err, IsNil)
+		return ss.Channel
+	}
+
+	c.Check(channelOf("some-snap", ""), Equals, "beta")
+	c.Check(channelOf("other-snap", ""), Equals, "stable")
+}
+
+func (s *snapmgrTestSuite) TestInstallAskedChannelOverridesModel(c *C) {
+	s.mockModel(c, "preferred-channels: some-snap=beta\n")
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	ts, err := snapstate.Install(s.state, "some-snap", "edge", 0, 0)
+	c.Assert(err, IsNil)
+	var ss snapstate.SnapSetup
+	err = ts.Tasks()[0].Get("snap-setup", &ss)
+	c.Assert(err, IsNil)
+	c.Check(ss.Channel, Equals, "edge")
+}
+
+func (s *snapmgrTestSuite) TestRemoveRequiredByModel(c *C) {
+	s.mockModel(c, "")
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "foo", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{OfficialName: "foo"}},
+	})
+
+	_, err := snapstate.Remove(s.state, "foo")
+	c.Check(err, ErrorMatches, `snap "foo" is required by the model of the device and cannot be removed`)
+}
+
+func (s *snapmgrTestSuite) TestNoModel(c *C) {
+	snapstate.DeviceModel = func() (*asserts.Model, error) {
+		return nil, asserts.ErrNotFound
+	}
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "foo", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{OfficialName: "foo"}},
+	})
+
+	_, err := snapstate.Remove(s.state, "foo")
+	c.Check(err, IsNil)
+}
+
+func (s *snapmgrTestSuite) TestModelError(c *C) {
+	snapstate.DeviceModel = func() (*asserts.Model, error) {
+		return nil, errors.New("boom")
+	}
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	_, err := snapstate.Install(s.state, "some-snap", "", 0, 0)
+	c.Check(err, ErrorMatches, `cannot find the model of the device: boom`)
+}
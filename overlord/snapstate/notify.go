@@ -0,0 +1,147 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"fmt"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/notifications"
+	"github.com/snapcore/snapd/overlord/configstate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// maxQueuedNotifications is how many notifications are kept until they
+// are delivered; the oldest ones are dropped first.
+const maxQueuedNotifications = 50
+
+// queueNotification queues a notification of the given type about the
+// device, to be delivered with the backends the user configured under
+// notifications. The message is kept in the metadata.
+// Note that the state must be locked by the caller.
+func queueNotification(st *state.State, typ, resource, message string, metadata map[string]interface{}) {
+	var queued []*notifications.Notification
+	if err := st.Get("notifications", &queued); err != nil && err != state.ErrNoState {
+		logger.Noticef("cannot queue notification: %v", err)
+		return
+	}
+	if metadata == nil {
+		metadata = make(map[string]interface{})
+	}
+	metadata["message"] = message
+	queued = append(queued, &notifications.Notification{
+		Timestamp: timeNow().Unix(),
+		Type:      typ,
+		Resource:  resource,
+		Metadata:  metadata,
+	})
+	if len(queued) > maxQueuedNotifications {
+		queued = queued[len(queued)-maxQueuedNotifications:]
+	}
+	st.Set("notifications", queued)
+}
+
+// notificationBackends returns the backends the user configured for
+// the notifications.
+func notificationBackends(st *state.State) []notifications.Backend {
+	var backends []notifications.Backend
+	var desktop bool
+	if configstate.Get(st, configstate.CoreSnapName, "notifications.desktop", &desktop) == nil && desktop {
+		b, err := notifications.NewBackend("desktop", "")
+		if err != nil {
+			logger.Noticef("cannot use desktop notifications: %v", err)
+		} else {
+			backends = append(backends, b)
+		}
+	}
+	for _, kind := range []string{"webhook", "mqtt", "script"} {
+		var target string
+		if configstate.Get(st, configstate.CoreSnapName, "notifications."+kind, &target) != nil {
+			continue
+		}
+		b, err := notifications.NewBackend(kind, target)
+		if err != nil {
+			logger.Noticef("cannot use %s notifications: %v", kind, err)
+			continue
+		}
+		backends = append(backends, b)
+	}
+	return backends
+}
+
+// ensureNotifications delivers the queued notifications with each of
+// the configured backends. They are delivered at most once: failures
+// are only logged, and notifications queued while no backend was
+// configured are dropped.
+func (m *SnapManager) ensureNotifications() {
+	st := m.state
+	st.Lock()
+	var queued []*notifications.Notification
+	if err := st.Get("notifications", &queued); err != nil || len(queued) == 0 {
+		st.Unlock()
+		return
+	}
+	st.Set("notifications", nil)
+	backends := notificationBackends(st)
+	// don't hold the state while delivering
+	st.Unlock()
+
+	for _, n := range queued {
+		for _, b := range backends {
+			if err := b.Deliver(n); err != nil {
+				logger.Noticef("cannot deliver %s notification: %v", n.Type, err)
+			}
+		}
+	}
+}
+
+// queueSecurityWarnings queues a warning notification for each of the
+// security warnings about the installed snaps not notified before.
+// Note that the state must be locked by the caller.
+func queueSecurityWarnings(st *state.State) error {
+	warnings, err := SecurityWarnings(st)
+	if err != nil {
+		return err
+	}
+	var notified map[string]bool
+	if err := st.Get("notified-warnings", &notified); err != nil && err != state.ErrNoState {
+		return err
+	}
+	current := make(map[string]bool, len(warnings))
+	for _, w := range warnings {
+		key := fmt.Sprintf("%s/%s/%s", w.Snap, w.Revision, w.ID)
+		current[key] = true
+		if notified[key] {
+			continue
+		}
+		msg := fmt.Sprintf("Snap %q revision %s is affected by security advisory %s.", w.Snap, w.Revision, w.ID)
+		if w.Summary != "" {
+			msg = fmt.Sprintf("Snap %q revision %s is affected by security advisory %s: %s", w.Snap, w.Revision, w.ID, w.Summary)
+		}
+		queueNotification(st, "warning", "/v2/warnings", msg, map[string]interface{}{
+			"snap":     w.Snap,
+			"advisory": w.ID,
+			"severity": w.Severity,
+		})
+	}
+	// forget about the warnings that went away, e.g. once refreshed
+	st.Set("notified-warnings", current)
+	return nil
+}
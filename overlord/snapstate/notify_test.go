@@ -0,0 +1,156 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/notifications"
+	"github.com/snapcore/snapd/overlord/configstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// queuedNotifications returns the notifications waiting to be delivered.
+// Note that the state must be locked by the caller.
+func queuedNotifications(c *C, st *state.State) []*notifications.Notification {
+	var queued []*notifications.Notification
+	err := st.Get("notifications", &queued)
+	if err != state.ErrNoState {
+		c.Assert(err, IsNil)
+	}
+	return queued
+}
+
+func (s *snapmgrTestSuite) TestNotificationsDelivered(c *C) {
+	var received []*notifications.Notification
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n notifications.Notification
+		c.Assert(json.NewDecoder(r.Body).Decode(&n), IsNil)
+		received = append(received, &n)
+	}))
+	defer server.Close()
+
+	now := time.Date(2016, 10, 1, 12, 0, 0, 0, time.UTC)
+	restore := snapstate.MockTimeNow(func() time.Time { return now })
+	defer restore()
+
+	s.state.Lock()
+	c.Assert(configstate.Set(s.state, "core", "notifications.webhook", server.URL), IsNil)
+	snapstate.QueueNotification(s.state, "warning", "/v2/warnings", "Something is off.", map[string]interface{}{"snap": "foo"})
+	s.state.Unlock()
+
+	s.snapmgr.EnsureNotifications()
+
+	c.Assert(received, HasLen, 1)
+	c.Check(received[0], DeepEquals, &notifications.Notification{
+		Timestamp: now.Unix(),
+		Type:      "warning",
+		Resource:  "/v2/warnings",
+		Metadata:  map[string]interface{}{"snap": "foo", "message": "Something is off."},
+	})
+
+	// each notification is delivered once
+	s.snapmgr.EnsureNotifications()
+	c.Check(received, HasLen, 1)
+}
+
+func (s *snapmgrTestSuite) TestNotificationsDroppedWithoutBackends(c *C) {
+	s.state.Lock()
+	snapstate.QueueNotification(s.state, "warning", "", "Something is off.", nil)
+	s.state.Unlock()
+
+	s.snapmgr.EnsureNotifications()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(queuedNotifications(c, s.state), HasLen, 0)
+}
+
+func (s *snapmgrTestSuite) TestNotificationsQueueLimited(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	for i := 0; i < 60; i++ {
+		snapstate.QueueNotification(s.state, "warning", "", fmt.Sprintf("warning %d", i), nil)
+	}
+	queued := queuedNotifications(c, s.state)
+	c.Assert(queued, HasLen, 50)
+	c.Check(queued[0].Message(), Equals, "warning 10")
+}
+
+func (s *snapmgrTestSuite) TestRebootRequiredNotified(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("")
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Assert(snapstate.SetRebootRequired(s.state, "pc-kernel"), IsNil)
+	c.Assert(snapstate.SetRebootRequired(s.state, "pc"), IsNil)
+	// once per snap
+	c.Assert(snapstate.SetRebootRequired(s.state, "pc"), IsNil)
+
+	queued := queuedNotifications(c, s.state)
+	c.Assert(queued, HasLen, 2)
+	c.Check(queued[0].Type, Equals, "reboot-required")
+	c.Check(queued[0].Message(), Equals, "Reboot required for pc-kernel.")
+	c.Check(queued[1].Message(), Equals, "Reboot required for pc, pc-kernel.")
+	c.Check(queued[1].Metadata["snaps"], DeepEquals, []interface{}{"pc", "pc-kernel"})
+}
+
+func (s *snapmgrTestSuite) TestSecurityWarningsNotified(c *C) {
+	now := time.Now()
+	restore := snapstate.MockTimeNow(func() time.Time { return now })
+	defer restore()
+
+	s.setUpAdvisories(c)
+	s.state.Lock()
+	c.Assert(configstate.Set(s.state, "core", "security-advisories.enable", true), IsNil)
+	s.state.Unlock()
+
+	s.snapmgr.EnsureAdvisories()
+
+	s.state.Lock()
+	queued := queuedNotifications(c, s.state)
+	c.Assert(queued, HasLen, 2)
+	c.Check(queued[0].Type, Equals, "warning")
+	c.Check(queued[0].Resource, Equals, "/v2/warnings")
+	c.Check(queued[0].Message(), Equals, `Snap "foo" revision 7 is affected by security advisory USN-1: remote code execution`)
+	c.Check(queued[0].Metadata["severity"], Equals, "high")
+	c.Check(queued[1].Message(), Equals, `Snap "foo" revision 7 is affected by security advisory USN-3.`)
+	s.state.Unlock()
+
+	// the same warnings are notified once
+	s.snapmgr.EnsureNotifications()
+	now = now.Add(25 * time.Hour)
+	s.snapmgr.EnsureAdvisories()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(queuedNotifications(c, s.state), HasLen, 0)
+}
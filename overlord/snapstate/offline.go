@@ -0,0 +1,233 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/fips"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/store"
+)
+
+// cachedCandidate is what is kept of a refresh offered by the store to
+// apply it later without network.
+type cachedCandidate struct {
+	snap.SideInfo
+	Architectures     []string `json:"architectures,omitempty"`
+	RolloutPercentage float64  `json:"rollout-percentage,omitempty"`
+}
+
+func (c *cachedCandidate) info() *snap.Info {
+	return &snap.Info{
+		SideInfo:          c.SideInfo,
+		Architectures:     c.Architectures,
+		RolloutPercentage: c.RolloutPercentage,
+	}
+}
+
+// downloadCache returns the cache of the snap files downloaded from the
+// store.
+func downloadCache() *store.DownloadCache {
+	return store.NewDownloadCache(dirs.SnapDownloadCacheDir)
+}
+
+// CacheRefreshCandidates persists the refreshes offered by the store,
+// replacing the ones cached before, so they can be applied offline
+// once their snap files were downloaded.
+// Note that the state must be locked by the caller.
+func CacheRefreshCandidates(st *state.State, updates []*snap.Info) {
+	candidates := make(map[string]*cachedCandidate, len(updates))
+	for _, update := range updates {
+		candidates[update.Name()] = &cachedCandidate{
+			SideInfo:          update.SideInfo,
+			Architectures:     update.Architectures,
+			RolloutPercentage: update.RolloutPercentage,
+		}
+	}
+	st.Set("refresh-candidates", candidates)
+}
+
+func cachedRefreshCandidates(st *state.State) (map[string]*cachedCandidate, error) {
+	var candidates map[string]*cachedCandidate
+	if err := st.Get("refresh-candidates", &candidates); err != nil && err != state.ErrNoState {
+		return nil, err
+	}
+	return candidates, nil
+}
+
+// pruneDownloadCache drops the cached snap files that are not needed
+// for the cached refreshes anymore.
+func pruneDownloadCache(st *state.State) {
+	candidates, err := cachedRefreshCandidates(st)
+	if err != nil {
+		logger.Noticef("cannot prune download cache: %v", err)
+		return
+	}
+	keep := make(map[string]bool, len(candidates))
+	for _, c := range candidates {
+		keep[c.Sha512] = true
+	}
+	if err := downloadCache().Prune(keep); err != nil {
+		logger.Noticef("cannot prune download cache: %v", err)
+	}
+}
+
+// offlineCandidate returns the cached refresh of the snap, checking it
+// is new and its snap file is cached.
+func offlineCandidate(st *state.State, name string) (*snap.Info, error) {
+	candidates, err := cachedRefreshCandidates(st)
+	if err != nil {
+		return nil, err
+	}
+	c := candidates[name]
+	if c == nil {
+		return nil, fmt.Errorf("no refresh of snap %q is cached", name)
+	}
+	var snapst SnapState
+	if err := Get(st, name, &snapst); err != nil && err != state.ErrNoState {
+		return nil, err
+	}
+	if err := checkRevisionIsNew(name, &snapst, c.Revision); err != nil {
+		return nil, err
+	}
+	if !downloadCache().Has(c.Sha512) {
+		return nil, fmt.Errorf("revision %s of snap %q was not downloaded", c.Revision, name)
+	}
+	return c.info(), nil
+}
+
+// OfflineRefreshes returns the names of the snaps with a cached refresh
+// that can be applied without network.
+// Note that the state must be locked by the caller.
+func OfflineRefreshes(st *state.State) ([]string, error) {
+	candidates, err := cachedRefreshCandidates(st)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for name := range candidates {
+		if _, err := offlineCandidate(st, name); err != nil {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// UpdateOffline initiates the refreshes of the given snaps, or of all
+// the snaps with one available, to their cached refresh candidates
+// using the snap files already downloaded, without network. A refresh
+// waits for the ones of the snaps it depends on, see WaitForDependencies.
+// Note that the state must be locked by the caller.
+func UpdateOffline(st *state.State, names []string, userID int) ([]string, []*state.TaskSet, error) {
+	if len(names) == 0 {
+		var err error
+		names, err = OfflineRefreshes(st)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(names) == 0 {
+			return nil, nil, fmt.Errorf("no refresh available offline")
+		}
+	}
+
+	tss := make([]*state.TaskSet, 0, len(names))
+	for _, name := range names {
+		var snapst SnapState
+		err := Get(st, name, &snapst)
+		if err != nil && err != state.ErrNoState {
+			return nil, nil, err
+		}
+		if snapst.Current() == nil {
+			return nil, nil, fmt.Errorf("cannot find snap %q", name)
+		}
+		if _, err := offlineCandidate(st, name); err != nil {
+			return nil, nil, err
+		}
+		ts, err := doInstall(st, snapst.Active, name, "", snapst.Channel, snap.Revision{}, userID, Offline)
+		if err != nil {
+			return nil, nil, err
+		}
+		tss = append(tss, ts)
+	}
+	if err := WaitForDependencies(st, names, tss); err != nil {
+		return nil, nil, err
+	}
+	return names, tss, nil
+}
+
+// fetchCachedSnap does the work of download-snap when refreshing
+// offline: it takes the cached refresh candidate of the snap and copies
+// its snap file out of the download cache, checking it against the
+// digest the store gave for it as downloads are.
+func fetchCachedSnap(t *state.Task, ss *SnapSetup, snapst *SnapState, meter progress.Meter) error {
+	st := t.State()
+	st.Lock()
+	info, err := offlineCandidate(st, ss.Name)
+	st.Unlock()
+	if err != nil {
+		return err
+	}
+	if len(info.Architectures) != 0 {
+		if err := checkArchitectures(ss.Name, info.Architectures); err != nil {
+			return err
+		}
+	}
+
+	f, err := ioutil.TempFile("", ss.Name)
+	if err != nil {
+		return err
+	}
+	h := fips.SHA512()
+	err = downloadCache().Fetch(info, io.MultiWriter(f, h), meter)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if digest := hex.EncodeToString(h.Sum(nil)); err == nil && digest != info.Sha512 {
+		err = fmt.Errorf("sha512 mismatch (expected %s, got %s)", info.Sha512, digest)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("cannot fetch snap %q from the download cache: %v", ss.Name, err)
+	}
+
+	ss.SnapPath = f.Name()
+	ss.Revision = info.Revision
+
+	// update the snap setup and state for the follow up tasks
+	st.Lock()
+	t.Set("snap-setup", ss)
+	snapst.Candidate = &info.SideInfo
+	Set(st, ss.Name, snapst)
+	st.Unlock()
+
+	return nil
+}
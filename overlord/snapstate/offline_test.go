@@ -0,0 +1,176 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/snap"
+)
+
+type offlineSuite struct {
+	// not embedded, not to run its tests again
+	mgr snapmgrTestSuite
+
+	digest string
+}
+
+var _ = Suite(&offlineSuite{})
+
+func (s *offlineSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	s.mgr.SetUpTest(c)
+
+	h := sha512.Sum512([]byte("snap content"))
+	s.digest = hex.EncodeToString(h[:])
+
+	s.mgr.state.Lock()
+	defer s.mgr.state.Unlock()
+	snapstate.Set(s.mgr.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Channel:  "stable",
+		Sequence: []*snap.SideInfo{{OfficialName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(7)}},
+	})
+	snapstate.CacheRefreshCandidates(s.mgr.state, []*snap.Info{{
+		SideInfo: snap.SideInfo{OfficialName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(8), Sha512: s.digest},
+	}})
+}
+
+func (s *offlineSuite) TearDownTest(c *C) {
+	s.mgr.TearDownTest(c)
+	dirs.SetRootDir("")
+}
+
+func (s *offlineSuite) downloaded(c *C) {
+	c.Assert(os.MkdirAll(dirs.SnapDownloadCacheDir, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dirs.SnapDownloadCacheDir, s.digest), []byte("snap content"), 0644), IsNil)
+}
+
+func (s *offlineSuite) TestNotDownloaded(c *C) {
+	s.mgr.state.Lock()
+	defer s.mgr.state.Unlock()
+
+	names, err := snapstate.OfflineRefreshes(s.mgr.state)
+	c.Assert(err, IsNil)
+	c.Check(names, HasLen, 0)
+
+	_, _, err = snapstate.UpdateOffline(s.mgr.state, nil, 0)
+	c.Check(err, ErrorMatches, "no refresh available offline")
+	_, _, err = snapstate.UpdateOffline(s.mgr.state, []string{"some-snap"}, 0)
+	c.Check(err, ErrorMatches, `revision 8 of snap "some-snap" was not downloaded`)
+	_, _, err = snapstate.UpdateOffline(s.mgr.state, []string{"other-snap"}, 0)
+	c.Check(err, ErrorMatches, `cannot find snap "other-snap"`)
+}
+
+func (s *offlineSuite) TestAlreadyInstalled(c *C) {
+	s.downloaded(c)
+
+	s.mgr.state.Lock()
+	defer s.mgr.state.Unlock()
+	snapstate.CacheRefreshCandidates(s.mgr.state, []*snap.Info{{
+		SideInfo: snap.SideInfo{OfficialName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(7), Sha512: s.digest},
+	}})
+
+	names, err := snapstate.OfflineRefreshes(s.mgr.state)
+	c.Assert(err, IsNil)
+	c.Check(names, HasLen, 0)
+	_, _, err = snapstate.UpdateOffline(s.mgr.state, []string{"some-snap"}, 0)
+	c.Check(err, ErrorMatches, `revision 7 of snap "some-snap" already installed`)
+}
+
+func (s *offlineSuite) TestUpdateOfflineRunThrough(c *C) {
+	s.downloaded(c)
+
+	s.mgr.state.Lock()
+	defer s.mgr.state.Unlock()
+
+	names, err := snapstate.OfflineRefreshes(s.mgr.state)
+	c.Assert(err, IsNil)
+	c.Check(names, DeepEquals, []string{"some-snap"})
+
+	updated, tss, err := snapstate.UpdateOffline(s.mgr.state, nil, 0)
+	c.Assert(err, IsNil)
+	c.Check(updated, DeepEquals, []string{"some-snap"})
+	chg := s.mgr.state.NewChange("refresh", "refresh offline")
+	for _, ts := range tss {
+		chg.AddAll(ts)
+	}
+	c.Check(chg.Tasks()[0].Summary(), Equals, `Fetch snap "some-snap" from the download cache`)
+
+	s.mgr.state.Unlock()
+	defer s.mgr.snapmgr.Stop()
+	s.mgr.settle()
+	s.mgr.state.Lock()
+
+	c.Assert(chg.Err(), IsNil)
+	var snapPath string
+	for _, op := range s.mgr.fakeBackend.ops {
+		c.Check(op.op, Not(Matches), "storesvc-.*")
+		if op.op == "setup-snap" {
+			snapPath = op.name
+			c.Check(op.revno, Equals, snap.R(8))
+		}
+	}
+	content, err := ioutil.ReadFile(snapPath)
+	c.Assert(err, IsNil)
+	c.Check(string(content), Equals, "snap content")
+	os.Remove(snapPath)
+
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.mgr.state, "some-snap", &snapst), IsNil)
+	c.Check(snapst.Current().Revision, Equals, snap.R(8))
+}
+
+func (s *offlineSuite) TestUpdateOfflineCorruptedCache(c *C) {
+	c.Assert(os.MkdirAll(dirs.SnapDownloadCacheDir, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dirs.SnapDownloadCacheDir, s.digest), []byte("tampered content"), 0644), IsNil)
+
+	s.mgr.state.Lock()
+	defer s.mgr.state.Unlock()
+
+	_, tss, err := snapstate.UpdateOffline(s.mgr.state, []string{"some-snap"}, 0)
+	c.Assert(err, IsNil)
+	chg := s.mgr.state.NewChange("refresh", "refresh offline")
+	for _, ts := range tss {
+		chg.AddAll(ts)
+	}
+
+	s.mgr.state.Unlock()
+	defer s.mgr.snapmgr.Stop()
+	s.mgr.settle()
+	s.mgr.state.Lock()
+
+	c.Assert(chg.Err(), ErrorMatches, `(?s).*cannot fetch snap "some-snap" from the download cache: sha512 mismatch .*`)
+	for _, op := range s.mgr.fakeBackend.ops {
+		c.Check(op.op, Not(Equals), "setup-snap")
+	}
+
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.mgr.state, "some-snap", &snapst), IsNil)
+	c.Check(snapst.Current().Revision, Equals, snap.R(7))
+}
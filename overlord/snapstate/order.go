@@ -0,0 +1,137 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"sort"
+
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+// SnapProviders finds, for each snap with connected plugs, the snaps
+// providing the slots they are connected to, for WaitForDependencies.
+// It is set by the overlord when wiring the managers.
+var SnapProviders func(st *state.State) (map[string]map[string]bool, error)
+
+func snapProviders(st *state.State) (map[string]map[string]bool, error) {
+	if SnapProviders == nil {
+		return nil, nil
+	}
+	return SnapProviders(st)
+}
+
+// isOS returns whether the snap is the OS snap, the one the other snaps
+// run on. A snap not installed yet is only taken for it by its name, as
+// the daemon installs it.
+func isOS(st *state.State, name string) bool {
+	info, err := Current(st, name)
+	if err != nil {
+		return name == "ubuntu-core"
+	}
+	return info.Type == snap.TypeOS
+}
+
+// installOrder orders the snaps so that the OS snap comes first, and a
+// snap after the snaps providing the slots its plugs are connected to.
+// Snaps connected to each other both ways are ordered by name.
+func installOrder(names []string, oses map[string]bool, provs map[string]map[string]bool) []string {
+	left := make(map[string]bool, len(names))
+	for _, name := range names {
+		left[name] = true
+	}
+
+	var order []string
+	for name := range oses {
+		order = append(order, name)
+		delete(left, name)
+	}
+	sort.Strings(order)
+	for len(left) > 0 {
+		var next []string
+		for name := range left {
+			needed := false
+			for prov := range provs[name] {
+				if prov != name && left[prov] {
+					needed = true
+					break
+				}
+			}
+			if !needed {
+				next = append(next, name)
+			}
+		}
+		sort.Strings(next)
+		if len(next) == 0 {
+			// a cycle, break it
+			for name := range left {
+				if len(next) == 0 || name < next[0] {
+					next = []string{name}
+				}
+			}
+		}
+		for _, name := range next {
+			order = append(order, name)
+			delete(left, name)
+		}
+	}
+	return order
+}
+
+// WaitForDependencies makes the given task sets, each installing or
+// refreshing the snap of the same name, wait for the ones of the snaps
+// they depend on among them: the OS snap, which the other snaps run on,
+// and the snaps providing the slots their plugs are connected to, as
+// found with what was set with SetSnapProviders. The task sets of
+// unrelated snaps are left to run in parallel.
+// Note that the state must be locked by the caller.
+func WaitForDependencies(st *state.State, names []string, tss []*state.TaskSet) error {
+	provs, err := snapProviders(st)
+	if err != nil {
+		return err
+	}
+
+	byName := make(map[string]*state.TaskSet, len(names))
+	oses := make(map[string]bool)
+	for i, name := range names {
+		byName[name] = tss[i]
+		if isOS(st, name) {
+			oses[name] = true
+		}
+	}
+	done := make(map[string]bool, len(names))
+	for _, name := range installOrder(names, oses, provs) {
+		ts := byName[name]
+		if !oses[name] {
+			for osName := range oses {
+				ts.WaitAll(byName[osName])
+			}
+		}
+		// only wait for the providers ordered before, which breaks
+		// the cycles the same way installOrder does
+		for prov := range provs[name] {
+			if done[prov] {
+				ts.WaitAll(byName[prov])
+			}
+		}
+		done[name] = true
+	}
+	return nil
+}
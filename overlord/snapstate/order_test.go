@@ -0,0 +1,70 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+type orderSuite struct{}
+
+var _ = Suite(&orderSuite{})
+
+func (s *orderSuite) TestWaitForDependencies(c *C) {
+	st := state.New(nil)
+	// a and b provide slots to each other, c provides one to b and d
+	// is on its own; none is installed yet
+	snapstate.SnapProviders = func(st *state.State) (map[string]map[string]bool, error) {
+		return map[string]map[string]bool{
+			"a": {"b": true},
+			"b": {"a": true, "c": true},
+		}, nil
+	}
+	defer func() { snapstate.SnapProviders = nil }()
+	st.Lock()
+	defer st.Unlock()
+
+	names := []string{"a", "b", "c", "d", "ubuntu-core"}
+	tss := make([]*state.TaskSet, len(names))
+	byName := make(map[string]*state.Task, len(names))
+	for i, name := range names {
+		t := st.NewTask("install", name)
+		tss[i] = state.NewTaskSet(t)
+		byName[name] = t
+	}
+	c.Assert(snapstate.WaitForDependencies(st, names, tss), IsNil)
+
+	waits := func(name string) []string {
+		var waited []string
+		for _, t := range byName[name].WaitTasks() {
+			waited = append(waited, t.Summary())
+		}
+		return waited
+	}
+	c.Check(waits("ubuntu-core"), HasLen, 0)
+	c.Check(waits("c"), DeepEquals, []string{"ubuntu-core"})
+	c.Check(waits("d"), DeepEquals, []string{"ubuntu-core"})
+	// the cycle is broken by name
+	c.Check(waits("a"), DeepEquals, []string{"ubuntu-core"})
+	c.Check(waits("b"), HasLen, 3)
+}
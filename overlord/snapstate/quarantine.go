@@ -0,0 +1,185 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/snap"
+)
+
+// The reasons a snap file is quarantined for.
+const (
+	QuarantineScanRejected = "scan-rejected"
+	QuarantineScanFailed   = "scan-failed"
+	QuarantineCheckFailed  = "check-failed"
+)
+
+// QuarantinedSnap describes a snap file kept in quarantine as its
+// installation was refused, for it to be inspected.
+type QuarantinedSnap struct {
+	ID       string        `json:"id"`
+	Time     time.Time     `json:"time"`
+	Snap     string        `json:"snap"`
+	SnapID   string        `json:"snap-id,omitempty"`
+	Revision snap.Revision `json:"revision"`
+	// Reason classifies why the installation was refused.
+	Reason string `json:"reason"`
+	Error  string `json:"error"`
+	// Origin is where the snap file was installed from, and Path where
+	// it is kept.
+	Origin string `json:"origin"`
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+}
+
+func quarantinedPath(id, ext string) string {
+	return filepath.Join(dirs.SnapQuarantineDir, id+ext)
+}
+
+// quarantineSnap keeps a copy of the snap file whose installation was
+// refused for the given reason, as failing with the given error, rather
+// than leaving it to be removed.
+func quarantineSnap(ss *SnapSetup, si *snap.SideInfo, reason string, cause error) (*QuarantinedSnap, error) {
+	fi, err := os.Stat(ss.SnapPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot quarantine snap %q: %v", ss.Name, err)
+	}
+	if !fi.Mode().IsRegular() {
+		// snaps being tried are directories left alone
+		return nil, nil
+	}
+	now := timeNow()
+	q := &QuarantinedSnap{
+		ID:       fmt.Sprintf("%d", now.UnixNano()),
+		Time:     now,
+		Snap:     ss.Name,
+		Revision: ss.Revision,
+		Reason:   reason,
+		Error:    cause.Error(),
+		Origin:   ss.SnapPath,
+		Size:     fi.Size(),
+	}
+	if si != nil {
+		q.SnapID = si.SnapID
+	}
+	q.Path = quarantinedPath(q.ID, ".snap")
+
+	if err := os.MkdirAll(dirs.SnapQuarantineDir, 0700); err != nil {
+		return nil, fmt.Errorf("cannot quarantine snap %q: %v", ss.Name, err)
+	}
+	if err := osutil.CopyFile(ss.SnapPath, q.Path, osutil.CopyFlagSync); err != nil {
+		return nil, fmt.Errorf("cannot quarantine snap %q: %v", ss.Name, err)
+	}
+	data, err := json.Marshal(q)
+	if err == nil {
+		err = osutil.AtomicWriteFile(quarantinedPath(q.ID, ".json"), data, 0600, 0)
+	}
+	if err != nil {
+		os.Remove(q.Path)
+		return nil, fmt.Errorf("cannot quarantine snap %q: %v", ss.Name, err)
+	}
+	return q, nil
+}
+
+// quarantineRefused quarantines the snap file whose installation was
+// refused, only logging failures not to hide why it was refused.
+func quarantineRefused(ss *SnapSetup, si *snap.SideInfo, reason string, cause error) {
+	q, err := quarantineSnap(ss, si, reason, cause)
+	if err != nil {
+		logger.Noticef("%v", err)
+		return
+	}
+	if q != nil {
+		logger.Noticef("Quarantined snap %q as %s: %v", q.Snap, q.ID, cause)
+	}
+}
+
+type quarantinedByTime []*QuarantinedSnap
+
+func (qs quarantinedByTime) Len() int           { return len(qs) }
+func (qs quarantinedByTime) Less(i, j int) bool { return qs[i].Time.Before(qs[j].Time) }
+func (qs quarantinedByTime) Swap(i, j int)      { qs[i], qs[j] = qs[j], qs[i] }
+
+// Quarantined returns the snap files kept in quarantine, oldest first.
+func Quarantined() ([]*QuarantinedSnap, error) {
+	matches, err := filepath.Glob(quarantinedPath("*", ".json"))
+	if err != nil {
+		return nil, err
+	}
+	quarantined := make([]*QuarantinedSnap, 0, len(matches))
+	for _, path := range matches {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read quarantined snap: %v", err)
+		}
+		var q QuarantinedSnap
+		if err := json.Unmarshal(data, &q); err != nil {
+			return nil, fmt.Errorf("cannot read quarantined snap %q: %v", filepath.Base(path), err)
+		}
+		quarantined = append(quarantined, &q)
+	}
+	sort.Sort(quarantinedByTime(quarantined))
+	return quarantined, nil
+}
+
+// PurgeQuarantine removes the snap files with the given ids from the
+// quarantine, or all of them if no id is given, returning the ones
+// removed.
+func PurgeQuarantine(ids []string) ([]*QuarantinedSnap, error) {
+	quarantined, err := Quarantined()
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*QuarantinedSnap, len(quarantined))
+	for _, q := range quarantined {
+		byID[q.ID] = q
+	}
+	if len(ids) == 0 {
+		for _, q := range quarantined {
+			ids = append(ids, q.ID)
+		}
+	}
+
+	purged := make([]*QuarantinedSnap, 0, len(ids))
+	for _, id := range ids {
+		q, ok := byID[id]
+		if !ok {
+			return purged, fmt.Errorf("cannot find quarantined snap %q", id)
+		}
+		if err := os.Remove(quarantinedPath(id, ".snap")); err != nil && !os.IsNotExist(err) {
+			return purged, fmt.Errorf("cannot purge quarantined snap %q: %v", id, err)
+		}
+		if err := os.Remove(quarantinedPath(id, ".json")); err != nil {
+			return purged, fmt.Errorf("cannot purge quarantined snap %q: %v", id, err)
+		}
+		purged = append(purged, q)
+	}
+	return purged, nil
+}
@@ -0,0 +1,126 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/snap"
+)
+
+type quarantineSuite struct {
+	snapPath string
+}
+
+var _ = Suite(&quarantineSuite{})
+
+func (s *quarantineSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	s.snapPath = filepath.Join(c.MkDir(), "foo.snap")
+	c.Assert(ioutil.WriteFile(s.snapPath, []byte("squashfs"), 0644), IsNil)
+}
+
+func (s *quarantineSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("")
+}
+
+func (s *quarantineSuite) quarantine(c *C, when time.Time, name string) *snapstate.QuarantinedSnap {
+	defer snapstate.MockTimeNow(func() time.Time { return when })()
+	ss := &snapstate.SnapSetup{Name: name, Revision: snap.R(7), SnapPath: s.snapPath}
+	si := &snap.SideInfo{OfficialName: name, SnapID: name + "-id", Revision: snap.R(7)}
+	q, err := snapstate.QuarantineSnap(ss, si, snapstate.QuarantineScanRejected, errors.New("Eicar-Test-Signature FOUND"))
+	c.Assert(err, IsNil)
+	return q
+}
+
+func (s *quarantineSuite) TestQuarantine(c *C) {
+	t1 := time.Date(2016, 10, 1, 12, 0, 0, 0, time.UTC)
+	t0 := t1.Add(-time.Hour)
+	q1 := s.quarantine(c, t1, "foo")
+	q0 := s.quarantine(c, t0, "bar")
+
+	c.Check(q1, DeepEquals, &snapstate.QuarantinedSnap{
+		ID:       "1475323200000000000",
+		Time:     t1,
+		Snap:     "foo",
+		SnapID:   "foo-id",
+		Revision: snap.R(7),
+		Reason:   "scan-rejected",
+		Error:    "Eicar-Test-Signature FOUND",
+		Origin:   s.snapPath,
+		Path:     filepath.Join(dirs.SnapQuarantineDir, "1475323200000000000.snap"),
+		Size:     8,
+	})
+	data, err := ioutil.ReadFile(q1.Path)
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, "squashfs")
+
+	quarantined, err := snapstate.Quarantined()
+	c.Assert(err, IsNil)
+	c.Assert(quarantined, HasLen, 2)
+	c.Check(quarantined[0].ID, Equals, q0.ID)
+	c.Check(quarantined[1].ID, Equals, q1.ID)
+	c.Check(quarantined[1].Time.Equal(t1), Equals, true)
+	c.Check(quarantined[1].Snap, Equals, "foo")
+}
+
+func (s *quarantineSuite) TestQuarantineDirectory(c *C) {
+	ss := &snapstate.SnapSetup{Name: "foo", SnapPath: c.MkDir()}
+	q, err := snapstate.QuarantineSnap(ss, nil, snapstate.QuarantineCheckFailed, errors.New("boom"))
+	c.Assert(err, IsNil)
+	c.Check(q, IsNil)
+
+	quarantined, err := snapstate.Quarantined()
+	c.Assert(err, IsNil)
+	c.Check(quarantined, HasLen, 0)
+}
+
+func (s *quarantineSuite) TestPurgeQuarantine(c *C) {
+	t := time.Date(2016, 10, 1, 12, 0, 0, 0, time.UTC)
+	q0 := s.quarantine(c, t, "foo")
+	q1 := s.quarantine(c, t.Add(time.Hour), "bar")
+	q2 := s.quarantine(c, t.Add(2*time.Hour), "baz")
+
+	purged, err := snapstate.PurgeQuarantine([]string{q1.ID})
+	c.Assert(err, IsNil)
+	c.Assert(purged, HasLen, 1)
+	c.Check(purged[0].Snap, Equals, "bar")
+	c.Check(osutil.FileExists(q1.Path), Equals, false)
+
+	_, err = snapstate.PurgeQuarantine([]string{q1.ID})
+	c.Check(err, ErrorMatches, `cannot find quarantined snap "1475326800000000000"`)
+
+	purged, err = snapstate.PurgeQuarantine(nil)
+	c.Assert(err, IsNil)
+	c.Assert(purged, HasLen, 2)
+	c.Check(purged[0].ID, Equals, q0.ID)
+	c.Check(purged[1].ID, Equals, q2.ID)
+	matches, err := filepath.Glob(filepath.Join(dirs.SnapQuarantineDir, "*"))
+	c.Assert(err, IsNil)
+	c.Check(matches, HasLen, 0)
+}
@@ -0,0 +1,180 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"fmt"
+
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+// readOnlyDataWanted returns whether the user asked for the system data
+// of the snap to be read-only, with readonly-data.<snap>=true.
+func readOnlyDataWanted(st *state.State, snapName string) bool {
+	var readOnly bool
+	configstate.Get(st, configstate.CoreSnapName, "readonly-data."+snapName, &readOnly)
+	return readOnly
+}
+
+// setDataMode returns the tasks sealing the system data of the current
+// revision of the snap read-only, or making it writable again.
+func setDataMode(st *state.State, snapName string, snapst *SnapState, readOnly bool) *state.TaskSet {
+	ss := SnapSetup{
+		Name:     snapName,
+		Revision: snapst.Current().Revision,
+	}
+	if snapst.DevMode() {
+		ss.Flags |= SnapSetupFlags(DevMode)
+	}
+
+	msg := i18n.G("Make data of snap %q writable")
+	if readOnly {
+		msg = i18n.G("Make data of snap %q read-only")
+	}
+	setMode := st.NewTask("set-data-mode", fmt.Sprintf(msg, snapName))
+	setMode.Set("snap-setup", ss)
+	setMode.Set("readonly", readOnly)
+
+	// the security profiles deny writing the sealed data too
+	setupSecurity := st.NewTask("setup-profiles", fmt.Sprintf(i18n.G("Setup snap %q security profiles"), snapName))
+	setupSecurity.Set("snap-setup-task", setMode.ID())
+	setupSecurity.WaitFor(setMode)
+
+	return state.NewTaskSet(setMode, setupSecurity)
+}
+
+// ensureReadOnlyData seals the system data of the snaps the user asked
+// for read-only, and makes it writable again once they no longer do.
+// The data of a revision is only sealed once the change installing or
+// refreshing the snap is done, so the revision can initialize it.
+// Note that the state must be locked by the caller.
+func (m *SnapManager) ensureReadOnlyData() error {
+	st := m.state
+	snapStates, err := All(st)
+	if err != nil {
+		return err
+	}
+	for name, snapst := range snapStates {
+		current := snapst.Current()
+		if !snapst.Active || current == nil {
+			continue
+		}
+		readOnly := readOnlyDataWanted(st, name)
+		want := snap.Revision{}
+		if readOnly {
+			want = current.Revision
+		}
+		if snapst.ReadOnlyData == want {
+			continue
+		}
+		if err := checkChangeConflict(st, name); err != nil {
+			// done once the snap is no longer busy
+			continue
+		}
+		ts := setDataMode(st, name, snapst, readOnly)
+		chg := st.NewChange("set-data-mode", ts.Tasks()[0].Summary())
+		chg.AddAll(ts)
+		logger.Noticef("%s", chg.Summary())
+		st.EnsureBefore(0)
+	}
+	return nil
+}
+
+// dataInfo returns the minimal info about the given revision of the
+// snap locating its data, which may be gone from the sequence.
+func dataInfo(snapName string, revision snap.Revision) *snap.Info {
+	return &snap.Info{SideInfo: snap.SideInfo{OfficialName: snapName, Revision: revision}}
+}
+
+// changeDataMode seals the system data of the given revision of the
+// snap read-only, or none if it is unset, unsealing the one of the
+// revision sealed before, if any.
+func (m *SnapManager) changeDataMode(t *state.Task, snapName string, sealed, revision snap.Revision) error {
+	pb := &TaskProgressAdapter{task: t}
+	if sealed == revision {
+		return nil
+	}
+	if !sealed.Unset() {
+		if err := m.backend.UnsealSnapData(dataInfo(snapName, sealed), pb); err != nil {
+			return err
+		}
+	}
+	if !revision.Unset() {
+		if err := m.backend.SealSnapData(dataInfo(snapName, revision), pb); err != nil {
+			return err
+		}
+	}
+
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+	var snapst SnapState
+	if err := Get(st, snapName, &snapst); err != nil {
+		return err
+	}
+	snapst.ReadOnlyData = revision
+	Set(st, snapName, &snapst)
+	return nil
+}
+
+func (m *SnapManager) doSetDataMode(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	ss, snapst, err := snapSetupAndState(t)
+	var readOnly bool
+	if err == nil {
+		err = t.Get("readonly", &readOnly)
+	}
+	if err == nil {
+		t.Set("old-readonly-data", snapst.ReadOnlyData)
+	}
+	st.Unlock()
+	if err != nil {
+		return err
+	}
+
+	revision := snap.Revision{}
+	if readOnly {
+		revision = ss.Revision
+	}
+	return m.changeDataMode(t, ss.Name, snapst.ReadOnlyData, revision)
+}
+
+func (m *SnapManager) undoSetDataMode(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	ss, snapst, err := snapSetupAndState(t)
+	var old snap.Revision
+	if err == nil {
+		err = t.Get("old-readonly-data", &old)
+	}
+	st.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return m.changeDataMode(t, ss.Name, snapst.ReadOnlyData, old)
+}
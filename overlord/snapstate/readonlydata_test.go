@@ -0,0 +1,132 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/configstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+func (s *snapmgrTestSuite) readOnlyDataOps() []fakeOp {
+	var ops []fakeOp
+	for _, op := range s.fakeBackend.ops {
+		switch op.op {
+		case "seal-snap-data", "unseal-snap-data", "setup-profiles:Doing":
+			ops = append(ops, op)
+		}
+	}
+	s.fakeBackend.ops = nil
+	return ops
+}
+
+func (s *snapmgrTestSuite) TestReadOnlyData(c *C) {
+	dataDir := func(rev string) string {
+		return filepath.Join(dirs.SnapDataDir, "foo", rev)
+	}
+
+	s.state.Lock()
+	snapst := &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{OfficialName: "foo", Revision: snap.R(7)}},
+	}
+	snapstate.Set(s.state, "foo", snapst)
+	c.Assert(configstate.Set(s.state, "core", "readonly-data.foo", true), IsNil)
+	s.state.Unlock()
+
+	s.settle()
+
+	s.state.Lock()
+	c.Assert(s.state.Changes(), HasLen, 1)
+	chg := s.state.Changes()[0]
+	c.Check(chg.Kind(), Equals, "set-data-mode")
+	c.Check(chg.Summary(), Equals, `Make data of snap "foo" read-only`)
+	c.Check(chg.Status(), Equals, state.DoneStatus)
+	c.Assert(snapstate.Get(s.state, "foo", snapst), IsNil)
+	c.Check(snapst.ReadOnlyData, Equals, snap.R(7))
+	c.Check(s.readOnlyDataOps(), DeepEquals, []fakeOp{
+		{op: "seal-snap-data", name: dataDir("7")},
+		{op: "setup-profiles:Doing", name: "foo", revno: snap.R(7)},
+	})
+
+	// once refreshed the new revision is sealed instead
+	snapst.Sequence = append(snapst.Sequence, &snap.SideInfo{OfficialName: "foo", Revision: snap.R(8)})
+	snapstate.Set(s.state, "foo", snapst)
+	s.state.Unlock()
+
+	s.settle()
+
+	s.state.Lock()
+	c.Assert(snapstate.Get(s.state, "foo", snapst), IsNil)
+	c.Check(snapst.ReadOnlyData, Equals, snap.R(8))
+	c.Check(s.readOnlyDataOps(), DeepEquals, []fakeOp{
+		{op: "unseal-snap-data", name: dataDir("7")},
+		{op: "seal-snap-data", name: dataDir("8")},
+		{op: "setup-profiles:Doing", name: "foo", revno: snap.R(8)},
+	})
+
+	// and made writable again when asked
+	c.Assert(configstate.Set(s.state, "core", "readonly-data.foo", false), IsNil)
+	s.state.Unlock()
+
+	s.settle()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Assert(snapstate.Get(s.state, "foo", snapst), IsNil)
+	c.Check(snapst.ReadOnlyData.Unset(), Equals, true)
+	c.Check(s.readOnlyDataOps(), DeepEquals, []fakeOp{
+		{op: "unseal-snap-data", name: dataDir("8")},
+		{op: "setup-profiles:Doing", name: "foo", revno: snap.R(8)},
+	})
+	c.Check(s.state.Changes(), HasLen, 3)
+}
+
+func (s *snapmgrTestSuite) TestReadOnlyDataWaitsForChanges(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "foo", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{OfficialName: "foo", Revision: snap.R(7)}},
+	})
+	c.Assert(configstate.Set(s.state, "core", "readonly-data.foo", true), IsNil)
+	chg := s.state.NewChange("refresh-snap", "...")
+	t := s.state.NewTask("link-snap", "...")
+	t.Set("snap-setup", &snapstate.SnapSetup{Name: "foo", Revision: snap.R(8)})
+	chg.AddTask(t)
+
+	c.Assert(s.snapmgr.EnsureReadOnlyData(), IsNil)
+	c.Check(s.state.Changes(), HasLen, 1)
+
+	t.SetStatus(state.DoneStatus)
+	c.Assert(s.snapmgr.EnsureReadOnlyData(), IsNil)
+	c.Assert(s.state.Changes(), HasLen, 2)
+
+	// only once
+	c.Assert(s.snapmgr.EnsureReadOnlyData(), IsNil)
+	c.Check(s.state.Changes(), HasLen, 2)
+}
@@ -0,0 +1,275 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/configstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/snap"
+)
+
+// NeedsReboot returns whether the device needs to reboot for the given
+// snap, once linked, to be fully in use.
+func NeedsReboot(info *snap.Info) bool {
+	switch info.Type {
+	case snap.TypeKernel, snap.TypeGadget:
+		// the new kernel or gadget boot assets are only used
+		// from the next boot
+		return true
+	case snap.TypeOS:
+		// on classic only snapd needs restarting, which is done
+		// right away
+		return !release.OnClassic
+	}
+	return false
+}
+
+// rebootRequiredState is what is kept in the state about the snaps
+// waiting for a reboot.
+type rebootRequiredState struct {
+	Snaps  []string `json:"snaps"`
+	BootID string   `json:"boot-id"`
+
+	// RebootAt is when the device is due to reboot for the snaps.
+	RebootAt time.Time `json:"reboot-at,omitempty"`
+	// RequestedAt is when the reboot was last asked for.
+	RequestedAt time.Time `json:"requested-at,omitempty"`
+}
+
+// bootID returns the identity of the current boot.
+func bootID() string {
+	data, err := ioutil.ReadFile(filepath.Join(dirs.GlobalRootDir, "/proc/sys/kernel/random/boot_id"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func getRebootRequired(st *state.State) (*rebootRequiredState, error) {
+	var rr rebootRequiredState
+	err := st.Get("reboot-required", &rr)
+	if err == state.ErrNoState {
+		return &rr, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if rr.BootID != bootID() {
+		// the device rebooted since
+		return &rebootRequiredState{}, nil
+	}
+	return &rr, nil
+}
+
+// setRebootRequired records that the device needs to reboot for the
+// given snap to be fully in use, and raises the flags distro tooling
+// looks at to tell the user.
+func setRebootRequired(st *state.State, snapName string) error {
+	rr, err := getRebootRequired(st)
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, name := range rr.Snaps {
+		if name == snapName {
+			found = true
+			break
+		}
+	}
+	if !found {
+		rr.Snaps = append(rr.Snaps, snapName)
+		sort.Strings(rr.Snaps)
+		queueNotification(st, "reboot-required", "", fmt.Sprintf("Reboot required for %s.", strings.Join(rr.Snaps, ", ")), map[string]interface{}{
+			"snaps": rr.Snaps,
+		})
+	}
+	rr.BootID = bootID()
+	st.Set("reboot-required", rr)
+
+	return writeRebootRequired(rr.Snaps)
+}
+
+func writeRebootRequired(snaps []string) error {
+	if err := os.MkdirAll(filepath.Dir(dirs.SnapRebootRequiredFile), 0755); err != nil {
+		return err
+	}
+	if err := osutil.AtomicWriteFile(dirs.SnapRebootRequiredFile+".pkgs", []byte(strings.Join(snaps, "\n")+"\n"), 0644, 0); err != nil {
+		return err
+	}
+	return osutil.AtomicWriteFile(dirs.SnapRebootRequiredFile, []byte("*** System restart required ***\n"), 0644, 0)
+}
+
+// RebootRequired returns the snaps the device needs to reboot for to
+// fully use, sorted by name. It is empty once the device rebooted.
+func RebootRequired(st *state.State) ([]string, error) {
+	rr, err := getRebootRequired(st)
+	if err != nil {
+		return nil, err
+	}
+	return rr.Snaps, nil
+}
+
+// RebootTime returns when the device is due to reboot for the snaps
+// waiting for it, or the zero time if none are or it is not known yet.
+func RebootTime(st *state.State) (time.Time, error) {
+	rr, err := getRebootRequired(st)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if len(rr.Snaps) == 0 {
+		return time.Time{}, nil
+	}
+	return rr.RebootAt, nil
+}
+
+// RebootNow asks for the device to reboot right away for the snaps
+// waiting for it, whatever the windows of reboot.schedule.
+func RebootNow(st *state.State) error {
+	rr, err := getRebootRequired(st)
+	if err != nil {
+		return err
+	}
+	if len(rr.Snaps) == 0 {
+		return fmt.Errorf("no reboot is pending")
+	}
+	logger.Noticef("Rebooting for %s as asked.", strings.Join(rr.Snaps, ", "))
+	rr.RebootAt = timeNow()
+	rr.RequestedAt = rr.RebootAt
+	st.Set("reboot-required", rr)
+	st.RequestRestart(state.RestartSystem)
+	return nil
+}
+
+// rebootRetryDelay is how long the device is given to reboot once
+// asked to, before asking again.
+var rebootRetryDelay = 10 * time.Minute
+
+func rebootWindows(st *state.State) []*configstate.RebootWindow {
+	var schedule string
+	if err := configstate.Get(st, configstate.CoreSnapName, "reboot.schedule", &schedule); err != nil {
+		return nil
+	}
+	windows, err := configstate.ParseRebootSchedule(schedule)
+	if err != nil {
+		logger.Noticef("cannot use the reboot schedule: %v", err)
+		return nil
+	}
+	return windows
+}
+
+func startsOn(w *configstate.RebootWindow, day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// nextReboot returns the first time from t on that is within one of
+// the windows, or t itself if there are no windows.
+func nextReboot(windows []*configstate.RebootWindow, t time.Time) time.Time {
+	if len(windows) == 0 {
+		return t
+	}
+	var next time.Time
+	// a window started the day before can still be open
+	for day := -1; day <= 7; day++ {
+		midnight := time.Date(t.Year(), t.Month(), t.Day()+day, 0, 0, 0, 0, t.Location())
+		for _, w := range windows {
+			if !startsOn(w, midnight.Weekday()) {
+				continue
+			}
+			start := midnight.Add(w.Start)
+			end := midnight.Add(w.End)
+			if w.End < w.Start {
+				end = end.Add(24 * time.Hour)
+			}
+			if !t.Before(start) && t.Before(end) {
+				return t
+			}
+			if start.After(t) && (next.IsZero() || start.Before(next)) {
+				next = start
+			}
+		}
+	}
+	return next
+}
+
+// ensureReboot reboots the device for the snaps waiting for it, once
+// no change is in progress anymore, within the windows of
+// reboot.schedule if set. Classic systems are left for their users to
+// reboot.
+func (m *SnapManager) ensureReboot() error {
+	if release.OnClassic {
+		return nil
+	}
+	st := m.state
+	rr, err := getRebootRequired(st)
+	if err != nil {
+		return err
+	}
+	if len(rr.Snaps) == 0 {
+		return nil
+	}
+	for _, chg := range st.Changes() {
+		if !chg.Status().Ready() {
+			return nil
+		}
+	}
+
+	now := timeNow()
+	from := now
+	if retry := rr.RequestedAt.Add(rebootRetryDelay); !rr.RequestedAt.IsZero() && retry.After(now) {
+		from = retry
+	}
+	at := nextReboot(rebootWindows(st), from)
+	if !at.Equal(rr.RebootAt) {
+		if at.After(now) {
+			logger.Noticef("Reboot for %s deferred to %s.", strings.Join(rr.Snaps, ", "), at.Format(time.RFC1123))
+		}
+		rr.RebootAt = at
+		st.Set("reboot-required", rr)
+	}
+	if now.Before(at) {
+		return nil
+	}
+
+	logger.Noticef("Rebooting for %s.", strings.Join(rr.Snaps, ", "))
+	rr.RequestedAt = now
+	st.Set("reboot-required", rr)
+	st.RequestRestart(state.RestartSystem)
+	return nil
+}
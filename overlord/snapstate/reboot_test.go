@@ -0,0 +1,189 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/configstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/release"
+)
+
+type rebootSuite struct {
+	state   *state.State
+	backend *witnessRestartReqStateBackend
+	snapmgr *snapstate.SnapManager
+
+	now     time.Time
+	restore []func()
+}
+
+var _ = Suite(&rebootSuite{})
+
+func (s *rebootSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	s.backend = &witnessRestartReqStateBackend{}
+	s.state = state.New(s.backend)
+
+	var err error
+	s.snapmgr, err = snapstate.Manager(s.state)
+	c.Assert(err, IsNil)
+
+	// a monday
+	s.now = time.Date(2016, 8, 1, 12, 0, 0, 0, time.UTC)
+	s.restore = []func(){
+		snapstate.MockTimeNow(func() time.Time { return s.now }),
+		release.MockOnClassic(false),
+	}
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.state.Set("reboot-required", map[string]interface{}{
+		"snaps":   []string{"pc-kernel"},
+		"boot-id": "",
+	})
+}
+
+func (s *rebootSuite) TearDownTest(c *C) {
+	for _, restore := range s.restore {
+		restore()
+	}
+	dirs.SetRootDir("")
+}
+
+func (s *rebootSuite) ensure(c *C) {
+	c.Assert(s.snapmgr.Ensure(), IsNil)
+	s.snapmgr.Wait()
+}
+
+func (s *rebootSuite) rebootTime(c *C) time.Time {
+	s.state.Lock()
+	defer s.state.Unlock()
+	at, err := snapstate.RebootTime(s.state)
+	c.Assert(err, IsNil)
+	return at
+}
+
+func (s *rebootSuite) TestNextReboot(c *C) {
+	t := time.Date(2016, 8, 1, 12, 0, 0, 0, time.UTC)
+
+	c.Check(snapstate.NextReboot(nil, t), Equals, t)
+
+	for _, tc := range []struct {
+		schedule string
+		next     time.Time
+	}{
+		// within the window
+		{"11:00-13:00", t},
+		{"mon,11:00-13:00", t},
+		// later today
+		{"14:00-15:00", time.Date(2016, 8, 1, 14, 0, 0, 0, time.UTC)},
+		// tomorrow
+		{"10:00-11:00", time.Date(2016, 8, 2, 10, 0, 0, 0, time.UTC)},
+		// next week
+		{"mon,10:00-11:00", time.Date(2016, 8, 8, 10, 0, 0, 0, time.UTC)},
+		{"sun,03:00-05:00", time.Date(2016, 8, 7, 3, 0, 0, 0, time.UTC)},
+		// the earliest window
+		{"sun,03:00-05:00/wed,01:00-02:00", time.Date(2016, 8, 3, 1, 0, 0, 0, time.UTC)},
+		// still open from the day before
+		{"sun,22:00-13:00", t},
+		{"sun,22:00-11:00", time.Date(2016, 8, 7, 22, 0, 0, 0, time.UTC)},
+	} {
+		windows, err := configstate.ParseRebootSchedule(tc.schedule)
+		c.Assert(err, IsNil)
+		c.Check(snapstate.NextReboot(windows, t), Equals, tc.next, Commentf(tc.schedule))
+	}
+}
+
+func (s *rebootSuite) TestEnsureRebootsRightAway(c *C) {
+	s.ensure(c)
+	c.Check(s.backend.restartRequested, DeepEquals, []state.RestartType{state.RestartSystem})
+
+	// the device is given time to reboot
+	s.now = s.now.Add(5 * time.Minute)
+	s.ensure(c)
+	c.Check(s.backend.restartRequested, HasLen, 1)
+
+	// before being asked again
+	s.now = s.now.Add(6 * time.Minute)
+	s.ensure(c)
+	c.Check(s.backend.restartRequested, HasLen, 2)
+}
+
+func (s *rebootSuite) TestEnsureDefersRebootToWindow(c *C) {
+	s.state.Lock()
+	c.Assert(configstate.Set(s.state, "core", "reboot.schedule", "sun,03:00-05:00"), IsNil)
+	s.state.Unlock()
+
+	s.ensure(c)
+	c.Check(s.backend.restartRequested, HasLen, 0)
+	c.Check(s.rebootTime(c), Equals, time.Date(2016, 8, 7, 3, 0, 0, 0, time.UTC))
+
+	s.now = time.Date(2016, 8, 7, 3, 1, 0, 0, time.UTC)
+	s.ensure(c)
+	c.Check(s.backend.restartRequested, DeepEquals, []state.RestartType{state.RestartSystem})
+}
+
+func (s *rebootSuite) TestEnsureRebootWaitsForChanges(c *C) {
+	s.state.Lock()
+	chg := s.state.NewChange("install-snap", "...")
+	chg.AddTask(s.state.NewTask("prerequisite", "..."))
+	s.state.Unlock()
+
+	s.ensure(c)
+	c.Check(s.backend.restartRequested, HasLen, 0)
+}
+
+func (s *rebootSuite) TestEnsureNoRebootOnClassic(c *C) {
+	restore := release.MockOnClassic(true)
+	defer restore()
+
+	s.ensure(c)
+	c.Check(s.backend.restartRequested, HasLen, 0)
+}
+
+func (s *rebootSuite) TestEnsureNoRebootAfterRebooting(c *C) {
+	s.state.Lock()
+	s.state.Set("reboot-required", map[string]interface{}{
+		"snaps":   []string{"pc-kernel"},
+		"boot-id": "some-previous-boot",
+	})
+	s.state.Unlock()
+
+	s.ensure(c)
+	c.Check(s.backend.restartRequested, HasLen, 0)
+}
+
+func (s *rebootSuite) TestRebootNow(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Assert(configstate.Set(s.state, "core", "reboot.schedule", "sun,03:00-05:00"), IsNil)
+
+	c.Assert(snapstate.RebootNow(s.state), IsNil)
+	c.Check(s.backend.restartRequested, DeepEquals, []state.RestartType{state.RestartSystem})
+
+	s.state.Set("reboot-required", nil)
+	c.Check(snapstate.RebootNow(s.state), ErrorMatches, "no reboot is pending")
+}
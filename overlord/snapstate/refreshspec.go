@@ -0,0 +1,119 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/snap"
+)
+
+// A refresh spec pins snaps to exact revisions, e.g.:
+//
+//	snaps:
+//	  ubuntu-core: 122
+//	  hello-world: 27
+type refreshSpec struct {
+	Snaps map[string]string `yaml:"snaps"`
+}
+
+// verifyRefreshSpecSignature checks the detached signature of a refresh
+// spec against the given keyring of the device owner only, so that a
+// spec signed by any other key gpg knows about is refused.
+var verifyRefreshSpecSignature = func(specPath, sigPath, keyring string) error {
+	var errBuf bytes.Buffer
+	gpg := exec.Command("gpg", "-q", "--batch", "--no-default-keyring", "--keyring", keyring, "--verify", sigPath, specPath)
+	gpg.Stderr = &errBuf
+	if err := gpg.Run(); err != nil {
+		return fmt.Errorf("%v (%q)", err, errBuf.Bytes())
+	}
+	return nil
+}
+
+func checkRefreshSpecSignature(spec, sig []byte) error {
+	if !osutil.FileExists(dirs.SnapRefreshSpecKeyring) {
+		return fmt.Errorf("no trusted keyring %s", dirs.SnapRefreshSpecKeyring)
+	}
+
+	tmpdir, err := ioutil.TempDir("", "refresh-spec")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpdir)
+
+	specPath := filepath.Join(tmpdir, "spec.yaml")
+	sigPath := specPath + ".sig"
+	if err := ioutil.WriteFile(specPath, spec, 0600); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(sigPath, sig, 0600); err != nil {
+		return err
+	}
+	return verifyRefreshSpecSignature(specPath, sigPath, dirs.SnapRefreshSpecKeyring)
+}
+
+// ReadRefreshSpec returns the revisions the given refresh spec pins the
+// snaps to, after checking its detached signature against the keyring
+// of the device owner. A spec without a signature is only accepted if
+// allowUnsigned is set and the device owner has no keyring, as a
+// device with an owner only takes revisions from them.
+func ReadRefreshSpec(spec, sig []byte, allowUnsigned bool) (map[string]snap.Revision, error) {
+	switch {
+	case len(sig) > 0:
+		if err := checkRefreshSpecSignature(spec, sig); err != nil {
+			return nil, fmt.Errorf("cannot verify refresh spec signature: %v", err)
+		}
+	case !allowUnsigned:
+		return nil, fmt.Errorf("cannot use refresh spec: missing signature")
+	case osutil.FileExists(dirs.SnapRefreshSpecKeyring):
+		return nil, fmt.Errorf("cannot use refresh spec: missing signature, required by the keyring of the device owner")
+	}
+
+	var rs refreshSpec
+	if err := yaml.Unmarshal(spec, &rs); err != nil {
+		return nil, fmt.Errorf("cannot parse refresh spec: %v", err)
+	}
+	if len(rs.Snaps) == 0 {
+		return nil, fmt.Errorf("refresh spec lists no snaps")
+	}
+
+	revisions := make(map[string]snap.Revision, len(rs.Snaps))
+	for name, rev := range rs.Snaps {
+		if err := snap.ValidateName(name); err != nil {
+			return nil, fmt.Errorf("invalid refresh spec: %v", err)
+		}
+		revision, err := snap.ParseRevision(rev)
+		if err != nil || !revision.Store() {
+			return nil, fmt.Errorf("invalid refresh spec: invalid revision %q for snap %q", rev, name)
+		}
+		revisions[name] = revision
+	}
+
+	return revisions, nil
+}
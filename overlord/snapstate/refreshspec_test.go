@@ -0,0 +1,134 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type refreshSpecSuite struct{}
+
+var _ = Suite(&refreshSpecSuite{})
+
+const refreshSpecYaml = `snaps:
+  foo: 12
+  ubuntu-core: 100
+`
+
+func (s *refreshSpecSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+}
+
+func (s *refreshSpecSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("")
+}
+
+func (s *refreshSpecSuite) mockKeyring(c *C) {
+	c.Assert(os.MkdirAll(filepath.Dir(dirs.SnapRefreshSpecKeyring), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(dirs.SnapRefreshSpecKeyring, nil, 0644), IsNil)
+}
+
+func (s *refreshSpecSuite) TestSigned(c *C) {
+	s.mockKeyring(c)
+
+	verified := false
+	restore := snapstate.MockVerifyRefreshSpecSignature(func(specPath, sigPath, keyring string) error {
+		spec, err := ioutil.ReadFile(specPath)
+		c.Assert(err, IsNil)
+		c.Check(string(spec), Equals, refreshSpecYaml)
+		sig, err := ioutil.ReadFile(sigPath)
+		c.Assert(err, IsNil)
+		c.Check(string(sig), Equals, "sig")
+		c.Check(keyring, Equals, dirs.SnapRefreshSpecKeyring)
+		verified = true
+		return nil
+	})
+	defer restore()
+
+	revisions, err := snapstate.ReadRefreshSpec([]byte(refreshSpecYaml), []byte("sig"), false)
+	c.Assert(err, IsNil)
+	c.Check(verified, Equals, true)
+	c.Check(revisions, DeepEquals, map[string]snap.Revision{
+		"foo":         snap.R(12),
+		"ubuntu-core": snap.R(100),
+	})
+}
+
+func (s *refreshSpecSuite) TestBadSignature(c *C) {
+	s.mockKeyring(c)
+
+	restore := snapstate.MockVerifyRefreshSpecSignature(func(specPath, sigPath, keyring string) error {
+		return errors.New("BAD signature")
+	})
+	defer restore()
+
+	_, err := snapstate.ReadRefreshSpec([]byte(refreshSpecYaml), []byte("sig"), true)
+	c.Assert(err, ErrorMatches, "cannot verify refresh spec signature: BAD signature")
+}
+
+func (s *refreshSpecSuite) TestSignedWithoutKeyring(c *C) {
+	gpg := testutil.MockCommand(c, "gpg", "")
+	defer gpg.Restore()
+
+	// without the keyring of the device owner nothing is trusted
+	_, err := snapstate.ReadRefreshSpec([]byte(refreshSpecYaml), []byte("sig"), false)
+	c.Assert(err, ErrorMatches, "cannot verify refresh spec signature: no trusted keyring .*/etc/snapd/refresh-spec.gpg")
+	c.Check(gpg.Calls(), HasLen, 0)
+}
+
+func (s *refreshSpecSuite) TestUnsigned(c *C) {
+	_, err := snapstate.ReadRefreshSpec([]byte(refreshSpecYaml), nil, false)
+	c.Assert(err, ErrorMatches, "cannot use refresh spec: missing signature")
+
+	revisions, err := snapstate.ReadRefreshSpec([]byte(refreshSpecYaml), nil, true)
+	c.Assert(err, IsNil)
+	c.Check(revisions, HasLen, 2)
+
+	// a device with an owner only takes signed specs
+	s.mockKeyring(c)
+	_, err = snapstate.ReadRefreshSpec([]byte(refreshSpecYaml), nil, true)
+	c.Assert(err, ErrorMatches, "cannot use refresh spec: missing signature, required by the keyring of the device owner")
+}
+
+func (s *refreshSpecSuite) TestInvalid(c *C) {
+	for _, t := range []struct {
+		spec string
+		err  string
+	}{
+		{"snaps: {}", `refresh spec lists no snaps`},
+		{"snaps: {foo: x1}", `invalid refresh spec: invalid revision "x1" for snap "foo"`},
+		{"snaps: {foo: -1}", `invalid refresh spec: invalid revision "-1" for snap "foo"`},
+		{"snaps: {Foo_: 1}", `invalid refresh spec: invalid snap name: "Foo_"`},
+		{"snaps: [foo]", `(?s)cannot parse refresh spec: .*`},
+	} {
+		_, err := snapstate.ReadRefreshSpec([]byte(t.spec), nil, true)
+		c.Check(err, ErrorMatches, t.err, Commentf(t.spec))
+	}
+}
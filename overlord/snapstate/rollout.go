@@ -0,0 +1,88 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/fips"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/snap"
+)
+
+// rolloutBuckets is how finely devices are split for progressive releases.
+const rolloutBuckets = 10000
+
+// inRollout returns whether the device with the given identity takes
+// part in the progressive release of the snap revision offered to the
+// given percentage of devices. The decision is stable for a device and
+// a release, and the devices taking part at a percentage still do at
+// any higher percentage.
+func inRollout(deviceID, snapID string, revision snap.Revision, percentage float64) bool {
+	if percentage <= 0 || percentage >= 100 {
+		// not a progressive release
+		return true
+	}
+	if deviceID == "" {
+		// wait for the release to complete
+		return false
+	}
+	h := fips.Sum256([]byte(fmt.Sprintf("%s/%s/%s", deviceID, snapID, revision)))
+	bucket := binary.BigEndian.Uint32(h[:4]) % rolloutBuckets
+	return float64(bucket) < percentage*rolloutBuckets/100
+}
+
+// SetDeviceSerial sets how the manager finds the serial of the device,
+// which decides whether the device takes part in progressive releases.
+func (m *SnapManager) SetDeviceSerial(serial func() (string, error)) {
+	m.deviceSerial = serial
+}
+
+// deviceID returns the stable identity of the device used for
+// progressive releases: its serial if it has one, or else its machine
+// id. It is empty if the device has neither.
+func (m *SnapManager) deviceID() string {
+	if m.deviceSerial != nil {
+		serial, err := m.deviceSerial()
+		if err == nil && serial != "" {
+			return serial
+		}
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dirs.GlobalRootDir, "/etc/machine-id"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// HeldByRollout returns whether the revision of the snap offered by
+// the store is held back from this device by a progressive release.
+func (m *SnapManager) HeldByRollout(info *snap.Info) bool {
+	if inRollout(m.deviceID(), info.SnapID, info.Revision, info.RolloutPercentage) {
+		return false
+	}
+	logger.Noticef("revision %s of snap %q is released to %v%% of devices, not including this one yet", info.Revision, info.Name(), info.RolloutPercentage)
+	return true
+}
@@ -0,0 +1,154 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"fmt"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+type rolloutSuite struct{}
+
+var _ = Suite(&rolloutSuite{})
+
+func (s *rolloutSuite) TestInRolloutCompleteRelease(c *C) {
+	c.Check(snapstate.InRollout("", "snap-id", snap.R(1), 0), Equals, true)
+	c.Check(snapstate.InRollout("", "snap-id", snap.R(1), 100), Equals, true)
+	c.Check(snapstate.InRollout("serial", "snap-id", snap.R(1), 0), Equals, true)
+}
+
+func (s *rolloutSuite) TestInRolloutNoDeviceID(c *C) {
+	c.Check(snapstate.InRollout("", "snap-id", snap.R(1), 99.9), Equals, false)
+}
+
+func (s *rolloutSuite) TestInRolloutStableAndGrowing(c *C) {
+	in10, in50 := 0, 0
+	for i := 0; i < 1000; i++ {
+		serial := fmt.Sprintf("brand/model/%d", i)
+		at10 := snapstate.InRollout(serial, "snap-id", snap.R(3), 10)
+		at50 := snapstate.InRollout(serial, "snap-id", snap.R(3), 50)
+		c.Check(snapstate.InRollout(serial, "snap-id", snap.R(3), 10), Equals, at10)
+		if at10 {
+			in10++
+			// devices in the rollout stay in as it progresses
+			c.Check(at50, Equals, true)
+		}
+		if at50 {
+			in50++
+		}
+	}
+	c.Check(in10 > 50 && in10 < 150, Equals, true, Commentf("%d", in10))
+	c.Check(in50 > 400 && in50 < 600, Equals, true, Commentf("%d", in50))
+}
+
+// outOfRollout returns a serial of a device that is not part of the
+// rollout of the given release at the given percentage.
+func outOfRollout(c *C, snapID string, revision snap.Revision, percentage float64) string {
+	for i := 0; i < 1000; i++ {
+		serial := fmt.Sprintf("brand/model/%d", i)
+		if !snapstate.InRollout(serial, snapID, revision, percentage) {
+			return serial
+		}
+	}
+	c.Fatalf("cannot find a device out of the rollout")
+	return ""
+}
+
+func (s *snapmgrTestSuite) TestUpdateHeldByRollout(c *C) {
+	serial := outOfRollout(c, "snapIDsnapidsnapidsnapidsnapidsn", snap.R(11), 1)
+	s.snapmgr.SetDeviceSerial(func() (string, error) { return serial, nil })
+	s.fakeStore.rolloutPercentage = 1
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{OfficialName: "some-snap", Revision: snap.R(7)}},
+	})
+
+	chg := s.state.NewChange("refresh", "refresh a snap")
+	ts, err := snapstate.Update(s.state, "some-snap", "stable", s.user.ID, 0)
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle()
+	s.state.Lock()
+
+	c.Assert(chg.Status(), Equals, state.ErrorStatus)
+	c.Check(chg.Err(), ErrorMatches, `(?s).*revision 11 of snap "some-snap" is being released progressively and not yet to this device.*`)
+	c.Check(s.fakeStore.downloads, HasLen, 0)
+}
+
+func (s *snapmgrTestSuite) TestUpdateUnholdRollout(c *C) {
+	serial := outOfRollout(c, "snapIDsnapidsnapidsnapidsnapidsn", snap.R(11), 1)
+	s.snapmgr.SetDeviceSerial(func() (string, error) { return serial, nil })
+	s.fakeStore.rolloutPercentage = 1
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{OfficialName: "some-snap", Revision: snap.R(7)}},
+	})
+
+	chg := s.state.NewChange("refresh", "refresh a snap")
+	ts, err := snapstate.Update(s.state, "some-snap", "stable", s.user.ID, snapstate.UnholdRollout)
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle()
+	s.state.Lock()
+
+	c.Check(chg.Err(), IsNil)
+	c.Check(s.fakeStore.downloads, HasLen, 1)
+
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "some-snap", &snapst), IsNil)
+	c.Check(snapst.Current().Revision, Equals, snap.R(11))
+}
+
+func (s *autoRefreshSuite) TestRefreshHeldByRollout(c *C) {
+	serial := outOfRollout(c, "some-snap-id", snap.R(8), 5)
+	s.mgr.snapmgr.SetDeviceSerial(func() (string, error) { return serial, nil })
+	s.mgr.fakeStore.refreshes = []*snap.Info{{
+		SideInfo:          snap.SideInfo{OfficialName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(8)},
+		RolloutPercentage: 5,
+	}}
+	s.ensure(c)
+	s.now = s.now.Add(9 * time.Hour)
+	s.ensure(c)
+
+	status := s.refreshStatus(c)
+	c.Check(status.ChangeID, Equals, "")
+	c.Check(status.Failures, Equals, 0)
+	c.Check(status.LastSuccess.Equal(s.now), Equals, true)
+}
@@ -0,0 +1,90 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"fmt"
+
+	"github.com/snapcore/snapd/overlord/configstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/scanner"
+	"github.com/snapcore/snapd/snap"
+)
+
+// scanRejectedError is the error about the scanner rejecting a snap.
+type scanRejectedError struct {
+	snap   string
+	reason string
+}
+
+func (e *scanRejectedError) Error() string {
+	return fmt.Sprintf("snap %q was rejected by the scanner: %s", e.snap, e.reason)
+}
+
+// configuredScanner returns the scanner the user configured under
+// scanner, or nil if there is none.
+// Note that the state must be locked by the caller.
+func configuredScanner(st *state.State) (scanner.Scanner, error) {
+	for _, kind := range []string{"command", "socket"} {
+		var target string
+		if configstate.Get(st, configstate.CoreSnapName, "scanner."+kind, &target) != nil || target == "" {
+			continue
+		}
+		return scanner.New(kind, target)
+	}
+	return nil, nil
+}
+
+// scanSnap gives the mounted snap to the configured scanner, if any,
+// failing if the scanner rejects the snap or cannot scan it.
+func scanSnap(st *state.State, ss *SnapSetup, si *snap.SideInfo) error {
+	st.Lock()
+	sc, err := configuredScanner(st)
+	st.Unlock()
+	if err != nil {
+		return fmt.Errorf("cannot scan snap %q: %v", ss.Name, err)
+	}
+	if sc == nil {
+		return nil
+	}
+
+	info, err := readInfo(ss.Name, si)
+	if err != nil {
+		return err
+	}
+	verdict, err := sc.Scan(&scanner.Request{
+		Snap:        info.Name(),
+		SnapID:      info.SnapID,
+		Revision:    info.Revision.String(),
+		Version:     info.Version,
+		Type:        string(info.Type),
+		Confinement: string(info.Confinement),
+		Developer:   info.Developer,
+		Path:        ss.SnapPath,
+		MountDir:    info.MountDir(),
+	})
+	if err != nil {
+		return fmt.Errorf("cannot scan snap %q: %v", ss.Name, err)
+	}
+	if !verdict.Allow {
+		return &scanRejectedError{snap: ss.Name, reason: verdict.Reason}
+	}
+	return nil
+}
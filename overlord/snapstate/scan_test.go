@@ -0,0 +1,112 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/configstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/scanner"
+)
+
+// mockScanner configures a scanner command exiting with the given
+// status, and returns where the requests it got are kept.
+func (s *snapmgrTestSuite) mockScanner(c *C, status string) (requests string) {
+	dir := c.MkDir()
+	requests = filepath.Join(dir, "requests")
+	cmd := filepath.Join(dir, "scan")
+	script := "#!/bin/sh\ncat >> " + requests + "\necho 'Eicar-Test-Signature FOUND'\nexit " + status + "\n"
+	c.Assert(ioutil.WriteFile(cmd, []byte(script), 0755), IsNil)
+	c.Assert(configstate.Set(s.state, "core", "scanner.command", cmd), IsNil)
+	return requests
+}
+
+func (s *snapmgrTestSuite) installSomeSnap(c *C) *state.Change {
+	chg := s.state.NewChange("install", "install a snap")
+	ts, err := snapstate.Install(s.state, "some-snap", "some-channel", s.user.ID, 0)
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.state.Unlock()
+	s.settle()
+	s.state.Lock()
+	return chg
+}
+
+func (s *snapmgrTestSuite) TestInstallScanned(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	defer s.snapmgr.Stop()
+
+	requests := s.mockScanner(c, "0")
+	chg := s.installSomeSnap(c)
+	c.Assert(chg.Err(), IsNil)
+	c.Check(chg.Status(), Equals, state.DoneStatus)
+
+	data, err := ioutil.ReadFile(requests)
+	c.Assert(err, IsNil)
+	var req scanner.Request
+	c.Assert(json.Unmarshal(data, &req), IsNil)
+	c.Check(req, DeepEquals, scanner.Request{
+		Snap:     "some-snap",
+		SnapID:   "snapIDsnapidsnapidsnapidsnapidsn",
+		Revision: "11",
+		Path:     "downloaded-snap-path",
+		MountDir: "/snap/some-snap/11",
+	})
+}
+
+func (s *snapmgrTestSuite) TestInstallRejectedByScanner(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	defer s.snapmgr.Stop()
+
+	s.mockScanner(c, "1")
+	chg := s.installSomeSnap(c)
+	c.Check(chg.Status(), Equals, state.ErrorStatus)
+	c.Check(chg.Err(), ErrorMatches, `(?s).*snap "some-snap" was rejected by the scanner: Eicar-Test-Signature FOUND.*`)
+	c.Check(snapstate.ClassifyChange(chg), Equals, snapstate.ErrorCodeScanRejected)
+
+	// the snap was unmounted again and never linked
+	var ops []string
+	for _, op := range s.fakeBackend.ops {
+		ops = append(ops, op.op)
+	}
+	c.Check(ops, DeepEquals, []string{"storesvc-snap", "storesvc-download", "current", "open-snap-file", "setup-snap", "undo-setup-snap"})
+	var snapst snapstate.SnapState
+	c.Check(snapstate.Get(s.state, "some-snap", &snapst), Equals, state.ErrNoState)
+}
+
+func (s *snapmgrTestSuite) TestInstallScannerFails(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	defer s.snapmgr.Stop()
+
+	c.Assert(configstate.Set(s.state, "core", "scanner.socket", filepath.Join(c.MkDir(), "scan.sock")), IsNil)
+	chg := s.installSomeSnap(c)
+	c.Check(chg.Status(), Equals, state.ErrorStatus)
+	c.Check(chg.Err(), ErrorMatches, `(?s).*cannot scan snap "some-snap": cannot reach scanner: .*`)
+}
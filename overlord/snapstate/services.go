@@ -0,0 +1,101 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/wrappers"
+)
+
+// RestartServices returns the tasks restarting the running services of
+// the active snaps, for them to pick up changes to the system, like to
+// its locale, and the names of those snaps. Snaps with changes in
+// progress are skipped.
+func RestartServices(s *state.State) ([]string, *state.TaskSet, error) {
+	all, err := All(s)
+	if err != nil {
+		return nil, nil, err
+	}
+	names := make([]string, 0, len(all))
+	for name := range all {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var restarted []string
+	ts := state.NewTaskSet()
+	for _, name := range names {
+		snapst := all[name]
+		if !snapst.Active {
+			continue
+		}
+		info, err := readInfo(name, snapst.Current())
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(info.Services()) == 0 {
+			continue
+		}
+		if err := checkChangeConflict(s, name); err != nil {
+			continue
+		}
+
+		ss := SnapSetup{
+			Name:     name,
+			Revision: info.Revision,
+		}
+		restart := s.NewTask("restart-services", fmt.Sprintf(i18n.G("Restart the running services of snap %q"), name))
+		restart.Set("snap-setup", ss)
+		ts.AddTask(restart)
+		restarted = append(restarted, name)
+	}
+
+	return restarted, ts, nil
+}
+
+func (m *SnapManager) doRestartServices(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	ss, snapst, err := snapSetupAndState(t)
+	st.Unlock()
+	if err != nil {
+		return err
+	}
+
+	info, err := readInfo(ss.Name, snapst.Current())
+	if err != nil {
+		return err
+	}
+	pb := &TaskProgressAdapter{task: t}
+	err = m.backend.RestartSnapServices(info, pb)
+	if e, ok := err.(*wrappers.ServiceStartError); ok && len(e.Log) > 0 {
+		st.Lock()
+		t.SetOutput(strings.Join(e.Log, "\n") + "\n")
+		st.Unlock()
+	}
+	return err
+}
@@ -0,0 +1,65 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+func (s *snapmgrTestSuite) TestRestartServices(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	for _, name := range []string{"services-a", "services-b", "services-busy", "some-snap"} {
+		snapstate.Set(s.state, name, &snapstate.SnapState{
+			Active:   name != "services-b",
+			Sequence: []*snap.SideInfo{{OfficialName: name, Revision: snap.R(7)}},
+		})
+	}
+	busy := s.state.NewChange("restart-services", "...")
+	task := s.state.NewTask("restart-services", "...")
+	task.Set("snap-setup", &snapstate.SnapSetup{Name: "services-busy"})
+	busy.AddTask(task)
+
+	names, ts, err := snapstate.RestartServices(s.state)
+	c.Assert(err, IsNil)
+	// only the active snaps with services and no change in progress
+	c.Check(names, DeepEquals, []string{"services-a"})
+	c.Assert(ts.Tasks(), HasLen, 1)
+	c.Check(ts.Tasks()[0].Kind(), Equals, "restart-services")
+
+	chg := s.state.NewChange("restart-services", "...")
+	chg.AddAll(ts)
+	busy.Abort()
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle()
+	s.state.Lock()
+
+	c.Assert(chg.Status(), Equals, state.DoneStatus)
+	c.Check(s.fakeBackend.ops, DeepEquals, []fakeOp{
+		{op: "restart-snap-services", name: "/snap/services-a/7"},
+	})
+}
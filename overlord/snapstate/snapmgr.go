@@ -24,21 +24,39 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"gopkg.in/tomb.v2"
 
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/configstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/release"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/store"
+	"github.com/snapcore/snapd/wrappers"
 )
 
 // SnapManager is responsible for the installation and removal of snaps.
 type SnapManager struct {
 	state   *state.State
 	backend managerBackend
-	store   StoreService
+
+	storeMu  sync.Mutex
+	store    StoreService
+	newStore func() StoreService
+
+	peerCache     *store.PeerCache
+	downloadCache *store.DownloadCache
+
+	deviceSerial func() (string, error)
+
+	nextErrorReportsFlush time.Time
+	nextEventsFlush       time.Time
 
 	runner *state.TaskRunner
 }
@@ -74,6 +92,9 @@ type SnapSetup struct {
 	Revision snap.Revision `json:"revision,omitempty"`
 	Channel  string        `json:"channel,omitempty"`
 	UserID   int           `json:"user-id,omitempty"`
+	// Architecture is the architecture the snap is downloaded for if
+	// not the one of the system
+	Architecture string `json:"architecture,omitempty"`
 
 	Flags SnapSetupFlags `json:"flags,omitempty"`
 
@@ -98,6 +119,29 @@ func (ss *SnapSetup) TryMode() bool {
 	return ss.Flags&TryMode != 0
 }
 
+// Pinned returns true if the snap is being installed at a pinned revision.
+func (ss *SnapSetup) Pinned() bool {
+	return ss.Flags&Pinned != 0
+}
+
+// UnholdRollout returns true if the snap is being refreshed to a
+// revision even if a progressive release does not include the device yet.
+func (ss *SnapSetup) UnholdRollout() bool {
+	return ss.Flags&UnholdRollout != 0
+}
+
+// Offline returns true if the snap is being refreshed to its cached
+// refresh candidate without network.
+func (ss *SnapSetup) Offline() bool {
+	return ss.Flags&Offline != 0
+}
+
+// AcceptLicense returns true if the license of the snap is being
+// accepted as part of installing it.
+func (ss *SnapSetup) AcceptLicense() bool {
+	return ss.Flags&AcceptLicense != 0
+}
+
 // SnapStateFlags are flags stored in SnapState.
 type SnapStateFlags Flags
 
@@ -110,6 +154,12 @@ type SnapState struct {
 	Flags     SnapStateFlags   `json:"flags,omitempty"`
 	// incremented revision used for local installs
 	LocalRevision snap.Revision `json:"local-revision,omitempty"`
+	// ReadOnlyData is the revision whose system data was sealed
+	// read-only, if any.
+	ReadOnlyData snap.Revision `json:"readonly-data,omitempty"`
+	// License records the acceptance of the license of the snap, if
+	// it asks for one.
+	License *LicenseAcceptance `json:"license,omitempty"`
 }
 
 // Current returns the side info for the current revision in the snap revision sequence if there is one.
@@ -150,6 +200,21 @@ func (snapst *SnapState) SetTryMode(active bool) {
 	}
 }
 
+// Pinned returns true if the snap is held at its current revision
+// instead of following its channel.
+func (snapst *SnapState) Pinned() bool {
+	return snapst.Flags&Pinned != 0
+}
+
+// SetPinned sets/clears the Pinned flag in the SnapState.
+func (snapst *SnapState) SetPinned(active bool) {
+	if active {
+		snapst.Flags |= Pinned
+	} else {
+		snapst.Flags &= ^Pinned
+	}
+}
+
 // Manager returns a new snap manager.
 func Manager(s *state.State) (*SnapManager, error) {
 	runner := state.NewTaskRunner(s)
@@ -160,15 +225,35 @@ func Manager(s *state.State) (*SnapManager, error) {
 	if cand := os.Getenv("UBUNTU_STORE_ID"); cand != "" {
 		storeID = cand
 	}
-	store := store.NewUbuntuStoreSnapRepository(nil, storeID)
-	// TODO: if needed we could also put the store on the state using
-	// the Cache mechanism and an accessor function
+	storeConfig := store.DefaultConfig()
+	storeConfig.MetadataCacheDir = dirs.SnapStoreCacheDir
+	storeConfig.PartialDownloadsDir = dirs.SnapPartialDownloadsDir
+	// keep the downloaded snaps around to refresh offline later
+	downloadCache := store.NewDownloadCache(dirs.SnapDownloadCacheDir)
+	storeConfig.DownloadBackends = append(storeConfig.DownloadBackends, downloadCache)
+	// share downloaded snaps with the peers on the local link if asked to
+	var peerCache *store.PeerCache
+	if addr := os.Getenv("SNAPPY_PEER_CACHE"); addr != "" {
+		peerCache = store.NewPeerCache(dirs.SnapPeerCacheDir)
+		if err := peerCache.Start(addr); err != nil {
+			// still fetch from peers, just don't serve them
+			logger.Noticef("cannot start peer cache: %v", err)
+		}
+		storeConfig.DownloadBackends = append(storeConfig.DownloadBackends, peerCache)
+	}
+	// the store is only set up once needed, and set up again after it
+	// was released to save memory
+	newStore := func() StoreService {
+		return store.NewUbuntuStoreSnapRepository(storeConfig, storeID)
+	}
 
 	m := &SnapManager{
-		state:   s,
-		backend: backend,
-		store:   store,
-		runner:  runner,
+		state:         s,
+		backend:       backend,
+		newStore:      newStore,
+		peerCache:     peerCache,
+		downloadCache: downloadCache,
+		runner:        runner,
 	}
 
 	// this handler does nothing
@@ -182,7 +267,13 @@ func Manager(s *state.State) (*SnapManager, error) {
 	runner.AddHandler("mount-snap", m.doMountSnap, m.undoMountSnap)
 	runner.AddHandler("unlink-current-snap", m.doUnlinkCurrentSnap, m.undoUnlinkCurrentSnap)
 	runner.AddHandler("copy-snap-data", m.doCopySnapData, m.undoCopySnapData)
+	runner.AddHandler("migrate-snap-data", m.doMigrateSnapData, nil)
 	runner.AddHandler("link-snap", m.doLinkSnap, m.undoLinkSnap)
+	runner.AddHandler("set-data-mode", m.doSetDataMode, m.undoSetDataMode)
+	runner.AddHandler("accept-license", m.doAcceptLicense, nil)
+	runner.AddHandler("mark-boot-ok", m.doMarkBootOk, nil)
+	runner.AddHandler("revert-boot", m.doRevertBoot, nil)
+	runner.AddHandler("restart-services", m.doRestartServices, nil)
 	// FIXME: port to native tasks and rename
 	//runner.AddHandler("garbage-collect", m.doGarbageCollect, nil)
 
@@ -202,14 +293,34 @@ func Manager(s *state.State) (*SnapManager, error) {
 	return m, nil
 }
 
-// Store returns the store service used by the manager.
+// Store returns the store service used by the manager, setting it up
+// on first use.
 func (m *SnapManager) Store() StoreService {
+	m.storeMu.Lock()
+	defer m.storeMu.Unlock()
+	if m.store == nil && m.newStore != nil {
+		m.store = m.newStore()
+	}
 	return m.store
 }
 
 // ReplaceStore replaces the store used by manager.
 func (m *SnapManager) ReplaceStore(store StoreService) {
+	m.storeMu.Lock()
+	defer m.storeMu.Unlock()
 	m.store = store
+	// a replaced store is kept for good
+	m.newStore = nil
+}
+
+// ReleaseMemory drops the store client and its caches, to be set up
+// again when next needed.
+func (m *SnapManager) ReleaseMemory() {
+	m.storeMu.Lock()
+	defer m.storeMu.Unlock()
+	if m.newStore != nil {
+		m.store = nil
+	}
 }
 
 func checkRevisionIsNew(name string, snapst *SnapState, revision snap.Revision) error {
@@ -284,6 +395,10 @@ func (m *SnapManager) doDownloadSnap(t *state.Task, _ *tomb.Tomb) error {
 
 	meter := &TaskProgressAdapter{task: t}
 
+	if ss.Offline() {
+		return fetchCachedSnap(t, ss, snapst, meter)
+	}
+
 	var auther store.Authenticator
 	if ss.UserID > 0 {
 		st.Lock()
@@ -295,18 +410,38 @@ func (m *SnapManager) doDownloadSnap(t *state.Task, _ *tomb.Tomb) error {
 		auther = user.Authenticator()
 	}
 
-	storeInfo, err := m.store.Snap(ss.Name, ss.Channel, auther)
+	theStore := m.Store()
+	var storeInfo *snap.Info
+	switch {
+	case ss.Pinned():
+		storeInfo, err = theStore.SnapRevision(ss.Name, ss.Revision, auther)
+	case ss.Architecture != "":
+		storeInfo, err = theStore.SnapForArchitecture(ss.Name, ss.Channel, ss.Architecture, auther)
+	default:
+		storeInfo, err = theStore.Snap(ss.Name, ss.Channel, auther)
+	}
 	if err != nil {
-		return err
+		return recordStoreError(t, err)
+	}
+
+	// refuse what cannot run here before downloading it, rather than
+	// once it is installed
+	if len(storeInfo.Architectures) != 0 {
+		if err := checkArchitectures(ss.Name, storeInfo.Architectures); err != nil {
+			return err
+		}
 	}
 
 	if err = checkRevisionIsNew(ss.Name, snapst, storeInfo.Revision); err != nil {
 		return err
 	}
+	if snapst.Current() != nil && !ss.Pinned() && !ss.UnholdRollout() && m.HeldByRollout(storeInfo) {
+		return fmt.Errorf("revision %s of snap %q is being released progressively and not yet to this device (use --unhold-rollout to refresh now)", storeInfo.Revision, ss.Name)
+	}
 
-	downloadedSnapFile, err := m.store.Download(storeInfo, meter, auther)
+	downloadedSnapFile, err := theStore.Download(storeInfo, meter, auther)
 	if err != nil {
-		return err
+		return recordStoreError(t, err)
 	}
 
 	ss.SnapPath = downloadedSnapFile
@@ -378,11 +513,48 @@ func (m *SnapManager) doClearSnapData(t *state.Task, _ *tomb.Tomb) error {
 		if err = m.backend.RemoveSnapCommonData(info); err != nil {
 			return err
 		}
+
+		// and the publisher data if no other snap of the publisher uses it
+		if info.UsesPublisherData() {
+			t.State().Lock()
+			inUse, err := publisherDataInUse(t.State(), info)
+			t.State().Unlock()
+			if err != nil {
+				return err
+			}
+			if !inUse {
+				if err = m.backend.RemoveSnapPublisherData(info); err != nil {
+					return err
+				}
+			}
+		}
 	}
 
 	return nil
 }
 
+// publisherDataInUse returns true if any other installed snap of the
+// publisher of the given snap uses the publisher data.
+func publisherDataInUse(st *state.State, info *snap.Info) (bool, error) {
+	snapStates, err := All(st)
+	if err != nil {
+		return false, err
+	}
+	for snapName, snapst := range snapStates {
+		if snapName == info.Name() {
+			continue
+		}
+		other, err := readInfo(snapName, snapst.Current())
+		if err != nil {
+			return false, err
+		}
+		if other.Developer == info.Developer && other.UsesPublisherData() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 func (m *SnapManager) doDiscardSnap(t *state.Task, _ *tomb.Tomb) error {
 	st := t.State()
 
@@ -419,13 +591,72 @@ func (m *SnapManager) doDiscardSnap(t *state.Task, _ *tomb.Tomb) error {
 	st.Lock()
 	Set(st, ss.Name, snapst)
 	st.Unlock()
+	m.prunePeerCache()
 	return nil
 }
 
+// prunePeerCache drops from the peer cache the snap files of the
+// revisions no longer on the system, so they are not shared anymore.
+func (m *SnapManager) prunePeerCache() {
+	if m.peerCache == nil {
+		return
+	}
+	m.state.Lock()
+	all, err := All(m.state)
+	m.state.Unlock()
+	if err != nil {
+		logger.Noticef("cannot prune peer cache: %v", err)
+		return
+	}
+	keep := make(map[string]bool)
+	for _, snapst := range all {
+		for _, si := range snapst.Sequence {
+			if si.Sha512 != "" {
+				keep[si.Sha512] = true
+			}
+		}
+	}
+	if err := m.peerCache.Prune(keep); err != nil {
+		logger.Noticef("cannot prune peer cache: %v", err)
+	}
+}
+
 // Ensure implements StateManager.Ensure.
 func (m *SnapManager) Ensure() error {
+	m.ensureMaxWorkers()
 	m.runner.Ensure()
-	return nil
+	recordErrorCodes(m.state)
+	m.ensureErrorReports()
+	m.ensureAdvisories()
+	m.ensureNotifications()
+	m.ensureEvents()
+	m.ensureManifest()
+
+	m.state.Lock()
+	defer m.state.Unlock()
+	if err := m.ensureAutoRefresh(); err != nil {
+		return err
+	}
+	if err := m.ensureReadOnlyData(); err != nil {
+		return err
+	}
+	if err := m.ensureConfigSources(); err != nil {
+		return err
+	}
+	if err := m.ensureBootOk(); err != nil {
+		return err
+	}
+	return m.ensureReboot()
+}
+
+// ensureMaxWorkers bounds how many tasks of independent snaps run at the
+// same time, as set with the tasks.max-workers option of the system.
+func (m *SnapManager) ensureMaxWorkers() {
+	m.state.Lock()
+	var n int
+	configstate.Get(m.state, configstate.CoreSnapName, "tasks.max-workers", &n)
+	m.state.Unlock()
+	m.runner.SetMaxWorkers(n)
 }
 
 // Wait implements StateManager.Wait.
@@ -436,6 +667,11 @@ func (m *SnapManager) Wait() {
 // Stop implements StateManager.Stop.
 func (m *SnapManager) Stop() {
 	m.runner.Stop()
+	if m.peerCache != nil {
+		if err := m.peerCache.Stop(); err != nil {
+			logger.Noticef("cannot stop peer cache: %v", err)
+		}
+	}
 }
 
 // TaskSnapSetup returns the SnapSetup with task params hold by or referred to by the the task.
@@ -509,13 +745,28 @@ func (m *SnapManager) doMountSnap(t *state.Task, _ *tomb.Tomb) error {
 	m.backend.Current(curInfo)
 
 	if err := checkSnap(t.State(), ss.SnapPath, curInfo, Flags(ss.Flags)); err != nil {
+		quarantineRefused(ss, snapst.Candidate, QuarantineCheckFailed, err)
 		return err
 	}
 
 	pb := &TaskProgressAdapter{task: t}
 	// TODO Use ss.Revision to obtain the right info to mount
 	//      instead of assuming the candidate is the right one.
-	return m.backend.SetupSnap(ss.SnapPath, snapst.Candidate, pb)
+	if err := m.backend.SetupSnap(ss.SnapPath, snapst.Candidate, pb); err != nil {
+		return err
+	}
+
+	// the snap is scanned once mounted so that scanners can read it
+	if err := scanSnap(t.State(), ss, snapst.Candidate); err != nil {
+		m.backend.UndoSetupSnap(ss.placeInfo(), pb)
+		reason := QuarantineScanFailed
+		if _, ok := err.(*scanRejectedError); ok {
+			reason = QuarantineScanRejected
+		}
+		quarantineRefused(ss, snapst.Candidate, reason, err)
+		return err
+	}
+	return nil
 }
 
 func (m *SnapManager) undoUnlinkCurrentSnap(t *state.Task, _ *tomb.Tomb) error {
@@ -535,8 +786,9 @@ func (m *SnapManager) undoUnlinkCurrentSnap(t *state.Task, _ *tomb.Tomb) error {
 	}
 
 	snapst.Active = true
+	licenseAccepted := snapst.LicenseAccepted(oldInfo)
 	st.Unlock()
-	err = m.backend.LinkSnap(oldInfo)
+	err = m.linkSnap(oldInfo, licenseAccepted)
 	st.Lock()
 	if err != nil {
 		return err
@@ -563,12 +815,17 @@ func (m *SnapManager) doUnlinkCurrentSnap(t *state.Task, _ *tomb.Tomb) error {
 	if err != nil {
 		return err
 	}
+	// the revision replacing it decides which services endure
+	newInfo, err := readInfo(ss.Name, snapst.Candidate)
+	if err != nil {
+		return err
+	}
 
 	snapst.Active = false
 
 	pb := &TaskProgressAdapter{task: t}
 	st.Unlock() // pb itself will ask for locking
-	err = m.backend.UnlinkSnap(oldInfo, pb)
+	err = m.backend.UnlinkSnapForRefresh(oldInfo, newInfo, pb)
 	st.Lock()
 	if err != nil {
 		return err
@@ -656,15 +913,24 @@ func (m *SnapManager) doLinkSnap(t *state.Task, _ *tomb.Tomb) error {
 	}
 	oldTryMode := snapst.TryMode()
 	snapst.SetTryMode(ss.TryMode())
+	oldPinned := snapst.Pinned()
+	snapst.SetPinned(ss.Pinned())
 
 	newInfo, err := readInfo(ss.Name, cand)
 	if err != nil {
 		return err
 	}
+	if ss.AcceptLicense() && newInfo.NeedsLicenseAgreement() {
+		snapst.acceptLicense(newInfo)
+	}
+	licenseAccepted := snapst.LicenseAccepted(newInfo)
+	if !licenseAccepted {
+		t.Logf("Services of snap %q not started: its license must be accepted first", ss.Name)
+	}
 
 	st.Unlock()
 	// XXX: this block is slightly ugly, find a pattern when we have more examples
-	err = m.backend.LinkSnap(newInfo)
+	err = m.linkSnap(newInfo, licenseAccepted)
 	if err != nil {
 		pb := &TaskProgressAdapter{task: t}
 		err := m.backend.UnlinkSnap(newInfo, pb)
@@ -676,22 +942,33 @@ func (m *SnapManager) doLinkSnap(t *state.Task, _ *tomb.Tomb) error {
 	}
 	st.Lock()
 	if err != nil {
+		if e, ok := err.(*wrappers.ServiceStartError); ok && len(e.Log) > 0 {
+			t.SetOutput(strings.Join(e.Log, "\n") + "\n")
+		}
 		return err
 	}
 
 	// save for undoLinkSnap
 	t.Set("old-trymode", oldTryMode)
+	t.Set("old-pinned", oldPinned)
 	t.Set("old-channel", oldChannel)
 	// Do at the end so we only preserve the new state if it worked.
 	Set(st, ss.Name, snapst)
 	// Make sure if state commits and snapst is mutated we won't be rerun
 	t.SetStatus(state.DoneStatus)
 
+	if NeedsReboot(newInfo) {
+		if err := setRebootRequired(st, ss.Name); err != nil {
+			logger.Noticef("cannot record that snap %q needs a reboot: %v", ss.Name, err)
+		}
+		t.Change().Set("reboot-required", true)
+	}
+
 	// if we just installed a core snap, request a restart
 	// so that we switch executing its snapd
 	if newInfo.Type == snap.TypeOS && release.OnClassic {
 		st.Unlock()
-		st.RequestRestart()
+		st.RequestRestart(state.RestartDaemon)
 		st.Lock()
 	}
 
@@ -719,6 +996,12 @@ func (m *SnapManager) undoLinkSnap(t *state.Task, _ *tomb.Tomb) error {
 	if err != nil {
 		return err
 	}
+	// tasks from before pinning existed do not carry old-pinned
+	var oldPinned bool
+	err = t.Get("old-pinned", &oldPinned)
+	if err != nil && err != state.ErrNoState {
+		return err
+	}
 
 	// relinking of the old snap is done in the undo of unlink-current-snap
 
@@ -727,6 +1010,7 @@ func (m *SnapManager) undoLinkSnap(t *state.Task, _ *tomb.Tomb) error {
 	snapst.Active = false
 	snapst.Channel = oldChannel
 	snapst.SetTryMode(oldTryMode)
+	snapst.SetPinned(oldPinned)
 
 	newInfo, err := readInfo(ss.Name, snapst.Candidate)
 	if err != nil {
@@ -20,6 +20,8 @@
 package snapstate_test
 
 import (
+	"crypto/sha512"
+	"encoding/hex"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -28,12 +30,15 @@ import (
 	. "gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/configstate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/snapstate/backend"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/store"
 )
 
 func TestSnapManager(t *testing.T) { TestingT(t) }
@@ -79,8 +84,13 @@ func (s *snapmgrTestSuite) SetUpTest(c *C) {
 
 	restore1 := snapstate.MockReadInfo(s.fakeBackend.ReadInfo)
 	restore2 := snapstate.MockOpenSnapFile(s.fakeBackend.OpenSnapFile)
+	oldManifestFile := dirs.SnapInstallManifestFile
+	dirs.SnapInstallManifestFile = filepath.Join(c.MkDir(), "install-manifest.jsonl")
 
 	s.reset = func() {
+		snapstate.DeviceModel = nil
+		snapstate.OpenAdvisoryDatabase = nil
+		dirs.SnapInstallManifestFile = oldManifestFile
 		restore2()
 		restore1()
 	}
@@ -102,11 +112,30 @@ func (s *snapmgrTestSuite) TestStore(c *C) {
 	c.Check(s.snapmgr.Store(), IsNil)
 }
 
+func (s *snapmgrTestSuite) TestStoreSetUpWhenNeeded(c *C) {
+	mgr, err := snapstate.Manager(s.state)
+	c.Assert(err, IsNil)
+
+	sto := mgr.Store()
+	c.Assert(sto, NotNil)
+	c.Check(mgr.Store(), Equals, sto)
+
+	mgr.ReleaseMemory()
+	again := mgr.Store()
+	c.Assert(again, NotNil)
+	c.Check(again, Not(Equals), sto)
+
+	// a replaced store is never released
+	mgr.ReplaceStore(s.fakeStore)
+	mgr.ReleaseMemory()
+	c.Check(mgr.Store(), Equals, s.fakeStore)
+}
+
 func verifyInstallUpdateTasks(c *C, curActive bool, ts *state.TaskSet, st *state.State) {
 	i := 0
 	n := 5
 	if curActive {
-		n++
+		n += 2
 	}
 	c.Assert(ts.Tasks(), HasLen, n)
 	// all tasks are accounted
@@ -121,6 +150,10 @@ func verifyInstallUpdateTasks(c *C, curActive bool, ts *state.TaskSet, st *state
 	}
 	c.Assert(ts.Tasks()[i].Kind(), Equals, "copy-snap-data")
 	i++
+	if curActive {
+		c.Assert(ts.Tasks()[i].Kind(), Equals, "migrate-snap-data")
+		i++
+	}
 	c.Assert(ts.Tasks()[i].Kind(), Equals, "setup-profiles")
 	i++
 	c.Assert(ts.Tasks()[i].Kind(), Equals, "link-snap")
@@ -511,6 +544,89 @@ func (s *snapmgrTestSuite) TestUpdateRunThrough(c *C) {
 	})
 }
 
+func (s *snapmgrTestSuite) TestUpdateToRevisionTasks(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Channel:  "edge",
+		Sequence: []*snap.SideInfo{{OfficialName: "some-snap", Revision: snap.R(11)}},
+	})
+
+	ts, err := snapstate.UpdateToRevision(s.state, "some-snap", snap.R(5), s.user.ID, 0)
+	c.Assert(err, IsNil)
+	verifyInstallUpdateTasks(c, true, ts, s.state)
+	c.Check(ts.Tasks()[0].Summary(), Equals, `Download snap "some-snap" revision 5`)
+
+	var ss snapstate.SnapSetup
+	err = ts.Tasks()[0].Get("snap-setup", &ss)
+	c.Assert(err, IsNil)
+	c.Check(ss.Channel, Equals, "edge")
+	c.Check(ss.Revision, Equals, snap.R(5))
+	c.Check(ss.Pinned(), Equals, true)
+
+	_, err = snapstate.UpdateToRevision(s.state, "some-snap", snap.R(11), s.user.ID, 0)
+	c.Check(err, ErrorMatches, `revision 11 of snap "some-snap" already installed`)
+}
+
+func (s *snapmgrTestSuite) TestUpdateToRevisionRunThrough(c *C) {
+	si := snap.SideInfo{
+		OfficialName: "some-snap",
+		Revision:     snap.R(7),
+	}
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Channel:  "stable",
+		Sequence: []*snap.SideInfo{&si},
+	})
+
+	chg := s.state.NewChange("refresh", "refresh a snap")
+	ts, err := snapstate.UpdateToRevision(s.state, "some-snap", snap.R(5), s.user.ID, 0)
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle()
+	s.state.Lock()
+
+	c.Assert(chg.Status(), Equals, state.DoneStatus)
+	c.Assert(s.fakeBackend.ops[0], DeepEquals, fakeOp{
+		op:    "storesvc-snap-revision",
+		name:  "some-snap",
+		revno: snap.R(5),
+	})
+
+	var snapst snapstate.SnapState
+	err = snapstate.Get(s.state, "some-snap", &snapst)
+	c.Assert(err, IsNil)
+	c.Check(snapst.Current().Revision, Equals, snap.R(5))
+	c.Check(snapst.Channel, Equals, "stable")
+	c.Check(snapst.Pinned(), Equals, true)
+
+	// a regular refresh follows the channel again
+	chg = s.state.NewChange("refresh", "refresh a snap")
+	ts, err = snapstate.Update(s.state, "some-snap", "", s.user.ID, 0)
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.state.Unlock()
+	s.settle()
+	s.state.Lock()
+
+	c.Assert(chg.Status(), Equals, state.DoneStatus)
+	var refreshed snapstate.SnapState
+	err = snapstate.Get(s.state, "some-snap", &refreshed)
+	c.Assert(err, IsNil)
+	c.Check(refreshed.Current().Revision, Equals, snap.R(11))
+	c.Check(refreshed.Pinned(), Equals, false)
+}
+
 func (s *snapmgrTestSuite) TestUpdateUndoRunThrough(c *C) {
 	si := snap.SideInfo{
 		OfficialName: "some-snap",
@@ -765,6 +881,108 @@ func (s *snapmgrTestSuite) TestUpdateTotalUndoRunThrough(c *C) {
 	})
 }
 
+func (s *snapmgrTestSuite) updateTwoSnapsInLanes(c *C, sharedLane bool) *state.Change {
+	chg := s.state.NewChange("refresh-snap", "refresh two snaps")
+	var shared int
+	if sharedLane {
+		shared = s.state.NewLane()
+	}
+	for _, name := range []string{"some-snap", "other-snap"} {
+		snapstate.Set(s.state, name, &snapstate.SnapState{
+			Active:   true,
+			Sequence: []*snap.SideInfo{{OfficialName: name, Revision: snap.R(7)}},
+		})
+		ts, err := snapstate.Update(s.state, name, "some-channel", s.user.ID, 0)
+		c.Assert(err, IsNil)
+		if sharedLane {
+			ts.JoinLane(shared)
+		} else {
+			ts.JoinLane(s.state.NewLane())
+		}
+		chg.AddAll(ts)
+	}
+
+	s.fakeBackend.linkSnapFailTrigger = "/snap/some-snap/11"
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle()
+	s.state.Lock()
+
+	c.Check(chg.Status(), Equals, state.ErrorStatus)
+	return chg
+}
+
+func (s *snapmgrTestSuite) TestUpdateLanesUndoIndependently(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	chg := s.updateTwoSnapsInLanes(c, false)
+
+	results := snapstate.SnapResults(chg)
+	c.Assert(results, HasLen, 2)
+	c.Check(results["some-snap"].Status, Equals, snapstate.SnapResultFailed)
+	c.Check(results["other-snap"].Status, Equals, snapstate.SnapResultSucceeded)
+
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "other-snap", &snapst), IsNil)
+	c.Check(snapst.Current().Revision, Equals, snap.R(11))
+	c.Assert(snapstate.Get(s.state, "some-snap", &snapst), IsNil)
+	c.Check(snapst.Current().Revision, Equals, snap.R(7))
+}
+
+func (s *snapmgrTestSuite) TestInstallLanesFailureLeavesOthersInstalled(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	// one task at a time still keeps the lanes apart
+	c.Assert(configstate.Set(s.state, "core", "tasks.max-workers", 1), IsNil)
+
+	chg := s.state.NewChange("install-snap", "install two snaps")
+	for _, name := range []string{"some-snap", "other-snap"} {
+		ts, err := snapstate.Install(s.state, name, "some-channel", s.user.ID, 0)
+		c.Assert(err, IsNil)
+		ts.JoinLane(s.state.NewLane())
+		chg.AddAll(ts)
+	}
+
+	s.fakeBackend.linkSnapFailTrigger = "/snap/some-snap/11"
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle()
+	s.state.Lock()
+
+	c.Check(chg.Status(), Equals, state.ErrorStatus)
+
+	results := snapstate.SnapResults(chg)
+	c.Assert(results, HasLen, 2)
+	c.Check(results["some-snap"].Status, Equals, snapstate.SnapResultFailed)
+	c.Check(results["other-snap"].Status, Equals, snapstate.SnapResultSucceeded)
+
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "other-snap", &snapst), IsNil)
+	c.Check(snapst.Active, Equals, true)
+	c.Check(snapst.Current().Revision, Equals, snap.R(11))
+	c.Check(snapstate.Get(s.state, "some-snap", &snapst), Equals, state.ErrNoState)
+}
+
+func (s *snapmgrTestSuite) TestUpdateSharedLaneUndoesAll(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	chg := s.updateTwoSnapsInLanes(c, true)
+
+	results := snapstate.SnapResults(chg)
+	c.Assert(results, HasLen, 2)
+	c.Check(results["some-snap"].Status, Equals, snapstate.SnapResultFailed)
+	c.Check(results["other-snap"].Status, Equals, snapstate.SnapResultUndone)
+
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "other-snap", &snapst), IsNil)
+	c.Check(snapst.Current().Revision, Equals, snap.R(7))
+}
+
 func (s *snapmgrTestSuite) TestUpdateSameRevisionRunThrough(c *C) {
 	si := snap.SideInfo{
 		OfficialName: "some-snap",
@@ -1004,6 +1222,52 @@ version: 1.0`)
 	c.Assert(snapst.LocalRevision, Equals, snap.R(-1))
 }
 
+func (s *snapmgrTestSuite) testRemovePublisherData(c *C, otherDeveloper string) []fakeOp {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "shared-a", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{OfficialName: "shared-a", Revision: snap.R(7), Developer: "acme"}},
+	})
+	snapstate.Set(s.state, "shared-b", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{OfficialName: "shared-b", Revision: snap.R(3), Developer: otherDeveloper}},
+	})
+
+	chg := s.state.NewChange("remove", "remove a snap")
+	ts, err := snapstate.Remove(s.state, "shared-a")
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle()
+	s.state.Lock()
+
+	c.Assert(chg.Status(), Equals, state.DoneStatus)
+	var ops []fakeOp
+	for _, op := range s.fakeBackend.ops {
+		if op.op == "remove-snap-publisher-data" {
+			ops = append(ops, op)
+		}
+	}
+	return ops
+}
+
+func (s *snapmgrTestSuite) TestRemoveLastSnapOfPublisherRemovesPublisherData(c *C) {
+	ops := s.testRemovePublisherData(c, "someone-else")
+	c.Check(ops, DeepEquals, []fakeOp{{
+		op:   "remove-snap-publisher-data",
+		name: filepath.Join(dirs.SnapPublisherDataDir, "acme"),
+	}})
+}
+
+func (s *snapmgrTestSuite) TestRemoveKeepsPublisherDataInUse(c *C) {
+	ops := s.testRemovePublisherData(c, "acme")
+	c.Check(ops, HasLen, 0)
+}
+
 func (s *snapmgrTestSuite) TestRemoveRunThrough(c *C) {
 	si := snap.SideInfo{
 		OfficialName: "some-snap",
@@ -1197,6 +1461,46 @@ func (s *snapmgrTestSuite) TestRemoveWithManyRevisionsRunThrough(c *C) {
 	c.Assert(err, Equals, state.ErrNoState)
 }
 
+func (s *snapmgrTestSuite) TestRemovePrunesPeerCache(c *C) {
+	dir := c.MkDir()
+	peerCache := store.NewPeerCache(filepath.Join(dir, "peer-cache"))
+	snapstate.SetSnapManagerPeerCache(s.snapmgr, peerCache)
+	digests := make(map[string]string)
+	for _, content := range []string{"removed", "kept"} {
+		path := filepath.Join(dir, content)
+		c.Assert(ioutil.WriteFile(path, []byte(content), 0644), IsNil)
+		h := sha512.Sum512([]byte(content))
+		digests[content] = hex.EncodeToString(h[:])
+		info := &snap.Info{SideInfo: snap.SideInfo{OfficialName: content, Sha512: digests[content]}}
+		c.Assert(peerCache.Cache(info, path), IsNil)
+	}
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{OfficialName: "some-snap", Revision: snap.R(7), Sha512: digests["removed"]}},
+	})
+	snapstate.Set(s.state, "other-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{OfficialName: "other-snap", Revision: snap.R(2), Sha512: digests["kept"]}},
+	})
+
+	chg := s.state.NewChange("remove", "remove a snap")
+	ts, err := snapstate.Remove(s.state, "some-snap")
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle()
+	s.state.Lock()
+
+	c.Assert(chg.Status(), Equals, state.DoneStatus)
+	c.Check(osutil.FileExists(filepath.Join(dir, "peer-cache", digests["removed"])), Equals, false)
+	c.Check(osutil.FileExists(filepath.Join(dir, "peer-cache", digests["kept"])), Equals, true)
+}
+
 func (s *snapmgrTestSuite) TestRemoveRefused(c *C) {
 	si := snap.SideInfo{
 		OfficialName: "gadget",
@@ -57,7 +57,23 @@ const (
 	// 0x40000000 >> iota
 )
 
-func doInstall(s *state.State, curActive bool, snapName, snapPath, channel string, userID int, flags Flags) (*state.TaskSet, error) {
+// Pinned is set for snaps held at an exact revision, e.g. from a
+// refresh spec, instead of following their channel.
+const Pinned = firstInterimUsableFlagValue
+
+// UnholdRollout is set to refresh a snap to a revision that is being
+// released progressively before the release includes the device.
+const UnholdRollout = Pinned << 1
+
+// Offline is set to refresh a snap to its cached refresh candidate,
+// using the snap file already downloaded, without network.
+const Offline = UnholdRollout << 1
+
+// AcceptLicense is set to accept the license of a snap that requires
+// it being explicitly accepted while installing or refreshing it.
+const AcceptLicense = Offline << 1
+
+func doInstall(s *state.State, curActive bool, snapName, snapPath, channel string, revision snap.Revision, userID int, flags Flags) (*state.TaskSet, error) {
 	if err := checkChangeConflict(s, snapName); err != nil {
 		return nil, err
 	}
@@ -67,15 +83,30 @@ func doInstall(s *state.State, curActive bool, snapName, snapPath, channel strin
 	}
 
 	var prepare *state.Task
+	if !revision.Unset() {
+		flags |= Pinned
+	}
 	ss := SnapSetup{
-		Channel: channel,
-		UserID:  userID,
-		Flags:   SnapSetupFlags(flags),
+		Channel:  channel,
+		Revision: revision,
+		UserID:   userID,
+		Flags:    SnapSetupFlags(flags),
 	}
 	ss.Name = snapName
 	ss.SnapPath = snapPath
+	if snapPath == "" {
+		architecture, err := Architecture(s, snapName)
+		if err != nil {
+			return nil, err
+		}
+		ss.Architecture = architecture
+	}
 	if snapPath != "" {
 		prepare = s.NewTask("prepare-snap", fmt.Sprintf(i18n.G("Prepare snap %q"), snapPath))
+	} else if flags&Offline != 0 {
+		prepare = s.NewTask("download-snap", fmt.Sprintf(i18n.G("Fetch snap %q from the download cache"), snapName))
+	} else if !revision.Unset() {
+		prepare = s.NewTask("download-snap", fmt.Sprintf(i18n.G("Download snap %q revision %s"), snapName, revision))
 	} else {
 		prepare = s.NewTask("download-snap", fmt.Sprintf(i18n.G("Download snap %q from channel %q"), snapName, channel))
 	}
@@ -94,7 +125,8 @@ func doInstall(s *state.State, curActive bool, snapName, snapPath, channel strin
 	precopy := mount
 
 	if curActive {
-		// unlink-current-snap (will stop services for copy-data)
+		// unlink-current-snap (will stop services for copy-data, except
+		// those the new revision lets endure the refresh)
 		unlink := s.NewTask("unlink-current-snap", fmt.Sprintf(i18n.G("Make current revision for snap %q unavailable"), snapName))
 		addTask(unlink)
 		unlink.WaitFor(mount)
@@ -105,11 +137,21 @@ func doInstall(s *state.State, curActive bool, snapName, snapPath, channel strin
 	copyData := s.NewTask("copy-snap-data", fmt.Sprintf(i18n.G("Copy snap %q data"), snapName))
 	addTask(copyData)
 	copyData.WaitFor(precopy)
+	presecurity := copyData
+
+	if curActive {
+		// migrate-data (runs the migrate-data hook of the new revision
+		// on the copy of the data, if it has one)
+		migrateData := s.NewTask("migrate-snap-data", fmt.Sprintf(i18n.G("Migrate snap %q data"), snapName))
+		addTask(migrateData)
+		migrateData.WaitFor(copyData)
+		presecurity = migrateData
+	}
 
 	// security
 	setupSecurity := s.NewTask("setup-profiles", fmt.Sprintf(i18n.G("Setup snap %q security profiles"), snapName))
 	addTask(setupSecurity)
-	setupSecurity.WaitFor(copyData)
+	setupSecurity.WaitFor(presecurity)
 
 	// finalize (wrappers+current symlink)
 	linkSnap := s.NewTask("link-snap", fmt.Sprintf(i18n.G("Make snap %q available to the system"), snapName))
@@ -123,7 +165,7 @@ func checkChangeConflict(s *state.State, snapName string) error {
 	for _, task := range s.Tasks() {
 		k := task.Kind()
 		chg := task.Change()
-		if (k == "link-snap" || k == "unlink-snap") && (chg == nil || !chg.Status().Ready()) {
+		if (k == "link-snap" || k == "unlink-snap" || k == "set-data-mode" || k == "revert-boot" || k == "accept-license" || k == "restart-services") && (chg == nil || !chg.Status().Ready()) {
 			ss, err := TaskSnapSetup(task)
 			if err != nil {
 				return fmt.Errorf("internal error: cannot obtain snap setup from task: %s", task.Summary())
@@ -148,7 +190,42 @@ func Install(s *state.State, name, channel string, userID int, flags Flags) (*st
 		return nil, fmt.Errorf("snap %q already installed", name)
 	}
 
-	return doInstall(s, false, name, "", channel, userID, flags)
+	mod, err := deviceModel()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkInstallAllowed(mod, name, false); err != nil {
+		return nil, err
+	}
+	if channel == "" && mod != nil {
+		channel = mod.PreferredChannels()[name]
+	}
+
+	return doInstall(s, false, name, "", channel, snap.Revision{}, userID, flags)
+}
+
+// InstallRevision returns a set of tasks for installing the given
+// revision of a snap from the store, pinning the snap to it.
+// Note that the state must be locked by the caller.
+func InstallRevision(s *state.State, name string, revision snap.Revision, userID int, flags Flags) (*state.TaskSet, error) {
+	var snapst SnapState
+	err := Get(s, name, &snapst)
+	if err != nil && err != state.ErrNoState {
+		return nil, err
+	}
+	if snapst.Current() != nil {
+		return nil, fmt.Errorf("snap %q already installed", name)
+	}
+
+	mod, err := deviceModel()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkInstallAllowed(mod, name, false); err != nil {
+		return nil, err
+	}
+
+	return doInstall(s, false, name, "", "stable", revision, userID, flags)
 }
 
 // InstallPath returns a set of tasks for installing snap from a file path.
@@ -160,7 +237,15 @@ func InstallPath(s *state.State, name, path, channel string, flags Flags) (*stat
 		return nil, err
 	}
 
-	return doInstall(s, snapst.Active, name, path, channel, 0, flags)
+	mod, err := deviceModel()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkInstallAllowed(mod, name, true); err != nil {
+		return nil, err
+	}
+
+	return doInstall(s, snapst.Active, name, path, channel, snap.Revision{}, 0, flags)
 }
 
 // TryPath returns a set of tasks for trying a snap from a file path.
@@ -188,7 +273,27 @@ func Update(s *state.State, name, channel string, userID int, flags Flags) (*sta
 	}
 
 	// TODO: pass the right UserID
-	return doInstall(s, snapst.Active, name, "", channel, userID, flags)
+	return doInstall(s, snapst.Active, name, "", channel, snap.Revision{}, userID, flags)
+}
+
+// UpdateToRevision initiates a change updating a snap to the given
+// revision from the store, pinning the snap to it: it then stays there
+// until it is refreshed again.
+// Note that the state must be locked by the caller.
+func UpdateToRevision(s *state.State, name string, revision snap.Revision, userID int, flags Flags) (*state.TaskSet, error) {
+	var snapst SnapState
+	err := Get(s, name, &snapst)
+	if err != nil && err != state.ErrNoState {
+		return nil, err
+	}
+	if snapst.Current() == nil {
+		return nil, fmt.Errorf("cannot find snap %q", name)
+	}
+	if err := checkRevisionIsNew(name, &snapst, revision); err != nil {
+		return nil, err
+	}
+
+	return doInstall(s, snapst.Active, name, "", snapst.Channel, revision, userID, flags)
 }
 
 func removeInactiveRevision(s *state.State, name string, revision snap.Revision) *state.TaskSet {
@@ -256,6 +361,14 @@ func Remove(s *state.State, name string) (*state.TaskSet, error) {
 		return nil, fmt.Errorf("snap %q is not removable", name)
 	}
 
+	mod, err := deviceModel()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkRemoveAllowed(mod, name); err != nil {
+		return nil, err
+	}
+
 	// main/current SnapSetup
 	ss := SnapSetup{
 		Name:     name,
@@ -422,17 +422,68 @@ func (c *Change) Tasks() []*Task {
 func (c *Change) Abort() {
 	c.state.writing()
 	for _, tid := range c.taskIDs {
-		t := c.state.tasks[tid]
-		switch t.Status() {
-		case DoStatus:
-			// Still pending so don't even start.
-			t.SetStatus(HoldStatus)
-		case DoneStatus:
-			// Already done so undo it.
-			t.SetStatus(UndoStatus)
-		case DoingStatus:
-			// In progress so stop and undo it.
-			t.SetStatus(AbortStatus)
+		abortTask(c.state.tasks[tid])
+	}
+}
+
+// AbortLanes cancels the tasks of the change in the given lanes, whether
+// in progress or not, leaving the tasks in other lanes alone: tasks also
+// in a lane not aborted are kept, unless they wait for a cancelled task,
+// in which case all their lanes are cancelled too.
+func (c *Change) AbortLanes(lanes []int) {
+	c.state.writing()
+	aborting := make(map[int]bool, len(lanes))
+	for _, lane := range lanes {
+		aborting[lane] = true
+	}
+
+	tasks := c.state.tasksIn(c.taskIDs)
+	for grown := true; grown; {
+		grown = false
+		for _, t := range tasks {
+			if !allLanesIn(t, aborting) {
+				continue
+			}
+			for _, ht := range t.HaltTasks() {
+				if ht.change != c.id {
+					continue
+				}
+				for _, lane := range ht.Lanes() {
+					if !aborting[lane] {
+						aborting[lane] = true
+						grown = true
+					}
+				}
+			}
+		}
+	}
+
+	for _, t := range tasks {
+		if allLanesIn(t, aborting) {
+			abortTask(t)
 		}
 	}
 }
+
+func allLanesIn(t *Task, lanes map[int]bool) bool {
+	for _, lane := range t.Lanes() {
+		if !lanes[lane] {
+			return false
+		}
+	}
+	return true
+}
+
+func abortTask(t *Task) {
+	switch t.Status() {
+	case DoStatus:
+		// Still pending so don't even start.
+		t.SetStatus(HoldStatus)
+	case DoneStatus:
+		// Already done so undo it.
+		t.SetStatus(UndoStatus)
+	case DoingStatus:
+		// In progress so stop and undo it.
+		t.SetStatus(AbortStatus)
+	}
+}
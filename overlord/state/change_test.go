@@ -358,3 +358,40 @@ func (cs *changeSuite) TestAbort(c *C) {
 		}
 	}
 }
+
+func (cs *changeSuite) TestAbortLanes(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	chg := st.NewChange("refresh", "...")
+	lane1 := st.NewLane()
+	lane2 := st.NewLane()
+	lane3 := st.NewLane()
+
+	// lane 1 failed; lane 2 is independent; lane 3 waits for lane 1
+	t1 := st.NewTask("download", "1")
+	t1.JoinLane(lane1)
+	t1.SetStatus(state.DoneStatus)
+	t2 := st.NewTask("download", "2")
+	t2.JoinLane(lane2)
+	t2.SetStatus(state.DoneStatus)
+	t3 := st.NewTask("download", "3")
+	t3.JoinLane(lane3)
+	t3.WaitFor(t1)
+	// in both lanes 1 and 2, so kept as lane 2 is fine
+	shared := st.NewTask("setup", "shared")
+	shared.JoinLane(lane1)
+	shared.JoinLane(lane2)
+	shared.SetStatus(state.DoneStatus)
+	for _, t := range []*state.Task{t1, t2, t3, shared} {
+		chg.AddTask(t)
+	}
+
+	chg.AbortLanes([]int{lane1})
+
+	c.Check(t1.Status(), Equals, state.UndoStatus)
+	c.Check(t2.Status(), Equals, state.DoneStatus)
+	c.Check(t3.Status(), Equals, state.HoldStatus)
+	c.Check(shared.Status(), Equals, state.DoneStatus)
+}
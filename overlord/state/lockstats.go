@@ -0,0 +1,164 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package state
+
+import (
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/snapcore/snapd/logger"
+)
+
+// LockOpStats are the statistics of the state lock for an operation,
+// the function that took the lock.
+type LockOpStats struct {
+	Op        string        `json:"operation"`
+	Count     int           `json:"count"`
+	TotalWait time.Duration `json:"total-wait"`
+	MaxWait   time.Duration `json:"max-wait"`
+	TotalHold time.Duration `json:"total-hold"`
+	MaxHold   time.Duration `json:"max-hold"`
+}
+
+// LockStats are the statistics of the state lock: who holds it now,
+// if anyone, and for each operation how long it waited for it and held
+// it.
+type LockStats struct {
+	Holder    string         `json:"holder,omitempty"`
+	HeldSince *time.Time     `json:"held-since,omitempty"`
+	Ops       []*LockOpStats `json:"operations"`
+}
+
+type byMaxHold []*LockOpStats
+
+func (ops byMaxHold) Len() int           { return len(ops) }
+func (ops byMaxHold) Less(i, j int) bool { return ops[i].MaxHold > ops[j].MaxHold }
+func (ops byMaxHold) Swap(i, j int)      { ops[i], ops[j] = ops[j], ops[i] }
+
+// lockCaller returns the name of the function calling Lock, without
+// its package path.
+func lockCaller() string {
+	pc, _, _, ok := runtime.Caller(2)
+	if !ok {
+		return "unknown"
+	}
+	f := runtime.FuncForPC(pc)
+	if f == nil {
+		return "unknown"
+	}
+	name := f.Name()
+	return name[strings.LastIndex(name, "/")+1:]
+}
+
+// lockAcquired records that op got the state lock, after asking for it
+// at the given time.
+func (s *State) lockAcquired(op string, asked time.Time) {
+	now := time.Now()
+	s.lockMu.Lock()
+	defer s.lockMu.Unlock()
+	if s.lockOps == nil {
+		s.lockOps = make(map[string]*LockOpStats)
+	}
+	stats := s.lockOps[op]
+	if stats == nil {
+		stats = &LockOpStats{Op: op}
+		s.lockOps[op] = stats
+	}
+	wait := now.Sub(asked)
+	stats.Count++
+	stats.TotalWait += wait
+	if wait > stats.MaxWait {
+		stats.MaxWait = wait
+	}
+	s.holder = op
+	s.heldSince = now
+}
+
+// lockReleased records that the holder of the state lock released it.
+func (s *State) lockReleased() {
+	s.lockMu.Lock()
+	defer s.lockMu.Unlock()
+	if stats := s.lockOps[s.holder]; stats != nil {
+		hold := time.Since(s.heldSince)
+		stats.TotalHold += hold
+		if hold > stats.MaxHold {
+			stats.MaxHold = hold
+		}
+	}
+	s.holder = ""
+}
+
+// LockStats returns the statistics of the state lock, the operations
+// with the longest holds first. It does not need the state lock, so it
+// can tell who is holding it for too long.
+func (s *State) LockStats() *LockStats {
+	s.lockMu.Lock()
+	defer s.lockMu.Unlock()
+	stats := &LockStats{Ops: make([]*LockOpStats, 0, len(s.lockOps))}
+	if s.holder != "" {
+		heldSince := s.heldSince
+		stats.Holder = s.holder
+		stats.HeldSince = &heldSince
+	}
+	for _, op := range s.lockOps {
+		opStats := *op
+		stats.Ops = append(stats.Ops, &opStats)
+	}
+	sort.Sort(byMaxHold(stats.Ops))
+	return stats
+}
+
+// WatchLock checks the state lock until stop is closed, logging the
+// stack traces of all the goroutines, once, whenever it is held for
+// longer than the threshold.
+func (s *State) WatchLock(threshold time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(threshold / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			s.checkLockHeld(threshold)
+		}
+	}
+}
+
+// checkLockHeld logs the stack traces of all the goroutines if the
+// state lock is held for longer than the threshold and this was not
+// logged yet for this hold, returning whether it did.
+func (s *State) checkLockHeld(threshold time.Duration) bool {
+	s.lockMu.Lock()
+	holder := s.holder
+	held := time.Since(s.heldSince)
+	if holder == "" || held < threshold || s.heldSince.Equal(s.lockReported) {
+		s.lockMu.Unlock()
+		return false
+	}
+	s.lockReported = s.heldSince
+	s.lockMu.Unlock()
+
+	buf := make([]byte, 1<<20)
+	n := runtime.Stack(buf, true)
+	logger.Noticef("state lock held by %s for %v, stack traces:\n%s", holder, held, buf[:n])
+	return true
+}
@@ -38,10 +38,19 @@ import (
 type Backend interface {
 	Checkpoint(data []byte) error
 	EnsureBefore(d time.Duration)
-	// TODO: take flags to ask for reboot vs restart?
-	RequestRestart()
+	RequestRestart(t RestartType)
 }
 
+// RestartType is what is to be restarted when asking for a restart.
+type RestartType int
+
+const (
+	// RestartDaemon asks for the managing process to be restarted.
+	RestartDaemon RestartType = iota
+	// RestartSystem asks for the whole system to be rebooted.
+	RestartSystem
+)
+
 type customData map[string]*json.RawMessage
 
 func (data customData) get(key string, value interface{}) error {
@@ -79,6 +88,7 @@ type State struct {
 
 	lastTaskId   int
 	lastChangeId int
+	lastLaneId   int
 
 	backend Backend
 	data    customData
@@ -88,6 +98,14 @@ type State struct {
 	modified bool
 
 	cache map[interface{}]interface{}
+
+	// the instrumentation of the lock, with a lock of its own to be
+	// available while the state is locked
+	lockMu       sync.Mutex
+	holder       string
+	heldSince    time.Time
+	lockOps      map[string]*LockOpStats
+	lockReported time.Time
 }
 
 // New returns a new empty state.
@@ -109,8 +127,11 @@ func (s *State) Modified() bool {
 
 // Lock acquires the state lock.
 func (s *State) Lock() {
+	op := lockCaller()
+	asked := time.Now()
 	s.mu.Lock()
 	atomic.AddInt32(&s.muC, 1)
+	s.lockAcquired(op, asked)
 }
 
 func (s *State) reading() {
@@ -127,6 +148,7 @@ func (s *State) writing() {
 }
 
 func (s *State) unlock() {
+	s.lockReleased()
 	atomic.AddInt32(&s.muC, -1)
 	s.mu.Unlock()
 }
@@ -138,6 +160,7 @@ type marshalledState struct {
 
 	LastChangeId int `json:"last-change-id"`
 	LastTaskId   int `json:"last-task-id"`
+	LastLaneId   int `json:"last-lane-id,omitempty"`
 }
 
 // MarshalJSON makes State a json.Marshaller
@@ -150,6 +173,7 @@ func (s *State) MarshalJSON() ([]byte, error) {
 
 		LastTaskId:   s.lastTaskId,
 		LastChangeId: s.lastChangeId,
+		LastLaneId:   s.lastLaneId,
 	})
 }
 
@@ -166,6 +190,7 @@ func (s *State) UnmarshalJSON(data []byte) error {
 	s.tasks = unmarshalled.Tasks
 	s.lastChangeId = unmarshalled.LastChangeId
 	s.lastTaskId = unmarshalled.LastTaskId
+	s.lastLaneId = unmarshalled.LastLaneId
 	// backlink state again
 	for _, t := range s.tasks {
 		t.state = s
@@ -222,10 +247,11 @@ func (s *State) EnsureBefore(d time.Duration) {
 	}
 }
 
-// RequestRestart asks for a restart of the managing process.
-func (s *State) RequestRestart() {
+// RequestRestart asks for a restart of the managing process, or of the
+// whole system.
+func (s *State) RequestRestart(t RestartType) {
 	if s.backend != nil {
-		s.backend.RequestRestart()
+		s.backend.RequestRestart(t)
 	}
 }
 
@@ -303,6 +329,15 @@ func (s *State) NewTask(kind, summary string) *Task {
 	return t
 }
 
+// NewLane creates a new lane for the tasks of a change to join. The
+// tasks in a lane are aborted together when one of them fails, without
+// aborting the tasks of the change in other lanes.
+func (s *State) NewLane() int {
+	s.writing()
+	s.lastLaneId++
+	return s.lastLaneId
+}
+
 // Tasks returns all tasks currently known to the state and linked to changes.
 func (s *State) Tasks() []*Task {
 	s.reading()
@@ -378,6 +413,7 @@ func ReadState(backend Backend, r io.Reader) (*State, error) {
 	s := new(State)
 	s.Lock()
 	defer s.unlock()
+	s.cache = make(map[interface{}]interface{})
 	d := json.NewDecoder(r)
 	err := d.Decode(&s)
 	if err != nil {
@@ -22,11 +22,13 @@ package state_test
 import (
 	"bytes"
 	"errors"
+	"strings"
 	"testing"
 	"time"
 
 	. "gopkg.in/check.v1"
 
+	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord/state"
 )
 
@@ -54,6 +56,72 @@ func (ss *stateSuite) TestLockUnlock(c *C) {
 	st.Unlock()
 }
 
+func (ss *stateSuite) TestLockStats(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	stats := st.LockStats()
+	c.Check(stats.Holder, Equals, "state_test.(*stateSuite).TestLockStats")
+	c.Check(stats.HeldSince, NotNil)
+	st.Unlock()
+	st.Lock()
+	st.Unlock()
+
+	stats = st.LockStats()
+	c.Check(stats.Holder, Equals, "")
+	c.Check(stats.HeldSince, IsNil)
+	c.Assert(stats.Ops, HasLen, 1)
+	c.Check(stats.Ops[0].Op, Equals, "state_test.(*stateSuite).TestLockStats")
+	c.Check(stats.Ops[0].Count, Equals, 2)
+}
+
+func holdStateLockShort(st *state.State) {
+	st.Lock()
+	st.Unlock()
+}
+
+func holdStateLockLong(st *state.State) {
+	st.Lock()
+	time.Sleep(10 * time.Millisecond)
+	st.Unlock()
+}
+
+func (ss *stateSuite) TestLockStatsLongestHoldsFirst(c *C) {
+	st := state.New(nil)
+	holdStateLockShort(st)
+	holdStateLockLong(st)
+
+	stats := st.LockStats()
+	c.Assert(stats.Ops, HasLen, 2)
+	c.Check(stats.Ops[0].Op, Equals, "state_test.holdStateLockLong")
+	c.Check(stats.Ops[0].MaxHold >= 10*time.Millisecond, Equals, true)
+	c.Check(stats.Ops[0].TotalHold, Equals, stats.Ops[0].MaxHold)
+	c.Check(stats.Ops[1].Op, Equals, "state_test.holdStateLockShort")
+}
+
+func (ss *stateSuite) TestWatchLock(c *C) {
+	var buf bytes.Buffer
+	l, err := logger.NewConsoleLog(&buf, logger.DefaultFlags)
+	c.Assert(err, IsNil)
+	logger.SetLogger(l)
+	defer logger.SetLogger(logger.NullLogger)
+
+	st := state.New(nil)
+	st.Lock()
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		st.WatchLock(5*time.Millisecond, stop)
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(stop)
+	<-done
+	st.Unlock()
+
+	c.Check(strings.Count(buf.String(), "state lock held by state_test.(*stateSuite).TestWatchLock for"), Equals, 1)
+	c.Check(buf.String(), Matches, "(?s).*goroutine .*TestWatchLock.*")
+}
+
 func (ss *stateSuite) TestGetAndSet(c *C) {
 	st := state.New(nil)
 	st.Lock()
@@ -133,11 +201,22 @@ func (ss *stateSuite) TestCache(c *C) {
 	c.Assert(ok, Equals, false)
 }
 
+func (ss *stateSuite) TestCacheAfterReadState(c *C) {
+	st, err := state.ReadState(nil, bytes.NewBufferString("{}"))
+	c.Assert(err, IsNil)
+	st.Lock()
+	defer st.Unlock()
+
+	type key1 struct{}
+	st.Cache(key1{}, "value1")
+	c.Assert(st.Cached(key1{}), Equals, "value1")
+}
+
 type fakeStateBackend struct {
 	checkpoints      [][]byte
 	error            func() error
 	ensureBefore     time.Duration
-	restartRequested bool
+	restartRequested []state.RestartType
 }
 
 func (b *fakeStateBackend) Checkpoint(data []byte) error {
@@ -152,8 +231,8 @@ func (b *fakeStateBackend) EnsureBefore(d time.Duration) {
 	b.ensureBefore = d
 }
 
-func (b *fakeStateBackend) RequestRestart() {
-	b.restartRequested = true
+func (b *fakeStateBackend) RequestRestart(t state.RestartType) {
+	b.restartRequested = append(b.restartRequested, t)
 }
 
 func (ss *stateSuite) TestImplicitCheckpointAndRead(c *C) {
@@ -647,7 +726,35 @@ func (ss *stateSuite) TestRequestRestart(c *C) {
 	b := new(fakeStateBackend)
 	st := state.New(b)
 
-	st.RequestRestart()
+	st.RequestRestart(state.RestartDaemon)
+	st.RequestRestart(state.RestartSystem)
+
+	c.Check(b.restartRequested, DeepEquals, []state.RestartType{state.RestartDaemon, state.RestartSystem})
+}
+
+func (ss *stateSuite) TestNewLaneAndCheckpoint(c *C) {
+	b := new(fakeStateBackend)
+	st := state.New(b)
+	st.Lock()
+
+	c.Check(st.NewLane(), Equals, 1)
+	lane := st.NewLane()
+	c.Check(lane, Equals, 2)
 
-	c.Check(b.restartRequested, Equals, true)
+	chg := st.NewChange("install", "summary")
+	t := st.NewTask("download", "1...")
+	t.JoinLane(lane)
+	chg.AddTask(t)
+
+	// implicit checkpoint
+	st.Unlock()
+
+	c.Assert(b.checkpoints, HasLen, 1)
+	st2, err := state.ReadState(nil, bytes.NewBuffer(b.checkpoints[0]))
+	c.Assert(err, IsNil)
+
+	st2.Lock()
+	defer st2.Unlock()
+	c.Check(st2.Task(t.ID()).Lanes(), DeepEquals, []int{2})
+	c.Check(st2.NewLane(), Equals, 3)
 }
@@ -46,7 +46,9 @@ type Task struct {
 	data      customData
 	waitTasks []string
 	haltTasks []string
+	lanes     []int
 	log       []string
+	output    string
 	change    string
 
 	spawnTime time.Time
@@ -74,7 +76,9 @@ type marshalledTask struct {
 	Data      map[string]*json.RawMessage `json:"data,omitempty"`
 	WaitTasks []string                    `json:"wait-tasks,omitempty"`
 	HaltTasks []string                    `json:"halt-tasks,omitempty"`
+	Lanes     []int                       `json:"lanes,omitempty"`
 	Log       []string                    `json:"log,omitempty"`
+	Output    string                      `json:"output,omitempty"`
 	Change    string                      `json:"change"`
 
 	SpawnTime time.Time  `json:"spawn-time"`
@@ -97,7 +101,9 @@ func (t *Task) MarshalJSON() ([]byte, error) {
 		Data:      t.data,
 		WaitTasks: t.waitTasks,
 		HaltTasks: t.haltTasks,
+		Lanes:     t.lanes,
 		Log:       t.log,
+		Output:    t.output,
 		Change:    t.change,
 
 		SpawnTime: t.spawnTime,
@@ -123,7 +129,9 @@ func (t *Task) UnmarshalJSON(data []byte) error {
 	t.data = unmarshalled.Data
 	t.waitTasks = unmarshalled.WaitTasks
 	t.haltTasks = unmarshalled.HaltTasks
+	t.lanes = unmarshalled.Lanes
 	t.log = unmarshalled.Log
+	t.output = unmarshalled.Output
 	t.change = unmarshalled.Change
 	t.spawnTime = unmarshalled.SpawnTime
 	if unmarshalled.ReadyTime != nil {
@@ -227,8 +235,9 @@ const (
 	// Messages logged in tasks are guaranteed to use the time formatted
 	// per RFC3339 plus the following strings as a prefix, so these may
 	// be handled programatically and parsed or stripped for presentation.
-	LogInfo  = "INFO"
-	LogError = "ERROR"
+	LogInfo    = "INFO"
+	LogWarning = "WARNING"
+	LogError   = "ERROR"
 )
 
 var timeNow = time.Now
@@ -257,7 +266,7 @@ func (t *Task) addLog(kind, format string, args []interface{}) {
 // are returned is an implementation detail and may change over time.
 //
 // Messages are prefixed with one of the known message kinds.
-// See details about LogInfo and LogError.
+// See details about LogInfo, LogWarning and LogError.
 //
 // The returned slice should not be read from without the
 // state lock held, and should not be written to.
@@ -272,12 +281,42 @@ func (t *Task) Logf(format string, args ...interface{}) {
 	t.addLog(LogInfo, format, args)
 }
 
+// Warningf logs a warning about the task, something that does not stop
+// it but that the user should know about.
+func (t *Task) Warningf(format string, args ...interface{}) {
+	t.state.writing()
+	t.addLog(LogWarning, format, args)
+}
+
 // Errorf logs error information about the progress of the task.
 func (t *Task) Errorf(format string, args ...interface{}) {
 	t.state.writing()
 	t.addLog(LogError, format, args)
 }
 
+// maxOutput is how much of the output kept for a task is kept.
+const maxOutput = 64 * 1024
+
+// truncatedOutput marks the output kept for a task as cut.
+const truncatedOutput = "[...]\n"
+
+// SetOutput keeps the output of a command run by the task, e.g. a hook,
+// so that its failures can be looked into. Only the last 64KiB of the
+// output are kept.
+func (t *Task) SetOutput(output string) {
+	t.state.writing()
+	if len(output) > maxOutput {
+		output = truncatedOutput + output[len(output)-maxOutput:]
+	}
+	t.output = output
+}
+
+// Output returns the output of a command run by the task, if kept.
+func (t *Task) Output() string {
+	t.state.reading()
+	return t.output
+}
+
 // Set associates value with key for future consulting by managers.
 // The provided value must properly marshal and unmarshal with encoding/json.
 func (t *Task) Set(key string, value interface{}) {
@@ -328,6 +367,28 @@ func (t *Task) HaltTasks() []*Task {
 	return t.state.tasksIn(t.haltTasks)
 }
 
+// JoinLane registers the task in the given lane, created with
+// State.NewLane. A task can be in several lanes.
+func (t *Task) JoinLane(lane int) {
+	t.state.writing()
+	for _, l := range t.lanes {
+		if l == lane {
+			return
+		}
+	}
+	t.lanes = append(t.lanes, lane)
+}
+
+// Lanes returns the lanes the task is in. Tasks that joined none are in
+// the default lane 0.
+func (t *Task) Lanes() []int {
+	t.state.reading()
+	if len(t.lanes) == 0 {
+		return []int{0}
+	}
+	return append([]int(nil), t.lanes...)
+}
+
 // A TaskSet holds a set of tasks.
 type TaskSet struct {
 	tasks []*Task
@@ -354,6 +415,13 @@ func (ts *TaskSet) WaitAll(anotherTs *TaskSet) {
 	}
 }
 
+// JoinLane registers all the tasks in the set in the given lane.
+func (ts *TaskSet) JoinLane(lane int) {
+	for _, t := range ts.tasks {
+		t.JoinLane(lane)
+	}
+}
+
 // AddTask adds the the task to the task set.
 func (ts *TaskSet) AddTask(task *Task) {
 	for _, t := range ts.tasks {
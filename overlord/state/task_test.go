@@ -22,6 +22,7 @@ package state_test
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	. "gopkg.in/check.v1"
 
@@ -241,6 +242,17 @@ func (cs *taskSuite) TestErrorf(c *C) {
 	c.Assert(t.Log()[0], Matches, "....-..-..T.* ERROR Some error")
 }
 
+func (cs *taskSuite) TestWarningf(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	t := st.NewTask("download", "1...")
+
+	t.Warningf("Some %s", "warning")
+	c.Assert(t.Log()[0], Matches, "....-..-..T.* WARNING Some warning")
+}
+
 func (ts *taskSuite) TestTaskMarshalsLog(c *C) {
 	st := state.New(nil)
 	st.Lock()
@@ -255,6 +267,31 @@ func (ts *taskSuite) TestTaskMarshalsLog(c *C) {
 	c.Assert(string(d), Matches, `.*"log":\["....-..-..T.* INFO foo"\].*`)
 }
 
+func (ts *taskSuite) TestOutput(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	t := st.NewTask("run-hook", "1...")
+	c.Check(t.Output(), Equals, "")
+	t.SetOutput("foo\nbar\n")
+	c.Check(t.Output(), Equals, "foo\nbar\n")
+
+	d, err := t.MarshalJSON()
+	c.Assert(err, IsNil)
+	c.Check(string(d), Matches, `.*"output":"foo\\nbar\\n".*`)
+}
+
+func (ts *taskSuite) TestOutputKeepsTheEnd(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	t := st.NewTask("run-hook", "1...")
+	t.SetOutput(strings.Repeat("a", 10) + strings.Repeat("b", 64*1024))
+	c.Check(t.Output(), Equals, "[...]\n"+strings.Repeat("b", 64*1024))
+}
+
 // TODO: Better testing of full task roundtripping via JSON.
 
 func (cs *taskSuite) TestMethodEntrance(c *C) {
@@ -270,7 +307,9 @@ func (cs *taskSuite) TestMethodEntrance(c *C) {
 		func() { t2.WaitFor(t1) },
 		func() { t1.SetProgress(2, 2) },
 		func() { t1.Logf("") },
+		func() { t1.Warningf("") },
 		func() { t1.Errorf("") },
+		func() { t1.SetOutput("") },
 		func() { t1.UnmarshalJSON(nil) },
 		func() { t1.SetProgress(1, 1) },
 	}
@@ -282,6 +321,7 @@ func (cs *taskSuite) TestMethodEntrance(c *C) {
 		func() { t1.HaltTasks() },
 		func() { t1.Progress() },
 		func() { t1.Log() },
+		func() { t1.Output() },
 		func() { t1.MarshalJSON() },
 		func() { t1.Progress() },
 		func() { t1.SetProgress(0, 1) },
@@ -389,3 +429,31 @@ func (ts *taskSuite) TestTaskSetAddTaskAndAddAll(c *C) {
 
 	c.Check(ts0.Tasks(), DeepEquals, []*state.Task{t1, t2, t3, t4})
 }
+
+func (ts *taskSuite) TestLanes(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	t := st.NewTask("download", "1...")
+	c.Check(t.Lanes(), DeepEquals, []int{0})
+
+	t.JoinLane(1)
+	t.JoinLane(2)
+	t.JoinLane(1)
+	c.Check(t.Lanes(), DeepEquals, []int{1, 2})
+}
+
+func (ts *taskSuite) TestTaskSetJoinLane(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	t1 := st.NewTask("download", "1...")
+	t2 := st.NewTask("install", "2...")
+	lane := st.NewLane()
+	state.NewTaskSet(t1, t2).JoinLane(lane)
+
+	c.Check(t1.Lanes(), DeepEquals, []int{lane})
+	c.Check(t2.Lanes(), DeepEquals, []int{lane})
+}
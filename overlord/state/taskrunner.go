@@ -21,6 +21,8 @@ package state
 
 import (
 	"errors"
+	"sort"
+	"strconv"
 	"sync"
 
 	"gopkg.in/tomb.v2"
@@ -45,6 +47,11 @@ type TaskRunner struct {
 	handlers map[string]handlerPair
 	stopped  bool
 
+	// maxWorkers limits the number of tasks run at once, if not 0;
+	// throttled is set when tasks ready to run were left waiting.
+	maxWorkers int
+	throttled  bool
+
 	// go-routines lifecycle
 	tombs map[string]*tomb.Tomb
 }
@@ -71,6 +78,16 @@ func (r *TaskRunner) AddHandler(kind string, do, undo HandlerFunc) {
 	r.handlers[kind] = handlerPair{do, undo}
 }
 
+// SetMaxWorkers limits the number of tasks run at the same time, with 0
+// meaning no limit. The tasks of different lanes, or otherwise not
+// waiting for each other, are run in parallel up to that limit.
+func (r *TaskRunner) SetMaxWorkers(n int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.maxWorkers = n
+}
+
 // run must be called with the state lock in place
 func (r *TaskRunner) run(t *Task) {
 	var handler HandlerFunc
@@ -109,6 +126,11 @@ func (r *TaskRunner) run(t *Task) {
 		defer r.state.Unlock()
 
 		delete(r.tombs, t.ID())
+		if r.throttled {
+			// a worker is free for the tasks left waiting
+			r.throttled = false
+			r.state.EnsureBefore(0)
+		}
 
 		switch err := tomb.Err(); err {
 		case Retry:
@@ -141,15 +163,17 @@ func (r *TaskRunner) run(t *Task) {
 		default:
 			t.SetStatus(ErrorStatus)
 			t.Errorf("%s", err)
-			r.abortChange(t.Change())
+			r.abortLanes(t.Change(), t.Lanes())
 		}
 
 		return nil
 	})
 }
 
-func (r *TaskRunner) abortChange(chg *Change) {
-	chg.Abort()
+// abortLanes aborts the tasks of the change in the given lanes, the
+// ones of the tasks that failed, stopping those in flight.
+func (r *TaskRunner) abortLanes(chg *Change, lanes []int) {
+	chg.AbortLanes(lanes)
 	ensureScheduled := false
 	for _, t := range chg.Tasks() {
 		status := t.Status()
@@ -196,7 +220,11 @@ func (r *TaskRunner) Ensure() {
 	r.state.Lock()
 	defer r.state.Unlock()
 
-	for _, t := range r.state.Tasks() {
+	tasks := r.state.Tasks()
+	// the oldest tasks first, for the lanes to progress evenly when
+	// the workers are limited
+	sort.Sort(byTaskID(tasks))
+	for _, t := range tasks {
 		handlers, ok := r.handlers[t.Kind()]
 		if !ok {
 			// Handled by a different runner instance.
@@ -235,11 +263,26 @@ func (r *TaskRunner) Ensure() {
 			// Dependencies still unhandled.
 			continue
 		}
+		if r.maxWorkers > 0 && len(r.tombs) >= r.maxWorkers {
+			// run once a worker is free
+			r.throttled = true
+			continue
+		}
 		logger.Debugf("Running task %s on %s: %s", t.ID(), t.Status(), t.Summary())
 		r.run(t)
 	}
 }
 
+type byTaskID []*Task
+
+func (ts byTaskID) Len() int      { return len(ts) }
+func (ts byTaskID) Swap(i, j int) { ts[i], ts[j] = ts[j], ts[i] }
+func (ts byTaskID) Less(i, j int) bool {
+	a, _ := strconv.Atoi(ts[i].ID())
+	b, _ := strconv.Atoi(ts[j].ID())
+	return a < b
+}
+
 // mustWait returns whether task t must wait for other tasks to be done.
 func mustWait(t *Task) bool {
 	switch t.Status() {
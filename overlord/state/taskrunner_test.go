@@ -50,7 +50,7 @@ func (b *stateBackend) EnsureBefore(d time.Duration) {
 	b.mu.Unlock()
 }
 
-func (b *stateBackend) RequestRestart() {}
+func (b *stateBackend) RequestRestart(t state.RestartType) {}
 
 func ensureChange(c *C, r *state.TaskRunner, sb *stateBackend, chg *state.Change) {
 	for i := 0; i < 10; i++ {
@@ -404,3 +404,95 @@ func (ts *taskRunnerSuite) TestStopAskForRetry(c *C) {
 	defer st.Unlock()
 	c.Check(t.Status(), Equals, state.DoingStatus)
 }
+
+func (ts *taskRunnerSuite) TestErrorAbortsItsLane(c *C) {
+	sb := &stateBackend{}
+	st := state.New(sb)
+	r := state.NewTaskRunner(st)
+	defer r.Stop()
+
+	var undone []string
+	r.AddHandler("do", func(t *state.Task, tb *tomb.Tomb) error {
+		return nil
+	}, func(t *state.Task, tb *tomb.Tomb) error {
+		st.Lock()
+		defer st.Unlock()
+		undone = append(undone, t.Summary())
+		return nil
+	})
+	r.AddHandler("fail", func(t *state.Task, tb *tomb.Tomb) error {
+		return errors.New("boom")
+	}, nil)
+
+	st.Lock()
+	chg := st.NewChange("refresh", "...")
+	lane1 := st.NewLane()
+	lane2 := st.NewLane()
+	a1 := st.NewTask("do", "a1")
+	a2 := st.NewTask("fail", "a2")
+	a2.WaitFor(a1)
+	state.NewTaskSet(a1, a2).JoinLane(lane1)
+	b1 := st.NewTask("do", "b1")
+	b2 := st.NewTask("do", "b2")
+	b2.WaitFor(b1)
+	state.NewTaskSet(b1, b2).JoinLane(lane2)
+	for _, t := range []*state.Task{a1, a2, b1, b2} {
+		chg.AddTask(t)
+	}
+	st.Unlock()
+
+	ensureChange(c, r, sb, chg)
+
+	st.Lock()
+	defer st.Unlock()
+	c.Check(a1.Status(), Equals, state.UndoneStatus)
+	c.Check(a2.Status(), Equals, state.ErrorStatus)
+	c.Check(b1.Status(), Equals, state.DoneStatus)
+	c.Check(b2.Status(), Equals, state.DoneStatus)
+	c.Check(undone, DeepEquals, []string{"a1"})
+	c.Check(chg.Status(), Equals, state.ErrorStatus)
+}
+
+func (ts *taskRunnerSuite) TestMaxWorkers(c *C) {
+	sb := &stateBackend{ensureBefore: time.Hour}
+	st := state.New(sb)
+	r := state.NewTaskRunner(st)
+	defer r.Stop()
+	r.SetMaxWorkers(2)
+
+	var mu sync.Mutex
+	running, most := 0, 0
+	r.AddHandler("work", func(t *state.Task, tb *tomb.Tomb) error {
+		mu.Lock()
+		running++
+		if running > most {
+			most = running
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return nil
+	}, nil)
+
+	st.Lock()
+	chg := st.NewChange("seed", "...")
+	for i := 0; i < 5; i++ {
+		chg.AddTask(st.NewTask("work", "..."))
+	}
+	st.Unlock()
+
+	// each round runs as many tasks as there are workers
+	for i := 0; i < 3; i++ {
+		r.Ensure()
+		r.Wait()
+	}
+
+	st.Lock()
+	defer st.Unlock()
+	c.Check(chg.Status(), Equals, state.DoneStatus)
+	c.Check(most, Equals, 2)
+	// the tasks left waiting asked for another ensure
+	c.Check(sb.ensureBefore, Equals, time.Duration(0))
+}
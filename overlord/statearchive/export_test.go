@@ -0,0 +1,42 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package statearchive
+
+import (
+	"github.com/snapcore/snapd/cmd"
+)
+
+// MockRunGPG mocks the helper used to sign and verify manifests.
+func MockRunGPG(f func(input []byte, args ...string) ([]byte, error)) (restore func()) {
+	old := runGPG
+	runGPG = f
+	return func() {
+		runGPG = old
+	}
+}
+
+// MockVersion mocks the version of the running snapd.
+func MockVersion(version string) (restore func()) {
+	old := cmd.Version
+	cmd.Version = version
+	return func() {
+		cmd.Version = old
+	}
+}
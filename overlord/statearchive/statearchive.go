@@ -0,0 +1,668 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package statearchive exports the snapd system state (snaps, interface
+// connections, configuration and assertions) and snapshots of the system
+// data of the snaps into a single archive and imports it again, so a
+// device's setup can be moved to replacement hardware.
+package statearchive
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/snapcore/snapd/cmd"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/fips"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/overlord/statelog"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snappy"
+)
+
+// Format is the version of the archive layout written by Export.
+const Format = 1
+
+const (
+	manifestName    = "manifest.json"
+	signatureName   = "manifest.json.sig"
+	stateName       = "state.json"
+	assertsPrefix   = "assertions/"
+	snapshotsPrefix = "snapshots/"
+)
+
+// Manifest describes the content of a state archive.
+type Manifest struct {
+	Format  int                      `json:"format"`
+	Series  string                   `json:"series"`
+	Version string                   `json:"version"`
+	Snaps   map[string]snap.Revision `json:"snaps"`
+	// Files maps the name of each archive member to its sha512 digest.
+	Files map[string]string `json:"files"`
+}
+
+// ExportOptions hold options for Export.
+type ExportOptions struct {
+	// KeyID is the GnuPG key used to sign the manifest, if any.
+	KeyID string
+}
+
+// ImportOptions hold options for Import.
+type ImportOptions struct {
+	// AllowUnsigned allows importing archives without a manifest signature.
+	AllowUnsigned bool
+	// Force allows replacing an existing system state.
+	Force bool
+}
+
+// runGPG runs gpg from the default home directory in batch mode, so
+// that it never asks anything.
+var runGPG = func(input []byte, args ...string) ([]byte, error) {
+	return osutil.RunGPG("", input, append([]string{"--batch"}, args...)...)
+}
+
+func digest(data []byte) string {
+	h := fips.Sum512(data)
+	return hex.EncodeToString(h[:])
+}
+
+func snapRevisions(stateData []byte) (map[string]snap.Revision, error) {
+	st, err := state.ReadState(nil, bytes.NewReader(stateData))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read state: %v", err)
+	}
+	st.Lock()
+	defer st.Unlock()
+
+	all, err := snapstate.All(st)
+	if err != nil {
+		return nil, err
+	}
+	revs := make(map[string]snap.Revision, len(all))
+	for name, snapst := range all {
+		revs[name] = snapst.Current().Revision
+	}
+
+	return revs, nil
+}
+
+// member is a file added to the archive. Unless data holds its content,
+// it is read from path as it is written, so that the data of the snaps
+// is streamed into the archive instead of being held in memory.
+type member struct {
+	name string
+	path string
+	data []byte
+	size int64
+	mode os.FileMode
+}
+
+func (m *member) open() (io.ReadCloser, error) {
+	if m.path == "" {
+		return ioutil.NopCloser(bytes.NewReader(m.data)), nil
+	}
+	return os.Open(m.path)
+}
+
+// digest returns the sha512 digest of the content of the member.
+func (m *member) digest() (string, error) {
+	r, err := m.open()
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	h := fips.SHA512()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func dataMember(name string, data []byte) *member {
+	return &member{name: name, data: data, size: int64(len(data)), mode: 0600}
+}
+
+// collect returns the state and the members of the archive for the
+// state and the assertions.
+func collect() (stateData []byte, members []*member, err error) {
+	if osutil.FileExists(dirs.SnapStateLogFile) {
+		stateData, err = statelog.ReadData(dirs.SnapStateLogFile)
+	} else {
+		stateData, err = ioutil.ReadFile(dirs.SnapStateFile)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot read system state: %v", err)
+	}
+	members = append(members, dataMember(stateName, stateData))
+
+	members, err = collectDir(members, dirs.SnapAssertsDBDir, assertsPrefix, false)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot read assertions: %v", err)
+	}
+
+	return stateData, members, nil
+}
+
+// collectSnapshots adds to members the system data of the given snaps,
+// of their current revision and common to all revisions, keeping the
+// modes of the files.
+func collectSnapshots(members []*member, revs map[string]snap.Revision) ([]*member, error) {
+	for name, rev := range revs {
+		info := &snap.Info{SideInfo: snap.SideInfo{OfficialName: name, Revision: rev}}
+		for _, dir := range []string{info.DataDir(), info.CommonDataDir()} {
+			rel, err := filepath.Rel(dirs.SnapDataDir, dir)
+			if err != nil {
+				return nil, err
+			}
+			members, err = collectDir(members, dir, snapshotsPrefix+filepath.ToSlash(rel)+"/", true)
+			if err != nil {
+				return nil, fmt.Errorf("cannot read data of snap %q: %v", name, err)
+			}
+		}
+	}
+	return members, nil
+}
+
+// collectDir adds to members the regular files under dir, if it exists,
+// with prefix added to their path relative to it, keeping their modes
+// if keepModes is set.
+func collectDir(members []*member, dir, prefix string, keepModes bool) ([]*member, error) {
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == dir {
+				return nil
+			}
+			return err
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		mode := os.FileMode(0600)
+		if keepModes {
+			mode = fi.Mode().Perm()
+		}
+		members = append(members, &member{
+			name: prefix + filepath.ToSlash(rel),
+			path: path,
+			size: fi.Size(),
+			mode: mode,
+		})
+		return nil
+	})
+	return members, err
+}
+
+// writeMember copies the member into the archive, checking it still
+// has the digest it is listed with in the manifest.
+func writeMember(tw *tar.Writer, m *member, expected string) error {
+	r, err := m.open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	hdr := &tar.Header{
+		Name: m.name,
+		Mode: int64(m.mode),
+		Size: m.size,
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	h := fips.SHA512()
+	n, err := io.Copy(io.MultiWriter(tw, h), r)
+	if err == tar.ErrWriteTooLong || (err == nil && (n != m.size || hex.EncodeToString(h.Sum(nil)) != expected)) {
+		return fmt.Errorf("cannot export %q: file changed while exporting", m.name)
+	}
+	return err
+}
+
+// Export writes the current system state, including assertions, and
+// the system data of the snaps as a gzipped tar archive to w and returns
+// its manifest.
+func Export(w io.Writer, opts *ExportOptions) (*Manifest, error) {
+	if opts == nil {
+		opts = &ExportOptions{}
+	}
+
+	stateData, members, err := collect()
+	if err != nil {
+		return nil, err
+	}
+
+	revs, err := snapRevisions(stateData)
+	if err != nil {
+		return nil, err
+	}
+	members, err = collectSnapshots(members, revs)
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(byName(members))
+
+	manifest := &Manifest{
+		Format:  Format,
+		Series:  release.Series,
+		Version: cmd.Version,
+		Snaps:   revs,
+		Files:   make(map[string]string, len(members)),
+	}
+	for _, m := range members {
+		manifest.Files[m.name], err = m.digest()
+		if err != nil {
+			return nil, fmt.Errorf("cannot export %q: %v", m.name, err)
+		}
+	}
+
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	// the manifest and its signature come first, as they describe the
+	// rest of the archive
+	head := []*member{dataMember(manifestName, manifestData)}
+	if opts.KeyID != "" {
+		sig, err := runGPG(manifestData, "--default-key", "0x"+opts.KeyID, "--detach-sign")
+		if err != nil {
+			return nil, fmt.Errorf("cannot sign state archive: %v", err)
+		}
+		head = append(head, dataMember(signatureName, sig))
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, m := range head {
+		if err := writeMember(tw, m, digest(m.data)); err != nil {
+			return nil, err
+		}
+	}
+	for _, m := range members {
+		if err := writeMember(tw, m, manifest.Files[m.name]); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+type byName []*member
+
+func (ms byName) Len() int           { return len(ms) }
+func (ms byName) Swap(i, j int)      { ms[i], ms[j] = ms[j], ms[i] }
+func (ms byName) Less(i, j int) bool { return ms[i].name < ms[j].name }
+
+// nextMember returns the header of the next member of the archive, and
+// its cleaned up name, or a nil header at its end.
+func nextMember(tr *tar.Reader) (*tar.Header, string, error) {
+	hdr, err := tr.Next()
+	if err == io.EOF {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("cannot read state archive: %v", err)
+	}
+	name := filepath.ToSlash(filepath.Clean(hdr.Name))
+	if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+		return nil, "", fmt.Errorf("cannot read state archive: invalid member name %q", hdr.Name)
+	}
+	return hdr, name, nil
+}
+
+// verifySignature checks the signature of the manifest against the
+// given keyring of the device owner only, so that archives signed by
+// any other key gpg knows about are refused.
+func verifySignature(manifestData, sig []byte, keyring string) error {
+	if _, err := os.Stat(keyring); err != nil {
+		return fmt.Errorf("no trusted keyring: %v", err)
+	}
+	f, err := ioutil.TempFile("", "snapd-state-sig")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if _, err := f.Write(sig); err != nil {
+		return err
+	}
+	_, err = runGPG(manifestData, "--no-default-keyring", "--keyring", keyring, "--verify", f.Name(), "-")
+	return err
+}
+
+// checkManifest verifies the signature of the manifest, if any, and
+// the manifest against the running system.
+func checkManifest(manifestData, sig []byte, opts *ImportOptions) (*Manifest, error) {
+	if sig != nil {
+		if err := verifySignature(manifestData, sig, dirs.SnapStateArchiveKeyring); err != nil {
+			return nil, fmt.Errorf("cannot verify state archive signature: %v", err)
+		}
+	} else if !opts.AllowUnsigned {
+		return nil, fmt.Errorf("cannot import state archive: manifest is not signed")
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(manifestData, &manifest); err != nil {
+		return nil, fmt.Errorf("cannot decode state archive manifest: %v", err)
+	}
+	if manifest.Format > Format {
+		return nil, fmt.Errorf("cannot import state archive: unsupported format %d (expected %d or earlier)", manifest.Format, Format)
+	}
+	if manifest.Series != release.Series {
+		return nil, fmt.Errorf("cannot import state archive: exported from series %q, system is series %q", manifest.Series, release.Series)
+	}
+	// the state of a newer snapd may hold what this one does not know
+	// about; development builds have no version to compare
+	if snappy.VersionIsValid(manifest.Version) && snappy.VersionIsValid(cmd.Version) && snappy.VersionCompare(manifest.Version, cmd.Version) > 0 {
+		return nil, fmt.Errorf("cannot import state archive: exported by snapd %s, newer than this snapd %s", manifest.Version, cmd.Version)
+	}
+
+	return &manifest, nil
+}
+
+// checkState verifies the state against the manifest and the snaps
+// present on the system.
+func checkState(stateData []byte, manifest *Manifest) error {
+	// the state must be one this snapd reads, as described by the manifest
+	revs, err := snapRevisions(stateData)
+	if err != nil {
+		return fmt.Errorf("cannot import state archive: %v", err)
+	}
+	for name, rev := range manifest.Snaps {
+		if revs[name] != rev {
+			return fmt.Errorf("cannot import state archive: state does not match manifest for snap %q", name)
+		}
+	}
+	if len(revs) != len(manifest.Snaps) {
+		return fmt.Errorf("cannot import state archive: state does not match manifest snaps")
+	}
+
+	// every snap revision the state refers to must be available locally
+	var missing []string
+	for name, rev := range manifest.Snaps {
+		info := &snap.Info{SideInfo: snap.SideInfo{OfficialName: name, Revision: rev}}
+		if !osutil.FileExists(info.MountFile()) {
+			missing = append(missing, fmt.Sprintf("%s (revision %s)", name, rev))
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("cannot import state archive: missing snaps: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// stagedFile is a member of the archive extracted next to its target,
+// to be renamed into place once the whole archive is verified.
+type stagedFile struct {
+	tmp    string
+	target string
+}
+
+// memberTarget returns where the member with the given name is
+// installed, if anywhere, and with which modes.
+func memberTarget(name string, hdr *tar.Header) (target string, dirMode, mode os.FileMode) {
+	switch {
+	case strings.HasPrefix(name, assertsPrefix):
+		return filepath.Join(dirs.SnapAssertsDBDir, filepath.FromSlash(strings.TrimPrefix(name, assertsPrefix))), 0775, 0644
+	case strings.HasPrefix(name, snapshotsPrefix):
+		return filepath.Join(dirs.SnapDataDir, filepath.FromSlash(path.Clean(strings.TrimPrefix(name, snapshotsPrefix)))), 0755, os.FileMode(hdr.Mode).Perm()
+	}
+	return "", 0, 0
+}
+
+// stage copies the content of the current member of the archive to a
+// temporary file next to target, returning the digest of what it
+// copied.
+func stage(tr *tar.Reader, target string, dirMode, mode os.FileMode) (tmp, digest string, err error) {
+	if err := os.MkdirAll(filepath.Dir(target), dirMode); err != nil {
+		return "", "", err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(target), "."+filepath.Base(target)+"~")
+	if err != nil {
+		return "", "", err
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(f.Name())
+		}
+	}()
+
+	h := fips.SHA512()
+	_, err = io.Copy(io.MultiWriter(f, h), tr)
+	if err == nil {
+		err = f.Chmod(mode)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	return f.Name(), hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// extract verifies the members of the archive following the manifest
+// as they are read, staging the ones to install and returning the
+// state.
+func extract(tr *tar.Reader, hdr *tar.Header, name string, manifest *Manifest) (stateData []byte, staged []stagedFile, err error) {
+	defer func() {
+		if err != nil {
+			for _, sf := range staged {
+				os.Remove(sf.tmp)
+			}
+		}
+	}()
+
+	seen := make(map[string]bool)
+	for ; hdr != nil; hdr, name, err = nextMember(tr) {
+		expected, ok := manifest.Files[name]
+		if !ok || seen[name] {
+			return nil, staged, fmt.Errorf("cannot import state archive: unexpected member %q", name)
+		}
+		seen[name] = true
+		if strings.HasPrefix(name, snapshotsPrefix) {
+			snapName := strings.SplitN(strings.TrimPrefix(name, snapshotsPrefix), "/", 2)[0]
+			if _, ok := manifest.Snaps[snapName]; !ok {
+				return nil, staged, fmt.Errorf("cannot import state archive: snapshot of unknown snap %q", snapName)
+			}
+		}
+
+		var got string
+		if target, dirMode, mode := memberTarget(name, hdr); target != "" {
+			tmp, d, err := stage(tr, target, dirMode, mode)
+			if err != nil {
+				return nil, staged, fmt.Errorf("cannot import state archive: %v", err)
+			}
+			staged = append(staged, stagedFile{tmp: tmp, target: target})
+			got = d
+		} else {
+			data, err := ioutil.ReadAll(tr)
+			if err != nil {
+				return nil, staged, fmt.Errorf("cannot read state archive: %v", err)
+			}
+			if name == stateName {
+				stateData = data
+			}
+			got = digest(data)
+		}
+		if got != expected {
+			return nil, staged, fmt.Errorf("cannot import state archive: digest mismatch for %q", name)
+		}
+	}
+	if err != nil {
+		return nil, staged, err
+	}
+
+	if stateData == nil {
+		return nil, staged, fmt.Errorf("cannot import state archive: missing member %q", stateName)
+	}
+	for name := range manifest.Files {
+		if !seen[name] {
+			return nil, staged, fmt.Errorf("cannot import state archive: missing member %q", name)
+		}
+	}
+
+	return stateData, staged, nil
+}
+
+// Import reads a state archive written by Export from r, checks it is
+// compatible with this system and installs its state, assertions and
+// the snapshots of the data of the snaps.
+// The snaps it refers to need to be already present on the system, and
+// snapd must not be running.
+func Import(r io.Reader, opts *ImportOptions) (*Manifest, error) {
+	if opts == nil {
+		opts = &ImportOptions{}
+	}
+
+	// snapd holds the lock of the state for as long as it runs; holding
+	// it here also keeps it from starting while the state is replaced
+	if err := os.MkdirAll(filepath.Dir(dirs.SnapStateLockFile), 0755); err != nil {
+		return nil, err
+	}
+	lock, err := osutil.TryLockFile(dirs.SnapStateLockFile)
+	if err == osutil.ErrAlreadyLocked {
+		return nil, fmt.Errorf("cannot import state archive: snapd is running")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot import state archive: %v", err)
+	}
+	defer lock.Close()
+
+	if !opts.Force {
+		for _, fn := range []string{dirs.SnapStateFile, dirs.SnapStateLogFile} {
+			if osutil.FileExists(fn) {
+				return nil, fmt.Errorf("cannot import state archive: state %q already exists", fn)
+			}
+		}
+	}
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read state archive: %v", err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+
+	// the manifest and its signature come first, so that the rest of
+	// the archive is verified as it is read
+	hdr, name, err := nextMember(tr)
+	if err != nil {
+		return nil, err
+	}
+	if name != manifestName {
+		return nil, fmt.Errorf("cannot import state archive: missing manifest")
+	}
+	manifestData, err := ioutil.ReadAll(tr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read state archive: %v", err)
+	}
+	var sig []byte
+	hdr, name, err = nextMember(tr)
+	if err != nil {
+		return nil, err
+	}
+	if name == signatureName {
+		if sig, err = ioutil.ReadAll(tr); err != nil {
+			return nil, fmt.Errorf("cannot read state archive: %v", err)
+		}
+		if hdr, name, err = nextMember(tr); err != nil {
+			return nil, err
+		}
+	}
+	manifest, err := checkManifest(manifestData, sig, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	stateData, staged, err := extract(tr, hdr, name, manifest)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkState(stateData, manifest); err != nil {
+		for _, sf := range staged {
+			os.Remove(sf.tmp)
+		}
+		return nil, err
+	}
+
+	for i, sf := range staged {
+		if err := os.Rename(sf.tmp, sf.target); err != nil {
+			for _, sf := range staged[i:] {
+				os.Remove(sf.tmp)
+			}
+			return nil, err
+		}
+	}
+
+	if osutil.FileExists(dirs.SnapStateLogFile) {
+		if err := writeStateLog(stateData); err != nil {
+			return nil, err
+		}
+		return manifest, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dirs.SnapStateFile), 0755); err != nil {
+		return nil, err
+	}
+	if err := osutil.AtomicWriteFile(dirs.SnapStateFile, stateData, 0600, 0); err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// writeStateLog replaces the state kept in the state log of a system
+// that uses one.
+func writeStateLog(data []byte) error {
+	log, err := statelog.Open(dirs.SnapStateLogFile)
+	if err != nil {
+		return err
+	}
+	defer log.Close()
+	return log.Checkpoint(data)
+}
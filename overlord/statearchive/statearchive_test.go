@@ -0,0 +1,360 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package statearchive_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/statearchive"
+	"github.com/snapcore/snapd/overlord/statelog"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/snap"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type archiveSuite struct {
+	restore func()
+	gpgArgs [][]string
+}
+
+var _ = Suite(&archiveSuite{})
+
+const stateJSON = `{"data":{"snaps":{"foo":{"sequence":[{"name":"foo","revision":"3"},{"name":"foo","revision":"7"}],"active":true}},"conns":{"foo:plug bar:slot":{"interface":"network"}}}}`
+
+func (s *archiveSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	s.gpgArgs = nil
+	s.restore = statearchive.MockRunGPG(func(input []byte, args ...string) ([]byte, error) {
+		s.gpgArgs = append(s.gpgArgs, args)
+		return []byte("signature"), nil
+	})
+
+	c.Assert(os.MkdirAll(filepath.Dir(dirs.SnapStateFile), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(dirs.SnapStateFile, []byte(stateJSON), 0600), IsNil)
+	accDir := filepath.Join(dirs.SnapAssertsDBDir, "asserts-v0", "account", "acc-id")
+	c.Assert(os.MkdirAll(accDir, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(accDir, "0"), []byte("account assertion"), 0644), IsNil)
+
+	// system data of the current and a previous revision of foo
+	for _, dir := range []string{"7/etc", "3", "common"} {
+		c.Assert(os.MkdirAll(filepath.Join(dirs.SnapDataDir, "foo", dir), 0755), IsNil)
+	}
+	c.Assert(ioutil.WriteFile(filepath.Join(dirs.SnapDataDir, "foo", "7", "etc", "foo.conf"), []byte("conf"), 0640), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dirs.SnapDataDir, "foo", "3", "old"), []byte("old"), 0644), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dirs.SnapDataDir, "foo", "common", "db"), []byte("db"), 0600), IsNil)
+}
+
+func (s *archiveSuite) TearDownTest(c *C) {
+	s.restore()
+	dirs.SetRootDir("/")
+}
+
+// moveToNewDevice removes the exported state, making the snap blobs
+// for the given snaps and the keyring of the device owner available as
+// a replacement device would have them.
+func (s *archiveSuite) moveToNewDevice(c *C, revs map[string]snap.Revision) {
+	dirs.SetRootDir(c.MkDir())
+	c.Assert(os.MkdirAll(filepath.Dir(dirs.SnapStateArchiveKeyring), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(dirs.SnapStateArchiveKeyring, nil, 0644), IsNil)
+	c.Assert(os.MkdirAll(dirs.SnapBlobDir, 0755), IsNil)
+	for name, rev := range revs {
+		info := &snap.Info{SideInfo: snap.SideInfo{OfficialName: name, Revision: rev}}
+		c.Assert(ioutil.WriteFile(info.MountFile(), nil, 0644), IsNil)
+	}
+}
+
+func (s *archiveSuite) TestExportImportRoundtrip(c *C) {
+	var buf bytes.Buffer
+	manifest, err := statearchive.Export(&buf, &statearchive.ExportOptions{KeyID: "abcd"})
+	c.Assert(err, IsNil)
+	c.Check(manifest.Format, Equals, statearchive.Format)
+	c.Check(manifest.Series, Equals, release.Series)
+	c.Check(manifest.Snaps, DeepEquals, map[string]snap.Revision{"foo": snap.R(7)})
+	c.Check(manifest.Files, HasLen, 4)
+	c.Check(s.gpgArgs, DeepEquals, [][]string{{"--default-key", "0xabcd", "--detach-sign"}})
+
+	s.moveToNewDevice(c, manifest.Snaps)
+
+	imported, err := statearchive.Import(&buf, nil)
+	c.Assert(err, IsNil)
+	c.Check(imported, DeepEquals, manifest)
+	c.Assert(s.gpgArgs, HasLen, 2)
+	c.Check(s.gpgArgs[1][:4], DeepEquals, []string{"--no-default-keyring", "--keyring", dirs.SnapStateArchiveKeyring, "--verify"})
+
+	data, err := ioutil.ReadFile(dirs.SnapStateFile)
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, stateJSON)
+	data, err = ioutil.ReadFile(filepath.Join(dirs.SnapAssertsDBDir, "asserts-v0", "account", "acc-id", "0"))
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, "account assertion")
+
+	// the data of the current revision came along, with its modes
+	for name, mode := range map[string]os.FileMode{"7/etc/foo.conf": 0640, "common/db": 0600} {
+		fi, err := os.Stat(filepath.Join(dirs.SnapDataDir, "foo", name))
+		c.Assert(err, IsNil)
+		c.Check(fi.Mode().Perm(), Equals, mode, Commentf(name))
+	}
+	c.Check(osutil.FileExists(filepath.Join(dirs.SnapDataDir, "foo", "3")), Equals, false)
+}
+
+func (s *archiveSuite) TestExportDataChanged(c *C) {
+	// the data of the snaps is read again as it is written, after the
+	// manifest is signed
+	restore := statearchive.MockRunGPG(func(input []byte, args ...string) ([]byte, error) {
+		c.Assert(ioutil.WriteFile(filepath.Join(dirs.SnapDataDir, "foo", "common", "db"), []byte("DB"), 0600), IsNil)
+		return []byte("signature"), nil
+	})
+	defer restore()
+
+	var buf bytes.Buffer
+	_, err := statearchive.Export(&buf, &statearchive.ExportOptions{KeyID: "abcd"})
+	c.Check(err, ErrorMatches, `cannot export "snapshots/foo/common/db": file changed while exporting`)
+}
+
+func (s *archiveSuite) TestImportRefusesUnsigned(c *C) {
+	var buf bytes.Buffer
+	manifest, err := statearchive.Export(&buf, nil)
+	c.Assert(err, IsNil)
+	c.Check(s.gpgArgs, HasLen, 0)
+
+	s.moveToNewDevice(c, manifest.Snaps)
+	archive := buf.Bytes()
+
+	_, err = statearchive.Import(bytes.NewReader(archive), nil)
+	c.Check(err, ErrorMatches, "cannot import state archive: manifest is not signed")
+
+	_, err = statearchive.Import(bytes.NewReader(archive), &statearchive.ImportOptions{AllowUnsigned: true})
+	c.Check(err, IsNil)
+}
+
+func (s *archiveSuite) TestImportMissingSnaps(c *C) {
+	var buf bytes.Buffer
+	_, err := statearchive.Export(&buf, nil)
+	c.Assert(err, IsNil)
+
+	s.moveToNewDevice(c, nil)
+
+	_, err = statearchive.Import(&buf, &statearchive.ImportOptions{AllowUnsigned: true})
+	c.Check(err, ErrorMatches, `cannot import state archive: missing snaps: foo \(revision 7\)`)
+	c.Check(osutil.FileExists(dirs.SnapStateFile), Equals, false)
+	// nothing extracted was left behind
+	for _, dir := range []string{filepath.Join(dirs.SnapAssertsDBDir, "asserts-v0", "account", "acc-id"), filepath.Join(dirs.SnapDataDir, "foo", "common")} {
+		fis, err := ioutil.ReadDir(dir)
+		c.Assert(err, IsNil)
+		c.Check(fis, HasLen, 0, Commentf(dir))
+	}
+}
+
+func (s *archiveSuite) TestImportRefusesWhileSnapdRuns(c *C) {
+	var buf bytes.Buffer
+	manifest, err := statearchive.Export(&buf, nil)
+	c.Assert(err, IsNil)
+	s.moveToNewDevice(c, manifest.Snaps)
+
+	c.Assert(os.MkdirAll(filepath.Dir(dirs.SnapStateLockFile), 0755), IsNil)
+	lock, err := osutil.TryLockFile(dirs.SnapStateLockFile)
+	c.Assert(err, IsNil)
+	defer lock.Close()
+
+	_, err = statearchive.Import(&buf, &statearchive.ImportOptions{AllowUnsigned: true})
+	c.Check(err, ErrorMatches, "cannot import state archive: snapd is running")
+	c.Check(osutil.FileExists(dirs.SnapStateFile), Equals, false)
+}
+
+func (s *archiveSuite) TestImportRefusesExistingState(c *C) {
+	var buf bytes.Buffer
+	_, err := statearchive.Export(&buf, nil)
+	c.Assert(err, IsNil)
+
+	_, err = statearchive.Import(&buf, &statearchive.ImportOptions{AllowUnsigned: true})
+	c.Check(err, ErrorMatches, `cannot import state archive: state ".*/state.json" already exists`)
+}
+
+func (s *archiveSuite) TestExportImportStateLog(c *C) {
+	c.Assert(os.Remove(dirs.SnapStateFile), IsNil)
+	log, err := statelog.Open(dirs.SnapStateLogFile)
+	c.Assert(err, IsNil)
+	c.Assert(log.Checkpoint([]byte(stateJSON)), IsNil)
+	c.Assert(log.Close(), IsNil)
+
+	var buf bytes.Buffer
+	manifest, err := statearchive.Export(&buf, nil)
+	c.Assert(err, IsNil)
+	c.Check(manifest.Snaps, DeepEquals, map[string]snap.Revision{"foo": snap.R(7)})
+
+	s.moveToNewDevice(c, manifest.Snaps)
+	log, err = statelog.Open(dirs.SnapStateLogFile)
+	c.Assert(err, IsNil)
+	c.Assert(log.Close(), IsNil)
+
+	_, err = statearchive.Import(bytes.NewReader(buf.Bytes()), &statearchive.ImportOptions{AllowUnsigned: true})
+	c.Check(err, ErrorMatches, `cannot import state archive: state ".*/state.log" already exists`)
+
+	_, err = statearchive.Import(&buf, &statearchive.ImportOptions{AllowUnsigned: true, Force: true})
+	c.Assert(err, IsNil)
+	c.Check(osutil.FileExists(dirs.SnapStateFile), Equals, false)
+	data, err := statelog.ReadData(dirs.SnapStateLogFile)
+	c.Assert(err, IsNil)
+	var got, expected map[string]interface{}
+	c.Assert(json.Unmarshal(data, &got), IsNil)
+	c.Assert(json.Unmarshal([]byte(stateJSON), &expected), IsNil)
+	c.Check(got, DeepEquals, expected)
+}
+
+func (s *archiveSuite) TestImportBadSignature(c *C) {
+	var buf bytes.Buffer
+	manifest, err := statearchive.Export(&buf, &statearchive.ExportOptions{KeyID: "abcd"})
+	c.Assert(err, IsNil)
+	s.moveToNewDevice(c, manifest.Snaps)
+
+	restore := statearchive.MockRunGPG(func(input []byte, args ...string) ([]byte, error) {
+		return nil, &os.PathError{Op: "gpg", Path: "-", Err: os.ErrInvalid}
+	})
+	defer restore()
+
+	_, err = statearchive.Import(&buf, nil)
+	c.Check(err, ErrorMatches, "cannot verify state archive signature: .*")
+}
+
+func (s *archiveSuite) TestImportNoKeyring(c *C) {
+	var buf bytes.Buffer
+	manifest, err := statearchive.Export(&buf, &statearchive.ExportOptions{KeyID: "abcd"})
+	c.Assert(err, IsNil)
+	s.moveToNewDevice(c, manifest.Snaps)
+	c.Assert(os.Remove(dirs.SnapStateArchiveKeyring), IsNil)
+	s.gpgArgs = nil
+
+	_, err = statearchive.Import(&buf, nil)
+	c.Check(err, ErrorMatches, "cannot verify state archive signature: no trusted keyring: .*")
+	c.Check(s.gpgArgs, HasLen, 0)
+}
+
+func writeArchive(c *C, members map[string]string) *bytes.Buffer {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, name := range []string{"manifest.json", "state.json", "snapshots/bar/common/db"} {
+		data, ok := members[name]
+		if !ok {
+			continue
+		}
+		c.Assert(tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(data))}), IsNil)
+		_, err := tw.Write([]byte(data))
+		c.Assert(err, IsNil)
+	}
+	c.Assert(tw.Close(), IsNil)
+	c.Assert(gz.Close(), IsNil)
+	return &buf
+}
+
+func (s *archiveSuite) TestImportChecks(c *C) {
+	s.moveToNewDevice(c, nil)
+
+	restore := statearchive.MockVersion("2.14")
+	defer restore()
+	fooState := `{"data":{"snaps":{"foo":{"sequence":[{"name":"foo","revision":"7"}],"active":true}}}}`
+	files := func(members ...string) string {
+		digests := make([]string, len(members))
+		for i, m := range members {
+			parts := strings.SplitN(m, "=", 2)
+			h := sha512.Sum512([]byte(parts[1]))
+			digests[i] = fmt.Sprintf("%q: %q", parts[0], hex.EncodeToString(h[:]))
+		}
+		return "{" + strings.Join(digests, ", ") + "}"
+	}
+
+	tests := []struct {
+		manifest string
+		state    string
+		snapshot string
+		err      string
+	}{
+		{`{"format": 2, "series": "16"}`, "{}", "", `cannot import state archive: unsupported format 2 \(expected 1 or earlier\)`},
+		{`{"format": 1, "series": "15.04"}`, "{}", "", `cannot import state archive: exported from series "15.04", system is series "16"`},
+		{`{"format": 1, "series": "16", "version": "2.15"}`, "{}", "", `cannot import state archive: exported by snapd 2.15, newer than this snapd 2.14`},
+		{`{"format": 1, "series": "16", "files": {"state.json": "00"}}`, "{}", "", `cannot import state archive: digest mismatch for "state.json"`},
+		{`{"format": 1, "series": "16"}`, "{}", "", `cannot import state archive: unexpected member "state.json"`},
+		{`{"format": 1, "series": "16"}`, "", "", `cannot import state archive: missing member "state.json"`},
+		{`{"format": 1, "series": "16", "files": ` + files("state.json=nope") + `}`, "nope", "", `cannot import state archive: cannot read state: .*`},
+		{`{"format": 1, "series": "16", "files": ` + files("state.json="+fooState) + `}`, fooState, "", `cannot import state archive: state does not match manifest snaps`},
+		{`{"format": 1, "series": "16", "snaps": {"foo": "3"}, "files": ` + files("state.json="+fooState) + `}`, fooState, "", `cannot import state archive: state does not match manifest for snap "foo"`},
+		{`{"format": 1, "series": "16", "snaps": {"foo": "7"}, "files": ` + files("state.json="+fooState, "snapshots/bar/common/db=db") + `}`, fooState, "db", `cannot import state archive: snapshot of unknown snap "bar"`},
+	}
+
+	for _, t := range tests {
+		members := map[string]string{"manifest.json": t.manifest}
+		if t.state != "" {
+			members["state.json"] = t.state
+		}
+		if t.snapshot != "" {
+			members["snapshots/bar/common/db"] = t.snapshot
+		}
+		_, err := statearchive.Import(writeArchive(c, members), &statearchive.ImportOptions{AllowUnsigned: true})
+		c.Check(err, ErrorMatches, t.err, Commentf(t.manifest))
+	}
+}
+
+func (s *archiveSuite) TestImportManifestFirst(c *C) {
+	s.moveToNewDevice(c, nil)
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, name := range []string{"state.json", "manifest.json"} {
+		c.Assert(tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: 2}), IsNil)
+		_, err := tw.Write([]byte("{}"))
+		c.Assert(err, IsNil)
+	}
+	c.Assert(tw.Close(), IsNil)
+	c.Assert(gz.Close(), IsNil)
+
+	_, err := statearchive.Import(&buf, &statearchive.ImportOptions{AllowUnsigned: true})
+	c.Check(err, ErrorMatches, "cannot import state archive: missing manifest")
+}
+
+func (s *archiveSuite) TestImportBadMemberName(c *C) {
+	s.moveToNewDevice(c, nil)
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	c.Assert(tw.WriteHeader(&tar.Header{Name: "../../etc/passwd", Mode: 0600}), IsNil)
+	c.Assert(tw.Close(), IsNil)
+	c.Assert(gz.Close(), IsNil)
+
+	_, err := statearchive.Import(&buf, &statearchive.ImportOptions{AllowUnsigned: true})
+	c.Check(err, ErrorMatches, `cannot read state archive: invalid member name "../../etc/passwd"`)
+}
@@ -21,6 +21,7 @@ package overlord
 
 import (
 	"fmt"
+	"runtime/debug"
 	"sync"
 
 	"github.com/snapcore/snapd/logger"
@@ -43,6 +44,14 @@ type StateManager interface {
 	Stop()
 }
 
+// memoryReleaser is implemented by the state managers keeping clients
+// or caches they can drop, to set them up again when next needed.
+type memoryReleaser interface {
+	ReleaseMemory()
+}
+
+var freeOSMemory = debug.FreeOSMemory
+
 // StateEngine controls the dispatching of state changes to state managers.
 //
 // Most of the actual work performed by the state engine is in fact done
@@ -136,3 +145,19 @@ func (se *StateEngine) Stop() {
 	}
 	se.stopped = true
 }
+
+// ReleaseMemory asks the managers to drop what they can set up again
+// when next needed, and returns the memory freed to the system.
+func (se *StateEngine) ReleaseMemory() {
+	se.mgrLock.Lock()
+	defer se.mgrLock.Unlock()
+	if se.stopped {
+		return
+	}
+	for _, m := range se.managers {
+		if r, ok := m.(memoryReleaser); ok {
+			r.ReleaseMemory()
+		}
+	}
+	freeOSMemory()
+}
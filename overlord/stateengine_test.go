@@ -121,3 +121,36 @@ func (ses *stateEngineSuite) TestStop(c *C) {
 	err := se.Ensure()
 	c.Check(err, ErrorMatches, "state engine already stopped")
 }
+
+type releasingManager struct {
+	fakeManager
+}
+
+func (rm *releasingManager) ReleaseMemory() {
+	*rm.calls = append(*rm.calls, "release:"+rm.name)
+}
+
+func (ses *stateEngineSuite) TestReleaseMemory(c *C) {
+	s := state.New(nil)
+	se := overlord.NewStateEngine(s)
+
+	calls := []string{}
+	restore := overlord.MockFreeOSMemory(func() {
+		calls = append(calls, "free")
+	})
+	defer restore()
+
+	mgr1 := &fakeManager{name: "mgr1", calls: &calls}
+	mgr2 := &releasingManager{fakeManager{name: "mgr2", calls: &calls}}
+
+	se.AddManager(mgr1)
+	se.AddManager(mgr2)
+
+	se.ReleaseMemory()
+	c.Check(calls, DeepEquals, []string{"release:mgr2", "free"})
+
+	se.Stop()
+	calls = nil
+	se.ReleaseMemory()
+	c.Check(calls, HasLen, 0)
+}
@@ -0,0 +1,30 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package statelog
+
+// MockCompaction mocks the size under which the log is never compacted
+// and how many times the size of the state it can grow to.
+func MockCompaction(minSize, ratio int64) (restore func()) {
+	oldMinSize, oldRatio := compactMinSize, compactRatio
+	compactMinSize, compactRatio = minSize, ratio
+	return func() {
+		compactMinSize, compactRatio = oldMinSize, oldRatio
+	}
+}
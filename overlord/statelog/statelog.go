@@ -0,0 +1,376 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package statelog keeps the state of snapd in an append-only log of
+// transactions, instead of rewriting the whole state file each time it
+// is checkpointed, for devices where the write amplification of the
+// latter wears out the flash storage. Each checkpoint only appends the
+// entries of the state that changed, and the log is compacted once it
+// grows well past the size of the state.
+package statelog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+)
+
+// header starts the log file, telling its format.
+const header = "snapd-state-log 1\n"
+
+var (
+	// compactMinSize is the size under which the log is never compacted.
+	compactMinSize int64 = 1024 * 1024
+	// compactRatio is how many times the size of the state the log can
+	// grow to before being compacted.
+	compactRatio int64 = 4
+)
+
+// A Log keeps the state in a file as a log of transactions, each
+// setting and deleting entries of the state. A transaction cut short
+// by a crash is dropped when the log is opened again, so the state is
+// always the one of the last complete checkpoint.
+type Log struct {
+	path string
+	f    *os.File
+	// entries are the current entries of the state.
+	entries map[string]json.RawMessage
+	// size is the size of the log file.
+	size int64
+	// compacted is the size of the log once compacted, as of the
+	// last compaction or opening.
+	compacted int64
+}
+
+// A record is a transaction, as written to the log.
+type record struct {
+	Set map[string]json.RawMessage `json:"set,omitempty"`
+	Del []string                   `json:"del,omitempty"`
+}
+
+// Open opens the log at the given path, creating an empty one if there
+// is none.
+func Open(path string) (*Log, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open state log: %v", err)
+	}
+	l := &Log{path: path, f: f, entries: make(map[string]json.RawMessage)}
+	if err := l.load(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// load reads the transactions of the log, dropping a last one that is
+// incomplete or corrupted, and writes the header of an empty log.
+func (l *Log) load() error {
+	fi, err := l.f.Stat()
+	if err != nil {
+		return fmt.Errorf("cannot open state log: %v", err)
+	}
+	if fi.Size() == 0 {
+		if _, err := l.f.WriteString(header); err != nil {
+			return fmt.Errorf("cannot write state log: %v", err)
+		}
+		if err := l.f.Sync(); err != nil {
+			return fmt.Errorf("cannot write state log: %v", err)
+		}
+		l.size = int64(len(header))
+		l.compacted = l.size
+		return nil
+	}
+
+	good, err := l.replay()
+	if err != nil {
+		if _, ok := err.(*badRecordError); !ok {
+			return err
+		}
+		logger.Noticef("Dropping the last transaction of the state log, at offset %d: %v", good, err)
+		if err := l.f.Truncate(good); err != nil {
+			return fmt.Errorf("cannot repair state log: %v", err)
+		}
+	}
+	l.size = good
+	buf, err := encodeRecord(&record{Set: l.entries})
+	if err != nil {
+		return err
+	}
+	l.compacted = int64(len(header) + len(buf))
+	return nil
+}
+
+// badRecordError is about a transaction of the log that is incomplete
+// or corrupted.
+type badRecordError struct {
+	msg string
+}
+
+func (e *badRecordError) Error() string {
+	return e.msg
+}
+
+// replay applies the transactions of the log file, read from its
+// start, returning the offset of the end of the last complete one. A
+// badRecordError is returned for a transaction that is not.
+func (l *Log) replay() (int64, error) {
+	r := bufio.NewReader(l.f)
+	head := make([]byte, len(header))
+	if _, err := io.ReadFull(r, head); err != nil || string(head) != header {
+		return 0, fmt.Errorf("cannot open state log: %s is not a state log", l.path)
+	}
+	good := int64(len(header))
+	for {
+		rec, n, err := readRecord(r)
+		if err == io.EOF {
+			return good, nil
+		}
+		if err != nil {
+			return good, err
+		}
+		l.apply(rec)
+		good += n
+	}
+}
+
+// readRecord reads a record, framed by its size and CRC32, returning
+// how many bytes it took. io.EOF is returned at the end of the log.
+func readRecord(r io.Reader) (*record, int64, error) {
+	var frame [8]byte
+	n, err := io.ReadFull(r, frame[:])
+	if err == io.EOF {
+		return nil, 0, io.EOF
+	}
+	if err != nil {
+		return nil, 0, &badRecordError{fmt.Sprintf("truncated transaction (%d bytes)", n)}
+	}
+	size := binary.BigEndian.Uint32(frame[:4])
+	sum := binary.BigEndian.Uint32(frame[4:])
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, 0, &badRecordError{"truncated transaction"}
+	}
+	if crc32.ChecksumIEEE(payload) != sum {
+		return nil, 0, &badRecordError{"corrupted transaction"}
+	}
+	var rec record
+	if err := json.Unmarshal(payload, &rec); err != nil {
+		return nil, 0, &badRecordError{fmt.Sprintf("cannot decode transaction: %v", err)}
+	}
+	return &rec, int64(len(frame)) + int64(size), nil
+}
+
+// encodeRecord returns the record framed by its size and CRC32.
+func encodeRecord(rec *record) ([]byte, error) {
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 8, 8+len(payload))
+	binary.BigEndian.PutUint32(buf[:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(buf[4:], crc32.ChecksumIEEE(payload))
+	return append(buf, payload...), nil
+}
+
+// apply makes the changes of the record to the entries.
+func (l *Log) apply(rec *record) {
+	for _, key := range rec.Del {
+		delete(l.entries, key)
+	}
+	for key, value := range rec.Set {
+		l.entries[key] = value
+	}
+}
+
+// splitState splits the JSON object of the state into entries: its
+// members, with the members of those that are objects themselves, such
+// as the data, changes and tasks of the state, as entries of their own
+// keyed "<member>/<key>" so that they are written only when they change.
+// The objects split are kept as empty objects.
+func splitState(data []byte) (map[string]json.RawMessage, error) {
+	var top map[string]json.RawMessage
+	if err := json.Unmarshal(data, &top); err != nil {
+		return nil, fmt.Errorf("cannot split state: %v", err)
+	}
+	entries := make(map[string]json.RawMessage, len(top))
+	for key, value := range top {
+		if strings.Contains(key, "/") {
+			return nil, fmt.Errorf("cannot split state: invalid key %q", key)
+		}
+		if !bytes.HasPrefix(bytes.TrimSpace(value), []byte("{")) {
+			entries[key] = value
+			continue
+		}
+		var members map[string]json.RawMessage
+		if err := json.Unmarshal(value, &members); err != nil {
+			return nil, fmt.Errorf("cannot split state: %v", err)
+		}
+		entries[key] = json.RawMessage("{}")
+		for member, v := range members {
+			entries[key+"/"+member] = v
+		}
+	}
+	return entries, nil
+}
+
+// joinState puts the entries back together into the JSON object of
+// the state.
+func joinState(entries map[string]json.RawMessage) ([]byte, error) {
+	top := make(map[string]interface{})
+	for key, value := range entries {
+		if !strings.Contains(key, "/") {
+			if string(value) == "{}" {
+				if _, ok := top[key]; !ok {
+					top[key] = make(map[string]json.RawMessage)
+				}
+				continue
+			}
+			top[key] = value
+		}
+	}
+	for key, value := range entries {
+		i := strings.Index(key, "/")
+		if i < 0 {
+			continue
+		}
+		members, ok := top[key[:i]].(map[string]json.RawMessage)
+		if !ok {
+			return nil, fmt.Errorf("cannot join state: entry %q is not in an object", key)
+		}
+		members[key[i+1:]] = value
+	}
+	return json.Marshal(top)
+}
+
+// Data returns the state, as the JSON checkpointed last, or nil if
+// there is none yet.
+func (l *Log) Data() ([]byte, error) {
+	if len(l.entries) == 0 {
+		return nil, nil
+	}
+	return joinState(l.entries)
+}
+
+// Checkpoint records the given state, the JSON of state.State, in a
+// transaction of the entries that changed since the last checkpoint.
+// Nothing is written when nothing changed. The log is compacted first
+// when it grew too big.
+func (l *Log) Checkpoint(data []byte) error {
+	entries, err := splitState(data)
+	if err != nil {
+		return err
+	}
+	rec := &record{Set: make(map[string]json.RawMessage)}
+	for key, value := range entries {
+		if old, ok := l.entries[key]; !ok || !bytes.Equal(old, value) {
+			rec.Set[key] = value
+		}
+	}
+	for key := range l.entries {
+		if _, ok := entries[key]; !ok {
+			rec.Del = append(rec.Del, key)
+		}
+	}
+	if len(rec.Set) == 0 && len(rec.Del) == 0 {
+		return nil
+	}
+	sort.Strings(rec.Del)
+
+	buf, err := encodeRecord(rec)
+	if err != nil {
+		return err
+	}
+	if grown := l.size + int64(len(buf)); grown > compactMinSize && grown > compactRatio*l.compacted {
+		return l.compact(entries)
+	}
+
+	if _, err := l.f.Write(buf); err != nil {
+		l.rewind()
+		return fmt.Errorf("cannot write state log: %v", err)
+	}
+	if err := l.f.Sync(); err != nil {
+		l.rewind()
+		return fmt.Errorf("cannot write state log: %v", err)
+	}
+	l.apply(rec)
+	l.size += int64(len(buf))
+	return nil
+}
+
+// rewind drops what was written of a transaction that failed.
+func (l *Log) rewind() {
+	l.f.Truncate(l.size)
+}
+
+// compact replaces the log with one made of a single transaction
+// setting all of the given entries, which become the current ones.
+func (l *Log) compact(entries map[string]json.RawMessage) error {
+	buf, err := encodeRecord(&record{Set: entries})
+	if err != nil {
+		return err
+	}
+	data := append([]byte(header), buf...)
+	if err := osutil.AtomicWriteFile(l.path, data, 0600, 0); err != nil {
+		return fmt.Errorf("cannot compact state log: %v", err)
+	}
+	f, err := os.OpenFile(l.path, os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("cannot compact state log: %v", err)
+	}
+	l.f.Close()
+	l.f = f
+	l.size = int64(len(data))
+	l.compacted = l.size
+	l.entries = entries
+	return nil
+}
+
+// Close closes the log.
+func (l *Log) Close() error {
+	return l.f.Close()
+}
+
+// ReadData returns the state kept in the log at the given path, as
+// Log.Data, leaving the log alone.
+func ReadData(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open state log: %v", err)
+	}
+	defer f.Close()
+	l := &Log{path: path, f: f, entries: make(map[string]json.RawMessage)}
+	if _, err := l.replay(); err != nil {
+		if _, ok := err.(*badRecordError); !ok {
+			return nil, err
+		}
+		// dropped when the log is opened
+	}
+	return l.Data()
+}
@@ -0,0 +1,252 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package statelog_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/overlord/statelog"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type statelogSuite struct {
+	path string
+}
+
+var _ = Suite(&statelogSuite{})
+
+func (s *statelogSuite) SetUpTest(c *C) {
+	s.path = filepath.Join(c.MkDir(), "state.log")
+}
+
+func (s *statelogSuite) size(c *C) int64 {
+	fi, err := os.Stat(s.path)
+	c.Assert(err, IsNil)
+	return fi.Size()
+}
+
+// checkpointData returns the JSON of a state with the given data and
+// a change with a task.
+func checkpointData(c *C, data map[string]interface{}) []byte {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+	for k, v := range data {
+		st.Set(k, v)
+	}
+	chg := st.NewChange("install", "install a snap")
+	chg.AddTask(st.NewTask("download", "download a snap"))
+	b, err := json.Marshal(st)
+	c.Assert(err, IsNil)
+	return b
+}
+
+// sameState checks that the given JSON is the one of the same state
+// as expected.
+func sameState(c *C, obtained, expected []byte) {
+	var o, e interface{}
+	c.Assert(json.Unmarshal(obtained, &o), IsNil)
+	c.Assert(json.Unmarshal(expected, &e), IsNil)
+	c.Check(o, DeepEquals, e)
+}
+
+func (s *statelogSuite) TestOpenEmpty(c *C) {
+	l, err := statelog.Open(s.path)
+	c.Assert(err, IsNil)
+	defer l.Close()
+
+	data, err := l.Data()
+	c.Assert(err, IsNil)
+	c.Check(data, IsNil)
+	content, err := ioutil.ReadFile(s.path)
+	c.Assert(err, IsNil)
+	c.Check(string(content), Equals, "snapd-state-log 1\n")
+}
+
+func (s *statelogSuite) TestCheckpointRoundtrip(c *C) {
+	l, err := statelog.Open(s.path)
+	c.Assert(err, IsNil)
+	first := checkpointData(c, map[string]interface{}{"a": 1, "b": map[string]string{"x": "y"}})
+	c.Assert(l.Checkpoint(first), IsNil)
+	data, err := l.Data()
+	c.Assert(err, IsNil)
+	sameState(c, data, first)
+
+	second := checkpointData(c, map[string]interface{}{"a": 2})
+	c.Assert(l.Checkpoint(second), IsNil)
+	c.Assert(l.Close(), IsNil)
+
+	l, err = statelog.Open(s.path)
+	c.Assert(err, IsNil)
+	defer l.Close()
+	data, err = l.Data()
+	c.Assert(err, IsNil)
+	sameState(c, data, second)
+
+	// what is read back is a state
+	st, err := state.ReadState(nil, bytes.NewReader(data))
+	c.Assert(err, IsNil)
+	st.Lock()
+	defer st.Unlock()
+	var a int
+	c.Check(st.Get("a", &a), IsNil)
+	c.Check(a, Equals, 2)
+	c.Check(st.Get("b", &a), Equals, state.ErrNoState)
+	c.Check(st.Changes(), HasLen, 1)
+}
+
+func (s *statelogSuite) TestCheckpointAppendsChanges(c *C) {
+	l, err := statelog.Open(s.path)
+	c.Assert(err, IsNil)
+	defer l.Close()
+
+	big := string(bytes.Repeat([]byte("x"), 4096))
+	c.Assert(l.Checkpoint([]byte(`{"data":{"big":"`+big+`","small":1},"last-task-id":1}`)), IsNil)
+	size := s.size(c)
+	c.Check(size > 4096, Equals, true)
+
+	// only what changed is written
+	c.Assert(l.Checkpoint([]byte(`{"data":{"big":"`+big+`","small":2},"last-task-id":1}`)), IsNil)
+	grown := s.size(c) - size
+	c.Check(grown > 0 && grown < 100, Equals, true, Commentf("grew by %d", grown))
+
+	// and nothing when nothing did
+	size = s.size(c)
+	c.Assert(l.Checkpoint([]byte(`{"data":{"big":"`+big+`","small":2},"last-task-id":1}`)), IsNil)
+	c.Check(s.size(c), Equals, size)
+
+	// removed entries are dropped
+	c.Assert(l.Checkpoint([]byte(`{"data":{"small":2},"last-task-id":null}`)), IsNil)
+	data, err := l.Data()
+	c.Assert(err, IsNil)
+	sameState(c, data, []byte(`{"data":{"small":2},"last-task-id":null}`))
+}
+
+func (s *statelogSuite) TestEmptyObjectsKept(c *C) {
+	l, err := statelog.Open(s.path)
+	c.Assert(err, IsNil)
+	defer l.Close()
+
+	c.Assert(l.Checkpoint([]byte(`{"data":{},"changes":{"1":{"id":"1"}}}`)), IsNil)
+	c.Assert(l.Checkpoint([]byte(`{"data":{},"changes":{}}`)), IsNil)
+	data, err := l.Data()
+	c.Assert(err, IsNil)
+	sameState(c, data, []byte(`{"data":{},"changes":{}}`))
+}
+
+func (s *statelogSuite) TestCheckpointInvalid(c *C) {
+	l, err := statelog.Open(s.path)
+	c.Assert(err, IsNil)
+	defer l.Close()
+
+	c.Check(l.Checkpoint([]byte(`[]`)), ErrorMatches, `cannot split state: .*`)
+	c.Check(l.Checkpoint([]byte(`{"a/b":1}`)), ErrorMatches, `cannot split state: invalid key "a/b"`)
+}
+
+func (s *statelogSuite) testDropsBadTail(c *C, spoil func(content []byte) []byte) {
+	l, err := statelog.Open(s.path)
+	c.Assert(err, IsNil)
+	c.Assert(l.Checkpoint([]byte(`{"data":{"a":1}}`)), IsNil)
+	good := s.size(c)
+	c.Assert(l.Checkpoint([]byte(`{"data":{"a":2}}`)), IsNil)
+	c.Assert(l.Close(), IsNil)
+
+	content, err := ioutil.ReadFile(s.path)
+	c.Assert(err, IsNil)
+	c.Assert(ioutil.WriteFile(s.path, spoil(content), 0600), IsNil)
+
+	data, err := statelog.ReadData(s.path)
+	c.Assert(err, IsNil)
+	sameState(c, data, []byte(`{"data":{"a":1}}`))
+
+	l, err = statelog.Open(s.path)
+	c.Assert(err, IsNil)
+	defer l.Close()
+	data, err = l.Data()
+	c.Assert(err, IsNil)
+	sameState(c, data, []byte(`{"data":{"a":1}}`))
+	// repaired
+	c.Check(s.size(c), Equals, good)
+
+	c.Assert(l.Checkpoint([]byte(`{"data":{"a":3}}`)), IsNil)
+	data, err = statelog.ReadData(s.path)
+	c.Assert(err, IsNil)
+	sameState(c, data, []byte(`{"data":{"a":3}}`))
+}
+
+func (s *statelogSuite) TestDropsTruncatedTransaction(c *C) {
+	s.testDropsBadTail(c, func(content []byte) []byte {
+		return content[:len(content)-3]
+	})
+}
+
+func (s *statelogSuite) TestDropsCorruptedTransaction(c *C) {
+	s.testDropsBadTail(c, func(content []byte) []byte {
+		content[len(content)-2] ^= 0xff
+		return content
+	})
+}
+
+func (s *statelogSuite) TestNotAStateLog(c *C) {
+	c.Assert(ioutil.WriteFile(s.path, []byte(`{"data":{}}`), 0600), IsNil)
+
+	_, err := statelog.Open(s.path)
+	c.Check(err, ErrorMatches, `cannot open state log: .*/state.log is not a state log`)
+	_, err = statelog.ReadData(s.path)
+	c.Check(err, ErrorMatches, `cannot open state log: .*/state.log is not a state log`)
+}
+
+func (s *statelogSuite) TestCompaction(c *C) {
+	restore := statelog.MockCompaction(0, 2)
+	defer restore()
+
+	l, err := statelog.Open(s.path)
+	c.Assert(err, IsNil)
+	defer l.Close()
+
+	c.Assert(l.Checkpoint([]byte(`{"data":{"a":1,"b":"bbbbbbbbbbbbbbbbbbbb"}}`)), IsNil)
+	compacted := s.size(c)
+	c.Assert(l.Checkpoint([]byte(`{"data":{"a":2,"b":"bbbbbbbbbbbbbbbbbbbb"}}`)), IsNil)
+	c.Check(s.size(c) > compacted, Equals, true)
+	for i := 3; i < 10; i++ {
+		c.Assert(l.Checkpoint([]byte(`{"data":{"a":`+strconv.Itoa(i)+`,"b":"bbbbbbbbbbbbbbbbbbbb"}}`)), IsNil)
+		c.Check(s.size(c) <= 2*compacted+10, Equals, true)
+	}
+
+	data, err := statelog.ReadData(s.path)
+	c.Assert(err, IsNil)
+	sameState(c, data, []byte(`{"data":{"a":9,"b":"bbbbbbbbbbbbbbbbbbbb"}}`))
+
+	// appending carries on after compacting
+	c.Assert(l.Checkpoint([]byte(`{"data":{"a":10}}`)), IsNil)
+	data, err = statelog.ReadData(s.path)
+	c.Assert(err, IsNil)
+	sameState(c, data, []byte(`{"data":{"a":10}}`))
+}
@@ -0,0 +1,133 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package partition
+
+import (
+	"fmt"
+	"strconv"
+)
+
+const (
+	// bootloader variables counting the attempts at booting a new
+	// kernel or OS: the bootloader increments bootAttemptsVar each time
+	// it boots in try mode, and once it reaches bootLimitVar it falls
+	// back to the last good kernel and OS and leaves try mode.
+	bootAttemptsVar = "snappy_boot_attempts"
+	bootLimitVar    = "snappy_boot_limit"
+)
+
+// DefaultBootLimit is how many times a new kernel or OS is attempted
+// to boot before the bootloader falls back to the last good ones.
+const DefaultBootLimit = 3
+
+// CountBootAttempts has the bootloader count the attempts at booting
+// the kernel or OS about to be tried, falling back after limit of them.
+func CountBootAttempts(bootloader Bootloader, limit int) error {
+	if err := bootloader.SetBootVar(bootAttemptsVar, "0"); err != nil {
+		return err
+	}
+	return bootloader.SetBootVar(bootLimitVar, strconv.Itoa(limit))
+}
+
+func intBootVar(bootloader Bootloader, name string) (int, error) {
+	value, err := bootloader.GetBootVar(name)
+	if err != nil {
+		return 0, err
+	}
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse boot variable %s=%q: %v", name, value, err)
+	}
+	return n, nil
+}
+
+// BootAttempts returns how many times the bootloader attempted to boot
+// the kernel or OS being tried, and how many times it will before
+// falling back; the limit is 0 if the attempts are not counted.
+func BootAttempts(bootloader Bootloader) (attempts, limit int, err error) {
+	if attempts, err = intBootVar(bootloader, bootAttemptsVar); err != nil {
+		return 0, 0, err
+	}
+	if limit, err = intBootVar(bootloader, bootLimitVar); err != nil {
+		return 0, 0, err
+	}
+	return attempts, limit, nil
+}
+
+// TryBooted returns whether the system booted a kernel or OS in try
+// mode, which is attempted again on the next boot unless the boot is
+// marked successful.
+func TryBooted(bootloader Bootloader) (bool, error) {
+	mode, err := bootloader.GetBootVar(bootmodeVar)
+	if err != nil {
+		return false, err
+	}
+	if mode != modeTry {
+		return false, nil
+	}
+	attempts, _, err := BootAttempts(bootloader)
+	if err != nil {
+		return false, err
+	}
+	if attempts > 0 {
+		return true, nil
+	}
+	// bootloaders not counting attempts flag the one trial boot
+	trial, err := bootloader.GetBootVar(trialBootVar)
+	if err != nil {
+		return false, err
+	}
+	return trial == "1", nil
+}
+
+// FailedTryBoot returns the kernel and OS snap files, such as
+// "core_42.snap", the bootloader gave up booting, falling back to the
+// last good ones. As the bootloader sets snappy_os and snappy_kernel
+// back to the good ones when falling back, the snaps tried are told by
+// snappy_try_os and snappy_try_kernel.
+func FailedTryBoot(bootloader Bootloader) ([]string, error) {
+	mode, err := bootloader.GetBootVar(bootmodeVar)
+	if err != nil {
+		return nil, err
+	}
+	if mode == modeTry {
+		// still trying
+		return nil, nil
+	}
+
+	var failed []string
+	for _, k := range []string{"snappy_os", "snappy_kernel"} {
+		tried, err := bootloader.GetBootVar(tryBootVar(k))
+		if err != nil {
+			return nil, err
+		}
+		good, err := bootloader.GetBootVar(goodBootVar(k))
+		if err != nil {
+			return nil, err
+		}
+		if tried != "" && tried != good {
+			failed = append(failed, tried)
+		}
+	}
+	return failed, nil
+}
@@ -0,0 +1,124 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package partition
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+func (s *PartitionTestSuite) TestCountBootAttempts(c *C) {
+	b := newMockBootloader()
+	attempts, limit, err := BootAttempts(b)
+	c.Assert(err, IsNil)
+	c.Check(attempts, Equals, 0)
+	c.Check(limit, Equals, 0)
+
+	c.Assert(CountBootAttempts(b, 3), IsNil)
+	c.Check(b.bootVars, DeepEquals, map[string]string{
+		"snappy_boot_attempts": "0",
+		"snappy_boot_limit":    "3",
+	})
+
+	// the bootloader counts
+	b.bootVars["snappy_boot_attempts"] = "2"
+	attempts, limit, err = BootAttempts(b)
+	c.Assert(err, IsNil)
+	c.Check(attempts, Equals, 2)
+	c.Check(limit, Equals, 3)
+
+	b.bootVars["snappy_boot_attempts"] = "x"
+	_, _, err = BootAttempts(b)
+	c.Check(err, ErrorMatches, `cannot parse boot variable snappy_boot_attempts="x": .*`)
+}
+
+func (s *PartitionTestSuite) TestTryBooted(c *C) {
+	b := newMockBootloader()
+	b.bootVars["snappy_mode"] = "try"
+	c.Assert(CountBootAttempts(b, 3), IsNil)
+
+	// not rebooted yet
+	tried, err := TryBooted(b)
+	c.Assert(err, IsNil)
+	c.Check(tried, Equals, false)
+
+	b.bootVars["snappy_boot_attempts"] = "1"
+	tried, err = TryBooted(b)
+	c.Assert(err, IsNil)
+	c.Check(tried, Equals, true)
+
+	// marking the boot successful starts over
+	c.Assert(MarkBootSuccessful(b), IsNil)
+	c.Check(b.bootVars["snappy_boot_attempts"], Equals, "0")
+	tried, err = TryBooted(b)
+	c.Assert(err, IsNil)
+	c.Check(tried, Equals, false)
+
+	// bootloaders not counting
+	b = newMockBootloader()
+	b.bootVars["snappy_mode"] = "try"
+	b.bootVars["snappy_trial_boot"] = "1"
+	tried, err = TryBooted(b)
+	c.Assert(err, IsNil)
+	c.Check(tried, Equals, true)
+}
+
+func (s *PartitionTestSuite) TestFailedTryBoot(c *C) {
+	b := newMockBootloader()
+	b.bootVars["snappy_os"] = "core_2.snap"
+	b.bootVars["snappy_try_os"] = "core_2.snap"
+	b.bootVars["snappy_good_os"] = "core_1.snap"
+	b.bootVars["snappy_kernel"] = "k_1.snap"
+	b.bootVars["snappy_good_kernel"] = "k_1.snap"
+	b.bootVars["snappy_boot_attempts"] = "3"
+	b.bootVars["snappy_boot_limit"] = "3"
+
+	// still trying
+	b.bootVars["snappy_mode"] = "try"
+	failed, err := FailedTryBoot(b)
+	c.Assert(err, IsNil)
+	c.Check(failed, HasLen, 0)
+
+	// given up as the documented scripts do, setting the snaps back to
+	// the good ones:
+	//   setenv snappy_mode regular
+	//   setenv snappy_os ${snappy_good_os}
+	//   setenv snappy_kernel ${snappy_good_kernel}
+	b.bootVars["snappy_mode"] = "regular"
+	b.bootVars["snappy_os"] = b.bootVars["snappy_good_os"]
+	b.bootVars["snappy_kernel"] = b.bootVars["snappy_good_kernel"]
+	failed, err = FailedTryBoot(b)
+	c.Assert(err, IsNil)
+	c.Check(failed, DeepEquals, []string{"core_2.snap"})
+
+	// booted successfully instead
+	b.bootVars["snappy_mode"] = "try"
+	b.bootVars["snappy_os"] = "core_2.snap"
+	c.Assert(MarkBootSuccessful(b), IsNil)
+	failed, err = FailedTryBoot(b)
+	c.Assert(err, IsNil)
+	c.Check(failed, HasLen, 0)
+
+	// nothing good known yet
+	b = newMockBootloader()
+	b.bootVars["snappy_os"] = "core_2.snap"
+	failed, err = FailedTryBoot(b)
+	c.Assert(err, IsNil)
+	c.Check(failed, HasLen, 0)
+}
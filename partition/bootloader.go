@@ -103,9 +103,7 @@ func MarkBootSuccessful(bootloader Bootloader) error {
 			return err
 		}
 
-		// FIXME: ugly string replace
-		newKey := strings.Replace(k, "snappy_", "snappy_good_", -1)
-		if err := bootloader.SetBootVar(newKey, value); err != nil {
+		if err := bootloader.SetBootVar(goodBootVar(k), value); err != nil {
 			return err
 		}
 
@@ -116,7 +114,35 @@ func MarkBootSuccessful(bootloader Bootloader) error {
 		if err := bootloader.SetBootVar("snappy_trial_boot", "0"); err != nil {
 			return err
 		}
+
+		// nothing is being tried anymore
+		if err := bootloader.SetBootVar(tryBootVar(k), ""); err != nil {
+			return err
+		}
+	}
+
+	// the next kernel or OS tried gets its own attempts
+	_, limit, err := BootAttempts(bootloader)
+	if err != nil {
+		return err
+	}
+	if limit > 0 {
+		return bootloader.SetBootVar(bootAttemptsVar, "0")
 	}
 
 	return nil
 }
+
+// goodBootVar returns the variable keeping the last good value of the
+// given boot variable, e.g. snappy_good_os for snappy_os.
+func goodBootVar(name string) string {
+	// FIXME: ugly string replace
+	return strings.Replace(name, "snappy_", "snappy_good_", -1)
+}
+
+// tryBootVar returns the variable keeping the kernel or OS being tried
+// for the given boot variable, e.g. snappy_try_os for snappy_os. Unlike
+// the variable itself, the bootloader leaves it alone when falling back.
+func tryBootVar(name string) string {
+	return strings.Replace(name, "snappy_", "snappy_try_", -1)
+}
@@ -91,5 +91,7 @@ func (s *PartitionTestSuite) TestMarkBootSuccessfulAllSnap(c *C) {
 		"snappy_good_kernel": "k1",
 		"snappy_os":          "os1",
 		"snappy_good_os":     "os1",
+		"snappy_try_kernel":  "",
+		"snappy_try_os":      "",
 	})
 }
@@ -0,0 +1,139 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package policy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// apparmorParser runs apparmor_parser with the given arguments, mocked
+// in the tests.
+var apparmorParser = func(args ...string) error {
+	if output, err := exec.Command("apparmor_parser", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("apparmor_parser %s failed: %v (%s)", strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// apparmorSets are the kinds of policy of the apparmor backend. The
+// templates are whole profiles once their placeholders are filled in,
+// and are checked with the parser before being installed, see
+// WithTemplateExpander; the policy groups are only fragments of
+// profiles.
+var apparmorSets = []policySet{
+	{kind: "policygroups", glob: "policygroups/*", subdir: "policygroups"},
+	{kind: "templates", glob: "templates/*", subdir: "templates", validate: validateCheckTemplate},
+}
+
+// checkTemplateReplacer fills in the placeholders of a template the
+// way the interfaces backend does for an application of a snap.
+var checkTemplateReplacer = strings.NewReplacer(
+	"###VAR###", `@{APP_NAME}="app"
+@{SNAP_NAME}="policy-check"
+@{SNAP_REVISION}="1"
+@{INSTALL_DIR}="/snap"`,
+	"###PROFILEATTACH###", `profile "snap.policy-check.app"`,
+	"###SNIPPETS###", "",
+)
+
+// expandCheckTemplate makes the given template into the profile of an
+// application made up to check it.
+func expandCheckTemplate(template []byte) []byte {
+	return []byte(checkTemplateReplacer.Replace(string(template)))
+}
+
+// validateCheckTemplate checks the given apparmor template made into
+// the profile of an application made up to check it.
+func validateCheckTemplate(path string) error {
+	return validateAppArmorTemplate(path, expandCheckTemplate)
+}
+
+// apparmorBackend returns the backend of the apparmor policy groups and
+// templates, which the generated profiles of the snaps are made from:
+// the profiles regenerated after the policy changed are reloaded.
+func apparmorBackend() *policyBackend {
+	return &policyBackend{
+		name:        "apparmor",
+		sets:        apparmorSets,
+		postInstall: reloadAppArmorProfiles,
+	}
+}
+
+// reloadAppArmorProfiles reloads the profiles of the snaps using the
+// changed policy, as regenerated from it. The generated profiles hold
+// the policy they are made from, so there is nothing to reload for
+// the policy files themselves.
+func reloadAppArmorProfiles(changes *PolicyChanges) error {
+	for _, profile := range changes.Profiles {
+		if err := apparmorParser("-r", profile); err != nil {
+			return fmt.Errorf("unable to reload apparmor profile %v: %v", profile, err)
+		}
+	}
+	return nil
+}
+
+// templateBackend is the apparmor Backend whose templates are checked
+// with the parser once made into profiles with expand, or not at all
+// if it is nil.
+type templateBackend struct {
+	Backend
+	expand func(template []byte) []byte
+}
+
+func (b *templateBackend) Validate(kind, path string) error {
+	if kind != "templates" {
+		return b.Backend.Validate(kind, path)
+	}
+	if b.expand == nil {
+		return nil
+	}
+	return validateAppArmorTemplate(path, b.expand)
+}
+
+// validateAppArmorTemplate checks the syntax of the given apparmor
+// template, without loading it into the kernel nor caching it. It is
+// made into a profile with expand first, as the profiles of the snaps
+// are made from it.
+func validateAppArmorTemplate(path string, expand func(template []byte) []byte) error {
+	template, err := ioutil.ReadFile(path)
+	if err != nil {
+		return &PathError{Op: "read", Path: path, Err: err}
+	}
+	f, err := ioutil.TempFile("", "apparmor-template-")
+	if err != nil {
+		return fmt.Errorf("unable to validate apparmor template %v: %v", path, err)
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(expand(template))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = apparmorParser("-QTK", f.Name())
+	}
+	if err != nil {
+		return fmt.Errorf("unable to validate apparmor template %v: %v", path, err)
+	}
+	return nil
+}
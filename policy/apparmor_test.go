@@ -0,0 +1,315 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package policy
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func (s *policySuite) TestAppArmorTemplatesValidated(c *C) {
+	var checked []string
+	apparmorParser = func(args ...string) error {
+		c.Assert(args, HasLen, 2)
+		c.Check(args[0], Equals, "-QTK")
+		content, err := ioutil.ReadFile(args[1])
+		c.Assert(err, IsNil)
+		checked = append(checked, string(content))
+		return nil
+	}
+	err := New(WithSecBase(c.MkDir())).Install("foo", s.orig)
+	c.Assert(err, IsNil)
+	c.Check(checked, DeepEquals, []string{
+		"apparmor::templates0",
+		"apparmor::templates1",
+		"apparmor::templates2",
+	})
+
+	// made into profiles with the given expander
+	checked = nil
+	expand := func(template []byte) []byte {
+		return append([]byte("expanded "), template...)
+	}
+	err = New(WithSecBase(c.MkDir()), WithTemplateExpander(expand)).Install("foo", s.orig)
+	c.Assert(err, IsNil)
+	c.Check(checked, DeepEquals, []string{
+		"expanded apparmor::templates0",
+		"expanded apparmor::templates1",
+		"expanded apparmor::templates2",
+	})
+
+	// not without one
+	checked = nil
+	err = New(WithSecBase(c.MkDir()), WithTemplateExpander(nil)).Install("foo", s.orig)
+	c.Assert(err, IsNil)
+	c.Check(checked, HasLen, 0)
+}
+
+const placeholderTemplate = `#include <tunables/global>
+
+###VAR###
+
+###PROFILEATTACH### (attach_disconnected) {
+  #include <abstractions/base>
+  @{INSTALL_DIR}/@{SNAP_NAME}/@{SNAP_REVISION}/** mrklix,
+###SNIPPETS###
+}
+`
+
+func (s *policySuite) TestAppArmorTemplatePlaceholders(c *C) {
+	var checked string
+	apparmorParser = func(args ...string) error {
+		content, err := ioutil.ReadFile(args[len(args)-1])
+		c.Assert(err, IsNil)
+		checked = string(content)
+		return nil
+	}
+	template := filepath.Join(c.MkDir(), "default")
+	c.Assert(ioutil.WriteFile(template, []byte(placeholderTemplate), 0644), IsNil)
+	c.Assert(validateCheckTemplate(template), IsNil)
+	c.Check(checked, Equals, `#include <tunables/global>
+
+@{APP_NAME}="app"
+@{SNAP_NAME}="policy-check"
+@{SNAP_REVISION}="1"
+@{INSTALL_DIR}="/snap"
+
+profile "snap.policy-check.app" (attach_disconnected) {
+  #include <abstractions/base>
+  @{INSTALL_DIR}/@{SNAP_NAME}/@{SNAP_REVISION}/** mrklix,
+
+}
+`)
+}
+
+func (s *policySuite) TestAppArmorTemplateRealParser(c *C) {
+	if _, err := exec.LookPath("apparmor_parser"); err != nil {
+		c.Skip("apparmor_parser is not available")
+	}
+	apparmorParser = s.apparmorParser
+	template := filepath.Join(c.MkDir(), "default")
+	c.Assert(ioutil.WriteFile(template, []byte(placeholderTemplate), 0644), IsNil)
+	c.Check(validateCheckTemplate(template), IsNil)
+}
+
+func (s *policySuite) TestAppArmorInvalidTemplate(c *C) {
+	s.parserErr = errors.New("syntax error")
+	secBase := c.MkDir()
+	m := New(WithSecBase(secBase))
+
+	err := m.Install("foo", s.orig)
+	c.Check(err, ErrorMatches, `unable to validate apparmor template .*/templates0: syntax error`)
+	c.Check(osutil.FileExists(filepath.Join(secBase, "apparmor")), Equals, false)
+
+	err = m.UpgradeTransactional("foo", s.orig)
+	c.Check(err, ErrorMatches, `unable to validate apparmor template .*/templates0: syntax error`)
+	c.Check(osutil.FileExists(filepath.Join(secBase, "apparmor")), Equals, false)
+}
+
+func (s *policySuite) TestAppArmorNotValidatedOnRemove(c *C) {
+	m := New(WithSecBase(c.MkDir()))
+	c.Assert(m.Install("foo", s.orig), IsNil)
+
+	s.parserCalls = nil
+	s.parserErr = errors.New("syntax error")
+	c.Check(m.Remove("foo", s.orig), IsNil)
+	c.Check(s.parserCalls, HasLen, 0)
+}
+
+func (s *policySuite) TestWithValidator(c *C) {
+	var validated []string
+	validate := func(path string) error {
+		validated = append(validated, filepath.Base(path))
+		return nil
+	}
+	m := New(WithSecBase(c.MkDir()), WithValidator("seccomp", validate), WithValidator("apparmor", nil))
+	c.Assert(m.Install("foo", s.orig), IsNil)
+	c.Check(validated, DeepEquals, []string{
+		"policygroups0", "policygroups1", "policygroups2",
+		"templates0", "templates1", "templates2",
+	})
+	c.Check(s.parserCalls, HasLen, 0)
+
+	// the defaults of other managers are left alone
+	err := New(WithSecBase(c.MkDir())).Install("foo", s.orig)
+	c.Assert(err, IsNil)
+	c.Check(s.parserCalls, HasLen, 3)
+}
+
+func (s *policySuite) TestAppArmorParserOutput(c *C) {
+	cmd := testutil.MockCommand(c, "apparmor_parser", "echo 'AppArmor parser error at line 3'; exit 1")
+	defer cmd.Restore()
+
+	// the real parser, run as the mocked command
+	apparmorParser = s.apparmorParser
+	template := filepath.Join(c.MkDir(), "template")
+	c.Assert(ioutil.WriteFile(template, []byte(placeholderTemplate), 0644), IsNil)
+	err := validateCheckTemplate(template)
+	c.Check(err, ErrorMatches, `unable to validate apparmor template `+template+`: apparmor_parser -QTK .*/apparmor-template-[0-9]+ failed: exit status 1 \(AppArmor parser error at line 3\)`)
+	calls := cmd.Calls()
+	c.Assert(calls, HasLen, 1)
+	c.Check(calls[0][:2], DeepEquals, []string{"apparmor_parser", "-QTK"})
+}
+
+func (s *policySuite) TestAppArmorTemplateUnreadable(c *C) {
+	err := validateCheckTemplate(filepath.Join(c.MkDir(), "missing"))
+	c.Check(err, ErrorMatches, `unable to read .*/missing: .*`)
+	c.Check(s.parserCalls, HasLen, 0)
+}
+
+// mockRegenerator returns a regenerator writing the profile of the bar
+// snap, connected to the foo framework, made from its installed
+// template as the interfaces backend does, and recording the packages
+// it was called for.
+func (s *policySuite) mockRegenerator(c *C, secBase string, called *[]string) func(pkgName string) ([]string, error) {
+	dirs.SetRootDir(c.MkDir())
+	c.Assert(os.MkdirAll(dirs.SnapAppArmorDir, 0755), IsNil)
+	return func(pkgName string) ([]string, error) {
+		*called = append(*called, pkgName)
+		template, err := ioutil.ReadFile(filepath.Join(secBase, "apparmor", "templates", pkgName+"_templates0"))
+		if err != nil {
+			return nil, err
+		}
+		profile := filepath.Join(dirs.SnapAppArmorDir, "snap.bar.app")
+		content := strings.NewReplacer(
+			"###PROFILEATTACH###", `profile "snap.bar.app"`,
+			"###SNIPPETS###", "/dev/foo rw,",
+		).Replace(string(template))
+		if err := ioutil.WriteFile(profile, []byte(content), 0644); err != nil {
+			return nil, err
+		}
+		return []string{profile}, nil
+	}
+}
+
+func (s *policySuite) reloadCalls() []string {
+	var calls []string
+	for _, call := range s.parserCalls {
+		if strings.HasPrefix(call, "-r ") {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+func (s *policySuite) TestAppArmorProfilesRegenerated(c *C) {
+	secBase := c.MkDir()
+	var called []string
+	regenerate := s.mockRegenerator(c, secBase, &called)
+	defer dirs.SetRootDir("")
+	profile := filepath.Join(dirs.SnapAppArmorDir, "snap.bar.app")
+
+	m := New(WithSecBase(secBase), WithRegenerator("apparmor", regenerate))
+	c.Assert(m.Install("foo", s.orig), IsNil)
+	c.Check(called, DeepEquals, []string{"foo"})
+	c.Check(s.reloadCalls(), DeepEquals, []string{"-r " + profile})
+	// the profile holds the template, not a reference to it
+	content, err := ioutil.ReadFile(profile)
+	c.Assert(err, IsNil)
+	c.Check(string(content), Equals, "apparmor::templates0")
+
+	// nothing changed
+	called = nil
+	s.parserCalls = nil
+	c.Assert(m.Upgrade("foo", s.orig), IsNil)
+	c.Check(called, HasLen, 0)
+	c.Check(s.reloadCalls(), HasLen, 0)
+
+	// the template changed
+	template := filepath.Join(s.orig, "meta", "framework-policy", "apparmor", "templates", "templates0")
+	c.Assert(ioutil.WriteFile(template, []byte("###PROFILEATTACH### {\n###SNIPPETS###\n}\n"), 0644), IsNil)
+	c.Assert(m.UpgradeTransactional("foo", s.orig), IsNil)
+	c.Check(called, DeepEquals, []string{"foo"})
+	c.Check(s.reloadCalls(), DeepEquals, []string{"-r " + profile})
+	content, err = ioutil.ReadFile(profile)
+	c.Assert(err, IsNil)
+	c.Check(string(content), Equals, "profile \"snap.bar.app\" {\n/dev/foo rw,\n}\n")
+}
+
+func (s *policySuite) TestAppArmorProfilesNotRegeneratedForOtherBackends(c *C) {
+	secBase := c.MkDir()
+	var called []string
+	regenerate := s.mockRegenerator(c, secBase, &called)
+	defer dirs.SetRootDir("")
+
+	m := New(WithSecBase(secBase), WithRegenerator("apparmor", regenerate))
+	c.Assert(m.Install("foo", s.orig), IsNil)
+
+	// only seccomp policy changed
+	called = nil
+	s.parserCalls = nil
+	seccomp := filepath.Join(s.orig, "meta", "framework-policy", "seccomp", "policygroups", "policygroups0")
+	c.Assert(ioutil.WriteFile(seccomp, []byte("write\n"), 0644), IsNil)
+	c.Assert(m.Upgrade("foo", s.orig), IsNil)
+	c.Check(called, HasLen, 0)
+	c.Check(s.reloadCalls(), HasLen, 0)
+}
+
+func (s *policySuite) TestAppArmorProfilesNotRegenerated(c *C) {
+	secBase := c.MkDir()
+	var called []string
+	regenerate := s.mockRegenerator(c, secBase, &called)
+	defer dirs.SetRootDir("")
+
+	err := New(WithSecBase(secBase), WithoutReload(), WithRegenerator("apparmor", regenerate)).Install("foo", s.orig)
+	c.Assert(err, IsNil)
+	c.Check(osutil.FileExists(filepath.Join(secBase, "apparmor", "policygroups", "foo_policygroups0")), Equals, true)
+	c.Check(called, HasLen, 0)
+	c.Check(s.reloadCalls(), HasLen, 0)
+
+	// without a regenerator there is nothing to reload
+	err = New(WithSecBase(c.MkDir())).Install("foo", s.orig)
+	c.Assert(err, IsNil)
+	c.Check(s.reloadCalls(), HasLen, 0)
+}
+
+func (s *policySuite) TestAppArmorRegenerateFails(c *C) {
+	regenerate := func(pkgName string) ([]string, error) {
+		return nil, errors.New("boom")
+	}
+	err := New(WithSecBase(c.MkDir()), WithRegenerator("apparmor", regenerate)).Install("foo", s.orig)
+	c.Check(err, ErrorMatches, "unable to regenerate the apparmor profiles using the policy of foo: boom")
+}
+
+func (s *policySuite) TestAppArmorProfileReloadFails(c *C) {
+	secBase := c.MkDir()
+	var called []string
+	regenerate := s.mockRegenerator(c, secBase, &called)
+	defer dirs.SetRootDir("")
+	apparmorParser = func(args ...string) error {
+		if args[0] == "-r" {
+			return errors.New("boom")
+		}
+		return nil
+	}
+
+	err := New(WithSecBase(secBase), WithRegenerator("apparmor", regenerate)).Install("foo", s.orig)
+	c.Check(err, ErrorMatches, "unable to reload apparmor profile "+filepath.Join(dirs.SnapAppArmorDir, "snap.bar.app")+": boom")
+}
@@ -0,0 +1,230 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package policy
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Backend is a security backend the frameworks ship policy for, in
+// meta/framework-policy/<name>. Its policy is installed under its own
+// directory, <name> in the base directory of the Manager unless set
+// with WithBackendDir.
+type Backend interface {
+	// Name returns the name of the backend.
+	Name() string
+	// Kinds returns the kinds of policy of the backend, as in
+	// "templates".
+	Kinds() []string
+	// SourceGlob returns the glob of the files of the given kind of
+	// policy, relative to the policy of the backend in the framework.
+	SourceGlob(kind string) string
+	// TargetDir returns the directory the given kind of policy goes
+	// to, relative to the directory of the backend.
+	TargetDir(kind string) string
+	// Validate checks a file of the given kind of policy of the
+	// framework before it is installed, so that broken policy never
+	// makes it to the system. Errors found in the file are returned as
+	// SyntaxErrors, to be reported with those of the other files.
+	Validate(kind, path string) error
+	// PostInstall makes the system use the policy files of a package
+	// after they changed. It is not called when working under another
+	// root directory, or without reloading.
+	PostInstall(changes *PolicyChanges) error
+}
+
+// PolicyChanges are the changes made to the policy files of a package
+// for a backend.
+type PolicyChanges struct {
+	// Package is the package the policy files are of.
+	Package string
+	// Installed are the files installed now.
+	Installed []string
+	// Removed are the files that were installed before, and no
+	// longer are.
+	Removed []string
+	// Changed are the files added, changed or removed.
+	Changed []string
+	// Profiles are the profiles of the snaps using the policy of the
+	// package, written again after it changed by the regenerator set
+	// with WithRegenerator.
+	Profiles []string
+}
+
+// policyBackend is a Backend whose kinds of policy are described by
+// its sets.
+type policyBackend struct {
+	name string
+	sets []policySet
+
+	// postInstall, if set, is what PostInstall does.
+	postInstall func(changes *PolicyChanges) error
+}
+
+// policySet is a kind of policy of a backend: the files matching glob
+// in the policy of the framework go to subdir in the directory of the
+// backend.
+type policySet struct {
+	kind   string
+	glob   string
+	subdir string
+
+	// validate, if set, checks a file of the framework before it is
+	// installed.
+	validate func(path string) error
+}
+
+// defaultSets are the kinds of policy of the backends, unless they
+// have their own.
+var defaultSets = []policySet{
+	{kind: "policygroups", glob: "policygroups/*", subdir: "policygroups"},
+	{kind: "templates", glob: "templates/*", subdir: "templates"},
+}
+
+func (b *policyBackend) Name() string {
+	return b.name
+}
+
+func (b *policyBackend) Kinds() []string {
+	kinds := make([]string, len(b.sets))
+	for i, set := range b.sets {
+		kinds[i] = set.kind
+	}
+	return kinds
+}
+
+func (b *policyBackend) set(kind string) *policySet {
+	for i := range b.sets {
+		if b.sets[i].kind == kind {
+			return &b.sets[i]
+		}
+	}
+	return &policySet{}
+}
+
+func (b *policyBackend) SourceGlob(kind string) string {
+	return b.set(kind).glob
+}
+
+func (b *policyBackend) TargetDir(kind string) string {
+	return b.set(kind).subdir
+}
+
+func (b *policyBackend) Validate(kind, path string) error {
+	if validate := b.set(kind).validate; validate != nil {
+		return validate(path)
+	}
+	return nil
+}
+
+func (b *policyBackend) PostInstall(changes *PolicyChanges) error {
+	if b.postInstall != nil {
+		return b.postInstall(changes)
+	}
+	return nil
+}
+
+// validatedBackend is a Backend whose policy is checked with validate
+// instead of its own checks, or not at all if it is nil.
+type validatedBackend struct {
+	Backend
+	validate func(path string) error
+}
+
+func (b *validatedBackend) Validate(kind, path string) error {
+	if b.validate != nil {
+		return b.validate(path)
+	}
+	return nil
+}
+
+// regeneratingBackend is a Backend whose profiles using changed policy
+// are written again with regenerate before the system uses them.
+type regeneratingBackend struct {
+	Backend
+	regenerate func(pkgName string) ([]string, error)
+}
+
+func (b *regeneratingBackend) PostInstall(changes *PolicyChanges) error {
+	if len(changes.Changed) > 0 {
+		profiles, err := b.regenerate(changes.Package)
+		if err != nil {
+			return fmt.Errorf("unable to regenerate the %s profiles using the policy of %v: %v", b.Name(), changes.Package, err)
+		}
+		changes.Profiles = profiles
+	}
+	return b.Backend.PostInstall(changes)
+}
+
+var (
+	backendsLock sync.Mutex
+	// backends are the registered backends, the built-in ones to
+	// begin with.
+	backends = builtinBackends()
+)
+
+// builtinBackends returns the built-in backends, the selinux one only
+// when SELinux is enabled on the system.
+func builtinBackends() []Backend {
+	builtin := []Backend{apparmorBackend(), seccompBackend()}
+	if selinuxEnabled() {
+		builtin = append(builtin, selinuxBackend())
+	}
+	return builtin
+}
+
+// RegisterBackend makes the Managers created afterwards handle the
+// policy of the given backend as well, in place of the registered
+// backend of the same name, if any.
+func RegisterBackend(b Backend) {
+	backendsLock.Lock()
+	defer backendsLock.Unlock()
+
+	for i, other := range backends {
+		if other.Name() == b.Name() {
+			backends[i] = b
+			return
+		}
+	}
+	backends = append(backends, b)
+}
+
+// UnregisterBackend makes the Managers created afterwards leave the
+// policy of the named backend alone, the built-in ones included.
+func UnregisterBackend(name string) {
+	backendsLock.Lock()
+	defer backendsLock.Unlock()
+
+	for i, b := range backends {
+		if b.Name() == name {
+			backends = append(backends[:i:i], backends[i+1:]...)
+			return
+		}
+	}
+}
+
+// registeredBackends returns a copy of the registered backends.
+func registeredBackends() []Backend {
+	backendsLock.Lock()
+	defer backendsLock.Unlock()
+
+	return append([]Backend(nil), backends...)
+}
@@ -0,0 +1,172 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package policy
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/net/context"
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/osutil"
+)
+
+// fakeBackend is a backend of smack rules, recording what it is told
+// after they are installed.
+type fakeBackend struct {
+	validateErr    error
+	postInstallErr error
+	changes        []*PolicyChanges
+}
+
+func (b *fakeBackend) Name() string                  { return "smack" }
+func (b *fakeBackend) Kinds() []string               { return []string{"rules"} }
+func (b *fakeBackend) SourceGlob(kind string) string { return "*.rules" }
+func (b *fakeBackend) TargetDir(kind string) string  { return "rules.d" }
+
+func (b *fakeBackend) Validate(kind, path string) error {
+	return b.validateErr
+}
+
+func (b *fakeBackend) PostInstall(changes *PolicyChanges) error {
+	b.changes = append(b.changes, changes)
+	return b.postInstallErr
+}
+
+func (s *policySuite) mockRegistry() (restore func()) {
+	old := registeredBackends()
+	return func() {
+		backendsLock.Lock()
+		defer backendsLock.Unlock()
+		backends = old
+	}
+}
+
+func (s *policySuite) mockSmackPolicy(c *C) {
+	base := filepath.Join(s.orig, "meta", "framework-policy", "smack")
+	c.Assert(os.MkdirAll(base, 0755), IsNil)
+	for _, name := range []string{"app.rules", "README"} {
+		c.Assert(ioutil.WriteFile(filepath.Join(base, name), []byte("smack::"+name), 0644), IsNil)
+	}
+}
+
+func (s *policySuite) TestRegisterBackend(c *C) {
+	defer s.mockRegistry()()
+	s.mockSmackPolicy(c)
+
+	b := &fakeBackend{}
+	RegisterBackend(b)
+	secBase := c.MkDir()
+	rules := filepath.Join(secBase, "smack", "rules.d", "foo_app.rules")
+
+	m := New(WithSecBase(secBase))
+	err := m.Install("foo", s.orig)
+	c.Assert(err, IsNil)
+	c.Check(osutil.FileExists(rules), Equals, true)
+	c.Check(osutil.FileExists(filepath.Join(secBase, "smack", "rules.d", "foo_README")), Equals, false)
+	c.Check(b.changes, DeepEquals, []*PolicyChanges{{
+		Package:   "foo",
+		Installed: []string{rules},
+		Changed:   []string{rules},
+	}})
+
+	policies, err := m.ListPolicies("foo")
+	c.Assert(err, IsNil)
+	c.Check(policies["smack/rules.d"], DeepEquals, []string{rules})
+
+	b.changes = nil
+	err = m.Remove("foo", s.orig)
+	c.Assert(err, IsNil)
+	c.Check(osutil.FileExists(rules), Equals, false)
+	c.Check(b.changes, DeepEquals, []*PolicyChanges{{
+		Package: "foo",
+		Removed: []string{rules},
+		Changed: []string{rules},
+	}})
+}
+
+func (s *policySuite) TestRegisterBackendValidates(c *C) {
+	defer s.mockRegistry()()
+	s.mockSmackPolicy(c)
+
+	RegisterBackend(&fakeBackend{validateErr: errors.New("bad rules")})
+	secBase := c.MkDir()
+	err := New(WithSecBase(secBase)).Install("foo", s.orig)
+	c.Check(err, ErrorMatches, "bad rules")
+	c.Check(policyFiles(c, secBase), HasLen, 0)
+}
+
+func (s *policySuite) TestRegisterBackendPostInstallFails(c *C) {
+	defer s.mockRegistry()()
+	s.mockSmackPolicy(c)
+
+	RegisterBackend(&fakeBackend{postInstallErr: errors.New("cannot load rules")})
+	secBase := c.MkDir()
+	m := New(WithSecBase(secBase))
+	res, err := m.FrameworkTransactionContext(context.Background(), OpInstall, "foo", s.orig)
+	c.Assert(err, FitsTypeOf, &CommitError{})
+	c.Check(err, ErrorMatches, `policy of "foo" was changed, but: cannot load rules`)
+	c.Check(err.(*CommitError).Result, Equals, res)
+	c.Check(res.Copied, Equals, 4*3+1)
+	// the policy is kept
+	c.Check(osutil.FileExists(filepath.Join(secBase, "smack", "rules.d", "foo_app.rules")), Equals, true)
+
+	// failing before the policy is changed is not a CommitError
+	c.Assert(os.Remove(filepath.Join(s.orig, "meta", "framework-policy", "smack", "app.rules")), IsNil)
+	c.Assert(os.Mkdir(filepath.Join(s.orig, "meta", "framework-policy", "smack", "app.rules"), 0755), IsNil)
+	res, err = m.FrameworkTransactionContext(context.Background(), OpUpgrade, "foo", s.orig)
+	c.Check(res, IsNil)
+	c.Check(err, Not(FitsTypeOf), &CommitError{})
+}
+
+func (s *policySuite) TestRegisterBackendReplaces(c *C) {
+	defer s.mockRegistry()()
+
+	n := len(New().backends)
+	first := &fakeBackend{}
+	RegisterBackend(first)
+	m := New()
+	c.Assert(m.backends, HasLen, n+1)
+	c.Check(m.backends[n], Equals, first)
+
+	second := &fakeBackend{}
+	RegisterBackend(second)
+	backends := New().backends
+	c.Assert(backends, HasLen, n+1)
+	c.Check(backends[n], Equals, second)
+	// managers already created are left alone
+	c.Check(m.backends[n], Equals, first)
+}
+
+func (s *policySuite) TestUnregisterBackend(c *C) {
+	defer s.mockRegistry()()
+
+	UnregisterBackend("seccomp")
+	UnregisterBackend("no-such-backend")
+	rootDir := c.MkDir()
+	m := New(WithRootDir(rootDir), WithSecBase("/sec"))
+	err := m.Install("foo", s.orig)
+	c.Assert(err, IsNil)
+	c.Check(osutil.IsDirectory(filepath.Join(rootDir, "sec", "apparmor")), Equals, true)
+	c.Check(osutil.IsDirectory(filepath.Join(rootDir, "sec", "seccomp")), Equals, false)
+}
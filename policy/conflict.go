@@ -0,0 +1,78 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package policy
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// checkConflicts checks that the target files op would write for the
+// given package that's installed in the given path are not in the way,
+// unless the manager was made WithForce: existing target files must be
+// recorded in the manifest of the package. Without a manifest, the
+// package was installed before manifests were kept, if at all: when it
+// is upgraded its own files are the target files of the policy of the
+// snap as installed, as for Remove, while a package being installed
+// owns none yet. The manifest written once it is installed or upgraded
+// then tells its files apart.
+func (m *Manager) checkConflicts(op Op, pkgName, instPath string) error {
+	if m.force || op == remove {
+		return nil
+	}
+	files, recorded, err := m.manifestFiles(pkgName)
+	if err != nil {
+		return err
+	}
+	if !recorded && op == upgrade {
+		if files, err = m.policyTargets(pkgName, instPath); err != nil {
+			return err
+		}
+	}
+	own := make(map[string]bool, len(files))
+	for _, file := range files {
+		own[file] = true
+	}
+
+	var conflicts []string
+	err = m.forEachPolicy(pkgName, instPath, func(glob, targetDir, prefix string) error {
+		sources, err := filepath.Glob(glob)
+		if err != nil {
+			return &PathError{Op: "glob", Path: glob, Err: err}
+		}
+		for _, source := range sources {
+			target := filepath.Join(targetDir, prefix+filepath.Base(source))
+			if own[target] {
+				continue
+			}
+			if _, err := os.Lstat(target); err == nil {
+				conflicts = append(conflicts, target)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(conflicts) > 0 {
+		return &ConflictError{Package: pkgName, Files: conflicts}
+	}
+	return nil
+}
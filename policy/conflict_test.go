@@ -0,0 +1,124 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package policy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/net/context"
+	. "gopkg.in/check.v1"
+)
+
+func (s *policySuite) TestInstallConflict(c *C) {
+	for _, transactional := range []bool{false, true} {
+		rootDir := c.MkDir()
+		dir := filepath.Join(rootDir, "sec", "apparmor", "policygroups")
+		target := func(name string) string {
+			return filepath.Join(dir, name)
+		}
+
+		install := func(m *Manager) (*OpResult, error) {
+			if transactional {
+				return m.FrameworkTransactionContext(context.Background(), OpInstall, "foo", s.orig)
+			}
+			return m.FrameworkOpContext(context.Background(), OpInstall, "foo", s.orig)
+		}
+
+		m := New(WithRootDir(rootDir), WithSecBase("/sec"))
+		_, err := install(m)
+		c.Assert(err, IsNil)
+		for _, name := range []string{"policygroups3", "policygroups4"} {
+			c.Assert(ioutil.WriteFile(filepath.Join(s.appg, name), []byte("new"), 0644), IsNil)
+			c.Assert(ioutil.WriteFile(target("foo_"+name), []byte("other"), 0644), IsNil)
+		}
+
+		_, err = install(m)
+		c.Assert(err, FitsTypeOf, &ConflictError{})
+		c.Check(err.(*ConflictError).Files, DeepEquals, []string{target("foo_policygroups3"), target("foo_policygroups4")})
+		c.Check(err, ErrorMatches, `policy of "foo" conflicts with existing files:
+- .*/foo_policygroups3
+- .*/foo_policygroups4`)
+		// nothing was touched
+		c.Check(policyFiles(c, rootDir)["sec/apparmor/policygroups/foo_policygroups3"], Equals, "other")
+		c.Check(policyFiles(c, rootDir)["sec/apparmor/policygroups/foo_policygroups0"], Equals, "apparmor::policygroups0")
+
+		res, err := install(New(WithRootDir(rootDir), WithSecBase("/sec"), WithForce()))
+		c.Assert(err, IsNil)
+		c.Check(res.Copied, Equals, 2)
+
+		// the files are its own now
+		res, err = install(m)
+		c.Assert(err, IsNil)
+		c.Check(res.Skipped, Equals, 4*3+2)
+
+		for _, name := range []string{"policygroups3", "policygroups4"} {
+			c.Assert(os.Remove(filepath.Join(s.appg, name)), IsNil)
+		}
+	}
+}
+
+func (s *policySuite) TestInstallWithoutManifest(c *C) {
+	rootDir := c.MkDir()
+	dir := filepath.Join(rootDir, "sec", "apparmor", "policygroups")
+	c.Assert(os.MkdirAll(dir, 0755), IsNil)
+	// a foreign file at a target path of a package that is not installed
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, "foo_policygroups0"), []byte("other"), 0644), IsNil)
+
+	m := New(WithRootDir(rootDir), WithSecBase("/sec"))
+	err := m.Install("foo", s.orig)
+	c.Check(err, ErrorMatches, `policy of "foo" conflicts with existing file .*/foo_policygroups0`)
+	c.Check(policyFiles(c, rootDir)["sec/apparmor/policygroups/foo_policygroups0"], Equals, "other")
+
+	// installed before manifests were kept, the files of its policy are
+	// its own
+	res, err := m.FrameworkOpContext(context.Background(), OpUpgrade, "foo", s.orig)
+	c.Assert(err, IsNil)
+	c.Check(res.Copied, Equals, 4*3)
+	c.Check(policyFiles(c, rootDir)["sec/apparmor/policygroups/foo_policygroups0"], Equals, "apparmor::policygroups0")
+
+	// and the manifest tells them apart from then on
+	manifest, err := m.Manifest("foo")
+	c.Assert(err, IsNil)
+	c.Check(manifest.Files, HasLen, 4*3)
+	c.Assert(ioutil.WriteFile(filepath.Join(s.appg, "policygroups3"), []byte("new"), 0644), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, "foo_policygroups3"), []byte("other"), 0644), IsNil)
+	err = m.Install("foo", s.orig)
+	c.Check(err, ErrorMatches, `policy of "foo" conflicts with existing file .*/foo_policygroups3`)
+}
+
+func (s *policySuite) TestUpgradeConflict(c *C) {
+	rootDir := c.MkDir()
+	m := New(WithRootDir(rootDir), WithSecBase("/sec"))
+	err := m.Install("foo", s.orig)
+	c.Assert(err, IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(s.appg, "policygroups3"), []byte("new"), 0644), IsNil)
+	other := filepath.Join(rootDir, "sec", "apparmor", "policygroups", "foo_policygroups3")
+	c.Assert(ioutil.WriteFile(other, []byte("other"), 0644), IsNil)
+
+	err = m.Upgrade("foo", s.orig)
+	c.Check(err, ErrorMatches, `policy of "foo" conflicts with existing file .*/foo_policygroups3`)
+
+	// installed before manifests were kept, all of its files are its own
+	c.Assert(os.Remove(filepath.Join(rootDir, "sec", "manifests", "foo.json")), IsNil)
+	err = m.Upgrade("foo", s.orig)
+	c.Check(err, IsNil)
+}
@@ -0,0 +1,180 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// The causes of the failures of the operations on the policy files that
+// callers may want to tell apart, found as the Err of a PathError.
+var (
+	// ErrNotRegularFile is about a file of the framework that is not a
+	// regular file, such as a symlink or a directory.
+	ErrNotRegularFile = errors.New("not a regular file")
+	// ErrTargetExists is about something being in the way of a target
+	// file or directory, such as a directory where a file is expected.
+	ErrTargetExists = errors.New("target exists")
+	// ErrMissingTarget is about a target file to remove not being there.
+	ErrMissingTarget = errors.New("not found")
+	// ErrLinkEscapes is about a file of the framework that is a symlink
+	// resolving to a file outside of the policy directory of the snap.
+	ErrLinkEscapes = errors.New("symlink escapes the policy directory")
+)
+
+// A PathError records what failed to be done with a file of the
+// framework or a target file, and why.
+type PathError struct {
+	// Op is what failed to be done, such as "remove" or "do Install
+	// for", as found in the message.
+	Op   string
+	Path string
+	Err  error
+}
+
+func (e *PathError) Error() string {
+	return fmt.Sprintf("unable to %s %v: %v", e.Op, e.Path, e.Err)
+}
+
+// A ConflictError is about target files being in the way of installing
+// the policy of a package, as they are not its own: they are not
+// recorded in its manifest, as they would be had it installed them.
+type ConflictError struct {
+	Package string
+	// Files are the target files in the way.
+	Files []string
+}
+
+func (e *ConflictError) Error() string {
+	if len(e.Files) == 1 {
+		return fmt.Sprintf("policy of %q conflicts with existing file %s", e.Package, e.Files[0])
+	}
+	return fmt.Sprintf("policy of %q conflicts with existing files:\n- %s", e.Package, strings.Join(e.Files, "\n- "))
+}
+
+// IsConflict returns whether err is about target files that are not
+// the own of the package being in the way of installing its policy.
+func IsConflict(err error) bool {
+	_, ok := err.(*ConflictError)
+	return ok
+}
+
+// A RemoveError is about the target files a lenient Remove could not
+// remove, once it removed all it could, see WithLenientRemove.
+type RemoveError struct {
+	Package string
+	// Result is what was done nonetheless.
+	Result *OpResult
+	// Errs are why the target files could not be removed, as
+	// PathErrors, in the order the files were handled.
+	Errs []error
+}
+
+func (e *RemoveError) Error() string {
+	if len(e.Errs) == 1 {
+		return fmt.Sprintf("policy of %q was only partly removed: %v", e.Package, e.Errs[0])
+	}
+	msgs := make([]string, len(e.Errs))
+	for i, err := range e.Errs {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("policy of %q was only partly removed:\n- %s", e.Package, strings.Join(msgs, "\n- "))
+}
+
+// A CommitError is about what failed once a transactional operation
+// changed the target files: they are not put back, see
+// Manager.InstallTransactional.
+type CommitError struct {
+	Package string
+	// Result is what was done.
+	Result *OpResult
+	Err    error
+}
+
+func (e *CommitError) Error() string {
+	return fmt.Sprintf("policy of %q was changed, but: %v", e.Package, e.Err)
+}
+
+// underlying returns the error a PathError or a CommitError was made
+// for, or err itself.
+func underlying(err error) error {
+	if e, ok := err.(*CommitError); ok {
+		err = e.Err
+	}
+	if e, ok := err.(*PathError); ok {
+		return e.Err
+	}
+	return err
+}
+
+// IsNotRegularFile returns whether err is about a file of the framework
+// not being a regular file.
+func IsNotRegularFile(err error) bool {
+	return underlying(err) == ErrNotRegularFile
+}
+
+// IsTargetExists returns whether err is about something being in the
+// way of a target file or directory.
+func IsTargetExists(err error) bool {
+	return underlying(err) == ErrTargetExists
+}
+
+// IsMissingTarget returns whether err is about a target file to remove
+// not being there.
+func IsMissingTarget(err error) bool {
+	return underlying(err) == ErrMissingTarget
+}
+
+// IsLinkEscapes returns whether err is about a file of the framework
+// being a symlink resolving outside of the policy directory of the snap.
+func IsLinkEscapes(err error) bool {
+	return underlying(err) == ErrLinkEscapes
+}
+
+// targetError returns the PathError about failing to do op with the
+// target path, with ErrMissingTarget or ErrTargetExists as its Err when
+// that is why.
+func targetError(op, path string, err error) error {
+	switch {
+	case os.IsNotExist(err) && op == "remove":
+		err = ErrMissingTarget
+	case os.IsExist(err), isErrno(err, syscall.EISDIR), isErrno(err, syscall.ENOTDIR):
+		err = ErrTargetExists
+	}
+	return &PathError{Op: op, Path: path, Err: err}
+}
+
+// isErrno returns whether err, as returned by the os package, is the
+// given errno.
+func isErrno(err error, errno syscall.Errno) bool {
+	switch e := err.(type) {
+	case *os.PathError:
+		err = e.Err
+	case *os.LinkError:
+		err = e.Err
+	case *os.SyscallError:
+		err = e.Err
+	}
+	return err == errno
+}
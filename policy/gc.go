@@ -0,0 +1,152 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// garbage returns the policy files installed for the backends whose
+// <package>_ prefix is not one of the given installed packages, keyed
+// by package. Packages that are left with only a manifest are in it
+// too, with no files.
+func (m *Manager) garbage(installedPkgs []string) (map[string][]string, error) {
+	installed := make(map[string]bool, len(installedPkgs))
+	for _, pkgName := range installedPkgs {
+		installed[pkgName] = true
+	}
+
+	garbage := make(map[string][]string)
+	for _, b := range m.backends {
+		for _, kind := range b.Kinds() {
+			glob := filepath.Join(m.policyDir(b, kind), "*_"+filepath.Base(b.SourceGlob(kind)))
+			files, err := filepath.Glob(glob)
+			if err != nil {
+				return nil, &PathError{Op: "glob", Path: glob, Err: err}
+			}
+			for _, file := range files {
+				base := filepath.Base(file)
+				// staged and backup files are hidden
+				if strings.HasPrefix(base, ".") {
+					continue
+				}
+				pkgName := base[:strings.Index(base, "_")]
+				if !installed[pkgName] {
+					garbage[pkgName] = append(garbage[pkgName], file)
+				}
+			}
+		}
+	}
+
+	manifests, err := filepath.Glob(m.manifestPath("*"))
+	if err != nil {
+		return nil, &PathError{Op: "glob", Path: m.manifestPath("*"), Err: err}
+	}
+	for _, path := range manifests {
+		pkgName := strings.TrimSuffix(filepath.Base(path), ".json")
+		if _, ok := garbage[pkgName]; !ok && !installed[pkgName] {
+			garbage[pkgName] = nil
+		}
+	}
+	return garbage, nil
+}
+
+// garbagePackages returns the sorted packages of the garbage.
+func garbagePackages(garbage map[string][]string) []string {
+	pkgNames := make([]string, 0, len(garbage))
+	for pkgName := range garbage {
+		pkgNames = append(pkgNames, pkgName)
+	}
+	sort.Strings(pkgNames)
+	return pkgNames
+}
+
+// GC removes the policy files installed for the backends that belong
+// to none of the given installed packages, as found by their
+// <package>_ prefix, cleaning up after frameworks whose removal crashed
+// or went wrong. The backends are told about the removed policy as by
+// Remove. The manifests of those packages are removed once their
+// files are, as by Remove, without being counted or observed.
+func (m *Manager) GC(installedPkgs []string) (*OpResult, error) {
+	garbage, err := m.garbage(installedPkgs)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &OpResult{}
+	for _, pkgName := range garbagePackages(garbage) {
+		var dirs []string
+		err := m.withLoadedPolicy(pkgName, func() error {
+			for _, file := range garbage[pkgName] {
+				if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+					err = targetError("remove", file, err)
+					m.observe.notify(FileFailed, file, "", err)
+					return err
+				}
+				m.observe.notify(FileRemoved, file, "", nil)
+				res.Removed++
+				dirs = append(dirs, filepath.Dir(file))
+			}
+			return nil
+		})
+		if serr := m.syncDirs(dirs); serr != nil && err == nil {
+			err = serr
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := m.updateManifest(remove, pkgName, nil); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// PlanGC returns the removals of policy files GC would make, without making
+// them.
+func (m *Manager) PlanGC(installedPkgs []string) ([]*FileOp, error) {
+	garbage, err := m.garbage(installedPkgs)
+	if err != nil {
+		return nil, err
+	}
+
+	var plan []*FileOp
+	for _, pkgName := range garbagePackages(garbage) {
+		for _, file := range garbage[pkgName] {
+			plan = append(plan, &FileOp{Action: FileRemove, Path: file})
+		}
+	}
+	return plan, nil
+}
+
+// GC removes the policy files installed in the system that belong to
+// none of the given installed packages, see Manager.GC.
+func GC(installedPkgs []string, rootDir string) (*OpResult, error) {
+	return New(WithRootDir(rootDir)).GC(installedPkgs)
+}
+
+// PlanGC returns the removals of files of the system GC would make,
+// without making them.
+func PlanGC(installedPkgs []string, rootDir string) ([]*FileOp, error) {
+	return New(WithRootDir(rootDir)).PlanGC(installedPkgs)
+}
@@ -0,0 +1,114 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package policy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/osutil"
+)
+
+func (s *policySuite) TestGC(c *C) {
+	rootDir := c.MkDir()
+	var events []*FileEvent
+	m := New(WithRootDir(rootDir), WithSecBase("/sec"), WithObserver(func(ev *FileEvent) {
+		events = append(events, ev)
+	}))
+	err := m.Install("foo", s.orig)
+	c.Assert(err, IsNil)
+	err = m.Install("bar", s.orig)
+	c.Assert(err, IsNil)
+	// hidden files are left to the operations
+	hidden := filepath.Join(rootDir, "sec", "seccomp", "templates", ".bar_templates0~new")
+	c.Assert(ioutil.WriteFile(hidden, nil, 0644), IsNil)
+	before := policyFiles(c, rootDir)
+	events = nil
+
+	// nothing to collect
+	res, err := m.GC([]string{"foo", "bar"})
+	c.Assert(err, IsNil)
+	c.Check(res, DeepEquals, &OpResult{})
+	c.Check(policyFiles(c, rootDir), DeepEquals, before)
+
+	// bar went away without its policy being removed
+	plan, err := m.PlanGC([]string{"foo"})
+	c.Assert(err, IsNil)
+	c.Assert(plan, HasLen, 4*3)
+	c.Check(plan[0], DeepEquals, &FileOp{Action: FileRemove, Path: filepath.Join(rootDir, "sec", "apparmor", "policygroups", "bar_policygroups0")})
+	c.Check(policyFiles(c, rootDir), DeepEquals, before)
+
+	res, err = m.GC([]string{"foo"})
+	c.Assert(err, IsNil)
+	c.Check(res, DeepEquals, &OpResult{Removed: 4 * 3})
+	c.Check(events, HasLen, 4*3)
+	for i, ev := range events {
+		c.Check(ev, DeepEquals, &FileEvent{Kind: FileRemoved, Path: plan[i].Path})
+	}
+	policies, err := m.ListPolicies("bar")
+	c.Assert(err, IsNil)
+	c.Check(policies, HasLen, 0)
+	policies, err = m.ListPolicies("foo")
+	c.Assert(err, IsNil)
+	c.Check(policies, HasLen, 4)
+	c.Check(osutil.FileExists(hidden), Equals, true)
+	// the manifest went with the policy
+	c.Check(osutil.FileExists(filepath.Join(rootDir, "sec", "manifests", "bar.json")), Equals, false)
+	c.Check(osutil.FileExists(filepath.Join(rootDir, "sec", "manifests", "foo.json")), Equals, true)
+}
+
+func (s *policySuite) TestGCManifestOnly(c *C) {
+	rootDir := c.MkDir()
+	m := New(WithRootDir(rootDir), WithSecBase("/sec"))
+	err := m.Install("bar", s.orig)
+	c.Assert(err, IsNil)
+	err = m.RemoveTransactional("bar", s.orig)
+	c.Assert(err, IsNil)
+	// left behind by a removal that crashed
+	manifest := filepath.Join(rootDir, "sec", "manifests", "bar.json")
+	c.Assert(os.MkdirAll(filepath.Dir(manifest), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(manifest, []byte(`{"package":"bar","files":[]}`), 0644), IsNil)
+
+	plan, err := m.PlanGC(nil)
+	c.Assert(err, IsNil)
+	c.Check(plan, HasLen, 0)
+	res, err := m.GC(nil)
+	c.Assert(err, IsNil)
+	c.Check(res, DeepEquals, &OpResult{})
+	c.Check(osutil.FileExists(manifest), Equals, false)
+}
+
+func (s *policySuite) TestGCFails(c *C) {
+	rootDir := c.MkDir()
+	SecBase = "/sec"
+	err := Install("foo", s.orig, rootDir)
+	c.Assert(err, IsNil)
+
+	// not a policy file snappy would have installed
+	bad := filepath.Join(rootDir, "sec", "apparmor", "templates", "bar_templates0")
+	c.Assert(os.MkdirAll(filepath.Join(bad, "sub"), 0755), IsNil)
+
+	_, err = GC([]string{"foo"}, rootDir)
+	c.Check(err, ErrorMatches, "unable to remove .*/bar_templates0: target exists")
+	c.Check(osutil.IsDirectory(bad), Equals, true)
+}
@@ -0,0 +1,479 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package policy
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+// Manager keeps the security policies of frameworks up to date under a
+// base directory of its own, for an image being built or an alternate
+// root as well as for the running system.
+type Manager struct {
+	rootDir   string
+	secBase   string
+	backends  []Backend
+	dirs      map[string]string
+	noReload  bool
+	noDirSync bool
+	workers   int
+	owner     *fileOwner
+	observe   Observer
+	force     bool
+	lenient   bool
+}
+
+// Option configures a Manager.
+type Option func(*Manager)
+
+// WithRootDir makes the manager prefix the directories it installs
+// the policy to with the given root directory.
+func WithRootDir(rootDir string) Option {
+	return func(m *Manager) {
+		m.rootDir = rootDir
+	}
+}
+
+// WithSecBase makes the manager install the policy of the backends
+// under the given base directory instead of SecBase.
+func WithSecBase(secBase string) Option {
+	return func(m *Manager) {
+		m.secBase = secBase
+	}
+}
+
+// WithBackendDir makes the manager install the policy of the named
+// backend to the given directory instead of under the base directory.
+// A backend that is not registered is handled as well, with the
+// policy groups and templates as its kinds of policy.
+func WithBackendDir(name, dir string) Option {
+	return func(m *Manager) {
+		m.dirs[name] = dir
+		for _, b := range m.backends {
+			if b.Name() == name {
+				return
+			}
+		}
+		m.backends = append(m.backends, &policyBackend{name: name, sets: defaultSets})
+	}
+}
+
+// WithoutReload makes the manager only install the policy files,
+// leaving the running system alone, e.g. when building an image: the
+// SELinux modules are not loaded, nor the apparmor profiles using the
+// changed policy reloaded.
+func WithoutReload() Option {
+	return func(m *Manager) {
+		m.noReload = true
+	}
+}
+
+// WithoutDirSync makes the manager leave alone the directories whose
+// policy files it replaced or removed, instead of syncing them so that
+// the changes survive a power loss. It is only meant for throwaway
+// environments, such as tests or images built in a temporary directory.
+func WithoutDirSync() Option {
+	return func(m *Manager) {
+		m.noDirSync = true
+	}
+}
+
+// WithRootOwner makes the manager give the policy files it installs to
+// root:root, instead of leaving them to the user it runs as, e.g. when
+// building an image as another user allowed to change file owners.
+func WithRootOwner() Option {
+	return func(m *Manager) {
+		m.owner = &fileOwner{uid: 0, gid: 0}
+	}
+}
+
+// WithObserver makes the manager tell the given observer about each of
+// the target files its operations handle, as they go: files copied,
+// skipped as they were the same already, removed, or that an operation
+// failed on. The observer is called for one file at a time, even when
+// copying several at once. The transactional operations only tell
+// about the files once all of the changes are made, or about the file
+// they failed on.
+func WithObserver(observe Observer) Option {
+	return func(m *Manager) {
+		var mu sync.Mutex
+		m.observe = func(ev *FileEvent) {
+			mu.Lock()
+			defer mu.Unlock()
+			observe(ev)
+		}
+	}
+}
+
+// WithForce makes the manager install the policy of a package over
+// target files that are not its own, instead of failing with a
+// ConflictError.
+func WithForce() Option {
+	return func(m *Manager) {
+		m.force = true
+	}
+}
+
+// WithLenientRemove makes Remove carry on past the target files it
+// cannot remove instead of stopping at the first, e.g. to clean up after
+// a partial install: files already gone are counted as Missing, and the
+// other failures are returned together in a RemoveError once all that
+// could be removed is. The transactional removal is left as it is.
+func WithLenientRemove() Option {
+	return func(m *Manager) {
+		m.lenient = true
+	}
+}
+
+// WithWorkers makes the manager copy up to the given number of policy
+// files at once, instead of as many as there are CPUs. Fewer than one
+// means one at a time.
+func WithWorkers(n int) Option {
+	return func(m *Manager) {
+		m.workers = n
+	}
+}
+
+// WithValidator makes the manager check the files of all the kinds of
+// policy of the named backend with the given function before installing
+// them, instead of the checks it does by default. A nil function turns
+// the checks off.
+func WithValidator(name string, validate func(path string) error) Option {
+	return func(m *Manager) {
+		for i, b := range m.backends {
+			if b.Name() == name {
+				m.backends[i] = &validatedBackend{Backend: b, validate: validate}
+			}
+		}
+	}
+}
+
+// WithRegenerator makes the manager have the profiles of the snaps
+// using the policy of a package written again with the given function
+// after the policy of the named backend changed, before the backend
+// makes the system use them. The function returns the paths of the
+// profiles it wrote. Without it, the profiles are left as they are.
+func WithRegenerator(name string, regenerate func(pkgName string) ([]string, error)) Option {
+	return func(m *Manager) {
+		for i, b := range m.backends {
+			if b.Name() == name {
+				m.backends[i] = &regeneratingBackend{Backend: b, regenerate: regenerate}
+			}
+		}
+	}
+}
+
+// WithTemplateExpander makes the manager check the apparmor templates
+// with apparmor_parser once made into profiles with the given function,
+// which fills in their placeholders the way the profiles of the snaps
+// are made from them. Without it, the placeholders known to the
+// interfaces backend are filled in for a made up application. A nil
+// function turns the checks of the templates off.
+func WithTemplateExpander(expand func(template []byte) []byte) Option {
+	return func(m *Manager) {
+		for i, b := range m.backends {
+			if b.Name() == "apparmor" {
+				m.backends[i] = &templateBackend{Backend: b, expand: expand}
+			}
+		}
+	}
+}
+
+// New returns a Manager configured with the given options. By default
+// it handles the registered backends, apparmor, seccomp and, with
+// SELinux enabled, selinux unless changed with RegisterBackend and
+// UnregisterBackend, under SecBase in the real root directory.
+func New(opts ...Option) *Manager {
+	m := &Manager{
+		secBase:  SecBase,
+		workers:  runtime.NumCPU(),
+		backends: registeredBackends(),
+		dirs:     make(map[string]string),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// policyDir is the directory the given kind of policy (e.g. templates)
+// of the backend goes to.
+func (m *Manager) policyDir(b Backend, kind string) string {
+	dir := m.dirs[b.Name()]
+	if dir == "" {
+		dir = filepath.Join(m.secBase, b.Name())
+	}
+	return filepath.Join(m.rootDir, dir, b.TargetDir(kind))
+}
+
+// forEachPolicy calls f for each of the kinds of policy of each of the
+// backends, with the glob of the files of the given package installed
+// in the given path, the target directory and the prefix of the target
+// files.
+func (m *Manager) forEachPolicy(pkgName, instPath string, f func(glob, targetDir, prefix string) error) error {
+	pol := filepath.Join(instPath, "meta", "framework-policy")
+	for _, b := range m.backends {
+		for _, kind := range b.Kinds() {
+			if err := f(filepath.Join(pol, b.Name(), b.SourceGlob(kind)), m.policyDir(b, kind), pkgName+"_"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validatePolicy checks the policy files of the snap installed in the
+// given path with their backends. The SyntaxErrors found in all of the
+// files are returned together.
+func (m *Manager) validatePolicy(instPath string) error {
+	pol := filepath.Join(instPath, "meta", "framework-policy")
+	var syntaxErrs SyntaxErrors
+	for _, b := range m.backends {
+		for _, kind := range b.Kinds() {
+			glob := filepath.Join(pol, b.Name(), b.SourceGlob(kind))
+			files, err := filepath.Glob(glob)
+			if err != nil {
+				return &PathError{Op: "glob", Path: glob, Err: err}
+			}
+			for _, file := range files {
+				source, err := policySource(verify, file)
+				if err != nil {
+					// left for the operation to fail on
+					continue
+				}
+				err = b.Validate(kind, source)
+				if errs, ok := err.(SyntaxErrors); ok {
+					syntaxErrs = append(syntaxErrs, errs...)
+					continue
+				}
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if len(syntaxErrs) > 0 {
+		return syntaxErrs
+	}
+	return nil
+}
+
+// installedPolicy returns the policy files of the given package that
+// are installed for each of the backends.
+func (m *Manager) installedPolicy(pkgName string) (map[Backend][]string, error) {
+	installed := make(map[Backend][]string)
+	for _, b := range m.backends {
+		for _, kind := range b.Kinds() {
+			files, err := m.installedFiles(b, kind, pkgName)
+			if err != nil {
+				return nil, err
+			}
+			installed[b] = append(installed[b], files...)
+		}
+	}
+	return installed, nil
+}
+
+// installedFiles returns the files of the given kind of policy of the
+// backend that are installed for the given package.
+func (m *Manager) installedFiles(b Backend, kind, pkgName string) ([]string, error) {
+	glob := filepath.Join(m.policyDir(b, kind), pkgName+"_"+filepath.Base(b.SourceGlob(kind)))
+	files, err := filepath.Glob(glob)
+	if err != nil {
+		return nil, &PathError{Op: "glob", Path: glob, Err: err}
+	}
+	return files, nil
+}
+
+// ListPolicies returns the policy files installed for the given
+// package, keyed by backend and kind of policy, as in
+// "apparmor/templates". Kinds without files are left out.
+func (m *Manager) ListPolicies(pkgName string) (map[string][]string, error) {
+	policies := make(map[string][]string)
+	for _, b := range m.backends {
+		for _, kind := range b.Kinds() {
+			files, err := m.installedFiles(b, kind, pkgName)
+			if err != nil {
+				return nil, err
+			}
+			if len(files) == 0 {
+				continue
+			}
+			key := b.Name() + "/" + b.TargetDir(kind)
+			policies[key] = append(policies[key], files...)
+		}
+	}
+	return policies, nil
+}
+
+// withLoadedPolicy runs f, which changes the policy files of the given
+// package, and then has the backends make the system use them, telling
+// them which files are installed, which were removed and which were
+// added, changed or removed. Nothing is loaded when working under
+// another root directory, or without reloading.
+func (m *Manager) withLoadedPolicy(pkgName string, f func() error) error {
+	if m.rootDir != "" || m.noReload {
+		return f()
+	}
+
+	before, err := m.installedPolicy(pkgName)
+	if err != nil {
+		return err
+	}
+	contents := make(map[string][]byte)
+	for _, b := range m.backends {
+		for _, file := range before[b] {
+			content, err := ioutil.ReadFile(file)
+			if err != nil {
+				return &PathError{Op: "read", Path: file, Err: err}
+			}
+			contents[file] = content
+		}
+	}
+	if err := f(); err != nil {
+		return err
+	}
+	after, err := m.installedPolicy(pkgName)
+	if err != nil {
+		return err
+	}
+
+	for _, b := range m.backends {
+		changes := &PolicyChanges{Package: pkgName, Installed: after[b]}
+		current := make(map[string]bool, len(after[b]))
+		for _, file := range after[b] {
+			current[file] = true
+		}
+		for _, file := range before[b] {
+			if !current[file] {
+				changes.Removed = append(changes.Removed, file)
+			}
+		}
+		changes.Changed, err = changedPolicy(before[b], after[b], contents)
+		if err != nil {
+			return err
+		}
+		if err := b.PostInstall(changes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// changedPolicy returns the policy files removed, added or changed
+// from before to after, given the contents of the files before.
+func changedPolicy(before, after []string, contents map[string][]byte) ([]string, error) {
+	var changed []string
+	current := make(map[string]bool, len(after))
+	for _, file := range after {
+		current[file] = true
+		old, ok := contents[file]
+		if !ok {
+			changed = append(changed, file)
+			continue
+		}
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, &PathError{Op: "read", Path: file, Err: err}
+		}
+		if !bytes.Equal(old, content) {
+			changed = append(changed, file)
+		}
+	}
+	for _, file := range before {
+		if !current[file] {
+			changed = append(changed, file)
+		}
+	}
+	return changed, nil
+}
+
+// Install sets up the framework's policy from the given snap that's
+// installed in the given path.
+func (m *Manager) Install(pkgName, instPath string) error {
+	_, err := m.frameworkOp(install, pkgName, instPath)
+	return err
+}
+
+// Upgrade brings the framework's policy up to date with the one of the
+// given snap that's installed in the given path, never leaving it
+// missing.
+func (m *Manager) Upgrade(pkgName, instPath string) error {
+	_, err := m.frameworkOp(upgrade, pkgName, instPath)
+	return err
+}
+
+// Remove cleans up the framework's policy recorded in the manifest of
+// the given package, going by the snap installed in the given path only
+// when there is no manifest. It stops at the first target file it
+// cannot remove, unless the manager was made WithLenientRemove.
+func (m *Manager) Remove(pkgName, instPath string) error {
+	_, err := m.frameworkOp(remove, pkgName, instPath)
+	return err
+}
+
+// InstallTransactional is like Install, but either all of the policy
+// is installed or, on failure, nothing is changed. What fails once the
+// policy is in place, such as loading it or updating the manifest,
+// leaves it there and returns a CommitError.
+func (m *Manager) InstallTransactional(pkgName, instPath string) error {
+	_, err := m.FrameworkTransactionContext(context.Background(), install, pkgName, instPath)
+	return err
+}
+
+// UpgradeTransactional is like Upgrade, but either all of the policy
+// is upgraded or, on failure, nothing is changed, but for a
+// CommitError as with InstallTransactional.
+func (m *Manager) UpgradeTransactional(pkgName, instPath string) error {
+	_, err := m.FrameworkTransactionContext(context.Background(), upgrade, pkgName, instPath)
+	return err
+}
+
+// RemoveTransactional is like Remove, but either all of the policy is
+// removed or, on failure, nothing is changed, but for a CommitError as
+// with InstallTransactional.
+func (m *Manager) RemoveTransactional(pkgName, instPath string) error {
+	_, err := m.FrameworkTransactionContext(context.Background(), remove, pkgName, instPath)
+	return err
+}
+
+// PlanInstall returns the operations on the files Install would make,
+// without making them.
+func (m *Manager) PlanInstall(pkgName, instPath string) ([]*FileOp, error) {
+	return m.frameworkPlan(install, pkgName, instPath)
+}
+
+// PlanUpgrade returns the operations on the files Upgrade would make,
+// without making them.
+func (m *Manager) PlanUpgrade(pkgName, instPath string) ([]*FileOp, error) {
+	return m.frameworkPlan(upgrade, pkgName, instPath)
+}
+
+// PlanRemove returns the operations on the files Remove would make,
+// without making them.
+func (m *Manager) PlanRemove(pkgName, instPath string) ([]*FileOp, error) {
+	return m.frameworkPlan(remove, pkgName, instPath)
+}
@@ -0,0 +1,219 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package policy
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *policySuite) TestManagerDefaults(c *C) {
+	SecBase = "/sec"
+	m := New()
+	SecBase = "/other"
+	c.Check(m.policyDir(m.backends[0], "templates"), Equals, "/sec/apparmor/templates")
+	c.Check(m.policyDir(m.backends[1], "policygroups"), Equals, "/sec/seccomp/policygroups")
+}
+
+func (s *policySuite) TestManagerSecBase(c *C) {
+	rootDir := c.MkDir()
+	m := New(WithRootDir(rootDir), WithSecBase("/image/security"))
+	c.Assert(m.Install("foo", s.orig), IsNil)
+
+	g, err := filepath.Glob(filepath.Join(rootDir, "image", "security", "*", "*", "foo_*"))
+	c.Assert(err, IsNil)
+	c.Check(g, HasLen, 4*3)
+
+	plan, err := m.PlanRemove("foo", s.orig)
+	c.Assert(err, IsNil)
+	c.Check(plan, HasLen, 4*3)
+
+	c.Assert(m.RemoveTransactional("foo", s.orig), IsNil)
+	c.Check(policyFiles(c, rootDir), HasLen, 0)
+}
+
+func (s *policySuite) TestManagerBackendDir(c *C) {
+	rootDir := c.MkDir()
+	m := New(WithRootDir(rootDir), WithSecBase("/sec"), WithBackendDir("seccomp", "/etc/seccomp"))
+	c.Assert(m.Upgrade("foo", s.orig), IsNil)
+
+	files := policyFiles(c, rootDir)
+	// and the manifest, under the base directory
+	c.Check(files, HasLen, 4*3+1)
+	c.Check(files["sec/apparmor/templates/foo_templates0"], Equals, "apparmor::templates0")
+	c.Check(files["etc/seccomp/templates/foo_templates0"], Equals, "# seccomp::templates0\nread\n")
+	c.Check(files["sec/manifests/foo.json"], Not(Equals), "")
+}
+
+func (s *policySuite) TestManagerListPolicies(c *C) {
+	rootDir := c.MkDir()
+	m := New(WithRootDir(rootDir), WithSecBase("/sec"), WithBackendDir("seccomp", "/etc/seccomp"))
+
+	policies, err := m.ListPolicies("foo")
+	c.Assert(err, IsNil)
+	c.Check(policies, HasLen, 0)
+
+	c.Assert(m.Install("foo", s.orig), IsNil)
+	c.Assert(m.Install("bar", s.orig), IsNil)
+
+	policies, err = ListPolicies("foo", rootDir)
+	c.Assert(err, IsNil)
+	c.Check(policies, HasLen, 0)
+
+	policies, err = m.ListPolicies("foo")
+	c.Assert(err, IsNil)
+	c.Check(policies, HasLen, 4)
+	c.Check(policies["apparmor/templates"], DeepEquals, []string{
+		filepath.Join(rootDir, "sec/apparmor/templates/foo_templates0"),
+		filepath.Join(rootDir, "sec/apparmor/templates/foo_templates1"),
+		filepath.Join(rootDir, "sec/apparmor/templates/foo_templates2"),
+	})
+	c.Check(policies["seccomp/policygroups"], DeepEquals, []string{
+		filepath.Join(rootDir, "etc/seccomp/policygroups/foo_policygroups0"),
+		filepath.Join(rootDir, "etc/seccomp/policygroups/foo_policygroups1"),
+		filepath.Join(rootDir, "etc/seccomp/policygroups/foo_policygroups2"),
+	})
+	c.Check(policies["apparmor/policygroups"], HasLen, 3)
+	c.Check(policies["seccomp/templates"], HasLen, 3)
+
+	c.Assert(m.Remove("foo", s.orig), IsNil)
+	policies, err = m.ListPolicies("foo")
+	c.Assert(err, IsNil)
+	c.Check(policies, HasLen, 0)
+}
+
+// mockSyncDir records the directories synced, relative to rootDir,
+// failing with err if set.
+func mockSyncDir(rootDir string, err error) (synced map[string]int, restore func()) {
+	synced = make(map[string]int)
+	syncDir = func(dir string) error {
+		synced[strings.TrimPrefix(dir, rootDir+"/")]++
+		return err
+	}
+	return synced, func() { syncDir = syncDirectory }
+}
+
+func (s *policySuite) TestManagerSyncsDirs(c *C) {
+	defer s.mockRegistry()()
+	RegisterBackend(selinuxBackend())
+	rootDir := c.MkDir()
+	synced, restore := mockSyncDir(rootDir, nil)
+	defer restore()
+
+	m := New(WithRootDir(rootDir), WithSecBase("/sec"))
+	c.Assert(m.Install("foo", s.orig), IsNil)
+	c.Check(synced, DeepEquals, map[string]int{
+		"sec/apparmor/policygroups": 1,
+		"sec/apparmor/templates":    1,
+		"sec/seccomp/policygroups":  1,
+		"sec/seccomp/templates":     1,
+		// both kinds of selinux policy go there
+		"sec/selinux/modules": 2,
+		// the new target directories
+		"sec/apparmor": 2,
+		"sec/seccomp":  2,
+		"sec/selinux":  1,
+	})
+
+	for k := range synced {
+		delete(synced, k)
+	}
+	c.Assert(m.Remove("foo", s.orig), IsNil)
+	// only the directories of the files recorded in the manifest
+	c.Check(synced, DeepEquals, map[string]int{
+		"sec/apparmor/policygroups": 1,
+		"sec/apparmor/templates":    1,
+		"sec/seccomp/policygroups":  1,
+		"sec/seccomp/templates":     1,
+	})
+}
+
+func (s *policySuite) TestManagerTransactionSyncsDirs(c *C) {
+	defer s.mockRegistry()()
+	RegisterBackend(selinuxBackend())
+	rootDir := c.MkDir()
+	synced, restore := mockSyncDir(rootDir, nil)
+	defer restore()
+
+	m := New(WithRootDir(rootDir), WithSecBase("/sec"))
+	c.Assert(m.InstallTransactional("foo", s.orig), IsNil)
+	// synced once per transaction
+	c.Check(synced, DeepEquals, map[string]int{
+		"sec/apparmor/policygroups": 1,
+		"sec/apparmor/templates":    1,
+		"sec/seccomp/policygroups":  1,
+		"sec/seccomp/templates":     1,
+		"sec/apparmor":              1,
+		"sec/seccomp":               1,
+		"sec/selinux":               1,
+	})
+}
+
+func (s *policySuite) TestManagerWithoutDirSync(c *C) {
+	rootDir := c.MkDir()
+	synced, restore := mockSyncDir(rootDir, nil)
+	defer restore()
+
+	m := New(WithRootDir(rootDir), WithSecBase("/sec"), WithoutDirSync())
+	c.Assert(m.Install("foo", s.orig), IsNil)
+	c.Assert(m.UpgradeTransactional("foo", s.orig), IsNil)
+	c.Check(synced, HasLen, 0)
+}
+
+func (s *policySuite) TestManagerWithRootOwner(c *C) {
+	rootDir := c.MkDir()
+	m := New(WithRootDir(rootDir), WithSecBase("/sec"), WithRootOwner())
+	c.Check(m.owner, DeepEquals, &fileOwner{uid: 0, gid: 0})
+
+	err := m.Install("foo", s.orig)
+	if os.Getuid() != 0 {
+		c.Check(err, ErrorMatches, "chown .*: operation not permitted")
+		return
+	}
+	c.Assert(err, IsNil)
+	for file := range policyFiles(c, rootDir) {
+		fi, err := os.Stat(filepath.Join(rootDir, file))
+		c.Assert(err, IsNil)
+		c.Check(m.owner.ownedBy(fi), Equals, true, Commentf(file))
+	}
+}
+
+func (s *policySuite) TestManagerSyncDirFails(c *C) {
+	rootDir := c.MkDir()
+	_, restore := mockSyncDir(rootDir, &os.PathError{Op: "sync", Path: "dir", Err: errors.New("input/output error")})
+	defer restore()
+
+	m := New(WithRootDir(rootDir), WithSecBase("/sec"))
+	err := m.Install("foo", s.orig)
+	c.Check(err, ErrorMatches, "sync dir: input/output error")
+}
+
+func (s *policySuite) TestSyncDirectory(c *C) {
+	dir := c.MkDir()
+	c.Check(syncDirectory(dir), IsNil)
+
+	err := syncDirectory(filepath.Join(dir, "missing"))
+	c.Assert(err, FitsTypeOf, &PathError{})
+	c.Check(err, ErrorMatches, "unable to sync .*/missing: .*no such file or directory")
+}
@@ -0,0 +1,293 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package policy
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/snapcore/snapd/osutil"
+)
+
+// A Manifest records the policy files installed for a framework, so
+// that they can be removed, verified and told apart from orphans even
+// once the files of the snap are gone. It is kept as JSON in
+// manifests/<package>.json under the base directory.
+type Manifest struct {
+	Package string          `json:"package"`
+	Files   []*ManifestFile `json:"files"`
+}
+
+// A ManifestFile is a policy file installed for a framework.
+type ManifestFile struct {
+	// Path is the target file, in the root directory of the manager.
+	Path string `json:"path"`
+	// Backend is the name of the backend the file is for.
+	Backend string `json:"backend"`
+	// SHA256 is the hex SHA256 hash of the file as installed.
+	SHA256 string `json:"sha256"`
+}
+
+// manifestPath returns the path of the manifest of the given package.
+func (m *Manager) manifestPath(pkgName string) string {
+	return filepath.Join(m.rootDir, m.secBase, "manifests", pkgName+".json")
+}
+
+// inRoot returns the given path as seen in the root directory of the
+// manager.
+func (m *Manager) inRoot(path string) string {
+	return "/" + strings.TrimLeft(strings.TrimPrefix(path, m.rootDir), "/")
+}
+
+// Manifest returns the manifest recorded when installing the policy of
+// the given package, or nil if there is none.
+func (m *Manager) Manifest(pkgName string) (*Manifest, error) {
+	path := m.manifestPath(pkgName)
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, &PathError{Op: "read", Path: path, Err: err}
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, &PathError{Op: "decode", Path: path, Err: err}
+	}
+	return &manifest, nil
+}
+
+// manifestFiles returns the target files recorded in the manifest of
+// the given package, with the root directory of the manager, and
+// whether there is a manifest.
+func (m *Manager) manifestFiles(pkgName string) (files []string, recorded bool, err error) {
+	manifest, err := m.Manifest(pkgName)
+	if err != nil || manifest == nil {
+		return nil, false, err
+	}
+	files = make([]string, len(manifest.Files))
+	for i, f := range manifest.Files {
+		files[i] = filepath.Join(m.rootDir, f.Path)
+	}
+	return files, true, nil
+}
+
+// updateManifest records the given target files the policy of the
+// package was installed to by op, dropping the manifest once they are
+// removed. Other files of the package in the target directories are
+// left out, for VerifyManifest and Orphans to find.
+func (m *Manager) updateManifest(op Op, pkgName string, targets []string) error {
+	path := m.manifestPath(pkgName)
+	if op == remove {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return targetError("remove", path, err)
+		}
+		return nil
+	}
+
+	installed := m.targetBackends(targets)
+	manifest := &Manifest{Package: pkgName, Files: []*ManifestFile{}}
+	for _, b := range m.backends {
+		sort.Strings(installed[b])
+		for _, file := range installed[b] {
+			_, sum, err := fileDigest(file)
+			if err != nil {
+				return &PathError{Op: "read", Path: file, Err: err}
+			}
+			manifest.Files = append(manifest.Files, &ManifestFile{
+				Path:    m.inRoot(file),
+				Backend: b.Name(),
+				SHA256:  hex.EncodeToString(sum),
+			})
+		}
+	}
+	data, err := json.MarshalIndent(manifest, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return targetError("make directory", filepath.Dir(path), err)
+	}
+	if err := osutil.AtomicWriteFile(path, append(data, '\n'), 0644, 0); err != nil {
+		return targetError("write", path, err)
+	}
+	return nil
+}
+
+// targetBackends groups the given target files by the backend whose
+// policy directories they are in.
+func (m *Manager) targetBackends(targets []string) map[Backend][]string {
+	dirs := make(map[string]Backend)
+	for _, b := range m.backends {
+		for _, kind := range b.Kinds() {
+			dirs[m.policyDir(b, kind)] = b
+		}
+	}
+	grouped := make(map[Backend][]string)
+	for _, file := range targets {
+		if b, ok := dirs[filepath.Dir(file)]; ok {
+			grouped[b] = append(grouped[b], file)
+		}
+	}
+	return grouped
+}
+
+// removeManifestFiles removes the given target files recorded in a
+// manifest, until ctx is done, whatever the snap has now or whether it
+// is still around. A file that is gone already fails the removal with
+// ErrMissingTarget, as it does without a manifest.
+func (m *Manager) removeManifestFiles(ctx context.Context, files []string) (*OpResult, error) {
+	res := &OpResult{}
+	var dirs []string
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := os.Remove(file); err != nil {
+			err = targetError("remove", file, err)
+			m.observe.notify(FileFailed, file, "", err)
+			return nil, err
+		}
+		m.observe.notify(FileRemoved, file, "", nil)
+		res.Removed++
+		dirs = append(dirs, filepath.Dir(file))
+	}
+	return res, m.syncDirs(dirs)
+}
+
+// stageManifest adds to the transaction the removal of the given
+// target files recorded in a manifest that are not changed by it
+// already. A file that is gone already is an ErrMissingTarget.
+func (t *transaction) stageManifest(files []string) error {
+	changed := make(map[string]bool, len(t.changes))
+	for _, change := range t.changes {
+		changed[change.target] = true
+	}
+	for _, file := range files {
+		if changed[file] {
+			continue
+		}
+		if !osutil.FileExists(file) {
+			err := &PathError{Op: "remove", Path: file, Err: ErrMissingTarget}
+			t.observe.notify(FileFailed, file, "", err)
+			return err
+		}
+		t.changes = append(t.changes, &fileChange{
+			target: file,
+			backup: filepath.Join(filepath.Dir(file), "."+filepath.Base(file)+"~old"),
+		})
+	}
+	return nil
+}
+
+// VerifyManifest compares the policy installed for the given package
+// with its manifest, without needing the snap: files of the manifest
+// that are gone are missing, files whose hash changed are modified,
+// and installed files of the package the manifest doesn't record are
+// extraneous. Without a manifest, all of the installed files are.
+func (m *Manager) VerifyManifest(pkgName string) (*VerifyReport, error) {
+	manifest, err := m.Manifest(pkgName)
+	if err != nil {
+		return nil, err
+	}
+	if manifest == nil {
+		manifest = &Manifest{Package: pkgName}
+	}
+
+	report := &VerifyReport{}
+	recorded := make(map[string]bool, len(manifest.Files))
+	for _, f := range manifest.Files {
+		file := filepath.Join(m.rootDir, f.Path)
+		recorded[file] = true
+		_, sum, err := fileDigest(file)
+		if os.IsNotExist(err) {
+			report.Missing = append(report.Missing, file)
+			continue
+		}
+		if err != nil {
+			return nil, &PathError{Op: "read", Path: file, Err: err}
+		}
+		if hex.EncodeToString(sum) != f.SHA256 {
+			report.Modified = append(report.Modified, file)
+		}
+	}
+
+	installed, err := m.installedPolicy(pkgName)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range m.backends {
+		for _, file := range installed[b] {
+			if !recorded[file] {
+				report.Extraneous = append(report.Extraneous, file)
+			}
+		}
+	}
+	return report, nil
+}
+
+// Orphans returns the policy files installed for the backends that no
+// manifest records, e.g. left behind by frameworks that are gone. The
+// files of frameworks installed before manifests were recorded are
+// found as well, until their policy is installed again.
+func (m *Manager) Orphans() ([]string, error) {
+	// with no package installed, all of the policy files are garbage
+	all, err := m.garbage(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var orphans []string
+	for _, pkgName := range garbagePackages(all) {
+		files, _, err := m.manifestFiles(pkgName)
+		if err != nil {
+			return nil, err
+		}
+		recorded := make(map[string]bool, len(files))
+		for _, file := range files {
+			recorded[file] = true
+		}
+		for _, file := range all[pkgName] {
+			if !recorded[file] {
+				orphans = append(orphans, file)
+			}
+		}
+	}
+	return orphans, nil
+}
+
+// VerifyManifest compares the framework's policy installed in the
+// system with its manifest, see Manager.VerifyManifest.
+func VerifyManifest(pkgName, rootDir string) (*VerifyReport, error) {
+	return New(WithRootDir(rootDir)).VerifyManifest(pkgName)
+}
+
+// Orphans returns the policy files installed in the system that no
+// manifest records, see Manager.Orphans.
+func Orphans(rootDir string) ([]string, error) {
+	return New(WithRootDir(rootDir)).Orphans()
+}
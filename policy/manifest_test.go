@@ -0,0 +1,240 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package policy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/net/context"
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/osutil"
+)
+
+func (s *policySuite) TestManifestRecorded(c *C) {
+	rootDir := c.MkDir()
+	m := New(WithRootDir(rootDir), WithSecBase("/sec"))
+
+	manifest, err := m.Manifest("foo")
+	c.Assert(err, IsNil)
+	c.Check(manifest, IsNil)
+
+	err = m.Install("foo", s.orig)
+	c.Assert(err, IsNil)
+	manifest, err = m.Manifest("foo")
+	c.Assert(err, IsNil)
+	c.Check(manifest.Package, Equals, "foo")
+	c.Assert(manifest.Files, HasLen, 4*3)
+	c.Check(manifest.Files[0], DeepEquals, &ManifestFile{
+		Path:    "/sec/apparmor/policygroups/foo_policygroups0",
+		Backend: "apparmor",
+		// sha256 of "apparmor::policygroups0"
+		SHA256: "9e3a24482c9f81978967f990b1189ae9cc9b7f8d767371df1ed97c470e70cca2",
+	})
+	c.Check(manifest.Files[11].Path, Equals, "/sec/seccomp/templates/foo_templates2")
+	c.Check(manifest.Files[11].Backend, Equals, "seccomp")
+
+	c.Assert(os.Remove(filepath.Join(s.appg, "policygroups2")), IsNil)
+	err = m.UpgradeTransactional("foo", s.orig)
+	c.Assert(err, IsNil)
+	manifest, err = m.Manifest("foo")
+	c.Assert(err, IsNil)
+	c.Check(manifest.Files, HasLen, 4*3-1)
+
+	err = m.Remove("foo", s.orig)
+	c.Assert(err, IsNil)
+	c.Check(osutil.FileExists(filepath.Join(rootDir, "sec", "manifests", "foo.json")), Equals, false)
+}
+
+func (s *policySuite) TestManifestRemoveWithoutSnap(c *C) {
+	for _, transactional := range []bool{false, true} {
+		rootDir := c.MkDir()
+		m := New(WithRootDir(rootDir), WithSecBase("/sec"))
+		err := m.Install("foo", s.orig)
+		c.Assert(err, IsNil)
+
+		// the files of the snap are gone
+		var res *OpResult
+		if transactional {
+			res, err = m.FrameworkTransactionContext(context.Background(), OpRemove, "foo", c.MkDir())
+		} else {
+			res, err = m.FrameworkOpContext(context.Background(), OpRemove, "foo", c.MkDir())
+		}
+		c.Assert(err, IsNil)
+		c.Check(res, DeepEquals, &OpResult{Removed: 4 * 3})
+		c.Check(policyFiles(c, rootDir), HasLen, 0)
+	}
+}
+
+func (s *policySuite) TestManifestRemoveExact(c *C) {
+	for _, transactional := range []bool{false, true} {
+		rootDir := c.MkDir()
+		m := New(WithRootDir(rootDir), WithSecBase("/sec"))
+		err := m.Install("foo", s.orig)
+		c.Assert(err, IsNil)
+		err = m.Install("bar", s.orig)
+		c.Assert(err, IsNil)
+
+		// the snap is unpacked differently now
+		snapDir := c.MkDir()
+		polDir := filepath.Join(snapDir, "meta", "framework-policy", "apparmor", "policygroups")
+		c.Assert(os.MkdirAll(polDir, 0755), IsNil)
+		c.Assert(ioutil.WriteFile(filepath.Join(polDir, "other"), nil, 0644), IsNil)
+
+		var res *OpResult
+		if transactional {
+			res, err = m.FrameworkTransactionContext(context.Background(), OpRemove, "foo", snapDir)
+		} else {
+			res, err = m.FrameworkOpContext(context.Background(), OpRemove, "foo", snapDir)
+		}
+		c.Assert(err, IsNil)
+		c.Check(res, DeepEquals, &OpResult{Removed: 4 * 3})
+		installed, err := m.ListPolicies("foo")
+		c.Assert(err, IsNil)
+		c.Check(installed, HasLen, 0)
+		// the policy of other packages is left alone
+		installed, err = m.ListPolicies("bar")
+		c.Assert(err, IsNil)
+		c.Check(installed, HasLen, 4)
+	}
+}
+
+func (s *policySuite) TestManifestRemoveMissing(c *C) {
+	for _, transactional := range []bool{false, true} {
+		rootDir := c.MkDir()
+		m := New(WithRootDir(rootDir), WithSecBase("/sec"))
+		err := m.Install("foo", s.orig)
+		c.Assert(err, IsNil)
+		missing := filepath.Join(rootDir, "sec", "seccomp", "templates", "foo_templates1")
+		c.Assert(os.Remove(missing), IsNil)
+
+		if transactional {
+			err = m.RemoveTransactional("foo", s.orig)
+		} else {
+			err = m.Remove("foo", s.orig)
+		}
+		c.Check(err, ErrorMatches, "unable to remove .*/foo_templates1: not found")
+		c.Check(IsMissingTarget(err), Equals, true)
+		// kept for the removal to be tried again
+		manifest, err := m.Manifest("foo")
+		c.Assert(err, IsNil)
+		c.Check(manifest, NotNil)
+	}
+}
+
+func (s *policySuite) TestVerifyManifest(c *C) {
+	rootDir := c.MkDir()
+	SecBase = "/sec"
+	err := Install("foo", s.orig, rootDir)
+	c.Assert(err, IsNil)
+
+	report, err := VerifyManifest("foo", rootDir)
+	c.Assert(err, IsNil)
+	c.Check(report.OK(), Equals, true)
+
+	target := func(name string) string {
+		return filepath.Join(rootDir, "sec", "apparmor", "policygroups", name)
+	}
+	c.Assert(ioutil.WriteFile(target("foo_policygroups0"), []byte("apparmor::policygroupsX"), 0644), IsNil)
+	c.Assert(os.Remove(target("foo_policygroups2")), IsNil)
+	c.Assert(ioutil.WriteFile(target("foo_extra"), nil, 0644), IsNil)
+	// no snap needed
+	c.Assert(os.RemoveAll(s.orig), IsNil)
+
+	report, err = VerifyManifest("foo", rootDir)
+	c.Assert(err, IsNil)
+	c.Check(report, DeepEquals, &VerifyReport{
+		Missing:    []string{target("foo_policygroups2")},
+		Modified:   []string{target("foo_policygroups0")},
+		Extraneous: []string{target("foo_extra")},
+	})
+}
+
+func (s *policySuite) TestManifestOnlyInstalled(c *C) {
+	rootDir := c.MkDir()
+	m := New(WithRootDir(rootDir), WithSecBase("/sec"))
+	stale := filepath.Join(rootDir, "sec", "apparmor", "policygroups", "foo_stale")
+	c.Assert(os.MkdirAll(filepath.Dir(stale), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(stale, nil, 0644), IsNil)
+
+	for _, install := range []func(string, string) error{m.Install, m.InstallTransactional} {
+		c.Assert(install("foo", s.orig), IsNil)
+		manifest, err := m.Manifest("foo")
+		c.Assert(err, IsNil)
+		c.Check(manifest.Files, HasLen, 4*3)
+
+		report, err := m.VerifyManifest("foo")
+		c.Assert(err, IsNil)
+		c.Check(report.Extraneous, DeepEquals, []string{stale})
+	}
+}
+
+func (s *policySuite) TestVerifyManifestMissing(c *C) {
+	rootDir := c.MkDir()
+	m := New(WithRootDir(rootDir), WithSecBase("/sec"))
+	err := m.Install("foo", s.orig)
+	c.Assert(err, IsNil)
+	c.Assert(os.Remove(filepath.Join(rootDir, "sec", "manifests", "foo.json")), IsNil)
+
+	report, err := m.VerifyManifest("foo")
+	c.Assert(err, IsNil)
+	c.Check(report.Missing, HasLen, 0)
+	c.Check(report.Extraneous, HasLen, 4*3)
+}
+
+func (s *policySuite) TestManifestBroken(c *C) {
+	rootDir := c.MkDir()
+	m := New(WithRootDir(rootDir), WithSecBase("/sec"))
+	err := m.Install("foo", s.orig)
+	c.Assert(err, IsNil)
+	path := filepath.Join(rootDir, "sec", "manifests", "foo.json")
+	c.Assert(ioutil.WriteFile(path, []byte("{"), 0644), IsNil)
+
+	_, err = m.Manifest("foo")
+	c.Check(err, ErrorMatches, `unable to decode .*/sec/manifests/foo.json: unexpected end of JSON input`)
+	err = m.Remove("foo", s.orig)
+	c.Check(err, ErrorMatches, `unable to decode .*`)
+}
+
+func (s *policySuite) TestOrphans(c *C) {
+	rootDir := c.MkDir()
+	m := New(WithRootDir(rootDir), WithSecBase("/sec"))
+	err := m.Install("foo", s.orig)
+	c.Assert(err, IsNil)
+	err = m.Install("bar", s.orig)
+	c.Assert(err, IsNil)
+
+	orphans, err := m.Orphans()
+	c.Assert(err, IsNil)
+	c.Check(orphans, HasLen, 0)
+
+	// bar went away without its policy being removed
+	c.Assert(os.Remove(filepath.Join(rootDir, "sec", "manifests", "bar.json")), IsNil)
+	// hidden files are left to the operations
+	c.Assert(ioutil.WriteFile(filepath.Join(rootDir, "sec", "seccomp", "templates", ".foo_templates0~new"), nil, 0644), IsNil)
+
+	orphans, err = m.Orphans()
+	c.Assert(err, IsNil)
+	c.Assert(orphans, HasLen, 4*3)
+	c.Check(orphans[0], Equals, filepath.Join(rootDir, "sec", "apparmor", "policygroups", "bar_policygroups0"))
+	c.Check(orphans[11], Equals, filepath.Join(rootDir, "sec", "seccomp", "templates", "bar_templates2"))
+}
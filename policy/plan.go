@@ -0,0 +1,88 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package policy
+
+import (
+	"github.com/snapcore/snapd/osutil"
+)
+
+// FileAction is what an operation would do to a target file.
+type FileAction string
+
+// The actions on the target files.
+const (
+	FileCreate    FileAction = "create"
+	FileOverwrite FileAction = "overwrite"
+	FileRemove    FileAction = "remove"
+)
+
+// A FileOp is an action an operation would take on a target file, with
+// the policy file it would be copied from, if any.
+type FileOp struct {
+	Action FileAction
+	Path   string
+	Source string
+}
+
+// frameworkPlan works out the operations on the target files the given
+// operation (Install, Remove or Upgrade) would make for the given
+// package that's installed in the given path, without touching them.
+func (m *Manager) frameworkPlan(op Op, pkgName, instPath string) ([]*FileOp, error) {
+	if err := m.checkConflicts(op, pkgName, instPath); err != nil {
+		return nil, err
+	}
+	t := &transaction{op: op, dryRun: true, owner: m.owner}
+	if err := m.stagePolicy(t, pkgName, instPath); err != nil {
+		return nil, err
+	}
+
+	plan := make([]*FileOp, len(t.changes))
+	for i, change := range t.changes {
+		fop := &FileOp{Path: change.target, Source: change.source}
+		switch {
+		case change.source == "":
+			fop.Action = FileRemove
+		case osutil.FileExists(change.target):
+			fop.Action = FileOverwrite
+		default:
+			fop.Action = FileCreate
+		}
+		plan[i] = fop
+	}
+	return plan, nil
+}
+
+// PlanInstall returns the operations on the files of the system Install
+// would make, without making them.
+func PlanInstall(pkgName, instPath, rootDir string) ([]*FileOp, error) {
+	return New(WithRootDir(rootDir)).PlanInstall(pkgName, instPath)
+}
+
+// PlanUpgrade returns the operations on the files of the system Upgrade
+// would make, without making them.
+func PlanUpgrade(pkgName, instPath, rootDir string) ([]*FileOp, error) {
+	return New(WithRootDir(rootDir)).PlanUpgrade(pkgName, instPath)
+}
+
+// PlanRemove returns the operations on the files of the system Remove
+// would make, without making them.
+func PlanRemove(pkgName, instPath, rootDir string) ([]*FileOp, error) {
+	return New(WithRootDir(rootDir)).PlanRemove(pkgName, instPath)
+}
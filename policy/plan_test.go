@@ -0,0 +1,102 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package policy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *policySuite) TestPlanInstall(c *C) {
+	rootDir := c.MkDir()
+	SecBase = "/sec"
+	c.Assert(os.MkdirAll(filepath.Join(rootDir, "sec", "apparmor", "policygroups"), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(rootDir, "sec", "apparmor", "policygroups", "foo_policygroups0"), []byte("old"), 0644), IsNil)
+
+	// not installed, the file is not its own
+	_, err := PlanInstall("foo", s.orig, rootDir)
+	c.Check(err, ErrorMatches, `policy of "foo" conflicts with existing file .*/foo_policygroups0`)
+	// but it is when upgraded, as installed before manifests were kept
+	plan, err := PlanUpgrade("foo", s.orig, rootDir)
+	c.Assert(err, IsNil)
+	c.Check(plan, HasLen, 4*3)
+
+	plan, err = New(WithRootDir(rootDir), WithForce()).PlanInstall("foo", s.orig)
+	c.Assert(err, IsNil)
+	c.Assert(plan, HasLen, 4*3)
+	c.Check(plan[0], DeepEquals, &FileOp{
+		Action: FileOverwrite,
+		Path:   filepath.Join(rootDir, "sec", "apparmor", "policygroups", "foo_policygroups0"),
+		Source: filepath.Join(s.appg, "policygroups0"),
+	})
+	c.Check(plan[1], DeepEquals, &FileOp{
+		Action: FileCreate,
+		Path:   filepath.Join(rootDir, "sec", "apparmor", "policygroups", "foo_policygroups1"),
+		Source: filepath.Join(s.appg, "policygroups1"),
+	})
+	c.Check(plan[11].Path, Equals, filepath.Join(rootDir, "sec", "seccomp", "templates", "foo_templates2"))
+
+	// nothing was touched
+	c.Check(policyFiles(c, rootDir), DeepEquals, map[string]string{
+		"sec/apparmor/policygroups/foo_policygroups0": "old",
+	})
+	_, err = os.Stat(filepath.Join(rootDir, "sec", "seccomp"))
+	c.Check(os.IsNotExist(err), Equals, true)
+}
+
+func (s *policySuite) TestPlanUpgradeRemove(c *C) {
+	rootDir := c.MkDir()
+	SecBase = "/sec"
+	c.Assert(Install("foo", s.orig, rootDir), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(s.appg, "policygroups1"), []byte("changed"), 0644), IsNil)
+	c.Assert(os.Remove(filepath.Join(s.appg, "policygroups2")), IsNil)
+	before := policyFiles(c, rootDir)
+
+	target := func(name string) string {
+		return filepath.Join(rootDir, "sec", "apparmor", "policygroups", name)
+	}
+	plan, err := PlanUpgrade("foo", s.orig, rootDir)
+	c.Assert(err, IsNil)
+	c.Check(plan, DeepEquals, []*FileOp{
+		{Action: FileOverwrite, Path: target("foo_policygroups1"), Source: filepath.Join(s.appg, "policygroups1")},
+		{Action: FileRemove, Path: target("foo_policygroups2")},
+	})
+
+	// the files recorded when installing, whatever the snap has now
+	plan, err = PlanRemove("foo", s.orig, rootDir)
+	c.Assert(err, IsNil)
+	c.Check(plan, HasLen, 4*3)
+	for _, fop := range plan {
+		c.Check(fop.Action, Equals, FileRemove)
+		c.Check(fop.Source, Equals, "")
+	}
+
+	c.Check(policyFiles(c, rootDir), DeepEquals, before)
+}
+
+func (s *policySuite) TestPlanError(c *C) {
+	bad := filepath.Join(s.appg, "badbad")
+	c.Assert(os.Symlink(bad, bad), IsNil)
+	_, err := PlanInstall("foo", s.orig, c.MkDir())
+	c.Check(err, ErrorMatches, ".*badbad: not a regular file")
+}
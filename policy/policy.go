@@ -22,47 +22,244 @@
 package policy
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+
+	"golang.org/x/net/context"
 
 	"github.com/snapcore/snapd/osutil"
 )
 
 var (
 	// SecBase is the directory to which the security policies and templates
-	// are copied
+	// are copied, unless a Manager is given another one
 	SecBase = "/var/lib/snappy"
 )
 
-type policyOp uint
+// An Op is an operation on the policy of a package, as performed by
+// FrameworkOpContext.
+type Op uint
 
 const (
-	install policyOp = iota
+	install Op = iota
 	remove
+	upgrade
+	verify
+)
+
+// The operations FrameworkOpContext performs.
+const (
+	OpInstall = install
+	OpRemove  = remove
+	OpUpgrade = upgrade
 )
 
-func (op policyOp) String() string {
+func (op Op) String() string {
 	switch op {
 	case remove:
 		return "Remove"
 	case install:
 		return "Install"
+	case upgrade:
+		return "Upgrade"
+	case verify:
+		return "Verify"
 	default:
-		return fmt.Sprintf("policyOp(%d)", op)
+		return fmt.Sprintf("Op(%d)", op)
+	}
+}
+
+// An OpResult tells what an operation did to the target files, as returned
+// by FrameworkOpContext and Manager.FrameworkTransactionContext.
+type OpResult struct {
+	// Copied is how many target files were created or overwritten.
+	Copied int
+	// Skipped is how many target files were left alone as they were
+	// already the same as the files of the framework.
+	Skipped int
+	// Removed is how many target files were removed.
+	Removed int
+	// Missing is how many target files to remove were gone already.
+	Missing int
+}
+
+func (r *OpResult) add(other *OpResult) {
+	r.Copied += other.Copied
+	r.Skipped += other.Skipped
+	r.Removed += other.Removed
+	r.Missing += other.Missing
+}
+
+// FileEventKind is what an operation did to a target file.
+type FileEventKind string
+
+// The kinds of events on the target files.
+const (
+	FileCopied  FileEventKind = "copied"
+	FileSkipped FileEventKind = "skipped"
+	FileRemoved FileEventKind = "removed"
+	FileMissing FileEventKind = "missing"
+	FileFailed  FileEventKind = "failed"
+)
+
+// A FileEvent tells what an operation did to a target file, with the
+// policy file it was copied from, if any.
+type FileEvent struct {
+	Kind   FileEventKind
+	Path   string
+	Source string
+	// Err is why the operation failed on the file, for FileFailed.
+	Err error
+}
+
+// An Observer is told about each of the target files as an operation
+// handles them, see WithObserver.
+type Observer func(ev *FileEvent)
+
+// notify tells the observer, if any, about the event on the given
+// target file.
+func (observe Observer) notify(kind FileEventKind, path, source string, err error) {
+	if observe != nil {
+		observe(&FileEvent{Kind: kind, Path: path, Source: source, Err: err})
+	}
+}
+
+// fileOwner is who the policy files are made to belong to.
+type fileOwner struct {
+	uid, gid int
+}
+
+// ownedBy returns whether the file with the given info belongs to owner.
+func (owner *fileOwner) ownedBy(fi os.FileInfo) bool {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	return ok && int(st.Uid) == owner.uid && int(st.Gid) == owner.gid
+}
+
+// fileMode returns the mode bits of a policy file that its copies keep:
+// the permissions, and the setuid, setgid and sticky bits.
+func fileMode(fi os.FileInfo) os.FileMode {
+	return fi.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+}
+
+// sameContents returns whether the target file is a regular file with
+// the size, the SHA256 hash and the mode of the source file, and
+// belonging to owner unless it is nil, so that copying it over can be
+// skipped, not to touch its modification time and have the profiles
+// using it recompiled for nothing.
+func sameContents(source, target string, owner *fileOwner) bool {
+	return sameMode(source, target, owner) && sameDigest(source, target)
+}
+
+// sameDigest returns whether the target file is a regular file with the
+// size and the SHA256 hash of the source file.
+func sameDigest(source, target string) bool {
+	ts, err := os.Lstat(target)
+	if err != nil || !ts.Mode().IsRegular() {
+		return false
+	}
+	size, sum, err := fileDigest(source)
+	if err != nil || size != ts.Size() {
+		return false
+	}
+	_, targetSum, err := fileDigest(target)
+	return err == nil && bytes.Equal(sum, targetSum)
+}
+
+// sameMode returns whether the target file has the mode of the source
+// file and, if owner is set, belongs to it.
+func sameMode(source, target string, owner *fileOwner) bool {
+	ts, err := os.Lstat(target)
+	if err != nil {
+		return false
+	}
+	ss, err := os.Stat(source)
+	if err != nil || fileMode(ss) != fileMode(ts) {
+		return false
+	}
+	return owner == nil || owner.ownedBy(ts)
+}
+
+// policyDirOf returns the policy directory of the snap, meta/framework-policy,
+// that the given file of its policy is found in, or "" if there is none.
+func policyDirOf(file string) string {
+	for dir := filepath.Dir(file); dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+		if filepath.Base(dir) == "framework-policy" && filepath.Base(filepath.Dir(dir)) == "meta" {
+			return dir
+		}
+	}
+	return ""
+}
+
+// policySource returns the file the content of the given file of the
+// policy of the snap is read from for doing op: the file itself if it is
+// a regular file, or the one it resolves to if it is a symlink to a
+// regular file within the policy directory of the snap, as frameworks
+// share policy groups this way. Anything else is refused.
+func policySource(op Op, file string) (string, error) {
+	s, err := os.Lstat(file)
+	if err != nil {
+		return "", &PathError{Op: "stat", Path: file, Err: err}
+	}
+	if s.Mode().IsRegular() {
+		return file, nil
+	}
+	notRegular := &PathError{Op: "do " + op.String() + " for", Path: file, Err: ErrNotRegularFile}
+	if s.Mode()&os.ModeSymlink == 0 {
+		return "", notRegular
+	}
+
+	source, err := filepath.EvalSymlinks(file)
+	if err != nil {
+		// dangling or looping
+		return "", notRegular
+	}
+	polDir := policyDirOf(file)
+	if polDir != "" {
+		// the snap may well be found through symlinks itself
+		resolved, err := filepath.EvalSymlinks(polDir)
+		if err != nil {
+			return "", &PathError{Op: "resolve", Path: polDir, Err: err}
+		}
+		polDir = resolved
+	}
+	if polDir == "" || !strings.HasPrefix(source, polDir+"/") {
+		return "", &PathError{Op: "do " + op.String() + " for", Path: file, Err: ErrLinkEscapes}
 	}
+	if s, err := os.Stat(source); err != nil || !s.Mode().IsRegular() {
+		return "", notRegular
+	}
+	return source, nil
 }
 
 // iterOp iterates over all the files found with the given glob, making the
 // basename (with the given prefix prepended) the target file in the given
 // target directory. It then performs op on that target file: either copying
-// from the globbed file to the target file, or removing the target file.
-// Directories are created as needed. Errors out with any of the things that
-// could go wrong with this, including a file found by glob not being a
-// regular file.
-func iterOp(op policyOp, glob, targetDir, prefix string) (err error) {
-	if err = os.MkdirAll(targetDir, 0755); err != nil {
-		return fmt.Errorf("unable to make %v directory: %v", targetDir, err)
+// from the globbed file to the target file, unless they are the same
+// already, or removing the target file. Directories are created as needed.
+// Errors out with any of the things that could go wrong with this,
+// including a file found by glob not being a regular file, nor a symlink
+// to one within the policy directory of the snap, see policySource.
+//
+// Up to the given number of target files are handled at once. When more
+// than one of them fail, the error is the one of the first file found with
+// the glob, no matter which failed first. Once ctx is done, the files
+// being copied are abandoned, and the remaining ones left alone.
+//
+// Target files are replaced atomically, so that they are never seen half
+// written, keeping the mode of the files found with the glob, and given
+// to owner unless it is nil. Upgrading then removes the target files with
+// the given prefix that match the glob but are no longer found with it,
+// so the policy is never missing. The observer, if any, is told about
+// each target file once it is handled, from the goroutine handling it.
+func iterOp(ctx context.Context, op Op, glob, targetDir, prefix string, workers int, owner *fileOwner, observe Observer) (*OpResult, error) {
+	if err := os.MkdirAll(targetDir, 0755); err != nil {
+		return nil, targetError("make directory", targetDir, err)
 	}
 
 	files, err := filepath.Glob(glob)
@@ -70,63 +267,394 @@ func iterOp(op policyOp, glob, targetDir, prefix string) (err error) {
 		// filepath.Glob seems to not return errors ever right
 		// now. This might be a bug in Go, or it might be by
 		// design. Better play safe.
-		return fmt.Errorf("unable to glob %v: %v", glob, err)
+		return nil, &PathError{Op: "glob", Path: glob, Err: err}
 	}
 
-	for _, file := range files {
-		s, err := os.Lstat(file)
+	sources := make([]string, len(files))
+	targets := make([]string, len(files))
+	keep := make(map[string]bool, len(files))
+	for i, file := range files {
+		sources[i], err = policySource(op, file)
 		if err != nil {
-			return fmt.Errorf("unable to stat %v: %v", file, err)
+			return nil, err
 		}
 
-		if !s.Mode().IsRegular() {
-			return fmt.Errorf("unable to do %s for %v: not a regular file", op, file)
+		targets[i] = filepath.Join(targetDir, prefix+filepath.Base(file))
+		keep[targets[i]] = true
+	}
+
+	switch op {
+	case install, upgrade, remove:
+	default:
+		return nil, fmt.Errorf("unknown operation %s", op)
+	}
+
+	skipped := make([]bool, len(files))
+	errs := make([]error, len(files))
+	parallel(len(files), workers, func(i int) {
+		skipped[i], errs[i] = fileOp(ctx, op, sources[i], targets[i], owner)
+		switch {
+		case errs[i] != nil:
+			observe.notify(FileFailed, targets[i], sources[i], errs[i])
+		case op == remove:
+			observe.notify(FileRemoved, targets[i], "", nil)
+		case skipped[i]:
+			observe.notify(FileSkipped, targets[i], sources[i], nil)
+		default:
+			observe.notify(FileCopied, targets[i], sources[i], nil)
 		}
+	})
 
-		targetFile := filepath.Join(targetDir, prefix+filepath.Base(file))
-		switch op {
-		case remove:
+	res := &OpResult{}
+	for i := range files {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		switch {
+		case op == remove:
+			res.Removed++
+		case skipped[i]:
+			res.Skipped++
+		default:
+			res.Copied++
+		}
+	}
+
+	if op == upgrade {
+		// only drop the stale files once the new ones are in place
+		installed, err := filepath.Glob(filepath.Join(targetDir, prefix+filepath.Base(glob)))
+		if err != nil {
+			return nil, &PathError{Op: "glob", Path: targetDir, Err: err}
+		}
+		for _, targetFile := range installed {
+			if keep[targetFile] {
+				continue
+			}
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
 			if err := os.Remove(targetFile); err != nil {
-				return fmt.Errorf("unable to remove %v: %v", targetFile, err)
+				err = targetError("remove", targetFile, err)
+				observe.notify(FileFailed, targetFile, "", err)
+				return nil, err
 			}
-		case install:
-			// do the copy
-			if err := osutil.CopyFile(file, targetFile, osutil.CopyFlagSync|osutil.CopyFlagOverwrite); err != nil {
-				return err
+			observe.notify(FileRemoved, targetFile, "", nil)
+			res.Removed++
+		}
+	}
+
+	return res, nil
+}
+
+// policyTargets returns the target files of the files found with the
+// glob, as iterOp names them.
+func policyTargets(glob, targetDir, prefix string) ([]string, error) {
+	files, err := filepath.Glob(glob)
+	if err != nil {
+		return nil, &PathError{Op: "glob", Path: glob, Err: err}
+	}
+	targets := make([]string, len(files))
+	for i, file := range files {
+		targets[i] = filepath.Join(targetDir, prefix+filepath.Base(file))
+	}
+	return targets, nil
+}
+
+// fileOp performs op on the given target file from the given file,
+// returning whether copying was skipped as they are the same already.
+func fileOp(ctx context.Context, op Op, file, targetFile string, owner *fileOwner) (skipped bool, err error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	switch op {
+	case remove:
+		if err := os.Remove(targetFile); err != nil {
+			return false, targetError("remove", targetFile, err)
+		}
+		return false, nil
+	default:
+		if sameContents(file, targetFile, owner) {
+			return true, nil
+		}
+		return false, replaceFile(ctx, file, targetFile, owner)
+	}
+}
+
+// parallel calls f with each index from 0 to n-1, from up to the given
+// number of goroutines at once, and waits for all the calls to return.
+func parallel(n, workers int, f func(i int)) {
+	if workers > n {
+		workers = n
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				f(i)
 			}
-		default:
-			return fmt.Errorf("unknown operation %s", op)
+		}()
+	}
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+}
+
+// copyChunk is how much of a file is copied at once, before checking
+// whether to carry on.
+var copyChunk = 32 * 1024
+
+// copyFile copies src over dst and syncs it, as osutil.CopyFile, giving
+// up once ctx is done. What was written of dst is removed on failure.
+// Unlike osutil.CopyFile, dst gets the mode of src whatever the umask,
+// and is given to owner unless it is nil.
+func copyFile(ctx context.Context, src, dst string, owner *fileOwner) (err error) {
+	fin, err := os.Open(src)
+	if err != nil {
+		return &PathError{Op: "open", Path: src, Err: err}
+	}
+	defer fin.Close()
+	fi, err := fin.Stat()
+	if err != nil {
+		return &PathError{Op: "stat", Path: src, Err: err}
+	}
+
+	fout, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fi.Mode())
+	if err != nil {
+		return targetError("create", dst, err)
+	}
+	defer func() {
+		if cerr := fout.Close(); cerr != nil && err == nil {
+			err = &PathError{Op: "close", Path: dst, Err: cerr}
+		}
+		if err != nil {
+			os.Remove(dst)
+		}
+	}()
+
+	// an existing dst, or the umask, would not give it the mode of src
+	if err := fout.Chmod(fileMode(fi)); err != nil {
+		return &PathError{Op: "chmod", Path: dst, Err: err}
+	}
+	if owner != nil {
+		if err := fout.Chown(owner.uid, owner.gid); err != nil {
+			return &PathError{Op: "chown", Path: dst, Err: err}
+		}
+	}
+
+	buf := make([]byte, copyChunk)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n, rerr := fin.Read(buf)
+		if n > 0 {
+			if _, err := fout.Write(buf[:n]); err != nil {
+				return &PathError{Op: "copy " + src + " to", Path: dst, Err: err}
+			}
+		}
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			return &PathError{Op: "copy " + src + " to", Path: dst, Err: rerr}
 		}
 	}
+	if err := fout.Sync(); err != nil {
+		return &PathError{Op: "sync", Path: dst, Err: err}
+	}
+	return nil
+}
 
+// replaceFile copies src over dst going through a temporary file next to
+// it, synced and then renamed over dst, so that dst is never missing nor
+// half written, even if snappy crashes while copying. The temporary file
+// is hidden not to be taken for a policy file.
+func replaceFile(ctx context.Context, src, dst string, owner *fileOwner) error {
+	tmp := filepath.Join(filepath.Dir(dst), "."+filepath.Base(dst)+".tmp")
+	if err := copyFile(ctx, src, tmp, owner); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return targetError("replace", dst, err)
+	}
 	return nil
 }
 
-// frameworkOp perform the given operation (either Install or Remove) on the
-// given package that's installed in the given path.
-func frameworkOp(op policyOp, pkgName, instPath, rootDir string) error {
-	pol := filepath.Join(instPath, "meta", "framework-policy")
-	for _, i := range []string{"apparmor", "seccomp"} {
-		for _, j := range []string{"policygroups", "templates"} {
-			if err := iterOp(op, filepath.Join(pol, i, j, "*"), filepath.Join(rootDir, SecBase, i, j), pkgName+"_"); err != nil {
+// syncDir is syncDirectory, mocked in the tests.
+var syncDir = syncDirectory
+
+// syncDirectory syncs dir, for the files renamed into or removed from it
+// to stay so after a crash.
+func syncDirectory(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return &PathError{Op: "sync", Path: dir, Err: err}
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return &PathError{Op: "sync", Path: dir, Err: err}
+	}
+	return nil
+}
+
+// syncDirs syncs each of the given directories once, unless the manager
+// was made WithoutDirSync.
+func (m *Manager) syncDirs(dirs []string) error {
+	if m.noDirSync {
+		return nil
+	}
+	seen := make(map[string]bool, len(dirs))
+	for _, dir := range dirs {
+		if seen[dir] {
+			continue
+		}
+		seen[dir] = true
+		if err := syncDir(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// frameworkOp perform the given operation (Install, Remove or Upgrade) on the
+// given package that's installed in the given path, returning what it did to
+// the target files. The policy is validated first, unless it is being
+// removed.
+func (m *Manager) frameworkOp(op Op, pkgName, instPath string) (*OpResult, error) {
+	return m.FrameworkOpContext(context.Background(), op, pkgName, instPath)
+}
+
+// FrameworkOpContext performs the given operation (OpInstall, OpRemove or
+// OpUpgrade) on the given package that's installed in the given path, as
+// Install, Remove and Upgrade, until ctx is done: the files being copied
+// are then abandoned and removed, the remaining ones left alone, and
+// ctx.Err() returned. Neither the policy files already handled nor the
+// SELinux modules are put back as they were. Once done, the installed
+// files are recorded in the manifest of the package. Removing removes
+// the files the manifest records, without looking at the snap, which
+// is only used for the policy installed before manifests were kept.
+// Target files in the way that are not the package's own are refused
+// with a ConflictError, unless the manager was made WithForce.
+func (m *Manager) FrameworkOpContext(ctx context.Context, op Op, pkgName, instPath string) (*OpResult, error) {
+	if op == remove && m.lenient {
+		return m.removeLenient(ctx, pkgName, instPath)
+	}
+	if op != remove {
+		if err := m.validatePolicy(instPath); err != nil {
+			return nil, err
+		}
+	}
+	if err := m.checkConflicts(op, pkgName, instPath); err != nil {
+		return nil, err
+	}
+	res := &OpResult{}
+	var installed []string
+	err := m.withLoadedPolicy(pkgName, func() error {
+		if op == remove {
+			files, recorded, err := m.manifestFiles(pkgName)
+			if err != nil {
 				return err
 			}
+			if recorded {
+				r, err := m.removeManifestFiles(ctx, files)
+				if err != nil {
+					return err
+				}
+				res.add(r)
+				return nil
+			}
 		}
+		return m.forEachPolicy(pkgName, instPath, func(glob, targetDir, prefix string) error {
+			made := !osutil.IsDirectory(targetDir)
+			r, err := iterOp(ctx, op, glob, targetDir, prefix, m.workers, m.owner, m.observe)
+			if err != nil {
+				return err
+			}
+			res.add(r)
+			if op != remove {
+				targets, err := policyTargets(glob, targetDir, prefix)
+				if err != nil {
+					return err
+				}
+				installed = append(installed, targets...)
+			}
+			dirs := []string{targetDir}
+			if made {
+				// for the new directory itself to be kept
+				dirs = append(dirs, filepath.Dir(targetDir))
+			}
+			return m.syncDirs(dirs)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := m.updateManifest(op, pkgName, installed); err != nil {
+		return nil, err
 	}
+	return res, nil
+}
 
-	return nil
+// FrameworkOpContext performs the given operation on the policy of the
+// package, as Manager.FrameworkOpContext with a Manager for the given root
+// directory.
+func FrameworkOpContext(ctx context.Context, op Op, pkgName, instPath, rootDir string) (*OpResult, error) {
+	return New(WithRootDir(rootDir)).FrameworkOpContext(ctx, op, pkgName, instPath)
 }
 
 // Install sets up the framework's policy from the given snap that's
 // installed in the given path.
 func Install(pkgName, instPath, rootDir string) error {
-	return frameworkOp(install, pkgName, instPath, rootDir)
+	return New(WithRootDir(rootDir)).Install(pkgName, instPath)
 }
 
-// Remove cleans up the framework's policy from the given snap that's
-// installed in the given path.
+// Upgrade brings the framework's policy installed in the system up to date
+// with the one of the given snap that's installed in the given path: changed
+// files are replaced, new ones added and stale ones removed, without ever
+// leaving the policy missing.
+func Upgrade(pkgName, instPath, rootDir string) error {
+	return New(WithRootDir(rootDir)).Upgrade(pkgName, instPath)
+}
+
+// Remove cleans up the framework's policy installed in the system, as
+// recorded in its manifest, see Manager.Remove.
 func Remove(pkgName, instPath, rootDir string) error {
-	return frameworkOp(remove, pkgName, instPath, rootDir)
+	return New(WithRootDir(rootDir)).Remove(pkgName, instPath)
+}
+
+// InstallTransactional is like Install, but either all of the policy is
+// installed or, on failure, the system is left as it was.
+func InstallTransactional(pkgName, instPath, rootDir string) error {
+	return New(WithRootDir(rootDir)).InstallTransactional(pkgName, instPath)
+}
+
+// UpgradeTransactional is like Upgrade, but either all of the policy is
+// upgraded or, on failure, the system is left as it was.
+func UpgradeTransactional(pkgName, instPath, rootDir string) error {
+	return New(WithRootDir(rootDir)).UpgradeTransactional(pkgName, instPath)
+}
+
+// RemoveTransactional is like Remove, but either all of the policy is
+// removed or, on failure, the system is left as it was.
+func RemoveTransactional(pkgName, instPath, rootDir string) error {
+	return New(WithRootDir(rootDir)).RemoveTransactional(pkgName, instPath)
+}
+
+// ListPolicies returns the policy files installed in the system for the
+// given package, keyed by backend and kind of policy, as in
+// "apparmor/templates".
+func ListPolicies(pkgName, rootDir string) (map[string][]string, error) {
+	return New(WithRootDir(rootDir)).ListPolicies(pkgName)
 }
 
 func aaUp(old, new, dir, pfx string) map[string]bool {
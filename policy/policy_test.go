@@ -27,7 +27,12 @@ import (
 	"testing"
 
 	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
+	"golang.org/x/net/context"
 	. "gopkg.in/check.v1"
 )
 
@@ -40,6 +45,10 @@ type policySuite struct {
 	appg string
 
 	secbase string
+
+	parserCalls    []string
+	parserErr      error
+	apparmorParser func(args ...string) error
 }
 
 var _ = Suite(&policySuite{})
@@ -55,19 +64,32 @@ func (s *policySuite) SetUpTest(c *C) {
 			for k := 0; k < 3; k++ {
 				name := filepath.Join(base, fmt.Sprintf("%s%d", j, k))
 				content := fmt.Sprintf("%s::%s%d", i, j, k)
+				if i == "seccomp" {
+					// checked before being installed
+					content = fmt.Sprintf("# %s\nread\n", content)
+				}
 				c.Assert(ioutil.WriteFile(name, []byte(content), 0644), IsNil)
 			}
 		}
 	}
 	s.secbase = SecBase
+
+	s.parserCalls = nil
+	s.parserErr = nil
+	s.apparmorParser = apparmorParser
+	apparmorParser = func(args ...string) error {
+		s.parserCalls = append(s.parserCalls, strings.Join(args, " "))
+		return s.parserErr
+	}
 }
 
 func (s *policySuite) TearDownTest(c *C) {
 	SecBase = s.secbase
+	apparmorParser = s.apparmorParser
 }
 
 func (s *policySuite) TestIterOpInstallRemove(c *C) {
-	err := iterOp(install, filepath.Join(s.appg, "*"), s.dest, "foo_")
+	_, err := iterOp(context.Background(), install, filepath.Join(s.appg, "*"), s.dest, "foo_", 1, nil, nil)
 	c.Check(err, IsNil)
 	g, err := filepath.Glob(filepath.Join(s.dest, "*"))
 	c.Check(err, IsNil)
@@ -82,7 +104,7 @@ func (s *policySuite) TestIterOpInstallRemove(c *C) {
 	c.Check(err, IsNil)
 	c.Check(string(bs), Equals, "apparmor::policygroups0")
 	// now, remove it
-	err = iterOp(remove, filepath.Join(s.appg, "*"), s.dest, "foo_")
+	_, err = iterOp(context.Background(), remove, filepath.Join(s.appg, "*"), s.dest, "foo_", 1, nil, nil)
 	c.Check(err, IsNil)
 	g, err = filepath.Glob(filepath.Join(s.dest, "*"))
 	c.Check(err, IsNil)
@@ -93,7 +115,7 @@ func (s *policySuite) TestIterOpInstallMkdir(c *C) {
 	dest := filepath.Join(s.dest, "bar")
 	_, err := os.Stat(dest)
 	c.Assert(os.IsNotExist(err), Equals, true)
-	err = iterOp(install, filepath.Join(s.appg, "*"), dest, "foo_")
+	_, err = iterOp(context.Background(), install, filepath.Join(s.appg, "*"), dest, "foo_", 1, nil, nil)
 	c.Check(err, IsNil)
 	bs, err := ioutil.ReadFile(filepath.Join(dest, "foo_policygroups0"))
 	c.Check(err, IsNil)
@@ -101,42 +123,260 @@ func (s *policySuite) TestIterOpInstallMkdir(c *C) {
 }
 
 func (s *policySuite) TestIterOpBadTargetdir(c *C) {
-	err := iterOp(42, "/*", "/root/if-you-see-this-directory-something-is-horribly-wrong", "__")
+	_, err := iterOp(context.Background(), 42, "/*", "/root/if-you-see-this-directory-something-is-horribly-wrong", "__", 1, nil, nil)
 	c.Check(err, ErrorMatches, `.*unable.*make.*directory.*`)
 }
 
 func (s *policySuite) TestIterOpBadFile(c *C) {
 	fn := filepath.Join(s.appg, "badbad")
 	c.Assert(os.Symlink(fn, fn), IsNil)
-	err := iterOp(42, filepath.Join(s.appg, "*"), s.dest, "foo_")
+	_, err := iterOp(context.Background(), 42, filepath.Join(s.appg, "*"), s.dest, "foo_", 1, nil, nil)
 	c.Check(err, ErrorMatches, ".*not a regular file.*")
+	c.Check(IsNotRegularFile(err), Equals, true)
+	c.Check(err.(*PathError).Path, Equals, fn)
+}
+
+func (s *policySuite) TestIterOpSymlinks(c *C) {
+	// links to other policy groups, or to templates, are followed
+	pg1 := filepath.Join(s.appg, "policygroups1")
+	c.Assert(os.Remove(pg1), IsNil)
+	c.Assert(os.Symlink("policygroups0", pg1), IsNil)
+	c.Assert(os.Symlink("../templates/templates2", filepath.Join(s.appg, "policygroups3")), IsNil)
+
+	// and so is the snap itself, as /snap/<name>/current is
+	current := filepath.Join(c.MkDir(), "current")
+	c.Assert(os.Symlink(s.orig, current), IsNil)
+	glob := filepath.Join(current, "meta", "framework-policy", "apparmor", "policygroups", "*")
+
+	res, err := iterOp(context.Background(), install, glob, s.dest, "foo_", 1, nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(res.Copied, Equals, 4)
+	for name, content := range map[string]string{
+		"foo_policygroups0": "apparmor::policygroups0",
+		"foo_policygroups1": "apparmor::policygroups0",
+		"foo_policygroups2": "apparmor::policygroups2",
+		"foo_policygroups3": "apparmor::templates2",
+	} {
+		target := filepath.Join(s.dest, name)
+		fi, err := os.Lstat(target)
+		c.Assert(err, IsNil)
+		c.Check(fi.Mode().IsRegular(), Equals, true, Commentf(name))
+		bs, err := ioutil.ReadFile(target)
+		c.Assert(err, IsNil)
+		c.Check(string(bs), Equals, content, Commentf(name))
+	}
+
+	res, err = iterOp(context.Background(), upgrade, glob, s.dest, "foo_", 1, nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(res.Skipped, Equals, 4)
+}
+
+func (s *policySuite) TestIterOpKeepsMode(c *C) {
+	oldMask := syscall.Umask(022)
+	defer syscall.Umask(oldMask)
+	c.Assert(os.Chmod(filepath.Join(s.appg, "policygroups0"), 0755), IsNil)
+	c.Assert(os.Chmod(filepath.Join(s.appg, "policygroups1"), 0600), IsNil)
+	c.Assert(os.Chmod(filepath.Join(s.appg, "policygroups2"), 0666), IsNil)
+	glob := filepath.Join(s.appg, "*")
+
+	_, err := iterOp(context.Background(), install, glob, s.dest, "foo_", 1, nil, nil)
+	c.Assert(err, IsNil)
+	for name, mode := range map[string]os.FileMode{
+		"foo_policygroups0": 0755,
+		"foo_policygroups1": 0600,
+		"foo_policygroups2": 0666,
+	} {
+		fi, err := os.Stat(filepath.Join(s.dest, name))
+		c.Assert(err, IsNil)
+		c.Check(fi.Mode(), Equals, mode, Commentf(name))
+	}
+
+	// a change of mode alone is not skipped
+	c.Assert(os.Chmod(filepath.Join(s.appg, "policygroups1"), 0640), IsNil)
+	res, err := iterOp(context.Background(), upgrade, glob, s.dest, "foo_", 1, nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(res.Copied, Equals, 1)
+	c.Check(res.Skipped, Equals, 2)
+	fi, err := os.Stat(filepath.Join(s.dest, "foo_policygroups1"))
+	c.Assert(err, IsNil)
+	c.Check(fi.Mode(), Equals, os.FileMode(0640))
+}
+
+func (s *policySuite) TestIterOpOwner(c *C) {
+	// the user running the tests can always give files to itself
+	owner := &fileOwner{uid: os.Getuid(), gid: os.Getgid()}
+	glob := filepath.Join(s.appg, "*")
+
+	res, err := iterOp(context.Background(), install, glob, s.dest, "foo_", 1, owner, nil)
+	c.Assert(err, IsNil)
+	c.Check(res.Copied, Equals, 3)
+	fi, err := os.Stat(filepath.Join(s.dest, "foo_policygroups0"))
+	c.Assert(err, IsNil)
+	c.Check(owner.ownedBy(fi), Equals, true)
+
+	res, err = iterOp(context.Background(), upgrade, glob, s.dest, "foo_", 1, owner, nil)
+	c.Assert(err, IsNil)
+	c.Check(res.Skipped, Equals, 3)
+
+	// files not owned as asked are copied over again
+	res, err = iterOp(context.Background(), upgrade, glob, s.dest, "foo_", 1, &fileOwner{uid: owner.uid + 1, gid: owner.gid}, nil)
+	if os.Getuid() == 0 {
+		c.Assert(err, IsNil)
+		c.Check(res.Copied, Equals, 3)
+	} else {
+		c.Check(err, ErrorMatches, "chown .*: operation not permitted")
+	}
+}
+
+// eventRecorder is an Observer keeping the events it is told about,
+// safe to use from the workers of iterOp.
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *eventRecorder) observe(ev *FileEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	event := fmt.Sprintf("%s %s", ev.Kind, filepath.Base(ev.Path))
+	if ev.Err != nil {
+		event += ": " + ev.Err.Error()
+	}
+	r.events = append(r.events, event)
+}
+
+// sorted returns the events so far, sorted, and forgets them.
+func (r *eventRecorder) sorted() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	events := r.events
+	r.events = nil
+	sort.Strings(events)
+	return events
+}
+
+func (s *policySuite) TestIterOpObserver(c *C) {
+	r := &eventRecorder{}
+	glob := filepath.Join(s.appg, "*")
+
+	_, err := iterOp(context.Background(), install, glob, s.dest, "foo_", 8, nil, r.observe)
+	c.Assert(err, IsNil)
+	c.Check(r.sorted(), DeepEquals, []string{
+		"copied foo_policygroups0",
+		"copied foo_policygroups1",
+		"copied foo_policygroups2",
+	})
+
+	c.Assert(ioutil.WriteFile(filepath.Join(s.appg, "policygroups0"), []byte("changed"), 0644), IsNil)
+	c.Assert(os.Remove(filepath.Join(s.appg, "policygroups2")), IsNil)
+	_, err = iterOp(context.Background(), upgrade, glob, s.dest, "foo_", 8, nil, r.observe)
+	c.Assert(err, IsNil)
+	c.Check(r.sorted(), DeepEquals, []string{
+		"copied foo_policygroups0",
+		"removed foo_policygroups2",
+		"skipped foo_policygroups1",
+	})
+
+	c.Assert(os.Remove(filepath.Join(s.dest, "foo_policygroups1")), IsNil)
+	_, err = iterOp(context.Background(), remove, glob, s.dest, "foo_", 8, nil, r.observe)
+	c.Check(err, NotNil)
+	c.Check(r.sorted(), DeepEquals, []string{
+		"failed foo_policygroups1: unable to remove " + filepath.Join(s.dest, "foo_policygroups1") + ": not found",
+		"removed foo_policygroups0",
+	})
+}
+
+func (s *policySuite) TestIterOpSymlinksRefused(c *C) {
+	outside := filepath.Join(c.MkDir(), "shadow")
+	c.Assert(ioutil.WriteFile(outside, []byte("evil"), 0644), IsNil)
+	link := filepath.Join(s.appg, "policygroups3")
+
+	for _, t := range []struct {
+		target string
+		err    string
+	}{
+		{outside, `unable to do Install for .*/policygroups3: symlink escapes the policy directory`},
+		{"../../../../meta/framework-policy/../../../../../../etc/passwd", `unable to do Install for .*/policygroups3: symlink escapes the policy directory`},
+		{"../templates", `unable to do Install for .*/policygroups3: not a regular file`},
+		{"missing", `unable to do Install for .*/policygroups3: not a regular file`},
+	} {
+		c.Assert(os.Symlink(t.target, link), IsNil)
+		_, err := iterOp(context.Background(), install, filepath.Join(s.appg, "*"), s.dest, "foo_", 1, nil, nil)
+		c.Check(err, ErrorMatches, t.err, Commentf(t.target))
+		c.Assert(os.Remove(link), IsNil)
+	}
+	_, err := os.Stat(filepath.Join(s.dest, "foo_policygroups3"))
+	c.Check(os.IsNotExist(err), Equals, true)
+
+	c.Assert(os.Symlink(outside, link), IsNil)
+	_, err = iterOp(context.Background(), install, filepath.Join(s.appg, "*"), s.dest, "foo_", 1, nil, nil)
+	c.Check(IsLinkEscapes(err), Equals, true)
+	c.Check(IsNotRegularFile(err), Equals, false)
+
+	// links are only followed within the policy directory of a snap
+	dir := c.MkDir()
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, "a"), nil, 0644), IsNil)
+	c.Assert(os.Symlink("a", filepath.Join(dir, "b")), IsNil)
+	_, err = iterOp(context.Background(), install, filepath.Join(dir, "*"), s.dest, "foo_", 1, nil, nil)
+	c.Check(IsLinkEscapes(err), Equals, true)
+}
+
+func (s *policySuite) TestIterOpTypedErrors(c *C) {
+	glob := filepath.Join(s.appg, "*")
+
+	// nothing to remove
+	_, err := iterOp(context.Background(), remove, glob, s.dest, "foo_", 1, nil, nil)
+	c.Check(err, ErrorMatches, "unable to remove .*/foo_policygroups0: not found")
+	c.Check(IsMissingTarget(err), Equals, true)
+	c.Check(IsTargetExists(err), Equals, false)
+
+	// a directory in the way of a target file
+	c.Assert(os.Mkdir(filepath.Join(s.dest, "foo_policygroups0"), 0755), IsNil)
+	_, err = iterOp(context.Background(), install, glob, s.dest, "foo_", 1, nil, nil)
+	c.Check(err, ErrorMatches, "unable to replace .*/foo_policygroups0: target exists")
+	c.Check(IsTargetExists(err), Equals, true)
+
+	// a file in the way of the target directory
+	targetDir := filepath.Join(s.dest, "file")
+	c.Assert(ioutil.WriteFile(targetDir, nil, 0644), IsNil)
+	_, err = iterOp(context.Background(), install, glob, targetDir, "foo_", 1, nil, nil)
+	c.Check(err, ErrorMatches, "unable to make directory .*/file: target exists")
+	c.Check(IsTargetExists(err), Equals, true)
+	c.Check(IsMissingTarget(err), Equals, false)
+	c.Check(IsNotRegularFile(err), Equals, false)
+
+	// other errors are kept as they are
+	c.Check(IsTargetExists(os.ErrExist), Equals, false)
+	err = &PathError{Op: "open", Path: "/foo", Err: os.ErrPermission}
+	c.Check(err, ErrorMatches, "unable to open /foo: permission denied")
+	c.Check(IsMissingTarget(err), Equals, false)
 }
 
 func (s *policySuite) TestIterOpBadOp(c *C) {
-	err := iterOp(42, filepath.Join(s.appg, "*"), s.dest, "foo_")
+	_, err := iterOp(context.Background(), 42, filepath.Join(s.appg, "*"), s.dest, "foo_", 1, nil, nil)
 	c.Check(err, ErrorMatches, ".*unknown operation.*")
 }
 
 func (s *policySuite) TestIterOpInstallBadFilemode(c *C) {
 	fn := filepath.Join(s.appg, "policygroups0")
 	c.Assert(os.Chmod(fn, 0), IsNil)
-	err := iterOp(install, filepath.Join(s.appg, "*"), s.dest, "foo_")
+	_, err := iterOp(context.Background(), install, filepath.Join(s.appg, "*"), s.dest, "foo_", 1, nil, nil)
 	c.Check(err, ErrorMatches, ".*unable to open.*")
 }
 
 func (s *policySuite) TestIterOpInstallBadTarget(c *C) {
 	c.Assert(os.Chmod(s.dest, 0), IsNil)
 	defer os.Chmod(s.dest, 0755)
-	err := iterOp(install, filepath.Join(s.appg, "*"), s.dest, "foo_")
+	_, err := iterOp(context.Background(), install, filepath.Join(s.appg, "*"), s.dest, "foo_", 1, nil, nil)
 	c.Check(err, ErrorMatches, ".*unable to create.*")
 }
 
 func (s *policySuite) TestIterOpRemoveBadDirmode(c *C) {
-	err := iterOp(install, filepath.Join(s.appg, "*"), s.dest, "foo_")
+	_, err := iterOp(context.Background(), install, filepath.Join(s.appg, "*"), s.dest, "foo_", 1, nil, nil)
 	c.Assert(err, IsNil)
 	c.Assert(os.Chmod(s.dest, 0), IsNil)
 	defer os.Chmod(s.dest, 0755)
-	err = iterOp(remove, filepath.Join(s.appg, "*"), s.dest, "foo_")
+	_, err = iterOp(context.Background(), remove, filepath.Join(s.appg, "*"), s.dest, "foo_", 1, nil, nil)
 	c.Assert(err, ErrorMatches, ".*unable to remove.*")
 }
 
@@ -154,15 +394,216 @@ func (s *policySuite) TestFrameworkRoundtrip(c *C) {
 	c.Check(g, HasLen, 0)
 }
 
+func (s *policySuite) TestIterOpUpgrade(c *C) {
+	_, err := iterOp(context.Background(), install, filepath.Join(s.appg, "*"), s.dest, "foo_", 1, nil, nil)
+	c.Assert(err, IsNil)
+	// a file of another package is left alone
+	other := filepath.Join(s.dest, "bar_policygroups2")
+	c.Assert(ioutil.WriteFile(other, []byte("bar"), 0644), IsNil)
+	unchanged, err := os.Stat(filepath.Join(s.dest, "foo_policygroups0"))
+	c.Assert(err, IsNil)
+
+	// the new policy changes one file, drops one and adds one
+	c.Assert(ioutil.WriteFile(filepath.Join(s.appg, "policygroups1"), []byte("changed"), 0644), IsNil)
+	c.Assert(os.Remove(filepath.Join(s.appg, "policygroups2")), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(s.appg, "policygroups3"), []byte("added"), 0644), IsNil)
+
+	_, err = iterOp(context.Background(), upgrade, filepath.Join(s.appg, "*"), s.dest, "foo_", 1, nil, nil)
+	c.Assert(err, IsNil)
+
+	g, err := filepath.Glob(filepath.Join(s.dest, "*"))
+	c.Assert(err, IsNil)
+	sort.Strings(g)
+	var names []string
+	for _, f := range g {
+		names = append(names, filepath.Base(f))
+	}
+	c.Check(names, DeepEquals, []string{"bar_policygroups2", "foo_policygroups0", "foo_policygroups1", "foo_policygroups3"})
+	for name, content := range map[string]string{
+		"foo_policygroups0": "apparmor::policygroups0",
+		"foo_policygroups1": "changed",
+		"foo_policygroups3": "added",
+		"bar_policygroups2": "bar",
+	} {
+		bs, err := ioutil.ReadFile(filepath.Join(s.dest, name))
+		c.Check(err, IsNil)
+		c.Check(string(bs), Equals, content)
+	}
+	// unchanged files are not rewritten
+	after, err := os.Stat(filepath.Join(s.dest, "foo_policygroups0"))
+	c.Assert(err, IsNil)
+	c.Check(os.SameFile(unchanged, after), Equals, true)
+}
+
+func (s *policySuite) TestIterOpUpgradeNothingInstalled(c *C) {
+	dest := filepath.Join(s.dest, "bar")
+	_, err := iterOp(context.Background(), upgrade, filepath.Join(s.appg, "*"), dest, "foo_", 1, nil, nil)
+	c.Assert(err, IsNil)
+	g, err := filepath.Glob(filepath.Join(dest, "foo_*"))
+	c.Assert(err, IsNil)
+	c.Check(g, HasLen, 3)
+}
+
+func (s *policySuite) TestIterOpParallel(c *C) {
+	for i := 3; i < 50; i++ {
+		name := filepath.Join(s.appg, fmt.Sprintf("policygroups%d", i))
+		c.Assert(ioutil.WriteFile(name, []byte(name), 0644), IsNil)
+	}
+	// one is there already
+	c.Assert(ioutil.WriteFile(filepath.Join(s.dest, "foo_policygroups0"), []byte("apparmor::policygroups0"), 0644), IsNil)
+
+	res, err := iterOp(context.Background(), install, filepath.Join(s.appg, "*"), s.dest, "foo_", 8, nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(res, DeepEquals, &OpResult{Copied: 49, Skipped: 1})
+	for i := 3; i < 50; i++ {
+		bs, err := ioutil.ReadFile(filepath.Join(s.dest, fmt.Sprintf("foo_policygroups%d", i)))
+		c.Check(err, IsNil)
+		c.Check(string(bs), Equals, filepath.Join(s.appg, fmt.Sprintf("policygroups%d", i)))
+	}
+
+	res, err = iterOp(context.Background(), remove, filepath.Join(s.appg, "*"), s.dest, "foo_", 8, nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(res, DeepEquals, &OpResult{Removed: 50})
+	g, err := filepath.Glob(filepath.Join(s.dest, "*"))
+	c.Assert(err, IsNil)
+	c.Check(g, HasLen, 0)
+}
+
+func (s *policySuite) TestIterOpParallelFirstError(c *C) {
+	for i := 3; i < 20; i++ {
+		name := filepath.Join(s.appg, fmt.Sprintf("policygroups%d", i))
+		c.Assert(ioutil.WriteFile(name, []byte(name), 0644), IsNil)
+	}
+	// directories in the way of the copies
+	for _, name := range []string{"foo_policygroups7", "foo_policygroups12", "foo_policygroups19"} {
+		c.Assert(os.MkdirAll(filepath.Join(s.dest, name), 0755), IsNil)
+	}
+
+	for i := 0; i < 10; i++ {
+		_, err := iterOp(context.Background(), install, filepath.Join(s.appg, "*"), s.dest, "foo_", 8, nil, nil)
+		c.Check(err, ErrorMatches, `.*foo_policygroups12.*`)
+	}
+}
+
+// countdownContext is cancelled once its Err was checked the given number
+// of times.
+type countdownContext struct {
+	context.Context
+	n int
+}
+
+func (ctx *countdownContext) Err() error {
+	if ctx.n <= 0 {
+		return context.Canceled
+	}
+	ctx.n--
+	return nil
+}
+
+func (s *policySuite) TestIterOpInstallReplacesAtomically(c *C) {
+	target := filepath.Join(s.dest, "foo_policygroups0")
+	c.Assert(ioutil.WriteFile(target, []byte("old"), 0644), IsNil)
+	before, err := os.Stat(target)
+	c.Assert(err, IsNil)
+	// left over by a crash
+	c.Assert(ioutil.WriteFile(filepath.Join(s.dest, ".foo_policygroups0.tmp"), []byte("ol"), 0644), IsNil)
+
+	_, err = iterOp(context.Background(), install, filepath.Join(s.appg, "policygroups0"), s.dest, "foo_", 1, nil, nil)
+	c.Assert(err, IsNil)
+	bs, err := ioutil.ReadFile(target)
+	c.Assert(err, IsNil)
+	c.Check(string(bs), Equals, "apparmor::policygroups0")
+	// the old file was renamed over rather than rewritten
+	after, err := os.Stat(target)
+	c.Assert(err, IsNil)
+	c.Check(os.SameFile(before, after), Equals, false)
+	g, err := filepath.Glob(filepath.Join(s.dest, ".*"))
+	c.Assert(err, IsNil)
+	c.Check(g, HasLen, 0)
+}
+
+func (s *policySuite) TestIterOpContextAbortsCopy(c *C) {
+	oldChunk := copyChunk
+	copyChunk = 4
+	defer func() { copyChunk = oldChunk }()
+	c.Assert(ioutil.WriteFile(filepath.Join(s.appg, "policygroups0"), []byte(strings.Repeat("x", 64)), 0644), IsNil)
+	glob := filepath.Join(s.appg, "policygroups0")
+
+	// nothing is left of a target being installed
+	ctx := &countdownContext{Context: context.Background(), n: 4}
+	_, err := iterOp(ctx, install, glob, s.dest, "foo_", 1, nil, nil)
+	c.Check(err, Equals, context.Canceled)
+	g, err := filepath.Glob(filepath.Join(s.dest, "*"))
+	c.Assert(err, IsNil)
+	c.Check(g, HasLen, 0)
+	g, err = filepath.Glob(filepath.Join(s.dest, ".*"))
+	c.Assert(err, IsNil)
+	c.Check(g, HasLen, 0)
+
+	// a replaced target is left as it was
+	target := filepath.Join(s.dest, "foo_policygroups0")
+	c.Assert(ioutil.WriteFile(target, []byte("old"), 0644), IsNil)
+	ctx = &countdownContext{Context: context.Background(), n: 4}
+	_, err = iterOp(ctx, upgrade, glob, s.dest, "foo_", 1, nil, nil)
+	c.Check(err, Equals, context.Canceled)
+	g, err = filepath.Glob(filepath.Join(s.dest, ".*"))
+	c.Assert(err, IsNil)
+	c.Check(g, HasLen, 0)
+	bs, err := ioutil.ReadFile(target)
+	c.Assert(err, IsNil)
+	c.Check(string(bs), Equals, "old")
+}
+
+func (s *policySuite) TestFrameworkOpContext(c *C) {
+	rootDir := c.MkDir()
+	SecBase = s.dest
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := FrameworkOpContext(ctx, OpInstall, "foo", s.orig, rootDir)
+	c.Check(err, Equals, context.Canceled)
+	g, err := filepath.Glob(filepath.Join(rootDir, SecBase, "*", "*", "foo_*"))
+	c.Assert(err, IsNil)
+	c.Check(g, HasLen, 0)
+
+	ctx, cancel = context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	_, err = FrameworkOpContext(ctx, OpInstall, "foo", s.orig, rootDir)
+	c.Check(err, Equals, context.DeadlineExceeded)
+
+	res, err := FrameworkOpContext(context.Background(), OpInstall, "foo", s.orig, rootDir)
+	c.Assert(err, IsNil)
+	c.Check(res, DeepEquals, &OpResult{Copied: 4 * 3})
+	res, err = FrameworkOpContext(context.Background(), OpRemove, "foo", s.orig, rootDir)
+	c.Assert(err, IsNil)
+	c.Check(res, DeepEquals, &OpResult{Removed: 4 * 3})
+}
+
+func (s *policySuite) TestFrameworkUpgrade(c *C) {
+	rootDir := c.MkDir()
+	SecBase = s.dest
+	c.Assert(Install("foo", s.orig, rootDir), IsNil)
+	c.Assert(os.Remove(filepath.Join(s.orig, "meta", "framework-policy", "seccomp", "templates", "templates0")), IsNil)
+	c.Check(Upgrade("foo", s.orig, rootDir), IsNil)
+	g, err := filepath.Glob(filepath.Join(rootDir, SecBase, "*", "*", "foo_*"))
+	c.Check(err, IsNil)
+	c.Check(g, HasLen, 4*3-1)
+	_, err = os.Stat(filepath.Join(rootDir, SecBase, "seccomp", "templates", "foo_templates0"))
+	c.Check(os.IsNotExist(err), Equals, true)
+}
+
 func (s *policySuite) TestFrameworkError(c *C) {
 	// check we get errors from the iterOp, is all
 	SecBase = s.dest
-	c.Check(frameworkOp(42, "foo", s.orig, ""), ErrorMatches, ".*unknown operation.*")
+	_, err := New().frameworkOp(42, "foo", s.orig)
+	c.Check(err, ErrorMatches, ".*unknown operation.*")
 }
 
 func (s *policySuite) TestOpString(c *C) {
 	c.Check(fmt.Sprintf("%s", install), Equals, "Install")
 	c.Check(fmt.Sprintf("%s", remove), Equals, "Remove")
+	c.Check(fmt.Sprintf("%s", upgrade), Equals, "Upgrade")
+	c.Check(fmt.Sprintf("%s", verify), Equals, "Verify")
 }
 
 func (s *policySuite) TestDelta(c *C) {
@@ -184,3 +625,43 @@ func (s *policySuite) TestDelta(c *C) {
 	// templates are all different files => no updates
 	c.Check(ts, HasLen, 0)
 }
+
+func (s *policySuite) TestOpResultSkipsUnchanged(c *C) {
+	rootDir := c.MkDir()
+	SecBase = "/sec"
+	res, err := FrameworkOpContext(context.Background(), OpInstall, "foo", s.orig, rootDir)
+	c.Assert(err, IsNil)
+	c.Check(res, DeepEquals, &OpResult{Copied: 4 * 3})
+
+	target := filepath.Join(rootDir, "sec", "apparmor", "policygroups", "foo_policygroups0")
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	c.Assert(os.Chtimes(target, old, old), IsNil)
+
+	// identical files are left alone
+	res, err = FrameworkOpContext(context.Background(), OpInstall, "foo", s.orig, rootDir)
+	c.Assert(err, IsNil)
+	c.Check(res, DeepEquals, &OpResult{Skipped: 4 * 3})
+	st, err := os.Stat(target)
+	c.Assert(err, IsNil)
+	c.Check(st.ModTime().Equal(old), Equals, true)
+
+	// same size, other content
+	c.Assert(ioutil.WriteFile(filepath.Join(s.appg, "policygroups1"), []byte("apparmor::policygroupsX"), 0644), IsNil)
+	res, err = FrameworkOpContext(context.Background(), OpUpgrade, "foo", s.orig, rootDir)
+	c.Assert(err, IsNil)
+	c.Check(res, DeepEquals, &OpResult{Copied: 1, Skipped: 4*3 - 1})
+
+	c.Assert(os.Remove(filepath.Join(s.appg, "policygroups2")), IsNil)
+	res, err = New(WithRootDir(rootDir)).FrameworkTransactionContext(context.Background(), OpUpgrade, "foo", s.orig)
+	c.Assert(err, IsNil)
+	c.Check(res, DeepEquals, &OpResult{Skipped: 4*3 - 1, Removed: 1})
+
+	c.Assert(ioutil.WriteFile(target, []byte("tampered"), 0644), IsNil)
+	res, err = New(WithRootDir(rootDir)).FrameworkTransactionContext(context.Background(), OpInstall, "foo", s.orig)
+	c.Assert(err, IsNil)
+	c.Check(res, DeepEquals, &OpResult{Copied: 1, Skipped: 4*3 - 2})
+
+	res, err = FrameworkOpContext(context.Background(), OpRemove, "foo", s.orig, rootDir)
+	c.Assert(err, IsNil)
+	c.Check(res, DeepEquals, &OpResult{Removed: 4*3 - 1})
+}
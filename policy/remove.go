@@ -0,0 +1,97 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package policy
+
+import (
+	"os"
+	"path/filepath"
+
+	"golang.org/x/net/context"
+)
+
+// policyTargets returns the target files of the policy of the snap of
+// the given package installed in the given path.
+func (m *Manager) policyTargets(pkgName, instPath string) ([]string, error) {
+	var targets []string
+	err := m.forEachPolicy(pkgName, instPath, func(glob, targetDir, prefix string) error {
+		files, err := filepath.Glob(glob)
+		if err != nil {
+			return &PathError{Op: "glob", Path: glob, Err: err}
+		}
+		for _, file := range files {
+			targets = append(targets, filepath.Join(targetDir, prefix+filepath.Base(file)))
+		}
+		return nil
+	})
+	return targets, err
+}
+
+// removeLenient removes the policy of the package as Remove does, but
+// carries on past the target files it cannot remove: those gone already
+// are counted as missing, and the other failures are returned together
+// in a RemoveError, keeping the manifest for the removal to be tried
+// again.
+func (m *Manager) removeLenient(ctx context.Context, pkgName, instPath string) (*OpResult, error) {
+	res := &OpResult{}
+	var errs []error
+	err := m.withLoadedPolicy(pkgName, func() error {
+		files, recorded, err := m.manifestFiles(pkgName)
+		if err != nil {
+			return err
+		}
+		if !recorded {
+			if files, err = m.policyTargets(pkgName, instPath); err != nil {
+				return err
+			}
+		}
+
+		var dirs []string
+		for _, file := range files {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			err := os.Remove(file)
+			switch {
+			case err == nil:
+				m.observe.notify(FileRemoved, file, "", nil)
+				res.Removed++
+				dirs = append(dirs, filepath.Dir(file))
+			case os.IsNotExist(err):
+				m.observe.notify(FileMissing, file, "", nil)
+				res.Missing++
+			default:
+				err = targetError("remove", file, err)
+				m.observe.notify(FileFailed, file, "", err)
+				errs = append(errs, err)
+			}
+		}
+		return m.syncDirs(dirs)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(errs) > 0 {
+		return nil, &RemoveError{Package: pkgName, Result: res, Errs: errs}
+	}
+	if err := m.updateManifest(remove, pkgName, nil); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,104 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package policy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/net/context"
+	. "gopkg.in/check.v1"
+)
+
+func (s *policySuite) TestRemoveLenientMissing(c *C) {
+	for _, manifest := range []bool{true, false} {
+		rootDir := c.MkDir()
+		err := New(WithRootDir(rootDir), WithSecBase("/sec")).Install("foo", s.orig)
+		c.Assert(err, IsNil)
+		// left behind by a partial install
+		c.Assert(os.Remove(filepath.Join(rootDir, "sec", "seccomp", "templates", "foo_templates1")), IsNil)
+		if !manifest {
+			// installed before manifests were kept
+			c.Assert(os.Remove(filepath.Join(rootDir, "sec", "manifests", "foo.json")), IsNil)
+		}
+
+		var missing []string
+		lenient := New(WithRootDir(rootDir), WithSecBase("/sec"), WithLenientRemove(), WithObserver(func(ev *FileEvent) {
+			if ev.Kind == FileMissing {
+				missing = append(missing, ev.Path)
+			}
+		}))
+		res, err := lenient.FrameworkOpContext(context.Background(), OpRemove, "foo", s.orig)
+		c.Assert(err, IsNil)
+		c.Check(res.Missing, Equals, 1)
+		c.Check(missing, DeepEquals, []string{filepath.Join(rootDir, "sec", "seccomp", "templates", "foo_templates1")})
+		c.Check(res.Removed, Equals, 4*3-1)
+		c.Check(policyFiles(c, rootDir), HasLen, 0)
+
+		// removing again is harmless
+		res, err = lenient.FrameworkOpContext(context.Background(), OpRemove, "foo", s.orig)
+		c.Assert(err, IsNil)
+		c.Check(res.Removed, Equals, 0)
+		c.Check(res.Missing, Equals, 4*3)
+	}
+}
+
+func (s *policySuite) TestRemoveLenientFails(c *C) {
+	rootDir := c.MkDir()
+	m := New(WithRootDir(rootDir), WithSecBase("/sec"), WithLenientRemove())
+	err := m.Install("foo", s.orig)
+	c.Assert(err, IsNil)
+	// targets that cannot be removed, as non-empty directories
+	var blocked []string
+	for _, name := range []string{"foo_policygroups0", "foo_policygroups2"} {
+		target := filepath.Join(rootDir, "sec", "apparmor", "policygroups", name)
+		c.Assert(os.Remove(target), IsNil)
+		c.Assert(os.MkdirAll(filepath.Join(target, "sub"), 0755), IsNil)
+		blocked = append(blocked, target)
+	}
+
+	err = m.Remove("foo", s.orig)
+	c.Assert(err, FitsTypeOf, &RemoveError{})
+	rerr := err.(*RemoveError)
+	c.Check(rerr.Package, Equals, "foo")
+	c.Check(rerr.Result.Removed, Equals, 4*3-2)
+	c.Assert(rerr.Errs, HasLen, 2)
+	c.Check(rerr.Errs[0].(*PathError).Path, Equals, blocked[0])
+	c.Check(rerr.Errs[1].(*PathError).Path, Equals, blocked[1])
+	c.Check(err, ErrorMatches, `policy of "foo" was only partly removed:
+- unable to remove .*/foo_policygroups0: .*
+- unable to remove .*/foo_policygroups2: .*`)
+
+	// the rest is gone, and the manifest is kept to try again
+	c.Check(policyFiles(c, rootDir), HasLen, 1)
+	_, err = os.Stat(filepath.Join(rootDir, "sec", "manifests", "foo.json"))
+	c.Check(err, IsNil)
+
+	for _, target := range blocked {
+		c.Assert(os.RemoveAll(target), IsNil)
+		c.Assert(ioutil.WriteFile(target, nil, 0644), IsNil)
+	}
+	res, err := m.FrameworkOpContext(context.Background(), OpRemove, "foo", s.orig)
+	c.Assert(err, IsNil)
+	c.Check(res.Removed, Equals, 2)
+	c.Check(res.Missing, Equals, 4*3-2)
+	c.Check(policyFiles(c, rootDir), HasLen, 0)
+}
@@ -0,0 +1,125 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package policy
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// seccompSets are the kinds of policy of the seccomp backend, both
+// checked for unknown syscalls and malformed lines before being
+// installed.
+var seccompSets = []policySet{
+	{kind: "policygroups", glob: "policygroups/*", subdir: "policygroups", validate: validateSeccompPolicy},
+	{kind: "templates", glob: "templates/*", subdir: "templates", validate: validateSeccompPolicy},
+}
+
+// seccompBackend returns the backend of the seccomp policy groups and
+// templates, which are picked up when the snaps are next started.
+func seccompBackend() *policyBackend {
+	return &policyBackend{
+		name: "seccomp",
+		sets: seccompSets,
+	}
+}
+
+// SyntaxError is an error found on a line of a policy file.
+type SyntaxError struct {
+	File string
+	Line int
+	Msg  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Msg)
+}
+
+// SyntaxErrors are all the errors found in the policy files of a
+// framework.
+type SyntaxErrors []*SyntaxError
+
+func (e SyntaxErrors) Error() string {
+	if len(e) == 1 {
+		return fmt.Sprintf("invalid policy: %v", e[0])
+	}
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = "- " + err.Error()
+	}
+	return fmt.Sprintf("invalid policy:\n%s", strings.Join(msgs, "\n"))
+}
+
+// validateSeccompPolicy checks that each line of the given seccomp
+// policy file is a comment, a known syscall to allow, @deny and a known
+// syscall to deny, or @unrestricted, returning the SyntaxErrors of the
+// lines that are not.
+func validateSeccompPolicy(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return &PathError{Op: "open", Path: path, Err: err}
+	}
+	defer f.Close()
+
+	var errs SyntaxErrors
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		if msg := checkSeccompLine(scanner.Text()); msg != "" {
+			errs = append(errs, &SyntaxError{File: path, Line: n, Msg: msg})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return &PathError{Op: "read", Path: path, Err: err}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// checkSeccompLine returns what is wrong with the given line of a
+// seccomp policy file, if anything.
+func checkSeccompLine(line string) string {
+	fields := strings.Fields(line)
+	if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+		return ""
+	}
+	switch fields[0] {
+	case "@unrestricted":
+		if len(fields) > 1 {
+			return fmt.Sprintf("unexpected %q after %q", strings.Join(fields[1:], " "), fields[0])
+		}
+		return ""
+	case "@deny":
+		if len(fields) == 1 {
+			return "missing syscall to deny"
+		}
+		fields = fields[1:]
+	}
+	if len(fields) > 1 {
+		return fmt.Sprintf("unexpected %q after %q", strings.Join(fields[1:], " "), fields[0])
+	}
+	if !knownSyscalls[fields[0]] {
+		return fmt.Sprintf("unknown syscall %q", fields[0])
+	}
+	return ""
+}
@@ -0,0 +1,86 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package policy
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/osutil"
+)
+
+func (s *policySuite) TestSeccompPolicyValid(c *C) {
+	path := filepath.Join(c.MkDir(), "template")
+	c.Assert(ioutil.WriteFile(path, []byte(`# Description: a template
+
+open
+  read
+@deny ptrace
+@unrestricted
+`), 0644), IsNil)
+	c.Check(validateSeccompPolicy(path), IsNil)
+}
+
+func (s *policySuite) TestSeccompPolicyErrors(c *C) {
+	path := filepath.Join(c.MkDir(), "template")
+	c.Assert(ioutil.WriteFile(path, []byte(`open
+opne
+@deny
+@deny ptrace now
+@unrestricted read
+read write
+`), 0644), IsNil)
+
+	err := validateSeccompPolicy(path)
+	c.Assert(err, FitsTypeOf, SyntaxErrors(nil))
+	c.Check(err, DeepEquals, SyntaxErrors{
+		{File: path, Line: 2, Msg: `unknown syscall "opne"`},
+		{File: path, Line: 3, Msg: `missing syscall to deny`},
+		{File: path, Line: 4, Msg: `unexpected "now" after "ptrace"`},
+		{File: path, Line: 5, Msg: `unexpected "read" after "@unrestricted"`},
+		{File: path, Line: 6, Msg: `unexpected "write" after "read"`},
+	})
+}
+
+func (s *policySuite) TestSeccompInvalidPolicyNotInstalled(c *C) {
+	base := filepath.Join(s.orig, "meta", "framework-policy", "seccomp")
+	group := filepath.Join(base, "policygroups", "policygroups1")
+	template := filepath.Join(base, "templates", "templates2")
+	c.Assert(ioutil.WriteFile(group, []byte("read\nopne\n"), 0644), IsNil)
+	c.Assert(ioutil.WriteFile(template, []byte("foo bar\n"), 0644), IsNil)
+
+	secBase := c.MkDir()
+	err := New(WithSecBase(secBase)).InstallTransactional("foo", s.orig)
+	c.Check(err, DeepEquals, SyntaxErrors{
+		{File: group, Line: 2, Msg: `unknown syscall "opne"`},
+		{File: template, Line: 1, Msg: `unexpected "bar" after "foo"`},
+	})
+	c.Check(err, ErrorMatches, `invalid policy:
+- .*/policygroups1:2: unknown syscall "opne"
+- .*/templates2:1: unexpected "bar" after "foo"`)
+	c.Check(osutil.FileExists(filepath.Join(secBase, "seccomp")), Equals, false)
+}
+
+func (s *policySuite) TestSyntaxErrorsSingle(c *C) {
+	err := SyntaxErrors{{File: "/a/b", Line: 3, Msg: "oops"}}
+	c.Check(err, ErrorMatches, `invalid policy: /a/b:3: oops`)
+}
@@ -0,0 +1,103 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package policy
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/snapcore/snapd/osutil"
+)
+
+// selinuxEnabled returns whether SELinux is enabled on the system, with
+// the selinuxfs mounted, mocked in the tests.
+var selinuxEnabled = func() bool {
+	return osutil.FileExists("/sys/fs/selinux/enforce")
+}
+
+// semodule runs semodule with the given arguments, mocked in the tests.
+var semodule = func(args ...string) error {
+	if output, err := exec.Command("semodule", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("semodule %s failed: %v (%s)", strings.Join(args, " "), err, output)
+	}
+	return nil
+}
+
+// selinuxBackend returns the backend of the SELinux policy modules the
+// frameworks ship directly in meta/framework-policy/selinux, as .pp or
+// .cil files. They go to the modules directory of the backend, and are
+// loaded into the kernel policy with semodule. It is only registered on
+// systems with SELinux enabled.
+func selinuxBackend() *policyBackend {
+	return &policyBackend{
+		name: "selinux",
+		sets: []policySet{
+			{kind: "pp-modules", glob: "*.pp", subdir: "modules"},
+			{kind: "cil-modules", glob: "*.cil", subdir: "modules"},
+		},
+		postInstall: loadSELinuxModules,
+	}
+}
+
+// loadSELinuxModules unloads the removed modules, and loads the
+// installed ones that were added or changed, leaving the others be.
+func loadSELinuxModules(changes *PolicyChanges) error {
+	for _, file := range changes.Removed {
+		if err := unloadSELinuxModule(file); err != nil {
+			return err
+		}
+	}
+	installed := make(map[string]bool, len(changes.Installed))
+	for _, file := range changes.Installed {
+		installed[file] = true
+	}
+	for _, file := range changes.Changed {
+		if !installed[file] {
+			continue
+		}
+		if err := loadSELinuxModule(file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// selinuxModuleName is the name of the module installed from the given
+// file, which semodule takes from the file name.
+func selinuxModuleName(path string) string {
+	name := filepath.Base(path)
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+func loadSELinuxModule(path string) error {
+	if err := semodule("-i", path); err != nil {
+		return fmt.Errorf("unable to load SELinux module %v: %v", selinuxModuleName(path), err)
+	}
+	return nil
+}
+
+func unloadSELinuxModule(path string) error {
+	if err := semodule("-r", selinuxModuleName(path)); err != nil {
+		return fmt.Errorf("unable to unload SELinux module %v: %v", selinuxModuleName(path), err)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package policy
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/osutil"
+)
+
+// mockSELinuxPolicy adds SELinux modules to the policy of the
+// framework, registering the selinux backend until restore is called.
+func (s *policySuite) mockSELinuxPolicy(c *C) (restore func()) {
+	restore = s.mockRegistry()
+	RegisterBackend(selinuxBackend())
+	base := filepath.Join(s.orig, "meta", "framework-policy", "selinux")
+	c.Assert(os.MkdirAll(base, 0755), IsNil)
+	for _, name := range []string{"mymod.pp", "other.cil", "README"} {
+		c.Assert(ioutil.WriteFile(filepath.Join(base, name), []byte("selinux::"+name), 0644), IsNil)
+	}
+	return restore
+}
+
+func (s *policySuite) mockSemodule(err error) (calls *[]string, restore func()) {
+	old := semodule
+	calls = &[]string{}
+	semodule = func(args ...string) error {
+		*calls = append(*calls, strings.Join(args, " "))
+		return err
+	}
+	return calls, func() { semodule = old }
+}
+
+func (s *policySuite) TestSELinuxModules(c *C) {
+	defer s.mockSELinuxPolicy(c)()
+	calls, restore := s.mockSemodule(nil)
+	defer restore()
+
+	secBase := c.MkDir()
+	modules := filepath.Join(secBase, "selinux", "modules")
+	m := New(WithSecBase(secBase))
+	c.Assert(m.Install("foo", s.orig), IsNil)
+	c.Check(osutil.FileExists(filepath.Join(modules, "foo_mymod.pp")), Equals, true)
+	c.Check(osutil.FileExists(filepath.Join(modules, "foo_other.cil")), Equals, true)
+	c.Check(osutil.FileExists(filepath.Join(modules, "foo_README")), Equals, false)
+	c.Check(*calls, DeepEquals, []string{
+		"-i " + filepath.Join(modules, "foo_mymod.pp"),
+		"-i " + filepath.Join(modules, "foo_other.cil"),
+	})
+
+	// a module dropped by the framework is unloaded on upgrade, and
+	// the ones left as they were are not loaded again
+	c.Assert(os.Remove(filepath.Join(s.orig, "meta", "framework-policy", "selinux", "other.cil")), IsNil)
+	*calls = nil
+	c.Assert(m.UpgradeTransactional("foo", s.orig), IsNil)
+	c.Check(osutil.FileExists(filepath.Join(modules, "foo_mymod.pp")), Equals, true)
+	c.Check(osutil.FileExists(filepath.Join(modules, "foo_other.cil")), Equals, false)
+	c.Check(*calls, DeepEquals, []string{"-r foo_other"})
+
+	// only a changed module is loaded again
+	c.Assert(ioutil.WriteFile(filepath.Join(s.orig, "meta", "framework-policy", "selinux", "mymod.pp"), []byte("new"), 0644), IsNil)
+	*calls = nil
+	c.Assert(m.Upgrade("foo", s.orig), IsNil)
+	c.Check(*calls, DeepEquals, []string{"-i " + filepath.Join(modules, "foo_mymod.pp")})
+
+	*calls = nil
+	c.Assert(m.Remove("foo", s.orig), IsNil)
+	c.Check(osutil.FileExists(filepath.Join(modules, "foo_mymod.pp")), Equals, false)
+	c.Check(*calls, DeepEquals, []string{"-r foo_mymod"})
+}
+
+func (s *policySuite) TestSELinuxNotLoadedUnderRoot(c *C) {
+	defer s.mockSELinuxPolicy(c)()
+	calls, restore := s.mockSemodule(nil)
+	defer restore()
+
+	rootDir := c.MkDir()
+	err := New(WithRootDir(rootDir), WithSecBase("/sec")).Install("foo", s.orig)
+	c.Assert(err, IsNil)
+	c.Check(policyFiles(c, rootDir)["sec/selinux/modules/foo_mymod.pp"], Equals, "selinux::mymod.pp")
+	c.Check(*calls, HasLen, 0)
+}
+
+func (s *policySuite) TestSELinuxLoadFails(c *C) {
+	defer s.mockSELinuxPolicy(c)()
+	_, restore := s.mockSemodule(errors.New("boom"))
+	defer restore()
+
+	err := New(WithSecBase(c.MkDir())).Install("foo", s.orig)
+	c.Check(err, ErrorMatches, "unable to load SELinux module foo_mymod: boom")
+}
+
+func (s *policySuite) TestSELinuxBackendOnlyWhenEnabled(c *C) {
+	old := selinuxEnabled
+	defer func() { selinuxEnabled = old }()
+
+	for _, enabled := range []bool{false, true} {
+		selinuxEnabled = func() bool { return enabled }
+		var names []string
+		for _, b := range builtinBackends() {
+			names = append(names, b.Name())
+		}
+		if enabled {
+			c.Check(names, DeepEquals, []string{"apparmor", "seccomp", "selinux"})
+		} else {
+			c.Check(names, DeepEquals, []string{"apparmor", "seccomp"})
+		}
+	}
+}
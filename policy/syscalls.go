@@ -0,0 +1,149 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package policy
+
+// knownSyscalls are the names of the syscalls of the architectures
+// supported, which the seccomp policy may refer to.
+var knownSyscalls = map[string]bool{
+	"_exit": true, "_llseek": true, "_newselect": true, "_sysctl": true,
+	"accept": true, "accept4": true, "access": true, "acct": true,
+	"add_key": true, "adjtimex": true, "afs_syscall": true, "alarm": true,
+	"arch_prctl": true, "arm_fadvise64_64": true, "arm_sync_file_range": true,
+	"bdflush": true, "bind": true, "bpf": true, "break": true,
+	"breakpoint": true, "brk": true, "cacheflush": true, "capget": true,
+	"capset": true, "chdir": true, "chmod": true, "chown": true,
+	"chown32": true, "chroot": true, "clock_adjtime": true,
+	"clock_getres": true, "clock_gettime": true, "clock_nanosleep": true,
+	"clock_settime": true, "clone": true, "close": true, "connect": true,
+	"copy_file_range": true, "creat": true, "create_module": true,
+	"delete_module": true, "dup": true, "dup2": true, "dup3": true,
+	"epoll_create": true, "epoll_create1": true, "epoll_ctl": true,
+	"epoll_ctl_old": true, "epoll_pwait": true, "epoll_wait": true,
+	"epoll_wait_old": true, "eventfd": true, "eventfd2": true, "execve": true,
+	"execveat": true, "exit": true, "exit_group": true, "faccessat": true,
+	"fadvise64": true, "fadvise64_64": true, "fallocate": true,
+	"fanotify_init": true, "fanotify_mark": true, "fchdir": true,
+	"fchmod": true, "fchmodat": true, "fchown": true, "fchown32": true,
+	"fchownat": true, "fcntl": true, "fcntl64": true, "fdatasync": true,
+	"fgetxattr": true, "finit_module": true, "flistxattr": true,
+	"flock": true, "fork": true, "fremovexattr": true, "fsetxattr": true,
+	"fstat": true, "fstat64": true, "fstatat64": true, "fstatfs": true,
+	"fstatfs64": true, "fstatvfs": true, "fsync": true, "ftime": true,
+	"ftruncate": true, "ftruncate64": true, "futex": true, "futimesat": true,
+	"get_kernel_syms": true, "get_mempolicy": true, "get_robust_list": true,
+	"get_thread_area": true, "getcpu": true, "getcwd": true, "getdents": true,
+	"getdents64": true, "getegid": true, "getegid32": true, "geteuid": true,
+	"geteuid32": true, "getgid": true, "getgid32": true, "getgroups": true,
+	"getgroups32": true, "getitimer": true, "getpeername": true,
+	"getpgid": true, "getpgrp": true, "getpid": true, "getpmsg": true,
+	"getppid": true, "getpriority": true, "getrandom": true,
+	"getresgid": true, "getresgid32": true, "getresuid": true,
+	"getresuid32": true, "getrlimit": true, "getrusage": true, "getsid": true,
+	"getsockname": true, "getsockopt": true, "gettid": true,
+	"gettimeofday": true, "getuid": true, "getuid32": true, "getxattr": true,
+	"gtty": true, "idle": true, "init_module": true,
+	"inotify_add_watch": true, "inotify_init": true, "inotify_init1": true,
+	"inotify_rm_watch": true, "io_cancel": true, "io_destroy": true,
+	"io_getevents": true, "io_setup": true, "io_submit": true, "ioctl": true,
+	"ioperm": true, "iopl": true, "ioprio_get": true, "ioprio_set": true,
+	"ipc": true, "kcmp": true, "kexec_file_load": true, "kexec_load": true,
+	"keyctl": true, "kill": true, "lchown": true, "lchown32": true,
+	"lgetxattr": true, "link": true, "linkat": true, "listen": true,
+	"listxattr": true, "llistxattr": true, "llseek": true, "lock": true,
+	"lookup_dcookie": true, "lremovexattr": true, "lseek": true,
+	"lsetxattr": true, "lstat": true, "lstat64": true, "madvise": true,
+	"mbind": true, "membarrier": true, "memfd_create": true,
+	"migrate_pages": true, "mincore": true, "mkdir": true, "mkdirat": true,
+	"mknod": true, "mknodat": true, "mlock": true, "mlock2": true,
+	"mlockall": true, "mmap": true, "mmap2": true, "modify_ldt": true,
+	"mount": true, "move_pages": true, "mprotect": true, "mpx": true,
+	"mq_getsetattr": true, "mq_notify": true, "mq_open": true,
+	"mq_timedreceive": true, "mq_timedsend": true, "mq_unlink": true,
+	"mremap": true, "msgctl": true, "msgget": true, "msgrcv": true,
+	"msgsnd": true, "msync": true, "multiplexer": true, "munlock": true,
+	"munlockall": true, "munmap": true, "name_to_handle_at": true,
+	"nanosleep": true, "newfstatat": true, "nfsservctl": true, "nice": true,
+	"oldfstat": true, "oldlstat": true, "oldolduname": true, "oldstat": true,
+	"olduname": true, "oldwait4": true, "open": true,
+	"open_by_handle_at": true, "openat": true, "pause": true,
+	"pciconfig_iobase": true, "pciconfig_read": true, "pciconfig_write": true,
+	"perf_event_open": true, "personality": true, "pipe": true, "pipe2": true,
+	"pivot_root": true, "pkey_alloc": true, "pkey_free": true,
+	"pkey_mprotect": true, "poll": true, "ppoll": true, "prctl": true,
+	"pread": true, "pread64": true, "preadv": true, "preadv2": true,
+	"prlimit64": true, "process_vm_readv": true, "process_vm_writev": true,
+	"prof": true, "profil": true, "pselect": true, "pselect6": true,
+	"ptrace": true, "putpmsg": true, "pwrite": true, "pwrite64": true,
+	"pwritev": true, "pwritev2": true, "query_module": true, "quotactl": true,
+	"read": true, "readahead": true, "readdir": true, "readlink": true,
+	"readlinkat": true, "readv": true, "reboot": true, "recv": true,
+	"recvfrom": true, "recvmmsg": true, "recvmsg": true,
+	"remap_file_pages": true, "removexattr": true, "rename": true,
+	"renameat": true, "renameat2": true, "request_key": true,
+	"restart_syscall": true, "rmdir": true, "rt_sigaction": true,
+	"rt_sigpending": true, "rt_sigprocmask": true, "rt_sigqueueinfo": true,
+	"rt_sigreturn": true, "rt_sigsuspend": true, "rt_sigtimedwait": true,
+	"rt_tgsigqueueinfo": true, "rtas": true, "s390_pci_mmio_read": true,
+	"s390_pci_mmio_write": true, "s390_runtime_instr": true,
+	"sched_get_priority_max": true, "sched_get_priority_min": true,
+	"sched_getaffinity": true, "sched_getattr": true, "sched_getparam": true,
+	"sched_getscheduler": true, "sched_rr_get_interval": true,
+	"sched_setaffinity": true, "sched_setattr": true, "sched_setparam": true,
+	"sched_setscheduler": true, "sched_yield": true, "seccomp": true,
+	"security": true, "select": true, "semctl": true, "semget": true,
+	"semop": true, "semtimedop": true, "send": true, "sendfile": true,
+	"sendfile64": true, "sendmmsg": true, "sendmsg": true, "sendto": true,
+	"set_mempolicy": true, "set_robust_list": true, "set_thread_area": true,
+	"set_tid_address": true, "set_tls": true, "setdomainname": true,
+	"setfsgid": true, "setfsgid32": true, "setfsuid": true,
+	"setfsuid32": true, "setgid": true, "setgid32": true, "setgroups": true,
+	"setgroups32": true, "sethostname": true, "setitimer": true,
+	"setns": true, "setpgid": true, "setpgrp": true, "setpriority": true,
+	"setregid": true, "setregid32": true, "setresgid": true,
+	"setresgid32": true, "setresuid": true, "setresuid32": true,
+	"setreuid": true, "setreuid32": true, "setrlimit": true, "setsid": true,
+	"setsockopt": true, "settimeofday": true, "setuid": true,
+	"setuid32": true, "setxattr": true, "sgetmask": true, "shmat": true,
+	"shmctl": true, "shmdt": true, "shmget": true, "shutdown": true,
+	"sigaction": true, "sigaltstack": true, "signal": true, "signalfd": true,
+	"signalfd4": true, "sigpending": true, "sigprocmask": true,
+	"sigreturn": true, "sigsuspend": true, "sigtimedwait": true,
+	"sigwaitinfo": true, "socket": true, "socketcall": true,
+	"socketpair": true, "splice": true, "spu_create": true, "spu_run": true,
+	"ssetmask": true, "stat": true, "stat64": true, "statfs": true,
+	"statfs64": true, "statvfs": true, "statx": true, "stime": true,
+	"stty": true, "subpage_prot": true, "swapcontext": true, "swapoff": true,
+	"swapon": true, "switch_endian": true, "symlink": true, "symlinkat": true,
+	"sync": true, "sync_file_range": true, "sync_file_range2": true,
+	"syncfs": true, "sys_debug_setcontext": true, "syscall": true,
+	"sysfs": true, "sysinfo": true, "syslog": true, "sysmips": true,
+	"tee": true, "tgkill": true, "time": true, "timer_create": true,
+	"timer_delete": true, "timer_getoverrun": true, "timer_gettime": true,
+	"timer_settime": true, "timerfd": true, "timerfd_create": true,
+	"timerfd_gettime": true, "timerfd_settime": true, "times": true,
+	"tkill": true, "truncate": true, "truncate64": true, "tuxcall": true,
+	"ugetrlimit": true, "ulimit": true, "umask": true, "umount": true,
+	"umount2": true, "uname": true, "unlink": true, "unlinkat": true,
+	"unshare": true, "uselib": true, "userfaultfd": true, "usr26": true,
+	"usr32": true, "ustat": true, "utime": true, "utimensat": true,
+	"utimes": true, "vfork": true, "vhangup": true, "vm86": true,
+	"vm86old": true, "vmsplice": true, "vserver": true, "wait4": true,
+	"waitid": true, "waitpid": true, "write": true, "writev": true,
+}
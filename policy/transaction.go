@@ -0,0 +1,348 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package policy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/net/context"
+
+	"github.com/snapcore/snapd/osutil"
+)
+
+// rename is os.Rename, mocked in the tests.
+var rename = os.Rename
+
+// fileChange is the change of a target file by a transaction: it is
+// replaced with the staged copy of the source file, or removed if there
+// is none. The target file is linked to the backup file while
+// committing, so that it is never missing when it is replaced.
+type fileChange struct {
+	target string
+	source string
+	staged string
+	backup string
+
+	backedUp  bool
+	committed bool
+}
+
+// A transaction stages all the changes of a framework operation before
+// making any, and commits them so that either all are made or, on
+// failure, the target files are left as they were.
+type transaction struct {
+	ctx     context.Context
+	op      Op
+	changes []*fileChange
+	// workers is how many files are staged at once.
+	workers int
+	// dryRun is set to only work out the changes, without staging
+	// them nor touching the target directories.
+	dryRun bool
+	// owner, if set, is given the staged files.
+	owner *fileOwner
+	// newDirs are the target directories made while staging.
+	newDirs []string
+	// skipped are the target files already the same as the files of
+	// the framework, with their source.
+	skipped []*fileChange
+	// observe, if set, is told about the target files once the
+	// changes are committed, or about the one they failed on.
+	observe Observer
+}
+
+// stage does the checks and copies of the operation on the files found
+// with the glob, as iterOp, but into staged files next to the target
+// files, which are left alone. Up to t.workers files are copied at once,
+// until t.ctx is done.
+func (t *transaction) stage(glob, targetDir, prefix string) error {
+	if !osutil.IsDirectory(targetDir) && !t.dryRun {
+		if err := os.MkdirAll(targetDir, 0755); err != nil {
+			return targetError("make directory", targetDir, err)
+		}
+		t.newDirs = append(t.newDirs, targetDir)
+	}
+
+	files, err := filepath.Glob(glob)
+	if err != nil {
+		return &PathError{Op: "glob", Path: glob, Err: err}
+	}
+
+	keep := make(map[string]bool, len(files))
+	var copies []*fileChange
+	for _, file := range files {
+		source, err := policySource(t.op, file)
+		if err != nil {
+			return err
+		}
+
+		targetFile := filepath.Join(targetDir, prefix+filepath.Base(file))
+		keep[targetFile] = true
+		change := &fileChange{
+			target: targetFile,
+			backup: filepath.Join(targetDir, "."+prefix+filepath.Base(file)+"~old"),
+		}
+		switch t.op {
+		case remove:
+			if !osutil.FileExists(targetFile) {
+				err := &PathError{Op: "remove", Path: targetFile, Err: ErrMissingTarget}
+				t.observe.notify(FileFailed, targetFile, "", err)
+				return err
+			}
+		case install, upgrade:
+			if sameContents(source, targetFile, t.owner) {
+				change.source = source
+				t.skipped = append(t.skipped, change)
+				continue
+			}
+			change.source = source
+			if t.dryRun {
+				break
+			}
+			change.staged = filepath.Join(targetDir, "."+prefix+filepath.Base(file)+"~new")
+			copies = append(copies, change)
+			continue
+		default:
+			return fmt.Errorf("unknown operation %s", t.op)
+		}
+		t.changes = append(t.changes, change)
+	}
+
+	// recorded before copying so that they are cleaned up on failure
+	t.changes = append(t.changes, copies...)
+	errs := make([]error, len(copies))
+	parallel(len(copies), t.workers, func(i int) {
+		change := copies[i]
+		if errs[i] = t.ctx.Err(); errs[i] == nil {
+			errs[i] = copyFile(t.ctx, change.source, change.staged, t.owner)
+		}
+		if errs[i] != nil {
+			t.observe.notify(FileFailed, change.target, change.source, errs[i])
+		}
+	})
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	if t.op == upgrade {
+		installed, err := filepath.Glob(filepath.Join(targetDir, prefix+filepath.Base(glob)))
+		if err != nil {
+			return &PathError{Op: "glob", Path: targetDir, Err: err}
+		}
+		for _, targetFile := range installed {
+			if keep[targetFile] {
+				continue
+			}
+			t.changes = append(t.changes, &fileChange{
+				target: targetFile,
+				backup: filepath.Join(targetDir, "."+filepath.Base(targetFile)+"~old"),
+			})
+		}
+	}
+
+	return nil
+}
+
+// commit makes the staged changes, only linking and renaming files, and
+// rolls them back on failure.
+func (t *transaction) commit() (*OpResult, error) {
+	for _, change := range t.changes {
+		if err := change.commit(); err != nil {
+			t.observe.notify(FileFailed, change.target, change.source, err)
+			return nil, t.rollback(err)
+		}
+	}
+	// past this point the operation is done
+	res := &OpResult{Skipped: len(t.skipped)}
+	for _, change := range t.skipped {
+		t.observe.notify(FileSkipped, change.target, change.source, nil)
+	}
+	for _, change := range t.changes {
+		if change.backedUp {
+			os.Remove(change.backup)
+		}
+		if change.source != "" {
+			t.observe.notify(FileCopied, change.target, change.source, nil)
+			res.Copied++
+		} else {
+			t.observe.notify(FileRemoved, change.target, "", nil)
+			res.Removed++
+		}
+	}
+	return res, nil
+}
+
+// commit backs up the target file, as a hard link to it for it to be
+// kept in place, and then renames the staged file over it, or removes
+// it if there is none.
+func (change *fileChange) commit() error {
+	if osutil.FileExists(change.target) {
+		// left over by an earlier transaction that did not finish
+		os.Remove(change.backup)
+		if err := os.Link(change.target, change.backup); err != nil {
+			return &PathError{Op: "back up", Path: change.target, Err: err}
+		}
+		change.backedUp = true
+	}
+	if change.staged == "" {
+		if err := os.Remove(change.target); err != nil {
+			return targetError("remove", change.target, err)
+		}
+	} else if err := rename(change.staged, change.target); err != nil {
+		return targetError("replace", change.target, err)
+	}
+	change.committed = true
+	return nil
+}
+
+// rollback undoes the changes committed so far, in reverse order, and
+// drops the staged files and the target directories made, returning
+// the error that caused it.
+func (t *transaction) rollback(cause error) error {
+	var failed error
+	for i := len(t.changes) - 1; i >= 0; i-- {
+		change := t.changes[i]
+		switch {
+		case change.backedUp:
+			// renamed over whatever is there, for the target to
+			// never be missing
+			if err := rename(change.backup, change.target); err != nil && failed == nil {
+				failed = err
+			}
+			// a rename between two links to the same file leaves
+			// both in place
+			os.Remove(change.backup)
+		case change.committed:
+			if err := os.Remove(change.target); err != nil && failed == nil {
+				failed = err
+			}
+		}
+		if change.staged != "" && !change.committed {
+			os.Remove(change.staged)
+		}
+	}
+	for i := len(t.newDirs) - 1; i >= 0; i-- {
+		// only removed if left empty
+		os.Remove(t.newDirs[i])
+	}
+	if failed != nil {
+		return fmt.Errorf("%v (and unable to roll back: %v)", cause, failed)
+	}
+	return cause
+}
+
+// dirs returns the directories of the target files, and the parents of
+// the target directories made, in the order the changes were staged.
+func (t *transaction) dirs() []string {
+	dirs := make([]string, 0, len(t.changes)+len(t.newDirs))
+	for _, change := range t.changes {
+		dirs = append(dirs, filepath.Dir(change.target))
+	}
+	for _, dir := range t.newDirs {
+		dirs = append(dirs, filepath.Dir(dir))
+	}
+	return dirs
+}
+
+// installed returns the target files the transaction leaves with the
+// files of the framework: the ones it copies and the ones that were the
+// same already.
+func (t *transaction) installed() []string {
+	targets := make([]string, 0, len(t.changes)+len(t.skipped))
+	for _, change := range t.changes {
+		if change.source != "" {
+			targets = append(targets, change.target)
+		}
+	}
+	for _, change := range t.skipped {
+		targets = append(targets, change.target)
+	}
+	return targets
+}
+
+// stagePolicy stages the changes of the operation of the transaction
+// on the policy of the given package that's installed in the given
+// path: removing stages the removal of the files recorded in its
+// manifest, if there is one, and the others the files of the snap.
+func (m *Manager) stagePolicy(t *transaction, pkgName, instPath string) error {
+	if t.op == remove {
+		files, recorded, err := m.manifestFiles(pkgName)
+		if err != nil {
+			return err
+		}
+		if recorded {
+			return t.stageManifest(files)
+		}
+	}
+	return m.forEachPolicy(pkgName, instPath, t.stage)
+}
+
+// FrameworkTransactionContext performs the given operation (OpInstall,
+// OpRemove or OpUpgrade) on the given package that's installed in the
+// given path as a transaction: the policy is validated and all of its
+// files are staged first, and only then are the target files replaced or
+// removed. The manifest of the package is used and updated as by
+// FrameworkOpContext. Once the target files are changed they are kept:
+// failing to sync their directories, to have the backends use them or to
+// update the manifest then returns the result along with a CommitError.
+// Up to as many files as the manager has workers are staged at once,
+// until ctx is done, which then rolls everything back.
+func (m *Manager) FrameworkTransactionContext(ctx context.Context, op Op, pkgName, instPath string) (*OpResult, error) {
+	if op != remove {
+		if err := m.validatePolicy(instPath); err != nil {
+			return nil, err
+		}
+	}
+	if err := m.checkConflicts(op, pkgName, instPath); err != nil {
+		return nil, err
+	}
+	var res *OpResult
+	committed := false
+	t := &transaction{ctx: ctx, op: op, workers: m.workers, owner: m.owner, observe: m.observe}
+	err := m.withLoadedPolicy(pkgName, func() error {
+		if err := m.stagePolicy(t, pkgName, instPath); err != nil {
+			return t.rollback(err)
+		}
+
+		var err error
+		res, err = t.commit()
+		committed = err == nil
+		// synced even when rolled back, for the target files to be
+		// kept as they were
+		if serr := m.syncDirs(t.dirs()); serr != nil && err == nil {
+			err = serr
+		}
+		return err
+	})
+	if err == nil {
+		err = m.updateManifest(op, pkgName, t.installed())
+	}
+	if err != nil {
+		if committed {
+			return res, &CommitError{Package: pkgName, Result: res, Err: err}
+		}
+		return nil, err
+	}
+	return res, nil
+}
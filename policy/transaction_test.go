@@ -0,0 +1,253 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package policy
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/context"
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/osutil"
+)
+
+// policyFiles returns the contents of the files installed under the
+// root directory, by path relative to it.
+func policyFiles(c *C, rootDir string) map[string]string {
+	files := make(map[string]string)
+	err := filepath.Walk(rootDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		bs, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(rootDir, path)
+		if err != nil {
+			return err
+		}
+		files[rel] = string(bs)
+		return nil
+	})
+	c.Assert(err, IsNil)
+	return files
+}
+
+func (s *policySuite) TestFrameworkTransactionRoundtrip(c *C) {
+	rootDir := c.MkDir()
+	SecBase = "/sec"
+	c.Assert(InstallTransactional("foo", s.orig, rootDir), IsNil)
+	files := policyFiles(c, rootDir)
+	// and the manifest
+	c.Check(files, HasLen, 4*3+1)
+	c.Check(files["sec/apparmor/policygroups/foo_policygroups0"], Equals, "apparmor::policygroups0")
+
+	c.Assert(ioutil.WriteFile(filepath.Join(s.appg, "policygroups1"), []byte("changed"), 0644), IsNil)
+	c.Assert(os.Remove(filepath.Join(s.appg, "policygroups2")), IsNil)
+	c.Assert(UpgradeTransactional("foo", s.orig, rootDir), IsNil)
+	files = policyFiles(c, rootDir)
+	c.Check(files, HasLen, 4*3-1+1)
+	c.Check(files["sec/apparmor/policygroups/foo_policygroups1"], Equals, "changed")
+
+	c.Assert(RemoveTransactional("foo", s.orig, rootDir), IsNil)
+	c.Check(policyFiles(c, rootDir), HasLen, 0)
+}
+
+func (s *policySuite) TestFrameworkTransactionSymlinks(c *C) {
+	rootDir := c.MkDir()
+	SecBase = "/sec"
+	// a seccomp policy group shared with a link, validated as the file
+	// it resolves to
+	pg := filepath.Join(s.orig, "meta", "framework-policy", "seccomp", "policygroups")
+	c.Assert(os.Symlink("policygroups0", filepath.Join(pg, "policygroups3")), IsNil)
+
+	c.Assert(InstallTransactional("foo", s.orig, rootDir), IsNil)
+	files := policyFiles(c, rootDir)
+	c.Check(files["sec/seccomp/policygroups/foo_policygroups3"], Equals, "# seccomp::policygroups0\nread\n")
+
+	report, err := New(WithRootDir(rootDir)).Verify("foo", s.orig)
+	c.Assert(err, IsNil)
+	c.Check(report.OK(), Equals, true)
+
+	c.Assert(os.Remove(filepath.Join(pg, "policygroups3")), IsNil)
+	c.Assert(os.Symlink("/etc/passwd", filepath.Join(pg, "policygroups3")), IsNil)
+	err = UpgradeTransactional("foo", s.orig, rootDir)
+	c.Check(err, ErrorMatches, "unable to do Upgrade for .*/policygroups3: symlink escapes the policy directory")
+	c.Check(policyFiles(c, rootDir), DeepEquals, files)
+}
+
+func (s *policySuite) TestFrameworkTransactionStagingFails(c *C) {
+	rootDir := c.MkDir()
+	SecBase = "/sec"
+	c.Assert(Install("foo", s.orig, rootDir), IsNil)
+	before := policyFiles(c, rootDir)
+
+	// the first policy files change, but the last ones can't be staged
+	c.Assert(ioutil.WriteFile(filepath.Join(s.appg, "policygroups1"), []byte("changed"), 0644), IsNil)
+	bad := filepath.Join(s.orig, "meta", "framework-policy", "seccomp", "templates", "badbad")
+	c.Assert(os.Symlink(bad, bad), IsNil)
+
+	err := UpgradeTransactional("foo", s.orig, rootDir)
+	c.Check(err, ErrorMatches, ".*badbad: not a regular file")
+	c.Check(policyFiles(c, rootDir), DeepEquals, before)
+}
+
+func (s *policySuite) TestFrameworkTransactionCommitFails(c *C) {
+	rootDir := c.MkDir()
+	SecBase = "/sec"
+	c.Assert(Install("foo", s.orig, rootDir), IsNil)
+	before := policyFiles(c, rootDir)
+
+	for _, f := range []string{"policygroups0", "policygroups1", "policygroups2"} {
+		c.Assert(ioutil.WriteFile(filepath.Join(s.appg, f), []byte("changed"), 0644), IsNil)
+	}
+	n := 0
+	rename = func(oldpath, newpath string) error {
+		n++
+		if n == 2 {
+			return errors.New("no space left on device")
+		}
+		return os.Rename(oldpath, newpath)
+	}
+	defer func() { rename = os.Rename }()
+
+	err := UpgradeTransactional("foo", s.orig, rootDir)
+	c.Check(err, ErrorMatches, "unable to replace .*/foo_policygroups1: no space left on device")
+	c.Check(policyFiles(c, rootDir), DeepEquals, before)
+}
+
+func (s *policySuite) TestFrameworkTransactionTargetNeverMissing(c *C) {
+	rootDir := c.MkDir()
+	SecBase = "/sec"
+	c.Assert(Install("foo", s.orig, rootDir), IsNil)
+
+	c.Assert(ioutil.WriteFile(filepath.Join(s.appg, "policygroups0"), []byte("changed"), 0644), IsNil)
+	n := 0
+	rename = func(oldpath, newpath string) error {
+		n++
+		// the target is backed up, and in place, before it is replaced
+		c.Check(osutil.FileExists(newpath), Equals, true)
+		c.Check(osutil.FileExists(filepath.Join(filepath.Dir(newpath), "."+filepath.Base(newpath)+"~old")), Equals, true)
+		return os.Rename(oldpath, newpath)
+	}
+	defer func() { rename = os.Rename }()
+
+	c.Assert(UpgradeTransactional("foo", s.orig, rootDir), IsNil)
+	c.Check(n, Equals, 1)
+	files := policyFiles(c, rootDir)
+	c.Check(files["sec/apparmor/policygroups/foo_policygroups0"], Equals, "changed")
+	// no backup is left behind
+	c.Check(files, HasLen, 4*3+1)
+}
+
+func (s *policySuite) TestFrameworkTransactionContextDone(c *C) {
+	rootDir := c.MkDir()
+	SecBase = "/sec"
+	c.Assert(Install("foo", s.orig, rootDir), IsNil)
+	before := policyFiles(c, rootDir)
+
+	c.Assert(ioutil.WriteFile(filepath.Join(s.appg, "policygroups0"), []byte("changed"), 0644), IsNil)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := New(WithRootDir(rootDir)).FrameworkTransactionContext(ctx, OpUpgrade, "foo", s.orig)
+	c.Check(err, Equals, context.Canceled)
+	c.Check(policyFiles(c, rootDir), DeepEquals, before)
+}
+
+func (s *policySuite) TestFrameworkTransactionObserver(c *C) {
+	r := &eventRecorder{}
+	m := New(WithRootDir(c.MkDir()), WithSecBase("/sec"), WithObserver(r.observe))
+	c.Assert(m.Install("foo", s.orig), IsNil)
+	c.Check(r.sorted(), HasLen, 4*3)
+
+	for _, f := range []string{"policygroups0", "policygroups1"} {
+		c.Assert(ioutil.WriteFile(filepath.Join(s.appg, f), []byte("changed"), 0644), IsNil)
+	}
+	n := 0
+	rename = func(oldpath, newpath string) error {
+		n++
+		if n == 2 {
+			return errors.New("no space left on device")
+		}
+		return os.Rename(oldpath, newpath)
+	}
+	defer func() { rename = os.Rename }()
+
+	// nothing but the failure is told about, as it is all rolled back
+	err := m.UpgradeTransactional("foo", s.orig)
+	c.Assert(err, NotNil)
+	c.Check(r.sorted(), DeepEquals, []string{
+		"failed foo_policygroups1: " + err.Error(),
+	})
+
+	rename = os.Rename
+	c.Assert(m.UpgradeTransactional("foo", s.orig), IsNil)
+	events := r.sorted()
+	c.Assert(events, HasLen, 4*3)
+	c.Check(events[:2], DeepEquals, []string{
+		"copied foo_policygroups0",
+		"copied foo_policygroups1",
+	})
+	for _, event := range events[2:] {
+		c.Check(strings.HasPrefix(event, "skipped "), Equals, true, Commentf(event))
+	}
+}
+
+func (s *policySuite) TestFrameworkTransactionInstallFailsLeavesNothing(c *C) {
+	rootDir := c.MkDir()
+	SecBase = "/sec"
+	rename = func(oldpath, newpath string) error {
+		if strings.HasSuffix(newpath, "foo_templates2") {
+			return errors.New("permission denied")
+		}
+		return os.Rename(oldpath, newpath)
+	}
+	defer func() { rename = os.Rename }()
+
+	err := InstallTransactional("foo", s.orig, rootDir)
+	c.Check(err, ErrorMatches, "unable to replace .*/apparmor/templates/foo_templates2: permission denied")
+	c.Check(policyFiles(c, rootDir), HasLen, 0)
+	// the directories made are gone too
+	g, err := filepath.Glob(filepath.Join(rootDir, "sec", "*", "*"))
+	c.Assert(err, IsNil)
+	sort.Strings(g)
+	c.Check(g, HasLen, 0)
+}
+
+func (s *policySuite) TestFrameworkTransactionRemoveMissing(c *C) {
+	rootDir := c.MkDir()
+	SecBase = "/sec"
+	c.Assert(Install("foo", s.orig, rootDir), IsNil)
+	c.Assert(os.Remove(filepath.Join(rootDir, "sec", "seccomp", "templates", "foo_templates1")), IsNil)
+	// installed before manifests were kept
+	c.Assert(os.Remove(filepath.Join(rootDir, "sec", "manifests", "foo.json")), IsNil)
+	before := policyFiles(c, rootDir)
+
+	err := RemoveTransactional("foo", s.orig, rootDir)
+	c.Check(err, ErrorMatches, "unable to remove .*/foo_templates1: not found")
+	c.Check(IsMissingTarget(err), Equals, true)
+	c.Check(policyFiles(c, rootDir), DeepEquals, before)
+}
@@ -0,0 +1,129 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package policy
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/snapcore/snapd/fips"
+)
+
+// A VerifyReport tells how the policy installed for a framework drifted
+// from the one the framework ships, e.g. as it was tampered with or
+// not fully installed. Paths are of target files.
+type VerifyReport struct {
+	// Missing are the files the framework ships that are not installed.
+	Missing []string
+	// Modified are the installed files that differ from the ones the
+	// framework ships, in size or content.
+	Modified []string
+	// ModeChanged are the installed files with the content of the ones
+	// the framework ships but not the mode or owner they are given.
+	ModeChanged []string
+	// Extraneous are the installed files the framework doesn't ship.
+	Extraneous []string
+}
+
+// OK returns whether the installed policy is the one of the framework.
+func (r *VerifyReport) OK() bool {
+	return len(r.Missing) == 0 && len(r.Modified) == 0 && len(r.ModeChanged) == 0 && len(r.Extraneous) == 0
+}
+
+// fileDigest returns the size and the SHA256 hash of the given file.
+func fileDigest(path string) (int64, []byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer f.Close()
+	h := fips.SHA256()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return 0, nil, err
+	}
+	return size, h.Sum(nil), nil
+}
+
+// verifyOp adds to the report how the target files with the given
+// prefix in the target directory differ from the files found with the
+// given glob, as iterOp would install them for owner.
+func verifyOp(report *VerifyReport, glob, targetDir, prefix string, owner *fileOwner) error {
+	files, err := filepath.Glob(glob)
+	if err != nil {
+		return &PathError{Op: "glob", Path: glob, Err: err}
+	}
+
+	keep := make(map[string]bool, len(files))
+	for _, file := range files {
+		source, err := policySource(verify, file)
+		if err != nil {
+			return err
+		}
+
+		targetFile := filepath.Join(targetDir, prefix+filepath.Base(file))
+		keep[targetFile] = true
+		if _, err := os.Lstat(targetFile); os.IsNotExist(err) {
+			report.Missing = append(report.Missing, targetFile)
+			continue
+		} else if err != nil {
+			return &PathError{Op: "stat", Path: targetFile, Err: err}
+		}
+		switch {
+		case !sameDigest(source, targetFile):
+			report.Modified = append(report.Modified, targetFile)
+		case !sameMode(source, targetFile, owner):
+			report.ModeChanged = append(report.ModeChanged, targetFile)
+		}
+	}
+
+	installed, err := filepath.Glob(filepath.Join(targetDir, prefix+filepath.Base(glob)))
+	if err != nil {
+		return &PathError{Op: "glob", Path: targetDir, Err: err}
+	}
+	for _, targetFile := range installed {
+		if !keep[targetFile] {
+			report.Extraneous = append(report.Extraneous, targetFile)
+		}
+	}
+	return nil
+}
+
+// Verify compares the policy installed for the given package with the
+// one of the snap installed in the given path, without changing
+// anything.
+func (m *Manager) Verify(pkgName, instPath string) (*VerifyReport, error) {
+	report := &VerifyReport{}
+	err := m.forEachPolicy(pkgName, instPath, func(glob, targetDir, prefix string) error {
+		return verifyOp(report, glob, targetDir, prefix, m.owner)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// Verify compares the framework's policy installed in the system with
+// the one of the given snap that's installed in the given path, to
+// detect tampering or incomplete installs.
+func Verify(pkgName, instPath, rootDir string) (*VerifyReport, error) {
+	return New(WithRootDir(rootDir)).Verify(pkgName, instPath)
+}
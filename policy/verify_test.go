@@ -0,0 +1,108 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package policy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *policySuite) TestVerifyInstalled(c *C) {
+	rootDir := c.MkDir()
+	SecBase = "/sec"
+	c.Assert(Install("foo", s.orig, rootDir), IsNil)
+
+	report, err := Verify("foo", s.orig, rootDir)
+	c.Assert(err, IsNil)
+	c.Check(report, DeepEquals, &VerifyReport{})
+	c.Check(report.OK(), Equals, true)
+}
+
+func (s *policySuite) TestVerifyDrift(c *C) {
+	rootDir := c.MkDir()
+	SecBase = "/sec"
+	c.Assert(Install("foo", s.orig, rootDir), IsNil)
+	c.Assert(Install("bar", s.orig, rootDir), IsNil)
+
+	target := func(name string) string {
+		return filepath.Join(rootDir, "sec", "apparmor", "policygroups", name)
+	}
+	// same size, other content
+	c.Assert(ioutil.WriteFile(target("foo_policygroups0"), []byte("apparmor::policygroupsX"), 0644), IsNil)
+	// other size
+	c.Assert(ioutil.WriteFile(target("foo_policygroups1"), []byte("x"), 0644), IsNil)
+	c.Assert(os.Remove(target("foo_policygroups2")), IsNil)
+	c.Assert(ioutil.WriteFile(target("foo_extra"), nil, 0644), IsNil)
+	seccompTarget := filepath.Join(rootDir, "sec", "seccomp", "templates", "foo_templates0")
+	c.Assert(os.Remove(seccompTarget), IsNil)
+	c.Assert(os.Symlink(filepath.Join(s.orig, "meta", "framework-policy", "seccomp", "templates", "templates0"), seccompTarget), IsNil)
+	before := policyFiles(c, rootDir)
+
+	report, err := Verify("foo", s.orig, rootDir)
+	c.Assert(err, IsNil)
+	c.Check(report, DeepEquals, &VerifyReport{
+		Missing:    []string{target("foo_policygroups2")},
+		Modified:   []string{target("foo_policygroups0"), target("foo_policygroups1"), seccompTarget},
+		Extraneous: []string{target("foo_extra")},
+	})
+	c.Check(report.OK(), Equals, false)
+
+	// the other framework is fine
+	report, err = Verify("bar", s.orig, rootDir)
+	c.Assert(err, IsNil)
+	c.Check(report.OK(), Equals, true)
+
+	// nothing was touched
+	c.Check(policyFiles(c, rootDir), DeepEquals, before)
+}
+
+func (s *policySuite) TestVerifyModeChanged(c *C) {
+	rootDir := c.MkDir()
+	SecBase = "/sec"
+	c.Assert(Install("foo", s.orig, rootDir), IsNil)
+
+	target := filepath.Join(rootDir, "sec", "apparmor", "policygroups", "foo_policygroups0")
+	c.Assert(os.Chmod(target, 0755), IsNil)
+
+	report, err := Verify("foo", s.orig, rootDir)
+	c.Assert(err, IsNil)
+	c.Check(report, DeepEquals, &VerifyReport{
+		ModeChanged: []string{target},
+	})
+	c.Check(report.OK(), Equals, false)
+}
+
+func (s *policySuite) TestVerifyNotInstalled(c *C) {
+	report, err := New(WithRootDir(c.MkDir()), WithSecBase("/sec")).Verify("foo", s.orig)
+	c.Assert(err, IsNil)
+	c.Check(report.Missing, HasLen, 4*3)
+	c.Check(report.Modified, HasLen, 0)
+	c.Check(report.Extraneous, HasLen, 0)
+}
+
+func (s *policySuite) TestVerifyError(c *C) {
+	bad := filepath.Join(s.appg, "badbad")
+	c.Assert(os.Symlink(bad, bad), IsNil)
+	_, err := Verify("foo", s.orig, c.MkDir())
+	c.Check(err, ErrorMatches, "unable to do Verify for .*badbad: not a regular file")
+}
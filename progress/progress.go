@@ -22,6 +22,7 @@ package progress
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"unicode"
 
@@ -173,12 +174,104 @@ func (*TextProgress) Notify(msg string) {
 	fmt.Printf("\r%s%s\n", msg, clearUntilEOL)
 }
 
+// PlainProgress shows progress as a sequence of complete lines,
+// without control codes, spinners or redraws, so that it is usable
+// with screen readers and dumb terminals.
+type PlainProgress struct {
+	w       io.Writer
+	label   string
+	total   float64
+	current float64
+	step    int
+	lastMsg string
+}
+
+// plainProgressStep is the percentage between two progress reports.
+const plainProgressStep = 25
+
+// NewPlainProgress returns a new PlainProgress writing to w.
+func NewPlainProgress(w io.Writer) *PlainProgress {
+	return &PlainProgress{w: w}
+}
+
+// Start announces the start of the operation
+func (t *PlainProgress) Start(label string, total float64) {
+	t.label = label
+	t.total = total
+	t.current = 0
+	t.step = 0
+	t.lastMsg = ""
+	fmt.Fprintf(t.w, "%s...\n", label)
+}
+
+// Set sets the progress to the current value, reporting each time
+// another plainProgressStep percent is reached
+func (t *PlainProgress) Set(current float64) {
+	t.current = current
+	if t.total <= 0 {
+		return
+	}
+	step := int(current*100/t.total) / plainProgressStep
+	if step > 100/plainProgressStep {
+		step = 100 / plainProgressStep
+	}
+	if step > t.step {
+		t.step = step
+		fmt.Fprintf(t.w, "%s: %d%%\n", t.label, step*plainProgressStep)
+	}
+}
+
+// SetTotal set the total steps needed
+func (t *PlainProgress) SetTotal(total float64) {
+	t.total = total
+}
+
+// Finished ends the current operation
+func (t *PlainProgress) Finished() {
+	t.label = ""
+	t.total = 0
+	t.current = 0
+	t.step = 0
+}
+
+// Write is there so that progress can implment a Writer and can be
+// used to display progress of io operations
+func (t *PlainProgress) Write(p []byte) (n int, err error) {
+	t.Set(t.current + float64(len(p)))
+	return len(p), nil
+}
+
+// Spin reports an operation of unknown duration, once per message
+func (t *PlainProgress) Spin(msg string) {
+	if msg == t.lastMsg {
+		return
+	}
+	t.lastMsg = msg
+	fmt.Fprintf(t.w, "%s...\n", msg)
+}
+
+// Notify the user of miscelaneous events
+func (t *PlainProgress) Notify(msg string) {
+	fmt.Fprintln(t.w, msg)
+}
+
+// PlainRequested returns whether the environment asks for plain,
+// line-oriented output, either because SNAP_SCREEN_READER is set or
+// because the terminal is a dumb one.
+func PlainRequested() bool {
+	return os.Getenv("SNAP_SCREEN_READER") != "" || os.Getenv("TERM") == "dumb"
+}
+
 // MakeProgressBar creates an appropriate progress (which may be a
 // NullProgress bar if there is no associated terminal).
 func MakeProgressBar() Meter {
 	var pbar Meter
 	if attachedToTerminal() {
-		pbar = NewTextProgress()
+		if PlainRequested() {
+			pbar = NewPlainProgress(os.Stdout)
+		} else {
+			pbar = NewTextProgress()
+		}
 	} else {
 		pbar = &NullProgress{}
 	}
@@ -20,6 +20,7 @@
 package progress
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -137,6 +138,12 @@ func (ts *ProgressTestSuite) TestMakeProgressBar(c *C) {
 		attachedToTerminal = ts.originalAttachedToTerminal
 	}()
 
+	os.Setenv("SNAP_SCREEN_READER", "")
+	defer os.Unsetenv("SNAP_SCREEN_READER")
+	oldTerm := os.Getenv("TERM")
+	os.Setenv("TERM", "xterm")
+	defer os.Setenv("TERM", oldTerm)
+
 	ts.attachedToTerminalReturn = true
 
 	pbar = MakeProgressBar()
@@ -148,3 +155,61 @@ func (ts *ProgressTestSuite) TestMakeProgressBar(c *C) {
 	c.Assert(pbar, FitsTypeOf, &NullProgress{})
 
 }
+
+func (ts *ProgressTestSuite) TestMakeProgressBarPlain(c *C) {
+	ts.originalAttachedToTerminal = attachedToTerminal
+	attachedToTerminal = ts.MockAttachedToTerminal
+	defer func() {
+		attachedToTerminal = ts.originalAttachedToTerminal
+	}()
+	ts.attachedToTerminalReturn = true
+
+	oldTerm := os.Getenv("TERM")
+	defer os.Setenv("TERM", oldTerm)
+
+	os.Setenv("TERM", "dumb")
+	c.Check(PlainRequested(), Equals, true)
+	c.Check(MakeProgressBar(), FitsTypeOf, &PlainProgress{})
+
+	os.Setenv("TERM", "xterm")
+	c.Check(PlainRequested(), Equals, false)
+	os.Setenv("SNAP_SCREEN_READER", "1")
+	defer os.Unsetenv("SNAP_SCREEN_READER")
+	c.Check(PlainRequested(), Equals, true)
+	c.Check(MakeProgressBar(), FitsTypeOf, &PlainProgress{})
+}
+
+func (ts *ProgressTestSuite) TestPlainProgress(c *C) {
+	var buf bytes.Buffer
+	t := NewPlainProgress(&buf)
+
+	t.Spin("Doing things")
+	t.Spin("Doing things")
+	t.Notify("a thing happened")
+	t.Spin("Doing other things")
+
+	t.Start("Download", 100)
+	for i := 1; i <= 100; i++ {
+		t.Set(float64(i))
+	}
+	t.Finished()
+
+	t.Start("Copy", 10)
+	t.Write([]byte("12345"))
+	t.Write([]byte("12345"))
+	t.Finished()
+
+	c.Check(buf.String(), Equals, `Doing things...
+a thing happened
+Doing other things...
+Download...
+Download: 25%
+Download: 50%
+Download: 75%
+Download: 100%
+Copy...
+Copy: 50%
+Copy: 100%
+`)
+	c.Check(buf.String(), Not(Matches), "(?s).*[\r\x1b].*")
+}
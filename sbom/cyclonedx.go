@@ -0,0 +1,140 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package sbom
+
+import (
+	"time"
+
+	"github.com/snapcore/snapd/cmd"
+	"github.com/snapcore/snapd/snap"
+)
+
+// The CycloneDX 1.4 JSON representation, as far as it is used here.
+
+type cdxTool struct {
+	Vendor  string `json:"vendor"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type cdxMetadata struct {
+	Timestamp string    `json:"timestamp"`
+	Tools     []cdxTool `json:"tools"`
+}
+
+type cdxHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type cdxProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type cdxComponent struct {
+	BOMRef     string        `json:"bom-ref"`
+	Type       string        `json:"type"`
+	Name       string        `json:"name"`
+	Version    string        `json:"version"`
+	Publisher  string        `json:"publisher,omitempty"`
+	Purl       string        `json:"purl"`
+	Hashes     []cdxHash     `json:"hashes,omitempty"`
+	Properties []cdxProperty `json:"properties"`
+}
+
+type cdxDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn"`
+}
+
+type cdxDoc struct {
+	BOMFormat    string          `json:"bomFormat"`
+	SpecVersion  string          `json:"specVersion"`
+	Version      int             `json:"version"`
+	Metadata     cdxMetadata     `json:"metadata"`
+	Components   []cdxComponent  `json:"components"`
+	Dependencies []cdxDependency `json:"dependencies"`
+}
+
+// cdxType returns the CycloneDX component type for a snap type.
+func cdxType(c *Component) string {
+	switch c.Type {
+	case snap.TypeOS:
+		return "operating-system"
+	case snap.TypeKernel, snap.TypeGadget:
+		return "firmware"
+	default:
+		return "application"
+	}
+}
+
+func cycloneDXDocument(components []*Component, created time.Time) *cdxDoc {
+	doc := &cdxDoc{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: "1.4",
+		Version:     1,
+		Metadata: cdxMetadata{
+			Timestamp: created.UTC().Format(time.RFC3339),
+			Tools:     []cdxTool{{Vendor: "Canonical", Name: "snapd", Version: cmd.Version}},
+		},
+		Components:   []cdxComponent{},
+		Dependencies: []cdxDependency{},
+	}
+	for _, c := range components {
+		comp := cdxComponent{
+			BOMRef:    c.purl(),
+			Type:      cdxType(c),
+			Name:      c.Name,
+			Version:   c.Version,
+			Publisher: c.Publisher,
+			Purl:      c.purl(),
+			Properties: []cdxProperty{
+				{Name: "snap:revision", Value: c.Revision.String()},
+				{Name: "snap:type", Value: string(c.Type)},
+			},
+		}
+		if c.SnapID != "" {
+			comp.Properties = append(comp.Properties, cdxProperty{Name: "snap:id", Value: c.SnapID})
+		}
+		if c.SHA256 != "" {
+			comp.Hashes = []cdxHash{
+				{Alg: "SHA-256", Content: c.SHA256},
+				{Alg: "SHA-512", Content: c.SHA512},
+			}
+		}
+		for _, ref := range c.Assertions {
+			comp.Properties = append(comp.Properties, cdxProperty{Name: "snap:assertion", Value: ref})
+		}
+		doc.Components = append(doc.Components, comp)
+	}
+	byName := make(map[string]*Component, len(components))
+	for _, c := range components {
+		byName[c.Name] = c
+	}
+	for _, c := range components {
+		dep := cdxDependency{Ref: c.purl(), DependsOn: []string{}}
+		if base := byName[c.Base]; base != nil {
+			dep.DependsOn = append(dep.DependsOn, base.purl())
+		}
+		doc.Dependencies = append(doc.Dependencies, dep)
+	}
+	return doc
+}
@@ -0,0 +1,208 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package sbom produces software bills of materials describing the
+// installed snaps, in the SPDX and CycloneDX formats.
+package sbom
+
+import (
+	"crypto"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/fips"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/snap"
+)
+
+// The supported formats of bills of materials.
+const (
+	SPDX      = "spdx"
+	CycloneDX = "cyclonedx"
+)
+
+// Component describes an installed snap in a bill of materials.
+type Component struct {
+	Name      string
+	Version   string
+	Revision  snap.Revision
+	SnapID    string
+	Publisher string
+	Type      snap.Type
+	// Base is the snap providing the runtime the snap runs on, if any.
+	Base string
+	// SHA256 and SHA512 are the hex encoded digests of the snap file;
+	// they are empty for snaps that are not installed from a file.
+	SHA256 string
+	SHA512 string
+	// Assertions reference the assertions vouching for the snap, as
+	// <type>/<primary key>...
+	Assertions []string
+}
+
+// AssertionFinder is the part of an assertion database used to find
+// the assertions of snaps.
+type AssertionFinder interface {
+	Find(assertionType *asserts.AssertionType, headers map[string]string) (asserts.Assertion, error)
+}
+
+func fileDigests(path string) (sha256, sha512 string, err error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+	defer f.Close()
+	if fi, err := f.Stat(); err != nil || fi.IsDir() {
+		// snaps being tried are directories
+		return "", "", err
+	}
+
+	h256 := fips.SHA256()
+	h512 := fips.SHA512()
+	if _, err := io.Copy(io.MultiWriter(h256, h512), f); err != nil {
+		return "", "", err
+	}
+	return hex.EncodeToString(h256.Sum(nil)), hex.EncodeToString(h512.Sum(nil)), nil
+}
+
+func assertionRef(a asserts.Assertion) string {
+	ref := []string{a.Type().Name}
+	for _, k := range a.Type().PrimaryKey {
+		ref = append(ref, a.Header(k))
+	}
+	return strings.Join(ref, "/")
+}
+
+func snapAssertions(info *snap.Info, sha512 string, db AssertionFinder) ([]string, error) {
+	if db == nil || info.SnapID == "" {
+		return nil, nil
+	}
+	var refs []string
+	find := func(assertType *asserts.AssertionType, headers map[string]string) error {
+		a, err := db.Find(assertType, headers)
+		if err == asserts.ErrNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		refs = append(refs, assertionRef(a))
+		return nil
+	}
+
+	err := find(asserts.SnapDeclarationType, map[string]string{
+		"series":  release.Series,
+		"snap-id": info.SnapID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if sha512 == "" {
+		return refs, nil
+	}
+	raw, err := hex.DecodeString(sha512)
+	if err != nil {
+		return nil, err
+	}
+	digest, err := asserts.EncodeDigest(crypto.SHA512, raw)
+	if err != nil {
+		return nil, err
+	}
+	err = find(asserts.SnapRevisionType, map[string]string{
+		"series":      release.Series,
+		"snap-id":     info.SnapID,
+		"snap-digest": digest,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return refs, nil
+}
+
+// Components returns the components describing the given snaps, with
+// their assertions found in db, which can be nil. Application snaps
+// have the OS snap among them as their base.
+func Components(infos []*snap.Info, db AssertionFinder) ([]*Component, error) {
+	base := ""
+	for _, info := range infos {
+		if info.Type == snap.TypeOS {
+			base = info.Name()
+		}
+	}
+
+	components := make([]*Component, 0, len(infos))
+	for _, info := range infos {
+		comp := &Component{
+			Name:      info.Name(),
+			Version:   info.Version,
+			Revision:  info.Revision,
+			SnapID:    info.SnapID,
+			Publisher: info.Developer,
+			Type:      info.Type,
+		}
+		if info.Type == snap.TypeApp || info.Type == "" {
+			comp.Base = base
+		}
+		var err error
+		comp.SHA256, comp.SHA512, err = fileDigests(info.MountFile())
+		if err != nil {
+			return nil, fmt.Errorf("cannot compute digests of snap %q: %v", comp.Name, err)
+		}
+		comp.Assertions, err = snapAssertions(info, comp.SHA512, db)
+		if err != nil {
+			return nil, fmt.Errorf("cannot find assertions of snap %q: %v", comp.Name, err)
+		}
+		components = append(components, comp)
+	}
+	sort.Sort(byName(components))
+	return components, nil
+}
+
+type byName []*Component
+
+func (cs byName) Len() int           { return len(cs) }
+func (cs byName) Less(i, j int) bool { return cs[i].Name < cs[j].Name }
+func (cs byName) Swap(i, j int)      { cs[i], cs[j] = cs[j], cs[i] }
+
+// purl returns the package URL of the component.
+func (c *Component) purl() string {
+	return fmt.Sprintf("pkg:snap/%s@%s?revision=%s", c.Name, c.Version, c.Revision)
+}
+
+// Document returns the bill of materials listing the components in the
+// given format, ready to be marshalled as JSON.
+func Document(format string, components []*Component, created time.Time) (interface{}, error) {
+	switch format {
+	case SPDX:
+		return spdxDocument(components, created), nil
+	case CycloneDX:
+		return cycloneDXDocument(components, created), nil
+	default:
+		return nil, fmt.Errorf("unknown bill of materials format %q", format)
+	}
+}
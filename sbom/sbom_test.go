@@ -0,0 +1,196 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package sbom_test
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/sbom"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type sbomSuite struct{}
+
+var _ = Suite(&sbomSuite{})
+
+func (s *sbomSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+}
+
+func (s *sbomSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("/")
+}
+
+type fakeFinder struct {
+	found map[string]asserts.Assertion
+}
+
+func (f *fakeFinder) Find(assertType *asserts.AssertionType, headers map[string]string) (asserts.Assertion, error) {
+	if a := f.found[assertType.Name+"/"+headers["snap-id"]+"/"+headers["snap-digest"]]; a != nil {
+		return a, nil
+	}
+	return nil, asserts.ErrNotFound
+}
+
+func decode(c *C, text string) asserts.Assertion {
+	a, err := asserts.Decode([]byte(text + "timestamp: 2016-09-01T00:00:00Z\nbody-length: 0\n\nopenpgp c2ln"))
+	c.Assert(err, IsNil)
+	return a
+}
+
+const fooYaml = "name: foo\nversion: 1.0\n"
+const coreYaml = "name: core\nversion: 16.04\ntype: os\n"
+
+func (s *sbomSuite) mockSnaps(c *C) []*snap.Info {
+	foo := snaptest.MockSnap(c, fooYaml, &snap.SideInfo{Revision: snap.R(7), SnapID: "foo-id", Developer: "acme"})
+	core := snaptest.MockSnap(c, coreYaml, &snap.SideInfo{Revision: snap.R(3)})
+	c.Assert(os.MkdirAll(filepath.Dir(foo.MountFile()), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(foo.MountFile(), []byte("blob"), 0644), IsNil)
+	return []*snap.Info{foo, core}
+}
+
+func (s *sbomSuite) TestComponents(c *C) {
+	infos := s.mockSnaps(c)
+
+	h512 := sha512.Sum512([]byte("blob"))
+	h256 := sha256.Sum256([]byte("blob"))
+	digest, err := asserts.EncodeDigest(crypto.SHA512, h512[:])
+	c.Assert(err, IsNil)
+	db := &fakeFinder{found: map[string]asserts.Assertion{
+		"snap-declaration/foo-id/":       decode(c, "type: snap-declaration\nauthority-id: canonical\nseries: 16\nsnap-id: foo-id\nsnap-name: foo\npublisher-id: acme-id\ngates: \n"),
+		"snap-revision/foo-id/" + digest: decode(c, "type: snap-revision\nauthority-id: canonical\nseries: 16\nsnap-id: foo-id\nsnap-digest: "+digest+"\nsnap-size: 4\nsnap-revision: 7\ndeveloper-id: acme-id\n"),
+	}}
+
+	components, err := sbom.Components(infos, db)
+	c.Assert(err, IsNil)
+	c.Check(components, DeepEquals, []*sbom.Component{{
+		Name:     "core",
+		Version:  "16.04",
+		Revision: snap.R(3),
+		Type:     snap.TypeOS,
+	}, {
+		Name:      "foo",
+		Version:   "1.0",
+		Revision:  snap.R(7),
+		SnapID:    "foo-id",
+		Publisher: "acme",
+		Type:      snap.TypeApp,
+		Base:      "core",
+		SHA256:    hex.EncodeToString(h256[:]),
+		SHA512:    hex.EncodeToString(h512[:]),
+		Assertions: []string{
+			"snap-declaration/16/foo-id",
+			"snap-revision/16/foo-id/" + digest,
+		},
+	}})
+
+	components, err = sbom.Components(infos, nil)
+	c.Assert(err, IsNil)
+	c.Check(components[1].Assertions, HasLen, 0)
+}
+
+func (s *sbomSuite) TestDocumentSPDX(c *C) {
+	components, err := sbom.Components(s.mockSnaps(c), nil)
+	c.Assert(err, IsNil)
+
+	doc, err := sbom.Document(sbom.SPDX, components, time.Date(2016, 9, 1, 10, 0, 0, 0, time.UTC))
+	c.Assert(err, IsNil)
+	var out map[string]interface{}
+	roundTrip(c, doc, &out)
+
+	c.Check(out["spdxVersion"], Equals, "SPDX-2.2")
+	c.Check(out["documentNamespace"], Equals, "https://snapcraft.io/spdx/installed-snaps-20160901T100000Z")
+	pkgs := out["packages"].([]interface{})
+	c.Assert(pkgs, HasLen, 2)
+	foo := pkgs[1].(map[string]interface{})
+	c.Check(foo["SPDXID"], Equals, "SPDXRef-snap-foo")
+	c.Check(foo["supplier"], Equals, "Organization: acme")
+	c.Check(foo["checksums"], HasLen, 2)
+	c.Check(foo["externalRefs"], DeepEquals, []interface{}{map[string]interface{}{
+		"referenceCategory": "PACKAGE-MANAGER",
+		"referenceType":     "purl",
+		"referenceLocator":  "pkg:snap/foo@1.0?revision=7",
+	}})
+	core := pkgs[0].(map[string]interface{})
+	c.Check(core["supplier"], Equals, "NOASSERTION")
+	c.Check(core["checksums"], IsNil)
+	c.Check(out["relationships"], DeepEquals, []interface{}{
+		map[string]interface{}{"spdxElementId": "SPDXRef-DOCUMENT", "relationshipType": "DESCRIBES", "relatedSpdxElement": "SPDXRef-snap-core"},
+		map[string]interface{}{"spdxElementId": "SPDXRef-DOCUMENT", "relationshipType": "DESCRIBES", "relatedSpdxElement": "SPDXRef-snap-foo"},
+		map[string]interface{}{"spdxElementId": "SPDXRef-snap-foo", "relationshipType": "DEPENDS_ON", "relatedSpdxElement": "SPDXRef-snap-core"},
+	})
+}
+
+func (s *sbomSuite) TestDocumentCycloneDX(c *C) {
+	components, err := sbom.Components(s.mockSnaps(c), nil)
+	c.Assert(err, IsNil)
+
+	doc, err := sbom.Document(sbom.CycloneDX, components, time.Date(2016, 9, 1, 10, 0, 0, 0, time.UTC))
+	c.Assert(err, IsNil)
+	var out map[string]interface{}
+	roundTrip(c, doc, &out)
+
+	c.Check(out["bomFormat"], Equals, "CycloneDX")
+	c.Check(out["metadata"].(map[string]interface{})["timestamp"], Equals, "2016-09-01T10:00:00Z")
+	comps := out["components"].([]interface{})
+	c.Assert(comps, HasLen, 2)
+	c.Check(comps[0].(map[string]interface{})["type"], Equals, "operating-system")
+	foo := comps[1].(map[string]interface{})
+	c.Check(foo["type"], Equals, "application")
+	c.Check(foo["publisher"], Equals, "acme")
+	c.Check(foo["purl"], Equals, "pkg:snap/foo@1.0?revision=7")
+	c.Check(foo["hashes"], HasLen, 2)
+	c.Check(foo["properties"], DeepEquals, []interface{}{
+		map[string]interface{}{"name": "snap:revision", "value": "7"},
+		map[string]interface{}{"name": "snap:type", "value": "app"},
+		map[string]interface{}{"name": "snap:id", "value": "foo-id"},
+	})
+	c.Check(out["dependencies"], DeepEquals, []interface{}{
+		map[string]interface{}{"ref": "pkg:snap/core@16.04?revision=3", "dependsOn": []interface{}{}},
+		map[string]interface{}{"ref": "pkg:snap/foo@1.0?revision=7", "dependsOn": []interface{}{"pkg:snap/core@16.04?revision=3"}},
+	})
+}
+
+func (s *sbomSuite) TestDocumentUnknownFormat(c *C) {
+	_, err := sbom.Document("xml", nil, time.Now())
+	c.Check(err, ErrorMatches, `unknown bill of materials format "xml"`)
+}
+
+func roundTrip(c *C, doc interface{}, out interface{}) {
+	data, err := json.Marshal(doc)
+	c.Assert(err, IsNil)
+	c.Assert(json.Unmarshal(data, out), IsNil)
+}
@@ -0,0 +1,142 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package sbom
+
+import (
+	"time"
+
+	"github.com/snapcore/snapd/cmd"
+)
+
+// The SPDX 2.2 JSON representation, as far as it is used here.
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxPackage struct {
+	SPDXID           string            `json:"SPDXID"`
+	Name             string            `json:"name"`
+	VersionInfo      string            `json:"versionInfo"`
+	Supplier         string            `json:"supplier"`
+	DownloadLocation string            `json:"downloadLocation"`
+	FilesAnalyzed    bool              `json:"filesAnalyzed"`
+	Checksums        []spdxChecksum    `json:"checksums,omitempty"`
+	ExternalRefs     []spdxExternalRef `json:"externalRefs"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+type spdxDoc struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+func spdxID(name string) string {
+	return "SPDXRef-snap-" + name
+}
+
+func spdxDocument(components []*Component, created time.Time) *spdxDoc {
+	created = created.UTC()
+	doc := &spdxDoc{
+		SPDXVersion:       "SPDX-2.2",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              "installed-snaps",
+		DocumentNamespace: "https://snapcraft.io/spdx/installed-snaps-" + created.Format("20060102T150405Z"),
+		CreationInfo: spdxCreationInfo{
+			Created:  created.Format(time.RFC3339),
+			Creators: []string{"Tool: snapd-" + cmd.Version},
+		},
+		Packages:      []spdxPackage{},
+		Relationships: []spdxRelationship{},
+	}
+	included := make(map[string]bool, len(components))
+	for _, c := range components {
+		included[c.Name] = true
+	}
+	for _, c := range components {
+		pkg := spdxPackage{
+			SPDXID:           spdxID(c.Name),
+			Name:             c.Name,
+			VersionInfo:      c.Version,
+			Supplier:         "NOASSERTION",
+			DownloadLocation: "NOASSERTION",
+			ExternalRefs: []spdxExternalRef{{
+				ReferenceCategory: "PACKAGE-MANAGER",
+				ReferenceType:     "purl",
+				ReferenceLocator:  c.purl(),
+			}},
+		}
+		if c.Publisher != "" {
+			pkg.Supplier = "Organization: " + c.Publisher
+		}
+		if c.SHA256 != "" {
+			pkg.Checksums = []spdxChecksum{
+				{Algorithm: "SHA256", ChecksumValue: c.SHA256},
+				{Algorithm: "SHA512", ChecksumValue: c.SHA512},
+			}
+		}
+		for _, ref := range c.Assertions {
+			pkg.ExternalRefs = append(pkg.ExternalRefs, spdxExternalRef{
+				ReferenceCategory: "OTHER",
+				ReferenceType:     "snap-assertion",
+				ReferenceLocator:  ref,
+			})
+		}
+		doc.Packages = append(doc.Packages, pkg)
+
+		doc.Relationships = append(doc.Relationships, spdxRelationship{
+			SPDXElementID:      doc.SPDXID,
+			RelationshipType:   "DESCRIBES",
+			RelatedSPDXElement: pkg.SPDXID,
+		})
+		if included[c.Base] {
+			doc.Relationships = append(doc.Relationships, spdxRelationship{
+				SPDXElementID:      pkg.SPDXID,
+				RelationshipType:   "DEPENDS_ON",
+				RelatedSPDXElement: spdxID(c.Base),
+			})
+		}
+	}
+	return doc
+}
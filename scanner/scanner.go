@@ -0,0 +1,155 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package scanner lets a malware or static analysis scanner vet snaps
+// before they are installed, run as a command or reached over a unix
+// socket.
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// scanTimeout is how long a scan can take before the snap is rejected.
+var scanTimeout = 5 * time.Minute
+
+// Request describes the snap to scan: its file, where its content can
+// be read, and its metadata.
+type Request struct {
+	Snap        string `json:"snap"`
+	SnapID      string `json:"snap-id,omitempty"`
+	Revision    string `json:"revision"`
+	Version     string `json:"version"`
+	Type        string `json:"type"`
+	Confinement string `json:"confinement"`
+	Developer   string `json:"developer,omitempty"`
+	// Path is the snap file and MountDir the directory its content is
+	// mounted at, read-only.
+	Path     string `json:"path"`
+	MountDir string `json:"mount-dir"`
+}
+
+// Verdict is what a scanner decided about a snap.
+type Verdict struct {
+	Allow bool `json:"allow"`
+	// Reason tells why the snap was rejected.
+	Reason string `json:"reason,omitempty"`
+}
+
+// A Scanner vets snaps before they are installed.
+type Scanner interface {
+	Scan(req *Request) (*Verdict, error)
+}
+
+// New returns the scanner of the given kind: a command, given its
+// absolute path, run with the snap file and the directory of its content
+// as arguments and the request as JSON on its standard input, allowing
+// the snap by exiting with 0 and rejecting it otherwise, its output
+// being the reason; or a unix socket, given its absolute path, the
+// request is written to as a line of JSON, the verdict being read back
+// as a line of JSON.
+func New(kind, target string) (Scanner, error) {
+	switch kind {
+	case "command", "socket":
+		if !filepath.IsAbs(target) {
+			return nil, fmt.Errorf("invalid scanner %s path %q: not absolute", kind, target)
+		}
+	default:
+		return nil, fmt.Errorf("unknown scanner %q", kind)
+	}
+	if kind == "command" {
+		return &commandScanner{path: target}, nil
+	}
+	return &socketScanner{path: target}, nil
+}
+
+type commandScanner struct {
+	path string
+}
+
+func (s *commandScanner) Scan(req *Request) (*Verdict, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	var output bytes.Buffer
+	cmd := exec.Command(s.path, req.Path, req.MountDir)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("cannot run scanner: %v", err)
+	}
+	timer := time.AfterFunc(scanTimeout, func() {
+		cmd.Process.Kill()
+	})
+	err = cmd.Wait()
+	if !timer.Stop() {
+		return nil, fmt.Errorf("scanner %s timed out after %v", s.path, scanTimeout)
+	}
+	if _, ok := err.(*exec.ExitError); ok {
+		reason := strings.TrimSpace(output.String())
+		if reason == "" {
+			reason = err.Error()
+		}
+		return &Verdict{Reason: reason}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scanner %s failed: %v", s.path, err)
+	}
+	return &Verdict{Allow: true}, nil
+}
+
+type socketScanner struct {
+	path string
+}
+
+func (s *socketScanner) Scan(req *Request) (*Verdict, error) {
+	conn, err := net.DialTimeout("unix", s.path, scanTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("cannot reach scanner: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(scanTimeout))
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(append(data, '\n')); err != nil {
+		return nil, fmt.Errorf("cannot send scan request: %v", err)
+	}
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil && len(line) == 0 {
+		return nil, fmt.Errorf("cannot read scan verdict: %v", err)
+	}
+	var verdict Verdict
+	if err := json.Unmarshal(line, &verdict); err != nil {
+		return nil, fmt.Errorf("cannot decode scan verdict: %v", err)
+	}
+	return &verdict, nil
+}
@@ -0,0 +1,169 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package scanner_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/scanner"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type scannerSuite struct {
+	req *scanner.Request
+}
+
+var _ = Suite(&scannerSuite{})
+
+func (s *scannerSuite) SetUpTest(c *C) {
+	s.req = &scanner.Request{
+		Snap:        "foo",
+		Revision:    "7",
+		Version:     "1.0",
+		Type:        "app",
+		Confinement: "strict",
+		Path:        "/var/lib/snapd/snaps/foo_7.snap",
+		MountDir:    "/snap/foo/7",
+	}
+}
+
+func (s *scannerSuite) TestNewErrors(c *C) {
+	for _, t := range []struct{ kind, target, err string }{
+		{"clamav", "/usr/bin/clamscan", `unknown scanner "clamav"`},
+		{"command", "clamscan", `invalid scanner command path "clamscan": not absolute`},
+		{"socket", "scan.sock", `invalid scanner socket path "scan.sock": not absolute`},
+	} {
+		_, err := scanner.New(t.kind, t.target)
+		c.Check(err, ErrorMatches, t.err, Commentf("%s %s", t.kind, t.target))
+	}
+}
+
+func (s *scannerSuite) TestCommandAllows(c *C) {
+	dir := c.MkDir()
+	cmd := filepath.Join(dir, "scan")
+	script := "#!/bin/sh\necho \"$1 $2\" > " + dir + "/args\ncat > " + dir + "/stdin\n"
+	c.Assert(ioutil.WriteFile(cmd, []byte(script), 0755), IsNil)
+
+	sc, err := scanner.New("command", cmd)
+	c.Assert(err, IsNil)
+	verdict, err := sc.Scan(s.req)
+	c.Assert(err, IsNil)
+	c.Check(verdict, DeepEquals, &scanner.Verdict{Allow: true})
+
+	args, err := ioutil.ReadFile(filepath.Join(dir, "args"))
+	c.Assert(err, IsNil)
+	c.Check(string(args), Equals, "/var/lib/snapd/snaps/foo_7.snap /snap/foo/7\n")
+	var req scanner.Request
+	stdin, err := ioutil.ReadFile(filepath.Join(dir, "stdin"))
+	c.Assert(err, IsNil)
+	c.Assert(json.Unmarshal(stdin, &req), IsNil)
+	c.Check(&req, DeepEquals, s.req)
+}
+
+func (s *scannerSuite) TestCommandRejects(c *C) {
+	cmd := filepath.Join(c.MkDir(), "scan")
+	c.Assert(ioutil.WriteFile(cmd, []byte("#!/bin/sh\necho 'Eicar-Test-Signature FOUND'\nexit 1\n"), 0755), IsNil)
+
+	sc, err := scanner.New("command", cmd)
+	c.Assert(err, IsNil)
+	verdict, err := sc.Scan(s.req)
+	c.Assert(err, IsNil)
+	c.Check(verdict, DeepEquals, &scanner.Verdict{Reason: "Eicar-Test-Signature FOUND"})
+}
+
+func (s *scannerSuite) TestCommandTimesOut(c *C) {
+	defer scanner.MockScanTimeout(50 * time.Millisecond)()
+	cmd := filepath.Join(c.MkDir(), "scan")
+	c.Assert(ioutil.WriteFile(cmd, []byte("#!/bin/sh\nexec sleep 10\n"), 0755), IsNil)
+
+	sc, err := scanner.New("command", cmd)
+	c.Assert(err, IsNil)
+	_, err = sc.Scan(s.req)
+	c.Check(err, ErrorMatches, `scanner .*/scan timed out after 50ms`)
+}
+
+func (s *scannerSuite) TestCommandMissing(c *C) {
+	sc, err := scanner.New("command", filepath.Join(c.MkDir(), "scan"))
+	c.Assert(err, IsNil)
+	_, err = sc.Scan(s.req)
+	c.Check(err, ErrorMatches, `cannot run scanner: .*`)
+}
+
+// scanServer answers each request on the socket with the given verdict
+// and sends back the requests it got.
+func scanServer(c *C, verdict string) (path string, reqs chan *scanner.Request) {
+	path = filepath.Join(c.MkDir(), "scan.sock")
+	l, err := net.Listen("unix", path)
+	c.Assert(err, IsNil)
+	reqs = make(chan *scanner.Request, 1)
+	go func() {
+		defer l.Close()
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		line, err := bufio.NewReader(conn).ReadBytes('\n')
+		if err != nil {
+			return
+		}
+		var req scanner.Request
+		json.Unmarshal(line, &req)
+		reqs <- &req
+		conn.Write([]byte(verdict + "\n"))
+	}()
+	return path, reqs
+}
+
+func (s *scannerSuite) TestSocket(c *C) {
+	path, reqs := scanServer(c, `{"allow": false, "reason": "unsigned binary"}`)
+
+	sc, err := scanner.New("socket", path)
+	c.Assert(err, IsNil)
+	verdict, err := sc.Scan(s.req)
+	c.Assert(err, IsNil)
+	c.Check(verdict, DeepEquals, &scanner.Verdict{Reason: "unsigned binary"})
+	c.Check(<-reqs, DeepEquals, s.req)
+}
+
+func (s *scannerSuite) TestSocketBadVerdict(c *C) {
+	path, _ := scanServer(c, `ok`)
+
+	sc, err := scanner.New("socket", path)
+	c.Assert(err, IsNil)
+	_, err = sc.Scan(s.req)
+	c.Check(err, ErrorMatches, `cannot decode scan verdict: .*`)
+}
+
+func (s *scannerSuite) TestSocketUnreachable(c *C) {
+	sc, err := scanner.New("socket", filepath.Join(c.MkDir(), "scan.sock"))
+	c.Assert(err, IsNil)
+	_, err = sc.Scan(s.req)
+	c.Check(err, ErrorMatches, `cannot reach scanner: .*`)
+}
@@ -0,0 +1,88 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snap
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ReadConfigFile reads the configuration options of a snap from a
+// file, in YAML or JSON, nested as their dotted keys are. The options
+// are returned by the dotted keys of the values within them, so that
+// each is set on its own; empty maps are kept as values.
+func ReadConfigFile(path string) (map[string]interface{}, error) {
+	errorFormat := fmt.Sprintf("cannot read configuration file %q: %%s", path)
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf(errorFormat, err)
+	}
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf(errorFormat, err)
+	}
+
+	options := make(map[string]interface{})
+	names := make([]string, 0, len(raw))
+	for name := range raw {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value, err := normalizeYamlValue(raw[name])
+		if err != nil {
+			return nil, fmt.Errorf(errorFormat, fmt.Sprintf("option %q: %v", name, err))
+		}
+		if err := flattenConfig(name, value, options); err != nil {
+			return nil, fmt.Errorf(errorFormat, err)
+		}
+	}
+	return options, nil
+}
+
+// flattenConfig adds the values within the option with the given key
+// to options, by their dotted keys.
+func flattenConfig(key string, value interface{}, options map[string]interface{}) error {
+	if !validConfigKey.MatchString(key) {
+		return fmt.Errorf("invalid option name: %q", key)
+	}
+	m, ok := value.(map[string]interface{})
+	if !ok || len(m) == 0 {
+		if _, ok := options[key]; ok {
+			return fmt.Errorf("option %q given twice", key)
+		}
+		options[key] = value
+		return nil
+	}
+	for name, sub := range m {
+		if strings.Contains(name, ".") {
+			return fmt.Errorf("invalid option name: %q", key+"."+name)
+		}
+		if err := flattenConfig(key+"."+name, sub, options); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snap_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/snap"
+)
+
+type configFileSuite struct{}
+
+var _ = Suite(&configFileSuite{})
+
+func (s *configFileSuite) writeFile(c *C, content string) string {
+	path := filepath.Join(c.MkDir(), "foo.yaml")
+	c.Assert(ioutil.WriteFile(path, []byte(content), 0644), IsNil)
+	return path
+}
+
+func (s *configFileSuite) TestReadConfigFile(c *C) {
+	path := s.writeFile(c, `username: frank
+server:
+  port: 8080
+  hosts: [a, b]
+  tls: {}
+log.level: debug
+`)
+	options, err := snap.ReadConfigFile(path)
+	c.Assert(err, IsNil)
+	c.Check(options, DeepEquals, map[string]interface{}{
+		"username":     "frank",
+		"server.port":  8080,
+		"server.hosts": []interface{}{"a", "b"},
+		"server.tls":   map[string]interface{}{},
+		"log.level":    "debug",
+	})
+}
+
+func (s *configFileSuite) TestReadConfigFileJSON(c *C) {
+	path := s.writeFile(c, `{"server": {"port": 8080}, "debug": true}`)
+	options, err := snap.ReadConfigFile(path)
+	c.Assert(err, IsNil)
+	c.Check(options, DeepEquals, map[string]interface{}{
+		"server.port": 8080,
+		"debug":       true,
+	})
+}
+
+func (s *configFileSuite) TestReadConfigFileErrors(c *C) {
+	for _, t := range []struct {
+		content string
+		err     string
+	}{
+		{`- a`, `(?s).*cannot unmarshal !!seq.*`},
+		{`Bad: 1`, `invalid option name: "Bad"`},
+		{`server: {a.b: 1}`, `invalid option name: "server.a.b"`},
+		{"server: {port: 1}\nserver.port: 2", `option "server.port" given twice`},
+		{`server: {1: a}`, `option "server": non-string key 1`},
+	} {
+		path := s.writeFile(c, t.content)
+		_, err := snap.ReadConfigFile(path)
+		c.Check(err, ErrorMatches, `cannot read configuration file ".*": `+t.err, Commentf(t.content))
+	}
+
+	_, err := snap.ReadConfigFile(filepath.Join(c.MkDir(), "missing.yaml"))
+	c.Check(err, ErrorMatches, `cannot read configuration file ".*": open .*: no such file or directory`)
+}
@@ -0,0 +1,306 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snap
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// validConfigKey is the form of the dotted keys of configuration
+// options, as snapd handles them.
+var validConfigKey = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*(\.[a-z0-9]+(-[a-z0-9]+)*)*$`)
+
+type configSchemaYaml struct {
+	Options map[string]*ConfigOption `yaml:"options,omitempty"`
+}
+
+// ConfigOption describes a configuration option of a snap in its
+// schema.
+type ConfigOption struct {
+	// Type is the type of the values of the option: "string",
+	// "integer", "number", "boolean", "array" or "object". Any option
+	// within an object option is accepted.
+	Type string `yaml:"type"`
+	// Enum, if set, are the only values the option can have.
+	Enum []interface{} `yaml:"enum,omitempty"`
+	// Minimum and Maximum, if set, bound the values of an integer or
+	// number option.
+	Minimum *float64 `yaml:"minimum,omitempty"`
+	Maximum *float64 `yaml:"maximum,omitempty"`
+	// Description tells what the option is for.
+	Description string `yaml:"description,omitempty"`
+}
+
+// ConfigSchema holds the configuration options a snap declares in its
+// meta/config-schema.yaml, keyed by their dotted keys.
+type ConfigSchema struct {
+	Options map[string]*ConfigOption
+}
+
+var configTypes = map[string]string{
+	"string":  "a string",
+	"integer": "an integer",
+	"number":  "a number",
+	"boolean": "a boolean",
+	"array":   "an array",
+	"object":  "an object",
+}
+
+// ReadConfigSchema reads the meta/config-schema.yaml of the snap
+// unpacked in the given directory. A snap without one has no schema,
+// and nil is returned.
+func ReadConfigSchema(snapDir string) (*ConfigSchema, error) {
+	const errorFormat = "cannot read configuration schema: %s"
+
+	data, err := ioutil.ReadFile(filepath.Join(snapDir, "meta", "config-schema.yaml"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf(errorFormat, err)
+	}
+
+	var sy configSchemaYaml
+	if err := yaml.Unmarshal(data, &sy); err != nil {
+		return nil, fmt.Errorf(errorFormat, err)
+	}
+
+	schema := &ConfigSchema{Options: make(map[string]*ConfigOption, len(sy.Options))}
+	for key, opt := range sy.Options {
+		if opt == nil {
+			opt = &ConfigOption{}
+		}
+		if err := validateConfigOption(key, opt); err != nil {
+			return nil, fmt.Errorf(errorFormat, err)
+		}
+		schema.Options[key] = opt
+	}
+	for key, opt := range schema.Options {
+		if parent := schema.objectParent(key); parent != "" {
+			return nil, fmt.Errorf(errorFormat, fmt.Sprintf("option %q is within object option %q", key, parent))
+		}
+		if opt.Type != "object" && schema.hasOptionsUnder(key) {
+			return nil, fmt.Errorf(errorFormat, fmt.Sprintf("option %q has options within it but is not an object", key))
+		}
+	}
+	return schema, nil
+}
+
+// validateConfigOption checks the declaration of the option with the
+// given dotted key.
+func validateConfigOption(key string, opt *ConfigOption) error {
+	if !validConfigKey.MatchString(key) {
+		return fmt.Errorf("invalid option name: %q", key)
+	}
+	if _, ok := configTypes[opt.Type]; !ok {
+		return fmt.Errorf("option %q has invalid type %q", key, opt.Type)
+	}
+	numeric := opt.Type == "integer" || opt.Type == "number"
+	if (opt.Minimum != nil || opt.Maximum != nil) && !numeric {
+		return fmt.Errorf("option %q cannot have a minimum or maximum as it is not a number", key)
+	}
+	if opt.Minimum != nil && opt.Maximum != nil && *opt.Minimum > *opt.Maximum {
+		return fmt.Errorf("option %q has a minimum greater than its maximum", key)
+	}
+	if len(opt.Enum) > 0 && !numeric && opt.Type != "string" {
+		return fmt.Errorf("option %q cannot have enum values as it is %s", key, configTypes[opt.Type])
+	}
+	for _, value := range opt.Enum {
+		if err := opt.checkType(key, value); err != nil {
+			return fmt.Errorf("enum value %v of option %q is not %s", value, key, configTypes[opt.Type])
+		}
+	}
+	return nil
+}
+
+// objectParent returns the object option the option with the given
+// dotted key is within, if any.
+func (s *ConfigSchema) objectParent(key string) string {
+	parts := strings.Split(key, ".")
+	for i := 1; i < len(parts); i++ {
+		parent := strings.Join(parts[:i], ".")
+		if opt, ok := s.Options[parent]; ok && opt.Type == "object" {
+			return parent
+		}
+	}
+	return ""
+}
+
+// hasOptionsUnder returns whether options are declared within the one
+// with the given dotted key.
+func (s *ConfigSchema) hasOptionsUnder(key string) bool {
+	for other := range s.Options {
+		if strings.HasPrefix(other, key+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks that the option of the snap with the given dotted
+// key can be set to value, which is decoded from JSON with numbers as
+// json.Number, or unset if it is nil. Options the schema does not
+// declare cannot be set, and a map value is checked option by option.
+func (s *ConfigSchema) Validate(key string, value interface{}) error {
+	if value == nil {
+		// unsetting cleans up whatever was set
+		return nil
+	}
+
+	parts := strings.Split(key, ".")
+	for i := 1; i < len(parts); i++ {
+		parent := strings.Join(parts[:i], ".")
+		if opt, ok := s.Options[parent]; ok {
+			if opt.Type == "object" {
+				return nil
+			}
+			return fmt.Errorf("option %q must be %s", parent, configTypes[opt.Type])
+		}
+	}
+
+	if opt, ok := s.Options[key]; ok {
+		return opt.check(key, value)
+	}
+	if m, ok := value.(map[string]interface{}); ok && s.hasOptionsUnder(key) {
+		subkeys := make([]string, 0, len(m))
+		for subkey := range m {
+			subkeys = append(subkeys, subkey)
+		}
+		sort.Strings(subkeys)
+		for _, subkey := range subkeys {
+			if err := s.Validate(key+"."+subkey, m[subkey]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown option %q", key)
+}
+
+// number returns the value of a number, as decoded from JSON or YAML.
+func number(value interface{}) (f float64, integer bool, ok bool) {
+	switch x := value.(type) {
+	case json.Number:
+		if _, err := x.Int64(); err == nil {
+			f, err := x.Float64()
+			return f, true, err == nil
+		}
+		f, err := x.Float64()
+		return f, f == float64(int64(f)), err == nil
+	case int:
+		return float64(x), true, true
+	case int64:
+		return float64(x), true, true
+	case float64:
+		return x, x == float64(int64(x)), true
+	}
+	return 0, false, false
+}
+
+// checkType checks that value has the type of the option with the
+// given dotted key.
+func (opt *ConfigOption) checkType(key string, value interface{}) error {
+	ok := false
+	switch opt.Type {
+	case "string":
+		_, ok = value.(string)
+	case "boolean":
+		_, ok = value.(bool)
+	case "integer":
+		_, integer, isNumber := number(value)
+		ok = isNumber && integer
+	case "number":
+		_, _, ok = number(value)
+	case "array":
+		_, ok = value.([]interface{})
+	case "object":
+		_, ok = value.(map[string]interface{})
+	}
+	if !ok {
+		return fmt.Errorf("option %q must be %s", key, configTypes[opt.Type])
+	}
+	return nil
+}
+
+// check checks that the option with the given dotted key can have the
+// given value.
+func (opt *ConfigOption) check(key string, value interface{}) error {
+	if err := opt.checkType(key, value); err != nil {
+		return err
+	}
+
+	if len(opt.Enum) > 0 {
+		found := false
+		for _, allowed := range opt.Enum {
+			if sameConfigValue(allowed, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			allowed := make([]string, len(opt.Enum))
+			for i, v := range opt.Enum {
+				allowed[i] = formatConfigValue(v)
+			}
+			return fmt.Errorf("option %q must be one of %s", key, strings.Join(allowed, ", "))
+		}
+	}
+
+	f, _, _ := number(value)
+	if opt.Minimum != nil && f < *opt.Minimum {
+		return fmt.Errorf("option %q must be at least %s", key, formatConfigValue(*opt.Minimum))
+	}
+	if opt.Maximum != nil && f > *opt.Maximum {
+		return fmt.Errorf("option %q must be at most %s", key, formatConfigValue(*opt.Maximum))
+	}
+	return nil
+}
+
+// sameConfigValue returns whether the two string or number values are
+// the same, whether decoded from JSON or YAML.
+func sameConfigValue(a, b interface{}) bool {
+	fa, _, aNumber := number(a)
+	fb, _, bNumber := number(b)
+	if aNumber || bNumber {
+		return aNumber && bNumber && fa == fb
+	}
+	return a == b
+}
+
+// formatConfigValue formats a string or number value for errors.
+func formatConfigValue(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return strconv.Quote(s)
+	}
+	if f, _, ok := number(v); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprintf("%v", v)
+}
@@ -0,0 +1,152 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snap_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/snap"
+)
+
+type configSchemaSuite struct {
+	dir string
+}
+
+var _ = Suite(&configSchemaSuite{})
+
+func (s *configSchemaSuite) SetUpTest(c *C) {
+	s.dir = c.MkDir()
+	c.Assert(os.MkdirAll(filepath.Join(s.dir, "meta"), 0755), IsNil)
+}
+
+func (s *configSchemaSuite) writeSchema(c *C, content string) {
+	err := ioutil.WriteFile(filepath.Join(s.dir, "meta", "config-schema.yaml"), []byte(content), 0644)
+	c.Assert(err, IsNil)
+}
+
+const testConfigSchema = `options:
+  port:
+    type: integer
+    minimum: 1
+    maximum: 65535
+  ratio:
+    type: number
+    maximum: 1.5
+  log-level:
+    type: string
+    enum: [debug, info, warn]
+  debug:
+    type: boolean
+  servers:
+    type: array
+  server.name:
+    type: string
+  extra:
+    type: object
+`
+
+func (s *configSchemaSuite) TestReadConfigSchemaMissing(c *C) {
+	schema, err := snap.ReadConfigSchema(s.dir)
+	c.Assert(err, IsNil)
+	c.Check(schema, IsNil)
+}
+
+func (s *configSchemaSuite) TestReadConfigSchema(c *C) {
+	s.writeSchema(c, testConfigSchema)
+
+	schema, err := snap.ReadConfigSchema(s.dir)
+	c.Assert(err, IsNil)
+	c.Check(schema.Options, HasLen, 7)
+	c.Check(schema.Options["port"].Type, Equals, "integer")
+	c.Check(*schema.Options["port"].Maximum, Equals, float64(65535))
+	c.Check(schema.Options["log-level"].Enum, DeepEquals, []interface{}{"debug", "info", "warn"})
+}
+
+func (s *configSchemaSuite) TestReadConfigSchemaErrors(c *C) {
+	for _, t := range []struct {
+		yaml string
+		err  string
+	}{
+		{"options: [", `cannot read configuration schema: yaml: .*`},
+		{"options:\n  Port:\n    type: integer\n", `cannot read configuration schema: invalid option name: "Port"`},
+		{"options:\n  port:\n    type: int\n", `cannot read configuration schema: option "port" has invalid type "int"`},
+		{"options:\n  port:\n", `cannot read configuration schema: option "port" has invalid type ""`},
+		{"options:\n  name:\n    type: string\n    minimum: 1\n", `cannot read configuration schema: option "name" cannot have a minimum or maximum as it is not a number`},
+		{"options:\n  port:\n    type: integer\n    minimum: 2\n    maximum: 1\n", `cannot read configuration schema: option "port" has a minimum greater than its maximum`},
+		{"options:\n  debug:\n    type: boolean\n    enum: [true]\n", `cannot read configuration schema: option "debug" cannot have enum values as it is a boolean`},
+		{"options:\n  port:\n    type: integer\n    enum: [1, x]\n", `cannot read configuration schema: enum value x of option "port" is not an integer`},
+		{"options:\n  a:\n    type: object\n  a.b:\n    type: string\n", `cannot read configuration schema: option "a.b" is within object option "a"`},
+		{"options:\n  a:\n    type: string\n  a.b:\n    type: string\n", `cannot read configuration schema: option "a" has options within it but is not an object`},
+	} {
+		s.writeSchema(c, t.yaml)
+		_, err := snap.ReadConfigSchema(s.dir)
+		c.Check(err, ErrorMatches, t.err, Commentf(t.yaml))
+	}
+}
+
+func (s *configSchemaSuite) TestValidate(c *C) {
+	s.writeSchema(c, testConfigSchema)
+	schema, err := snap.ReadConfigSchema(s.dir)
+	c.Assert(err, IsNil)
+
+	for _, t := range []struct {
+		key   string
+		value interface{}
+		err   string
+	}{
+		{"port", json.Number("8080"), ""},
+		{"port", json.Number("0"), `option "port" must be at least 1`},
+		{"port", json.Number("70000"), `option "port" must be at most 65535`},
+		{"port", json.Number("80.5"), `option "port" must be an integer`},
+		{"port", "80", `option "port" must be an integer`},
+		{"port.x", json.Number("1"), `option "port" must be an integer`},
+		{"prot", json.Number("80"), `unknown option "prot"`},
+		{"ratio", json.Number("0.5"), ""},
+		{"ratio", json.Number("2"), `option "ratio" must be at most 1.5`},
+		{"log-level", "info", ""},
+		{"log-level", "verbose", `option "log-level" must be one of "debug", "info", "warn"`},
+		{"debug", true, ""},
+		{"debug", "yes", `option "debug" must be a boolean`},
+		{"servers", []interface{}{"a", "b"}, ""},
+		{"servers", "a", `option "servers" must be an array`},
+		{"server.name", "x", ""},
+		{"server", map[string]interface{}{"name": "x"}, ""},
+		{"server", map[string]interface{}{"nmae": "x"}, `unknown option "server.nmae"`},
+		{"server", map[string]interface{}{"name": true}, `option "server.name" must be a string`},
+		{"server", "x", `unknown option "server"`},
+		{"extra", map[string]interface{}{"anything": "goes"}, ""},
+		{"extra.anything.deep", json.Number("1"), ""},
+		{"extra", "x", `option "extra" must be an object`},
+		// unsetting is always fine
+		{"prot", nil, ""},
+	} {
+		err := schema.Validate(t.key, t.value)
+		if t.err == "" {
+			c.Check(err, IsNil, Commentf("%s=%v", t.key, t.value))
+		} else {
+			c.Check(err, ErrorMatches, t.err, Commentf("%s=%v", t.key, t.value))
+		}
+	}
+}
@@ -0,0 +1,106 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2014-2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snap
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// NetworkInterface is the name of the interface giving snaps network
+// access as clients.
+const NetworkInterface = "network"
+
+// The ways name resolution of a snap can be set up, with the dns attribute
+// of its network plug.
+const (
+	// DNSHost resolves names like the host does, the default.
+	DNSHost = "host"
+	// DNSStub resolves names through a stub resolver dedicated to the
+	// snap, which logs the queries and forwards them to the resolvers of
+	// the host.
+	DNSStub = "stub"
+	// DNSLink resolves names like DNSStub, but forwards the queries to
+	// the resolvers that systemd-resolved uses for the network link
+	// given by the dns-link attribute.
+	DNSLink = "link"
+)
+
+// DNSSettings describes how names are resolved for a snap.
+type DNSSettings struct {
+	Mode string
+	Link string
+}
+
+// network interface names, as limited by the kernel
+var validDNSLink = regexp.MustCompile(`^[a-zA-Z0-9_.:-]{1,15}$`)
+
+// PlugDNS returns the name resolution settings given by the attributes of
+// a network plug.
+func PlugDNS(plug *PlugInfo) (*DNSSettings, error) {
+	settings := &DNSSettings{Mode: DNSHost}
+	if v, ok := plug.Attrs["dns"]; ok {
+		mode, ok := v.(string)
+		if !ok || (mode != DNSHost && mode != DNSStub && mode != DNSLink) {
+			return nil, fmt.Errorf("dns attribute must be one of %q, %q or %q, got %v", DNSHost, DNSStub, DNSLink, v)
+		}
+		settings.Mode = mode
+	}
+	v, ok := plug.Attrs["dns-link"]
+	switch {
+	case settings.Mode == DNSLink && !ok:
+		return nil, fmt.Errorf("dns-link attribute is required when dns is %q", DNSLink)
+	case settings.Mode != DNSLink && ok:
+		return nil, fmt.Errorf("dns-link attribute can only be used when dns is %q", DNSLink)
+	case ok:
+		link, ok := v.(string)
+		if !ok || !validDNSLink.MatchString(link) {
+			return nil, fmt.Errorf("dns-link attribute must be a network interface name, got %v", v)
+		}
+		settings.Link = link
+	}
+	return settings, nil
+}
+
+// DNS returns the name resolution settings of the snap, given by its
+// network plugs. Plugs with different settings are an error.
+func (s *Info) DNS() (*DNSSettings, error) {
+	names := make([]string, 0, len(s.Plugs))
+	for name, plug := range s.Plugs {
+		if plug.Interface == NetworkInterface {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	settings := &DNSSettings{Mode: DNSHost}
+	for i, name := range names {
+		plugSettings, err := PlugDNS(s.Plugs[name])
+		if err != nil {
+			return nil, fmt.Errorf("cannot use plug %q: %v", name, err)
+		}
+		if i > 0 && *plugSettings != *settings {
+			return nil, fmt.Errorf("network plugs of snap %q have different dns settings", s.Name())
+		}
+		settings = plugSettings
+	}
+	return settings, nil
+}
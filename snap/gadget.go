@@ -0,0 +1,123 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snap
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+)
+
+type gadgetYaml struct {
+	Defaults     map[string]map[string]interface{} `yaml:"defaults,omitempty"`
+	StateBackend string                            `yaml:"state-backend,omitempty"`
+}
+
+// GadgetInfo holds the device setup provided by a gadget snap in its
+// meta/gadget.yaml.
+type GadgetInfo struct {
+	// Defaults maps snap names to the configuration options they are
+	// given when they are seeded.
+	Defaults map[string]map[string]interface{}
+
+	// StateBackend is how snapd keeps its state on the device, chosen
+	// when the state is created: "json", the default, rewrites a file
+	// each time, and "log" appends the changes to a log instead.
+	StateBackend string
+}
+
+// ReadGadgetInfo reads the meta/gadget.yaml of the gadget snap
+// unpacked in the given directory. A gadget without a gadget.yaml
+// provides nothing.
+func ReadGadgetInfo(gadgetDir string) (*GadgetInfo, error) {
+	const errorFormat = "cannot read gadget snap details: %s"
+
+	gmeta, err := ioutil.ReadFile(filepath.Join(gadgetDir, "meta", "gadget.yaml"))
+	if os.IsNotExist(err) {
+		return &GadgetInfo{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf(errorFormat, err)
+	}
+
+	var gy gadgetYaml
+	if err := yaml.Unmarshal(gmeta, &gy); err != nil {
+		return nil, fmt.Errorf(errorFormat, err)
+	}
+
+	info := &GadgetInfo{StateBackend: gy.StateBackend}
+	switch gy.StateBackend {
+	case "", "json", "log":
+	default:
+		return nil, fmt.Errorf(errorFormat, fmt.Sprintf("invalid state-backend %q", gy.StateBackend))
+	}
+	for snapName, options := range gy.Defaults {
+		if err := ValidateName(snapName); err != nil {
+			return nil, fmt.Errorf(errorFormat, fmt.Sprintf("defaults of %q: %v", snapName, err))
+		}
+		normalized := make(map[string]interface{}, len(options))
+		for key, value := range options {
+			if normalized[key], err = normalizeYamlValue(value); err != nil {
+				return nil, fmt.Errorf(errorFormat, fmt.Sprintf("defaults of %q: option %q: %v", snapName, key, err))
+			}
+		}
+		if info.Defaults == nil {
+			info.Defaults = make(map[string]map[string]interface{})
+		}
+		info.Defaults[snapName] = normalized
+	}
+
+	return info, nil
+}
+
+// normalizeYamlValue turns the maps decoded from yaml into maps with
+// string keys, as used by the configuration of snaps.
+func normalizeYamlValue(v interface{}) (interface{}, error) {
+	switch x := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(x))
+		for k, item := range x {
+			key, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("non-string key %v", k)
+			}
+			value, err := normalizeYamlValue(item)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = value
+		}
+		return m, nil
+	case []interface{}:
+		l := make([]interface{}, len(x))
+		for i, item := range x {
+			value, err := normalizeYamlValue(item)
+			if err != nil {
+				return nil, err
+			}
+			l[i] = value
+		}
+		return l, nil
+	}
+	return v, nil
+}
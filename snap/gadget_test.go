@@ -0,0 +1,107 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2014-2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snap_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/snap"
+)
+
+type gadgetYamlTestSuite struct {
+	dir string
+}
+
+var _ = Suite(&gadgetYamlTestSuite{})
+
+func (s *gadgetYamlTestSuite) SetUpTest(c *C) {
+	s.dir = c.MkDir()
+	c.Assert(os.MkdirAll(filepath.Join(s.dir, "meta"), 0755), IsNil)
+}
+
+func (s *gadgetYamlTestSuite) writeGadgetYaml(c *C, content string) {
+	err := ioutil.WriteFile(filepath.Join(s.dir, "meta", "gadget.yaml"), []byte(content), 0644)
+	c.Assert(err, IsNil)
+}
+
+func (s *gadgetYamlTestSuite) TestReadGadgetInfoMissing(c *C) {
+	info, err := snap.ReadGadgetInfo(s.dir)
+	c.Assert(err, IsNil)
+	c.Check(info.Defaults, HasLen, 0)
+}
+
+func (s *gadgetYamlTestSuite) TestReadGadgetInfoDefaults(c *C) {
+	s.writeGadgetYaml(c, `defaults:
+  foo:
+    port: 8080
+    server:
+      name: example
+      aliases: [a, b]
+  core:
+    store-certs:
+      corp: data
+`)
+
+	info, err := snap.ReadGadgetInfo(s.dir)
+	c.Assert(err, IsNil)
+	c.Check(info.Defaults, DeepEquals, map[string]map[string]interface{}{
+		"foo": {
+			"port": 8080,
+			"server": map[string]interface{}{
+				"name":    "example",
+				"aliases": []interface{}{"a", "b"},
+			},
+		},
+		"core": {
+			"store-certs": map[string]interface{}{"corp": "data"},
+		},
+	})
+}
+
+func (s *gadgetYamlTestSuite) TestReadGadgetInfoStateBackend(c *C) {
+	info, err := snap.ReadGadgetInfo(s.dir)
+	c.Assert(err, IsNil)
+	c.Check(info.StateBackend, Equals, "")
+
+	s.writeGadgetYaml(c, "state-backend: log\n")
+	info, err = snap.ReadGadgetInfo(s.dir)
+	c.Assert(err, IsNil)
+	c.Check(info.StateBackend, Equals, "log")
+}
+
+func (s *gadgetYamlTestSuite) TestReadGadgetInfoErrors(c *C) {
+	for _, t := range []struct {
+		yaml string
+		err  string
+	}{
+		{"defaults: [", `cannot read gadget snap details: yaml: .*`},
+		{"defaults:\n  Foo_Bar:\n    a: 1\n", `cannot read gadget snap details: defaults of "Foo_Bar": invalid snap name: "Foo_Bar"`},
+		{"defaults:\n  foo:\n    a:\n      1: x\n", `cannot read gadget snap details: defaults of "foo": option "a": non-string key 1`},
+		{"state-backend: sqlite\n", `cannot read gadget snap details: invalid state-backend "sqlite"`},
+	} {
+		s.writeGadgetYaml(c, t.yaml)
+		_, err := snap.ReadGadgetInfo(s.dir)
+		c.Check(err, ErrorMatches, t.err, Commentf(t.yaml))
+	}
+}
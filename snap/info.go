@@ -24,6 +24,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/snapcore/snapd/dirs"
@@ -118,6 +119,10 @@ type Info struct {
 	// The information in all the remaining fields is not sourced from the snap blob itself.
 	SideInfo
 
+	// ReadOnlyData is whether the system data of the revision was
+	// sealed read-only by snapd.
+	ReadOnlyData bool
+
 	// The information in these fields is ephemeral, available only from the store.
 	AnonDownloadURL string
 	DownloadURL     string
@@ -125,6 +130,37 @@ type Info struct {
 	IconURL string
 	Prices  map[string]float64 `yaml:"prices,omitempty" json:"prices,omitempty"`
 	MustBuy bool
+
+	// RolloutPercentage is the share of devices a progressive
+	// release of this revision is offered to; it is zero for releases
+	// offered to all devices.
+	RolloutPercentage float64
+
+	// DeltaSize is the size of the delta the store offers to get
+	// this revision from the installed one; it is zero if there is
+	// no such delta.
+	DeltaSize int64
+
+	// ReleaseNotesURL points to the publisher notes for this revision.
+	ReleaseNotesURL string
+
+	// Publisher is the display name of the publisher of the snap.
+	Publisher string
+	// License is the SPDX license expression of the snap.
+	License string
+	// Screenshots are the URLs of the screenshots of the snap.
+	Screenshots []string
+	// Channels maps the channels the snap is released to, for the
+	// architecture of the system, to what they offer.
+	Channels map[string]*ChannelSnapInfo
+}
+
+// ChannelSnapInfo is what a channel of a snap offers in the store.
+type ChannelSnapInfo struct {
+	Revision    Revision        `json:"revision"`
+	Version     string          `json:"version"`
+	Confinement ConfinementType `json:"confinement"`
+	Size        int64           `json:"size,omitempty"`
 }
 
 // Name returns the blessed name for the snap.
@@ -186,6 +222,66 @@ func (s *Info) CommonDataHomeDir() string {
 	return filepath.Join(dirs.SnapDataHomeGlob, s.Name(), "common")
 }
 
+// HostTmpInterface is the name of the interface through which snaps
+// share the /tmp of the host instead of getting a private one.
+const HostTmpInterface = "host-tmp"
+
+// UsesHostTmp returns true if the snap shares the /tmp of the host, that
+// is if it has a plug of the host-tmp interface.
+func (s *Info) UsesHostTmp() bool {
+	for _, plug := range s.Plugs {
+		if plug.Interface == HostTmpInterface {
+			return true
+		}
+	}
+	return false
+}
+
+// PublisherDataInterface is the name of the interface through which snaps
+// of the same publisher share data.
+const PublisherDataInterface = "publisher-data"
+
+// UsesPublisherData returns true if the snap shares data with the other
+// snaps of its publisher, that is if it has a plug or slot of the
+// publisher-data interface and a known publisher.
+func (s *Info) UsesPublisherData() bool {
+	if s.Developer == "" {
+		return false
+	}
+	for _, plug := range s.Plugs {
+		if plug.Interface == PublisherDataInterface {
+			return true
+		}
+	}
+	for _, slot := range s.Slots {
+		if slot.Interface == PublisherDataInterface {
+			return true
+		}
+	}
+	return false
+}
+
+// PublisherDataDir returns the data directory common to all the snaps of
+// the publisher of the snap that use publisher data.
+func (s *Info) PublisherDataDir() string {
+	return filepath.Join(dirs.SnapPublisherDataDir, s.Developer)
+}
+
+// LicenseAgreementExplicit is the license-agreement of snaps whose
+// license must be accepted before their services are started.
+const LicenseAgreementExplicit = "explicit"
+
+// NeedsLicenseAgreement returns true if the license of the snap must be
+// explicitly accepted.
+func (s *Info) NeedsLicenseAgreement() bool {
+	return s.LicenseAgreement == LicenseAgreementExplicit
+}
+
+// LicenseFile returns the path of the license text shipped with the snap.
+func (s *Info) LicenseFile() string {
+	return filepath.Join(s.MountDir(), "meta", "license.txt")
+}
+
 // sanity check that Info is a PlaceInfo
 var _ PlaceInfo = (*Info)(nil)
 
@@ -218,6 +314,9 @@ type AppInfo struct {
 
 	Name    string
 	Command string
+	// CommandChain lists helpers, relative to the snap, that are run
+	// in order before the app commands, each exec'ing the next.
+	CommandChain []string
 
 	Daemon          string
 	StopTimeout     timeout.Timeout
@@ -229,6 +328,29 @@ type AppInfo struct {
 	SocketMode   string
 	ListenStream string
 
+	// After, Before and Requires list host units (from a fixed
+	// whitelist) the service is ordered against or depends on. After
+	// and Before may also name other services of the same snap.
+	After    []string
+	Before   []string
+	Requires []string
+
+	// RefreshMode is either "restart" (the default: the service is
+	// stopped for the whole refresh) or "endure" (the service, and its
+	// socket, keep running until the new revision is linked).
+	RefreshMode string
+
+	// PassthroughEnv lists the variables of the environment of the
+	// service manager that the service gets, on top of the snap ones.
+	PassthroughEnv []string
+
+	// OOMScoreAdjust, Nice and IONiceClass tune how the service is
+	// treated under memory, CPU and IO pressure; zero values leave the
+	// system defaults alone.
+	OOMScoreAdjust int
+	Nice           int
+	IONiceClass    string
+
 	// TODO: this should go away once we have more plumbing and can change
 	// things vs refactor
 	// https://github.com/snapcore/snapd/pull/794#discussion_r58688496
@@ -270,8 +392,18 @@ func (app *AppInfo) WrapperPath() string {
 
 func (app *AppInfo) launcherCommand(command string) string {
 	securityTag := app.SecurityTag()
-	return fmt.Sprintf("/usr/bin/ubuntu-core-launcher %s %s %s", securityTag, securityTag, filepath.Join(app.Snap.MountDir(), command))
+	return fmt.Sprintf("/usr/bin/ubuntu-core-launcher %s %s %s", securityTag, securityTag, strings.Join(app.CommandWithChain(command), " "))
+
+}
 
+// CommandWithChain returns the full paths of the helpers in the command
+// chain of the app followed by that of the given app command.
+func (app *AppInfo) CommandWithChain(command string) []string {
+	cmd := make([]string, 0, len(app.CommandChain)+1)
+	for _, helper := range app.CommandChain {
+		cmd = append(cmd, filepath.Join(app.Snap.MountDir(), helper))
+	}
+	return append(cmd, filepath.Join(app.Snap.MountDir(), command))
 }
 
 // LauncherCommand returns the launcher command line to use when invoking the app binary.
@@ -299,6 +431,100 @@ func (app *AppInfo) ServiceSocketFile() string {
 	return filepath.Join(dirs.SnapServicesDir, app.SecurityTag()+".socket")
 }
 
+// siblingService returns the other service of the same snap called name, if any.
+func (app *AppInfo) siblingService(name string) *AppInfo {
+	if app.Snap == nil || name == app.Name {
+		return nil
+	}
+	other := app.Snap.Apps[name]
+	if other == nil || other.Daemon == "" {
+		return nil
+	}
+	return other
+}
+
+// Services returns the apps of the snap that are services, sorted by name.
+func (s *Info) Services() []*AppInfo {
+	var svcs []*AppInfo
+	for _, app := range s.Apps {
+		if app.Daemon != "" {
+			svcs = append(svcs, app)
+		}
+	}
+	sort.Sort(byAppName(svcs))
+	return svcs
+}
+
+type byAppName []*AppInfo
+
+func (a byAppName) Len() int           { return len(a) }
+func (a byAppName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byAppName) Less(i, j int) bool { return a[i].Name < a[j].Name }
+
+// SortServices orders the given services so that every service comes
+// after the services of the same snap it declares to be after, and
+// before those it declares to be before; services are started in the
+// returned order and stopped in the reverse one. Ties are broken by
+// name. It returns an error if the ordering contains a cycle.
+func SortServices(apps []*AppInfo) ([]*AppInfo, error) {
+	sorted := make([]*AppInfo, len(apps))
+	copy(sorted, apps)
+	sort.Sort(byAppName(sorted))
+
+	known := make(map[*AppInfo]bool, len(sorted))
+	for _, app := range sorted {
+		known[app] = true
+	}
+	// predecessors[app] lists the services that must be started before app
+	predecessors := make(map[*AppInfo][]*AppInfo, len(sorted))
+	for _, app := range sorted {
+		for _, name := range app.After {
+			if other := app.siblingService(name); known[other] {
+				predecessors[app] = append(predecessors[app], other)
+			}
+		}
+		for _, name := range app.Before {
+			if other := app.siblingService(name); known[other] {
+				predecessors[other] = append(predecessors[other], app)
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	marks := make(map[*AppInfo]int, len(sorted))
+	ordered := make([]*AppInfo, 0, len(sorted))
+	var visit func(app *AppInfo) error
+	visit = func(app *AppInfo) error {
+		switch marks[app] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("cannot order services: cycle involving %q", app.Name)
+		}
+		marks[app] = visiting
+		preds := predecessors[app]
+		sort.Sort(byAppName(preds))
+		for _, pred := range preds {
+			if err := visit(pred); err != nil {
+				return err
+			}
+		}
+		marks[app] = visited
+		ordered = append(ordered, app)
+		return nil
+	}
+	for _, app := range sorted {
+		if err := visit(app); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
 func copyEnv(in map[string]string) map[string]string {
 	out := make(map[string]string)
 	for k, v := range in {
@@ -83,6 +83,20 @@ type appYaml struct {
 	Socket       bool   `yaml:"socket,omitempty"`
 	ListenStream string `yaml:"listen-stream,omitempty"`
 	SocketMode   string `yaml:"socket-mode,omitempty"`
+
+	After    []string `yaml:"after,omitempty"`
+	Before   []string `yaml:"before,omitempty"`
+	Requires []string `yaml:"requires,omitempty"`
+
+	RefreshMode string `yaml:"refresh-mode,omitempty"`
+
+	CommandChain []string `yaml:"command-chain,omitempty"`
+
+	PassthroughEnv []string `yaml:"passthrough-env,omitempty"`
+
+	OOMScoreAdjust int    `yaml:"oom-score-adjust,omitempty"`
+	Nice           int    `yaml:"nice,omitempty"`
+	IONiceClass    string `yaml:"ionice-class,omitempty"`
 }
 
 type hookYaml struct {
@@ -249,6 +263,15 @@ func setAppsFromSnapYaml(y snapYaml, snap *Info) {
 			ListenStream:    yApp.ListenStream,
 			BusName:         yApp.BusName,
 			Environment:     yApp.Environment,
+			After:           yApp.After,
+			Before:          yApp.Before,
+			Requires:        yApp.Requires,
+			RefreshMode:     yApp.RefreshMode,
+			CommandChain:    yApp.CommandChain,
+			PassthroughEnv:  yApp.PassthroughEnv,
+			OOMScoreAdjust:  yApp.OOMScoreAdjust,
+			Nice:            yApp.Nice,
+			IONiceClass:     yApp.IONiceClass,
 		}
 		if len(y.Plugs) > 0 || len(yApp.PlugNames) > 0 {
 			app.Plugs = make(map[string]*PlugInfo)
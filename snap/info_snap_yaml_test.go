@@ -1234,3 +1234,78 @@ apps:
 		"k2": "v2",
 	})
 }
+
+func (s *YamlSuite) TestSnapYamlHostUnitOrdering(c *C) {
+	y := []byte(`
+name: foo
+version: 1.0
+apps:
+ svc:
+  daemon: simple
+  after: [network-online.target]
+  before: [sound.target]
+  requires: [time-sync.target]
+`)
+	info, err := snap.InfoFromSnapYaml(y)
+	c.Assert(err, IsNil)
+	app := info.Apps["svc"]
+	c.Check(app.After, DeepEquals, []string{"network-online.target"})
+	c.Check(app.Before, DeepEquals, []string{"sound.target"})
+	c.Check(app.Requires, DeepEquals, []string{"time-sync.target"})
+}
+
+func (s *YamlSuite) TestSnapYamlSchedulingFields(c *C) {
+	y := []byte(`
+name: foo
+version: 1.0
+apps:
+ backup:
+  daemon: simple
+  oom-score-adjust: 500
+  nice: 10
+  ionice-class: idle
+ db:
+  daemon: simple
+`)
+	info, err := snap.InfoFromSnapYaml(y)
+	c.Assert(err, IsNil)
+	app := info.Apps["backup"]
+	c.Check(app.OOMScoreAdjust, Equals, 500)
+	c.Check(app.Nice, Equals, 10)
+	c.Check(app.IONiceClass, Equals, "idle")
+	app = info.Apps["db"]
+	c.Check(app.OOMScoreAdjust, Equals, 0)
+	c.Check(app.Nice, Equals, 0)
+	c.Check(app.IONiceClass, Equals, "")
+}
+
+func (s *YamlSuite) TestSnapYamlPassthroughEnv(c *C) {
+	y := []byte(`
+name: foo
+version: 1.0
+apps:
+ fetcher:
+  daemon: simple
+  passthrough-env: [http_proxy, https_proxy]
+ db:
+  daemon: simple
+`)
+	info, err := snap.InfoFromSnapYaml(y)
+	c.Assert(err, IsNil)
+	c.Check(info.Apps["fetcher"].PassthroughEnv, DeepEquals, []string{"http_proxy", "https_proxy"})
+	c.Check(info.Apps["db"].PassthroughEnv, HasLen, 0)
+}
+
+func (s *YamlSuite) TestSnapYamlRefreshMode(c *C) {
+	y := []byte(`
+name: foo
+version: 1.0
+apps:
+ proxy:
+  daemon: simple
+  refresh-mode: endure
+`)
+	info, err := snap.InfoFromSnapYaml(y)
+	c.Assert(err, IsNil)
+	c.Check(info.Apps["proxy"].RefreshMode, Equals, "endure")
+}
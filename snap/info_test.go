@@ -24,6 +24,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
 
 	. "gopkg.in/check.v1"
@@ -102,6 +103,25 @@ apps:
 	c.Check(info.Apps["foo"].LauncherCommand(), Equals, "/usr/bin/ubuntu-core-launcher snap.foo.foo snap.foo.foo /snap/foo/42/foo-bin")
 }
 
+func (s *infoSuite) TestAppInfoLauncherCommandWithChain(c *C) {
+	dirs.SetRootDir("")
+
+	info, err := snap.InfoFromSnapYaml([]byte(`name: foo
+apps:
+   foo:
+     command: foo-bin -x
+     stop-command: stop-bin
+     command-chain: [bin/setup-env, bin/select-runtime]
+`))
+	c.Assert(err, IsNil)
+	info.Revision = snap.R(42)
+
+	app := info.Apps["foo"]
+	c.Check(app.CommandWithChain(app.Command), DeepEquals, []string{"/snap/foo/42/bin/setup-env", "/snap/foo/42/bin/select-runtime", "/snap/foo/42/foo-bin -x"})
+	c.Check(app.LauncherCommand(), Equals, "/usr/bin/ubuntu-core-launcher snap.foo.foo snap.foo.foo /snap/foo/42/bin/setup-env /snap/foo/42/bin/select-runtime /snap/foo/42/foo-bin -x")
+	c.Check(app.LauncherStopCommand(), Equals, "/usr/bin/ubuntu-core-launcher snap.foo.foo snap.foo.foo /snap/foo/42/bin/setup-env /snap/foo/42/bin/select-runtime /snap/foo/42/stop-bin")
+}
+
 const sampleYaml = `
 name: sample
 version: 1
@@ -290,6 +310,87 @@ apps:
 	})
 }
 
+func (s *infoSuite) TestUsesHostTmp(c *C) {
+	for _, t := range []struct {
+		yaml string
+		uses bool
+	}{
+		{"name: foo\nplugs: {host-tmp: null}", true},
+		{"name: foo\nplugs: {tmp: {interface: host-tmp}}", true},
+		{"name: foo\nslots: {host-tmp: null}", false},
+		{"name: foo", false},
+	} {
+		info, err := snap.InfoFromSnapYaml([]byte(t.yaml))
+		c.Assert(err, IsNil)
+		c.Check(info.UsesHostTmp(), Equals, t.uses, Commentf("%s", t.yaml))
+	}
+}
+
+func (s *infoSuite) TestUsesPublisherData(c *C) {
+	for _, t := range []struct {
+		yaml string
+		uses bool
+	}{
+		{"name: foo\nplugs: {publisher-data: null}", true},
+		{"name: foo\nslots: {shared: {interface: publisher-data}}", true},
+		{"name: foo\nplugs: {network: null}", false},
+		{"name: foo", false},
+	} {
+		info, err := snap.InfoFromSnapYaml([]byte(t.yaml))
+		c.Assert(err, IsNil)
+		info.Developer = "acme"
+		c.Check(info.UsesPublisherData(), Equals, t.uses, Commentf("%s", t.yaml))
+		// snaps without a known publisher never do
+		info.Developer = ""
+		c.Check(info.UsesPublisherData(), Equals, false)
+	}
+
+	info := &snap.Info{SideInfo: snap.SideInfo{Developer: "acme"}}
+	c.Check(info.PublisherDataDir(), Equals, filepath.Join(dirs.SnapPublisherDataDir, "acme"))
+}
+
+func (s *infoSuite) TestNeedsLicenseAgreement(c *C) {
+	info, err := snap.InfoFromSnapYaml([]byte("name: foo\nlicense-agreement: explicit"))
+	c.Assert(err, IsNil)
+	c.Check(info.NeedsLicenseAgreement(), Equals, true)
+
+	info, err = snap.InfoFromSnapYaml([]byte("name: foo"))
+	c.Assert(err, IsNil)
+	c.Check(info.NeedsLicenseAgreement(), Equals, false)
+
+	info.Revision = snap.R(42)
+	c.Check(info.LicenseFile(), Equals, filepath.Join(dirs.SnapSnapsDir, "foo", "42", "meta", "license.txt"))
+}
+
+func (s *infoSuite) TestDNS(c *C) {
+	for _, t := range []struct {
+		yaml     string
+		settings *snap.DNSSettings
+		err      string
+	}{
+		{"name: foo", &snap.DNSSettings{Mode: "host"}, ""},
+		{"name: foo\nplugs: {network: null}", &snap.DNSSettings{Mode: "host"}, ""},
+		{"name: foo\nplugs: {network: {dns: stub}}", &snap.DNSSettings{Mode: "stub"}, ""},
+		{"name: foo\nplugs: {network: {dns: link, dns-link: eth1}}", &snap.DNSSettings{Mode: "link", Link: "eth1"}, ""},
+		{"name: foo\nplugs: {net1: {interface: network, dns: stub}, net2: {interface: network, dns: stub}}", &snap.DNSSettings{Mode: "stub"}, ""},
+		{"name: foo\nplugs: {network: {dns: other}}", nil, `cannot use plug "network": dns attribute must be one of "host", "stub" or "link", got other`},
+		{"name: foo\nplugs: {network: {dns: link}}", nil, `cannot use plug "network": dns-link attribute is required when dns is "link"`},
+		{"name: foo\nplugs: {network: {dns: stub, dns-link: eth1}}", nil, `cannot use plug "network": dns-link attribute can only be used when dns is "link"`},
+		{"name: foo\nplugs: {network: {dns: link, dns-link: eth0/../x}}", nil, `cannot use plug "network": dns-link attribute must be a network interface name, got eth0/../x`},
+		{"name: foo\nplugs: {net1: {interface: network, dns: stub}, net2: {interface: network}}", nil, `network plugs of snap "foo" have different dns settings`},
+	} {
+		info, err := snap.InfoFromSnapYaml([]byte(t.yaml))
+		c.Assert(err, IsNil)
+		settings, err := info.DNS()
+		if t.err != "" {
+			c.Check(err, ErrorMatches, regexp.QuoteMeta(t.err), Commentf("%s", t.yaml))
+			continue
+		}
+		c.Check(err, IsNil, Commentf("%s", t.yaml))
+		c.Check(settings, DeepEquals, t.settings, Commentf("%s", t.yaml))
+	}
+}
+
 func (s *infoSuite) TestSplitSnapApp(c *C) {
 	for _, t := range []struct {
 		in  string
@@ -329,3 +430,60 @@ hooks:
 	_, err = snap.ReadInfoFromSnapFile(snapf, nil)
 	c.Assert(err, ErrorMatches, ".*invalid hook name.*")
 }
+
+const orderedServicesYaml = `name: ordered
+version: 1.0
+apps:
+  web:
+    command: web
+    daemon: simple
+    after: [db, network.target]
+  db:
+    command: db
+    daemon: simple
+  cache:
+    command: cache
+    daemon: simple
+    before: [web]
+  migrate:
+    command: migrate
+    daemon: oneshot
+    before: [db]
+  tool:
+    command: tool
+`
+
+func appNames(apps []*snap.AppInfo) []string {
+	names := make([]string, len(apps))
+	for i, app := range apps {
+		names[i] = app.Name
+	}
+	return names
+}
+
+func (s *infoSuite) TestServices(c *C) {
+	info, err := snap.InfoFromSnapYaml([]byte(orderedServicesYaml))
+	c.Assert(err, IsNil)
+	c.Check(appNames(info.Services()), DeepEquals, []string{"cache", "db", "migrate", "web"})
+}
+
+func (s *infoSuite) TestSortServices(c *C) {
+	info, err := snap.InfoFromSnapYaml([]byte(orderedServicesYaml))
+	c.Assert(err, IsNil)
+	sorted, err := snap.SortServices(info.Services())
+	c.Assert(err, IsNil)
+	c.Check(appNames(sorted), DeepEquals, []string{"cache", "migrate", "db", "web"})
+
+	// services not in the list are not considered
+	sorted, err = snap.SortServices([]*snap.AppInfo{info.Apps["web"], info.Apps["cache"]})
+	c.Assert(err, IsNil)
+	c.Check(appNames(sorted), DeepEquals, []string{"cache", "web"})
+}
+
+func (s *infoSuite) TestSortServicesCycle(c *C) {
+	info, err := snap.InfoFromSnapYaml([]byte(orderedServicesYaml))
+	c.Assert(err, IsNil)
+	info.Apps["db"].After = []string{"web"}
+	_, err = snap.SortServices(info.Services())
+	c.Check(err, ErrorMatches, `cannot order services: cycle involving "(db|web)"`)
+}
@@ -32,15 +32,19 @@ import (
 // used by so many other modules, we run into circular dependencies if it's
 // somewhere more reasonable like the snappy module.
 func Basic(info *snap.Info) []string {
-	return []string{
+	env := []string{
 		fmt.Sprintf("SNAP=%s", info.MountDir()),
 		fmt.Sprintf("SNAP_DATA=%s", info.DataDir()),
 		fmt.Sprintf("SNAP_NAME=%s", info.Name()),
 		fmt.Sprintf("SNAP_VERSION=%s", info.Version),
 		fmt.Sprintf("SNAP_REVISION=%s", info.Revision),
 		fmt.Sprintf("SNAP_ARCH=%s", arch.UbuntuArchitecture()),
-		"SNAP_LIBRARY_PATH=/var/lib/snapd/lib/gl:",
+		"SNAP_LIBRARY_PATH=/var/lib/snapd/lib/gl:/var/lib/snapd/lib/gl32:",
+	}
+	if info.UsesPublisherData() {
+		env = append(env, fmt.Sprintf("SNAP_PUBLISHER_DATA=%s", info.PublisherDataDir()))
 	}
+	return env
 }
 
 // User returns the user-level environment variables for a snap.
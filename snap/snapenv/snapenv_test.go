@@ -52,7 +52,7 @@ func (ts *HTestSuite) TestBasic(c *C) {
 		"SNAP=/snap/foo/17",
 		fmt.Sprintf("SNAP_ARCH=%s", arch.UbuntuArchitecture()),
 		"SNAP_DATA=/var/snap/foo/17",
-		"SNAP_LIBRARY_PATH=/var/lib/snapd/lib/gl:",
+		"SNAP_LIBRARY_PATH=/var/lib/snapd/lib/gl:/var/lib/snapd/lib/gl32:",
 		"SNAP_NAME=foo",
 		"SNAP_REVISION=17",
 		"SNAP_VERSION=1.0",
@@ -60,6 +60,29 @@ func (ts *HTestSuite) TestBasic(c *C) {
 
 }
 
+func (ts *HTestSuite) TestBasicPublisherData(c *C) {
+	info := &snap.Info{
+		SuggestedName: "foo",
+		Version:       "1.0",
+		SideInfo: snap.SideInfo{
+			Revision:  snap.R(17),
+			Developer: "acme",
+		},
+	}
+	info.Plugs = map[string]*snap.PlugInfo{
+		"publisher-data": {Snap: info, Name: "publisher-data", Interface: "publisher-data"},
+	}
+
+	env := Basic(info)
+	c.Check(env[len(env)-1], Equals, "SNAP_PUBLISHER_DATA=/var/snap/.publisher/acme")
+
+	// snaps without a known publisher don't get any
+	info.Developer = ""
+	for _, v := range Basic(info) {
+		c.Check(v, Not(Matches), "SNAP_PUBLISHER_DATA=.*")
+	}
+}
+
 func (ts *HTestSuite) TestUser(c *C) {
 	env := User(mockSnapInfo, "/root")
 	c.Assert(env, DeepEquals, []string{
@@ -0,0 +1,220 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package snapmeta parses and validates snap metadata exactly as snapd
+// does, for tools that build or check snaps outside of snapd.
+//
+// Unlike the rest of snapd, which is free to change its internal API at
+// any time, this package follows a stability promise: within a major
+// Version, fields and functions are only ever added, never removed or
+// changed in meaning. What snapd accepts may get stricter or looser
+// over time, as the validation is snapd's own.
+package snapmeta
+
+import (
+	"os"
+	"sort"
+	"time"
+
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snapdir"
+	"github.com/snapcore/snapd/snap/squashfs"
+)
+
+// Version is the version of this API, major.minor.
+const Version = "1.0"
+
+// Metadata is the validated content of the snap.yaml of a snap.
+type Metadata struct {
+	Name          string
+	Version       string
+	Summary       string
+	Description   string
+	Type          string
+	Architectures []string
+	Assumes       []string
+	Confinement   string
+	Epoch         string
+	Environment   map[string]string
+
+	Apps  map[string]*App
+	Hooks map[string]*Hook
+	Plugs map[string]*Plug
+	Slots map[string]*Slot
+}
+
+// App is an application of a snap, possibly a service.
+type App struct {
+	Name        string
+	Command     string
+	Environment map[string]string
+	// Plugs and Slots are the names of those bound to the app, sorted.
+	Plugs []string
+	Slots []string
+
+	// Daemon is the kind of service the app is, empty if it is not one.
+	Daemon           string
+	StopCommand      string
+	PostStopCommand  string
+	StopTimeout      time.Duration
+	RestartCondition string
+	Socket           bool
+	ListenStream     string
+	SocketMode       string
+	After            []string
+	Before           []string
+	Requires         []string
+	RefreshMode      string
+}
+
+// Hook is a hook of a snap.
+type Hook struct {
+	Name string
+	// Plugs are the names of those bound to the hook, sorted.
+	Plugs []string
+}
+
+// Plug is a plug of a snap.
+type Plug struct {
+	Name      string
+	Interface string
+	Label     string
+	Attrs     map[string]interface{}
+}
+
+// Slot is a slot of a snap.
+type Slot struct {
+	Name      string
+	Interface string
+	Label     string
+	Attrs     map[string]interface{}
+}
+
+// Parse parses and validates the given snap.yaml content.
+func Parse(snapYaml []byte) (*Metadata, error) {
+	info, err := snap.InfoFromSnapYaml(snapYaml)
+	if err != nil {
+		return nil, err
+	}
+	if err := snap.Validate(info); err != nil {
+		return nil, err
+	}
+	return fromInfo(info), nil
+}
+
+// Read reads and validates the metadata of the snap at path, either a
+// snap file or an unpacked snap directory.
+func Read(path string) (*Metadata, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	var container snap.Container
+	if fi.IsDir() {
+		container = snapdir.New(path)
+	} else {
+		container = squashfs.New(path)
+	}
+	info, err := snap.ReadInfoFromSnapFile(container, nil)
+	if err != nil {
+		return nil, err
+	}
+	return fromInfo(info), nil
+}
+
+func fromInfo(info *snap.Info) *Metadata {
+	meta := &Metadata{
+		Name:          info.Name(),
+		Version:       info.Version,
+		Summary:       info.Summary(),
+		Description:   info.Description(),
+		Type:          string(info.Type),
+		Architectures: info.Architectures,
+		Assumes:       info.Assumes,
+		Confinement:   string(info.Confinement),
+		Epoch:         info.Epoch,
+		Environment:   info.Environment,
+		Apps:          make(map[string]*App, len(info.Apps)),
+		Hooks:         make(map[string]*Hook, len(info.Hooks)),
+		Plugs:         make(map[string]*Plug, len(info.Plugs)),
+		Slots:         make(map[string]*Slot, len(info.Slots)),
+	}
+	for name, app := range info.Apps {
+		meta.Apps[name] = &App{
+			Name:             app.Name,
+			Command:          app.Command,
+			Environment:      app.Environment,
+			Plugs:            plugNames(app.Plugs),
+			Slots:            slotNames(app.Slots),
+			Daemon:           app.Daemon,
+			StopCommand:      app.StopCommand,
+			PostStopCommand:  app.PostStopCommand,
+			StopTimeout:      time.Duration(app.StopTimeout),
+			RestartCondition: app.RestartCond.String(),
+			Socket:           app.Socket,
+			ListenStream:     app.ListenStream,
+			SocketMode:       app.SocketMode,
+			After:            app.After,
+			Before:           app.Before,
+			Requires:         app.Requires,
+			RefreshMode:      app.RefreshMode,
+		}
+	}
+	for name, hook := range info.Hooks {
+		meta.Hooks[name] = &Hook{
+			Name:  hook.Name,
+			Plugs: plugNames(hook.Plugs),
+		}
+	}
+	for name, plug := range info.Plugs {
+		meta.Plugs[name] = &Plug{
+			Name:      plug.Name,
+			Interface: plug.Interface,
+			Label:     plug.Label,
+			Attrs:     plug.Attrs,
+		}
+	}
+	for name, slot := range info.Slots {
+		meta.Slots[name] = &Slot{
+			Name:      slot.Name,
+			Interface: slot.Interface,
+			Label:     slot.Label,
+			Attrs:     slot.Attrs,
+		}
+	}
+	return meta
+}
+
+func plugNames(plugs map[string]*snap.PlugInfo) []string {
+	names := make([]string, 0, len(plugs))
+	for name := range plugs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func slotNames(slots map[string]*snap.SlotInfo) []string {
+	names := make([]string, 0, len(slots))
+	for name := range slots {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
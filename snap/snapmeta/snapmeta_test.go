@@ -0,0 +1,119 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapmeta_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/snap/snapmeta"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type snapmetaSuite struct{}
+
+var _ = Suite(&snapmetaSuite{})
+
+const sampleYaml = `name: proxy
+version: 1.2
+summary: a proxy
+type: app
+plugs:
+  network:
+slots:
+  http-proxy:
+    interface: content
+    read: [/share]
+apps:
+  proxy:
+    command: bin/proxy
+    daemon: simple
+    stop-timeout: 10s
+    restart-condition: always
+    refresh-mode: endure
+    plugs: [network]
+  ctl:
+    command: bin/ctl
+    slots: [http-proxy]
+hooks:
+  apply-config:
+    plugs: [network]
+`
+
+func (s *snapmetaSuite) TestParse(c *C) {
+	meta, err := snapmeta.Parse([]byte(sampleYaml))
+	c.Assert(err, IsNil)
+
+	c.Check(meta.Name, Equals, "proxy")
+	c.Check(meta.Version, Equals, "1.2")
+	c.Check(meta.Summary, Equals, "a proxy")
+	c.Check(meta.Type, Equals, "app")
+	c.Check(meta.Epoch, Equals, "0")
+	c.Check(meta.Confinement, Equals, "strict")
+
+	c.Assert(meta.Apps, HasLen, 2)
+	proxy := meta.Apps["proxy"]
+	c.Check(proxy.Command, Equals, "bin/proxy")
+	c.Check(proxy.Daemon, Equals, "simple")
+	c.Check(proxy.StopTimeout, Equals, 10*time.Second)
+	c.Check(proxy.RestartCondition, Equals, "always")
+	c.Check(proxy.RefreshMode, Equals, "endure")
+	c.Check(proxy.Plugs, DeepEquals, []string{"network"})
+	c.Check(meta.Apps["ctl"].Slots, DeepEquals, []string{"http-proxy"})
+
+	c.Check(meta.Hooks["apply-config"].Plugs, DeepEquals, []string{"network"})
+	c.Check(meta.Plugs["network"].Interface, Equals, "network")
+	slot := meta.Slots["http-proxy"]
+	c.Check(slot.Interface, Equals, "content")
+	c.Check(slot.Attrs, DeepEquals, map[string]interface{}{"read": []interface{}{"/share"}})
+}
+
+func (s *snapmetaSuite) TestParseValidates(c *C) {
+	_, err := snapmeta.Parse([]byte("name: foo\nversion: 1\napps:\n  foo:\n    command: foo\n    daemon: sometimes\n"))
+	c.Check(err, ErrorMatches, `"daemon" field contains invalid value "sometimes"`)
+
+	_, err = snapmeta.Parse([]byte("name: Foo\nversion: 1\n"))
+	c.Check(err, ErrorMatches, `invalid snap name: "Foo"`)
+
+	_, err = snapmeta.Parse([]byte("name: [foo\n"))
+	c.Check(err, ErrorMatches, "info failed to parse: .*")
+}
+
+func (s *snapmetaSuite) TestReadDir(c *C) {
+	dir := c.MkDir()
+	c.Assert(os.MkdirAll(filepath.Join(dir, "meta"), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, "meta", "snap.yaml"), []byte(sampleYaml), 0644), IsNil)
+
+	meta, err := snapmeta.Read(dir)
+	c.Assert(err, IsNil)
+	c.Check(meta.Name, Equals, "proxy")
+	c.Check(meta.Apps, HasLen, 2)
+}
+
+func (s *snapmetaSuite) TestReadMissing(c *C) {
+	_, err := snapmeta.Read(filepath.Join(c.MkDir(), "nothere"))
+	c.Check(os.IsNotExist(err), Equals, true)
+}
@@ -21,7 +21,10 @@ package snap
 
 import (
 	"fmt"
+	"path"
 	"regexp"
+	"sort"
+	"strings"
 )
 
 // Regular expression describing correct identifiers.
@@ -84,6 +87,16 @@ func Validate(info *Info) error {
 		}
 	}
 
+	// the ordering between the services must not loop
+	if _, err := SortServices(info.Services()); err != nil {
+		return err
+	}
+
+	// the network plugs must agree on how names are resolved
+	if _, err := info.DNS(); err != nil {
+		return err
+	}
+
 	// validate hook entries
 	for _, hook := range info.Hooks {
 		err := ValidateHook(hook)
@@ -136,5 +149,143 @@ func ValidateApp(app *AppInfo) error {
 			return err
 		}
 	}
+
+	if err := validateCommandChain(app.CommandChain); err != nil {
+		return err
+	}
+
+	if err := validatePassthroughEnv(app); err != nil {
+		return err
+	}
+
+	// Validate the units the app is ordered against; after and before
+	// may also name other services of the same snap
+	hostUnits := map[string][]string{
+		"after":    app.After,
+		"before":   app.Before,
+		"requires": app.Requires,
+	}
+	names := make([]string, 0, len(hostUnits))
+	for name := range hostUnits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := validateHostUnits(app, name, hostUnits[name]); err != nil {
+			return err
+		}
+	}
+
+	switch app.RefreshMode {
+	case "", "restart", "endure":
+		// valid
+	default:
+		return fmt.Errorf(`"refresh-mode" field contains invalid value %q`, app.RefreshMode)
+	}
+
+	return validateSchedulingFields(app)
+}
+
+// command chain helpers are single paths, so no spaces
+var validCommandChainEntry = regexp.MustCompile(`^[A-Za-z0-9/._#:-]+$`)
+
+// validateCommandChain checks that the helpers of the command chain
+// are paths inside the snap.
+func validateCommandChain(chain []string) error {
+	for _, helper := range chain {
+		if !validCommandChainEntry.MatchString(helper) {
+			return fmt.Errorf("command-chain entry %q contains illegal characters", helper)
+		}
+		if strings.HasPrefix(helper, "/") {
+			return fmt.Errorf("command-chain entry %q must be a relative path", helper)
+		}
+		if clean := path.Clean(helper); clean == ".." || strings.HasPrefix(clean, "../") {
+			return fmt.Errorf("command-chain entry %q must not point outside of the snap", helper)
+		}
+	}
+	return nil
+}
+
+var validEnvName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// maxPassthroughEnv limits how much of the host environment a service
+// can take in.
+const maxPassthroughEnv = 32
+
+// reservedEnv are the variables the snap environment or the loader rely
+// on, which services cannot take from the host.
+var reservedEnv = map[string]bool{
+	"HOME":            true,
+	"PATH":            true,
+	"USER":            true,
+	"TMPDIR":          true,
+	"XDG_RUNTIME_DIR": true,
+}
+
+// validatePassthroughEnv checks the variables the app takes from the
+// environment of the service manager.
+func validatePassthroughEnv(app *AppInfo) error {
+	if len(app.PassthroughEnv) > maxPassthroughEnv {
+		return fmt.Errorf("passthrough-env cannot list more than %d variables", maxPassthroughEnv)
+	}
+	for _, name := range app.PassthroughEnv {
+		if !validEnvName.MatchString(name) {
+			return fmt.Errorf("passthrough-env entry %q is not a valid variable name", name)
+		}
+		if reservedEnv[name] || name == "SNAP" || strings.HasPrefix(name, "SNAP_") || strings.HasPrefix(name, "LD_") {
+			return fmt.Errorf("passthrough-env entry %q is reserved", name)
+		}
+		_, inApp := app.Environment[name]
+		inSnap := false
+		if app.Snap != nil {
+			_, inSnap = app.Snap.Environment[name]
+		}
+		if inApp || inSnap {
+			return fmt.Errorf("passthrough-env entry %q is already set by the environment of the snap", name)
+		}
+	}
+	return nil
+}
+
+// validateSchedulingFields checks the fields that tune the scheduling and
+// the out-of-memory handling of the app against the ranges systemd accepts.
+func validateSchedulingFields(app *AppInfo) error {
+	if app.OOMScoreAdjust < -1000 || app.OOMScoreAdjust > 1000 {
+		return fmt.Errorf(`"oom-score-adjust" field contains invalid value %d (must be between -1000 and 1000)`, app.OOMScoreAdjust)
+	}
+	if app.Nice < -20 || app.Nice > 19 {
+		return fmt.Errorf(`"nice" field contains invalid value %d (must be between -20 and 19)`, app.Nice)
+	}
+	switch app.IONiceClass {
+	case "", "realtime", "best-effort", "idle":
+		// valid
+	default:
+		return fmt.Errorf(`"ionice-class" field contains invalid value %q`, app.IONiceClass)
+	}
+	return nil
+}
+
+// hostUnitsWhitelist is the whitelist of host systemd units that apps
+// may declare ordering against or dependencies on.
+var hostUnitsWhitelist = map[string]bool{
+	"network.target":        true,
+	"network-online.target": true,
+	"nss-lookup.target":     true,
+	"time-sync.target":      true,
+	"local-fs.target":       true,
+	"remote-fs.target":      true,
+	"sound.target":          true,
+	"bluetooth.target":      true,
+}
+
+func validateHostUnits(app *AppInfo, name string, units []string) error {
+	for _, unit := range units {
+		if name != "requires" && app.siblingService(unit) != nil {
+			continue
+		}
+		if !hostUnitsWhitelist[unit] {
+			return fmt.Errorf("%q field contains unsupported host unit %q", name, unit)
+		}
+	}
 	return nil
 }
@@ -20,6 +20,8 @@
 package snap_test
 
 import (
+	"fmt"
+
 	. "gopkg.in/check.v1"
 
 	. "github.com/snapcore/snapd/snap"
@@ -150,6 +152,116 @@ func (s *ValidateSuite) TestAppDaemonValue(c *C) {
 	c.Check(ValidateApp(&AppInfo{Name: "foo", Daemon: "nono"}), ErrorMatches, `"daemon" field contains invalid value "nono"`)
 }
 
+func (s *ValidateSuite) TestAppHostUnits(c *C) {
+	c.Check(ValidateApp(&AppInfo{Name: "foo", After: []string{"network-online.target"}}), IsNil)
+	c.Check(ValidateApp(&AppInfo{Name: "foo", Before: []string{"sound.target"}}), IsNil)
+	c.Check(ValidateApp(&AppInfo{Name: "foo", Requires: []string{"time-sync.target"}}), IsNil)
+
+	c.Check(ValidateApp(&AppInfo{Name: "foo", After: []string{"sshd.service"}}), ErrorMatches, `"after" field contains unsupported host unit "sshd.service"`)
+	c.Check(ValidateApp(&AppInfo{Name: "foo", Before: []string{"multi-user.target"}}), ErrorMatches, `"before" field contains unsupported host unit "multi-user.target"`)
+	c.Check(ValidateApp(&AppInfo{Name: "foo", Requires: []string{"snap.other.app.service"}}), ErrorMatches, `"requires" field contains unsupported host unit "snap.other.app.service"`)
+
+	// the fields are checked in a stable order
+	for i := 0; i < 10; i++ {
+		app := &AppInfo{Name: "foo", After: []string{"sshd.service"}, Before: []string{"multi-user.target"}, Requires: []string{"cups.service"}}
+		c.Check(ValidateApp(app), ErrorMatches, `"after" field contains unsupported host unit "sshd.service"`)
+	}
+}
+
+func (s *ValidateSuite) TestAppSiblingServiceUnits(c *C) {
+	info, err := InfoFromSnapYaml([]byte(`name: foo
+version: 1.0
+apps:
+  db:
+    command: db
+    daemon: simple
+  web:
+    command: web
+    daemon: simple
+    after: [db]
+  tool:
+    command: tool
+`))
+	c.Assert(err, IsNil)
+	c.Check(Validate(info), IsNil)
+
+	web := info.Apps["web"]
+	web.Before = []string{"tool"}
+	c.Check(ValidateApp(web), ErrorMatches, `"before" field contains unsupported host unit "tool"`)
+	web.Before = []string{"web"}
+	c.Check(ValidateApp(web), ErrorMatches, `"before" field contains unsupported host unit "web"`)
+	web.Before = nil
+	web.Requires = []string{"db"}
+	c.Check(ValidateApp(web), ErrorMatches, `"requires" field contains unsupported host unit "db"`)
+}
+
+func (s *ValidateSuite) TestServicesOrderingCycle(c *C) {
+	info, err := InfoFromSnapYaml([]byte(`name: foo
+version: 1.0
+apps:
+  db:
+    command: db
+    daemon: simple
+    after: [web]
+  web:
+    command: web
+    daemon: simple
+    after: [db]
+`))
+	c.Assert(err, IsNil)
+	c.Check(Validate(info), ErrorMatches, `cannot order services: cycle involving "(db|web)"`)
+}
+
+func (s *ValidateSuite) TestAppCommandChain(c *C) {
+	c.Check(ValidateApp(&AppInfo{Name: "foo", CommandChain: []string{"bin/setup-env", "./runtime/select"}}), IsNil)
+
+	c.Check(ValidateApp(&AppInfo{Name: "foo", CommandChain: []string{"/usr/bin/env"}}), ErrorMatches, `command-chain entry "/usr/bin/env" must be a relative path`)
+	c.Check(ValidateApp(&AppInfo{Name: "foo", CommandChain: []string{"bin/../../other/helper"}}), ErrorMatches, `command-chain entry "bin/../../other/helper" must not point outside of the snap`)
+	c.Check(ValidateApp(&AppInfo{Name: "foo", CommandChain: []string{"bin/helper --flag"}}), ErrorMatches, `command-chain entry "bin/helper --flag" contains illegal characters`)
+	c.Check(ValidateApp(&AppInfo{Name: "foo", CommandChain: []string{""}}), ErrorMatches, `command-chain entry "" contains illegal characters`)
+}
+
+func (s *ValidateSuite) TestAppPassthroughEnv(c *C) {
+	c.Check(ValidateApp(&AppInfo{Name: "foo", PassthroughEnv: []string{"http_proxy", "JAVA_HOME"}}), IsNil)
+
+	for _, name := range []string{"PATH", "HOME", "SNAP", "SNAP_DATA", "LD_PRELOAD", "XDG_RUNTIME_DIR"} {
+		c.Check(ValidateApp(&AppInfo{Name: "foo", PassthroughEnv: []string{name}}), ErrorMatches, fmt.Sprintf(`passthrough-env entry %q is reserved`, name))
+	}
+	c.Check(ValidateApp(&AppInfo{Name: "foo", PassthroughEnv: []string{"http-proxy"}}), ErrorMatches, `passthrough-env entry "http-proxy" is not a valid variable name`)
+	c.Check(ValidateApp(&AppInfo{Name: "foo", PassthroughEnv: []string{"1FOO"}}), ErrorMatches, `passthrough-env entry "1FOO" is not a valid variable name`)
+
+	app := &AppInfo{Name: "foo", PassthroughEnv: []string{"JAVA_HOME"}, Environment: map[string]string{"JAVA_HOME": "$SNAP/jre"}}
+	c.Check(ValidateApp(app), ErrorMatches, `passthrough-env entry "JAVA_HOME" is already set by the environment of the snap`)
+	app = &AppInfo{Name: "foo", PassthroughEnv: []string{"JAVA_HOME"}, Snap: &Info{Environment: map[string]string{"JAVA_HOME": "$SNAP/jre"}}}
+	c.Check(ValidateApp(app), ErrorMatches, `passthrough-env entry "JAVA_HOME" is already set by the environment of the snap`)
+
+	many := make([]string, 33)
+	for i := range many {
+		many[i] = fmt.Sprintf("VAR%d", i)
+	}
+	c.Check(ValidateApp(&AppInfo{Name: "foo", PassthroughEnv: many}), ErrorMatches, `passthrough-env cannot list more than 32 variables`)
+}
+
+func (s *ValidateSuite) TestAppRefreshMode(c *C) {
+	c.Check(ValidateApp(&AppInfo{Name: "foo", RefreshMode: "restart"}), IsNil)
+	c.Check(ValidateApp(&AppInfo{Name: "foo", RefreshMode: "endure"}), IsNil)
+	c.Check(ValidateApp(&AppInfo{Name: "foo", RefreshMode: "sometimes"}), ErrorMatches, `"refresh-mode" field contains invalid value "sometimes"`)
+}
+
+func (s *ValidateSuite) TestAppSchedulingFields(c *C) {
+	c.Check(ValidateApp(&AppInfo{Name: "foo", OOMScoreAdjust: -1000}), IsNil)
+	c.Check(ValidateApp(&AppInfo{Name: "foo", OOMScoreAdjust: 1000}), IsNil)
+	c.Check(ValidateApp(&AppInfo{Name: "foo", Nice: -20}), IsNil)
+	c.Check(ValidateApp(&AppInfo{Name: "foo", Nice: 19}), IsNil)
+	for _, class := range []string{"realtime", "best-effort", "idle"} {
+		c.Check(ValidateApp(&AppInfo{Name: "foo", IONiceClass: class}), IsNil)
+	}
+
+	c.Check(ValidateApp(&AppInfo{Name: "foo", OOMScoreAdjust: 1001}), ErrorMatches, `"oom-score-adjust" field contains invalid value 1001 \(must be between -1000 and 1000\)`)
+	c.Check(ValidateApp(&AppInfo{Name: "foo", Nice: -21}), ErrorMatches, `"nice" field contains invalid value -21 \(must be between -20 and 19\)`)
+	c.Check(ValidateApp(&AppInfo{Name: "foo", IONiceClass: "low"}), ErrorMatches, `"ionice-class" field contains invalid value "low"`)
+}
+
 func (s *ValidateSuite) TestAppWhitelistError(c *C) {
 	err := ValidateApp(&AppInfo{Name: "foo", Command: "x\n"})
 	c.Assert(err, NotNil)
@@ -235,3 +347,19 @@ hooks:
 	err = Validate(info)
 	c.Check(err, ErrorMatches, `invalid hook name: "abc123"`)
 }
+
+func (s *ValidateSuite) TestValidateDNSSettings(c *C) {
+	info, err := InfoFromSnapYaml([]byte(`name: foo
+version: 1.0
+plugs:
+  net1:
+    interface: network
+    dns: stub
+  net2:
+    interface: network
+`))
+	c.Assert(err, IsNil)
+
+	err = Validate(info)
+	c.Check(err, ErrorMatches, `network plugs of snap "foo" have different dns settings`)
+}
@@ -99,6 +99,10 @@ var (
 	// run
 	ErrNotFirstBoot = errors.New("this is not your first boot")
 
+	// ErrAlreadyPreseeded is returned when preseeding an image that
+	// has already been preseeded
+	ErrAlreadyPreseeded = errors.New("image is already preseeded")
+
 	// ErrNotImplemented may be returned when an implementation of
 	// an interface is partial.
 	ErrNotImplemented = errors.New("not implemented")
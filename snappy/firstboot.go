@@ -97,7 +97,8 @@ func enableInstalledSnaps() error {
 
 // FirstBoot checks whether it's the first boot, and if so enables the
 // first ethernet device and runs gadgetConfig (as well as flagging that
-// it run)
+// it run). Snaps of a preseeded image were already activated when the
+// image was built, so they are left alone.
 func FirstBoot() error {
 	if firstBootHasRun() {
 		return ErrNotFirstBoot
@@ -105,16 +106,66 @@ func FirstBoot() error {
 	defer stampFirstBoot()
 	defer enableFirstEther()
 
+	if Preseeded() {
+		return nil
+	}
+
 	return enableInstalledSnaps()
 }
 
+// preseeding is set while activating snaps at image build time
+var preseeding bool
+
+// Preseed does the parts of the first boot that do not need the
+// device at image build time: it activates the installed snaps,
+// writing and enabling their wrappers without starting anything, and
+// flags the image as preseeded. It expects to run chrooted into the
+// image.
+func Preseed() error {
+	if firstBootHasRun() {
+		return ErrNotFirstBoot
+	}
+	if Preseeded() {
+		return ErrAlreadyPreseeded
+	}
+
+	all, err := (&Overlord{}).Installed()
+	if err != nil {
+		return err
+	}
+
+	preseeding = true
+	defer func() { preseeding = false }()
+
+	activator := getActivator()
+	pb := &progress.NullProgress{}
+	for _, sn := range all {
+		if err := activator.SetActive(sn, true, pb); err != nil {
+			return fmt.Errorf("cannot activate %s: %v", FullName(sn.Info()), err)
+		}
+	}
+
+	return stamp(preseedStampFile)
+}
+
+// Preseeded returns whether the image was preseeded.
+func Preseeded() bool {
+	return osutil.FileExists(preseedStampFile)
+}
+
 // NOTE: if you change stampFile, update the condition in
 // snapd.firstboot.service to match
 var stampFile = "/var/lib/snapd/firstboot/stamp"
 
+var preseedStampFile = "/var/lib/snapd/firstboot/preseeded"
+
 func stampFirstBoot() error {
+	return stamp(stampFile)
+}
+
+func stamp(path string) error {
 	// filepath.Dir instead of firstbootDir directly to ease testing
-	stampDir := filepath.Dir(stampFile)
+	stampDir := filepath.Dir(path)
 
 	if _, err := os.Stat(stampDir); os.IsNotExist(err) {
 		if err := os.MkdirAll(stampDir, 0755); err != nil {
@@ -122,7 +173,7 @@ func stampFirstBoot() error {
 		}
 	}
 
-	return osutil.AtomicWriteFile(stampFile, []byte{}, 0644, 0)
+	return osutil.AtomicWriteFile(path, []byte{}, 0644, 0)
 }
 
 var globs = []string{"/sys/class/net/eth*", "/sys/class/net/en*"}
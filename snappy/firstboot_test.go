@@ -27,6 +27,7 @@ import (
 	. "gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/progress"
 	"github.com/snapcore/snapd/systemd"
 )
 
@@ -47,6 +48,7 @@ func (s *FirstBootTestSuite) SetUpTest(c *C) {
 	dirs.SetRootDir(tempdir)
 	os.MkdirAll(dirs.SnapSnapsDir, 0755)
 	stampFile = filepath.Join(c.MkDir(), "stamp")
+	preseedStampFile = filepath.Join(c.MkDir(), "preseeded")
 
 	// mock the world!
 	systemd.SystemctlCmd = func(cmd ...string) ([]byte, error) {
@@ -167,3 +169,77 @@ func (s *FirstBootTestSuite) TestSystemSnapsEnablesKernel(c *C) {
 func (s *FirstBootTestSuite) TestSystemSnapsDoesEnableApps(c *C) {
 	s.ensureSystemSnapIsEnabledOnFirstBoot(c, "", true)
 }
+
+type recordingActivator struct {
+	activated []string
+}
+
+func (a *recordingActivator) SetActive(sn *Snap, active bool, meter progress.Meter) error {
+	a.activated = append(a.activated, sn.Name())
+	return (&Overlord{}).SetActive(sn, active, meter)
+}
+
+func (s *FirstBootTestSuite) mockActivator() (*recordingActivator, func()) {
+	a := &recordingActivator{}
+	getActivator = func() activator { return a }
+	return a, func() {
+		getActivator = func() activator { return &Overlord{} }
+	}
+}
+
+func (s *FirstBootTestSuite) TestPreseedActivatesWithoutStarting(c *C) {
+	var sysdLog [][]string
+	systemd.SystemctlCmd = func(cmd ...string) ([]byte, error) {
+		sysdLog = append(sysdLog, cmd)
+		return []byte("ActiveState=inactive\n"), nil
+	}
+
+	_, err := makeInstalledMockSnap(`name: foo
+version: 1.0
+apps:
+ svc:
+  command: bin/svc
+  daemon: simple
+`, 11)
+	c.Assert(err, IsNil)
+
+	c.Assert(Preseed(), IsNil)
+	c.Check(Preseeded(), Equals, true)
+	c.Check(firstBootHasRun(), Equals, false)
+
+	all, err := (&Overlord{}).Installed()
+	c.Assert(err, IsNil)
+	c.Assert(all, HasLen, 1)
+	c.Check(all[0].IsActive(), Equals, true)
+
+	c.Check(sysdLog, DeepEquals, [][]string{
+		{"--root", dirs.GlobalRootDir, "enable", "snap.foo.svc.service"},
+	})
+	c.Check(preseeding, Equals, false)
+}
+
+func (s *FirstBootTestSuite) TestPreseedTwice(c *C) {
+	c.Assert(Preseed(), IsNil)
+	c.Check(Preseed(), Equals, ErrAlreadyPreseeded)
+}
+
+func (s *FirstBootTestSuite) TestPreseedAfterFirstBoot(c *C) {
+	c.Assert(FirstBoot(), IsNil)
+	c.Check(Preseed(), Equals, ErrNotFirstBoot)
+}
+
+func (s *FirstBootTestSuite) TestFirstBootAfterPreseedSkipsActivation(c *C) {
+	_, err := makeInstalledMockSnap(mockOSYaml, 11)
+	c.Assert(err, IsNil)
+
+	a, restore := s.mockActivator()
+	defer restore()
+
+	c.Assert(Preseed(), IsNil)
+	c.Check(a.activated, DeepEquals, []string{"ubuntu-core"})
+
+	a.activated = nil
+	c.Assert(FirstBoot(), IsNil)
+	c.Check(a.activated, HasLen, 0)
+	c.Check(firstBootHasRun(), Equals, true)
+}
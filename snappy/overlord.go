@@ -217,8 +217,13 @@ func generateWrappers(s *snap.Info, inter interacter) error {
 	if err := wrappers.AddSnapBinaries(s); err != nil {
 		return err
 	}
-	// add the daemons from the snap.yaml
-	if err := wrappers.AddSnapServices(s, inter); err != nil {
+	// add the daemons from the snap.yaml; when preseeding an image
+	// there is no systemd to start them, they are started on boot
+	addServices := wrappers.AddSnapServices
+	if preseeding {
+		addServices = wrappers.EnableSnapServices
+	}
+	if err := addServices(s, inter); err != nil {
 		return err
 	}
 	// add the desktop files
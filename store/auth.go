@@ -67,7 +67,7 @@ func RequestPackageAccessMacaroon() (string, error) {
 	req.Header.Set("accept", "application/json")
 	req.Header.Set("content-type", "application/json")
 
-	client := &http.Client{}
+	client := newHTTPClient()
 	resp, err := client.Do(req)
 	if err != nil {
 		return "", fmt.Errorf(errorPrefix+"%v", err)
@@ -117,7 +117,7 @@ func DischargeAuthCaveat(username, password, macaroon, otp string) (string, erro
 	req.Header.Set("accept", "application/json")
 	req.Header.Set("content-type", "application/json")
 
-	client := &http.Client{}
+	client := newHTTPClient()
 	resp, err := client.Do(req)
 	if err != nil {
 		return "", fmt.Errorf(errorPrefix+"%v", err)
@@ -0,0 +1,113 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package store
+
+import (
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/snapcore/snapd/fips"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/snap"
+)
+
+// ErrNotAvailable is returned by a DownloadBackend that cannot provide the
+// requested snap file.
+var ErrNotAvailable = errors.New("snap not available from download backend")
+
+// DownloadBackend is an alternative source of snap files that is tried
+// before downloading from the store itself.
+type DownloadBackend interface {
+	// Name returns a short name for the backend, used when logging.
+	Name() string
+	// Fetch writes the snap file for remoteSnap into w. It returns
+	// ErrNotAvailable if the backend does not have the file.
+	Fetch(remoteSnap *snap.Info, w io.Writer, pbar progress.Meter) error
+}
+
+// DownloadCacher is implemented by download backends that want to keep a
+// copy of snap files that were downloaded and verified by the store.
+type DownloadCacher interface {
+	Cache(remoteSnap *snap.Info, path string) error
+}
+
+// fetchFromBackends tries to fill w with the snap file from the configured
+// download backends, verifying the content against the digest provided by
+// the store. It returns whether one of the backends succeeded; when none
+// does w is left empty.
+func (s *SnapUbuntuStoreRepository) fetchFromBackends(remoteSnap *snap.Info, w *os.File, pbar progress.Meter) bool {
+	if remoteSnap.Sha512 == "" {
+		// nothing to verify against
+		return false
+	}
+
+	for _, backend := range s.backends {
+		if err := resetFile(w); err != nil {
+			logger.Noticef("cannot reset download of %q: %v", remoteSnap.Name(), err)
+			return false
+		}
+
+		h := fips.SHA512()
+		err := backend.Fetch(remoteSnap, io.MultiWriter(w, h), pbar)
+		if err == ErrNotAvailable {
+			continue
+		}
+		if err != nil {
+			logger.Noticef("cannot download %q from %s: %v", remoteSnap.Name(), backend.Name(), err)
+			continue
+		}
+		if digest := hex.EncodeToString(h.Sum(nil)); digest != remoteSnap.Sha512 {
+			logger.Noticef("cannot use %q from %s: sha512 mismatch (expected %s, got %s)", remoteSnap.Name(), backend.Name(), remoteSnap.Sha512, digest)
+			continue
+		}
+
+		return true
+	}
+
+	if err := resetFile(w); err != nil {
+		logger.Noticef("cannot reset download of %q: %v", remoteSnap.Name(), err)
+	}
+	return false
+}
+
+// cacheDownload hands a downloaded snap file to the backends that keep
+// copies of them.
+func (s *SnapUbuntuStoreRepository) cacheDownload(remoteSnap *snap.Info, path string) {
+	for _, backend := range s.backends {
+		cacher, ok := backend.(DownloadCacher)
+		if !ok {
+			continue
+		}
+		if err := cacher.Cache(remoteSnap, path); err != nil {
+			logger.Noticef("cannot cache %q in %s: %v", remoteSnap.Name(), backend.Name(), err)
+		}
+	}
+}
+
+func resetFile(f *os.File) error {
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	_, err := f.Seek(0, 0)
+	return err
+}
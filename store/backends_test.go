@@ -0,0 +1,142 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package store
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/snap"
+)
+
+type fakeBackend struct {
+	name    string
+	content string
+	err     error
+	fetched []string
+	cached  []string
+}
+
+func (b *fakeBackend) Name() string { return b.name }
+
+func (b *fakeBackend) Fetch(remoteSnap *snap.Info, w io.Writer, pbar progress.Meter) error {
+	b.fetched = append(b.fetched, remoteSnap.Name())
+	if b.err != nil {
+		return b.err
+	}
+	_, err := w.Write([]byte(b.content))
+	return err
+}
+
+type fakeCachingBackend struct {
+	fakeBackend
+}
+
+func (b *fakeCachingBackend) Cache(remoteSnap *snap.Info, path string) error {
+	b.cached = append(b.cached, path)
+	return nil
+}
+
+func sha512Hex(content string) string {
+	h := sha512.Sum512([]byte(content))
+	return hex.EncodeToString(h[:])
+}
+
+func mockRemoteSnap(content string) *snap.Info {
+	info := &snap.Info{}
+	info.OfficialName = "foo"
+	info.AnonDownloadURL = "anon-url"
+	info.Sha512 = sha512Hex(content)
+	info.Size = int64(len(content))
+	return info
+}
+
+func (t *remoteRepoTestSuite) TestDownloadFromBackend(c *C) {
+	download = func(name string, w io.Writer, req *http.Request, pbar progress.Meter) error {
+		c.Fatal("unexpected download from the store")
+		return nil
+	}
+
+	unavailable := &fakeBackend{name: "unavailable", err: ErrNotAvailable}
+	good := &fakeBackend{name: "good", content: "from a backend"}
+	t.store.backends = []DownloadBackend{unavailable, good}
+
+	path, err := t.store.Download(mockRemoteSnap("from a backend"), nil, nil)
+	c.Assert(err, IsNil)
+	defer os.Remove(path)
+
+	content, err := ioutil.ReadFile(path)
+	c.Assert(err, IsNil)
+	c.Check(string(content), Equals, "from a backend")
+	c.Check(unavailable.fetched, DeepEquals, []string{"foo"})
+	c.Check(good.fetched, DeepEquals, []string{"foo"})
+}
+
+func (t *remoteRepoTestSuite) TestDownloadBackendDigestMismatchFallsBack(c *C) {
+	download = func(name string, w io.Writer, req *http.Request, pbar progress.Meter) error {
+		c.Check(req.URL.String(), Equals, "anon-url")
+		w.Write([]byte("from the store"))
+		return nil
+	}
+
+	broken := &fakeBackend{name: "broken", err: fmt.Errorf("boom")}
+	bad := &fakeCachingBackend{fakeBackend{name: "bad", content: "something much longer than expected"}}
+	t.store.backends = []DownloadBackend{broken, bad}
+
+	path, err := t.store.Download(mockRemoteSnap("from the store"), nil, nil)
+	c.Assert(err, IsNil)
+	defer os.Remove(path)
+
+	content, err := ioutil.ReadFile(path)
+	c.Assert(err, IsNil)
+	c.Check(string(content), Equals, "from the store")
+	c.Check(bad.cached, DeepEquals, []string{path})
+	c.Check(t.logbuf.String(), Matches, `(?s).*cannot download "foo" from broken: boom.*`)
+	c.Check(t.logbuf.String(), Matches, `(?s).*cannot use "foo" from bad: sha512 mismatch.*`)
+}
+
+func (t *remoteRepoTestSuite) TestDownloadBackendsNeedDigest(c *C) {
+	download = func(name string, w io.Writer, req *http.Request, pbar progress.Meter) error {
+		w.Write([]byte("from the store"))
+		return nil
+	}
+
+	backend := &fakeBackend{name: "good", content: "from a backend"}
+	t.store.backends = []DownloadBackend{backend}
+
+	remoteSnap := mockRemoteSnap("")
+	remoteSnap.Sha512 = ""
+	path, err := t.store.Download(remoteSnap, nil, nil)
+	c.Assert(err, IsNil)
+	defer os.Remove(path)
+
+	content, err := ioutil.ReadFile(path)
+	c.Assert(err, IsNil)
+	c.Check(string(content), Equals, "from the store")
+	c.Check(backend.fetched, HasLen, 0)
+}
@@ -0,0 +1,142 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package store
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+)
+
+// storeCertsStamp summarizes the contents of the store certificates
+// directory, so changes to it can be noticed cheaply.
+func storeCertsStamp() (string, error) {
+	fis, err := ioutil.ReadDir(dirs.SnapStoreCertsDir)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	parts := make([]string, 0, len(fis))
+	for _, fi := range fis {
+		if !strings.HasSuffix(fi.Name(), ".pem") {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s:%d:%d", fi.Name(), fi.Size(), fi.ModTime().UnixNano()))
+	}
+	return strings.Join(parts, ","), nil
+}
+
+// storeCertPool returns the system certificate pool extended with the
+// extra store certificates, or nil if there are none.
+func storeCertPool() (*x509.CertPool, error) {
+	paths, err := filepath.Glob(filepath.Join(dirs.SnapStoreCertsDir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		logger.Noticef("cannot load system certificates: %v", err)
+		pool = x509.NewCertPool()
+	}
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			logger.Noticef("no certificates found in %s", path)
+		}
+	}
+	return pool, nil
+}
+
+// certsTransport is a http.RoundTripper that trusts the extra store
+// certificates on top of the system ones, picking up changes to them
+// as they happen.
+type certsTransport struct {
+	newTransport func() (*http.Transport, error)
+
+	mu    sync.Mutex
+	stamp string
+	tr    *http.Transport
+}
+
+func (t *certsTransport) transport() (*http.Transport, error) {
+	stamp, err := storeCertsStamp()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read store certificates: %v", err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.tr != nil && stamp == t.stamp {
+		return t.tr, nil
+	}
+
+	tr, err := t.newTransport()
+	if err != nil {
+		return nil, err
+	}
+	pool, err := storeCertPool()
+	if err != nil {
+		return nil, fmt.Errorf("cannot load store certificates: %v", err)
+	}
+	if pool != nil {
+		tr.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	if t.tr != nil {
+		t.tr.CloseIdleConnections()
+	}
+	t.tr = tr
+	t.stamp = stamp
+	return tr, nil
+}
+
+// CloseIdleConnections closes the idle connections of the current
+// transport.
+func (t *certsTransport) CloseIdleConnections() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.tr != nil {
+		t.tr.CloseIdleConnections()
+	}
+}
+
+func (t *certsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	tr, err := t.transport()
+	if err != nil {
+		return nil, err
+	}
+	return tr.RoundTrip(req)
+}
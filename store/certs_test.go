@@ -0,0 +1,110 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package store
+
+import (
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+)
+
+type certsSuite struct{}
+
+var _ = Suite(&certsSuite{})
+
+func (s *certsSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+}
+
+func (s *certsSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("")
+}
+
+func (s *certsSuite) TestCertsTransport(c *C) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "hello")
+	}))
+	defer server.Close()
+
+	n := 0
+	tr := &certsTransport{newTransport: func() (*http.Transport, error) {
+		n++
+		return &http.Transport{}, nil
+	}}
+	client := &http.Client{Transport: tr}
+
+	// the test server is not trusted by default
+	_, err := client.Get(server.URL)
+	c.Assert(err, ErrorMatches, ".*certificate.*")
+	c.Check(n, Equals, 1)
+
+	// nor does a second request rebuild the transport
+	_, err = client.Get(server.URL)
+	c.Assert(err, NotNil)
+	c.Check(n, Equals, 1)
+
+	c.Assert(os.MkdirAll(dirs.SnapStoreCertsDir, 0755), IsNil)
+	path := filepath.Join(dirs.SnapStoreCertsDir, "test.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	c.Assert(ioutil.WriteFile(path, cert, 0644), IsNil)
+
+	resp, err := client.Get(server.URL)
+	c.Assert(err, IsNil)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	c.Assert(err, IsNil)
+	c.Check(string(body), Equals, "hello")
+	c.Check(n, Equals, 2)
+
+	// removing the certificate is noticed too
+	c.Assert(os.Remove(path), IsNil)
+	_, err = client.Get(server.URL)
+	c.Assert(err, ErrorMatches, ".*certificate.*")
+	c.Check(n, Equals, 3)
+}
+
+func (s *certsSuite) TestStoreCertsStamp(c *C) {
+	stamp, err := storeCertsStamp()
+	c.Assert(err, IsNil)
+	c.Check(stamp, Equals, "")
+
+	c.Assert(os.MkdirAll(dirs.SnapStoreCertsDir, 0755), IsNil)
+	path := filepath.Join(dirs.SnapStoreCertsDir, "test.pem")
+	c.Assert(ioutil.WriteFile(path, []byte("x"), 0644), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dirs.SnapStoreCertsDir, "ignored"), nil, 0644), IsNil)
+	stamp1, err := storeCertsStamp()
+	c.Assert(err, IsNil)
+	c.Check(stamp1, Matches, `test\.pem:1:\d+`)
+
+	later := time.Now().Add(time.Minute)
+	c.Assert(os.Chtimes(path, later, later), IsNil)
+	stamp2, err := storeCertsStamp()
+	c.Assert(err, IsNil)
+	c.Check(stamp2, Not(Equals), stamp1)
+}
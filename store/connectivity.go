@@ -0,0 +1,228 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package store
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TLSDetails describes the TLS connection to an endpoint.
+type TLSDetails struct {
+	Version     string    `json:"version"`
+	CipherSuite string    `json:"cipher-suite"`
+	ServerName  string    `json:"server-name,omitempty"`
+	Subject     string    `json:"subject,omitempty"`
+	Issuer      string    `json:"issuer,omitempty"`
+	NotAfter    time.Time `json:"not-after,omitempty"`
+}
+
+// ConnectivityResult is the outcome of reaching one of the endpoints the
+// store client uses. Times are in milliseconds.
+type ConnectivityResult struct {
+	Endpoint string `json:"endpoint"`
+	URL      string `json:"url"`
+	// Proxy is the proxy used to reach the endpoint, without credentials
+	Proxy       string      `json:"proxy,omitempty"`
+	Status      int         `json:"status,omitempty"`
+	Error       string      `json:"error,omitempty"`
+	DNSTime     int64       `json:"dns-time"`
+	ConnectTime int64       `json:"connect-time"`
+	TotalTime   int64       `json:"total-time"`
+	TLS         *TLSDetails `json:"tls,omitempty"`
+}
+
+// the snap whose details and download are used to check the
+// reachability of the details endpoint and of the CDN
+const connectivitySnap = "ubuntu-core"
+
+var (
+	connectivityAuthURL = authURL
+	connectivityProxy   = func(u *url.URL) (*url.URL, error) {
+		return proxyConfigFromEnv().proxy(u)
+	}
+	connectivityTimeout = 30 * time.Second
+)
+
+var tlsVersions = map[uint16]string{
+	0x0300: "SSL 3.0",
+	0x0301: "TLS 1.0",
+	0x0302: "TLS 1.1",
+	0x0303: "TLS 1.2",
+	0x0304: "TLS 1.3",
+}
+
+func tlsDetails(state *tls.ConnectionState) *TLSDetails {
+	if state == nil {
+		return nil
+	}
+	details := &TLSDetails{
+		Version:     tlsVersions[state.Version],
+		CipherSuite: fmt.Sprintf("0x%04x", state.CipherSuite),
+		ServerName:  state.ServerName,
+	}
+	if details.Version == "" {
+		details.Version = fmt.Sprintf("0x%04x", state.Version)
+	}
+	if len(state.PeerCertificates) > 0 {
+		cert := state.PeerCertificates[0]
+		details.Subject = cert.Subject.CommonName
+		details.Issuer = cert.Issuer.CommonName
+		details.NotAfter = cert.NotAfter
+	}
+	return details
+}
+
+func milliseconds(d time.Duration) int64 {
+	return int64(d / time.Millisecond)
+}
+
+// checkEndpoint resolves and connects to the host serving u, directly or
+// through the proxy in effect, and then makes the request, timing each
+// step. The body of the response is returned for successful requests.
+func (s *SnapUbuntuStoreRepository) checkEndpoint(endpoint, method string, u *url.URL) (*ConnectivityResult, []byte) {
+	result := &ConnectivityResult{Endpoint: endpoint, URL: u.String()}
+
+	host := u
+	proxy, err := connectivityProxy(u)
+	if err != nil {
+		result.Error = fmt.Sprintf("cannot find proxy: %v", err)
+		return result, nil
+	}
+	if proxy != nil {
+		host = proxy
+		redacted := *proxy
+		redacted.User = nil
+		result.Proxy = redacted.String()
+	}
+	hostname, port, err := net.SplitHostPort(host.Host)
+	if err != nil {
+		hostname, port = strings.Trim(host.Host, "[]"), ""
+	}
+	if port == "" {
+		port = "80"
+		if host.Scheme == "https" {
+			port = "443"
+		}
+	}
+
+	start := time.Now()
+	addrs, err := net.LookupHost(hostname)
+	result.DNSTime = milliseconds(time.Since(start))
+	if err != nil {
+		result.Error = fmt.Sprintf("cannot resolve %s: %v", hostname, err)
+		return result, nil
+	}
+
+	start = time.Now()
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(addrs[0], port), connectivityTimeout)
+	result.ConnectTime = milliseconds(time.Since(start))
+	if err != nil {
+		result.Error = fmt.Sprintf("cannot connect to %s: %v", host.Host, err)
+		return result, nil
+	}
+	conn.Close()
+
+	req, err := http.NewRequest(method, u.String(), nil)
+	if err != nil {
+		result.Error = err.Error()
+		return result, nil
+	}
+	s.setUbuntuStoreHeaders(req, "", nil)
+	client := &http.Client{Transport: s.client.Transport, Timeout: connectivityTimeout}
+
+	start = time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		result.TotalTime = milliseconds(time.Since(start))
+		result.Error = err.Error()
+		return result, nil
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	result.TotalTime = milliseconds(time.Since(start))
+	result.Status = resp.StatusCode
+	result.TLS = tlsDetails(resp.TLS)
+	if err != nil {
+		result.Error = fmt.Sprintf("cannot read response: %v", err)
+		return result, nil
+	}
+	return result, body
+}
+
+// CheckConnectivity checks the reachability of the endpoints used by the
+// store client: the search, details and assertions services of the store,
+// the authentication service, and the CDN snaps are downloaded from.
+// An endpoint is reachable when it answers, whatever the status.
+func (s *SnapUbuntuStoreRepository) CheckConnectivity() []*ConnectivityResult {
+	var results []*ConnectivityResult
+
+	if s.searchURI != nil {
+		result, _ := s.checkEndpoint("search", "GET", s.searchURI)
+		results = append(results, result)
+	}
+
+	var download string
+	if s.detailsURI != nil {
+		u, err := s.detailsURI.Parse(connectivitySnap)
+		if err != nil {
+			return append(results, &ConnectivityResult{Endpoint: "details", Error: err.Error()})
+		}
+		u.RawQuery = s.detailsURI.RawQuery
+		result, body := s.checkEndpoint("details", "GET", u)
+		results = append(results, result)
+		var details snapDetails
+		if result.Status == 200 && json.Unmarshal(body, &details) == nil {
+			download = details.AnonDownloadURL
+		}
+	}
+
+	if s.assertionsURI != nil {
+		result, _ := s.checkEndpoint("assertions", "GET", s.assertionsURI)
+		results = append(results, result)
+	}
+
+	if u, err := url.Parse(connectivityAuthURL()); err == nil {
+		result, _ := s.checkEndpoint("auth", "GET", u)
+		results = append(results, result)
+	}
+
+	if download == "" {
+		results = append(results, &ConnectivityResult{
+			Endpoint: "cdn",
+			Error:    fmt.Sprintf("cannot find download URL of snap %q", connectivitySnap),
+		})
+	} else if u, err := url.Parse(download); err != nil {
+		results = append(results, &ConnectivityResult{Endpoint: "cdn", URL: download, Error: err.Error()})
+	} else {
+		result, _ := s.checkEndpoint("cdn", "HEAD", u)
+		results = append(results, result)
+	}
+
+	return results
+}
@@ -28,30 +28,65 @@ import (
 // Full json available via:
 // curl -s -H "accept: application/hal+json" -H "X-Ubuntu-Release: rolling-core" https://search.apps.ubuntu.com/api/v1/package/ubuntu-core.canonical | python -m json.tool
 type snapDetails struct {
-	AnonDownloadURL string             `json:"anon_download_url,omitempty"`
-	Architectures   []string           `json:"architecture"`
-	Channel         string             `json:"channel,omitempty"`
-	DownloadSha512  string             `json:"download_sha512,omitempty"`
-	Summary         string             `json:"summary,omitempty"`
-	Description     string             `json:"description,omitempty"`
-	DownloadSize    int64              `json:"binary_filesize,omitempty"`
-	DownloadURL     string             `json:"download_url,omitempty"`
-	IconURL         string             `json:"icon_url"`
-	LastUpdated     string             `json:"last_updated,omitempty"`
-	Name            string             `json:"package_name"`
-	Prices          map[string]float64 `json:"prices,omitempty"`
-	Publisher       string             `json:"publisher,omitempty"`
-	RatingsAverage  float64            `json:"ratings_average,omitempty"`
-	Revision        snap.Revision      `json:"revision"`
-	SnapID          string             `json:"snap_id"`
-	SupportURL      string             `json:"support_url"`
-	Title           string             `json:"title"`
-	Type            snap.Type          `json:"content,omitempty"`
-	Version         string             `json:"version"`
+	AnonDownloadURL   string             `json:"anon_download_url,omitempty"`
+	Architectures     []string           `json:"architecture"`
+	Channel           string             `json:"channel,omitempty"`
+	Deltas            []snapDeltaDetails `json:"deltas,omitempty"`
+	DownloadSha512    string             `json:"download_sha512,omitempty"`
+	Summary           string             `json:"summary,omitempty"`
+	Description       string             `json:"description,omitempty"`
+	DownloadSize      int64              `json:"binary_filesize,omitempty"`
+	DownloadURL       string             `json:"download_url,omitempty"`
+	IconURL           string             `json:"icon_url"`
+	LastUpdated       string             `json:"last_updated,omitempty"`
+	Name              string             `json:"package_name"`
+	Prices            map[string]float64 `json:"prices,omitempty"`
+	Publisher         string             `json:"publisher,omitempty"`
+	RatingsAverage    float64            `json:"ratings_average,omitempty"`
+	ReleaseNotesURL   string             `json:"release_notes_url,omitempty"`
+	Revision          snap.Revision      `json:"revision"`
+	RolloutPercentage float64            `json:"rollout_percentage,omitempty"`
+	SnapID            string             `json:"snap_id"`
+	SupportURL        string             `json:"support_url"`
+	Title             string             `json:"title"`
+	Type              snap.Type          `json:"content,omitempty"`
+	Version           string             `json:"version"`
 
 	// FIXME: the store should return "developer" to us instead of
 	//        origin
 	Developer   string `json:"origin" yaml:"origin"`
 	Private     bool   `json:"private" yaml:"private"`
 	Confinement string `json:"confinement" yaml:"confinement"`
+
+	License        string       `json:"license,omitempty"`
+	ScreenshotURLs []string     `json:"screenshot_urls,omitempty"`
+	ChannelMapList []channelMap `json:"channel_maps_list,omitempty"`
+}
+
+// channelMap lists what the channels of a track of a snap offer for
+// an architecture.
+type channelMap struct {
+	Track        string                   `json:"track"`
+	Architecture string                   `json:"architecture"`
+	Map          []channelMapEntryDetails `json:"map"`
+}
+
+// channelMapEntryDetails is what a channel offers; channels without
+// their own release ("tracking" or "closed" info) offer nothing.
+type channelMapEntryDetails struct {
+	Info        string        `json:"info"`
+	Channel     string        `json:"channel"`
+	Revision    snap.Revision `json:"revision"`
+	Version     string        `json:"version"`
+	Confinement string        `json:"confinement"`
+	Size        int64         `json:"binary_filesize"`
+}
+
+// snapDeltaDetails describes a delta the store can serve to go from
+// one revision of a snap to another.
+type snapDeltaDetails struct {
+	FromRevision int    `json:"from_revision"`
+	ToRevision   int    `json:"to_revision"`
+	Format       string `json:"format"`
+	Size         int64  `json:"binary_filesize,omitempty"`
 }
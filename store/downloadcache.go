@@ -0,0 +1,144 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package store
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/snapcore/snapd/fips"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/snap"
+)
+
+// DownloadCache is a DownloadBackend keeping the snap files downloaded
+// and verified by the store on the local disk, under their sha512
+// digest, so they can be used again without network.
+type DownloadCache struct {
+	dir string
+}
+
+// NewDownloadCache returns a DownloadCache keeping its files in dir.
+func NewDownloadCache(dir string) *DownloadCache {
+	return &DownloadCache{dir: dir}
+}
+
+// Name implements DownloadBackend.
+func (c *DownloadCache) Name() string {
+	return "download-cache"
+}
+
+func (c *DownloadCache) path(sha512 string) string {
+	return filepath.Join(c.dir, sha512)
+}
+
+// Has returns whether the cache has the snap file with the given
+// sha512 digest.
+func (c *DownloadCache) Has(sha512 string) bool {
+	return validPeerFile.MatchString(sha512) && osutil.FileExists(c.path(sha512))
+}
+
+// Fetch implements DownloadBackend by copying the cached file with the
+// digest of remoteSnap into w.
+func (c *DownloadCache) Fetch(remoteSnap *snap.Info, w io.Writer, pbar progress.Meter) error {
+	if !c.Has(remoteSnap.Sha512) {
+		return ErrNotAvailable
+	}
+	f, err := os.Open(c.path(remoteSnap.Sha512))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if pbar != nil {
+		if st, err := f.Stat(); err == nil {
+			pbar.Start(remoteSnap.Name(), float64(st.Size()))
+		}
+		w = io.MultiWriter(w, pbar)
+		defer pbar.Finished()
+	}
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// Cache implements DownloadCacher by keeping a copy of the snap file
+// found at path if it matches the digest of remoteSnap. Unlike the
+// PeerCache, it also keeps private snaps as they never leave the device.
+func (c *DownloadCache) Cache(remoteSnap *snap.Info, path string) error {
+	if !validPeerFile.MatchString(remoteSnap.Sha512) {
+		return nil
+	}
+	return cacheVerifiedFile(c.dir, remoteSnap, path)
+}
+
+// Prune removes the cached files whose digest is not in keep.
+func (c *DownloadCache) Prune(keep map[string]bool) error {
+	files, err := ioutil.ReadDir(c.dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, fi := range files {
+		if keep[fi.Name()] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(c.dir, fi.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cacheVerifiedFile copies the snap file found at path into dir under
+// the digest of remoteSnap, after checking it matches.
+func cacheVerifiedFile(dir string, remoteSnap *snap.Info, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := fips.SHA512()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	if digest := hex.EncodeToString(h.Sum(nil)); digest != remoteSnap.Sha512 {
+		return fmt.Errorf("sha512 mismatch (expected %s, got %s)", remoteSnap.Sha512, digest)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	target := filepath.Join(dir, remoteSnap.Sha512)
+	if osutil.FileExists(target) {
+		return nil
+	}
+	tmp := target + ".partial"
+	if err := osutil.CopyFile(path, tmp, osutil.CopyFlagSync|osutil.CopyFlagOverwrite); err != nil {
+		return err
+	}
+	return os.Rename(tmp, target)
+}
@@ -0,0 +1,86 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package store
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/osutil"
+)
+
+type downloadCacheSuite struct {
+	dir   string
+	cache *DownloadCache
+}
+
+var _ = Suite(&downloadCacheSuite{})
+
+func (s *downloadCacheSuite) SetUpTest(c *C) {
+	s.dir = c.MkDir()
+	s.cache = NewDownloadCache(filepath.Join(s.dir, "cache"))
+}
+
+func (s *downloadCacheSuite) cacheFile(c *C, content string) {
+	src := filepath.Join(s.dir, "src.snap")
+	c.Assert(ioutil.WriteFile(src, []byte(content), 0644), IsNil)
+	c.Assert(s.cache.Cache(mockRemoteSnap(content), src), IsNil)
+}
+
+func (s *downloadCacheSuite) TestCacheAndFetch(c *C) {
+	var buf bytes.Buffer
+	c.Check(s.cache.Fetch(mockRemoteSnap("snap content"), &buf, nil), Equals, ErrNotAvailable)
+	c.Check(s.cache.Has(sha512Hex("snap content")), Equals, false)
+
+	s.cacheFile(c, "snap content")
+	c.Check(s.cache.Has(sha512Hex("snap content")), Equals, true)
+	c.Assert(s.cache.Fetch(mockRemoteSnap("snap content"), &buf, nil), IsNil)
+	c.Check(buf.String(), Equals, "snap content")
+}
+
+func (s *downloadCacheSuite) TestCacheVerifies(c *C) {
+	src := filepath.Join(s.dir, "other.snap")
+	c.Assert(ioutil.WriteFile(src, []byte("tampered"), 0644), IsNil)
+	err := s.cache.Cache(mockRemoteSnap("snap content"), src)
+	c.Check(err, ErrorMatches, "sha512 mismatch .*")
+	c.Check(s.cache.Has(sha512Hex("snap content")), Equals, false)
+}
+
+func (s *downloadCacheSuite) TestCacheKeepsPrivate(c *C) {
+	src := filepath.Join(s.dir, "src.snap")
+	c.Assert(ioutil.WriteFile(src, []byte("private"), 0644), IsNil)
+	remoteSnap := mockRemoteSnap("private")
+	remoteSnap.Private = true
+	c.Assert(s.cache.Cache(remoteSnap, src), IsNil)
+	c.Check(s.cache.Has(sha512Hex("private")), Equals, true)
+}
+
+func (s *downloadCacheSuite) TestPrune(c *C) {
+	c.Check(s.cache.Prune(nil), IsNil)
+
+	s.cacheFile(c, "kept")
+	s.cacheFile(c, "dropped")
+	c.Assert(s.cache.Prune(map[string]bool{sha512Hex("kept"): true}), IsNil)
+	c.Check(osutil.FileExists(filepath.Join(s.dir, "cache", sha512Hex("kept"))), Equals, true)
+	c.Check(osutil.FileExists(filepath.Join(s.dir, "cache", sha512Hex("dropped"))), Equals, false)
+}
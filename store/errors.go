@@ -22,7 +22,12 @@ package store
 import (
 	"errors"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
 	"net/url"
+	"strconv"
+	"time"
 )
 
 var (
@@ -46,8 +51,123 @@ var (
 type ErrDownload struct {
 	Code int
 	URL  *url.URL
+	// RetryAfter is how long the server asked to wait before trying
+	// again, if it did.
+	RetryAfter time.Duration
 }
 
 func (e *ErrDownload) Error() string {
 	return fmt.Sprintf("received an unexpected http response code (%v) when trying to download %s", e.Code, e.URL)
 }
+
+// ResponseError is returned when the store answers a request with an
+// unexpected HTTP status code.
+type ResponseError struct {
+	StatusCode int
+	// RetryAfter is how long the store asked to wait before trying
+	// again, if it did.
+	RetryAfter time.Duration
+
+	msg string
+}
+
+func (e *ResponseError) Error() string {
+	return e.msg
+}
+
+func responseErrorf(resp *http.Response, format string, v ...interface{}) *ResponseError {
+	return &ResponseError{
+		StatusCode: resp.StatusCode,
+		RetryAfter: retryAfter(resp, time.Now()),
+		msg:        fmt.Sprintf(format, v...),
+	}
+}
+
+// retryAfter returns how long the Retry-After header of the response,
+// either in seconds or a date, asks to wait.
+func retryAfter(resp *http.Response, now time.Time) time.Duration {
+	h := resp.Header.Get("Retry-After")
+	if h == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(h); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(h); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// ErrorKind classifies the errors from the store, or from reaching it,
+// by what can be done about them.
+type ErrorKind string
+
+// The kinds of errors from the store.
+const (
+	// ErrorPermanent is for errors that trying again will not fix.
+	ErrorPermanent ErrorKind = "permanent"
+	// ErrorRetryable is for network and server errors that might
+	// go away.
+	ErrorRetryable ErrorKind = "retryable"
+	// ErrorRateLimited is for when the store asks to slow down; see
+	// RetryAfter.
+	ErrorRateLimited ErrorKind = "rate-limited"
+	// ErrorAuthExpired is for when the credentials used are not
+	// accepted anymore.
+	ErrorAuthExpired ErrorKind = "auth-expired"
+)
+
+func statusKind(code int) ErrorKind {
+	switch code {
+	case http.StatusUnauthorized:
+		return ErrorAuthExpired
+	case 429: // Too Many Requests
+		return ErrorRateLimited
+	case http.StatusRequestTimeout, http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return ErrorRetryable
+	}
+	return ErrorPermanent
+}
+
+// Kind returns the kind of the given error returned by the store, or ""
+// if there is no error.
+func Kind(err error) ErrorKind {
+	switch e := err.(type) {
+	case nil:
+		return ""
+	case *ResponseError:
+		return statusKind(e.StatusCode)
+	case *ErrDownload:
+		return statusKind(e.Code)
+	case *url.Error:
+		return Kind(e.Err)
+	case net.Error:
+		return ErrorRetryable
+	}
+	switch err {
+	case ErrInvalidCredentials:
+		return ErrorAuthExpired
+	case io.ErrUnexpectedEOF:
+		return ErrorRetryable
+	}
+	return ErrorPermanent
+}
+
+// IsRetryable returns whether trying again might get past the error.
+func IsRetryable(err error) bool {
+	kind := Kind(err)
+	return kind == ErrorRetryable || kind == ErrorRateLimited
+}
+
+// RetryAfter returns how long the store asked to wait before trying
+// again, if it did along with the error.
+func RetryAfter(err error) time.Duration {
+	switch e := err.(type) {
+	case *ResponseError:
+		return e.RetryAfter
+	case *ErrDownload:
+		return e.RetryAfter
+	}
+	return 0
+}
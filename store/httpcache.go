@@ -0,0 +1,227 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package store
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/snapcore/snapd/fips"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+)
+
+// defaultMaxCacheEntries is how many responses a CachingTransport keeps
+// unless told otherwise.
+const defaultMaxCacheEntries = 200
+
+// the request headers that select a different response from the store
+var cacheKeyHeaders = []string{
+	"Accept",
+	"Authorization",
+	"X-Ubuntu-Architecture",
+	"X-Ubuntu-Device-Channel",
+	"X-Ubuntu-Release",
+	"X-Ubuntu-Store",
+	"X-Ubuntu-Wire-Protocol",
+}
+
+// the response headers that are kept along with a cached body
+var cachedHeaders = []string{
+	"Content-Type",
+	"X-Suggested-Currency",
+}
+
+// cacheEntry is what a CachingTransport keeps on disk for a response.
+type cacheEntry struct {
+	ETag   string            `json:"etag"`
+	Header map[string]string `json:"header"`
+	Body   []byte            `json:"body"`
+}
+
+// CachingTransport is an http.RoundTripper that keeps on disk the
+// successful responses carrying an ETag, and revalidates them with
+// If-None-Match on the next identical request. A "304 Not Modified"
+// answer is then turned back into the cached response, so the store
+// only sends what changed.
+type CachingTransport struct {
+	Transport http.RoundTripper
+	// Dir is where the responses are kept; no caching is done if empty.
+	Dir string
+	// MaxEntries is how many responses are kept at most, the least
+	// recently used going first.
+	MaxEntries int
+}
+
+// RoundTrip is from the http.RoundTripper interface.
+func (tr *CachingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if tr.Dir == "" || (req.Method != "GET" && req.Method != "POST") {
+		return tr.Transport.RoundTrip(req)
+	}
+
+	// the body of the request is part of the key, so read it and
+	// hand a fresh copy on
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	outreq := new(http.Request)
+	*outreq = *req
+	outreq.Header = make(http.Header, len(req.Header))
+	for k, v := range req.Header {
+		outreq.Header[k] = v
+	}
+	if req.Body != nil {
+		outreq.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	entryPath := filepath.Join(tr.Dir, cacheKey(req, body))
+	entry, err := readCacheEntry(entryPath)
+	if err != nil && !os.IsNotExist(err) {
+		logger.Noticef("cannot read cached store response: %v", err)
+	}
+	if entry != nil {
+		outreq.Header.Set("If-None-Match", entry.ETag)
+	}
+
+	resp, err := tr.Transport.RoundTrip(outreq)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && entry != nil:
+		resp.Body.Close()
+		// mark it as recently used
+		now := time.Now()
+		os.Chtimes(entryPath, now, now)
+		return entry.response(req), nil
+	case resp.StatusCode == http.StatusOK && resp.Header.Get("ETag") != "":
+		data, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = ioutil.NopCloser(bytes.NewReader(data))
+		tr.store(entryPath, resp, data)
+	}
+
+	return resp, nil
+}
+
+func cacheKey(req *http.Request, body []byte) string {
+	h := fips.SHA256()
+	io.WriteString(h, req.Method+" "+req.URL.String()+"\n")
+	for _, k := range cacheKeyHeaders {
+		io.WriteString(h, k+": "+req.Header.Get(k)+"\n")
+	}
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func readCacheEntry(path string) (*cacheEntry, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// response rebuilds the response to req from the entry.
+func (entry *cacheEntry) response(req *http.Request) *http.Response {
+	header := make(http.Header)
+	for k, v := range entry.Header {
+		header.Set(k, v)
+	}
+	header.Set("ETag", entry.ETag)
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(entry.Body)),
+		ContentLength: int64(len(entry.Body)),
+		Request:       req,
+	}
+}
+
+func (tr *CachingTransport) store(path string, resp *http.Response, body []byte) {
+	entry := cacheEntry{
+		ETag:   resp.Header.Get("ETag"),
+		Header: make(map[string]string),
+		Body:   body,
+	}
+	for _, k := range cachedHeaders {
+		if v := resp.Header.Get(k); v != "" {
+			entry.Header[k] = v
+		}
+	}
+	data, err := json.Marshal(&entry)
+	if err != nil {
+		logger.Noticef("cannot cache store response: %v", err)
+		return
+	}
+	if err := os.MkdirAll(tr.Dir, 0700); err != nil {
+		logger.Noticef("cannot cache store response: %v", err)
+		return
+	}
+	if err := osutil.AtomicWriteFile(path, data, 0600, 0); err != nil {
+		logger.Noticef("cannot cache store response: %v", err)
+		return
+	}
+	tr.prune()
+}
+
+// prune removes the least recently used responses above MaxEntries.
+func (tr *CachingTransport) prune() {
+	max := tr.MaxEntries
+	if max <= 0 {
+		max = defaultMaxCacheEntries
+	}
+	fis, err := ioutil.ReadDir(tr.Dir)
+	if err != nil || len(fis) <= max {
+		return
+	}
+	sort.Sort(byModTime(fis))
+	for _, fi := range fis[:len(fis)-max] {
+		if err := os.Remove(filepath.Join(tr.Dir, fi.Name())); err != nil {
+			logger.Noticef("cannot prune cached store response: %v", err)
+		}
+	}
+}
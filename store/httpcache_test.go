@@ -0,0 +1,140 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package store_test
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/store"
+)
+
+type httpCacheSuite struct {
+	dir string
+}
+
+var _ = Suite(&httpCacheSuite{})
+
+func (s *httpCacheSuite) SetUpTest(c *C) {
+	s.dir = c.MkDir()
+}
+
+func (s *httpCacheSuite) client(maxEntries int) *http.Client {
+	return &http.Client{Transport: &store.CachingTransport{
+		Transport:  http.DefaultTransport,
+		Dir:        s.dir,
+		MaxEntries: maxEntries,
+	}}
+}
+
+func (s *httpCacheSuite) TestRevalidatesWithETag(c *C) {
+	var inm []string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inm = append(inm, r.Header.Get("If-None-Match"))
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("X-Suggested-Currency", "EUR")
+		io.WriteString(w, "payload")
+	}))
+	defer mockServer.Close()
+
+	client := s.client(0)
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(mockServer.URL + "/details")
+		c.Assert(err, IsNil)
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		c.Assert(err, IsNil)
+		c.Check(resp.StatusCode, Equals, http.StatusOK)
+		c.Check(string(body), Equals, "payload")
+		c.Check(resp.Header.Get("X-Suggested-Currency"), Equals, "EUR")
+	}
+	c.Check(inm, DeepEquals, []string{"", `"v1"`})
+}
+
+func (s *httpCacheSuite) TestNoETagNoCache(c *C) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Header.Get("If-None-Match"), Equals, "")
+		io.WriteString(w, "payload")
+	}))
+	defer mockServer.Close()
+
+	client := s.client(0)
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(mockServer.URL)
+		c.Assert(err, IsNil)
+		resp.Body.Close()
+	}
+	matches, err := filepath.Glob(filepath.Join(s.dir, "*"))
+	c.Assert(err, IsNil)
+	c.Check(matches, HasLen, 0)
+}
+
+func (s *httpCacheSuite) TestBodyIsPartOfTheKey(c *C) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		c.Assert(err, IsNil)
+		if r.Header.Get("If-None-Match") != "" {
+			c.Check(r.Header.Get("If-None-Match"), Equals, `"`+string(body)+`"`)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"`+string(body)+`"`)
+		w.Write(body)
+	}))
+	defer mockServer.Close()
+
+	client := s.client(0)
+	for _, payload := range []string{"a", "b", "a", "b"} {
+		resp, err := client.Post(mockServer.URL, "application/json", strings.NewReader(payload))
+		c.Assert(err, IsNil)
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		c.Assert(err, IsNil)
+		c.Check(string(body), Equals, payload)
+	}
+}
+
+func (s *httpCacheSuite) TestPrunesOldEntries(c *C) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"`+r.URL.Path+`"`)
+		io.WriteString(w, r.URL.Path)
+	}))
+	defer mockServer.Close()
+
+	client := s.client(2)
+	for _, p := range []string{"/a", "/b", "/c"} {
+		resp, err := client.Get(mockServer.URL + p)
+		c.Assert(err, IsNil)
+		resp.Body.Close()
+	}
+	matches, err := filepath.Glob(filepath.Join(s.dir, "*"))
+	c.Assert(err, IsNil)
+	c.Check(matches, HasLen, 2)
+}
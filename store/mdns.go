@@ -0,0 +1,317 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package store
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/snapcore/snapd/logger"
+)
+
+// A minimal multicast DNS implementation, just enough for peers on the
+// same link to find each other's snap caches: responders answer queries
+// for peerServiceName with a TXT record holding the port their cache is
+// served on, and the address of the peer is taken from the response.
+
+const (
+	peerServiceName = "_snapd-peer._tcp.local."
+
+	mdnsTypeTXT = 16
+	mdnsTypeANY = 255
+	mdnsClassIN = 1
+
+	mdnsResponseFlags = 0x8400
+)
+
+var mdnsGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+var errBadMessage = errors.New("malformed mdns message")
+
+type mdnsRecord struct {
+	name  string
+	rtype uint16
+	txt   []string
+}
+
+type mdnsMessage struct {
+	response  bool
+	questions []mdnsRecord
+	answers   []mdnsRecord
+}
+
+func appendName(b []byte, name string) []byte {
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		b = append(b, byte(len(label)))
+		b = append(b, label...)
+	}
+	return append(b, 0)
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+func (m *mdnsMessage) pack() []byte {
+	b := make([]byte, 4, 512)
+	if m.response {
+		binary.BigEndian.PutUint16(b[2:], mdnsResponseFlags)
+	}
+	b = appendUint16(b, uint16(len(m.questions)))
+	b = appendUint16(b, uint16(len(m.answers)))
+	b = appendUint16(b, 0)
+	b = appendUint16(b, 0)
+	for _, q := range m.questions {
+		b = appendName(b, q.name)
+		b = appendUint16(b, q.rtype)
+		b = appendUint16(b, mdnsClassIN)
+	}
+	for _, a := range m.answers {
+		b = appendName(b, a.name)
+		b = appendUint16(b, a.rtype)
+		b = appendUint16(b, mdnsClassIN)
+		// ttl
+		b = append(b, 0, 0, 0, 120)
+		var rdata []byte
+		for _, s := range a.txt {
+			rdata = append(rdata, byte(len(s)))
+			rdata = append(rdata, s...)
+		}
+		b = appendUint16(b, uint16(len(rdata)))
+		b = append(b, rdata...)
+	}
+	return b
+}
+
+// readName reads a possibly compressed domain name starting at off and
+// returns it with the offset just past it.
+func readName(msg []byte, off int) (string, int, error) {
+	var labels []string
+	end := -1
+	for hops := 0; hops < 16; {
+		if off >= len(msg) {
+			return "", 0, errBadMessage
+		}
+		l := int(msg[off])
+		switch {
+		case l == 0:
+			if end < 0 {
+				end = off + 1
+			}
+			return strings.Join(labels, ".") + ".", end, nil
+		case l&0xC0 == 0xC0:
+			if off+1 >= len(msg) {
+				return "", 0, errBadMessage
+			}
+			if end < 0 {
+				end = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(msg[off:]) & 0x3FFF)
+			hops++
+		default:
+			if off+1+l > len(msg) {
+				return "", 0, errBadMessage
+			}
+			labels = append(labels, string(msg[off+1:off+1+l]))
+			off += 1 + l
+		}
+	}
+	return "", 0, errBadMessage
+}
+
+func unpackMdnsMessage(msg []byte) (*mdnsMessage, error) {
+	if len(msg) < 12 {
+		return nil, errBadMessage
+	}
+	m := &mdnsMessage{response: msg[2]&0x80 != 0}
+	qdcount := int(binary.BigEndian.Uint16(msg[4:]))
+	ancount := int(binary.BigEndian.Uint16(msg[6:]))
+
+	off := 12
+	for i := 0; i < qdcount; i++ {
+		name, next, err := readName(msg, off)
+		if err != nil {
+			return nil, err
+		}
+		if next+4 > len(msg) {
+			return nil, errBadMessage
+		}
+		m.questions = append(m.questions, mdnsRecord{name: name, rtype: binary.BigEndian.Uint16(msg[next:])})
+		off = next + 4
+	}
+	for i := 0; i < ancount; i++ {
+		name, next, err := readName(msg, off)
+		if err != nil {
+			return nil, err
+		}
+		if next+10 > len(msg) {
+			return nil, errBadMessage
+		}
+		rec := mdnsRecord{name: name, rtype: binary.BigEndian.Uint16(msg[next:])}
+		rdlen := int(binary.BigEndian.Uint16(msg[next+8:]))
+		rdata := next + 10
+		if rdata+rdlen > len(msg) {
+			return nil, errBadMessage
+		}
+		if rec.rtype == mdnsTypeTXT {
+			for p := rdata; p < rdata+rdlen; {
+				l := int(msg[p])
+				if p+1+l > rdata+rdlen {
+					return nil, errBadMessage
+				}
+				rec.txt = append(rec.txt, string(msg[p+1:p+1+l]))
+				p += 1 + l
+			}
+		}
+		m.answers = append(m.answers, rec)
+		off = rdata + rdlen
+	}
+	return m, nil
+}
+
+// mdnsResponder answers peer queries on the local link.
+type mdnsResponder struct {
+	conn *net.UDPConn
+	port int
+	wg   sync.WaitGroup
+}
+
+func newMdnsResponder(port int) (*mdnsResponder, error) {
+	conn, err := net.ListenMulticastUDP("udp4", nil, mdnsGroup)
+	if err != nil {
+		return nil, fmt.Errorf("cannot listen for mdns queries: %v", err)
+	}
+	r := &mdnsResponder{conn: conn, port: port}
+	r.wg.Add(1)
+	go r.serve()
+	return r, nil
+}
+
+func (r *mdnsResponder) answer() []byte {
+	m := &mdnsMessage{
+		response: true,
+		answers: []mdnsRecord{{
+			name:  peerServiceName,
+			rtype: mdnsTypeTXT,
+			txt:   []string{"port=" + strconv.Itoa(r.port)},
+		}},
+	}
+	return m.pack()
+}
+
+func (r *mdnsResponder) serve() {
+	defer r.wg.Done()
+	buf := make([]byte, 9000)
+	for {
+		n, from, err := r.conn.ReadFromUDP(buf)
+		if err != nil {
+			// closed
+			return
+		}
+		m, err := unpackMdnsMessage(buf[:n])
+		if err != nil || m.response {
+			continue
+		}
+		for _, q := range m.questions {
+			if q.name != peerServiceName || (q.rtype != mdnsTypeTXT && q.rtype != mdnsTypeANY) {
+				continue
+			}
+			// answer the querier directly, it is not listening
+			// on the multicast group
+			if _, err := r.conn.WriteToUDP(r.answer(), from); err != nil {
+				logger.Noticef("cannot answer mdns query from %v: %v", from, err)
+			}
+			break
+		}
+	}
+}
+
+func (r *mdnsResponder) Close() error {
+	err := r.conn.Close()
+	r.wg.Wait()
+	return err
+}
+
+// discoverPeers queries the local link for peer caches and returns the
+// base URLs of the ones that answered within the timeout.
+func discoverPeers(timeout time.Duration) ([]string, error) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	query := &mdnsMessage{questions: []mdnsRecord{{name: peerServiceName, rtype: mdnsTypeTXT}}}
+	if _, err := conn.WriteToUDP(query.pack(), mdnsGroup); err != nil {
+		return nil, err
+	}
+	if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+
+	var peers []string
+	seen := make(map[string]bool)
+	buf := make([]byte, 9000)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
+				return peers, nil
+			}
+			return peers, err
+		}
+		m, err := unpackMdnsMessage(buf[:n])
+		if err != nil || !m.response {
+			continue
+		}
+		for _, peer := range peersFromAnswer(m, from.IP) {
+			if !seen[peer] {
+				seen[peer] = true
+				peers = append(peers, peer)
+			}
+		}
+	}
+}
+
+func peersFromAnswer(m *mdnsMessage, ip net.IP) []string {
+	var peers []string
+	for _, a := range m.answers {
+		if a.name != peerServiceName || a.rtype != mdnsTypeTXT {
+			continue
+		}
+		for _, txt := range a.txt {
+			if !strings.HasPrefix(txt, "port=") {
+				continue
+			}
+			port, err := strconv.Atoi(strings.TrimPrefix(txt, "port="))
+			if err != nil || port <= 0 || port > 65535 {
+				continue
+			}
+			peers = append(peers, "http://"+net.JoinHostPort(ip.String(), strconv.Itoa(port)))
+		}
+	}
+	return peers
+}
@@ -0,0 +1,314 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package store
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/snapcore/snapd/fips"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/snap"
+)
+
+// peerDiscoveryTimeout is how long to wait for peers to answer.
+var peerDiscoveryTimeout = 2 * time.Second
+
+// discover finds the base URLs of the peer caches on the local link.
+var discover = discoverPeers
+
+var validPeerFile = regexp.MustCompile("^[0-9a-f]{128}$")
+
+// defaultPeerCacheSize is how much disk the files of the peer cache use
+// at most, the least recently used being dropped first.
+const defaultPeerCacheSize = 2 * 1024 * 1024 * 1024
+
+// PeerCache is a DownloadBackend that shares verified snap files between
+// devices on the same link. Files are cached under their sha512 digest,
+// served over HTTP and advertised using multicast DNS; a device fetching
+// a snap asks its peers first and only goes to the store if none of them
+// has it.
+type PeerCache struct {
+	dir     string
+	maxSize int64
+	client  *http.Client
+
+	// mu serializes changes to the cached files
+	mu sync.Mutex
+
+	listener  net.Listener
+	responder *mdnsResponder
+}
+
+// NewPeerCache returns a PeerCache keeping its files in dir.
+func NewPeerCache(dir string) *PeerCache {
+	return &PeerCache{
+		dir:     dir,
+		maxSize: defaultPeerCacheSize,
+		client:  &http.Client{Timeout: 10 * time.Minute},
+	}
+}
+
+// Name implements DownloadBackend.
+func (c *PeerCache) Name() string {
+	return "peer-cache"
+}
+
+// Fetch implements DownloadBackend by asking the peers on the local link
+// for the file with the digest of remoteSnap. What a peer sends is
+// checked against the size and digest of remoteSnap before anything is
+// written to w, the next peer being asked if it does not match.
+func (c *PeerCache) Fetch(remoteSnap *snap.Info, w io.Writer, pbar progress.Meter) error {
+	if remoteSnap.Size <= 0 {
+		// nothing to bound what peers send with
+		return ErrNotAvailable
+	}
+	peers, err := discover(peerDiscoveryTimeout)
+	if err != nil {
+		logger.Noticef("cannot discover peer caches: %v", err)
+	}
+	for _, peer := range peers {
+		f, err := c.fetchFromPeer(peer, remoteSnap, pbar)
+		if err == ErrNotAvailable {
+			continue
+		}
+		if err != nil {
+			logger.Noticef("cannot fetch %q from peer %s: %v", remoteSnap.Name(), peer, err)
+			continue
+		}
+		_, err = io.Copy(w, f)
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	return ErrNotAvailable
+}
+
+// fetchFromPeer downloads the file with the digest of remoteSnap from
+// the peer into a temporary file, returned open at its start once its
+// size and digest were checked.
+func (c *PeerCache) fetchFromPeer(peer string, remoteSnap *snap.Info, pbar progress.Meter) (*os.File, error) {
+	resp, err := c.client.Get(peer + "/snaps/" + remoteSnap.Sha512)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, ErrNotAvailable
+	}
+	if resp.ContentLength > remoteSnap.Size {
+		return nil, fmt.Errorf("size mismatch (expected %d, got %d)", remoteSnap.Size, resp.ContentLength)
+	}
+
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return nil, err
+	}
+	f, err := ioutil.TempFile(c.dir, "fetch-")
+	if err != nil {
+		return nil, err
+	}
+	if err := copyFromPeer(f, resp.Body, remoteSnap, pbar); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return f, nil
+}
+
+// copyFromPeer copies what a peer sends into f, checking it against the
+// size and digest of remoteSnap, and rewinds f.
+func copyFromPeer(f *os.File, body io.Reader, remoteSnap *snap.Info, pbar progress.Meter) error {
+	h := fips.SHA512()
+	var dst io.Writer = io.MultiWriter(f, h)
+	if pbar != nil {
+		pbar.Start(remoteSnap.Name(), float64(remoteSnap.Size))
+		dst = io.MultiWriter(dst, pbar)
+	}
+	// one byte more than expected is enough to know it is too big
+	n, err := io.Copy(dst, io.LimitReader(body, remoteSnap.Size+1))
+	if pbar != nil {
+		pbar.Finished()
+	}
+	if err != nil {
+		return err
+	}
+	if n > remoteSnap.Size {
+		return fmt.Errorf("size mismatch (expected %d, got more)", remoteSnap.Size)
+	}
+	if n < remoteSnap.Size {
+		return fmt.Errorf("size mismatch (expected %d, got %d)", remoteSnap.Size, n)
+	}
+	if digest := hex.EncodeToString(h.Sum(nil)); digest != remoteSnap.Sha512 {
+		return fmt.Errorf("sha512 mismatch (expected %s, got %s)", remoteSnap.Sha512, digest)
+	}
+	_, err = f.Seek(0, 0)
+	return err
+}
+
+// Cache implements DownloadCacher by keeping a copy of the snap file
+// found at path if it matches the digest of remoteSnap. Private snaps
+// are never shared. The least recently used files are then dropped
+// to keep the cache within its size.
+func (c *PeerCache) Cache(remoteSnap *snap.Info, path string) error {
+	if remoteSnap.Private || !validPeerFile.MatchString(remoteSnap.Sha512) {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := cacheVerifiedFile(c.dir, remoteSnap, path); err != nil {
+		return err
+	}
+	// using it counts as using it last
+	now := time.Now()
+	if err := os.Chtimes(filepath.Join(c.dir, remoteSnap.Sha512), now, now); err != nil {
+		return err
+	}
+	return c.evict()
+}
+
+type byModTime []os.FileInfo
+
+func (a byModTime) Len() int           { return len(a) }
+func (a byModTime) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byModTime) Less(i, j int) bool { return a[i].ModTime().Before(a[j].ModTime()) }
+
+// cachedFiles returns the files of the cache, least recently used
+// first.
+func (c *PeerCache) cachedFiles() ([]os.FileInfo, error) {
+	all, err := ioutil.ReadDir(c.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	files := all[:0]
+	for _, fi := range all {
+		if validPeerFile.MatchString(fi.Name()) {
+			files = append(files, fi)
+		}
+	}
+	sort.Sort(byModTime(files))
+	return files, nil
+}
+
+// evict drops the least recently used files until the cache fits in
+// its size. Note that the caller must hold mu.
+func (c *PeerCache) evict() error {
+	files, err := c.cachedFiles()
+	if err != nil {
+		return err
+	}
+	var size int64
+	for _, fi := range files {
+		size += fi.Size()
+	}
+	for _, fi := range files {
+		if size <= c.maxSize {
+			break
+		}
+		if err := os.Remove(filepath.Join(c.dir, fi.Name())); err != nil {
+			return err
+		}
+		size -= fi.Size()
+	}
+	return nil
+}
+
+// Prune removes the cached files whose digest is not in keep, as those
+// of the revisions removed from the system.
+func (c *PeerCache) Prune(keep map[string]bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	files, err := c.cachedFiles()
+	if err != nil {
+		return err
+	}
+	for _, fi := range files {
+		if keep[fi.Name()] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(c.dir, fi.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ServeHTTP serves the cached files to peers as /snaps/<sha512>.
+func (c *PeerCache) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/snaps/")
+	if r.Method != "GET" || name == r.URL.Path || !validPeerFile.MatchString(name) {
+		http.NotFound(w, r)
+		return
+	}
+	path := filepath.Join(c.dir, name)
+	// serving it counts as using it
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	http.ServeFile(w, r, path)
+}
+
+// Start serves the cache on addr and advertises it on the local link.
+func (c *PeerCache) Start(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("cannot serve peer cache: %v", err)
+	}
+	responder, err := newMdnsResponder(l.Addr().(*net.TCPAddr).Port)
+	if err != nil {
+		l.Close()
+		return err
+	}
+	c.listener = l
+	c.responder = responder
+
+	go http.Serve(l, c)
+
+	return nil
+}
+
+// Stop stops serving and advertising the cache.
+func (c *PeerCache) Stop() error {
+	if c.listener == nil {
+		return nil
+	}
+	err := c.listener.Close()
+	if rerr := c.responder.Close(); err == nil {
+		err = rerr
+	}
+	c.listener = nil
+	c.responder = nil
+	return err
+}
@@ -0,0 +1,273 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package store
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/osutil"
+)
+
+type peerCacheSuite struct {
+	dir       string
+	cache     *PeerCache
+	restorers []func()
+}
+
+var _ = Suite(&peerCacheSuite{})
+
+func (s *peerCacheSuite) SetUpTest(c *C) {
+	s.dir = c.MkDir()
+	s.cache = NewPeerCache(filepath.Join(s.dir, "cache"))
+	origDiscover := discover
+	s.restorers = []func(){func() { discover = origDiscover }}
+}
+
+func (s *peerCacheSuite) TearDownTest(c *C) {
+	for _, restore := range s.restorers {
+		restore()
+	}
+}
+
+func (s *peerCacheSuite) cacheFile(c *C, content string) {
+	src := filepath.Join(s.dir, "src.snap")
+	c.Assert(ioutil.WriteFile(src, []byte(content), 0644), IsNil)
+	c.Assert(s.cache.Cache(mockRemoteSnap(content), src), IsNil)
+}
+
+func (s *peerCacheSuite) TestCacheVerifies(c *C) {
+	s.cacheFile(c, "snap content")
+	c.Check(osutil.FileExists(filepath.Join(s.dir, "cache", sha512Hex("snap content"))), Equals, true)
+
+	src := filepath.Join(s.dir, "other.snap")
+	c.Assert(ioutil.WriteFile(src, []byte("tampered"), 0644), IsNil)
+	err := s.cache.Cache(mockRemoteSnap("snap content 2"), src)
+	c.Check(err, ErrorMatches, "sha512 mismatch .*")
+	c.Check(osutil.FileExists(filepath.Join(s.dir, "cache", sha512Hex("snap content 2"))), Equals, false)
+}
+
+func (s *peerCacheSuite) TestCacheSkipsPrivate(c *C) {
+	src := filepath.Join(s.dir, "src.snap")
+	c.Assert(ioutil.WriteFile(src, []byte("private"), 0644), IsNil)
+	remoteSnap := mockRemoteSnap("private")
+	remoteSnap.Private = true
+	c.Assert(s.cache.Cache(remoteSnap, src), IsNil)
+	_, err := os.Stat(filepath.Join(s.dir, "cache"))
+	c.Check(os.IsNotExist(err), Equals, true)
+}
+
+func (s *peerCacheSuite) TestServeHTTP(c *C) {
+	s.cacheFile(c, "snap content")
+
+	for _, t := range []struct {
+		path   string
+		status int
+	}{
+		{"/snaps/" + sha512Hex("snap content"), 200},
+		{"/snaps/" + sha512Hex("missing"), 404},
+		{"/snaps/../../etc/passwd", 404},
+		{"/" + sha512Hex("snap content"), 404},
+	} {
+		req, err := http.NewRequest("GET", t.path, nil)
+		c.Assert(err, IsNil)
+		rec := httptest.NewRecorder()
+		s.cache.ServeHTTP(rec, req)
+		c.Check(rec.Code, Equals, t.status, Commentf(t.path))
+	}
+}
+
+func (s *peerCacheSuite) TestFetchFromPeer(c *C) {
+	peer := NewPeerCache(filepath.Join(s.dir, "peer"))
+	src := filepath.Join(s.dir, "src.snap")
+	c.Assert(ioutil.WriteFile(src, []byte("shared"), 0644), IsNil)
+	c.Assert(peer.Cache(mockRemoteSnap("shared"), src), IsNil)
+
+	empty := httptest.NewServer(NewPeerCache(filepath.Join(s.dir, "empty")))
+	defer empty.Close()
+	full := httptest.NewServer(peer)
+	defer full.Close()
+
+	discover = func(timeout time.Duration) ([]string, error) {
+		c.Check(timeout, Equals, peerDiscoveryTimeout)
+		return []string{empty.URL, full.URL}, nil
+	}
+
+	var buf bytes.Buffer
+	c.Assert(s.cache.Fetch(mockRemoteSnap("shared"), &buf, nil), IsNil)
+	c.Check(buf.String(), Equals, "shared")
+
+	buf.Reset()
+	c.Check(s.cache.Fetch(mockRemoteSnap("nobody has it"), &buf, nil), Equals, ErrNotAvailable)
+	c.Check(buf.Len(), Equals, 0)
+}
+
+func (s *peerCacheSuite) TestFetchSkipsBadPeers(c *C) {
+	var served []string
+	serve := func(content string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			served = append(served, content)
+			w.Write([]byte(content))
+		}))
+	}
+	tampered := serve("sharex")
+	defer tampered.Close()
+	oversized := serve("shared and much more")
+	defer oversized.Close()
+	good := serve("shared")
+	defer good.Close()
+
+	discover = func(timeout time.Duration) ([]string, error) {
+		return []string{tampered.URL, oversized.URL, good.URL}, nil
+	}
+
+	var buf bytes.Buffer
+	c.Assert(s.cache.Fetch(mockRemoteSnap("shared"), &buf, nil), IsNil)
+	c.Check(buf.String(), Equals, "shared")
+	c.Check(served, DeepEquals, []string{"sharex", "shared and much more", "shared"})
+
+	// nothing left behind
+	files, err := ioutil.ReadDir(filepath.Join(s.dir, "cache"))
+	c.Assert(err, IsNil)
+	c.Check(files, HasLen, 0)
+
+	// none of them has it right, so it comes from the store
+	discover = func(timeout time.Duration) ([]string, error) {
+		return []string{tampered.URL, oversized.URL}, nil
+	}
+	buf.Reset()
+	c.Check(s.cache.Fetch(mockRemoteSnap("shared"), &buf, nil), Equals, ErrNotAvailable)
+	c.Check(buf.Len(), Equals, 0)
+}
+
+func (s *peerCacheSuite) TestFetchCapsAtSize(c *C) {
+	var sent int64
+	big := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		chunk := bytes.Repeat([]byte("x"), 1024)
+		for i := 0; i < 1024; i++ {
+			n, err := w.Write(chunk)
+			sent += int64(n)
+			if err != nil {
+				return
+			}
+		}
+	}))
+	defer big.Close()
+	discover = func(timeout time.Duration) ([]string, error) {
+		return []string{big.URL}, nil
+	}
+
+	var buf bytes.Buffer
+	c.Check(s.cache.Fetch(mockRemoteSnap("small"), &buf, nil), Equals, ErrNotAvailable)
+	c.Check(buf.Len(), Equals, 0)
+
+	// without a size peers are not asked
+	remoteSnap := mockRemoteSnap("small")
+	remoteSnap.Size = 0
+	discover = func(timeout time.Duration) ([]string, error) {
+		c.Fatal("unexpected discovery")
+		return nil, nil
+	}
+	c.Check(s.cache.Fetch(remoteSnap, &buf, nil), Equals, ErrNotAvailable)
+}
+
+func (s *peerCacheSuite) TestCacheEvictsLeastRecentlyUsed(c *C) {
+	s.cache.maxSize = 12
+	s.cacheFile(c, "first")
+	s.cacheFile(c, "second")
+	first := filepath.Join(s.dir, "cache", sha512Hex("first"))
+	second := filepath.Join(s.dir, "cache", sha512Hex("second"))
+	past := time.Now().Add(-time.Hour)
+	c.Assert(os.Chtimes(second, past, past), IsNil)
+	c.Assert(os.Chtimes(first, past.Add(time.Minute), past.Add(time.Minute)), IsNil)
+
+	// serving first makes second the least recently used
+	req, err := http.NewRequest("GET", "/snaps/"+sha512Hex("first"), nil)
+	c.Assert(err, IsNil)
+	s.cache.ServeHTTP(httptest.NewRecorder(), req)
+
+	s.cacheFile(c, "third")
+	c.Check(osutil.FileExists(first), Equals, true)
+	c.Check(osutil.FileExists(second), Equals, false)
+	c.Check(osutil.FileExists(filepath.Join(s.dir, "cache", sha512Hex("third"))), Equals, true)
+}
+
+func (s *peerCacheSuite) TestPrune(c *C) {
+	s.cacheFile(c, "kept")
+	s.cacheFile(c, "removed")
+	other := filepath.Join(s.dir, "cache", "fetch-123")
+	c.Assert(ioutil.WriteFile(other, nil, 0644), IsNil)
+
+	c.Assert(s.cache.Prune(map[string]bool{sha512Hex("kept"): true}), IsNil)
+	c.Check(osutil.FileExists(filepath.Join(s.dir, "cache", sha512Hex("kept"))), Equals, true)
+	c.Check(osutil.FileExists(filepath.Join(s.dir, "cache", sha512Hex("removed"))), Equals, false)
+	// only cached snap files are considered
+	c.Check(osutil.FileExists(other), Equals, true)
+
+	c.Check(NewPeerCache(filepath.Join(s.dir, "missing")).Prune(nil), IsNil)
+}
+
+func (s *peerCacheSuite) TestMdnsRoundTrip(c *C) {
+	query := &mdnsMessage{questions: []mdnsRecord{{name: peerServiceName, rtype: mdnsTypeTXT}}}
+	m, err := unpackMdnsMessage(query.pack())
+	c.Assert(err, IsNil)
+	c.Check(m.response, Equals, false)
+	c.Check(m.questions, DeepEquals, []mdnsRecord{{name: peerServiceName, rtype: mdnsTypeTXT}})
+
+	r := &mdnsResponder{port: 8123}
+	m, err = unpackMdnsMessage(r.answer())
+	c.Assert(err, IsNil)
+	c.Check(m.response, Equals, true)
+	c.Check(peersFromAnswer(m, net.IPv4(192, 168, 1, 7)), DeepEquals, []string{"http://192.168.1.7:8123"})
+}
+
+func (s *peerCacheSuite) TestMdnsCompressedNames(c *C) {
+	msg := []byte{
+		0, 0, 0x84, 0, 0, 0, 0, 2, 0, 0, 0, 0,
+		// _snapd-peer._tcp.local TXT
+		11, '_', 's', 'n', 'a', 'p', 'd', '-', 'p', 'e', 'e', 'r',
+		4, '_', 't', 'c', 'p', 5, 'l', 'o', 'c', 'a', 'l', 0,
+		0, 16, 0, 1, 0, 0, 0, 120, 0, 10,
+		9, 'p', 'o', 'r', 't', '=', '1', '2', '3', '4',
+		// pointer back to the first name, TXT with a bogus port
+		0xC0, 12,
+		0, 16, 0, 1, 0, 0, 0, 120, 0, 8,
+		7, 'p', 'o', 'r', 't', '=', 'x', 'y',
+	}
+	m, err := unpackMdnsMessage(msg)
+	c.Assert(err, IsNil)
+	c.Assert(m.answers, HasLen, 2)
+	c.Check(m.answers[1].name, Equals, peerServiceName)
+	c.Check(peersFromAnswer(m, net.IPv4(10, 0, 0, 1)), DeepEquals, []string{"http://10.0.0.1:1234"})
+
+	// loops and truncation are rejected
+	_, err = unpackMdnsMessage([]byte{0, 0, 0x84, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0xC0, 12})
+	c.Check(err, Equals, errBadMessage)
+	_, err = unpackMdnsMessage(msg[:40])
+	c.Check(err, Equals, errBadMessage)
+}
@@ -0,0 +1,584 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Outbound connections honour the usual http_proxy/https_proxy/no_proxy
+// environment. On top of that, for networks that only offer smarter
+// proxies:
+//
+//  - SNAPPY_PROXY_PAC names a proxy auto-config file (a path or an
+//    http(s) URL, fetched directly) that is evaluated with pactester,
+//    from pacparser, to pick the proxy
+//  - SNAPPY_PROXY_AUTH selects how to authenticate to the proxy: "basic"
+//    (with SNAPPY_PROXY_USER and SNAPPY_PROXY_PASSWORD), or "ntlm" and
+//    "negotiate", which run a helper speaking the ntlm_auth protocol
+//    (SNAPPY_PROXY_AUTH_HELPER, defaulting to samba's ntlm_auth, from
+//    winbind)
+
+const (
+	proxyPACEnv        = "SNAPPY_PROXY_PAC"
+	proxyAuthEnv       = "SNAPPY_PROXY_AUTH"
+	proxyUserEnv       = "SNAPPY_PROXY_USER"
+	proxyPasswordEnv   = "SNAPPY_PROXY_PASSWORD"
+	proxyAuthHelperEnv = "SNAPPY_PROXY_AUTH_HELPER"
+)
+
+// pacCacheTTL is for how long the proxy picked for a host is reused.
+var pacCacheTTL = 5 * time.Minute
+
+// helperPackages are the packages shipping the helpers run by default,
+// for the errors to tell what to install when they are missing.
+var helperPackages = map[string]string{
+	"pactester": "pacparser",
+	"ntlm_auth": "winbind",
+}
+
+// lookHelper checks that the given helper program can be run, failing
+// with an error naming it, and its package when known, otherwise.
+func lookHelper(name string) error {
+	if _, err := exec.LookPath(name); err == nil {
+		return nil
+	}
+	if pkg := helperPackages[name]; pkg != "" {
+		return fmt.Errorf("cannot find %s, install the %s package", name, pkg)
+	}
+	return fmt.Errorf("cannot find %s", name)
+}
+
+// runPACHelper evaluates the PAC file at pacPath for rawurl and returns
+// the result, e.g. "PROXY proxy.example.com:3128; DIRECT".
+var runPACHelper = func(pacPath, rawurl string) (string, error) {
+	if err := lookHelper("pactester"); err != nil {
+		return "", fmt.Errorf("cannot evaluate PAC file: %v", err)
+	}
+	out, err := exec.Command("pactester", "-p", pacPath, "-u", rawurl).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("pactester failed: %v (%q)", err, out)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// parsePACResult returns the first usable proxy in the result of a PAC
+// FindProxyForURL call, or nil if the connection should be direct.
+func parsePACResult(result string) (*url.URL, error) {
+	for _, entry := range strings.Split(result, ";") {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "DIRECT":
+			return nil, nil
+		case "PROXY", "HTTP", "HTTPS":
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("invalid PAC result %q", result)
+			}
+			scheme := "http"
+			if strings.ToUpper(fields[0]) == "HTTPS" {
+				scheme = "https"
+			}
+			return &url.URL{Scheme: scheme, Host: fields[1]}, nil
+		default:
+			// SOCKS and friends are not supported, try the next one
+		}
+	}
+	return nil, fmt.Errorf("no usable proxy in PAC result %q", result)
+}
+
+type pacEntry struct {
+	proxy   *url.URL
+	expires time.Time
+}
+
+// pacClient fetches the PAC files given as URLs, directly as the proxy
+// to use is what they tell.
+var pacClient = &http.Client{
+	Transport: &http.Transport{TLSHandshakeTimeout: 10 * time.Second},
+	Timeout:   30 * time.Second,
+}
+
+// pacResolver picks proxies by evaluating a PAC file.
+type pacResolver struct {
+	location string
+
+	mu    sync.Mutex
+	data  []byte
+	cache map[string]pacEntry
+}
+
+func newPACResolver(location string) *pacResolver {
+	return &pacResolver{
+		location: location,
+		cache:    make(map[string]pacEntry),
+	}
+}
+
+// fetch returns the PAC file given as a URL, fetching it the first time.
+// Called with the lock held.
+func (r *pacResolver) fetch() ([]byte, error) {
+	if r.data != nil {
+		return r.data, nil
+	}
+	resp, err := pacClient.Get(r.location)
+	if err != nil {
+		return nil, fmt.Errorf("cannot fetch PAC file: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("cannot fetch PAC file: got status %d", resp.StatusCode)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("cannot fetch PAC file: %v", err)
+	}
+	r.data = data
+	return data, nil
+}
+
+// evaluate runs the PAC file for rawurl, from a temporary copy if it was
+// given as a URL. Called with the lock held.
+func (r *pacResolver) evaluate(rawurl string) (string, error) {
+	if !strings.HasPrefix(r.location, "http://") && !strings.HasPrefix(r.location, "https://") {
+		return runPACHelper(r.location, rawurl)
+	}
+
+	data, err := r.fetch()
+	if err != nil {
+		return "", err
+	}
+	f, err := ioutil.TempFile("", "snapd-proxy-pac")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(data)
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		return "", err
+	}
+	return runPACHelper(f.Name(), rawurl)
+}
+
+func (r *pacResolver) proxy(u *url.URL) (*url.URL, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := u.Scheme + "://" + u.Host
+	if entry, ok := r.cache[key]; ok && time.Now().Before(entry.expires) {
+		return entry.proxy, nil
+	}
+
+	result, err := r.evaluate(u.String())
+	if err != nil {
+		return nil, err
+	}
+	proxy, err := parsePACResult(result)
+	if err != nil {
+		return nil, err
+	}
+	r.cache[key] = pacEntry{proxy: proxy, expires: time.Now().Add(pacCacheTTL)}
+	return proxy, nil
+}
+
+// proxyAuthHelper runs an external program speaking the ntlm_auth
+// helper protocol to produce the tokens of a connection based proxy
+// authentication exchange.
+type proxyAuthHelper interface {
+	// Step feeds the challenge from the proxy (empty at first) and
+	// returns the token to send back.
+	Step(challenge string) (string, error)
+	Close() error
+}
+
+type cmdAuthHelper struct {
+	cmd *exec.Cmd
+	in  io.WriteCloser
+	out *bufio.Reader
+}
+
+var startAuthHelper = func(scheme string) (proxyAuthHelper, error) {
+	args := strings.Fields(os.Getenv(proxyAuthHelperEnv))
+	if len(args) == 0 {
+		protocol := "ntlmssp-client-1"
+		if scheme == "negotiate" {
+			protocol = "gss-spnego-client"
+		}
+		args = []string{"ntlm_auth", "--helper-protocol=" + protocol, "--use-cached-creds"}
+	}
+
+	if err := lookHelper(args[0]); err != nil {
+		return nil, fmt.Errorf("cannot run proxy authentication helper: %v", err)
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("cannot run proxy authentication helper: %v", err)
+	}
+	return &cmdAuthHelper{cmd: cmd, in: in, out: bufio.NewReader(out)}, nil
+}
+
+func (h *cmdAuthHelper) Step(challenge string) (string, error) {
+	request := "YR\n"
+	if challenge != "" {
+		request = "TT " + challenge + "\n"
+	}
+	if _, err := io.WriteString(h.in, request); err != nil {
+		return "", err
+	}
+	line, err := h.out.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("cannot read from proxy authentication helper: %v", err)
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 2 && (fields[0] == "YR" || fields[0] == "KK" || fields[0] == "AF" || fields[0] == "TT") {
+		return fields[1], nil
+	}
+	return "", fmt.Errorf("proxy authentication helper failed: %q", strings.TrimSpace(line))
+}
+
+func (h *cmdAuthHelper) Close() error {
+	h.in.Close()
+	return h.cmd.Wait()
+}
+
+// proxyTunnel dials connections through a proxy with CONNECT, doing a
+// connection based (NTLM or negotiate) authentication on the way. Plain
+// http requests are instead sent to the proxy as they are, with the
+// authentication done on the request itself.
+type proxyTunnel struct {
+	scheme string
+	proxy  func(*url.URL) (*url.URL, error)
+	// direct sends the plain http requests that go to no proxy
+	direct http.RoundTripper
+}
+
+// authScheme returns the scheme as used in the HTTP headers.
+func (t *proxyTunnel) authScheme() string {
+	if t.scheme == "ntlm" {
+		return "NTLM"
+	}
+	return "Negotiate"
+}
+
+func (t *proxyTunnel) challenge(resp *http.Response) string {
+	prefix := t.authScheme() + " "
+	for _, h := range resp.Header["Proxy-Authenticate"] {
+		if strings.HasPrefix(h, prefix) {
+			return strings.TrimSpace(strings.TrimPrefix(h, prefix))
+		}
+	}
+	return ""
+}
+
+// authenticate sends with send the tokens of the authentication
+// exchange with the proxy, and returns the last answer of the proxy.
+func (t *proxyTunnel) authenticate(send func(token string) (*http.Response, error)) (*http.Response, error) {
+	helper, err := startAuthHelper(t.scheme)
+	if err != nil {
+		return nil, err
+	}
+	defer helper.Close()
+
+	token, err := helper.Step("")
+	if err != nil {
+		return nil, err
+	}
+	resp, err := send(token)
+	if err != nil || resp.StatusCode != http.StatusProxyAuthRequired {
+		return resp, err
+	}
+	// the connection is reused, so the body needs draining
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	challenge := t.challenge(resp)
+	if challenge == "" {
+		return nil, fmt.Errorf("proxy did not offer a %s challenge", t.authScheme())
+	}
+	if token, err = helper.Step(challenge); err != nil {
+		return nil, err
+	}
+	return send(token)
+}
+
+func (t *proxyTunnel) Dial(network, addr string) (net.Conn, error) {
+	proxy, err := t.proxy(&url.URL{Scheme: "https", Host: addr})
+	if err != nil {
+		return nil, err
+	}
+	if proxy == nil {
+		return net.Dial(network, addr)
+	}
+
+	conn, err := net.Dial("tcp", proxy.Host)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.handshake(conn, addr); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("cannot connect to %s through proxy %s: %v", addr, proxy.Host, err)
+	}
+	return conn, nil
+}
+
+func (t *proxyTunnel) handshake(conn net.Conn, addr string) error {
+	br := bufio.NewReader(conn)
+	resp, err := t.authenticate(func(token string) (*http.Response, error) {
+		req := &http.Request{
+			Method: "CONNECT",
+			URL:    &url.URL{Opaque: addr},
+			Host:   addr,
+			Header: http.Header{"Proxy-Authorization": {t.authScheme() + " " + token}},
+		}
+		if err := req.Write(conn); err != nil {
+			return nil, err
+		}
+		return http.ReadResponse(br, req)
+	})
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("proxy answered %q", resp.Status)
+	}
+	// the rest of the connection is the tunnel
+	if br.Buffered() > 0 {
+		return fmt.Errorf("unexpected data from proxy")
+	}
+	return nil
+}
+
+// connBody closes the connection a response was read from along with
+// its body.
+type connBody struct {
+	io.ReadCloser
+	conn net.Conn
+}
+
+func (b *connBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.conn.Close()
+	return err
+}
+
+// RoundTrip sends plain http requests through the proxy.
+func (t *proxyTunnel) RoundTrip(req *http.Request) (*http.Response, error) {
+	proxy, err := t.proxy(req.URL)
+	if err != nil {
+		return nil, err
+	}
+	if proxy == nil {
+		return t.direct.RoundTrip(req)
+	}
+
+	// the request is sent again once authenticated
+	var body []byte
+	if req.Body != nil {
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	conn, err := net.Dial("tcp", proxy.Host)
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(conn)
+	resp, err := t.authenticate(func(token string) (*http.Response, error) {
+		authReq := *req
+		authReq.Header = make(http.Header, len(req.Header)+1)
+		for k, v := range req.Header {
+			authReq.Header[k] = v
+		}
+		authReq.Header.Set("Proxy-Authorization", t.authScheme()+" "+token)
+		if body != nil {
+			authReq.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+		if err := authReq.WriteProxy(conn); err != nil {
+			return nil, err
+		}
+		return http.ReadResponse(br, &authReq)
+	})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("cannot send request to %s through proxy %s: %v", req.URL.Host, proxy.Host, err)
+	}
+	resp.Body = &connBody{ReadCloser: resp.Body, conn: conn}
+	return resp, nil
+}
+
+// proxyConfig is the proxy setup of outbound store connections.
+type proxyConfig struct {
+	pac      *pacResolver
+	auth     string
+	user     string
+	password string
+}
+
+func proxyConfigFromEnv() *proxyConfig {
+	cfg := &proxyConfig{
+		auth:     strings.ToLower(os.Getenv(proxyAuthEnv)),
+		user:     os.Getenv(proxyUserEnv),
+		password: os.Getenv(proxyPasswordEnv),
+	}
+	if pac := os.Getenv(proxyPACEnv); pac != "" {
+		cfg.pac = newPACResolver(pac)
+	}
+	return cfg
+}
+
+// same returns whether cfg is the same proxy setup as other.
+func (cfg *proxyConfig) same(other *proxyConfig) bool {
+	if (cfg.pac == nil) != (other.pac == nil) {
+		return false
+	}
+	if cfg.pac != nil && cfg.pac.location != other.pac.location {
+		return false
+	}
+	return cfg.auth == other.auth && cfg.user == other.user && cfg.password == other.password
+}
+
+// proxy picks the proxy for u, from the PAC file if there is one, or
+// from the environment otherwise.
+func (cfg *proxyConfig) proxy(u *url.URL) (*url.URL, error) {
+	var proxy *url.URL
+	var err error
+	if cfg.pac != nil {
+		proxy, err = cfg.pac.proxy(u)
+	} else {
+		proxy, err = http.ProxyFromEnvironment(&http.Request{URL: u})
+	}
+	if err != nil || proxy == nil {
+		return proxy, err
+	}
+	if cfg.auth == "basic" && proxy.User == nil {
+		withUser := *proxy
+		withUser.User = url.UserPassword(cfg.user, cfg.password)
+		proxy = &withUser
+	}
+	return proxy, nil
+}
+
+func (cfg *proxyConfig) transport() (*http.Transport, error) {
+	switch cfg.auth {
+	case "", "basic":
+		return &http.Transport{
+			Proxy: func(req *http.Request) (*url.URL, error) {
+				return cfg.proxy(req.URL)
+			},
+			TLSHandshakeTimeout: 10 * time.Second,
+		}, nil
+	case "ntlm", "negotiate":
+		tunnel := &proxyTunnel{
+			scheme: cfg.auth,
+			proxy:  cfg.proxy,
+			direct: &http.Transport{},
+		}
+		tr := &http.Transport{
+			Dial:                tunnel.Dial,
+			TLSHandshakeTimeout: 10 * time.Second,
+		}
+		tr.RegisterProtocol("http", tunnel)
+		return tr, nil
+	default:
+		return nil, fmt.Errorf("unsupported proxy authentication %q", cfg.auth)
+	}
+}
+
+// newStoreTransport returns a transport configured for the proxy setup
+// cfg and trusting the extra store certificates. Its requests fail if
+// the proxy setup is unusable.
+func newStoreTransport(cfg *proxyConfig) *certsTransport {
+	return &certsTransport{newTransport: func() (*http.Transport, error) {
+		tr, err := cfg.transport()
+		if err != nil {
+			return nil, fmt.Errorf("cannot use proxy configuration: %v", err)
+		}
+		return tr, nil
+	}}
+}
+
+// proxyTransport is a http.RoundTripper configured for the proxy setup
+// of the system, rebuilt as it changes.
+type proxyTransport struct {
+	mu  sync.Mutex
+	cfg *proxyConfig
+	tr  *certsTransport
+}
+
+func (t *proxyTransport) transport() *certsTransport {
+	cfg := proxyConfigFromEnv()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.tr != nil && cfg.same(t.cfg) {
+		return t.tr
+	}
+	if t.tr != nil {
+		t.tr.CloseIdleConnections()
+	}
+	t.cfg = cfg
+	t.tr = newStoreTransport(cfg)
+	return t.tr
+}
+
+func (t *proxyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.transport().RoundTrip(req)
+}
+
+var storeTransport = &proxyTransport{}
+
+// newHTTPTransport returns the transport used to reach the store,
+// configured for the proxy setup of the system and trusting the extra
+// store certificates.
+func newHTTPTransport() http.RoundTripper {
+	return storeTransport
+}
+
+// newHTTPClient returns a http.Client using the store transport.
+func newHTTPClient() *http.Client {
+	return &http.Client{Transport: newHTTPTransport()}
+}
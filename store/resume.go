@@ -0,0 +1,238 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package store
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/snapcore/snapd/fips"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+)
+
+// ledgerChunkSize is the size of the chunks whose digests are recorded
+// in a download ledger.
+var ledgerChunkSize int64 = 4 * 1024 * 1024
+
+// downloadLedger records how far a download got, so that it can be
+// validated and resumed after snapd is restarted.
+type downloadLedger struct {
+	URL    string `json:"url"`
+	Sha512 string `json:"sha512"`
+	// Written is the number of bytes covered by Chunks.
+	Written int64 `json:"written"`
+	// Chunks are the sha256 digests of the complete chunks written so far.
+	Chunks []string `json:"chunks"`
+}
+
+// resumableDownload is an io.Writer appending to the partial file of a
+// download and keeping its ledger up to date. The ledger is saved after
+// each complete chunk once the chunk is synced to disk, so it never
+// claims more than the partial file holds.
+type resumableDownload struct {
+	f          *os.File
+	path       string
+	ledgerPath string
+	ledger     downloadLedger
+
+	chunk   hash.Hash
+	inChunk int64
+}
+
+// openResumableDownload opens the partial download of the file with the
+// given sha512 digest in dir, keeping only the chunks that still match
+// the ledger.
+func openResumableDownload(dir, name, digest, url string) (*resumableDownload, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	base := filepath.Join(dir, name+"_"+digest)
+	rd := &resumableDownload{
+		path:       base + ".partial",
+		ledgerPath: base + ".ledger",
+		chunk:      fips.SHA256(),
+	}
+
+	f, err := os.OpenFile(rd.path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	rd.f = f
+
+	if err := rd.loadLedger(); err != nil {
+		logger.Noticef("cannot use download ledger for %q, starting over: %v", name, err)
+	}
+	if rd.ledger.Sha512 != digest {
+		rd.ledger = downloadLedger{Sha512: digest}
+	}
+	rd.ledger.URL = url
+
+	valid := rd.validChunks()
+	if valid < len(rd.ledger.Chunks) {
+		logger.Noticef("discarding %d corrupted chunks of the partial download of %q", len(rd.ledger.Chunks)-valid, name)
+	}
+	rd.ledger.Chunks = rd.ledger.Chunks[:valid]
+	rd.ledger.Written = int64(valid) * ledgerChunkSize
+	if err := rd.truncate(rd.ledger.Written); err != nil {
+		rd.f.Close()
+		return nil, err
+	}
+
+	return rd, nil
+}
+
+func (rd *resumableDownload) loadLedger() error {
+	data, err := ioutil.ReadFile(rd.ledgerPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &rd.ledger)
+}
+
+func (rd *resumableDownload) saveLedger() error {
+	data, err := json.Marshal(&rd.ledger)
+	if err != nil {
+		return err
+	}
+	return osutil.AtomicWriteFile(rd.ledgerPath, data, 0600, 0)
+}
+
+// validChunks returns how many of the chunks in the ledger are found
+// intact, in order, in the partial file.
+func (rd *resumableDownload) validChunks() int {
+	buf := make([]byte, ledgerChunkSize)
+	for i, expected := range rd.ledger.Chunks {
+		if _, err := rd.f.ReadAt(buf, int64(i)*ledgerChunkSize); err != nil {
+			return i
+		}
+		sum := fips.Sum256(buf)
+		if hex.EncodeToString(sum[:]) != expected {
+			return i
+		}
+	}
+	return len(rd.ledger.Chunks)
+}
+
+// truncate drops everything in the partial file after size.
+func (rd *resumableDownload) truncate(size int64) error {
+	if err := rd.f.Truncate(size); err != nil {
+		return err
+	}
+	if _, err := rd.f.Seek(size, 0); err != nil {
+		return err
+	}
+	rd.chunk.Reset()
+	rd.inChunk = 0
+	return nil
+}
+
+// Offset returns where the download resumes from.
+func (rd *resumableDownload) Offset() int64 {
+	return rd.ledger.Written
+}
+
+// Write is from the io.Writer interface.
+func (rd *resumableDownload) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := int64(len(p))
+		if left := ledgerChunkSize - rd.inChunk; n > left {
+			n = left
+		}
+		m, err := rd.f.Write(p[:n])
+		rd.chunk.Write(p[:m])
+		rd.inChunk += int64(m)
+		written += m
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+
+		if rd.inChunk == ledgerChunkSize {
+			if err := rd.f.Sync(); err != nil {
+				return written, err
+			}
+			rd.ledger.Chunks = append(rd.ledger.Chunks, hex.EncodeToString(rd.chunk.Sum(nil)))
+			rd.ledger.Written += ledgerChunkSize
+			rd.chunk.Reset()
+			rd.inChunk = 0
+			if err := rd.saveLedger(); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// Rewind starts the download over, for when the server sends the whole
+// file instead of the requested range.
+func (rd *resumableDownload) Rewind() error {
+	rd.ledger.Chunks = nil
+	rd.ledger.Written = 0
+	if err := rd.truncate(0); err != nil {
+		return err
+	}
+	return rd.saveLedger()
+}
+
+// Close closes the partial file, keeping it and its ledger around to
+// resume from.
+func (rd *resumableDownload) Close() error {
+	return rd.f.Close()
+}
+
+// Finish checks the complete download against its digest and returns
+// the path of the snap file. A download that does not match is thrown
+// away.
+func (rd *resumableDownload) Finish() (string, error) {
+	if err := rd.f.Sync(); err != nil {
+		return "", err
+	}
+	if _, err := rd.f.Seek(0, 0); err != nil {
+		return "", err
+	}
+	h := fips.SHA512()
+	if _, err := io.Copy(h, rd.f); err != nil {
+		return "", err
+	}
+	rd.f.Close()
+	os.Remove(rd.ledgerPath)
+
+	if digest := hex.EncodeToString(h.Sum(nil)); digest != rd.ledger.Sha512 {
+		os.Remove(rd.path)
+		return "", fmt.Errorf("sha512 mismatch after download (expected %s, got %s)", rd.ledger.Sha512, digest)
+	}
+
+	snapPath := rd.path[:len(rd.path)-len(".partial")] + ".snap"
+	if err := os.Rename(rd.path, snapPath); err != nil {
+		return "", err
+	}
+	return snapPath, nil
+}
@@ -0,0 +1,195 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package store
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/progress"
+)
+
+func (t *remoteRepoTestSuite) mockPartialDownloads(c *C) (dir string, restore func()) {
+	oldChunkSize := ledgerChunkSize
+	ledgerChunkSize = 4
+	t.store.partialDir = c.MkDir()
+	return t.store.partialDir, func() {
+		ledgerChunkSize = oldChunkSize
+	}
+}
+
+func (t *remoteRepoTestSuite) TestDownloadResumesAfterFailure(c *C) {
+	dir, restore := t.mockPartialDownloads(c)
+	defer restore()
+
+	content := "0123456789abcdef"
+	download = func(name string, w io.Writer, req *http.Request, pbar progress.Meter) error {
+		c.Check(req.Header.Get("Range"), Equals, "")
+		// two complete chunks and a bit
+		w.Write([]byte(content[:10]))
+		return fmt.Errorf("connection reset")
+	}
+	remoteSnap := mockRemoteSnap(content)
+	_, err := t.store.Download(remoteSnap, nil, nil)
+	c.Assert(err, ErrorMatches, "connection reset")
+
+	base := filepath.Join(dir, "foo_"+remoteSnap.Sha512)
+	ledger, err := ioutil.ReadFile(base + ".ledger")
+	c.Assert(err, IsNil)
+	c.Check(string(ledger), Matches, `.*"url":"anon-url".*"written":8.*`)
+
+	// the incomplete chunk is dropped, and the rest requested
+	download = func(name string, w io.Writer, req *http.Request, pbar progress.Meter) error {
+		c.Check(req.Header.Get("Range"), Equals, "bytes=8-")
+		w.Write([]byte(content[8:]))
+		return nil
+	}
+	path, err := t.store.Download(remoteSnap, nil, nil)
+	c.Assert(err, IsNil)
+	defer os.Remove(path)
+
+	c.Check(path, Equals, base+".snap")
+	data, err := ioutil.ReadFile(path)
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, content)
+	_, err = os.Stat(base + ".ledger")
+	c.Check(os.IsNotExist(err), Equals, true)
+}
+
+func (t *remoteRepoTestSuite) TestDownloadDiscardsCorruptedChunks(c *C) {
+	dir, restore := t.mockPartialDownloads(c)
+	defer restore()
+
+	content := "0123456789abcdef"
+	download = func(name string, w io.Writer, req *http.Request, pbar progress.Meter) error {
+		w.Write([]byte(content[:8]))
+		return fmt.Errorf("power cut")
+	}
+	remoteSnap := mockRemoteSnap(content)
+	_, err := t.store.Download(remoteSnap, nil, nil)
+	c.Assert(err, ErrorMatches, "power cut")
+
+	// damage the second chunk
+	partial := filepath.Join(dir, "foo_"+remoteSnap.Sha512+".partial")
+	c.Assert(ioutil.WriteFile(partial, []byte("0123XXXX"), 0600), IsNil)
+
+	download = func(name string, w io.Writer, req *http.Request, pbar progress.Meter) error {
+		c.Check(req.Header.Get("Range"), Equals, "bytes=4-")
+		w.Write([]byte(content[4:]))
+		return nil
+	}
+	path, err := t.store.Download(remoteSnap, nil, nil)
+	c.Assert(err, IsNil)
+	defer os.Remove(path)
+
+	data, err := ioutil.ReadFile(path)
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, content)
+}
+
+func (t *remoteRepoTestSuite) TestDownloadResumableDigestMismatch(c *C) {
+	dir, restore := t.mockPartialDownloads(c)
+	defer restore()
+
+	download = func(name string, w io.Writer, req *http.Request, pbar progress.Meter) error {
+		w.Write([]byte("not what was expected"))
+		return nil
+	}
+	_, err := t.store.Download(mockRemoteSnap("the content"), nil, nil)
+	c.Assert(err, ErrorMatches, "sha512 mismatch after download .*")
+
+	matches, err := filepath.Glob(filepath.Join(dir, "*"))
+	c.Assert(err, IsNil)
+	c.Check(matches, HasLen, 0)
+}
+
+func (t *remoteRepoTestSuite) TestDownloadResumeServerIgnoresRange(c *C) {
+	_, restore := t.mockPartialDownloads(c)
+	defer restore()
+
+	content := "0123456789abcdef"
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// always the whole file
+		io.WriteString(w, content)
+	}))
+	defer mockServer.Close()
+
+	remoteSnap := mockRemoteSnap(content)
+	remoteSnap.AnonDownloadURL = mockServer.URL
+
+	// leave a partial download behind
+	download = func(name string, w io.Writer, req *http.Request, pbar progress.Meter) error {
+		w.Write([]byte(content[:8]))
+		return fmt.Errorf("interrupted")
+	}
+	_, err := t.store.Download(remoteSnap, nil, nil)
+	c.Assert(err, ErrorMatches, "interrupted")
+
+	download = t.origDownloadFunc
+	path, err := t.store.Download(remoteSnap, nil, nil)
+	c.Assert(err, IsNil)
+	defer os.Remove(path)
+
+	data, err := ioutil.ReadFile(path)
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, content)
+}
+
+func (t *remoteRepoTestSuite) TestDownloadResumeServerHonoursRange(c *C) {
+	_, restore := t.mockPartialDownloads(c)
+	defer restore()
+
+	content := "0123456789abcdef"
+	var ranges []string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		http.ServeContent(w, r, "foo.snap", time.Time{}, strings.NewReader(content))
+	}))
+	defer mockServer.Close()
+
+	remoteSnap := mockRemoteSnap(content)
+	remoteSnap.AnonDownloadURL = mockServer.URL
+
+	download = func(name string, w io.Writer, req *http.Request, pbar progress.Meter) error {
+		w.Write([]byte(content[:12]))
+		return fmt.Errorf("interrupted")
+	}
+	_, err := t.store.Download(remoteSnap, nil, nil)
+	c.Assert(err, ErrorMatches, "interrupted")
+
+	download = t.origDownloadFunc
+	path, err := t.store.Download(remoteSnap, nil, nil)
+	c.Assert(err, IsNil)
+	defer os.Remove(path)
+
+	data, err := ioutil.ReadFile(path)
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, content)
+	c.Check(ranges, DeepEquals, []string{"bytes=12-"})
+}
@@ -0,0 +1,75 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2014-2015 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package store
+
+import (
+	"time"
+
+	"github.com/snapcore/snapd/logger"
+)
+
+// RetryPolicy says how the requests to the store that fail with a
+// retryable error are tried again.
+type RetryPolicy struct {
+	// Attempts is how many times a request is made at most.
+	Attempts int
+	// Delay is how long to wait before trying again the first time,
+	// doubled each following time up to MaxDelay.
+	Delay    time.Duration
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy is the retry policy used unless configured
+// otherwise.
+var DefaultRetryPolicy = RetryPolicy{
+	Attempts: 4,
+	Delay:    time.Second,
+	MaxDelay: 30 * time.Second,
+}
+
+var retrySleep = time.Sleep
+
+// Do calls f until it succeeds or fails with an error that is not worth
+// retrying, at most p.Attempts times, and returns its last error. When
+// the store asks to wait longer than p.MaxDelay before trying again, the
+// error is returned right away for the caller to try again later.
+func (p RetryPolicy) Do(f func() error) error {
+	delay := p.Delay
+	for attempt := 1; ; attempt++ {
+		err := f()
+		if err == nil || attempt >= p.Attempts || !IsRetryable(err) {
+			return err
+		}
+		wait := delay
+		if after := RetryAfter(err); after > 0 {
+			if after > p.MaxDelay {
+				return err
+			}
+			wait = after
+		}
+		logger.Debugf("retrying store request in %v (attempt %d of %d): %v", wait, attempt+1, p.Attempts, err)
+		retrySleep(wait)
+
+		delay *= 2
+		if delay > p.MaxDelay {
+			delay = p.MaxDelay
+		}
+	}
+}
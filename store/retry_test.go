@@ -0,0 +1,271 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2014-2015 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package store
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/snap"
+)
+
+func (t *remoteRepoTestSuite) TestErrorKind(c *C) {
+	u, _ := url.Parse("http://example.com/foo.snap")
+	for _, tc := range []struct {
+		err  error
+		kind ErrorKind
+	}{
+		{nil, ""},
+		{errors.New("some error"), ErrorPermanent},
+		{ErrSnapNotFound, ErrorPermanent},
+		{ErrInvalidCredentials, ErrorAuthExpired},
+		{io.ErrUnexpectedEOF, ErrorRetryable},
+		{&ResponseError{StatusCode: 500}, ErrorRetryable},
+		{&ResponseError{StatusCode: 503}, ErrorRetryable},
+		{&ResponseError{StatusCode: 429}, ErrorRateLimited},
+		{&ResponseError{StatusCode: 401}, ErrorAuthExpired},
+		{&ResponseError{StatusCode: 404}, ErrorPermanent},
+		{&ErrDownload{Code: 502, URL: u}, ErrorRetryable},
+		{&ErrDownload{Code: 403, URL: u}, ErrorPermanent},
+		{&url.Error{Op: "Get", URL: u.String(), Err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}}, ErrorRetryable},
+		{&url.Error{Op: "Get", URL: u.String(), Err: errors.New("unsupported protocol scheme")}, ErrorPermanent},
+	} {
+		c.Check(Kind(tc.err), Equals, tc.kind, Commentf("%v", tc.err))
+	}
+
+	c.Check(IsRetryable(&ResponseError{StatusCode: 429}), Equals, true)
+	c.Check(IsRetryable(&ResponseError{StatusCode: 401}), Equals, false)
+}
+
+func (t *remoteRepoTestSuite) TestRetryAfterHeader(c *C) {
+	now := time.Date(2016, 11, 1, 10, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		header string
+		after  time.Duration
+	}{
+		{"", 0},
+		{"120", 2 * time.Minute},
+		{"-1", 0},
+		{"soon", 0},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second},
+		{now.Add(-time.Hour).Format(http.TimeFormat), 0},
+	} {
+		resp := &http.Response{Header: http.Header{}}
+		if tc.header != "" {
+			resp.Header.Set("Retry-After", tc.header)
+		}
+		c.Check(retryAfter(resp, now), Equals, tc.after, Commentf("%q", tc.header))
+	}
+}
+
+func (t *remoteRepoTestSuite) TestRetryPolicyDo(c *C) {
+	policy := RetryPolicy{Attempts: 4, Delay: time.Second, MaxDelay: 3 * time.Second}
+
+	calls := 0
+	err := policy.Do(func() error {
+		calls++
+		return &ResponseError{StatusCode: 503, msg: "unavailable"}
+	})
+	c.Check(err, ErrorMatches, "unavailable")
+	c.Check(calls, Equals, 4)
+	c.Check(t.retrySleeps, DeepEquals, []time.Duration{time.Second, 2 * time.Second, 3 * time.Second})
+
+	// permanent errors are not retried
+	t.retrySleeps = nil
+	calls = 0
+	err = policy.Do(func() error {
+		calls++
+		return ErrSnapNotFound
+	})
+	c.Check(err, Equals, ErrSnapNotFound)
+	c.Check(calls, Equals, 1)
+	c.Check(t.retrySleeps, HasLen, 0)
+
+	// succeeding stops the retries
+	calls = 0
+	err = policy.Do(func() error {
+		calls++
+		if calls < 3 {
+			return io.ErrUnexpectedEOF
+		}
+		return nil
+	})
+	c.Check(err, IsNil)
+	c.Check(calls, Equals, 3)
+}
+
+func (t *remoteRepoTestSuite) TestRetryPolicyDoRateLimited(c *C) {
+	policy := RetryPolicy{Attempts: 3, Delay: time.Second, MaxDelay: time.Minute}
+
+	calls := 0
+	err := policy.Do(func() error {
+		calls++
+		if calls == 1 {
+			return &ResponseError{StatusCode: 429, RetryAfter: 10 * time.Second}
+		}
+		return nil
+	})
+	c.Check(err, IsNil)
+	c.Check(t.retrySleeps, DeepEquals, []time.Duration{10 * time.Second})
+
+	// waiting longer than the policy allows is left to the caller
+	t.retrySleeps = nil
+	calls = 0
+	err = policy.Do(func() error {
+		calls++
+		return &ResponseError{StatusCode: 429, RetryAfter: time.Hour, msg: "slow down"}
+	})
+	c.Check(err, ErrorMatches, "slow down")
+	c.Check(RetryAfter(err), Equals, time.Hour)
+	c.Check(calls, Equals, 1)
+	c.Check(t.retrySleeps, HasLen, 0)
+}
+
+func (t *remoteRepoTestSuite) TestSnapRevisionRetried(c *C) {
+	n := 0
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		switch n {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.Header().Set("Retry-After", "5")
+			w.WriteHeader(429)
+		default:
+			io.WriteString(w, mockRevisionDetailsJSON)
+		}
+	}))
+	defer mockServer.Close()
+
+	detailsURI, err := url.Parse(mockServer.URL + "/details/")
+	c.Assert(err, IsNil)
+	repo := NewUbuntuStoreSnapRepository(&SnapUbuntuStoreConfig{
+		DetailsURI: detailsURI,
+		Retry:      &RetryPolicy{Attempts: 3, Delay: time.Second, MaxDelay: time.Minute},
+	}, "")
+
+	result, err := repo.SnapRevision("hello-world", snap.R(23), nil)
+	c.Assert(err, IsNil)
+	c.Check(result.Revision, Equals, snap.R(23))
+	c.Check(n, Equals, 3)
+	c.Check(t.retrySleeps, DeepEquals, []time.Duration{time.Second, 5 * time.Second})
+}
+
+func (t *remoteRepoTestSuite) TestSnapRevisionRetriesExhausted(c *C) {
+	n := 0
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer mockServer.Close()
+
+	detailsURI, err := url.Parse(mockServer.URL + "/details/")
+	c.Assert(err, IsNil)
+	repo := NewUbuntuStoreSnapRepository(&SnapUbuntuStoreConfig{DetailsURI: detailsURI}, "")
+
+	_, err = repo.SnapRevision("hello-world", snap.R(23), nil)
+	c.Assert(err, ErrorMatches, `Ubuntu CPI service returned unexpected HTTP status code 502 while looking for snap "hello-world" at revision 23`)
+	c.Check(Kind(err), Equals, ErrorRetryable)
+	c.Check(n, Equals, DefaultRetryPolicy.Attempts)
+}
+
+func (t *remoteRepoTestSuite) TestListRefreshRetriedResendsBody(c *C) {
+	var bodies []string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		c.Assert(err, IsNil)
+		bodies = append(bodies, string(body))
+		if len(bodies) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		io.WriteString(w, `{"_embedded": {"clickindex:package": []}}`)
+	}))
+	defer mockServer.Close()
+
+	bulkURI, err := url.Parse(mockServer.URL + "/updates/")
+	c.Assert(err, IsNil)
+	repo := NewUbuntuStoreSnapRepository(&SnapUbuntuStoreConfig{BulkURI: bulkURI}, "")
+
+	results, err := repo.ListRefresh([]*RefreshCandidate{{
+		SnapID:   "buPKUD3TKqCOgLEjjHx5kSiCpIs5cMuQ",
+		Channel:  "stable",
+		Revision: snap.R(1),
+	}}, nil)
+	c.Assert(err, IsNil)
+	c.Check(results, HasLen, 0)
+	c.Assert(bodies, HasLen, 2)
+	c.Check(bodies[1], Equals, bodies[0])
+	c.Check(bodies[0], Matches, `.*"snap_id":"buPKUD3TKqCOgLEjjHx5kSiCpIs5cMuQ".*`)
+}
+
+func (t *remoteRepoTestSuite) TestDownloadRetriedStartsOver(c *C) {
+	n := 0
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		if n == 1 {
+			// a partial body, then the connection goes away
+			w.Header().Set("Content-Length", "100")
+			io.WriteString(w, "garbage")
+			return
+		}
+		io.WriteString(w, "snap data")
+	}))
+	defer mockServer.Close()
+
+	info := &snap.Info{}
+	info.OfficialName = "foo"
+	info.AnonDownloadURL = mockServer.URL + "/foo.snap"
+
+	path, err := t.store.Download(info, nil, nil)
+	c.Assert(err, IsNil)
+	data, err := ioutil.ReadFile(path)
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, "snap data")
+	c.Check(n, Equals, 2)
+	c.Check(t.retrySleeps, DeepEquals, []time.Duration{DefaultRetryPolicy.Delay})
+}
+
+func (t *remoteRepoTestSuite) TestDownloadPermanentErrorNotRetried(c *C) {
+	n := 0
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer mockServer.Close()
+
+	info := &snap.Info{}
+	info.OfficialName = "foo"
+	info.AnonDownloadURL = mockServer.URL + "/foo.snap"
+
+	_, err := t.store.Download(info, nil, nil)
+	c.Assert(err, FitsTypeOf, &ErrDownload{})
+	c.Check(err.(*ErrDownload).Code, Equals, http.StatusForbidden)
+	c.Check(n, Equals, 1)
+	c.Check(t.retrySleeps, HasLen, 0)
+}
@@ -33,6 +33,7 @@ import (
 	"reflect"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/snapcore/snapd/arch"
 	"github.com/snapcore/snapd/asserts"
@@ -71,26 +72,88 @@ func infoFromRemote(d snapDetails) *snap.Info {
 	info.DownloadURL = d.DownloadURL
 	info.Prices = d.Prices
 	info.Private = d.Private
+	info.RolloutPercentage = d.RolloutPercentage
+	info.ReleaseNotesURL = d.ReleaseNotesURL
+	info.Publisher = d.Publisher
+	info.License = d.License
+	info.Screenshots = d.ScreenshotURLs
+	info.Channels = channelsFromRemote(d.ChannelMapList)
 	return info
 }
 
+// channelsFromRemote returns what the channels released to offer for
+// the architecture of the system. Channels of tracks other than the
+// default one are named <track>/<risk>.
+func channelsFromRemote(maps []channelMap) map[string]*snap.ChannelSnapInfo {
+	var channels map[string]*snap.ChannelSnapInfo
+	for _, cm := range maps {
+		if cm.Architecture != "" && cm.Architecture != arch.UbuntuArchitecture() {
+			continue
+		}
+		for _, entry := range cm.Map {
+			if entry.Info != "released" {
+				continue
+			}
+			name := entry.Channel
+			if cm.Track != "" && cm.Track != "latest" {
+				name = cm.Track + "/" + entry.Channel
+			}
+			if channels == nil {
+				channels = make(map[string]*snap.ChannelSnapInfo)
+			}
+			channels[name] = &snap.ChannelSnapInfo{
+				Revision:    entry.Revision,
+				Version:     entry.Version,
+				Confinement: snap.ConfinementType(entry.Confinement),
+				Size:        entry.Size,
+			}
+		}
+	}
+	return channels
+}
+
 // SnapUbuntuStoreConfig represents the configuration to access the snap store
 type SnapUbuntuStoreConfig struct {
 	SearchURI     *url.URL
+	DetailsURI    *url.URL
 	BulkURI       *url.URL
 	AssertionsURI *url.URL
 	PurchasesURI  *url.URL
+
+	// DownloadBackends are tried in order before downloading from
+	// the store, see DownloadBackend.
+	DownloadBackends []DownloadBackend
+
+	// MetadataCacheDir, if set, is where the responses to snap
+	// details, search and refresh requests are cached, see
+	// CachingTransport.
+	MetadataCacheDir string
+
+	// PartialDownloadsDir, if set, is where downloads are kept while
+	// in progress, with a ledger to validate and resume them from
+	// after snapd is restarted.
+	PartialDownloadsDir string
+
+	// Retry, if set, replaces DefaultRetryPolicy for the requests
+	// to the store and the downloads.
+	Retry *RetryPolicy
 }
 
 // SnapUbuntuStoreRepository represents the ubuntu snap store
 type SnapUbuntuStoreRepository struct {
 	storeID       string
 	searchURI     *url.URL
+	detailsURI    *url.URL
 	bulkURI       *url.URL
 	assertionsURI *url.URL
 	purchasesURI  *url.URL
+	backends      []DownloadBackend
+	partialDir    string
+	retry         RetryPolicy
 	// reused http client
 	client *http.Client
+	// http client for the metadata requests, caching if configured
+	metadataClient *http.Client
 
 	mu                sync.Mutex
 	suggestedCurrency string
@@ -154,6 +217,12 @@ func myappsURL() string {
 
 var defaultConfig = SnapUbuntuStoreConfig{}
 
+// DefaultConfig returns a copy of the default store configuration.
+func DefaultConfig() *SnapUbuntuStoreConfig {
+	cfg := defaultConfig
+	return &cfg
+}
+
 func init() {
 	storeBaseURI, err := url.Parse(cpiURL())
 	if err != nil {
@@ -168,6 +237,12 @@ func init() {
 	v.Set("fields", strings.Join(getStructFields(snapDetails{}), ","))
 	defaultConfig.SearchURI.RawQuery = v.Encode()
 
+	defaultConfig.DetailsURI, err = storeBaseURI.Parse("snaps/details/")
+	if err != nil {
+		panic(err)
+	}
+	defaultConfig.DetailsURI.RawQuery = v.Encode()
+
 	defaultConfig.BulkURI, err = storeBaseURI.Parse("metadata")
 	if err != nil {
 		panic(err)
@@ -201,17 +276,32 @@ func NewUbuntuStoreSnapRepository(cfg *SnapUbuntuStoreConfig, storeID string) *S
 	if cfg == nil {
 		cfg = &defaultConfig
 	}
+	transport := &LoggedTransport{
+		Transport: newHTTPTransport(),
+		Key:       "SNAPD_DEBUG_HTTP",
+	}
+	retry := DefaultRetryPolicy
+	if cfg.Retry != nil {
+		retry = *cfg.Retry
+	}
 	// see https://wiki.ubuntu.com/AppStore/Interfaces/ClickPackageIndex
 	return &SnapUbuntuStoreRepository{
 		storeID:       storeID,
 		searchURI:     cfg.SearchURI,
+		detailsURI:    cfg.DetailsURI,
 		bulkURI:       cfg.BulkURI,
 		assertionsURI: cfg.AssertionsURI,
 		purchasesURI:  cfg.PurchasesURI,
+		backends:      cfg.DownloadBackends,
+		partialDir:    cfg.PartialDownloadsDir,
+		retry:         retry,
 		client: &http.Client{
-			Transport: &LoggedTransport{
-				Transport: http.DefaultTransport,
-				Key:       "SNAPD_DEBUG_HTTP",
+			Transport: transport,
+		},
+		metadataClient: &http.Client{
+			Transport: &CachingTransport{
+				Transport: transport,
+				Dir:       cfg.MetadataCacheDir,
 			},
 		},
 	}
@@ -237,6 +327,25 @@ func (s *SnapUbuntuStoreRepository) setUbuntuStoreHeaders(req *http.Request, cha
 	}
 }
 
+// doRequest makes the request with the given client and hands the
+// response to handle, trying again per the retry policy of the store if
+// either fails with a retryable error. The body, if any, is sent with
+// each attempt.
+func (s *SnapUbuntuStoreRepository) doRequest(client *http.Client, req *http.Request, body []byte, handle func(resp *http.Response) error) error {
+	return s.retry.Do(func() error {
+		if body != nil {
+			req.Body = ioutil.NopCloser(bytes.NewReader(body))
+			req.ContentLength = int64(len(body))
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		return handle(resp)
+	})
+}
+
 // read all the available metadata from the store response and cache
 func (s *SnapUbuntuStoreRepository) checkStoreResponse(resp *http.Response) {
 	suggestedCurrency := resp.Header.Get("X-Suggested-Currency")
@@ -303,25 +412,24 @@ func (s *SnapUbuntuStoreRepository) getPurchasesFromURL(url *url.URL, channel st
 
 	s.setUbuntuStoreHeaders(req, channel, auther)
 
-	resp, err := s.client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
 	var purchases []*purchase
-
-	switch resp.StatusCode {
-	case http.StatusOK:
-		dec := json.NewDecoder(resp.Body)
-		if err := dec.Decode(&purchases); err != nil {
-			return nil, fmt.Errorf("cannot decode known purchases from store: %v", err)
+	err = s.doRequest(s.client, req, nil, func(resp *http.Response) error {
+		switch resp.StatusCode {
+		case http.StatusOK:
+			dec := json.NewDecoder(resp.Body)
+			if err := dec.Decode(&purchases); err != nil {
+				return fmt.Errorf("cannot decode known purchases from store: %v", err)
+			}
+			return nil
+		case http.StatusUnauthorized:
+			// TODO handle token expiry and refresh
+			return ErrInvalidCredentials
+		default:
+			return responseErrorf(resp, "cannot obtain known purchases from store: server returned %v code", resp.StatusCode)
 		}
-	case http.StatusUnauthorized:
-		// TODO handle token expiry and refresh
-		return nil, ErrInvalidCredentials
-	default:
-		return nil, fmt.Errorf("cannot obtain known purchases from store: server returned %v code", resp.StatusCode)
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return purchases, nil
@@ -415,6 +523,13 @@ func mustBuy(prices map[string]float64, purchases []*purchase) bool {
 
 // Snap returns the snap.Info for the store hosted snap with the given name or an error.
 func (s *SnapUbuntuStoreRepository) Snap(name, channel string, auther Authenticator) (*snap.Info, error) {
+	return s.SnapForArchitecture(name, channel, "", auther)
+}
+
+// SnapForArchitecture returns the snap.Info for the store hosted snap
+// with the given name built for the given architecture, instead of the
+// one of the system if set, or an error.
+func (s *SnapUbuntuStoreRepository) SnapForArchitecture(name, channel, architecture string, auther Authenticator) (*snap.Info, error) {
 
 	u := *s.searchURI // make a copy, so we can mutate it
 
@@ -430,30 +545,34 @@ func (s *SnapUbuntuStoreRepository) Snap(name, channel string, auther Authentica
 
 	// set headers
 	s.setUbuntuStoreHeaders(req, channel, auther)
-
-	resp, err := s.client.Do(req)
-	if err != nil {
-		return nil, err
+	if architecture != "" {
+		req.Header.Set("X-Ubuntu-Architecture", architecture)
 	}
-	defer resp.Body.Close()
 
-	// check statusCode
-	switch {
-	case resp.StatusCode == 404:
-		return nil, ErrSnapNotFound
-	case resp.StatusCode != 200:
-		tpl := "Ubuntu CPI service returned unexpected HTTP status code %d while looking for snap %q in channel %q"
-		if oops := resp.Header.Get("X-Oops-Id"); oops != "" {
-			tpl += " [%s]"
-			return nil, fmt.Errorf(tpl, resp.StatusCode, name, channel, oops)
+	var searchData searchResults
+	err = s.doRequest(s.metadataClient, req, nil, func(resp *http.Response) error {
+		// check statusCode
+		switch {
+		case resp.StatusCode == 404:
+			return ErrSnapNotFound
+		case resp.StatusCode != 200:
+			tpl := "Ubuntu CPI service returned unexpected HTTP status code %d while looking for snap %q in channel %q"
+			if oops := resp.Header.Get("X-Oops-Id"); oops != "" {
+				tpl += " [%s]"
+				return responseErrorf(resp, tpl, resp.StatusCode, name, channel, oops)
+			}
+			return responseErrorf(resp, tpl, resp.StatusCode, name, channel)
 		}
-		return nil, fmt.Errorf(tpl, resp.StatusCode, name, channel)
-	}
 
-	// and decode json
-	var searchData searchResults
-	dec := json.NewDecoder(resp.Body)
-	if err := dec.Decode(&searchData); err != nil {
+		// and decode json
+		dec := json.NewDecoder(resp.Body)
+		if err := dec.Decode(&searchData); err != nil {
+			return err
+		}
+		s.checkStoreResponse(resp)
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -467,8 +586,6 @@ func (s *SnapUbuntuStoreRepository) Snap(name, channel string, auther Authentica
 		return nil, fmt.Errorf("unexpected multiple store results for an exact match search for %q in %q channel", name, channel)
 	}
 
-	s.checkStoreResponse(resp)
-
 	info := infoFromRemote(searchData.Payload.Packages[0])
 
 	err = s.decoratePurchases([]*snap.Info{info}, channel, auther)
@@ -480,16 +597,17 @@ func (s *SnapUbuntuStoreRepository) Snap(name, channel string, auther Authentica
 
 }
 
-// Find finds  (installable) snaps from the store, matching the
-// given search term.
-func (s *SnapUbuntuStoreRepository) Find(searchTerm string, channel string, auther Authenticator) ([]*snap.Info, error) {
-	if channel == "" {
-		channel = "stable"
+// SnapRevision returns the snap.Info for the given revision of the store
+// hosted snap with the given name or an error.
+func (s *SnapUbuntuStoreRepository) SnapRevision(name string, revision snap.Revision, auther Authenticator) (*snap.Info, error) {
+	if s.detailsURI == nil {
+		return nil, fmt.Errorf("cannot get details of snap %q: no details URI configured", name)
 	}
 
-	u := *s.searchURI // make a copy, so we can mutate it
+	u := *s.detailsURI // make a copy, so we can mutate it
+	u.Path = path.Join(u.Path, name)
 	q := u.Query()
-	q.Set("q", searchTerm)
+	q.Set("revision", revision.String())
 	u.RawQuery = q.Encode()
 
 	req, err := http.NewRequest("GET", u.String(), nil)
@@ -498,27 +616,81 @@ func (s *SnapUbuntuStoreRepository) Find(searchTerm string, channel string, auth
 	}
 
 	// set headers
-	s.setUbuntuStoreHeaders(req, channel, auther)
+	s.setUbuntuStoreHeaders(req, "", auther)
+
+	var details snapDetails
+	err = s.doRequest(s.metadataClient, req, nil, func(resp *http.Response) error {
+		// check statusCode
+		switch {
+		case resp.StatusCode == 404:
+			return ErrSnapNotFound
+		case resp.StatusCode != 200:
+			tpl := "Ubuntu CPI service returned unexpected HTTP status code %d while looking for snap %q at revision %s"
+			if oops := resp.Header.Get("X-Oops-Id"); oops != "" {
+				tpl += " [%s]"
+				return responseErrorf(resp, tpl, resp.StatusCode, name, revision, oops)
+			}
+			return responseErrorf(resp, tpl, resp.StatusCode, name, revision)
+		}
 
-	resp, err := s.client.Do(req)
+		// and decode json
+		dec := json.NewDecoder(resp.Body)
+		if err := dec.Decode(&details); err != nil {
+			return err
+		}
+		s.checkStoreResponse(resp)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("received an unexpected http response code (%v) when trying to search via %q", resp.Status, req.URL)
+	if details.Revision != revision {
+		return nil, fmt.Errorf("store returned revision %s of snap %q instead of %s", details.Revision, name, revision)
 	}
 
-	if ct := resp.Header.Get("Content-Type"); ct != "application/hal+json" {
-		return nil, fmt.Errorf("received an unexpected content type (%q) when trying to search via %q", ct, req.URL)
+	return infoFromRemote(details), nil
+}
+
+// Find finds  (installable) snaps from the store, matching the
+// given search term.
+func (s *SnapUbuntuStoreRepository) Find(searchTerm string, channel string, auther Authenticator) ([]*snap.Info, error) {
+	if channel == "" {
+		channel = "stable"
 	}
 
+	u := *s.searchURI // make a copy, so we can mutate it
+	q := u.Query()
+	q.Set("q", searchTerm)
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// set headers
+	s.setUbuntuStoreHeaders(req, channel, auther)
+
 	var searchData searchResults
+	err = s.doRequest(s.metadataClient, req, nil, func(resp *http.Response) error {
+		if resp.StatusCode != 200 {
+			return responseErrorf(resp, "received an unexpected http response code (%v) when trying to search via %q", resp.Status, req.URL)
+		}
 
-	dec := json.NewDecoder(resp.Body)
-	if err := dec.Decode(&searchData); err != nil {
-		return nil, fmt.Errorf("cannot decode reply (got %v) when trying to search via %q", err, req.URL)
+		if ct := resp.Header.Get("Content-Type"); ct != "application/hal+json" {
+			return fmt.Errorf("received an unexpected content type (%q) when trying to search via %q", ct, req.URL)
+		}
+
+		dec := json.NewDecoder(resp.Body)
+		if err := dec.Decode(&searchData); err != nil {
+			return fmt.Errorf("cannot decode reply (got %v) when trying to search via %q", err, req.URL)
+		}
+		s.checkStoreResponse(resp)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	snaps := make([]*snap.Info, len(searchData.Payload.Packages))
@@ -531,8 +703,6 @@ func (s *SnapUbuntuStoreRepository) Find(searchTerm string, channel string, auth
 		logger.Noticef("cannot get user purchases: %v", err)
 	}
 
-	s.checkStoreResponse(resp)
-
 	return snaps, nil
 }
 
@@ -601,13 +771,13 @@ func (s *SnapUbuntuStoreRepository) ListRefresh(installed []*RefreshCandidate, a
 	// build input for the updates endpoint
 	jsonData, err := json.Marshal(metadataWrapper{
 		Snaps:  currentSnaps,
-		Fields: []string{"snap_id", "package_name", "revision", "version", "download_url"},
+		Fields: []string{"snap_id", "package_name", "revision", "version", "download_url", "rollout_percentage", "binary_filesize", "content", "deltas", "release_notes_url"},
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", s.bulkURI.String(), bytes.NewBuffer([]byte(jsonData)))
+	req, err := http.NewRequest("POST", s.bulkURI.String(), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -616,15 +786,19 @@ func (s *SnapUbuntuStoreRepository) ListRefresh(installed []*RefreshCandidate, a
 	// (see LP: #1427155)
 	s.setUbuntuStoreHeaders(req, "", auther)
 
-	resp, err := s.client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
 	var updateData searchResults
-	dec := json.NewDecoder(resp.Body)
-	if err := dec.Decode(&updateData); err != nil {
+	err = s.doRequest(s.metadataClient, req, jsonData, func(resp *http.Response) error {
+		if resp.StatusCode != 200 {
+			return responseErrorf(resp, "cannot list refreshes: store returned unexpected HTTP status code %d", resp.StatusCode)
+		}
+		dec := json.NewDecoder(resp.Body)
+		if err := dec.Decode(&updateData); err != nil {
+			return err
+		}
+		s.checkStoreResponse(resp)
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -635,14 +809,25 @@ func (s *SnapUbuntuStoreRepository) ListRefresh(installed []*RefreshCandidate, a
 		if rsnap.Revision == candidateMap[rsnap.SnapID].Revision {
 			continue
 		}
-		res = append(res, infoFromRemote(rsnap))
+		info := infoFromRemote(rsnap)
+		info.DeltaSize = deltaSize(rsnap, candidateMap[rsnap.SnapID].Revision)
+		res = append(res, info)
 	}
 
-	s.checkStoreResponse(resp)
-
 	return res, nil
 }
 
+// deltaSize returns the size of the delta the store offers from the
+// given revision to the one described, or 0 if there is none.
+func deltaSize(d snapDetails, from snap.Revision) int64 {
+	for _, delta := range d.Deltas {
+		if delta.FromRevision == from.N && delta.ToRevision == d.Revision.N {
+			return delta.Size
+		}
+	}
+	return 0
+}
+
 // Download downloads the given snap and returns its filename.
 // The file is saved in temporary storage, and should be removed
 // after use to prevent the disk from running out of space.
@@ -661,6 +846,10 @@ func (s *SnapUbuntuStoreRepository) Download(remoteSnap *snap.Info, pbar progres
 		}
 	}()
 
+	if s.fetchFromBackends(remoteSnap, w, pbar) {
+		return w.Name(), w.Sync()
+	}
+
 	url := remoteSnap.AnonDownloadURL
 	if url == "" || auther != nil {
 		url = remoteSnap.DownloadURL
@@ -672,16 +861,89 @@ func (s *SnapUbuntuStoreRepository) Download(remoteSnap *snap.Info, pbar progres
 	}
 	s.setUbuntuStoreHeaders(req, "", auther)
 
-	if err := download(remoteSnap.Name(), w, req, pbar); err != nil {
+	if s.partialDir != "" && remoteSnap.Sha512 != "" {
+		// resumable downloads live in their own file
+		os.Remove(w.Name())
+		return s.downloadResumable(remoteSnap, req, pbar)
+	}
+
+	attempt := 0
+	err = s.retry.Do(func() error {
+		attempt++
+		if attempt > 1 {
+			// start over
+			if err := w.Truncate(0); err != nil {
+				return err
+			}
+			if _, err := w.Seek(0, 0); err != nil {
+				return err
+			}
+		}
+		return download(remoteSnap.Name(), w, req, pbar)
+	})
+	if err != nil {
+		return "", err
+	}
+	if err := w.Sync(); err != nil {
+		return "", err
+	}
+	s.cacheDownload(remoteSnap, w.Name())
+
+	return w.Name(), nil
+}
+
+// downloadResumable downloads the snap into the partial downloads
+// directory, picking up where an earlier attempt left off.
+func (s *SnapUbuntuStoreRepository) downloadResumable(remoteSnap *snap.Info, req *http.Request, pbar progress.Meter) (string, error) {
+	rd, err := openResumableDownload(s.partialDir, remoteSnap.Name(), remoteSnap.Sha512, req.URL.String())
+	if err != nil {
+		return "", err
+	}
+	defer rd.Close()
+
+	err = s.retry.Do(func() error {
+		// drop what was written of an incomplete chunk by an earlier
+		// attempt
+		if err := rd.truncate(rd.Offset()); err != nil {
+			return err
+		}
+		req.Header.Del("Range")
+		if off := rd.Offset(); off > 0 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", off))
+		}
+		err := download(remoteSnap.Name(), rd, req, pbar)
+		if e, ok := err.(*ErrDownload); ok && e.Code == http.StatusRequestedRangeNotSatisfiable {
+			// the server does not like what we have, start over
+			req.Header.Del("Range")
+			if err := rd.Rewind(); err != nil {
+				return err
+			}
+			err = download(remoteSnap.Name(), rd, req, pbar)
+		}
+		return err
+	})
+	if err != nil {
+		// what was written so far is kept to resume from
+		return "", err
+	}
+
+	path, err := rd.Finish()
+	if err != nil {
 		return "", err
 	}
+	s.cacheDownload(remoteSnap, path)
+
+	return path, nil
+}
 
-	return w.Name(), w.Sync()
+// rewinder is implemented by download targets that can start over.
+type rewinder interface {
+	Rewind() error
 }
 
 // download writes an http.Request showing a progress.Meter
 var download = func(name string, w io.Writer, req *http.Request, pbar progress.Meter) error {
-	client := &http.Client{}
+	client := newHTTPClient()
 
 	resp, err := client.Do(req)
 	if err != nil {
@@ -689,8 +951,23 @@ var download = func(name string, w io.Writer, req *http.Request, pbar progress.M
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		return &ErrDownload{Code: resp.StatusCode, URL: req.URL}
+	ranged := req.Header.Get("Range") != ""
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && ranged:
+		// resuming
+	case resp.StatusCode == 200:
+		if ranged {
+			// the server sent the whole file
+			rw, ok := w.(rewinder)
+			if !ok {
+				return fmt.Errorf("cannot restart download of %q", name)
+			}
+			if err := rw.Rewind(); err != nil {
+				return err
+			}
+		}
+	default:
+		return &ErrDownload{Code: resp.StatusCode, URL: req.URL, RetryAfter: retryAfter(resp, time.Now())}
 	}
 
 	if pbar != nil {
@@ -729,30 +1006,33 @@ func (s *SnapUbuntuStoreRepository) Assertion(assertType *asserts.AssertionType,
 	}
 	req.Header.Set("Accept", asserts.MediaType)
 
-	resp, err := s.client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		if resp.Header.Get("Content-Type") == "application/json" {
-			var svcErr assertionSvcError
-			dec := json.NewDecoder(resp.Body)
-			if err := dec.Decode(&svcErr); err != nil {
-				return nil, fmt.Errorf("cannot decode assertion service error with HTTP status code %d: %v", resp.StatusCode, err)
-			}
-			if svcErr.Status == 404 {
-				return nil, ErrAssertionNotFound
+	var a asserts.Assertion
+	err = s.doRequest(s.client, req, nil, func(resp *http.Response) error {
+		if resp.StatusCode != 200 {
+			if resp.Header.Get("Content-Type") == "application/json" {
+				var svcErr assertionSvcError
+				dec := json.NewDecoder(resp.Body)
+				if err := dec.Decode(&svcErr); err != nil {
+					return responseErrorf(resp, "cannot decode assertion service error with HTTP status code %d: %v", resp.StatusCode, err)
+				}
+				if svcErr.Status == 404 {
+					return ErrAssertionNotFound
+				}
+				return responseErrorf(resp, "assertion service error: [%s] %q", svcErr.Title, svcErr.Detail)
 			}
-			return nil, fmt.Errorf("assertion service error: [%s] %q", svcErr.Title, svcErr.Detail)
+			return responseErrorf(resp, "unexpected HTTP status code %d", resp.StatusCode)
 		}
-		return nil, fmt.Errorf("unexpected HTTP status code %d", resp.StatusCode)
-	}
 
-	// and decode assertion
-	dec := asserts.NewDecoder(resp.Body)
-	return dec.Decode()
+		// and decode assertion
+		dec := asserts.NewDecoder(resp.Body)
+		var err error
+		a, err = dec.Decode()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
 }
 
 // SuggestedCurrency retrieves the cached value for the store's suggested currency
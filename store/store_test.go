@@ -30,9 +30,11 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	. "gopkg.in/check.v1"
 
+	"github.com/snapcore/snapd/arch"
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/logger"
@@ -46,6 +48,8 @@ type remoteRepoTestSuite struct {
 	logbuf *bytes.Buffer
 
 	origDownloadFunc func(string, io.Writer, *http.Request, progress.Meter) error
+	origRetrySleep   func(time.Duration)
+	retrySleeps      []time.Duration
 }
 
 func TestStore(t *testing.T) { TestingT(t) }
@@ -61,6 +65,11 @@ func (fa *fakeAuthenticator) Authenticate(r *http.Request) {
 func (t *remoteRepoTestSuite) SetUpTest(c *C) {
 	t.store = NewUbuntuStoreSnapRepository(nil, "")
 	t.origDownloadFunc = download
+	t.origRetrySleep = retrySleep
+	t.retrySleeps = nil
+	retrySleep = func(d time.Duration) {
+		t.retrySleeps = append(t.retrySleeps, d)
+	}
 	dirs.SetRootDir(c.MkDir())
 	c.Assert(os.MkdirAll(dirs.SnapSnapsDir, 0755), IsNil)
 
@@ -72,6 +81,7 @@ func (t *remoteRepoTestSuite) SetUpTest(c *C) {
 
 func (t *remoteRepoTestSuite) TearDownTest(c *C) {
 	download = t.origDownloadFunc
+	retrySleep = t.origRetrySleep
 }
 
 func (t *remoteRepoTestSuite) TearDownSuite(c *C) {
@@ -352,6 +362,139 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryDetails(c *C) {
 	c.Check(snap.Validate(result), IsNil)
 }
 
+const mockChannelMapsJSON = `"private": true,
+                "license": "GPL-3.0",
+                "screenshot_urls": ["https://example.com/shot1.png", "https://example.com/shot2.png"],
+                "channel_maps_list": [
+                    {
+                        "track": "latest",
+                        "architecture": "ARCH",
+                        "map": [
+                            {"info": "released", "channel": "stable", "revision": 25, "version": "6.0", "confinement": "strict", "binary_filesize": 20480},
+                            {"info": "tracking", "channel": "candidate"},
+                            {"info": "released", "channel": "edge", "revision": 26, "version": "6.1", "confinement": "devmode", "binary_filesize": 20481}
+                        ]
+                    },
+                    {
+                        "track": "2.0",
+                        "architecture": "ARCH",
+                        "map": [
+                            {"info": "released", "channel": "stable", "revision": 30, "version": "2.0", "confinement": "strict"}
+                        ]
+                    },
+                    {
+                        "track": "latest",
+                        "architecture": "other-arch",
+                        "map": [
+                            {"info": "released", "channel": "stable", "revision": 1, "version": "1.0", "confinement": "strict"}
+                        ]
+                    }
+                ]`
+
+func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryDetailsChannelsMediaLicense(c *C) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		extra := strings.Replace(mockChannelMapsJSON, "ARCH", arch.UbuntuArchitecture(), -1)
+		io.WriteString(w, strings.Replace(MockDetailsJSON, `"private": true`, extra, 1))
+	}))
+
+	c.Assert(mockServer, NotNil)
+	defer mockServer.Close()
+
+	searchURI, err := url.Parse(mockServer.URL + "/search")
+	c.Assert(err, IsNil)
+	cfg := SnapUbuntuStoreConfig{
+		SearchURI: searchURI,
+	}
+	repo := NewUbuntuStoreSnapRepository(&cfg, "")
+	c.Assert(repo, NotNil)
+
+	result, err := repo.Snap("hello-world", "edge", nil)
+	c.Assert(err, IsNil)
+	c.Check(result.Publisher, Equals, "Canonical")
+	c.Check(result.License, Equals, "GPL-3.0")
+	c.Check(result.Screenshots, DeepEquals, []string{"https://example.com/shot1.png", "https://example.com/shot2.png"})
+	c.Check(result.Channels, DeepEquals, map[string]*snap.ChannelSnapInfo{
+		"stable":     {Revision: snap.R(25), Version: "6.0", Confinement: snap.StrictConfinement, Size: 20480},
+		"edge":       {Revision: snap.R(26), Version: "6.1", Confinement: snap.DevmodeConfinement, Size: 20481},
+		"2.0/stable": {Revision: snap.R(30), Version: "2.0", Confinement: snap.StrictConfinement},
+	})
+}
+
+func (t *remoteRepoTestSuite) TestUbuntuStoreRepositorySnapForArchitecture(c *C) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Header.Get("X-Ubuntu-Architecture"), Equals, "armhf")
+		c.Check(r.Header.Get("X-Ubuntu-Device-Channel"), Equals, "edge")
+
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, MockDetailsJSON)
+	}))
+
+	c.Assert(mockServer, NotNil)
+	defer mockServer.Close()
+
+	searchURI, err := url.Parse(mockServer.URL + "/search")
+	c.Assert(err, IsNil)
+	cfg := SnapUbuntuStoreConfig{
+		SearchURI: searchURI,
+	}
+	repo := NewUbuntuStoreSnapRepository(&cfg, "")
+	c.Assert(repo, NotNil)
+
+	result, err := repo.SnapForArchitecture("hello-world", "edge", "armhf", nil)
+	c.Assert(err, IsNil)
+	c.Check(result.Name(), Equals, "hello-world")
+}
+
+const mockRevisionDetailsJSON = `{
+    "anon_download_url": "https://public.apps.ubuntu.com/anon/download-snap/buPKUD3TKqCOgLEjjHx5kSiCpIs5cMuQ_23.snap",
+    "download_sha512": "5364253e4a988f4f5c04380086d542f410455b97d48cc6c69ca2a5877d8aef2a6b2b2f83ec4f688cae61ebc8a6bf2cdbd4dbd8f743f0522fc76540429b79df42",
+    "package_name": "hello-world",
+    "revision": 23,
+    "version": "6.0"
+}`
+
+func (t *remoteRepoTestSuite) TestUbuntuStoreRepositorySnapRevision(c *C) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, Equals, "/details/hello-world")
+		c.Check(r.URL.Query().Get("revision"), Matches, "2[34]")
+		c.Check(r.URL.Query().Get("fields"), Equals, "anon_download_url")
+
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, mockRevisionDetailsJSON)
+	}))
+	c.Assert(mockServer, NotNil)
+	defer mockServer.Close()
+
+	detailsURI, err := url.Parse(mockServer.URL + "/details/?fields=anon_download_url")
+	c.Assert(err, IsNil)
+	repo := NewUbuntuStoreSnapRepository(&SnapUbuntuStoreConfig{DetailsURI: detailsURI}, "")
+
+	result, err := repo.SnapRevision("hello-world", snap.R(23), nil)
+	c.Assert(err, IsNil)
+	c.Check(result.Name(), Equals, "hello-world")
+	c.Check(result.Revision, Equals, snap.R(23))
+	c.Check(result.Sha512, Equals, "5364253e4a988f4f5c04380086d542f410455b97d48cc6c69ca2a5877d8aef2a6b2b2f83ec4f688cae61ebc8a6bf2cdbd4dbd8f743f0522fc76540429b79df42")
+
+	_, err = repo.SnapRevision("hello-world", snap.R(24), nil)
+	c.Check(err, ErrorMatches, `store returned revision 23 of snap "hello-world" instead of 24`)
+}
+
+func (t *remoteRepoTestSuite) TestUbuntuStoreRepositorySnapRevisionNotFound(c *C) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	c.Assert(mockServer, NotNil)
+	defer mockServer.Close()
+
+	detailsURI, err := url.Parse(mockServer.URL + "/details/")
+	c.Assert(err, IsNil)
+	repo := NewUbuntuStoreSnapRepository(&SnapUbuntuStoreConfig{DetailsURI: detailsURI}, "")
+
+	_, err = repo.SnapRevision("hello-world", snap.R(23), nil)
+	c.Check(err, Equals, ErrSnapNotFound)
+}
+
 func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryDetailsSetsAuth(c *C) {
 	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// check authorization is set
@@ -571,6 +714,41 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreFind(c *C) {
 	c.Check(snaps[0].MustBuy, Equals, true)
 }
 
+func (t *remoteRepoTestSuite) TestUbuntuStoreFindCachesWithETag(c *C) {
+	n := 0
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		if n > 1 {
+			c.Check(r.Header.Get("If-None-Match"), Equals, `"search-1"`)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		c.Check(r.Header.Get("If-None-Match"), Equals, "")
+		w.Header().Set("Content-Type", "application/hal+json")
+		w.Header().Set("ETag", `"search-1"`)
+		io.WriteString(w, MockSearchJSON)
+	}))
+	c.Assert(mockServer, NotNil)
+	defer mockServer.Close()
+
+	searchURI, err := url.Parse(mockServer.URL)
+	c.Assert(err, IsNil)
+	cfg := SnapUbuntuStoreConfig{
+		SearchURI:        searchURI,
+		MetadataCacheDir: c.MkDir(),
+	}
+	repo := NewUbuntuStoreSnapRepository(&cfg, "")
+	c.Assert(repo, NotNil)
+
+	for i := 0; i < 2; i++ {
+		snaps, err := repo.Find("hello", "", nil)
+		c.Assert(err, IsNil)
+		c.Assert(snaps, HasLen, 1)
+		c.Check(snaps[0].Name(), Equals, "hello-world")
+	}
+	c.Check(n, Equals, 2)
+}
+
 func (t *remoteRepoTestSuite) TestUbuntuStoreFindFails(c *C) {
 	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		c.Check(r.URL.RawQuery, Equals, "q=hello")
@@ -772,7 +950,7 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryListRefresh(c *C) {
 	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		jsonReq, err := ioutil.ReadAll(r.Body)
 		c.Assert(err, IsNil)
-		c.Assert(string(jsonReq), Equals, `{"snaps":[{"snap_id":"`+helloWorldSnapID+`","channel":"stable","revision":1,"epoch":"0","confinement":"strict"}],"fields":["snap_id","package_name","revision","version","download_url"]}`)
+		c.Assert(string(jsonReq), Equals, `{"snaps":[{"snap_id":"`+helloWorldSnapID+`","channel":"stable","revision":1,"epoch":"0","confinement":"strict"}],"fields":["snap_id","package_name","revision","version","download_url","rollout_percentage","binary_filesize","content","deltas","release_notes_url"]}`)
 		io.WriteString(w, MockUpdatesJSON)
 	}))
 
@@ -804,11 +982,71 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryListRefresh(c *C) {
 	c.Assert(results[0].Version, Equals, "16.04-1")
 }
 
+func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryListRefreshProgressiveRelease(c *C) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, strings.Replace(MockUpdatesJSON, `"revision": 6,`, `"revision": 6, "rollout_percentage": 12.5,`, 1))
+	}))
+
+	c.Assert(mockServer, NotNil)
+	defer mockServer.Close()
+
+	bulkURI, err := url.Parse(mockServer.URL + "/updates/")
+	c.Assert(err, IsNil)
+	repo := NewUbuntuStoreSnapRepository(&SnapUbuntuStoreConfig{BulkURI: bulkURI}, "")
+	c.Assert(repo, NotNil)
+
+	results, err := repo.ListRefresh([]*RefreshCandidate{
+		{
+			SnapID:   helloWorldSnapID,
+			Channel:  "stable",
+			Revision: snap.R(1),
+			Epoch:    "0",
+		},
+	}, nil)
+	c.Assert(err, IsNil)
+	c.Assert(results, HasLen, 1)
+	c.Check(results[0].RolloutPercentage, Equals, 12.5)
+}
+
+func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryListRefreshDeltasAndNotes(c *C) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, strings.Replace(MockUpdatesJSON, `"revision": 6,`, `"revision": 6,
+                "binary_filesize": 20480,
+                "release_notes_url": "https://example.com/hello-world/6",
+                "deltas": [
+                    {"from_revision": 2, "to_revision": 6, "format": "xdelta3", "binary_filesize": 512},
+                    {"from_revision": 1, "to_revision": 6, "format": "xdelta3", "binary_filesize": 1024}
+                ],`, 1))
+	}))
+
+	c.Assert(mockServer, NotNil)
+	defer mockServer.Close()
+
+	bulkURI, err := url.Parse(mockServer.URL + "/updates/")
+	c.Assert(err, IsNil)
+	repo := NewUbuntuStoreSnapRepository(&SnapUbuntuStoreConfig{BulkURI: bulkURI}, "")
+	c.Assert(repo, NotNil)
+
+	results, err := repo.ListRefresh([]*RefreshCandidate{
+		{
+			SnapID:   helloWorldSnapID,
+			Channel:  "stable",
+			Revision: snap.R(1),
+			Epoch:    "0",
+		},
+	}, nil)
+	c.Assert(err, IsNil)
+	c.Assert(results, HasLen, 1)
+	c.Check(results[0].Size, Equals, int64(20480))
+	c.Check(results[0].DeltaSize, Equals, int64(1024))
+	c.Check(results[0].ReleaseNotesURL, Equals, "https://example.com/hello-world/6")
+}
+
 func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryUpdateNotSendLocalRevs(c *C) {
 	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		jsonReq, err := ioutil.ReadAll(r.Body)
 		c.Assert(err, IsNil)
-		c.Assert(string(jsonReq), Equals, `{"snaps":[{"snap_id":"`+helloWorldSnapID+`","channel":"stable","epoch":"0","confinement":"devmode"}],"fields":["snap_id","package_name","revision","version","download_url"]}`)
+		c.Assert(string(jsonReq), Equals, `{"snaps":[{"snap_id":"`+helloWorldSnapID+`","channel":"stable","epoch":"0","confinement":"devmode"}],"fields":["snap_id","package_name","revision","version","download_url","rollout_percentage","binary_filesize","content","deltas","release_notes_url"]}`)
 		io.WriteString(w, MockUpdatesJSON)
 	}))
 
@@ -844,7 +1082,7 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryUpdatesSetsAuth(c *C) {
 
 		jsonReq, err := ioutil.ReadAll(r.Body)
 		c.Assert(err, IsNil)
-		c.Assert(string(jsonReq), Equals, `{"snaps":[{"snap_id":"`+helloWorldSnapID+`","channel":"stable","revision":1,"epoch":"0","confinement":"strict"}],"fields":["snap_id","package_name","revision","version","download_url"]}`)
+		c.Assert(string(jsonReq), Equals, `{"snaps":[{"snap_id":"`+helloWorldSnapID+`","channel":"stable","revision":1,"epoch":"0","confinement":"strict"}],"fields":["snap_id","package_name","revision","version","download_url","rollout_percentage","binary_filesize","content","deltas","release_notes_url"]}`)
 		io.WriteString(w, MockUpdatesJSON)
 	}))
 
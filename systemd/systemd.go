@@ -92,8 +92,10 @@ type Systemd interface {
 	Restart(service string, timeout time.Duration) error
 	Status(service string) (string, error)
 	ServiceStatus(service string) (*ServiceStatus, error)
+	UnitTimings(unit string) (*UnitTimings, error)
 	Logs(services []string) ([]Log, error)
 	WriteMountUnitFile(name, what, where string) (string, error)
+	WriteReadOnlyBindMountUnitFile(name, dir string) (string, error)
 }
 
 // A Log is a single entry in the systemd journal
@@ -275,6 +277,59 @@ func (s *systemd) ServiceStatus(serviceName string) (*ServiceStatus, error) {
 	return status, nil
 }
 
+// UnitTimings holds the points in time, relative to boot, at which a
+// unit was last started and at which it finished activating.
+type UnitTimings struct {
+	Unit string `json:"unit"`
+	// Activating is when the unit left the inactive state.
+	Activating time.Duration `json:"activating"`
+	// Active is when the unit entered the active state.
+	Active time.Duration `json:"active"`
+}
+
+// Duration is the time the unit took to activate, or zero if it never did.
+func (t *UnitTimings) Duration() time.Duration {
+	if t.Active < t.Activating {
+		return 0
+	}
+	return t.Active - t.Activating
+}
+
+// UnitTimings returns when the given unit was activated, relative to boot.
+func (s *systemd) UnitTimings(unit string) (*UnitTimings, error) {
+	bs, err := SystemctlCmd("show", "--property=Id,InactiveExitTimestampMonotonic,ActiveEnterTimestampMonotonic", unit)
+	if err != nil {
+		return nil, err
+	}
+
+	timings := &UnitTimings{Unit: unit}
+
+	for _, bs := range statusregex.FindAllSubmatch(bs, -1) {
+		if len(bs[0]) == 0 {
+			continue
+		}
+		k := string(bs[1])
+		v := string(bs[2])
+		var d *time.Duration
+		switch k {
+		case "InactiveExitTimestampMonotonic":
+			d = &timings.Activating
+		case "ActiveEnterTimestampMonotonic":
+			d = &timings.Active
+		default:
+			continue
+		}
+		// according to systemd.exec(5) these are in microseconds
+		us, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse %s of %s: %v", k, unit, err)
+		}
+		*d = time.Duration(us) * time.Microsecond
+	}
+
+	return timings, nil
+}
+
 // Stop the given service, and wait until it has stopped.
 func (s *systemd) Stop(serviceName string, timeout time.Duration) error {
 	if _, err := SystemctlCmd("stop", serviceName); err != nil {
@@ -431,3 +486,23 @@ WantedBy=multi-user.target
 	mu := MountUnitPath(where, "mount")
 	return filepath.Base(mu), osutil.AtomicWriteFile(mu, []byte(c), 0644, 0)
 }
+
+// WriteReadOnlyBindMountUnitFile writes a mount unit bind mounting dir
+// read-only onto itself.
+func (s *systemd) WriteReadOnlyBindMountUnitFile(name, dir string) (string, error) {
+	c := fmt.Sprintf(`[Unit]
+Description=Read-only mount unit for %s
+
+[Mount]
+What=%s
+Where=%s
+Options=bind,ro
+Type=none
+
+[Install]
+WantedBy=multi-user.target
+`, name, dir, dir)
+
+	mu := MountUnitPath(dir, "mount")
+	return filepath.Base(mu), osutil.AtomicWriteFile(mu, []byte(c), 0644, 0)
+}
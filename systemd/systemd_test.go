@@ -173,6 +173,41 @@ func (s *SystemdTestSuite) TestStatusObj(c *C) {
 	})
 }
 
+func (s *SystemdTestSuite) TestUnitTimings(c *C) {
+	s.outs = [][]byte{
+		[]byte("Id=foo.service\nInactiveExitTimestampMonotonic=2500000\nActiveEnterTimestampMonotonic=4000000\n"),
+	}
+	s.errors = []error{nil}
+	out, err := New("", s.rep).UnitTimings("foo.service")
+	c.Assert(err, IsNil)
+	c.Check(out, DeepEquals, &UnitTimings{
+		Unit:       "foo.service",
+		Activating: 2500 * time.Millisecond,
+		Active:     4 * time.Second,
+	})
+	c.Check(out.Duration(), Equals, 1500*time.Millisecond)
+	c.Check(s.argses, DeepEquals, [][]string{{"show", "--property=Id,InactiveExitTimestampMonotonic,ActiveEnterTimestampMonotonic", "foo.service"}})
+}
+
+func (s *SystemdTestSuite) TestUnitTimingsNeverActive(c *C) {
+	s.outs = [][]byte{
+		[]byte("Id=foo.service\nInactiveExitTimestampMonotonic=2500000\nActiveEnterTimestampMonotonic=0\n"),
+	}
+	s.errors = []error{nil}
+	out, err := New("", s.rep).UnitTimings("foo.service")
+	c.Assert(err, IsNil)
+	c.Check(out.Duration(), Equals, time.Duration(0))
+}
+
+func (s *SystemdTestSuite) TestUnitTimingsBadValue(c *C) {
+	s.outs = [][]byte{
+		[]byte("Id=foo.service\nActiveEnterTimestampMonotonic=nope\n"),
+	}
+	s.errors = []error{nil}
+	_, err := New("", s.rep).UnitTimings("foo.service")
+	c.Check(err, ErrorMatches, `cannot parse ActiveEnterTimestampMonotonic of foo.service: .*`)
+}
+
 func (s *SystemdTestSuite) TestStopTimeout(c *C) {
 	restore := MockStopDelays(time.Millisecond, 25*time.Second)
 	defer restore()
@@ -326,6 +361,28 @@ WantedBy=multi-user.target
 `, snapDir))
 }
 
+func (s *SystemdTestSuite) TestWriteReadOnlyBindMountUnit(c *C) {
+	mountUnitName, err := New("", nil).WriteReadOnlyBindMountUnitFile("foo", "/var/snap/foo/7")
+	c.Assert(err, IsNil)
+	defer os.Remove(mountUnitName)
+	c.Check(mountUnitName, Equals, "var-snap-foo-7.mount")
+
+	mount, err := ioutil.ReadFile(filepath.Join(dirs.SnapServicesDir, mountUnitName))
+	c.Assert(err, IsNil)
+	c.Assert(string(mount), Equals, `[Unit]
+Description=Read-only mount unit for foo
+
+[Mount]
+What=/var/snap/foo/7
+Where=/var/snap/foo/7
+Options=bind,ro
+Type=none
+
+[Install]
+WantedBy=multi-user.target
+`)
+}
+
 func (s *SystemdTestSuite) TestRestartCondUnmarshal(c *C) {
 	for cond := range RestartMap {
 		bs := []byte(cond)
@@ -43,7 +43,7 @@ export SNAP_NAME="pastebinit"
 export SNAP_VERSION="1.4.0.0.1"
 export SNAP_REVISION="44"
 export SNAP_ARCH="%[1]s"
-export SNAP_LIBRARY_PATH="/var/lib/snapd/lib/gl:"
+export SNAP_LIBRARY_PATH="/var/lib/snapd/lib/gl:/var/lib/snapd/lib/gl32:"
 export SNAP_USER_DATA="$HOME/snap/pastebinit/44"
 
 if [ ! -d "$SNAP_USER_DATA" ]; then
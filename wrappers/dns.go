@@ -0,0 +1,133 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2014-2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package wrappers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/dnsstub"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/systemd"
+)
+
+func dnsStubServiceName(s *snap.Info) string {
+	return fmt.Sprintf("snap.%s.dns-stub.service", s.Name())
+}
+
+func dnsStubServicePath(s *snap.Info) string {
+	return filepath.Join(dirs.SnapServicesDir, dnsStubServiceName(s))
+}
+
+func snapResolvConf(s *snap.Info) string {
+	return filepath.Join(dirs.SnapResolvDir, s.Name(), "resolv.conf")
+}
+
+func genDNSStubServiceFile(s *snap.Info, settings *snap.DNSSettings) string {
+	args := s.Name()
+	if settings.Mode == snap.DNSLink {
+		args = fmt.Sprintf("--link=%s %s", settings.Link, s.Name())
+	}
+	return fmt.Sprintf(`[Unit]
+# Auto-generated, DO NO EDIT
+Description=Stub resolver for snap %[1]s
+After=network.target systemd-resolved.service
+X-Snappy=yes
+
+[Service]
+ExecStart=/usr/bin/snap routine dns-stub %[2]s
+Restart=on-failure
+
+[Install]
+WantedBy=multi-user.target
+`, s.Name(), args)
+}
+
+// AddSnapDNS sets up the name resolution of the snap as given by its
+// network plugs. For snaps with their own stub resolver, it generates and
+// starts the service running the stub, and makes it the only nameserver
+// of the snap with a resolv.conf that the mount profile of the snap binds
+// over /etc/resolv.conf, see AddSnapMounts. Queries the snap sends to
+// other resolvers directly are neither logged nor stopped.
+func AddSnapDNS(s *snap.Info, inter interacter) error {
+	settings, err := s.DNS()
+	if err != nil {
+		return err
+	}
+	if settings.Mode == snap.DNSHost {
+		return RemoveSnapDNS(s, inter)
+	}
+
+	resolvConf := snapResolvConf(s)
+	if err := os.MkdirAll(filepath.Dir(resolvConf), 0755); err != nil {
+		return err
+	}
+	content := fmt.Sprintf("# Auto-generated, DO NO EDIT\nnameserver %s\n", dnsstub.ListenAddress(s.Name()))
+	if err := osutil.AtomicWriteFile(resolvConf, []byte(content), 0644, 0); err != nil {
+		return err
+	}
+
+	replaced, err := writeUnitFile(dnsStubServicePath(s), genDNSStubServiceFile(s, settings))
+	if err != nil {
+		return err
+	}
+	sysd := systemd.New(dirs.GlobalRootDir, inter)
+	if err := sysd.DaemonReload(); err != nil {
+		return err
+	}
+	name := dnsStubServiceName(s)
+	if err := sysd.Enable(name); err != nil {
+		return err
+	}
+	if replaced {
+		return sysd.Restart(name, killWait)
+	}
+	return sysd.Start(name)
+}
+
+// RemoveSnapDNS stops the stub resolver of the snap, if it has one, and
+// removes the files that set up its name resolution.
+func RemoveSnapDNS(s *snap.Info, inter interacter) error {
+	path := dnsStubServicePath(s)
+	if osutil.FileExists(path) {
+		sysd := systemd.New(dirs.GlobalRootDir, inter)
+		name := dnsStubServiceName(s)
+		if err := sysd.Disable(name); err != nil {
+			return err
+		}
+		if err := sysd.Stop(name, killWait); err != nil {
+			return err
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		if err := sysd.DaemonReload(); err != nil {
+			return err
+		}
+	}
+	if err := os.Remove(snapResolvConf(s)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	os.Remove(filepath.Dir(snapResolvConf(s)))
+	return nil
+}
@@ -0,0 +1,110 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2014-2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package wrappers_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/dnsstub"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/systemd"
+	"github.com/snapcore/snapd/wrappers"
+)
+
+type dnsTestSuite struct {
+	tempdir    string
+	prevctlCmd func(...string) ([]byte, error)
+	sysdLog    [][]string
+}
+
+var _ = Suite(&dnsTestSuite{})
+
+func (s *dnsTestSuite) SetUpTest(c *C) {
+	s.tempdir = c.MkDir()
+	dirs.SetRootDir(s.tempdir)
+
+	s.sysdLog = nil
+	s.prevctlCmd = systemd.SystemctlCmd
+	systemd.SystemctlCmd = func(cmd ...string) ([]byte, error) {
+		s.sysdLog = append(s.sysdLog, cmd)
+		return []byte("ActiveState=inactive\n"), nil
+	}
+}
+
+func (s *dnsTestSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("")
+	systemd.SystemctlCmd = s.prevctlCmd
+}
+
+const dnsLinkSnapYaml = `name: lookup
+version: 1.0
+plugs:
+  network:
+    dns: link
+    dns-link: eth1
+`
+
+func (s *dnsTestSuite) TestAddSnapDNSAndRemove(c *C) {
+	info := snaptest.MockSnap(c, dnsLinkSnapYaml, &snap.SideInfo{Revision: snap.R(1)})
+
+	err := wrappers.AddSnapDNS(info, nil)
+	c.Assert(err, IsNil)
+
+	svcFile := filepath.Join(s.tempdir, "/etc/systemd/system/snap.lookup.dns-stub.service")
+	content, err := ioutil.ReadFile(svcFile)
+	c.Assert(err, IsNil)
+	c.Check(string(content), Matches, `(?s).*\nExecStart=/usr/bin/snap routine dns-stub --link=eth1 lookup\n.*`)
+
+	resolvConf := filepath.Join(dirs.SnapResolvDir, "lookup", "resolv.conf")
+	content, err = ioutil.ReadFile(resolvConf)
+	c.Assert(err, IsNil)
+	c.Check(string(content), Equals, "# Auto-generated, DO NO EDIT\nnameserver "+dnsstub.ListenAddress("lookup")+"\n")
+
+	c.Check(s.sysdLog, DeepEquals, [][]string{
+		{"daemon-reload"},
+		{"--root", s.tempdir, "enable", "snap.lookup.dns-stub.service"},
+		{"start", "snap.lookup.dns-stub.service"},
+	})
+
+	s.sysdLog = nil
+	err = wrappers.RemoveSnapDNS(info, nil)
+	c.Assert(err, IsNil)
+	c.Check(osutil.FileExists(svcFile), Equals, false)
+	_, err = os.Stat(filepath.Dir(resolvConf))
+	c.Check(os.IsNotExist(err), Equals, true)
+	c.Check(s.sysdLog[0], DeepEquals, []string{"--root", s.tempdir, "disable", "snap.lookup.dns-stub.service"})
+	c.Check(s.sysdLog[len(s.sysdLog)-1], DeepEquals, []string{"daemon-reload"})
+}
+
+func (s *dnsTestSuite) TestAddSnapDNSHost(c *C) {
+	info := snaptest.MockSnap(c, "name: lookup\nversion: 1.0\nplugs: {network: null}\n", &snap.SideInfo{Revision: snap.R(1)})
+
+	err := wrappers.AddSnapDNS(info, nil)
+	c.Assert(err, IsNil)
+	c.Check(s.sysdLog, HasLen, 0)
+	c.Check(osutil.FileExists(filepath.Join(dirs.SnapResolvDir, "lookup", "resolv.conf")), Equals, false)
+}
@@ -0,0 +1,124 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package wrappers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/systemd"
+)
+
+// The services of a snap whose output is not forwarded log to a
+// journald namespace of their own, configured not to forward to
+// syslog; their logs stay available with journalctl --namespace.
+const journalConfTemplate = `[Journal]
+ForwardToSyslog=no
+ForwardToKMsg=no
+ForwardToConsole=no
+ForwardToWall=no
+`
+
+func journalNamespace(snapName string) string {
+	return "snap-" + snapName
+}
+
+func journalConfPath(snapName string) string {
+	return filepath.Join(dirs.SnapJournaldConfDir, fmt.Sprintf("journald@%s.conf", journalNamespace(snapName)))
+}
+
+func journalDropInPath(serviceFile string) string {
+	return filepath.Join(serviceFile+".d", "journal-forward.conf")
+}
+
+// writeJournalDropIn makes the service log to the journald namespace
+// of the snap if its output is not forwarded, and to the main journal
+// otherwise. It reports whether the drop-in changed.
+func writeJournalDropIn(snapName, serviceFile string) (changed bool, err error) {
+	path := journalDropInPath(serviceFile)
+	if !osutil.FileExists(journalConfPath(snapName)) {
+		return removeJournalDropIn(serviceFile)
+	}
+
+	content := fmt.Sprintf("[Service]\nLogNamespace=%s\n", journalNamespace(snapName))
+	old, err := ioutil.ReadFile(path)
+	if err == nil && string(old) == content {
+		return false, nil
+	}
+	if _, err := writeUnitFile(path, content); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func removeJournalDropIn(serviceFile string) (removed bool, err error) {
+	path := journalDropInPath(serviceFile)
+	if err := os.Remove(path); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	// leave the directory alone if something else is in there
+	os.Remove(filepath.Dir(path))
+	return true, nil
+}
+
+// SetSnapJournalForward controls whether the journal output of the
+// services of the snap is forwarded to syslog, and from there to any
+// remote log collector, like that of the rest of the system. The
+// change applies to the services when they are next started.
+func SetSnapJournalForward(snapName string, forward bool, inter interacter) error {
+	if forward {
+		if err := os.Remove(journalConfPath(snapName)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	} else {
+		if err := os.MkdirAll(dirs.SnapJournaldConfDir, 0755); err != nil {
+			return err
+		}
+		if err := osutil.AtomicWriteFile(journalConfPath(snapName), []byte(journalConfTemplate), 0644, 0); err != nil {
+			return err
+		}
+	}
+
+	serviceFiles, err := filepath.Glob(filepath.Join(dirs.SnapServicesDir, fmt.Sprintf("snap.%s.*.service", snapName)))
+	if err != nil {
+		return err
+	}
+	reload := false
+	for _, serviceFile := range serviceFiles {
+		changed, err := writeJournalDropIn(snapName, serviceFile)
+		if err != nil {
+			return err
+		}
+		reload = reload || changed
+	}
+	if !reload {
+		return nil
+	}
+
+	sysd := systemd.New(dirs.GlobalRootDir, inter)
+	return sysd.DaemonReload()
+}
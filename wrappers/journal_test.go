@@ -0,0 +1,110 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package wrappers_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/systemd"
+	"github.com/snapcore/snapd/wrappers"
+)
+
+type journalTestSuite struct {
+	tempdir    string
+	prevctlCmd func(...string) ([]byte, error)
+	sysdLog    [][]string
+}
+
+var _ = Suite(&journalTestSuite{})
+
+func (s *journalTestSuite) SetUpTest(c *C) {
+	s.tempdir = c.MkDir()
+	dirs.SetRootDir(s.tempdir)
+
+	s.sysdLog = nil
+	s.prevctlCmd = systemd.SystemctlCmd
+	systemd.SystemctlCmd = func(cmd ...string) ([]byte, error) {
+		s.sysdLog = append(s.sysdLog, cmd)
+		return []byte("ActiveState=inactive\n"), nil
+	}
+}
+
+func (s *journalTestSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("")
+	systemd.SystemctlCmd = s.prevctlCmd
+}
+
+func (s *journalTestSuite) TestSetSnapJournalForward(c *C) {
+	info := snaptest.MockSnap(c, packageHello, &snap.SideInfo{Revision: snap.R(12)})
+	c.Assert(wrappers.AddSnapServices(info, nil), IsNil)
+
+	conf := filepath.Join(s.tempdir, "/etc/systemd/journald@snap-hello-snap.conf")
+	dropIn := filepath.Join(s.tempdir, "/etc/systemd/system/snap.hello-snap.svc1.service.d/journal-forward.conf")
+
+	s.sysdLog = nil
+	err := wrappers.SetSnapJournalForward("hello-snap", false, &progress.NullProgress{})
+	c.Assert(err, IsNil)
+	data, err := ioutil.ReadFile(conf)
+	c.Assert(err, IsNil)
+	c.Check(string(data), Matches, "(?s)\\[Journal\\]\nForwardToSyslog=no\n.*")
+	data, err = ioutil.ReadFile(dropIn)
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, "[Service]\nLogNamespace=snap-hello-snap\n")
+	c.Check(s.sysdLog, DeepEquals, [][]string{{"daemon-reload"}})
+
+	// nothing changes, no reload
+	s.sysdLog = nil
+	err = wrappers.SetSnapJournalForward("hello-snap", false, &progress.NullProgress{})
+	c.Assert(err, IsNil)
+	c.Check(s.sysdLog, HasLen, 0)
+
+	s.sysdLog = nil
+	err = wrappers.SetSnapJournalForward("hello-snap", true, &progress.NullProgress{})
+	c.Assert(err, IsNil)
+	c.Check(osutil.FileExists(conf), Equals, false)
+	c.Check(osutil.FileExists(filepath.Dir(dropIn)), Equals, false)
+	c.Check(s.sysdLog, DeepEquals, [][]string{{"daemon-reload"}})
+}
+
+func (s *journalTestSuite) TestAddSnapServicesKeepsJournalForward(c *C) {
+	err := wrappers.SetSnapJournalForward("hello-snap", false, &progress.NullProgress{})
+	c.Assert(err, IsNil)
+	// no services yet, nothing to reload
+	c.Check(s.sysdLog, HasLen, 0)
+
+	info := snaptest.MockSnap(c, packageHello, &snap.SideInfo{Revision: snap.R(12)})
+	c.Assert(wrappers.AddSnapServices(info, nil), IsNil)
+
+	dropIn := filepath.Join(s.tempdir, "/etc/systemd/system/snap.hello-snap.svc1.service.d/journal-forward.conf")
+	c.Check(osutil.FileExists(dropIn), Equals, true)
+
+	c.Assert(wrappers.RemoveSnapServices(info, &progress.NullProgress{}), IsNil)
+	c.Check(osutil.FileExists(filepath.Dir(dropIn)), Equals, false)
+	// the setting itself stays for the next revision
+	c.Check(osutil.FileExists(filepath.Join(s.tempdir, "/etc/systemd/journald@snap-hello-snap.conf")), Equals, true)
+}
@@ -0,0 +1,160 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2014-2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package wrappers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/snap"
+)
+
+// DefaultTmpSize is the size of the private /tmp of the snaps whose
+// size is not set, as a share of the memory of the system.
+const DefaultTmpSize = "10%"
+
+var validTmpSize = regexp.MustCompile(`^[1-9][0-9]*[kKmMgG%]?$`)
+
+// ValidateTmpSize checks that size is a size of tmpfs: a number of
+// bytes, optionally with a k, m or g suffix, or a share of the memory of
+// the system, such as 10%.
+func ValidateTmpSize(size string) error {
+	if !validTmpSize.MatchString(size) {
+		return fmt.Errorf("invalid size of /tmp: %q", size)
+	}
+	if strings.HasSuffix(size, "%") {
+		if n, err := strconv.Atoi(strings.TrimSuffix(size, "%")); err != nil || n > 100 {
+			return fmt.Errorf("invalid size of /tmp: %q is more than all of the memory", size)
+		}
+	}
+	return nil
+}
+
+// snapMountProfile returns the path of the mount profile of the snap,
+// the mounts ubuntu-core-launcher sets up in the mount namespace of the
+// snap, which it does from 1.0.41 on.
+func snapMountProfile(snapName string) string {
+	return filepath.Join(dirs.SnapMountPolicyDir, fmt.Sprintf("snap.%s.fstab", snapName))
+}
+
+func snapTmpSizePath(snapName string) string {
+	return filepath.Join(dirs.SnapMountPolicyDir, fmt.Sprintf("snap.%s.tmp-size", snapName))
+}
+
+// snapTmpSize returns the size of the private /tmp of the snap.
+func snapTmpSize(snapName string) string {
+	size, err := ioutil.ReadFile(snapTmpSizePath(snapName))
+	if err != nil || ValidateTmpSize(string(size)) != nil {
+		return DefaultTmpSize
+	}
+	return string(size)
+}
+
+func tmpMountEntry(snapName string) string {
+	return fmt.Sprintf("tmpfs /tmp tmpfs rw,nosuid,nodev,mode=1777,size=%s 0 0", snapTmpSize(snapName))
+}
+
+// snapMountEntries returns the mounts of the mount profile of the snap.
+func snapMountEntries(s *snap.Info) ([]string, error) {
+	var entries []string
+	if s.UsesHostTmp() {
+		entries = append(entries, "/tmp /tmp none rbind,rw 0 0")
+	} else {
+		entries = append(entries, tmpMountEntry(s.Name()))
+	}
+
+	settings, err := s.DNS()
+	if err != nil {
+		return nil, err
+	}
+	if settings.Mode != snap.DNSHost {
+		entries = append(entries, fmt.Sprintf("%s /etc/resolv.conf none bind,ro 0 0", dirs.StripRootDir(snapResolvConf(s))))
+	}
+
+	return entries, nil
+}
+
+// AddSnapMounts writes the mount profile of the snap: a private tmpfs
+// over /tmp, of the size set with SetSnapTmpSize, unless the snap has a
+// host-tmp plug to share the /tmp of the host, and the resolv.conf of
+// the snap over /etc/resolv.conf, unless it uses the name resolution of
+// the host, see AddSnapDNS.
+func AddSnapMounts(s *snap.Info) error {
+	entries, err := snapMountEntries(s)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dirs.SnapMountPolicyDir, 0755); err != nil {
+		return err
+	}
+	content := strings.Join(entries, "\n") + "\n"
+	return osutil.AtomicWriteFile(snapMountProfile(s.Name()), []byte(content), 0644, 0)
+}
+
+// RemoveSnapMounts removes the mount profile of the snap.
+func RemoveSnapMounts(s *snap.Info) error {
+	if err := os.Remove(snapMountProfile(s.Name())); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// SetSnapTmpSize sets the size of the private /tmp of the snap, or puts
+// back the default one if size is empty. The mount profile of the snap,
+// if it is installed, is updated for the change to apply when its apps
+// are next started.
+func SetSnapTmpSize(snapName, size string) error {
+	if size != "" {
+		if err := ValidateTmpSize(size); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(dirs.SnapMountPolicyDir, 0755); err != nil {
+			return err
+		}
+		if err := osutil.AtomicWriteFile(snapTmpSizePath(snapName), []byte(size), 0644, 0); err != nil {
+			return err
+		}
+	} else if err := os.Remove(snapTmpSizePath(snapName)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	profile := snapMountProfile(snapName)
+	content, err := ioutil.ReadFile(profile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	entries := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	for i, entry := range entries {
+		if strings.HasPrefix(entry, "tmpfs /tmp ") {
+			entries[i] = tmpMountEntry(snapName)
+		}
+	}
+	return osutil.AtomicWriteFile(profile, []byte(strings.Join(entries, "\n")+"\n"), 0644, 0)
+}
@@ -0,0 +1,102 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2014-2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package wrappers_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/wrappers"
+)
+
+type mountTestSuite struct{}
+
+var _ = Suite(&mountTestSuite{})
+
+func (s *mountTestSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+}
+
+func (s *mountTestSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("")
+}
+
+func (s *mountTestSuite) profile(c *C, snapName string) string {
+	content, err := ioutil.ReadFile(filepath.Join(dirs.SnapMountPolicyDir, "snap."+snapName+".fstab"))
+	c.Assert(err, IsNil)
+	return string(content)
+}
+
+func (s *mountTestSuite) TestAddSnapMountsPrivateTmp(c *C) {
+	info := snaptest.MockSnap(c, "name: foo\nversion: 1.0\n", &snap.SideInfo{Revision: snap.R(1)})
+
+	c.Assert(wrappers.AddSnapMounts(info), IsNil)
+	c.Check(s.profile(c, "foo"), Equals, "tmpfs /tmp tmpfs rw,nosuid,nodev,mode=1777,size=10% 0 0\n")
+
+	c.Assert(wrappers.RemoveSnapMounts(info), IsNil)
+	c.Check(osutil.FileExists(filepath.Join(dirs.SnapMountPolicyDir, "snap.foo.fstab")), Equals, false)
+	// removing it again is fine
+	c.Assert(wrappers.RemoveSnapMounts(info), IsNil)
+}
+
+func (s *mountTestSuite) TestAddSnapMountsHostTmpAndDNS(c *C) {
+	info := snaptest.MockSnap(c, dnsLinkSnapYaml+"  host-tmp:\n", &snap.SideInfo{Revision: snap.R(1)})
+
+	c.Assert(wrappers.AddSnapMounts(info), IsNil)
+	c.Check(s.profile(c, "lookup"), Equals, "/tmp /tmp none rbind,rw 0 0\n"+
+		"/var/lib/snapd/resolv/lookup/resolv.conf /etc/resolv.conf none bind,ro 0 0\n")
+}
+
+func (s *mountTestSuite) TestSetSnapTmpSize(c *C) {
+	info := snaptest.MockSnap(c, dnsLinkSnapYaml, &snap.SideInfo{Revision: snap.R(1)})
+
+	// set before the snap is installed
+	c.Assert(wrappers.SetSnapTmpSize("lookup", "64M"), IsNil)
+	c.Check(osutil.FileExists(filepath.Join(dirs.SnapMountPolicyDir, "snap.lookup.fstab")), Equals, false)
+	c.Assert(wrappers.AddSnapMounts(info), IsNil)
+	c.Check(s.profile(c, "lookup"), Equals, "tmpfs /tmp tmpfs rw,nosuid,nodev,mode=1777,size=64M 0 0\n"+
+		"/var/lib/snapd/resolv/lookup/resolv.conf /etc/resolv.conf none bind,ro 0 0\n")
+
+	// and changed once it is
+	c.Assert(wrappers.SetSnapTmpSize("lookup", "1g"), IsNil)
+	c.Check(s.profile(c, "lookup"), Equals, "tmpfs /tmp tmpfs rw,nosuid,nodev,mode=1777,size=1g 0 0\n"+
+		"/var/lib/snapd/resolv/lookup/resolv.conf /etc/resolv.conf none bind,ro 0 0\n")
+
+	c.Assert(wrappers.SetSnapTmpSize("lookup", ""), IsNil)
+	c.Check(s.profile(c, "lookup"), Matches, "tmpfs /tmp tmpfs .*,size=10% 0 0\n.*\n")
+
+	c.Check(wrappers.SetSnapTmpSize("lookup", "lots"), ErrorMatches, `invalid size of /tmp: "lots"`)
+}
+
+func (s *mountTestSuite) TestValidateTmpSize(c *C) {
+	for _, size := range []string{"1048576", "512k", "64M", "2g", "1%", "100%"} {
+		c.Check(wrappers.ValidateTmpSize(size), IsNil, Commentf(size))
+	}
+	for _, size := range []string{"", "0", "-1", "64MB", "1.5G", "10 %", "%"} {
+		c.Check(wrappers.ValidateTmpSize(size), ErrorMatches, "invalid size of /tmp: .*", Commentf(size))
+	}
+	c.Check(wrappers.ValidateTmpSize("101%"), ErrorMatches, `invalid size of /tmp: "101%" is more than all of the memory`)
+}
@@ -22,6 +22,7 @@ package wrappers
 import (
 	"bytes"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
@@ -41,6 +42,40 @@ type interacter interface {
 	Notify(status string)
 }
 
+// ServiceStartError is returned when a service of a snap fails to start.
+type ServiceStartError struct {
+	Service string
+	Err     error
+	// Log has the last entries the service logged, for context.
+	Log []string
+}
+
+func (e *ServiceStartError) Error() string {
+	return e.Err.Error()
+}
+
+// maxServiceLog is how many of the entries logged by a service that
+// failed to start are kept.
+const maxServiceLog = 20
+
+// serviceStartError wraps the error starting the service with what it
+// last logged.
+func serviceStartError(sysd systemd.Systemd, serviceName string, err error) error {
+	e := &ServiceStartError{Service: serviceName, Err: err}
+	logs, lerr := sysd.Logs([]string{serviceName})
+	if lerr != nil {
+		logger.Noticef("cannot get the log of service %q: %v", serviceName, lerr)
+		return e
+	}
+	if len(logs) > maxServiceLog {
+		logs = logs[len(logs)-maxServiceLog:]
+	}
+	for _, l := range logs {
+		e.Log = append(e.Log, l.String())
+	}
+	return e
+}
+
 // wait this time between TERM and KILL
 var killWait = 5 * time.Second
 
@@ -74,75 +109,210 @@ func generateSnapSocketFile(app *snap.AppInfo) (string, error) {
 	return genSocketFile(app), nil
 }
 
+// writeUnitFile writes the unit file at path, leaving it alone if it
+// already has the given content. It reports whether a unit with a
+// different content was replaced.
+func writeUnitFile(path, content string) (replaced bool, err error) {
+	old, err := ioutil.ReadFile(path)
+	if err == nil && string(old) == content {
+		return false, nil
+	}
+	os.MkdirAll(filepath.Dir(path), 0755)
+	if err := osutil.AtomicWriteFile(path, []byte(content), 0644, 0); err != nil {
+		return false, err
+	}
+	return old != nil, nil
+}
+
 // AddSnapServices adds and starts service units for the applications from the snap which are services.
+//
+// Services are started in the order given by snap.SortServices. A
+// service whose unit was left in place by RemoveSnapServicesForRefresh
+// is restarted if its unit changed, and otherwise just kept running.
 func AddSnapServices(s *snap.Info, inter interacter) error {
-	for _, app := range s.Apps {
-		if app.Daemon == "" {
-			continue
-		}
-		// Generate service file
-		content, err := generateSnapServiceFile(app)
-		if err != nil {
+	svcs, err := snap.SortServices(s.Services())
+	if err != nil {
+		return err
+	}
+	if len(svcs) == 0 {
+		return nil
+	}
+
+	// write all the units first so one daemon-reload picks them up
+	restart, err := writeSnapServiceUnits(svcs)
+	if err != nil {
+		return err
+	}
+
+	sysd := systemd.New(dirs.GlobalRootDir, inter)
+	if err := sysd.DaemonReload(); err != nil {
+		return err
+	}
+
+	for _, app := range svcs {
+		// enable plus start, or restart if the unit changed under a
+		// running service
+		serviceName := filepath.Base(app.ServiceFile())
+		if err := sysd.Enable(serviceName); err != nil {
 			return err
 		}
-		svcFilePath := app.ServiceFile()
-		os.MkdirAll(filepath.Dir(svcFilePath), 0755)
-		if err := osutil.AtomicWriteFile(svcFilePath, []byte(content), 0644, 0); err != nil {
-			return err
+		if restart[app.ServiceFile()] {
+			err = sysd.Restart(serviceName, serviceStopTimeout(app))
+		} else {
+			err = sysd.Start(serviceName)
 		}
-		// Generate systemd socket file if needed
+		if err != nil {
+			return serviceStartError(sysd, serviceName, err)
+		}
+
 		if app.Socket {
-			content, err := generateSnapSocketFile(app)
-			if err != nil {
+			socketName := filepath.Base(app.ServiceSocketFile())
+			if err := sysd.Enable(socketName); err != nil {
 				return err
 			}
-			svcSocketFilePath := app.ServiceSocketFile()
-			os.MkdirAll(filepath.Dir(svcSocketFilePath), 0755)
-			if err := osutil.AtomicWriteFile(svcSocketFilePath, []byte(content), 0644, 0); err != nil {
-				return err
+			if restart[app.ServiceSocketFile()] {
+				err = sysd.Restart(socketName, serviceStopTimeout(app))
+			} else {
+				err = sysd.Start(socketName)
+			}
+			if err != nil {
+				return serviceStartError(sysd, socketName, err)
 			}
 		}
-		// daemon-reload and enable plus start
-		serviceName := filepath.Base(app.ServiceFile())
-		sysd := systemd.New(dirs.GlobalRootDir, inter)
+	}
 
-		if err := sysd.DaemonReload(); err != nil {
-			return err
-		}
+	return nil
+}
 
-		// enable the service
-		if err := sysd.Enable(serviceName); err != nil {
-			return err
-		}
+// EnableSnapServices writes and enables the service units for the
+// applications from the snap which are services, without reloading
+// systemd or starting them. It is used when preseeding an image, where
+// there is no running systemd to talk to; the units are started on boot.
+func EnableSnapServices(s *snap.Info, inter interacter) error {
+	svcs, err := snap.SortServices(s.Services())
+	if err != nil {
+		return err
+	}
+	if len(svcs) == 0 {
+		return nil
+	}
 
-		if err := sysd.Start(serviceName); err != nil {
+	if _, err := writeSnapServiceUnits(svcs); err != nil {
+		return err
+	}
+
+	sysd := systemd.New(dirs.GlobalRootDir, inter)
+	for _, app := range svcs {
+		if err := sysd.Enable(filepath.Base(app.ServiceFile())); err != nil {
 			return err
 		}
-
 		if app.Socket {
-			socketName := filepath.Base(app.ServiceSocketFile())
-			// enable the socket
-			if err := sysd.Enable(socketName); err != nil {
+			if err := sysd.Enable(filepath.Base(app.ServiceSocketFile())); err != nil {
 				return err
 			}
+		}
+	}
 
-			if err := sysd.Start(socketName); err != nil {
-				return err
+	return nil
+}
+
+// WriteSnapServices writes the service units for the applications from
+// the snap which are services and reloads systemd, without enabling or
+// starting them. It is used for snaps whose license was not accepted
+// yet; AddSnapServices enables and starts them once it is.
+func WriteSnapServices(s *snap.Info, inter interacter) error {
+	svcs, err := snap.SortServices(s.Services())
+	if err != nil {
+		return err
+	}
+	if len(svcs) == 0 {
+		return nil
+	}
+
+	if _, err := writeSnapServiceUnits(svcs); err != nil {
+		return err
+	}
+
+	sysd := systemd.New(dirs.GlobalRootDir, inter)
+	return sysd.DaemonReload()
+}
+
+// writeSnapServiceUnits writes the service (and socket) units of the
+// given apps, returning which of the unit files replaced different content.
+func writeSnapServiceUnits(svcs []*snap.AppInfo) (map[string]bool, error) {
+	replaced := make(map[string]bool)
+	for _, app := range svcs {
+		content, err := generateSnapServiceFile(app)
+		if err != nil {
+			return nil, err
+		}
+		changed, err := writeUnitFile(app.ServiceFile(), content)
+		if err != nil {
+			return nil, err
+		}
+		dropInChanged, err := writeJournalDropIn(app.Snap.Name(), app.ServiceFile())
+		if err != nil {
+			return nil, err
+		}
+		replaced[app.ServiceFile()] = changed || dropInChanged
+		// Generate systemd socket file if needed
+		if app.Socket {
+			content, err := generateSnapSocketFile(app)
+			if err != nil {
+				return nil, err
 			}
+			changed, err := writeUnitFile(app.ServiceSocketFile(), content)
+			if err != nil {
+				return nil, err
+			}
+			replaced[app.ServiceSocketFile()] = changed
 		}
 	}
 
-	return nil
+	return replaced, nil
 }
 
 // RemoveSnapServices stops and removes service units for the applications from the snap which are services.
 func RemoveSnapServices(s *snap.Info, inter interacter) error {
+	return removeSnapServices(s, nil, inter)
+}
+
+// RemoveSnapServicesForRefresh is like RemoveSnapServices but is used
+// when s is about to be replaced by next: services that next declares
+// with refresh-mode "endure" are left running, together with their
+// socket if next still has one, so that AddSnapServices for next only
+// needs to restart them. A socket kept this way holds on to incoming
+// connections while its service is restarted.
+func RemoveSnapServicesForRefresh(s, next *snap.Info, inter interacter) error {
+	return removeSnapServices(s, next, inter)
+}
+
+func removeSnapServices(s, next *snap.Info, inter interacter) error {
+	svcs, err := snap.SortServices(s.Services())
+	if err != nil {
+		return err
+	}
+
 	sysd := systemd.New(dirs.GlobalRootDir, inter)
 
 	nservices := 0
 
-	for _, app := range s.Apps {
-		if app.Daemon == "" {
+	// stop in the reverse of the start order
+	for i := len(svcs) - 1; i >= 0; i-- {
+		app := svcs[i]
+		var nextApp *snap.AppInfo
+		if next != nil {
+			nextApp = next.Apps[app.Name]
+		}
+		if nextApp != nil && nextApp.Daemon != "" && nextApp.RefreshMode == "endure" {
+			if !app.Socket || nextApp.Socket {
+				continue
+			}
+			// the service endures but its socket goes away
+			nservices++
+			if err := removeSocket(sysd, app); err != nil {
+				return err
+			}
 			continue
 		}
 		nservices++
@@ -167,6 +337,10 @@ func RemoveSnapServices(s *snap.Info, inter interacter) error {
 			logger.Noticef("Failed to remove service file for %q: %v", serviceName, err)
 		}
 
+		if _, err := removeJournalDropIn(app.ServiceFile()); err != nil {
+			logger.Noticef("Failed to remove journal drop-in for %q: %v", serviceName, err)
+		}
+
 		if err := os.Remove(app.ServiceSocketFile()); err != nil && !os.IsNotExist(err) {
 			logger.Noticef("Failed to remove socket file for %q: %v", serviceName, err)
 		}
@@ -182,23 +356,108 @@ func RemoveSnapServices(s *snap.Info, inter interacter) error {
 	return nil
 }
 
+// InactiveSnapServices returns the units of the services of the snap
+// that are not up: those not active, but for oneshot services that
+// ran, and for socket activated ones those whose socket is not active.
+func InactiveSnapServices(s *snap.Info, inter interacter) ([]string, error) {
+	sysd := systemd.New(dirs.GlobalRootDir, inter)
+	var inactive []string
+	for _, app := range s.Services() {
+		unit := filepath.Base(app.ServiceFile())
+		if app.Socket {
+			unit = filepath.Base(app.ServiceSocketFile())
+		}
+		status, err := sysd.ServiceStatus(unit)
+		if err != nil {
+			return nil, err
+		}
+		if status.ActiveState == "active" || (app.Daemon == "oneshot" && status.ActiveState == "inactive") {
+			continue
+		}
+		inactive = append(inactive, unit)
+	}
+	return inactive, nil
+}
+
+// RestartSnapServices restarts the running services of the snap, for
+// them to pick up changes to the system, like to its locale. They are
+// stopped in the reverse of the order given by snap.SortServices and
+// started again in that order; services with refresh-mode "endure" are
+// left running, as they are on refreshes.
+func RestartSnapServices(s *snap.Info, inter interacter) error {
+	svcs, err := snap.SortServices(s.Services())
+	if err != nil {
+		return err
+	}
+
+	sysd := systemd.New(dirs.GlobalRootDir, inter)
+	var running []*snap.AppInfo
+	for _, app := range svcs {
+		if app.RefreshMode == "endure" {
+			continue
+		}
+		status, err := sysd.ServiceStatus(filepath.Base(app.ServiceFile()))
+		if err != nil {
+			return err
+		}
+		if status.ActiveState == "active" {
+			running = append(running, app)
+		}
+	}
+
+	for i := len(running) - 1; i >= 0; i-- {
+		app := running[i]
+		if err := sysd.Stop(filepath.Base(app.ServiceFile()), serviceStopTimeout(app)); err != nil {
+			return err
+		}
+	}
+	for _, app := range running {
+		serviceName := filepath.Base(app.ServiceFile())
+		if err := sysd.Start(serviceName); err != nil {
+			return serviceStartError(sysd, serviceName, err)
+		}
+	}
+
+	return nil
+}
+
+func removeSocket(sysd systemd.Systemd, app *snap.AppInfo) error {
+	socketName := filepath.Base(app.ServiceSocketFile())
+	if err := sysd.Disable(socketName); err != nil {
+		return err
+	}
+	if err := sysd.Stop(socketName, serviceStopTimeout(app)); err != nil {
+		return err
+	}
+	if err := os.Remove(app.ServiceSocketFile()); err != nil && !os.IsNotExist(err) {
+		logger.Noticef("Failed to remove socket file for %q: %v", socketName, err)
+	}
+	return nil
+}
+
 func genServiceFile(appInfo *snap.AppInfo) string {
 	serviceTemplate := `[Unit]
 # Auto-generated, DO NO EDIT
 Description=Service for snap application {{.App.Snap.Name}}.{{.App.Name}}
-After=snapd.frameworks.target{{ if .App.Socket }} {{.SocketFileName}}{{end}}
-Requires=snapd.frameworks.target{{ if .App.Socket }} {{.SocketFileName}}{{end}}
-X-Snappy=yes
+After={{.After}}
+Requires={{.Requires}}
+{{if .Before}}Before={{.Before}}
+{{end}}X-Snappy=yes
 
 [Service]
 ExecStart={{.App.LauncherCommand}}
 Restart={{.Restart}}
 WorkingDirectory={{.App.Snap.DataDir}}
 Environment={{.EnvVars}}
-{{if .App.StopCommand}}ExecStop={{.App.LauncherStopCommand}}{{end}}
+EnvironmentFile=-{{.LocaleFile}}
+{{if .App.PassthroughEnv}}PassEnvironment={{.PassEnv}}
+{{end}}{{if .App.StopCommand}}ExecStop={{.App.LauncherStopCommand}}{{end}}
 {{if .App.PostStopCommand}}ExecStopPost={{.App.LauncherPostStopCommand}}{{end}}
 {{if .StopTimeout}}TimeoutStopSec={{.StopTimeout.Seconds}}{{end}}
-Type={{.App.Daemon}}
+{{if .App.OOMScoreAdjust}}OOMScoreAdjust={{.App.OOMScoreAdjust}}
+{{end}}{{if .App.Nice}}Nice={{.App.Nice}}
+{{end}}{{if .App.IONiceClass}}IOSchedulingClass={{.App.IONiceClass}}
+{{end}}Type={{.App.Daemon}}
 {{if .App.BusName}}BusName={{.App.BusName}}{{end}}
 
 [Install]
@@ -211,31 +470,51 @@ WantedBy={{.ServiceTargetUnit}}
 	if restartCond == "" {
 		restartCond = systemd.RestartOnFailure.String()
 	}
-	socketFileName := ""
+	after := []string{"snapd.frameworks.target"}
+	requires := []string{"snapd.frameworks.target"}
 	if appInfo.Socket {
-		socketFileName = filepath.Base(appInfo.ServiceSocketFile())
+		socketFileName := filepath.Base(appInfo.ServiceSocketFile())
+		after = append(after, socketFileName)
+		requires = append(requires, socketFileName)
 	}
+	after = append(after, unitNames(appInfo, appInfo.After)...)
+	requires = append(requires, appInfo.Requires...)
 
 	wrapperData := struct {
 		App *snap.AppInfo
 
-		SocketFileName    string
+		After             string
+		Before            string
+		Requires          string
 		Restart           string
 		StopTimeout       time.Duration
 		ServiceTargetUnit string
 
-		Home    string
-		EnvVars string
+		Home       string
+		EnvVars    string
+		LocaleFile string
+		PassEnv    string
 	}{
 		App: appInfo,
 
-		SocketFileName:    socketFileName,
+		After:             strings.Join(after, " "),
+		Before:            strings.Join(unitNames(appInfo, appInfo.Before), " "),
+		Requires:          strings.Join(requires, " "),
 		Restart:           restartCond,
 		StopTimeout:       serviceStopTimeout(appInfo),
 		ServiceTargetUnit: systemd.ServicesTarget,
 
 		// systemd runs as PID 1 so %h will not work.
 		Home: "/root",
+
+		// services get the system locale, picking up changes to it
+		// when snapd restarts them, as snapd.locale-changed.service
+		// asks it to
+		LocaleFile: "/etc/default/locale",
+
+		// variables taken from the environment of systemd, everything
+		// else it has is not passed on
+		PassEnv: strings.Join(appInfo.PassthroughEnv, " "),
 	}
 	allVars := snapenv.Basic(appInfo.Snap)
 	allVars = append(allVars, snapenv.User(appInfo.Snap, "/root")...)
@@ -249,6 +528,19 @@ WantedBy={{.ServiceTargetUnit}}
 	return templateOut.String()
 }
 
+// unitNames maps the names of other services of the snap the app
+// refers to onto their service units, leaving host units as they are.
+func unitNames(app *snap.AppInfo, names []string) []string {
+	units := make([]string, len(names))
+	for i, name := range names {
+		units[i] = name
+		if other := app.Snap.Apps[name]; other != nil && other.Daemon != "" {
+			units[i] = filepath.Base(other.ServiceFile())
+		}
+	}
+	return units
+}
+
 func genSocketFile(appInfo *snap.AppInfo) string {
 	serviceTemplate := `[Unit]
 # Auto-generated, DO NO EDIT
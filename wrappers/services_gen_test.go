@@ -45,7 +45,8 @@ X-Snappy=yes
 ExecStart=/usr/bin/ubuntu-core-launcher snap.snap.app snap.snap.app /snap/snap/44/bin/start
 Restart=on-failure
 WorkingDirectory=/var/snap/snap/44
-Environment="SNAP=/snap/snap/44" "SNAP_DATA=/var/snap/snap/44" "SNAP_NAME=snap" "SNAP_VERSION=1.0" "SNAP_REVISION=44" "SNAP_ARCH=%[3]s" "SNAP_LIBRARY_PATH=/var/lib/snapd/lib/gl:" "SNAP_USER_DATA=/root/snap/snap/44"
+Environment="SNAP=/snap/snap/44" "SNAP_DATA=/var/snap/snap/44" "SNAP_NAME=snap" "SNAP_VERSION=1.0" "SNAP_REVISION=44" "SNAP_ARCH=%[3]s" "SNAP_LIBRARY_PATH=/var/lib/snapd/lib/gl:/var/lib/snapd/lib/gl32:" "SNAP_USER_DATA=/root/snap/snap/44"
+EnvironmentFile=-/etc/default/locale
 ExecStop=/usr/bin/ubuntu-core-launcher snap.snap.app snap.snap.app /snap/snap/44/bin/stop
 ExecStopPost=/usr/bin/ubuntu-core-launcher snap.snap.app snap.snap.app /snap/snap/44/bin/stop --post
 TimeoutStopSec=10
@@ -71,7 +72,8 @@ X-Snappy=yes
 ExecStart=/usr/bin/ubuntu-core-launcher snap.xkcd-webserver.xkcd-webserver snap.xkcd-webserver.xkcd-webserver /snap/xkcd-webserver/44/bin/foo start
 Restart=on-failure
 WorkingDirectory=/var/snap/xkcd-webserver/44
-Environment="SNAP=/snap/xkcd-webserver/44" "SNAP_DATA=/var/snap/xkcd-webserver/44" "SNAP_NAME=xkcd-webserver" "SNAP_VERSION=0.3.4" "SNAP_REVISION=44" "SNAP_ARCH=%[3]s" "SNAP_LIBRARY_PATH=/var/lib/snapd/lib/gl:" "SNAP_USER_DATA=/root/snap/xkcd-webserver/44"
+Environment="SNAP=/snap/xkcd-webserver/44" "SNAP_DATA=/var/snap/xkcd-webserver/44" "SNAP_NAME=xkcd-webserver" "SNAP_VERSION=0.3.4" "SNAP_REVISION=44" "SNAP_ARCH=%[3]s" "SNAP_LIBRARY_PATH=/var/lib/snapd/lib/gl:/var/lib/snapd/lib/gl32:" "SNAP_USER_DATA=/root/snap/xkcd-webserver/44"
+EnvironmentFile=-/etc/default/locale
 ExecStop=/usr/bin/ubuntu-core-launcher snap.xkcd-webserver.xkcd-webserver snap.xkcd-webserver.xkcd-webserver /snap/xkcd-webserver/44/bin/foo stop
 ExecStopPost=/usr/bin/ubuntu-core-launcher snap.xkcd-webserver.xkcd-webserver snap.xkcd-webserver.xkcd-webserver /snap/xkcd-webserver/44/bin/foo post-stop
 TimeoutStopSec=30
@@ -289,3 +291,108 @@ func (s *servicesWrapperGenSuite) TestGenerateSnapSocketFileMode(c *C) {
 	c.Assert(content, Matches, "(?ms).*SocketMode=0600")
 
 }
+
+func (s *servicesWrapperGenSuite) TestGenerateSnapServiceFileHostUnits(c *C) {
+	yamlText := `
+name: snap
+version: 1.0
+apps:
+    app:
+        command: bin/start
+        daemon: simple
+        after: [network-online.target, time-sync.target]
+        before: [sound.target]
+        requires: [time-sync.target]
+`
+	info, err := snap.InfoFromSnapYaml([]byte(yamlText))
+	c.Assert(err, IsNil)
+	info.Revision = snap.R(44)
+	app := info.Apps["app"]
+
+	wrapperText, err := wrappers.GenerateSnapServiceFile(app)
+	c.Assert(err, IsNil)
+	c.Check(wrapperText, Matches, `(?ms).*^After=snapd.frameworks.target network-online.target time-sync.target
+Requires=snapd.frameworks.target time-sync.target
+Before=sound.target
+X-Snappy=yes$.*`)
+}
+
+func (s *servicesWrapperGenSuite) TestGenerateSnapServiceFileBadHostUnit(c *C) {
+	yamlText := `
+name: snap
+version: 1.0
+apps:
+    app:
+        command: bin/start
+        daemon: simple
+        after: [ssh.service]
+`
+	info, err := snap.InfoFromSnapYaml([]byte(yamlText))
+	c.Assert(err, IsNil)
+	info.Revision = snap.R(44)
+
+	_, err = wrappers.GenerateSnapServiceFile(info.Apps["app"])
+	c.Assert(err, ErrorMatches, `"after" field contains unsupported host unit "ssh.service"`)
+}
+
+func (s *servicesWrapperGenSuite) TestGenerateSnapServiceFileScheduling(c *C) {
+	yamlText := `
+name: snap
+version: 1.0
+apps:
+    app:
+        command: bin/start
+        daemon: simple
+        oom-score-adjust: 500
+        nice: 10
+        ionice-class: idle
+`
+	info, err := snap.InfoFromSnapYaml([]byte(yamlText))
+	c.Assert(err, IsNil)
+	info.Revision = snap.R(44)
+
+	wrapperText, err := wrappers.GenerateSnapServiceFile(info.Apps["app"])
+	c.Assert(err, IsNil)
+	c.Check(wrapperText, Matches, `(?ms).*^OOMScoreAdjust=500
+Nice=10
+IOSchedulingClass=idle
+Type=simple$.*`)
+}
+
+func (s *servicesWrapperGenSuite) TestGenerateSnapServiceFilePassthroughEnv(c *C) {
+	yamlText := `
+name: snap
+version: 1.0
+apps:
+    app:
+        command: bin/start
+        daemon: simple
+        passthrough-env: [http_proxy, JAVA_HOME]
+`
+	info, err := snap.InfoFromSnapYaml([]byte(yamlText))
+	c.Assert(err, IsNil)
+	info.Revision = snap.R(44)
+
+	wrapperText, err := wrappers.GenerateSnapServiceFile(info.Apps["app"])
+	c.Assert(err, IsNil)
+	c.Check(wrapperText, Matches, `(?ms).*^EnvironmentFile=-/etc/default/locale
+PassEnvironment=http_proxy JAVA_HOME$.*`)
+}
+
+func (s *servicesWrapperGenSuite) TestGenerateSnapServiceFileBadNice(c *C) {
+	yamlText := `
+name: snap
+version: 1.0
+apps:
+    app:
+        command: bin/start
+        daemon: simple
+        nice: 20
+`
+	info, err := snap.InfoFromSnapYaml([]byte(yamlText))
+	c.Assert(err, IsNil)
+	info.Revision = snap.R(44)
+
+	_, err = wrappers.GenerateSnapServiceFile(info.Apps["app"])
+	c.Assert(err, ErrorMatches, `"nice" field contains invalid value 20 \(must be between -20 and 19\)`)
+}
@@ -102,6 +102,79 @@ func (s *servicesTestSuite) TestAddSnapServicesAndRemove(c *C) {
 	c.Check(sysdLog[3], DeepEquals, []string{"daemon-reload"})
 }
 
+func (s *servicesTestSuite) TestAddSnapServicesStartFailureKeepsLog(c *C) {
+	systemd.SystemctlCmd = func(cmd ...string) ([]byte, error) {
+		if cmd[0] == "start" {
+			return nil, fmt.Errorf("systemctl start failed with exit status 1")
+		}
+		return nil, nil
+	}
+	prevJctl := systemd.JournalctlCmd
+	defer func() { systemd.JournalctlCmd = prevJctl }()
+	var jctlArgs []string
+	systemd.JournalctlCmd = func(svcs []string) ([]byte, error) {
+		jctlArgs = svcs
+		var out []byte
+		for i := 0; i < 25; i++ {
+			out = append(out, fmt.Sprintf(`{"__REALTIME_TIMESTAMP":"%d000000","SYSLOG_IDENTIFIER":"svc1","MESSAGE":"line %d"}`+"\n", 1475323200+i, i)...)
+		}
+		return out, nil
+	}
+
+	info := snaptest.MockSnap(c, packageHello, &snap.SideInfo{Revision: snap.R(12)})
+
+	err := wrappers.AddSnapServices(info, nil)
+	c.Assert(err, ErrorMatches, "systemctl start failed with exit status 1")
+	c.Check(jctlArgs, DeepEquals, []string{"snap.hello-snap.svc1.service"})
+
+	e, ok := err.(*wrappers.ServiceStartError)
+	c.Assert(ok, Equals, true)
+	c.Check(e.Service, Equals, "snap.hello-snap.svc1.service")
+	c.Assert(e.Log, HasLen, 20)
+	c.Check(e.Log[0], Equals, "2016-10-01T12:00:05.000000Z svc1 line 5")
+	c.Check(e.Log[19], Equals, "2016-10-01T12:00:24.000000Z svc1 line 24")
+}
+
+func (s *servicesTestSuite) TestEnableSnapServicesDoesNotStart(c *C) {
+	var sysdLog [][]string
+	systemd.SystemctlCmd = func(cmd ...string) ([]byte, error) {
+		sysdLog = append(sysdLog, cmd)
+		return []byte("ActiveState=inactive\n"), nil
+	}
+
+	info := snaptest.MockSnap(c, packageHello, &snap.SideInfo{Revision: snap.R(12)})
+
+	err := wrappers.EnableSnapServices(info, nil)
+	c.Assert(err, IsNil)
+
+	svcFile := filepath.Join(s.tempdir, "/etc/systemd/system/snap.hello-snap.svc1.service")
+	c.Check(osutil.FileExists(svcFile), Equals, true)
+
+	c.Check(sysdLog, DeepEquals, [][]string{
+		{"--root", dirs.GlobalRootDir, "enable", filepath.Base(svcFile)},
+	})
+}
+
+func (s *servicesTestSuite) TestWriteSnapServicesDoesNotEnable(c *C) {
+	var sysdLog [][]string
+	systemd.SystemctlCmd = func(cmd ...string) ([]byte, error) {
+		sysdLog = append(sysdLog, cmd)
+		return []byte("ActiveState=inactive\n"), nil
+	}
+
+	info := snaptest.MockSnap(c, packageHello, &snap.SideInfo{Revision: snap.R(12)})
+
+	err := wrappers.WriteSnapServices(info, nil)
+	c.Assert(err, IsNil)
+
+	svcFile := filepath.Join(s.tempdir, "/etc/systemd/system/snap.hello-snap.svc1.service")
+	c.Check(osutil.FileExists(svcFile), Equals, true)
+
+	c.Check(sysdLog, DeepEquals, [][]string{
+		{"daemon-reload"},
+	})
+}
+
 func (s *servicesTestSuite) TestRemoveSnapPackageFallbackToKill(c *C) {
 	restore := wrappers.MockKillWait(200 * time.Millisecond)
 	defer restore()
@@ -139,3 +212,199 @@ apps:
 
 	c.Check(sysdLog[len(sysdLog)-1], DeepEquals, []string{"daemon-reload"})
 }
+
+const orderedServicesYaml = `name: ordered
+version: 1.0
+apps:
+ db:
+  command: db
+  daemon: simple
+ web:
+  command: web
+  daemon: simple
+  after: [db]
+`
+
+// startsAndStops filters the systemctl calls that start or stop units
+func startsAndStops(sysdLog [][]string) [][]string {
+	var calls [][]string
+	for _, cmd := range sysdLog {
+		if cmd[0] == "start" || cmd[0] == "stop" {
+			calls = append(calls, cmd)
+		}
+	}
+	return calls
+}
+
+func (s *servicesTestSuite) TestAddAndRemoveSnapServicesOrdering(c *C) {
+	var sysdLog [][]string
+	systemd.SystemctlCmd = func(cmd ...string) ([]byte, error) {
+		sysdLog = append(sysdLog, cmd)
+		return []byte("ActiveState=inactive\n"), nil
+	}
+
+	info := snaptest.MockSnap(c, orderedServicesYaml, &snap.SideInfo{Revision: snap.R(1)})
+
+	err := wrappers.AddSnapServices(info, nil)
+	c.Assert(err, IsNil)
+
+	content, err := ioutil.ReadFile(info.Apps["web"].ServiceFile())
+	c.Assert(err, IsNil)
+	c.Check(string(content), Matches, "(?ms).*^After=snapd.frameworks.target snap.ordered.db.service$.*")
+
+	c.Check(sysdLog[0], DeepEquals, []string{"daemon-reload"})
+	c.Check(startsAndStops(sysdLog), DeepEquals, [][]string{
+		{"start", "snap.ordered.db.service"},
+		{"start", "snap.ordered.web.service"},
+	})
+
+	sysdLog = nil
+	err = wrappers.RemoveSnapServices(info, &progress.NullProgress{})
+	c.Assert(err, IsNil)
+	c.Check(startsAndStops(sysdLog), DeepEquals, [][]string{
+		{"stop", "snap.ordered.web.service"},
+		{"stop", "snap.ordered.db.service"},
+	})
+}
+
+const enduringProxyYaml = `name: proxy
+version: 1.0
+apps:
+ proxy:
+  command: proxy
+  daemon: simple
+  socket: true
+  listen-stream: /var/snap/proxy/common/proxy.socket
+  refresh-mode: %s
+ stats:
+  command: stats
+  daemon: simple
+`
+
+func (s *servicesTestSuite) TestRemoveSnapServicesForRefreshEndure(c *C) {
+	var sysdLog [][]string
+	systemd.SystemctlCmd = func(cmd ...string) ([]byte, error) {
+		sysdLog = append(sysdLog, cmd)
+		return []byte("ActiveState=inactive\n"), nil
+	}
+
+	oldInfo := snaptest.MockSnap(c, fmt.Sprintf(enduringProxyYaml, "endure"), &snap.SideInfo{Revision: snap.R(1)})
+	newInfo := snaptest.MockSnap(c, fmt.Sprintf(enduringProxyYaml, "endure"), &snap.SideInfo{Revision: snap.R(2)})
+
+	err := wrappers.AddSnapServices(oldInfo, nil)
+	c.Assert(err, IsNil)
+
+	sysdLog = nil
+	err = wrappers.RemoveSnapServicesForRefresh(oldInfo, newInfo, &progress.NullProgress{})
+	c.Assert(err, IsNil)
+
+	// only the service that does not endure was stopped
+	c.Check(startsAndStops(sysdLog), DeepEquals, [][]string{
+		{"stop", "snap.proxy.stats.service"},
+	})
+	proxy := oldInfo.Apps["proxy"]
+	c.Check(osutil.FileExists(proxy.ServiceFile()), Equals, true)
+	c.Check(osutil.FileExists(proxy.ServiceSocketFile()), Equals, true)
+	c.Check(osutil.FileExists(oldInfo.Apps["stats"].ServiceFile()), Equals, false)
+
+	// the new revision restarts the service whose unit changed and
+	// leaves its unchanged socket listening
+	sysdLog = nil
+	err = wrappers.AddSnapServices(newInfo, nil)
+	c.Assert(err, IsNil)
+	c.Check(startsAndStops(sysdLog), DeepEquals, [][]string{
+		{"stop", "snap.proxy.proxy.service"},
+		{"start", "snap.proxy.proxy.service"},
+		{"start", "snap.proxy.proxy.socket"},
+		{"start", "snap.proxy.stats.service"},
+	})
+
+	// linking the same revision again does not restart anything
+	sysdLog = nil
+	err = wrappers.AddSnapServices(newInfo, nil)
+	c.Assert(err, IsNil)
+	c.Check(startsAndStops(sysdLog), DeepEquals, [][]string{
+		{"start", "snap.proxy.proxy.service"},
+		{"start", "snap.proxy.proxy.socket"},
+		{"start", "snap.proxy.stats.service"},
+	})
+}
+
+func (s *servicesTestSuite) TestRemoveSnapServicesForRefreshRestart(c *C) {
+	var sysdLog [][]string
+	systemd.SystemctlCmd = func(cmd ...string) ([]byte, error) {
+		sysdLog = append(sysdLog, cmd)
+		return []byte("ActiveState=inactive\n"), nil
+	}
+
+	oldInfo := snaptest.MockSnap(c, fmt.Sprintf(enduringProxyYaml, "endure"), &snap.SideInfo{Revision: snap.R(1)})
+	newInfo := snaptest.MockSnap(c, fmt.Sprintf(enduringProxyYaml, "restart"), &snap.SideInfo{Revision: snap.R(2)})
+
+	err := wrappers.AddSnapServices(oldInfo, nil)
+	c.Assert(err, IsNil)
+
+	// the new revision decides, and it does not let the proxy endure
+	sysdLog = nil
+	err = wrappers.RemoveSnapServicesForRefresh(oldInfo, newInfo, &progress.NullProgress{})
+	c.Assert(err, IsNil)
+	c.Check(startsAndStops(sysdLog), DeepEquals, [][]string{
+		{"stop", "snap.proxy.stats.service"},
+		{"stop", "snap.proxy.proxy.service"},
+	})
+	c.Check(osutil.FileExists(oldInfo.Apps["proxy"].ServiceSocketFile()), Equals, false)
+}
+
+func (s *servicesTestSuite) TestRestartSnapServices(c *C) {
+	var sysdLog [][]string
+	stopped := map[string]bool{}
+	systemd.SystemctlCmd = func(cmd ...string) ([]byte, error) {
+		sysdLog = append(sysdLog, cmd)
+		unit := cmd[len(cmd)-1]
+		if cmd[0] == "stop" {
+			stopped[unit] = true
+		}
+		if cmd[0] == "show" && !stopped[unit] && unit != "snap.ordered.idle.service" {
+			return []byte("ActiveState=active\n"), nil
+		}
+		return []byte("ActiveState=inactive\n"), nil
+	}
+
+	info := snaptest.MockSnap(c, orderedServicesYaml+` idle:
+  command: idle
+  daemon: simple
+ enduring:
+  command: enduring
+  daemon: simple
+  refresh-mode: endure
+`, &snap.SideInfo{Revision: snap.R(1)})
+
+	err := wrappers.RestartSnapServices(info, &progress.NullProgress{})
+	c.Assert(err, IsNil)
+	// the running services are restarted in order, but for the one
+	// that endures
+	c.Check(startsAndStops(sysdLog), DeepEquals, [][]string{
+		{"stop", "snap.ordered.web.service"},
+		{"stop", "snap.ordered.db.service"},
+		{"start", "snap.ordered.db.service"},
+		{"start", "snap.ordered.web.service"},
+	})
+}
+
+func (s *servicesTestSuite) TestInactiveSnapServices(c *C) {
+	var queried []string
+	systemd.SystemctlCmd = func(cmd ...string) ([]byte, error) {
+		unit := cmd[len(cmd)-1]
+		queried = append(queried, unit)
+		if unit == "snap.proxy.stats.service" {
+			return []byte("ActiveState=failed\n"), nil
+		}
+		return []byte("ActiveState=active\n"), nil
+	}
+
+	info := snaptest.MockSnap(c, fmt.Sprintf(enduringProxyYaml, "restart"), &snap.SideInfo{Revision: snap.R(1)})
+	inactive, err := wrappers.InactiveSnapServices(info, &progress.NullProgress{})
+	c.Assert(err, IsNil)
+	c.Check(inactive, DeepEquals, []string{"snap.proxy.stats.service"})
+	// the socket is what is up for the socket activated service
+	c.Check(queried, DeepEquals, []string{"snap.proxy.proxy.socket", "snap.proxy.stats.service"})
+}